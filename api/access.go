@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/api/router/handlers"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// roleRedaction resolves the role of each API request from its bearer token and withholds what
+// the role may not see from the response: stored credentials, secrets matches and bodies over the
+// role's limit, per api_access.roles. Unknown tokens are rejected. Event streams are redacted event
+// by event as they are written. The request's user (core.APIUser) is the token holder, else its
+// X-Toolkit-User header.
+func roleRedaction(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, holder, err := core.ResolveAPIRole(r.Header.Get("Authorization"))
+		if err != nil {
+			logger.Error("API: Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			handlers.WriteProblem(w, r, http.StatusUnauthorized, models.ErrCodeUnauthorized, "Invalid API token")
+			return
+		}
+		r = r.WithContext(core.WithAPIUser(r, holder))
+		policy, restricted := core.APIRoleRedaction(role)
+		if !restricted {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if holder != "" {
+			logger.Debug("API: %s %s as %s (%s)", r.Method, r.URL.Path, holder, role)
+		}
+		rw := &redactingResponseWriter{ResponseWriter: w, status: http.StatusOK, request: r, role: role, policy: policy}
+		next.ServeHTTP(rw, r)
+		if rw.streaming {
+			// An event cut short by the end of the stream is redacted too.
+			rw.writeEvent(rw.pending.Bytes())
+			return
+		}
+
+		body := rw.buf.Bytes()
+		redacted := false
+		contentType := strings.ToLower(w.Header().Get("Content-Type"))
+		switch {
+		case len(body) == 0:
+		case strings.Contains(contentType, "json") || (contentType == "" || strings.HasPrefix(contentType, "text/plain")) && json.Valid(body):
+			if out, changed, err := core.RedactAPIResponse(routePattern(r), body, policy); err != nil {
+				logger.Error("API: Redacting %s for role %s: %v", r.URL.Path, role, err)
+			} else {
+				body, redacted = out, changed
+			}
+		case strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "javascript") || strings.Contains(contentType, "xml"):
+			body, redacted = core.RedactAPIText(body, policy)
+		}
+		if redacted {
+			w.Header().Set("X-Toolkit-Redacted", role)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(rw.status)
+		w.Write(body)
+	})
+}
+
+// routePattern returns the chi route pattern of a request, "" before it is routed.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// redactingResponseWriter holds a response back for redaction. Event streams are written as their
+// events complete, each redacted on its own; a partial event waits in pending for its end.
+type redactingResponseWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	role        string
+	policy      config.APIRoleConfig
+	status      int
+	wroteHeader bool
+	streaming   bool
+	buf         bytes.Buffer
+	pending     bytes.Buffer
+}
+
+func (w *redactingResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.streaming = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *redactingResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.streaming {
+		w.pending.Write(data)
+		for {
+			event, rest, found := bytes.Cut(w.pending.Bytes(), []byte("\n\n"))
+			if !found {
+				break
+			}
+			if err := w.writeEvent(append(event, '\n', '\n')); err != nil {
+				return 0, err
+			}
+			// rest aliases pending's buffer; copy it out before resetting.
+			rest = append([]byte(nil), rest...)
+			w.pending.Reset()
+			w.pending.Write(rest)
+		}
+		return len(data), nil
+	}
+	return w.buf.Write(data)
+}
+
+// writeEvent redacts the data of a server-sent event and writes the event. Data that is JSON is
+// redacted as a JSON response of the route, other data as text; comments and the event's name and
+// ID are kept.
+func (w *redactingResponseWriter) writeEvent(event []byte) error {
+	if len(event) == 0 {
+		return nil
+	}
+	var lines, data []string
+	dataAt := -1
+	for _, line := range strings.Split(strings.TrimRight(string(event), "\n"), "\n") {
+		value, isData := strings.CutPrefix(strings.TrimSuffix(line, "\r"), "data:")
+		if !isData {
+			lines = append(lines, line)
+			continue
+		}
+		if dataAt < 0 {
+			dataAt = len(lines)
+		}
+		data = append(data, strings.TrimPrefix(value, " "))
+	}
+	if dataAt < 0 {
+		_, err := w.ResponseWriter.Write(event)
+		return err
+	}
+	payload := []byte(strings.Join(data, "\n"))
+	if json.Valid(payload) {
+		out, _, err := core.RedactAPIResponse(routePattern(w.request), payload, w.policy)
+		if err != nil {
+			// Never pass on what could not be redacted.
+			logger.Error("API: Redacting an event of %s for role %s: %v", w.request.URL.Path, w.role, err)
+			out = []byte(`{"error":"event withheld"}`)
+		}
+		payload = out
+	} else {
+		payload, _ = core.RedactAPIText(payload, w.policy)
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if i == dataAt {
+			writeEventData(&b, payload)
+		}
+		b.WriteString(line + "\n")
+	}
+	if dataAt == len(lines) {
+		writeEventData(&b, payload)
+	}
+	b.WriteString("\n")
+	_, err := w.ResponseWriter.Write([]byte(b.String()))
+	return err
+}
+
+// writeEventData writes data as the data lines of an event.
+func writeEventData(b *strings.Builder, data []byte) {
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+}
+
+// Flush flushes the complete events of event streams; other responses are written once complete.
+func (w *redactingResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.streaming {
+		f.Flush()
+	}
+}
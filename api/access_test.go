@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"toolkit/config"
+)
+
+// withAPIAccess sets the API roles of a test: "viewer" tokens get credentials withheld and bodies
+// cut to 16 bytes.
+func withAPIAccess(t *testing.T) {
+	t.Helper()
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	config.AppConfig.APIAccess = config.APIAccessConfig{
+		Tokens: []config.APITokenConfig{{Name: "alice", Token: "viewer-token", Role: "viewer"}},
+		Roles:  map[string]config.APIRoleConfig{"viewer": {RedactCredentials: true, MaxBodyBytes: 16}},
+	}
+	config.AppConfig.Redaction.Headers = []string{"Authorization", "Cookie"}
+	config.AppConfig.Redaction.Replacement = "[REDACTED]"
+}
+
+// secretStream writes two traffic events the way StreamTrafficLogHandler does, split across
+// writes in the middle of an event, then a plain-text event.
+func secretStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	event := `{"id":%d,"request_headers":"{\"Cookie\":[\"session=s3cr3t-%d\"]}","response_body":"%s"}`
+	first := fmt.Sprintf("event: traffic\ndata: "+event+"\nid: 1\n\n", 1, 1, strings.Repeat("A", 64))
+	fmt.Fprint(w, first[:40])
+	w.(http.Flusher).Flush()
+	fmt.Fprint(w, first[40:])
+	fmt.Fprintf(w, "event: traffic\ndata: "+event+"\nid: 2\n\n", 2, 2, "short")
+	fmt.Fprint(w, ": keep-alive\n\n")
+	fmt.Fprint(w, "event: error\ndata: Authorization: Bearer s3cr3t-3\n\n")
+}
+
+func TestRoleRedactionRedactsEventStreams(t *testing.T) {
+	withAPIAccess(t)
+	req := httptest.NewRequest(http.MethodGet, "/traffic-log/stream?full=true", nil)
+	req.Header.Set("Authorization", "Bearer viewer-token")
+	rec := httptest.NewRecorder()
+	roleRedaction(http.HandlerFunc(secretStream)).ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if strings.Contains(body, "s3cr3t") {
+		t.Fatalf("a redacted role got a secret over the event stream:\n%s", body)
+	}
+	if strings.Contains(body, strings.Repeat("A", 64)) || !strings.Contains(body, `"response_body_withheld_bytes":`) {
+		t.Errorf("a body over the role's limit was streamed whole:\n%s", body)
+	}
+	for _, want := range []string{"event: traffic\ndata: {", "id: 1\n\n", "id: 2\n\n", ": keep-alive\n\n", "[REDACTED]", `"response_body":"short"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream lacks %q:\n%s", want, body)
+		}
+	}
+	if n := strings.Count(body, "event: traffic\n"); n != 2 {
+		t.Errorf("got %d traffic events, want 2:\n%s", n, body)
+	}
+}
+
+func TestRoleRedactionKeepsEventStreamsOfAdmins(t *testing.T) {
+	withAPIAccess(t)
+	req := httptest.NewRequest(http.MethodGet, "/traffic-log/stream?full=true", nil)
+	rec := httptest.NewRecorder()
+	roleRedaction(http.HandlerFunc(secretStream)).ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "session=s3cr3t-1") {
+		t.Errorf("the admin stream was redacted:\n%s", rec.Body.String())
+	}
+}
+
+func TestRoleRedactionRedactsEventCutShort(t *testing.T) {
+	withAPIAccess(t)
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.Header.Set("Authorization", "Bearer viewer-token")
+	rec := httptest.NewRecorder()
+	roleRedaction(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"token":"s3cr3t-4"}`)
+	})).ServeHTTP(rec, req)
+
+	if body := rec.Body.String(); strings.Contains(body, "s3cr3t") || !strings.Contains(body, "[REDACTED]") {
+		t.Errorf("an unterminated event was not redacted:\n%s", body)
+	}
+}
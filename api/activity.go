@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// activityCaptureBytes is how much of a response is kept to find the ID of what it created.
+const activityCaptureBytes = 64 << 10
+
+// activitySkippedPaths change collaboration state or are machine traffic, not mutations worth a
+// feed entry.
+var activitySkippedPaths = []string{"/presence", "/locks", "/sync"}
+
+// activityFeed records every successful request that changes data in the activity feed, with the
+// user who made it and the entity it touched, taken from the route: PUT /findings/{finding_id}
+// updates finding 12, POST /findings creates the finding whose ID the response carries and POST
+// /replay/{session_id}/run runs a replay session.
+func activityFeed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if core.ReadOnlyAllows(r.Method, r.URL.Path) || activitySkipped(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		aw := &activityResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(aw, r)
+		if aw.status >= 400 {
+			return
+		}
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		activity := models.Activity{
+			User:   core.APIUser(r.Context()),
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Route:  route,
+			Status: aw.status,
+		}
+		respID, respTargetID := aw.createdIDs()
+		describeActivity(r, &activity, respID)
+		if respTargetID > 0 {
+			activity.TargetID = &respTargetID
+		} else if id, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64); err == nil {
+			activity.TargetID = &id
+		} else if activity.EntityType == "target" {
+			if id, err := strconv.ParseInt(activity.EntityID, 10, 64); err == nil {
+				activity.TargetID = &id
+			}
+		}
+		core.RecordActivity(activity)
+	})
+}
+
+func activitySkipped(path string) bool {
+	for _, p := range activitySkippedPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// describeActivity sets the action and entity of a request from its route. The entity is the last
+// collection/{param} pair; a literal segment after it is the action, or a creation when it names a
+// collection and the response carries an ID.
+func describeActivity(r *http.Request, a *models.Activity, respID string) {
+	var segments []string
+	for _, s := range strings.Split(strings.Trim(a.Route, "/"), "/") {
+		if s != "" && s != "*" {
+			segments = append(segments, s)
+		}
+	}
+	trailing := ""
+	for i, s := range segments {
+		if isRouteParam(s) {
+			continue
+		}
+		if i+1 < len(segments) && isRouteParam(segments[i+1]) {
+			a.EntityType = singularEntity(s)
+			a.EntityID = chi.URLParam(r, strings.Trim(segments[i+1], "{}"))
+			trailing = ""
+			continue
+		}
+		trailing = s
+	}
+
+	switch {
+	case trailing != "" && strings.HasSuffix(trailing, "s") && r.Method == http.MethodPost && respID != "":
+		a.Action = "create"
+		a.EntityType, a.EntityID = singularEntity(trailing), respID
+	case trailing != "" && !strings.HasSuffix(trailing, "s"):
+		a.Action = strings.ReplaceAll(trailing, "-", "_")
+	case r.Method == http.MethodDelete:
+		a.Action = "delete"
+	case r.Method == http.MethodPost && a.EntityID == "":
+		a.Action = "create"
+	default:
+		a.Action = "update"
+	}
+}
+
+func isRouteParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// singularEntity turns a collection segment into an entity type: scope-rules is scope_rule.
+func singularEntity(collection string) string {
+	name := strings.ReplaceAll(collection, "-", "_")
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ss"):
+		return name
+	}
+	return strings.TrimSuffix(name, "s")
+}
+
+// activityResponseWriter keeps the status and the start of a response while writing it through.
+type activityResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+}
+
+func (w *activityResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *activityResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if room := activityCaptureBytes - w.buf.Len(); room > 0 {
+		w.buf.Write(data[:min(room, len(data))])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *activityResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// createdIDs returns the id and target_id members of a JSON object response.
+func (w *activityResponseWriter) createdIDs() (id string, targetID int64) {
+	if !strings.Contains(strings.ToLower(w.Header().Get("Content-Type")), "json") {
+		return "", 0
+	}
+	dec := json.NewDecoder(bytes.NewReader(w.buf.Bytes()))
+	dec.UseNumber()
+	var members map[string]interface{}
+	if dec.Decode(&members) != nil {
+		return "", 0
+	}
+	switch v := members["id"].(type) {
+	case json.Number, string:
+		id = fmt.Sprint(v)
+	}
+	switch v := members["target_id"].(type) {
+	case json.Number:
+		targetID, _ = v.Int64()
+	case map[string]interface{}: // sql.NullInt64
+		if n, ok := v["Int64"].(json.Number); ok && v["Valid"] == true {
+			targetID, _ = n.Int64()
+		}
+	}
+	return id, targetID
+}
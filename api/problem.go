@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/api/router/handlers"
+)
+
+// problemDetails is a fallback for error responses that bypass handlers.WriteProblem: plain text
+// from http.Error, e.g. chi's own 404 and 405 responses, or JSON objects such as
+// models.ErrorResponse. It turns them into problem details, guessing the code from the status, so
+// every API error has the same shape. Handlers write their errors with handle, writeError or
+// WriteProblem and an explicit code instead of relying on it. Other members of a JSON error object
+// are kept. Successful responses, problem details and other content types pass through untouched.
+func problemDetails(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := &problemResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(pw, r)
+		if !pw.buffering {
+			return
+		}
+		body := pw.buf.Bytes()
+		if !strings.HasPrefix(strings.ToLower(w.Header().Get("Content-Type")), "application/json") {
+			handlers.WriteProblem(w, r, pw.status, "", strings.TrimSpace(string(body)))
+			return
+		}
+		var members map[string]interface{}
+		if json.Unmarshal(body, &members) != nil || members == nil {
+			// Not an error object: written as it came.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(pw.status)
+			w.Write(body)
+			return
+		}
+		detail, _ := members["message"].(string)
+		if detail == "" {
+			detail, _ = members["error"].(string)
+		}
+		handlers.WriteProblem(w, r, pw.status, "", detail, members)
+	})
+}
+
+// problemResponseWriter holds back error responses that are converted to problem details.
+type problemResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+}
+
+func (w *problemResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	contentType := strings.ToLower(w.Header().Get("Content-Type"))
+	if status >= 400 && (contentType == "" || strings.HasPrefix(contentType, "text/plain") || strings.HasPrefix(contentType, "application/json")) {
+		w.buffering = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *problemResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush flushes responses that are not held back.
+func (w *problemResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.buffering {
+		f.Flush()
+	}
+}
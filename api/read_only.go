@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"toolkit/api/router/handlers"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// readOnlyMode refuses the requests that would change data while the toolkit runs read-only
+// (server.read_only or --read-only) and marks every response with X-Toolkit-Read-Only, so the UI
+// can tell.
+func readOnlyMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !core.ReadOnly() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-Toolkit-Read-Only", "true")
+		if !core.ReadOnlyAllows(r.Method, r.URL.Path) {
+			logger.Info("API: Refused %s %s from %s in read-only mode", r.Method, r.URL.Path, r.RemoteAddr)
+			handlers.WriteProblem(w, r, http.StatusForbidden, models.ErrCodeForbidden, "the toolkit is in read-only mode")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
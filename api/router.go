@@ -12,6 +12,10 @@ import (
 // All registered paths are relative to the /api base path.
 func NewRouter() http.Handler {
 	router := chi.NewRouter()
+	router.Use(problemDetails) // Fallback turning errors not written as problem details (RFC 7807) into them
+	router.Use(roleRedaction)  // Withholds credentials, secrets and large bodies from restricted roles (api_access)
+	router.Use(readOnlyMode)   // Refuses requests that change data in read-only mode (server.read_only)
+	router.Use(activityFeed)   // Records the changes made through the API, by user, in the activity feed
 
 	handlers.RegisterHealthRoutes(router)
 	handlers.RegisterPlatformRoutes(router)
@@ -32,6 +36,38 @@ func NewRouter() http.Handler {
 	handlers.RegisterSubfinderRoutes(router) // Add subfinder routes
 	handlers.RegisterHttpxRoutes(router)     // Register httpx routes (status and stop)
 	handlers.RegisterTagRoutes(router)       // Register tag and tag association routes
+	handlers.RegisterReplayRoutes(router)
+	handlers.RegisterSecretsRoutes(router)
+	handlers.RegisterJSAnalysisRoutes(router)
+	handlers.RegisterGraphQLRoutes(router)
+	handlers.RegisterSavedViewRoutes(router)
+	handlers.RegisterAnnotationRoutes(router)
+	handlers.RegisterNotificationRoutes(router)
+	handlers.RegisterJobRoutes(router)
+	handlers.RegisterIPAssetRoutes(router)
+	handlers.RegisterCTWatchRoutes(router)
+	handlers.RegisterStatsRoutes(router)
+	handlers.RegisterRedactionRoutes(router)
+	handlers.RegisterClientProfileRoutes(router)
+	handlers.RegisterProgramConstraintRoutes(router)
+	handlers.RegisterCookieJarRoutes(router)
+	handlers.RegisterRateProfileRoutes(router)
+	handlers.RegisterAttachmentRoutes(router)
+	handlers.RegisterCanonicalURLRoutes(router)
+	handlers.RegisterWordlistRoutes(router)
+	handlers.RegisterAuthTokenRoutes(router)
+	handlers.RegisterInboxRoutes(router)
+	handlers.RegisterDNSResolverRoutes(router)
+	handlers.RegisterCloudStorageRoutes(router)
+	handlers.RegisterExternalToolRoutes(router)
+	handlers.RegisterEngagementRoutes(router)
+	handlers.RegisterPayloadRoutes(router)
+	handlers.RegisterProxyScriptRoutes(router)
+	handlers.RegisterPluginRoutes(router)
+	handlers.RegisterSyncRoutes(router)
+	handlers.RegisterCollaborationRoutes(router)
+	handlers.RegisterContentTemplateRoutes(router)
+	handlers.RegisterCaptureSessionRoutes(router)
 
 	// Placeholder/Not Implemented Yet routes
 	handlers.RegisterRelationshipRoutes(router)
@@ -10,6 +10,7 @@ import (
 	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
+	"toolkit/models"
 )
 
 // AnalyzeJavaScriptRequest defines the expected structure for the request body
@@ -21,47 +22,48 @@ type AnalyzeJavaScriptRequest struct {
 // AnalyzeJavaScriptResponse defines the structure for the analysis results
 // returned by the AnalyzeJSLinksHandler.
 type AnalyzeJavaScriptResponse struct {
-	LogID   int64               `json:"log_id"`
-	Results map[string][]string `json:"results"`
-	Message string              `json:"message,omitempty"`
+	LogID    int64                  `json:"log_id"`
+	Results  map[string][]string    `json:"results"`
+	Pipeline *core.JSPipelineResult `json:"pipeline,omitempty"` // Stored JS file version, endpoints and bundle diff
+	Message  string                 `json:"message,omitempty"`
 }
 
 // AnalyzeJSLinksHandler handles requests to analyze JavaScript content from a logged HTTP response.
 // It fetches the response body for a given http_log_id, checks if it's JavaScript or JSON,
-// runs the analysis using core.AnalyzeJSContent, and returns the extracted items.
+// runs the analysis pipeline (core.RunJSPipeline), and returns the extracted items.
 func AnalyzeJSLinksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("AnalyzeJSLinksHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req AnalyzeJavaScriptRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("AnalyzeJSLinksHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.HTTPLogID == 0 {
 		logger.Error("AnalyzeJSLinksHandler: http_log_id is required")
-		http.Error(w, "http_log_id is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "http_log_id is required")
 		return
 	}
 
 	var resBodyBytes []byte
 	var contentType sql.NullString
-	query := `SELECT response_body, response_content_type FROM http_traffic_log WHERE id = ?`
+	query := `SELECT ` + database.TrafficBodyExpr("http_traffic_log", "response_body") + `, response_content_type FROM http_traffic_log WHERE id = ?`
 	err := database.DB.QueryRow(query, req.HTTPLogID).Scan(&resBodyBytes, &contentType)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("AnalyzeJSLinksHandler: Log entry with ID %d not found", req.HTTPLogID)
-			http.Error(w, fmt.Sprintf("Log entry with ID %d not found", req.HTTPLogID), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry with ID %d not found", req.HTTPLogID))
 		} else {
 			logger.Error("AnalyzeJSLinksHandler: Error querying log ID %d: %v", req.HTTPLogID, err)
-			http.Error(w, "Error retrieving log entry from database", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error retrieving log entry from database")
 		}
 		return
 	}
@@ -89,18 +91,21 @@ func AnalyzeJSLinksHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Info("AnalyzeJSLinksHandler: Analyzing JS/JSON content for log ID %d", req.HTTPLogID)
-	results, analysisErr := core.AnalyzeJSContent(resBodyBytes, req.HTTPLogID)
+	results, pipeline, analysisErr := core.RunJSPipeline(req.HTTPLogID)
 
 	if analysisErr != nil {
 		logger.Error("AnalyzeJSLinksHandler: Analysis failed for log ID %d: %v", req.HTTPLogID, analysisErr)
+		if results == nil {
+			results = make(map[string][]string)
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(AnalyzeJavaScriptResponse{LogID: req.HTTPLogID, Results: make(map[string][]string), Message: fmt.Sprintf("Analysis encountered an error: %v", analysisErr)})
+		json.NewEncoder(w).Encode(AnalyzeJavaScriptResponse{LogID: req.HTTPLogID, Results: results, Pipeline: pipeline, Message: fmt.Sprintf("Analysis encountered an error: %v", analysisErr)})
 		return
 	}
 
 	logger.Info("AnalyzeJSLinksHandler: Analysis complete for log ID %d. Found %d categories of results.", req.HTTPLogID, len(results))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(AnalyzeJavaScriptResponse{LogID: req.HTTPLogID, Results: results})
+	json.NewEncoder(w).Encode(AnalyzeJavaScriptResponse{LogID: req.HTTPLogID, Results: results, Pipeline: pipeline})
 }
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeAnnotationError maps annotation errors to HTTP status codes.
+func writeAnnotationError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "unsupported entity type"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Annotation operation failed")
+	}
+}
+
+// parseAnnotationTime accepts an RFC3339 timestamp or a YYYY-MM-DD date.
+func parseAnnotationTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected RFC3339 or YYYY-MM-DD", value)
+}
+
+// parseAnnotationFilters reads the list query parameters shared by the annotation and activity endpoints.
+func parseAnnotationFilters(r *http.Request) (models.AnnotationFilters, error) {
+	q := r.URL.Query()
+	filters := models.AnnotationFilters{
+		EntityType: q.Get("entity_type"),
+		Search:     q.Get("search"),
+		SortOrder:  q.Get("sort_order"),
+	}
+	filters.TargetID, _ = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	filters.EntityID, _ = strconv.ParseInt(q.Get("entity_id"), 10, 64)
+	filters.Page, _ = strconv.Atoi(q.Get("page"))
+	filters.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.Limit <= 0 || filters.Limit > 500 {
+		filters.Limit = 50
+	}
+	for param, dest := range map[string]*time.Time{"since": &filters.Since, "until": &filters.Until} {
+		if value := q.Get(param); value != "" {
+			t, err := parseAnnotationTime(value)
+			if err != nil {
+				return filters, err
+			}
+			*dest = t
+		}
+	}
+	return filters, nil
+}
+
+// writeAnnotationList runs an annotation query and writes the paginated response.
+func writeAnnotationList(w http.ResponseWriter, r *http.Request, handler string, filters models.AnnotationFilters) {
+	annotations, total, err := database.GetAnnotations(filters)
+	if err != nil {
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve annotations")
+		return
+	}
+	totalPages := (total + int64(filters.Limit) - 1) / int64(filters.Limit)
+	response := struct {
+		Page         int                 `json:"page"`
+		Limit        int                 `json:"limit"`
+		TotalRecords int64               `json:"total_records"`
+		TotalPages   int64               `json:"total_pages"`
+		Annotations  []models.Annotation `json:"annotations"`
+	}{filters.Page, filters.Limit, total, totalPages, annotations}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAnnotationsHandler lists annotations, oldest first.
+// Query parameters: entity_type, entity_id, target_id, search, since, until, sort_order, page, limit.
+func GetAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	filters, err := parseAnnotationFilters(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	writeAnnotationList(w, r, "GetAnnotationsHandler", filters)
+}
+
+// GetTargetActivityHandler returns every annotation of a target in chronological order, i.e. the
+// engagement activity log. Accepts the same query parameters as GetAnnotationsHandler.
+func GetTargetActivityHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	filters, err := parseAnnotationFilters(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	filters.TargetID = targetID
+	writeAnnotationList(w, r, "GetTargetActivityHandler", filters)
+}
+
+// CreateAnnotationHandler adds a comment to a traffic log, domain, finding, checklist item or modifier task.
+func CreateAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.AnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if strings.TrimSpace(req.Content) == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "content is required")
+		return
+	}
+	if req.EntityID <= 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "entity_id is required")
+		return
+	}
+
+	id, err := database.CreateAnnotation(req.EntityType, req.EntityID, req.Content)
+	if err != nil {
+		writeAnnotationError(w, r, "CreateAnnotationHandler", err)
+		return
+	}
+	created, err := database.GetAnnotationByID(id)
+	if err != nil {
+		writeAnnotationError(w, r, "CreateAnnotationHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetAnnotationHandler returns a single annotation.
+func GetAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	annotationID, err := strconv.ParseInt(chi.URLParam(r, "annotation_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid annotation ID")
+		return
+	}
+	annotation, err := database.GetAnnotationByID(annotationID)
+	if err != nil {
+		writeAnnotationError(w, r, "GetAnnotationHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotation)
+}
+
+// UpdateAnnotationHandler edits the content of an annotation. The entity it is attached to cannot be changed.
+func UpdateAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	annotationID, err := strconv.ParseInt(chi.URLParam(r, "annotation_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid annotation ID")
+		return
+	}
+	var req models.AnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if strings.TrimSpace(req.Content) == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "content is required")
+		return
+	}
+
+	if err := database.UpdateAnnotation(annotationID, req.Content); err != nil {
+		writeAnnotationError(w, r, "UpdateAnnotationHandler", err)
+		return
+	}
+	updated, err := database.GetAnnotationByID(annotationID)
+	if err != nil {
+		writeAnnotationError(w, r, "UpdateAnnotationHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteAnnotationHandler removes an annotation.
+func DeleteAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	annotationID, err := strconv.ParseInt(chi.URLParam(r, "annotation_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid annotation ID")
+		return
+	}
+	if err := database.DeleteAnnotation(annotationID); err != nil {
+		writeAnnotationError(w, r, "DeleteAnnotationHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterAnnotationRoutes(r chi.Router) {
+	r.Get("/annotations", GetAnnotationsHandler) // ?entity_type=&entity_id=&target_id=
+	r.Post("/annotations", CreateAnnotationHandler)
+	r.Get("/annotations/{annotation_id}", GetAnnotationHandler)
+	r.Put("/annotations/{annotation_id}", UpdateAnnotationHandler)
+	r.Delete("/annotations/{annotation_id}", DeleteAnnotationHandler)
+
+	// Engagement activity log: all annotations of a target, chronologically.
+	r.Get("/targets/{target_id}/activity", GetTargetActivityHandler)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// attachmentFormMemory is how much of a multipart upload is kept in memory before it spills to a
+// temporary file.
+const attachmentFormMemory = 8 << 20
+
+// writeAttachmentError maps attachment errors to HTTP status codes.
+func writeAttachmentError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "unsupported entity type"), strings.HasPrefix(msg, "invalid attachment"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.HasPrefix(msg, "attachment too large"):
+		WriteProblem(w, r, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Attachment operation failed")
+	}
+}
+
+// GetAttachmentsHandler lists attachments, newest first.
+// Query parameters: entity_type, entity_id, target_id.
+func GetAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.AttachmentFilters{EntityType: q.Get("entity_type")}
+	filters.TargetID, _ = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	filters.EntityID, _ = strconv.ParseInt(q.Get("entity_id"), 10, 64)
+	attachments, err := core.GetAttachments(filters)
+	if err != nil {
+		writeAttachmentError(w, r, "GetAttachmentsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// UploadAttachmentHandler attaches a file to a target, finding, note or checklist item. It takes
+// a multipart form with the file in 'file' and the entity_type, entity_id and an optional
+// description. Uploading a file already attached to the entity returns the existing attachment
+// with 200 instead of 201.
+func UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := config.AppConfig.Attachment.MaxFileBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+attachmentFormMemory)
+	defer r.Body.Close()
+	if err := r.ParseMultipartForm(attachmentFormMemory); err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			WriteProblem(w, r, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, "attachment too large: files are limited to "+strconv.FormatInt(maxBytes, 10)+" bytes (attachments.max_file_bytes)")
+			return
+		}
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid multipart form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Missing 'file' field: "+err.Error())
+		return
+	}
+	defer file.Close()
+	entityID, err := strconv.ParseInt(r.FormValue("entity_id"), 10, 64)
+	if err != nil || entityID <= 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "entity_id is required")
+		return
+	}
+
+	attachment, err := core.AttachFile(r.FormValue("entity_type"), entityID, header.Filename, header.Header.Get("Content-Type"),
+		r.FormValue("description"), file)
+	if err != nil {
+		writeAttachmentError(w, r, "UploadAttachmentHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !attachment.Existing {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// GetAttachmentHandler returns the metadata of an attachment.
+func GetAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachment_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid attachment ID")
+		return
+	}
+	attachment, err := core.GetAttachment(attachmentID)
+	if err != nil {
+		writeAttachmentError(w, r, "GetAttachmentHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// DownloadAttachmentHandler serves the content of an attachment. Images are shown inline with
+// ?inline=true; everything else is always a download, so uploaded HTML or SVG cannot run in the
+// toolkit's origin.
+func DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachment_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid attachment ID")
+		return
+	}
+	attachment, file, err := core.OpenAttachment(attachmentID)
+	if err != nil {
+		writeAttachmentError(w, r, "DownloadAttachmentHandler", err)
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	inline, _ := strconv.ParseBool(r.URL.Query().Get("inline"))
+	if inline && strings.HasPrefix(attachment.ContentType, "image/") && !strings.HasPrefix(attachment.ContentType, "image/svg") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	http.ServeContent(w, r, "", attachment.CreatedAt, file)
+}
+
+// UpdateAttachmentHandler renames an attachment (file_name) and replaces its description. The
+// content and the entity it is attached to cannot be changed.
+func UpdateAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachment_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid attachment ID")
+		return
+	}
+	var req struct {
+		FileName    string `json:"file_name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := core.UpdateAttachment(attachmentID, req.FileName, req.Description)
+	if err != nil {
+		writeAttachmentError(w, r, "UpdateAttachmentHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteAttachmentHandler removes an attachment. Its file is deleted when no other attachment
+// shares it.
+func DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachment_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid attachment ID")
+		return
+	}
+	if err := core.DeleteAttachment(attachmentID); err != nil {
+		writeAttachmentError(w, r, "DeleteAttachmentHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterAttachmentRoutes sets up the routes of files attached to targets, findings, notes and
+// checklist items.
+func RegisterAttachmentRoutes(r chi.Router) {
+	r.Get("/attachments", GetAttachmentsHandler) // ?entity_type=&entity_id=&target_id=
+	r.Post("/attachments", UploadAttachmentHandler)
+	r.Get("/attachments/{attachment_id}", GetAttachmentHandler)
+	r.Get("/attachments/{attachment_id}/download", DownloadAttachmentHandler)
+	r.Put("/attachments/{attachment_id}", UpdateAttachmentHandler)
+	r.Delete("/attachments/{attachment_id}", DeleteAttachmentHandler)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeAuthTokenError maps auth token errors to HTTP statuses.
+func writeAuthTokenError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.HasPrefix(msg, "invalid"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process the auth token")
+	}
+}
+
+// GetTargetAuthTokensHandler lists the JWTs and SAML messages seen in a target's traffic, most
+// recently seen first. ?kind=jwt or ?kind=saml filters them.
+func GetTargetAuthTokensHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != models.AuthTokenKindJWT && kind != models.AuthTokenKindSAML {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid kind: expected jwt or saml")
+		return
+	}
+	tokens, err := database.GetAuthTokens(targetID, kind)
+	if err != nil {
+		writeAuthTokenError(w, r, "GetTargetAuthTokensHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// ScanTargetAuthTokensHandler looks for tokens in all of a target's captured traffic.
+func ScanTargetAuthTokensHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	result, err := core.ScanTargetAuthTokens(targetID)
+	if err != nil {
+		writeAuthTokenError(w, r, "ScanTargetAuthTokensHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetAuthTokenHandler returns a tracked token decoded, with the log entries it appeared in.
+func GetAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "token_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid token ID")
+		return
+	}
+	detail, err := core.GetAuthTokenDetail(tokenID)
+	if err != nil {
+		writeAuthTokenError(w, r, "GetAuthTokenHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// DecodeAuthTokenHandler decodes a JWT or SAML message. Body: {"token": "eyJ..."}.
+func DecodeAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	decoded, err := core.DecodeAuthToken(req.Token)
+	if err != nil {
+		writeAuthTokenError(w, r, "DecodeAuthTokenHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decoded)
+}
+
+// ForgeJWTHandler edits the header and claims of a JWT and signs it with a key or the "none"
+// algorithm. With modifier_log_id, a Modifier task sending it in place of the original is created.
+// Body: {"token_id": 3, "claims": {"role": "admin"}, "algorithm": "HS256", "key": "secret",
+// "modifier_log_id": 120}.
+func ForgeJWTHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.JWTForgeRequest
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber() // Keep large numeric claims exact
+	if err := dec.Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	forged, err := core.ForgeJWTToken(req)
+	if err != nil {
+		writeAuthTokenError(w, r, "ForgeJWTHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forged)
+}
+
+// ForgeSAMLHandler edits a SAML message and keeps, strips or redoes its signatures. With
+// modifier_log_id, a Modifier task sending it in place of the original is created. Body:
+// {"token_id": 4, "name_id": "admin@example.com", "signature": "resign", "key": "-----BEGIN ..."}.
+func ForgeSAMLHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.SAMLForgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	forged, err := core.ForgeSAMLToken(req)
+	if err != nil {
+		writeAuthTokenError(w, r, "ForgeSAMLHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forged)
+}
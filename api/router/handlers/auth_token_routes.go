@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterAuthTokenRoutes sets up the routes of the JWTs and SAML messages tracked in captured
+// traffic, and of the decoding and forging helpers.
+func RegisterAuthTokenRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/auth-tokens", GetTargetAuthTokensHandler)
+	r.Post("/targets/{target_id}/auth-tokens/scan", ScanTargetAuthTokensHandler)
+	r.Get("/auth-tokens/{token_id}", GetAuthTokenHandler)
+	r.Post("/auth-tokens/decode", DecodeAuthTokenHandler)
+	r.Post("/auth-tokens/jwt/forge", ForgeJWTHandler)
+	r.Post("/auth-tokens/saml/forge", ForgeSAMLHandler)
+}
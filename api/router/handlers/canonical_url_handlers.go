@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetCanonicalURLsHandler lists the unique URLs of a target: discovered URLs and traffic
+// endpoints counted once per canonical form.
+// Query parameters: search, source, in_scope (true/false), page, limit (default 50, max 500).
+func GetCanonicalURLsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	q := r.URL.Query()
+	filters := models.CanonicalURLFilters{TargetID: targetID, Search: q.Get("search"), Source: q.Get("source")}
+	if v := q.Get("in_scope"); v != "" {
+		inScope, err := strconv.ParseBool(v)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid in_scope")
+			return
+		}
+		filters.IsInScope = &inScope
+	}
+	filters.Page, _ = strconv.Atoi(q.Get("page"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	filters.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if filters.Limit < 1 || filters.Limit > 500 {
+		filters.Limit = 50
+	}
+
+	urls, total, err := database.GetCanonicalURLs(filters)
+	if err != nil {
+		logger.Error("GetCanonicalURLsHandler: Error listing canonical URLs of target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve canonical URLs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.PaginatedResponse{
+		Page:         filters.Page,
+		Limit:        filters.Limit,
+		TotalRecords: total,
+		TotalPages:   (total + filters.Limit - 1) / filters.Limit,
+		Records:      urls,
+	})
+}
+
+// RebuildCanonicalURLsHandler recomputes the canonical URLs of a target, e.g. after
+// url_canonical.strip_params or its scope rules changed.
+func RebuildCanonicalURLsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	result, err := core.RebuildCanonicalURLs(targetID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("RebuildCanonicalURLsHandler: Error rebuilding canonical URLs of target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to rebuild canonical URLs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
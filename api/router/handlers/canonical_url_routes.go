@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterCanonicalURLRoutes sets up the routes for the deduplicated URL inventory.
+func RegisterCanonicalURLRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/canonical-urls", GetCanonicalURLsHandler)
+	r.Post("/targets/{target_id}/canonical-urls/rebuild", RebuildCanonicalURLsHandler)
+}
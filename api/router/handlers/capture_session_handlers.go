@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// ListCaptureSessionsHandler lists the capture sessions of a target with their request counts,
+// latest first. ?limit= caps their number.
+func ListCaptureSessionsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	sessions, err := core.ListCaptureSessions(targetID, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, sessions)
+}
+
+// StartCaptureSessionHandler starts a named capture session of a target. Body: {"name": "testing
+// checkout flow", "notes": "..."}. A target has at most one running session.
+func StartCaptureSessionHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	var req models.CaptureSessionRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	session, err := core.StartCaptureSession(targetID, req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, session)
+}
+
+// StopCaptureSessionHandler ends the running capture session of a target, optionally replacing
+// its notes, and returns it with its final counts.
+func StopCaptureSessionHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	var req struct {
+		Notes *string `json:"notes,omitempty"`
+	}
+	// The body is optional.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return models.InvalidRequestError("Invalid request body: %v", err)
+	}
+	session, err := core.StopCaptureSession(targetID, req.Notes)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, session)
+}
+
+// GetCaptureSessionHandler returns a capture session with its request counts.
+func GetCaptureSessionHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "session_id", "capture session ID")
+	if err != nil {
+		return err
+	}
+	session, err := core.GetCaptureSession(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, session)
+}
+
+// UpdateCaptureSessionHandler renames a capture session or changes its notes; omitted fields are
+// left unchanged.
+func UpdateCaptureSessionHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "session_id", "capture session ID")
+	if err != nil {
+		return err
+	}
+	var req models.CaptureSessionRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	session, err := core.UpdateCaptureSession(id, req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, session)
+}
+
+// DeleteCaptureSessionHandler removes a capture session. The traffic logged within it is kept.
+func DeleteCaptureSessionHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "session_id", "capture session ID")
+	if err != nil {
+		return err
+	}
+	if err := core.DeleteCaptureSession(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterCaptureSessionRoutes sets up the routes of the named traffic capture windows of targets.
+func RegisterCaptureSessionRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/capture-sessions", handle(ListCaptureSessionsHandler))
+	r.Post("/targets/{target_id}/capture-sessions", handle(StartCaptureSessionHandler))
+	r.Post("/targets/{target_id}/capture-sessions/stop", handle(StopCaptureSessionHandler))
+	r.Get("/capture-sessions/{session_id}", handle(GetCaptureSessionHandler))
+	r.Put("/capture-sessions/{session_id}", handle(UpdateCaptureSessionHandler))
+	r.Delete("/capture-sessions/{session_id}", handle(DeleteCaptureSessionHandler))
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+)
+
+// GetChecklistAnalyticsHandler returns the checklist completion of a target overall, per template
+// and per category, with a timeline (interval=day|week, default day).
+func GetChecklistAnalyticsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	analytics, err := core.GetChecklistAnalytics(targetID, r.URL.Query().Get("interval"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, analytics)
+}
+
+// GetChecklistCoverageHandler maps the checklist items of a target to the endpoints of its
+// captured traffic they were tested against.
+func GetChecklistCoverageHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	report, err := core.GetChecklistCoverage(targetID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeChecklistCommandError maps command runner errors to HTTP status codes.
+func writeChecklistCommandError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "runner is disabled"):
+		WriteProblem(w, r, http.StatusForbidden, models.ErrCodeForbidden, msg)
+	case strings.Contains(msg, "in PATH"), strings.Contains(msg, "allowed_commands"), strings.Contains(msg, "placeholder"),
+		strings.Contains(msg, "not supported"), strings.Contains(msg, "quote"), strings.Contains(msg, "has no command"),
+		strings.Contains(msg, "bare command name"), strings.Contains(msg, "command is empty"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Command run failed")
+	}
+}
+
+// RunChecklistItemCommandHandler runs a checklist item's command in the background.
+// The response is the new run; follow its output via /checklist-command-runs/{run_id}/stream.
+func RunChecklistItemCommandHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
+	var req models.ChecklistCommandRunRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+		defer r.Body.Close()
+	}
+	run, err := core.StartChecklistCommand(itemID, req)
+	if err != nil {
+		writeChecklistCommandError(w, r, "RunChecklistItemCommandHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GetChecklistItemCommandRunsHandler lists the command runs of a checklist item, newest first.
+func GetChecklistItemCommandRunsHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
+	runs, err := database.GetChecklistCommandRuns(itemID)
+	if err != nil {
+		writeChecklistCommandError(w, r, "GetChecklistItemCommandRunsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// GetChecklistCommandRunHandler returns a command run with the output collected so far.
+func GetChecklistCommandRunHandler(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(chi.URLParam(r, "run_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid run ID")
+		return
+	}
+	run, err := core.GetChecklistCommandRun(runID)
+	if err != nil {
+		writeChecklistCommandError(w, r, "GetChecklistCommandRunHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// StreamChecklistCommandRunHandler streams a run's output as server-sent events. "output" events carry
+// output chunks; a final "done" event carries the finished run (without its output) as JSON.
+// Query parameters: offset (byte offset to resume from).
+func StreamChecklistCommandRunHandler(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(chi.URLParam(r, "run_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid run ID")
+		return
+	}
+	if _, err := database.GetChecklistCommandRunByID(runID); err != nil {
+		writeChecklistCommandError(w, r, "StreamChecklistCommandRunHandler", err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Streaming not supported")
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		chunk, done, err := core.ReadChecklistCommandOutput(runID, offset)
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+			flusher.Flush()
+			return
+		}
+		if chunk != "" {
+			offset += len(chunk)
+			fmt.Fprint(w, "event: output\n")
+			for _, line := range strings.Split(chunk, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprintf(w, "id: %d\n\n", offset)
+			flusher.Flush()
+		}
+		if done {
+			run, err := core.GetChecklistCommandRun(runID)
+			if err == nil {
+				run.Output = ""
+				runJSON, _ := json.Marshal(run)
+				fmt.Fprintf(w, "event: done\ndata: %s\n\n", runJSON)
+				flusher.Flush()
+			}
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+}
+
+// CancelChecklistCommandRunHandler stops a running command.
+func CancelChecklistCommandRunHandler(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(chi.URLParam(r, "run_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid run ID")
+		return
+	}
+	if !core.CancelChecklistCommand(runID) {
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, fmt.Sprintf("Command run %d is not running", runID))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf("Command run %d cancelled", runID)})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeChecklistEvidenceError maps checklist evidence errors to HTTP status codes.
+func writeChecklistEvidenceError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "already linked"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	case strings.Contains(msg, "invalid evidence_type"), strings.Contains(msg, "is required"), strings.Contains(msg, "belongs to target"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Checklist evidence operation failed")
+	}
+}
+
+// GetChecklistItemEvidenceHandler lists the evidence linked to a checklist item.
+func GetChecklistItemEvidenceHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
+	if _, err := database.GetChecklistItemByID(itemID); err != nil {
+		writeChecklistEvidenceError(w, r, "GetChecklistItemEvidenceHandler", err)
+		return
+	}
+	evidence, err := database.GetChecklistItemEvidence(itemID)
+	if err != nil {
+		writeChecklistEvidenceError(w, r, "GetChecklistItemEvidenceHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(evidence)
+}
+
+// AddChecklistItemEvidenceHandler links a traffic log, finding or screenshot to a checklist item.
+func AddChecklistItemEvidenceHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
+	var req models.ChecklistEvidenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	id, err := database.AddChecklistItemEvidence(itemID, req)
+	if err != nil {
+		writeChecklistEvidenceError(w, r, "AddChecklistItemEvidenceHandler", err)
+		return
+	}
+	created, err := database.GetChecklistItemEvidenceByID(id)
+	if err != nil {
+		writeChecklistEvidenceError(w, r, "AddChecklistItemEvidenceHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// DeleteChecklistItemEvidenceHandler unlinks evidence from a checklist item.
+func DeleteChecklistItemEvidenceHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
+	evidenceID, err := strconv.ParseInt(chi.URLParam(r, "evidence_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid evidence ID")
+		return
+	}
+	if err := database.DeleteChecklistItemEvidence(itemID, evidenceID); err != nil {
+		writeChecklistEvidenceError(w, r, "DeleteChecklistItemEvidenceHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetChecklistReportHandler generates a checklist report for a target with the evidence behind each item.
+// Query parameters: format (json (default) or markdown).
+func GetChecklistReportHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	report, err := database.GetChecklistReport(targetID)
+	if err != nil {
+		logger.Error("GetChecklistReportHandler: target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to generate checklist report")
+		return
+	}
+
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case "markdown", "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(renderChecklistReportMarkdown(targetID, report)))
+	default:
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid format, expected 'json' or 'markdown'")
+	}
+}
+
+// renderChecklistReportMarkdown renders checklist items as a task list with their evidence nested below.
+func renderChecklistReportMarkdown(targetID int64, report []models.ChecklistReportItem) string {
+	var b strings.Builder
+	completed := 0
+	for _, item := range report {
+		if item.IsCompleted {
+			completed++
+		}
+	}
+	fmt.Fprintf(&b, "# Checklist Report (Target %d)\n\n%d of %d items completed.\n\n", targetID, completed, len(report))
+	for _, item := range report {
+		mark := " "
+		if item.IsCompleted {
+			mark = "x"
+		}
+		fmt.Fprintf(&b, "- [%s] %s\n", mark, item.ItemText)
+		if item.Notes.Valid && strings.TrimSpace(item.Notes.String) != "" {
+			fmt.Fprintf(&b, "  - Notes: %s\n", strings.TrimSpace(item.Notes.String))
+		}
+		for _, e := range item.Evidence {
+			var ref string
+			switch e.EvidenceType {
+			case models.ChecklistEvidenceHTTPLog:
+				ref = fmt.Sprintf("Request #%d: `%s`", e.HTTPTrafficLogID.Int64, e.Label)
+			case models.ChecklistEvidenceFinding:
+				ref = fmt.Sprintf("Finding #%d: %s", e.FindingID.Int64, e.Label)
+			default:
+				ref = fmt.Sprintf("Screenshot: ![%s](%s)", e.Caption.String, e.ScreenshotPath.String)
+			}
+			if e.Caption.Valid && e.EvidenceType != models.ChecklistEvidenceScreenshot {
+				ref += " - " + e.Caption.String
+			}
+			fmt.Fprintf(&b, "  - %s\n", ref)
+		}
+	}
+	return b.String()
+}
@@ -19,7 +19,7 @@ import (
 func GetChecklistItemsHandler(w http.ResponseWriter, r *http.Request, targetID int64) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetChecklistItemsHandler: MethodNotAllowed: %s for target %d", r.Method, targetID)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -31,18 +31,9 @@ func GetChecklistItemsHandler(w http.ResponseWriter, r *http.Request, targetID i
 	filter := r.URL.Query().Get("filter")
 	showIncompleteOnlyStr := r.URL.Query().Get("show_incomplete_only")
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 25 // Default limit
-	} else if limit > 200 { // Max limit
-		limit = 200
-	}
-	offset := (page - 1) * limit
+	pageNumber, _ := strconv.Atoi(pageStr)
+	limit, _ := strconv.Atoi(limitStr)
+	page := database.NewPage(pageNumber, limit, 25, 200, false)
 
 	if sortBy == "" {
 		sortBy = "created_at" // Default sort column
@@ -56,22 +47,19 @@ func GetChecklistItemsHandler(w http.ResponseWriter, r *http.Request, targetID i
 		showIncompleteOnly = false
 	}
 
-	items, totalRecords, totalCompletedRecordsForFilter, err := database.GetChecklistItemsByTargetIDPaginated(targetID, limit, offset, sortBy, sortOrder, filter, showIncompleteOnly)
+	items, totalRecords, totalCompletedRecordsForFilter, err := database.GetChecklistItemsByTargetIDPaginated(targetID, page.Limit, page.Offset(), sortBy, sortOrder, filter, showIncompleteOnly)
 	if err != nil {
 		// Error already logged in database function
-		http.Error(w, "Failed to retrieve checklist items", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve checklist items")
 		return
 	}
 
-	totalPages := 0
-	if totalRecords > 0 {
-		totalPages = (int(totalRecords) + limit - 1) / limit
-	}
+	totalPages := int(page.TotalPages(totalRecords))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.PaginatedTargetChecklistItemsResponse{
-		Page:                           page,
-		Limit:                          limit,
+		Page:                           page.Number,
+		Limit:                          page.Limit,
 		TotalRecords:                   totalRecords,
 		TotalCompletedRecordsForFilter: totalCompletedRecordsForFilter, // Add the new field
 		TotalPages:                     totalPages,
@@ -87,7 +75,7 @@ func GetChecklistItemsHandler(w http.ResponseWriter, r *http.Request, targetID i
 func AddChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("AddChecklistItemHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -101,7 +89,7 @@ func AddChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&requestPayload); err != nil {
 		logger.Error("AddChecklistItemHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -119,14 +107,14 @@ func AddChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
 
 	if item.TargetID == 0 || strings.TrimSpace(item.ItemText) == "" {
 		logger.Error("AddChecklistItemHandler: TargetID and ItemText are required. Got TargetID: %d, ItemText: '%s'", item.TargetID, item.ItemText)
-		http.Error(w, "TargetID and ItemText are required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "TargetID and ItemText are required")
 		return
 	}
 
 	id, err := database.AddChecklistItem(item)
 	if err != nil {
 		logger.Error("AddChecklistItemHandler: Error adding checklist item for target %d: %v", item.TargetID, err)
-		http.Error(w, "Failed to add checklist item", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to add checklist item")
 		return
 	}
 	item.ID = id
@@ -142,7 +130,7 @@ func AddChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
 func UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
 	if r.Method != http.MethodPut {
 		logger.Error("UpdateChecklistItemHandler: MethodNotAllowed: %s for item %d", r.Method, itemID)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -156,14 +144,14 @@ func UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID i
 	bodyBytes, bodyReadErr := io.ReadAll(r.Body)
 	if bodyReadErr != nil {
 		logger.Error("UpdateChecklistItemHandler: Error reading request body for item %d: %v", itemID, bodyReadErr)
-		http.Error(w, "Failed to read request body: "+bodyReadErr.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to read request body: "+bodyReadErr.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if err := json.Unmarshal(bodyBytes, &itemUpdates); err != nil {
 		logger.Error("UpdateChecklistItemHandler: Error decoding request body for item %d: %v", itemID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 
@@ -171,10 +159,10 @@ func UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID i
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("UpdateChecklistItemHandler: Checklist item with ID %d not found", itemID)
-			http.Error(w, "Checklist item not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Checklist item not found")
 		} else {
 			logger.Error("UpdateChecklistItemHandler: Error fetching checklist item %d: %v", itemID, err)
-			http.Error(w, "Failed to retrieve checklist item", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve checklist item")
 		}
 		return
 	}
@@ -208,14 +196,14 @@ func UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID i
 
 	if strings.TrimSpace(existingItem.ItemText) == "" {
 		logger.Error("UpdateChecklistItemHandler: ItemText cannot be empty for item %d", itemID)
-		http.Error(w, "ItemText cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "ItemText cannot be empty")
 		return
 	}
 
 	err = database.UpdateChecklistItem(existingItem)
 	if err != nil {
 		logger.Error("UpdateChecklistItemHandler: Error updating checklist item %d: %v", itemID, err)
-		http.Error(w, "Failed to update checklist item", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update checklist item")
 		return
 	}
 	existingItem.UpdatedAt = time.Now()
@@ -228,7 +216,7 @@ func UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID i
 func DeleteChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID int64) {
 	if r.Method != http.MethodDelete {
 		logger.Error("DeleteChecklistItemHandler: MethodNotAllowed: %s for item %d", r.Method, itemID)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -236,10 +224,10 @@ func DeleteChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID i
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("DeleteChecklistItemHandler: Checklist item with ID %d not found for deletion", itemID)
-			http.Error(w, "Checklist item not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Checklist item not found")
 		} else {
 			logger.Error("DeleteChecklistItemHandler: Error fetching checklist item %d before deletion: %v", itemID, err)
-			http.Error(w, "Failed to retrieve checklist item for deletion", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve checklist item for deletion")
 		}
 		return
 	}
@@ -247,7 +235,7 @@ func DeleteChecklistItemHandler(w http.ResponseWriter, r *http.Request, itemID i
 	err = database.DeleteChecklistItem(itemID)
 	if err != nil {
 		logger.Error("DeleteChecklistItemHandler: Error deleting checklist item %d: %v", itemID, err)
-		http.Error(w, "Failed to delete checklist item", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete checklist item")
 		return
 	}
 
@@ -275,21 +263,21 @@ type CopyTemplateItemsToTargetResponse struct {
 func CopyTemplateItemsToTargetHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("CopyTemplateItemsToTargetHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed. Only POST is accepted.", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed. Only POST is accepted.")
 		return
 	}
 
 	var req CopyTemplateItemsToTargetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("CopyTemplateItemsToTargetHandler: Failed to decode request body: %v", err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.TargetID <= 0 {
 		logger.Error("CopyTemplateItemsToTargetHandler: Invalid target_id: %d", req.TargetID)
-		http.Error(w, "Invalid target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
 		return
 	}
 
@@ -333,14 +321,14 @@ func CopyTemplateItemsToTargetHandler(w http.ResponseWriter, r *http.Request) {
 func DeleteAllChecklistItemsForTargetHandler(w http.ResponseWriter, r *http.Request, targetID int64) {
 	if r.Method != http.MethodDelete {
 		logger.Error("DeleteAllChecklistItemsForTargetHandler: MethodNotAllowed: %s for target %d", r.Method, targetID)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	err := database.DeleteAllChecklistItemsForTarget(targetID)
 	if err != nil {
 		logger.Error("DeleteAllChecklistItemsForTargetHandler: Error deleting all checklist items for target %d: %v", targetID, err)
-		http.Error(w, "Failed to delete all checklist items", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete all checklist items")
 		return
 	}
 
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"toolkit/models"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -19,7 +20,7 @@ func RegisterChecklistRoutes(r chi.Router) {
 			itemIDStr := chi.URLParam(req, "itemID")
 			itemID, err := strconv.ParseInt(itemIDStr, 10, 64)
 			if err != nil {
-				http.Error(w, "Invalid checklist item ID", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
 				return
 			}
 			UpdateChecklistItemHandler(w, req, itemID) // Existing handler
@@ -29,19 +30,73 @@ func RegisterChecklistRoutes(r chi.Router) {
 			itemIDStr := chi.URLParam(req, "itemID")
 			itemID, err := strconv.ParseInt(itemIDStr, 10, 64)
 			if err != nil {
-				http.Error(w, "Invalid checklist item ID", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
 				return
 			}
 			DeleteChecklistItemHandler(w, req, itemID) // Existing handler
 		})
+
+		// Evidence backing the item: /checklist-items/{itemID}/evidence
+		subRouter.Get("/evidence", func(w http.ResponseWriter, req *http.Request) {
+			itemID, err := strconv.ParseInt(chi.URLParam(req, "itemID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
+				return
+			}
+			GetChecklistItemEvidenceHandler(w, req, itemID)
+		})
+		subRouter.Post("/evidence", func(w http.ResponseWriter, req *http.Request) {
+			itemID, err := strconv.ParseInt(chi.URLParam(req, "itemID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
+				return
+			}
+			AddChecklistItemEvidenceHandler(w, req, itemID)
+		})
+		subRouter.Delete("/evidence/{evidence_id}", func(w http.ResponseWriter, req *http.Request) {
+			itemID, err := strconv.ParseInt(chi.URLParam(req, "itemID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
+				return
+			}
+			DeleteChecklistItemEvidenceHandler(w, req, itemID)
+		})
+
+		// Running the item's command: /checklist-items/{itemID}/run and /runs
+		subRouter.Post("/run", func(w http.ResponseWriter, req *http.Request) {
+			itemID, err := strconv.ParseInt(chi.URLParam(req, "itemID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
+				return
+			}
+			RunChecklistItemCommandHandler(w, req, itemID)
+		})
+		subRouter.Get("/runs", func(w http.ResponseWriter, req *http.Request) {
+			itemID, err := strconv.ParseInt(chi.URLParam(req, "itemID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist item ID")
+				return
+			}
+			GetChecklistItemCommandRunsHandler(w, req, itemID)
+		})
 	})
 
+	r.Get("/checklist-command-runs/{run_id}", GetChecklistCommandRunHandler)
+	r.Get("/checklist-command-runs/{run_id}/stream", StreamChecklistCommandRunHandler) // text/event-stream
+	r.Post("/checklist-command-runs/{run_id}/cancel", CancelChecklistCommandRunHandler)
+
+	// GET /targets/{target_id}/checklist-report?format=json|markdown
+	r.Get("/targets/{target_id}/checklist-report", GetChecklistReportHandler)
+	// Completion per template and category over time, and items mapped to the endpoints they were tested against
+	r.Get("/targets/{target_id}/checklist-analytics", handle(GetChecklistAnalyticsHandler))
+	r.Get("/targets/{target_id}/checklist-coverage", handle(GetChecklistCoverageHandler))
+
 	// DELETE /targets/{target_id}/checklist-items/all
 	r.Delete("/targets/{target_id}/checklist-items/all", func(w http.ResponseWriter, req *http.Request) {
 		targetIDStr := chi.URLParam(req, "target_id")
 		targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid target ID", http.StatusBadRequest)
+			WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
 			return
 		}
 		DeleteAllChecklistItemsForTargetHandler(w, req, targetID)
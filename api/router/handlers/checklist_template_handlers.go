@@ -14,14 +14,14 @@ import (
 func ListChecklistTemplatesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("ListChecklistTemplatesHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	templates, err := database.GetAllChecklistTemplates()
 	if err != nil {
 		logger.Error("ListChecklistTemplatesHandler: Error fetching checklist templates: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -36,7 +36,7 @@ func ListChecklistTemplatesHandler(w http.ResponseWriter, r *http.Request) {
 func GetChecklistTemplateItemsHandler(w http.ResponseWriter, r *http.Request, templateID int64) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetChecklistTemplateItemsHandler: MethodNotAllowed: %s for template ID %d", r.Method, templateID)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -59,7 +59,7 @@ func GetChecklistTemplateItemsHandler(w http.ResponseWriter, r *http.Request, te
 	items, totalRecords, err := database.GetChecklistTemplateItemsPaginated(templateID, limit, offset)
 	if err != nil {
 		logger.Error("GetChecklistTemplateItemsHandler: Error fetching items for template ID %d: %v", templateID, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -87,7 +87,7 @@ func GetChecklistTemplateItemsHandler(w http.ResponseWriter, r *http.Request, te
 func CopyAllChecklistTemplateItemsToTargetHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("CopyAllChecklistTemplateItemsToTargetHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed. Only POST is accepted.", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed. Only POST is accepted.")
 		return
 	}
 
@@ -98,26 +98,26 @@ func CopyAllChecklistTemplateItemsToTargetHandler(w http.ResponseWriter, r *http
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("CopyAllChecklistTemplateItemsToTargetHandler: Failed to decode request body: %v", err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.TemplateID <= 0 {
 		logger.Error("CopyAllChecklistTemplateItemsToTargetHandler: Invalid template_id: %d", req.TemplateID)
-		http.Error(w, "Invalid template_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid template_id")
 		return
 	}
 	if req.TargetID <= 0 {
 		logger.Error("CopyAllChecklistTemplateItemsToTargetHandler: Invalid target_id: %d", req.TargetID)
-		http.Error(w, "Invalid target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
 		return
 	}
 
 	itemsCopied, err := database.CopyAllTemplateItemsToTarget(req.TemplateID, req.TargetID)
 	if err != nil {
 		logger.Error("CopyAllChecklistTemplateItemsToTargetHandler: Error copying items from template %d to target %d: %v", req.TemplateID, req.TargetID, err)
-		http.Error(w, "Failed to copy checklist items: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to copy checklist items: "+err.Error())
 		return
 	}
 
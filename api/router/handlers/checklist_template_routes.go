@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"toolkit/models"
 
 	"toolkit/logger" // Assuming logger is used or might be useful
 
@@ -19,7 +20,7 @@ func RegisterChecklistTemplateRoutes(r chi.Router) {
 		templateID, err := strconv.ParseInt(templateIDStr, 10, 64)
 		if err != nil {
 			logger.Error("RegisterChecklistTemplateRoutes: Invalid templateID format '%s': %v", templateIDStr, err)
-			http.Error(w, "Invalid checklist template ID format", http.StatusBadRequest)
+			WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid checklist template ID format")
 			return
 		}
 		GetChecklistTemplateItemsHandler(w, req, templateID) // Existing handler
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// GetTargetClientProfileHandler returns the client profile of a target (empty when none is set).
+func GetTargetClientProfileHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	profile, err := core.GetTargetClientProfile(targetID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, profile)
+}
+
+// PutTargetClientProfileHandler sets the client profile of a target: the headers, user agent and
+// cookies added to the requests the toolkit sends for it.
+func PutTargetClientProfileHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	var profile models.TargetClientProfile
+	if err := decodeJSONBody(r, &profile); err != nil {
+		return err
+	}
+	profile.TargetID = targetID
+	saved, err := core.SaveTargetClientProfile(profile)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, saved)
+}
+
+// DeleteTargetClientProfileHandler removes the client profile of a target.
+func DeleteTargetClientProfileHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	if err := core.DeleteTargetClientProfile(targetID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterClientProfileRoutes sets up the routes of the per-target HTTP client profile.
+func RegisterClientProfileRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/client-profile", handle(GetTargetClientProfileHandler))
+	r.Put("/targets/{target_id}/client-profile", handle(PutTargetClientProfileHandler))
+	r.Delete("/targets/{target_id}/client-profile", handle(DeleteTargetClientProfileHandler))
+}
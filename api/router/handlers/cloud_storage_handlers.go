@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListCloudBucketsHandler lists the cloud storage buckets of a target, writable and publicly
+// listable buckets first.
+// Query parameters: provider (s3, gcs, azure), status, source, existing (true leaves out
+// not_found and unchecked buckets).
+func ListCloudBucketsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	q := r.URL.Query()
+	filters := models.CloudBucketFilters{TargetID: targetID, Provider: q.Get("provider"), Status: q.Get("status"), Source: q.Get("source")}
+	if v := q.Get("existing"); v != "" {
+		existing, err := strconv.ParseBool(v)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid existing")
+			return
+		}
+		filters.ExistingOnly = existing
+	}
+	buckets, err := database.ListCloudBuckets(filters)
+	if err != nil {
+		logger.Error("ListCloudBucketsHandler: Error listing cloud buckets of target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to list cloud buckets")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
+
+// CheckCloudStorageHandler starts a job checking the S3/GCS/Azure buckets of a target: candidates
+// derived from its names and domains, buckets referenced in its traffic and JavaScript, and the
+// names of the request.
+func CheckCloudStorageHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.CloudStorageCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartCloudStorageCheck(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "no buckets"), strings.Contains(msg, "invalid"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("CheckCloudStorageHandler: Error starting cloud storage check for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start cloud storage check")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
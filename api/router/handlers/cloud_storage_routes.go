@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterCloudStorageRoutes sets up the routes for the S3/GCS/Azure bucket checker.
+func RegisterCloudStorageRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/cloud-storage", ListCloudBucketsHandler)
+	r.Post("/targets/{target_id}/cloud-storage/check", CheckCloudStorageHandler)
+}
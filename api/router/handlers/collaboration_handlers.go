@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListActivityHandler returns the activity feed, newest first. Filters: user, entity_type,
+// entity_id, target_id; after_id returns the activity after an entry, oldest first. limit defaults
+// to 100.
+func ListActivityHandler(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	filters := models.ActivityFilters{User: q.Get("user"), EntityType: q.Get("entity_type"), EntityID: q.Get("entity_id")}
+	var err error
+	if v := q.Get("target_id"); v != "" {
+		if filters.TargetID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return models.InvalidRequestError("invalid target_id %q", v)
+		}
+	}
+	if v := q.Get("after_id"); v != "" {
+		if filters.AfterID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return models.InvalidRequestError("invalid after_id %q", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filters.Limit, err = strconv.Atoi(v); err != nil {
+			return models.InvalidRequestError("invalid limit %q", v)
+		}
+	}
+	activity, err := core.ListActivity(filters)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, activity)
+}
+
+// ListPresenceHandler returns who is viewing an entity (entity_type, entity_id), or everything.
+func ListPresenceHandler(w http.ResponseWriter, r *http.Request) error {
+	presence, err := core.ListPresence(r.URL.Query().Get("entity_type"), r.URL.Query().Get("entity_id"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, presence)
+}
+
+// TouchPresenceHandler records that the requesting user views an entity. Clients repeat it while
+// the entity stays open.
+func TouchPresenceHandler(w http.ResponseWriter, r *http.Request) error {
+	var req models.PresenceRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	presence, err := core.TouchPresence(core.APIUser(r.Context()), req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, presence)
+}
+
+// LeavePresenceHandler records that the requesting user closed an entity (entity_type,
+// entity_id).
+func LeavePresenceHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := core.LeavePresence(core.APIUser(r.Context()), r.URL.Query().Get("entity_type"), r.URL.Query().Get("entity_id")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ListLocksHandler returns the soft locks that have not expired.
+func ListLocksHandler(w http.ResponseWriter, r *http.Request) error {
+	locks, err := core.ListLocks()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, locks)
+}
+
+// AcquireLockHandler takes or refreshes a soft lock on a finding or note for the requesting user.
+// Answers 409 when another user holds it, unless force is set.
+func AcquireLockHandler(w http.ResponseWriter, r *http.Request) error {
+	var req models.LockRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	lock, err := core.AcquireLock(core.APIUser(r.Context()), req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, lock)
+}
+
+// ReleaseLockHandler releases the requesting user's lock on an entity; force=true releases
+// another user's.
+func ReleaseLockHandler(w http.ResponseWriter, r *http.Request) error {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	err := core.ReleaseLock(core.APIUser(r.Context()), chi.URLParam(r, "entity_type"), chi.URLParam(r, "entity_id"), force)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// StreamEventsHandler streams server-sent events: "activity" for each mutation made through the
+// API (its ID is the event ID, so a reconnect resumes after Last-Event-ID), and "presence" and
+// "locks" with all viewers and locks whenever they change. target_id narrows the activity to a
+// target; after_id replays the activity after an entry.
+func StreamEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filters := models.ActivityFilters{Limit: 100}
+	if v := r.URL.Query().Get("target_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
+			return
+		}
+		filters.TargetID = id
+	}
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = r.URL.Query().Get("after_id")
+	}
+	if after != "" {
+		id, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid after_id")
+			return
+		}
+		filters.AfterID = id
+	} else {
+		id, err := core.LatestActivityID()
+		if err != nil {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, err.Error())
+			return
+		}
+		filters.AfterID = id
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var presence []models.Presence
+	var locks []models.EntityLock
+	lastWrite := time.Now()
+	for {
+		wrote := false
+		activity, err := core.ListActivity(filters)
+		if err == nil {
+			for _, a := range activity {
+				data, _ := json.Marshal(a)
+				fmt.Fprintf(w, "event: %s\ndata: %s\nid: %d\n\n", models.EventTypeActivity, data, a.ID)
+				filters.AfterID = a.ID
+				wrote = true
+			}
+		}
+		if current, err := core.ListPresence("", ""); err == nil && (presence == nil || !samePresence(presence, current)) {
+			presence = current
+			data, _ := json.Marshal(current)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", models.EventTypePresence, data)
+			wrote = true
+		}
+		if current, err := core.ListLocks(); err == nil && (locks == nil || !reflect.DeepEqual(locks, current)) {
+			locks = current
+			data, _ := json.Marshal(current)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", models.EventTypeLocks, data)
+			wrote = true
+		}
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+			flusher.Flush()
+			return
+		}
+		if !wrote && time.Since(lastWrite) >= 15*time.Second {
+			// Comments keep proxies from closing an idle stream.
+			fmt.Fprint(w, ": keep-alive\n\n")
+			wrote = true
+		}
+		if wrote {
+			flusher.Flush()
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// samePresence compares viewers regardless of when they were last seen, so refreshing presence
+// is not an event.
+func samePresence(a, b []models.Presence) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].User != b[i].User || a[i].EntityType != b[i].EntityType || a[i].EntityID != b[i].EntityID {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterCollaborationRoutes sets up the routes of users sharing a toolkit: the activity feed,
+// who is viewing what, soft locks and the event stream of all three.
+func RegisterCollaborationRoutes(r chi.Router) {
+	r.Get("/activity", handle(ListActivityHandler))
+	r.Get("/presence", handle(ListPresenceHandler))
+	r.Post("/presence", handle(TouchPresenceHandler))
+	r.Delete("/presence", handle(LeavePresenceHandler))
+	r.Get("/locks", handle(ListLocksHandler))
+	r.Post("/locks", handle(AcquireLockHandler))
+	r.Delete("/locks/{entity_type}/{entity_id}", handle(ReleaseLockHandler))
+	r.Get("/events", StreamEventsHandler)
+}
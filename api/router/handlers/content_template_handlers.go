@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// ListContentTemplatesHandler lists the finding and note templates, with the placeholders each
+// uses. Query parameter: kind (finding or note).
+func ListContentTemplatesHandler(w http.ResponseWriter, r *http.Request) error {
+	templates, err := core.ListContentTemplates(r.URL.Query().Get("kind"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, templates)
+}
+
+// GetContentTemplateHandler returns a content template.
+func GetContentTemplateHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "template_id", "template ID")
+	if err != nil {
+		return err
+	}
+	template, err := core.GetContentTemplate(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, template)
+}
+
+// CreateContentTemplateHandler adds a finding or note template whose fields may hold {{name}}
+// placeholders.
+func CreateContentTemplateHandler(w http.ResponseWriter, r *http.Request) error {
+	var template models.ContentTemplate
+	if err := decodeJSONBody(r, &template); err != nil {
+		return err
+	}
+	created, err := core.CreateContentTemplate(template)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, created)
+}
+
+// UpdateContentTemplateHandler replaces the fields of a content template.
+func UpdateContentTemplateHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "template_id", "template ID")
+	if err != nil {
+		return err
+	}
+	var template models.ContentTemplate
+	if err := decodeJSONBody(r, &template); err != nil {
+		return err
+	}
+	template.ID = id
+	updated, err := core.UpdateContentTemplate(template)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteContentTemplateHandler removes a content template.
+func DeleteContentTemplateHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "template_id", "template ID")
+	if err != nil {
+		return err
+	}
+	if err := core.DeleteContentTemplate(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// InstantiateContentTemplateHandler fills in a content template and creates the Draft finding or
+// note, or only returns it with preview. Body: {"http_traffic_log_id": 12, "parameter": "q",
+// "variables": {"payload": "..."}}. Placeholders without a value are listed as unresolved.
+func InstantiateContentTemplateHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "template_id", "template ID")
+	if err != nil {
+		return err
+	}
+	var req models.ContentTemplateInstantiateRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	instance, err := core.InstantiateContentTemplate(id, req)
+	if err != nil {
+		return err
+	}
+	status := http.StatusCreated
+	if req.Preview {
+		status = http.StatusOK
+	}
+	return writeJSON(w, status, instance)
+}
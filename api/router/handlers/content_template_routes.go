@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterContentTemplateRoutes sets up the routes of the reusable finding and note templates.
+func RegisterContentTemplateRoutes(r chi.Router) {
+	r.Get("/content-templates", handle(ListContentTemplatesHandler))
+	r.Post("/content-templates", handle(CreateContentTemplateHandler))
+	r.Get("/content-templates/{template_id}", handle(GetContentTemplateHandler))
+	r.Put("/content-templates/{template_id}", handle(UpdateContentTemplateHandler))
+	r.Delete("/content-templates/{template_id}", handle(DeleteContentTemplateHandler))
+	r.Post("/content-templates/{template_id}/instantiate", handle(InstantiateContentTemplateHandler))
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetTargetSessionsHandler returns the cookies a target set in captured traffic with their status
+// and age, and its recent session events. Only session cookies are listed unless ?all=true;
+// ?events limits the number of events (default 50).
+func GetTargetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	events, _ := strconv.Atoi(r.URL.Query().Get("events"))
+	sessions, err := core.GetTargetSessions(targetID, all, events)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetTargetSessionsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load sessions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RebuildTargetCookieJarHandler rebuilds the cookie jar and session events of a target from its
+// captured traffic.
+func RebuildTargetCookieJarHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	result, err := core.RebuildTargetCookieJar(targetID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("RebuildTargetCookieJarHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to rebuild cookie jar")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
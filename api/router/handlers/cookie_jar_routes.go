@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterCookieJarRoutes sets up the routes of the per-target cookie jar, session events and
+// login flow.
+func RegisterCookieJarRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/sessions", GetTargetSessionsHandler)
+	r.Post("/targets/{target_id}/sessions/rebuild", RebuildTargetCookieJarHandler)
+	r.Get("/targets/{target_id}/login-flow", GetLoginFlowHandler)
+	r.Put("/targets/{target_id}/login-flow", SetLoginFlowHandler)
+	r.Delete("/targets/{target_id}/login-flow", DeleteLoginFlowHandler)
+	r.Post("/targets/{target_id}/login-flow/run", RunLoginFlowHandler) // Mints a fresh session
+}
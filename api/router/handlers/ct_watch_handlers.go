@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// writeCTWatchError maps certificate transparency watcher errors to HTTP status codes.
+func writeCTWatchError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "not configured"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "crt.sh"):
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
+	}
+}
+
+// GetCTWatcherStatusHandler returns the counters of the certificate transparency watcher and the
+// position of every watched wildcard pattern.
+func GetCTWatcherStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := core.GetCTWatcherStatus()
+	if err != nil {
+		writeCTWatchError(w, r, "GetCTWatcherStatusHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// PollCTWatcherHandler checks CT logs now, for one target (target_id query parameter) or all of them.
+func PollCTWatcherHandler(w http.ResponseWriter, r *http.Request) {
+	var targetID int64
+	if v := r.URL.Query().Get("target_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
+			return
+		}
+		targetID = id
+	}
+	result, err := core.PollCTLogsNow(r.Context(), targetID)
+	if err != nil {
+		writeCTWatchError(w, r, "PollCTWatcherHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
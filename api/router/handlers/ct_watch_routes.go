@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterCTWatchRoutes sets up the routes of the certificate transparency watcher.
+func RegisterCTWatchRoutes(r chi.Router) {
+	r.Get("/ct-watch", GetCTWatcherStatusHandler)
+	r.Post("/ct-watch/poll", PollCTWatcherHandler)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"toolkit/core"
+)
+
+// GetDNSResolversHandler reports the configured resolvers (dns.resolvers) with their health and
+// query counters, or that the system resolver is used.
+func GetDNSResolversHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetDNSResolversStatus())
+}
+
+// CheckDNSResolversHandler looks up dns.health_check_domain through every configured resolver now
+// and returns their updated health.
+func CheckDNSResolversHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.CheckDNSResolvers(r.Context()))
+}
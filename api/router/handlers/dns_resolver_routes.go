@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterDNSResolverRoutes sets up the routes reporting and checking the resolvers of the recon
+// DNS lookups.
+func RegisterDNSResolverRoutes(r chi.Router) {
+	r.Get("/dns/resolvers", GetDNSResolversHandler)
+	r.Post("/dns/resolvers/check", CheckDNSResolversHandler)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// EnrichDomainsHandler starts a job hashing favicons and looking up Shodan/Censys host data for
+// the given domains of a target (every domain with an httpx result when domain_ids is empty).
+func EnrichDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.DomainEnrichmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartDomainEnrichment(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "not configured"), strings.Contains(msg, "no domains"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("EnrichDomainsHandler: Error starting enrichment for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start domain enrichment")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
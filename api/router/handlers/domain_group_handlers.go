@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDomainGroupsHandler groups the domains of a target by root domain (eTLD+1) with per-group
+// counts, and rolls them up by business unit. Query parameters: business_unit (NULL for root
+// domains without one), search (part of the root domain).
+func GetDomainGroupsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	q := r.URL.Query()
+	report, err := core.GetDomainGroups(targetID, q.Get("business_unit"), q.Get("search"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, report)
+}
+
+// SetDomainGroupHandler assigns a root domain of a target to a business unit. Body:
+// {"business_unit": "Payments", "notes": "..."}; empty values remove the assignment.
+func SetDomainGroupHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	var update models.DomainGroupUpdate
+	if err := decodeJSONBody(r, &update); err != nil {
+		return err
+	}
+	group, err := core.SetDomainGroup(targetID, chi.URLParam(r, "root_domain"), update)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, group)
+}
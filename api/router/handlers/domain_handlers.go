@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"toolkit/config"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -39,13 +43,13 @@ func CreateDomainHandler(w http.ResponseWriter, r *http.Request) {
 	var domain models.Domain
 	if err := json.NewDecoder(r.Body).Decode(&domain); err != nil {
 		logger.Error("CreateDomainHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if domain.TargetID == 0 || strings.TrimSpace(domain.DomainName) == "" {
-		http.Error(w, "target_id and domain_name are required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id and domain_name are required")
 		return
 	}
 
@@ -53,9 +57,9 @@ func CreateDomainHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Error("CreateDomainHandler: Error creating domain: %v", err)
 		if strings.Contains(err.Error(), "already exists") {
-			http.Error(w, err.Error(), http.StatusConflict)
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
 		} else {
-			http.Error(w, "Failed to create domain", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create domain")
 		}
 		return
 	}
@@ -93,6 +97,10 @@ func CreateDomainHandler(w http.ResponseWriter, r *http.Request) {
 // @Param source_search query string false "Search term for source"
 // @Param is_in_scope query boolean false "Filter by in-scope status"
 // @Param is_favorite query boolean false "Filter by favorite status"
+// @Param view_id query int false "Saved view whose filters are applied (explicit parameters take precedence)"
+// @Param view query string false "Saved view name (alternative to view_id)"
+// @Param pagination query string false "cursor pages by created_at or updated_at and ID instead of page number" Enums(cursor)
+// @Param cursor query string false "prev_cursor or next_cursor of a previous response"
 // @Success 200 {object} models.PaginatedDomainsResponse "Successfully retrieved domains"
 // @Failure 400 {object} models.ErrorResponse "Invalid target_id or query parameters"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
@@ -101,87 +109,52 @@ func GetDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
-
-	filters := models.DomainFilters{TargetID: targetID}
-	filters.Page, _ = strconv.Atoi(r.URL.Query().Get("page"))
-	if filters.Page <= 0 {
-		filters.Page = 1
+	if err := applySavedView(r, models.SavedViewTypeDomains, targetID); err != nil {
+		writeApplySavedViewError(w, r, err)
+		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	if limitStr == "" {
-		filters.Limit = 25
-	} else {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			filters.Limit = 25
-		} else {
-			if parsedLimit == 0 {
-				filters.Limit = 0
-			} else if parsedLimit < 0 || parsedLimit > 200 {
-				filters.Limit = 200
-			} else {
-				filters.Limit = parsedLimit
-			}
+	filters := core.DomainFiltersFromQuery(targetID, r.URL.Query())
+	// limit=0 lists all domains; without a limit a page holds 25.
+	pageNumber, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit := 25
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			limit = parsedLimit
 		}
 	}
+	page := database.NewPage(pageNumber, limit, 25, 200, true)
+	filters.Page, filters.Limit = page.Number, page.Limit
 
-	filters.SortBy = r.URL.Query().Get("sort_by")
-	if filters.SortBy == "" {
-		filters.SortBy = "domain_name"
-	}
-	filters.SortOrder = strings.ToUpper(r.URL.Query().Get("sort_order"))
-	if filters.SortOrder != "ASC" && filters.SortOrder != "DESC" {
-		filters.SortOrder = "ASC"
-	}
-
-	filters.DomainNameSearch = r.URL.Query().Get("domain_name_search")
-	filters.SourceSearch = r.URL.Query().Get("source_search")
-
-	if isInScopeStr := r.URL.Query().Get("is_in_scope"); isInScopeStr != "" {
-		isInScopeVal, err := strconv.ParseBool(isInScopeStr)
-		if err == nil {
-			filters.IsInScope = &isInScopeVal
+	var domains []models.Domain
+	var totalRecords int64
+	var distinctValues *database.DistinctDomainValues
+	var prevCursor, nextCursor string
+	if cursorPagination(r) {
+		if page.Limit == 0 {
+			page.Limit = 25 // Cursor pages are never unbounded
 		}
-	}
-
-	if isFavoriteStr := r.URL.Query().Get("is_favorite"); isFavoriteStr != "" {
-		isFavoriteVal, err := strconv.ParseBool(isFavoriteStr)
-		if err == nil {
-			filters.IsFavorite = &isFavoriteVal
+		keyset, errCursor := database.DomainKeyset.Page(r.URL.Query().Get("cursor"), r.URL.Query().Get("sort_by"), r.URL.Query().Get("sort_order"), page.Limit)
+		if errCursor != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, errCursor.Error())
+			return
 		}
+		filters.Page, filters.Limit = 0, page.Limit // Cursor pages have no number
+		filters.SortBy, filters.SortOrder = keyset.Column, keyset.Order
+		domains, totalRecords, distinctValues, prevCursor, nextCursor, err = database.GetDomainsByCursor(filters, keyset)
+	} else {
+		domains, totalRecords, distinctValues, err = database.GetDomains(filters)
 	}
-
-	// Filter by httpx scan status
-	filters.HttpxScanStatus = r.URL.Query().Get("httpx_scan_status")
-	if filters.HttpxScanStatus == "" {
-		filters.HttpxScanStatus = "all" // Default if not provided
-	}
-
-	// New distinct filters
-	filters.FilterHTTPStatusCode = r.URL.Query().Get("filter_http_status_code")
-	filters.FilterHTTPServer = r.URL.Query().Get("filter_http_server")
-	filters.FilterHTTPTech = r.URL.Query().Get("filter_http_tech")
-	domains, totalRecords, distinctValues, err := database.GetDomains(filters)
 	if err != nil {
 		logger.Error("GetDomainsHandler: Error getting domains for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve domains", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve domains")
 		return
 	}
 
-	var totalPages int64
-	if filters.Limit > 0 {
-		totalPages = (totalRecords + int64(filters.Limit) - 1) / int64(filters.Limit)
-	} else {
-		if totalRecords > 0 {
-			totalPages = 1
-		} else {
-			totalPages = 0
-		}
-	}
+	totalPages := page.TotalPages(totalRecords)
 
 	w.Header().Set("Content-Type", "application/json")
 	response := models.PaginatedDomainsResponse{
@@ -191,6 +164,8 @@ func GetDomainsHandler(w http.ResponseWriter, r *http.Request) {
 		TotalPages:   totalPages,
 		SortBy:       filters.SortBy,
 		SortOrder:    filters.SortOrder,
+		PrevCursor:   prevCursor,
+		NextCursor:   nextCursor,
 		Records:      domains,
 	}
 	if distinctValues != nil { // Populate distinct values if returned
@@ -201,6 +176,80 @@ func GetDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// ExportDomainsHandler handles GET requests to download the domains of a target.
+// @Summary Export domains
+// @Description Downloads all domains of a target matching the same filters as the domain list (no pagination): host names one per line (txt, for tools such as nuclei or ffuf), CSV, or JSON.
+// @Tags Domains
+// @Produce plain,json
+// @Param target_id path int true "Target ID"
+// @Param format query string false "Export format" Enums(txt, csv, json) default(txt)
+// @Param sort_by query string false "Column to sort by (e.g., domain_name, created_at)" default(domain_name)
+// @Param sort_order query string false "Sort order (asc, desc)" default(asc)
+// @Param domain_name_search query string false "Search term for domain name"
+// @Param source_search query string false "Search term for source"
+// @Param is_in_scope query boolean false "Filter by in-scope status"
+// @Param is_favorite query boolean false "Filter by favorite status"
+// @Param httpx_scan_status query string false "Filter by httpx scan status" Enums(all, scanned, not_scanned)
+// @Param filter_http_status_code query string false "Exact httpx status code (NULL for none)"
+// @Param filter_http_server query string false "Exact httpx server (NULL for none)"
+// @Param filter_http_tech query string false "Exact httpx tech string (NULL for none)"
+// @Param filter_favicon_hash query string false "Exact favicon hash"
+// @Param filter_open_port query string false "Open port"
+// @Param filter_takeover_status query string false "Takeover status (NULL for unchecked)" Enums(vulnerable, dangling, not_vulnerable, NULL)
+// @Param filter_root_domain query string false "Root domain (eTLD+1) the domains are under"
+// @Param filter_business_unit query string false "Business unit of the domains' root domain (NULL for none)"
+// @Param view_id query int false "Saved view whose filters are applied (explicit parameters take precedence)"
+// @Param view query string false "Saved view name (alternative to view_id)"
+// @Success 200 {string} string "Exported domains"
+// @Failure 400 {object} models.ErrorResponse "Invalid target_id or format"
+// @Failure 404 {object} models.ErrorResponse "Target or saved view not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /targets/{target_id}/domains/export [get]
+func ExportDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "txt"
+	}
+	if !slices.Contains(core.DomainExportFormats, format) {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("Invalid format '%s', expected one of: %s", format, strings.Join(core.DomainExportFormats, ", ")))
+		return
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("ExportDomainsHandler: Error getting target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve target")
+		return
+	}
+	if err := applySavedView(r, models.SavedViewTypeDomains, targetID); err != nil {
+		writeApplySavedViewError(w, r, err)
+		return
+	}
+
+	filters := core.DomainFiltersFromQuery(targetID, r.URL.Query())
+	filters.Page = 1
+	domains, _, _, err := database.GetDomains(filters)
+	if err != nil {
+		logger.Error("ExportDomainsHandler: Error getting domains for target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve domains")
+		return
+	}
+
+	contentType, ext := core.DomainExportContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="domains_target_%d.%s"`, targetID, ext))
+	if err := core.ExportDomains(w, format, domains); err != nil {
+		logger.Error("ExportDomainsHandler: Writing %s export for target %d: %v", format, targetID, err)
+	}
+}
+
 // UpdateDomainHandler handles PUT requests to update an existing domain.
 // @Summary Update an existing domain
 // @Description Updates details of a specific domain by its ID.
@@ -218,14 +267,14 @@ func UpdateDomainHandler(w http.ResponseWriter, r *http.Request) {
 	domainIDStr := chi.URLParam(r, "domain_id")
 	domainID, err := strconv.ParseInt(domainIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid domain_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain_id in path")
 		return
 	}
 
 	var domainUpdates models.Domain
 	if err := json.NewDecoder(r.Body).Decode(&domainUpdates); err != nil {
 		logger.Error("UpdateDomainHandler: Error decoding request body for domain %d: %v", domainID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -236,9 +285,9 @@ func UpdateDomainHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Error("UpdateDomainHandler: Error updating domain %d: %v", domainID, err)
 		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Domain not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Domain not found")
 		} else {
-			http.Error(w, "Failed to update domain", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update domain")
 		}
 		return
 	}
@@ -272,7 +321,7 @@ func DeleteDomainHandler(w http.ResponseWriter, r *http.Request) {
 	domainIDStr := chi.URLParam(r, "domain_id")
 	domainID, err := strconv.ParseInt(domainIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid domain_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain_id in path")
 		return
 	}
 
@@ -280,9 +329,9 @@ func DeleteDomainHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Error("DeleteDomainHandler: Error deleting domain %d: %v", domainID, err)
 		if strings.Contains(err.Error(), "not found") { // Check if the DB layer indicates "not found"
-			http.Error(w, "Domain not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Domain not found")
 		} else {
-			http.Error(w, "Failed to delete domain", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete domain")
 		}
 		return
 	}
@@ -306,31 +355,31 @@ func DiscoverSubdomainsHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
 
 	var req SubdomainDiscoveryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("DiscoverSubdomainsHandler: Error decoding request body for target %d: %v", targetID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if strings.TrimSpace(req.Domain) == "" {
-		http.Error(w, "Domain is required in the request payload", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Domain is required in the request payload")
 		return
 	}
 
-	_, err = exec.LookPath("subfinder")
+	subfinderPath, err := core.ResolveTool("subfinder")
 	if err != nil {
-		logger.Error("DiscoverSubdomainsHandler: subfinder command not found in PATH: %v", err)
-		http.Error(w, "Subdomain discovery tool (subfinder) is not configured or not found.", http.StatusInternalServerError)
+		logger.Error("DiscoverSubdomainsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Subdomain discovery tool (subfinder) is not configured or not found.")
 		return
 	}
 
-	go runSubfinderAndStoreResults(targetID, req)
+	go runSubfinderAndStoreResults(subfinderPath, targetID, req)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
@@ -340,7 +389,24 @@ func DiscoverSubdomainsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func runSubfinderAndStoreResults(targetID int64, config SubdomainDiscoveryRequest) {
+// subfinderRateLimitArgs translates the rate_limit config section into subfinder flags.
+func subfinderRateLimitArgs() []string {
+	rlConf := config.AppConfig.RateLimit
+	if !rlConf.Enabled {
+		return nil
+	}
+	rate := int(rlConf.RequestsPerSecond)
+	if rate < 1 {
+		rate = 1
+	}
+	args := []string{"-rl", strconv.Itoa(rate)}
+	if rlConf.MaxConcurrency > 0 {
+		args = append(args, "-t", strconv.Itoa(rlConf.MaxConcurrency))
+	}
+	return args
+}
+
+func runSubfinderAndStoreResults(subfinderPath string, targetID int64, config SubdomainDiscoveryRequest) {
 	logger.Info("Starting subfinder for target %d, domain %s", targetID, config.Domain)
 
 	args := []string{"-d", config.Domain, "-json", "-silent"}
@@ -350,11 +416,12 @@ func runSubfinderAndStoreResults(targetID int64, config SubdomainDiscoveryReques
 	if len(config.Sources) > 0 {
 		args = append(args, "-sources", strings.Join(config.Sources, ","))
 	}
+	args = append(args, subfinderRateLimitArgs()...)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "subfinder", args...)
+	cmd := exec.CommandContext(ctx, subfinderPath, args...)
 	output, err := cmd.Output()
 
 	if ctx.Err() == context.DeadlineExceeded {
@@ -373,6 +440,7 @@ func runSubfinderAndStoreResults(targetID int64, config SubdomainDiscoveryReques
 
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	var discoveredCount int
+	var discovered []string
 	for _, line := range lines {
 		if strings.TrimSpace(line) == "" {
 			continue
@@ -394,12 +462,20 @@ func runSubfinderAndStoreResults(targetID int64, config SubdomainDiscoveryReques
 				}
 			} else {
 				discoveredCount++
+				discovered = append(discovered, result.Host)
 			}
 		} else if err != nil {
 			logger.Warn("Failed to parse subfinder output line: '%s'. Error: %v", line, err)
 		}
 	}
 	logger.Info("Subfinder finished for target %d, domain %s. Discovered and attempted to store %d new subdomains.", targetID, config.Domain, discoveredCount)
+	if discoveredCount > 0 {
+		core.NotifyTargetEvent(models.NotificationNewSubdomain, targetID, fmt.Sprintf("%d new subdomains of %s", discoveredCount, config.Domain),
+			core.SubdomainNotificationList(discovered, 10), discovered)
+		core.ResolveIPAssetsAfterDiscovery(targetID)
+	}
+	core.NotifyTargetEvent(models.NotificationScanFinished, targetID, fmt.Sprintf("Subfinder finished for %s", config.Domain),
+		fmt.Sprintf("%d new subdomains out of %d results.", discoveredCount, len(lines)), nil)
 }
 
 // ImportInScopeDomainsHandler handles POST requests to import in-scope domains from a target's scope rules.
@@ -416,14 +492,14 @@ func ImportInScopeDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
 
 	scopeRules, err := database.GetScopeRulesByTargetID(targetID)
 	if err != nil {
 		logger.Error("ImportInScopeDomainsHandler: Error fetching scope rules for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve scope rules", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve scope rules")
 		return
 	}
 
@@ -526,6 +602,70 @@ func ImportInScopeDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info("ImportInScopeDomainsHandler: For target %d, imported %d, skipped %d domains. Errors: %d", targetID, importedCount, skippedCount, len(errorMessages))
 }
 
+// maxDomainImportBytes caps the size of a domain import file.
+const maxDomainImportBytes = 32 << 20
+
+// ImportDomainsHandler handles POST requests to bulk import domains from a file.
+// @Summary Import domains from a file
+// @Description Adds the domains of a newline-delimited or CSV file (subfinder, amass, ... output) to a target, skipping duplicates, domains already tracked and, with validate_scope, out-of-scope domains. The file is sent as the "file" field of a multipart form (with source, validate_scope and dry_run form fields) or as the raw request body (with those as query parameters).
+// @Tags Domains
+// @Accept mpfd,plain
+// @Produce json
+// @Param target_id path int true "Target ID"
+// @Param file formData file false "Domain list (multipart uploads)"
+// @Param source query string false "Source recorded for the added domains (default: import)"
+// @Param validate_scope query bool false "Skip domains outside the target's scope"
+// @Param dry_run query bool false "Only report what would be added"
+// @Success 200 {object} models.DomainImportResult "Import summary"
+// @Failure 400 {object} models.ErrorResponse "Invalid target_id or import file"
+// @Failure 404 {object} models.ErrorResponse "Target not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /targets/{target_id}/domains/import [post]
+func ImportDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxDomainImportBytes)
+	defer r.Body.Close()
+
+	body := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxDomainImportBytes); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid multipart form: "+err.Error())
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Missing 'file' field: "+err.Error())
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	opts := models.DomainImportOptions{Source: r.FormValue("source")}
+	opts.ValidateScope, _ = strconv.ParseBool(r.FormValue("validate_scope"))
+	opts.DryRun, _ = strconv.ParseBool(r.FormValue("dry_run"))
+
+	result, err := core.ImportDomains(targetID, body, opts)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.HasPrefix(msg, "invalid import file"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		default:
+			logger.Error("ImportDomainsHandler: Importing domains for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to import domains")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // DeleteAllDomainsForTargetHandler handles DELETE requests to remove all domains for a specific target.
 // @Summary Delete all domains for a target
 // @Description Deletes all domain entries associated with the specified target ID.
@@ -540,14 +680,14 @@ func DeleteAllDomainsForTargetHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
 
 	deletedCount, err := database.DeleteAllDomainsForTarget(targetID)
 	if err != nil {
 		logger.Error("DeleteAllDomainsForTargetHandler: Error deleting domains for target %d: %v", targetID, err)
-		http.Error(w, "Failed to delete domains for target", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete domains for target")
 		return
 	}
 
@@ -574,14 +714,14 @@ func GetDomainDetailHandler(w http.ResponseWriter, r *http.Request) {
 	domainIDStr := chi.URLParam(r, "domain_id")
 	domainID, err := strconv.ParseInt(domainIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid domain_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain_id in path")
 		return
 	}
 
 	domain, err := database.GetDomainByID(domainID) // This function will need to be updated
 	if err != nil {
 		// GetDomainByID should return a specific error for "not found"
-		http.Error(w, err.Error(), http.StatusNotFound) // Or InternalServerError based on error type
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error()) // Or InternalServerError based on error type
 		return
 	}
 
@@ -605,7 +745,7 @@ func RunHttpxForDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
 
@@ -614,13 +754,13 @@ func RunHttpxForDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("RunHttpxForDomainsHandler: Error decoding request body for target %d: %v", targetID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if len(req.DomainIDs) == 0 {
-		http.Error(w, "No domain IDs provided", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "No domain IDs provided")
 		return
 	}
 
@@ -628,17 +768,22 @@ func RunHttpxForDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	domains, err := database.GetDomainsByIDs(req.DomainIDs)
 	if err != nil {
 		logger.Error("RunHttpxForDomainsHandler: Error fetching domains by IDs for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve domain details", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve domain details")
 		return
 	}
 
-	go RunHttpxScan(targetID, domains) // Run asynchronously
+	job, err := StartHttpxScan(targetID, domains)
+	if err != nil {
+		writeHttpxStartError(w, r, "RunHttpxForDomainsHandler", targetID, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message":   fmt.Sprintf("Httpx scan initiated for %d domain(s).", len(domains)),
 		"target_id": targetIDStr,
+		"job_id":    job.ID,
 	})
 }
 
@@ -659,7 +804,7 @@ func SetDomainFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	domainIDStr := chi.URLParam(r, "domain_id")
 	domainID, err := strconv.ParseInt(domainIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain ID")
 		return
 	}
 
@@ -667,16 +812,16 @@ func SetDomainFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 		IsFavorite bool `json:"is_favorite"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 
 	if err := database.SetDomainFavoriteStatus(domainID, reqBody.IsFavorite); err != nil {
 		// database.SetDomainFavoriteStatus logs specific errors and can return a "not found" error
 		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Domain not found: "+err.Error(), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Domain not found: "+err.Error())
 		} else {
-			http.Error(w, "Failed to update favorite status: "+err.Error(), http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update favorite status: "+err.Error())
 		}
 		return
 	}
@@ -700,7 +845,7 @@ func FavoriteAllFilteredDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
 		return
 	}
 
@@ -711,14 +856,14 @@ func FavoriteAllFilteredDomainsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&filters); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 
 	updatedCount, err := database.FavoriteAllFilteredDomainsDB(targetID, filters.DomainNameSearch, filters.SourceSearch, filters.IsInScope)
 	if err != nil {
 		logger.Error("FavoriteAllFilteredDomainsHandler: Error updating domains for target %d: %v", targetID, err)
-		http.Error(w, "Failed to update domains: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update domains: "+err.Error())
 		return
 	}
 
@@ -742,14 +887,14 @@ func RunHttpxForAllFilteredDomainsHandler(w http.ResponseWriter, r *http.Request
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
 
 	var filters models.DomainFilters
 	if err := json.NewDecoder(r.Body).Decode(&filters); err != nil {
 		logger.Error("RunHttpxForAllFilteredDomainsHandler: Error decoding request body for target %d: %v", targetID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -764,7 +909,7 @@ func RunHttpxForAllFilteredDomainsHandler(w http.ResponseWriter, r *http.Request
 	logger.Info("RunHttpxForAllFilteredDomainsHandler: GetDomainIDsByFilters returned %d IDs for target %d with filters: %+v", len(domainIDs), targetID, filters)
 	if err != nil {
 		logger.Error("RunHttpxForAllFilteredDomainsHandler: Error fetching domain IDs by filters for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve domain IDs for scan", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve domain IDs for scan")
 		return
 	}
 
@@ -780,17 +925,22 @@ func RunHttpxForAllFilteredDomainsHandler(w http.ResponseWriter, r *http.Request
 	logger.Info("RunHttpxForAllFilteredDomainsHandler: GetDomainsByIDs returned %d full domain objects for target %d", len(domainsToScan), targetID)
 	if err != nil {
 		logger.Error("RunHttpxForAllFilteredDomainsHandler: Error fetching full domain details by IDs for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve full domain details for scan", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve full domain details for scan")
 		return
 	}
 
-	logger.Info("RunHttpxForAllFilteredDomainsHandler: Initiating RunHttpxScan with %d domains for target %d", len(domainsToScan), targetID)
-	go RunHttpxScan(targetID, domainsToScan) // Run asynchronously
+	logger.Info("RunHttpxForAllFilteredDomainsHandler: Initiating httpx scan with %d domains for target %d", len(domainsToScan), targetID)
+	job, err := StartHttpxScan(targetID, domainsToScan)
+	if err != nil {
+		writeHttpxStartError(w, r, "RunHttpxForAllFilteredDomainsHandler", targetID, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message":   fmt.Sprintf("Httpx scan initiated for %d filtered domain(s).", len(domainsToScan)),
 		"target_id": targetIDStr,
+		"job_id":    job.ID,
 	})
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HarvestDomainsHandler starts a job fetching robots.txt, sitemaps and security.txt for the given
+// domains of a target (every domain with an httpx result when domain_ids is empty).
+func HarvestDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.HarvestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartHarvest(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "no domains"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("HarvestDomainsHandler: Error starting harvest for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start harvest")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetDomainHarvestHandler returns the latest harvest of a domain with the URLs it discovered.
+func GetDomainHarvestHandler(w http.ResponseWriter, r *http.Request) {
+	domainID, err := strconv.ParseInt(chi.URLParam(r, "domain_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain ID")
+		return
+	}
+	harvest, err := database.GetDomainHarvest(domainID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no harvest found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetDomainHarvestHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load harvest")
+		return
+	}
+	urls, err := database.ListHarvestedURLs(harvest.TargetID, harvest.BaseURL)
+	if err != nil {
+		logger.Error("GetDomainHarvestHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load harvested URLs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DomainHarvestView{Harvest: harvest, URLs: urls})
+}
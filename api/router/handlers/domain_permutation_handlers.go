@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PermuteDomainsHandler starts a job generating subdomain candidates of a target from its known
+// domains and a wordlist and adding the ones that resolve. With dry_run the candidates are
+// returned instead (200).
+func PermuteDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.PermutationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, preview, err := core.StartPermutation(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.HasPrefix(msg, "no base domains"), strings.HasPrefix(msg, "no words"), strings.HasPrefix(msg, "no new candidates"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("PermuteDomainsHandler: Error starting permutation for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start permutation")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if preview != nil {
+		json.NewEncoder(w).Encode(preview)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
@@ -18,6 +18,9 @@ func RegisterDomainRoutes(r chi.Router) {
 		subRouter.Delete("/", DeleteDomainHandler)
 		subRouter.Get("/details", GetDomainDetailHandler)
 		subRouter.Put("/favorite", SetDomainFavoriteHandler) // New route for favorite
+		subRouter.Get("/harvest", GetDomainHarvestHandler)
+		subRouter.Get("/baseline", GetDomainBaselineHandler)
+		subRouter.Get("/response-clusters", GetDomainResponseClustersHandler)
 	})
 
 	// Discover subdomains for a specific target
@@ -26,6 +29,12 @@ func RegisterDomainRoutes(r chi.Router) {
 	// Import in-scope domains from target's scope rules
 	r.Post("/targets/{target_id}/domains/import-scope", ImportInScopeDomainsHandler)
 
+	// Import domains from a newline-delimited or CSV file
+	r.Post("/targets/{target_id}/domains/import", ImportDomainsHandler)
+
+	// Export domains matching the list filters as txt, CSV or JSON
+	r.Get("/targets/{target_id}/domains/export", ExportDomainsHandler)
+
 	// Delete all domains for a specific target
 	r.Delete("/targets/{target_id}/domains/all", DeleteAllDomainsForTargetHandler)
 
@@ -37,4 +46,23 @@ func RegisterDomainRoutes(r chi.Router) {
 
 	// Run httpx for ALL domains matching filters for a specific target
 	r.Post("/targets/{target_id}/domains/run-httpx-all-filtered", RunHttpxForAllFilteredDomainsHandler)
+
+	// Generate subdomain permutations of known domains and add the ones that resolve
+	r.Post("/targets/{target_id}/domains/permutations", PermuteDomainsHandler)
+
+	// Hash favicons and look up Shodan/Censys host data for domains of a specific target
+	r.Post("/targets/{target_id}/domains/enrich", EnrichDomainsHandler)
+
+	// Verify subdomain takeovers of domains of a specific target
+	r.Post("/targets/{target_id}/domains/takeover-check", CheckDomainTakeoverHandler)
+
+	// Harvest robots.txt, sitemaps and security.txt of domains of a specific target
+	r.Post("/targets/{target_id}/domains/harvest", HarvestDomainsHandler)
+
+	// Learn the soft-404/wildcard pages of domains of a specific target
+	r.Post("/targets/{target_id}/domains/baseline", BaselineDomainsHandler)
+
+	// Group domains of a specific target by root domain and business unit
+	r.Get("/targets/{target_id}/domain-groups", handle(GetDomainGroupsHandler))
+	r.Put("/targets/{target_id}/domain-groups/{root_domain}", handle(SetDomainGroupHandler))
 }
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// parseEngagementPeriod reads the optional ?since= and ?until= bounds (RFC 3339 or YYYY-MM-DD).
+func parseEngagementPeriod(r *http.Request) (since, until *time.Time, err error) {
+	for param, dest := range map[string]**time.Time{"since": &since, "until": &until} {
+		if value := r.URL.Query().Get(param); value != "" {
+			t, err := core.ParseEngagementTime(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			*dest = &t
+		}
+	}
+	return since, until, nil
+}
+
+// writeEngagementError maps an engagement error to its status code.
+func writeEngagementError(w http.ResponseWriter, r *http.Request, handler, failure string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.HasPrefix(msg, "invalid"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "already has a running"), strings.Contains(msg, "has no running"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, failure)
+	}
+}
+
+// ListEngagementSessionsHandler returns the engagement sessions of a target, latest first.
+// ?since= and ?until= limit them to those started within the period; ?limit= caps their number.
+func ListEngagementSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	since, until, err := parseEngagementPeriod(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	sessions, err := core.ListEngagementSessions(targetID, since, until, limit)
+	if err != nil {
+		writeEngagementError(w, r, "ListEngagementSessionsHandler", "Failed to list engagement sessions", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// StartEngagementSessionHandler starts the engagement timer of a target, optionally with a label,
+// a time budget (planned_minutes) and a deadline (due_at).
+func StartEngagementSessionHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.EngagementSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	session, err := core.StartEngagementSession(targetID, req)
+	if err != nil {
+		writeEngagementError(w, r, "StartEngagementSessionHandler", "Failed to start engagement session", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// StopEngagementSessionHandler stops the running engagement session of a target, optionally
+// replacing its notes, and returns it with its final counts.
+func StopEngagementSessionHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req struct {
+		Notes *string `json:"notes,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	session, err := core.StopEngagementSession(targetID, req.Notes)
+	if err != nil {
+		writeEngagementError(w, r, "StopEngagementSessionHandler", "Failed to stop engagement session", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// GetEngagementSummaryHandler totals the time spent and requests made on a target per day, over
+// the sessions started within ?since= and ?until= (RFC 3339 or YYYY-MM-DD, both optional).
+func GetEngagementSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	since, until, err := parseEngagementPeriod(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	summary, err := core.GetEngagementSummary(targetID, since, until)
+	if err != nil {
+		writeEngagementError(w, r, "GetEngagementSummaryHandler", "Failed to summarize engagement", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// UpdateEngagementSessionHandler changes the label, time budget, deadline or notes of an
+// engagement session. Omitted fields are left unchanged; a zero due_at clears the deadline.
+func UpdateEngagementSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid engagement session ID")
+		return
+	}
+	var req models.EngagementSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	session, err := core.UpdateEngagementSession(id, req)
+	if err != nil {
+		writeEngagementError(w, r, "UpdateEngagementSessionHandler", "Failed to update engagement session", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// DeleteEngagementSessionHandler deletes an engagement session.
+func DeleteEngagementSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid engagement session ID")
+		return
+	}
+	if err := core.DeleteEngagementSession(id); err != nil {
+		writeEngagementError(w, r, "DeleteEngagementSessionHandler", "Failed to delete engagement session", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterEngagementRoutes sets up the routes of the per-target engagement timer and its summary.
+func RegisterEngagementRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/engagement/sessions", ListEngagementSessionsHandler)
+	r.Post("/targets/{target_id}/engagement/sessions", StartEngagementSessionHandler)
+	r.Post("/targets/{target_id}/engagement/sessions/stop", StopEngagementSessionHandler)
+	r.Get("/targets/{target_id}/engagement/summary", GetEngagementSummaryHandler)
+	r.Put("/engagement/sessions/{id}", UpdateEngagementSessionHandler)
+	r.Delete("/engagement/sessions/{id}", DeleteEngagementSessionHandler)
+}
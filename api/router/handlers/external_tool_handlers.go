@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeExternalToolError maps external tool errors to HTTP status codes.
+func writeExternalToolError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "unknown tool"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "invalid version"), strings.Contains(msg, "not configured"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "download of"), strings.Contains(msg, "checksum"), strings.Contains(msg, "no release"):
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
+	}
+}
+
+// GetExternalToolsHandler lists the external tools with the binary the runners use, its version
+// and whether it is compatible.
+func GetExternalToolsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.ExternalToolStatuses(r.Context()))
+}
+
+// InstallExternalToolHandler downloads a release of a tool into tools.bin_dir. The optional body
+// {"version": "2.6.6"} selects the release; the pinned one is installed by default.
+func InstallExternalToolHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ExternalToolInstallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	status, err := core.InstallExternalTool(r.Context(), chi.URLParam(r, "tool"), req.Version)
+	if err != nil {
+		writeExternalToolError(w, r, "InstallExternalToolHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
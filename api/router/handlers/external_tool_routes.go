@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterExternalToolRoutes sets up the routes of the external tools the toolkit runs.
+func RegisterExternalToolRoutes(r chi.Router) {
+	r.Get("/tools", GetExternalToolsHandler)
+	r.Post("/tools/{tool}/install", InstallExternalToolHandler)
+}
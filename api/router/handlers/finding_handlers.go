@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -24,19 +26,19 @@ func CreateTargetFindingHandler(w http.ResponseWriter, r *http.Request) {
 	var findingReq models.TargetFinding
 	if err := json.NewDecoder(r.Body).Decode(&findingReq); err != nil {
 		logger.Error("CreateTargetFindingHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if findingReq.TargetID == 0 {
 		logger.Error("CreateTargetFindingHandler: target_id is required in the request body")
-		http.Error(w, "target_id is required in the request body", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id is required in the request body")
 		return
 	}
 	if strings.TrimSpace(findingReq.Title) == "" {
 		logger.Error("CreateTargetFindingHandler: Finding title cannot be empty for target_id %d", findingReq.TargetID)
-		http.Error(w, "Finding title cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Finding title cannot be empty")
 		return
 	}
 
@@ -45,7 +47,7 @@ func CreateTargetFindingHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := database.CreateTargetFinding(findingReq)
 	if err != nil {
 		logger.Error("CreateTargetFindingHandler: Error creating finding for target %d: %v", findingReq.TargetID, err)
-		http.Error(w, "Failed to create finding", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create finding")
 		return
 	}
 
@@ -63,13 +65,42 @@ func CreateTargetFindingHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdFinding)
 }
 
+// PromoteTrafficLogEntryHandler creates a draft finding from a traffic log entry, pre-filled with
+// its URL, method and request/response evidence. The optional JSON body picks the vulnerability
+// type (by vulnerability_type_id or vulnerability_type name), title and severity.
+func PromoteTrafficLogEntryHandler(w http.ResponseWriter, r *http.Request, logID int64) {
+	var req models.FindingPromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	finding, err := core.PromoteTrafficToFinding(logID, req)
+	if err != nil {
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "no target"), strings.Contains(msg, "invalid severity"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		default:
+			logger.Error("PromoteTrafficLogEntryHandler: Error promoting log %d: %v", logID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to promote log entry to a finding")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(finding)
+}
+
 // GetTargetFindingsHandler handles GET requests to list findings for a target.
 func GetTargetFindingsHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := chi.URLParam(r, "target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetTargetFindingsHandler: Invalid target_id format: %s", targetIDStr)
-		http.Error(w, "Invalid target ID format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID format")
 		return
 	}
 
@@ -77,7 +108,7 @@ func GetTargetFindingsHandler(w http.ResponseWriter, r *http.Request) {
 	findings, err := database.GetTargetFindingsByTargetID(targetID)
 	if err != nil {
 		logger.Error("GetTargetFindingsHandler: Error fetching findings for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve findings", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve findings")
 		return
 	}
 
@@ -95,7 +126,7 @@ func GetFindingByIDHandler(w http.ResponseWriter, r *http.Request) {
 	findingID, err := strconv.ParseInt(findingIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetFindingByIDHandler: Invalid finding_id format: %s", findingIDStr)
-		http.Error(w, "Invalid finding ID format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid finding ID format")
 		return
 	}
 
@@ -104,10 +135,10 @@ func GetFindingByIDHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("GetFindingByIDHandler: Finding %d not found: %v", findingID, err)
-			http.Error(w, "Finding not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Finding not found")
 		} else {
 			logger.Error("GetFindingByIDHandler: Error fetching finding %d: %v", findingID, err)
-			http.Error(w, "Failed to retrieve finding", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve finding")
 		}
 		return
 	}
@@ -122,27 +153,27 @@ func UpdateTargetFindingHandler(w http.ResponseWriter, r *http.Request) {
 	findingID, err := strconv.ParseInt(findingIDStr, 10, 64)
 	if err != nil {
 		logger.Error("UpdateTargetFindingHandler: Invalid finding_id format: %s", findingIDStr)
-		http.Error(w, "Invalid finding ID format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid finding ID format")
 		return
 	}
 
 	var findingUpdateReq models.TargetFinding
 	if err := json.NewDecoder(r.Body).Decode(&findingUpdateReq); err != nil {
 		logger.Error("UpdateTargetFindingHandler: Error decoding request body for finding %d: %v", findingID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if strings.TrimSpace(findingUpdateReq.Title) == "" {
-		http.Error(w, "Finding title cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Finding title cannot be empty")
 		return
 	}
 
 	existingFinding, err := database.GetTargetFindingByID(findingID)
 	if err != nil {
 		logger.Error("UpdateTargetFindingHandler: Finding %d not found: %v", findingID, err)
-		http.Error(w, "Finding not found", http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Finding not found")
 		return
 	}
 
@@ -150,10 +181,15 @@ func UpdateTargetFindingHandler(w http.ResponseWriter, r *http.Request) {
 	findingUpdateReq.TargetID = existingFinding.TargetID
 	findingUpdateReq.DiscoveredAt = existingFinding.DiscoveredAt // Preserve original discovery date
 
+	// Soft locks are advisory: the update goes through and the holder is reported.
+	if holder := core.LockHolder(core.APIUser(r.Context()), models.LockEntityFinding, findingID); holder != "" {
+		w.Header().Set("X-Toolkit-Locked-By", holder)
+	}
+
 	// database.UpdateTargetFinding is expected to handle all new fields in findingUpdateReq.
 	if err := database.UpdateTargetFinding(findingUpdateReq); err != nil {
 		logger.Error("UpdateTargetFindingHandler: Error updating finding %d: %v", findingID, err)
-		http.Error(w, "Failed to update finding", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update finding")
 		return
 	}
 
@@ -176,20 +212,20 @@ func DeleteTargetFindingHandler(w http.ResponseWriter, r *http.Request) {
 	findingID, err := strconv.ParseInt(findingIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeleteTargetFindingHandler: Invalid finding_id format: %s", findingIDStr)
-		http.Error(w, "Invalid finding ID format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid finding ID format")
 		return
 	}
 
 	finding, err := database.GetTargetFindingByID(findingID)
 	if err != nil {
 		logger.Error("DeleteTargetFindingHandler: Finding %d not found for deletion: %v", findingID, err)
-		http.Error(w, "Finding not found", http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Finding not found")
 		return
 	}
 
 	if err := database.DeleteTargetFinding(findingID, finding.TargetID); err != nil {
 		logger.Error("DeleteTargetFindingHandler: Error deleting finding %d: %v", findingID, err)
-		http.Error(w, "Failed to delete finding", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete finding")
 		return
 	}
 
@@ -203,14 +239,14 @@ func CreateVulnerabilityTypeHandler(w http.ResponseWriter, r *http.Request) {
 	var req VulnerabilityTypeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("CreateVulnerabilityTypeHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if strings.TrimSpace(req.Name) == "" {
 		logger.Error("CreateVulnerabilityTypeHandler: Vulnerability type name cannot be empty")
-		http.Error(w, "Vulnerability type name cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Vulnerability type name cannot be empty")
 		return
 	}
 
@@ -224,9 +260,9 @@ func CreateVulnerabilityTypeHandler(w http.ResponseWriter, r *http.Request) {
 		logger.Error("CreateVulnerabilityTypeHandler: Error creating vulnerability type: %v", err)
 		// Check for unique constraint violation specifically if your DB layer returns a recognizable error
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			http.Error(w, "Vulnerability type with this name already exists", http.StatusConflict)
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, "Vulnerability type with this name already exists")
 		} else {
-			http.Error(w, "Failed to create vulnerability type", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create vulnerability type")
 		}
 		return
 	}
@@ -254,7 +290,7 @@ func GetAllVulnerabilityTypesHandler(w http.ResponseWriter, r *http.Request) {
 	types, err := database.GetAllVulnerabilityTypes()
 	if err != nil {
 		logger.Error("GetAllVulnerabilityTypesHandler: Error fetching vulnerability types: %v", err)
-		http.Error(w, "Failed to retrieve vulnerability types", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve vulnerability types")
 		return
 	}
 	if types == nil {
@@ -270,20 +306,20 @@ func UpdateVulnerabilityTypeHandler(w http.ResponseWriter, r *http.Request) {
 	vtID, err := strconv.ParseInt(vtIDStr, 10, 64)
 	if err != nil {
 		logger.Error("UpdateVulnerabilityTypeHandler: Invalid vulnerability_type_id format: %s", vtIDStr)
-		http.Error(w, "Invalid vulnerability type ID format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid vulnerability type ID format")
 		return
 	}
 
 	var req VulnerabilityTypeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("UpdateVulnerabilityTypeHandler: Error decoding request body for ID %d: %v", vtID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if strings.TrimSpace(req.Name) == "" {
-		http.Error(w, "Vulnerability type name cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Vulnerability type name cannot be empty")
 		return
 	}
 
@@ -296,11 +332,11 @@ func UpdateVulnerabilityTypeHandler(w http.ResponseWriter, r *http.Request) {
 	if err := database.UpdateVulnerabilityType(vtUpdate); err != nil {
 		logger.Error("UpdateVulnerabilityTypeHandler: Error updating vulnerability type %d: %v", vtID, err)
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			http.Error(w, "Vulnerability type with this name already exists", http.StatusConflict)
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, "Vulnerability type with this name already exists")
 		} else if strings.Contains(err.Error(), "not found") { // Assuming DB layer might return this
-			http.Error(w, "Vulnerability type not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Vulnerability type not found")
 		} else {
-			http.Error(w, "Failed to update vulnerability type", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update vulnerability type")
 		}
 		return
 	}
@@ -325,14 +361,14 @@ func DeleteVulnerabilityTypeHandler(w http.ResponseWriter, r *http.Request) {
 	vtID, err := strconv.ParseInt(vtIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeleteVulnerabilityTypeHandler: Invalid vulnerability_type_id format: %s", vtIDStr)
-		http.Error(w, "Invalid vulnerability type ID format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid vulnerability type ID format")
 		return
 	}
 
 	// Optional: Check if the type exists before attempting delete, to return 404 if not found.
 	// _, fetchErr := database.GetVulnerabilityTypeByID(vtID)
 	// if fetchErr != nil {
-	// 	http.Error(w, "Vulnerability type not found", http.StatusNotFound)
+	// 	WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Vulnerability type not found")
 	// 	return
 	// }
 
@@ -340,9 +376,9 @@ func DeleteVulnerabilityTypeHandler(w http.ResponseWriter, r *http.Request) {
 		logger.Error("DeleteVulnerabilityTypeHandler: Error deleting vulnerability type %d: %v", vtID, err)
 		// Check for foreign key constraint violation if your DB layer returns a recognizable error
 		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
-			http.Error(w, "Cannot delete vulnerability type: it is currently associated with one or more findings.", http.StatusConflict)
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, "Cannot delete vulnerability type: it is currently associated with one or more findings.")
 		} else {
-			http.Error(w, "Failed to delete vulnerability type", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete vulnerability type")
 		}
 		return
 	}
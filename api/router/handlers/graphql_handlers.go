@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetGraphQLEndpointsHandler lists detected GraphQL endpoints.
+// Query parameters: target_id.
+func GetGraphQLEndpointsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	endpoints, err := database.GetGraphQLEndpoints(targetID)
+	if err != nil {
+		logger.Error("GetGraphQLEndpointsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve GraphQL endpoints")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+// GetGraphQLEndpointHandler returns a single GraphQL endpoint.
+func GetGraphQLEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := strconv.ParseInt(chi.URLParam(r, "endpoint_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid GraphQL endpoint ID")
+		return
+	}
+	endpoint, err := database.GetGraphQLEndpointByID(endpointID)
+	if err != nil {
+		writeGraphQLLookupError(w, r, "GetGraphQLEndpointHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+// GetGraphQLSchemaHandler returns the stored schema of an endpoint.
+// Query parameter: format (summary: root types and fields (default); raw: the introspection JSON).
+func GetGraphQLSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := strconv.ParseInt(chi.URLParam(r, "endpoint_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid GraphQL endpoint ID")
+		return
+	}
+	schema, err := database.GetGraphQLSchema(endpointID)
+	if err != nil {
+		writeGraphQLLookupError(w, r, "GetGraphQLSchemaHandler", err)
+		return
+	}
+	if schema == "" {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "No schema stored for this endpoint; run introspection first")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("format") == "raw" {
+		io.WriteString(w, schema)
+		return
+	}
+	summary, err := core.SummarizeGraphQLSchema(schema)
+	if err != nil {
+		logger.Error("GetGraphQLSchemaHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Stored schema could not be read: "+err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(summary)
+}
+
+// IntrospectGraphQLHandler runs introspection against an endpoint and stores the schema.
+// The optional body selects a replay session and extra headers used for authentication.
+func IntrospectGraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := strconv.ParseInt(chi.URLParam(r, "endpoint_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid GraphQL endpoint ID")
+		return
+	}
+	var req models.GraphQLIntrospectRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	summary, err := core.IntrospectGraphQLEndpoint(endpointID, req)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("IntrospectGraphQLHandler: Endpoint %d: %v", endpointID, err)
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, "Introspection failed: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GetGraphQLOperationsHandler lists captured GraphQL operations.
+// Query parameters: target_id, endpoint_id, type, search, page, limit.
+func GetGraphQLOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.GraphQLOperationFilters{
+		OperationType: q.Get("type"),
+		Search:        q.Get("search"),
+	}
+	filters.TargetID, _ = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	filters.EndpointID, _ = strconv.ParseInt(q.Get("endpoint_id"), 10, 64)
+	filters.Page, _ = strconv.Atoi(q.Get("page"))
+	filters.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.Limit <= 0 || filters.Limit > 500 {
+		filters.Limit = 50
+	}
+
+	operations, totalRecords, err := database.GetGraphQLOperations(filters)
+	if err != nil {
+		logger.Error("GetGraphQLOperationsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve GraphQL operations")
+		return
+	}
+	totalPages := (totalRecords + int64(filters.Limit) - 1) / int64(filters.Limit)
+
+	response := struct {
+		Page         int                       `json:"page"`
+		Limit        int                       `json:"limit"`
+		TotalRecords int64                     `json:"total_records"`
+		TotalPages   int64                     `json:"total_pages"`
+		Operations   []models.GraphQLOperation `json:"operations"`
+	}{
+		Page:         filters.Page,
+		Limit:        filters.Limit,
+		TotalRecords: totalRecords,
+		TotalPages:   totalPages,
+		Operations:   operations,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetGraphQLOperationHandler returns an operation with its variable definitions and root fields,
+// which the query builder uses to render editable variables.
+func GetGraphQLOperationHandler(w http.ResponseWriter, r *http.Request) {
+	operationID, err := strconv.ParseInt(chi.URLParam(r, "operation_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid GraphQL operation ID")
+		return
+	}
+	detail, err := core.GetGraphQLOperationDetail(operationID)
+	if err != nil {
+		writeGraphQLLookupError(w, r, "GetGraphQLOperationHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// BuildGraphQLModifierTaskHandler creates a modifier task from an operation, with optional
+// edited variables, query, session and headers.
+func BuildGraphQLModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
+	operationID, err := strconv.ParseInt(chi.URLParam(r, "operation_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid GraphQL operation ID")
+		return
+	}
+	var req models.GraphQLBuildTaskRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	task, err := core.BuildGraphQLModifierTask(operationID, req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		case strings.Contains(err.Error(), "variables must be") || strings.Contains(err.Error(), "persisted query"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		default:
+			logger.Error("BuildGraphQLModifierTaskHandler: Operation %d: %v", operationID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create modifier task: "+err.Error())
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(task)
+}
+
+func writeGraphQLLookupError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		return
+	}
+	logger.Error("%s: %v", handler, err)
+	WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve GraphQL data")
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterGraphQLRoutes(r chi.Router) {
+	r.Get("/graphql/endpoints", GetGraphQLEndpointsHandler)
+	r.Get("/graphql/endpoints/{endpoint_id}", GetGraphQLEndpointHandler)
+	r.Get("/graphql/endpoints/{endpoint_id}/schema", GetGraphQLSchemaHandler)       // ?format=summary|raw
+	r.Post("/graphql/endpoints/{endpoint_id}/introspect", IntrospectGraphQLHandler) // Sends the introspection query through the proxy
+	r.Get("/graphql/operations", GetGraphQLOperationsHandler)
+	r.Get("/graphql/operations/{operation_id}", GetGraphQLOperationHandler)
+	r.Post("/graphql/operations/{operation_id}/modifier-task", BuildGraphQLModifierTaskHandler)
+}
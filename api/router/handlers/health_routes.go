@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"toolkit/core"
 	"toolkit/logger"
 
 	"github.com/go-chi/chi/v5"
@@ -15,7 +16,7 @@ func RegisterHealthRoutes(r chi.Router) {
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]bool{"ok": true}); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]bool{"ok": true, "read_only": core.ReadOnly()}); err != nil {
 		logger.Error("Error encoding health check response: %v", err)
 	}
 }
@@ -1,17 +1,44 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"toolkit/models" // For ErrorResponse
+	"toolkit/core"
+	"toolkit/models"
 )
 
 // notImplementedHandler returns a 501 Not Implemented error.
 func notImplementedHandler(w http.ResponseWriter, r *http.Request) {
 	errMsg := fmt.Sprintf("%s %s - Not Implemented Yet (relative path within API router)", r.Method, r.URL.Path)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
-	// Ensure models.ErrorResponse is used if you have a specific structure
-	json.NewEncoder(w).Encode(models.ErrorResponse{Message: errMsg})
+	WriteProblem(w, r, http.StatusNotImplemented, models.ErrCodeNotImplemented, errMsg)
+}
+
+// writeRequestExport writes a request as a curl command or raw HTTP message (format "curl" or "raw")
+// as text/plain, so it can be copied straight into a terminal or another tool. The redaction rules
+// of targetID are applied first.
+func writeRequestExport(w http.ResponseWriter, r *http.Request, format string, targetID *int64, method, rawURL string, headers http.Header, body []byte) {
+	rawURL, headers, body = core.RedactRequestForExport(targetID, rawURL, headers, body)
+	var out string
+	switch format {
+	case "", "curl":
+		out = core.BuildCurlCommand(method, rawURL, headers, body)
+	case "raw":
+		var err error
+		out, err = core.BuildRawHTTPRequest(method, rawURL, headers, body)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+	default:
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid format, expected 'curl' or 'raw'")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, out)
+}
+
+// cursorPagination reports whether a listing request asks for cursor pagination, with a cursor or
+// pagination=cursor for the first page. Other requests are paged by page number.
+func cursorPagination(r *http.Request) bool {
+	return r.URL.Query().Get("cursor") != "" || r.URL.Query().Get("pagination") == "cursor"
 }
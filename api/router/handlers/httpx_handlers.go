@@ -6,15 +6,17 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"toolkit/config" // Added for accessing proxy port
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -30,26 +32,28 @@ type HttpxResult struct {
 	Title         string   `json:"title"`
 	WebServer     string   `json:"webserver"`
 	Technologies  []string `json:"tech"`
+	Favicon       string   `json:"favicon"` // murmur3 hash of the favicon (-favicon), as a string
+	FaviconURL    string   `json:"favicon_url"`
 	FullJson      string   `json:"-"` // To store the raw JSON line
 }
 
-// HttpxTaskStatus defines the structure for tracking httpx scan progress.
+// HttpxTaskStatus is the /httpx/status view of the latest httpx job of a target.
 type HttpxTaskStatus struct {
+	JobID            int64     `json:"job_id,omitempty"`
 	IsRunning        bool      `json:"is_running"`
 	Message          string    `json:"message"`
 	DomainsTotal     int       `json:"domains_total"`
-	DomainsProcessed int       `json:"domains_processed"`  // Domains attempted by httpx
-	DomainsUpdatedDB int       `json:"domains_updated_db"` // Domains for which DB update was attempted
+	DomainsProcessed int       `json:"domains_processed"`  // Domains httpx is done with
+	DomainsUpdatedDB int       `json:"domains_updated_db"` // Domains updated with an httpx result
+	ErrorCount       int64     `json:"error_count"`
+	RetryCount       int64     `json:"retry_count"`
 	LastError        string    `json:"last_error,omitempty"`
 	StartTime        time.Time `json:"start_time"`
+	DurationMs       *int64    `json:"duration_ms,omitempty"`
 }
 
-var (
-	httpxTaskStatuses        = make(map[int64]*HttpxTaskStatus)
-	httpxTaskStatusLock      = &sync.RWMutex{}
-	httpxScanCancelFuncs     = make(map[int64]context.CancelFunc) // New map for cancel functions
-	httpxScanCancelFuncsLock = &sync.Mutex{}                      // Mutex for the cancel functions map
-)
+// httpxStartLock keeps two scans of the same target from starting at once.
+var httpxStartLock = &sync.Mutex{}
 
 // isBetterResult determines if newResult is "better" than oldResult.
 func isBetterResult(newResult, oldResult HttpxResult) bool {
@@ -92,300 +96,296 @@ func isBetterResult(newResult, oldResult HttpxResult) bool {
 	return false
 }
 
-// RunHttpxScan executes httpx against the provided domains and logs the output.
-func RunHttpxScan(targetID int64, domains []models.Domain) {
+// StartHttpxScan starts an httpx job for the domains of a target. Only one httpx scan runs per
+// target at a time.
+func StartHttpxScan(targetID int64, domains []models.Domain) (models.Job, error) {
 	if len(domains) == 0 {
-		logger.Info("RunHttpxScan: No domains provided for target ID %d. Skipping scan.", targetID)
-		httpxTaskStatusLock.Lock()
-		if status, ok := httpxTaskStatuses[targetID]; ok {
-			status.IsRunning = false
-			status.Message = "No domains provided to scan."
-		} else {
-			httpxTaskStatuses[targetID] = &HttpxTaskStatus{
-				IsRunning:        false,
-				Message:          "No domains provided to scan.",
-				DomainsTotal:     0,
-				DomainsProcessed: 0,
-				DomainsUpdatedDB: 0,
-				StartTime:        time.Now(),
-			}
-		}
-		httpxTaskStatusLock.Unlock()
-		return
+		return models.Job{}, fmt.Errorf("no domains to scan")
 	}
-
-	httpxTaskStatusLock.Lock()
-	httpxTaskStatuses[targetID] = &HttpxTaskStatus{
-		IsRunning:        true,
-		Message:          "Initializing httpx scan...",
-		DomainsTotal:     len(domains),
-		DomainsProcessed: 0,
-		DomainsUpdatedDB: 0,
-		StartTime:        time.Now(),
-	}
-	httpxTaskStatusLock.Unlock()
-
-	// Overall context for the entire scan operation, including cancellation capability
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
-
-	// Store the cancel function
-	httpxScanCancelFuncsLock.Lock()
-	httpxScanCancelFuncs[targetID] = cancel
-	httpxScanCancelFuncsLock.Unlock()
-
-	defer func() {
-		// Ensure context is cancelled when RunHttpxScan exits, regardless of reason
-		cancel()
-
-		// Remove cancel func from the map
-		httpxScanCancelFuncsLock.Lock()
-		delete(httpxScanCancelFuncs, targetID)
-		httpxScanCancelFuncsLock.Unlock()
-
-		// Set final status
-		httpxTaskStatusLock.Lock()
-		defer httpxTaskStatusLock.Unlock()
-
-		if status, ok := httpxTaskStatuses[targetID]; ok {
-			status.IsRunning = false
-			// Check context error first for cancellation
-			if errors.Is(ctx.Err(), context.Canceled) {
-				status.Message = "Httpx scan cancelled by user."
-				if status.LastError == "" {
-					status.LastError = "Operation cancelled."
-				}
-			} else if status.LastError != "" {
-				status.Message = fmt.Sprintf("Httpx scan finished with errors: %s. Processed %d/%d domains. DB updates for %d domains.", status.LastError, status.DomainsProcessed, status.DomainsTotal, status.DomainsUpdatedDB)
-			} else if status.DomainsProcessed < status.DomainsTotal && status.Message != "Httpx scan cancelled by user." {
-				status.Message = fmt.Sprintf("Httpx scan interrupted. Processed %d/%d domains. DB updates for %d domains.", status.DomainsProcessed, status.DomainsTotal, status.DomainsUpdatedDB)
-			} else if status.Message != "Httpx scan cancelled by user." {
-				status.Message = fmt.Sprintf("Httpx scan completed. Processed %d/%d domains. DB updates for %d domains.", status.DomainsProcessed, status.DomainsTotal, status.DomainsUpdatedDB)
-			}
-		}
-	}()
-
-	_, err := exec.LookPath("httpx")
+	httpxStartLock.Lock()
+	defer httpxStartLock.Unlock()
+	latest, err := core.LatestJob(models.JobTypeHttpx, targetID)
 	if err != nil {
-		errMsg := fmt.Sprintf("httpx command not found in PATH: %v", err)
-		logger.Error("RunHttpxScan: %s for target ID %d", errMsg, targetID)
-		httpxTaskStatusLock.Lock()
-		if status, ok := httpxTaskStatuses[targetID]; ok {
-			status.Message = "httpx command not found."
-			status.LastError = errMsg
-		}
-		httpxTaskStatusLock.Unlock()
-		return
+		return models.Job{}, err
 	}
-
-	logger.Info("RunHttpxScan: Starting httpx scan for target ID %d on %d domains.", targetID, len(domains))
-	httpxTaskStatusLock.Lock()
-	if status, ok := httpxTaskStatuses[targetID]; ok {
-		status.Message = fmt.Sprintf("Starting httpx scan for %d domains...", len(domains))
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("an httpx scan is already running for target %d (job %d)", targetID, latest.ID)
 	}
-	httpxTaskStatusLock.Unlock()
+	return core.StartJob(models.JobTypeHttpx, &targetID, fmt.Sprintf("Queued httpx scan of %d domains...", len(domains)), func(ctx context.Context, job *core.JobHandle) error {
+		err := runHttpxScan(ctx, job, targetID, domains)
+		final := job.Snapshot()
+		summary := fmt.Sprintf("Processed %d/%d domains, %d updated, %d errors.", final.ItemsProcessed, final.ItemsTotal, final.ItemsSucceeded, final.ErrorCount)
+		core.NotifyTargetEvent(models.NotificationScanFinished, targetID, "Httpx scan finished", summary, nil)
+		return err
+	})
+}
 
-	proxyPort := config.AppConfig.Proxy.Port
-	proxyURL := ""
-	if proxyPort != "" {
-		proxyURL = fmt.Sprintf("http://127.0.0.1:%s", proxyPort)
+// httpxArgs builds the httpx command line from the httpx, rate_limit and proxy config sections and
+// the target's client profile, whose headers are passed with -H. A profile user agent replaces
+// -random-agent.
+func httpxArgs(targetID int64) []string {
+	conf := config.AppConfig.Httpx
+	threads := conf.Threads
+	if threads <= 0 {
+		threads = 25
 	}
-
-	baseArgs := []string{
+	args := []string{
 		"-json", "-status-code", "-content-length", "-title", "-tech-detect", "-server",
 		"-cdn", "-websocket", "-vhost", "-pipeline", "-http2", "-follow-redirects",
-		"-silent", "-no-color", "-timeout", "10", "-threads", "25", "-retries", "1",
-		"-random-agent",
+		"-silent", "-no-color", "-timeout", "10", "-retries", "1",
+		"-favicon",
 	}
-	if proxyURL != "" {
-		baseArgs = append(baseArgs, "-proxy", proxyURL)
+	profileHeaders := core.ClientProfileHeaders(targetID)
+	if profileHeaders.Get("User-Agent") == "" {
+		args = append(args, "-random-agent")
+	}
+	names := make([]string, 0, len(profileHeaders))
+	for name := range profileHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-H", name+": "+profileHeaders.Get(name))
+	}
+	if rlConf := config.AppConfig.RateLimit; rlConf.Enabled {
+		if rlConf.MaxConcurrency > 0 && rlConf.MaxConcurrency < threads {
+			threads = rlConf.MaxConcurrency
+		}
+		rate := int(rlConf.RequestsPerSecond)
+		if rate < 1 {
+			rate = 1
+		}
+		args = append(args, "-rate-limit", strconv.Itoa(rate))
+		logger.Info("RunHttpxScan: Rate limiting enabled, using -rate-limit %d and -threads %d.", rate, threads)
+	}
+	args = append(args, "-threads", strconv.Itoa(threads))
+	if proxyPort := config.AppConfig.Proxy.Port; proxyPort != "" {
+		proxyURL := fmt.Sprintf("http://127.0.0.1:%s", proxyPort)
+		args = append(args, "-proxy", proxyURL)
 		logger.Info("RunHttpxScan: Using proxy %s for httpx scan.", proxyURL)
 	}
+	return args
+}
 
-	const batchSize = 50
-	numDomains := len(domains)
-	var cumulativeDomainsUpdatedDB int64 = 0
+// runHttpxScan runs httpx over the domains in chunks of httpx.chunk_size. Results are stored as
+// httpx streams them; a chunk whose process fails is retried for the domains still without a result.
+func runHttpxScan(ctx context.Context, job *core.JobHandle, targetID int64, domains []models.Domain) error {
+	httpxPath, err := core.ResolveTool("httpx")
+	if err != nil {
+		return err
+	}
+	conf := config.AppConfig.Httpx
+	timeout := time.Duration(conf.TimeoutMinutes) * time.Minute
+	if timeout <= 0 {
+		timeout = 60 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	for i := 0; i < numDomains; i += batchSize {
-		// Check for context cancellation before starting a new batch
-		select {
-		case <-ctx.Done():
-			logger.Info("RunHttpxScan: Context cancelled before processing batch %d for target ID %d. Error: %v", (i/batchSize)+1, targetID, ctx.Err())
-			// The defer function will handle setting the final status message.
-			return // Exit the scan early
-		default:
-			// Continue processing
+	chunkSize := conf.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 200
+	}
+	args := httpxArgs(targetID)
+	numChunks := (len(domains) + chunkSize - 1) / chunkSize
+	job.SetTotal(int64(len(domains)))
+	logger.Info("RunHttpxScan: Starting httpx job %d for target ID %d on %d domains in %d chunks.", job.ID(), targetID, len(domains), numChunks)
+
+	best := make(map[int64]HttpxResult) // Best result stored so far, by domain ID
+	var lastErr error
+	for i := 0; i < len(domains); i += chunkSize {
+		chunk := domains[i:min(i+chunkSize, len(domains))]
+		chunkNum := i/chunkSize + 1
+		job.SetMessage("Scanning chunk %d of %d (%d domains)...", chunkNum, numChunks, len(chunk))
+
+		pending := chunk
+		var chunkErr error
+		for attempt := 0; ; attempt++ {
+			chunkErr = runHttpxChunk(ctx, job, httpxPath, args, pending, best)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if chunkErr == nil {
+				break
+			}
+			job.RecordError(chunkErr)
+			lastErr = chunkErr
+			logger.Error("RunHttpxScan: Chunk %d of job %d (attempt %d): %v", chunkNum, job.ID(), attempt+1, chunkErr)
+			pending = httpxDomainsWithoutResult(pending, best)
+			if len(pending) == 0 || attempt >= conf.MaxRetries {
+				break
+			}
+			job.RecordRetry()
+			backoff := time.Duration(conf.RetryBackoffMs) * time.Millisecond << attempt
+			job.SetMessage("Chunk %d of %d failed, retrying %d domains in %s...", chunkNum, numChunks, len(pending), backoff)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
 		}
 
-		end := i + batchSize
-		if end > numDomains {
-			end = numDomains
+		// Domains httpx answered for are already counted; the rest are done with now.
+		var unanswered int64
+		for _, d := range httpxDomainsWithoutResult(chunk, best) {
+			unanswered++
+			if chunkErr != nil {
+				continue // Leave domains alone when httpx never completed for them
+			}
+			noResult := models.Domain{ID: d.ID, HttpxFullJson: models.NullString(`{"status_code": null, "message": "No HTTP response on probed ports"}`)}
+			if err := database.UpdateDomainWithHttpxResult(noResult); err != nil {
+				job.RecordError(fmt.Errorf("marking domain %s as scanned: %w", d.DomainName, err))
+			}
 		}
-		currentBatchDomains := domains[i:end]
-		batchDomainsProcessedThisIteration := 0
+		job.AddProgress(unanswered, 0)
+	}
 
-		var batchDomainListBuilder strings.Builder
-		for _, d := range currentBatchDomains {
-			batchDomainListBuilder.WriteString(d.DomainName + "\n")
-		}
-		batchDomainInput := batchDomainListBuilder.String()
+	final := job.Snapshot()
+	job.SetMessage("Httpx scan completed. Processed %d/%d domains. DB updates for %d domains.", final.ItemsProcessed, final.ItemsTotal, final.ItemsSucceeded)
+	if final.ItemsSucceeded == 0 && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
 
-		httpxTaskStatusLock.Lock()
-		if status, ok := httpxTaskStatuses[targetID]; ok {
-			status.Message = fmt.Sprintf("Processing batch %d of %d (%d domains)...", (i/batchSize)+1, (numDomains+batchSize-1)/batchSize, len(currentBatchDomains))
+// httpxDomainsWithoutResult returns the domains that have no stored httpx result yet.
+func httpxDomainsWithoutResult(domains []models.Domain, best map[int64]HttpxResult) []models.Domain {
+	var without []models.Domain
+	for _, d := range domains {
+		if _, ok := best[d.ID]; !ok {
+			without = append(without, d)
 		}
-		httpxTaskStatusLock.Unlock()
-
-		var batchOutBuffer, batchErrBuffer bytes.Buffer
-		batchCmd := exec.CommandContext(ctx, "httpx", baseArgs...)
-		batchCmd.Stdin = strings.NewReader(batchDomainInput)
-		batchCmd.Stdout = &batchOutBuffer
-		batchCmd.Stderr = &batchErrBuffer
+	}
+	return without
+}
 
-		batchCmdErr := batchCmd.Run()
-		batchDomainsProcessedThisIteration = len(currentBatchDomains)
+// runHttpxChunk runs one httpx process over the domains and stores each JSONL result as it is
+// printed, keeping the best result per domain (see isBetterResult).
+func runHttpxChunk(ctx context.Context, job *core.JobHandle, httpxPath string, args []string, domains []models.Domain, best map[int64]HttpxResult) error {
+	byName := make(map[string]models.Domain, len(domains))
+	var input strings.Builder
+	for _, d := range domains {
+		byName[d.DomainName] = d
+		input.WriteString(d.DomainName + "\n")
+	}
 
-		batchResultsMap := make(map[string][]HttpxResult)
+	var stderr bytes.Buffer
+	stdout, stdoutWriter := io.Pipe()
+	cmd := exec.CommandContext(ctx, httpxPath, args...)
+	cmd.Stdin = strings.NewReader(input.String())
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = 2 * time.Second // Stop waiting for output once httpx is killed on cancellation
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting httpx: %w", err)
+	}
+	waitErrCh := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		stdoutWriter.Close()
+		waitErrCh <- err
+	}()
 
-		if batchErrBuffer.Len() > 0 {
-			stderrContent := batchErrBuffer.String()
-			logger.Warn("RunHttpxScan: httpx stderr for batch %d, target ID %d: %s", (i/batchSize)+1, targetID, stderrContent)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var result HttpxResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			logger.Error("RunHttpxScan: Error unmarshalling httpx JSON line: %v. Line: %s", err, line)
+			continue
 		}
-
-		if batchCmdErr != nil {
-			// Check if the error is due to context cancellation
-			if errors.Is(batchCmdErr, context.Canceled) || errors.Is(batchCmdErr, context.DeadlineExceeded) {
-				logger.Info("RunHttpxScan: httpx command for batch %d cancelled or timed out for target ID %d. Error: %v", (i/batchSize)+1, targetID, batchCmdErr)
-				// The main context check at the loop start or the defer will handle the overall status.
-				// We still update processed count for this batch as an attempt was made.
-			} else {
-				batchErrMsg := fmt.Sprintf("httpx execution error for batch %d: %v", (i/batchSize)+1, batchCmdErr)
-				logger.Error("RunHttpxScan: %s for target ID %d.", batchErrMsg, targetID)
-				httpxTaskStatusLock.Lock()
-				if status, ok := httpxTaskStatuses[targetID]; ok {
-					if status.LastError == "" {
-						status.LastError = batchErrMsg
-					}
-				}
-				httpxTaskStatusLock.Unlock()
-			}
-		} else {
-			batchScanner := bufio.NewScanner(&batchOutBuffer)
-			for batchScanner.Scan() {
-				line := batchScanner.Text()
-				var result HttpxResult
-				if err := json.Unmarshal([]byte(line), &result); err != nil {
-					logger.Error("RunHttpxScan: Error unmarshalling httpx JSON line for batch, target ID %d: %v. Line: %s", targetID, err, line)
-					continue
-				}
-				result.FullJson = line
-				batchResultsMap[result.Input] = append(batchResultsMap[result.Input], result)
-			}
-			if scanErr := batchScanner.Err(); scanErr != nil {
-				scanErrMsg := fmt.Sprintf("Error reading httpx output for batch %d: %v", (i/batchSize)+1, scanErr)
-				logger.Error("RunHttpxScan: %s for target ID %d", scanErrMsg, targetID)
-				httpxTaskStatusLock.Lock()
-				if status, ok := httpxTaskStatuses[targetID]; ok {
-					if status.LastError == "" {
-						status.LastError = scanErrMsg
-					}
-				}
-				httpxTaskStatusLock.Unlock()
-			}
+		result.FullJson = line
+		d, ok := byName[result.Input]
+		if !ok {
+			continue
 		}
-
-		var batchDomainsUpdatedDB int64 = 0
-		for _, domainInBatch := range currentBatchDomains {
-			domainID := domainInBatch.ID
-			inputDomainName := domainInBatch.DomainName
-			var domainUpdateData models.Domain
-
-			if resultsForThisDomain, found := batchResultsMap[inputDomainName]; found && len(resultsForThisDomain) > 0 {
-				bestResult := resultsForThisDomain[0]
-				for k := 1; k < len(resultsForThisDomain); k++ {
-					if isBetterResult(resultsForThisDomain[k], bestResult) {
-						bestResult = resultsForThisDomain[k]
-					}
-				}
-				domainUpdateData = models.Domain{
-					ID:                domainID,
-					HTTPStatusCode:    sql.NullInt64{Int64: int64(bestResult.StatusCode), Valid: bestResult.StatusCode != 0},
-					HTTPContentLength: sql.NullInt64{Int64: bestResult.ContentLength, Valid: true},
-					HTTPTitle:         models.NullString(bestResult.Title),
-					HTTPServer:        models.NullString(bestResult.WebServer),
-					HTTPTech:          models.NullString(strings.Join(bestResult.Technologies, ",")),
-					HttpxFullJson:     models.NullString(bestResult.FullJson),
-				}
-				logger.Info("RunHttpxScan: Updating domain ID %d (input: %s) with httpx result.", domainID, inputDomainName)
-			} else {
-				if batchCmdErr == nil {
-					logger.Info("RunHttpxScan: Domain '%s' (ID: %d) in batch yielded no direct results from httpx. Marking as scanned.", inputDomainName, domainID)
-					noResultJson := `{"status_code": null, "message": "No HTTP response on probed ports"}`
-					domainUpdateData = models.Domain{
-						ID:            domainID,
-						HttpxFullJson: models.NullString(noResultJson),
-					}
-				} else {
-					logger.Info("RunHttpxScan: Skipping DB update for domain '%s' (ID: %d) due to batch httpx error.", inputDomainName, domainID)
-					continue
-				}
-			}
-
-			errDbUpdate := database.UpdateDomainWithHttpxResult(domainUpdateData)
-			if errDbUpdate != nil {
-				dbErrMsg := fmt.Sprintf("Failed to update domain ID %d (input: %s) with httpx result: %v", domainID, inputDomainName, errDbUpdate)
-				logger.Error("RunHttpxScan: %s", dbErrMsg)
-				httpxTaskStatusLock.Lock()
-				if status, ok := httpxTaskStatuses[targetID]; ok {
-					if status.LastError == "" {
-						status.LastError = dbErrMsg
-					}
-				}
-				httpxTaskStatusLock.Unlock()
-			} else {
-				batchDomainsUpdatedDB++
-			}
+		previous, seen := best[d.ID]
+		if seen && !isBetterResult(result, previous) {
+			continue
 		}
-		cumulativeDomainsUpdatedDB += batchDomainsUpdatedDB
-
-		httpxTaskStatusLock.Lock()
-		if status, ok := httpxTaskStatuses[targetID]; ok {
-			status.DomainsProcessed += batchDomainsProcessedThisIteration
-			status.DomainsUpdatedDB = int(cumulativeDomainsUpdatedDB)
-			status.Message = fmt.Sprintf("Batch %d of %d finished. Processed %d/%d domains. DB updates for %d domains.",
-				(i/batchSize)+1, (numDomains+batchSize-1)/batchSize,
-				status.DomainsProcessed, status.DomainsTotal, status.DomainsUpdatedDB)
+		update := models.Domain{
+			ID:                d.ID,
+			HTTPStatusCode:    sql.NullInt64{Int64: int64(result.StatusCode), Valid: result.StatusCode != 0},
+			HTTPContentLength: sql.NullInt64{Int64: result.ContentLength, Valid: true},
+			HTTPTitle:         models.NullString(result.Title),
+			HTTPServer:        models.NullString(result.WebServer),
+			HTTPTech:          models.NullString(strings.Join(result.Technologies, ",")),
+			HttpxFullJson:     models.NullString(result.FullJson),
 		}
-		httpxTaskStatusLock.Unlock()
-
-		logMessageForBatch := fmt.Sprintf("Batch %d of %d finished for target ID %d. Domains attempted in batch: %d. DB updates for %d domains in batch. Cumulative DB updates: %d.",
-			(i/batchSize)+1, (numDomains+batchSize-1)/batchSize, targetID,
-			batchDomainsProcessedThisIteration, batchDomainsUpdatedDB, cumulativeDomainsUpdatedDB)
-		logger.Info("RunHttpxScan: %s", logMessageForBatch)
+		if hash, err := strconv.ParseInt(result.Favicon, 10, 32); err == nil {
+			update.FaviconHash = sql.NullInt64{Int64: hash, Valid: true}
+			update.FaviconURL = models.NullString(result.FaviconURL)
+		}
+		if err := database.UpdateDomainWithHttpxResult(update); err != nil {
+			job.RecordError(fmt.Errorf("updating domain %s with httpx result: %w", d.DomainName, err))
+			continue
+		}
+		best[d.ID] = result
+		if !seen {
+			job.AddProgress(1, 1)
+		}
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		io.Copy(io.Discard, stdout) // Let httpx finish writing so Wait returns
+	}
 
-	} // End of batch loop
+	waitErr := <-waitErrCh
+	if stderr.Len() > 0 {
+		logger.Warn("RunHttpxScan: httpx stderr: %s", stderr.String())
+	}
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case waitErr != nil:
+		msg := strings.TrimSpace(stderr.String())
+		return fmt.Errorf("httpx exited with error: %v %s", waitErr, msg[:min(len(msg), 300)])
+	case scanErr != nil:
+		return fmt.Errorf("reading httpx output: %w", scanErr)
+	}
+	return nil
+}
 
-	logger.Info("RunHttpxScan: Finished all httpx batches for target ID %d. Total domains attempted: %d. Total DB updates attempted: %d.", targetID, numDomains, cumulativeDomainsUpdatedDB)
+// httpxStatusFromJob converts an httpx job to the /httpx/status response.
+func httpxStatusFromJob(job models.Job) HttpxTaskStatus {
+	return HttpxTaskStatus{
+		JobID:            job.ID,
+		IsRunning:        job.Status == models.JobStatusRunning,
+		Message:          job.Message,
+		DomainsTotal:     int(job.ItemsTotal),
+		DomainsProcessed: int(job.ItemsProcessed),
+		DomainsUpdatedDB: int(job.ItemsSucceeded),
+		ErrorCount:       job.ErrorCount,
+		RetryCount:       job.RetryCount,
+		LastError:        job.LastError,
+		StartTime:        job.StartedAt,
+		DurationMs:       job.DurationMs,
+	}
 }
 
-// GetHttpxStatusHandler returns the current status of httpx scans for a target.
+// GetHttpxStatusHandler returns the status of the latest httpx scan of a target.
 func GetHttpxStatusHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
 		return
 	}
 
-	httpxTaskStatusLock.RLock()
-	status, ok := httpxTaskStatuses[targetID]
-	httpxTaskStatusLock.RUnlock()
-
-	if !ok {
-		status = &HttpxTaskStatus{
-			IsRunning: false,
-			Message:   "No active httpx scan for this target or status not initialized.",
-			StartTime: time.Now(),
-		}
+	latest, err := core.LatestJob(models.JobTypeHttpx, targetID)
+	if err != nil {
+		logger.Error("GetHttpxStatusHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to get httpx status")
+		return
+	}
+	status := HttpxTaskStatus{
+		IsRunning: false,
+		Message:   "No active httpx scan for this target or status not initialized.",
+		StartTime: time.Now(),
+	}
+	if latest != nil {
+		status = httpxStatusFromJob(*latest)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -397,36 +397,34 @@ func StopHttpxScanHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
 		return
 	}
 
-	httpxScanCancelFuncsLock.Lock()
-	cancelFunc, ok := httpxScanCancelFuncs[targetID]
-	// It's important to remove from the map *while holding the lock*
-	// to prevent a race condition if Stop is called multiple times quickly
-	// or if the scan finishes concurrently.
-	if ok {
-		delete(httpxScanCancelFuncs, targetID)
-	}
-	httpxScanCancelFuncsLock.Unlock()
-
-	if ok && cancelFunc != nil {
-		cancelFunc() // Call the context cancel function
-		logger.Info("StopHttpxScanHandler: Cancellation requested for httpx scan on target ID %d.", targetID)
+	latest, err := core.LatestJob(models.JobTypeHttpx, targetID)
+	if err == nil && latest != nil && latest.Status == models.JobStatusRunning {
+		err = core.CancelJob(latest.ID)
+	}
+	if err != nil || latest == nil || latest.Status != models.JobStatusRunning {
+		logger.Warn("StopHttpxScanHandler: No active cancellable httpx scan found for target ID %d (%v).", targetID, err)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "No active scan found to stop for this target.")
+		return
+	}
+	logger.Info("StopHttpxScanHandler: Cancellation requested for httpx job %d on target ID %d.", latest.ID, targetID)
 
-		// Update status immediately to reflect cancellation attempt
-		httpxTaskStatusLock.Lock()
-		if status, statOk := httpxTaskStatuses[targetID]; statOk {
-			status.Message = "Httpx scan cancellation requested by user..."
-			// IsRunning will be set to false by the RunHttpxScan's defer function when it exits.
-		}
-		httpxTaskStatusLock.Unlock()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Httpx scan cancellation initiated.", "job_id": latest.ID})
+}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Httpx scan cancellation initiated."})
-	} else {
-		logger.Warn("StopHttpxScanHandler: No active cancellable httpx scan found for target ID %d.", targetID)
-		http.Error(w, "No active scan found to stop for this target.", http.StatusNotFound)
+// writeHttpxStartError maps StartHttpxScan errors to HTTP status codes.
+func writeHttpxStartError(w http.ResponseWriter, r *http.Request, handler string, targetID int64, err error) {
+	switch {
+	case strings.Contains(err.Error(), "already running"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
+	case strings.Contains(err.Error(), "no domains"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+	default:
+		logger.Error("%s: Error starting httpx scan for target %d: %v", handler, targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start httpx scan")
 	}
 }
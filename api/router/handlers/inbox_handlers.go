@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	inboxMaxWait       = 120 * time.Second
+	inboxDefaultSince  = 10 * time.Minute
+	inboxMaxWebhookLen = 10 << 20
+)
+
+// writeInboxError maps inbox errors to HTTP status codes.
+func writeInboxError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "disabled"):
+		WriteProblem(w, r, http.StatusForbidden, models.ErrCodeForbidden, msg)
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "invalid"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "IMAP"):
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
+	}
+}
+
+// GetInboxStatusHandler returns the inbox configuration and the counters of the IMAP poller.
+func GetInboxStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetInboxStatus())
+}
+
+// PollInboxHandler reads the new messages of the IMAP mailbox now.
+func PollInboxHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := core.PollInboxNow(r.Context())
+	if err != nil {
+		writeInboxError(w, r, "PollInboxHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// InboxWebhookHandler receives an email from a webhook catcher or inbound mail service. It accepts
+// JSON ({"from", "to", "subject", "text", "html"} or {"raw": "<RFC 822 message>"}), form posts
+// with the fields of common inbound parse services (from/sender, to/recipient, subject,
+// text/body-plain, html/body-html, email/body-mime) and raw message/rfc822 bodies. When
+// inbox.webhook_token is set, it must be sent as ?token= or X-Inbox-Token.
+func InboxWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if expected := config.AppConfig.Inbox.WebhookToken; expected != "" {
+		token := r.Header.Get("X-Inbox-Token")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			WriteProblem(w, r, http.StatusUnauthorized, models.ErrCodeUnauthorized, "Invalid or missing inbox webhook token")
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, inboxMaxWebhookLen)
+
+	var msg models.InboxWebhookMessage
+	contentType := strings.ToLower(r.Header.Get("Content-Type"))
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+	case strings.HasPrefix(contentType, "multipart/form-data"), strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if err := r.ParseMultipartForm(inboxMaxWebhookLen); err != nil && err != http.ErrNotMultipart {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid form payload: "+err.Error())
+			return
+		}
+		field := func(names ...string) string {
+			for _, name := range names {
+				if v := r.FormValue(name); v != "" {
+					return v
+				}
+			}
+			return ""
+		}
+		msg = models.InboxWebhookMessage{
+			From:      field("from", "sender"),
+			To:        field("to", "recipient"),
+			Subject:   field("subject"),
+			Text:      field("text", "body-plain", "plain"),
+			HTML:      field("html", "body-html"),
+			MessageID: field("message_id", "Message-Id"),
+			Raw:       field("email", "body-mime", "raw"),
+		}
+	default:
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+		msg.Raw = string(raw)
+	}
+
+	message, created, err := core.ReceiveInboxWebhook(msg)
+	if err != nil {
+		writeInboxError(w, r, "InboxWebhookHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(message)
+}
+
+// GetInboxMessagesHandler lists inbox messages without their bodies, newest first. ?target_id=
+// keeps the messages of one target; ?limit= defaults to 50.
+func GetInboxMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	var targetID int64
+	if v := r.URL.Query().Get("target_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
+			return
+		}
+		targetID = id
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+	messages, err := database.GetInboxMessages(targetID, limit)
+	if err != nil {
+		writeInboxError(w, r, "GetInboxMessagesHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// GetInboxMessageHandler returns an inbox message with its bodies.
+func GetInboxMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "message_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid message ID")
+		return
+	}
+	message, err := database.GetInboxMessage(id)
+	if err != nil {
+		writeInboxError(w, r, "GetInboxMessageHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// DeleteInboxMessageHandler deletes an inbox message.
+func DeleteInboxMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "message_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid message ID")
+		return
+	}
+	if err := database.DeleteInboxMessage(id); err != nil {
+		writeInboxError(w, r, "DeleteInboxMessageHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLatestInboxCodeHandler returns the newest message of a target carrying a verification code or
+// link. ?since= (RFC 3339 time or a duration like 5m, default 10m) bounds its age; ?wait= (seconds,
+// max 120) waits for one to arrive, polling the IMAP mailbox meanwhile.
+func GetLatestInboxCodeHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	since := time.Now().Add(-inboxDefaultSince)
+	if v := r.URL.Query().Get("since"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		} else if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			since = time.Now().Add(-d)
+		} else {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid since: expected an RFC 3339 time or a duration")
+			return
+		}
+	}
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid wait")
+			return
+		}
+		wait = time.Duration(n) * time.Second
+		if wait > inboxMaxWait {
+			wait = inboxMaxWait
+		}
+	}
+	message, err := core.WaitForInboxCode(r.Context(), targetID, since, wait)
+	if err != nil {
+		writeInboxError(w, r, "GetLatestInboxCodeHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterInboxRoutes sets up the routes of the test inbox: the IMAP poller, the webhook receiving
+// emails and the messages with their verification codes and links.
+func RegisterInboxRoutes(r chi.Router) {
+	r.Get("/inbox", GetInboxStatusHandler)
+	r.Post("/inbox/poll", PollInboxHandler)
+	r.Post("/inbox/webhook", InboxWebhookHandler)
+	r.Get("/inbox/messages", GetInboxMessagesHandler)
+	r.Get("/inbox/messages/{message_id}", GetInboxMessageHandler)
+	r.Delete("/inbox/messages/{message_id}", DeleteInboxMessageHandler)
+	r.Get("/targets/{target_id}/inbox/latest", GetLatestInboxCodeHandler)
+}
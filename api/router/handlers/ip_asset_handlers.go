@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeIPAssetError maps IP asset errors to HTTP status codes.
+func writeIPAssetError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "invalid"), strings.HasPrefix(msg, "no "):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "already"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	case strings.Contains(msg, "enriching"):
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
+	}
+}
+
+func ipAssetTargetID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return 0, false
+	}
+	return targetID, true
+}
+
+func ipAssetID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "ipAssetID"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid IP asset ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// ListIPAssetsHandler lists the IP assets of a target.
+// Query parameters: in_scope (true/false), source, asn, search, limit (default 100), offset.
+func ListIPAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := ipAssetTargetID(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	filters := models.IPAssetFilters{TargetID: targetID, Source: q.Get("source"), Search: q.Get("search")}
+	if v := q.Get("in_scope"); v != "" {
+		inScope, err := strconv.ParseBool(v)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid in_scope")
+			return
+		}
+		filters.IsInScope = &inScope
+	}
+	if v := strings.TrimPrefix(strings.ToUpper(q.Get("asn")), "AS"); v != "" {
+		asn, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid asn")
+			return
+		}
+		filters.ASN = asn
+	}
+	for name, dst := range map[string]*int{"limit": &filters.Limit, "offset": &filters.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid "+name)
+				return
+			}
+			*dst = n
+		}
+	}
+	if filters.Limit <= 0 {
+		filters.Limit = 100
+	}
+
+	assets, total, err := database.ListIPAssets(filters)
+	if err != nil {
+		writeIPAssetError(w, r, "ListIPAssetsHandler", err)
+		return
+	}
+	if assets == nil {
+		assets = []models.IPAsset{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.IPAssetListResponse{Total: total, Limit: filters.Limit, Offset: filters.Offset, Records: assets})
+}
+
+// AddIPAssetHandler adds an IP address or CIDR block to a target's inventory.
+func AddIPAssetHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := ipAssetTargetID(w, r)
+	if !ok {
+		return
+	}
+	var req models.IPAssetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Address) == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "address is required")
+		return
+	}
+	asset, err := core.AddIPAsset(r.Context(), targetID, req)
+	if err != nil {
+		writeIPAssetError(w, r, "AddIPAssetHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(asset)
+}
+
+// ResolveIPAssetsHandler starts a job resolving the target's domains into IP assets.
+// Query parameters: enrich (default ip_assets.enrich), in_scope_only (default false).
+func ResolveIPAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := ipAssetTargetID(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	opts := core.IPAssetResolutionOptions{Enrich: config.AppConfig.IPAssets.Enrich}
+	for name, dst := range map[string]*bool{"enrich": &opts.Enrich, "in_scope_only": &opts.InScopeOnly} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid "+name)
+				return
+			}
+			*dst = b
+		}
+	}
+	job, err := core.StartIPAssetResolution(targetID, opts)
+	if err != nil {
+		writeIPAssetError(w, r, "ResolveIPAssetsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// DiscoverVhostsHandler starts a job sending candidate Host headers to the target's in-scope IP
+// assets (or the ip_asset_ids of the body) to find virtual hosts. The body is optional.
+func DiscoverVhostsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := ipAssetTargetID(w, r)
+	if !ok {
+		return
+	}
+	var req models.VhostDiscoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartVhostDiscovery(targetID, req)
+	if err != nil {
+		writeIPAssetError(w, r, "DiscoverVhostsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// RescopeIPAssetsHandler recomputes which of the target's IP assets are in scope.
+func RescopeIPAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := ipAssetTargetID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		writeIPAssetError(w, r, "RescopeIPAssetsHandler", err)
+		return
+	}
+	result, err := core.RescopeIPAssets(targetID)
+	if err != nil {
+		writeIPAssetError(w, r, "RescopeIPAssetsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetIPAssetHandler returns one IP asset with the domains resolving to it.
+func GetIPAssetHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := ipAssetID(w, r)
+	if !ok {
+		return
+	}
+	asset, err := database.GetIPAssetByID(id)
+	if err != nil {
+		writeIPAssetError(w, r, "GetIPAssetHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(asset)
+}
+
+// DeleteIPAssetHandler removes an IP asset.
+func DeleteIPAssetHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := ipAssetID(w, r)
+	if !ok {
+		return
+	}
+	if err := database.DeleteIPAsset(id); err != nil {
+		writeIPAssetError(w, r, "DeleteIPAssetHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnrichIPAssetHandler runs the reverse DNS and ASN lookups of one IP asset now.
+func EnrichIPAssetHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := ipAssetID(w, r)
+	if !ok {
+		return
+	}
+	asset, err := core.EnrichIPAssetByID(r.Context(), id)
+	if err != nil {
+		writeIPAssetError(w, r, "EnrichIPAssetHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(asset)
+}
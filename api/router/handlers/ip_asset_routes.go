@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterIPAssetRoutes sets up the routes for the IP/CIDR asset inventory.
+func RegisterIPAssetRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/ip-assets", ListIPAssetsHandler)
+	r.Post("/targets/{target_id}/ip-assets", AddIPAssetHandler)
+	r.Post("/targets/{target_id}/ip-assets/resolve", ResolveIPAssetsHandler)
+	r.Post("/targets/{target_id}/ip-assets/rescope", RescopeIPAssetsHandler)
+	r.Post("/targets/{target_id}/ip-assets/vhost-discovery", DiscoverVhostsHandler)
+
+	r.Get("/ip-assets/{ipAssetID}", GetIPAssetHandler)
+	r.Delete("/ip-assets/{ipAssetID}", DeleteIPAssetHandler)
+	r.Post("/ip-assets/{ipAssetID}/enrich", EnrichIPAssetHandler)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeJobError maps job errors to HTTP status codes.
+func writeJobError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "not running"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
+	}
+}
+
+// ListJobsHandler lists background jobs, newest first.
+// Query parameters: type, target_id, status, limit (default 50).
+func ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.JobFilters{Type: q.Get("type"), Status: q.Get("status")}
+	if v := q.Get("target_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
+			return
+		}
+		filters.TargetID = id
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid limit")
+			return
+		}
+		filters.Limit = limit
+	}
+	jobs, err := core.ListJobs(filters)
+	if err != nil {
+		writeJobError(w, r, "ListJobsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetJobHandler returns one job with its live progress.
+func GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid job ID")
+		return
+	}
+	job, err := core.GetJob(id)
+	if err != nil {
+		writeJobError(w, r, "GetJobHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJobHandler asks a running job to stop.
+func CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid job ID")
+		return
+	}
+	if err := core.CancelJob(id); err != nil {
+		writeJobError(w, r, "CancelJobHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Job cancellation initiated.", "job_id": id})
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterJobRoutes(r chi.Router) {
+	r.Get("/jobs", ListJobsHandler)
+	r.Get("/jobs/{jobID}", GetJobHandler)
+	r.Post("/jobs/{jobID}/cancel", CancelJobHandler)
+}
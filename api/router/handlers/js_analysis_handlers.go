@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetJSFilesHandler lists JS files tracked by the analysis pipeline.
+// Query parameters: target_id.
+func GetJSFilesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	files, err := database.GetJSFiles(targetID)
+	if err != nil {
+		logger.Error("GetJSFilesHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve JS files")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+// GetJSFileVersionsHandler lists the captured versions of a JS file, newest first.
+func GetJSFileVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "file_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid JS file ID")
+		return
+	}
+	if _, err := database.GetJSFileByID(fileID); err != nil {
+		writeJSFileLookupError(w, r, "GetJSFileVersionsHandler", err)
+		return
+	}
+	versions, err := database.GetJSFileVersions(fileID)
+	if err != nil {
+		logger.Error("GetJSFileVersionsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve JS file versions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// GetJSFileDiffHandler returns the endpoints added and removed between two versions of a JS file.
+// Query parameters: from, to (version IDs). Without them the two latest versions are compared.
+func GetJSFileDiffHandler(w http.ResponseWriter, r *http.Request) {
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "file_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid JS file ID")
+		return
+	}
+	if _, err := database.GetJSFileByID(fileID); err != nil {
+		writeJSFileLookupError(w, r, "GetJSFileDiffHandler", err)
+		return
+	}
+	versions, err := database.GetJSFileVersions(fileID)
+	if err != nil {
+		logger.Error("GetJSFileDiffHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve JS file versions")
+		return
+	}
+
+	q := r.URL.Query()
+	fromID, _ := strconv.ParseInt(q.Get("from"), 10, 64)
+	toID, _ := strconv.ParseInt(q.Get("to"), 10, 64)
+	if fromID == 0 && toID == 0 {
+		if len(versions) < 2 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "JS file has fewer than two versions to compare")
+			return
+		}
+		fromID, toID = versions[1].ID, versions[0].ID
+	}
+	belongs := func(id int64) bool {
+		for _, v := range versions {
+			if v.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !belongs(fromID) || !belongs(toID) {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Both 'from' and 'to' must be versions of this JS file")
+		return
+	}
+
+	diff, err := database.DiffJSFileVersions(fileID, fromID, toID)
+	if err != nil {
+		logger.Error("GetJSFileDiffHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to diff JS file versions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// GetJSEndpointsHandler lists endpoints, paths and DOM sinks extracted from JS files.
+// Query parameters: target_id, file_id, version_id, kind, search, all_versions, page, limit.
+// By default only the latest version of each file is included.
+func GetJSEndpointsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.JSEndpointFilters{
+		Kind:   q.Get("kind"),
+		Search: q.Get("search"),
+	}
+	filters.TargetID, _ = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	filters.JSFileID, _ = strconv.ParseInt(q.Get("file_id"), 10, 64)
+	filters.VersionID, _ = strconv.ParseInt(q.Get("version_id"), 10, 64)
+	filters.AllVersions, _ = strconv.ParseBool(q.Get("all_versions"))
+	filters.Page, _ = strconv.Atoi(q.Get("page"))
+	filters.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.Limit <= 0 || filters.Limit > 1000 {
+		filters.Limit = 100
+	}
+
+	endpoints, totalRecords, err := database.GetJSEndpoints(filters)
+	if err != nil {
+		logger.Error("GetJSEndpointsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve JS endpoints")
+		return
+	}
+	totalPages := (totalRecords + int64(filters.Limit) - 1) / int64(filters.Limit)
+
+	response := struct {
+		Page         int                 `json:"page"`
+		Limit        int                 `json:"limit"`
+		TotalRecords int64               `json:"total_records"`
+		TotalPages   int64               `json:"total_pages"`
+		Endpoints    []models.JSEndpoint `json:"endpoints"`
+	}{
+		Page:         filters.Page,
+		Limit:        filters.Limit,
+		TotalRecords: totalRecords,
+		TotalPages:   totalPages,
+		Endpoints:    endpoints,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeJSFileLookupError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		return
+	}
+	logger.Error("%s: %v", handler, err)
+	WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve JS file")
+}
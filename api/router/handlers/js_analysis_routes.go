@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterJSAnalysisRoutes(r chi.Router) {
+	r.Get("/js/files", GetJSFilesHandler)
+	r.Get("/js/files/{file_id}/versions", GetJSFileVersionsHandler)
+	r.Get("/js/files/{file_id}/diff", GetJSFileDiffHandler) // ?from=&to= (defaults to the two latest versions)
+	r.Get("/js/endpoints", GetJSEndpointsHandler)
+}
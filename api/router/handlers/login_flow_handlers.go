@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeLoginFlowError maps login flow errors to HTTP statuses.
+func writeLoginFlowError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.HasPrefix(msg, "invalid"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "already running"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to manage the login flow")
+	}
+}
+
+// GetLoginFlowHandler returns the login flow of a target with its steps, extractors and the
+// result of its last run.
+func GetLoginFlowHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	flow, err := database.GetLoginFlow(targetID)
+	if err != nil {
+		writeLoginFlowError(w, r, "GetLoginFlowHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flow)
+}
+
+// SetLoginFlowHandler records logged requests as the login flow of a target, replacing the
+// previous one. Body: {"log_ids": [12, 13], "extractors": [{"name": "access_token", "step": 2,
+// "source": "json_path", "expression": "$.access_token"}], "session_headers": {"Authorization":
+// "Bearer {{access_token}}"}, "auto_refresh": true}.
+func SetLoginFlowHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.LoginFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	flow, err := core.SetLoginFlow(targetID, req)
+	if err != nil {
+		writeLoginFlowError(w, r, "SetLoginFlowHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flow)
+}
+
+// DeleteLoginFlowHandler removes the login flow of a target. Its replay session is kept.
+func DeleteLoginFlowHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	if err := database.DeleteLoginFlow(targetID); err != nil {
+		writeLoginFlowError(w, r, "DeleteLoginFlowHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunLoginFlowHandler replays the login flow of a target and writes the minted credentials to its
+// replay session. A flow that fails is reported with status "failed" in the result.
+func RunLoginFlowHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	run, err := core.RunLoginFlow(targetID, models.LoginFlowTriggerManual)
+	if err != nil {
+		writeLoginFlowError(w, r, "RunLoginFlowHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
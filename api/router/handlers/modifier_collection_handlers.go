@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var exportFilenameUnsafeRegex = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// writeModifierCollectionError maps modifier collection and target variable errors to HTTP status codes.
+func writeModifierCollectionError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "already exists"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Modifier collection operation failed")
+	}
+}
+
+// GetModifierCollectionsHandler lists modifier collections.
+// Query parameters: target_id.
+func GetModifierCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	collections, err := database.GetModifierCollections(targetID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "GetModifierCollectionsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collections)
+}
+
+// CreateModifierCollectionHandler creates a folder for modifier tasks.
+func CreateModifierCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ModifierCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "name is required")
+		return
+	}
+
+	collection := models.ModifierCollection{Name: req.Name, Description: req.Description}
+	if req.TargetID != 0 {
+		if _, err := database.GetTargetByID(req.TargetID); err != nil {
+			writeModifierCollectionError(w, r, "CreateModifierCollectionHandler", err)
+			return
+		}
+		collection.TargetID = sql.NullInt64{Int64: req.TargetID, Valid: true}
+	}
+	id, err := database.CreateModifierCollection(collection)
+	if err != nil {
+		writeModifierCollectionError(w, r, "CreateModifierCollectionHandler", err)
+		return
+	}
+	created, err := database.GetModifierCollectionByID(id)
+	if err != nil {
+		writeModifierCollectionError(w, r, "CreateModifierCollectionHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetModifierCollectionHandler returns a collection and its tasks.
+func GetModifierCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collection_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid collection_id in path")
+		return
+	}
+	collection, err := database.GetModifierCollectionByID(collectionID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "GetModifierCollectionHandler", err)
+		return
+	}
+	tasks, err := database.GetModifierTasks(0, collectionID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "GetModifierCollectionHandler", err)
+		return
+	}
+	if tasks == nil {
+		tasks = []models.ModifierTask{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"collection": collection,
+		"tasks":      tasks,
+	})
+}
+
+// UpdateModifierCollectionHandler renames a collection and/or replaces its description.
+func UpdateModifierCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collection_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid collection_id in path")
+		return
+	}
+	existing, err := database.GetModifierCollectionByID(collectionID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "UpdateModifierCollectionHandler", err)
+		return
+	}
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if req.Name != nil {
+		existing.Name = strings.TrimSpace(*req.Name)
+		if existing.Name == "" {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "name cannot be empty")
+			return
+		}
+	}
+	if req.Description != nil {
+		existing.Description = *req.Description
+	}
+
+	if err := database.UpdateModifierCollection(existing); err != nil {
+		writeModifierCollectionError(w, r, "UpdateModifierCollectionHandler", err)
+		return
+	}
+	updated, err := database.GetModifierCollectionByID(collectionID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "UpdateModifierCollectionHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteModifierCollectionHandler removes a collection. Its tasks are kept, outside any collection.
+func DeleteModifierCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collection_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid collection_id in path")
+		return
+	}
+	if err := database.DeleteModifierCollection(collectionID); err != nil {
+		writeModifierCollectionError(w, r, "DeleteModifierCollectionHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportModifierCollectionHandler downloads a collection as a Postman v2.1 collection, with the
+// variables of its target as collection variables.
+func ExportModifierCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collection_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid collection_id in path")
+		return
+	}
+	collection, err := database.GetModifierCollectionByID(collectionID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "ExportModifierCollectionHandler", err)
+		return
+	}
+	tasks, err := database.GetModifierTasks(0, collectionID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "ExportModifierCollectionHandler", err)
+		return
+	}
+	var variables []models.TargetVariable
+	if collection.TargetID.Valid {
+		if variables, err = database.GetTargetVariables(collection.TargetID.Int64); err != nil {
+			writeModifierCollectionError(w, r, "ExportModifierCollectionHandler", err)
+			return
+		}
+	}
+	data, err := core.BuildPostmanCollection(collection, tasks, variables)
+	if err != nil {
+		writeModifierCollectionError(w, r, "ExportModifierCollectionHandler", err)
+		return
+	}
+	filename := strings.Trim(exportFilenameUnsafeRegex.ReplaceAllString(collection.Name, "_"), "_")
+	if filename == "" {
+		filename = fmt.Sprintf("collection_%d", collection.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.postman_collection.json"`, filename))
+	w.Write(data)
+}
+
+// SetModifierTaskCollectionHandler files a task under a collection, or removes it from its
+// collection when collection_id is null or 0.
+func SetModifierTaskCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.ParseInt(chi.URLParam(r, "task_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid task_id in path")
+		return
+	}
+	var req struct {
+		CollectionID *int64 `json:"collection_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	var collectionID int64
+	if req.CollectionID != nil {
+		collectionID = *req.CollectionID
+	}
+	if collectionID != 0 {
+		if _, err := database.GetModifierCollectionByID(collectionID); err != nil {
+			writeModifierCollectionError(w, r, "SetModifierTaskCollectionHandler", err)
+			return
+		}
+	}
+	if err := database.SetModifierTaskCollection(taskID, collectionID); err != nil {
+		writeModifierCollectionError(w, r, "SetModifierTaskCollectionHandler", err)
+		return
+	}
+	task, err := database.GetModifierTaskByID(taskID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "SetModifierTaskCollectionHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// parseTargetVariableRequest decodes and validates a target variable payload.
+func parseTargetVariableRequest(r *http.Request) (models.TargetVariableRequest, error) {
+	var req models.TargetVariableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid request payload: %w", err)
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if !core.IsValidVariableName(req.Name) {
+		return req, fmt.Errorf("invalid variable name '%s', expected letters, digits, '_', '.' or '-'", req.Name)
+	}
+	return req, nil
+}
+
+// GetTargetVariablesHandler lists the variables of a target.
+func GetTargetVariablesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	variables, err := database.GetTargetVariables(targetID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "GetTargetVariablesHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variables)
+}
+
+// CreateTargetVariableHandler adds a variable to a target.
+func CreateTargetVariableHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		writeModifierCollectionError(w, r, "CreateTargetVariableHandler", err)
+		return
+	}
+	defer r.Body.Close()
+	req, err := parseTargetVariableRequest(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	id, err := database.CreateTargetVariable(models.TargetVariable{TargetID: targetID, Name: req.Name, Value: req.Value})
+	if err != nil {
+		writeModifierCollectionError(w, r, "CreateTargetVariableHandler", err)
+		return
+	}
+	created, err := database.GetTargetVariableByID(targetID, id)
+	if err != nil {
+		writeModifierCollectionError(w, r, "CreateTargetVariableHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// UpdateTargetVariableHandler renames a target variable and/or replaces its value.
+func UpdateTargetVariableHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	variableID, err := strconv.ParseInt(chi.URLParam(r, "variable_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid variable_id in path")
+		return
+	}
+	defer r.Body.Close()
+	req, err := parseTargetVariableRequest(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	v := models.TargetVariable{ID: variableID, TargetID: targetID, Name: req.Name, Value: req.Value}
+	if err := database.UpdateTargetVariable(v); err != nil {
+		writeModifierCollectionError(w, r, "UpdateTargetVariableHandler", err)
+		return
+	}
+	updated, err := database.GetTargetVariableByID(targetID, variableID)
+	if err != nil {
+		writeModifierCollectionError(w, r, "UpdateTargetVariableHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteTargetVariableHandler removes a variable of a target.
+func DeleteTargetVariableHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
+		return
+	}
+	variableID, err := strconv.ParseInt(chi.URLParam(r, "variable_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid variable_id in path")
+		return
+	}
+	if err := database.DeleteTargetVariable(targetID, variableID); err != nil {
+		writeModifierCollectionError(w, r, "DeleteTargetVariableHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -27,11 +27,14 @@ import (
 
 // executeModifiedRequestPayload defines the structure for the incoming request from the frontend.
 type executeModifiedRequestPayload struct {
-	TaskID  *int64 `json:"task_id,omitempty"` // Optional, for future use (e.g., versioning)
-	Method  string `json:"method"`
-	URL     string `json:"url"`
-	Headers string `json:"headers"` // Raw string of headers, e.g., "Key1: Value1\nKey2: Value2"
-	Body    string `json:"body"`    // Raw string of the request body
+	TaskID   *int64 `json:"task_id,omitempty"`   // Optional, for future use (e.g., versioning)
+	TargetID *int64 `json:"target_id,omitempty"` // Target whose variables are substituted; defaults to the task's target
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Headers  string `json:"headers"` // Raw string of headers, e.g., "Key1: Value1\nKey2: Value2"
+	Body     string `json:"body"`    // Raw string of the request body
+	// SkipClientProfile sends the request without the target's client profile headers.
+	SkipClientProfile bool `json:"skip_client_profile,omitempty"`
 }
 
 // executeModifiedResponsePayload defines the structure for the response sent back to the frontend.
@@ -42,20 +45,24 @@ type executeModifiedResponsePayload struct {
 	Body       string              `json:"body"` // Base64 encoded response body
 	Error      string              `json:"error,omitempty"`
 	DurationMs int64               `json:"duration_ms"`
+	// UnresolvedVariables lists {{name}} placeholders that had no value and were sent as is.
+	UnresolvedVariables []string `json:"unresolved_variables,omitempty"`
+	// PayloadValues holds the values generated for payload placeholders such as {{oob}}.
+	PayloadValues map[string]string `json:"payload_values,omitempty"`
 }
 
 // AddModifierTaskHandler handles requests to add a new task to the modifier.
 func AddModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.AddModifierTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	// Basic validation (can be expanded)
 	if req.ParameterizedURLID == 0 && req.HTTPTrafficLogID == 0 {
-		http.Error(w, "Either parameterized_url_id or http_traffic_log_id is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Either parameterized_url_id or http_traffic_log_id is required")
 		return
 	}
 
@@ -72,11 +79,11 @@ func AddModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 				sourceDescription = "source item"
 			}
 			logger.Info("AddModifierTaskHandler: Source for creating modifier task not found (%s): %v", sourceDescription, err)
-			http.Error(w, fmt.Sprintf("Source item (%s) not found.", sourceDescription), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Source item (%s) not found.", sourceDescription))
 			return
 		}
 		logger.Error("AddModifierTaskHandler: Failed to create modifier task: %v", err)
-		http.Error(w, "Failed to create modifier task: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create modifier task: "+err.Error())
 		return
 	}
 
@@ -86,11 +93,13 @@ func AddModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetModifierTasksHandler retrieves all tasks for the modifier.
+// Query parameters: target_id, collection_id.
 func GetModifierTasksHandler(w http.ResponseWriter, r *http.Request) {
-	// Add query param handling for target_id if needed in the future
-	tasks, err := database.GetModifierTasks(0) // 0 for no target_id filter for now
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	collectionID, _ := strconv.ParseInt(r.URL.Query().Get("collection_id"), 10, 64)
+	tasks, err := database.GetModifierTasks(targetID, collectionID)
 	if err != nil {
-		http.Error(w, "Failed to retrieve modifier tasks: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve modifier tasks: "+err.Error())
 		return
 	}
 	if tasks == nil {
@@ -105,17 +114,17 @@ func GetModifierTaskDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	taskIDStr := chi.URLParam(r, "task_id")
 	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid task_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid task_id in path")
 		return
 	}
 
 	task, err := database.GetModifierTaskByID(taskID)
 	if err != nil {
-		http.Error(w, "Failed to retrieve modifier task details: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve modifier task details: "+err.Error())
 		return
 	}
 	if task == nil {
-		http.Error(w, "Modifier task not found", http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Modifier task not found")
 		return
 	}
 
@@ -127,13 +136,13 @@ func GetModifierTaskDetailsHandler(w http.ResponseWriter, r *http.Request) {
 func DeleteModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 	taskIDStr := chi.URLParam(r, "task_id") // Get task_id from URL path
 	if taskIDStr == "" {
-		http.Error(w, "task_id path parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "task_id path parameter is required")
 		return
 	}
 
 	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid task_id in path: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid task_id in path: "+err.Error())
 		return
 	}
 
@@ -141,7 +150,7 @@ func DeleteModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// Consider checking for sql.ErrNoRows if DeleteModifierTask returns it
 		// to provide a 404 if the task wasn't found.
-		http.Error(w, "Failed to delete modifier task: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete modifier task: "+err.Error())
 		return
 	}
 
@@ -216,21 +225,57 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 	var payload executeModifiedRequestPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		logger.Error("ExecuteModifiedRequestHandler: Error decoding request: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if strings.TrimSpace(payload.Method) == "" || strings.TrimSpace(payload.URL) == "" {
 		logger.Error("ExecuteModifiedRequestHandler: Method and URL are required.")
-		http.Error(w, "Method and URL are required.", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Method and URL are required.")
 		return
 	}
 
+	// The task stores the request with its {{name}} placeholders; the target's variables are
+	// substituted into what is sent and logged.
+	var targetIDForLog *int64
+	if payload.TaskID != nil {
+		taskDetails, taskErr := database.GetModifierTaskByID(*payload.TaskID)
+		if taskErr == nil && taskDetails != nil && taskDetails.TargetID.Valid {
+			targetIDForLog = &taskDetails.TargetID.Int64
+		} else if taskErr != nil {
+			logger.Error("ExecuteModifiedRequestHandler: Failed to get task details for TargetID: %v", taskErr)
+		}
+	}
+	if payload.TargetID != nil && *payload.TargetID != 0 {
+		targetIDForLog = payload.TargetID
+	}
+	templateHeaders, templateBody := payload.Headers, payload.Body
+	templateMethod, templateURL := payload.Method, payload.URL
+	var unresolved []string
+	var variables map[string]string
+	var variablesTargetID int64
+	if targetIDForLog != nil {
+		variablesTargetID = *targetIDForLog
+		var errVars error
+		if variables, errVars = database.GetTargetVariableMap(*targetIDForLog); errVars != nil {
+			logger.Error("ExecuteModifiedRequestHandler: Failed to load variables of target %d: %v", *targetIDForLog, errVars)
+		}
+	}
+	// Payload placeholders ({{oob}}, {{random}}, ...) get fresh values for each request, recorded
+	// with its log entry below.
+	payloadValues := core.GeneratePayloadValues(variablesTargetID, variables, payload.Method, payload.URL, payload.Headers, payload.Body)
+	if targetIDForLog != nil || len(payloadValues) > 0 {
+		unresolved = core.SubstituteVariablesInPlace(core.WithPayloadValues(variables, payloadValues), &payload.Method, &payload.URL, &payload.Headers, &payload.Body)
+		if len(unresolved) > 0 {
+			logger.Warn("ExecuteModifiedRequestHandler: No value for variables %s, sending them as is", strings.Join(unresolved, ", "))
+		}
+	}
+
 	parsedHeaders, err := parseHeadersString(payload.Headers)
 	if err != nil {
 		logger.Error("ExecuteModifiedRequestHandler: Error parsing headers: %v", err)
-		http.Error(w, "Error parsing headers: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Error parsing headers: "+err.Error())
 		return
 	}
 
@@ -241,7 +286,7 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 	safe, errSafe := isSafeURLForModifier(payload.URL, allowLoopback)
 	if !safe {
 		logger.Error("ExecuteModifiedRequestHandler: Unsafe URL for SSRF: %s. Error: %v", payload.URL, errSafe)
-		http.Error(w, "The requested URL is considered unsafe: "+errSafe.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "The requested URL is considered unsafe: "+errSafe.Error())
 		return
 	}
 
@@ -249,22 +294,26 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 	httpRequest, err := http.NewRequest(strings.ToUpper(payload.Method), payload.URL, reqBodyReader)
 	if err != nil {
 		logger.Error("ExecuteModifiedRequestHandler: Error creating new HTTP request: %v", err)
-		http.Error(w, "Error creating request: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error creating request: "+err.Error())
 		return
 	}
 	httpRequest.Header = parsedHeaders
+	if targetIDForLog != nil && !payload.SkipClientProfile {
+		core.ApplyClientProfile(httpRequest, *targetIDForLog)
+	}
 
 	// Before executing, update the ModifierTask's base request details in the DB
 	// with the current values from the payload (which are from the UI).
 	if payload.TaskID != nil && *payload.TaskID != 0 {
-		reqHeadersForDBJSON, errMarshal := json.Marshal(parsedHeaders) // Marshal http.Header to JSON string
+		templateParsedHeaders, _ := parseHeadersString(templateHeaders)
+		reqHeadersForDBJSON, errMarshal := json.Marshal(templateParsedHeaders) // Marshal http.Header to JSON string
 		if errMarshal != nil {
 			logger.Error("ExecuteModifiedRequestHandler: Error marshalling request headers for DB update: %v", errMarshal)
 			// Decide if this is a fatal error for the operation or just log and continue
 		}
-		reqBodyForDBBase64 := base64.StdEncoding.EncodeToString([]byte(payload.Body))
+		reqBodyForDBBase64 := base64.StdEncoding.EncodeToString([]byte(templateBody))
 
-		if err := database.UpdateModifierTaskBaseRequestDetails(*payload.TaskID, strings.ToUpper(payload.Method), payload.URL, string(reqHeadersForDBJSON), reqBodyForDBBase64); err != nil {
+		if err := database.UpdateModifierTaskBaseRequestDetails(*payload.TaskID, strings.ToUpper(templateMethod), templateURL, string(reqHeadersForDBJSON), reqBodyForDBBase64); err != nil {
 			logger.Error("ExecuteModifiedRequestHandler: Failed to update modifier task base request details for TaskID %d: %v", *payload.TaskID, err)
 			// Decide if this is fatal or log and continue. For now, log and continue.
 		}
@@ -281,7 +330,7 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 			TLSClientConfig: core.GetProxyClientTLSConfig(), // Use the TLS config that trusts our CA
 		}
 		client = &http.Client{
-			Transport:     tr,
+			Transport:     &core.RateLimitedTransport{Base: tr, NoCache: true}, // Sent by hand, never answered from the response cache
 			Timeout:       30 * time.Second,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
 		}
@@ -295,7 +344,7 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLSVerify},
 		}
 		client = &http.Client{
-			Transport: tr,
+			Transport: &core.RateLimitedTransport{Base: tr, NoCache: true},
 			Timeout:   30 * time.Second, // Set a reasonable timeout
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse // Do not follow redirects automatically
@@ -307,8 +356,11 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 	httpResponse, err := client.Do(httpRequest)
 	durationMs := time.Since(startTime).Milliseconds()
 
-	apiResponse := executeModifiedResponsePayload{DurationMs: durationMs}
+	apiResponse := executeModifiedResponsePayload{DurationMs: durationMs, UnresolvedVariables: unresolved, PayloadValues: payloadValues}
 
+	if len(payloadValues) > 0 && err != nil {
+		core.RecordPayloadValues(targetIDForLog, 0, models.PayloadSourceModifier, strings.ToUpper(payload.Method)+" "+payload.URL, payloadValues)
+	}
 	if err != nil {
 		logger.Error("ExecuteModifiedRequestHandler: Error executing request to %s: %v", payload.URL, err)
 		apiResponse.Error = "Failed to execute request: " + err.Error()
@@ -385,16 +437,6 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log the executed request and response
-	var targetIDForLog *int64
-	if payload.TaskID != nil {
-		taskDetails, taskErr := database.GetModifierTaskByID(*payload.TaskID)
-		if taskErr == nil && taskDetails != nil && taskDetails.TargetID.Valid {
-			targetIDForLog = &taskDetails.TargetID.Int64
-		} else if taskErr != nil {
-			logger.Error("ExecuteModifiedRequestHandler: Failed to get task details for TargetID: %v", taskErr)
-		}
-	}
-
 	reqHeadersForLogJSON, _ := json.Marshal(parsedHeaders)        // parsedHeaders is http.Header
 	respHeadersForLogJSON, _ := json.Marshal(apiResponse.Headers) // apiResponse.Headers is map[string][]string
 
@@ -416,15 +458,20 @@ func ExecuteModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
 		DurationMs:           durationMs,
 		IsHTTPS:              strings.HasPrefix(strings.ToLower(payload.URL), "https://"),
 		IsPageCandidate:      strings.Contains(strings.ToLower(httpResponse.Header.Get("Content-Type")), "text/html"),
-		LogSource:            models.NullString("Modifier"),         // Set log source
-		PageSitemapID:        sql.NullInt64{Valid: false},           // No direct page sitemap association here
-		SourceModifierTaskID: sql.NullInt64{Int64: 0, Valid: false}, // Default to invalid
+		LogSource:            models.NullString(models.LogSourceModifier), // Set log source
+		PageSitemapID:        sql.NullInt64{Valid: false},                 // No direct page sitemap association here
+		SourceModifierTaskID: sql.NullInt64{Int64: 0, Valid: false},       // Default to invalid
 	}
 	if payload.TaskID != nil {
 		logEntry.SourceModifierTaskID = sql.NullInt64{Int64: *payload.TaskID, Valid: true}
 	}
 
-	if logID, dbLogErr := database.LogExecutedModifierRequest(logEntry); dbLogErr != nil {
+	core.RedactForStorage(logEntry)
+	logID, dbLogErr := database.LogExecutedModifierRequest(logEntry)
+	if len(payloadValues) > 0 {
+		core.RecordPayloadValues(targetIDForLog, logID, models.PayloadSourceModifier, strings.ToUpper(payload.Method)+" "+payload.URL, payloadValues)
+	}
+	if dbLogErr != nil {
 		logger.Error("ExecuteModifiedRequestHandler: Failed to log executed modified request: %v", dbLogErr)
 		// Continue to send response to client even if logging fails
 	} else {
@@ -458,7 +505,7 @@ func UpdateModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 	taskIDStr := chi.URLParam(r, "task_id")
 	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid task_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid task_id in path")
 		return
 	}
 
@@ -466,13 +513,13 @@ func UpdateModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if strings.TrimSpace(req.Name) == "" {
-		http.Error(w, "Task name cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Task name cannot be empty")
 		return
 	}
 
@@ -482,11 +529,11 @@ func UpdateModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 		// This depends on how UpdateModifierTaskName or GetModifierTaskByID (if called within) handles it.
 		// For now, assuming a general server error.
 		logger.Error("UpdateModifierTaskHandler: Error updating task name for ID %d: %v", taskID, err)
-		http.Error(w, "Failed to update modifier task name: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update modifier task name: "+err.Error())
 		return
 	}
 	if updatedTask == nil { // Should be handled by error above if GetModifierTaskByID returns sql.ErrNoRows
-		http.Error(w, "Modifier task not found after update attempt", http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Modifier task not found after update attempt")
 		return
 	}
 
@@ -500,25 +547,25 @@ func CloneModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 	originalTaskIDStr := chi.URLParam(r, "task_id")
 	originalTaskID, err := strconv.ParseInt(originalTaskIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid original task_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid original task_id in path")
 		return
 	}
 
 	originalTask, err := database.GetModifierTaskByID(originalTaskID)
 	if err != nil {
 		logger.Error("CloneModifierTaskHandler: Error fetching original task ID %d: %v", originalTaskID, err)
-		http.Error(w, "Failed to retrieve original task for cloning", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve original task for cloning")
 		return
 	}
 	if originalTask == nil {
-		http.Error(w, "Original modifier task not found for cloning", http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Original modifier task not found for cloning")
 		return
 	}
 
 	clonedTask, err := database.CloneModifierTaskDB(originalTaskID)
 	if err != nil {
 		logger.Error("CloneModifierTaskHandler: Error cloning task ID %d: %v", originalTaskID, err)
-		http.Error(w, "Failed to clone modifier task: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to clone modifier task: "+err.Error())
 		return
 	}
 
@@ -532,7 +579,7 @@ func CloneModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
 func UpdateModifierTasksOrderHandler(w http.ResponseWriter, r *http.Request) {
 	var taskOrders map[string]int // Expecting {"taskID_string": order_int}
 	if err := json.NewDecoder(r.Body).Decode(&taskOrders); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -542,7 +589,7 @@ func UpdateModifierTasksOrderHandler(w http.ResponseWriter, r *http.Request) {
 		taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
 		if err != nil {
 			logger.Error("UpdateModifierTasksOrderHandler: Invalid task_id '%s' in payload: %v", taskIDStr, err)
-			http.Error(w, "Invalid task_id in payload: "+taskIDStr, http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid task_id in payload: "+taskIDStr)
 			return
 		}
 		orders[taskID] = order
@@ -550,7 +597,7 @@ func UpdateModifierTasksOrderHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := database.UpdateModifierTasksOrder(orders); err != nil {
 		logger.Error("UpdateModifierTasksOrderHandler: Error updating task order in DB: %v", err)
-		http.Error(w, "Failed to update task order: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update task order: "+err.Error())
 		return
 	}
 
@@ -565,20 +612,20 @@ func DeleteAllModifierTasksForTargetHandler(w http.ResponseWriter, r *http.Reque
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeleteAllModifierTasksForTargetHandler: Invalid target_id in path: %v", err)
-		http.Error(w, "Invalid target_id in path", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id in path")
 		return
 	}
 
 	if targetID == 0 {
 		logger.Error("DeleteAllModifierTasksForTargetHandler: target_id cannot be zero.")
-		http.Error(w, "target_id cannot be zero", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id cannot be zero")
 		return
 	}
 
 	rowsAffected, err := database.DeleteModifierTasksByTargetID(targetID)
 	if err != nil {
 		logger.Error("DeleteAllModifierTasksForTargetHandler: Error deleting tasks for target %d: %v", targetID, err)
-		http.Error(w, "Failed to delete modifier tasks for target", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete modifier tasks for target")
 		return
 	}
 
@@ -590,3 +637,114 @@ func DeleteAllModifierTasksForTargetHandler(w http.ResponseWriter, r *http.Reque
 		"rows_affected": rowsAffected,
 	})
 }
+
+// importModifierTaskPayload is the body of POST /modifier/tasks/import.
+type importModifierTaskPayload struct {
+	Format       string `json:"format"`  // "curl" or "raw"
+	Content      string `json:"content"` // The pasted curl command or raw HTTP request
+	TargetID     int64  `json:"target_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Scheme       string `json:"scheme,omitempty"` // Scheme for raw requests with a relative target (default https)
+	CollectionID int64  `json:"collection_id,omitempty"`
+}
+
+// ImportModifierTaskHandler creates a modifier task from a pasted curl command or raw HTTP request.
+func ImportModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var payload importModifierTaskPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if strings.TrimSpace(payload.Content) == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "content is required")
+		return
+	}
+	format := strings.ToLower(payload.Format)
+	if format == "" {
+		// Guess from the content: anything starting with "curl" is a curl command
+		format = "raw"
+		if strings.HasPrefix(strings.TrimSpace(payload.Content), "curl") {
+			format = "curl"
+		}
+	}
+
+	var parsed *core.ParsedHTTPRequest
+	var err error
+	switch format {
+	case "curl":
+		parsed, err = core.ParseCurlCommand(payload.Content)
+	case "raw":
+		parsed, err = core.ParseRawHTTPRequest(payload.Content, payload.Scheme)
+	default:
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid format, expected 'curl' or 'raw'")
+		return
+	}
+	if err != nil {
+		logger.Error("ImportModifierTaskHandler: Could not parse %s input: %v", format, err)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Could not parse input: "+err.Error())
+		return
+	}
+
+	headersJSON, err := json.Marshal(parsed.Headers)
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to encode headers: "+err.Error())
+		return
+	}
+	task := models.ModifierTask{
+		Name:               payload.Name,
+		BaseRequestMethod:  parsed.Method,
+		BaseRequestURL:     parsed.URL,
+		BaseRequestHeaders: models.NullString(string(headersJSON)),
+		BaseRequestBody:    models.NullString(base64.StdEncoding.EncodeToString(parsed.Body)),
+	}
+	if payload.TargetID != 0 {
+		task.TargetID = sql.NullInt64{Int64: payload.TargetID, Valid: true}
+	}
+	if payload.CollectionID != 0 {
+		if _, err := database.GetModifierCollectionByID(payload.CollectionID); err != nil {
+			writeModifierCollectionError(w, r, "ImportModifierTaskHandler", err)
+			return
+		}
+		task.CollectionID = sql.NullInt64{Int64: payload.CollectionID, Valid: true}
+	}
+	if task.Name == "" {
+		task.Name = fmt.Sprintf("Imported - %s %s", parsed.Method, parsed.URL)
+	}
+
+	created, err := database.CreateModifierTask(task)
+	if err != nil {
+		logger.Error("ImportModifierTaskHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create modifier task: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// ExportModifierTaskHandler exports a modifier task's base request as a curl command or raw HTTP message.
+// Query parameter: format (curl, raw; default curl).
+func ExportModifierTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.ParseInt(chi.URLParam(r, "task_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid task_id in path")
+		return
+	}
+	task, err := database.GetModifierTaskByID(taskID)
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve modifier task: "+err.Error())
+		return
+	}
+	if task == nil {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Modifier task not found")
+		return
+	}
+	var targetID *int64
+	if task.TargetID.Valid {
+		targetID = &task.TargetID.Int64
+	}
+	writeRequestExport(w, r, r.URL.Query().Get("format"), targetID, task.BaseRequestMethod, task.BaseRequestURL,
+		core.HeadersFromJSON(task.BaseRequestHeaders.String), core.DecodeStoredBody(task.BaseRequestBody.String))
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// executeRawRequestPayload is the body of POST /modifier/execute/raw.
+type executeRawRequestPayload struct {
+	TaskID    *int64 `json:"task_id,omitempty"`
+	TargetID  *int64 `json:"target_id,omitempty"` // Target whose variables are substituted; defaults to the task's target
+	URL       string `json:"url"`                 // scheme://host[:port] to connect to; defaults to the task's base URL. The path is ignored.
+	Raw       string `json:"raw"`                 // The request exactly as it is sent
+	RawBase64 string `json:"raw_base64,omitempty"`
+	// NormalizeLineEndings turns bare LF into CRLF, for requests typed in an editor. Off by default
+	// so bare LF can be sent on purpose.
+	NormalizeLineEndings bool `json:"normalize_line_endings,omitempty"`
+	TimeoutMs            int  `json:"timeout_ms,omitempty"`      // Overall timeout, default 30000
+	IdleTimeoutMs        int  `json:"idle_timeout_ms,omitempty"` // Stop reading after this much silence mid-response, default 2000
+}
+
+// executeRawResponsePayload is the first response in the fields the regular execute endpoint
+// uses, plus everything the server sent as received.
+type executeRawResponsePayload struct {
+	executeModifiedResponsePayload
+	RawResponse      string `json:"raw_response"` // Base64 encoded bytes received
+	ResponseCount    int    `json:"response_count"`
+	BytesSent        int    `json:"bytes_sent"`
+	ConnectionClosed bool   `json:"connection_closed"`
+	Truncated        bool   `json:"truncated,omitempty"`
+	ParseError       string `json:"parse_error,omitempty"`
+}
+
+// normalizeRawLineEndings converts bare LF line endings to CRLF.
+func normalizeRawLineEndings(raw []byte) []byte {
+	return []byte(strings.ReplaceAll(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n", "\r\n"))
+}
+
+// ExecuteRawModifiedRequestHandler sends a request byte for byte over its own connection, for
+// request smuggling and parser discrepancy tests that net/http would normalize away.
+func ExecuteRawModifiedRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var payload executeRawRequestPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if r.Header.Get("X-Modifier-Send-Through-Proxy") == "true" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Raw requests cannot be sent through the proxy, it would normalize them")
+		return
+	}
+
+	rawTemplate := []byte(payload.Raw)
+	if payload.RawBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(payload.RawBase64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid raw_base64: "+err.Error())
+			return
+		}
+		rawTemplate = decoded
+	}
+	if len(rawTemplate) == 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "raw or raw_base64 is required")
+		return
+	}
+
+	var targetIDForLog *int64
+	if payload.TaskID != nil {
+		task, err := database.GetModifierTaskByID(*payload.TaskID)
+		if err != nil {
+			logger.Error("ExecuteRawModifiedRequestHandler: Failed to get task %d: %v", *payload.TaskID, err)
+		} else if task == nil {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Modifier task not found")
+			return
+		} else {
+			if task.TargetID.Valid {
+				targetIDForLog = &task.TargetID.Int64
+			}
+			if payload.URL == "" {
+				payload.URL = task.BaseRequestURL
+			}
+		}
+	}
+	if payload.TargetID != nil && *payload.TargetID != 0 {
+		targetIDForLog = payload.TargetID
+	}
+	if strings.TrimSpace(payload.URL) == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "url is required (scheme://host[:port] to connect to)")
+		return
+	}
+
+	raw, connectURL := string(rawTemplate), payload.URL
+	var unresolved []string
+	var variables map[string]string
+	var variablesTargetID int64
+	if targetIDForLog != nil {
+		variablesTargetID = *targetIDForLog
+		var errVars error
+		if variables, errVars = database.GetTargetVariableMap(*targetIDForLog); errVars != nil {
+			logger.Error("ExecuteRawModifiedRequestHandler: Failed to load variables of target %d: %v", *targetIDForLog, errVars)
+		}
+	}
+	payloadValues := core.GeneratePayloadValues(variablesTargetID, variables, raw, connectURL)
+	if targetIDForLog != nil || len(payloadValues) > 0 {
+		unresolved = core.SubstituteVariablesInPlace(core.WithPayloadValues(variables, payloadValues), &raw, &connectURL)
+	}
+	rawBytes := []byte(raw)
+	if payload.NormalizeLineEndings {
+		rawBytes = normalizeRawLineEndings(rawBytes)
+	}
+
+	u, err := url.Parse(connectURL)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("Invalid url '%s', expected http(s)://host[:port]", connectURL))
+		return
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if safe, errSafe := isSafeURLForModifier(connectURL, config.AppConfig.Proxy.ModifierAllowLoopback); !safe {
+		logger.Error("ExecuteRawModifiedRequestHandler: Unsafe URL for SSRF: %s. Error: %v", connectURL, errSafe)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "The requested URL is considered unsafe: "+errSafe.Error())
+		return
+	}
+
+	if payload.TaskID != nil {
+		if err := database.UpdateModifierTaskRawRequest(*payload.TaskID, base64.StdEncoding.EncodeToString(rawTemplate)); err != nil {
+			logger.Error("ExecuteRawModifiedRequestHandler: %v", err)
+		}
+	}
+
+	startTime := time.Now()
+	exchange, err := core.SendRawHTTPRequest(r.Context(), rawBytes, core.RawRequestOptions{
+		Address:            net.JoinHostPort(u.Hostname(), port),
+		TLS:                u.Scheme == "https",
+		InsecureSkipVerify: config.AppConfig.Proxy.ModifierSkipTLSVerify,
+		Timeout:            time.Duration(payload.TimeoutMs) * time.Millisecond,
+		IdleTimeout:        time.Duration(payload.IdleTimeoutMs) * time.Millisecond,
+	})
+	apiResponse := executeRawResponsePayload{}
+	apiResponse.UnresolvedVariables = unresolved
+	apiResponse.PayloadValues = payloadValues
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		if len(payloadValues) > 0 {
+			core.RecordPayloadValues(targetIDForLog, 0, models.PayloadSourceModifier, "raw request to "+connectURL, payloadValues)
+		}
+		logger.Error("ExecuteRawModifiedRequestHandler: %v", err)
+		apiResponse.DurationMs = time.Since(startTime).Milliseconds()
+		apiResponse.Error = "Failed to execute raw request: " + err.Error()
+		json.NewEncoder(w).Encode(apiResponse)
+		return
+	}
+
+	apiResponse.DurationMs = exchange.Duration.Milliseconds()
+	apiResponse.RawResponse = base64.StdEncoding.EncodeToString(exchange.Raw)
+	apiResponse.ResponseCount = len(exchange.Responses)
+	apiResponse.BytesSent = exchange.BytesSent
+	apiResponse.ConnectionClosed = exchange.ConnectionClosed
+	apiResponse.Truncated = exchange.Truncated
+	apiResponse.ParseError = exchange.ParseError
+
+	method, target, reqHeaders, reqBody := core.SplitRawHTTPRequest(rawBytes)
+	logURL := target
+	if !strings.Contains(target, "://") {
+		logURL = u.Scheme + "://" + u.Host + target
+	}
+	logEntry := &models.HTTPTrafficLog{
+		TargetID:           targetIDForLog,
+		Timestamp:          startTime,
+		RequestMethod:      models.NullString(method),
+		RequestURL:         models.NullString(logURL),
+		RequestHTTPVersion: models.NullString("HTTP/1.1"),
+		RequestBody:        reqBody,
+		ResponseBody:       exchange.Raw,
+		ResponseBodySize:   int64(len(exchange.Raw)),
+		DurationMs:         apiResponse.DurationMs,
+		IsHTTPS:            u.Scheme == "https",
+	}
+	if reqHeadersJSON, errMarshal := json.Marshal(reqHeaders); errMarshal == nil {
+		logEntry.RequestHeaders = models.NullString(string(reqHeadersJSON))
+	}
+	if len(exchange.Responses) > 0 {
+		first := exchange.Responses[0]
+		body, errDecode := core.DecodeContentEncoding(first.Body, first.Header.Get("Content-Encoding"))
+		headers := first.Header
+		if errDecode == nil && first.Header.Get("Content-Encoding") != "" {
+			headers = first.Header.Clone()
+			headers.Del("Content-Encoding")
+			headers.Del("Content-Length")
+		}
+		apiResponse.StatusCode = first.StatusCode
+		apiResponse.StatusText = first.Status
+		apiResponse.Headers = headers
+		apiResponse.Body = base64.StdEncoding.EncodeToString(body)
+
+		respHeadersJSON, _ := json.Marshal(headers)
+		logEntry.ResponseStatusCode = first.StatusCode
+		logEntry.ResponseReasonPhrase = models.NullString(strings.TrimPrefix(first.Status, fmt.Sprintf("%d ", first.StatusCode)))
+		logEntry.ResponseHTTPVersion = models.NullString(first.Proto)
+		logEntry.ResponseHeaders = models.NullString(string(respHeadersJSON))
+		logEntry.ResponseContentType = models.NullString(first.Header.Get("Content-Type"))
+		logEntry.IsPageCandidate = strings.Contains(strings.ToLower(first.Header.Get("Content-Type")), "text/html")
+		if len(exchange.Responses) == 1 && exchange.ParseError == "" {
+			logEntry.ResponseBody = body
+			logEntry.ResponseBodySize = int64(len(body))
+		}
+	}
+	if payload.TaskID != nil {
+		logEntry.SourceModifierTaskID = sql.NullInt64{Int64: *payload.TaskID, Valid: true}
+	}
+
+	core.RedactForStorage(logEntry)
+	logID, errLog := database.LogExecutedModifierRequest(logEntry)
+	if len(payloadValues) > 0 {
+		core.RecordPayloadValues(targetIDForLog, logID, models.PayloadSourceModifier, method+" "+logURL, payloadValues)
+	}
+	if errLog != nil {
+		logger.Error("ExecuteRawModifiedRequestHandler: Failed to log executed raw request: %v", errLog)
+	} else if payload.TaskID != nil {
+		if errUpdate := database.UpdateModifierTaskLastExecutedLogID(*payload.TaskID, logID); errUpdate != nil {
+			logger.Error("ExecuteRawModifiedRequestHandler: %v", errUpdate)
+		}
+	}
+
+	json.NewEncoder(w).Encode(apiResponse)
+	logger.Info("ExecuteRawModifiedRequestHandler: Sent %d raw bytes to %s, received %d bytes in %d response(s)", exchange.BytesSent, u.Host, len(exchange.Raw), len(exchange.Responses))
+}
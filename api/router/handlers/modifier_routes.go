@@ -6,12 +6,30 @@ import (
 
 func RegisterModifierRoutes(r chi.Router) {
 	r.Post("/modifier/tasks", AddModifierTaskHandler)
+	r.Post("/modifier/tasks/import", ImportModifierTaskHandler) // From a pasted curl command or raw HTTP request
 	r.Get("/modifier/tasks", GetModifierTasksHandler)
 	r.Get("/modifier/tasks/{task_id}", GetModifierTaskDetailsHandler)
+	r.Get("/modifier/tasks/{task_id}/export", ExportModifierTaskHandler) // ?format=curl|raw
 	r.Post("/modifier/execute", ExecuteModifiedRequestHandler)
-	r.Put("/modifier/tasks/{task_id}", UpdateModifierTaskHandler) // For updating parts of the task, like name
+	r.Post("/modifier/execute/raw", ExecuteRawModifiedRequestHandler) // Byte-exact request over its own connection
+	r.Put("/modifier/tasks/{task_id}", UpdateModifierTaskHandler)     // For updating parts of the task, like name
 	r.Post("/modifier/tasks/{task_id}/clone", CloneModifierTaskHandler)
 	r.Put("/modifier/tasks/order", UpdateModifierTasksOrderHandler)                        // For updating the order of all tasks
 	r.Delete("/modifier/tasks/{task_id}", DeleteModifierTaskHandler)                       // For deleting a specific task
 	r.Delete("/modifier/tasks/target/{target_id}", DeleteAllModifierTasksForTargetHandler) // New route
+	r.Put("/modifier/tasks/{task_id}/collection", SetModifierTaskCollectionHandler)        // {"collection_id": N}, null to unfile
+
+	// Collections: folders of tasks, exportable as Postman collections
+	r.Get("/modifier/collections", GetModifierCollectionsHandler) // ?target_id=
+	r.Post("/modifier/collections", CreateModifierCollectionHandler)
+	r.Get("/modifier/collections/{collection_id}", GetModifierCollectionHandler)
+	r.Put("/modifier/collections/{collection_id}", UpdateModifierCollectionHandler)
+	r.Delete("/modifier/collections/{collection_id}", DeleteModifierCollectionHandler)
+	r.Get("/modifier/collections/{collection_id}/export", ExportModifierCollectionHandler) // Postman v2.1 JSON
+
+	// Per-target variables substituted as {{name}} when a request is executed
+	r.Get("/targets/{target_id}/variables", GetTargetVariablesHandler)
+	r.Post("/targets/{target_id}/variables", CreateTargetVariableHandler)
+	r.Put("/targets/{target_id}/variables/{variable_id}", UpdateTargetVariableHandler)
+	r.Delete("/targets/{target_id}/variables/{variable_id}", DeleteTargetVariableHandler)
 }
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -15,29 +16,40 @@ import (
 
 // CreateNoteHandler handles POST requests to create a new note.
 func CreateNoteHandler(w http.ResponseWriter, r *http.Request) {
-	var note models.Note
-	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+	var req models.NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("CreateNoteHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
-	if strings.TrimSpace(note.Content) == "" {
+	if strings.TrimSpace(req.Content) == "" {
 		logger.Error("CreateNoteHandler: Note content cannot be empty.")
-		http.Error(w, "Note content cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Note content cannot be empty")
 		return
 	}
 
+	note := models.Note{Title: models.NullString(req.Title), Content: req.Content}
+	if req.TargetID != nil && *req.TargetID != 0 {
+		note.TargetID = sql.NullInt64{Int64: *req.TargetID, Valid: true}
+		if _, err := database.GetTargetByID(*req.TargetID); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+	}
 	id, err := database.CreateNote(note)
 	if err != nil {
 		logger.Error("CreateNoteHandler: Error creating note: %v", err)
-		http.Error(w, "Failed to create note", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create note")
 		return
 	}
 	note.ID = id
 	note.CreatedAt = time.Now()
 	note.UpdatedAt = time.Now()
+	if err := database.ReplaceNoteLinks(note, core.ParseWikiLinks(note.Content)); err != nil {
+		logger.Error("CreateNoteHandler: Error storing links of note %d: %v", id, err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -50,10 +62,10 @@ func GetNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("GetNoteHandler: Note with ID %d not found", noteID)
-			http.Error(w, "Note not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Note not found")
 		} else {
 			logger.Error("GetNoteHandler: Error fetching note %d: %v", noteID, err)
-			http.Error(w, "Failed to retrieve note", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note")
 		}
 		return
 	}
@@ -62,7 +74,14 @@ func GetNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
 }
 
 // ListNotesHandler handles GET requests to list all notes with pagination.
+// Query parameters: target_id, search (full-text), page, limit, sort_by, sort_order, and
+// pagination=cursor or cursor for cursor pages (by updated_at or created_at).
 func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	if r.URL.Query().Get("search") != "" {
+		SearchNotesHandler(w, r)
+		return
+	}
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	sortBy := r.URL.Query().Get("sort_by")
@@ -74,6 +93,10 @@ func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
+	if cursorPagination(r) {
+		listNotesByCursor(w, r, targetID, limit)
+		return
+	}
 	if sortBy == "" {
 		sortBy = "updated_at"
 	}
@@ -83,10 +106,10 @@ func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
 
 	offset := (page - 1) * limit
 
-	notes, totalRecords, err := database.GetAllNotesPaginated(limit, offset, sortBy, sortOrder)
+	notes, totalRecords, err := database.GetAllNotesPaginated(targetID, limit, offset, sortBy, sortOrder)
 	if err != nil {
 		logger.Error("ListNotesHandler: Error fetching notes: %v", err)
-		http.Error(w, "Failed to retrieve notes", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve notes")
 		return
 	}
 
@@ -117,12 +140,47 @@ func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// listNotesByCursor serves a cursor page of ListNotesHandler.
+func listNotesByCursor(w http.ResponseWriter, r *http.Request, targetID int64, limit int) {
+	page, err := database.NoteKeyset.Page(r.URL.Query().Get("cursor"), r.URL.Query().Get("sort_by"), r.URL.Query().Get("sort_order"), limit)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	notes, totalRecords, prevCursor, nextCursor, err := database.GetNotesByCursor(targetID, page)
+	if err != nil {
+		logger.Error("ListNotesHandler: Error fetching notes: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve notes")
+		return
+	}
+	response := struct {
+		Limit        int           `json:"limit"`
+		TotalRecords int64         `json:"total_records"`
+		SortBy       string        `json:"sort_by"`
+		SortOrder    string        `json:"sort_order"`
+		PrevCursor   string        `json:"prev_cursor,omitempty"`
+		NextCursor   string        `json:"next_cursor,omitempty"`
+		Notes        []models.Note `json:"notes"`
+	}{
+		Limit:        limit,
+		TotalRecords: totalRecords,
+		SortBy:       page.Column,
+		SortOrder:    page.Order,
+		PrevCursor:   prevCursor,
+		NextCursor:   nextCursor,
+		Notes:        notes,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // UpdateNoteHandler handles PUT requests to update an existing note.
+// The title and content are replaced; target_id is only changed when present (0 makes the note global).
 func UpdateNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
-	var updates models.Note
+	var updates models.NoteRequest
 	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
 		logger.Error("UpdateNoteHandler: Error decoding request body for note %d: %v", noteID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -130,27 +188,44 @@ func UpdateNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
 	existingNote, err := database.GetNoteByID(noteID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Note not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Note not found")
 		} else {
-			http.Error(w, "Failed to retrieve note for update", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note for update")
 		}
 		return
 	}
 
-	existingNote.Title = updates.Title
+	existingNote.Title = models.NullString(updates.Title)
 	if strings.TrimSpace(updates.Content) == "" {
-		http.Error(w, "Note content cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Note content cannot be empty")
 		return
 	}
 	existingNote.Content = updates.Content
+	if updates.TargetID != nil {
+		existingNote.TargetID = sql.NullInt64{Int64: *updates.TargetID, Valid: *updates.TargetID != 0}
+		if existingNote.TargetID.Valid {
+			if _, err := database.GetTargetByID(*updates.TargetID); err != nil {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+				return
+			}
+		}
+	}
+
+	// Soft locks are advisory: the update goes through and the holder is reported.
+	if holder := core.LockHolder(core.APIUser(r.Context()), models.LockEntityNote, noteID); holder != "" {
+		w.Header().Set("X-Toolkit-Locked-By", holder)
+	}
 
 	err = database.UpdateNote(existingNote)
 	if err != nil {
 		logger.Error("UpdateNoteHandler: Error updating note %d: %v", noteID, err)
-		http.Error(w, "Failed to update note", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update note")
 		return
 	}
 	existingNote.UpdatedAt = time.Now()
+	if err := database.ReplaceNoteLinks(existingNote, core.ParseWikiLinks(existingNote.Content)); err != nil {
+		logger.Error("UpdateNoteHandler: Error storing links of note %d: %v", noteID, err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(existingNote)
@@ -160,9 +235,9 @@ func UpdateNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
 func DeleteNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
 	if _, err := database.GetNoteByID(noteID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Note not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Note not found")
 		} else {
-			http.Error(w, "Failed to retrieve note for deletion", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note for deletion")
 		}
 		return
 	}
@@ -170,8 +245,146 @@ func DeleteNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
 	err := database.DeleteNote(noteID)
 	if err != nil {
 		logger.Error("DeleteNoteHandler: Error deleting note %d: %v", noteID, err)
-		http.Error(w, "Failed to delete note", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete note")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// SearchNotesHandler handles GET requests for full-text search over note titles and content.
+// Query parameters: search (or q), target_id, page, limit.
+func SearchNotesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("search")
+	if query == "" {
+		query = r.URL.Query().Get("q")
+	}
+	if strings.TrimSpace(query) == "" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "search query is required")
+		return
+	}
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	results, totalRecords, err := database.SearchNotes(query, targetID, limit, (page-1)*limit)
+	if err != nil {
+		logger.Error("SearchNotesHandler: Error searching notes for '%s': %v", query, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to search notes")
+		return
+	}
+	totalPages := (totalRecords + int64(limit) - 1) / int64(limit)
+
+	response := struct {
+		Page         int                       `json:"page"`
+		Limit        int                       `json:"limit"`
+		TotalRecords int64                     `json:"total_records"`
+		TotalPages   int64                     `json:"total_pages"`
+		Search       string                    `json:"search"`
+		Notes        []models.NoteSearchResult `json:"notes"`
+	}{page, limit, totalRecords, totalPages, query, results}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RenderNoteHandler handles GET requests for a note rendered from markdown to HTML.
+// Query parameters: format (html (default) returns text/html, json wraps the HTML with the note).
+func RenderNoteHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
+	note, err := database.GetNoteByID(noteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Note not found")
+		} else {
+			logger.Error("RenderNoteHandler: Error fetching note %d: %v", noteID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note")
+		}
+		return
+	}
+	rendered := core.RenderMarkdown(note.Content)
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			models.Note
+			HTML string `json:"html"`
+		}{note, rendered})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(rendered))
+}
+
+// RenderMarkdownPreviewHandler handles POST requests to render unsaved markdown (e.g. an editor preview).
+func RenderMarkdownPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"html":  core.RenderMarkdown(req.Content),
+		"links": core.ParseWikiLinks(req.Content),
+	})
+}
+
+// GetNoteLinksHandler handles GET requests for a note's outgoing links and the notes linking to it.
+func GetNoteLinksHandler(w http.ResponseWriter, r *http.Request, noteID int64) {
+	if _, err := database.GetNoteByID(noteID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Note not found")
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note")
+		}
+		return
+	}
+	links, err := database.GetNoteLinks(noteID)
+	if err != nil {
+		logger.Error("GetNoteLinksHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note links")
+		return
+	}
+	backlinks, err := database.GetNoteBacklinks(models.NoteLinkNote, noteID)
+	if err != nil {
+		logger.Error("GetNoteLinksHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve note backlinks")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"links": links, "backlinks": backlinks})
+}
+
+// GetEntityBacklinksHandler handles GET requests for the notes that link to an entity.
+// Query parameters: type (note, target, finding, httplog, domain), id.
+func GetEntityBacklinksHandler(w http.ResponseWriter, r *http.Request) {
+	linkType := r.URL.Query().Get("type")
+	switch linkType {
+	case models.NoteLinkNote, models.NoteLinkTarget, models.NoteLinkFinding, models.NoteLinkHTTPLog, models.NoteLinkDomain:
+	case "log":
+		linkType = models.NoteLinkHTTPLog
+	default:
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "type must be one of note, target, finding, httplog, domain")
+		return
+	}
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid id")
+		return
+	}
+	notes, err := database.GetNoteBacklinks(linkType, id)
+	if err != nil {
+		logger.Error("GetEntityBacklinksHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve backlinks")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notes)
+}
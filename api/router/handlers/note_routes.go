@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"toolkit/models"
 
 	"toolkit/logger"
 
@@ -11,8 +12,11 @@ import (
 
 func RegisterNoteRoutes(r chi.Router) {
 	// Collection routes for /notes
-	r.Get("/notes", ListNotesHandler)   // Existing handler
-	r.Post("/notes", CreateNoteHandler) // Existing handler
+	r.Get("/notes", ListNotesHandler)                     // Existing handler
+	r.Post("/notes", CreateNoteHandler)                   // Existing handler
+	r.Get("/notes/search", SearchNotesHandler)            // ?q=&target_id=
+	r.Get("/notes/backlinks", GetEntityBacklinksHandler)  // ?type=finding&id=5
+	r.Post("/notes/render", RenderMarkdownPreviewHandler) // Render unsaved markdown
 
 	// Routes for specific note items: /notes/{noteID}
 	r.Route("/notes/{noteID}", func(subRouter chi.Router) {
@@ -22,7 +26,7 @@ func RegisterNoteRoutes(r chi.Router) {
 			noteID, err := strconv.ParseInt(noteIDStr, 10, 64)
 			if err != nil {
 				logger.Error("RegisterNoteRoutes: Invalid noteID format '%s': %v", noteIDStr, err)
-				http.Error(w, "Invalid note ID format", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid note ID format")
 				return
 			}
 			GetNoteHandler(w, req, noteID) // Existing handler
@@ -37,5 +41,23 @@ func RegisterNoteRoutes(r chi.Router) {
 			noteID, _ := strconv.ParseInt(chi.URLParam(req, "noteID"), 10, 64) // Error checked by Get
 			DeleteNoteHandler(w, req, noteID)                                  // Existing handler
 		})
+		// GET /notes/{noteID}/render
+		subRouter.Get("/render", func(w http.ResponseWriter, req *http.Request) {
+			noteID, err := strconv.ParseInt(chi.URLParam(req, "noteID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid note ID format")
+				return
+			}
+			RenderNoteHandler(w, req, noteID)
+		})
+		// GET /notes/{noteID}/links
+		subRouter.Get("/links", func(w http.ResponseWriter, req *http.Request) {
+			noteID, err := strconv.ParseInt(chi.URLParam(req, "noteID"), 10, 64)
+			if err != nil {
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid note ID format")
+				return
+			}
+			GetNoteLinksHandler(w, req, noteID)
+		})
 	})
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// GetNotificationSettingsHandler returns the configured notification sinks and event switches.
+func GetNotificationSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetNotificationSettings())
+}
+
+// SendTestNotificationHandler sends a test notification to every configured sink.
+func SendTestNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	err := core.SendNotification(r.Context(), models.Notification{
+		Kind:    models.NotificationTest,
+		Title:   "Toolkit test notification",
+		Message: "Notifications are working.",
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not configured") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		logger.Error("SendTestNotificationHandler: %v", err)
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,10 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterNotificationRoutes(r chi.Router) {
+	r.Get("/notifications", GetNotificationSettingsHandler)
+	r.Post("/notifications/test", SendTestNotificationHandler)
+}
@@ -23,17 +23,17 @@ func CreatePageHandler(w http.ResponseWriter, r *http.Request) {
 		Description string `json:"description"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.TargetID == 0 {
-		http.Error(w, "target_id is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id is required")
 		return
 	}
 	if req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "name is required")
 		return
 	}
 
@@ -46,7 +46,7 @@ func CreatePageHandler(w http.ResponseWriter, r *http.Request) {
 
 	pageID, err := database.CreatePage(page)
 	if err != nil {
-		http.Error(w, "Failed to create page: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create page: "+err.Error())
 		return
 	}
 
@@ -67,21 +67,21 @@ func StopPageRecordingHandler(w http.ResponseWriter, r *http.Request) {
 		StartTimestamp int64 `json:"start_timestamp"` // Expect Unix timestamp (seconds)
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.PageID == 0 {
-		http.Error(w, "page_id is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "page_id is required")
 		return
 	}
 	if req.TargetID == 0 {
-		http.Error(w, "target_id is required for log association", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id is required for log association")
 		return
 	}
 	if req.StartTimestamp == 0 {
-		http.Error(w, "start_timestamp is required for log association", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "start_timestamp is required for log association")
 		return
 	}
 
@@ -90,7 +90,7 @@ func StopPageRecordingHandler(w http.ResponseWriter, r *http.Request) {
 
 	err := database.UpdatePageEndTime(req.PageID, endTime)
 	if err != nil {
-		http.Error(w, "Failed to update page end time: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update page end time: "+err.Error())
 		return
 	}
 
@@ -99,7 +99,7 @@ func StopPageRecordingHandler(w http.ResponseWriter, r *http.Request) {
 		// Log the error, but the page end time was updated, so maybe not a 500 for the whole operation.
 		// Depending on strictness, you might choose to return 500.
 		logger.Error("StopPageRecordingHandler: Failed to associate logs for page ID %d: %v", req.PageID, err)
-		// http.Error(w, "Failed to associate logs: "+err.Error(), http.StatusInternalServerError)
+		// WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to associate logs: "+err.Error())
 		// return
 	}
 
@@ -116,18 +116,18 @@ func StopPageRecordingHandler(w http.ResponseWriter, r *http.Request) {
 func GetPagesForTargetHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
-		http.Error(w, "target_id query parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id: "+err.Error())
 		return
 	}
 
 	pages, err := database.GetPagesForTarget(targetID)
 	if err != nil {
-		http.Error(w, "Failed to retrieve pages: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve pages: "+err.Error())
 		return
 	}
 
@@ -143,12 +143,12 @@ func GetPagesForTargetHandler(w http.ResponseWriter, r *http.Request) {
 func GetLogsForPageHandler(w http.ResponseWriter, r *http.Request) {
 	pageIDStr := r.URL.Query().Get("page_id")
 	if pageIDStr == "" {
-		http.Error(w, "page_id query parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "page_id query parameter is required")
 		return
 	}
 	pageID, err := strconv.ParseInt(pageIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid page_id: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid page_id: "+err.Error())
 		return
 	}
 	// --- New: Parse pagination and sorting parameters ---
@@ -195,7 +195,7 @@ func GetLogsForPageHandler(w http.ResponseWriter, r *http.Request) {
 	logs, totalRecords, err := database.GetLogsForPagePaginatedAndSorted(pageID, page, limit, dbSortByColumn, dbSortOrder)
 	if err != nil {
 		logger.Error("GetLogsForPageHandler: Error fetching logs for page %d: %v", pageID, err)
-		http.Error(w, "Failed to retrieve logs for page: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve logs for page: "+err.Error())
 		return
 	}
 
@@ -232,13 +232,13 @@ func GetLogsForPageHandler(w http.ResponseWriter, r *http.Request) {
 func DeletePageHandler(w http.ResponseWriter, r *http.Request) {
 	pageIDStr := chi.URLParam(r, "page_id") // Get page_id from URL path
 	if pageIDStr == "" {
-		http.Error(w, "page_id path parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "page_id path parameter is required")
 		return
 	}
 
 	pageID, err := strconv.ParseInt(pageIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid page_id in path: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid page_id in path: "+err.Error())
 		return
 	}
 
@@ -246,7 +246,7 @@ func DeletePageHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// Check if the error is because the page was not found, though DeletePage currently doesn't distinguish
 		// For now, any error from DeletePage is treated as internal server error.
-		http.Error(w, "Failed to delete page: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete page: "+err.Error())
 		return
 	}
 
@@ -259,7 +259,7 @@ func DeletePageHandler(w http.ResponseWriter, r *http.Request) {
 func UpdatePagesOrderHandler(w http.ResponseWriter, r *http.Request) {
 	var pageOrders map[string]int // Expecting {"pageID_string": order_int}
 	if err := json.NewDecoder(r.Body).Decode(&pageOrders); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -268,14 +268,14 @@ func UpdatePagesOrderHandler(w http.ResponseWriter, r *http.Request) {
 	for pageIDStr, order := range pageOrders {
 		pageID, err := strconv.ParseInt(pageIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid page ID in payload: "+pageIDStr, http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid page ID in payload: "+pageIDStr)
 			return
 		}
 		orders[pageID] = order
 	}
 
 	if err := database.UpdatePagesOrder(orders); err != nil {
-		http.Error(w, "Failed to update page order: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update page order: "+err.Error())
 		return
 	}
 
@@ -287,12 +287,12 @@ func UpdatePagesOrderHandler(w http.ResponseWriter, r *http.Request) {
 func UpdatePageDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	pageIDStr := chi.URLParam(r, "page_id")
 	if pageIDStr == "" {
-		http.Error(w, "page_id path parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "page_id path parameter is required")
 		return
 	}
 	pageID, err := strconv.ParseInt(pageIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid page_id in path: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid page_id in path: "+err.Error())
 		return
 	}
 
@@ -302,20 +302,20 @@ func UpdatePageDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.Name == "" { // Name is typically required for a page
-		http.Error(w, "Page name cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Page name cannot be empty")
 		return
 	}
 
 	description := sql.NullString{String: req.Description, Valid: req.Description != ""}
 
 	if err := database.UpdatePageDetails(pageID, req.Name, description); err != nil {
-		http.Error(w, "Failed to update page details: "+err.Error(), http.StatusInternalServerError) // Ensure this line is complete
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update page details: "+err.Error()) // Ensure this line is complete
 		return
 	}
 
@@ -3,11 +3,13 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -37,7 +39,7 @@ func AnalyzeTargetForParameterizedURLsHandler(w http.ResponseWriter, r *http.Req
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("AnalyzeTargetForParameterizedURLsHandler: Invalid target_id: %v", err)
-		http.Error(w, "Invalid target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
 		return
 	}
 
@@ -53,11 +55,12 @@ func AnalyzeTargetForParameterizedURLsHandler(w http.ResponseWriter, r *http.Req
 	})
 	if err != nil {
 		logger.Error("AnalyzeTargetForParameterizedURLsHandler: Error fetching logs for target %d: %v", targetID, err)
-		http.Error(w, "Failed to fetch logs for analysis", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to fetch logs for analysis")
 		return
 	}
 
 	var processedCount, newEntriesCount int
+	var endpointURLs []string
 	for _, log := range logs {
 		if !log.RequestURL.Valid || !strings.Contains(log.RequestURL.String, "?") {
 			continue // Skip URLs without query parameters
@@ -89,17 +92,25 @@ func AnalyzeTargetForParameterizedURLsHandler(w http.ResponseWriter, r *http.Req
 			// Continue processing other logs
 		} else {
 			processedCount++
+			endpointURLs = append(endpointURLs, log.RequestURL.String)
 			if created {
 				newEntriesCount++
 			}
 		}
 	}
 
+	// Count the endpoints once per canonical URL, however their query strings were ordered or tagged
+	newCanonicalCount, err := core.RecordTrafficURLs(targetID, endpointURLs)
+	if err != nil {
+		logger.Error("AnalyzeTargetForParameterizedURLsHandler: Error recording canonical URLs for target %d: %v", targetID, err)
+	}
+
 	response := map[string]interface{}{
 		"message":                      "Parameter analysis completed.",
 		"total_logs_scanned":           len(logs),
 		"parameterized_urls_processed": processedCount,
 		"new_unique_entries_found":     newEntriesCount,
+		"new_canonical_urls":           newCanonicalCount,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -114,7 +125,7 @@ func GetParameterizedURLsHandler(w http.ResponseWriter, r *http.Request) {
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil || targetID == 0 {
 		logger.Error("GetParameterizedURLsHandler: Invalid or missing target_id: %v", err)
-		http.Error(w, "Invalid or missing target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid or missing target_id")
 		return
 	}
 	params.TargetID = targetID
@@ -132,11 +143,12 @@ func GetParameterizedURLsHandler(w http.ResponseWriter, r *http.Request) {
 	params.RequestMethod = r.URL.Query().Get("request_method")
 	params.PathSearch = r.URL.Query().Get("path_search")
 	params.ParamKeysSearch = r.URL.Query().Get("param_keys_search")
+	params.Source = r.URL.Query().Get("source")
 
 	urls, totalRecords, err := database.GetParameterizedURLs(params)
 	if err != nil {
 		logger.Error("GetParameterizedURLsHandler: Error fetching parameterized URLs for target %d: %v", params.TargetID, err)
-		http.Error(w, "Failed to retrieve parameterized URLs", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve parameterized URLs")
 		return
 	}
 
@@ -151,3 +163,135 @@ func GetParameterizedURLsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetRedirectParamCandidatesHandler lists the parameters of a target's parameter inventory that
+// may take a URL the server redirects to or fetches, most confident first. ?host= limits the
+// list to one host.
+func GetRedirectParamCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	candidates, err := core.FindRedirectParamCandidates(targetID, r.URL.Query().Get("host"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetRedirectParamCandidatesHandler: Error scanning parameters of target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to scan parameters")
+		return
+	}
+	if candidates == nil {
+		candidates = []models.RedirectParamCandidate{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candidates)
+}
+
+// StartRedirectParamScanHandler starts a job filing a target's redirect and SSRF parameter
+// candidates as Draft findings, optionally testing redirect candidates with the marker domain
+// first. Progress is available from the jobs API.
+func StartRedirectParamScanHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.RedirectParamScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartRedirectParamScan(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "invalid min_confidence"), strings.Contains(msg, "no redirect or SSRF"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("StartRedirectParamScanHandler: Error starting scan for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start redirect parameter scan")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// StartParamMiningHandler starts a job probing a target's captured endpoints with batches of
+// candidate parameter names. Parameters that are reflected or change the response are added to the
+// parameter inventory with source 'param_mining' and a confidence level. The body is optional.
+func StartParamMiningHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.ParamMiningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartParamMining(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "invalid"), strings.HasPrefix(msg, "no "):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("StartParamMiningHandler: Error starting parameter mining for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start parameter mining")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GenerateEndpointRequestsHandler generates a modifier task or an ffuf command for each endpoint of
+// the target's parameter inventory (see models.RequestGenerationRequest).
+func GenerateEndpointRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.RequestGenerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	result, err := core.GenerateEndpointRequests(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "invalid"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already exists"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("GenerateEndpointRequestsHandler: Error generating requests for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to generate requests")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if result.Format == models.RequestGenerationModifier && !req.DryRun && result.Generated > 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
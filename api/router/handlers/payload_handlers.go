@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ExpandPayloadsHandler expands the payload placeholders ({{oob}}, {{random}}, {{timestamp}},
+// {{target.domain}}) and variables of a list of payloads, or of a stored wordlist, for a fuzzer
+// that sends them itself. Each payload gets its own values, which are recorded for correlation.
+func ExpandPayloadsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.PayloadExpandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	result, err := core.ExpandPayloads(targetID, req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		case strings.HasPrefix(err.Error(), "invalid"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		default:
+			logger.Error("ExpandPayloadsHandler: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to expand payloads")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// FindPayloadExpansionsHandler looks up the values generated for payload placeholders, latest
+// first. ?q= takes the name of an out-of-band interaction (any name containing an {{oob}}
+// identifier) or a value; ?target_id=, ?log_id= and ?limit= (default 100) narrow the results.
+func FindPayloadExpansionsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.PayloadExpansionFilters{Query: q.Get("q")}
+	for param, dest := range map[string]*int64{"target_id": &filters.TargetID, "log_id": &filters.LogID} {
+		if value := q.Get(param); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid "+param)
+				return
+			}
+			*dest = id
+		}
+	}
+	filters.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	expansions, err := core.FindPayloadExpansions(filters)
+	if err != nil {
+		logger.Error("FindPayloadExpansionsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to look up payload expansions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(expansions)
+}
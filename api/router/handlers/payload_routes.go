@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterPayloadRoutes sets up the routes of the payload placeholders: expanding payload lists
+// and looking up the values generated for requests.
+func RegisterPayloadRoutes(r chi.Router) {
+	r.Post("/targets/{target_id}/payloads/expand", ExpandPayloadsHandler)
+	r.Get("/payload-expansions", FindPayloadExpansionsHandler) // ?q=<oob interaction or value>&target_id=&log_id=&limit=
+}
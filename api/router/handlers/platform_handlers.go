@@ -20,9 +20,7 @@ func createPlatform(w http.ResponseWriter, r *http.Request) {
 	var p models.Platform
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
 		logger.Error("createPlatform: Error decoding request body: %v", err)
-		// w.Header().Set("Content-Type", "application/json") // http.Error sets this, or json.NewEncoder does
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body: " + err.Error()})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -30,9 +28,7 @@ func createPlatform(w http.ResponseWriter, r *http.Request) {
 	p.Name = strings.TrimSpace(p.Name)
 	if p.Name == "" {
 		logger.Error("createPlatform: Platform name is required and was empty")
-		// w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Platform name is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Platform name is required")
 		return
 	}
 
@@ -42,14 +38,10 @@ func createPlatform(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(strings.ToLower(err.Error()), "unique constraint failed") ||
 			strings.Contains(strings.ToLower(err.Error()), "already exists") { // More robust check
 			logger.Error("createPlatform: Platform name '%s' conflicts: %v", p.Name, err)
-			// w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Platform name '%s' already exists.", p.Name)})
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, fmt.Sprintf("Platform name '%s' already exists.", p.Name))
 		} else {
 			logger.Error("createPlatform: Error inserting platform '%s': %v", p.Name, err)
-			// w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error while inserting platform"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error while inserting platform")
 		}
 		return
 	}
@@ -67,9 +59,7 @@ func getPlatforms(w http.ResponseWriter, r *http.Request) {
 	platforms, err := database.GetAllPlatforms()
 	if err != nil {
 		logger.Error("getPlatforms: Error querying platforms: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -86,7 +76,7 @@ func GetPlatformByIDChiHandler(w http.ResponseWriter, r *http.Request) {
 	platformID, err := strconv.ParseInt(platformIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetPlatformByIDChiHandler: Invalid platform ID format '%s': %v", platformIDStr, err)
-		http.Error(w, "Invalid platform ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid platform ID")
 		return
 	}
 	getPlatformByID(w, r, platformID)
@@ -99,14 +89,10 @@ func getPlatformByID(w http.ResponseWriter, r *http.Request, platformID int64) {
 		// Check if the error message indicates "not found" as our db function now returns a specific error for that
 		if strings.Contains(err.Error(), "not found") || errors.Is(err, sql.ErrNoRows) {
 			logger.Error("getPlatformByID: Platform with ID %d not found", platformID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Platform with ID %d not found", platformID)})
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Platform with ID %d not found", platformID))
 		} else {
 			logger.Error("getPlatformByID: Error querying platform ID %d: %v", platformID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -124,7 +110,7 @@ func UpdatePlatformChiHandler(w http.ResponseWriter, r *http.Request) {
 	platformID, err := strconv.ParseInt(platformIDStr, 10, 64)
 	if err != nil {
 		logger.Error("UpdatePlatformChiHandler: Invalid platform ID format '%s': %v", platformIDStr, err)
-		http.Error(w, "Invalid platform ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid platform ID")
 		return
 	}
 	updatePlatform(w, r, platformID)
@@ -135,9 +121,7 @@ func updatePlatform(w http.ResponseWriter, r *http.Request, platformID int64) {
 	var p models.Platform
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
 		logger.Error("updatePlatform: Error decoding request body for ID %d: %v", platformID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body: " + err.Error()})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -145,9 +129,7 @@ func updatePlatform(w http.ResponseWriter, r *http.Request, platformID int64) {
 	p.Name = strings.TrimSpace(p.Name)
 	if p.Name == "" {
 		logger.Error("updatePlatform: Platform name is required for update (ID: %d)", platformID)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Platform name is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Platform name is required")
 		return
 	}
 
@@ -155,20 +137,14 @@ func updatePlatform(w http.ResponseWriter, r *http.Request, platformID int64) {
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("updatePlatform: Platform with ID %d not found for update", platformID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Platform with ID %d not found", platformID)})
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Platform with ID %d not found", platformID))
 		} else if strings.Contains(strings.ToLower(err.Error()), "unique constraint failed") ||
 			strings.Contains(strings.ToLower(err.Error()), "already exists") {
 			logger.Error("updatePlatform: Update for ID %d failed due to UNIQUE constraint (name '%s'): %v", platformID, p.Name, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Update failed because the name '%s' already exists.", p.Name)})
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, fmt.Sprintf("Update failed because the name '%s' already exists.", p.Name))
 		} else {
 			logger.Error("updatePlatform: Error executing update for ID %d: %v", platformID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error while updating platform"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error while updating platform")
 		}
 		return
 	}
@@ -187,7 +163,7 @@ func DeletePlatformChiHandler(w http.ResponseWriter, r *http.Request) {
 	platformID, err := strconv.ParseInt(platformIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeletePlatformChiHandler: Invalid platform ID format '%s': %v", platformIDStr, err)
-		http.Error(w, "Invalid platform ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid platform ID")
 		return
 	}
 	deletePlatform(w, r, platformID)
@@ -200,10 +176,10 @@ func deletePlatform(w http.ResponseWriter, r *http.Request, platformID int64) {
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("deletePlatform: Platform with ID %d not found for deletion", platformID)
-			http.Error(w, fmt.Sprintf("Platform with ID %d not found", platformID), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Platform with ID %d not found", platformID))
 		} else {
 			logger.Error("deletePlatform: Error deleting platform ID %d: %v", platformID, err)
-			http.Error(w, "Internal server error during delete", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during delete")
 		}
 		return
 	}
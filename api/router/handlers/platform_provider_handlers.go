@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writePlatformProviderError maps platform provider errors to HTTP status codes.
+func writePlatformProviderError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "not configured"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "auth token captured"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+	}
+}
+
+// ListPlatformProvidersHandler returns the status of every platform provider configured in the proxy.
+func ListPlatformProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	statuses := []models.PlatformProviderStatus{}
+	for _, p := range core.PlatformProviders() {
+		statuses = append(statuses, p.Status())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// GetPlatformProviderHandler returns the status of one platform provider.
+func GetPlatformProviderHandler(w http.ResponseWriter, r *http.Request) {
+	provider, err := core.GetPlatformProvider(chi.URLParam(r, "name"))
+	if err != nil {
+		writePlatformProviderError(w, r, "GetPlatformProviderHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(provider.Status())
+}
+
+// FetchPlatformAnalyticsHandler fetches the analytics of one program from the platform right away,
+// using the credentials the provider captured from proxied traffic, and returns the provider status.
+func FetchPlatformAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	provider, err := core.GetPlatformProvider(chi.URLParam(r, "name"))
+	if err != nil {
+		writePlatformProviderError(w, r, "FetchPlatformAnalyticsHandler", err)
+		return
+	}
+	if err := provider.FetchAnalytics(r.Context(), chi.URLParam(r, "externalID")); err != nil {
+		writePlatformProviderError(w, r, "FetchPlatformAnalyticsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(provider.Status())
+}
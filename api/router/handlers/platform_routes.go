@@ -12,4 +12,9 @@ func RegisterPlatformRoutes(r chi.Router) {
 	r.Get("/platforms/{platformID}", GetPlatformByIDChiHandler)
 	r.Put("/platforms/{platformID}", UpdatePlatformChiHandler)
 	r.Delete("/platforms/{platformID}", DeletePlatformChiHandler)
+
+	// Platform providers (traffic-driven integrations such as Synack): status and manual analytics fetch
+	r.Get("/platform-providers", ListPlatformProvidersHandler)
+	r.Get("/platform-providers/{name}", GetPlatformProviderHandler)
+	r.Post("/platform-providers/{name}/analytics/{externalID}", FetchPlatformAnalyticsHandler)
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListPluginsHandler rescans plugins.dir and lists the plugins found with their state. Plugins
+// with an invalid manifest are listed with the reason in error.
+func ListPluginsHandler(w http.ResponseWriter, r *http.Request) error {
+	plugins, err := core.ListPlugins()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, plugins)
+}
+
+// GetPluginHandler returns a plugin.
+func GetPluginHandler(w http.ResponseWriter, r *http.Request) error {
+	plugin, err := core.GetPlugin(chi.URLParam(r, "plugin"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, plugin)
+}
+
+// EnablePluginHandler turns a plugin on. It stays on until disabled or until its plugin.json
+// changes.
+func EnablePluginHandler(w http.ResponseWriter, r *http.Request) error {
+	return setPluginEnabled(w, r, true)
+}
+
+// DisablePluginHandler turns a plugin off.
+func DisablePluginHandler(w http.ResponseWriter, r *http.Request) error {
+	return setPluginEnabled(w, r, false)
+}
+
+func setPluginEnabled(w http.ResponseWriter, r *http.Request, enabled bool) error {
+	plugin, err := core.SetPluginEnabled(chi.URLParam(r, "plugin"), enabled)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, plugin)
+}
+
+// RunReconPluginHandler starts a job running a recon plugin for a target; the domains it finds
+// are imported with source plugin:<name>.
+func RunReconPluginHandler(w http.ResponseWriter, r *http.Request) error {
+	var req models.PluginReconRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	if req.TargetID == 0 {
+		return models.InvalidRequestError("target_id is required")
+	}
+	job, err := core.StartPluginRecon(chi.URLParam(r, "plugin"), req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterPluginRoutes sets up the routes of the plugins found in plugins.dir.
+func RegisterPluginRoutes(r chi.Router) {
+	r.Get("/plugins", handle(ListPluginsHandler))
+	r.Get("/plugins/{plugin}", handle(GetPluginHandler))
+	r.Post("/plugins/{plugin}/enable", handle(EnablePluginHandler))
+	r.Post("/plugins/{plugin}/disable", handle(DisablePluginHandler))
+	r.Post("/plugins/{plugin}/recon", handle(RunReconPluginHandler))
+}
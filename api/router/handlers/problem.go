@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProblemContentType is the content type of API error responses.
+const ProblemContentType = "application/problem+json"
+
+// apiHandler is a handler returning its error instead of writing it; wrap it with handle.
+type apiHandler func(w http.ResponseWriter, r *http.Request) error
+
+// handle adapts an apiHandler to http.HandlerFunc. A returned error is written as problem details
+// with the status of its code (see models.ErrorCode); internal errors are logged and only their
+// message, or a generic one, reaches the caller.
+func handle(h apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			writeError(w, r, err)
+		}
+	}
+}
+
+// writeError writes err as problem details.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code := models.ErrorCode(err)
+	detail := err.Error()
+	if code == models.ErrCodeInternal {
+		route := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		logger.Error("API: %s %s: %v", r.Method, route, err)
+		detail = "Internal server error"
+		var appErr *models.AppError
+		if errors.As(err, &appErr) && appErr.Message != "" {
+			detail = appErr.Message
+		}
+	}
+	WriteProblem(w, r, models.ErrorCodeStatus(code), code, detail)
+}
+
+// WriteProblem writes an error response as problem details (RFC 7807). code may be empty to derive
+// it from status. Members of extensions are added to the problem unless it defines them itself.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, extensions ...map[string]interface{}) {
+	problem := models.NewProblemDetails(status, code, detail, "/api"+r.URL.Path)
+	var body interface{} = problem
+	if len(extensions) > 0 {
+		members := map[string]interface{}{}
+		for _, ext := range extensions {
+			for name, value := range ext {
+				members[name] = value
+			}
+		}
+		encoded, _ := json.Marshal(problem)
+		json.Unmarshal(encoded, &members)
+		body = members
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// pathID parses the path parameter name as an ID, e.g. pathID(r, "target_id", "target ID").
+func pathID(r *http.Request, name, label string) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil {
+		return 0, models.InvalidRequestError("Invalid %s", label)
+	}
+	return id, nil
+}
+
+// decodeJSONBody decodes the JSON request body into v.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return models.InvalidRequestError("Invalid request body: %v", err)
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response with status. It returns nil so an apiHandler can end with
+// it: once the status is written, a failing write cannot be reported to the caller.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+	return nil
+}
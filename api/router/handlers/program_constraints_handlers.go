@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// GetTargetProgramConstraintsHandler returns the program constraints of a target (empty when none
+// are set).
+func GetTargetProgramConstraintsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	constraints, err := core.GetTargetProgramConstraints(targetID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, constraints)
+}
+
+// PutTargetProgramConstraintsHandler sets the program constraints of a target: the maximum request
+// rate, banned paths and required headers the proxy enforces on the toolkit's requests.
+func PutTargetProgramConstraintsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	var constraints models.TargetProgramConstraints
+	if err := decodeJSONBody(r, &constraints); err != nil {
+		return err
+	}
+	constraints.TargetID = targetID
+	saved, err := core.SaveTargetProgramConstraints(constraints)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, saved)
+}
+
+// DeleteTargetProgramConstraintsHandler removes the program constraints of a target.
+func DeleteTargetProgramConstraintsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	if err := core.DeleteTargetProgramConstraints(targetID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ListProgramConstraintEventsHandler returns the requests the proxy blocked, changed or delayed to
+// keep to a target's program constraints, newest first. Query parameters: action, limit (default 100).
+func ListProgramConstraintEventsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return models.InvalidRequestError("Invalid limit")
+		}
+	}
+	events, err := core.ListProgramConstraintEvents(targetID, r.URL.Query().Get("action"), limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, events)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterProgramConstraintRoutes sets up the routes of the per-target program constraints the
+// proxy enforces on toolkit requests.
+func RegisterProgramConstraintRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/program-constraints", handle(GetTargetProgramConstraintsHandler))
+	r.Put("/targets/{target_id}/program-constraints", handle(PutTargetProgramConstraintsHandler))
+	r.Delete("/targets/{target_id}/program-constraints", handle(DeleteTargetProgramConstraintsHandler))
+	r.Get("/targets/{target_id}/program-constraints/events", handle(ListProgramConstraintEventsHandler))
+}
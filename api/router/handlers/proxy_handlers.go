@@ -3,15 +3,209 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"toolkit/core" // Assuming your proxy core logic is here
 	"toolkit/logger"
+	"toolkit/models"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// RegisterProxySendRoutes registers the API routes related to sending requests via the proxy.
+// RegisterProxySendRoutes registers the API routes related to sending requests via the proxy, to
+// its CA certificate, to intercepting mobile devices, to its performance mode and to its
+// target-bound listeners.
 func RegisterProxySendRoutes(r chi.Router) {
 	r.Post("/proxy/send-requests", SendPathsToProxyHandler)
+	r.Get("/proxy/ca.crt", GetProxyCACertificateHandler)
+	r.Get("/proxy/mobileconfig", GetProxyMobileConfigHandler)
+	r.Get("/proxy/tls-failures", ListTLSHandshakeFailuresHandler)
+	r.Delete("/proxy/tls-failures/{host}", DeleteTLSHandshakeFailuresHandler)
+	r.Get("/proxy/exclusions", ListProxyExclusionRuleStatsHandler)
+	r.Post("/proxy/exclusions/test", TestProxyExclusionRulesHandler)
+	r.Get("/proxy/performance", GetProxyPerformanceHandler)
+	r.Put("/proxy/performance", UpdateProxyPerformanceHandler)
+	r.Get("/proxy/listeners", ListProxyListenersHandler)
+	r.Post("/proxy/listeners", CreateProxyListenerHandler)
+	r.Put("/proxy/listeners/{port}", UpdateProxyListenerHandler)
+	r.Delete("/proxy/listeners/{port}", DeleteProxyListenerHandler)
+}
+
+// GetProxyCACertificateHandler downloads the proxy's CA certificate for installation on a device.
+// Query parameter: format (pem, der; default pem).
+func GetProxyCACertificateHandler(w http.ResponseWriter, r *http.Request) {
+	core.WriteCACertificate(w, r.URL.Query().Get("format"))
+}
+
+// GetProxyMobileConfigHandler downloads an iOS configuration profile installing the proxy CA and,
+// with ssid, the proxy settings of that Wi-Fi network.
+// Query parameters: ssid, host and port (proxy address devices use; default the API host and the
+// configured proxy port).
+func GetProxyMobileConfigHandler(w http.ResponseWriter, r *http.Request) {
+	core.WriteMobileConfig(w, r)
+}
+
+// ListTLSHandshakeFailuresHandler lists the hosts whose clients failed the TLS handshake with the
+// proxy certificate, with certificate pinning hints.
+// Query parameter: likely_pinned (true to list only hosts that never completed a handshake).
+func ListTLSHandshakeFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	failures, err := core.ListTLSHandshakeFailures(r.URL.Query().Get("likely_pinned") == "true")
+	if err != nil {
+		logger.Error("ListTLSHandshakeFailuresHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to list TLS handshake failures")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(failures)
+}
+
+// DeleteTLSHandshakeFailuresHandler forgets the handshake failures of a host, e.g. after pinning
+// was bypassed on the device.
+func DeleteTLSHandshakeFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	host := chi.URLParam(r, "host")
+	if err := core.ResetTLSHandshakeFailures(host); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("DeleteTLSHandshakeFailuresHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete TLS handshake failures")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListProxyExclusionRuleStatsHandler lists the global proxy exclusion rules with how many requests
+// each one skipped, most hits first.
+func ListProxyExclusionRuleStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := core.ListProxyExclusionRuleStats()
+	if err != nil {
+		logger.Error("ListProxyExclusionRuleStatsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to list proxy exclusion rule hits")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// TestProxyExclusionRulesHandler evaluates a URL against the global proxy exclusion rules (or the
+// rules in the body) and reports which ones match, without sending a request.
+func TestProxyExclusionRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ProxyExclusionTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	result, err := core.TestProxyExclusionRules(req.URL, req.Rules)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid url") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		logger.Error("TestProxyExclusionRulesHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to test proxy exclusion rules")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetProxyPerformanceHandler returns whether the proxy logs only a sample of out-of-scope and
+// static-asset traffic, and how many requests were sampled out.
+func GetProxyPerformanceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetProxyPerformanceStatus())
+}
+
+// UpdateProxyPerformanceHandler turns the proxy's performance mode on or off and sets its sample
+// rate without a restart. Body: {"enabled": true, "sample_rate": 20}; omitted fields are kept.
+func UpdateProxyPerformanceHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ProxyPerformanceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	status, err := core.SetProxyPerformanceMode(req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		logger.Error("UpdateProxyPerformanceHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update the proxy performance mode")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// writeProxyListenerError maps proxy listener errors to HTTP statuses.
+func writeProxyListenerError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.HasPrefix(msg, "invalid"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "already exists"), strings.Contains(msg, "already in use"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to manage the proxy listener")
+	}
+}
+
+// ListProxyListenersHandler lists the proxy listeners bound to targets and whether they run.
+func ListProxyListenersHandler(w http.ResponseWriter, r *http.Request) {
+	listeners, err := core.ListProxyListeners()
+	if err != nil {
+		writeProxyListenerError(w, r, "ListProxyListenersHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listeners)
+}
+
+// CreateProxyListenerHandler adds a proxy port bound to a target, started right away when the
+// proxy runs in the API's process. Body: {"port": "8779", "target_id": 2}.
+func CreateProxyListenerHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ProxyListenerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	listener, err := core.AddProxyListener(req)
+	if err != nil {
+		writeProxyListenerError(w, r, "CreateProxyListenerHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(listener)
+}
+
+// UpdateProxyListenerHandler binds a proxy listener to another target. Body: {"target_id": 3}.
+func UpdateProxyListenerHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ProxyListenerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	listener, err := core.SetProxyListenerTarget(chi.URLParam(r, "port"), req.TargetID)
+	if err != nil {
+		writeProxyListenerError(w, r, "UpdateProxyListenerHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listener)
+}
+
+// DeleteProxyListenerHandler stops a proxy listener and removes it.
+func DeleteProxyListenerHandler(w http.ResponseWriter, r *http.Request) {
+	if err := core.RemoveProxyListener(chi.URLParam(r, "port")); err != nil {
+		writeProxyListenerError(w, r, "DeleteProxyListenerHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // SendPathsRequest defines the expected structure for the request body
@@ -26,26 +220,26 @@ type SendPathsRequest struct {
 func SendPathsToProxyHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("SendPathsToProxyHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req SendPathsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("SendPathsToProxyHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.TargetID == 0 {
 		logger.Error("SendPathsToProxyHandler: TargetID is required")
-		http.Error(w, "TargetID is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "TargetID is required")
 		return
 	}
 	if len(req.URLs) == 0 {
 		logger.Error("SendPathsToProxyHandler: No URLs provided")
-		http.Error(w, "No URLs provided to send", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "No URLs provided to send")
 		return
 	}
 
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// ListProxyScriptsHandler lists the proxy scripts in the order they run, with their run and failure
+// counts since the toolkit started.
+func ListProxyScriptsHandler(w http.ResponseWriter, r *http.Request) error {
+	scripts, err := core.ListProxyScripts()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, scripts)
+}
+
+// GetProxyScriptHandler returns a proxy script.
+func GetProxyScriptHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "script_id", "script ID")
+	if err != nil {
+		return err
+	}
+	script, err := core.GetProxyScript(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, script)
+}
+
+// CreateProxyScriptHandler adds a script the proxy runs on request and/or response events. It gets
+// each message as JSON on stdin and can change, tag or drop it by writing JSON to stdout.
+func CreateProxyScriptHandler(w http.ResponseWriter, r *http.Request) error {
+	var script models.ProxyScript
+	if err := decodeJSONBody(r, &script); err != nil {
+		return err
+	}
+	created, err := core.CreateProxyScript(script)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, created)
+}
+
+// UpdateProxyScriptHandler replaces the settings of a proxy script.
+func UpdateProxyScriptHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "script_id", "script ID")
+	if err != nil {
+		return err
+	}
+	var script models.ProxyScript
+	if err := decodeJSONBody(r, &script); err != nil {
+		return err
+	}
+	script.ID = id
+	updated, err := core.UpdateProxyScript(script)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteProxyScriptHandler removes a proxy script.
+func DeleteProxyScriptHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "script_id", "script ID")
+	if err != nil {
+		return err
+	}
+	if err := core.DeleteProxyScript(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// TestProxyScriptHandler runs a proxy script against a logged request and response and returns
+// what the script got and returned, without sending anything. Body: {"log_id": 12, "event": "response"}.
+func TestProxyScriptHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "script_id", "script ID")
+	if err != nil {
+		return err
+	}
+	var req models.ProxyScriptTestRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	result, err := core.TestProxyScript(id, req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
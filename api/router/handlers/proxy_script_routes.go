@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterProxyScriptRoutes sets up the routes of the user scripts the proxy runs on request and
+// response events.
+func RegisterProxyScriptRoutes(r chi.Router) {
+	r.Get("/proxy/scripts", handle(ListProxyScriptsHandler))
+	r.Post("/proxy/scripts", handle(CreateProxyScriptHandler))
+	r.Get("/proxy/scripts/{script_id}", handle(GetProxyScriptHandler))
+	r.Put("/proxy/scripts/{script_id}", handle(UpdateProxyScriptHandler))
+	r.Delete("/proxy/scripts/{script_id}", handle(DeleteProxyScriptHandler))
+	r.Post("/proxy/scripts/{script_id}/test", handle(TestProxyScriptHandler))
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StartRateProfileHandler starts a job profiling the rate limiting and WAF of an endpoint's
+// host, given by url or by the log_id of a captured request. Progress is available from the
+// jobs API.
+func StartRateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.RateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartRateProfile(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.HasPrefix(msg, "invalid"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("StartRateProfileHandler: Error starting rate profile for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start rate profile")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetRateProfilesHandler lists the host rate profiles, of one target with ?target_id.
+func GetRateProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	var targetID int64
+	if raw := r.URL.Query().Get("target_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+			return
+		}
+		targetID = id
+	}
+	profiles, err := core.GetHostRateProfiles(targetID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetRateProfilesHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load rate profiles")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// GetRateProfileHandler returns the rate profile of a host.
+func GetRateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	profile, err := core.GetHostRateProfile(chi.URLParam(r, "host"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetRateProfileHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load rate profile")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// SetHostSafeRateHandler sets the safe rate of a host by hand; a safe_rps of 0 clears it. The
+// rate applies to toolkit requests right away.
+func SetHostSafeRateHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.SetSafeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	profile, err := core.SetHostSafeRate(chi.URLParam(r, "host"), req.SafeRPS)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		logger.Error("SetHostSafeRateHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to set safe rate")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// DeleteRateProfileHandler removes the rate profile and safe rate of a host.
+func DeleteRateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if err := core.DeleteHostRateProfile(chi.URLParam(r, "host")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("DeleteRateProfileHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete rate profile")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
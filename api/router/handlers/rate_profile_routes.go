@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterRateProfileRoutes sets up the routes of the rate-limit and WAF profiler and the
+// per-host safe rates.
+func RegisterRateProfileRoutes(r chi.Router) {
+	r.Post("/targets/{target_id}/rate-profiles", StartRateProfileHandler)
+	r.Get("/rate-profiles", GetRateProfilesHandler)
+	r.Get("/rate-profiles/{host}", GetRateProfileHandler)
+	r.Put("/rate-profiles/{host}", SetHostSafeRateHandler)
+	r.Delete("/rate-profiles/{host}", DeleteRateProfileHandler)
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterRedactionRoutes sets up the routes of the per-target redaction rules.
+func RegisterRedactionRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/redaction", GetTargetRedactionHandler)
+	r.Put("/targets/{target_id}/redaction", PutTargetRedactionHandler)
+	r.Delete("/targets/{target_id}/redaction", DeleteTargetRedactionHandler)
+	r.Post("/targets/{target_id}/redaction/apply", ApplyTargetRedactionHandler)
+}
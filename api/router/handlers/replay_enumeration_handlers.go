@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// StartReplayEnumerationHandler generates requests from a URL template and sends them as a
+// replay batch per replay session. Body: {"url_template": "/api/users/{id}/orders/{orderId}",
+// "values": {"orderId": ["7", "8"]}, "ranges": {"id": {"from": 1, "to": 20}}, "source_log_id": 12}.
+// With dry_run, the generated requests are returned without sending them.
+func StartReplayEnumerationHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	var req models.ReplayEnumerationRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	enumeration, err := core.StartReplayEnumeration(targetID, req)
+	if err != nil {
+		return err
+	}
+	if req.DryRun {
+		return writeJSON(w, http.StatusOK, enumeration)
+	}
+	return writeJSON(w, http.StatusAccepted, enumeration)
+}
+
+// GetReplayEnumerationsHandler lists the replay enumerations of a target, newest first.
+func GetReplayEnumerationsHandler(w http.ResponseWriter, r *http.Request) error {
+	targetID, err := pathID(r, "target_id", "target ID")
+	if err != nil {
+		return err
+	}
+	enumerations, err := core.GetReplayEnumerations(targetID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, enumerations)
+}
+
+// GetReplayEnumerationReportHandler returns the answers to a replay enumeration's requests,
+// grouped by request across sessions, requests more than one session could read first.
+func GetReplayEnumerationReportHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "enumeration_id", "enumeration ID")
+	if err != nil {
+		return err
+	}
+	report, err := core.GetReplayEnumerationReport(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetReplaySessionsHandler lists stored replay sessions, optionally filtered by target_id.
+func GetReplaySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	sessions, err := database.GetReplaySessions(targetID)
+	if err != nil {
+		logger.Error("GetReplaySessionsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve replay sessions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// CreateReplaySessionHandler stores a replay session from explicit cookies and headers.
+func CreateReplaySessionHandler(w http.ResponseWriter, r *http.Request) {
+	var session models.ReplaySession
+	if err := json.NewDecoder(r.Body).Decode(&session); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	id, err := database.CreateReplaySession(session)
+	if err != nil {
+		logger.Error("CreateReplaySessionHandler: %v", err)
+		if strings.Contains(err.Error(), "required") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create replay session")
+		}
+		return
+	}
+	created, err := database.GetReplaySessionByID(id)
+	if err != nil {
+		logger.Error("CreateReplaySessionHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve created replay session")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// CreateReplaySessionFromLogHandler builds a replay session from the cookies and auth headers of a logged request.
+func CreateReplaySessionFromLogHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateReplaySessionFromLogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if req.HTTPTrafficLogID == 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "http_traffic_log_id is required")
+		return
+	}
+
+	session, err := database.CreateReplaySessionFromLog(req.HTTPTrafficLogID, req.Name)
+	if err != nil {
+		logger.Error("CreateReplaySessionFromLogHandler: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create replay session")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// DeleteReplaySessionHandler removes a stored replay session.
+func DeleteReplaySessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "session_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid session ID")
+		return
+	}
+	if err := database.DeleteReplaySession(sessionID); err != nil {
+		logger.Error("DeleteReplaySessionHandler: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete replay session")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetReplayBatchesHandler lists replay batches, optionally filtered by target_id and/or page_id
+// (the flow replays of a Page Sitemap page).
+func GetReplayBatchesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	pageID, _ := strconv.ParseInt(r.URL.Query().Get("page_id"), 10, 64)
+	batches, err := database.GetReplayBatches(targetID, pageID)
+	if err != nil {
+		logger.Error("GetReplayBatchesHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve replay batches")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batches)
+}
+
+// CreateReplayBatchHandler enqueues the selected log entries and/or discovered URLs for replay
+// through the proxy and starts the batch in the background.
+func CreateReplayBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateReplayBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if req.SessionID != 0 {
+		if _, err := database.GetReplaySessionByID(req.SessionID); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+	}
+
+	batch, err := database.CreateReplayBatch(req)
+	if err != nil {
+		logger.Error("CreateReplayBatchHandler: %v", err)
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "no log_ids") ||
+			strings.HasPrefix(err.Error(), "invalid") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create replay batch")
+		}
+		return
+	}
+
+	if err := core.StartReplayBatch(batch.ID); err != nil {
+		logger.Error("CreateReplayBatchHandler: Failed to start batch %d: %v", batch.ID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start replay batch: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetReplayBatchHandler returns a replay batch with the status of each item.
+func GetReplayBatchHandler(w http.ResponseWriter, r *http.Request) {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batch_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid batch ID")
+		return
+	}
+	detail, err := database.GetReplayBatchDetail(batchID)
+	if err != nil {
+		logger.Error("GetReplayBatchHandler: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve replay batch")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// GetReplayBatchLogsHandler returns the traffic log entries recorded for a replay batch.
+func GetReplayBatchLogsHandler(w http.ResponseWriter, r *http.Request) {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batch_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid batch ID")
+		return
+	}
+	logs, err := database.GetHTTPTrafficLogsByReplayBatch(batchID)
+	if err != nil {
+		logger.Error("GetReplayBatchLogsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve replay batch logs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logs)
+}
+
+// CancelReplayBatchHandler stops a running replay batch.
+func CancelReplayBatchHandler(w http.ResponseWriter, r *http.Request) {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batch_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid batch ID")
+		return
+	}
+	if !core.CancelReplayBatch(batchID) {
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, "Replay batch is not running")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Cancellation requested"})
+}
+
+// ReplayPageHandler replays the logs of a recorded Page Sitemap page in the order they were
+// recorded, as a replay batch linked to the page. Each replayed request is logged with a link to
+// the log entry it replays, so a multi-step flow can be re-tested and compared after changes.
+func ReplayPageHandler(w http.ResponseWriter, r *http.Request) {
+	pageID, err := strconv.ParseInt(chi.URLParam(r, "page_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid page_id in path")
+		return
+	}
+	var req models.ReplayPageRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	page, err := database.GetPageByID(pageID)
+	if err != nil {
+		logger.Error("ReplayPageHandler: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve page")
+		}
+		return
+	}
+	if req.SessionID != 0 {
+		if _, err := database.GetReplaySessionByID(req.SessionID); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	logIDs, err := database.GetLogIDsForPage(pageID)
+	if err != nil {
+		logger.Error("ReplayPageHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve page logs")
+		return
+	}
+	if len(logIDs) == 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Page has no recorded requests to replay (is it still recording?)")
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Flow replay: " + page.Name
+	}
+	batch, err := database.CreateReplayBatch(models.CreateReplayBatchRequest{
+		TargetID:       page.TargetID,
+		SessionID:      req.SessionID,
+		Name:           name,
+		LogIDs:         logIDs,
+		DelayMs:        req.DelayMs,
+		PageID:         pageID,
+		PreserveTiming: req.PreserveTiming,
+		CarryCookies:   req.CarryCookies == nil || *req.CarryCookies,
+	})
+	if err != nil {
+		logger.Error("ReplayPageHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create replay batch")
+		return
+	}
+	if err := core.StartReplayBatch(batch.ID); err != nil {
+		logger.Error("ReplayPageHandler: Failed to start batch %d: %v", batch.ID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start replay batch: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// StartReplayComparisonHandler replays captured requests of a target against another environment
+// (base_url), by default the latest request of each endpoint, and returns the started batch.
+func StartReplayComparisonHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.ReplayCompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	batch, err := core.StartReplayComparison(targetID, req)
+	if err != nil {
+		logger.Error("StartReplayComparisonHandler: %v", err)
+		switch {
+		case strings.HasPrefix(err.Error(), "target with ID"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		case strings.Contains(err.Error(), "not found") || strings.HasPrefix(err.Error(), "invalid") ||
+			strings.HasPrefix(err.Error(), "no captured requests"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		default:
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start replay comparison")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetReplayComparisonHandler compares the responses of a replay batch with the captured responses
+// it replays, per endpoint.
+func GetReplayComparisonHandler(w http.ResponseWriter, r *http.Request) {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batch_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid batch ID")
+		return
+	}
+	report, err := core.GetReplayComparison(batchID)
+	if err != nil {
+		logger.Error("GetReplayComparisonHandler: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to compare replay batch")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterReplayRoutes(r chi.Router) {
+	r.Get("/replay/sessions", GetReplaySessionsHandler)
+	r.Post("/replay/sessions", CreateReplaySessionHandler)
+	r.Post("/replay/sessions/from-log", CreateReplaySessionFromLogHandler) // Copies Cookie/Authorization from a logged request
+	r.Delete("/replay/sessions/{session_id}", DeleteReplaySessionHandler)
+
+	r.Get("/replay/batches", GetReplayBatchesHandler)
+	r.Post("/replay/batches", CreateReplayBatchHandler) // Enqueues and starts the batch
+	r.Get("/replay/batches/{batch_id}", GetReplayBatchHandler)
+	r.Get("/replay/batches/{batch_id}/logs", GetReplayBatchLogsHandler) // Log entries recorded for the batch
+	r.Post("/replay/batches/{batch_id}/cancel", CancelReplayBatchHandler)
+	r.Get("/replay/batches/{batch_id}/comparison", GetReplayComparisonHandler) // Replayed vs. captured responses per endpoint
+
+	r.Post("/targets/{target_id}/replay/compare", StartReplayComparisonHandler) // Replays captured endpoints against another base URL
+
+	// Requests generated from a URL template, sent once per replay session
+	r.Post("/targets/{target_id}/replay/enumerations", handle(StartReplayEnumerationHandler))
+	r.Get("/targets/{target_id}/replay/enumerations", handle(GetReplayEnumerationsHandler))
+	r.Get("/replay/enumerations/{enumeration_id}", handle(GetReplayEnumerationReportHandler)) // Answers grouped by request across sessions
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BaselineDomainsHandler starts a job probing random paths and clustering logged responses of the
+// given domains of a target (every domain with an httpx result when domain_ids is empty) to learn
+// their soft-404/wildcard pages.
+func BaselineDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.ResponseBaselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartResponseBaseline(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "no domains"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("BaselineDomainsHandler: Error starting response baseline for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start response baseline")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetDomainBaselineHandler returns the soft-404/wildcard signatures of a domain.
+func GetDomainBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	domainID, err := strconv.ParseInt(chi.URLParam(r, "domain_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain ID")
+		return
+	}
+	baseline, found, err := database.GetResponseBaseline(domainID)
+	if err != nil {
+		logger.Error("GetDomainBaselineHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load response baseline")
+		return
+	}
+	if !found {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("no response baseline for domain %d", domainID))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(baseline)
+}
+
+// GetDomainResponseClustersHandler clusters the logged responses of a domain into families of
+// near-identical pages.
+func GetDomainResponseClustersHandler(w http.ResponseWriter, r *http.Request) {
+	domainID, err := strconv.ParseInt(chi.URLParam(r, "domain_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid domain ID")
+		return
+	}
+	clusters, err := core.ClusterDomainResponses(domainID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetDomainResponseClustersHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to cluster responses")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clusters)
+}
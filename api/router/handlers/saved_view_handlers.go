@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// validateSavedViewRequest checks the view type, name and that only known list parameters are stored.
+func validateSavedViewRequest(req *models.SavedViewRequest) error {
+	allowed, ok := models.SavedViewFilterKeys[req.ViewType]
+	if !ok {
+		return fmt.Errorf("invalid view_type '%s', expected '%s' or '%s'", req.ViewType, models.SavedViewTypeTrafficLog, models.SavedViewTypeDomains)
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if req.Filters == nil {
+		req.Filters = make(map[string]string)
+	}
+	for key := range req.Filters {
+		known := false
+		for _, k := range allowed {
+			if k == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unsupported filter '%s' for %s views (supported: %s)", key, req.ViewType, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// applySavedView merges the filters of the view selected by the view_id or view (name) query
+// parameter into the request's query string. Parameters given explicitly in the request win.
+func applySavedView(r *http.Request, viewType string, targetID int64) error {
+	q := r.URL.Query()
+	viewIDStr, viewName := q.Get("view_id"), q.Get("view")
+	if viewIDStr == "" && viewName == "" {
+		return nil
+	}
+
+	var view models.SavedView
+	var err error
+	if viewIDStr != "" {
+		viewID, errParse := strconv.ParseInt(viewIDStr, 10, 64)
+		if errParse != nil {
+			return fmt.Errorf("invalid view_id '%s'", viewIDStr)
+		}
+		view, err = database.GetSavedViewByID(viewID)
+	} else {
+		view, err = database.FindSavedView(targetID, viewType, viewName)
+	}
+	if err != nil {
+		return err
+	}
+	if view.ViewType != viewType {
+		return fmt.Errorf("saved view %d is a %s view", view.ID, view.ViewType)
+	}
+	if view.TargetID.Valid && view.TargetID.Int64 != targetID {
+		return fmt.Errorf("saved view %d belongs to target %d", view.ID, view.TargetID.Int64)
+	}
+
+	for key, value := range view.Filters {
+		if !q.Has(key) {
+			q.Set(key, value)
+		}
+	}
+	q.Del("view_id")
+	q.Del("view")
+	r.URL.RawQuery = q.Encode()
+	logger.Debug("applySavedView: Applied %s view %d ('%s'): %s", viewType, view.ID, view.Name, r.URL.RawQuery)
+	return nil
+}
+
+// writeApplySavedViewError reports a saved view that could not be applied to a list request.
+func writeApplySavedViewError(w http.ResponseWriter, r *http.Request, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		return
+	}
+	WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+}
+
+// writeSavedViewError maps saved view errors to HTTP status codes.
+func writeSavedViewError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "already exists"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Saved view operation failed")
+	}
+}
+
+// GetSavedViewsHandler lists saved views.
+// Query parameters: target_id (includes global views), type.
+func GetSavedViewsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	views, err := database.GetSavedViews(targetID, r.URL.Query().Get("type"))
+	if err != nil {
+		logger.Error("GetSavedViewsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve saved views")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// CreateSavedViewHandler saves a named set of list filters.
+func CreateSavedViewHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.SavedViewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if err := validateSavedViewRequest(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	view := models.SavedView{ViewType: req.ViewType, Name: req.Name, Filters: req.Filters}
+	if req.TargetID != 0 {
+		view.TargetID = sql.NullInt64{Int64: req.TargetID, Valid: true}
+	}
+	id, err := database.CreateSavedView(view)
+	if err != nil {
+		writeSavedViewError(w, r, "CreateSavedViewHandler", err)
+		return
+	}
+	created, err := database.GetSavedViewByID(id)
+	if err != nil {
+		writeSavedViewError(w, r, "CreateSavedViewHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetSavedViewHandler returns a single saved view.
+func GetSavedViewHandler(w http.ResponseWriter, r *http.Request) {
+	viewID, err := strconv.ParseInt(chi.URLParam(r, "view_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid view ID")
+		return
+	}
+	view, err := database.GetSavedViewByID(viewID)
+	if err != nil {
+		writeSavedViewError(w, r, "GetSavedViewHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// UpdateSavedViewHandler renames a saved view and/or replaces its filters.
+// The view type and target cannot be changed.
+func UpdateSavedViewHandler(w http.ResponseWriter, r *http.Request) {
+	viewID, err := strconv.ParseInt(chi.URLParam(r, "view_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid view ID")
+		return
+	}
+	existing, err := database.GetSavedViewByID(viewID)
+	if err != nil {
+		writeSavedViewError(w, r, "UpdateSavedViewHandler", err)
+		return
+	}
+	var req models.SavedViewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	req.ViewType = existing.ViewType
+	if req.Name == "" {
+		req.Name = existing.Name
+	}
+	if req.Filters == nil {
+		req.Filters = existing.Filters
+	}
+	if err := validateSavedViewRequest(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	existing.Name, existing.Filters = req.Name, req.Filters
+	if err := database.UpdateSavedView(existing); err != nil {
+		writeSavedViewError(w, r, "UpdateSavedViewHandler", err)
+		return
+	}
+	updated, err := database.GetSavedViewByID(viewID)
+	if err != nil {
+		writeSavedViewError(w, r, "UpdateSavedViewHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteSavedViewHandler removes a saved view.
+func DeleteSavedViewHandler(w http.ResponseWriter, r *http.Request) {
+	viewID, err := strconv.ParseInt(chi.URLParam(r, "view_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid view ID")
+		return
+	}
+	if err := database.DeleteSavedView(viewID); err != nil {
+		writeSavedViewError(w, r, "DeleteSavedViewHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
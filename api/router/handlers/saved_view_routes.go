@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterSavedViewRoutes(r chi.Router) {
+	r.Get("/views", GetSavedViewsHandler) // ?target_id=&type=traffic_log|domains
+	r.Post("/views", CreateSavedViewHandler)
+	r.Get("/views/{view_id}", GetSavedViewHandler)
+	r.Put("/views/{view_id}", UpdateSavedViewHandler)
+	r.Delete("/views/{view_id}", DeleteSavedViewHandler)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -18,35 +19,27 @@ func addScopeRule(w http.ResponseWriter, r *http.Request) {
 	var sr models.ScopeRule
 	if err := json.NewDecoder(r.Body).Decode(&sr); err != nil {
 		logger.Error("addScopeRule: Error decoding request body: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body: " + err.Error()})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if sr.TargetID == 0 {
 		logger.Error("addScopeRule: target_id is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "target_id is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id is required")
 		return
 	}
 	sr.Pattern = strings.TrimSpace(sr.Pattern)
 	if sr.Pattern == "" {
 		logger.Error("addScopeRule: pattern is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "pattern is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "pattern is required")
 		return
 	}
 	sr.ItemType = strings.TrimSpace(strings.ToLower(sr.ItemType))
 	validItemTypes := map[string]bool{"domain": true, "subdomain": true, "ip_address": true, "cidr": true, "url_path": true}
 	if !validItemTypes[sr.ItemType] {
 		logger.Error("addScopeRule: item_type is required and was empty or invalid: %s", sr.ItemType)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "item_type is required and must be valid (domain, subdomain, ip_address, cidr, url_path)"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "item_type is required and must be valid (domain, subdomain, ip_address, cidr, url_path)")
 		return
 	}
 
@@ -54,19 +47,13 @@ func addScopeRule(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if strings.Contains(err.Error(), "already exists") {
 			logger.Error("addScopeRule: Conflict: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: err.Error()})
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
 		} else if strings.Contains(err.Error(), "does not exist") { // Target does not exist
 			logger.Error("addScopeRule: Bad Request: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest) // Or StatusNotFound depending on preference
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: err.Error()})
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error()) // Or StatusNotFound depending on preference
 		} else {
 			logger.Error("addScopeRule: Internal server error: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -77,6 +64,17 @@ func addScopeRule(w http.ResponseWriter, r *http.Request) {
 		logger.Error("addScopeRule: Error encoding response for scope rule on target %d: %v", createdRule.TargetID, err)
 	}
 	logger.Info("Scope rule created: ID %d, TargetID %d, Pattern '%s', InScope: %t", createdRule.ID, createdRule.TargetID, createdRule.Pattern, createdRule.IsInScope)
+	core.NotifyTargetEvent(models.NotificationScopeChanged, createdRule.TargetID, "Scope rule added", scopeRuleNotificationText(createdRule), createdRule)
+	core.RefreshIPAssetScope(createdRule.TargetID)
+}
+
+// scopeRuleNotificationText describes a scope rule in a scope change notification.
+func scopeRuleNotificationText(rule models.ScopeRule) string {
+	scope := "in scope"
+	if !rule.IsInScope {
+		scope = "out of scope"
+	}
+	return fmt.Sprintf("%s %s (%s)", rule.ItemType, rule.Pattern, scope)
 }
 
 // getScopeRules handles listing scope rules for a target.
@@ -84,18 +82,14 @@ func getScopeRules(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
 		logger.Error("getScopeRules: target_id query parameter is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "target_id query parameter is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("getScopeRules: Invalid target_id parameter '%s': %v", targetIDStr, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid target_id parameter, must be an integer"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id parameter, must be an integer")
 		return
 	}
 
@@ -105,9 +99,7 @@ func getScopeRules(w http.ResponseWriter, r *http.Request) {
 	scopeRules, err := database.GetScopeRulesByTargetID(targetID)
 	if err != nil {
 		logger.Error("getScopeRules: Error querying scope rules for target_id %d: %v", targetID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -128,7 +120,7 @@ func GetScopeRuleByIDChiHandler(w http.ResponseWriter, r *http.Request) {
 	ruleID, err := strconv.ParseInt(ruleIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetScopeRuleByIDChiHandler: Invalid rule ID format '%s': %v", ruleIDStr, err)
-		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid rule ID")
 		return
 	}
 	getScopeRuleByID(w, r, ruleID)
@@ -140,14 +132,10 @@ func getScopeRuleByID(w http.ResponseWriter, r *http.Request, ruleID int64) {
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("getScopeRuleByID: Scope rule with ID %d not found", ruleID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Scope rule with ID %d not found", ruleID)})
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Scope rule with ID %d not found", ruleID))
 		} else {
 			logger.Error("getScopeRuleByID: Error querying scope rule ID %d: %v", ruleID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -165,7 +153,7 @@ func DeleteScopeRuleChiHandler(w http.ResponseWriter, r *http.Request) {
 	ruleID, err := strconv.ParseInt(ruleIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeleteScopeRuleChiHandler: Invalid rule ID format '%s': %v", ruleIDStr, err)
-		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid rule ID")
 		return
 	}
 	deleteScopeRule(w, r, ruleID)
@@ -175,22 +163,21 @@ func DeleteScopeRuleChiHandler(w http.ResponseWriter, r *http.Request) {
 func deleteScopeRule(w http.ResponseWriter, r *http.Request, ruleID int64) {
 	logger.Info("Attempting to delete scope rule with ID %d", ruleID)
 
+	rule, _ := database.GetScopeRuleByID(ruleID)
 	err := database.DeleteScopeRule(ruleID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("deleteScopeRule: Scope rule with ID %d not found for deletion", ruleID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Scope rule with ID %d not found", ruleID)})
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Scope rule with ID %d not found", ruleID))
 		} else {
 			logger.Error("deleteScopeRule: Error deleting rule ID %d: %v", ruleID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error during delete"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during delete")
 		}
 		return
 	}
 
 	logger.Info("Scope rule deleted successfully: ID %d", ruleID)
+	core.NotifyTargetEvent(models.NotificationScopeChanged, rule.TargetID, "Scope rule removed", scopeRuleNotificationText(rule), rule)
+	core.RefreshIPAssetScope(rule.TargetID)
 	w.WriteHeader(http.StatusNoContent)
 }
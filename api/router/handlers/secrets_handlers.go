@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSecretsHandler lists detected secrets.
+// Query parameters: target_id, status, rule, log_id, page, limit.
+func GetSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.SecretFilters{
+		Status:   q.Get("status"),
+		RuleName: q.Get("rule"),
+	}
+	filters.TargetID, _ = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	filters.LogID, _ = strconv.ParseInt(q.Get("log_id"), 10, 64)
+	filters.Page, _ = strconv.Atoi(q.Get("page"))
+	filters.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.Limit <= 0 || filters.Limit > 500 {
+		filters.Limit = 50
+	}
+
+	secrets, totalRecords, err := database.GetSecrets(filters)
+	if err != nil {
+		logger.Error("GetSecretsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve secrets")
+		return
+	}
+	totalPages := (totalRecords + int64(filters.Limit) - 1) / int64(filters.Limit)
+
+	response := struct {
+		Page         int             `json:"page"`
+		Limit        int             `json:"limit"`
+		TotalRecords int64           `json:"total_records"`
+		TotalPages   int64           `json:"total_pages"`
+		Secrets      []models.Secret `json:"secrets"`
+	}{
+		Page:         filters.Page,
+		Limit:        filters.Limit,
+		TotalRecords: totalRecords,
+		TotalPages:   totalPages,
+		Secrets:      secrets,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSecretHandler returns a single detected secret.
+func GetSecretHandler(w http.ResponseWriter, r *http.Request) {
+	secretID, err := strconv.ParseInt(chi.URLParam(r, "secret_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid secret ID")
+		return
+	}
+	secret, err := database.GetSecretByID(secretID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			logger.Error("GetSecretHandler: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve secret")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(secret)
+}
+
+// UpdateSecretStatusHandler triages a secret. With ?all_matching=true the status is applied to every
+// secret with the same value.
+func UpdateSecretStatusHandler(w http.ResponseWriter, r *http.Request) {
+	secretID, err := strconv.ParseInt(chi.URLParam(r, "secret_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid secret ID")
+		return
+	}
+	var req models.UpdateSecretStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	allMatching := r.URL.Query().Get("all_matching") == "true"
+	updated, err := database.UpdateSecretStatus(secretID, req.Status, req.Notes, allMatching)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		case strings.Contains(err.Error(), "invalid status"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		default:
+			logger.Error("UpdateSecretStatusHandler: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update secret")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"updated": updated})
+}
+
+// ScanLogForSecretsHandler scans a stored log entry for secrets, e.g. after adding custom rules.
+func ScanLogForSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		HTTPTrafficLogID int64 `json:"http_traffic_log_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.HTTPTrafficLogID == 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "http_traffic_log_id is required")
+		return
+	}
+	defer r.Body.Close()
+
+	inserted, err := core.ScanTrafficLogForSecrets(req.HTTPTrafficLogID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			logger.Error("ScanLogForSecretsHandler: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to scan log entry")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"new_secrets": inserted})
+}
+
+// GetSecretRulesHandler lists the active detection rules.
+func GetSecretRulesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetSecretRules())
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterSecretsRoutes(r chi.Router) {
+	r.Get("/secrets", GetSecretsHandler)
+	r.Get("/secrets/rules", GetSecretRulesHandler)
+	r.Post("/secrets/scan", ScanLogForSecretsHandler) // Re-scan a single log entry on demand
+	r.Get("/secrets/{secret_id}", GetSecretHandler)
+	r.Put("/secrets/{secret_id}/status", UpdateSecretStatusHandler) // Triage: confirmed / false_positive / new
+}
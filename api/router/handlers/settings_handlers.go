@@ -6,24 +6,28 @@ import (
 	"io" // Import the io package
 	"net/http"
 	"strconv"
-	"toolkit/database"
+	"strings"
 	"toolkit/config" // Import the config package
+	"toolkit/core"
+	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
 ) // Ensure models is imported if TableLayoutConfig is there
 
 // GetCurrentTargetSettingHandler retrieves the currently set target ID.
 func GetCurrentTargetSettingHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetCurrentTargetSettingHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	targetIDStr, err := database.GetSetting(models.CurrentTargetIDKey)
 	if err != nil {
 		logger.Error("GetCurrentTargetSettingHandler: Error getting current target setting: %v", err)
-		http.Error(w, "Failed to retrieve current target setting", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve current target setting")
 		return
 	}
 
@@ -48,7 +52,7 @@ func GetCurrentTargetSettingHandler(w http.ResponseWriter, r *http.Request) {
 func SetCurrentTargetSettingHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("SetCurrentTargetSettingHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -57,7 +61,7 @@ func SetCurrentTargetSettingHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("SetCurrentTargetSettingHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -70,7 +74,7 @@ func SetCurrentTargetSettingHandler(w http.ResponseWriter, r *http.Request) {
 		err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM targets WHERE id = ?)", *req.TargetID).Scan(&exists)
 		if err != nil || !exists {
 			logger.Error("SetCurrentTargetSettingHandler: Target ID %d does not exist or DB error: %v", *req.TargetID, err)
-			http.Error(w, fmt.Sprintf("Target ID %d not found or invalid.", *req.TargetID), http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("Target ID %d not found or invalid.", *req.TargetID))
 			return
 		}
 	} else {
@@ -79,7 +83,7 @@ func SetCurrentTargetSettingHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := database.SetSetting(models.CurrentTargetIDKey, targetIDStr); err != nil {
 		logger.Error("SetCurrentTargetSettingHandler: Error saving current target setting: %v", err)
-		http.Error(w, "Failed to save current target setting", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save current target setting")
 		return
 	}
 
@@ -93,7 +97,7 @@ func GetCustomHeadersSettingHandler(w http.ResponseWriter, r *http.Request) {
 	headersJSON, err := database.GetSetting(models.CustomHTTPHeadersKey)
 	if err != nil {
 		logger.Error("GetCustomHeadersSettingHandler: Error getting custom headers setting: %v", err)
-		http.Error(w, "Failed to retrieve custom headers setting", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve custom headers setting")
 		return
 	}
 
@@ -116,7 +120,7 @@ func SetCustomHeadersSettingHandler(w http.ResponseWriter, r *http.Request) {
 	var headersMap map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&headersMap); err != nil {
 		logger.Error("SetCustomHeadersSettingHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -124,13 +128,13 @@ func SetCustomHeadersSettingHandler(w http.ResponseWriter, r *http.Request) {
 	headersJSON, err := json.Marshal(headersMap)
 	if err != nil {
 		logger.Error("SetCustomHeadersSettingHandler: Error marshalling custom headers to JSON: %v", err)
-		http.Error(w, "Failed to process custom headers", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process custom headers")
 		return
 	}
 
 	if err := database.SetSetting(models.CustomHTTPHeadersKey, string(headersJSON)); err != nil {
 		logger.Error("SetCustomHeadersSettingHandler: Error saving custom headers setting: %v", err)
-		http.Error(w, "Failed to save custom headers setting", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save custom headers setting")
 		return
 	}
 
@@ -143,7 +147,7 @@ func GetTableColumnWidthsHandler(w http.ResponseWriter, r *http.Request) {
 	widthsJSON, err := database.GetSetting(models.TableColumnWidthsKey)
 	if err != nil {
 		logger.Error("GetTableColumnWidthsHandler: Error getting column widths: %v", err)
-		http.Error(w, "Failed to retrieve column widths", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve column widths")
 		return
 	}
 
@@ -168,7 +172,7 @@ func SetTableColumnWidthsHandler(w http.ResponseWriter, r *http.Request) {
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
 		logger.Error("SetTableColumnWidthsHandler: Error reading request body: %v", err)
-		http.Error(w, "Failed to read request body: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to read request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close() // Ensure body is closed
@@ -178,22 +182,20 @@ func SetTableColumnWidthsHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
 		logger.Error("SetTableColumnWidthsHandler: Error decoding request body: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request payload: " + err.Error()})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 
 	widthsJSON, err := json.Marshal(payload)
 	if err != nil {
 		logger.Error("SetTableColumnWidthsHandler: Error marshalling column widths to JSON: %v", err)
-		http.Error(w, "Failed to process column widths", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process column widths")
 		return
 	}
 
 	if err := database.SetSetting(models.TableColumnWidthsKey, string(widthsJSON)); err != nil {
 		logger.Error("SetTableColumnWidthsHandler: Error saving column widths: %v", err)
-		http.Error(w, "Failed to save column widths", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save column widths")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -204,15 +206,13 @@ func SetTableColumnWidthsHandler(w http.ResponseWriter, r *http.Request) {
 func ResetTableColumnWidthsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("ResetTableColumnWidthsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	if err := database.SetSetting(models.TableColumnWidthsKey, "{}"); err != nil {
 		logger.Error("ResetTableColumnWidthsHandler: Error resetting column widths: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to reset table column widths."})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to reset table column widths.")
 		return
 	}
 
@@ -225,7 +225,7 @@ func ResetTableColumnWidthsHandler(w http.ResponseWriter, r *http.Request) {
 func GetUISettingsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetUISettingsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -322,14 +322,14 @@ func GetUISettingsHandler(w http.ResponseWriter, r *http.Request) {
 func SetUISettingsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut && r.Method != http.MethodPost {
 		logger.Error("SetUISettingsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed (use PUT or POST)", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed (use PUT or POST)")
 		return
 	}
 
 	var newSettings map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&newSettings); err != nil {
 		logger.Error("SetUISettingsHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -347,12 +347,12 @@ func SetUISettingsHandler(w http.ResponseWriter, r *http.Request) {
 		settingsJSON, err := json.Marshal(uiSettingsToSave)
 		if err != nil {
 			logger.Error("SetUISettingsHandler: Error marshalling UI settings: %v", err)
-			http.Error(w, "Failed to process UI settings", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process UI settings")
 			return
 		}
 		if err := database.SetSetting(models.UISettingsKey, string(settingsJSON)); err != nil {
 			logger.Error("SetUISettingsHandler: Error saving UI settings: %v", err)
-			http.Error(w, "Failed to save UI settings", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save UI settings")
 			return
 		}
 	} // Add more settings handling here as needed
@@ -367,14 +367,14 @@ func SetUISettingsHandler(w http.ResponseWriter, r *http.Request) {
 func GetProxyExclusionRulesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetProxyExclusionRulesHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	rules, err := database.GetProxyExclusionRules()
 	if err != nil {
 		logger.Error("GetProxyExclusionRulesHandler: Error getting rules: %v", err)
-		http.Error(w, "Failed to retrieve proxy exclusion rules", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve proxy exclusion rules")
 		return
 	}
 
@@ -387,21 +387,26 @@ func GetProxyExclusionRulesHandler(w http.ResponseWriter, r *http.Request) {
 func SetProxyExclusionRulesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost && r.Method != http.MethodPut { // Allow POST or PUT
 		logger.Error("SetProxyExclusionRulesHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed (use POST or PUT)", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed (use POST or PUT)")
 		return
 	}
 
 	var rules []models.ProxyExclusionRule
 	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
 		logger.Error("SetProxyExclusionRulesHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
-	if err := database.SetProxyExclusionRules(rules); err != nil {
+	rules, err := core.SaveProxyExclusionRules(rules)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid proxy exclusion rule") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
 		logger.Error("SetProxyExclusionRulesHandler: Error saving rules: %v", err)
-		http.Error(w, "Failed to save proxy exclusion rules", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save proxy exclusion rules")
 		return
 	}
 
@@ -422,7 +427,7 @@ type ApplicationSettingsResponse struct {
 func GetApplicationSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetApplicationSettingsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -434,7 +439,7 @@ func GetApplicationSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		logger.Error("GetApplicationSettingsHandler: Error encoding response: %v", err)
-		http.Error(w, "Failed to encode settings", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to encode settings")
 	}
 }
 
@@ -442,14 +447,14 @@ func GetApplicationSettingsHandler(w http.ResponseWriter, r *http.Request) {
 func SaveApplicationSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		logger.Error("SaveApplicationSettingsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var newSettings ApplicationSettingsResponse
 	if err := json.NewDecoder(r.Body).Decode(&newSettings); err != nil {
 		logger.Error("SaveApplicationSettingsHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -462,14 +467,167 @@ func SaveApplicationSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	// This function needs to be implemented in your config package.
 	if err := config.SaveAppConfig(); err != nil {
 		logger.Error("SaveApplicationSettingsHandler: Error saving application configuration: %v", err)
-		http.Error(w, "Failed to save application settings", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save application settings")
 		return
 	}
 
-	// TODO: Consider if any services need to be re-initialized or notified of config changes.
-	// For example, if mission polling interval changes, the SynackMissionService might need a restart or update.
+	if err := core.RuntimeSettingsSavedToFile("missions"); err != nil {
+		logger.Error("SaveApplicationSettingsHandler: Error clearing stored mission settings: %v", err)
+	}
+	core.NotifyRuntimeSettingsChanged("missions")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Application settings saved successfully."})
 	logger.Info("Application settings updated and saved to config file.")
 }
+
+// RateLimitSettingsResponse defines the structure for the /settings/rate-limit endpoint.
+type RateLimitSettingsResponse struct {
+	Config config.RateLimitConfig `json:"config"`
+	Stats  *core.RateLimiterStats `json:"stats,omitempty"`
+}
+
+// GetRateLimitSettingsHandler returns the rate limit configuration along with live limiter stats.
+func GetRateLimitSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := core.GetRateLimiter().Stats()
+	response := RateLimitSettingsResponse{
+		Config: config.AppConfig.RateLimit,
+		Stats:  &stats,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SaveRateLimitSettingsHandler updates the rate limit configuration, persists it and
+// swaps in a new limiter so the change applies without a restart.
+func SaveRateLimitSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var newConf config.RateLimitConfig
+	if err := json.NewDecoder(r.Body).Decode(&newConf); err != nil {
+		logger.Error("SaveRateLimitSettingsHandler: Error decoding request body: %v", err)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if newConf.RequestsPerSecond < 0 || newConf.Burst < 0 || newConf.MaxConcurrency < 0 ||
+		newConf.PerHostConcurrency < 0 || newConf.MaxRetries < 0 || newConf.BackoffInitialMs < 0 || newConf.BackoffMaxMs < 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Rate limit values cannot be negative")
+		return
+	}
+
+	config.AppConfig.RateLimit = newConf
+	if err := config.SaveAppConfig(); err != nil {
+		logger.Error("SaveRateLimitSettingsHandler: Error saving application configuration: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to save rate limit settings")
+		return
+	}
+	if err := core.RuntimeSettingsSavedToFile("rate_limit"); err != nil {
+		logger.Error("SaveRateLimitSettingsHandler: Error clearing stored rate limit settings: %v", err)
+	}
+	core.ReconfigureRateLimiter(newConf)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Rate limit settings saved successfully."})
+	logger.Info("Rate limit settings updated and saved to config file.")
+}
+
+// GetRuntimeSettingsHandler lists the settings that can be changed while the toolkit runs, with
+// their current value, their value in config.yaml and whether a stored value overrides it.
+func GetRuntimeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := core.GetRuntimeSettings()
+	if err != nil {
+		logger.Error("GetRuntimeSettingsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve runtime settings")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateRuntimeSettingsHandler stores runtime settings given as {"key": value} and applies them to
+// the running subsystems. A null value resets the setting to its config.yaml value.
+func UpdateRuntimeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var update models.RuntimeSettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	result, err := core.UpdateRuntimeSettings(update)
+	if err != nil {
+		logger.Error("UpdateRuntimeSettingsHandler: %v", err)
+		if strings.HasPrefix(err.Error(), "invalid") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update runtime settings")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetTargetRuntimeSettingsHandler lists the runtime settings that can be overridden for a target,
+// with their effective value for its traffic.
+func GetTargetRuntimeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := runtimeSettingsTargetID(w, r)
+	if !ok {
+		return
+	}
+	settings, err := core.GetTargetRuntimeSettings(targetID)
+	if err != nil {
+		logger.Error("GetTargetRuntimeSettingsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve target settings")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateTargetRuntimeSettingsHandler stores per-target overrides given as {"key": value}. A null
+// value removes the override so the global value applies again.
+func UpdateTargetRuntimeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := runtimeSettingsTargetID(w, r)
+	if !ok {
+		return
+	}
+	var update models.RuntimeSettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	result, err := core.UpdateTargetRuntimeSettings(targetID, update)
+	if err != nil {
+		logger.Error("UpdateTargetRuntimeSettingsHandler: %v", err)
+		if strings.HasPrefix(err.Error(), "invalid") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update target settings")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// runtimeSettingsTargetID parses the target_id path parameter and checks the target exists.
+func runtimeSettingsTargetID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil || targetID <= 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return 0, false
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			logger.Error("runtimeSettingsTargetID: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve target")
+		}
+		return 0, false
+	}
+	return targetID, true
+}
@@ -34,6 +34,17 @@ func RegisterSettingsRoutes(r chi.Router) {
 		r.Put("/", SetProxyExclusionRulesHandler)
 	})
 
+	r.Route("/settings/rate-limit", func(r chi.Router) {
+		r.Get("/", GetRateLimitSettingsHandler)
+		r.Put("/", SaveRateLimitSettingsHandler)
+	})
+
+	// Runtime-tunable options (proxy, body caps, rate limits, Synack toggles) stored in app_settings
+	r.Route("/settings/runtime", func(r chi.Router) {
+		r.Get("/", GetRuntimeSettingsHandler)
+		r.Put("/", UpdateRuntimeSettingsHandler)
+	})
+
 	// New route for general application settings (UI, Missions, etc.)
 	r.Route("/settings/app", func(r chi.Router) {
 		r.Get("/", GetApplicationSettingsHandler)  // New handler
@@ -28,30 +28,26 @@ func GetGeneratedSitemapHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
 		logger.Error("GetGeneratedSitemapHandler: target_id query parameter is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "target_id query parameter is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetGeneratedSitemapHandler: Invalid target_id parameter '%s': %v", targetIDStr, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid target_id parameter, must be an integer"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id parameter, must be an integer")
 		return
 	}
 
 	logEntries, err := database.GetLogEntriesForSitemapGeneration(targetID)
 	if err != nil { // Error already logged in GetLogEntriesForSitemapGeneration
-		http.Error(w, "Failed to retrieve log entries for sitemap", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve log entries for sitemap")
 		return
 	}
 
 	manualEntries, err := database.GetSitemapManualEntriesByTargetID(targetID)
 	if err != nil { // Error already logged
-		http.Error(w, "Failed to retrieve manual sitemap entries", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve manual sitemap entries")
 		return
 	}
 
@@ -98,25 +94,21 @@ func GetSitemapManualEntriesHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
 		logger.Error("GetSitemapManualEntriesHandler: target_id query parameter is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "target_id query parameter is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetSitemapManualEntriesHandler: Invalid target_id parameter '%s': %v", targetIDStr, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid target_id parameter, must be an integer"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id parameter, must be an integer")
 		return
 	}
 
 	entries, err := database.GetSitemapManualEntriesByTargetID(targetID)
 	if err != nil {
 		logger.Error("GetSitemapManualEntriesHandler: Error fetching sitemap manual entries for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve sitemap manual entries", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve sitemap manual entries")
 		return
 	}
 
@@ -132,35 +124,27 @@ func GetSitemapManualEntriesHandler(w http.ResponseWriter, r *http.Request) {
 func AddSitemapManualEntryHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("AddSitemapManualEntryHandler: MethodNotAllowed: %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Method not allowed. Only POST is accepted."})
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed. Only POST is accepted.")
 		return
 	}
 
 	var req models.AddSitemapManualEntryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("AddSitemapManualEntryHandler: Error decoding request body: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request payload: " + err.Error()})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.HTTPTrafficLogID == 0 {
 		logger.Error("AddSitemapManualEntryHandler: http_log_id is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "http_log_id is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "http_log_id is required")
 		return
 	}
 	req.FolderPath = strings.TrimSpace(req.FolderPath)
 	if req.FolderPath == "" {
 		logger.Error("AddSitemapManualEntryHandler: folder_path is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "folder_path is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "folder_path is required")
 		return
 	}
 	// Ensure folder_path starts and ends with a slash for consistency, unless it's just "/"
@@ -183,32 +167,24 @@ func AddSitemapManualEntryHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("AddSitemapManualEntryHandler: Original http_traffic_log entry with ID %d not found", req.HTTPTrafficLogID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Original log entry with ID %d not found", req.HTTPTrafficLogID)})
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Original log entry with ID %d not found", req.HTTPTrafficLogID))
 		} else {
 			logger.Error("AddSitemapManualEntryHandler: Error fetching original log entry ID %d: %v", req.HTTPTrafficLogID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to retrieve original log entry details"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve original log entry details")
 		}
 		return
 	}
 
 	if !originalLog.TargetID.Valid {
 		logger.Error("AddSitemapManualEntryHandler: Original log entry ID %d is not associated with a target (target_id is NULL)", req.HTTPTrafficLogID)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Original log entry is not associated with a target"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Original log entry is not associated with a target")
 		return
 	}
 
 	parsedURL, err := url.Parse(originalLog.RequestURL)
 	if err != nil {
 		logger.Error("AddSitemapManualEntryHandler: Could not parse RequestURL '%s' from log ID %d: %v", originalLog.RequestURL, req.HTTPTrafficLogID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to parse URL from original log entry"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to parse URL from original log entry")
 		return
 	}
 	requestPath := parsedURL.Path
@@ -228,14 +204,10 @@ func AddSitemapManualEntryHandler(w http.ResponseWriter, r *http.Request) {
 		// It might return a specific error type for duplicates (UNIQUE constraint on http_traffic_log_id)
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			logger.Info("AddSitemapManualEntryHandler: Attempt to add duplicate sitemap entry for http_log_id %d. Error: %v", req.HTTPTrafficLogID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "This log entry has already been added to the sitemap."})
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, "This log entry has already been added to the sitemap.")
 		} else {
 			logger.Error("AddSitemapManualEntryHandler: Error creating sitemap manual entry: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to add entry to sitemap."})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to add entry to sitemap.")
 		}
 		return
 	}
@@ -20,6 +20,19 @@ func RegisterSitemapRoutes(r chi.Router) {
 	r.Put("/pages/{page_id}", UpdatePageDetailsHandler) // Update page details (name, description)
 	r.Delete("/pages/{page_id}", DeletePageHandler)     // Delete a specific page
 
+	// Replay a recorded page's logs in order as a flow (progress via /replay/batches)
+	r.Post("/pages/{page_id}/replay", ReplayPageHandler)
+
 	r.Post("/targets/{target_id}/analyze-parameters", AnalyzeTargetForParameterizedURLsHandler)
 	r.Get("/parameterized-urls", GetParameterizedURLsHandler)
+
+	// Redirect and SSRF parameter candidates from the parameter inventory
+	r.Get("/targets/{target_id}/parameters/redirect-candidates", GetRedirectParamCandidatesHandler)
+	r.Post("/targets/{target_id}/parameters/redirect-scan", StartRedirectParamScanHandler) // Job, files Draft findings
+
+	// Parameter mining: hidden parameters of captured endpoints join the parameter inventory
+	r.Post("/targets/{target_id}/parameters/mine", StartParamMiningHandler)
+
+	// One modifier task or ffuf command per endpoint of the parameter inventory
+	r.Post("/targets/{target_id}/parameters/generate-requests", GenerateEndpointRequestsHandler)
 }
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxStatsBuckets bounds the timeline of one statistics request.
+const maxStatsBuckets = 24 * 31
+
+// parseStatsTime accepts RFC3339 timestamps and plain dates (midnight UTC).
+func parseStatsTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// GetTrafficStatsHandler returns the traffic statistics of a target for a dashboard: summary,
+// timeline, status codes, sources and origins, top endpoints and hosts, slow endpoints and new hosts
+// per day. Query parameters: from, to (RFC3339 or YYYY-MM-DD; default the last 7 days), bucket
+// (hour or day; default hour for windows up to 3 days), top (default 10, max 100), origin (manual
+// or automated; default all traffic).
+func GetTrafficStatsHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetTrafficStatsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load target")
+		return
+	}
+
+	q := r.URL.Query()
+	filters := models.TrafficStatsFilters{TargetID: targetID, To: time.Now().UTC(), Top: 10}
+	if v := q.Get("to"); v != "" {
+		if filters.To, err = parseStatsTime(v); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid to (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+	}
+	filters.From = filters.To.Add(-7 * 24 * time.Hour)
+	if v := q.Get("from"); v != "" {
+		if filters.From, err = parseStatsTime(v); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid from (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+	}
+	if !filters.From.Before(filters.To) {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "from must be before to")
+		return
+	}
+	window := filters.To.Sub(filters.From)
+	switch filters.Bucket = q.Get("bucket"); filters.Bucket {
+	case "":
+		filters.Bucket = "hour"
+		if window > 3*24*time.Hour {
+			filters.Bucket = "day"
+		}
+	case "hour", "day":
+	default:
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid bucket (hour or day)")
+		return
+	}
+	step := time.Hour
+	if filters.Bucket == "day" {
+		step = 24 * time.Hour
+	}
+	if window/step > maxStatsBuckets {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("Range too large for bucket=%s (at most %d buckets)", filters.Bucket, maxStatsBuckets))
+		return
+	}
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid top (1-100)")
+			return
+		}
+		filters.Top = n
+	}
+	if filters.Origin = q.Get("origin"); filters.Origin != "" {
+		if _, ok := database.TrafficOriginCondition("log_source", filters.Origin); !ok {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid origin (manual or automated)")
+			return
+		}
+	}
+
+	stats, err := database.GetTrafficStats(filters)
+	if err != nil {
+		logger.Error("GetTrafficStatsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to compute traffic statistics")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetBodyBlobStatsHandler returns how the traffic log bodies are stored: deduplicated blobs, the
+// bytes they save, and the inline bodies a dedupe job would move.
+func GetBodyBlobStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := core.GetBodyBlobStats()
+	if err != nil {
+		logger.Error("GetBodyBlobStatsHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to compute body storage statistics")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// StartBodyDedupHandler starts a job moving inline traffic log bodies to deduplicated blobs,
+// optionally vacuuming the database afterwards ({"vacuum": true}). Progress is available from
+// the jobs API.
+func StartBodyDedupHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.BodyDedupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartTrafficBodyDedup(req)
+	if err != nil {
+		if strings.Contains(err.Error(), "already running") {
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
+			return
+		}
+		logger.Error("StartBodyDedupHandler: %v", err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start body deduplication")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterStatsRoutes sets up the routes of the dashboard statistics.
+func RegisterStatsRoutes(r chi.Router) {
+	r.Get("/targets/{target_id}/stats/traffic", GetTrafficStatsHandler)
+	r.Get("/stats/traffic-bodies", GetBodyBlobStatsHandler)
+	r.Post("/stats/traffic-bodies/dedupe", StartBodyDedupHandler)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"toolkit/logger"
+	"toolkit/models"
 )
 
 // SubfinderStatusResponse defines the structure for the subfinder status.
@@ -27,12 +28,12 @@ type SubfinderStatusResponse struct {
 func GetSubfinderStatusHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
-		http.Error(w, "target_id query parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 	_, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid target_id: must be an integer", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id: must be an integer")
 		return
 	}
 	// Placeholder: In a real implementation, you would query the status of background tasks.
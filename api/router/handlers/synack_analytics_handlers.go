@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeSynackAnalyticsError maps Synack analytics refresh errors to HTTP status codes.
+func writeSynackAnalyticsError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "not configured"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "not running"), strings.Contains(msg, "auth token"), strings.Contains(msg, "already running"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+	}
+}
+
+// RefreshSynackTargetAnalyticsHandler refetches the analytics and findings of one Synack target
+// (by database ID) right away and returns the new fetch time.
+func RefreshSynackTargetAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	targetDbID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid Synack target DB ID format")
+		return
+	}
+	if err := core.RefreshSynackTargetAnalytics(r.Context(), targetDbID); err != nil {
+		writeSynackAnalyticsError(w, r, "RefreshSynackTargetAnalyticsHandler", err)
+		return
+	}
+	fetchedAt, err := database.GetSynackTargetAnalyticsFetchTime(targetDbID)
+	if err != nil {
+		writeSynackAnalyticsError(w, r, "RefreshSynackTargetAnalyticsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"synack_target_db_id":       targetDbID,
+		"analytics_last_fetched_at": fetchedAt,
+	})
+}
+
+// StartSynackStaleAnalyticsRefreshHandler starts the background refresh of stale Synack analytics.
+// Query parameters: max_age_hours (defaults to synack.analytics_max_age_hours).
+func StartSynackStaleAnalyticsRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	maxAgeHours := 0
+	if v := r.URL.Query().Get("max_age_hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid max_age_hours")
+			return
+		}
+		maxAgeHours = n
+	}
+	status, err := core.StartSynackStaleAnalyticsRefresh(maxAgeHours)
+	if err != nil {
+		writeSynackAnalyticsError(w, r, "StartSynackStaleAnalyticsRefreshHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetSynackStaleAnalyticsRefreshHandler returns the progress of the current or last stale refresh.
+func GetSynackStaleAnalyticsRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetSynackStaleAnalyticsRefreshStatus())
+}
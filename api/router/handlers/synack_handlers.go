@@ -19,7 +19,7 @@ import (
 func ListSynackTargetsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("ListSynackTargetsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -70,7 +70,7 @@ func ListSynackTargetsHandler(w http.ResponseWriter, r *http.Request) {
 	targets, totalRecords, err := database.ListSynackTargetsPaginated(limit, offset, sortByParam, sortOrderParam, filterIsActive)
 	if err != nil {
 		logger.Error("ListSynackTargetsHandler: Error listing Synack targets from database: %v", err)
-		http.Error(w, "Failed to list Synack targets", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to list Synack targets")
 		return
 	}
 
@@ -103,7 +103,7 @@ func ListSynackTargetsHandler(w http.ResponseWriter, r *http.Request) {
 func GetSynackTargetDetailHandler(w http.ResponseWriter, r *http.Request, targetDbID int64) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetSynackTargetDetailHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -127,10 +127,10 @@ func GetSynackTargetDetailHandler(w http.ResponseWriter, r *http.Request, target
 	if err != nil {
 		if err == sql.ErrNoRows {
 			logger.Error("GetSynackTargetDetailHandler: Synack target with DB ID %d not found", targetDbID)
-			http.Error(w, fmt.Sprintf("Synack target with DB ID %d not found", targetDbID), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Synack target with DB ID %d not found", targetDbID))
 		} else {
 			logger.Error("GetSynackTargetDetailHandler: Error querying Synack target DB ID %d: %v", targetDbID, err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -198,7 +198,7 @@ func GetSynackTargetDetailHandler(w http.ResponseWriter, r *http.Request, target
 func GetSynackTargetAnalyticsHandler(w http.ResponseWriter, r *http.Request, targetDbID int64) {
 	if r.Method != http.MethodGet {
 		logger.Error("GetSynackTargetAnalyticsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -246,19 +246,19 @@ func GetSynackTargetAnalyticsHandler(w http.ResponseWriter, r *http.Request, tar
 	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM synack_targets WHERE id = ?)", targetDbID).Scan(&targetExists)
 	if err != nil {
 		logger.Error("GetSynackTargetAnalyticsHandler: Error checking target existence for DB ID %d: %v", targetDbID, err)
-		http.Error(w, "Internal server error verifying target", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error verifying target")
 		return
 	}
 	if !targetExists {
 		logger.Error("GetSynackTargetAnalyticsHandler: Synack target with DB ID %d not found", targetDbID)
-		http.Error(w, fmt.Sprintf("Synack target with DB ID %d not found", targetDbID), http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Synack target with DB ID %d not found", targetDbID))
 		return
 	}
 
 	findings, totalRecords, err := database.GetSynackTargetFindingsPaginated(targetDbID, limit, offset, dbSortColumnKey, dbSortOrder)
 	if err != nil {
 		logger.Error("GetSynackTargetAnalyticsHandler: Error fetching findings for Synack target DB ID %d: %v", targetDbID, err)
-		http.Error(w, "Error fetching findings data", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error fetching findings data")
 		return
 	}
 
@@ -291,7 +291,7 @@ func GetSynackTargetAnalyticsHandler(w http.ResponseWriter, r *http.Request, tar
 func ListAllSynackAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("ListAllSynackAnalyticsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -327,7 +327,7 @@ func ListAllSynackAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 	analytics, totalRecords, err := database.ListAllSynackAnalyticsPaginated(limit, offset, dbSortColumn, dbSortOrder)
 	if err != nil {
 		logger.Error("ListAllSynackAnalyticsHandler: Error fetching all analytics: %v", err)
-		http.Error(w, "Error fetching analytics data", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error fetching analytics data")
 		return
 	}
 
@@ -360,13 +360,13 @@ func ListAllSynackAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 func RefreshSynackTargetFindingsHandler(w http.ResponseWriter, r *http.Request, targetDbID int64) {
 	if r.Method != http.MethodPost {
 		logger.Error("RefreshSynackTargetFindingsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	if err := database.UpdateSynackTargetAnalyticsFetchTime(targetDbID, time.Time{}); err != nil {
 		logger.Error("RefreshSynackTargetFindingsHandler: Error resetting analytics fetch time for target %d: %v", targetDbID, err)
-		http.Error(w, "Failed to initiate findings refresh", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to initiate findings refresh")
 		return
 	}
 
@@ -379,7 +379,7 @@ func RefreshSynackTargetFindingsHandler(w http.ResponseWriter, r *http.Request,
 func ListObservedMissionsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		logger.Error("ListObservedMissionsHandler: MethodNotAllowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -415,7 +415,7 @@ func ListObservedMissionsHandler(w http.ResponseWriter, r *http.Request) {
 	missions, totalRecords, err := database.ListObservedMissionsPaginated(limit, offset, dbSortColumnKey, dbSortOrder)
 	if err != nil {
 		logger.Error("ListObservedMissionsHandler: Error listing observed missions: %v", err)
-		http.Error(w, "Failed to list observed missions", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to list observed missions")
 		return
 	}
 
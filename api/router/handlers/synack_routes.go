@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"toolkit/logger"
+	"toolkit/models"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -18,7 +19,7 @@ func RegisterSynackRoutes(r chi.Router) {
 			targetDbID, err := strconv.ParseInt(targetDbIDStr, 10, 64)
 			if err != nil {
 				logger.Error("Synack Target Item Router: Invalid target_db_id '%s': %v", targetDbIDStr, err)
-				http.Error(w, "Invalid Synack target DB ID format", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid Synack target DB ID format")
 				return
 			}
 			GetSynackTargetDetailHandler(w, req, targetDbID)
@@ -41,4 +42,13 @@ func RegisterSynackRoutes(r chi.Router) {
 
 	// New route for observed missions
 	r.Get("/synack/missions/observed", ListObservedMissionsHandler)
+
+	// Analytics refresh: one target now, or every target past the freshness window in the background
+	r.Post("/synack/targets/{id}/refresh-analytics", RefreshSynackTargetAnalyticsHandler)
+	r.Post("/synack/analytics/refresh-stale", StartSynackStaleAnalyticsRefreshHandler)
+	r.Get("/synack/analytics/refresh-stale", GetSynackStaleAnalyticsRefreshHandler)
+
+	// Watcher for new targets and missions
+	r.Get("/synack/watcher", GetSynackWatcherStatusHandler)
+	r.Post("/synack/watcher/poll", PollSynackWatcherHandler)
 }
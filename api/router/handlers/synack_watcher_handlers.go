@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"toolkit/core"
+)
+
+// GetSynackWatcherStatusHandler returns the counters of the Synack target/mission watcher.
+func GetSynackWatcherStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetSynackWatcherStatus())
+}
+
+// PollSynackWatcherHandler runs one watcher poll now and returns the updated status.
+func PollSynackWatcherHandler(w http.ResponseWriter, r *http.Request) {
+	if err := core.PollSynackWatcherNow(r.Context()); err != nil {
+		writeSynackAnalyticsError(w, r, "PollSynackWatcherHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetSynackWatcherStatus())
+}
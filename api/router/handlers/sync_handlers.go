@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"toolkit/core"
+	"toolkit/models"
+)
+
+// SyncExchangeHandler is the endpoint peers sync through. The body is a request encrypted with
+// sync.key; the answer, the local changes since the point the peer asked for, is encrypted too.
+// Answers 404 when sync is not enabled and 401 when the body can't be decrypted with sync.key.
+func SyncExchangeHandler(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, core.SyncMaxMessageBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return models.NewAppError(models.ErrCodePayloadTooLarge, "sync request over %d bytes", core.SyncMaxMessageBytes)
+		}
+		return models.InvalidRequestError("invalid sync request: %v", err)
+	}
+	out, err := core.ServeSync(body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(out)
+	return err
+}
+
+// GetSyncStatusHandler describes the sync setup of this instance and the progress with each peer
+// of sync.peers.
+func GetSyncStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	status, err := core.GetSyncStatus()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, status)
+}
+
+// RunSyncHandler starts a job syncing with a peer of sync.peers, or with all of them when peer is
+// empty.
+func RunSyncHandler(w http.ResponseWriter, r *http.Request) error {
+	var req models.SyncRunRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSONBody(r, &req); err != nil {
+			return err
+		}
+	}
+	job, err := core.StartSync(req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterSyncRoutes sets up the routes of sync between toolkit instances.
+func RegisterSyncRoutes(r chi.Router) {
+	r.Post("/sync", handle(SyncExchangeHandler))
+	r.Get("/sync/status", handle(GetSyncStatusHandler))
+	r.Post("/sync/run", handle(RunSyncHandler))
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -17,8 +16,36 @@ import (
 
 // updateTagPayload defines the expected structure for updating a tag.
 type updateTagPayload struct {
-	Name  *string `json:"name,omitempty"`
-	Color *string `json:"color,omitempty"` // Pointer for optional update, allows "" to clear or null to not change
+	Name        *string `json:"name,omitempty"`
+	Color       *string `json:"color,omitempty"` // Pointer for optional update, allows "" to clear or null to not change
+	Description *string `json:"description,omitempty"`
+	ParentID    *int64  `json:"parent_id,omitempty"` // 0 moves the tag to the top level
+}
+
+// writeTagError maps tag errors to HTTP status codes.
+func writeTagError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.Contains(msg, "already exists"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	case strings.Contains(msg, "cannot"), strings.Contains(msg, "required"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Tag operation failed")
+	}
+}
+
+// parseTagID reads the {tagID} URL parameter.
+func parseTagID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	tagID, err := strconv.ParseInt(chi.URLParam(r, "tagID"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid tag ID")
+		return 0, false
+	}
+	return tagID, true
 }
 
 // UpdateTagHandler handles PUT requests to update an existing tag.
@@ -27,14 +54,14 @@ func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
 	tagID, err := strconv.ParseInt(tagIDStr, 10, 64)
 	if err != nil {
 		logger.Error("UpdateTagHandler: Invalid tag ID format '%s': %v", tagIDStr, err)
-		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid tag ID")
 		return
 	}
 
 	var payload updateTagPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		logger.Error("UpdateTagHandler: Error decoding request body for tag %d: %v", tagID, err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -42,13 +69,7 @@ func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch existing tag to ensure it exists and to apply partial updates
 	_, err = database.GetTagByID(tagID) // Check for existence
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			logger.Error("UpdateTagHandler: Tag with ID %d not found", tagID)
-			http.Error(w, "Tag not found", http.StatusNotFound)
-		} else {
-			logger.Error("UpdateTagHandler: Error fetching tag %d for update: %v", tagID, err)
-			http.Error(w, "Failed to retrieve tag for update", http.StatusInternalServerError)
-		}
+		writeTagError(w, r, "UpdateTagHandler", err)
 		return
 	}
 
@@ -59,7 +80,7 @@ func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
 		trimmedName := strings.TrimSpace(*payload.Name)
 		if trimmedName == "" {
 			logger.Error("UpdateTagHandler: Tag name cannot be empty if provided for update (tag ID %d)", tagID)
-			http.Error(w, "Tag name cannot be empty if provided", http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Tag name cannot be empty if provided")
 			return
 		}
 		tagForDBUpdate.Name = trimmedName
@@ -70,15 +91,26 @@ func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
 	} else { // If "color" key is not in JSON, Color.Valid will be false, and DB won't update it.
 		tagForDBUpdate.Color = sql.NullString{Valid: false}
 	}
+	if payload.Description != nil {
+		tagForDBUpdate.Description = sql.NullString{String: *payload.Description, Valid: true}
+	}
+
+	if payload.ParentID != nil {
+		parentID := sql.NullInt64{Int64: *payload.ParentID, Valid: *payload.ParentID != 0}
+		if err := database.SetTagParent(tagID, parentID); err != nil {
+			writeTagError(w, r, "UpdateTagHandler", err)
+			return
+		}
+	}
 
 	updatedTag, err := database.UpdateTag(tagForDBUpdate)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed: tags.name") && payload.Name != nil {
 			logger.Error("UpdateTagHandler: Tag name '%s' already exists (tag ID %d): %v", *payload.Name, tagID, err)
-			http.Error(w, fmt.Sprintf("Tag name '%s' already exists.", *payload.Name), http.StatusConflict)
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, fmt.Sprintf("Tag name '%s' already exists.", *payload.Name))
 		} else {
 			logger.Error("UpdateTagHandler: Error updating tag %d: %v", tagID, err)
-			http.Error(w, "Failed to update tag", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to update tag")
 		}
 		return
 	}
@@ -88,32 +120,26 @@ func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Successfully updated tag ID %d. New name: '%s', New color: '%s'", updatedTag.ID, updatedTag.Name, updatedTag.Color.String)
 }
 
-// DeleteTagHandler handles DELETE requests to remove a tag.
+// DeleteTagHandler handles DELETE requests to remove a tag. Child tags move to the top level.
 func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
 	tagIDStr := chi.URLParam(r, "tagID")
 	tagID, err := strconv.ParseInt(tagIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeleteTagHandler: Invalid tag ID format '%s': %v", tagIDStr, err)
-		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid tag ID")
 		return
 	}
 
 	// First, check if the tag exists
 	_, err = database.GetTagByID(tagID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			logger.Error("DeleteTagHandler: Tag with ID %d not found for deletion", tagID)
-			http.Error(w, "Tag not found", http.StatusNotFound)
-		} else {
-			logger.Error("DeleteTagHandler: Error fetching tag %d before deletion: %v", tagID, err)
-			http.Error(w, "Failed to retrieve tag for deletion", http.StatusInternalServerError)
-		}
+		writeTagError(w, r, "DeleteTagHandler", err)
 		return
 	}
 
 	if err := database.DeleteTagAndAssociations(tagID); err != nil {
 		logger.Error("DeleteTagHandler: Error deleting tag %d and its associations: %v", tagID, err)
-		http.Error(w, "Failed to delete tag", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete tag")
 		return
 	}
 
@@ -121,18 +147,16 @@ func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Successfully deleted tag ID %d and its associations.", tagID)
 }
 
-// ListTagsHandler handles GET requests to list all tags.
-// (Currently a stub)
+// ListTagsHandler handles GET requests to list all tags with their usage and child counts.
+// Query parameters: target_id (only count items of this target).
 func ListTagsHandler(w http.ResponseWriter, r *http.Request) {
-	tags, err := database.GetAllTags()
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	tags, err := database.GetTagSummaries(targetID)
 	if err != nil {
 		logger.Error("ListTagsHandler: Error fetching all tags: %v", err)
-		http.Error(w, "Failed to retrieve tags", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve tags")
 		return
 	}
-	if tags == nil {
-		tags = []models.Tag{} // Return empty array instead of null
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(tags)
 	logger.Info("ListTagsHandler: Successfully served %d tags.", len(tags))
@@ -140,8 +164,10 @@ func ListTagsHandler(w http.ResponseWriter, r *http.Request) {
 
 // createTagPayload defines the expected structure for creating a tag.
 type createTagPayload struct {
-	Name  string  `json:"name"`
-	Color *string `json:"color"` // Use pointer to handle optional color
+	Name        string  `json:"name"`
+	Color       *string `json:"color"` // Use pointer to handle optional color
+	Description *string `json:"description"`
+	ParentID    int64   `json:"parent_id"` // Optional parent tag
 }
 
 // CreateTagHandler handles POST requests to create a new tag.
@@ -149,7 +175,7 @@ func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
 	var payload createTagPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		logger.Error("CreateTagHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -157,17 +183,27 @@ func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
 	trimmedName := strings.TrimSpace(payload.Name)
 	if trimmedName == "" {
 		logger.Error("CreateTagHandler: Tag name cannot be empty.")
-		http.Error(w, "Tag name cannot be empty", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Tag name cannot be empty")
 		return
 	}
 	tagToCreate := models.Tag{Name: trimmedName}
 	if payload.Color != nil {
 		tagToCreate.Color = sql.NullString{String: *payload.Color, Valid: true}
 	}
+	if payload.Description != nil {
+		tagToCreate.Description = sql.NullString{String: *payload.Description, Valid: true}
+	}
+	if payload.ParentID != 0 {
+		tagToCreate.ParentID = sql.NullInt64{Int64: payload.ParentID, Valid: true}
+	}
 	createdTag, err := database.CreateTag(tagToCreate) // CreateTag handles if it already exists by name
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
+		}
 		// CreateTag already logs specific errors like UNIQUE constraint
-		http.Error(w, "Failed to create tag: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to create tag: "+err.Error())
 		return
 	}
 
@@ -178,12 +214,114 @@ func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetTagByIDHandler handles GET requests for a specific tag by its ID.
-// (Currently a stub)
 func GetTagByIDHandler(w http.ResponseWriter, r *http.Request) {
-	// tagIDStr := chi.URLParam(r, "tagID")
-	// TODO: Implement logic to fetch and return tag by ID
-	logger.Info("GetTagByIDHandler: Called (Not Implemented Yet)")
-	notImplementedHandler(w, r)
+	tagID, ok := parseTagID(w, r)
+	if !ok {
+		return
+	}
+	tag, err := database.GetTagByID(tagID)
+	if err != nil {
+		writeTagError(w, r, "GetTagByIDHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// GetTagUsageHandler returns how many items of each type carry a tag, per target.
+func GetTagUsageHandler(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := parseTagID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := database.GetTagByID(tagID); err != nil {
+		writeTagError(w, r, "GetTagUsageHandler", err)
+		return
+	}
+	usage, err := database.GetTagUsage(tagID)
+	if err != nil {
+		writeTagError(w, r, "GetTagUsageHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// GetTaggedItemsHandler lists the logs, domains, findings and other items carrying a tag.
+// Query parameters: target_id, item_type, include_children (also list items tagged with descendant tags).
+func GetTaggedItemsHandler(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := parseTagID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := database.GetTagByID(tagID); err != nil {
+		writeTagError(w, r, "GetTaggedItemsHandler", err)
+		return
+	}
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	includeChildren, _ := strconv.ParseBool(r.URL.Query().Get("include_children"))
+	items, err := database.GetTaggedItems(tagID, includeChildren, targetID, r.URL.Query().Get("item_type"))
+	if err != nil {
+		writeTagError(w, r, "GetTaggedItemsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// MergeTagsHandler merges the tags listed in source_tag_ids into the tag in the URL.
+// The source tags are deleted; their items and child tags move to the destination.
+func MergeTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := parseTagID(w, r)
+	if !ok {
+		return
+	}
+	var req models.TagMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	moved, err := database.MergeTags(tagID, req.SourceTagIDs)
+	if err != nil {
+		writeTagError(w, r, "MergeTagsHandler", err)
+		return
+	}
+	tag, err := database.GetTagByID(tagID)
+	if err != nil {
+		writeTagError(w, r, "MergeTagsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tag": tag, "merged_tag_ids": req.SourceTagIDs, "associations_moved": moved})
+}
+
+// RenameTagHandler renames a tag, optionally renaming "<old name>/..." child tags along with it.
+func RenameTagHandler(w http.ResponseWriter, r *http.Request) {
+	tagID, ok := parseTagID(w, r)
+	if !ok {
+		return
+	}
+	var req models.TagRenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	renamed, err := database.RenameTag(tagID, req.Name, req.Cascade)
+	if err != nil {
+		writeTagError(w, r, "RenameTagHandler", err)
+		return
+	}
+	tag, err := database.GetTagByID(tagID)
+	if err != nil {
+		writeTagError(w, r, "RenameTagHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tag": tag, "children_renamed": renamed})
 }
 
 // associateTagPayload defines the expected structure for associating a tag.
@@ -198,7 +336,7 @@ func AssociateTagHandler(w http.ResponseWriter, r *http.Request) {
 	var payload associateTagPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		logger.Error("AssociateTagHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -206,14 +344,14 @@ func AssociateTagHandler(w http.ResponseWriter, r *http.Request) {
 	payload.ItemType = strings.TrimSpace(strings.ToLower(payload.ItemType))
 	if payload.TagID == 0 || payload.ItemID == 0 || payload.ItemType == "" {
 		logger.Error("AssociateTagHandler: tag_id, item_id, and item_type are required. Got TagID: %d, ItemID: %d, ItemType: '%s'", payload.TagID, payload.ItemID, payload.ItemType)
-		http.Error(w, "tag_id, item_id, and item_type are required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "tag_id, item_id, and item_type are required")
 		return
 	}
 
 	association, err := database.AssociateTagWithItem(payload.TagID, payload.ItemID, payload.ItemType)
 	if err != nil {
 		// database.AssociateTagWithItem logs specific errors
-		http.Error(w, "Failed to associate tag: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to associate tag: "+err.Error())
 		return
 	}
 
@@ -231,27 +369,27 @@ func DisassociateTagHandler(w http.ResponseWriter, r *http.Request) {
 
 	if tagIDStr == "" || itemIDStr == "" || itemType == "" {
 		logger.Error("DisassociateTagHandler: tag_id, item_id, and item_type query parameters are required.")
-		http.Error(w, "tag_id, item_id, and item_type query parameters are required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "tag_id, item_id, and item_type query parameters are required")
 		return
 	}
 
 	tagID, err := strconv.ParseInt(tagIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DisassociateTagHandler: Invalid tag_id format '%s': %v", tagIDStr, err)
-		http.Error(w, "Invalid tag_id format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid tag_id format")
 		return
 	}
 
 	itemID, err := strconv.ParseInt(itemIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DisassociateTagHandler: Invalid item_id format '%s': %v", itemIDStr, err)
-		http.Error(w, "Invalid item_id format", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid item_id format")
 		return
 	}
 
 	if err := database.RemoveTagAssociation(tagID, itemID, itemType); err != nil {
 		// database.RemoveTagAssociation logs specific errors
-		http.Error(w, "Failed to remove tag association: "+err.Error(), http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to remove tag association: "+err.Error())
 		return
 	}
 
@@ -17,6 +17,10 @@ func RegisterTagRoutes(r chi.Router) {
 		subRouter.Get("/", GetTagByIDHandler)   // Assumes GetTagByIDHandler exists or will be created
 		subRouter.Put("/", UpdateTagHandler)    // Handler created in the previous step
 		subRouter.Delete("/", DeleteTagHandler) // Handler created in the previous step
+		subRouter.Get("/items", GetTaggedItemsHandler)
+		subRouter.Get("/usage", GetTagUsageHandler)
+		subRouter.Post("/merge", MergeTagsHandler)
+		subRouter.Post("/rename", RenameTagHandler)
 	})
 
 	// Routes for tag associations
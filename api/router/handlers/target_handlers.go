@@ -34,9 +34,7 @@ func createTarget(w http.ResponseWriter, r *http.Request) {
 	var req models.TargetCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("createTarget: Error decoding request body: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body: " + err.Error()})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -44,32 +42,24 @@ func createTarget(w http.ResponseWriter, r *http.Request) {
 	// Basic input validation in the handler
 	if req.PlatformID == 0 {
 		logger.Error("createTarget: PlatformID is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "PlatformID is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "PlatformID is required")
 		return
 	}
 	req.Codename = strings.TrimSpace(req.Codename)
 	if req.Codename == "" {
 		logger.Error("createTarget: Codename is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Codename is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Codename is required")
 		return
 	}
 	req.Link = strings.TrimSpace(req.Link)
 	if req.Link == "" {
 		logger.Error("createTarget: Link is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Link is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Link is required")
 		return
 	}
 	if !isValidURL(req.Link) { // Ensure isValidURL is defined in this file or package
 		logger.Error("createTarget: Invalid Link format: %s", req.Link)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Link must be a valid URL"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Link must be a valid URL")
 		return
 	}
 	// Validate scope item types before passing to DB layer
@@ -78,9 +68,7 @@ func createTarget(w http.ResponseWriter, r *http.Request) {
 		itemType := strings.ToLower(strings.TrimSpace(item.ItemType))
 		if itemType != "" && !validScopeItemTypes[itemType] { // Only validate if provided, DB layer will determine if empty
 			logger.Error("createTarget: Invalid item_type '%s' for pattern '%s'", item.ItemType, item.Pattern)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Invalid item_type '%s' provided for scope rule.", item.ItemType)})
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("Invalid item_type '%s' provided for scope rule.", item.ItemType))
 			return
 		}
 	}
@@ -90,19 +78,13 @@ func createTarget(w http.ResponseWriter, r *http.Request) {
 		// database.CreateTargetWithScopeRules handles specific error types like "platform not found" or "codename exists"
 		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "conflicts") {
 			logger.Error("createTarget: Conflict creating target: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: err.Error()})
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
 		} else if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "invalid item_type") {
 			logger.Error("createTarget: Bad request creating target: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: err.Error()})
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
 		} else {
 			logger.Error("createTarget: Internal server error: %v", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -125,9 +107,7 @@ func getTargets(w http.ResponseWriter, r *http.Request) {
 		pid, err := strconv.ParseInt(platformIDStr, 10, 64)
 		if err != nil {
 			logger.Error("getTargets: Invalid platform_id parameter '%s': %v", platformIDStr, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid platform_id parameter"})
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid platform_id parameter")
 			return
 		}
 		platformIDFilter = &pid
@@ -136,9 +116,7 @@ func getTargets(w http.ResponseWriter, r *http.Request) {
 	targets, err := database.GetTargets(platformIDFilter)
 	if err != nil {
 		logger.Error("getTargets: Error querying targets: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		return
 	}
 
@@ -160,7 +138,7 @@ func GetTargetByIDChiHandler(w http.ResponseWriter, r *http.Request) {
 	targetID, err := strconv.ParseInt(idOrSlug, 10, 64)
 	if err != nil {
 		logger.Error("GetTargetByIDChiHandler: Invalid target ID format '%s': %v", idOrSlug, err)
-		http.Error(w, "Invalid target ID (must be numeric for this endpoint)", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID (must be numeric for this endpoint)")
 		return
 	}
 	GetTargetByID(w, r, targetID)
@@ -171,7 +149,7 @@ func UpdateTargetDetailsHandler(w http.ResponseWriter, r *http.Request, targetID
 	var req models.TargetUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("UpdateTargetDetailsHandler: Error decoding request body for target ID %d: %v", targetID, err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -179,12 +157,12 @@ func UpdateTargetDetailsHandler(w http.ResponseWriter, r *http.Request, targetID
 	req.Link = strings.TrimSpace(req.Link)
 	if req.Link == "" {
 		logger.Error("UpdateTargetDetailsHandler: Link is required for target ID %d.", targetID)
-		http.Error(w, "Link is required.", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Link is required.")
 		return
 	}
 	if !strings.HasPrefix(req.Link, "#") && !isValidURL(req.Link) { // Ensure isValidURL is defined
 		logger.Error("UpdateTargetDetailsHandler: Invalid Link format '%s' for target ID %d.", req.Link, targetID)
-		http.Error(w, "Link must be a valid URL (e.g., http://example.com) or a placeholder starting with '#'.", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Link must be a valid URL (e.g., http://example.com) or a placeholder starting with '#'.")
 		return
 	}
 
@@ -192,10 +170,10 @@ func UpdateTargetDetailsHandler(w http.ResponseWriter, r *http.Request, targetID
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("UpdateTargetDetailsHandler: Target with ID %d not found for update.", targetID)
-			http.Error(w, fmt.Sprintf("Target with ID %d not found.", targetID), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Target with ID %d not found.", targetID))
 		} else {
 			logger.Error("UpdateTargetDetailsHandler: Error executing update for target ID %d: %v", targetID, err)
-			http.Error(w, "Internal server error during update", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during update")
 		}
 		return
 	}
@@ -210,7 +188,7 @@ func UpdateTargetDetailsChiHandler(w http.ResponseWriter, r *http.Request) {
 	targetID, err := strconv.ParseInt(idOrSlug, 10, 64)
 	if err != nil {
 		logger.Error("UpdateTargetDetailsChiHandler: Invalid target ID format '%s': %v", idOrSlug, err)
-		http.Error(w, "Invalid target ID (must be numeric for update)", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID (must be numeric for update)")
 		return
 	}
 	UpdateTargetDetailsHandler(w, r, targetID)
@@ -221,17 +199,13 @@ func DeleteTarget(w http.ResponseWriter, r *http.Request, identifier string) {
 	deleted, err := database.DeleteTargetByIDOrSlug(identifier)
 	if err != nil {
 		logger.Error("DeleteTarget: Error deleting target '%s': %v", identifier, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error during delete"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during delete")
 		return
 	}
 
 	if !deleted {
 		logger.Error("DeleteTarget: Target '%s' not found for deletion", identifier)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Target '%s' not found", identifier)})
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Target '%s' not found", identifier))
 		return
 	}
 
@@ -249,9 +223,7 @@ func DeleteTargetChiHandler(w http.ResponseWriter, r *http.Request) {
 func DeleteTargetByCodenameHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		logger.Error("DeleteTargetByCodenameHandler: MethodNotAllowed: %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Only DELETE method is allowed"})
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Only DELETE method is allowed")
 		return
 	}
 	codename := r.URL.Query().Get("codename")
@@ -259,35 +231,27 @@ func DeleteTargetByCodenameHandler(w http.ResponseWriter, r *http.Request) {
 
 	if codename == "" || platformIDStr == "" {
 		logger.Error("DeleteTargetByCodenameHandler: 'codename' and 'platform_id' query parameters are required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "'codename' and 'platform_id' query parameters are required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "'codename' and 'platform_id' query parameters are required")
 		return
 	}
 
 	platformID, err := strconv.ParseInt(platformIDStr, 10, 64)
 	if err != nil {
 		logger.Error("DeleteTargetByCodenameHandler: Invalid 'platform_id': %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid 'platform_id' parameter"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid 'platform_id' parameter")
 		return
 	}
 
 	deleted, err := database.DeleteTargetByCodenameAndPlatform(platformID, codename)
 	if err != nil {
 		logger.Error("DeleteTargetByCodenameHandler: Error deleting target by codename '%s', platform %d: %v", codename, platformID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error during delete"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during delete")
 		return
 	}
 
 	if !deleted {
 		logger.Error("DeleteTargetByCodenameHandler: No target found with codename '%s' for platform ID %d", codename, platformID)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("No target found with codename '%s' for platform ID %d", codename, platformID)})
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("No target found with codename '%s' for platform ID %d", codename, platformID))
 		return
 	}
 
@@ -301,14 +265,10 @@ func GetTargetByID(w http.ResponseWriter, r *http.Request, targetID int64) {
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Error("GetTargetByID: Target with ID %d not found", targetID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: fmt.Sprintf("Target with ID %d not found", targetID)})
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Target with ID %d not found", targetID))
 		} else {
 			logger.Error("GetTargetByID: Error querying target ID %d: %v", targetID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal server error"})
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -324,23 +284,21 @@ func GetTargetByID(w http.ResponseWriter, r *http.Request, targetID int64) {
 func PromoteSynackTargetHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logger.Error("PromoteSynackTargetHandler: MethodNotAllowed: %s", r.Method)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Method not allowed"})
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req models.PromoteSynackTargetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("PromoteSynackTargetHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.SynackTargetIDStr == "" || req.PlatformID == 0 {
 		logger.Error("PromoteSynackTargetHandler: synack_target_id_str and platform_id are required.")
-		http.Error(w, "synack_target_id_str and platform_id are required.", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "synack_target_id_str and platform_id are required.")
 		return
 	}
 
@@ -355,10 +313,10 @@ func PromoteSynackTargetHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("PromoteSynackTargetHandler: Synack target with ID_str '%s' not found.", req.SynackTargetIDStr)
-			http.Error(w, fmt.Sprintf("Synack target with ID '%s' not found.", req.SynackTargetIDStr), http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Synack target with ID '%s' not found.", req.SynackTargetIDStr))
 		} else {
 			logger.Error("PromoteSynackTargetHandler: Error fetching Synack target '%s': %v", req.SynackTargetIDStr, err)
-			http.Error(w, "Internal server error fetching Synack target.", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error fetching Synack target.")
 		}
 		return
 	}
@@ -379,19 +337,19 @@ func PromoteSynackTargetHandler(w http.ResponseWriter, r *http.Request) {
 	mainTargetCodename = strings.TrimSpace(mainTargetCodename)
 	if mainTargetCodename == "" {
 		logger.Error("PromoteSynackTargetHandler: Could not determine a codename for the new target.")
-		http.Error(w, "Codename for the new target is required (either override or from Synack data).", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Codename for the new target is required (either override or from Synack data).")
 		return
 	}
 
 	mainTargetLink := req.LinkOverride
 	if mainTargetLink == "" {
 		logger.Error("PromoteSynackTargetHandler: Link for the new target is required via link_override.")
-		http.Error(w, "link_override is required when promoting a Synack target.", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "link_override is required when promoting a Synack target.")
 		return
 	}
 	if !strings.HasPrefix(mainTargetLink, "#") && !isValidURL(mainTargetLink) { // Ensure isValidURL is defined
 		logger.Error("PromoteSynackTargetHandler: Invalid link_override format: %s. Must be a valid URL or a placeholder starting with '#'.", mainTargetLink)
-		http.Error(w, "link_override must be a valid URL or a placeholder starting with '#'.", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "link_override must be a valid URL or a placeholder starting with '#'.")
 		return
 	}
 
@@ -409,13 +367,13 @@ func PromoteSynackTargetHandler(w http.ResponseWriter, r *http.Request) {
 		// database.CreateTargetWithScopeRules handles specific error types
 		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "conflicts") {
 			logger.Error("PromoteSynackTargetHandler: Conflict creating main target: %v", err)
-			http.Error(w, err.Error(), http.StatusConflict)
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
 		} else if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "invalid item_type") {
 			logger.Error("PromoteSynackTargetHandler: Bad request creating main target: %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
 		} else {
 			logger.Error("PromoteSynackTargetHandler: Internal server error creating main target: %v", err)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error.")
 		}
 		return
 	}
@@ -443,8 +401,49 @@ func GetChecklistItemsForTargetChiHandler(w http.ResponseWriter, r *http.Request
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetChecklistItemsForTargetChiHandler: Invalid target ID format '%s': %v", targetIDStr, err)
-		http.Error(w, "Invalid target ID for checklist items (must be numeric)", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID for checklist items (must be numeric)")
 		return
 	}
 	GetChecklistItemsHandler(w, r, targetID) // This function is in checklist_handlers.go
 }
+
+// CloneTargetHandler creates a new target from an existing one with the selected components
+// (scope rules, exclusions, checklist items, notes, ...), so recurring engagements against the
+// same program start from a known baseline.
+func CloneTargetHandler(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.TargetCloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if strings.TrimSpace(req.Link) != "" && !isValidURL(strings.TrimSpace(req.Link)) {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Link must be a valid URL")
+		return
+	}
+
+	result, err := database.CloneTarget(sourceID, req)
+	if err != nil {
+		logger.Error("CloneTargetHandler: Error cloning target %d: %v", sourceID, err)
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		case strings.Contains(err.Error(), "already exists"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
+		case strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "invalid component") || strings.Contains(err.Error(), "does not exist"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		default:
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeTargetProgramError maps program metadata and scope history errors to HTTP status codes.
+func writeTargetProgramError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		return
+	}
+	logger.Error("%s: %v", handler, err)
+	WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Target program operation failed")
+}
+
+// parseProgramTargetID reads the numeric {idOrSlug} URL parameter.
+func parseProgramTargetID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "idOrSlug"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID (must be numeric for this endpoint)")
+		return 0, false
+	}
+	return targetID, true
+}
+
+// UpdateTargetProgramHandler updates the payout ranges, program rules and scope fetch time of a target.
+// Fields left out of the request are not changed.
+func UpdateTargetProgramHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := parseProgramTargetID(w, r)
+	if !ok {
+		return
+	}
+	var req models.TargetProgramRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if req.PayoutRanges != nil {
+		for _, p := range *req.PayoutRanges {
+			if strings.TrimSpace(p.Severity) == "" {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "payout range severity is required")
+				return
+			}
+			if p.Min < 0 || (p.Max != 0 && p.Max < p.Min) {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "payout range for '"+p.Severity+"' must have 0 <= min <= max")
+				return
+			}
+		}
+	}
+
+	if err := database.UpdateTargetProgram(targetID, req); err != nil {
+		writeTargetProgramError(w, r, "UpdateTargetProgramHandler", err)
+		return
+	}
+	GetTargetByID(w, r, targetID)
+}
+
+// GetScopeHistoryHandler lists the scope rules added to and removed from a target, newest first.
+// Query parameters: limit (default 100, max 1000).
+func GetScopeHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := parseProgramTargetID(w, r)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		writeTargetProgramError(w, r, "GetScopeHistoryHandler", err)
+		return
+	}
+	history, err := database.GetScopeRuleHistory(targetID, limit)
+	if err != nil {
+		writeTargetProgramError(w, r, "GetScopeHistoryHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetScopeChangesHandler returns the net scope changes of a target since the scope was last
+// reviewed. Query parameters: since (RFC3339 timestamp or YYYY-MM-DD; overrides the review time).
+func GetScopeChangesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := parseProgramTargetID(w, r)
+	if !ok {
+		return
+	}
+	var since *time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := parseAnnotationTime(v)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid since: "+err.Error())
+			return
+		}
+		since = &t
+	}
+	diff, err := database.GetScopeDiff(targetID, since)
+	if err != nil {
+		writeTargetProgramError(w, r, "GetScopeChangesHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// AcknowledgeScopeChangesHandler marks the current scope of a target as reviewed, so the next
+// scope changes request only reports changes made after this point.
+func AcknowledgeScopeChangesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := parseProgramTargetID(w, r)
+	if !ok {
+		return
+	}
+	if err := database.MarkTargetScopeReviewed(targetID); err != nil {
+		writeTargetProgramError(w, r, "AcknowledgeScopeChangesHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportPlatformScopeHandler fetches the scope of a HackerOne or Bugcrowd program and adds it to the
+// target as scope rules (and optionally domains). API credentials come from the platform_import config.
+func ImportPlatformScopeHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := parseProgramTargetID(w, r)
+	if !ok {
+		return
+	}
+	var req models.PlatformScopeImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	result, err := core.ImportPlatformScope(r.Context(), targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "platform API"), strings.Contains(msg, "sending request"):
+			logger.Error("ImportPlatformScopeHandler: %v", err)
+			WriteProblem(w, r, http.StatusBadGateway, models.ErrCodeUpstream, msg)
+		case strings.Contains(msg, "not configured"), strings.Contains(msg, "unsupported platform"), strings.Contains(msg, "is required"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		default:
+			writeTargetProgramError(w, r, "ImportPlatformScopeHandler", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -24,9 +24,21 @@ func RegisterTargetRoutes(r chi.Router) {
 		// Note: {idOrSlug} here should resolve to a numeric targetID for checklist items.
 		// The GetChecklistItemsForTargetChiHandler will need to parse it as int.
 		subRouter.Get("/checklist-items", GetChecklistItemsForTargetChiHandler) // New handler to be created
+
+		// Program metadata and scope history: /target/{targetID}/program, /target/{targetID}/scope-*
+		subRouter.Put("/program", UpdateTargetProgramHandler)
+		subRouter.Get("/scope-history", GetScopeHistoryHandler)
+		subRouter.Get("/scope-changes", GetScopeChangesHandler)
+		subRouter.Post("/scope-changes/ack", AcknowledgeScopeChangesHandler)
+		subRouter.Post("/scope-import", ImportPlatformScopeHandler)
 	})
 
 	// Specific operational routes
 	r.Delete("/targets/by-codename", DeleteTargetByCodenameHandler) // Existing handler
 	r.Post("/targets/from-synack", PromoteSynackTargetHandler)      // Existing handler
+	r.Post("/targets/{target_id}/clone", CloneTargetHandler)        // New target from an existing one's baseline
+
+	// Per-target overrides of runtime settings (see /settings/runtime)
+	r.Get("/targets/{target_id}/settings", GetTargetRuntimeSettingsHandler)
+	r.Put("/targets/{target_id}/settings", UpdateTargetRuntimeSettingsHandler)
 }
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StartTrafficAnalysisHandler starts a job running analyzers over the stored responses of a
+// target, optionally only those of a Content-Type. Progress is available from the jobs API.
+func StartTrafficAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.TrafficAnalysisRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartTrafficAnalysis(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "invalid analyzer"), strings.Contains(msg, "no stored responses"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("StartTrafficAnalysisHandler: Error starting traffic analysis for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start traffic analysis")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// StartTrafficBeautifyHandler starts a job storing beautified copies of the JavaScript, JSON, HTML
+// and XML responses that have none yet, of the target in the body or of all targets. Progress is
+// available from the jobs API.
+func StartTrafficBeautifyHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.BeautifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartTrafficBeautify(req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		default:
+			logger.Error("StartTrafficBeautifyHandler: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start beautifying")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetSecurityHeaderReportHandler grades the security headers observed in a target's captured
+// responses per host, listing missing and weak headers with example log IDs. ?host= limits the
+// report to one host.
+func GetSecurityHeaderReportHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	report, err := core.GetSecurityHeaderReport(targetID, r.URL.Query().Get("host"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetSecurityHeaderReportHandler: Error building report for target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to build security header report")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetTLSReportHandler flags weak TLS configurations of the hosts a target's traffic was sent to,
+// from the TLS connections the proxy made. ?host= limits the report to one host and
+// ?weak_only=true to weak hosts.
+func GetTLSReportHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	report, err := core.GetTLSReport(targetID, r.URL.Query().Get("host"), r.URL.Query().Get("weak_only") == "true")
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetTLSReportHandler: Error building report for target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to build TLS report")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetTLSConnectionHandler returns the TLS parameters of a connection the proxy made, with its
+// certificate chain and weaknesses.
+func GetTLSConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid TLS connection ID")
+		return
+	}
+	conn, err := core.GetTLSConnection(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetTLSConnectionHandler: Error loading TLS connection %d: %v", id, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to load TLS connection")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conn)
+}
+
+// GetIDORWorklistHandler lists where numeric and UUID identifiers appear in a target's captured
+// requests, per session, with suggested tests. ?host= limits the list to one host and ?limit=
+// caps the candidates.
+func GetIDORWorklistHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid limit")
+			return
+		}
+	}
+	worklist, err := core.GetIDORWorklist(targetID, r.URL.Query().Get("host"), limit)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetIDORWorklistHandler: Error building worklist for target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to build IDOR worklist")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(worklist)
+}
+
+// GetAuthSchemeReportHandler classifies the endpoints of a target's captured requests by auth
+// scheme (cookie, bearer, basic, api_key or none) and lists the possibly unauthenticated ones:
+// those that answered a request without credentials with a 2xx while sibling endpoints require
+// credentials. ?host= limits the report to one host.
+func GetAuthSchemeReportHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	report, err := core.GetAuthSchemeReport(targetID, r.URL.Query().Get("host"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+			return
+		}
+		logger.Error("GetAuthSchemeReportHandler: Error building report for target %d: %v", targetID, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to build auth scheme report")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetTrafficAnomaliesHandler lists a target's captured responses that are far larger or slower
+// than their endpoint's median, largest deviation first.
+// Query parameters: host, kind (size or latency), size_factor, latency_factor, min_samples
+// (defaults from the anomalies config) and limit (default 100).
+func GetTrafficAnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	q := r.URL.Query()
+	filters := models.TrafficAnomalyFilters{TargetID: targetID, Host: q.Get("host"), Kind: q.Get("kind"), Limit: 100}
+	for name, dst := range map[string]*float64{"size_factor": &filters.SizeFactor, "latency_factor": &filters.LatencyFactor} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = strconv.ParseFloat(v, 64); err != nil {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid "+name)
+				return
+			}
+		}
+	}
+	for name, dst := range map[string]*int{"min_samples": &filters.MinSamples, "limit": &filters.Limit} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil || *dst < 0 {
+				WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid "+name)
+				return
+			}
+		}
+	}
+	report, err := core.GetTrafficAnomalies(filters)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.HasPrefix(msg, "invalid"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		default:
+			logger.Error("GetTrafficAnomaliesHandler: Error detecting anomalies for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to detect traffic anomalies")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// StartCORSCheckHandler starts a job replaying captured GET endpoints of a target with crafted
+// Origin headers and filing Draft findings for those that trust them. Progress is available from
+// the jobs API.
+func StartCORSCheckHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.CORSCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	job, err := core.StartCORSCheck(targetID, req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+		case strings.Contains(msg, "invalid log entry"), strings.Contains(msg, "no captured"):
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+		case strings.Contains(msg, "already running"):
+			WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+		default:
+			logger.Error("StartCORSCheckHandler: Error starting CORS check for target %d: %v", targetID, err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to start CORS check")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
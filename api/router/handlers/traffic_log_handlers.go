@@ -8,156 +8,69 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
 )
 
 // GetTrafficLogHandler retrieves paginated and filtered HTTP traffic log entries for a target.
-// It supports filtering by various fields like method, status, content type, and a general search term.
-// It also provides distinct values for filter dropdowns in the UI.
+// It supports filtering by various fields like method, status, content type, and a general search term;
+// body searches the response bodies and their beautified copies.
+// It also provides distinct values for filter dropdowns in the UI. With pagination=cursor or a
+// cursor, pages follow the capture time and ID (prev_cursor/next_cursor) instead of page numbers.
+// capture_session_id narrows the logs to those captured within a capture session's time window.
 func GetTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		notImplementedHandler(w, r) // Assuming notImplementedHandler is in the same 'handlers' package
 		return
 	}
 
-	targetIDStr := r.URL.Query().Get("target_id")
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
-	sortByParam := r.URL.Query().Get("sort_by")
-	sortOrderParam := strings.ToUpper(r.URL.Query().Get("sort_order"))
-	favoritesOnlyStr := r.URL.Query().Get("favorites_only")
-	filterMethod := strings.ToUpper(r.URL.Query().Get("method"))
-	filterStatus := r.URL.Query().Get("status")
-	filterContentType := r.URL.Query().Get("type")
-	filterSearchText := r.URL.Query().Get("search")
-	filterTagIDsStr := r.URL.Query().Get("filter_tag_ids") // New: Filter by tag IDs
-	filterDomain := r.URL.Query().Get("domain")
+	// A saved view (view_id or view=name) fills in any filters not given explicitly.
+	viewTargetID, _ := strconv.ParseInt(r.URL.Query().Get("target_id"), 10, 64)
+	if err := applySavedView(r, models.SavedViewTypeTrafficLog, viewTargetID); err != nil {
+		writeApplySavedViewError(w, r, err)
+		return
+	}
 
+	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
 		logger.Error("GetTrafficLogHandler: target_id query parameter is required")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "target_id query parameter is required"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetTrafficLogHandler: Invalid target_id parameter '%s': %v", targetIDStr, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid target_id parameter, must be an integer"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id parameter, must be an integer")
 		return
 	}
 
-	page, _ := strconv.Atoi(pageStr)
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(limitStr)
-	if limit < 1 {
-		limit = 20
-	} else if limit > 200 { // Cap the limit to prevent DoS
-		limit = 200
+	filters := models.ProxyLogFiltersFromValues(targetID, r.URL.Query().Get)
+	conditions, err := database.TrafficLogConditions("htl", filters)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid origin parameter, must be 'manual' or 'automated'")
+		return
 	}
-	offset := (page - 1) * limit
-
-	whereClauses := []string{"htl.target_id = ?"} // Qualified target_id
-	queryArgs := []interface{}{targetID}
-
-	var tagIDsFilter []int64
-	if filterTagIDsStr != "" {
-		tagIDParts := strings.Split(filterTagIDsStr, ",")
-		for _, part := range tagIDParts {
-			trimmedPart := strings.TrimSpace(part)
-			if id, errConv := strconv.ParseInt(trimmedPart, 10, 64); errConv == nil && id > 0 {
-				tagIDsFilter = append(tagIDsFilter, id)
-			}
+	page := database.NewPage(filters.Page, filters.Limit, 20, 200, false)
+	var keyset *database.KeysetPage
+	if cursorPagination(r) {
+		kp, err := database.TrafficLogKeyset.Page(r.URL.Query().Get("cursor"), filters.SortBy, filters.SortOrder, page.Limit)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+			return
 		}
+		keyset = &kp
 	}
+	// The filter dropdowns list the values left by the other filters, so the method, status, type
+	// and source filters do not narrow their own options.
+	distinctFilters := filters
+	distinctFilters.FilterMethod, distinctFilters.FilterStatus, distinctFilters.FilterContentType, distinctFilters.FilterSource = "", "", "", ""
+	distinctConditions, _ := database.TrafficLogConditions("", distinctFilters)
+	finalDistinctWhereClause := distinctConditions.SQL()
+	distinctQueryArgs := distinctConditions.Args()
 
-	distinctWhereClauses := []string{"target_id = ?"}
-	distinctQueryArgs := []interface{}{targetID}
-
-	if favOnly, err := strconv.ParseBool(favoritesOnlyStr); err == nil && favOnly {
-		whereClauses = append(whereClauses, "htl.is_favorite = TRUE") // Alias added
-		distinctWhereClauses = append(distinctWhereClauses, "is_favorite = TRUE")
-	}
-
-	if filterMethod != "" {
-		whereClauses = append(whereClauses, "UPPER(htl.request_method) = ?") // Alias added
-		queryArgs = append(queryArgs, filterMethod)
-		// distinctWhereClauses for method is not explicitly added here, it's part of finalDistinctWhereClause construction
-	}
-
-	if filterStatus != "" {
-		statusCode, err := strconv.ParseInt(filterStatus, 10, 64)
-		if err == nil {
-			whereClauses = append(whereClauses, "htl.response_status_code = ?") // Alias added
-			queryArgs = append(queryArgs, statusCode)
-		} else {
-			logger.Info("GetTrafficLogHandler: Invalid status code filter '%s': %v. Ignoring filter.", filterStatus, err)
-		}
-	}
-
-	if filterContentType != "" {
-		whereClauses = append(whereClauses, "LOWER(htl.response_content_type) LIKE LOWER(?)") // Alias added
-		queryArgs = append(queryArgs, "%"+filterContentType+"%")
-	}
-
-	if filterDomain != "" {
-		// This pattern is more robust than a simple LIKE %domain%
-		// It ensures we match the hostname part of the URL.
-		domainClause := `(
-            htl.request_url LIKE 'http://' || ? || '/%' OR
-            htl.request_url LIKE 'https://' || ? || '/%' OR
-            htl.request_url = 'http://' || ? OR
-            htl.request_url = 'https://' || ? OR
-            htl.request_url LIKE 'http://' || ? || ':%' OR
-            htl.request_url LIKE 'https://' || ? || ':%'
-        )`
-		whereClauses = append(whereClauses, domainClause)
-		queryArgs = append(queryArgs, filterDomain, filterDomain, filterDomain, filterDomain, filterDomain, filterDomain)
-
-		// Also add to distinct clauses to filter the dropdowns correctly
-		// Note: No 'htl.' alias needed here as it's a single table query for distinct values
-		distinctDomainClause := `(request_url LIKE 'http://' || ? || '/%' OR request_url LIKE 'https://' || ? || '/%' OR request_url = 'http://' || ? OR request_url = 'https://' || ? OR request_url LIKE 'http://' || ? || ':%' OR request_url LIKE 'https://' || ? || ':%')`
-		distinctWhereClauses = append(distinctWhereClauses, distinctDomainClause)
-		distinctQueryArgs = append(distinctQueryArgs, filterDomain, filterDomain, filterDomain, filterDomain, filterDomain, filterDomain)
-	}
-
-	if filterSearchText != "" {
-		unaliasedSearchClause := `(LOWER(request_url) LIKE LOWER(?) OR UPPER(request_method) LIKE UPPER(?) OR LOWER(response_content_type) LIKE LOWER(?) OR CAST(response_status_code AS TEXT) LIKE ?)`
-		aliasedSearchClause := `(LOWER(htl.request_url) LIKE LOWER(?) OR UPPER(htl.request_method) LIKE UPPER(?) OR LOWER(htl.response_content_type) LIKE LOWER(?) OR CAST(htl.response_status_code AS TEXT) LIKE ?)`
-		whereClauses = append(whereClauses, aliasedSearchClause)
-		distinctWhereClauses = append(distinctWhereClauses, unaliasedSearchClause)
-		searchPattern := "%" + filterSearchText + "%"
-		queryArgs = append(queryArgs, searchPattern, searchPattern, searchPattern, searchPattern)
-		distinctQueryArgs = append(distinctQueryArgs, searchPattern, searchPattern, searchPattern, searchPattern)
-	}
-
-	if len(tagIDsFilter) > 0 {
-		placeholders := make([]string, len(tagIDsFilter))
-		tagIDArgs := make([]interface{}, len(tagIDsFilter))
-		for i, id := range tagIDsFilter {
-			placeholders[i] = "?"
-			tagIDArgs[i] = id
-		}
-		tagFilterClause := fmt.Sprintf("htl.id IN (SELECT DISTINCT item_id FROM tag_associations WHERE item_type = 'httplog' AND tag_id IN (%s))", strings.Join(placeholders, ","))
-		whereClauses = append(whereClauses, tagFilterClause)
-		queryArgs = append(queryArgs, tagIDArgs...)
-
-		// For distinct values, we need to ensure the logs considered for distinct methods/statuses etc. also match the tag filter.
-		// The distinctWhereClauses and distinctQueryArgs are used for fetching distinct methods, statuses, types.
-		// If we want these dropdowns to be reactive to the tag filter, we add the tag filter here too.
-		distinctWhereClauses = append(distinctWhereClauses, fmt.Sprintf("id IN (SELECT DISTINCT item_id FROM tag_associations WHERE item_type = 'httplog' AND tag_id IN (%s))", strings.Join(placeholders, ",")))
-		distinctQueryArgs = append(distinctQueryArgs, tagIDArgs...)
-	}
-
-	finalWhereClause := strings.Join(whereClauses, " AND ")
-	finalDistinctWhereClause := strings.Join(distinctWhereClauses, " AND ")
 	distinctValues := make(map[string]interface{}) // Changed to interface{} to support different value types
 
 	methodQuery := fmt.Sprintf("SELECT DISTINCT request_method FROM http_traffic_log WHERE %s ORDER BY request_method ASC", finalDistinctWhereClause)
@@ -202,14 +115,31 @@ func GetTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
 			logger.Error("GetTrafficLogHandler: Error iterating distinct statuses: %v", err)
 		}
 	}
+	sourceQuery := fmt.Sprintf("SELECT DISTINCT COALESCE(NULLIF(log_source, ''), ?) AS source FROM http_traffic_log WHERE %s ORDER BY source ASC", finalDistinctWhereClause)
+	rows, err = database.DB.Query(sourceQuery, append([]interface{}{models.LogSourceProxy}, distinctQueryArgs...)...)
+	if err != nil {
+		logger.Error("GetTrafficLogHandler: Error fetching distinct sources for target %d: %v", targetID, err)
+	} else {
+		defer rows.Close()
+		sources := []string{}
+		var source string
+		for rows.Next() {
+			if err := rows.Scan(&source); err == nil {
+				sources = append(sources, source)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			logger.Error("GetTrafficLogHandler: Error iterating distinct sources: %v", err)
+		}
+		distinctValues["source"] = sources
+	}
+
 	var totalRecords int64
-	countQuery := fmt.Sprintf("SELECT COUNT(htl.id) FROM http_traffic_log htl WHERE %s", finalWhereClause)
-	err = database.DB.QueryRow(countQuery, queryArgs...).Scan(&totalRecords)
+	countQuery := "SELECT COUNT(htl.id) FROM http_traffic_log htl " + conditions.Where()
+	err = database.DB.QueryRow(countQuery, conditions.Args()...).Scan(&totalRecords)
 	if err != nil {
 		logger.Error("GetTrafficLogHandler: Error counting traffic logs for target %d: %v", targetID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Error counting traffic logs"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error counting traffic logs")
 		return
 	}
 
@@ -264,56 +194,29 @@ func GetTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
 		distinctValues["tags"] = distinctTags // Store as []models.Tag
 	}
 
-	totalPages := int64(0)
-	if totalRecords > 0 { // limit is guaranteed to be > 0 due to earlier checks
-		totalPages = (totalRecords + int64(limit) - 1) / int64(limit)
+	// A page past the end shows the last page.
+	page = page.Clamp(totalRecords)
+	totalPages := page.TotalPages(totalRecords)
+	orderBy := database.TrafficLogSort.OrderBy(filters.SortBy, filters.SortOrder)
+	limitClause, limitArgs := page.LimitClause()
+	if keyset != nil {
+		page.Number = 0 // Cursor pages have no number
+		orderBy = keyset.Apply(&conditions, "htl")
+		limitClause, limitArgs = keyset.LimitClause()
 	}
 
-	if page > int(totalPages) && totalPages > 0 {
-		page = int(totalPages)
-		offset = (page - 1) * limit
-	}
-
-	allowedSortColumns := map[string]string{
-		"id":                    "htl.id",
-		"timestamp":             "htl.timestamp",
-		"request_method":        "htl.request_method",
-		"request_url":           "htl.request_url",
-		"response_status_code":  "htl.response_status_code",
-		"response_content_type": "htl.response_content_type",
-		"response_body_size":    "htl.response_body_size",
-		"page_sitemap_id":       "htl.page_sitemap_id", // Qualified
-		"tags":                  "(SELECT GROUP_CONCAT(t_sort.name ORDER BY t_sort.name ASC) FROM tags t_sort JOIN tag_associations ta_sort ON t_sort.id = ta_sort.tag_id WHERE ta_sort.item_id = htl.id AND ta_sort.item_type = 'httplog')",
-		"page_sitemap_name":     "page_sitemap_name", // This is an alias from SELECT
-		"duration_ms":           "htl.duration_ms",
-	}
-
-	dbSortColumn := "htl.timestamp" // Default sort, aliased
-	if col, ok := allowedSortColumns[sortByParam]; ok {
-		dbSortColumn = col
-	}
-
-	dbSortOrder := "DESC"
-	if sortOrderParam == "ASC" || sortOrderParam == "DESC" {
-		dbSortOrder = sortOrderParam
-	}
-
-	orderByClause := fmt.Sprintf("ORDER BY %s %s, htl.id %s", dbSortColumn, dbSortOrder, dbSortOrder) // Alias id
-
-	finalQueryString := fmt.Sprintf(`SELECT htl.id, htl.timestamp, htl.request_method, htl.request_url, htl.request_full_url_with_fragment,
+	finalQueryString := `SELECT htl.id, htl.timestamp, htl.request_method, htl.request_url, htl.request_full_url_with_fragment,
 	                 htl.response_status_code, htl.response_content_type, htl.response_body_size, htl.duration_ms, htl.is_favorite,
 	                 htl.log_source, htl.page_sitemap_id, p.name as page_sitemap_name
 	          FROM http_traffic_log htl LEFT JOIN pages p ON htl.page_sitemap_id = p.id
-	          WHERE %s %s LIMIT ? OFFSET ?`, finalWhereClause, orderByClause)
+	          ` + conditions.Where() + " " + orderBy + limitClause
 
-	finalQueryArgs := append(queryArgs, limit, offset)
+	finalQueryArgs := conditions.Args(limitArgs...)
 
 	rows, err = database.DB.Query(finalQueryString, finalQueryArgs...)
 	if err != nil {
 		logger.Error("GetTrafficLogHandler: Error querying traffic logs for target %d: %v", targetID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Error retrieving traffic logs"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error retrieving traffic logs")
 		return
 	}
 	defer rows.Close()
@@ -354,9 +257,17 @@ func GetTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
 			t.IsFavorite = isFavorite.Bool
 		}
 		t.TargetID = &targetID
+		t.Origin = models.TrafficOrigin(t.LogSource.String)
 		logs = append(logs, t)
 	}
 
+	var prevCursor, nextCursor string
+	if keyset != nil {
+		if logs, prevCursor, nextCursor, err = database.KeysetRows(*keyset, logs, func(l models.HTTPTrafficLog) int64 { return l.ID }); err != nil {
+			logger.Error("GetTrafficLogHandler: %v", err)
+		}
+	}
+
 	// Fetch tags for all logs retrieved in this page
 	if len(logs) > 0 {
 		logIDs := make([]int64, len(logs))
@@ -375,25 +286,27 @@ func GetTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	if err = rows.Err(); err != nil {
 		logger.Error("GetTrafficLogHandler: Error iterating traffic log rows: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Error processing traffic log results"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error processing traffic log results")
 		return
 	}
 
 	response := struct {
 		Logs           []models.HTTPTrafficLog `json:"logs"`
-		Page           int                     `json:"page"`
+		Page           int                     `json:"page,omitempty"` // Not set for cursor pages
 		Limit          int                     `json:"limit"`
 		TotalRecords   int64                   `json:"total_records"`
 		TotalPages     int64                   `json:"total_pages"` // Corrected type
+		PrevCursor     string                  `json:"prev_cursor,omitempty"`
+		NextCursor     string                  `json:"next_cursor,omitempty"`
 		DistinctValues map[string]interface{}  `json:"distinct_values"`
 	}{
 		Logs:           logs,
-		Page:           page,
-		Limit:          limit,
+		Page:           page.Number,
+		Limit:          page.Limit,
 		TotalRecords:   totalRecords,
 		TotalPages:     totalPages,
+		PrevCursor:     prevCursor,
+		NextCursor:     nextCursor,
 		DistinctValues: distinctValues,
 	}
 
@@ -401,7 +314,96 @@ func GetTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		logger.Error("GetTrafficLogHandler: Error encoding response for target %d: %v", targetID, err)
 	}
-	logger.Info("GetTrafficLogHandler: Served %d traffic logs for target %d, page %d", len(logs), targetID, page)
+	logger.Info("GetTrafficLogHandler: Served %d traffic logs for target %d, page %d", len(logs), targetID, page.Number)
+}
+
+// StreamTrafficLogHandler streams new traffic log entries as server-sent events, like
+// `toolkit traffic tail`. Each "traffic" event carries an entry as JSON, with its ID as the event
+// ID, so a reconnect resumes after Last-Event-ID. The filters (and view/view_id) are those of
+// GET /traffic-log, with target_id optional. after_id starts after an entry; otherwise the last
+// lines (default 0) matching entries are sent first. full=true includes headers and bodies; it is
+// forbidden to API roles whose responses are redacted.
+func StreamTrafficLogHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	full := q.Get("full") == "true"
+	if full {
+		// Raw headers and bodies are only streamed to roles that may see them unredacted.
+		role, _, err := core.ResolveAPIRole(r.Header.Get("Authorization"))
+		if _, restricted := core.APIRoleRedaction(role); err != nil || restricted {
+			WriteProblem(w, r, http.StatusForbidden, models.ErrCodeForbidden, "full=true is not allowed for this API role; tail without it")
+			return
+		}
+	}
+	var targetID int64
+	if v := q.Get("target_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
+			return
+		}
+		targetID = id
+	}
+	if err := applySavedView(r, models.SavedViewTypeTrafficLog, targetID); err != nil {
+		writeApplySavedViewError(w, r, err)
+		return
+	}
+	conditions, err := database.TrafficLogConditions("", models.ProxyLogFiltersFromValues(targetID, r.URL.Query().Get))
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid origin, must be 'manual' or 'automated'")
+		return
+	}
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = q.Get("after_id")
+	}
+	var afterID int64
+	if after != "" {
+		if afterID, err = strconv.ParseInt(after, 10, 64); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid after_id")
+			return
+		}
+	} else {
+		lines, _ := strconv.Atoi(q.Get("lines"))
+		if afterID, err = database.TrafficTailStart(conditions, lines); err != nil {
+			logger.Error("StreamTrafficLogHandler: %v", err)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Error reading the traffic log")
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	lastWrite := time.Now()
+	err = core.TailTrafficLogs(r.Context(), conditions, afterID, full, func(entries []models.HTTPTrafficLog) error {
+		for _, e := range entries {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: traffic\ndata: %s\nid: %d\n\n", data, e.ID)
+		}
+		if len(entries) == 0 {
+			if time.Since(lastWrite) < 15*time.Second {
+				return nil
+			}
+			// Comments keep proxies from closing an idle stream.
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+		lastWrite = time.Now()
+		return nil
+	})
+	if err != nil {
+		logger.Error("StreamTrafficLogHandler: %v", err)
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+		flusher.Flush()
+	}
 }
 
 // LogEntryDetailResponse is a wrapper for HTTPTrafficLog to include navigation IDs.
@@ -410,6 +412,8 @@ type LogEntryDetailResponse struct {
 	PrevLogID *int64       `json:"prev_log_id,omitempty"`
 	NextLogID *int64       `json:"next_log_id,omitempty"`
 	Tags      []models.Tag `json:"tags,omitempty"` // Added to include associated tags
+	// TLS parameters negotiated with the server, for entries captured over TLS
+	TLSConnection *models.TLSConnection `json:"tls_connection,omitempty"`
 }
 
 // getTrafficLogEntryDetail fetches full details for a single traffic log entry,
@@ -424,12 +428,13 @@ func getTrafficLogEntryDetail(w http.ResponseWriter, r *http.Request, logID int6
 	var notes sql.NullString
 
 	query := `SELECT htl.id, htl.target_id, htl.timestamp, htl.request_method, htl.request_url, 
-	                 htl.request_http_version, htl.request_headers, htl.request_body, 
+	                 htl.request_http_version, htl.request_headers, ` + database.TrafficBodyExpr("htl", "request_body") + `, 
 					 htl.response_status_code, htl.response_reason_phrase, htl.response_http_version, 
-					 htl.response_headers, htl.response_body, htl.response_content_type, 
+					 htl.response_headers, ` + database.TrafficBodyExpr("htl", "response_body") + `, htl.response_content_type, 
 					 htl.response_body_size, htl.duration_ms, htl.client_ip, htl.server_ip, 
 					 htl.is_https, htl.is_page_candidate, htl.notes, htl.is_favorite, 
-					 htl.request_full_url_with_fragment, htl.page_sitemap_id, p.name as page_sitemap_name
+					 htl.request_full_url_with_fragment, htl.page_sitemap_id, p.name as page_sitemap_name,
+					 htl.tls_connection_id
 			  FROM http_traffic_log htl
 			  LEFT JOIN pages p ON htl.page_sitemap_id = p.id
 			  WHERE htl.id = ?`
@@ -443,15 +448,16 @@ func getTrafficLogEntryDetail(w http.ResponseWriter, r *http.Request, logID int6
 		&logEntry.IsHTTPS, &logEntry.IsPageCandidate, &notes, &logEntry.IsFavorite,
 		&logEntry.RequestFullURLWithFragment,
 		&logEntry.PageSitemapID, &logEntry.PageSitemapName, // Scan the new page sitemap fields
+		&logEntry.TLSConnectionID,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			logger.Error("getTrafficLogEntryDetail: Log entry with ID %d not found", logID)
-			http.Error(w, "Log entry not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Log entry not found")
 		} else {
 			logger.Error("getTrafficLogEntryDetail: Error querying log entry ID %d: %v", logID, err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		}
 		return
 	}
@@ -484,47 +490,19 @@ func getTrafficLogEntryDetail(w http.ResponseWriter, r *http.Request, logID int6
 	}
 
 	if logEntry.TargetID != nil && *logEntry.TargetID > 0 {
-		queryParams := r.URL.Query()
-		favoritesOnlyStr := queryParams.Get("favorites_only")
-		filterMethod := strings.ToUpper(queryParams.Get("method"))
-		filterStatus := queryParams.Get("status")
-		filterContentType := queryParams.Get("type")
-		filterSearchText := queryParams.Get("search")
-
-		filterClauses := []string{"target_id = ?"}
-		filterArgs := []interface{}{*logEntry.TargetID}
-
-		if favOnly, errFav := strconv.ParseBool(favoritesOnlyStr); errFav == nil && favOnly {
-			filterClauses = append(filterClauses, "is_favorite = TRUE")
-		}
-		if filterMethod != "" {
-			filterClauses = append(filterClauses, "UPPER(request_method) = ?")
-			filterArgs = append(filterArgs, filterMethod)
-		}
-		if filterStatus != "" {
-			if statusCode, errStatus := strconv.ParseInt(filterStatus, 10, 64); errStatus == nil {
-				filterClauses = append(filterClauses, "response_status_code = ?")
-				filterArgs = append(filterArgs, statusCode)
-			}
-		}
-		if filterContentType != "" {
-			filterClauses = append(filterClauses, "LOWER(response_content_type) LIKE LOWER(?)")
-			filterArgs = append(filterArgs, "%"+filterContentType+"%")
-		}
-		if filterSearchText != "" {
-			searchClause := `(LOWER(request_url) LIKE LOWER(?) OR UPPER(request_method) LIKE UPPER(?) OR LOWER(response_content_type) LIKE LOWER(?) OR CAST(response_status_code AS TEXT) LIKE ?)`
-			filterClauses = append(filterClauses, searchClause)
-			searchPattern := "%" + filterSearchText + "%"
-			filterArgs = append(filterArgs, searchPattern, searchPattern, searchPattern, searchPattern)
+		// Previous and next follow the filters of the list the entry was opened from.
+		filters := models.ProxyLogFiltersFromValues(*logEntry.TargetID, r.URL.Query().Get)
+		conditions, errFilters := database.TrafficLogConditions("", filters)
+		if errFilters != nil {
+			logger.Info("getTrafficLogEntryDetail: Ignoring filters of log %d: %v", logID, errFilters)
+			conditions, _ = database.TrafficLogConditions("", models.ProxyLogFilters{TargetID: *logEntry.TargetID})
 		}
-
-		filterCondition := strings.Join(filterClauses, " AND ")
 		var prevID, nextID sql.NullInt64
 
-		prevQuerySQL := fmt.Sprintf(`SELECT id FROM http_traffic_log 
-		                             WHERE %s AND ((timestamp = ? AND id < ?) OR timestamp < ?) 
-									 ORDER BY timestamp DESC, id DESC LIMIT 1`, filterCondition)
-		prevArgs := append(filterArgs, logEntry.Timestamp, logEntry.ID, logEntry.Timestamp)
+		prevQuerySQL := `SELECT id FROM http_traffic_log
+		                 WHERE ` + conditions.SQL() + ` AND ((timestamp = ? AND id < ?) OR timestamp < ?)
+		                 ORDER BY timestamp DESC, id DESC LIMIT 1`
+		prevArgs := conditions.Args(logEntry.Timestamp, logEntry.ID, logEntry.Timestamp)
 
 		errPrev := database.DB.QueryRow(prevQuerySQL, prevArgs...).Scan(&prevID)
 		if errPrev == nil && prevID.Valid {
@@ -533,10 +511,10 @@ func getTrafficLogEntryDetail(w http.ResponseWriter, r *http.Request, logID int6
 			logger.Error("getTrafficLogEntryDetail: Error querying previous (filtered) log ID for log %d: %v", logID, errPrev)
 		}
 
-		nextQuerySQL := fmt.Sprintf(`SELECT id FROM http_traffic_log 
-		                             WHERE %s AND ((timestamp = ? AND id > ?) OR timestamp > ?) 
-									 ORDER BY timestamp ASC, id ASC LIMIT 1`, filterCondition)
-		nextArgs := append(filterArgs, logEntry.Timestamp, logEntry.ID, logEntry.Timestamp)
+		nextQuerySQL := `SELECT id FROM http_traffic_log
+		                 WHERE ` + conditions.SQL() + ` AND ((timestamp = ? AND id > ?) OR timestamp > ?)
+		                 ORDER BY timestamp ASC, id ASC LIMIT 1`
+		nextArgs := conditions.Args(logEntry.Timestamp, logEntry.ID, logEntry.Timestamp)
 
 		errNext := database.DB.QueryRow(nextQuerySQL, nextArgs...).Scan(&nextID)
 		if errNext == nil && nextID.Valid {
@@ -546,6 +524,14 @@ func getTrafficLogEntryDetail(w http.ResponseWriter, r *http.Request, logID int6
 		}
 	}
 
+	if logEntry.TLSConnectionID.Valid {
+		if conn, errTLS := core.GetTLSConnection(logEntry.TLSConnectionID.Int64); errTLS != nil {
+			logger.Error("getTrafficLogEntryDetail: Error fetching TLS connection of log ID %d: %v", logID, errTLS)
+		} else {
+			responsePayload.TLSConnection = &conn
+		}
+	}
+
 	// Fetch associated tags
 	tags, tagsErr := database.GetTagsForItem(logID, "httplog") // Assuming "httplog" is the item_type for traffic logs
 	if tagsErr != nil {
@@ -570,7 +556,7 @@ func updateTrafficLogEntryNotes(w http.ResponseWriter, r *http.Request, logID in
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("updateTrafficLogEntryNotes: Error decoding request body for log ID %d: %v", logID, err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
@@ -578,7 +564,7 @@ func updateTrafficLogEntryNotes(w http.ResponseWriter, r *http.Request, logID in
 	stmt, err := database.DB.Prepare("UPDATE http_traffic_log SET notes = ? WHERE id = ?")
 	if err != nil {
 		logger.Error("updateTrafficLogEntryNotes: Error preparing update statement for log ID %d: %v", logID, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error")
 		return
 	}
 	defer stmt.Close()
@@ -586,14 +572,14 @@ func updateTrafficLogEntryNotes(w http.ResponseWriter, r *http.Request, logID in
 	result, err := stmt.Exec(req.Notes, logID)
 	if err != nil {
 		logger.Error("updateTrafficLogEntryNotes: Error executing update for log ID %d: %v", logID, err)
-		http.Error(w, "Internal server error during update", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during update")
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
 		logger.Error("updateTrafficLogEntryNotes: Log entry with ID %d not found for notes update", logID)
-		http.Error(w, fmt.Sprintf("Log entry with ID %d not found", logID), http.StatusNotFound)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry with ID %d not found", logID))
 		return
 	}
 
@@ -610,30 +596,18 @@ func setTrafficLogEntryFavoriteStatus(w http.ResponseWriter, r *http.Request, lo
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("setTrafficLogEntryFavoriteStatus: Error decoding request body for log ID %d: %v", logID, err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
-	stmt, err := database.DB.Prepare("UPDATE http_traffic_log SET is_favorite = ? WHERE id = ?")
-	if err != nil {
-		logger.Error("setTrafficLogEntryFavoriteStatus: Error preparing update statement for log ID %d: %v", logID, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer stmt.Close()
-
-	result, err := stmt.Exec(req.IsFavorite, logID)
-	if err != nil {
-		logger.Error("setTrafficLogEntryFavoriteStatus: Error executing update for log ID %d: %v", logID, err)
-		http.Error(w, "Internal server error during update", http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		logger.Error("setTrafficLogEntryFavoriteStatus: Log entry with ID %d not found for favorite update", logID)
-		http.Error(w, fmt.Sprintf("Log entry with ID %d not found", logID), http.StatusNotFound)
+	if err := database.SetHTTPTrafficLogFavorite(logID, req.IsFavorite); err != nil {
+		logger.Error("setTrafficLogEntryFavoriteStatus: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, err.Error())
+		} else {
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Internal server error during update")
+		}
 		return
 	}
 
@@ -649,9 +623,7 @@ func DeleteTrafficLogsForTargetHandler(w http.ResponseWriter, r *http.Request, t
 
 	if targetID == 0 {
 		logger.Error("API DeleteTrafficLogs: Target ID 0 provided for deleting logs, which is invalid.")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Target ID cannot be zero"})
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Target ID cannot be zero")
 		return
 	}
 
@@ -660,25 +632,19 @@ func DeleteTrafficLogsForTargetHandler(w http.ResponseWriter, r *http.Request, t
 	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM targets WHERE id = ?)", targetID).Scan(&exists)
 	if err != nil {
 		logger.Error("API DeleteTrafficLogs: Error checking existence of target ID %d before deleting logs: %v", targetID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to verify target existence"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to verify target existence")
 		return
 	}
 	if !exists {
 		logger.Info("API DeleteTrafficLogs: Attempt to delete logs for non-existent target ID %d", targetID)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Target not found, no logs to delete"})
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Target not found, no logs to delete")
 		return
 	}
 
 	stmt, err := database.DB.Prepare("DELETE FROM http_traffic_log WHERE target_id = ?")
 	if err != nil {
 		logger.Error("API DeleteTrafficLogs: Error preparing delete statement for http_traffic_log, target_id %d: %v", targetID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to prepare log deletion query"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to prepare log deletion query")
 		return
 	}
 	defer stmt.Close()
@@ -686,9 +652,7 @@ func DeleteTrafficLogsForTargetHandler(w http.ResponseWriter, r *http.Request, t
 	result, err := stmt.Exec(targetID)
 	if err != nil {
 		logger.Error("API DeleteTrafficLogs: Error executing delete on http_traffic_log for target_id %d: %v", targetID, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to delete logs for target"})
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to delete logs for target")
 		return
 	}
 
@@ -700,7 +664,7 @@ func DeleteTrafficLogsForTargetHandler(w http.ResponseWriter, r *http.Request, t
 // AnalyzeCommentsHandler handles requests to find comments in a log entry's response body.
 func AnalyzeCommentsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -710,25 +674,25 @@ func AnalyzeCommentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("AnalyzeCommentsHandler: Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body: "+err.Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.HTTPLogID == 0 {
-		http.Error(w, "http_log_id is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "http_log_id is required")
 		return
 	}
 
 	// Fetch the response body for the given log ID
 	var responseBody []byte
-	err := database.DB.QueryRow("SELECT response_body FROM http_traffic_log WHERE id = ?", req.HTTPLogID).Scan(&responseBody)
+	err := database.DB.QueryRow("SELECT "+database.TrafficBodyExpr("http_traffic_log", "response_body")+" FROM http_traffic_log WHERE id = ?", req.HTTPLogID).Scan(&responseBody)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "Log entry not found", http.StatusNotFound)
+			WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, "Log entry not found")
 		} else {
 			logger.Error("AnalyzeCommentsHandler: Error fetching response body for log ID %d: %v", req.HTTPLogID, err)
-			http.Error(w, "Failed to fetch log entry data", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to fetch log entry data")
 		}
 		return
 	}
@@ -748,14 +712,14 @@ func GetDistinctDomainsForTargetLogsHandler(w http.ResponseWriter, r *http.Reque
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil || targetID == 0 {
 		logger.Error("GetDistinctDomainsForTargetLogsHandler: Invalid or missing target_id: %v", err)
-		http.Error(w, "Invalid or missing target_id", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid or missing target_id")
 		return
 	}
 
 	domains, err := database.GetDistinctDomainsFromLogs(targetID)
 	if err != nil {
 		logger.Error("GetDistinctDomainsForTargetLogsHandler: Error fetching distinct domains for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve distinct domains", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve distinct domains")
 		return
 	}
 
@@ -765,3 +729,55 @@ func GetDistinctDomainsForTargetLogsHandler(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(domains)
 }
+
+// GetTrafficDiffHandler returns a structural diff of headers and bodies between two log entries,
+// e.g. an original request and its tampered or replayed counterpart.
+// Query parameters: from (log ID), to (log ID), beautify (true to compare the beautified copies of
+// the response bodies, for minified JavaScript and HTML).
+func GetTrafficDiffHandler(w http.ResponseWriter, r *http.Request) {
+	fromID, errFrom := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	toID, errTo := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil || fromID <= 0 || toID <= 0 {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Query parameters 'from' and 'to' must be valid log IDs")
+		return
+	}
+
+	fromLog, err := database.GetHTTPTrafficLogEntryByID(fromID)
+	if err != nil {
+		logger.Error("GetTrafficDiffHandler: Error fetching log %d: %v", fromID, err)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry %d not found", fromID))
+		return
+	}
+	toLog, err := database.GetHTTPTrafficLogEntryByID(toID)
+	if err != nil {
+		logger.Error("GetTrafficDiffHandler: Error fetching log %d: %v", toID, err)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry %d not found", toID))
+		return
+	}
+
+	var diff core.TrafficDiff
+	if beautify, _ := strconv.ParseBool(r.URL.Query().Get("beautify")); beautify {
+		diff = core.DiffBeautifiedTrafficLogs(fromLog, toLog)
+	} else {
+		diff = core.DiffTrafficLogs(fromLog, toLog)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// ExportTrafficLogEntryHandler exports a log entry's request as a curl command or raw HTTP message.
+// Query parameter: format (curl, raw; default curl).
+func ExportTrafficLogEntryHandler(w http.ResponseWriter, r *http.Request, logID int64) {
+	logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		logger.Error("ExportTrafficLogEntryHandler: Error fetching log %d: %v", logID, err)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry %d not found", logID))
+		return
+	}
+	requestURL := logEntry.RequestURL.String
+	if logEntry.RequestFullURLWithFragment.Valid && logEntry.RequestFullURLWithFragment.String != "" {
+		requestURL = logEntry.RequestFullURLWithFragment.String
+	}
+	writeRequestExport(w, r, r.URL.Query().Get("format"), logEntry.TargetID, logEntry.RequestMethod.String, requestURL,
+		core.HeadersFromJSON(logEntry.RequestHeaders.String), logEntry.RequestBody)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"toolkit/models"
 
 	"toolkit/logger"
 
@@ -12,6 +13,14 @@ import (
 func RegisterTrafficLogRoutes(r chi.Router) {
 	r.Get("/traffic-log", GetTrafficLogHandler)
 	r.Get("/traffic-log/distinct-domains", GetDistinctDomainsForTargetLogsHandler)
+	r.Get("/traffic-log/stream", StreamTrafficLogHandler) // text/event-stream, like `toolkit traffic tail`
+	r.Get("/traffic-log/diff", GetTrafficDiffHandler)
+	r.Get("/traffic/diff", GetTrafficDiffHandler) // Alias mirroring the `toolkit traffic diff` command
+	// Sends an entry to the modifier, replay, an auth matrix, ffuf, the analyzers or a curl/raw export
+	r.Post("/traffic/{id}/send-to", handle(SendTrafficToHandler))
+	r.Get("/traffic/{id}/sends", handle(GetTrafficSendsHandler))
+	// Stores readable copies of minified JS, JSON, HTML and XML responses (runs as a job)
+	r.Post("/traffic/beautify", StartTrafficBeautifyHandler)
 
 	// Routes for specific log entries: /traffic-log/entry/{logID}
 	r.Route("/traffic-log/entry/{logID}", func(subRouter chi.Router) {
@@ -21,19 +30,55 @@ func RegisterTrafficLogRoutes(r chi.Router) {
 			logID, err := strconv.ParseInt(logIDStr, 10, 64)
 			if err != nil {
 				logger.Error("TrafficLogEntry Get: Invalid log entry ID '%s': %v", logIDStr, err)
-				http.Error(w, "Invalid log entry ID format", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
 				return
 			}
 			getTrafficLogEntryDetail(w, req, logID) // Existing handler
 		})
 
+		// GET /traffic-log/entry/{logID}/export?format=curl|raw
+		subRouter.Get("/export", func(w http.ResponseWriter, req *http.Request) {
+			logIDStr := chi.URLParam(req, "logID")
+			logID, err := strconv.ParseInt(logIDStr, 10, 64)
+			if err != nil {
+				logger.Error("TrafficLogEntry Export: Invalid log entry ID '%s': %v", logIDStr, err)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
+				return
+			}
+			ExportTrafficLogEntryHandler(w, req, logID)
+		})
+
+		// GET /traffic-log/entry/{logID}/preview?remote_resources=true
+		subRouter.Get("/preview", func(w http.ResponseWriter, req *http.Request) {
+			logIDStr := chi.URLParam(req, "logID")
+			logID, err := strconv.ParseInt(logIDStr, 10, 64)
+			if err != nil {
+				logger.Error("TrafficLogEntry Preview: Invalid log entry ID '%s': %v", logIDStr, err)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
+				return
+			}
+			PreviewTrafficLogEntryHandler(w, req, logID)
+		})
+
+		// GET /traffic-log/entry/{logID}/pretty?part=request|response
+		subRouter.Get("/pretty", func(w http.ResponseWriter, req *http.Request) {
+			logIDStr := chi.URLParam(req, "logID")
+			logID, err := strconv.ParseInt(logIDStr, 10, 64)
+			if err != nil {
+				logger.Error("TrafficLogEntry Pretty: Invalid log entry ID '%s': %v", logIDStr, err)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
+				return
+			}
+			PrettyTrafficLogEntryHandler(w, req, logID)
+		})
+
 		// PUT /traffic-log/entry/{logID}/notes
 		subRouter.Put("/notes", func(w http.ResponseWriter, req *http.Request) {
 			logIDStr := chi.URLParam(req, "logID")
 			logID, err := strconv.ParseInt(logIDStr, 10, 64)
 			if err != nil {
 				logger.Error("TrafficLogEntry Notes Update: Invalid log entry ID '%s': %v", logIDStr, err)
-				http.Error(w, "Invalid log entry ID format", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
 				return
 			}
 			updateTrafficLogEntryNotes(w, req, logID) // Existing handler
@@ -45,11 +90,23 @@ func RegisterTrafficLogRoutes(r chi.Router) {
 			logID, err := strconv.ParseInt(logIDStr, 10, 64)
 			if err != nil {
 				logger.Error("TrafficLogEntry Favorite Update: Invalid log entry ID '%s': %v", logIDStr, err)
-				http.Error(w, "Invalid log entry ID format", http.StatusBadRequest)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
 				return
 			}
 			setTrafficLogEntryFavoriteStatus(w, req, logID) // Existing handler
 		})
+
+		// POST /traffic-log/entry/{logID}/promote
+		subRouter.Post("/promote", func(w http.ResponseWriter, req *http.Request) {
+			logIDStr := chi.URLParam(req, "logID")
+			logID, err := strconv.ParseInt(logIDStr, 10, 64)
+			if err != nil {
+				logger.Error("TrafficLogEntry Promote: Invalid log entry ID '%s': %v", logIDStr, err)
+				WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid log entry ID format")
+				return
+			}
+			PromoteTrafficLogEntryHandler(w, req, logID)
+		})
 	})
 
 	// Route for target-specific log operations: /traffic-log/target/{targetID}
@@ -58,7 +115,7 @@ func RegisterTrafficLogRoutes(r chi.Router) {
 		targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 		if err != nil {
 			logger.Error("TrafficLogTarget Delete: Invalid target ID '%s': %v", targetIDStr, err)
-			http.Error(w, "Invalid target ID format", http.StatusBadRequest)
+			WriteProblem(w, req, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID format")
 			return
 		}
 		DeleteTrafficLogsForTargetHandler(w, req, targetID) // Existing handler
@@ -66,4 +123,21 @@ func RegisterTrafficLogRoutes(r chi.Router) {
 
 	// Route for analyzing comments in a log entry's response body
 	r.Post("/traffic-log/analyze/comments", AnalyzeCommentsHandler) // AnalyzeCommentsHandler is in traffic_log_handlers.go
+
+	// Batch analysis of a target's stored responses (runs as a job)
+	r.Post("/targets/{target_id}/traffic/analyze", StartTrafficAnalysisHandler)
+	// Active CORS misconfiguration check of captured endpoints (runs as a job)
+	r.Post("/targets/{target_id}/traffic/cors-check", StartCORSCheckHandler)
+
+	// Security header posture of a target's hosts, from its captured responses
+	r.Get("/targets/{target_id}/traffic/security-headers", GetSecurityHeaderReportHandler)
+	// Weak TLS configurations of a target's hosts, from the TLS connections the proxy made
+	r.Get("/targets/{target_id}/traffic/tls", GetTLSReportHandler)
+	r.Get("/tls-connections/{id}", GetTLSConnectionHandler)
+	// IDOR worklist: identifiers in captured requests per session, with suggested swap tests
+	r.Get("/targets/{target_id}/traffic/idor-worklist", GetIDORWorklistHandler)
+	// Auth scheme of each endpoint, and endpoints answering without credentials while siblings require them
+	r.Get("/targets/{target_id}/traffic/auth-schemes", GetAuthSchemeReportHandler)
+	// Responses far larger or slower than their endpoint's median
+	r.Get("/targets/{target_id}/traffic/anomalies", GetTrafficAnomaliesHandler)
 }
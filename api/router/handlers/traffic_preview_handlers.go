@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// prettyTrafficBodyResponse is the body of GET /traffic-log/entry/{logID}/pretty.
+type prettyTrafficBodyResponse struct {
+	core.PrettyBody
+	LogID     int64  `json:"log_id"`
+	Part      string `json:"part"`      // request or response
+	Truncated bool   `json:"truncated"` // Only the first proxy.max_capture_bytes of the response were stored
+}
+
+// PreviewTrafficLogEntryHandler serves a stored HTML (or image) response so the frontend can show
+// what the page looked like, typically in an iframe. HTML is sanitized and every preview is served
+// with a sandbox Content-Security-Policy, so captured scripts never run in the toolkit's origin.
+// Query parameter: remote_resources (true lets the page load stylesheets, images and fonts from
+// the original site; by default viewing a preview sends nothing to the target).
+func PreviewTrafficLogEntryHandler(w http.ResponseWriter, r *http.Request, logID int64) {
+	logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		logger.Error("PreviewTrafficLogEntryHandler: Error fetching log %d: %v", logID, err)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry %d not found", logID))
+		return
+	}
+	headers := core.HeadersFromJSON(logEntry.ResponseHeaders.String)
+	body, err := core.DecodeContentEncoding(logEntry.ResponseBody, headers.Get("Content-Encoding"))
+	if err != nil {
+		logger.Warn("PreviewTrafficLogEntryHandler: Could not decode body of log %d: %v", logID, err)
+	}
+	contentType := logEntry.ResponseContentType.String
+	if contentType == "" {
+		contentType = headers.Get("Content-Type")
+	}
+	if contentType == "" && len(body) > 0 {
+		contentType = http.DetectContentType(body)
+	}
+	allowRemote, _ := strconv.ParseBool(r.URL.Query().Get("remote_resources"))
+
+	lowerCT := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(lowerCT, "html"):
+		body = core.SanitizeHTMLForPreview(body, logEntry.RequestURL.String)
+	case strings.HasPrefix(lowerCT, "image/"):
+		// Served as is; the sandbox policy also covers scripts in SVG images.
+	default:
+		WriteProblem(w, r, http.StatusUnsupportedMediaType, models.ErrCodeInvalidRequest, fmt.Sprintf("Log entry %d has a %s response, only HTML and images can be previewed", logID, contentType))
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", core.PreviewContentSecurityPolicy(allowRemote))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+}
+
+// PrettyTrafficLogEntryHandler returns the request or response body of a log entry formatted for
+// a syntax highlighter: JSON, XML, HTML and JavaScript are pretty-printed (responses from their
+// stored beautified copy, made on first use), other bodies are returned as stored together with
+// their detected language.
+// Query parameter: part (response, request; default response).
+func PrettyTrafficLogEntryHandler(w http.ResponseWriter, r *http.Request, logID int64) {
+	part := r.URL.Query().Get("part")
+	if part == "" {
+		part = "response"
+	}
+	if part != "response" && part != "request" {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid part, expected 'request' or 'response'")
+		return
+	}
+	logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		logger.Error("PrettyTrafficLogEntryHandler: Error fetching log %d: %v", logID, err)
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, fmt.Sprintf("Log entry %d not found", logID))
+		return
+	}
+
+	resp := prettyTrafficBodyResponse{LogID: logID, Part: part}
+	if part == "request" {
+		headers := core.HeadersFromJSON(logEntry.RequestHeaders.String)
+		body, err := core.DecodeContentEncoding(logEntry.RequestBody, headers.Get("Content-Encoding"))
+		if err != nil {
+			logger.Warn("PrettyTrafficLogEntryHandler: Could not decode request body of log %d: %v", logID, err)
+		}
+		resp.PrettyBody = core.PrettyPrintBody(body, headers.Get("Content-Type"))
+	} else {
+		resp.Truncated = logEntry.ResponseBodyTruncated
+		resp.PrettyBody = core.PrettyTrafficResponse(logEntry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
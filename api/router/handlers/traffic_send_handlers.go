@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SendTrafficToHandler sends a traffic log entry to another module: a modifier task (modifier),
+// a replay batch (replay), a replay batch per replay session (authmatrix), an ffuf command
+// (fuzzer), the response analyzers (scanner) or a curl or raw export. The send is recorded for
+// the entry; created tasks and batches point back to it.
+func SendTrafficToHandler(w http.ResponseWriter, r *http.Request) error {
+	logID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return models.InvalidRequestError("invalid traffic log ID")
+	}
+	var req models.TrafficSendRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		return err
+	}
+	result, err := core.SendTrafficTo(logID, core.APIUser(r.Context()), req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, result)
+}
+
+// GetTrafficSendsHandler lists where a traffic log entry was sent, newest first.
+func GetTrafficSendsHandler(w http.ResponseWriter, r *http.Request) error {
+	logID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return models.InvalidRequestError("invalid traffic log ID")
+	}
+	sends, err := core.GetTrafficSends(logID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, sends)
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"toolkit/database"
 	"toolkit/logger"
+	"toolkit/models"
 )
 
 // GetSitemapGraphDataHandler handles GET requests for the sitemap graph data.
@@ -22,21 +23,21 @@ func GetSitemapGraphDataHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
 		logger.Error("GetSitemapGraphDataHandler: target_id query parameter is required")
-		http.Error(w, "target_id query parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetSitemapGraphDataHandler: Invalid target_id parameter '%s': %v", targetIDStr, err)
-		http.Error(w, "Invalid target_id parameter, must be an integer", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id parameter, must be an integer")
 		return
 	}
 
 	graphData, err := database.GetSitemapGraphData(targetID)
 	if err != nil {
 		logger.Error("GetSitemapGraphDataHandler: Failed to get sitemap graph data for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve sitemap graph data", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve sitemap graph data")
 		return
 	}
 
@@ -60,21 +61,21 @@ func GetPageSitemapGraphDataHandler(w http.ResponseWriter, r *http.Request) {
 	targetIDStr := r.URL.Query().Get("target_id")
 	if targetIDStr == "" {
 		logger.Error("GetPageSitemapGraphDataHandler: target_id query parameter is required")
-		http.Error(w, "target_id query parameter is required", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "target_id query parameter is required")
 		return
 	}
 
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		logger.Error("GetPageSitemapGraphDataHandler: Invalid target_id parameter '%s': %v", targetIDStr, err)
-		http.Error(w, "Invalid target_id parameter, must be an integer", http.StatusBadRequest)
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id parameter, must be an integer")
 		return
 	}
 
 	graphData, err := database.GetPageSitemapGraphData(targetID)
 	if err != nil {
 		logger.Error("GetPageSitemapGraphDataHandler: Failed to get page sitemap graph data for target %d: %v", targetID, err)
-		http.Error(w, "Failed to retrieve page sitemap graph data", http.StatusInternalServerError)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to retrieve page sitemap graph data")
 		return
 	}
 
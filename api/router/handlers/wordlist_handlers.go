@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// writeWordlistError maps wordlist errors to HTTP status codes.
+func writeWordlistError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		WriteProblem(w, r, http.StatusNotFound, models.ErrCodeNotFound, msg)
+	case strings.HasPrefix(msg, "invalid"):
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, msg)
+	case strings.Contains(msg, "already exists"):
+		WriteProblem(w, r, http.StatusConflict, models.ErrCodeConflict, msg)
+	case strings.HasPrefix(msg, "wordlist too large"):
+		WriteProblem(w, r, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, msg)
+	default:
+		logger.Error("%s: %v", handler, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.ErrCodeInternal, "Wordlist operation failed")
+	}
+}
+
+// GetWordlistsHandler lists wordlists by name.
+// Query parameters: target_id, source (upload, merge or generated), tag.
+func GetWordlistsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := models.WordlistFilters{Source: q.Get("source"), Tag: q.Get("tag")}
+	filters.TargetID, _ = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	wordlists, err := core.GetWordlists(filters)
+	if err != nil {
+		writeWordlistError(w, r, "GetWordlistsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wordlists)
+}
+
+// UploadWordlistHandler stores a wordlist. It takes a multipart form with the words, one per
+// line, in 'file' and an optional name (default: the file name without its extension),
+// description, target_id and dedupe.
+func UploadWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := config.AppConfig.Wordlists.MaxFileBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes*2+attachmentFormMemory)
+	defer r.Body.Close()
+	if err := r.ParseMultipartForm(attachmentFormMemory); err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			WriteProblem(w, r, http.StatusRequestEntityTooLarge, models.ErrCodePayloadTooLarge, "wordlist too large: wordlists are limited to "+strconv.FormatInt(maxBytes, 10)+" bytes (wordlists.max_file_bytes)")
+			return
+		}
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid multipart form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Missing 'file' field: "+err.Error())
+		return
+	}
+	defer file.Close()
+	name := r.FormValue("name")
+	if strings.TrimSpace(name) == "" {
+		name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+	}
+	var targetID int64
+	if v := r.FormValue("target_id"); v != "" {
+		if targetID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target_id")
+			return
+		}
+	}
+	dedupe, _ := strconv.ParseBool(r.FormValue("dedupe"))
+
+	wordlist, err := core.CreateWordlist(name, r.FormValue("description"), targetID, dedupe, file)
+	if err != nil {
+		writeWordlistError(w, r, "UploadWordlistHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wordlist)
+}
+
+// MergeWordlistsHandler combines wordlists into a new one.
+func MergeWordlistsHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.WordlistMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	wordlist, err := core.MergeWordlists(req)
+	if err != nil {
+		writeWordlistError(w, r, "MergeWordlistsHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wordlist)
+}
+
+// GetWordlistHandler returns the metadata of a wordlist.
+func GetWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	wordlistID, err := strconv.ParseInt(chi.URLParam(r, "wordlist_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid wordlist ID")
+		return
+	}
+	wordlist, err := core.GetWordlist(wordlistID)
+	if err != nil {
+		writeWordlistError(w, r, "GetWordlistHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wordlist)
+}
+
+// DownloadWordlistHandler serves the words of a wordlist as a text file.
+func DownloadWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	wordlistID, err := strconv.ParseInt(chi.URLParam(r, "wordlist_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid wordlist ID")
+		return
+	}
+	wordlist, file, err := core.OpenWordlist(wordlistID)
+	if err != nil {
+		writeWordlistError(w, r, "DownloadWordlistHandler", err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": wordlist.Name + ".txt"}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+wordlist.SHA256+`"`)
+	http.ServeContent(w, r, "", wordlist.UpdatedAt, file)
+}
+
+// UpdateWordlistHandler renames a wordlist and replaces its description.
+func UpdateWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	wordlistID, err := strconv.ParseInt(chi.URLParam(r, "wordlist_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid wordlist ID")
+		return
+	}
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := core.UpdateWordlist(wordlistID, req.Name, req.Description)
+	if err != nil {
+		writeWordlistError(w, r, "UpdateWordlistHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteWordlistHandler removes a wordlist and its file.
+func DeleteWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	wordlistID, err := strconv.ParseInt(chi.URLParam(r, "wordlist_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid wordlist ID")
+		return
+	}
+	if err := core.DeleteWordlist(wordlistID); err != nil {
+		writeWordlistError(w, r, "DeleteWordlistHandler", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DedupeWordlistHandler removes repeated words from a wordlist. The optional JSON body
+// {"case_insensitive": true} also treats words differing only in case as repeats.
+func DedupeWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	wordlistID, err := strconv.ParseInt(chi.URLParam(r, "wordlist_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid wordlist ID")
+		return
+	}
+	var req struct {
+		CaseInsensitive bool `json:"case_insensitive"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	result, err := core.DedupeWordlist(wordlistID, req.CaseInsensitive)
+	if err != nil {
+		writeWordlistError(w, r, "DedupeWordlistHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GenerateTargetWordlistHandler builds (or rebuilds) a wordlist from a target's captured paths,
+// parameters and JavaScript endpoints.
+func GenerateTargetWordlistHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "target_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid target ID")
+		return
+	}
+	var req models.WordlistGenerateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request payload: "+err.Error())
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	wordlist, err := core.GenerateTargetWordlist(targetID, req)
+	if err != nil {
+		writeWordlistError(w, r, "GenerateTargetWordlistHandler", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wordlist)
+}
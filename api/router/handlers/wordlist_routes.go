@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterWordlistRoutes sets up the routes of stored wordlists and target-specific wordlist
+// generation.
+func RegisterWordlistRoutes(r chi.Router) {
+	r.Get("/wordlists", GetWordlistsHandler) // ?target_id=&source=&tag=
+	r.Post("/wordlists", UploadWordlistHandler)
+	r.Post("/wordlists/merge", MergeWordlistsHandler)
+	r.Get("/wordlists/{wordlist_id}", GetWordlistHandler)
+	r.Get("/wordlists/{wordlist_id}/download", DownloadWordlistHandler)
+	r.Put("/wordlists/{wordlist_id}", UpdateWordlistHandler)
+	r.Delete("/wordlists/{wordlist_id}", DeleteWordlistHandler)
+	r.Post("/wordlists/{wordlist_id}/dedupe", DedupeWordlistHandler)
+	r.Post("/targets/{target_id}/wordlists/generate", GenerateTargetWordlistHandler)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	activityUser       string
+	activityEntityType string
+	activityTargetID   int64
+	activityLimit      int
+	activityJSON       bool
+)
+
+var activityCmd = &cobra.Command{
+	Use:   "activity",
+	Short: "Show who changed what through the API",
+	Long: `Lists the activity feed: every change made through the API, newest first, with the user who
+made it and the entity it touched. The user is the name of the api_access token of the request,
+else its X-Toolkit-User header, else "local".
+
+For teams sharing a toolkit, the API also tracks who is viewing what (/presence) and soft locks
+on findings and notes being edited (/locks); GET /api/events streams the activity, viewers and
+locks as server-sent events. Activity older than collaboration.activity_retention_days is pruned.`,
+	Example: `  toolkit activity
+  toolkit activity --user alice --entity-type finding
+  toolkit activity --target-id 3 --limit 20 --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		activity, err := core.ListActivity(models.ActivityFilters{
+			User:       activityUser,
+			EntityType: activityEntityType,
+			TargetID:   activityTargetID,
+			Limit:      activityLimit,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		if activityJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(activity)
+			return
+		}
+		if len(activity) == 0 {
+			fmt.Println("No activity.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tUSER\tACTION\tENTITY\tREQUEST")
+		for _, a := range activity {
+			entity := "-"
+			if a.EntityType != "" {
+				entity = a.EntityType
+				if a.EntityID != "" {
+					entity += " " + a.EntityID
+				}
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s %s\n", a.ID, a.CreatedAt.Local().Format(time.DateTime),
+				a.User, a.Action, entity, a.Method, a.Path)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	activityCmd.Flags().StringVar(&activityUser, "user", "", "Only the activity of this user")
+	activityCmd.Flags().StringVar(&activityEntityType, "entity-type", "", "Only the activity on this entity type (finding, note, target, ...)")
+	activityCmd.Flags().Int64Var(&activityTargetID, "target-id", 0, "Only the activity on this target")
+	activityCmd.Flags().IntVar(&activityLimit, "limit", 50, "Maximum entries to show")
+	activityCmd.Flags().BoolVar(&activityJSON, "json", false, "Output the activity as JSON")
+	rootCmd.AddCommand(activityCmd)
+}
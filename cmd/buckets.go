@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	bucketsCheckTargetID       int64
+	bucketsCheckNames          []string
+	bucketsCheckProviders      []string
+	bucketsCheckTestWrite      bool
+	bucketsCheckSkipCandidates bool
+	bucketsCheckSkipDiscovered bool
+
+	bucketsListTargetID int64
+	bucketsListProvider string
+	bucketsListStatus   string
+	bucketsListAll      bool
+)
+
+var bucketsCmd = &cobra.Command{
+	Use:   "buckets",
+	Short: "S3, GCS and Azure Blob buckets of the target and their permissions",
+	Long: `Finds the cloud storage buckets of a target and checks what anonymous users may do with them.
+Candidate names are derived from the target's codename and in-scope domains (example, example-backups,
+assets.example.com, ...); buckets referenced in captured traffic, JS endpoints and analyzer results
+(the 'buckets' analyzer) are checked as well.`,
+}
+
+var bucketsCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the target's buckets for existence and public read/write access",
+	Long: `Starts a background job that checks each bucket for existence and anonymous listing. GCS write
+access is read from its testPermissions API, which writes nothing; S3 and Azure writes are only tried
+with --test-write, which uploads a marker object (toolkit-write-check-*.txt) and deletes it right away.
+Requests go to the providers without the target's client profile and are logged to the traffic log
+with source 'Cloud Storage Check'; the misses of candidate names are not logged or stored.
+
+Writable buckets, and buckets the target references that do not exist (anyone can create them),
+become Draft findings. Uses the current target unless --target-id is given. The same job can be
+started through POST /targets/{target_id}/cloud-storage/check.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, bucketsCheckTargetID)
+		job, err := core.StartCloudStorageCheck(targetID, models.CloudStorageCheckRequest{
+			Names:          bucketsCheckNames,
+			Providers:      bucketsCheckProviders,
+			SkipCandidates: bucketsCheckSkipCandidates,
+			SkipDiscovered: bucketsCheckSkipDiscovered,
+			TestWrite:      bucketsCheckTestWrite,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Checked %d/%d buckets (%d exposed, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		})
+		printCloudBuckets(models.CloudBucketFilters{TargetID: targetID, ExistingOnly: true})
+	},
+}
+
+var bucketsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the checked buckets, writable and public ones first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, bucketsListTargetID)
+		filters := models.CloudBucketFilters{TargetID: targetID, Provider: bucketsListProvider, Status: bucketsListStatus, ExistingOnly: !bucketsListAll && bucketsListStatus == ""}
+		if !printCloudBuckets(filters) {
+			fmt.Println("No buckets found.")
+		}
+	},
+}
+
+// printCloudBuckets prints the matching buckets as a table and reports whether there were any.
+func printCloudBuckets(filters models.CloudBucketFilters) bool {
+	buckets, err := database.ListCloudBuckets(filters)
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(buckets) == 0 {
+		return false
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tNAME\tSTATUS\tWRITE\tSOURCE\tEVIDENCE\tDETAILS")
+	for _, b := range buckets {
+		write, evidence, details := "-", "-", b.Details
+		if b.PublicWrite != nil {
+			write = "no"
+			if *b.PublicWrite {
+				write = "YES"
+			}
+		}
+		if b.EvidenceLogID != nil {
+			evidence = fmt.Sprintf("log %d", *b.EvidenceLogID)
+		}
+		if details == "" {
+			details = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", b.Provider, b.Name, b.Status, write, b.Source, evidence, details)
+	}
+	w.Flush()
+	return true
+}
+
+func init() {
+	bucketsCheckCmd.Flags().Int64Var(&bucketsCheckTargetID, "target-id", 0, "Target to check (defaults to the current target)")
+	bucketsCheckCmd.Flags().StringSliceVar(&bucketsCheckNames, "name", nil, "Extra bucket name or URL, account/container for Azure (repeatable)")
+	bucketsCheckCmd.Flags().StringSliceVar(&bucketsCheckProviders, "provider", nil, "Provider to check: s3, gcs, azure (repeatable; default: all)")
+	bucketsCheckCmd.Flags().BoolVar(&bucketsCheckTestWrite, "test-write", false, "Upload and delete a marker object to test S3 and Azure write access")
+	bucketsCheckCmd.Flags().BoolVar(&bucketsCheckSkipCandidates, "skip-candidates", false, "Do not check names derived from the target's codename and domains")
+	bucketsCheckCmd.Flags().BoolVar(&bucketsCheckSkipDiscovered, "skip-discovered", false, "Do not check buckets referenced in traffic and JavaScript")
+	registerFlagCompletion(bucketsCheckCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(bucketsCheckCmd, "provider", cobra.FixedCompletions(models.CloudProviders, cobra.ShellCompDirectiveNoFileComp))
+
+	bucketsListCmd.Flags().Int64Var(&bucketsListTargetID, "target-id", 0, "Target to list (defaults to the current target)")
+	bucketsListCmd.Flags().StringVar(&bucketsListProvider, "provider", "", "Only buckets of this provider: s3, gcs, azure")
+	bucketsListCmd.Flags().StringVar(&bucketsListStatus, "status", "", "Only buckets with this status: not_found, private, public_read, error")
+	bucketsListCmd.Flags().BoolVar(&bucketsListAll, "all", false, "Include buckets that do not exist or were not checked")
+	registerFlagCompletion(bucketsListCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(bucketsListCmd, "provider", cobra.FixedCompletions(models.CloudProviders, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(bucketsListCmd, "status", cobra.FixedCompletions([]string{models.CloudBucketStatusNotFound, models.CloudBucketStatusPrivate,
+		models.CloudBucketStatusPublicRead, models.CloudBucketStatusError}, cobra.ShellCompDirectiveNoFileComp))
+
+	bucketsCmd.AddCommand(bucketsCheckCmd)
+	bucketsCmd.AddCommand(bucketsListCmd)
+	rootCmd.AddCommand(bucketsCmd)
+}
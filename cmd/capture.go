@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	captureTargetID int64
+	captureNotes    string
+	captureName     string
+	captureLimit    int
+	captureJSON     bool
+)
+
+var captureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "Named time windows of a target's traffic",
+	Long: `Capture sessions name a stretch of testing on a target, such as "testing checkout flow". Every request
+logged for the target between 'capture start' and 'capture stop' belongs to the session, whatever page
+or tool it came from; nothing in the logged traffic is changed, unlike a Page Sitemap recording.
+
+List a session's traffic with 'traffic list --capture-session ID' (capture_session_id in the API and
+saved views). The same data is served under /targets/{target_id}/capture-sessions.`,
+}
+
+var captureStartCmd = &cobra.Command{
+	Use:   "start NAME",
+	Short: "Start a capture session for the target",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, captureTargetID)
+		name := strings.Join(args, " ")
+		req := models.CaptureSessionRequest{Name: &name}
+		if cmd.Flags().Changed("notes") {
+			req.Notes = &captureNotes
+		}
+		session, err := core.StartCaptureSession(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Started capture session %d ('%s') for target %d at %s.\n", session.ID, session.Name, targetID,
+			session.StartedAt.Local().Format("15:04"))
+	},
+}
+
+var captureStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running capture session of the target",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, captureTargetID)
+		var notes *string
+		if cmd.Flags().Changed("notes") {
+			notes = &captureNotes
+		}
+		session, err := core.StopCaptureSession(targetID, notes)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Stopped capture session %d ('%s') after %s (%d requests, %d yours).\n", session.ID, session.Name,
+			formatEngagementSeconds(session.DurationSeconds), session.Requests, session.ManualRequests)
+		fmt.Printf("List its traffic with: toolkit traffic list -t %d --capture-session %d\n", targetID, session.ID)
+	},
+}
+
+var captureListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the capture sessions of the target, latest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, captureTargetID)
+		sessions, err := core.ListCaptureSessions(targetID, captureLimit)
+		if err != nil {
+			exitWithError(err)
+		}
+		if captureJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(sessions)
+			return
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No capture sessions found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSTARTED\tENDED\tDURATION\tREQUESTS\tYOURS\tNOTES")
+		for _, s := range sessions {
+			ended := "running"
+			if s.EndedAt != nil {
+				ended = s.EndedAt.Local().Format("15:04")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", s.ID, s.Name, s.StartedAt.Local().Format("2006-01-02 15:04"), ended,
+				formatEngagementSeconds(s.DurationSeconds), s.Requests, s.ManualRequests, orDash(s.Notes))
+		}
+		w.Flush()
+	},
+}
+
+var captureUpdateCmd = &cobra.Command{
+	Use:   "update SESSION_ID",
+	Short: "Rename a capture session or change its notes",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id := parseCaptureSessionID(args[0])
+		req := models.CaptureSessionRequest{}
+		if cmd.Flags().Changed("name") {
+			req.Name = &captureName
+		}
+		if cmd.Flags().Changed("notes") {
+			req.Notes = &captureNotes
+		}
+		if req.Name == nil && req.Notes == nil {
+			exitWithError(fmt.Errorf("nothing to update; pass --name or --notes"))
+		}
+		session, err := core.UpdateCaptureSession(id, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Updated capture session %d ('%s').\n", session.ID, session.Name)
+	},
+}
+
+var captureDeleteCmd = &cobra.Command{
+	Use:   "delete SESSION_ID",
+	Short: "Delete a capture session, keeping its traffic",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id := parseCaptureSessionID(args[0])
+		if err := core.DeleteCaptureSession(id); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Deleted capture session %d.\n", id)
+	},
+}
+
+// parseCaptureSessionID parses a capture session ID argument and exits when it is invalid.
+func parseCaptureSessionID(arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid capture session ID '%s'\n", arg)
+		os.Exit(1)
+	}
+	return id
+}
+
+func init() {
+	for _, c := range []*cobra.Command{captureStartCmd, captureStopCmd, captureListCmd} {
+		c.Flags().Int64VarP(&captureTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+		registerFlagCompletion(c, "target-id", completeTargetIDs)
+	}
+	captureStartCmd.Flags().StringVar(&captureNotes, "notes", "", "Notes of the session")
+	captureStopCmd.Flags().StringVar(&captureNotes, "notes", "", "Replace the notes of the session")
+	captureUpdateCmd.Flags().StringVar(&captureName, "name", "", "New name of the session")
+	captureUpdateCmd.Flags().StringVar(&captureNotes, "notes", "", "New notes of the session")
+	captureListCmd.Flags().IntVar(&captureLimit, "limit", 0, "Show at most this many sessions")
+	captureListCmd.Flags().BoolVar(&captureJSON, "json", false, "Output as JSON")
+	captureCmd.AddCommand(captureStartCmd, captureStopCmd, captureListCmd, captureUpdateCmd, captureDeleteCmd)
+	rootCmd.AddCommand(captureCmd)
+}
@@ -2,21 +2,47 @@ package cmd
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
 
 	"github.com/spf13/cobra"
 )
 
 var completionCmd = &cobra.Command{
-	Use:                   "completion [bash|zsh|fish|powershell]",
-	Short:                 "Generate completion script for your shell",
-	Long:                  `...`, // Long help text remains the same
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate completion script for your shell",
+	Long: `Prints a completion script for your shell. Besides commands and flags, the script completes
+target slugs and IDs, platforms, tag names, domains and saved views from the database.
+
+Bash:
+  $ source <(toolkit completion bash)
+  # To load completions for each session, execute once:
+  $ toolkit completion bash > /etc/bash_completion.d/toolkit
+
+Zsh:
+  # If shell completion is not already enabled, execute once:
+  $ echo "autoload -U compinit; compinit" >> ~/.zshrc
+  $ toolkit completion zsh > "${fpath[1]}/_toolkit"
+
+Fish:
+  $ toolkit completion fish > ~/.config/fish/completions/toolkit.fish
+
+PowerShell:
+  PS> toolkit completion powershell | Out-String | Invoke-Expression
+
+Start a new shell for the completions to take effect. Dynamic completions query the database
+the toolkit uses by default (or --dbpath given on the command line being completed).`,
 	DisableFlagsInUseLine: true,
 	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
 	Args:                  cobra.ExactValidArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		switch args[0] {
 		case "bash":
-			return rootCmd.GenBashCompletion(os.Stdout)
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
 		case "zsh":
 			return rootCmd.GenZshCompletion(os.Stdout)
 		case "fish":
@@ -28,6 +54,241 @@ var completionCmd = &cobra.Command{
 	},
 }
 
+// --- Dynamic completions ---
+// Candidates are "value\tdescription"; shells without descriptions show the value only.
+
+// completionDBReady reports whether the completion functions can query the database. rootCmd's
+// PersistentPreRunE opens it before cobra parses the command line being completed, so a --dbpath
+// on that line is applied here.
+func completionDBReady(cmd *cobra.Command) bool {
+	if f := cmd.Flag("dbpath"); f != nil && f.Changed {
+		path, err := expandTildeCmd(dbPath)
+		if err != nil {
+			return false
+		}
+		if err := database.InitDB(path); err != nil {
+			logger.Error("completion: opening database %s: %v", path, err)
+			return false
+		}
+	}
+	return database.DB != nil
+}
+
+// completeTargets completes the [id|slug] argument of the target commands with target slugs.
+func completeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	targets, err := database.GetTargets(nil)
+	if err != nil {
+		logger.Error("completion: listing targets: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, t := range targets {
+		value := t.Slug
+		if value == "" {
+			value = strconv.FormatInt(t.ID, 10)
+		}
+		completions = append(completions, value+"\t"+t.Codename)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTargetIDs completes target ID flags.
+func completeTargetIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	targets, err := database.GetTargets(nil)
+	if err != nil {
+		logger.Error("completion: listing targets: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, t := range targets {
+		completions = append(completions, strconv.FormatInt(t.ID, 10)+"\t"+t.Codename)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePlatforms completes the [id|name] argument of the platform commands with platform names.
+func completePlatforms(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	platforms, err := database.GetAllPlatforms()
+	if err != nil {
+		logger.Error("completion: listing platforms: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, p := range platforms {
+		completions = append(completions, p.Name+"\tID "+strconv.FormatInt(p.ID, 10))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePlatformIDs completes --platform-id flags.
+func completePlatformIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	platforms, err := database.GetAllPlatforms()
+	if err != nil {
+		logger.Error("completion: listing platforms: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, p := range platforms {
+		completions = append(completions, strconv.FormatInt(p.ID, 10)+"\t"+p.Name)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTagNames completes the comma-separated tag filter flags.
+func completeTagNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	tags, err := database.GetAllTags()
+	if err != nil {
+		logger.Error("completion: listing tags: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return completeCommaList(toComplete, names)
+}
+
+// completeDomainNames completes domain flags with the domains tracked for the target given by
+// --target-id (or the current target).
+func completeDomainNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	targetID := completionTargetID(cmd)
+	if targetID == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	domains, _, _, err := database.GetDomains(models.DomainFilters{
+		TargetID:         targetID,
+		Page:             1,
+		Limit:            1000,
+		SortBy:           "domain_name",
+		SortOrder:        "ASC",
+		DomainNameSearch: toComplete,
+	})
+	if err != nil {
+		logger.Error("completion: listing domains of target %d: %v", targetID, err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, d := range domains {
+		completions = append(completions, d.DomainName)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTrafficViews completes --view with the saved traffic log views of the target given by
+// --target-id (or the current target) and the global ones.
+func completeTrafficViews(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	views, err := database.GetSavedViews(completionTargetID(cmd), models.SavedViewTypeTrafficLog)
+	if err != nil {
+		logger.Error("completion: listing saved views: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, v := range views {
+		completions = append(completions, v.Name)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDomainViews completes --view with the saved domain views of the target given by
+// --target-id (or the current target) and the global ones.
+func completeDomainViews(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	views, err := database.GetSavedViews(completionTargetID(cmd), models.SavedViewTypeDomains)
+	if err != nil {
+		logger.Error("completion: listing saved views: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, v := range views {
+		completions = append(completions, v.Name)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePluginNames completes the NAME argument of the plugins commands.
+func completePluginNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || !completionDBReady(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	plugins, err := core.ListPlugins()
+	if err != nil {
+		logger.Error("completion: listing plugins: %v", err)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, p := range plugins {
+		completions = append(completions, p.Name+"\t"+strings.Join(p.Kinds, ","))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeCloneComponents completes the comma-separated --components and --without flags of
+// 'target clone'.
+func completeCloneComponents(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeCommaList(toComplete, models.TargetCloneComponents)
+}
+
+// completeCommaList completes the last item of a comma-separated flag value, leaving out the
+// items already given.
+func completeCommaList(toComplete string, values []string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	given := map[string]bool{}
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+		for _, v := range strings.Split(toComplete[:i], ",") {
+			given[v] = true
+		}
+	}
+	var completions []string
+	for _, v := range values {
+		if !given[v] {
+			completions = append(completions, prefix+v)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completionTargetID returns the target a completion applies to: the --target-id flag of the
+// command being completed, or the current target from the state file.
+func completionTargetID(cmd *cobra.Command) int64 {
+	if f := cmd.Flag("target-id"); f != nil && f.Changed {
+		id, _ := strconv.ParseInt(f.Value.String(), 10, 64)
+		return id
+	}
+	if state, err := readState(); err == nil {
+		return state.CurrentTargetID
+	}
+	return 0
+}
+
+// registerFlagCompletion attaches a completion function to a flag defined by the caller's init.
+func registerFlagCompletion(cmd *cobra.Command, flag string, fn func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+	cobra.CheckErr(cmd.RegisterFlagCompletionFunc(flag, fn))
+}
+
 func init() {
 	rootCmd.AddCommand(completionCmd)
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"toolkit/core"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbSanitizeForce bool
+	dbSanitizeJSON  bool
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Work with the toolkit database",
+}
+
+var dbSanitizeCmd = &cobra.Command{
+	Use:   "sanitize <output.db>",
+	Short: "Write a copy of the database with bodies and credentials stripped",
+	Long: `Writes a copy of the database that can be shared for demos and bug reports against the toolkit
+itself. The copy keeps targets, scope, URLs, statuses, sizes, findings and notes, but not:
+
+  - request and response bodies of captured traffic, modifier tasks and login flows
+  - secrets matches, auth tokens, cookie values and target variables
+  - client profile headers and cookies, login flow session headers and custom headers
+  - inbox mail and checklist command output
+
+Credential headers (Authorization, Cookie, ... and redaction.headers) and URL parameters (token,
+password, ... and redaction.parameters) are masked. The open database is not changed; attachment and
+wordlist files are not part of the copy.`,
+	Example: `  toolkit db sanitize /tmp/toolkit-demo.db
+  toolkit --dbpath /tmp/toolkit-demo.db server --read-only`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := expandTildeCmd(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		report, err := core.SanitizeDatabase(path, dbSanitizeForce)
+		if err != nil {
+			exitWithError(err)
+		}
+		if dbSanitizeJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TABLE\tROWS\tACTION")
+		for _, s := range report.Steps {
+			if s.Rows > 0 {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", s.Table, s.Rows, s.Action)
+			}
+		}
+		w.Flush()
+		fmt.Printf("Wrote sanitized copy to %s (%d bytes).\n", report.Path, report.Size)
+	},
+}
+
+func init() {
+	dbSanitizeCmd.Flags().BoolVar(&dbSanitizeForce, "force", false, "Replace the output file if it exists")
+	dbSanitizeCmd.Flags().BoolVar(&dbSanitizeJSON, "json", false, "Output the report as JSON")
+	dbCmd.AddCommand(dbSanitizeCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -8,9 +8,10 @@ import (
 	"os"
 	// "regexp" // REMOVED
 	"strconv" // Needed for page/limit/targetID parsing
-	// "strings" // Not needed here
+	"strings"
 	"text/tabwriter"
 	"time"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -24,6 +25,15 @@ var (
 	discoveredListTargetID int64
 	discoveredListLimit    int
 	discoveredListPage     int
+
+	// Flags for unique and canonicalize
+	discoveredUniqueTargetID int64
+	discoveredUniqueSearch   string
+	discoveredUniqueSource   string
+	discoveredUniqueInScope  bool
+	discoveredUniqueLimit    int
+	discoveredUniquePage     int
+	discoveredCanonTargetID  int64
 )
 
 // --- Base Command ---
@@ -187,6 +197,9 @@ for a specific target. Requires --target-id or a currently set target.`,
 		// Print Footer
 		fmt.Println("---")
 		footer := fmt.Sprintf("Page %d / %d (%d total URLs found for this target)", discoveredListPage, totalPages, totalRecords)
+		if _, unique, err := database.CountDiscoveredURLs(targetIDToList); err == nil {
+			footer += fmt.Sprintf(", %d unique URLs (see 'toolkit discovered unique')", unique)
+		}
 		fmt.Println(footer)
 
 		baseCmd := fmt.Sprintf("toolkit discovered list urls --target-id %d", targetIDToList)
@@ -206,16 +219,86 @@ for a specific target. Requires --target-id or a currently set target.`,
 	},
 }
 
+// --- Unique URLs Subcommand ---
+
+var discoveredUniqueCmd = &cobra.Command{
+	Use:   "unique",
+	Short: "List the unique (canonical) URLs of a target",
+	Long: `Lists a target's discovered URLs and traffic endpoints once per canonical form: host
+lower-cased, query parameters sorted, fragments and the session/tracking parameters of
+url_canonical.strip_params (utm_*, gclid, jsessionid, ...) dropped. Uses the current target unless
+--target-id is given.`,
+	Example: `  toolkit discovered unique --in-scope=true --source jsluice`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, discoveredUniqueTargetID)
+		filters := models.CanonicalURLFilters{TargetID: targetID, Search: discoveredUniqueSearch, Source: discoveredUniqueSource,
+			Page: max(discoveredUniquePage, 1), Limit: discoveredUniqueLimit}
+		if cmd.Flags().Changed("in-scope") {
+			filters.IsInScope = &discoveredUniqueInScope
+		}
+		urls, total, err := database.GetCanonicalURLs(filters)
+		if err != nil {
+			exitWithError(err)
+		}
+		if total == 0 {
+			fmt.Printf("No unique URLs found for target ID %d.\n", targetID)
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+		fmt.Fprintln(writer, "IN_SCOPE\tSOURCES\tLAST_SEEN\tURL")
+		for _, u := range urls {
+			fmt.Fprintf(writer, "%t\t%s\t%s\t%s\n", u.IsInScope, strings.Join(u.Sources, ","), u.LastSeenAt.Format("2006-01-02 15:04:05"), u.CanonicalURL)
+		}
+		writer.Flush()
+		if filters.Limit > 0 {
+			fmt.Printf("---\nPage %d / %d (%d unique URLs)\n", filters.Page, (total+filters.Limit-1)/filters.Limit, total)
+		}
+	},
+}
+
+// --- Canonicalize Subcommand ---
+
+var discoveredCanonicalizeCmd = &cobra.Command{
+	Use:   "canonicalize",
+	Short: "Recompute the canonical form of a target's URLs",
+	Long: `Recomputes the canonical form of every discovered URL and traffic endpoint of a target and
+rebuilds its unique URL list. Run it after changing url_canonical.strip_params or the scope rules,
+or for URLs stored before canonicalization existed. Uses the current target unless --target-id
+is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, discoveredCanonTargetID)
+		result, err := core.RebuildCanonicalURLs(targetID)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Canonicalized %d discovered URLs and %d traffic endpoints of target %d: %d unique URLs.\n",
+			result.DiscoveredURLs, result.TrafficURLs, targetID, result.UniqueURLs)
+	},
+}
+
 // --- Init Function ---
 
 func init() {
 	// Add flags to the list urls command
 	discoveredListUrlsCmd.Flags().Int64VarP(&discoveredListTargetID, "target-id", "t", 0, "ID of the target to list discovered URLs for (uses current target if not specified)")
+	registerFlagCompletion(discoveredListUrlsCmd, "target-id", completeTargetIDs)
 	discoveredListUrlsCmd.Flags().IntVarP(&discoveredListLimit, "limit", "l", 50, "Number of results per page")
 	discoveredListUrlsCmd.Flags().IntVarP(&discoveredListPage, "page", "p", 1, "Page number to retrieve")
 
+	discoveredUniqueCmd.Flags().Int64VarP(&discoveredUniqueTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	discoveredUniqueCmd.Flags().StringVar(&discoveredUniqueSearch, "search", "", "Canonical URL contains")
+	discoveredUniqueCmd.Flags().StringVar(&discoveredUniqueSource, "source", "", "Only URLs seen from this source (jsluice, sitemap, robots_disallow, traffic, ...)")
+	discoveredUniqueCmd.Flags().BoolVar(&discoveredUniqueInScope, "in-scope", false, "Only in-scope (--in-scope=true) or out-of-scope (--in-scope=false) URLs")
+	discoveredUniqueCmd.Flags().IntVarP(&discoveredUniqueLimit, "limit", "l", 0, "Number of results per page (0 = all)")
+	discoveredUniqueCmd.Flags().IntVarP(&discoveredUniquePage, "page", "p", 1, "Page number to retrieve")
+	registerFlagCompletion(discoveredUniqueCmd, "target-id", completeTargetIDs)
+	discoveredCanonicalizeCmd.Flags().Int64VarP(&discoveredCanonTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	registerFlagCompletion(discoveredCanonicalizeCmd, "target-id", completeTargetIDs)
+
 	// Add subcommands to the base discovered command
 	discoveredCmd.AddCommand(discoveredListUrlsCmd)
+	discoveredCmd.AddCommand(discoveredUniqueCmd)
+	discoveredCmd.AddCommand(discoveredCanonicalizeCmd)
 	// Add other discovered subcommands here later (e.g., list secrets, list paths)
 
 	// Add the base discovered command to the root command
@@ -0,0 +1,634 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	domainsImportTargetID      int64
+	domainsImportFile          string
+	domainsImportSource        string
+	domainsImportValidateScope bool
+	domainsImportDryRun        bool
+	domainsImportShowSkipped   bool
+
+	domainsExportTargetID    int64
+	domainsExportFormat      string
+	domainsExportOutput      string
+	domainsExportView        string
+	domainsExportSearch      string
+	domainsExportSource      string
+	domainsExportInScope     bool
+	domainsExportFavorites   bool
+	domainsExportHttpx       string
+	domainsExportStatusCode  string
+	domainsExportServer      string
+	domainsExportTech        string
+	domainsExportFaviconHash string
+	domainsExportPort        string
+	domainsExportTakeover    string
+	domainsExportRoot        string
+	domainsExportUnit        string
+	domainsExportSortBy      string
+	domainsExportSortOrder   string
+
+	domainsPermuteTargetID  int64
+	domainsPermuteBases     []string
+	domainsPermuteWords     []string
+	domainsPermuteWordlist  string
+	domainsPermuteStored    string
+	domainsPermuteWordsOnly bool
+	domainsPermuteMax       int
+	domainsPermuteDryRun    bool
+
+	domainsResolversCheck bool
+
+	domainsTakeoverTargetID  int64
+	domainsTakeoverDomainIDs []int64
+
+	domainsVhostsTargetID   int64
+	domainsVhostsIPAssetIDs []int64
+	domainsVhostsPorts      []int
+	domainsVhostsWords      []string
+	domainsVhostsStored     string
+	domainsVhostsWordsOnly  bool
+	domainsVhostsMax        int
+
+	domainsGroupsTargetID int64
+	domainsGroupsUnit     string
+	domainsGroupsSearch   string
+	domainsGroupsSet      string
+	domainsGroupsNotes    string
+	domainsGroupsJSON     bool
+)
+
+var domainsCmd = &cobra.Command{
+	Use:     "domains",
+	Short:   "Manage the domains tracked for a target",
+	Aliases: []string{"domain"},
+}
+
+var domainsImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Bulk import domains from a file or stdin",
+	Long: `Adds the domains listed in a file to a target. The file holds one domain per line (the output
+of subfinder, amass, assetfinder, ...) or CSV; with a header row naming a domain, host or name
+column that column is used. URLs, ports and "*." prefixes are stripped.
+
+Domains repeated in the file or already tracked for the target are skipped, and so are domains
+outside the target's scope with --validate-scope. Uses the current target unless --target-id is given.`,
+	Example: `  toolkit domains import --file subs.txt --source amass
+  subfinder -d example.com -silent | toolkit domains import --source subfinder --validate-scope`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, domainsImportTargetID)
+		var input io.Reader = os.Stdin
+		if domainsImportFile != "" && domainsImportFile != "-" {
+			path, err := expandTildeCmd(domainsImportFile)
+			if err != nil {
+				exitWithError(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			input = f
+		}
+
+		result, err := core.ImportDomains(targetID, input, models.DomainImportOptions{
+			Source:        domainsImportSource,
+			ValidateScope: domainsImportValidateScope,
+			DryRun:        domainsImportDryRun,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing domains: %v\n", err)
+			os.Exit(1)
+		}
+		printDomainImportResult(result)
+	},
+}
+
+var domainsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the domains of a target, filtered like the domain list",
+	Long: `Writes the domains of a target matching the given filters as host names one per line (txt),
+CSV or JSON, to stdout or --output. The filters are those of the web UI's domain list; --view
+applies a saved domain view, with the other flags taking precedence. Uses the current target
+unless --target-id is given.`,
+	Example: `  toolkit domains export --in-scope=true --httpx-status scanned --status-code 200 | nuclei -l -
+  toolkit domains export --tech nginx --format csv -o nginx-hosts.csv`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, domainsExportTargetID)
+		format := strings.ToLower(domainsExportFormat)
+		if !slices.Contains(core.DomainExportFormats, format) {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --format '%s', expected one of: %s\n", domainsExportFormat, strings.Join(core.DomainExportFormats, ", "))
+			os.Exit(1)
+		}
+
+		q := url.Values{}
+		if domainsExportView != "" {
+			view, err := database.FindSavedView(targetID, models.SavedViewTypeDomains, domainsExportView)
+			if err != nil {
+				exitWithError(err)
+			}
+			for key, value := range view.Filters {
+				q.Set(key, value)
+			}
+			logger.Info("domains export: Applying saved view '%s' (ID %d): %v", view.Name, view.ID, view.Filters)
+		}
+		flagParams := []struct {
+			flag, param, value string
+		}{
+			{"search", "domain_name_search", domainsExportSearch},
+			{"source", "source_search", domainsExportSource},
+			{"in-scope", "is_in_scope", strconv.FormatBool(domainsExportInScope)},
+			{"favorites", "is_favorite", strconv.FormatBool(domainsExportFavorites)},
+			{"httpx-status", "httpx_scan_status", domainsExportHttpx},
+			{"status-code", "filter_http_status_code", domainsExportStatusCode},
+			{"server", "filter_http_server", domainsExportServer},
+			{"tech", "filter_http_tech", domainsExportTech},
+			{"favicon-hash", "filter_favicon_hash", domainsExportFaviconHash},
+			{"port", "filter_open_port", domainsExportPort},
+			{"takeover", "filter_takeover_status", domainsExportTakeover},
+			{"root", "filter_root_domain", domainsExportRoot},
+			{"business-unit", "filter_business_unit", domainsExportUnit},
+			{"sort-by", "sort_by", domainsExportSortBy},
+			{"sort-order", "sort_order", domainsExportSortOrder},
+		}
+		for _, p := range flagParams {
+			if cmd.Flags().Changed(p.flag) {
+				q.Set(p.param, p.value)
+			}
+		}
+		filters := core.DomainFiltersFromQuery(targetID, q)
+		filters.Page = 1
+		domains, _, _, err := database.GetDomains(filters)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error retrieving domains for target %d: %v\n", targetID, err)
+			os.Exit(1)
+		}
+
+		out := os.Stdout
+		if domainsExportOutput != "" && domainsExportOutput != "-" {
+			path, err := expandTildeCmd(domainsExportOutput)
+			if err != nil {
+				exitWithError(err)
+			}
+			if out, err = os.Create(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			defer out.Close()
+		}
+		if err := core.ExportDomains(out, format, domains); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+			os.Exit(1)
+		}
+		if out != os.Stdout {
+			fmt.Fprintf(os.Stderr, "Exported %d domain(s) to %s.\n", len(domains), out.Name())
+		}
+	},
+}
+
+var domainsPermuteCmd = &cobra.Command{
+	Use:   "permute",
+	Short: "Generate and resolve subdomain permutations of known domains",
+	Long: `Starts a background job that generates candidate subdomains under the base domains (--base, or
+the target's in-scope wildcard rules such as *.example.com) from the target's known subdomains and
+a wordlist: words prepended, inserted, joined to and replacing labels, numbers bumped (api2 gives
+api1 and api3) and word.base. Candidates are resolved with permutation.workers concurrent lookups
+and the live ones are added as domains with source 'permutation'. Names matched by an out-of-scope
+rule are never tried and answers of wildcard DNS zones are ignored.
+
+The wordlist is permutation.wordlist from the config, or a built-in list of common words; --word,
+--wordlist and --wordlist-id (a stored wordlist, see 'toolkit wordlists') add words, --words-only
+uses only those. Uses the current target unless --target-id
+is given. The same job can be started through POST /targets/{target_id}/domains/permutations.`,
+	Example: `  # Preview the candidates for example.com
+  toolkit domains permute --base example.com --dry-run
+
+  # Resolve permutations with extra words only
+  toolkit domains permute --wordlist words.txt --words-only --max 20000`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, domainsPermuteTargetID)
+		req := models.PermutationRequest{Bases: domainsPermuteBases, Words: domainsPermuteWords, WordsOnly: domainsPermuteWordsOnly,
+			MaxCandidates: domainsPermuteMax, DryRun: domainsPermuteDryRun}
+		if domainsPermuteStored != "" {
+			req.WordlistID = resolveWordlistID(domainsPermuteStored)
+		}
+		if domainsPermuteWordlist != "" {
+			path, err := expandTildeCmd(domainsPermuteWordlist)
+			if err != nil {
+				exitWithError(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				req.Words = append(req.Words, scanner.Text())
+			}
+			f.Close()
+			if err := scanner.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		job, preview, err := core.StartPermutation(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		if preview != nil {
+			for _, name := range preview.Candidates {
+				fmt.Println(name)
+			}
+			more := ""
+			if preview.Truncated {
+				more = " (capped; raise --max or permutation.max_candidates for more)"
+			}
+			fmt.Fprintf(os.Stderr, "%d candidate(s) under %s from %d known domain(s) and %d word(s)%s.\n",
+				len(preview.Candidates), strings.Join(preview.Bases, ", "), preview.Seeds, preview.Words, more)
+			return
+		}
+		fmt.Printf("Job %d: %s\n", job.ID, job.Message)
+		// Ctrl+C cancels the job so it is not left 'running' when the command exits.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		for job.Status == models.JobStatusRunning {
+			select {
+			case <-sigs:
+				core.CancelJob(job.ID)
+			case <-time.After(500 * time.Millisecond):
+			}
+			if job, err = core.GetJob(job.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("\rResolved %d/%d candidates (%d live, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		}
+		fmt.Println()
+		switch job.Status {
+		case models.JobStatusSucceeded:
+			fmt.Println(job.Message)
+		default:
+			detail := job.LastError
+			if detail == "" {
+				detail = job.Message
+			}
+			fmt.Fprintf(os.Stderr, "Job %d %s: %s\n", job.ID, job.Status, detail)
+			os.Exit(1)
+		}
+	},
+}
+
+var domainsResolversCmd = &cobra.Command{
+	Use:   "resolvers",
+	Short: "Show the DNS resolvers of recon lookups and their health",
+	Long: `Lists the resolvers of dns.resolvers and dns.resolvers_file, which IP asset resolution, domain
+enrichment, permutation and takeover check jobs rotate their lookups over, with their query counters and health. A
+resolver failing dns.max_failures lookups in a row is skipped for dns.cooldown_seconds. --check
+looks up dns.health_check_domain through each resolver first.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status := core.GetDNSResolversStatus()
+		if domainsResolversCheck {
+			status = core.CheckDNSResolvers(context.Background())
+		}
+		for _, e := range status.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", e)
+		}
+		if status.SystemResolver {
+			fmt.Println("No resolvers configured (dns.resolvers); recon lookups use the system resolver.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESOLVER\tPROTOCOL\tHEALTHY\tQUERIES\tFAILURES\tLAST CHECK\tLAST ERROR")
+		for _, r := range status.Resolvers {
+			healthy := "yes"
+			if !r.Healthy {
+				healthy = "no, until " + r.DownUntil.Local().Format("15:04:05")
+			}
+			check := "-"
+			if r.LastCheckAt != nil {
+				check = fmt.Sprintf("%s (%dms)", r.LastCheckAt.Local().Format("15:04:05"), r.LastCheckMs)
+			}
+			lastError := r.LastError
+			if lastError == "" {
+				lastError = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.Resolver, r.Protocol, healthy, r.Queries, r.Failures, check, lastError)
+		}
+		w.Flush()
+	},
+}
+
+var domainsTakeoverCmd = &cobra.Command{
+	Use:   "takeover",
+	Short: "Verify subdomain takeovers of the target's domains",
+	Long: `Starts a background job that follows the CNAME chain of each domain through the recon resolvers
+and verifies takeovers of the services it points to (S3, GitHub Pages, Heroku, Azure, Fastly, ...):
+the domain's response is matched against the service's fingerprint of an unclaimed resource, or,
+for services whose names anyone can register, the last CNAME target must not resolve. Requests are
+logged to the traffic log with source 'Takeover Check'.
+
+The result is stored on each domain (vulnerable, dangling or not_vulnerable; see 'toolkit domains
+export --takeover vulnerable') and confirmed takeovers become Draft findings. A CNAME whose target
+does not resolve without a known service is flagged as dangling for manual review. Extra services
+can be added under takeover.fingerprints in the config.
+
+Checks every domain within the target's scope rules unless --domain-id is given. Uses the current
+target unless --target-id is given. The same job can be started through
+POST /targets/{target_id}/domains/takeover-check.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, domainsTakeoverTargetID)
+		job, err := core.StartTakeoverCheck(targetID, models.TakeoverCheckRequest{DomainIDs: domainsTakeoverDomainIDs})
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Checked %d/%d domains (%d vulnerable, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		})
+
+		var flagged []models.Domain
+		for _, status := range []string{models.TakeoverStatusVulnerable, models.TakeoverStatusDangling} {
+			domains, _, _, err := database.GetDomains(models.DomainFilters{TargetID: targetID, FilterTakeoverStatus: status, SortBy: "domain_name", SortOrder: "ASC"})
+			if err != nil {
+				exitWithError(err)
+			}
+			flagged = append(flagged, domains...)
+		}
+		if len(flagged) == 0 {
+			return
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DOMAIN\tSTATUS\tSERVICE\tCNAME\tEVIDENCE")
+		for _, d := range flagged {
+			service, evidence := "-", "-"
+			if d.TakeoverService.Valid {
+				service = d.TakeoverService.String
+			}
+			if d.TakeoverEvidenceLogID.Valid {
+				evidence = fmt.Sprintf("log %d", d.TakeoverEvidenceLogID.Int64)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.DomainName, d.TakeoverStatus.String, service, d.TakeoverCNAME.String, evidence)
+		}
+		w.Flush()
+	},
+}
+
+var domainsGroupsCmd = &cobra.Command{
+	Use:   "groups",
+	Short: "Group the target's domains by root domain and business unit",
+	Long: `Lists the root domains (eTLD+1 by the public suffix list, so api.eu.example.co.uk is under
+example.co.uk) of the target's domains, most domains first, with how many are in scope, live
+(httpx status), and flagged by the takeover check, followed by the totals per business unit.
+
+--set assigns a root domain to the business unit given with --business-unit, with optional
+--notes, to split a large scope by organization area; an empty --business-unit removes the
+assignment. 'toolkit domains export --business-unit Payments' and --root export the domains of a
+business unit or root domain. Uses the current target unless --target-id is given. The same
+data is served by GET /targets/{target_id}/domain-groups.`,
+	Example: `  toolkit domains groups
+  toolkit domains groups --set example-pay.com --business-unit Payments
+  toolkit domains groups --business-unit NULL   # root domains without a business unit`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, domainsGroupsTargetID)
+		if cmd.Flags().Changed("set") {
+			group, err := core.SetDomainGroup(targetID, domainsGroupsSet, models.DomainGroupUpdate{BusinessUnit: domainsGroupsUnit, Notes: domainsGroupsNotes})
+			if err != nil {
+				exitWithError(err)
+			}
+			if group.BusinessUnit == "" {
+				fmt.Printf("%s (%d domains) has no business unit.\n", group.RootDomain, group.Domains)
+			} else {
+				fmt.Printf("%s (%d domains) belongs to %s.\n", group.RootDomain, group.Domains, group.BusinessUnit)
+			}
+			return
+		}
+		report, err := core.GetDomainGroups(targetID, domainsGroupsUnit, domainsGroupsSearch)
+		if err != nil {
+			exitWithError(err)
+		}
+		if domainsGroupsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+		if len(report.Groups) == 0 {
+			fmt.Println("No domains found.")
+			return
+		}
+		listed := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ROOT DOMAIN\tBUSINESS UNIT\tDOMAINS\tIN SCOPE\tLIVE\tTAKEOVER")
+		for _, g := range report.Groups {
+			listed += g.Domains
+			unit := g.BusinessUnit
+			if unit == "" {
+				unit = "-"
+			}
+			takeover := "-"
+			if g.TakeoverVulnerable+g.TakeoverDangling > 0 {
+				takeover = fmt.Sprintf("%d vulnerable, %d dangling", g.TakeoverVulnerable, g.TakeoverDangling)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", g.RootDomain, unit, g.Domains, g.InScope, g.Live, takeover)
+		}
+		w.Flush()
+
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BUSINESS UNIT\tROOT DOMAINS\tDOMAINS\tIN SCOPE\tLIVE\tVULNERABLE")
+		for _, u := range report.BusinessUnits {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", u.Name, len(u.RootDomains), u.Domains, u.InScope, u.Live, u.TakeoverVulnerable)
+		}
+		w.Flush()
+		fmt.Printf("\n%d domain(s) under %d root domain(s).\n", listed, len(report.Groups))
+	},
+}
+
+var domainsVhostsCmd = &cobra.Command{
+	Use:   "vhosts",
+	Short: "Discover virtual hosts on the target's in-scope IPs",
+	Long: `Starts a background job that sends candidate Host headers (and TLS server names) to each in-scope
+IP asset on the vhost.ports ports. Candidates are the target's in-scope domains, which may be served
+by IPs they do not resolve to, and word.base names under its wildcard scope rules (or the base
+domains of its domains) from the permutation wordlist. Each IP and port is first asked for the IP
+itself and random names; a candidate answered with a different page is a virtual host.
+
+Hits are logged to the traffic log with source 'Vhost Discovery', added as domains with source
+'vhost' and linked to the IP asset. The IP inventory is filled by
+POST /targets/{target_id}/ip-assets/resolve. Uses the current target unless --target-id is given. The same job can be started through
+POST /targets/{target_id}/ip-assets/vhost-discovery.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, domainsVhostsTargetID)
+		req := models.VhostDiscoveryRequest{IPAssetIDs: domainsVhostsIPAssetIDs, Ports: domainsVhostsPorts, Words: domainsVhostsWords,
+			WordsOnly: domainsVhostsWordsOnly, MaxCandidates: domainsVhostsMax}
+		if domainsVhostsStored != "" {
+			req.WordlistID = resolveWordlistID(domainsVhostsStored)
+		}
+		job, err := core.StartVhostDiscovery(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Tried %d/%d hosts (%d vhosts, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		})
+
+		domains, _, _, err := database.GetDomains(models.DomainFilters{TargetID: targetID, SourceSearch: models.DomainSourceVhost, SortBy: "domain_name", SortOrder: "ASC"})
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(domains) == 0 {
+			return
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DOMAIN\tNOTES")
+		for _, d := range domains {
+			fmt.Fprintf(w, "%s\t%s\n", d.DomainName, d.Notes.String)
+		}
+		w.Flush()
+	},
+}
+
+// flagOrCurrentTargetID returns the --target-id of a command, or the current target, and exits when
+// neither is set.
+func flagOrCurrentTargetID(cmd *cobra.Command, flagValue int64) int64 {
+	if cmd.Flags().Changed("target-id") {
+		return flagValue
+	}
+	state, err := readState()
+	if err != nil || state.CurrentTargetID == 0 {
+		fmt.Fprintln(os.Stderr, "Error: No current target set and --target-id flag not provided.")
+		fmt.Fprintln(os.Stderr, "Please set a current target using 'toolkit target set-current' or provide --target-id.")
+		os.Exit(1)
+	}
+	return state.CurrentTargetID
+}
+
+// printDomainImportResult prints the summary of an import and, with --show-skipped, the skipped rows.
+func printDomainImportResult(result models.DomainImportResult) {
+	verb := "Added"
+	if result.DryRun {
+		verb = "Would add"
+	}
+	fmt.Printf("%s %d domain(s) to target %d from %d row(s) (source: %s).\n", verb, result.Added, result.TargetID, result.Rows, result.Source)
+	fmt.Printf("Skipped: %d already tracked, %d duplicate, %d out of scope, %d invalid.\n", result.Existing, result.Duplicates, result.OutOfScope, result.Invalid)
+	if domainsImportShowSkipped && len(result.Skipped) > 0 {
+		fmt.Println()
+		for _, s := range result.Skipped {
+			fmt.Printf("  line %-6d %-13s %s\n", s.Line, s.Reason, s.Value)
+		}
+		if result.Truncated {
+			fmt.Printf("  ... only the first %d skipped rows are listed\n", len(result.Skipped))
+		}
+	}
+}
+
+func init() {
+	domainsImportCmd.Flags().Int64Var(&domainsImportTargetID, "target-id", 0, "Target to import into (defaults to the current target)")
+	domainsImportCmd.Flags().StringVarP(&domainsImportFile, "file", "f", "-", "File to import, '-' for stdin")
+	domainsImportCmd.Flags().StringVar(&domainsImportSource, "source", models.DomainImportDefaultSource, "Source recorded for the added domains (e.g. amass)")
+	domainsImportCmd.Flags().BoolVar(&domainsImportValidateScope, "validate-scope", false, "Skip domains outside the target's scope and mark added ones in scope")
+	domainsImportCmd.Flags().BoolVar(&domainsImportDryRun, "dry-run", false, "Only report what would be added")
+	domainsImportCmd.Flags().BoolVar(&domainsImportShowSkipped, "show-skipped", false, "List the skipped rows and why")
+	registerFlagCompletion(domainsImportCmd, "target-id", completeTargetIDs)
+
+	domainsExportCmd.Flags().Int64Var(&domainsExportTargetID, "target-id", 0, "Target to export (defaults to the current target)")
+	domainsExportCmd.Flags().StringVar(&domainsExportFormat, "format", "txt", "Output format: txt, csv or json")
+	domainsExportCmd.Flags().StringVarP(&domainsExportOutput, "output", "o", "", "File to write (default stdout)")
+	domainsExportCmd.Flags().StringVar(&domainsExportView, "view", "", "Apply a saved domain view by name")
+	domainsExportCmd.Flags().StringVar(&domainsExportSearch, "search", "", "Domain name contains")
+	domainsExportCmd.Flags().StringVar(&domainsExportSource, "source", "", "Source contains (e.g. subfinder)")
+	domainsExportCmd.Flags().BoolVar(&domainsExportInScope, "in-scope", false, "Only in-scope (--in-scope=true) or out-of-scope (--in-scope=false) domains")
+	domainsExportCmd.Flags().BoolVar(&domainsExportFavorites, "favorites", false, "Only favorite domains")
+	domainsExportCmd.Flags().StringVar(&domainsExportHttpx, "httpx-status", "", "all, scanned or not_scanned")
+	domainsExportCmd.Flags().StringVar(&domainsExportStatusCode, "status-code", "", "Exact httpx status code (NULL for none)")
+	domainsExportCmd.Flags().StringVar(&domainsExportServer, "server", "", "Exact httpx server (NULL for none)")
+	domainsExportCmd.Flags().StringVar(&domainsExportTech, "tech", "", "Exact httpx tech string (NULL for none)")
+	domainsExportCmd.Flags().StringVar(&domainsExportFaviconHash, "favicon-hash", "", "Exact favicon hash")
+	domainsExportCmd.Flags().StringVar(&domainsExportPort, "port", "", "Open port reported by Shodan/Censys")
+	domainsExportCmd.Flags().StringVar(&domainsExportTakeover, "takeover", "", "Takeover status: vulnerable, dangling, not_vulnerable (NULL for unchecked)")
+	domainsExportCmd.Flags().StringVar(&domainsExportRoot, "root", "", "Only domains under this root domain (eTLD+1)")
+	domainsExportCmd.Flags().StringVar(&domainsExportUnit, "business-unit", "", "Only domains whose root domain belongs to this business unit (NULL for none)")
+	domainsExportCmd.Flags().StringVar(&domainsExportSortBy, "sort-by", "", "Column to sort by (default domain_name)")
+	domainsExportCmd.Flags().StringVar(&domainsExportSortOrder, "sort-order", "", "asc or desc")
+	registerFlagCompletion(domainsExportCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(domainsExportCmd, "format", cobra.FixedCompletions(core.DomainExportFormats, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(domainsExportCmd, "httpx-status", cobra.FixedCompletions([]string{"all", "scanned", "not_scanned"}, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(domainsExportCmd, "view", completeDomainViews)
+	registerFlagCompletion(domainsExportCmd, "takeover", cobra.FixedCompletions([]string{models.TakeoverStatusVulnerable, models.TakeoverStatusDangling, models.TakeoverStatusNotVulnerable, "NULL"}, cobra.ShellCompDirectiveNoFileComp))
+
+	domainsPermuteCmd.Flags().Int64Var(&domainsPermuteTargetID, "target-id", 0, "Target to permute (defaults to the current target)")
+	domainsPermuteCmd.Flags().StringSliceVar(&domainsPermuteBases, "base", nil, "Base domain to permute under (repeatable; default: in-scope wildcard rules)")
+	domainsPermuteCmd.Flags().StringSliceVar(&domainsPermuteWords, "word", nil, "Extra word (repeatable)")
+	domainsPermuteCmd.Flags().StringVar(&domainsPermuteWordlist, "wordlist", "", "File of extra words, one per line")
+	domainsPermuteCmd.Flags().StringVar(&domainsPermuteStored, "wordlist-id", "", "Stored wordlist (ID or name) whose words are added")
+	domainsPermuteCmd.Flags().BoolVar(&domainsPermuteWordsOnly, "words-only", false, "Use only --word/--wordlist/--wordlist-id, not the configured or built-in wordlist")
+	domainsPermuteCmd.Flags().IntVar(&domainsPermuteMax, "max", 0, "Maximum candidates to generate (default permutation.max_candidates)")
+	domainsPermuteCmd.Flags().BoolVar(&domainsPermuteDryRun, "dry-run", false, "Print the candidates instead of resolving them")
+	registerFlagCompletion(domainsPermuteCmd, "target-id", completeTargetIDs)
+
+	domainsResolversCmd.Flags().BoolVar(&domainsResolversCheck, "check", false, "Run a health check of every resolver first")
+
+	domainsTakeoverCmd.Flags().Int64Var(&domainsTakeoverTargetID, "target-id", 0, "Target to check (defaults to the current target)")
+	domainsTakeoverCmd.Flags().Int64SliceVar(&domainsTakeoverDomainIDs, "domain-id", nil, "Domain to check (repeatable; default: every in-scope domain)")
+	registerFlagCompletion(domainsTakeoverCmd, "target-id", completeTargetIDs)
+
+	domainsVhostsCmd.Flags().Int64Var(&domainsVhostsTargetID, "target-id", 0, "Target to probe (defaults to the current target)")
+	domainsVhostsCmd.Flags().Int64SliceVar(&domainsVhostsIPAssetIDs, "ip-asset-id", nil, "IP asset to probe (repeatable; default: every in-scope IP)")
+	domainsVhostsCmd.Flags().IntSliceVar(&domainsVhostsPorts, "port", nil, "Port to probe, 443 and 8443 over TLS (repeatable; default vhost.ports)")
+	domainsVhostsCmd.Flags().StringSliceVar(&domainsVhostsWords, "word", nil, "Extra word (repeatable)")
+	domainsVhostsCmd.Flags().StringVar(&domainsVhostsStored, "wordlist-id", "", "Stored wordlist (ID or name) whose words are added")
+	domainsVhostsCmd.Flags().BoolVar(&domainsVhostsWordsOnly, "words-only", false, "Use only --word/--wordlist-id, not the configured or built-in wordlist")
+	domainsVhostsCmd.Flags().IntVar(&domainsVhostsMax, "max", 0, "Maximum candidate hosts (default vhost.max_candidates)")
+	registerFlagCompletion(domainsVhostsCmd, "target-id", completeTargetIDs)
+
+	domainsCmd.AddCommand(domainsImportCmd)
+	domainsCmd.AddCommand(domainsExportCmd)
+	domainsCmd.AddCommand(domainsPermuteCmd)
+	domainsCmd.AddCommand(domainsResolversCmd)
+	domainsCmd.AddCommand(domainsTakeoverCmd)
+	domainsGroupsCmd.Flags().Int64Var(&domainsGroupsTargetID, "target-id", 0, "Target whose domains to group (defaults to the current target)")
+	domainsGroupsCmd.Flags().StringVar(&domainsGroupsUnit, "business-unit", "", "Only root domains of this business unit (NULL for none); with --set, the unit to assign")
+	domainsGroupsCmd.Flags().StringVar(&domainsGroupsSearch, "search", "", "Only root domains containing this text")
+	domainsGroupsCmd.Flags().StringVar(&domainsGroupsSet, "set", "", "Root domain to assign to --business-unit")
+	domainsGroupsCmd.Flags().StringVar(&domainsGroupsNotes, "notes", "", "Notes stored with --set")
+	domainsGroupsCmd.Flags().BoolVar(&domainsGroupsJSON, "json", false, "Print the groups and business units as JSON")
+	registerFlagCompletion(domainsGroupsCmd, "target-id", completeTargetIDs)
+
+	domainsCmd.AddCommand(domainsVhostsCmd)
+	domainsCmd.AddCommand(domainsGroupsCmd)
+	rootCmd.AddCommand(domainsCmd)
+}
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	engagementTargetID int64
+	engagementLabel    string
+	engagementPlanned  int
+	engagementDue      string
+	engagementNotes    string
+	engagementLimit    int
+	engagementSince    string
+	engagementUntil    string
+	engagementJSON     bool
+)
+
+var engagementCmd = &cobra.Command{
+	Use:   "engagement",
+	Short: "Time spent and requests made on a target",
+	Long: `Tracks engagement sessions per target: stretches of time spent on it with the requests logged for it
+meanwhile, for Synack patch verifications and for billing consulting engagements.
+
+Sessions are started and stopped with the timer ('engagement start' / 'engagement stop'). With
+engagement.auto_track (default on), your own traffic to a target without a running session starts an
+automatic one, which ends at its last request once idle for engagement.idle_timeout_minutes (default
+15). A session's active time adds up the gaps between your requests, each capped at the idle timeout.
+The same data is served under /targets/{target_id}/engagement.`,
+}
+
+var engagementStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the timer of the target",
+	Long: `Starts a session for the target. With --planned or --due, an engagement_time notification is sent once
+the session runs past its planned time or its deadline.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, engagementTargetID)
+		req := models.EngagementSessionRequest{}
+		if cmd.Flags().Changed("label") {
+			req.Label = &engagementLabel
+		}
+		if cmd.Flags().Changed("planned") {
+			req.PlannedMinutes = &engagementPlanned
+		}
+		if cmd.Flags().Changed("notes") {
+			req.Notes = &engagementNotes
+		}
+		if engagementDue != "" {
+			due, err := parseEngagementDue(engagementDue)
+			if err != nil {
+				exitWithError(err)
+			}
+			req.DueAt = &due
+		}
+		session, err := core.StartEngagementSession(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Started engagement session %d for target %d at %s.\n", session.ID, targetID, session.StartedAt.Local().Format("15:04"))
+		printEngagementSchedule(session)
+	},
+}
+
+var engagementStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running session of the target",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, engagementTargetID)
+		var notes *string
+		if cmd.Flags().Changed("notes") {
+			notes = &engagementNotes
+		}
+		session, err := core.StopEngagementSession(targetID, notes)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Stopped engagement session %d after %s (%s active, %d requests, %d yours).\n", session.ID,
+			formatEngagementSeconds(session.DurationSeconds), formatEngagementSeconds(session.ActiveSeconds), session.Requests, session.ManualRequests)
+		printEngagementSchedule(session)
+	},
+}
+
+var engagementStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the running session of the target",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, engagementTargetID)
+		sessions, err := core.ListEngagementSessions(targetID, nil, nil, 1)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(sessions) == 0 || !sessions[0].Running {
+			fmt.Printf("No engagement session is running for target %d.\n", targetID)
+			return
+		}
+		s := sessions[0]
+		fmt.Printf("Engagement session %d (%s) running since %s: %s (%s active, %d requests, %d yours).\n", s.ID, s.Source,
+			s.StartedAt.Local().Format("2006-01-02 15:04"), formatEngagementSeconds(s.DurationSeconds), formatEngagementSeconds(s.ActiveSeconds),
+			s.Requests, s.ManualRequests)
+		printEngagementSchedule(s)
+	},
+}
+
+var engagementListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions of the target, latest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, engagementTargetID)
+		since, until := parseEngagementPeriodFlags()
+		sessions, err := core.ListEngagementSessions(targetID, since, until, engagementLimit)
+		if err != nil {
+			exitWithError(err)
+		}
+		if engagementJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(sessions)
+			return
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No engagement sessions found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSOURCE\tSTARTED\tENDED\tDURATION\tACTIVE\tREQUESTS\tYOURS\tLABEL")
+		for _, s := range sessions {
+			ended := "running"
+			if s.EndedAt != nil {
+				ended = s.EndedAt.Local().Format("15:04")
+			}
+			duration := formatEngagementSeconds(s.DurationSeconds)
+			if s.OverBudget || s.Overdue {
+				duration += " (over)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", s.ID, s.Source, s.StartedAt.Local().Format("2006-01-02 15:04"), ended,
+				duration, formatEngagementSeconds(s.ActiveSeconds), s.Requests, s.ManualRequests, orDash(s.Label))
+		}
+		w.Flush()
+	},
+}
+
+var engagementSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Total the time spent and requests made on the target per day",
+	Long: `Totals the sessions started within --since and --until (RFC 3339 or YYYY-MM-DD in local time; --until
+is exclusive) per day they started on. Hours are rounded to the hundredth.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, engagementTargetID)
+		since, until := parseEngagementPeriodFlags()
+		summary, err := core.GetEngagementSummary(targetID, since, until)
+		if err != nil {
+			exitWithError(err)
+		}
+		if engagementJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(summary)
+			return
+		}
+		if summary.Sessions == 0 {
+			fmt.Println("No engagement sessions found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DATE\tSESSIONS\tHOURS\tACTIVE\tREQUESTS\tYOURS")
+		for _, d := range summary.Days {
+			fmt.Fprintf(w, "%s\t%d\t%.2f\t%s\t%d\t%d\n", d.Date, d.Sessions, d.Hours, formatEngagementSeconds(d.ActiveSeconds), d.Requests, d.ManualRequests)
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%.2f\t%s\t%d\t%d\n", summary.Sessions, summary.Hours, formatEngagementSeconds(summary.ActiveSeconds),
+			summary.Requests, summary.ManualRequests)
+		w.Flush()
+		if summary.Running != nil {
+			fmt.Printf("\nSession %d is still running; its time counts up to now.\n", summary.Running.ID)
+		}
+	},
+}
+
+// parseEngagementDue parses --due: an RFC 3339 time, or a duration from now such as 90m.
+func parseEngagementDue(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return time.Now().Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --due '%s', expected an RFC 3339 time or a duration such as 90m", value)
+}
+
+// parseEngagementPeriodFlags parses --since and --until and exits when either is invalid.
+func parseEngagementPeriodFlags() (since, until *time.Time) {
+	for value, dest := range map[string]**time.Time{engagementSince: &since, engagementUntil: &until} {
+		if value == "" {
+			continue
+		}
+		t, err := core.ParseEngagementTime(value)
+		if err != nil {
+			exitWithError(err)
+		}
+		*dest = &t
+	}
+	return since, until
+}
+
+// printEngagementSchedule prints how a session stands against its time budget and deadline.
+func printEngagementSchedule(s models.EngagementSession) {
+	if s.RemainingSeconds != nil {
+		if *s.RemainingSeconds >= 0 {
+			fmt.Printf("Planned: %d minutes, %s left.\n", s.PlannedMinutes, formatEngagementSeconds(*s.RemainingSeconds))
+		} else {
+			fmt.Printf("Planned: %d minutes, %s over.\n", s.PlannedMinutes, formatEngagementSeconds(-*s.RemainingSeconds))
+		}
+	}
+	if s.DueAt != nil {
+		due := s.DueAt.Local().Format("2006-01-02 15:04")
+		if s.Overdue {
+			fmt.Printf("Due: %s (overdue).\n", due)
+		} else {
+			fmt.Printf("Due: %s.\n", due)
+		}
+	}
+}
+
+// formatEngagementSeconds formats seconds as hours and minutes, e.g. 1h05m.
+func formatEngagementSeconds(seconds int64) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dh%02dm", seconds/3600, seconds%3600/60)
+}
+
+func init() {
+	for _, c := range []*cobra.Command{engagementStartCmd, engagementStopCmd, engagementStatusCmd, engagementListCmd, engagementSummaryCmd} {
+		c.Flags().Int64VarP(&engagementTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+		registerFlagCompletion(c, "target-id", completeTargetIDs)
+		engagementCmd.AddCommand(c)
+	}
+	engagementStartCmd.Flags().StringVar(&engagementLabel, "label", "", "Label of the session, e.g. the mission or ticket")
+	engagementStartCmd.Flags().IntVar(&engagementPlanned, "planned", 0, "Time budget in minutes")
+	engagementStartCmd.Flags().StringVar(&engagementDue, "due", "", "Deadline: an RFC 3339 time or a duration from now (e.g. 2h)")
+	engagementStartCmd.Flags().StringVar(&engagementNotes, "notes", "", "Notes of the session")
+	engagementStopCmd.Flags().StringVar(&engagementNotes, "notes", "", "Replace the notes of the session")
+	for _, c := range []*cobra.Command{engagementListCmd, engagementSummaryCmd} {
+		c.Flags().StringVar(&engagementSince, "since", "", "Only sessions started at or after this time (RFC 3339 or YYYY-MM-DD)")
+		c.Flags().StringVar(&engagementUntil, "until", "", "Only sessions started before this time (RFC 3339 or YYYY-MM-DD)")
+		c.Flags().BoolVar(&engagementJSON, "json", false, "Output as JSON")
+	}
+	engagementListCmd.Flags().IntVar(&engagementLimit, "limit", 0, "Show at most this many sessions")
+	rootCmd.AddCommand(engagementCmd)
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"toolkit/models"
+)
+
+// exitWithError prints err and exits with the status of its error code, so scripts can tell
+// invalid input (2), missing records (3) and conflicts (4) from other failures (1).
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(models.ErrorExitStatus(err))
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	inboxListTargetID int64
+	inboxListLimit    int
+
+	inboxWaitTargetID int64
+	inboxWaitTimeout  time.Duration
+	inboxWaitSince    time.Duration
+)
+
+var inboxCmd = &cobra.Command{
+	Use:   "inbox",
+	Short: "Verification codes and magic links from the test inbox",
+	Long: `Reads the emails of a test inbox, over IMAP (inbox.imap) or posted to POST /api/inbox/webhook by a
+webhook catcher, and extracts their verification codes and links. Messages are attached to the
+target tagged in the recipient address (hunter+t12@example.com), else to the target whose scope
+their links or sender match, else to the current target. The newest code and magic link of a
+target are also stored as its inbox_code and inbox_link variables.`,
+}
+
+var inboxListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the received messages, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		messages, err := database.GetInboxMessages(inboxListTargetID, inboxListLimit)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(messages) == 0 {
+			fmt.Println("No inbox messages found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tRECEIVED\tTARGET\tFROM\tSUBJECT\tCODES\tMAGIC LINKS")
+		for _, m := range messages {
+			target := "-"
+			if m.TargetID.Valid {
+				target = fmt.Sprintf("%d (%s)", m.TargetID.Int64, m.MatchReason)
+			}
+			codes := strings.Join(m.Codes, ",")
+			if codes == "" {
+				codes = "-"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\n", m.ID, m.ReceivedAt.Local().Format("2006-01-02 15:04:05"), target,
+				m.From, shortenInboxSubject(m.Subject), codes, len(m.MagicLinks))
+		}
+		w.Flush()
+	},
+}
+
+var inboxShowCmd = &cobra.Command{
+	Use:   "show MESSAGE_ID",
+	Short: "Show a message with its codes, links and text",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid message ID '%s'\n", args[0])
+			os.Exit(1)
+		}
+		m, err := database.GetInboxMessage(id)
+		if err != nil {
+			exitWithError(err)
+		}
+		printInboxMessage(m)
+		if m.BodyText != "" {
+			fmt.Printf("\n%s\n", m.BodyText)
+		}
+	},
+}
+
+var inboxPollCmd = &cobra.Command{
+	Use:   "poll",
+	Short: "Read the new messages of the IMAP mailbox now",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := core.PollInboxNow(context.Background())
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Fetched %d messages, %d new.\n", result.Fetched, result.Stored)
+	},
+}
+
+var inboxWaitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait for a verification code or link for a target and print it",
+	Long: `Prints the newest code and magic link received for a target within --since, waiting up to --timeout
+for one to arrive. The IMAP mailbox is polled while waiting; webhook messages are picked up as the
+server receives them. The code alone is printed on the first line, for scripts.
+Uses the current target unless --target-id is given.`,
+	Example: `  code=$(toolkit inbox wait --timeout 2m | head -n1)`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, inboxWaitTargetID)
+		m, err := core.WaitForInboxCode(context.Background(), targetID, time.Now().Add(-inboxWaitSince), inboxWaitTimeout)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(m.Codes) > 0 {
+			fmt.Println(m.Codes[0])
+		} else {
+			fmt.Println("-")
+		}
+		printInboxMessage(*m)
+	},
+}
+
+func printInboxMessage(m models.InboxMessage) {
+	fmt.Printf("Message %d (%s) received %s\n", m.ID, m.Source, m.ReceivedAt.Local().Format("2006-01-02 15:04:05"))
+	if m.TargetID.Valid {
+		fmt.Printf("Target:  %d (%s)\n", m.TargetID.Int64, m.MatchReason)
+	}
+	fmt.Printf("From:    %s\nTo:      %s\nSubject: %s\n", m.From, strings.Join(m.To, ", "), m.Subject)
+	if len(m.Codes) > 0 {
+		fmt.Printf("Codes:   %s\n", strings.Join(m.Codes, ", "))
+	}
+	for _, link := range m.MagicLinks {
+		fmt.Printf("Magic link: %s\n", link)
+	}
+}
+
+// shortenInboxSubject keeps long subjects from widening the table.
+func shortenInboxSubject(s string) string {
+	if len(s) > 50 {
+		return s[:47] + "..."
+	}
+	return s
+}
+
+func init() {
+	inboxListCmd.Flags().Int64VarP(&inboxListTargetID, "target-id", "t", 0, "Only messages of this target (default: all)")
+	inboxListCmd.Flags().IntVarP(&inboxListLimit, "limit", "n", 50, "Number of messages to list")
+	registerFlagCompletion(inboxListCmd, "target-id", completeTargetIDs)
+
+	inboxWaitCmd.Flags().Int64VarP(&inboxWaitTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	inboxWaitCmd.Flags().DurationVar(&inboxWaitTimeout, "timeout", time.Minute, "How long to wait for a message")
+	inboxWaitCmd.Flags().DurationVar(&inboxWaitSince, "since", 10*time.Minute, "Accept messages received this long ago")
+	registerFlagCompletion(inboxWaitCmd, "target-id", completeTargetIDs)
+
+	inboxCmd.AddCommand(inboxListCmd)
+	inboxCmd.AddCommand(inboxShowCmd)
+	inboxCmd.AddCommand(inboxPollCmd)
+	inboxCmd.AddCommand(inboxWaitCmd)
+	rootCmd.AddCommand(inboxCmd)
+}
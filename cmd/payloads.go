@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	payloadsTargetID int64
+	payloadsFile     string
+	payloadsWordlist string
+	payloadsLogID    int64
+	payloadsLimit    int
+	payloadsJSON     bool
+)
+
+var payloadsCmd = &cobra.Command{
+	Use:   "payloads",
+	Short: "Expand payload placeholders and trace out-of-band interactions back to requests",
+	Long: `Modifier requests and payload lists can use placeholders that get fresh values each time they are sent:
+
+  {{oob}}            <unique id>.<payloads.oob_domain>, for out-of-band interactions (Collaborator, interactsh, own DNS)
+  {{random}}         payloads.random_length random lowercase letters and digits
+  {{timestamp}}      Unix time in seconds
+  {{target.domain}}  host of the target's link
+
+A placeholder used twice in one request gets the same value; a target variable of the same name takes
+precedence. The values are recorded with the request's log entry, so 'toolkit payloads lookup' tells
+which request an interaction or a reflected value came from.`,
+}
+
+var payloadsExpandCmd = &cobra.Command{
+	Use:   "expand [payload...]",
+	Short: "Expand the placeholders of payloads for an external fuzzer",
+	Long: `Expands the placeholders and target variables of payloads given as arguments, read from --file or
+--wordlist, or from stdin, and prints one expanded payload per line. Each payload gets its own values,
+which are recorded with the payload as written. Uses the current target unless --target-id is given.`,
+	Example: `  # Blind SSRF payloads with a unique OOB host each, fed to ffuf
+  toolkit payloads expand --file ssrf.txt > /tmp/ssrf.txt
+  ffuf -u 'https://app.example.com/fetch?url=FUZZ' -w /tmp/ssrf.txt`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, payloadsTargetID)
+		req := models.PayloadExpandRequest{Payloads: args}
+		if payloadsWordlist != "" {
+			req.WordlistID = resolveWordlistID(payloadsWordlist)
+		}
+		if len(args) == 0 && payloadsWordlist == "" {
+			var input io.Reader = os.Stdin
+			if payloadsFile != "" && payloadsFile != "-" {
+				path, err := expandTildeCmd(payloadsFile)
+				if err != nil {
+					exitWithError(err)
+				}
+				f, err := os.Open(path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+					os.Exit(1)
+				}
+				defer f.Close()
+				input = f
+			}
+			scanner := bufio.NewScanner(input)
+			scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					req.Payloads = append(req.Payloads, line)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading payloads: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		result, err := core.ExpandPayloads(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		for _, payload := range result.Payloads {
+			fmt.Fprintln(w, payload)
+		}
+		w.Flush()
+		fmt.Fprintf(os.Stderr, "Expanded %d payloads, %d values recorded.\n", len(result.Payloads), result.Recorded)
+		if len(result.Unresolved) > 0 {
+			fmt.Fprintf(os.Stderr, "No value for %v; left as written (set payloads.oob_domain for {{oob}}).\n", result.Unresolved)
+		}
+	},
+}
+
+var payloadsLookupCmd = &cobra.Command{
+	Use:   "lookup [interaction|value]",
+	Short: "Find the requests that carried a generated value",
+	Long: `Looks up values generated for payload placeholders, latest first. The argument is the name an
+out-of-band interaction came in for (e.g. the full DNS name or Host header; the {{oob}} identifier in
+it is matched) or a generated value. Without it, the latest values of the target are listed; --target-id
+also narrows a lookup to one target.`,
+	Example: `  toolkit payloads lookup x.3f9a1c0b7d2e4a65.oob.example.com
+  toolkit payloads lookup --log-id 1234`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filters := models.PayloadExpansionFilters{LogID: payloadsLogID, Limit: payloadsLimit}
+		if len(args) == 1 {
+			filters.Query = args[0]
+		}
+		if cmd.Flags().Changed("target-id") || len(args) == 0 && payloadsLogID == 0 {
+			filters.TargetID = flagOrCurrentTargetID(cmd, payloadsTargetID)
+		}
+		expansions, err := core.FindPayloadExpansions(filters)
+		if err != nil {
+			exitWithError(err)
+		}
+		if payloadsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(expansions)
+			return
+		}
+		if len(expansions) == 0 {
+			fmt.Println("No generated values found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LOG\tTARGET\tPLACEHOLDER\tVALUE\tSOURCE\tSENT\tREQUEST")
+		for _, e := range expansions {
+			logID, targetID := "-", "-"
+			if e.LogID != nil {
+				logID = fmt.Sprint(*e.LogID)
+			}
+			if e.TargetID != nil {
+				targetID = fmt.Sprint(*e.TargetID)
+			}
+			fmt.Fprintf(w, "%s\t%s\t{{%s}}\t%s\t%s\t%s\t%s\n", logID, targetID, e.Placeholder, e.Value, e.Source,
+				e.CreatedAt.Local().Format("2006-01-02 15:04:05"), orDash(e.Context))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{payloadsExpandCmd, payloadsLookupCmd} {
+		c.Flags().Int64VarP(&payloadsTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+		registerFlagCompletion(c, "target-id", completeTargetIDs)
+		payloadsCmd.AddCommand(c)
+	}
+	payloadsExpandCmd.Flags().StringVarP(&payloadsFile, "file", "f", "", "File of payloads, one per line (default: stdin)")
+	payloadsExpandCmd.Flags().StringVar(&payloadsWordlist, "wordlist", "", "Stored wordlist (ID or name) whose lines are expanded")
+	payloadsExpandCmd.MarkFlagsMutuallyExclusive("file", "wordlist")
+	payloadsLookupCmd.Flags().Int64Var(&payloadsLogID, "log-id", 0, "Values sent with this log entry")
+	payloadsLookupCmd.Flags().IntVar(&payloadsLimit, "limit", 100, "Show at most this many values")
+	payloadsLookupCmd.Flags().BoolVar(&payloadsJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(payloadsCmd)
+}
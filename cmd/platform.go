@@ -364,6 +364,11 @@ func init() {
 	platformAddCmd.MarkFlagRequired("name")
 	platformUpdateCmd.MarkFlagRequired("name")
 
+	// Complete the platform argument with names from the database
+	platformGetCmd.ValidArgsFunction = completePlatforms
+	platformUpdateCmd.ValidArgsFunction = completePlatforms
+	platformDeleteCmd.ValidArgsFunction = completePlatforms
+
 	// Add subcommands to the base platform command
 	platformCmd.AddCommand(platformListCmd)
 	platformCmd.AddCommand(platformAddCmd)
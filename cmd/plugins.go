@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pluginsListJSON           bool
+	pluginsReconTargetID      int64
+	pluginsReconValidateScope bool
+	pluginsReconDryRun        bool
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Manage plugins adding analyzers, recon sources and notification sinks",
+	Long: `Plugins are external programs adding analyzers, recon sources and notification sinks. Each plugin
+is a directory in plugins.dir (~/.config/toolkit/plugins by default) with a plugin.json:
+
+  {"name": "wappalyzer", "version": "1.0.0", "kinds": ["analyzer"],
+   "command": ["python3", "main.py"], "content_types": ["text/html"],
+   "timeout_seconds": 30, "env": ["WAPPALYZER_KEY"]}
+
+A plugin is off until enabled. For every call the toolkit starts the command in the plugin
+directory, writes a request to its stdin and reads the answer from its stdout, both JSON:
+
+  analyzer  {"protocol": 1, "kind": "analyzer", "plugin": "...", "analyze": {"body": "..."}}
+            -> {"findings": [{"category": "technology", "value": "nginx", "context": "..."}]}
+  recon     {"protocol": 1, "kind": "recon", "plugin": "...",
+             "recon": {"target_id": 1, "codename": "...", "scope": ["*.example.com"], "domains": [...]}}
+            -> {"domains": ["a.example.com", ...]}
+  notifier  {"protocol": 1, "kind": "notifier", "plugin": "...", "notification": {"kind": "...", "title": "..."}}
+            -> nothing
+
+A call fails when the plugin exits non-zero or answers {"error": "..."}. Bodies that are not UTF-8
+are sent base64-encoded with "body_base64": true. Analyzer plugins run with the built-in analyzers
+('toolkit traffic analyze'), recon plugins run with 'toolkit plugins recon' and notifier plugins get
+every notification that is sent.
+
+Plugins run with a temporary HOME and TMPDIR, only PATH and the variables listed under env, are
+killed (with the processes they started, on Linux) after timeout_seconds (plugins.timeout_seconds,
+30 by default) and may write at most plugins.max_output_bytes. This keeps them away from the
+toolkit's environment and database; it does not confine a hostile program, so only enable plugins
+you trust. A plugin whose plugin.json changes after it was enabled does not run until it is
+enabled again.`,
+	Example: `  toolkit plugins list
+  toolkit plugins enable wappalyzer
+  toolkit traffic analyze 1234 --tool wappalyzer
+  toolkit plugins recon crtsh --target-id 1 --validate-scope`,
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the plugins found in plugins.dir",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		plugins, err := core.ListPlugins()
+		if err != nil {
+			exitWithError(err)
+		}
+		if pluginsListJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(plugins)
+			return
+		}
+		if len(plugins) == 0 {
+			fmt.Printf("No plugins in %s.\n", config.AppConfig.Plugins.Dir)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tKINDS\tSTATE\tDESCRIPTION")
+		for _, p := range plugins {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, orDash(p.Version), orDash(strings.Join(p.Kinds, ",")), pluginState(p), orDash(p.Description))
+		}
+		w.Flush()
+		for _, p := range plugins {
+			if p.Error != "" {
+				fmt.Fprintf(os.Stderr, "Warning: plugin %s (%s): %s\n", p.Name, p.Dir, p.Error)
+			}
+		}
+	},
+}
+
+var pluginsEnableCmd = &cobra.Command{
+	Use:   "enable NAME",
+	Short: "Turn a plugin on",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setPluginEnabled(args[0], true)
+	},
+}
+
+var pluginsDisableCmd = &cobra.Command{
+	Use:   "disable NAME",
+	Short: "Turn a plugin off",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setPluginEnabled(args[0], false)
+	},
+}
+
+var pluginsReconCmd = &cobra.Command{
+	Use:   "recon NAME",
+	Short: "Run a recon plugin and import the domains it finds",
+	Long: `Runs a recon plugin for a target as a background job and imports the domains it returns, recorded
+with source plugin:<name>. Domains the target already has are skipped, and with --validate-scope
+also those outside its scope rules. Uses the current target unless --target-id is given. The same
+job can be started through POST /plugins/{plugin}/recon.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, pluginsReconTargetID)
+		job, err := core.StartPluginRecon(args[0], models.PluginReconRequest{TargetID: targetID,
+			ValidateScope: pluginsReconValidateScope, DryRun: pluginsReconDryRun})
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Running plugin %s...", args[0])
+		})
+	},
+}
+
+// pluginState describes whether a plugin runs.
+func pluginState(p models.Plugin) string {
+	switch {
+	case p.Error != "":
+		return "invalid"
+	case p.Enabled && p.Changed:
+		return "changed"
+	case p.Enabled:
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func setPluginEnabled(name string, enabled bool) {
+	p, err := core.SetPluginEnabled(name, enabled)
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Plugin %s (%s) %s.\n", p.Name, strings.Join(p.Kinds, ", "), pluginState(p))
+}
+
+func init() {
+	pluginsListCmd.Flags().BoolVar(&pluginsListJSON, "json", false, "Output the plugins as JSON")
+
+	f := pluginsReconCmd.Flags()
+	f.Int64VarP(&pluginsReconTargetID, "target-id", "t", 0, "Target to find domains of (default: current target)")
+	f.BoolVar(&pluginsReconValidateScope, "validate-scope", false, "Skip domains outside the target's scope rules")
+	f.BoolVar(&pluginsReconDryRun, "dry-run", false, "Run the plugin but store nothing")
+	registerFlagCompletion(pluginsReconCmd, "target-id", completeTargetIDs)
+
+	for _, c := range []*cobra.Command{pluginsEnableCmd, pluginsDisableCmd, pluginsReconCmd} {
+		c.ValidArgsFunction = completePluginNames
+	}
+	pluginsCmd.AddCommand(pluginsListCmd, pluginsEnableCmd, pluginsDisableCmd, pluginsReconCmd)
+	rootCmd.AddCommand(pluginsCmd)
+}
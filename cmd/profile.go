@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"toolkit/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileCreateProxyPort     string
+	profileCreateServerPort    string
+	profileCreateDBPath        string
+	profileCreateUpstreamProxy string
+	profileCreateShareCA       bool
+	profileCreateSettings      []string
+)
+
+var profileCmd = &cobra.Command{
+	Use:     "profile",
+	Short:   "Manage config profiles (e.g. home, vps, synack)",
+	Aliases: []string{"profiles"},
+	Long: `A config profile is a config directory of its own, with its config.yaml, database, proxy CA,
+state and logs, so that setups such as "home", "vps" or "synack" each keep their proxy port,
+database, upstream proxy and API keys. The default profile lives in ~/.config/toolkit; a named
+profile lives in ~/.config/toolkit/profiles/<name>.
+
+Select a profile with --profile on any command or the ` + config.ProfileEnvVar + ` environment variable.
+--config and --dbpath still override the profile's config file and database.`,
+	Example: `  toolkit profile create vps --proxy-port 9777 --server-port 9778 --upstream-proxy socks5://127.0.0.1:1080
+  toolkit --profile vps start
+  ` + config.ProfileEnvVar + `=vps toolkit traffic list`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the config profiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := config.ListProfiles()
+		if err != nil {
+			exitWithError(err)
+		}
+		active := config.ActiveProfile()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tPROFILE\tPROXY PORT\tSERVER PORT\tUPSTREAM PROXY\tDIRECTORY")
+		for _, name := range names {
+			conf, _, err := config.ReadProfileConfig(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			marker := ""
+			if name == active {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", marker, name, orDefault(conf.Proxy.Port), orDefault(conf.Server.Port),
+				orDash(conf.Proxy.UpstreamProxy), config.ProfileDir(name))
+		}
+		w.Flush()
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a config profile",
+	Long: `Creates the directory of a named profile with a config.yaml holding the given options. Options
+left out take their defaults, with the database, CA and logs inside the profile's directory; edit
+its config.yaml for anything else, such as API keys. Use --share-ca to reuse the default profile's
+proxy CA instead of running 'toolkit --profile <name> proxy init-ca', so browsers trusting it keep
+working.`,
+	Example: `  toolkit profile create synack --proxy-port 8877 --set enrichment.shodan.api_key=KEY --share-ca`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		settings := map[string]string{
+			"proxy.port":           profileCreateProxyPort,
+			"server.port":          profileCreateServerPort,
+			"database.path":        profileCreateDBPath,
+			"proxy.upstream_proxy": profileCreateUpstreamProxy,
+		}
+		for _, s := range profileCreateSettings {
+			key, value, ok := strings.Cut(s, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				fmt.Fprintf(os.Stderr, "Error: invalid --set '%s', expected key=value (e.g. proxy.port=9777)\n", s)
+				os.Exit(1)
+			}
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		if profileCreateShareCA {
+			defaultDir := config.ProfileDir(config.DefaultProfile)
+			settings["proxy.ca_cert_path"] = filepath.Join(defaultDir, "mytool-ca.crt")
+			settings["proxy.ca_key_path"] = filepath.Join(defaultDir, "mytool-ca.key")
+			if conf, ok, _ := config.ReadProfileConfig(config.DefaultProfile); ok && conf.Proxy.CACertPath != "" && conf.Proxy.CAKeyPath != "" {
+				settings["proxy.ca_cert_path"] = conf.Proxy.CACertPath
+				settings["proxy.ca_key_path"] = conf.Proxy.CAKeyPath
+			}
+		}
+
+		path, err := config.CreateProfile(args[0], settings)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Created profile '%s': %s\n", args[0], path)
+		if !profileCreateShareCA {
+			fmt.Printf("Generate its proxy CA with: toolkit --profile %s proxy init-ca\n", args[0])
+		}
+		fmt.Printf("Use it with --profile %s or %s=%s.\n", args[0], config.ProfileEnvVar, args[0])
+	},
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the active config profile and the paths it uses",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("Profile:        %s\n", config.ActiveProfile())
+		fmt.Printf("Directory:      %s\n", config.GetDefaultConfigPaths().ConfigDir)
+		fmt.Printf("Database:       %s\n", config.AppConfig.Database.Path)
+		fmt.Printf("Server port:    %s\n", config.AppConfig.Server.Port)
+		fmt.Printf("Proxy port:     %s\n", config.AppConfig.Proxy.Port)
+		fmt.Printf("Upstream proxy: %s\n", orDash(config.AppConfig.Proxy.UpstreamProxy))
+		fmt.Printf("Proxy CA:       %s\n", config.AppConfig.Proxy.CACertPath)
+	},
+}
+
+// orDefault shows an option a profile's config file leaves unset.
+func orDefault(s string) string {
+	if s == "" {
+		return "(default)"
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	profileCreateCmd.Flags().StringVar(&profileCreateProxyPort, "proxy-port", "", "Port of the MITM proxy (proxy.port)")
+	profileCreateCmd.Flags().StringVar(&profileCreateServerPort, "server-port", "", "Port of the API server (server.port)")
+	profileCreateCmd.Flags().StringVar(&profileCreateDBPath, "db-path", "", "Database file (database.path; default: bountytool.db in the profile's directory)")
+	profileCreateCmd.Flags().StringVar(&profileCreateUpstreamProxy, "upstream-proxy", "", "Proxy the MITM proxy forwards through, e.g. http://127.0.0.1:8080 (proxy.upstream_proxy)")
+	profileCreateCmd.Flags().BoolVar(&profileCreateShareCA, "share-ca", false, "Use the default profile's proxy CA")
+	profileCreateCmd.Flags().StringArrayVar(&profileCreateSettings, "set", nil, "Any other option as key=value, e.g. enrichment.shodan.api_key=KEY (repeatable)")
+
+	profileCmd.AddCommand(profileListCmd, profileCreateCmd, profileShowCmd)
+	rootCmd.AddCommand(profileCmd)
+}
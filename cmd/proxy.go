@@ -7,16 +7,19 @@ import (
 	// "os" // Not used
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
 	"toolkit/config"
 	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
+	"toolkit/models"
 
 	"github.com/spf13/cobra"
 )
 
 var standaloneProxyPort string
 var standaloneProxyTargetID int64
+var proxyListenersTargetID int64
 
 var proxyCmd = &cobra.Command{
 	Use:   "proxy",
@@ -108,7 +111,7 @@ var proxyInitCACmd = &cobra.Command{
 			return
 		}
 
-		err := core.GenerateAndSaveCA(certPath, keyPath)
+		err := core.GenerateAndSaveCA(certPath, keyPath, config.AppConfig.Proxy.CAKeyType)
 		if err != nil {
 			fmt.Printf("Error generating CA. Check logs for details: %v\n", err)
 			return
@@ -117,12 +120,122 @@ var proxyInitCACmd = &cobra.Command{
 	},
 }
 
+var proxyRotateCACmd = &cobra.Command{
+	Use:   "rotate-ca",
+	Short: "Replaces the proxy CA with a newly generated one, keeping a backup of the old certificate and key",
+	Long: `Generates a new root CA (key type from proxy.ca_key_type: rsa or ecdsa) in place of the current one.
+The old certificate and key are renamed with a .rotated-<time> suffix. Restart the proxy and install the
+new certificate (e.g. from http://<proxy address>/proxy/ca.crt) on your clients afterwards.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		certPath := config.AppConfig.Proxy.CACertPath
+		keyPath := config.AppConfig.Proxy.CAKeyPath
+		if certPath == "" || keyPath == "" {
+			logger.Error("CA certificate or key path is not defined in configuration.")
+			return
+		}
+
+		certBackup, keyBackup, err := core.RotateCA(certPath, keyPath, config.AppConfig.Proxy.CAKeyType)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rotating CA: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Previous CA certificate kept as %s\n", certBackup)
+		fmt.Printf("Previous CA private key kept as %s\n", keyBackup)
+		fmt.Println("Restart the proxy and import the new CA certificate into your browser/system's trust store.")
+	},
+}
+
+var proxyListenersCmd = &cobra.Command{
+	Use:   "listeners",
+	Short: "Manage additional proxy ports bound to targets",
+	Long: `Additional proxy listeners run next to the main proxy port, each bound to a target: requests
+through them are checked against that target's scope and logged for it, whichever target is active.
+Point browser profiles at different ports to test several programs at once.
+Listeners start with the proxy. Changes made here apply when the proxy next starts; use the
+/api/proxy/listeners endpoints to change the listeners of a running proxy.`,
+	Example: `  # Bind port 8779 to target 2 and port 8780 to the current target
+  toolkit proxy listeners add 8779 --target-id 2
+  toolkit proxy listeners add 8780`,
+}
+
+var proxyListenersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the proxy listeners bound to targets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listeners, err := core.ListProxyListeners()
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(listeners) == 0 {
+			fmt.Println("No proxy listeners.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PORT\tTARGET\tCODENAME\tCREATED")
+		for _, l := range listeners {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", l.Port, l.TargetID, l.TargetCodename, l.CreatedAt.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
+var proxyListenersAddCmd = &cobra.Command{
+	Use:   "add PORT",
+	Short: "Add a proxy listener bound to a target (default: the current target)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, proxyListenersTargetID)
+		l, err := core.AddProxyListener(models.ProxyListenerRequest{Port: args[0], TargetID: targetID})
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Proxy listener on port %s bound to target %d (%s). It starts with the proxy.\n", l.Port, l.TargetID, l.TargetCodename)
+	},
+}
+
+var proxyListenersBindCmd = &cobra.Command{
+	Use:   "bind PORT",
+	Short: "Bind a proxy listener to another target (default: the current target)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, proxyListenersTargetID)
+		l, err := core.SetProxyListenerTarget(args[0], targetID)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Proxy listener on port %s bound to target %d (%s).\n", l.Port, l.TargetID, l.TargetCodename)
+	},
+}
+
+var proxyListenersRemoveCmd = &cobra.Command{
+	Use:     "rm PORT",
+	Short:   "Remove a proxy listener",
+	Aliases: []string{"remove"},
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := core.RemoveProxyListener(args[0]); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Proxy listener on port %s removed.\n", args[0])
+	},
+}
+
 func init() {
 	// UPDATED default port to 8777
 	proxyStartCmd.Flags().StringVarP(&standaloneProxyPort, "port", "p", "8777", "Port for the proxy server to listen on (overrides config)")
 	proxyStartCmd.Flags().Int64VarP(&standaloneProxyTargetID, "target-id", "t", 0, "Target ID to associate logged traffic with (optional)")
+	registerFlagCompletion(proxyStartCmd, "target-id", completeTargetIDs)
 
 	proxyCmd.AddCommand(proxyStartCmd)
 	proxyCmd.AddCommand(proxyInitCACmd)
+	proxyCmd.AddCommand(proxyRotateCACmd)
+
+	for _, c := range []*cobra.Command{proxyListenersAddCmd, proxyListenersBindCmd} {
+		c.Flags().Int64VarP(&proxyListenersTargetID, "target-id", "t", 0, "Target to bind the listener to (default: the current target)")
+		registerFlagCompletion(c, "target-id", completeTargetIDs)
+	}
+	proxyListenersCmd.AddCommand(proxyListenersListCmd, proxyListenersAddCmd, proxyListenersBindCmd, proxyListenersRemoveCmd)
+	proxyCmd.AddCommand(proxyListenersCmd)
 	rootCmd.AddCommand(proxyCmd)
 }
\ No newline at end of file
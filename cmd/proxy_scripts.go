@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyScriptEvents    []string
+	proxyScriptTargetID  int64
+	proxyScriptHost      string
+	proxyScriptTimeoutMs int
+	proxyScriptPosition  int
+	proxyScriptDisabled  bool
+	proxyScriptTestLogID int64
+	proxyScriptTestEvent string
+)
+
+var proxyScriptsCmd = &cobra.Command{
+	Use:   "scripts",
+	Short: "Manage scripts the proxy runs on requests and responses",
+	Long: `Proxy scripts are user programs the proxy runs on request and response events, e.g. to sign requests
+with an HMAC, add a header the app computes client-side or tag traffic. A script gets the message as JSON
+on stdin:
+
+  {"event": "request", "script": "sign", "target_id": 1,
+   "request": {"method": "POST", "url": "https://...", "headers": {"Content-Type": ["application/json"]}, "body": "{...}"},
+   "response": {"status": 200, "headers": {...}, "body": "..."}}          (response events only)
+
+and writes JSON to stdout, or nothing to leave the message as it is:
+
+  {"action": "continue", "request": {"headers": {...}}, "tags": ["signed"]}
+
+Fields returned under request (method, url, headers, body) or response (status, headers, body) replace the
+message's; headers replace all headers. "drop" answers a request with 403 without sending it, or keeps a
+response out of the log. Bodies that are not UTF-8 come and go base64-encoded with "body_base64": true.
+Files run with lua (.lua), node (.js), starlark (.star), python3 (.py), ruby (.rb) or sh (.sh); other
+files must be executable. A failing or slow script (--timeout-ms, 2s by default) is logged and leaves the
+message unchanged. Scripts run in --position order, each on what the previous one returned.
+
+Scripts run only while proxy_scripts.enabled is set in the config, and only files in proxy_scripts.dir
+(~/.config/toolkit/scripts); PATH is given relative to it. They run with a temporary HOME and none of the
+toolkit's environment besides PATH.`,
+	Example: `  toolkit proxy scripts add sign hmac.lua --host 'api.example.com'
+  toolkit proxy scripts add tag-errors errors.js --event response
+  toolkit proxy scripts test 1 --log-id 1234`,
+}
+
+var proxyScriptsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the proxy scripts in the order they run",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		scripts, err := core.ListProxyScripts()
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(scripts) == 0 {
+			fmt.Println("No proxy scripts.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tENABLED\tEVENTS\tTARGET\tHOST\tPATH\tRUNS\tFAILURES")
+		for _, s := range scripts {
+			target := "-"
+			if s.TargetID != nil {
+				target = strconv.FormatInt(*s.TargetID, 10)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%t\t%s\t%s\t%s\t%s\t%d\t%d\n", s.ID, s.Name, s.Enabled, strings.Join(s.Events, ","), target,
+				orDash(s.HostPattern), s.Path, s.Runs, s.Failures)
+		}
+		w.Flush()
+	},
+}
+
+var proxyScriptsAddCmd = &cobra.Command{
+	Use:   "add NAME PATH",
+	Short: "Add a script the proxy runs on requests (or --event response)",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := expandTildeCmd(args[1])
+		if err != nil {
+			exitWithError(err)
+		}
+		s := models.ProxyScript{Name: args[0], Path: path, Events: proxyScriptEvents,
+			HostPattern: proxyScriptHost, Enabled: !proxyScriptDisabled, TimeoutMs: proxyScriptTimeoutMs, Position: proxyScriptPosition}
+		if cmd.Flags().Changed("target-id") {
+			s.TargetID = &proxyScriptTargetID
+		}
+		created, err := core.CreateProxyScript(s)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Proxy script %d (%s) runs %s on %s events.\n", created.ID, created.Name, created.Path, strings.Join(created.Events, " and "))
+	},
+}
+
+var proxyScriptsRemoveCmd = &cobra.Command{
+	Use:     "rm SCRIPT_ID",
+	Short:   "Remove a proxy script",
+	Aliases: []string{"remove"},
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id := proxyScriptIDArg(args[0])
+		if err := core.DeleteProxyScript(id); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Proxy script %d removed.\n", id)
+	},
+}
+
+var proxyScriptsEnableCmd = &cobra.Command{
+	Use:   "enable SCRIPT_ID",
+	Short: "Turn a proxy script on",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setProxyScriptEnabled(args[0], true)
+	},
+}
+
+var proxyScriptsDisableCmd = &cobra.Command{
+	Use:   "disable SCRIPT_ID",
+	Short: "Turn a proxy script off",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setProxyScriptEnabled(args[0], false)
+	},
+}
+
+var proxyScriptsTestCmd = &cobra.Command{
+	Use:   "test SCRIPT_ID",
+	Short: "Run a proxy script against a logged request and show what it returns",
+	Long: `Runs a proxy script against a request (and its response, for --event response) from the traffic log
+and prints the script's input, output and stderr as JSON. Nothing is sent or changed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := core.TestProxyScript(proxyScriptIDArg(args[0]),
+			models.ProxyScriptTestRequest{LogID: proxyScriptTestLogID, Event: proxyScriptTestEvent})
+		if err != nil {
+			exitWithError(err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+		if result.Error != "" {
+			os.Exit(1)
+		}
+	},
+}
+
+func proxyScriptIDArg(arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid script ID '%s'\n", arg)
+		os.Exit(2)
+	}
+	return id
+}
+
+func setProxyScriptEnabled(arg string, enabled bool) {
+	s, err := core.SetProxyScriptEnabled(proxyScriptIDArg(arg), enabled)
+	if err != nil {
+		exitWithError(err)
+	}
+	state := "disabled"
+	if s.Enabled {
+		state = "enabled"
+	}
+	fmt.Printf("Proxy script %d (%s) %s.\n", s.ID, s.Name, state)
+}
+
+func init() {
+	f := proxyScriptsAddCmd.Flags()
+	f.StringSliceVar(&proxyScriptEvents, "event", []string{models.ProxyScriptEventRequest}, "Events the script runs on: request, response (repeatable)")
+	f.Int64VarP(&proxyScriptTargetID, "target-id", "t", 0, "Only run on traffic of this target (default: all traffic)")
+	f.StringVar(&proxyScriptHost, "host", "", "Only run on hosts matching this pattern, e.g. '*.example.com'")
+	f.IntVar(&proxyScriptTimeoutMs, "timeout-ms", 0, "Time the script may take per message (default 2000)")
+	f.IntVar(&proxyScriptPosition, "position", 0, "Scripts run in ascending position")
+	f.BoolVar(&proxyScriptDisabled, "disabled", false, "Add the script turned off")
+	registerFlagCompletion(proxyScriptsAddCmd, "target-id", completeTargetIDs)
+
+	proxyScriptsTestCmd.Flags().Int64Var(&proxyScriptTestLogID, "log-id", 0, "Log entry the script runs against")
+	proxyScriptsTestCmd.Flags().StringVar(&proxyScriptTestEvent, "event", "", "request or response (default: the script's first event)")
+	proxyScriptsTestCmd.MarkFlagRequired("log-id")
+
+	proxyScriptsCmd.AddCommand(proxyScriptsListCmd, proxyScriptsAddCmd, proxyScriptsRemoveCmd, proxyScriptsEnableCmd,
+		proxyScriptsDisableCmd, proxyScriptsTestCmd)
+	proxyCmd.AddCommand(proxyScriptsCmd)
+}
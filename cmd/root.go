@@ -6,6 +6,7 @@ import (
 	"path/filepath" // Added for path manipulation
 	"strings"
 	"toolkit/config"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 
@@ -18,6 +19,7 @@ var (
 	appLogPathFlag   string
 	proxyLogPathFlag string
 	logLevelFlag     string
+	profileFlag      string
 )
 
 // Helper function to expand tilde in this package too
@@ -42,6 +44,10 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to simplify bug bounty hunting tasks.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The profile decides the default config, database and log paths, so it is selected first
+		if err := config.SetProfile(profileFlag); err != nil {
+			return err
+		}
 		// Initialize config first, passing flag values for logging config
 		if err := config.Init(cfgFile, appLogPathFlag, proxyLogPathFlag, logLevelFlag); err != nil {
 			return fmt.Errorf("failed to initialize config in PersistentPreRunE: %w", err)
@@ -88,6 +94,9 @@ This application is a tool to simplify bug bounty hunting tasks.`,
 		if err := database.InitDB(finalDBPath); err != nil {
 			return fmt.Errorf("failed to initialize database at %s: %w", finalDBPath, err)
 		}
+		if err := core.ApplyStoredRuntimeSettings(); err != nil {
+			logger.Error("PersistentPreRunE: Could not apply stored runtime settings: %v", err)
+		}
 
 		isSuppressedCmd := false
 		if cmd.Name() == "completion" ||
@@ -111,7 +120,8 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/toolkit/config.yaml or ./config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "config profile to use, e.g. home or vps (default: $"+config.ProfileEnvVar+", then the default profile); see 'toolkit profile'")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is the profile's config.yaml: $HOME/.config/toolkit/config.yaml or ./config.yaml for the default profile)")
 	rootCmd.PersistentFlags().StringVar(&dbPath, "dbpath", "", "path to SQLite database file (overrides config/default)")
 	rootCmd.PersistentFlags().StringVar(&appLogPathFlag, "app-log", "", "path for the application log file (overrides config/default)")
 	rootCmd.PersistentFlags().StringVar(&proxyLogPathFlag, "proxy-log", "", "path for the proxy log file (overrides config/default)")
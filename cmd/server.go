@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strings"
 	"toolkit/api"
+	"toolkit/config"
+	"toolkit/core"
 	"toolkit/logger"
 
 	"github.com/spf13/cobra"
@@ -11,6 +13,9 @@ import (
 
 var standaloneServerPort string
 
+// readOnlyFlag is bound to --read-only of the server and start commands.
+var readOnlyFlag bool
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Starts the web UI and API server (can be run standalone or as part of 'start')",
@@ -23,6 +28,8 @@ var serverCmd = &cobra.Command{
 		logger.Info("--- Server Command: Run ---")
 		logger.Info("Attempting to start server on port %s...", portToUse)
 
+		runStartupMaintenance()
+
 		logger.Info("Server Command: Calling api.NewRouter()...")
 		apiRouter := api.NewRouter()
 		if apiRouter == nil {
@@ -59,8 +66,24 @@ var serverCmd = &cobra.Command{
 	},
 }
 
+// runStartupMaintenance applies --read-only, then fails the jobs interrupted by the last shutdown
+// and prunes orphaned files, unless the toolkit runs read-only.
+func runStartupMaintenance() {
+	if readOnlyFlag {
+		config.AppConfig.Server.ReadOnly = true
+	}
+	if core.ReadOnly() {
+		logger.Info("Read-only mode: API requests that change data are refused and proxy traffic is not logged.")
+		return
+	}
+	core.FailInterruptedJobs()
+	core.PruneAttachmentFiles()
+	core.PruneWordlistFiles()
+}
+
 func init() {
 	// UPDATED default port to 8778
 	serverCmd.Flags().StringVarP(&standaloneServerPort, "port", "p", "8778", "Port for the server to listen on (if run standalone)")
+	serverCmd.Flags().BoolVar(&readOnlyFlag, "read-only", false, "Refuse API requests that change data and don't log proxy traffic (overrides config)")
 	rootCmd.AddCommand(serverCmd)
 }
\ No newline at end of file
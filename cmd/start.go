@@ -60,6 +60,8 @@ Press Ctrl+C to gracefully shut down all services.`,
 		actualProxyTargetID := startProxyTargetID
 		logger.Info("Start Command: Final ports determined - Server: %s, Proxy: %s", actualServerPort, actualProxyPort)
 
+		runStartupMaintenance()
+
 		var wg sync.WaitGroup
 
 		ctx, cancel := context.WithCancel(context.Background())
@@ -113,6 +115,7 @@ Press Ctrl+C to gracefully shut down all services.`,
 
 		// --- Initialize Synack Mission Polling Service (before proxy needs it) ---
 		missionService := core.NewSynackMissionService(ctx, &config.AppConfig, database.DB)
+		core.RegisterRuntimeServices(ctx, missionService)
 
 		// --- Start MITM Proxy Goroutine ---
 		wg.Add(1)
@@ -173,6 +176,48 @@ Press Ctrl+C to gracefully shut down all services.`,
 			logger.Info("Start Command: Synack Mission Polling Service is disabled in configuration, not starting.")
 		}
 
+		if config.AppConfig.Watcher.Enabled {
+			// Started through core so the settings API can stop and restart it
+			logger.Info("Start Command: Starting Synack target/mission watcher...")
+			core.StartSynackWatcher(ctx)
+		}
+
+		if config.AppConfig.CTWatch.Enabled {
+			wg.Add(1)
+			go func(parentCtx context.Context) {
+				defer wg.Done()
+				logger.Info("Start Command Goroutine(CTWatch): Starting certificate transparency watcher...")
+				core.RunCTWatcher(parentCtx)
+			}(ctx)
+		}
+
+		if syncCfg := config.AppConfig.Sync; syncCfg.Enabled && syncCfg.IntervalMinutes > 0 && len(syncCfg.Peers) > 0 {
+			wg.Add(1)
+			go func(parentCtx context.Context) {
+				defer wg.Done()
+				logger.Info("Start Command Goroutine(Sync): Starting sync with peers...")
+				core.RunSyncLoop(parentCtx)
+			}(ctx)
+		}
+
+		if dnsCfg := config.AppConfig.DNS; (len(dnsCfg.Resolvers) > 0 || dnsCfg.ResolversFile != "") && dnsCfg.HealthCheckIntervalSeconds > 0 {
+			wg.Add(1)
+			go func(parentCtx context.Context) {
+				defer wg.Done()
+				logger.Info("Start Command Goroutine(DNS): Starting resolver health checks...")
+				core.RunDNSHealthChecks(parentCtx)
+			}(ctx)
+		}
+
+		if config.AppConfig.Inbox.Enabled && config.AppConfig.Inbox.IMAP.Address != "" {
+			wg.Add(1)
+			go func(parentCtx context.Context) {
+				defer wg.Done()
+				logger.Info("Start Command Goroutine(Inbox): Starting IMAP inbox poller...")
+				core.RunInboxPoller(parentCtx)
+			}(ctx)
+		}
+
 		// --- Wait for termination signal ---
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -211,5 +256,7 @@ func init() {
 	startCmd.Flags().StringVar(&startServerPort, "server-port", "8778", "Port for the API server (overrides config)")
 	startCmd.Flags().StringVar(&startProxyPort, "proxy-port", "8777", "Port for the MITM proxy server (overrides config)")
 	startCmd.Flags().Int64Var(&startProxyTargetID, "proxy-target-id", 0, "Target ID for the proxy to associate traffic with (optional)")
+	startCmd.Flags().BoolVar(&readOnlyFlag, "read-only", false, "Refuse API requests that change data and don't log proxy traffic (overrides config)")
+	registerFlagCompletion(startCmd, "proxy-target-id", completeTargetIDs)
 	rootCmd.AddCommand(startCmd)
 }
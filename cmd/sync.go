@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var syncStatusJSON bool
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync targets, scope, domains, findings and selected traffic with other toolkit instances",
+	Long: `Keeps toolkit instances, e.g. a laptop and a VPS doing recon, in sync: targets, scope rules,
+domains, findings and selected traffic log entries (favorites, entries tagged with one of
+sync.traffic_tags and the evidence of findings) changed on one show up on the other.
+
+Every instance gets the same sync.key ('toolkit sync keygen') and sync.enabled: true. An instance
+lists the others under sync.peers with the URL of their API; the peers only need to run the
+server. 'toolkit sync run' (or sync.interval_minutes while 'toolkit start' runs) sends the local
+changes to each peer through POST /api/sync and receives the peer's changes in return. Messages
+are compressed and encrypted with AES-256-GCM under a key derived from sync.key, so the channel
+needs no TLS to stay private; requests older than 10 minutes or seen before are refused.
+
+Conflicts are settled per row, last writer wins: the most recent change to a row is kept, and a
+deletion wins over changes made before it. Rows created on both instances independently (the same
+target slug, domain or scope rule) are merged. Deletions are remembered for sync.tombstone_days.`,
+	Example: `  toolkit sync keygen
+  toolkit sync status
+  toolkit sync run vps`,
+}
+
+var syncKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a key for sync.key",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := core.GenerateSyncKey()
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(key)
+	},
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the sync setup and the progress with each peer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := core.GetSyncStatus()
+		if err != nil {
+			exitWithError(err)
+		}
+		if syncStatusJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(status)
+			return
+		}
+		fmt.Printf("Enabled:     %t\n", status.Enabled)
+		key := "missing or invalid"
+		if status.KeyConfigured {
+			key = "configured"
+		}
+		fmt.Printf("Key:         %s\n", key)
+		fmt.Printf("Instance ID: %s\n", orDash(status.InstanceID))
+		fmt.Printf("Changes:     %d\n", status.Sequence)
+		if len(status.Peers) == 0 {
+			fmt.Println("\nNo peers in sync.peers.")
+			return
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PEER\tURL\tLAST SYNC\tPENDING\tLAST RESULT")
+		for _, p := range status.Peers {
+			lastSync, result := "-", "-"
+			if p.LastSyncAt != nil {
+				lastSync = p.LastSyncAt.Local().Format(time.DateTime)
+			}
+			switch {
+			case p.LastError != "":
+				result = "error: " + p.LastError
+			case p.LastResult != nil:
+				result = fmt.Sprintf("sent %d, received %d", p.LastResult.Pushed, p.LastResult.Pulled)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", p.Name, p.URL, lastSync, p.Pending, result)
+		}
+		w.Flush()
+	},
+}
+
+var syncRunCmd = &cobra.Command{
+	Use:   "run [PEER]",
+	Short: "Sync with a peer, or with all peers of sync.peers",
+	Long: `Exchanges changes with a peer of sync.peers, or with each of them, as a background job, until
+neither side has more. The progress is stored after every exchange, so an interrupted run resumes
+where it stopped. The same job can be started through POST /sync/run.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeSyncPeers,
+	Run: func(cmd *cobra.Command, args []string) {
+		var req models.SyncRunRequest
+		if len(args) == 1 {
+			req.Peer = args[0]
+		}
+		job, err := core.StartSync(req)
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("%s (%d/%d peers)", job.Message, job.ItemsProcessed, job.ItemsTotal)
+		})
+	},
+}
+
+// completeSyncPeers completes the names of sync.peers.
+func completeSyncPeers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, p := range config.AppConfig.Sync.Peers {
+		completions = append(completions, p.Name+"\t"+p.URL)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	syncStatusCmd.Flags().BoolVar(&syncStatusJSON, "json", false, "Output the status as JSON")
+	syncCmd.AddCommand(syncKeygenCmd, syncStatusCmd, syncRunCmd)
+	rootCmd.AddCommand(syncCmd)
+}
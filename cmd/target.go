@@ -5,13 +5,17 @@ import (
 	"encoding/json" // ADDED import for json
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath" // ADDED import for filepath
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 	"toolkit/config"
+	"toolkit/core"
 	"toolkit/database"
 	"toolkit/logger"
 	"toolkit/models"
@@ -748,6 +752,439 @@ var targetDeleteCmd = &cobra.Command{
 	},
 }
 
+// --- Clone Command ---
+
+var (
+	targetCloneComponents []string
+	targetCloneWithout    []string
+)
+
+var targetCloneCmd = &cobra.Command{
+	Use:   "clone [id|slug]",
+	Short: "Create a new target from an existing one",
+	Long: `Creates a new target that starts from the baseline of an existing one, for recurring engagements
+against the same program. By default every component is copied:
+  scope           in-scope rules
+  exclusions      out-of-scope rules
+  checklist       checklist items (not completed, without notes)
+  notes           the target's notes
+  program         payout ranges and program rules
+  redaction       per-target redaction rules
+  client_profile  headers, user agent and cookies added to toolkit requests
+  variables       Modifier variables
+  saved_views     saved traffic log and domain filters
+  settings        per-target runtime setting overrides
+Findings, traffic and recon results are never copied.`,
+	Example: `  toolkit target clone acme --codename "ACME Q3"
+  toolkit target clone 4 --codename "ACME retest" --components scope,exclusions,checklist
+  toolkit target clone 4 --codename "ACME retest" --without notes,saved_views`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		identifier := args[0]
+		logger.Info("Executing 'target clone' command for identifier: %s", identifier)
+
+		sourceID, err := getTargetIDByIdentifier(identifier, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding target: %v\n", err)
+			os.Exit(1)
+		}
+		if len(targetCloneComponents) > 0 && len(targetCloneWithout) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --components and --without cannot be used together.")
+			os.Exit(1)
+		}
+		components := targetCloneComponents
+		if len(targetCloneWithout) > 0 {
+			skip := make(map[string]bool)
+			for _, c := range targetCloneWithout {
+				skip[strings.ToLower(strings.TrimSpace(c))] = true
+			}
+			for _, c := range models.TargetCloneComponents {
+				if !skip[c] {
+					components = append(components, c)
+				}
+			}
+			if len(components) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: --without leaves no component to copy.")
+				os.Exit(1)
+			}
+		}
+
+		req := models.TargetCloneRequest{
+			Codename:   targetCodename,
+			PlatformID: targetPlatformID,
+			Link:       targetLink,
+			Components: components,
+		}
+		if cmd.Flags().Changed("notes") {
+			req.Notes = &targetNotes
+		}
+		result, err := database.CloneTarget(sourceID, req)
+		if err != nil {
+			logger.Error("target clone: %v", err)
+			fmt.Fprintf(os.Stderr, "Error cloning target: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Cloned target %d into '%s' (ID: %d, Slug: %s, Platform ID: %d)\n",
+			sourceID, result.Target.Codename, result.Target.ID, result.Target.Slug, result.Target.PlatformID)
+		writer := new(tabwriter.Writer)
+		writer.Init(os.Stdout, 4, 8, 1, '\t', 0)
+		fmt.Fprintln(writer, "  COMPONENT\tCOPIED")
+		for _, c := range models.TargetCloneComponents {
+			if n, ok := result.Copied[c]; ok {
+				fmt.Fprintf(writer, "  %s\t%d\n", c, n)
+			}
+		}
+		writer.Flush()
+	},
+}
+
+// --- Client Profile Command ---
+
+var (
+	targetProfileHeaders       []string
+	targetProfileRemoveHeaders []string
+	targetProfileUserAgent     string
+	targetProfileCookies       string
+	targetProfileClear         bool
+)
+
+var targetClientProfileCmd = &cobra.Command{
+	Use:   "client-profile [id|slug]",
+	Short: "Show or change the headers, user agent and cookies added to toolkit requests",
+	Long: `A target's client profile is added to the requests the toolkit sends for it: JS path sends,
+the Modifier, replays, GraphQL introspection, harvesting, baselining, favicon fetches and httpx
+scans. Use it for program-required headers such as X-Bug-Bounty. Headers a request already has
+keep their value. Without flags the profile is shown; the current target is used when no target
+is given.`,
+	Example: `  toolkit target client-profile --header "X-Bug-Bounty: researcher-handle"
+  toolkit target client-profile acme --user-agent "Mozilla/5.0 (researcher-handle)" --cookies "program=1"
+  toolkit target client-profile --remove-header X-Bug-Bounty
+  toolkit target client-profile --clear`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := targetFromArgsOrCurrent(args)
+
+		if targetProfileClear {
+			if err := core.DeleteTargetClientProfile(targetID); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Removed the client profile of target %d.\n", targetID)
+			return
+		}
+		profile, err := core.GetTargetClientProfile(targetID)
+		if err != nil {
+			exitWithError(err)
+		}
+		changed := false
+		for _, h := range targetProfileHeaders {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: Invalid header '%s', expected 'Name: value'.\n", h)
+				os.Exit(1)
+			}
+			profile.Headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+			changed = true
+		}
+		for _, name := range targetProfileRemoveHeaders {
+			delete(profile.Headers, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+			changed = true
+		}
+		if cmd.Flags().Changed("user-agent") {
+			profile.UserAgent = targetProfileUserAgent
+			changed = true
+		}
+		if cmd.Flags().Changed("cookies") {
+			profile.Cookies = targetProfileCookies
+			changed = true
+		}
+		if changed {
+			if profile, err = core.SaveTargetClientProfile(profile); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Updated the client profile of target %d.\n", targetID)
+		}
+
+		if profile.IsEmpty() {
+			fmt.Printf("Target %d has no client profile.\n", targetID)
+			return
+		}
+		names := make([]string, 0, len(profile.Headers))
+		for name := range profile.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s: %s\n", name, profile.Headers[name])
+		}
+		if profile.UserAgent != "" {
+			fmt.Printf("User-Agent: %s\n", profile.UserAgent)
+		}
+		if profile.Cookies != "" {
+			fmt.Printf("Cookie: %s\n", profile.Cookies)
+		}
+	},
+}
+
+var (
+	targetConstraintsMaxRPS        float64
+	targetConstraintsBanPaths      []string
+	targetConstraintsUnbanPaths    []string
+	targetConstraintsHeaders       []string
+	targetConstraintsRemoveHeaders []string
+	targetConstraintsClear         bool
+	targetConstraintsEvents        bool
+	targetConstraintsEventsLimit   int
+)
+
+var targetProgramConstraintsCmd = &cobra.Command{
+	Use:   "program-constraints [id|slug]",
+	Short: "Show or change the rate limit, banned paths and required headers of a program",
+	Long: `A target's program constraints bind the toolkit's own requests: those sent through the proxy
+(replays, environment comparisons, GraphQL introspection and JS analysis) and those the checks send
+directly (CORS, redirects, login flows, takeover, cloud storage, vhosts, parameter mining, harvesting,
+baselines and enrichment). Requests to banned paths are blocked (403 from the proxy) and never reach
+the host, required headers are set on every request and requests are delayed to stay under the
+maximum rate. Each action is recorded; list them with --events. Banned paths match
+case-insensitively; * matches any characters and a path without one also covers the paths below
+it. Without flags the constraints are shown; the current target is used when no target is given.`,
+	Example: `  toolkit target program-constraints --max-rps 5 --header "X-HackerOne: researcher-handle"
+  toolkit target program-constraints acme --ban-path /logout --ban-path "/api/*/delete"
+  toolkit target program-constraints --unban-path /logout --remove-header X-HackerOne
+  toolkit target program-constraints --events --limit 50
+  toolkit target program-constraints --clear`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := targetFromArgsOrCurrent(args)
+
+		if targetConstraintsClear {
+			if err := core.DeleteTargetProgramConstraints(targetID); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Removed the program constraints of target %d.\n", targetID)
+			return
+		}
+		if targetConstraintsEvents {
+			events, err := core.ListProgramConstraintEvents(targetID, "", targetConstraintsEventsLimit)
+			if err != nil {
+				exitWithError(err)
+			}
+			if len(events) == 0 {
+				fmt.Printf("No requests of target %d were blocked, changed or delayed.\n", targetID)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tACTION\tSOURCE\tREQUEST\tDETAIL")
+			for _, e := range events {
+				detail := e.Detail
+				if e.DelayMs > 0 {
+					detail = fmt.Sprintf("%s, waited %dms", detail, e.DelayMs)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s %s\t%s\n", e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.Action, orDash(e.Source), e.Method, e.URL, detail)
+			}
+			w.Flush()
+			return
+		}
+		constraints, err := core.GetTargetProgramConstraints(targetID)
+		if err != nil {
+			exitWithError(err)
+		}
+		changed := false
+		if cmd.Flags().Changed("max-rps") {
+			constraints.MaxRPS = targetConstraintsMaxRPS
+			changed = true
+		}
+		for _, p := range targetConstraintsBanPaths {
+			constraints.BannedPaths = append(constraints.BannedPaths, p)
+			changed = true
+		}
+		for _, p := range targetConstraintsUnbanPaths {
+			kept := constraints.BannedPaths[:0]
+			for _, banned := range constraints.BannedPaths {
+				if banned != strings.TrimSpace(p) {
+					kept = append(kept, banned)
+				}
+			}
+			constraints.BannedPaths = kept
+			changed = true
+		}
+		for _, h := range targetConstraintsHeaders {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: Invalid header '%s', expected 'Name: value'.\n", h)
+				os.Exit(1)
+			}
+			constraints.RequiredHeaders[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+			changed = true
+		}
+		for _, name := range targetConstraintsRemoveHeaders {
+			delete(constraints.RequiredHeaders, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+			changed = true
+		}
+		if changed {
+			if constraints, err = core.SaveTargetProgramConstraints(constraints); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Updated the program constraints of target %d.\n", targetID)
+		}
+
+		if constraints.IsEmpty() {
+			fmt.Printf("Target %d has no program constraints.\n", targetID)
+			return
+		}
+		if constraints.MaxRPS > 0 {
+			fmt.Printf("Max rate:      %g req/s\n", constraints.MaxRPS)
+		}
+		for _, p := range constraints.BannedPaths {
+			fmt.Printf("Banned path:   %s\n", p)
+		}
+		names := make([]string, 0, len(constraints.RequiredHeaders))
+		for name := range constraints.RequiredHeaders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("Header:        %s: %s\n", name, constraints.RequiredHeaders[name])
+		}
+	},
+}
+
+// --- Attachment Commands ---
+
+var (
+	targetAttachFinding       int64
+	targetAttachNote          int64
+	targetAttachChecklistItem int64
+	targetAttachDescription   string
+	targetAttachmentsDownload int64
+	targetAttachmentsOutput   string
+)
+
+// targetFromArgsOrCurrent resolves the target given as argument, or the current target.
+func targetFromArgsOrCurrent(args []string) int64 {
+	if len(args) == 1 {
+		id, err := getTargetIDByIdentifier(args[0], 0)
+		if err != nil {
+			exitWithError(fmt.Errorf("finding target: %w", err))
+		}
+		return id
+	}
+	state, err := readState()
+	if err != nil || state.CurrentTargetID == 0 {
+		fmt.Fprintln(os.Stderr, "Error: No current target set and no target given.")
+		os.Exit(1)
+	}
+	return state.CurrentTargetID
+}
+
+var targetAttachCmd = &cobra.Command{
+	Use:   "attach FILE...",
+	Short: "Attach files to the current target or to one of its findings, notes or checklist items",
+	Long: `Stores screenshots, PoC files, exported reports or tool output in the attachments directory
+(attachments.dir) and attaches them to the current target, or with --finding, --note or
+--checklist-item to that entity. Files are stored once per content hash and are limited to
+attachments.max_file_bytes.`,
+	Example: `  toolkit target attach nmap-top1000.txt
+  toolkit target attach --finding 42 -d "Alert box on the profile page" poc.png`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entityType, entityID := models.AttachmentEntityTarget, int64(0)
+		switch {
+		case targetAttachFinding != 0:
+			entityType, entityID = models.AttachmentEntityFinding, targetAttachFinding
+		case targetAttachNote != 0:
+			entityType, entityID = models.AttachmentEntityNote, targetAttachNote
+		case targetAttachChecklistItem != 0:
+			entityType, entityID = models.AttachmentEntityChecklistItem, targetAttachChecklistItem
+		default:
+			entityID = targetFromArgsOrCurrent(nil)
+		}
+
+		failed := false
+		for _, path := range args {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			attachment, err := core.AttachFile(entityType, entityID, filepath.Base(path), "", targetAttachDescription, f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error attaching %s: %v\n", path, err)
+				failed = true
+				continue
+			}
+			if attachment.Existing {
+				fmt.Printf("%s is already attached to %s %d (attachment %d).\n", path, entityType, entityID, attachment.ID)
+			} else {
+				fmt.Printf("Attached %s to %s %d (attachment %d, %d bytes).\n", path, entityType, entityID, attachment.ID, attachment.Size)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var targetAttachmentsCmd = &cobra.Command{
+	Use:   "attachments [id|slug]",
+	Short: "List the files attached to a target and its findings and checklist items",
+	Long: `Lists the files attached to a target, its findings and its checklist items, newest first; the
+current target is used when no target is given. --download saves an attachment's content to
+--output (default: its file name in the current directory).`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if targetAttachmentsDownload != 0 {
+			attachment, file, err := core.OpenAttachment(targetAttachmentsDownload)
+			if err != nil {
+				exitWithError(err)
+			}
+			defer file.Close()
+			output := targetAttachmentsOutput
+			if output == "" {
+				output = attachment.FileName
+			}
+			out, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+			if err != nil {
+				exitWithError(err)
+			}
+			if _, err := io.Copy(out, file); err != nil {
+				out.Close()
+				fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", output, err)
+				os.Exit(1)
+			}
+			if err := out.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", output, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Saved attachment %d to %s.\n", attachment.ID, output)
+			return
+		}
+
+		targetID := targetFromArgsOrCurrent(args)
+		attachments, err := core.GetAttachments(models.AttachmentFilters{TargetID: targetID})
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(attachments) == 0 {
+			fmt.Printf("No attachments for target %d.\n", targetID)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFILE\tSIZE\tTYPE\tATTACHED TO\tCREATED")
+		for _, a := range attachments {
+			label := a.EntityLabel
+			if len(label) > 40 {
+				label = label[:37] + "..."
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s %d %s\t%s\n", a.ID, a.FileName, a.Size, a.ContentType, a.EntityType, a.EntityID, label,
+				a.CreatedAt.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
 // --- Set Current Command ---
 
 var targetSetCurrentCmd = &cobra.Command{
@@ -821,8 +1258,33 @@ var targetCurrentCmd = &cobra.Command{
 // --- Init Function ---
 
 func init() {
+	targetClientProfileCmd.Flags().StringArrayVar(&targetProfileHeaders, "header", nil, "Set a header ('Name: value'; repeatable)")
+	targetClientProfileCmd.Flags().StringArrayVar(&targetProfileRemoveHeaders, "remove-header", nil, "Remove a header by name (repeatable)")
+	targetClientProfileCmd.Flags().StringVar(&targetProfileUserAgent, "user-agent", "", "User-Agent to send (empty to unset)")
+	targetClientProfileCmd.Flags().StringVar(&targetProfileCookies, "cookies", "", "Cookies to send, as 'a=1; b=2' (empty to unset)")
+	targetClientProfileCmd.Flags().BoolVar(&targetProfileClear, "clear", false, "Remove the client profile")
+
+	targetProgramConstraintsCmd.Flags().Float64Var(&targetConstraintsMaxRPS, "max-rps", 0, "Maximum requests per second to the target (0 for no limit)")
+	targetProgramConstraintsCmd.Flags().StringArrayVar(&targetConstraintsBanPaths, "ban-path", nil, "Ban a path pattern, e.g. /logout or /api/*/delete (repeatable)")
+	targetProgramConstraintsCmd.Flags().StringArrayVar(&targetConstraintsUnbanPaths, "unban-path", nil, "Remove a banned path pattern (repeatable)")
+	targetProgramConstraintsCmd.Flags().StringArrayVar(&targetConstraintsHeaders, "header", nil, "Require a header ('Name: value'; repeatable)")
+	targetProgramConstraintsCmd.Flags().StringArrayVar(&targetConstraintsRemoveHeaders, "remove-header", nil, "Remove a required header by name (repeatable)")
+	targetProgramConstraintsCmd.Flags().BoolVar(&targetConstraintsClear, "clear", false, "Remove the program constraints")
+	targetProgramConstraintsCmd.Flags().BoolVar(&targetConstraintsEvents, "events", false, "List the requests the proxy blocked, changed or delayed")
+	targetProgramConstraintsCmd.Flags().IntVar(&targetConstraintsEventsLimit, "limit", 20, "Number of events to list with --events")
+
+	// Add attachment command flags
+	targetAttachCmd.Flags().Int64Var(&targetAttachFinding, "finding", 0, "Attach to this finding")
+	targetAttachCmd.Flags().Int64Var(&targetAttachNote, "note", 0, "Attach to this note")
+	targetAttachCmd.Flags().Int64Var(&targetAttachChecklistItem, "checklist-item", 0, "Attach to this target checklist item")
+	targetAttachCmd.Flags().StringVarP(&targetAttachDescription, "description", "d", "", "Description of the files")
+	targetAttachCmd.MarkFlagsMutuallyExclusive("finding", "note", "checklist-item")
+	targetAttachmentsCmd.Flags().Int64Var(&targetAttachmentsDownload, "download", 0, "Save the content of this attachment")
+	targetAttachmentsCmd.Flags().StringVarP(&targetAttachmentsOutput, "output", "o", "", "File to save the downloaded attachment to")
+
 	// Add list command flags
 	targetListCmd.Flags().Int64VarP(&targetListPlatformID, "platform-id", "p", 0, "Filter targets by specific platform ID")
+	registerFlagCompletion(targetListCmd, "platform-id", completePlatformIDs)
 
 	// Add add command flags
 	targetAddCmd.Flags().Int64VarP(&targetPlatformID, "platform-id", "p", 0, "Platform ID for the new target (required)")
@@ -834,6 +1296,7 @@ func init() {
 	targetAddCmd.MarkFlagRequired("codename")
 	targetAddCmd.MarkFlagRequired("link")
 	targetAddCmd.MarkFlagRequired("slug")
+	registerFlagCompletion(targetAddCmd, "platform-id", completePlatformIDs)
 
 	// Add update command flags
 	targetUpdateCmd.Flags().Int64VarP(&targetPlatformID, "platform-id", "p", 0, "Update target's platform ID")
@@ -841,14 +1304,37 @@ func init() {
 	targetUpdateCmd.Flags().StringVarP(&targetLink, "link", "l", "", "Update target's link/URL")
 	targetUpdateCmd.Flags().StringVarP(&targetSlug, "slug", "s", "", "Update target's unique slug (lowercase, numbers, hyphens only)")
 	targetUpdateCmd.Flags().StringVarP(&targetNotes, "notes", "n", "", "Update target's notes")
+	registerFlagCompletion(targetUpdateCmd, "platform-id", completePlatformIDs)
 
 	// Add delete command flags
 	targetDeleteCmd.Flags().BoolVar(&deleteByCodename, "by-codename", false, "Delete by codename instead of ID/slug (requires --platform-id)")
 	targetDeleteCmd.Flags().Int64VarP(&targetPlatformID, "platform-id", "p", 0, "Platform ID (required when deleting --by-codename)")
+	registerFlagCompletion(targetDeleteCmd, "platform-id", completePlatformIDs)
+
+	// Add clone command flags
+	targetCloneCmd.Flags().StringVarP(&targetCodename, "codename", "c", "", "Codename for the new target (required)")
+	targetCloneCmd.Flags().Int64VarP(&targetPlatformID, "platform-id", "p", 0, "Platform ID for the new target (default: the source target's)")
+	targetCloneCmd.Flags().StringVarP(&targetLink, "link", "l", "", "Primary link/URL for the new target (default: the source target's)")
+	targetCloneCmd.Flags().StringVarP(&targetNotes, "notes", "n", "", "Notes for the new target (default: the source target's)")
+	targetCloneCmd.Flags().StringSliceVar(&targetCloneComponents, "components", nil, "Only copy these components (comma-separated)")
+	targetCloneCmd.Flags().StringSliceVar(&targetCloneWithout, "without", nil, "Copy every component except these (comma-separated)")
+	targetCloneCmd.MarkFlagRequired("codename")
+	registerFlagCompletion(targetCloneCmd, "platform-id", completePlatformIDs)
+	registerFlagCompletion(targetCloneCmd, "components", completeCloneComponents)
+	registerFlagCompletion(targetCloneCmd, "without", completeCloneComponents)
 
 	// Add set-current command flags
 	targetSetCurrentCmd.Flags().Int64VarP(&targetPlatformID, "platform-id", "p", 0, "Platform ID (required when setting current target by codename)")
+	registerFlagCompletion(targetSetCurrentCmd, "platform-id", completePlatformIDs)
+
 
+	// Complete the target argument with slugs from the database
+	targetGetCmd.ValidArgsFunction = completeTargets
+	targetUpdateCmd.ValidArgsFunction = completeTargets
+	targetDeleteCmd.ValidArgsFunction = completeTargets
+	targetCloneCmd.ValidArgsFunction = completeTargets
+	targetSetCurrentCmd.ValidArgsFunction = completeTargets
+	targetAttachmentsCmd.ValidArgsFunction = completeTargets
 
 	// Add subcommands to the base target command
 	targetCmd.AddCommand(targetListCmd)
@@ -856,6 +1342,11 @@ func init() {
 	targetCmd.AddCommand(targetGetCmd)
 	targetCmd.AddCommand(targetUpdateCmd)
 	targetCmd.AddCommand(targetDeleteCmd)
+	targetCmd.AddCommand(targetCloneCmd)
+	targetCmd.AddCommand(targetClientProfileCmd)
+	targetCmd.AddCommand(targetProgramConstraintsCmd)
+	targetCmd.AddCommand(targetAttachCmd)
+	targetCmd.AddCommand(targetAttachmentsCmd)
 	targetCmd.AddCommand(targetSetCurrentCmd)
 	targetCmd.AddCommand(targetCurrentCmd)
 
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"toolkit/config"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	toolsInstallVersion string
+	toolsInstallMissing bool
+)
+
+var toolsCmd = &cobra.Command{
+	Use:     "tools",
+	Short:   "Check and install the external tools the toolkit runs",
+	Aliases: []string{"tool"},
+	Long: `Lists the external binaries the toolkit runs: subfinder and httpx are required by subdomain
+discovery and httpx scans, nuclei and ffuf are optional helpers for checklist commands. Binaries in
+tools.bin_dir are used before those in PATH, by the runners and by checklist commands alike.
+'toolkit tools install' downloads pinned releases into tools.bin_dir and checks them against the
+release checksums.`,
+}
+
+var toolsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the external tools with their installed versions",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses := core.ExternalToolStatuses(context.Background())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TOOL\tREQUIRED\tSTATUS\tVERSION\tMIN\tPINNED\tPATH")
+		for _, s := range statuses {
+			required := "no"
+			if s.Required {
+				required = "yes"
+			}
+			path := orDash(s.Path)
+			if s.Managed {
+				path += " (managed)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, required, s.Status, orDash(s.Version), s.MinVersion, s.PinnedVersion, path)
+		}
+		w.Flush()
+		for _, s := range statuses {
+			if s.Status != models.ExternalToolOK && (s.Required || s.Status != models.ExternalToolMissing) {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", s.Message)
+			}
+		}
+		fmt.Printf("Managed binaries: %s\n", config.AppConfig.Tools.BinDir)
+	},
+}
+
+var toolsInstallCmd = &cobra.Command{
+	Use:   "install [tool...]",
+	Short: "Download pinned releases of external tools into tools.bin_dir",
+	Example: `  toolkit tools install subfinder httpx
+  toolkit tools install nuclei --version 3.3.5
+  toolkit tools install --missing`,
+	ValidArgs: core.ExternalToolNames(),
+	Run: func(cmd *cobra.Command, args []string) {
+		names := args
+		if toolsInstallMissing {
+			if len(args) > 0 {
+				fmt.Fprintln(os.Stderr, "Error: give tool names or --missing, not both")
+				os.Exit(1)
+			}
+			for _, s := range core.ExternalToolStatuses(context.Background()) {
+				if s.Status != models.ExternalToolOK {
+					names = append(names, s.Name)
+				}
+			}
+			if len(names) == 0 {
+				fmt.Println("All external tools are installed and compatible.")
+				return
+			}
+		}
+		if len(names) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: give the tools to install or --missing")
+			os.Exit(1)
+		}
+		if toolsInstallVersion != "" && len(names) > 1 {
+			fmt.Fprintln(os.Stderr, "Error: --version applies to a single tool")
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, name := range names {
+			fmt.Printf("Installing %s...\n", name)
+			status, err := core.InstallExternalTool(context.Background(), name, toolsInstallVersion)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Installed %s %s: %s\n", status.Name, orDash(status.Version), status.Path)
+			if status.Status != models.ExternalToolOK {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", status.Message)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	toolsInstallCmd.Flags().StringVar(&toolsInstallVersion, "version", "", "Release to install instead of the pinned one (tools.versions)")
+	toolsInstallCmd.Flags().BoolVar(&toolsInstallMissing, "missing", false, "Install every tool that is missing or incompatible")
+
+	toolsCmd.AddCommand(toolsListCmd, toolsInstallCmd)
+	rootCmd.AddCommand(toolsCmd)
+}
@@ -3,15 +3,19 @@ package cmd
 import (
 	"bufio" // For trafficPurgeCmd confirmation
 	"bytes" // For printBody helper
+	"context"
 	"database/sql"
 	"encoding/json" // For printHeaders and printBody helpers
 	"errors"
 	"fmt"
 	"math"
 	"os"
+	"os/signal"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 	"toolkit/core"
@@ -31,12 +35,166 @@ var (
 	trafficListStatusCode int
 	trafficListLimit      int
 	trafficListPage       int
+	trafficListTargetID   int64
+	trafficListView       string
+	trafficListTags       []string
+	trafficListOrigin     string
+	trafficListSource     string
+	trafficListBody       string
+	trafficListCapture    int64
 	// Map flags / List Mapped flags
 	trafficMapTargetID int64
 	// Analyze flags
-	trafficAnalyzeTool string
+	trafficAnalyzeTool  string
+	trafficAnalyzeLimit int
+	// Analyze-all flags
+	trafficAnalyzeAllTarget      int64
+	trafficAnalyzeAllContentType string
+	trafficAnalyzeAllTools       string
 	// Purge flags
 	trafficPurgeForce bool
+	// Diff flags
+	trafficDiffJSON     bool
+	trafficDiffContext  int
+	trafficDiffBeautify bool
+	// Export flags
+	trafficExportFormat string
+	// Send-to flags
+	trafficSendName         string
+	trafficSendCollectionID int64
+	trafficSendSessionIDs   []int64
+	trafficSendParams       []string
+	trafficSendWordlistID   int64
+	trafficSendAnalyzers    []string
+	trafficSendJSON         bool
+	// Promote flags
+	trafficPromoteType     string
+	trafficPromoteTitle    string
+	trafficPromoteSeverity string
+	trafficPromoteNoPrompt bool
+	// Sessions flags
+	trafficSessionsTargetID int64
+	trafficSessionsAll      bool
+	trafficSessionsEvents   int
+	trafficSessionsRebuild  bool
+	// Login flow flags
+	trafficLoginFlowTargetID    int64
+	trafficLoginFlowExtract     []string
+	trafficLoginFlowHeaders     []string
+	trafficLoginFlowSessionID   int64
+	trafficLoginFlowAutoRefresh bool
+	// Auth token flags
+	trafficTokensTargetID int64
+	trafficTokensKind     string
+	trafficTokensScan     bool
+	// Headers flags
+	trafficHeadersTargetID int64
+	trafficHeadersHost     string
+	trafficHeadersJSON     bool
+	// TLS report flags
+	trafficTLSTargetID int64
+	trafficTLSHost     string
+	trafficTLSWeakOnly bool
+	trafficTLSJSON     bool
+	// CORS check flags
+	trafficCORSTargetID int64
+	trafficCORSLogIDs   []int64
+	trafficCORSHost     string
+	trafficCORSMax      int
+
+	trafficIDORTargetID int64
+	trafficIDORHost     string
+	trafficIDORLimit    int
+	trafficIDORJSON     bool
+
+	trafficAuthTargetID int64
+	trafficAuthHost     string
+	trafficAuthAll      bool
+	trafficAuthJSON     bool
+
+	trafficAnomalyTargetID      int64
+	trafficAnomalyHost          string
+	trafficAnomalyKind          string
+	trafficAnomalySizeFactor    float64
+	trafficAnomalyLatencyFactor float64
+	trafficAnomalyMinSamples    int
+	trafficAnomalyLimit         int
+	trafficAnomalyJSON          bool
+
+	trafficRedirectTargetID      int64
+	trafficRedirectHost          string
+	trafficRedirectScan          bool
+	trafficRedirectTest          bool
+	trafficRedirectMinConfidence string
+	trafficRedirectMaxTests      int
+	trafficRedirectJSON          bool
+
+	trafficMineTargetID  int64
+	trafficMineLogIDs    []int64
+	trafficMineHost      string
+	trafficMineMax       int
+	trafficMineWords     []string
+	trafficMineWordlist  string
+	trafficMineWordsOnly bool
+	trafficMineChunkSize int
+
+	trafficGenTargetID      int64
+	trafficGenFormat        string
+	trafficGenHost          string
+	trafficGenMethods       []string
+	trafficGenPath          string
+	trafficGenSource        string
+	trafficGenNameTemplate  string
+	trafficGenValueTemplate string
+	trafficGenCollectionID  int64
+	trafficGenCollection    string
+	trafficGenWordlist      string
+	trafficGenAll           bool
+	trafficGenMax           int
+	trafficGenDryRun        bool
+
+	trafficRateTargetID int64
+	trafficRateURL      string
+	trafficRateLogID    int64
+	trafficRateStart    float64
+	trafficRateMax      float64
+	trafficRateRequests int
+	trafficRateSet      string
+	trafficRateClear    string
+	trafficRateAll      bool
+	trafficRateJSON     bool
+
+	// Flags for the dedupe-bodies command
+	trafficDedupeVacuum bool
+	trafficDedupeStats  bool
+
+	// Flags for the beautify command
+	trafficBeautifyTargetID int64
+
+	// Flags for the compare-env command
+	trafficCompareTargetID     int64
+	trafficCompareBaseURL      string
+	trafficCompareLogIDs       []int64
+	trafficCompareHost         string
+	trafficComparePathContains string
+	trafficCompareMax          int
+	trafficCompareSessionID    int64
+	trafficCompareDelay        int
+	trafficCompareJSON         bool
+
+	// Flags for the enumerate command
+	trafficEnumTargetID   int64
+	trafficEnumURL        string
+	trafficEnumMethod     string
+	trafficEnumLogID      int64
+	trafficEnumValues     []string
+	trafficEnumRanges     []string
+	trafficEnumWordlists  []string
+	trafficEnumZip        bool
+	trafficEnumSessionIDs []int64
+	trafficEnumDelay      int
+	trafficEnumDryRun     bool
+	trafficEnumJSON       bool
 )
 
 // --- Base Command ---
@@ -96,7 +254,12 @@ var trafficListCmd = &cobra.Command{
 	Long: `Retrieves and displays entries from the HTTP traffic log, with optional filters.
 Supports pagination using --page and --limit flags.
 Regex filter can be applied to different fields using --regex-field.
-Note: Total page count is based on database filters (--domain, --status-code) only, not the --regex filter which is applied after fetching.`,
+--origin manual hides the toolkit's own requests (replays, checks, JS analysis, ...) and lists
+only the user's browsing and modifier requests; --origin automated lists only the toolkit's.
+--body searches the response bodies and their beautified copies (see 'toolkit traffic beautify'),
+so code in minified bundles can be found as it reads once formatted.
+--capture-session lists the traffic logged within a capture session's window (see 'toolkit capture').
+Note: Total page count is based on database filters (--domain, --status-code, --tag, --origin, --source, --body, --capture-session, --target-id, --view) only, not the --regex filter which is applied after fetching.`,
 	Aliases: []string{"ls"},
 	Example: `  # List the 30 most recent traffic entries
   toolkit traffic list
@@ -120,7 +283,25 @@ Note: Total page count is based on database filters (--domain, --status-code) on
   toolkit traffic list --regex "error_code" --regex-field res_body
 
   # List traffic where request headers contain 'X-Api-Key' (case-insensitive regex)
-  toolkit traffic list -r "(?i)x-api-key" -f req_headers`,
+  toolkit traffic list -r "(?i)x-api-key" -f req_headers
+
+  # List traffic tagged 'idor' or 'auth'
+  toolkit traffic list --tag idor,auth
+
+  # List only manual browsing, without the toolkit's own requests
+  toolkit traffic list --origin manual
+
+  # List the replayed requests
+  toolkit traffic list --source Replay
+
+  # List responses calling a function, as the formatted code reads
+  toolkit traffic list --body "fetch(\"/api/admin"
+
+  # List the traffic of capture session 4 ("testing checkout flow")
+  toolkit traffic list --capture-session 4
+
+  # Recall a saved view (see 'toolkit traffic views')
+  toolkit traffic list --view interesting-json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger.Info("Executing 'traffic list' command")
 
@@ -146,26 +327,26 @@ Note: Total page count is based on database filters (--domain, --status-code) on
 			os.Exit(1)
 		}
 
-		// --- Calculate Total Count and Pages ---
-		countQuery := `SELECT COUNT(*) FROM http_traffic_log`
-		countConditions := []string{}
-		countArgs := []interface{}{}
-
-		if trafficListDomain != "" {
-			countConditions = append(countConditions, "request_url LIKE ?")
-			countArgs = append(countArgs, "%"+trafficListDomain+"%")
+		// --- Resolve saved view and build filters ---
+		targetID, view := resolveTrafficListView(cmd)
+		if view != nil {
+			if limit, errLimit := strconv.Atoi(view.Filters["limit"]); errLimit == nil && limit > 0 && !cmd.Flags().Changed("limit") {
+				trafficListLimit = limit
+			}
 		}
-		if trafficListStatusCode > 0 {
-			countConditions = append(countConditions, "response_status_code = ?")
-			countArgs = append(countArgs, trafficListStatusCode)
+		if trafficListOrigin != "" {
+			if _, ok := database.TrafficOriginCondition("log_source", trafficListOrigin); !ok {
+				fmt.Fprintf(os.Stderr, "Error: Invalid --origin '%s'. Must be one of: %s, %s\n", trafficListOrigin, models.TrafficOriginManual, models.TrafficOriginAutomated)
+				os.Exit(1)
+			}
 		}
+		conditions := trafficListConditions(targetID, view)
 
-		if len(countConditions) > 0 {
-			countQuery += " WHERE " + strings.Join(countConditions, " AND ")
-		}
+		// --- Calculate Total Count and Pages ---
+		countQuery := `SELECT COUNT(*) FROM http_traffic_log ` + conditions.Where()
 
 		var totalRecords int64
-		err := database.DB.QueryRow(countQuery, countArgs...).Scan(&totalRecords)
+		err := database.DB.QueryRow(countQuery, conditions.Args()...).Scan(&totalRecords)
 		if err != nil {
 			logger.Error("Failed to count total traffic log records: %v", err)
 			fmt.Fprintln(os.Stderr, "Error counting traffic log records.")
@@ -190,19 +371,8 @@ Note: Total page count is based on database filters (--domain, --status-code) on
 		// Select all fields needed for display AND potential regex filtering
 		query := `SELECT id, timestamp, request_method, request_url, response_status_code, 
                          response_content_type, response_body_size, duration_ms, is_https,
-                         request_headers, request_body, response_headers, response_body 
+                         request_headers, ` + database.TrafficBodyExpr("http_traffic_log", "request_body") + `, response_headers, ` + database.TrafficBodyExpr("http_traffic_log", "response_body") + ` 
                   FROM http_traffic_log`
-		conditions := []string{}
-		dbArgs := []interface{}{}
-
-		if trafficListDomain != "" {
-			conditions = append(conditions, "request_url LIKE ?")
-			dbArgs = append(dbArgs, "%"+trafficListDomain+"%")
-		}
-		if trafficListStatusCode > 0 {
-			conditions = append(conditions, "response_status_code = ?")
-			dbArgs = append(dbArgs, trafficListStatusCode)
-		}
 
 		var regexFilter *regexp.Regexp
 		var regexErr error
@@ -216,11 +386,9 @@ Note: Total page count is based on database filters (--domain, --status-code) on
 			}
 		}
 
-		if len(conditions) > 0 {
-			query += " WHERE " + strings.Join(conditions, " AND ")
-		}
-		query += " ORDER BY timestamp DESC"
+		query += " " + conditions.Where() + " " + trafficListOrderBy(view)
 
+		dbArgs := conditions.Args()
 		if trafficListLimit > 0 {
 			query += " LIMIT ? OFFSET ?"
 			dbArgs = append(dbArgs, trafficListLimit, offset)
@@ -293,23 +461,7 @@ Note: Total page count is based on database filters (--domain, --status-code) on
 			t.ResponseBody = resBodyBytes // These are []byte, direct assignment is fine
 
 			if regexFilter != nil {
-				match := false
-				switch trafficListRegexField {
-				case "url": // t.RequestURL is now sql.NullString
-					match = t.RequestURL.Valid && regexFilter.MatchString(t.RequestURL.String)
-				case "req_headers":
-					match = t.RequestHeaders.Valid && regexFilter.MatchString(t.RequestHeaders.String)
-				case "req_body":
-					match = regexFilter.Match(t.RequestBody)
-				case "res_headers":
-					match = t.ResponseHeaders.Valid && regexFilter.MatchString(t.ResponseHeaders.String)
-				case "res_body":
-					match = regexFilter.Match(t.ResponseBody)
-				default: // Default to URL if field is invalid or not specified with regex
-					match = t.RequestURL.Valid && regexFilter.MatchString(t.RequestURL.String)
-				}
-
-				if match {
+				if trafficRegexMatch(regexFilter, trafficListRegexField, t) {
 					logs = append(logs, t)
 				}
 			} else {
@@ -382,6 +534,15 @@ Note: Total page count is based on database filters (--domain, --status-code) on
 		if trafficListStatusCode > 0 {
 			baseCmd += fmt.Sprintf(" --status-code %d", trafficListStatusCode)
 		}
+		if trafficListOrigin != "" {
+			baseCmd += " --origin " + trafficListOrigin
+		}
+		if trafficListSource != "" {
+			baseCmd += fmt.Sprintf(" --source \"%s\"", trafficListSource)
+		}
+		if trafficListCapture > 0 {
+			baseCmd += fmt.Sprintf(" --capture-session %d", trafficListCapture)
+		}
 		if trafficListRegex != "" {
 			baseCmd += fmt.Sprintf(" --regex '%s' --regex-field %s", trafficListRegex, trafficListRegexField)
 		}
@@ -401,6 +562,288 @@ Note: Total page count is based on database filters (--domain, --status-code) on
 	},
 }
 
+// trafficListSort lists the saved view sort_by values supported by 'traffic list'.
+var trafficListSort = database.SortSpec{
+	Columns: map[string]string{
+		"id":                    "id",
+		"timestamp":             "timestamp",
+		"request_method":        "request_method",
+		"request_url":           "request_url",
+		"response_status_code":  "response_status_code",
+		"response_content_type": "response_content_type",
+		"response_body_size":    "response_body_size",
+		"duration_ms":           "duration_ms",
+	},
+	Default:      "timestamp",
+	DefaultOrder: "DESC",
+	TieBreaker:   "id",
+}
+
+// trafficListConditions builds the WHERE conditions for 'traffic list' from the flags and an optional
+// saved view. Flags take precedence over the view's filters; the view semantics mirror the traffic log
+// API, except that a domain matches anywhere in the URL.
+func trafficListConditions(targetID int64, view *models.SavedView) database.Conditions {
+	values := map[string]string{}
+	if view != nil {
+		for k, v := range view.Filters {
+			values[k] = v
+		}
+	}
+	if trafficListDomain != "" {
+		values["domain"] = trafficListDomain
+	}
+	if trafficListStatusCode > 0 {
+		values["status"] = strconv.Itoa(trafficListStatusCode)
+	}
+	if trafficListOrigin != "" {
+		values["origin"] = trafficListOrigin
+	}
+	if trafficListSource != "" {
+		values["source"] = trafficListSource
+	}
+	if trafficListBody != "" {
+		values["body"] = trafficListBody
+	}
+	if trafficListCapture > 0 {
+		values["capture_session_id"] = strconv.FormatInt(trafficListCapture, 10)
+	}
+	if len(trafficListTags) > 0 {
+		values["filter_tag_ids"] = trafficListTagIDs(trafficListTags)
+	}
+	filters := models.ProxyLogFiltersFromValues(targetID, func(key string) string { return values[key] })
+	filters.FilterURLContains, filters.FilterDomain = filters.FilterDomain, ""
+	if _, ok := database.TrafficOriginCondition("log_source", filters.FilterOrigin); !ok {
+		filters.FilterOrigin = "" // An unknown origin in a view is ignored; --origin is checked before
+	}
+	conditions, _ := database.TrafficLogConditions("", filters)
+	return conditions
+}
+
+// trafficListTagIDs resolves the --tag names to a comma-separated list of tag IDs, the form saved
+// views store them in. Unknown names are an error.
+func trafficListTagIDs(names []string) string {
+	tags, err := database.GetAllTags()
+	if err != nil {
+		logger.Error("Failed to list tags: %v", err)
+		fmt.Fprintln(os.Stderr, "Error retrieving tags from database.")
+		os.Exit(1)
+	}
+	var ids []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, t := range tags {
+			if strings.EqualFold(t.Name, name) {
+				ids = append(ids, strconv.FormatInt(t.ID, 10))
+				found = true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "Error: No tag named '%s'.\n", name)
+			os.Exit(1)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// resolveTrafficListView returns the target ID to filter on and the saved view named by --view, if any.
+// With --view and no --target-id the current target is used to find the view; a view bound to a
+// target filters on that target.
+func resolveTrafficListView(cmd *cobra.Command) (int64, *models.SavedView) {
+	targetID := trafficListTargetID
+	if trafficListView == "" {
+		return targetID, nil
+	}
+	if !cmd.Flags().Changed("target-id") {
+		if state, errState := readState(); errState == nil {
+			targetID = state.CurrentTargetID
+		}
+	}
+	v, errView := database.FindSavedView(targetID, models.SavedViewTypeTrafficLog, trafficListView)
+	if errView != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", errView)
+		os.Exit(1)
+	}
+	if v.TargetID.Valid {
+		targetID = v.TargetID.Int64
+	}
+	logger.Info("traffic %s: Applying saved view '%s' (ID %d): %v", cmd.Name(), v.Name, v.ID, v.Filters)
+	return targetID, &v
+}
+
+// trafficRegexMatch reports whether the --regex-field of a log entry matches the --regex filter.
+func trafficRegexMatch(re *regexp.Regexp, field string, t models.HTTPTrafficLog) bool {
+	switch field {
+	case "req_headers":
+		return t.RequestHeaders.Valid && re.MatchString(t.RequestHeaders.String)
+	case "req_body":
+		return re.Match(t.RequestBody)
+	case "res_headers":
+		return t.ResponseHeaders.Valid && re.MatchString(t.ResponseHeaders.String)
+	case "res_body":
+		return re.Match(t.ResponseBody)
+	default: // Default to URL if field is invalid or not specified with regex
+		return t.RequestURL.Valid && re.MatchString(t.RequestURL.String)
+	}
+}
+
+// trafficListOrderBy returns the ORDER BY clause for 'traffic list' (newest first unless the view sorts otherwise).
+func trafficListOrderBy(view *models.SavedView) string {
+	if view == nil {
+		return trafficListSort.OrderBy("", "")
+	}
+	return trafficListSort.OrderBy(view.Filters["sort_by"], view.Filters["sort_order"])
+}
+
+// --- Tail Command ---
+var (
+	trafficTailLines int
+	trafficTailFull  bool
+	trafficTailJSON  bool
+)
+
+var trafficTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Stream new traffic log entries as they are captured",
+	Long: `Follows the traffic log like 'tail -f': prints the last --lines matching entries, then every
+new entry as it is logged, until interrupted. The filters are those of 'toolkit traffic list'
+(--domain, --status-code, --target-id, --tag, --origin, --source, --body, --regex, --view).
+--full prints each request and response with headers and bodies; --json prints one JSON object
+per entry. Entries are read from the database, so the proxy of 'toolkit server' or
+'toolkit proxy' running in another terminal is followed.
+GET /api/traffic-log/stream streams the same entries as server-sent events.`,
+	Example: `  # Follow everything the proxy captures
+  toolkit traffic tail
+
+  # Follow the user's browsing of one target, without the toolkit's own requests
+  toolkit traffic tail -t 3 --origin manual
+
+  # Dump the full requests and responses of failing API calls
+  toolkit traffic tail -d api.example.com --status-code 500 --full
+
+  # Start with the last 50 entries of a saved view, as JSON lines
+  toolkit traffic tail --view interesting-json -n 50 --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		trafficListRegexField = strings.ToLower(strings.TrimSpace(trafficListRegexField))
+		var regexFilter *regexp.Regexp
+		if trafficListRegex != "" {
+			var err error
+			if regexFilter, err = regexp.Compile(trafficListRegex); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Invalid regex pattern: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if trafficListOrigin != "" {
+			if _, ok := database.TrafficOriginCondition("log_source", trafficListOrigin); !ok {
+				fmt.Fprintf(os.Stderr, "Error: Invalid --origin '%s'. Must be one of: %s, %s\n", trafficListOrigin, models.TrafficOriginManual, models.TrafficOriginAutomated)
+				os.Exit(1)
+			}
+		}
+		targetID, view := resolveTrafficListView(cmd)
+		conditions := trafficListConditions(targetID, view)
+		afterID, err := database.TrafficTailStart(conditions, trafficTailLines)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		go func() {
+			<-sigs
+			cancel()
+		}()
+
+		// Regexes on headers and bodies need the full entries.
+		full := trafficTailFull || (regexFilter != nil && trafficListRegexField != "url")
+		enc := json.NewEncoder(os.Stdout)
+		err = core.TailTrafficLogs(ctx, conditions, afterID, full, func(entries []models.HTTPTrafficLog) error {
+			for _, t := range entries {
+				if regexFilter != nil && !trafficRegexMatch(regexFilter, trafficListRegexField, t) {
+					continue
+				}
+				switch {
+				case trafficTailJSON:
+					enc.Encode(t)
+				case trafficTailFull:
+					printTrafficTailEntry(t)
+				default:
+					status := "-"
+					if t.ResponseStatusCode > 0 {
+						status = strconv.Itoa(t.ResponseStatusCode)
+					}
+					fmt.Printf("%-6d %s %-7s %3s %9d %6dms %-10s %s\n", t.ID, t.Timestamp.Local().Format("15:04:05"),
+						t.RequestMethod.String, status, t.ResponseBodySize, t.DurationMs, t.Origin, t.RequestURL.String)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+// printTrafficTailEntry prints a log entry's request and response like 'traffic get'.
+func printTrafficTailEntry(t models.HTTPTrafficLog) {
+	fmt.Printf("=== #%d %s (%s) ===\n", t.ID, t.Timestamp.Local().Format(time.DateTime), t.Origin)
+	fmt.Printf("%s %s %s\n", t.RequestMethod.String, t.RequestURL.String, t.RequestHTTPVersion.String)
+	printHeaders(t.RequestHeaders.String)
+	fmt.Println()
+	printBody(t.RequestBody, "")
+	fmt.Println("---")
+	if t.ResponseStatusCode > 0 || t.ResponseHeaders.Valid {
+		fmt.Printf("%s %d (%dms)\n", t.ResponseHTTPVersion.String, t.ResponseStatusCode, t.DurationMs)
+		printHeaders(t.ResponseHeaders.String)
+		fmt.Println()
+		printBody(t.ResponseBody, t.ResponseContentType.String)
+	} else {
+		fmt.Println("(No Response Recorded or Error Occurred)")
+	}
+	fmt.Println()
+}
+
+// --- Views Command ---
+var trafficViewsCmd = &cobra.Command{
+	Use:   "views",
+	Short: "List saved traffic log views",
+	Long: `Lists the saved filter/sort combinations for the traffic log that can be recalled with
+'toolkit traffic list --view <name>'. Views are saved from the UI or the /views API.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		views, err := database.GetSavedViews(trafficListTargetID, models.SavedViewTypeTrafficLog)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(views) == 0 {
+			fmt.Println("No saved traffic log views.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tTARGET\tFILTERS")
+		for _, v := range views {
+			target := "all"
+			if v.TargetID.Valid {
+				target = strconv.FormatInt(v.TargetID.Int64, 10)
+			}
+			keys := make([]string, 0, len(v.Filters))
+			for k := range v.Filters {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			parts := make([]string, len(keys))
+			for i, k := range keys {
+				parts[i] = k + "=" + v.Filters[k]
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", v.ID, v.Name, target, strings.Join(parts, " "))
+		}
+		w.Flush()
+	},
+}
+
 // --- Map Command ---
 var trafficMapCmd = &cobra.Command{
 	Use:   "map [log_id]",
@@ -681,8 +1124,8 @@ For long bodies, pipe the output to a pager like 'less' (e.g., toolkit traffic g
 		var reasonPhraseSql, reqHttpVerSql, resHttpVerSql sql.NullString
 
 		query := `SELECT id, target_id, timestamp, request_method, request_url, request_http_version, 
-                         request_headers, request_body, response_status_code, response_reason_phrase, 
-                         response_http_version, response_headers, response_body, response_content_type, 
+                         request_headers, ` + database.TrafficBodyExpr("http_traffic_log", "request_body") + `, response_status_code, response_reason_phrase, 
+                         response_http_version, response_headers, ` + database.TrafficBodyExpr("http_traffic_log", "response_body") + `, response_content_type, 
                          response_body_size, duration_ms, client_ip, server_ip, is_https, 
                          is_page_candidate, notes 
                   FROM http_traffic_log WHERE id = ?`
@@ -803,11 +1246,42 @@ For long bodies, pipe the output to a pager like 'less' (e.g., toolkit traffic g
 // --- Analyze Command ---
 var trafficAnalyzeCmd = &cobra.Command{
 	Use:   "analyze [log_id]",
-	Short: "Analyze response body of a traffic log entry",
-	Long: `Fetches the response body for a given traffic log ID and runs an analysis tool
-(currently 'jsluice' for JavaScript) to extract interesting information like URLs, paths, secrets.`,
-	Args: cobra.ExactArgs(1),
+	Short: "Analyze response bodies of captured traffic",
+	Long: `Runs analyzers over captured response bodies and stores their findings in analysis_results.
+Available analyzers:
+  jsluice    URLs, secrets, strings and paths in JavaScript
+  secrets    Secret detection rules (findings are stored redacted)
+  comments   HTML, JavaScript and CSS comments
+  endpoints  URLs and paths in any text response
+
+Enabled analyzer plugins ('toolkit plugins list') are available under their names.
+
+Each analyzer only runs on responses whose Content-Type it supports. Select analyzers with --tool
+(a name, a comma-separated list or 'all').
+
+With a log_id and the default '--tool jsluice', the JavaScript pipeline runs: it also applies source
+maps, stores endpoints, paths and DOM sinks per file version, and shows which endpoints were added
+or removed when the bundle changed.
+
+Without a log_id, the analyzers run over the newest entries matching the 'traffic list' filters
+(--domain, --status-code, --target-id, --view), up to --limit entries.`,
+	Example: `  # Run the JavaScript pipeline on one entry
+  toolkit traffic analyze 42
+
+  # Run every applicable analyzer on one entry
+  toolkit traffic analyze 42 --tool all
+
+  # Look for secrets and comments in the 500 newest responses of example.com
+  toolkit traffic analyze --tool secrets,comments --domain example.com --limit 500
+
+  # Run every analyzer over a saved view
+  toolkit traffic analyze --tool all --view interesting-json`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			runTrafficAnalyzersOnFilters(cmd)
+			return
+		}
 		logIDStr := args[0]
 		logger.Info("Executing 'traffic analyze' command for log ID: %s using tool: %s", logIDStr, trafficAnalyzeTool)
 
@@ -817,10 +1291,14 @@ var trafficAnalyzeCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "Error: Invalid log_id '%s'. Must be an integer.\n", logIDStr)
 			os.Exit(1)
 		}
+		if !strings.EqualFold(trafficAnalyzeTool, "jsluice") {
+			runTrafficAnalyzersOnLog(logID)
+			return
+		}
 
 		var resBodyBytes []byte
 		var contentType sql.NullString
-		query := `SELECT response_body, response_content_type FROM http_traffic_log WHERE id = ?`
+		query := `SELECT ` + database.TrafficBodyExpr("http_traffic_log", "response_body") + `, response_content_type FROM http_traffic_log WHERE id = ?`
 		err = database.DB.QueryRow(query, logID).Scan(&resBodyBytes, &contentType)
 
 		if err != nil {
@@ -851,11 +1329,12 @@ var trafficAnalyzeCmd = &cobra.Command{
 		}
 
 		var results map[string][]string
+		var pipeline *core.JSPipelineResult
 		var analysisErr error
 
 		switch strings.ToLower(trafficAnalyzeTool) {
 		case "jsluice":
-			results, analysisErr = core.AnalyzeJSContent(resBodyBytes, logID)
+			results, pipeline, analysisErr = core.RunJSPipeline(logID)
 		default:
 			fmt.Fprintf(os.Stderr, "Error: Unknown analysis tool '%s'. Supported tools: jsluice\n", trafficAnalyzeTool)
 			os.Exit(1)
@@ -881,91 +1360,2199 @@ var trafficAnalyzeCmd = &cobra.Command{
 				}
 			}
 		}
+		if pipeline != nil {
+			printJSPipelineSummary(pipeline)
+		}
 		fmt.Println("--- End Analysis Results ---")
 
 	},
 }
 
-// --- Purge Command ---
-var trafficPurgeCmd = &cobra.Command{
-	Use:   "purge",
-	Short: "Purge unmapped and unanalyzed traffic log entries",
-	Long: `Deletes entries from the http_traffic_log table that meet the following criteria:
-  1. Their 'target_id' is NULL (meaning they are not mapped to any target).
-  2. Their 'id' does not appear in the 'http_traffic_log_id' column of the 'analysis_results' table (meaning no analysis output is associated with them).
-This command will ask for confirmation before proceeding unless the --force flag is used.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		logger.Info("Executing 'traffic purge' command")
+// trafficAnalyzerNames returns the analyzers selected with --tool, or nil for all of them.
+func trafficAnalyzerNames() []string {
+	if strings.EqualFold(strings.TrimSpace(trafficAnalyzeTool), "all") {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(trafficAnalyzeTool, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := core.GetAnalyzer(name); !ok {
+			fmt.Fprintf(os.Stderr, "Error: Unknown analyzer '%s'. Available: %s, all\n", name, strings.Join(core.AnalyzerNames(), ", "))
+			os.Exit(1)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: --tool must name at least one analyzer.")
+		os.Exit(1)
+	}
+	return names
+}
 
-		if !trafficPurgeForce {
-			fmt.Print("WARNING: This will permanently delete unmapped and unanalyzed traffic log entries.\nAre you sure you want to continue? (yes/no): ")
-			reader := bufio.NewReader(os.Stdin)
-			input, _ := reader.ReadString('\n')
-			if strings.ToLower(strings.TrimSpace(input)) != "yes" {
-				fmt.Println("Purge operation cancelled.")
-				return
-			}
+// runTrafficAnalyzersOnLog runs the selected analyzers on one entry and prints their findings.
+func runTrafficAnalyzersOnLog(logID int64) {
+	results, err := core.AnalyzeTrafficLog(logID, trafficAnalyzerNames())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Analysis of log ID %d failed: %v\n", logID, err)
+		os.Exit(1)
+	}
+	fmt.Printf("--- Analysis Results for Log ID %d ---\n", logID)
+	if len(results) == 0 {
+		fmt.Println("(No selected analyzer supports the response Content-Type)")
+	}
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("\n[%s] %d finding(s)\n", name, len(results[name]))
+		for _, f := range results[name] {
+			fmt.Printf("  - %s: %s\n", f.Category, f.Value)
 		}
+	}
+	fmt.Println("--- End Analysis Results ---")
+}
 
-		query := `
-            DELETE FROM http_traffic_log
-            WHERE target_id IS NULL 
-            AND id NOT IN (
-                SELECT DISTINCT http_traffic_log_id 
-                FROM analysis_results 
-                WHERE http_traffic_log_id IS NOT NULL
-            )
-        `
-		logger.Debug("Executing purge query: %s", query)
+// runTrafficAnalyzersOnFilters runs the selected analyzers over the newest entries with a response
+// body that match the 'traffic list' filters and prints a summary per entry.
+func runTrafficAnalyzersOnFilters(cmd *cobra.Command) {
+	names := trafficAnalyzerNames()
+	if trafficAnalyzeLimit < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --limit must be at least 1.")
+		os.Exit(1)
+	}
+	targetID, view := resolveTrafficListView(cmd)
+	conditions := trafficListConditions(targetID, view)
+	conditions.Add("LENGTH(" + database.TrafficBodyExpr("http_traffic_log", "response_body") + ") > 0")
+	query := `SELECT id FROM http_traffic_log ` + conditions.Where() + ` ` + trafficListOrderBy(view) + ` LIMIT ?`
+	rows, err := database.DB.Query(query, conditions.Args(trafficAnalyzeLimit)...)
+	if err != nil {
+		logger.Error("traffic analyze: Error querying traffic log entries: %v", err)
+		fmt.Fprintln(os.Stderr, "Error retrieving traffic log entries from database.")
+		os.Exit(1)
+	}
+	var logIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			logIDs = append(logIDs, id)
+		}
+	}
+	rows.Close()
+	if len(logIDs) == 0 {
+		fmt.Println("No traffic log entries with a response body match the filters.")
+		return
+	}
 
-		result, err := database.DB.Exec(query)
+	totals := make(map[string]int)
+	analyzed, failed := 0, 0
+	for _, logID := range logIDs {
+		results, err := core.AnalyzeTrafficLog(logID, names)
 		if err != nil {
-			logger.Error("Failed to execute purge command: %v", err)
-			fmt.Fprintln(os.Stderr, "Error purging traffic log entries.")
+			fmt.Fprintf(os.Stderr, "Log %d: %v\n", logID, err)
+			failed++
+			continue
+		}
+		if len(results) == 0 {
+			continue
+		}
+		analyzed++
+		ran := make([]string, 0, len(results))
+		for name := range results {
+			ran = append(ran, name)
+		}
+		sort.Strings(ran)
+		parts := make([]string, len(ran))
+		for i, name := range ran {
+			parts[i] = fmt.Sprintf("%s %d", name, len(results[name]))
+			totals[name] += len(results[name])
+		}
+		fmt.Printf("Log %d: %s\n", logID, strings.Join(parts, ", "))
+	}
+
+	fmt.Printf("\nAnalyzed %d of %d matching entries (%d failed, %d without an applicable analyzer).\n",
+		analyzed, len(logIDs), failed, len(logIDs)-analyzed-failed)
+	ran := make([]string, 0, len(totals))
+	for name := range totals {
+		ran = append(ran, name)
+	}
+	sort.Strings(ran)
+	for _, name := range ran {
+		fmt.Printf("  %-10s %d finding(s)\n", name, totals[name])
+	}
+}
+
+// --- Analyze-all Command ---
+var trafficAnalyzeAllCmd = &cobra.Command{
+	Use:   "analyze-all",
+	Short: "Run analyzers over every stored response of a target",
+	Long: `Starts a background job that runs analyzers over every stored response of a target (optionally
+only those whose Content-Type contains --content-type) with analysis.workers entries analyzed
+concurrently, and reports its progress until it finishes. Findings are stored in analysis_results.
+Analyzers default to analysis.analyzers from the config. The same job can be started through
+POST /targets/{target_id}/traffic/analyze.`,
+	Example: `  # Analyze every JavaScript response of the current target
+  toolkit traffic analyze-all --content-type javascript
+
+  # Only look for secrets and comments in target 3's HTML
+  toolkit traffic analyze-all --target 3 --content-type html --tool secrets,comments`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := trafficAnalyzeAllTarget
+		if targetID == 0 {
+			if state, errState := readState(); errState == nil {
+				targetID = state.CurrentTargetID
+			}
+		}
+		if targetID == 0 {
+			fmt.Fprintln(os.Stderr, "Error: No target specified. Use --target or set a current target with 'toolkit target set-current'.")
 			os.Exit(1)
 		}
+		req := models.TrafficAnalysisRequest{ContentType: trafficAnalyzeAllContentType}
+		for _, name := range strings.Split(trafficAnalyzeAllTools, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				req.Analyzers = append(req.Analyzers, name)
+			}
+		}
 
-		rowsAffected, err := result.RowsAffected()
+		job, err := core.StartTrafficAnalysis(targetID, req)
 		if err != nil {
-			logger.Error("Failed to get rows affected by purge: %v", err)
-			fmt.Fprintln(os.Stderr, "Purge executed, but could not determine number of rows affected.")
-		} else {
-			fmt.Printf("Successfully purged %d unmapped and unanalyzed traffic log entries.\n", rowsAffected)
-			logger.Info("Purged %d traffic log entries.", rowsAffected)
+			exitWithError(err)
+		}
+		fmt.Printf("Job %d: %s\n", job.ID, job.Message)
+		// Ctrl+C cancels the job so it is not left 'running' when the command exits.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		for job.Status == models.JobStatusRunning {
+			select {
+			case <-sigs:
+				core.CancelJob(job.ID)
+			case <-time.After(500 * time.Millisecond):
+			}
+			if job, err = core.GetJob(job.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("\rAnalyzed %d/%d responses (%d errors)", job.ItemsProcessed, job.ItemsTotal, job.ErrorCount)
+		}
+		fmt.Println()
+		switch job.Status {
+		case models.JobStatusSucceeded:
+			fmt.Println(job.Message)
+		default:
+			detail := job.LastError
+			if detail == "" {
+				detail = job.Message
+			}
+			fmt.Fprintf(os.Stderr, "Job %d %s: %s\n", job.ID, job.Status, detail)
+			os.Exit(1)
 		}
 	},
 }
 
-// --- Init Function ---
+// printJSPipelineSummary prints the stored JS file version and, for a changed bundle, its endpoint diff.
+func printJSPipelineSummary(p *core.JSPipelineResult) {
+	fmt.Printf("\n[Pipeline]\n")
+	fmt.Printf("  JS file ID:    %d\n", p.JSFileID)
+	if p.IsNewVersion {
+		fmt.Printf("  Version ID:    %d (new)\n", p.VersionID)
+	} else {
+		fmt.Printf("  Version ID:    %d (unchanged since last capture)\n", p.VersionID)
+	}
+	fmt.Printf("  Endpoints:     %d stored\n", p.EndpointCount)
+	sourceMap := p.SourceMapStatus
+	if p.SourceMapURL != "" {
+		sourceMap += " (" + p.SourceMapURL + ")"
+	}
+	if p.SourceMapSourcesCount > 0 {
+		sourceMap += fmt.Sprintf(", %d sources", p.SourceMapSourcesCount)
+	}
+	fmt.Printf("  Source map:    %s\n", sourceMap)
+	if p.Diff == nil {
+		return
+	}
+	fmt.Printf("\n[Changes since version %d: +%d / -%d]\n", p.Diff.FromVersionID, len(p.Diff.Added), len(p.Diff.Removed))
+	for _, e := range p.Diff.Added {
+		fmt.Printf("  + [%s] %s\n", e.Kind, e.Value)
+	}
+	for _, e := range p.Diff.Removed {
+		fmt.Printf("  - [%s] %s\n", e.Kind, e.Value)
+	}
+}
 
-func init() {
-	// List command flags
-	trafficListCmd.Flags().StringVarP(&trafficListDomain, "domain", "d", "", "Filter traffic by requests containing this domain/host string")
-	trafficListCmd.Flags().StringVarP(&trafficListRegex, "regex", "r", "", "Filter traffic by URL matching this Go regex pattern (applied after DB query)")
-	trafficListCmd.Flags().StringVarP(&trafficListRegexField, "regex-field", "f", "url", "Field to apply regex filter on (url, req_headers, req_body, res_headers, res_body)")
-	trafficListCmd.Flags().IntVarP(&trafficListStatusCode, "status-code", "s", 0, "Filter traffic by HTTP response status code (e.g., 200, 404)")
-	trafficListCmd.Flags().IntVarP(&trafficListLimit, "limit", "l", 30, "Number of results per page")
-	trafficListCmd.Flags().IntVarP(&trafficListPage, "page", "p", 1, "Page number to retrieve")
+// --- Diff Command ---
+var trafficDiffCmd = &cobra.Command{
+	Use:   "diff [from_log_id] [to_log_id]",
+	Short: "Show what changed between two traffic log entries",
+	Long: `Compares two traffic log entries (e.g. an original request and a tampered or replayed one)
+and prints the differences in request line, status code, headers and bodies.
+JSON bodies are compared structurally (by JSON path); other text bodies are diffed line by line.
+With --beautify the response bodies are compared as their beautified copies, so a change in a
+minified bundle shows as the lines that changed instead of one replaced line.
+Entries answered from the response cache (response_cache in the config) are flagged, since their
+response repeats an earlier entry instead of being a new answer from the target.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Info("Executing 'traffic diff' command for log IDs: %s -> %s", args[0], args[1])
 
-	// Map command flags
-	trafficMapCmd.Flags().Int64VarP(&trafficMapTargetID, "target-id", "t", 0, "ID of the target to map the log entry to (uses current target if not specified)")
+		var ids [2]int64
+		for i, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Invalid log_id '%s'. Must be an integer.\n", arg)
+				os.Exit(1)
+			}
+			ids[i] = id
+		}
 
-	// List Mapped command flags
-	trafficListMappedCmd.Flags().Int64VarP(&trafficMapTargetID, "target-id", "t", 0, "ID of the target to list mapped entries for (uses current target if not specified)")
+		fromLog, err := database.GetHTTPTrafficLogEntryByID(ids[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		toLog, err := database.GetHTTPTrafficLogEntryByID(ids[1])
+		if err != nil {
+			exitWithError(err)
+		}
 
-	// Analyze command flags
-	trafficAnalyzeCmd.Flags().StringVar(&trafficAnalyzeTool, "tool", "jsluice", "Analysis tool to use (currently supports 'jsluice')")
+		var diff core.TrafficDiff
+		if trafficDiffBeautify {
+			diff = core.DiffBeautifiedTrafficLogs(fromLog, toLog)
+		} else {
+			diff = core.DiffTrafficLogs(fromLog, toLog)
+		}
+		if trafficDiffJSON {
+			out, _ := json.MarshalIndent(diff, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
 
-	// Purge command flags
-	trafficPurgeCmd.Flags().BoolVarP(&trafficPurgeForce, "force", "", false, "Skip confirmation before purging records")
+		fmt.Printf("--- Log %d\n+++ Log %d\n", diff.FromID, diff.ToID)
+		if diff.FromCachedLogID != 0 {
+			fmt.Printf("(Log %d was answered from the response cache with the response of log %d)\n", diff.FromID, diff.FromCachedLogID)
+		}
+		if diff.ToCachedLogID != 0 {
+			fmt.Printf("(Log %d was answered from the response cache with the response of log %d)\n", diff.ToID, diff.ToCachedLogID)
+		}
+		if !diff.HasChanges() {
+			fmt.Println("(No differences)")
+			return
+		}
+		if diff.RequestLine != nil {
+			fmt.Printf("\n[Request Line]\n- %s\n+ %s\n", diff.RequestLine.From, diff.RequestLine.To)
+		}
+		if diff.StatusCode != nil {
+			fmt.Printf("\n[Status Code]\n- %s\n+ %s\n", diff.StatusCode.From, diff.StatusCode.To)
+		}
+		printHeaderChanges("Request Headers", diff.RequestHeaders)
+		printHeaderChanges("Response Headers", diff.ResponseHeaders)
+		printBodyDiff("Request Body", diff.RequestBody, trafficDiffContext)
+		printBodyDiff("Response Body", diff.ResponseBody, trafficDiffContext)
+	},
+}
+
+func printHeaderChanges(title string, changes []core.HeaderChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Printf("\n[%s]\n", title)
+	for _, c := range changes {
+		for _, v := range c.From {
+			fmt.Printf("- %s: %s\n", c.Name, v)
+		}
+		for _, v := range c.To {
+			fmt.Printf("+ %s: %s\n", c.Name, v)
+		}
+	}
+}
+
+func printBodyDiff(title string, d core.BodyDiff, context int) {
+	switch d.Mode {
+	case "identical":
+		return
+	case "binary":
+		fmt.Printf("\n[%s] binary content differs (%d -> %d bytes)\n", title, d.FromSize, d.ToSize)
+		return
+	case "json":
+		fmt.Printf("\n[%s] JSON (%d -> %d bytes)\n", title, d.FromSize, d.ToSize)
+		for _, c := range d.JSONChanges {
+			from, _ := json.Marshal(c.From)
+			to, _ := json.Marshal(c.To)
+			switch c.Change {
+			case "added":
+				fmt.Printf("+ %s: %s\n", c.Path, to)
+			case "removed":
+				fmt.Printf("- %s: %s\n", c.Path, from)
+			default:
+				fmt.Printf("~ %s: %s -> %s\n", c.Path, from, to)
+			}
+		}
+		return
+	}
+
+	fmt.Printf("\n[%s] text (%d -> %d bytes)\n", title, d.FromSize, d.ToSize)
+	if d.Truncated {
+		fmt.Println("(Bodies too large for an exact diff; showing the changed region as a whole)")
+	}
+	// Only print unchanged lines within 'context' lines of a change.
+	lastPrinted := -1
+	for i, l := range d.Lines {
+		if l.Op == " " {
+			near := false
+			for j := i - context; j <= i+context; j++ {
+				if j >= 0 && j < len(d.Lines) && d.Lines[j].Op != " " {
+					near = true
+					break
+				}
+			}
+			if !near {
+				continue
+			}
+		}
+		if lastPrinted >= 0 && i > lastPrinted+1 {
+			fmt.Println("...")
+		}
+		fmt.Printf("%s %s\n", l.Op, l.Text)
+		lastPrinted = i
+	}
+}
+
+// --- Export Command ---
+var trafficExportCmd = &cobra.Command{
+	Use:   "export [log_id]",
+	Short: "Export a traffic log entry's request as a curl command or raw HTTP",
+	Long: `Prints the request of a traffic log entry as a copy-pasteable curl command (default)
+or as a raw HTTP/1.1 message (--format raw).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid log_id '%s'. Must be an integer.\n", args[0])
+			os.Exit(1)
+		}
+		logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		requestURL := logEntry.RequestURL.String
+		if logEntry.RequestFullURLWithFragment.Valid && logEntry.RequestFullURLWithFragment.String != "" {
+			requestURL = logEntry.RequestFullURLWithFragment.String
+		}
+		requestURL, headers, body := core.RedactRequestForExport(logEntry.TargetID, requestURL,
+			core.HeadersFromJSON(logEntry.RequestHeaders.String), logEntry.RequestBody)
+
+		switch strings.ToLower(trafficExportFormat) {
+		case "curl":
+			fmt.Println(core.BuildCurlCommand(logEntry.RequestMethod.String, requestURL, headers, body))
+		case "raw":
+			raw, errRaw := core.BuildRawHTTPRequest(logEntry.RequestMethod.String, requestURL, headers, body)
+			if errRaw != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", errRaw)
+				os.Exit(1)
+			}
+			fmt.Print(raw)
+		default:
+			fmt.Fprintf(os.Stderr, "Error: Unknown format '%s'. Supported formats: curl, raw\n", trafficExportFormat)
+			os.Exit(1)
+		}
+	},
+}
+
+var trafficSendToCmd = &cobra.Command{
+	Use:   "send-to [log_id] [destination]",
+	Short: "Send a traffic log entry to the modifier, replay, an auth matrix, ffuf, the analyzers or an export",
+	Long: `Routes a traffic log entry to another module in one step, like POST /api/traffic/{id}/send-to:
+
+  modifier    a modifier task built from the request (--name, --collection-id)
+  replay      a replay batch sending it again (--session-ids with one session to use its cookies)
+  authmatrix  a replay batch per replay session (--session-ids, the target's sessions by default),
+              to compare what each account gets
+  fuzzer      an ffuf command with FUZZ in the query, form or JSON parameters (--params, all by
+              default), or in the last path segment of a request without any (--wordlist-id)
+  scanner     the response analyzers (--analyzers, analysis.analyzers by default)
+  curl, raw   the request as a curl command or raw HTTP message, export redaction applied
+
+The send is recorded for the entry (GET /api/traffic/{id}/sends); created modifier tasks and
+replay batch items point back to it through their source_log_id.`,
+	Example: `  toolkit traffic send-to 42 modifier --name "IDOR on invoices"
+  toolkit traffic send-to 42 authmatrix
+  toolkit traffic send-to 42 fuzzer --params id,sort`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 1 {
+			return core.SendToDestinations, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid log_id '%s'. Must be an integer.\n", args[0])
+			os.Exit(1)
+		}
+		req := models.TrafficSendRequest{
+			Destination:  args[1],
+			Name:         trafficSendName,
+			CollectionID: trafficSendCollectionID,
+			Params:       trafficSendParams,
+			WordlistID:   trafficSendWordlistID,
+			Analyzers:    trafficSendAnalyzers,
+		}
+		if strings.EqualFold(args[1], models.SendToReplay) && len(trafficSendSessionIDs) > 0 {
+			req.SessionID = trafficSendSessionIDs[0]
+		} else {
+			req.SessionIDs = trafficSendSessionIDs
+		}
+		result, err := core.SendTrafficTo(logID, core.LocalUser, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficSendJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(result)
+			return
+		}
+		switch {
+		case result.Command != "":
+			fmt.Println(strings.TrimRight(result.Command, "\n"))
+		case result.Task != nil:
+			fmt.Printf("Created modifier task %d: %s\n", result.Task.ID, result.Task.Name)
+		case len(result.Batches) > 0:
+			for _, b := range result.Batches {
+				fmt.Printf("Started replay batch %d: %s\n", b.ID, b.Name.String)
+			}
+			fmt.Println("Follow them with GET /api/replay/batches/{id}.")
+		default:
+			analyzers := make([]string, 0, len(result.Findings))
+			for name := range result.Findings {
+				analyzers = append(analyzers, name)
+			}
+			sort.Strings(analyzers)
+			for _, name := range analyzers {
+				fmt.Printf("%s: %d findings\n", name, result.Findings[name])
+			}
+		}
+	},
+}
+
+var trafficPromoteCmd = &cobra.Command{
+	Use:   "promote [log_id]",
+	Short: "Promote a traffic log entry to a draft finding",
+	Long: `Creates a Draft finding for the log entry's target, pre-filled with the URL, method, the raw
+request and response as evidence, and a curl command to reproduce it. Export redaction rules
+apply. Without --type the vulnerability types are listed and you are asked to pick one
+(--no-prompt skips the question).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid log_id '%s'. Must be an integer.\n", args[0])
+			os.Exit(1)
+		}
+		logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+		if err != nil {
+			exitWithError(err)
+		}
+		if logEntry.TargetID == nil {
+			fmt.Fprintf(os.Stderr, "Error: Log entry %d has no target. Map it first with 'toolkit traffic map %d'.\n", logID, logID)
+			os.Exit(1)
+		}
+		req := models.FindingPromotionRequest{Title: trafficPromoteTitle, Severity: trafficPromoteSeverity}
+		vulnType := trafficPromoteType
+		if vulnType == "" && !trafficPromoteNoPrompt && stdinIsTerminal() {
+			vulnType = promptVulnerabilityType()
+		}
+		if id, errID := strconv.ParseInt(vulnType, 10, 64); errID == nil {
+			req.VulnerabilityTypeID = id
+		} else {
+			req.VulnerabilityType = vulnType
+		}
+
+		finding, err := core.PromoteTrafficToFinding(logID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Created draft finding %d for target %d: %s\n", finding.ID, finding.TargetID, finding.Title)
+		if finding.VulnerabilityTypeID.Valid {
+			fmt.Printf("  Vulnerability type ID: %d\n", finding.VulnerabilityTypeID.Int64)
+		}
+		if finding.Severity.Valid {
+			fmt.Printf("  Severity: %s\n", finding.Severity.String)
+		}
+	},
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal rather than a pipe or file.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// promptVulnerabilityType lists the vulnerability types and reads a choice: a list number, a name
+// or nothing to leave the type unset.
+func promptVulnerabilityType() string {
+	types, err := database.GetAllVulnerabilityTypes()
+	if err != nil || len(types) == 0 {
+		return ""
+	}
+	fmt.Println("Vulnerability types:")
+	for i, vt := range types {
+		fmt.Printf("  %2d) %s\n", i+1, vt.Name)
+	}
+	fmt.Print("Choose a type (number or name, empty to skip): ")
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	input = strings.TrimSpace(input)
+	if n, err := strconv.Atoi(input); err == nil {
+		if n < 1 || n > len(types) {
+			fmt.Fprintf(os.Stderr, "Error: No vulnerability type numbered %d.\n", n)
+			os.Exit(1)
+		}
+		return strconv.FormatInt(types[n-1].ID, 10)
+	}
+	return input
+}
+
+// --- Purge Command ---
+var trafficPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Purge unmapped and unanalyzed traffic log entries",
+	Long: `Deletes entries from the http_traffic_log table that meet the following criteria:
+  1. Their 'target_id' is NULL (meaning they are not mapped to any target).
+  2. Their 'id' does not appear in the 'http_traffic_log_id' column of the 'analysis_results' table (meaning no analysis output is associated with them).
+This command will ask for confirmation before proceeding unless the --force flag is used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Info("Executing 'traffic purge' command")
+
+		if !trafficPurgeForce {
+			fmt.Print("WARNING: This will permanently delete unmapped and unanalyzed traffic log entries.\nAre you sure you want to continue? (yes/no): ")
+			reader := bufio.NewReader(os.Stdin)
+			input, _ := reader.ReadString('\n')
+			if strings.ToLower(strings.TrimSpace(input)) != "yes" {
+				fmt.Println("Purge operation cancelled.")
+				return
+			}
+		}
+
+		query := `
+            DELETE FROM http_traffic_log
+            WHERE target_id IS NULL 
+            AND id NOT IN (
+                SELECT DISTINCT http_traffic_log_id 
+                FROM analysis_results 
+                WHERE http_traffic_log_id IS NOT NULL
+            )
+        `
+		logger.Debug("Executing purge query: %s", query)
+
+		result, err := database.DB.Exec(query)
+		if err != nil {
+			logger.Error("Failed to execute purge command: %v", err)
+			fmt.Fprintln(os.Stderr, "Error purging traffic log entries.")
+			os.Exit(1)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			logger.Error("Failed to get rows affected by purge: %v", err)
+			fmt.Fprintln(os.Stderr, "Purge executed, but could not determine number of rows affected.")
+		} else {
+			fmt.Printf("Successfully purged %d unmapped and unanalyzed traffic log entries.\n", rowsAffected)
+			logger.Info("Purged %d traffic log entries.", rowsAffected)
+		}
+	},
+}
+
+var trafficSessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Show the session cookies a target set and detected session changes",
+	Long: `Lists the cookies the target set in captured traffic (session cookies only unless --all), with
+their status (active, expired or suspect after a 401 or login redirect), age and how often they
+rotated, followed by recent session events. Session cookies are those matching
+cookie_jar.session_cookies. --rebuild first rescans the target's traffic, for traffic captured
+before tracking or after changing the patterns. Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficSessionsTargetID)
+		if trafficSessionsRebuild {
+			result, err := core.RebuildTargetCookieJar(targetID)
+			if err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Rescanned %d log entries: %d cookies (%d session), %d session events.\n\n",
+				result.LogsScanned, result.Cookies, result.SessionCookies, result.Events)
+		}
+		sessions, err := core.GetTargetSessions(targetID, trafficSessionsAll, trafficSessionsEvents)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(sessions.Cookies) == 0 {
+			fmt.Printf("No cookies tracked for target %d.\n", targetID)
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tDOMAIN\tPATH\tSTATUS\tAGE\tSET\tROTATED\tEXPIRES")
+			for _, c := range sessions.Cookies {
+				name := c.Name
+				if trafficSessionsAll && c.IsSession {
+					name += " *"
+				}
+				expires := "session"
+				if c.ExpiresAt != nil {
+					expires = c.ExpiresAt.Local().Format("2006-01-02 15:04")
+				}
+				age := (time.Duration(c.AgeSeconds) * time.Second).String()
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", name, c.Domain, c.Path, c.Status, age, c.SetCount, c.ChangeCount, expires)
+			}
+			w.Flush()
+			if trafficSessionsAll {
+				fmt.Println("* session cookie")
+			}
+		}
+		if len(sessions.Events) == 0 {
+			return
+		}
+		fmt.Println("\nRecent session events:")
+		for _, e := range sessions.Events {
+			logRef := ""
+			if e.HTTPTrafficLogID.Valid {
+				logRef = fmt.Sprintf(" (log %d)", e.HTTPTrafficLogID.Int64)
+			}
+			fmt.Printf("  %s  %-14s %s%s\n", e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.EventType, e.Details, logRef)
+		}
+	},
+}
+
+var trafficLoginFlowCmd = &cobra.Command{
+	Use:   "login-flow",
+	Short: "Show the login flow of a target, replayed to mint fresh sessions",
+	Long: `A login flow is a sequence of logged requests that logs into the target, with extractors that
+pull tokens from their responses. Running it replays the requests with a fresh cookie jar (their
+URLs, headers and bodies may reference target variables and earlier extracted values as {{name}}),
+then writes the cookies it was given and the --header templates to its replay session and stores
+the extracted values as target variables. With --auto-refresh, the flow runs by itself when captured
+traffic shows the session ended (a 401 or a redirect to a login page, see 'traffic sessions').
+Uses the current target unless --target-id is given.`,
+	Example: `  # Record logs 41 (login form) and 42 (token request) as the login flow
+  toolkit traffic login-flow set 41 42 \
+    --extract 'csrf@1=body_regex:name="csrf" value="([^"]+)"' \
+    --extract 'access_token=json_path:$.access_token' \
+    -H 'Authorization: Bearer {{access_token}}' --auto-refresh
+
+  # Mint a fresh session now
+  toolkit traffic login-flow run`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficLoginFlowTargetID)
+		flow, err := database.GetLoginFlow(targetID)
+		if err != nil {
+			exitWithError(err)
+		}
+		printLoginFlow(flow)
+	},
+}
+
+var trafficLoginFlowSetCmd = &cobra.Command{
+	Use:   "set LOG_ID...",
+	Short: "Record logged requests, in order, as the login flow of a target",
+	Long: `Records the given log entries, in order, as the target's login flow, replacing the previous one.
+Their Cookie headers are not kept: the flow sends the cookies it is given along the way.
+--extract NAME[@STEP]=SOURCE:EXPRESSION stores a value from the response of step STEP (default: the
+last step) as {{NAME}}. SOURCE is body_regex (first capture group), json_path ($.data.token), header
+or cookie. -H 'Name: template' sets a header of the replay session from extracted values.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficLoginFlowTargetID)
+		req := models.LoginFlowRequest{SessionHeaders: make(map[string]string), AutoRefresh: trafficLoginFlowAutoRefresh}
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid log ID '%s'\n", arg)
+				os.Exit(1)
+			}
+			req.LogIDs = append(req.LogIDs, id)
+		}
+		for _, spec := range trafficLoginFlowExtract {
+			e, err := parseLoginFlowExtractor(spec)
+			if err != nil {
+				exitWithError(err)
+			}
+			req.Extractors = append(req.Extractors, e)
+		}
+		for _, h := range trafficLoginFlowHeaders {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: invalid header '%s': expected 'Name: value'\n", h)
+				os.Exit(1)
+			}
+			req.SessionHeaders[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		if trafficLoginFlowSessionID != 0 {
+			req.SessionID = &trafficLoginFlowSessionID
+		}
+		flow, err := core.SetLoginFlow(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Login flow of target %d recorded.\n\n", targetID)
+		printLoginFlow(flow)
+	},
+}
+
+var trafficLoginFlowRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Replay the login flow of a target and update its replay session",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficLoginFlowTargetID)
+		run, err := core.RunLoginFlow(targetID, models.LoginFlowTriggerManual)
+		if err != nil {
+			exitWithError(err)
+		}
+		for _, s := range run.Steps {
+			if s.LogID == 0 {
+				fmt.Printf("  %d. %s %s -> no response\n", s.Position, s.Method, s.URL)
+				continue
+			}
+			fmt.Printf("  %d. %s %s -> %d (log %d)\n", s.Position, s.Method, s.URL, s.StatusCode, s.LogID)
+		}
+		if run.Status != models.LoginFlowStatusSucceeded {
+			fmt.Fprintf(os.Stderr, "Login flow failed: %s\n", run.Error)
+			os.Exit(1)
+		}
+		names := make([]string, 0, len(run.Extracted))
+		for name := range run.Extracted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("Login flow succeeded: replay session %d updated.\n", run.SessionID)
+		if len(run.Cookies) > 0 {
+			fmt.Printf("Cookies: %s\n", strings.Join(run.Cookies, ", "))
+		}
+		if len(names) > 0 {
+			fmt.Printf("Extracted (stored as target variables): %s\n", strings.Join(names, ", "))
+		}
+	},
+}
+
+var trafficLoginFlowRemoveCmd = &cobra.Command{
+	Use:     "rm",
+	Short:   "Remove the login flow of a target (its replay session is kept)",
+	Aliases: []string{"remove"},
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficLoginFlowTargetID)
+		if err := database.DeleteLoginFlow(targetID); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Login flow of target %d removed.\n", targetID)
+	},
+}
+
+// parseLoginFlowExtractor parses a --extract NAME[@STEP]=SOURCE:EXPRESSION value.
+func parseLoginFlowExtractor(spec string) (models.LoginFlowExtractor, error) {
+	var e models.LoginFlowExtractor
+	invalid := fmt.Errorf("invalid extractor '%s': expected NAME[@STEP]=SOURCE:EXPRESSION", spec)
+	name, rest, ok := strings.Cut(spec, "=")
+	if !ok {
+		return e, invalid
+	}
+	if n, step, hasStep := strings.Cut(name, "@"); hasStep {
+		s, err := strconv.Atoi(step)
+		if err != nil {
+			return e, invalid
+		}
+		name, e.Step = n, s
+	}
+	source, expr, ok := strings.Cut(rest, ":")
+	if !ok {
+		return e, invalid
+	}
+	e.Name, e.Source, e.Expression = strings.TrimSpace(name), strings.TrimSpace(source), expr
+	return e, nil
+}
+
+func printLoginFlow(flow models.LoginFlow) {
+	fmt.Println("Steps:")
+	for _, s := range flow.Steps {
+		source := ""
+		if s.SourceLogID.Valid {
+			source = fmt.Sprintf(" (from log %d)", s.SourceLogID.Int64)
+		}
+		fmt.Printf("  %d. %s %s%s\n", s.Position, s.Method, s.URL, source)
+	}
+	if len(flow.Extractors) > 0 {
+		fmt.Println("Extractors:")
+		for _, e := range flow.Extractors {
+			step := "last step"
+			if e.Step != 0 {
+				step = fmt.Sprintf("step %d", e.Step)
+			}
+			fmt.Printf("  {{%s}} <- %s %s (%s)\n", e.Name, e.Source, e.Expression, step)
+		}
+	}
+	if len(flow.SessionHeaders) > 0 {
+		names := make([]string, 0, len(flow.SessionHeaders))
+		for name := range flow.SessionHeaders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("Session headers:")
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, flow.SessionHeaders[name])
+		}
+	}
+	session := "created on the first run"
+	if flow.SessionID.Valid {
+		session = strconv.FormatInt(flow.SessionID.Int64, 10)
+	}
+	fmt.Printf("Replay session: %s\n", session)
+	fmt.Printf("Auto refresh: %t\n", flow.AutoRefresh)
+	if flow.LastRunAt != nil {
+		fmt.Printf("Last run: %s, %s", flow.LastRunAt.Local().Format("2006-01-02 15:04:05"), flow.LastStatus)
+		if flow.LastError != "" {
+			fmt.Printf(" (%s)", flow.LastError)
+		}
+		fmt.Println()
+	}
+}
+
+var trafficTokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "List the JWTs and SAML messages seen in a target's traffic",
+	Long: `Lists the JWTs and SAML messages found in the URLs, headers and bodies of a target's captured
+traffic, with their algorithm, subject, expiry and the number of log entries they appeared in.
+Tokens are recorded as traffic is proxied (auth_tokens.capture); --scan first rescans the traffic
+captured before. Forge edited tokens through the API (POST /api/auth-tokens/jwt/forge and
+/api/auth-tokens/saml/forge), which can also create a Modifier task sending them.
+Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficTokensTargetID)
+		if trafficTokensScan {
+			result, err := core.ScanTargetAuthTokens(targetID)
+			if err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Rescanned %d log entries: %d occurrences recorded.\n\n", result.LogsScanned, result.Occurrences)
+		}
+		tokens, err := database.GetAuthTokens(targetID, trafficTokensKind)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(tokens) == 0 {
+			fmt.Printf("No auth tokens tracked for target %d.\n", targetID)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tKIND\tALG\tSUBJECT\tISSUER\tEXPIRES\tLOGS\tLAST SEEN")
+		for _, t := range tokens {
+			alg := t.Algorithm
+			if !t.Signed {
+				alg += " (unsigned)"
+			}
+			expires := "-"
+			if t.ExpiresAt != nil {
+				expires = t.ExpiresAt.Local().Format("2006-01-02 15:04")
+				if t.ExpiresAt.Before(time.Now()) {
+					expires += " (expired)"
+				}
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", t.ID, t.Kind, alg, shortenTokenField(t.Subject), shortenTokenField(t.Issuer),
+				expires, t.OccurrenceCount, t.LastSeenAt.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
+var trafficTokensShowCmd = &cobra.Command{
+	Use:   "show TOKEN_ID",
+	Short: "Decode a tracked token and list the log entries it appeared in",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid token ID '%s'\n", args[0])
+			os.Exit(1)
+		}
+		detail, err := core.GetAuthTokenDetail(id)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Token %d (%s) of target %d\n%s\n\n", detail.ID, detail.Kind, detail.TargetID, detail.Token)
+		printDecodedAuthToken(models.DecodedAuthToken{Kind: detail.Kind, JWT: detail.JWT, SAML: detail.SAML})
+		fmt.Println("\nSeen in:")
+		for _, o := range detail.Occurrences {
+			fmt.Printf("  log %d  %s  %s %s  (%s)\n", o.HTTPTrafficLogID, o.Timestamp.Local().Format("2006-01-02 15:04:05"), o.Method, o.URL, o.Location)
+		}
+	},
+}
+
+var trafficTokensDecodeCmd = &cobra.Command{
+	Use:   "decode TOKEN",
+	Short: "Decode a JWT or SAML message (base64, deflated or XML)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		decoded, err := core.DecodeAuthToken(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		printDecodedAuthToken(*decoded)
+	},
+}
+
+func printDecodedAuthToken(decoded models.DecodedAuthToken) {
+	var out []byte
+	switch {
+	case decoded.JWT != nil:
+		out, _ = json.MarshalIndent(map[string]interface{}{"header": decoded.JWT.Header, "claims": decoded.JWT.Claims}, "", "  ")
+		fmt.Println(string(out))
+		if decoded.JWT.Expired {
+			fmt.Println("Expired.")
+		}
+	case decoded.SAML != nil:
+		samlXML := decoded.SAML.XML
+		summary := *decoded.SAML
+		summary.XML = ""
+		out, _ = json.MarshalIndent(summary, "", "  ")
+		fmt.Println(string(out))
+		fmt.Println(samlXML)
+	}
+}
+
+// shortenTokenField keeps long token subjects and issuers from widening the table.
+func shortenTokenField(s string) string {
+	if len(s) > 40 {
+		return s[:37] + "..."
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var trafficHeadersCmd = &cobra.Command{
+	Use:   "headers",
+	Short: "Grade the security headers of each host from captured responses",
+	Long: `Aggregates the security headers of the target's captured responses per host: HSTS on HTTPS
+responses; CSP, X-Frame-Options (or CSP frame-ancestors), Referrer-Policy and Permissions-Policy on
+HTML pages; X-Content-Type-Options on successful responses; and version disclosure in Server and
+X-Powered-By. Each host gets a score and grade (A-F), worst first, with the missing or weak headers
+and example log IDs to look at. Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficHeadersTargetID)
+		report, err := core.GetSecurityHeaderReport(targetID, trafficHeadersHost)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficHeadersJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		if len(report.Hosts) == 0 {
+			fmt.Printf("No captured responses for target %d.\n", targetID)
+			return
+		}
+		for i, h := range report.Hosts {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s  grade %s (%d/100), %d responses (%d HTML, %d HTTPS)\n", h.Host, h.Grade, h.Score, h.Responses, h.HTMLResponses, h.HTTPSResponses)
+			for _, c := range h.Checks {
+				if c.Status == models.HeaderStatusOK || c.Status == models.HeaderStatusNotApplicable {
+					continue
+				}
+				fmt.Printf("  %-26s %s\n", c.Header, c.Status)
+				for _, issue := range c.Issues {
+					fmt.Printf("    - %s\n", issue)
+				}
+				if len(c.ExampleLogIDs) > 0 {
+					ids := make([]string, len(c.ExampleLogIDs))
+					for j, id := range c.ExampleLogIDs {
+						ids[j] = strconv.FormatInt(id, 10)
+					}
+					fmt.Printf("    e.g. log %s\n", strings.Join(ids, ", "))
+				}
+			}
+		}
+	},
+}
+
+var trafficTLSCmd = &cobra.Command{
+	Use:   "tls",
+	Short: "Flag weak TLS configurations of each host from the proxy's connections",
+	Long: `Aggregates per host the TLS connections the target's traffic was sent over through the proxy:
+the negotiated versions, cipher suites, SNI and ALPN protocols, and the certificate chain. Flags
+TLS 1.0/1.1, insecure, non forward-secret or CBC cipher suites, expired, expiring, self-signed or
+untrusted certificates, certificates not covering the host, weak keys and SHA-1 or MD5 signatures.
+Weak hosts come first. Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficTLSTargetID)
+		report, err := core.GetTLSReport(targetID, trafficTLSHost, trafficTLSWeakOnly)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficTLSJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		if len(report.Hosts) == 0 {
+			fmt.Printf("No TLS connections recorded for target %d.\n", targetID)
+			return
+		}
+		for i, h := range report.Hosts {
+			if i > 0 {
+				fmt.Println()
+			}
+			state := "ok"
+			if h.Weak {
+				state = "WEAK"
+			}
+			fmt.Printf("%s  %s, %d requests over %d connections\n", h.Host, state, h.Requests, len(h.Connections))
+			fmt.Printf("  %s  %s\n", strings.Join(h.Versions, ", "), strings.Join(h.CipherSuites, ", "))
+			if c := h.Certificate; c != nil {
+				fmt.Printf("  certificate %s, issued by %s, %s %d, valid until %s\n", c.Subject, c.Issuer, c.KeyType, c.KeyBits, c.NotAfter.Format("2006-01-02"))
+			}
+			for _, issue := range h.Issues {
+				ids := make([]string, len(issue.ConnectionIDs))
+				for j, id := range issue.ConnectionIDs {
+					ids[j] = strconv.FormatInt(id, 10)
+				}
+				fmt.Printf("  [%s] %s (connection %s)\n", issue.Severity, issue.Issue, strings.Join(ids, ", "))
+			}
+		}
+	},
+}
+
+var trafficIDORCmd = &cobra.Command{
+	Use:   "idor",
+	Short: "List endpoints taking numeric or UUID identifiers, with suggested IDOR tests",
+	Long: `Tracks the numeric and UUID identifiers in the target's latest captured requests (path
+segments, query parameters and JSON or form bodies; idor.max_logs) per session, telling sessions
+apart by their session cookies (cookie_jar.session_cookies) and Authorization header and naming
+them after matching replay sessions. Each place an identifier appears is listed, most promising
+first, with suggested tests: the identifier plus and minus one, identifiers another session sent
+to the same place, and the request replayed with each other replay session
+(POST /api/replay/batches). Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficIDORTargetID)
+		worklist, err := core.GetIDORWorklist(targetID, trafficIDORHost, trafficIDORLimit)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficIDORJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(worklist)
+			return
+		}
+		if len(worklist.Candidates) == 0 {
+			fmt.Printf("No identifiers found in %d captured requests of target %d.\n", worklist.LogsScanned, targetID)
+			return
+		}
+		labels := make([]string, len(worklist.Sessions))
+		for i, s := range worklist.Sessions {
+			labels[i] = fmt.Sprintf("%s (%d requests)", s.Label, s.Requests)
+		}
+		fmt.Printf("Scanned %d requests from %d sessions: %s\n\n", worklist.LogsScanned, len(worklist.Sessions), strings.Join(labels, ", "))
+		for i, c := range worklist.Candidates {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("[%d] %s %s%s  %s %s (%s), %d requests, %d values, log %d\n", c.Priority, c.Method, c.Host, c.Endpoint, c.Location, c.Name, c.Kind, c.Requests, c.DistinctValues, c.ExampleLogID)
+			if len(c.Reasons) > 0 {
+				fmt.Printf("    %s\n", strings.Join(c.Reasons, "; "))
+			}
+			for _, t := range c.Tests {
+				fmt.Printf("    - %s\n", t.Description)
+				if t.URL != "" {
+					fmt.Printf("      %s\n", t.URL)
+				}
+			}
+		}
+	},
+}
+
+var trafficAuthSchemesCmd = &cobra.Command{
+	Use:   "auth-schemes",
+	Short: "Classify endpoints by auth scheme and list possibly unauthenticated ones",
+	Long: `Classifies the endpoints (method, host and path, with numeric and UUID segments as {id} and
+{uuid}) of the target's latest captured requests (auth_schemes.max_logs) by the credentials they
+carried: session cookies (cookie_jar.session_cookies), a bearer or basic Authorization header, or
+an API key header or query parameter (auth_schemes.api_key_headers and api_key_params).
+
+Endpoints that answered a request without credentials with a 2xx while sibling endpoints require
+credentials are listed as possibly unauthenticated. Siblings are the endpoints under the same
+parent path, or on the same host when there are none; an endpoint requires credentials when it
+answered a request without them with a 401 or 403, or was only requested with an Authorization
+header or API key. Uses the current target unless --target-id is given.`,
+	Example: `  # Possibly unauthenticated endpoints of the current target
+  toolkit traffic auth-schemes
+
+  # Every endpoint of one host with its scheme
+  toolkit traffic auth-schemes --host api.example.com --all`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficAuthTargetID)
+		report, err := core.GetAuthSchemeReport(targetID, trafficAuthHost)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficAuthJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		if len(report.Endpoints) == 0 {
+			fmt.Printf("No endpoints found in %d captured requests of target %d.\n", report.LogsScanned, targetID)
+			return
+		}
+		var schemes []string
+		for _, s := range []string{models.AuthSchemeCookie, models.AuthSchemeBearer, models.AuthSchemeBasic, models.AuthSchemeAPIKey, models.AuthSchemeNone} {
+			if n := report.Schemes[s]; n > 0 {
+				schemes = append(schemes, fmt.Sprintf("%s %d", s, n))
+			}
+		}
+		fmt.Printf("Scanned %d requests, %d endpoints: %s\n", report.LogsScanned, len(report.Endpoints), strings.Join(schemes, ", "))
+		if trafficAuthAll {
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "METHOD\tENDPOINT\tSCHEME\tREQUESTS\tNO CREDENTIALS\tREQUIRES AUTH")
+			for _, e := range report.Endpoints {
+				scheme := e.Scheme
+				if len(e.APIKeyNames) > 0 {
+					scheme += " (" + strings.Join(e.APIKeyNames, ", ") + ")"
+				}
+				fmt.Fprintf(w, "%s\t%s%s\t%s\t%d\t%d\t%t\n", e.Method, e.Host, e.Endpoint, scheme, e.Requests, e.UnauthenticatedRequests, e.RequiresAuth)
+			}
+			w.Flush()
+		}
+		if len(report.Unauthenticated) == 0 {
+			fmt.Println("\nNo possibly unauthenticated endpoints.")
+			return
+		}
+		fmt.Printf("\nPossibly unauthenticated endpoints (%d):\n", len(report.Unauthenticated))
+		for _, e := range report.Unauthenticated {
+			fmt.Printf("  %s %s%s  log %d\n    %s\n", e.Method, e.Host, e.Endpoint, e.UnauthenticatedLogID, e.Reason)
+		}
+	},
+}
+
+var trafficAnomaliesCmd = &cobra.Command{
+	Use:   "anomalies",
+	Short: "List responses far larger or slower than their endpoint's usual ones",
+	Long: `Builds a baseline of each endpoint (method, host and path, with numeric and UUID segments
+as {id} and {uuid}) from the target's latest captured responses (anomalies.max_logs): the median
+response size and latency of endpoints with at least --min-samples responses. Responses over
+--size-factor times the median size or --latency-factor times the median latency are listed,
+largest deviation first. Such outliers often are debug endpoints, verbose errors or the side
+effects of an injection. Uses the current target unless --target-id is given.`,
+	Example: `  # Responses at least 5x the usual size of their endpoint
+  toolkit traffic anomalies --kind size --size-factor 5`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficAnomalyTargetID)
+		report, err := core.GetTrafficAnomalies(models.TrafficAnomalyFilters{
+			TargetID:      targetID,
+			Host:          trafficAnomalyHost,
+			Kind:          trafficAnomalyKind,
+			SizeFactor:    trafficAnomalySizeFactor,
+			LatencyFactor: trafficAnomalyLatencyFactor,
+			MinSamples:    trafficAnomalyMinSamples,
+			Limit:         trafficAnomalyLimit,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficAnomalyJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		fmt.Printf("Scanned %d responses; %d endpoints have at least %d responses.\n", report.ResponsesScanned, report.Endpoints, report.MinSamples)
+		if len(report.Anomalies) == 0 {
+			fmt.Printf("No response is %gx the median size or %gx the median latency of its endpoint.\n", report.SizeFactor, report.LatencyFactor)
+			return
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LOG\tKIND\tFACTOR\tVALUE\tMEDIAN\tSTATUS\tENDPOINT")
+		for _, a := range report.Anomalies {
+			value, median := fmt.Sprintf("%d B", a.Value), fmt.Sprintf("%d B", a.Baseline.MedianSize)
+			if a.Kind == models.AnomalyKindLatency {
+				value, median = fmt.Sprintf("%d ms", a.Value), fmt.Sprintf("%d ms", a.Baseline.MedianDurationMs)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%.1fx\t%s\t%s\t%d\t%s %s\n", a.LogID, a.Kind, a.Factor, value, median, a.StatusCode, a.Baseline.Method, a.Baseline.Endpoint)
+		}
+		w.Flush()
+		if report.Total > len(report.Anomalies) {
+			fmt.Printf("\n%d more; raise --limit to list them.\n", report.Total-len(report.Anomalies))
+		}
+	},
+}
+
+var trafficCORSCheckCmd = &cobra.Command{
+	Use:   "cors-check",
+	Short: "Replay captured endpoints with crafted Origin headers to find CORS misconfigurations",
+	Long: `Resends captured GET requests of the target (the latest of each distinct URL with a 2xx
+response, or the given --log-id entries) with crafted Origin headers: an arbitrary origin
+(cors_check.attacker_domain), null, prefix and suffix variations of the host, an unescaped-dot
+variation, an arbitrary subdomain and plain HTTP. When Access-Control-Allow-Origin echoes one, a
+Draft finding is created with the request and response as evidence; severity depends on
+Access-Control-Allow-Credentials. Probes are logged to the traffic log with log source 'CORS Check'.
+Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficCORSTargetID)
+		job, err := core.StartCORSCheck(targetID, models.CORSCheckRequest{LogIDs: trafficCORSLogIDs, Host: trafficCORSHost, MaxEndpoints: trafficCORSMax})
+		if err != nil {
+			exitWithError(err)
+		}
+		job = followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Checked %d/%d endpoints (%d misconfigured, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		})
+		if job.ItemsSucceeded > 0 {
+			fmt.Println("Review the new Draft findings on the target's Findings page.")
+		}
+	},
+}
+
+var trafficRedirectParamsCmd = &cobra.Command{
+	Use:   "redirect-params",
+	Short: "Find parameters that may lead to open redirects or SSRF",
+	Long: `Scans the target's parameter inventory (built by parameter analysis) for parameters whose
+name suggests a redirect target or a URL the server fetches (next, redirect, return_to, url,
+callback, dest, webhook, ...) or whose captured value is a URL, and lists them with a confidence
+level: low when only the name or only the value points at a URL, medium when both do.
+
+With --scan, the candidates at or above --min-confidence are filed as Draft findings (Open
+Redirect or SSRF). --test also replays GET redirect candidates with the harmless marker domain
+(redirect_check.marker_domain) as their value: a redirect there confirms an open redirect (high
+confidence), and candidates that do not redirect drop to low. Test requests are logged to the
+traffic log with log source 'Redirect Check'. Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficRedirectTargetID)
+		if !trafficRedirectScan && !trafficRedirectTest {
+			candidates, err := core.FindRedirectParamCandidates(targetID, trafficRedirectHost)
+			if err != nil {
+				exitWithError(err)
+			}
+			if trafficRedirectJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				enc.Encode(candidates)
+				return
+			}
+			if len(candidates) == 0 {
+				fmt.Printf("No redirect or SSRF parameter candidates for target %d. Run parameter analysis first if the inventory is empty.\n", targetID)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CONFIDENCE\tKINDS\tPARAM\tENDPOINT\tVALUE")
+			for _, c := range candidates {
+				value := c.Value
+				if len(value) > 60 {
+					value = value[:57] + "..."
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s %s%s\t%s\n", c.Confidence, strings.Join(c.Kinds, ","), c.Param, c.Method, c.Host, c.Path, value)
+			}
+			w.Flush()
+			return
+		}
+
+		job, err := core.StartRedirectParamScan(targetID, models.RedirectParamScanRequest{
+			Host:          trafficRedirectHost,
+			Test:          trafficRedirectTest,
+			MinConfidence: trafficRedirectMinConfidence,
+			MaxTests:      trafficRedirectMaxTests,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		job = followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Scanned %d/%d candidates (%d filed, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		})
+		if job.ItemsSucceeded > 0 {
+			fmt.Println("Review the new Draft findings on the target's Findings page.")
+		}
+	},
+}
+
+var trafficMineParamsCmd = &cobra.Command{
+	Use:   "mine-params",
+	Short: "Discover hidden parameters of captured endpoints",
+	Long: `Probes captured requests of the target (the latest of each distinct GET URL browsed through the
+proxy, Page Sitemap or Modifier with a 2xx HTML, JSON or XML response, or the given --log-id
+entries) with batches of candidate parameter names, each with a random value. Candidates are the
+form fields, JSON keys and script variables of the page, then param_mining.wordlist or a built-in
+list of common names; --word and --wordlist-id add names, --words-only uses only those. POST
+requests given by --log-id take the names in their form or JSON body.
+
+A batch whose response reflects a value or differs from the endpoint's baseline is halved until the
+names that cause it remain, and each one is confirmed alone. Confirmed parameters join the parameter
+inventory with source 'param_mining' and a confidence: high when the value is reflected, medium when
+the status code changes, low when only the content does. Confirming requests are logged to the
+traffic log with log source 'Param Mining'. Uses the current target unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficMineTargetID)
+		req := models.ParamMiningRequest{LogIDs: trafficMineLogIDs, Host: trafficMineHost, MaxEndpoints: trafficMineMax,
+			Words: trafficMineWords, WordsOnly: trafficMineWordsOnly, ChunkSize: trafficMineChunkSize}
+		if trafficMineWordlist != "" {
+			req.WordlistID = resolveWordlistID(trafficMineWordlist)
+		}
+		job, err := core.StartParamMining(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Mined %d/%d endpoints (%d with hidden parameters, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsSucceeded, job.ErrorCount)
+		})
+
+		params, _, err := database.GetParameterizedURLs(models.ParameterizedURLFilters{TargetID: targetID, Source: models.ParameterSourceMining,
+			SortBy: "request_path", SortOrder: "ASC", Page: 1, Limit: 500})
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(params) == 0 {
+			return
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CONFIDENCE\tPARAM\tENDPOINT\tEVIDENCE\tNOTES")
+		for _, p := range params {
+			evidence := "-"
+			if p.HTTPTrafficLogID.Valid {
+				evidence = fmt.Sprintf("log %d", p.HTTPTrafficLogID.Int64)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s %s\t%s\t%s\n", p.Confidence.String, p.ParamKeys, p.RequestMethod.String, p.RequestPath.String, evidence, p.Notes.String)
+		}
+		w.Flush()
+	},
+}
+
+var trafficGenerateRequestsCmd = &cobra.Command{
+	Use:   "generate-requests",
+	Short: "Generate a modifier task or ffuf command for each endpoint of the parameter inventory",
+	Long: `Bootstraps coverage of a large API in one step: each endpoint (method, host and path) of the
+target's parameter inventory gets a request built from its latest captured request, with its headers
+and body, and every parameter seen or mined for it. Parameters known only from mining are added where
+the request sends its parameters.
+
+--format modifier (the default) creates modifier tasks filed under a new collection (--collection
+names it, --collection-id reuses one). Endpoints that already have a task generated from them are
+skipped unless --all is given. --format ffuf prints ffuf command lines instead, with the parameters
+set to FUZZ and {{target_wordlist}} as the wordlist unless --wordlist-id is given; they can be pasted
+into checklist items.
+
+--value-template sets each parameter value: {name} and {value} stand for the parameter and its
+captured value. '{{{name}}}' turns the values into {{<parameter>}} target variables, "{value}'" appends
+a quote. --name-template names the tasks from {method}, {host}, {path} and {params}. Uses the
+current target unless --target-id is given.`,
+	Example: `  toolkit traffic generate-requests --path /api/ --collection "API v2"
+  toolkit traffic generate-requests --method POST --value-template '{{{name}}}'
+  toolkit traffic generate-requests --format ffuf --host api.example.com --wordlist-id params`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficGenTargetID)
+		req := models.RequestGenerationRequest{Format: trafficGenFormat, Host: trafficGenHost, Methods: trafficGenMethods,
+			PathContains: trafficGenPath, Source: trafficGenSource, NameTemplate: trafficGenNameTemplate, ValueTemplate: trafficGenValueTemplate,
+			CollectionID: trafficGenCollectionID, CollectionName: trafficGenCollection, IncludeExisting: trafficGenAll,
+			MaxEndpoints: trafficGenMax, DryRun: trafficGenDryRun}
+		if trafficGenWordlist != "" {
+			req.WordlistID = resolveWordlistID(trafficGenWordlist)
+		}
+		result, err := core.GenerateEndpointRequests(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		for _, e := range result.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", e)
+		}
+		if result.Format == models.RequestGenerationFfuf {
+			for _, r := range result.Requests {
+				fmt.Printf("# %s %s (%s)\n%s\n", r.Method, r.URL, strings.Join(r.Parameters, ", "), r.Command)
+			}
+			fmt.Fprintf(os.Stderr, "%d ffuf commands for %d endpoints.\n", result.Generated, result.Endpoints)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TASK\tMETHOD\tURL\tPARAMETERS\tSOURCE LOG")
+		for _, r := range result.Requests {
+			task, source := "-", "-"
+			if r.TaskID != 0 {
+				task = strconv.FormatInt(r.TaskID, 10)
+			}
+			if r.SourceLogID != 0 {
+				source = strconv.FormatInt(r.SourceLogID, 10)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", task, r.Method, r.URL, strings.Join(r.Parameters, ","), source)
+		}
+		w.Flush()
+		switch {
+		case trafficGenDryRun:
+			fmt.Printf("Dry run: %d of %d endpoints would get a task (%d already have one).\n", result.Generated, result.Endpoints, result.Skipped)
+		case result.Generated > 0:
+			fmt.Printf("Created %d modifier tasks in collection %d (%d of %d endpoints already had one).\n", result.Generated, result.CollectionID, result.Skipped, result.Endpoints)
+		default:
+			fmt.Printf("No tasks created (%d of %d endpoints already had one).\n", result.Skipped, result.Endpoints)
+		}
+	},
+}
+
+var trafficRateProfileCmd = &cobra.Command{
+	Use:   "rate-profile",
+	Short: "Profile a host's rate limiting and WAF, and manage per-host safe rates",
+	Long: `Without options, lists the rate profiles of the target's hosts (--all for every host).
+
+With --url or --log-id (a captured request to resend), profiles the endpoint's host: after a
+baseline request, bursts of --requests requests are sent at --start-rps, doubling up to
+--max-rps, until responses turn into 429, 503 or (unlike the baseline) 403. WAFs and CDNs
+(Cloudflare, Akamai, AWS WAF, Imperva, ...) are recognized from response headers, cookies and
+bodies. The host's safe rate, rate_profiler.safety_factor times the highest unthrottled rate, is
+stored, and every later toolkit request to the host (replays, checks, repeater, scans) is paced
+to it, even with the rate limiter disabled.
+
+--set-safe-rate HOST=RPS sets a host's safe rate by hand (0 clears it); --clear HOST removes its
+profile. Uses the current target unless --target-id is given.`,
+	Example: `  # Profile an endpoint, from 1 up to 20 req/s
+  toolkit traffic rate-profile --url https://api.example.com/health
+
+  # Cap api.example.com at 2 requests per second
+  toolkit traffic rate-profile --set-safe-rate api.example.com=2`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch {
+		case trafficRateSet != "":
+			host, raw, found := strings.Cut(trafficRateSet, "=")
+			rps, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if !found || err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --set-safe-rate expects HOST=RPS, got %q\n", trafficRateSet)
+				os.Exit(1)
+			}
+			profile, err := core.SetHostSafeRate(host, rps)
+			if err != nil {
+				exitWithError(err)
+			}
+			if profile.SafeRPS == nil {
+				fmt.Printf("Cleared the safe rate of %s.\n", profile.Host)
+			} else {
+				fmt.Printf("Requests to %s are now capped at %.2f req/s.\n", profile.Host, *profile.SafeRPS)
+			}
+			return
+		case trafficRateClear != "":
+			if err := core.DeleteHostRateProfile(trafficRateClear); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Removed the rate profile of %s.\n", trafficRateClear)
+			return
+		case trafficRateURL == "" && trafficRateLogID == 0:
+			var targetID int64
+			if !trafficRateAll {
+				targetID = flagOrCurrentTargetID(cmd, trafficRateTargetID)
+			}
+			profiles, err := core.GetHostRateProfiles(targetID)
+			if err != nil {
+				exitWithError(err)
+			}
+			if trafficRateJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				enc.Encode(profiles)
+				return
+			}
+			if len(profiles) == 0 {
+				fmt.Println("No rate profiles. Profile a host with --url or --log-id.")
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "HOST\tSAFE RPS\tTHROTTLED AT\tTESTED UP TO\tWAF\tPROFILED")
+			for _, p := range profiles {
+				safe, throttled, profiled := "-", "-", "-"
+				if p.SafeRPS != nil {
+					safe = fmt.Sprintf("%.2f", *p.SafeRPS)
+					if p.Manual {
+						safe += " (manual)"
+					}
+				}
+				if p.ThrottleRPS != nil && p.ThrottleStatus != nil {
+					throttled = fmt.Sprintf("%.2f (%d)", *p.ThrottleRPS, *p.ThrottleStatus)
+				}
+				if p.ProfiledAt != nil {
+					profiled = p.ProfiledAt.Local().Format("2006-01-02 15:04")
+				}
+				wafs := make([]string, len(p.WAFs))
+				for i, waf := range p.WAFs {
+					wafs[i] = waf.Name
+				}
+				waf := strings.Join(wafs, ", ")
+				if waf == "" {
+					waf = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\t%s\n", p.Host, safe, throttled, p.MaxTestedRPS, waf, profiled)
+			}
+			w.Flush()
+			return
+		}
+
+		targetID := flagOrCurrentTargetID(cmd, trafficRateTargetID)
+		job, err := core.StartRateProfile(targetID, models.RateProfileRequest{
+			URL:      trafficRateURL,
+			LogID:    trafficRateLogID,
+			StartRPS: trafficRateStart,
+			MaxRPS:   trafficRateMax,
+			Requests: trafficRateRequests,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Sent %d/%d requests (%d throttled, %d errors)", job.ItemsProcessed, job.ItemsTotal, job.ItemsProcessed-job.ItemsSucceeded, job.ErrorCount)
+		})
+	},
+}
+
+var trafficDedupeBodiesCmd = &cobra.Command{
+	Use:   "dedupe-bodies",
+	Short: "Move traffic bodies captured before deduplication into shared blobs",
+	Long: `Request and response bodies of at least 512 bytes are stored once per SHA-256 in a blob
+table shared by every log entry with the same body, so repeat captures of JS bundles, images and
+identical API responses take no extra room. Entries logged before this was introduced keep their
+bodies inline; this command moves them into blobs.
+
+--vacuum rebuilds the database file afterwards so the freed space is returned to the file
+system. --stats only prints how bodies are stored.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !trafficDedupeStats {
+			job, err := core.StartTrafficBodyDedup(models.BodyDedupRequest{Vacuum: trafficDedupeVacuum})
+			if err != nil {
+				exitWithError(err)
+			}
+			followJob(job, func(job models.Job) string {
+				return fmt.Sprintf("Moved bodies of %d/%d entries", job.ItemsProcessed, job.ItemsTotal)
+			})
+		}
+		stats, err := core.GetBodyBlobStats()
+		if err != nil {
+			exitWithError(err)
+		}
+		mb := func(n int64) string { return fmt.Sprintf("%.1f MB", float64(n)/(1<<20)) }
+		fmt.Printf("Blobs:   %d (%s), referenced %d times, saving %s\n", stats.Blobs, mb(stats.BlobBytes), stats.References, mb(stats.SavedBytes))
+		fmt.Printf("Inline:  %d entries (%s)\n", stats.InlineEntries, mb(stats.InlineBytes))
+		if stats.MovableEntries > 0 {
+			fmt.Printf("Movable: %d entries have bodies to move; run 'toolkit traffic dedupe-bodies'\n", stats.MovableEntries)
+		}
+	},
+}
+
+var trafficBeautifyCmd = &cobra.Command{
+	Use:   "beautify",
+	Short: "Store readable copies of minified JavaScript, JSON, HTML and XML responses",
+	Long: `Formats the JavaScript, JSON, HTML and XML response bodies that have no beautified copy yet
+and stores the copies next to the originals, once per distinct body. Diffs (--beautify), the body
+search ('toolkit traffic list --body') and the analyzers (beautify.analysis) read the copies
+instead of one-line minified bundles. Copies are otherwise made on first use, or as responses
+are captured with beautify.on_capture. Beautifies all targets unless --target-id is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		job, err := core.StartTrafficBeautify(models.BeautifyRequest{TargetID: trafficBeautifyTargetID})
+		if err != nil {
+			exitWithError(err)
+		}
+		followJob(job, func(job models.Job) string {
+			return fmt.Sprintf("Beautified %d/%d responses", job.ItemsProcessed, job.ItemsTotal)
+		})
+	},
+}
+
+var trafficCompareEnvCmd = &cobra.Command{
+	Use:   "compare-env",
+	Short: "Replay captured requests against another environment and compare the responses",
+	Long: `Replays captured requests of the target against --base-url (e.g. staging or a regional
+deployment) and compares each response with the captured one: status, body (JSON fields or
+text lines) and headers. Volatile headers such as Date, ETag, Set-Cookie and request IDs are
+ignored. Endpoints that differ are listed first.
+
+Without --log-ids, the latest request you captured of each endpoint (method, host and path with
+IDs as {id}) is replayed, up to --max-endpoints. Requests keep their path and query under the
+base URL's path; Origin and Referer headers are moved to the new origin. The comparison is a
+replay batch, so its requests are logged and served under /replay/batches/{batch_id}.`,
+	Example: `  # Compare the API endpoints of production with staging
+  toolkit traffic compare-env --base-url https://staging.example.com --host api.example.com
+
+  # Compare two specific requests with the auth of replay session 3
+  toolkit traffic compare-env --base-url https://eu.example.com --log-ids 120,121 --session-id 3`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficCompareTargetID)
+		batch, err := core.StartReplayComparison(targetID, models.ReplayCompareRequest{
+			BaseURL:      trafficCompareBaseURL,
+			LogIDs:       trafficCompareLogIDs,
+			Host:         trafficCompareHost,
+			PathContains: trafficComparePathContains,
+			MaxEndpoints: trafficCompareMax,
+			SessionID:    trafficCompareSessionID,
+			DelayMs:      trafficCompareDelay,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Replay batch %d: %d requests to %s\n", batch.ID, batch.TotalItems, trafficCompareBaseURL)
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-sigs:
+				core.CancelReplayBatch(batch.ID)
+			case <-time.After(500 * time.Millisecond):
+			}
+			detail, err := database.GetReplayBatchDetail(batch.ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("\rSent %d/%d requests (%d failed)", detail.CompletedItems+detail.FailedItems, detail.TotalItems, detail.FailedItems)
+			if detail.Status != "pending" && detail.Status != "running" {
+				fmt.Println()
+				break
+			}
+		}
+
+		report, err := core.GetReplayComparison(batch.ID)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficCompareJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		fmt.Printf("Batch %s: %d compared, %d identical, %d with another status, %d with another body, %d with other headers, %d failed\n\n",
+			report.Status, report.Compared, report.Identical, report.StatusDiffers, report.BodyDiffers, report.HeadersDiffer, report.Failed)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "METHOD\tPATH\tSTATUS\tSIZE\tBODY\tHEADERS\tLOGS")
+		for _, ep := range report.Endpoints {
+			for _, c := range ep.Requests {
+				if c.Error != "" {
+					fmt.Fprintf(w, "%s\t%s\t%d -> error\t-\t%s\t-\t%d\n", ep.Method, ep.Path, c.SourceStatus, c.Error, c.SourceLogID)
+					continue
+				}
+				status := strconv.Itoa(c.SourceStatus)
+				if c.StatusChanged {
+					status += " -> " + strconv.Itoa(c.ReplayStatus)
+				}
+				body := c.BodyMode
+				if c.BodyChanges > 0 {
+					body = fmt.Sprintf("%s (%d changes)", c.BodyMode, c.BodyChanges)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d -> %d\t%s\t%s\t%d -> %d\n", ep.Method, ep.Path, status, c.SourceSize, c.ReplaySize,
+					body, orDash(strings.Join(c.HeaderChanges, ", ")), c.SourceLogID, c.ReplayLogID)
+			}
+		}
+		w.Flush()
+		fmt.Printf("\nCompare two entries in full with 'toolkit traffic diff <captured> <replayed>'.\n")
+	},
+}
+
+var trafficEnumerateCmd = &cobra.Command{
+	Use:   "enumerate",
+	Short: "Send requests built from a URL template with enumerated parameter values, once per replay session",
+	Long: `Builds requests from a URL template such as /api/users/{id}/orders/{orderId}: each {name}
+parameter takes the values given with --values, --range or --wordlist, every combination of them
+(--zip pairs the first values, then the second ones, ...). {{name}} target variables are filled in
+first. A relative template uses the host of the base request (--log-id), whose method, headers and
+body the requests reuse, or of the target's URL.
+
+The requests are sent through the proxy as a replay batch per replay session, the target's by
+default, and the answers are grouped by request across sessions: "shared" when several sessions got
+the same successful body (a likely authorization gap between accounts), "differs", "single" when
+only one session could read it, "denied" or "failed". Without replay sessions the requests are sent once.
+The report stays available under /replay/enumerations/{id}.`,
+	Example: `  # Which of 50 users' orders can each account read?
+  toolkit traffic enumerate --url '/api/users/{id}/orders/{orderId}' --range id=1-50 --values orderId=1,2 --log-id 120
+
+  # Pair each user with their own order, and only print the generated URLs
+  toolkit traffic enumerate --url '{{target_url}}/api/users/{id}/orders/{orderId}' --values id=3,4 --values orderId=31,44 --zip --dry-run`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, trafficEnumTargetID)
+		req := models.ReplayEnumerationRequest{
+			URLTemplate: trafficEnumURL,
+			Method:      trafficEnumMethod,
+			SourceLogID: trafficEnumLogID,
+			Values:      map[string][]string{},
+			Ranges:      map[string]models.ReplayEnumerationRange{},
+			WordlistIDs: map[string]int64{},
+			SessionIDs:  trafficEnumSessionIDs,
+			DelayMs:     trafficEnumDelay,
+			DryRun:      trafficEnumDryRun,
+		}
+		if trafficEnumZip {
+			req.Mode = models.EnumerationModeZip
+		}
+		for _, v := range trafficEnumValues {
+			name, list, ok := strings.Cut(v, "=")
+			if !ok {
+				exitWithError(fmt.Errorf("invalid --values '%s': expected name=value,value", v))
+			}
+			req.Values[name] = append(req.Values[name], strings.Split(list, ",")...)
+		}
+		for _, v := range trafficEnumRanges {
+			name, bounds, ok := strings.Cut(v, "=")
+			from, to, okRange := strings.Cut(bounds, "-")
+			r := models.ReplayEnumerationRange{}
+			var errFrom, errTo error
+			r.From, errFrom = strconv.ParseInt(from, 10, 64)
+			r.To, errTo = strconv.ParseInt(to, 10, 64)
+			if !ok || !okRange || errFrom != nil || errTo != nil {
+				exitWithError(fmt.Errorf("invalid --range '%s': expected name=from-to", v))
+			}
+			req.Ranges[name] = r
+		}
+		for _, v := range trafficEnumWordlists {
+			name, wordlist, ok := strings.Cut(v, "=")
+			if !ok {
+				exitWithError(fmt.Errorf("invalid --wordlist '%s': expected name=wordlist", v))
+			}
+			req.WordlistIDs[name] = resolveWordlistID(wordlist)
+		}
+
+		enumeration, err := core.StartReplayEnumeration(targetID, req)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficEnumDryRun {
+			if trafficEnumJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				enc.Encode(enumeration)
+				return
+			}
+			for _, r := range enumeration.Requests {
+				fmt.Printf("%s %s\n", enumeration.Method, r.URL)
+			}
+			fmt.Printf("\n%d requests.\n", len(enumeration.Requests))
+			return
+		}
+		fmt.Printf("Enumeration %d: %d requests in %d replay batches\n", enumeration.ID, len(enumeration.Requests), len(enumeration.BatchIDs))
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-sigs:
+				for _, batchID := range enumeration.BatchIDs {
+					core.CancelReplayBatch(batchID)
+				}
+			case <-time.After(500 * time.Millisecond):
+			}
+			sent, failed, running := 0, 0, false
+			for _, batchID := range enumeration.BatchIDs {
+				detail, err := database.GetReplayBatchDetail(batchID)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+					os.Exit(1)
+				}
+				sent += detail.CompletedItems + detail.FailedItems
+				failed += detail.FailedItems
+				running = running || detail.Status == "queued" || detail.Status == "running"
+			}
+			fmt.Printf("\rSent %d/%d requests (%d failed)", sent, len(enumeration.Requests)*len(enumeration.BatchIDs), failed)
+			if !running {
+				fmt.Println()
+				break
+			}
+		}
+
+		report, err := core.GetReplayEnumerationReport(enumeration.ID)
+		if err != nil {
+			exitWithError(err)
+		}
+		if trafficEnumJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		var counts []string
+		for _, verdict := range []string{models.EnumerationVerdictShared, models.EnumerationVerdictDiffers, models.EnumerationVerdictSingle,
+			models.EnumerationVerdictDenied, models.EnumerationVerdictFailed, models.EnumerationVerdictPending} {
+			if report.Verdicts[verdict] > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", report.Verdicts[verdict], verdict))
+			}
+		}
+		fmt.Printf("Enumeration %s: %s\n\n", report.Status, strings.Join(counts, ", "))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "VERDICT\tURL"
+		for _, s := range report.Sessions {
+			header += "\t" + strings.ToUpper(s.SessionName)
+		}
+		fmt.Fprintln(w, header)
+		for _, r := range report.Results {
+			line := r.Verdict + "\t" + r.URL
+			for _, resp := range r.Responses {
+				switch {
+				case resp.Error != "":
+					line += "\terror"
+				case resp.LogID != 0:
+					line += fmt.Sprintf("\t%d %dB (log %d)", resp.StatusCode, resp.Size, resp.LogID)
+				default:
+					line += fmt.Sprintf("\t%d", resp.StatusCode)
+				}
+			}
+			fmt.Fprintln(w, line)
+		}
+		w.Flush()
+		fmt.Printf("\nCompare two answers in full with 'toolkit traffic diff <log> <log>'.\n")
+	},
+}
+
+// followJob prints the progress of a background job until it ends, cancelling it on Ctrl+C so
+// it is not left 'running' when the command exits. It exits the process when the job does not
+// succeed.
+func followJob(job models.Job, progress func(models.Job) string) models.Job {
+	fmt.Printf("Job %d: %s\n", job.ID, job.Message)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	var err error
+	for job.Status == models.JobStatusRunning {
+		select {
+		case <-sigs:
+			core.CancelJob(job.ID)
+		case <-time.After(500 * time.Millisecond):
+		}
+		if job, err = core.GetJob(job.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\r%s", progress(job))
+	}
+	fmt.Println()
+	if job.Status != models.JobStatusSucceeded {
+		detail := job.LastError
+		if detail == "" {
+			detail = job.Message
+		}
+		fmt.Fprintf(os.Stderr, "Job %d %s: %s\n", job.ID, job.Status, detail)
+		os.Exit(1)
+	}
+	fmt.Println(job.Message)
+	return job
+}
+
+// --- Init Function ---
+
+func init() {
+	// List command flags
+	trafficListCmd.Flags().StringVarP(&trafficListDomain, "domain", "d", "", "Filter traffic by requests containing this domain/host string")
+	trafficListCmd.Flags().StringVarP(&trafficListRegex, "regex", "r", "", "Filter traffic by URL matching this Go regex pattern (applied after DB query)")
+	trafficListCmd.Flags().StringVarP(&trafficListRegexField, "regex-field", "f", "url", "Field to apply regex filter on (url, req_headers, req_body, res_headers, res_body)")
+	trafficListCmd.Flags().IntVarP(&trafficListStatusCode, "status-code", "s", 0, "Filter traffic by HTTP response status code (e.g., 200, 404)")
+	trafficListCmd.Flags().IntVarP(&trafficListLimit, "limit", "l", 30, "Number of results per page")
+	trafficListCmd.Flags().IntVarP(&trafficListPage, "page", "p", 1, "Page number to retrieve")
+	trafficListCmd.Flags().Int64VarP(&trafficListTargetID, "target-id", "t", 0, "Only list traffic for this target (with --view, defaults to the current target)")
+	trafficListCmd.Flags().StringVar(&trafficListView, "view", "", "Apply the filters and sorting of a saved traffic log view (flags take precedence)")
+	trafficListCmd.Flags().StringSliceVar(&trafficListTags, "tag", nil, "Only list traffic with any of these tags (comma-separated names)")
+	trafficListCmd.Flags().StringVar(&trafficListOrigin, "origin", "", "Only list manual (the user's) or automated (the toolkit's) traffic")
+	trafficListCmd.Flags().StringVar(&trafficListSource, "source", "", "Only list traffic with this log source (e.g. mitmproxy, Replay, Harvester)")
+	trafficListCmd.Flags().StringVar(&trafficListBody, "body", "", "Only list traffic whose response body or its beautified copy contains this text")
+	trafficListCmd.Flags().Int64Var(&trafficListCapture, "capture-session", 0, "Only list traffic logged within this capture session")
+	registerFlagCompletion(trafficListCmd, "domain", completeDomainNames)
+	registerFlagCompletion(trafficListCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficListCmd, "view", completeTrafficViews)
+	registerFlagCompletion(trafficListCmd, "tag", completeTagNames)
+	registerFlagCompletion(trafficListCmd, "regex-field", cobra.FixedCompletions([]string{"url", "req_headers", "req_body", "res_headers", "res_body"}, cobra.ShellCompDirectiveNoFileComp))
+	// Tail shares the filter flags of list
+	trafficTailCmd.Flags().StringVarP(&trafficListDomain, "domain", "d", "", "Only show requests containing this domain/host string")
+	trafficTailCmd.Flags().StringVarP(&trafficListRegex, "regex", "r", "", "Only show entries whose --regex-field matches this Go regex pattern")
+	trafficTailCmd.Flags().StringVarP(&trafficListRegexField, "regex-field", "f", "url", "Field to apply regex filter on (url, req_headers, req_body, res_headers, res_body)")
+	trafficTailCmd.Flags().IntVarP(&trafficListStatusCode, "status-code", "s", 0, "Only show responses with this HTTP status code")
+	trafficTailCmd.Flags().Int64VarP(&trafficListTargetID, "target-id", "t", 0, "Only show traffic for this target (with --view, defaults to the current target)")
+	trafficTailCmd.Flags().StringVar(&trafficListView, "view", "", "Apply the filters of a saved traffic log view (flags take precedence)")
+	trafficTailCmd.Flags().StringSliceVar(&trafficListTags, "tag", nil, "Only show traffic with any of these tags (comma-separated names)")
+	trafficTailCmd.Flags().StringVar(&trafficListOrigin, "origin", "", "Only show manual (the user's) or automated (the toolkit's) traffic")
+	trafficTailCmd.Flags().StringVar(&trafficListSource, "source", "", "Only show traffic with this log source (e.g. mitmproxy, Replay, Harvester)")
+	trafficTailCmd.Flags().StringVar(&trafficListBody, "body", "", "Only show traffic whose response body or its beautified copy contains this text")
+	trafficTailCmd.Flags().IntVarP(&trafficTailLines, "lines", "n", 10, "Show the last N matching entries before following")
+	trafficTailCmd.Flags().BoolVar(&trafficTailFull, "full", false, "Print full requests and responses with headers and bodies")
+	trafficTailCmd.Flags().BoolVar(&trafficTailJSON, "json", false, "Print one JSON object per entry")
+	registerFlagCompletion(trafficTailCmd, "domain", completeDomainNames)
+	registerFlagCompletion(trafficTailCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficTailCmd, "view", completeTrafficViews)
+	registerFlagCompletion(trafficTailCmd, "tag", completeTagNames)
+	registerFlagCompletion(trafficTailCmd, "regex-field", cobra.FixedCompletions([]string{"url", "req_headers", "req_body", "res_headers", "res_body"}, cobra.ShellCompDirectiveNoFileComp))
+	trafficViewsCmd.Flags().Int64VarP(&trafficListTargetID, "target-id", "t", 0, "Only list views for this target (plus global views)")
+	registerFlagCompletion(trafficViewsCmd, "target-id", completeTargetIDs)
+
+	// Map command flags
+	trafficMapCmd.Flags().Int64VarP(&trafficMapTargetID, "target-id", "t", 0, "ID of the target to map the log entry to (uses current target if not specified)")
+	registerFlagCompletion(trafficMapCmd, "target-id", completeTargetIDs)
+
+	// List Mapped command flags
+	trafficListMappedCmd.Flags().Int64VarP(&trafficMapTargetID, "target-id", "t", 0, "ID of the target to list mapped entries for (uses current target if not specified)")
+	registerFlagCompletion(trafficListMappedCmd, "target-id", completeTargetIDs)
+
+	// Analyze command flags
+	trafficAnalyzeCmd.Flags().StringVar(&trafficAnalyzeTool, "tool", "jsluice", "Analyzers to run: jsluice, secrets, comments, endpoints, a comma-separated list, or 'all'")
+	trafficAnalyzeCmd.Flags().IntVarP(&trafficAnalyzeLimit, "limit", "l", 100, "Maximum number of entries to analyze when no log_id is given")
+	trafficAnalyzeCmd.Flags().StringVarP(&trafficListDomain, "domain", "d", "", "Only analyze requests containing this domain/host string")
+	trafficAnalyzeCmd.Flags().IntVarP(&trafficListStatusCode, "status-code", "s", 0, "Only analyze responses with this status code")
+	trafficAnalyzeCmd.Flags().Int64VarP(&trafficListTargetID, "target-id", "t", 0, "Only analyze traffic for this target (with --view, defaults to the current target)")
+	trafficAnalyzeCmd.Flags().StringVar(&trafficListView, "view", "", "Analyze the entries matching a saved traffic log view")
+	trafficAnalyzeCmd.Flags().StringSliceVar(&trafficListTags, "tag", nil, "Only analyze traffic with any of these tags (comma-separated names)")
+	registerFlagCompletion(trafficAnalyzeCmd, "domain", completeDomainNames)
+	registerFlagCompletion(trafficAnalyzeCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficAnalyzeCmd, "view", completeTrafficViews)
+	registerFlagCompletion(trafficAnalyzeCmd, "tag", completeTagNames)
+
+	// Analyze-all command flags
+	trafficAnalyzeAllCmd.Flags().Int64VarP(&trafficAnalyzeAllTarget, "target", "t", 0, "ID of the target whose traffic to analyze (uses current target if not specified)")
+	trafficAnalyzeAllCmd.Flags().StringVar(&trafficAnalyzeAllContentType, "content-type", "", "Only analyze responses whose Content-Type contains this (e.g. javascript, html, json)")
+	trafficAnalyzeAllCmd.Flags().StringVar(&trafficAnalyzeAllTools, "tool", "", "Comma-separated analyzers to run (default: analysis.analyzers from the config)")
+	registerFlagCompletion(trafficAnalyzeAllCmd, "target", completeTargetIDs)
+
+	// Purge command flags
+	trafficPurgeCmd.Flags().BoolVarP(&trafficPurgeForce, "force", "", false, "Skip confirmation before purging records")
+
+	// Export flags
+	trafficExportCmd.Flags().StringVar(&trafficExportFormat, "format", "curl", "Output format: curl or raw")
+	registerFlagCompletion(trafficExportCmd, "format", cobra.FixedCompletions([]string{"curl", "raw"}, cobra.ShellCompDirectiveNoFileComp))
+
+	// Send-to flags
+	trafficSendToCmd.Flags().StringVar(&trafficSendName, "name", "", "Name of the modifier task or replay batch")
+	trafficSendToCmd.Flags().Int64Var(&trafficSendCollectionID, "collection-id", 0, "modifier: file the task under this collection")
+	trafficSendToCmd.Flags().Int64SliceVar(&trafficSendSessionIDs, "session-ids", nil, "replay: the session to use; authmatrix: the sessions to compare")
+	trafficSendToCmd.Flags().StringSliceVar(&trafficSendParams, "params", nil, "fuzzer: parameters to fuzz (default: all)")
+	trafficSendToCmd.Flags().Int64Var(&trafficSendWordlistID, "wordlist-id", 0, "fuzzer: wordlist to use (default: {{target_wordlist}})")
+	trafficSendToCmd.Flags().StringSliceVar(&trafficSendAnalyzers, "analyzers", nil, "scanner: analyzers to run (default: analysis.analyzers)")
+	trafficSendToCmd.Flags().BoolVar(&trafficSendJSON, "json", false, "Output the result as JSON")
+
+	// Promote flags
+	trafficPromoteCmd.Flags().StringVar(&trafficPromoteType, "type", "", "Vulnerability type name or ID (prompted for when omitted)")
+	trafficPromoteCmd.Flags().StringVar(&trafficPromoteTitle, "title", "", "Finding title (default: '<type> in METHOD /path')")
+	trafficPromoteCmd.Flags().StringVar(&trafficPromoteSeverity, "severity", "", "Severity: Informational, Low, Medium, High or Critical")
+	trafficPromoteCmd.Flags().BoolVar(&trafficPromoteNoPrompt, "no-prompt", false, "Do not ask for a vulnerability type when --type is not given")
+	registerFlagCompletion(trafficPromoteCmd, "severity", cobra.FixedCompletions(models.FindingSeverities, cobra.ShellCompDirectiveNoFileComp))
+
+	// Sessions flags
+	trafficSessionsCmd.Flags().Int64VarP(&trafficSessionsTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficSessionsCmd.Flags().BoolVar(&trafficSessionsAll, "all", false, "List every tracked cookie, not only session cookies")
+	trafficSessionsCmd.Flags().IntVar(&trafficSessionsEvents, "events", 20, "Number of recent session events to show")
+	trafficSessionsCmd.Flags().BoolVar(&trafficSessionsRebuild, "rebuild", false, "Rescan the target's captured traffic first")
+	registerFlagCompletion(trafficSessionsCmd, "target-id", completeTargetIDs)
+
+	// Login flow flags
+	trafficLoginFlowCmd.PersistentFlags().Int64VarP(&trafficLoginFlowTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficLoginFlowSetCmd.Flags().StringArrayVar(&trafficLoginFlowExtract, "extract", nil, "Extractor NAME[@STEP]=SOURCE:EXPRESSION (body_regex, json_path, header or cookie; repeatable)")
+	trafficLoginFlowSetCmd.Flags().StringArrayVarP(&trafficLoginFlowHeaders, "header", "H", nil, "Replay session header template 'Name: value with {{name}}' (repeatable)")
+	trafficLoginFlowSetCmd.Flags().Int64Var(&trafficLoginFlowSessionID, "session", 0, "Replay session to update (default: one created on the first run)")
+	trafficLoginFlowSetCmd.Flags().BoolVar(&trafficLoginFlowAutoRefresh, "auto-refresh", false, "Run the flow when captured traffic shows the session ended")
+	registerFlagCompletion(trafficLoginFlowCmd, "target-id", completeTargetIDs)
+	trafficLoginFlowCmd.AddCommand(trafficLoginFlowSetCmd, trafficLoginFlowRunCmd, trafficLoginFlowRemoveCmd)
+
+	// Auth token flags
+	trafficTokensCmd.Flags().Int64VarP(&trafficTokensTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficTokensCmd.Flags().StringVar(&trafficTokensKind, "kind", "", "Only list jwt or saml tokens")
+	trafficTokensCmd.Flags().BoolVar(&trafficTokensScan, "scan", false, "Rescan the target's captured traffic first")
+	registerFlagCompletion(trafficTokensCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficTokensCmd, "kind", cobra.FixedCompletions([]string{models.AuthTokenKindJWT, models.AuthTokenKindSAML}, cobra.ShellCompDirectiveNoFileComp))
+	trafficTokensCmd.AddCommand(trafficTokensShowCmd, trafficTokensDecodeCmd)
+
+	// Headers flags
+	trafficHeadersCmd.Flags().Int64VarP(&trafficHeadersTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficHeadersCmd.Flags().StringVar(&trafficHeadersHost, "host", "", "Only report this host")
+	trafficHeadersCmd.Flags().BoolVar(&trafficHeadersJSON, "json", false, "Output the report as JSON")
+	registerFlagCompletion(trafficHeadersCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficHeadersCmd, "host", completeDomainNames)
+
+	trafficTLSCmd.Flags().Int64VarP(&trafficTLSTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficTLSCmd.Flags().StringVar(&trafficTLSHost, "host", "", "Only report this host")
+	trafficTLSCmd.Flags().BoolVar(&trafficTLSWeakOnly, "weak", false, "Only list hosts with a high or medium issue")
+	trafficTLSCmd.Flags().BoolVar(&trafficTLSJSON, "json", false, "Output the report as JSON")
+	registerFlagCompletion(trafficTLSCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficTLSCmd, "host", completeDomainNames)
+
+	// CORS check flags
+	trafficCORSCheckCmd.Flags().Int64VarP(&trafficCORSTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficCORSCheckCmd.Flags().Int64SliceVar(&trafficCORSLogIDs, "log-id", nil, "Only replay these log entries (repeatable or comma-separated)")
+	trafficCORSCheckCmd.Flags().StringVar(&trafficCORSHost, "host", "", "Only check endpoints of this host")
+	trafficCORSCheckCmd.Flags().IntVar(&trafficCORSMax, "max", 0, "Maximum number of endpoints to check (default: cors_check.max_endpoints)")
+	registerFlagCompletion(trafficCORSCheckCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficCORSCheckCmd, "host", completeDomainNames)
+
+	// IDOR flags
+	trafficIDORCmd.Flags().Int64VarP(&trafficIDORTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficIDORCmd.Flags().StringVar(&trafficIDORHost, "host", "", "Only list endpoints of this host")
+	trafficIDORCmd.Flags().IntVarP(&trafficIDORLimit, "limit", "l", 25, "Maximum number of candidates to list (0 for all)")
+	trafficIDORCmd.Flags().BoolVar(&trafficIDORJSON, "json", false, "Output the worklist as JSON")
+	registerFlagCompletion(trafficIDORCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficIDORCmd, "host", completeDomainNames)
+
+	trafficAuthSchemesCmd.Flags().Int64VarP(&trafficAuthTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficAuthSchemesCmd.Flags().StringVar(&trafficAuthHost, "host", "", "Only classify endpoints of this host")
+	trafficAuthSchemesCmd.Flags().BoolVar(&trafficAuthAll, "all", false, "List every endpoint with its scheme, not only the possibly unauthenticated ones")
+	trafficAuthSchemesCmd.Flags().BoolVar(&trafficAuthJSON, "json", false, "Output the report as JSON")
+	registerFlagCompletion(trafficAuthSchemesCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficAuthSchemesCmd, "host", completeDomainNames)
+
+	trafficAnomaliesCmd.Flags().Int64VarP(&trafficAnomalyTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficAnomaliesCmd.Flags().StringVar(&trafficAnomalyHost, "host", "", "Only report this host")
+	trafficAnomaliesCmd.Flags().StringVar(&trafficAnomalyKind, "kind", "", "Only list size or latency anomalies")
+	trafficAnomaliesCmd.Flags().Float64Var(&trafficAnomalySizeFactor, "size-factor", 0, "Flag responses this many times the median size (default: anomalies.size_factor)")
+	trafficAnomaliesCmd.Flags().Float64Var(&trafficAnomalyLatencyFactor, "latency-factor", 0, "Flag responses this many times the median latency (default: anomalies.latency_factor)")
+	trafficAnomaliesCmd.Flags().IntVar(&trafficAnomalyMinSamples, "min-samples", 0, "Responses an endpoint needs for a baseline (default: anomalies.min_samples)")
+	trafficAnomaliesCmd.Flags().IntVarP(&trafficAnomalyLimit, "limit", "l", 50, "Maximum number of anomalies to list (0 for all)")
+	trafficAnomaliesCmd.Flags().BoolVar(&trafficAnomalyJSON, "json", false, "Output the report as JSON")
+	registerFlagCompletion(trafficAnomaliesCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficAnomaliesCmd, "host", completeDomainNames)
+
+	// Redirect params flags
+	trafficRedirectParamsCmd.Flags().Int64VarP(&trafficRedirectTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficRedirectParamsCmd.Flags().StringVar(&trafficRedirectHost, "host", "", "Only scan parameters of this host")
+	trafficRedirectParamsCmd.Flags().BoolVar(&trafficRedirectScan, "scan", false, "File the candidates as Draft findings")
+	trafficRedirectParamsCmd.Flags().BoolVar(&trafficRedirectTest, "test", false, "Test GET redirect candidates with the marker domain before filing (implies --scan)")
+	trafficRedirectParamsCmd.Flags().StringVar(&trafficRedirectMinConfidence, "min-confidence", "medium", "Lowest confidence filed as a finding (low, medium, high)")
+	trafficRedirectParamsCmd.Flags().IntVar(&trafficRedirectMaxTests, "max-tests", 0, "Maximum number of parameters to test (default: redirect_check.max_tests)")
+	trafficRedirectParamsCmd.Flags().BoolVar(&trafficRedirectJSON, "json", false, "Output the candidates as JSON")
+	registerFlagCompletion(trafficRedirectParamsCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficRedirectParamsCmd, "host", completeDomainNames)
+	registerFlagCompletion(trafficRedirectParamsCmd, "min-confidence", cobra.FixedCompletions(models.Confidences, cobra.ShellCompDirectiveNoFileComp))
+
+	// Mine-params flags
+	trafficMineParamsCmd.Flags().Int64VarP(&trafficMineTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficMineParamsCmd.Flags().Int64SliceVar(&trafficMineLogIDs, "log-id", nil, "Only mine these log entries (repeatable or comma-separated)")
+	trafficMineParamsCmd.Flags().StringVar(&trafficMineHost, "host", "", "Only mine endpoints of this host")
+	trafficMineParamsCmd.Flags().IntVar(&trafficMineMax, "max", 0, "Maximum number of endpoints to mine (default: param_mining.max_endpoints)")
+	trafficMineParamsCmd.Flags().StringSliceVar(&trafficMineWords, "word", nil, "Extra parameter name (repeatable)")
+	trafficMineParamsCmd.Flags().StringVar(&trafficMineWordlist, "wordlist-id", "", "Stored wordlist (ID or name) whose words are added")
+	trafficMineParamsCmd.Flags().BoolVar(&trafficMineWordsOnly, "words-only", false, "Use only --word/--wordlist-id, not the configured or built-in list")
+	trafficMineParamsCmd.Flags().IntVar(&trafficMineChunkSize, "chunk-size", 0, "Parameter names per request (default: param_mining.chunk_size)")
+	registerFlagCompletion(trafficMineParamsCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficMineParamsCmd, "host", completeDomainNames)
+
+	// Request generation flags
+	trafficGenerateRequestsCmd.Flags().Int64VarP(&trafficGenTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenFormat, "format", models.RequestGenerationModifier, "Output: modifier (tasks) or ffuf (commands)")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenHost, "host", "", "Only endpoints of this host")
+	trafficGenerateRequestsCmd.Flags().StringSliceVar(&trafficGenMethods, "method", nil, "Only endpoints with these methods (repeatable or comma-separated)")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenPath, "path", "", "Only endpoints whose path contains this")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenSource, "source", "", "Only parameters from traffic or param_mining")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenNameTemplate, "name-template", "", "Task names from {method}, {host}, {path} and {params} (default \"{method} {host}{path}\")")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenValueTemplate, "value-template", "", "Parameter values from {name} and {value} (default: captured values, FUZZ for ffuf)")
+	trafficGenerateRequestsCmd.Flags().Int64Var(&trafficGenCollectionID, "collection-id", 0, "File the tasks under this existing collection")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenCollection, "collection", "", "Name of the new collection (default: Generated <time>)")
+	trafficGenerateRequestsCmd.Flags().StringVar(&trafficGenWordlist, "wordlist-id", "", "Stored wordlist (ID or name) for ffuf (default: {{target_wordlist}})")
+	trafficGenerateRequestsCmd.Flags().BoolVar(&trafficGenAll, "all", false, "Also endpoints that already have a generated task")
+	trafficGenerateRequestsCmd.Flags().IntVar(&trafficGenMax, "max", 0, "Maximum number of requests to generate (default 500)")
+	trafficGenerateRequestsCmd.Flags().BoolVar(&trafficGenDryRun, "dry-run", false, "Show the tasks without creating them")
+	registerFlagCompletion(trafficGenerateRequestsCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(trafficGenerateRequestsCmd, "host", completeDomainNames)
+
+	// Rate profile flags
+	trafficRateProfileCmd.Flags().Int64VarP(&trafficRateTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficRateProfileCmd.Flags().StringVar(&trafficRateURL, "url", "", "Profile the host of this URL with GET requests")
+	trafficRateProfileCmd.Flags().Int64Var(&trafficRateLogID, "log-id", 0, "Profile by resending this captured request")
+	trafficRateProfileCmd.Flags().Float64Var(&trafficRateStart, "start-rps", 0, "Rate of the first burst (default: rate_profiler.start_rps)")
+	trafficRateProfileCmd.Flags().Float64Var(&trafficRateMax, "max-rps", 0, "Highest rate to test (default: rate_profiler.max_rps)")
+	trafficRateProfileCmd.Flags().IntVar(&trafficRateRequests, "requests", 0, "Requests per burst (default: rate_profiler.requests_per_step)")
+	trafficRateProfileCmd.Flags().StringVar(&trafficRateSet, "set-safe-rate", "", "Set a host's safe rate by hand, as HOST=RPS (0 clears it)")
+	trafficRateProfileCmd.Flags().StringVar(&trafficRateClear, "clear", "", "Remove the rate profile of this host")
+	trafficRateProfileCmd.Flags().BoolVar(&trafficRateAll, "all", false, "List the profiles of every host, not only the target's")
+	trafficRateProfileCmd.Flags().BoolVar(&trafficRateJSON, "json", false, "Output the profiles as JSON")
+	trafficRateProfileCmd.MarkFlagsMutuallyExclusive("url", "log-id", "set-safe-rate", "clear")
+	registerFlagCompletion(trafficRateProfileCmd, "target-id", completeTargetIDs)
+
+	// Dedupe-bodies flags
+	trafficDedupeBodiesCmd.Flags().BoolVar(&trafficDedupeVacuum, "vacuum", false, "Rebuild the database file afterwards so it shrinks")
+	trafficDedupeBodiesCmd.Flags().BoolVar(&trafficDedupeStats, "stats", false, "Only print how bodies are stored")
+	trafficDedupeBodiesCmd.MarkFlagsMutuallyExclusive("vacuum", "stats")
+
+	trafficBeautifyCmd.Flags().Int64VarP(&trafficBeautifyTargetID, "target-id", "t", 0, "Only beautify this target's responses")
+	registerFlagCompletion(trafficBeautifyCmd, "target-id", completeTargetIDs)
+
+	// Compare-env command flags
+	trafficCompareEnvCmd.Flags().Int64VarP(&trafficCompareTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficCompareEnvCmd.Flags().StringVar(&trafficCompareBaseURL, "base-url", "", "Environment to replay against, e.g. https://staging.example.com (required)")
+	trafficCompareEnvCmd.Flags().Int64SliceVar(&trafficCompareLogIDs, "log-ids", nil, "Replay these captured requests instead of one per endpoint")
+	trafficCompareEnvCmd.Flags().StringVar(&trafficCompareHost, "host", "", "Only endpoints of this host")
+	trafficCompareEnvCmd.Flags().StringVar(&trafficComparePathContains, "path-contains", "", "Only endpoints whose path contains this text")
+	trafficCompareEnvCmd.Flags().IntVar(&trafficCompareMax, "max-endpoints", 100, "Replay at most this many endpoints")
+	trafficCompareEnvCmd.Flags().Int64Var(&trafficCompareSessionID, "session-id", 0, "Replay session whose headers (auth) to send")
+	trafficCompareEnvCmd.Flags().IntVar(&trafficCompareDelay, "delay-ms", 0, "Wait this long between requests")
+	trafficCompareEnvCmd.Flags().BoolVar(&trafficCompareJSON, "json", false, "Output the comparison as JSON")
+	trafficCompareEnvCmd.MarkFlagRequired("base-url")
+	registerFlagCompletion(trafficCompareEnvCmd, "target-id", completeTargetIDs)
+
+	// Enumerate command flags
+	trafficEnumerateCmd.Flags().Int64VarP(&trafficEnumTargetID, "target-id", "t", 0, "ID of the target (uses current target if not specified)")
+	trafficEnumerateCmd.Flags().StringVar(&trafficEnumURL, "url", "", "URL template with {name} parameters, e.g. /api/users/{id}/orders/{orderId} (required)")
+	trafficEnumerateCmd.Flags().StringVarP(&trafficEnumMethod, "method", "X", "", "HTTP method (default the base request's, or GET)")
+	trafficEnumerateCmd.Flags().Int64Var(&trafficEnumLogID, "log-id", 0, "Base request whose host, headers and body the requests reuse")
+	trafficEnumerateCmd.Flags().StringArrayVar(&trafficEnumValues, "values", nil, "Values of a parameter, as name=value,value (repeatable)")
+	trafficEnumerateCmd.Flags().StringArrayVar(&trafficEnumRanges, "range", nil, "Integer values of a parameter, as name=from-to (repeatable)")
+	trafficEnumerateCmd.Flags().StringArrayVar(&trafficEnumWordlists, "wordlist", nil, "Wordlist (ID or name) of a parameter, as name=wordlist (repeatable)")
+	trafficEnumerateCmd.Flags().BoolVar(&trafficEnumZip, "zip", false, "Pair the parameters' values in order instead of sending every combination")
+	trafficEnumerateCmd.Flags().Int64SliceVar(&trafficEnumSessionIDs, "session-ids", nil, "Replay sessions to send the requests as (default the target's)")
+	trafficEnumerateCmd.Flags().IntVar(&trafficEnumDelay, "delay-ms", 0, "Wait this long between requests")
+	trafficEnumerateCmd.Flags().BoolVar(&trafficEnumDryRun, "dry-run", false, "Only print the generated requests")
+	trafficEnumerateCmd.Flags().BoolVar(&trafficEnumJSON, "json", false, "Output the report as JSON")
+	trafficEnumerateCmd.MarkFlagRequired("url")
+	registerFlagCompletion(trafficEnumerateCmd, "target-id", completeTargetIDs)
+
+	// Diff flags
+	trafficDiffCmd.Flags().BoolVar(&trafficDiffJSON, "json", false, "Output the diff as JSON")
+	trafficDiffCmd.Flags().IntVarP(&trafficDiffContext, "context", "C", 3, "Number of unchanged lines to show around each change in text bodies")
+	trafficDiffCmd.Flags().BoolVarP(&trafficDiffBeautify, "beautify", "b", false, "Compare the beautified copies of the response bodies (for minified JavaScript and HTML)")
 
 	// Add subcommands to the base traffic command
 	trafficCmd.AddCommand(trafficListCmd)
+	trafficCmd.AddCommand(trafficTailCmd)
+	trafficCmd.AddCommand(trafficViewsCmd)
 	trafficCmd.AddCommand(trafficMapCmd)
 	trafficCmd.AddCommand(trafficListMappedCmd)
 	trafficCmd.AddCommand(trafficGetCmd)
 	trafficCmd.AddCommand(trafficAnalyzeCmd)
+	trafficCmd.AddCommand(trafficAnalyzeAllCmd)
 	trafficCmd.AddCommand(trafficPurgeCmd)
+	trafficCmd.AddCommand(trafficDiffCmd)
+	trafficCmd.AddCommand(trafficExportCmd)
+	trafficCmd.AddCommand(trafficSendToCmd)
+	trafficCmd.AddCommand(trafficPromoteCmd)
+	trafficCmd.AddCommand(trafficSessionsCmd)
+	trafficCmd.AddCommand(trafficLoginFlowCmd)
+	trafficCmd.AddCommand(trafficTokensCmd)
+	trafficCmd.AddCommand(trafficHeadersCmd)
+	trafficCmd.AddCommand(trafficTLSCmd)
+	trafficCmd.AddCommand(trafficCORSCheckCmd)
+	trafficCmd.AddCommand(trafficRedirectParamsCmd)
+	trafficCmd.AddCommand(trafficMineParamsCmd)
+	trafficCmd.AddCommand(trafficGenerateRequestsCmd)
+	trafficCmd.AddCommand(trafficIDORCmd)
+	trafficCmd.AddCommand(trafficAuthSchemesCmd)
+	trafficCmd.AddCommand(trafficAnomaliesCmd)
+	trafficCmd.AddCommand(trafficRateProfileCmd)
+	trafficCmd.AddCommand(trafficDedupeBodiesCmd)
+	trafficCmd.AddCommand(trafficBeautifyCmd)
+	trafficCmd.AddCommand(trafficCompareEnvCmd)
+	trafficCmd.AddCommand(trafficEnumerateCmd)
 
 	// Add the base traffic command to the root command
 	rootCmd.AddCommand(trafficCmd)
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"time"
+	"toolkit/tui"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tuiTargetID int64
+	tuiLimit    int
+	tuiInterval time.Duration
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Interactive terminal UI for live traffic",
+	Long: `Opens a full-screen terminal UI showing the traffic the proxy captures for a target.
+
+The top pane lists the newest requests and refreshes while the proxy runs (start it with
+'toolkit start' or 'toolkit proxy start' in another terminal). The bottom pane shows the
+selected request and response, or the scope of the target.
+
+Keys:
+  ↑/↓ j/k PgUp/PgDn Home/End  Move in the focused pane
+  Enter / Tab / Esc           Focus the detail pane / toggle focus / go back
+  /  F                        Filter by method, URL or status / show favorites only
+  f  a  m                     Favorite, tag, or send the selected request to the Modifier
+  t  s                        Switch the proxy's current target / show the scope
+  r  q                        Refresh / quit`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tui.Run(tui.Options{
+			TargetID:     tuiTargetID,
+			Limit:        tuiLimit,
+			PollInterval: tuiInterval,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	tuiCmd.Flags().Int64Var(&tuiTargetID, "target-id", 0, "Target to show (defaults to the proxy's current target)")
+	tuiCmd.Flags().IntVar(&tuiLimit, "limit", 500, "Newest requests kept in the traffic pane")
+	tuiCmd.Flags().DurationVar(&tuiInterval, "interval", time.Second, "How often new traffic is loaded")
+	registerFlagCompletion(tuiCmd, "target-id", completeTargetIDs)
+	rootCmd.AddCommand(tuiCmd)
+}
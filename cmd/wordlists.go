@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"toolkit/core"
+	"toolkit/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	wordlistsListTargetID int64
+	wordlistsListSource   string
+	wordlistsListTag      string
+
+	wordlistsAddName        string
+	wordlistsAddDescription string
+	wordlistsAddTargetID    int64
+	wordlistsAddDedupe      bool
+
+	wordlistsShowOutput string
+
+	wordlistsDedupeIgnoreCase bool
+
+	wordlistsMergeName        string
+	wordlistsMergeDescription string
+	wordlistsMergeDedupe      bool
+	wordlistsMergeIgnoreCase  bool
+
+	wordlistsGenerateTargetID int64
+	wordlistsGenerateName     string
+	wordlistsGenerateKinds    []string
+	wordlistsGenerateMinCount int
+	wordlistsGenerateMaxWords int
+
+	wordlistsTagRemove bool
+)
+
+var wordlistsCmd = &cobra.Command{
+	Use:     "wordlists",
+	Short:   "Manage wordlists for brute-forcing and fuzzing",
+	Aliases: []string{"wordlist"},
+	Long: `Stores wordlists in wordlists.dir, one word per line, with their metadata in the database.
+Stored wordlists can be tagged, deduplicated and merged, generated from a target's captured traffic,
+and used by checklist commands through the {{wordlist_<id>}} and {{target_wordlist}} placeholders and
+by 'toolkit domains permute --wordlist-id'. Wordlists are given by ID or name.`,
+}
+
+var wordlistsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the stored wordlists",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		wordlists, err := core.GetWordlists(models.WordlistFilters{TargetID: wordlistsListTargetID, Source: wordlistsListSource, Tag: wordlistsListTag})
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(wordlists) == 0 {
+			fmt.Println("No wordlists found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tWORDS\tSIZE\tSOURCE\tTARGET\tTAGS\tUPDATED")
+		for _, l := range wordlists {
+			target := "-"
+			if l.TargetID.Valid {
+				target = strconv.FormatInt(l.TargetID.Int64, 10)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n", l.ID, l.Name, l.WordCount, l.Size, l.Source, target,
+				strings.Join(l.Tags, ","), l.UpdatedAt.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
+var wordlistsAddCmd = &cobra.Command{
+	Use:   "add FILE",
+	Short: "Store a wordlist from a file ('-' for stdin)",
+	Long: `Stores a wordlist, one word per line. Lines are trimmed and empty lines dropped; --dedupe drops
+repeated words as well. The name defaults to the file name without its extension.`,
+	Example: `  toolkit wordlists add ~/SecLists/Discovery/Web-Content/raft-small-words.txt --dedupe
+  cat words.txt | toolkit wordlists add - --name api-words --target-id 3`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := wordlistsAddName
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+			defer f.Close()
+			in = f
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+			}
+		}
+		wordlist, err := core.CreateWordlist(name, wordlistsAddDescription, wordlistsAddTargetID, wordlistsAddDedupe, in)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Stored wordlist %d '%s' (%d words, %d bytes) in %s.\n", wordlist.ID, wordlist.Name, wordlist.WordCount, wordlist.Size, wordlist.Path)
+	},
+}
+
+var wordlistsShowCmd = &cobra.Command{
+	Use:   "show WORDLIST",
+	Short: "Print the words of a wordlist, or save them with --output",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		wordlist, file, err := core.OpenWordlist(resolveWordlistID(args[0]))
+		if err != nil {
+			exitWithError(err)
+		}
+		defer file.Close()
+		if wordlistsShowOutput == "" {
+			io.Copy(os.Stdout, file)
+			return
+		}
+		out, err := os.OpenFile(wordlistsShowOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+		if err != nil {
+			exitWithError(err)
+		}
+		if _, err := io.Copy(out, file); err != nil {
+			out.Close()
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", wordlistsShowOutput, err)
+			os.Exit(1)
+		}
+		if err := out.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", wordlistsShowOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved wordlist %d (%d words) to %s.\n", wordlist.ID, wordlist.WordCount, wordlistsShowOutput)
+	},
+}
+
+var wordlistsRemoveCmd = &cobra.Command{
+	Use:     "rm WORDLIST...",
+	Short:   "Delete wordlists and their files",
+	Aliases: []string{"delete"},
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := false
+		for _, arg := range args {
+			id := resolveWordlistID(arg)
+			if err := core.DeleteWordlist(id); err != nil {
+				fmt.Fprintf(os.Stderr, "Error deleting wordlist %s: %v\n", arg, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Deleted wordlist %d.\n", id)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var wordlistsTagCmd = &cobra.Command{
+	Use:   "tag WORDLIST TAG...",
+	Short: "Tag a wordlist, creating missing tags (--remove to untag)",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wordlist, err := core.TagWordlist(resolveWordlistID(args[0]), args[1:], wordlistsTagRemove)
+		if err != nil {
+			exitWithError(err)
+		}
+		tags := strings.Join(wordlist.Tags, ", ")
+		if tags == "" {
+			tags = "none"
+		}
+		fmt.Printf("Wordlist %d '%s' tags: %s\n", wordlist.ID, wordlist.Name, tags)
+	},
+}
+
+var wordlistsDedupeCmd = &cobra.Command{
+	Use:   "dedupe WORDLIST",
+	Short: "Remove repeated words from a wordlist, keeping the first of each",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := core.DedupeWordlist(resolveWordlistID(args[0]), wordlistsDedupeIgnoreCase)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Removed %d repeated word(s) from wordlist %d '%s' (%d words left).\n", result.Removed, result.Wordlist.ID,
+			result.Wordlist.Name, result.Wordlist.WordCount)
+	},
+}
+
+var wordlistsMergeCmd = &cobra.Command{
+	Use:     "merge WORDLIST WORDLIST...",
+	Short:   "Combine wordlists into a new wordlist",
+	Example: `  toolkit wordlists merge raft-small-words acme-generated --name acme-dirs`,
+	Args:    cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := models.WordlistMergeRequest{
+			Name:            wordlistsMergeName,
+			Description:     wordlistsMergeDescription,
+			Dedupe:          wordlistsMergeDedupe,
+			CaseInsensitive: wordlistsMergeIgnoreCase,
+		}
+		for _, arg := range args {
+			req.IDs = append(req.IDs, resolveWordlistID(arg))
+		}
+		wordlist, err := core.MergeWordlists(req)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Stored wordlist %d '%s' (%d words) in %s.\n", wordlist.ID, wordlist.Name, wordlist.WordCount, wordlist.Path)
+	},
+}
+
+var wordlistsGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a wordlist from a target's captured paths, parameters and JavaScript endpoints",
+	Long: `Builds a wordlist from what was learned about a target: the paths, path segments and query
+parameter names of its captured requests, discovered URLs and API endpoints (--kind paths, segments,
+params), and the paths and parameters of URLs found in its JavaScript (--kind js). Words are ordered by
+how often they were seen. Running it again replaces the generated wordlist of the same name, which
+defaults to <codename>-generated.`,
+	Example: `  toolkit wordlists generate
+  toolkit wordlists generate --kind params --name acme-params --min-count 2`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetID := flagOrCurrentTargetID(cmd, wordlistsGenerateTargetID)
+		wordlist, err := core.GenerateTargetWordlist(targetID, models.WordlistGenerateRequest{
+			Name:     wordlistsGenerateName,
+			Kinds:    wordlistsGenerateKinds,
+			MinCount: wordlistsGenerateMinCount,
+			MaxWords: wordlistsGenerateMaxWords,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Generated wordlist %d '%s' (%d words) in %s.\n", wordlist.ID, wordlist.Name, wordlist.WordCount, wordlist.Path)
+	},
+}
+
+// resolveWordlistID returns the ID of a wordlist given by ID or name, and exits when there is none.
+func resolveWordlistID(arg string) int64 {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return id
+	}
+	wordlist, err := core.GetWordlistByName(arg)
+	if err != nil {
+		exitWithError(err)
+	}
+	return wordlist.ID
+}
+
+func init() {
+	wordlistsListCmd.Flags().Int64Var(&wordlistsListTargetID, "target-id", 0, "Only wordlists of this target")
+	wordlistsListCmd.Flags().StringVar(&wordlistsListSource, "source", "", "Only wordlists from this source: upload, merge or generated")
+	wordlistsListCmd.Flags().StringVar(&wordlistsListTag, "tag", "", "Only wordlists with this tag")
+	registerFlagCompletion(wordlistsListCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(wordlistsListCmd, "source", cobra.FixedCompletions([]string{models.WordlistSourceUpload, models.WordlistSourceMerge,
+		models.WordlistSourceGenerated}, cobra.ShellCompDirectiveNoFileComp))
+
+	wordlistsAddCmd.Flags().StringVarP(&wordlistsAddName, "name", "n", "", "Name of the wordlist (default: the file name)")
+	wordlistsAddCmd.Flags().StringVarP(&wordlistsAddDescription, "description", "d", "", "Description of the wordlist")
+	wordlistsAddCmd.Flags().Int64Var(&wordlistsAddTargetID, "target-id", 0, "Target the wordlist belongs to (default: none)")
+	wordlistsAddCmd.Flags().BoolVar(&wordlistsAddDedupe, "dedupe", false, "Drop repeated words")
+	registerFlagCompletion(wordlistsAddCmd, "target-id", completeTargetIDs)
+
+	wordlistsShowCmd.Flags().StringVarP(&wordlistsShowOutput, "output", "o", "", "File to save the words to instead of printing them")
+
+	wordlistsTagCmd.Flags().BoolVar(&wordlistsTagRemove, "remove", false, "Remove the tags instead of adding them")
+
+	wordlistsDedupeCmd.Flags().BoolVarP(&wordlistsDedupeIgnoreCase, "ignore-case", "i", false, "Treat words differing only in case as repeats")
+
+	wordlistsMergeCmd.Flags().StringVarP(&wordlistsMergeName, "name", "n", "", "Name of the new wordlist (required)")
+	wordlistsMergeCmd.Flags().StringVarP(&wordlistsMergeDescription, "description", "d", "", "Description of the new wordlist")
+	wordlistsMergeCmd.Flags().BoolVar(&wordlistsMergeDedupe, "dedupe", true, "Drop repeated words")
+	wordlistsMergeCmd.Flags().BoolVarP(&wordlistsMergeIgnoreCase, "ignore-case", "i", false, "With --dedupe, treat words differing only in case as repeats")
+	wordlistsMergeCmd.MarkFlagRequired("name")
+
+	wordlistsGenerateCmd.Flags().Int64Var(&wordlistsGenerateTargetID, "target-id", 0, "Target to generate for (defaults to the current target)")
+	wordlistsGenerateCmd.Flags().StringVarP(&wordlistsGenerateName, "name", "n", "", "Name of the wordlist (default: <codename>-generated)")
+	wordlistsGenerateCmd.Flags().StringSliceVar(&wordlistsGenerateKinds, "kind", nil, "Kinds of words: paths, segments, params, js (repeatable; default: all)")
+	wordlistsGenerateCmd.Flags().IntVar(&wordlistsGenerateMinCount, "min-count", 1, "Only words seen at least this often")
+	wordlistsGenerateCmd.Flags().IntVar(&wordlistsGenerateMaxWords, "max-words", 0, "Keep only the most frequent words (0 for all)")
+	registerFlagCompletion(wordlistsGenerateCmd, "target-id", completeTargetIDs)
+	registerFlagCompletion(wordlistsGenerateCmd, "kind", cobra.FixedCompletions(models.WordlistKinds, cobra.ShellCompDirectiveNoFileComp))
+
+	wordlistsCmd.AddCommand(wordlistsListCmd)
+	wordlistsCmd.AddCommand(wordlistsAddCmd)
+	wordlistsCmd.AddCommand(wordlistsShowCmd)
+	wordlistsCmd.AddCommand(wordlistsRemoveCmd)
+	wordlistsCmd.AddCommand(wordlistsTagCmd)
+	wordlistsCmd.AddCommand(wordlistsDedupeCmd)
+	wordlistsCmd.AddCommand(wordlistsMergeCmd)
+	wordlistsCmd.AddCommand(wordlistsGenerateCmd)
+	rootCmd.AddCommand(wordlistsCmd)
+}
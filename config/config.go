@@ -32,16 +32,459 @@ type DatabaseConfig struct {
 type ServerConfig struct {
 	Port    string `mapstructure:"port" yaml:"port"`
 	LogPath string `mapstructure:"log_path" yaml:"log_path"`
+	// ReadOnly refuses every API request that would change data and stops proxy logging, e.g. while
+	// screen sharing or giving a teammate view access. Also set by the --read-only flag.
+	ReadOnly bool `mapstructure:"read_only" yaml:"read_only"`
 }
 
 // ProxyConfig holds proxy related configuration.
 type ProxyConfig struct {
-	Port                  string `mapstructure:"port" yaml:"port"`
-	CACertPath            string `mapstructure:"ca_cert_path" yaml:"ca_cert_path"`
-	CAKeyPath             string `mapstructure:"ca_key_path" yaml:"ca_key_path"`
-	LogPath               string `mapstructure:"log_path" yaml:"log_path"`
-	ModifierSkipTLSVerify bool   `mapstructure:"modifier_skip_tls_verify" yaml:"modifier_skip_tls_verify"`
-	ModifierAllowLoopback bool   `mapstructure:"modifier_allow_loopback" yaml:"modifier_allow_loopback"`
+	Port                    string `mapstructure:"port" yaml:"port"`
+	CACertPath              string `mapstructure:"ca_cert_path" yaml:"ca_cert_path"`
+	CAKeyPath               string `mapstructure:"ca_key_path" yaml:"ca_key_path"`
+	LogPath                 string `mapstructure:"log_path" yaml:"log_path"`
+	ModifierSkipTLSVerify   bool   `mapstructure:"modifier_skip_tls_verify" yaml:"modifier_skip_tls_verify"`
+	ModifierAllowLoopback   bool   `mapstructure:"modifier_allow_loopback" yaml:"modifier_allow_loopback"`
+	CAKeyType               string `mapstructure:"ca_key_type" yaml:"ca_key_type"`                                 // Key type of CAs generated by 'proxy init-ca' and 'proxy rotate-ca': rsa or ecdsa
+	LeafCertCacheTTLMinutes int    `mapstructure:"leaf_cert_cache_ttl_minutes" yaml:"leaf_cert_cache_ttl_minutes"` // How long a signed per-host certificate is reused
+	LeafCertCacheSize       int    `mapstructure:"leaf_cert_cache_size" yaml:"leaf_cert_cache_size"`               // Hosts kept in the certificate cache
+	SOCKS5Port              string `mapstructure:"socks5_port" yaml:"socks5_port"`                                 // SOCKS5 listener for clients that ignore HTTP proxy settings (empty = disabled)
+	TransparentPort         string `mapstructure:"transparent_port" yaml:"transparent_port"`                       // Listener for iptables/pf-redirected traffic (empty = disabled)
+	MaxCaptureBytes         int64  `mapstructure:"max_capture_bytes" yaml:"max_capture_bytes"`                     // Response body bytes stored per request; the rest is streamed through unstored (0 = unlimited)
+	PerformanceMode         bool   `mapstructure:"performance_mode" yaml:"performance_mode"`                       // Log only a sample of out-of-scope and static-asset traffic
+	SampleRate              int    `mapstructure:"sample_rate" yaml:"sample_rate"`                                 // In performance mode, 1 in this many sampled requests is logged
+	UpstreamProxy           string `mapstructure:"upstream_proxy" yaml:"upstream_proxy"`                           // HTTP(S) or SOCKS5 proxy the MITM proxy sends its traffic through (empty = direct)
+	ScriptMaxBodyBytes      int64  `mapstructure:"script_max_body_bytes" yaml:"script_max_body_bytes"`             // Response bodies up to this size are passed to proxy scripts; larger ones stream through unchanged
+}
+
+// RateLimitConfig holds throttling settings applied to toolkit-initiated requests
+// (replays, httpx/subfinder runs) and, optionally, to passthrough proxy traffic.
+type RateLimitConfig struct {
+	Enabled            bool    `mapstructure:"enabled" yaml:"enabled"`
+	RequestsPerSecond  float64 `mapstructure:"requests_per_second" yaml:"requests_per_second"`   // Per-host request rate
+	Burst              int     `mapstructure:"burst" yaml:"burst"`                               // Per-host burst size
+	MaxConcurrency     int     `mapstructure:"max_concurrency" yaml:"max_concurrency"`           // Global cap on in-flight requests (0 = unlimited)
+	PerHostConcurrency int     `mapstructure:"per_host_concurrency" yaml:"per_host_concurrency"` // Cap on in-flight requests per host (0 = unlimited)
+	ApplyToProxy       bool    `mapstructure:"apply_to_proxy" yaml:"apply_to_proxy"`             // Also throttle browser traffic passing through the proxy
+	MaxRetries         int     `mapstructure:"max_retries" yaml:"max_retries"`                   // Retries after a 429 response
+	BackoffInitialMs   int     `mapstructure:"backoff_initial_ms" yaml:"backoff_initial_ms"`     // First backoff delay when no Retry-After is given
+	BackoffMaxMs       int     `mapstructure:"backoff_max_ms" yaml:"backoff_max_ms"`             // Upper bound for backoff delays
+}
+
+// ResponseCacheConfig holds settings for the response cache, which answers a toolkit-initiated
+// request repeated within the window with the response logged for it instead of sending it again.
+type ResponseCacheConfig struct {
+	Enabled       bool     `mapstructure:"enabled" yaml:"enabled"`
+	WindowSeconds int      `mapstructure:"window_seconds" yaml:"window_seconds"` // How long a logged response is reused
+	Methods       []string `mapstructure:"methods" yaml:"methods"`               // Request methods whose responses are reused
+}
+
+// SecretRuleConfig is a user-defined secret detection rule.
+type SecretRuleConfig struct {
+	Name        string   `mapstructure:"name" yaml:"name"`
+	Regex       string   `mapstructure:"regex" yaml:"regex"`
+	SecretGroup int      `mapstructure:"secret_group" yaml:"secret_group"` // Capture group holding the secret (0 = whole match)
+	MinEntropy  float64  `mapstructure:"min_entropy" yaml:"min_entropy"`   // Shannon entropy threshold for the secret (0 = none)
+	Keywords    []string `mapstructure:"keywords" yaml:"keywords"`         // Body must contain one of these (case-insensitive) before the regex runs
+}
+
+// SecretsConfig holds settings for the secret/API key scanner run on logged bodies.
+type SecretsConfig struct {
+	Enabled         bool               `mapstructure:"enabled" yaml:"enabled"`
+	MaxBodyBytes    int                `mapstructure:"max_body_bytes" yaml:"max_body_bytes"`       // Bodies larger than this are scanned only up to this size
+	DisabledRules   []string           `mapstructure:"disabled_rules" yaml:"disabled_rules"`       // Names of built-in rules to skip
+	CustomRules     []SecretRuleConfig `mapstructure:"custom_rules" yaml:"custom_rules"`           // Additional rules
+	ScanRequestBody bool               `mapstructure:"scan_request_body" yaml:"scan_request_body"` // Also scan request bodies
+}
+
+// JSAnalysisConfig holds settings for the JavaScript analysis pipeline.
+type JSAnalysisConfig struct {
+	AutoAnalyze       bool `mapstructure:"auto_analyze" yaml:"auto_analyze"`                 // Run the pipeline on every JavaScript response logged by the proxy
+	FetchSourceMaps   bool `mapstructure:"fetch_source_maps" yaml:"fetch_source_maps"`       // Fetch external source maps referenced by analyzed files
+	MaxSourceMapBytes int  `mapstructure:"max_source_map_bytes" yaml:"max_source_map_bytes"` // Source maps larger than this are ignored
+	SkipVendorSources bool `mapstructure:"skip_vendor_sources" yaml:"skip_vendor_sources"`   // Skip node_modules and bundler runtime sources in source maps
+}
+
+// GraphQLConfig holds settings for GraphQL operation capture.
+type GraphQLConfig struct {
+	CaptureOperations bool `mapstructure:"capture_operations" yaml:"capture_operations"` // Parse GraphQL operations out of proxied requests
+}
+
+// HttpxConfig holds settings for httpx scans of domains.
+type HttpxConfig struct {
+	ChunkSize      int `mapstructure:"chunk_size" yaml:"chunk_size"`             // Domains passed to one httpx process
+	MaxRetries     int `mapstructure:"max_retries" yaml:"max_retries"`           // Retries of a chunk whose httpx process failed, for the domains without a result
+	RetryBackoffMs int `mapstructure:"retry_backoff_ms" yaml:"retry_backoff_ms"` // Delay before the first retry, doubled for each further retry
+	Threads        int `mapstructure:"threads" yaml:"threads"`                   // httpx -threads (capped by rate_limit.max_concurrency)
+	TimeoutMinutes int `mapstructure:"timeout_minutes" yaml:"timeout_minutes"`   // A scan is stopped after this long
+}
+
+// IPAssetsConfig holds settings for the IP/CIDR asset inventory.
+type IPAssetsConfig struct {
+	ResolveAfterDiscovery bool `mapstructure:"resolve_after_discovery" yaml:"resolve_after_discovery"` // Resolve a target's domains into IP assets after subdomain discovery finds new ones
+	Enrich                bool `mapstructure:"enrich" yaml:"enrich"`                                   // Reverse DNS and ASN lookups (Team Cymru DNS) for new IP assets
+	ResolverWorkers       int  `mapstructure:"resolver_workers" yaml:"resolver_workers"`               // Concurrent DNS lookups
+	LookupTimeoutSeconds  int  `mapstructure:"lookup_timeout_seconds" yaml:"lookup_timeout_seconds"`   // Timeout of one DNS lookup
+}
+
+// DNSConfig holds the resolvers of the recon DNS lookups (IP asset resolution and enrichment,
+// domain enrichment, permutation, takeover checks). Without resolvers the system resolver is used.
+type DNSConfig struct {
+	Resolvers                  []string `mapstructure:"resolvers" yaml:"resolvers"`                                         // 1.1.1.1, 8.8.8.8:53, tcp://9.9.9.9, tls://1.1.1.1 (DoT) or https://dns.google/dns-query (DoH)
+	ResolversFile              string   `mapstructure:"resolvers_file" yaml:"resolvers_file"`                               // File of further resolvers, one per line
+	QueriesPerSecond           float64  `mapstructure:"queries_per_second" yaml:"queries_per_second"`                       // Per resolver (0 = unlimited)
+	TimeoutSeconds             int      `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`                             // Connection and DoH request timeout
+	MaxFailures                int      `mapstructure:"max_failures" yaml:"max_failures"`                                   // Consecutive failures before a resolver is skipped
+	CooldownSeconds            int      `mapstructure:"cooldown_seconds" yaml:"cooldown_seconds"`                           // How long a failing resolver is skipped
+	HealthCheckIntervalSeconds int      `mapstructure:"health_check_interval_seconds" yaml:"health_check_interval_seconds"` // 0 disables periodic health checks
+	HealthCheckDomain          string   `mapstructure:"health_check_domain" yaml:"health_check_domain"`                     // Name every resolver must resolve
+}
+
+// PermutationConfig holds settings for permutation-based subdomain generation.
+type PermutationConfig struct {
+	Wordlist             string `mapstructure:"wordlist" yaml:"wordlist"`                             // File of words (one per line) used instead of the built-in list
+	Workers              int    `mapstructure:"workers" yaml:"workers"`                               // Concurrent DNS lookups
+	LookupTimeoutSeconds int    `mapstructure:"lookup_timeout_seconds" yaml:"lookup_timeout_seconds"` // Timeout of one DNS lookup
+	MaxCandidates        int    `mapstructure:"max_candidates" yaml:"max_candidates"`                 // Candidates resolved per job
+}
+
+// CTWatchConfig holds settings for the certificate transparency watcher, which looks up newly
+// issued certificates for the wildcard scope rules of every target.
+type CTWatchConfig struct {
+	Enabled             bool     `mapstructure:"enabled" yaml:"enabled"`
+	PollIntervalMinutes int      `mapstructure:"poll_interval_minutes" yaml:"poll_interval_minutes"` // Minimum 15
+	CrtshURL            string   `mapstructure:"crtsh_url" yaml:"crtsh_url"`                         // crt.sh base URL
+	TimeoutSeconds      int      `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`             // crt.sh is slow for large domains
+	MaxRetries          int      `mapstructure:"max_retries" yaml:"max_retries"`                     // Retries of a failed crt.sh request
+	InterestingKeywords []string `mapstructure:"interesting_keywords" yaml:"interesting_keywords"`   // New names containing one of these get their own notification
+}
+
+// EnrichmentConfig holds settings for favicon hashing and Shodan/Censys lookups of domains.
+type EnrichmentConfig struct {
+	Workers         int          `mapstructure:"workers" yaml:"workers"`                     // Domains enriched concurrently
+	TimeoutSeconds  int          `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`     // Timeout of one favicon fetch or API request
+	MaxRelatedHosts int          `mapstructure:"max_related_hosts" yaml:"max_related_hosts"` // Hosts kept from a favicon hash search
+	Shodan          ShodanConfig `mapstructure:"shodan" yaml:"shodan"`
+	Censys          CensysConfig `mapstructure:"censys" yaml:"censys"`
+}
+
+// ShodanConfig holds the Shodan API settings. Lookups are skipped without an API key.
+type ShodanConfig struct {
+	APIKey        string `mapstructure:"api_key" yaml:"api_key"`
+	BaseURL       string `mapstructure:"base_url" yaml:"base_url"`
+	SearchFavicon bool   `mapstructure:"search_favicon" yaml:"search_favicon"` // Search http.favicon.hash for related hosts (uses query credits)
+}
+
+// CensysConfig holds the Censys Search API settings. Lookups are skipped without API credentials.
+type CensysConfig struct {
+	APIID     string `mapstructure:"api_id" yaml:"api_id"`
+	APISecret string `mapstructure:"api_secret" yaml:"api_secret"`
+	BaseURL   string `mapstructure:"base_url" yaml:"base_url"`
+}
+
+// TakeoverConfig holds settings for the subdomain takeover check.
+type TakeoverConfig struct {
+	Workers        int                         `mapstructure:"workers" yaml:"workers"`                 // Domains checked concurrently
+	TimeoutSeconds int                         `mapstructure:"timeout_seconds" yaml:"timeout_seconds"` // Timeout of one fingerprint request
+	Fingerprints   []TakeoverFingerprintConfig `mapstructure:"fingerprints" yaml:"fingerprints"`       // Checked before the built-in fingerprints
+}
+
+// TakeoverFingerprintConfig describes a service whose unclaimed resources can be taken over.
+type TakeoverFingerprintConfig struct {
+	Service      string   `mapstructure:"service" yaml:"service"`
+	CNAMEs       []string `mapstructure:"cnames" yaml:"cnames"`             // Substrings of the CNAME targets of the service
+	Fingerprints []string `mapstructure:"fingerprints" yaml:"fingerprints"` // Substrings of the response of an unclaimed resource
+	StatusCode   int      `mapstructure:"status_code" yaml:"status_code"`   // Required status of that response (0 = any)
+	NXDomain     bool     `mapstructure:"nxdomain" yaml:"nxdomain"`         // A CNAME target that does not resolve can be registered
+}
+
+// CloudStorageConfig holds settings for the S3/GCS/Azure bucket checker.
+type CloudStorageConfig struct {
+	Workers         int      `mapstructure:"workers" yaml:"workers"`                   // Buckets checked concurrently
+	TimeoutSeconds  int      `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`   // Timeout of one request
+	MaxCandidates   int      `mapstructure:"max_candidates" yaml:"max_candidates"`     // Candidate names derived per provider
+	Suffixes        []string `mapstructure:"suffixes" yaml:"suffixes"`                 // Appended to the target's names; empty uses the built-in list
+	AzureContainers []string `mapstructure:"azure_containers" yaml:"azure_containers"` // Containers tried on candidate Azure storage accounts
+}
+
+// VhostConfig holds settings for virtual host discovery on the target's IPs.
+type VhostConfig struct {
+	Workers        int   `mapstructure:"workers" yaml:"workers"`                 // Requests in flight
+	TimeoutSeconds int   `mapstructure:"timeout_seconds" yaml:"timeout_seconds"` // Timeout of one request
+	Ports          []int `mapstructure:"ports" yaml:"ports"`                     // Ports probed on each IP; 443 and 8443 use HTTPS
+	MaxCandidates  int   `mapstructure:"max_candidates" yaml:"max_candidates"`   // Host headers tried per IP and port
+}
+
+// HarvesterConfig holds settings for the robots.txt, sitemap.xml and security.txt harvester.
+type HarvesterConfig struct {
+	Workers        int `mapstructure:"workers" yaml:"workers"`                   // Domains harvested concurrently
+	TimeoutSeconds int `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`   // Timeout of one request
+	MaxSitemaps    int `mapstructure:"max_sitemaps" yaml:"max_sitemaps"`         // Sitemaps (including sitemap index children) fetched per domain
+	MaxSitemapURLs int `mapstructure:"max_sitemap_urls" yaml:"max_sitemap_urls"` // URLs kept from the sitemaps of one domain
+}
+
+// ResponseBaselineConfig holds settings for soft-404/wildcard detection of domains.
+type ResponseBaselineConfig struct {
+	Workers         int `mapstructure:"workers" yaml:"workers"`                     // Domains baselined concurrently
+	TimeoutSeconds  int `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`     // Timeout of one probe request
+	ProbeCount      int `mapstructure:"probe_count" yaml:"probe_count"`             // Random paths requested per domain
+	MaxDistance     int `mapstructure:"max_distance" yaml:"max_distance"`           // Simhash bits two bodies may differ by and still match
+	MinClusterPaths int `mapstructure:"min_cluster_paths" yaml:"min_cluster_paths"` // Top-level paths sharing one page before it counts as a wildcard page
+	MaxResponses    int `mapstructure:"max_responses" yaml:"max_responses"`         // Logged responses clustered per domain
+}
+
+// AnalysisConfig holds settings for batch analysis of stored traffic ('traffic analyze-all').
+type AnalysisConfig struct {
+	Analyzers []string `mapstructure:"analyzers" yaml:"analyzers"` // Analyzers run when none are requested: jsluice, secrets, comments, endpoints, buckets
+	Workers   int      `mapstructure:"workers" yaml:"workers"`     // Log entries analyzed concurrently
+}
+
+// URLCanonicalConfig holds settings for canonicalizing discovered URLs and traffic endpoints.
+type URLCanonicalConfig struct {
+	StripParams []string `mapstructure:"strip_params" yaml:"strip_params"` // Session and tracking parameters dropped from canonical URLs, case-insensitive; a trailing * matches a prefix (utm_*)
+}
+
+// CORSCheckConfig holds settings for the active CORS misconfiguration checker.
+type CORSCheckConfig struct {
+	AttackerDomain string `mapstructure:"attacker_domain" yaml:"attacker_domain"` // Domain used to build the crafted Origin headers
+	Workers        int    `mapstructure:"workers" yaml:"workers"`                 // Endpoints checked concurrently
+	TimeoutSeconds int    `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`
+	MaxEndpoints   int    `mapstructure:"max_endpoints" yaml:"max_endpoints"` // Endpoints checked per run when no log entries are given
+}
+
+// RedirectCheckConfig holds settings for the open redirect and SSRF parameter detector.
+type RedirectCheckConfig struct {
+	MarkerDomain   string `mapstructure:"marker_domain" yaml:"marker_domain"` // Harmless domain injected when testing redirects
+	Workers        int    `mapstructure:"workers" yaml:"workers"`             // Parameters tested concurrently
+	TimeoutSeconds int    `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`
+	MaxTests       int    `mapstructure:"max_tests" yaml:"max_tests"` // Parameters tested per run
+}
+
+// ParamMiningConfig holds settings for parameter mining, which probes captured endpoints with
+// batches of candidate parameter names.
+type ParamMiningConfig struct {
+	Wordlist       string `mapstructure:"wordlist" yaml:"wordlist"`               // File of parameter names (one per line) used instead of the built-in list
+	Workers        int    `mapstructure:"workers" yaml:"workers"`                 // Endpoints mined concurrently
+	TimeoutSeconds int    `mapstructure:"timeout_seconds" yaml:"timeout_seconds"` // Timeout of one request
+	ChunkSize      int    `mapstructure:"chunk_size" yaml:"chunk_size"`           // Parameter names sent per request
+	MaxEndpoints   int    `mapstructure:"max_endpoints" yaml:"max_endpoints"`     // Endpoints mined per run when no log entries are given
+}
+
+// IDORConfig holds settings for the IDOR worklist built from captured traffic.
+type IDORConfig struct {
+	MaxLogs int `mapstructure:"max_logs" yaml:"max_logs"` // Latest captured requests scanned for identifiers
+}
+
+// BeautifyConfig holds settings for the readable copies of minified JavaScript, JSON, HTML and XML
+// response bodies that diffs, the body search and the analyzers use.
+type BeautifyConfig struct {
+	OnCapture bool `mapstructure:"on_capture" yaml:"on_capture"` // Beautify responses when they are captured instead of on first use
+	Analysis  bool `mapstructure:"analysis" yaml:"analysis"`     // Run the analyzers over the beautified copy of JavaScript and HTML responses
+}
+
+// AuthSchemeConfig holds settings for the detection of the authentication scheme of captured
+// endpoints.
+type AuthSchemeConfig struct {
+	MaxLogs       int      `mapstructure:"max_logs" yaml:"max_logs"`               // Latest captured requests classified
+	APIKeyHeaders []string `mapstructure:"api_key_headers" yaml:"api_key_headers"` // Request headers carrying API keys (case-insensitive)
+	APIKeyParams  []string `mapstructure:"api_key_params" yaml:"api_key_params"`   // Query parameters carrying API keys or tokens
+}
+
+// EngagementConfig holds settings for engagement sessions, which track the time spent on targets.
+type EngagementConfig struct {
+	AutoTrack          bool `mapstructure:"auto_track" yaml:"auto_track"`                     // Start a session when the user's traffic to a target without one reaches the proxy
+	IdleTimeoutMinutes int  `mapstructure:"idle_timeout_minutes" yaml:"idle_timeout_minutes"` // Automatic sessions end after this long without traffic; longer gaps are not active time
+}
+
+// PayloadsConfig holds settings for the payload placeholders ({{oob}}, {{random}}, ...) expanded in
+// Modifier requests and payload lists.
+type PayloadsConfig struct {
+	OOBDomain    string `mapstructure:"oob_domain" yaml:"oob_domain"`       // Out-of-band interaction domain ({{oob}} is <id>.<oob_domain>), e.g. a Collaborator or interactsh domain
+	RandomLength int    `mapstructure:"random_length" yaml:"random_length"` // Characters of {{random}}
+}
+
+// AnomalyConfig holds the thresholds of the size and latency anomaly detection over captured
+// traffic.
+type AnomalyConfig struct {
+	MaxLogs       int     `mapstructure:"max_logs" yaml:"max_logs"`             // Latest captured responses the endpoint baselines are built from
+	MinSamples    int     `mapstructure:"min_samples" yaml:"min_samples"`       // Responses an endpoint needs before it has a baseline
+	SizeFactor    float64 `mapstructure:"size_factor" yaml:"size_factor"`       // Responses this many times the endpoint's median size are flagged
+	LatencyFactor float64 `mapstructure:"latency_factor" yaml:"latency_factor"` // Responses this many times the endpoint's median latency are flagged
+	MinSizeDelta  int64   `mapstructure:"min_size_delta" yaml:"min_size_delta"` // Bytes a response must exceed the median by to be flagged
+	MinLatencyMs  int64   `mapstructure:"min_latency_ms" yaml:"min_latency_ms"` // Milliseconds a response must exceed the median by to be flagged
+}
+
+// RateProfilerConfig holds defaults of the rate-limit and WAF profiler.
+type RateProfilerConfig struct {
+	StartRPS        float64 `mapstructure:"start_rps" yaml:"start_rps"`                 // Rate of the first burst; each next burst doubles it
+	MaxRPS          float64 `mapstructure:"max_rps" yaml:"max_rps"`                     // Highest rate tried
+	RequestsPerStep int     `mapstructure:"requests_per_step" yaml:"requests_per_step"` // Requests sent at each rate
+	SafetyFactor    float64 `mapstructure:"safety_factor" yaml:"safety_factor"`         // Share of the highest unthrottled rate stored as the safe rate
+	TimeoutSeconds  int     `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`
+}
+
+// AttachmentsConfig holds settings for files attached to targets, findings, notes and checklist
+// items.
+type AttachmentsConfig struct {
+	Dir           string `mapstructure:"dir" yaml:"dir"`                         // Directory the files are stored in, by SHA-256
+	MaxFileBytes  int64  `mapstructure:"max_file_bytes" yaml:"max_file_bytes"`   // Larger uploads are rejected
+	MaxTotalBytes int64  `mapstructure:"max_total_bytes" yaml:"max_total_bytes"` // Stored files may not exceed this in total (0 for no limit)
+}
+
+// WordlistsConfig holds settings for stored wordlists and target-specific wordlist generation.
+type WordlistsConfig struct {
+	Dir          string `mapstructure:"dir" yaml:"dir"`                       // Directory the wordlists are stored in, as <id>.txt
+	MaxFileBytes int64  `mapstructure:"max_file_bytes" yaml:"max_file_bytes"` // Larger uploads and merges are rejected
+	MaxLogs      int    `mapstructure:"max_logs" yaml:"max_logs"`             // Latest captured requests of a target words are generated from
+}
+
+// CookieJarConfig holds settings for tracking the cookies targets set in captured traffic and
+// detecting ended sessions.
+type CookieJarConfig struct {
+	Enabled            bool     `mapstructure:"enabled" yaml:"enabled"`
+	SessionCookies     []string `mapstructure:"session_cookies" yaml:"session_cookies"`           // Names of session cookies, case-insensitive; * matches any characters (*sess*)
+	LoginPaths         []string `mapstructure:"login_paths" yaml:"login_paths"`                   // Path fragments of login pages; an authenticated request redirected to one means the session ended
+	AutoUpdateSessions bool     `mapstructure:"auto_update_sessions" yaml:"auto_update_sessions"` // Replace rotated session cookies in the target's replay sessions
+}
+
+// AuthTokensConfig holds settings for tracking the JWTs and SAML messages seen in captured traffic.
+type AuthTokensConfig struct {
+	Capture bool `mapstructure:"capture" yaml:"capture"` // Record the tokens proxied requests and responses carry, and where
+}
+
+// InboxConfig holds settings for the test inbox, whose emails are read over IMAP or posted to the
+// inbox webhook so that verification codes and magic links surface next to the target under test.
+type InboxConfig struct {
+	Enabled             bool            `mapstructure:"enabled" yaml:"enabled"`
+	PollIntervalSeconds int             `mapstructure:"poll_interval_seconds" yaml:"poll_interval_seconds"` // Minimum 10
+	IMAP                InboxIMAPConfig `mapstructure:"imap" yaml:"imap"`
+	WebhookToken        string          `mapstructure:"webhook_token" yaml:"webhook_token"` // Required as ?token= or X-Inbox-Token by the webhook when set
+	CodePatterns        []string        `mapstructure:"code_patterns" yaml:"code_patterns"` // Regexes whose first group is a verification code
+	MaxMessages         int             `mapstructure:"max_messages" yaml:"max_messages"`   // Older messages are pruned
+}
+
+// InboxIMAPConfig holds the IMAP account of the test inbox. Polling is skipped without an address.
+type InboxIMAPConfig struct {
+	Address        string `mapstructure:"address" yaml:"address"` // host:port, e.g. imap.gmail.com:993
+	Username       string `mapstructure:"username" yaml:"username"`
+	Password       string `mapstructure:"password" yaml:"password"`
+	Mailbox        string `mapstructure:"mailbox" yaml:"mailbox"`
+	TLS            bool   `mapstructure:"tls" yaml:"tls"` // Implicit TLS; false for a plain local server
+	TimeoutSeconds int    `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`
+}
+
+// RedactionConfig holds the global rules for masking credentials in captured traffic. Targets can
+// extend or replace them through the API.
+type RedactionConfig struct {
+	Enabled     bool     `mapstructure:"enabled" yaml:"enabled"`
+	ApplyAt     string   `mapstructure:"apply_at" yaml:"apply_at"`       // "storage" (before saving), "export" (curl/raw exports) or "both"
+	Replacement string   `mapstructure:"replacement" yaml:"replacement"` // Text that replaces redacted values
+	Headers     []string `mapstructure:"headers" yaml:"headers"`         // Header names, case-insensitive
+	JSONPaths   []string `mapstructure:"json_paths" yaml:"json_paths"`   // Body JSON paths: $.password, $.user.token, $.items[*].secret, $..password
+	Parameters  []string `mapstructure:"parameters" yaml:"parameters"`   // Query string and form body parameter names, case-insensitive
+}
+
+// APIAccessConfig holds the bearer tokens of the API and the roles they grant. Requests without a
+// token have the default role; with the default "admin" role and no tokens the API is open as before.
+type APIAccessConfig struct {
+	DefaultRole string                   `mapstructure:"default_role" yaml:"default_role"` // Role of requests without a token
+	Tokens      []APITokenConfig         `mapstructure:"tokens" yaml:"tokens"`
+	Roles       map[string]APIRoleConfig `mapstructure:"roles" yaml:"roles"` // Redaction per role; "admin" sees everything
+}
+
+// APITokenConfig is a bearer token of the API.
+type APITokenConfig struct {
+	Name  string `mapstructure:"name" yaml:"name"` // Who holds it, for the logs
+	Token string `mapstructure:"token" yaml:"token"`
+	Role  string `mapstructure:"role" yaml:"role"`
+}
+
+// APIRoleConfig holds what API responses withhold from a role.
+type APIRoleConfig struct {
+	RedactCredentials bool `mapstructure:"redact_credentials" yaml:"redact_credentials"` // Stored tokens, cookies, passwords and API keys, credential headers and URL parameters
+	RedactSecrets     bool `mapstructure:"redact_secrets" yaml:"redact_secrets"`         // Secrets scanner matches
+	MaxBodyBytes      int  `mapstructure:"max_body_bytes" yaml:"max_body_bytes"`         // Request and response bodies are cut to this size (0 = no limit)
+}
+
+// CollaborationConfig holds settings for sharing a toolkit between users: who is viewing what,
+// soft locks on findings and notes being edited and the activity feed of API mutations.
+type CollaborationConfig struct {
+	PresenceTTLSeconds    int `mapstructure:"presence_ttl_seconds" yaml:"presence_ttl_seconds"`       // A viewer not seen for this long is gone
+	LockTTLSeconds        int `mapstructure:"lock_ttl_seconds" yaml:"lock_ttl_seconds"`               // Lifetime of a lock unless the request asks for another
+	ActivityRetentionDays int `mapstructure:"activity_retention_days" yaml:"activity_retention_days"` // Older activity is pruned; 0 keeps it forever
+}
+
+// CommandRunnerConfig holds settings for running checklist item commands from the toolkit.
+type CommandRunnerConfig struct {
+	Enabled         bool     `mapstructure:"enabled" yaml:"enabled"`                   // Allow checklist commands to be executed
+	AllowedCommands []string `mapstructure:"allowed_commands" yaml:"allowed_commands"` // Executables (by name) that may be run
+	TimeoutSeconds  int      `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`   // Runs are killed after this long
+	MaxOutputBytes  int      `mapstructure:"max_output_bytes" yaml:"max_output_bytes"` // Output beyond this is discarded
+	WorkDir         string   `mapstructure:"work_dir" yaml:"work_dir"`                 // Parent directory for per-run working directories
+	UseProxy        bool     `mapstructure:"use_proxy" yaml:"use_proxy"`               // Set HTTP(S)_PROXY so tool traffic is logged by the toolkit proxy
+}
+
+// ExternalToolsConfig holds settings for the external binaries the toolkit runs (subfinder, httpx,
+// nuclei, ffuf) and the releases 'toolkit tools install' downloads.
+type ExternalToolsConfig struct {
+	BinDir         string            `mapstructure:"bin_dir" yaml:"bin_dir"`                   // Toolkit-managed binaries, looked up before PATH
+	Versions       map[string]string `mapstructure:"versions" yaml:"versions"`                 // Release to install per tool, overriding the pinned one
+	ReleaseBaseURL string            `mapstructure:"release_base_url" yaml:"release_base_url"` // GitHub, or a mirror with the same /<owner>/<repo>/releases/download layout
+}
+
+// PluginsConfig holds settings for plugins: external programs adding analyzers, recon sources and
+// notification sinks, found in <dir>/<plugin>/plugin.json.
+type PluginsConfig struct {
+	Dir            string `mapstructure:"dir" yaml:"dir"`                           // Directory scanned for plugins
+	TimeoutSeconds int    `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`   // Time a call may take unless the manifest sets timeout_seconds
+	MaxOutputBytes int64  `mapstructure:"max_output_bytes" yaml:"max_output_bytes"` // Larger answers fail the call
+}
+
+// ProxyScriptsConfig holds settings for proxy scripts: user programs the proxy runs on request and
+// response events.
+type ProxyScriptsConfig struct {
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"` // Allow proxy scripts to be added and run
+	Dir     string `mapstructure:"dir" yaml:"dir"`         // Script files must be in this directory
+}
+
+// SyncConfig holds settings for syncing targets, scope rules, domains, findings and selected traffic
+// with other toolkit instances. Instances sharing the key exchange changes through POST /api/sync.
+type SyncConfig struct {
+	Enabled         bool             `mapstructure:"enabled" yaml:"enabled"`                   // Serve POST /api/sync and allow sync runs
+	Key             string           `mapstructure:"key" yaml:"key"`                           // Shared secret, base64 of 32 bytes ('toolkit sync keygen')
+	Peers           []SyncPeerConfig `mapstructure:"peers" yaml:"peers"`                       // Instances this one syncs with
+	IntervalMinutes int              `mapstructure:"interval_minutes" yaml:"interval_minutes"` // Sync with every peer this often while 'toolkit start' runs; 0 syncs on demand only
+	TrafficTags     []string         `mapstructure:"traffic_tags" yaml:"traffic_tags"`         // Traffic log entries with one of these tags are synced
+	FavoriteTraffic bool             `mapstructure:"favorite_traffic" yaml:"favorite_traffic"` // Favorite traffic log entries are synced
+	TombstoneDays   int              `mapstructure:"tombstone_days" yaml:"tombstone_days"`     // Deletions are kept this long for peers that haven't synced yet
+	BatchSize       int              `mapstructure:"batch_size" yaml:"batch_size"`             // Rows sent per request
+}
+
+// SyncPeerConfig is a toolkit instance to sync with.
+type SyncPeerConfig struct {
+	Name     string `mapstructure:"name" yaml:"name"`
+	URL      string `mapstructure:"url" yaml:"url"`             // API base URL of the peer, e.g. https://vps.example.com:8778/api
+	APIToken string `mapstructure:"api_token" yaml:"api_token"` // Bearer token, when the peer has api_access tokens
+}
+
+// HackerOneConfig holds the HackerOne API credentials used for scope import.
+type HackerOneConfig struct {
+	APIUsername string `mapstructure:"api_username" yaml:"api_username"` // API token identifier
+	APIToken    string `mapstructure:"api_token" yaml:"api_token"`
+	BaseURL     string `mapstructure:"base_url" yaml:"base_url"`
+}
+
+// BugcrowdConfig holds the Bugcrowd API credentials used for scope import.
+type BugcrowdConfig struct {
+	APIToken string `mapstructure:"api_token" yaml:"api_token"` // Token in "<username>:<password>" form
+	BaseURL  string `mapstructure:"base_url" yaml:"base_url"`
+}
+
+// PlatformImportConfig holds settings for importing program scope from bug bounty platform APIs.
+type PlatformImportConfig struct {
+	HackerOne      HackerOneConfig `mapstructure:"hackerone" yaml:"hackerone"`
+	Bugcrowd       BugcrowdConfig  `mapstructure:"bugcrowd" yaml:"bugcrowd"`
+	TimeoutSeconds int             `mapstructure:"timeout_seconds" yaml:"timeout_seconds"` // Timeout for each platform API request
 }
 
 // LoggingConfig holds logging related configuration.
@@ -56,6 +499,10 @@ type SynackConfig struct {
 	AnalyticsEnabled                 bool   `mapstructure:"analytics_enabled" yaml:"analytics_enabled"`
 	AnalyticsBaseURL                 string `mapstructure:"analytics_base_url" yaml:"analytics_base_url"`
 	AnalyticsPathPattern             string `mapstructure:"analytics_path_pattern" yaml:"analytics_path_pattern"`
+	AnalyticsMaxAgeHours             int    `mapstructure:"analytics_max_age_hours" yaml:"analytics_max_age_hours"`           // Analytics older than this are refetched when the target list is seen (0 = fetch once)
+	AnalyticsMaxRetries              int    `mapstructure:"analytics_max_retries" yaml:"analytics_max_retries"`               // Retries for failed (network error, 429, 5xx) analytics requests
+	AnalyticsBackoffInitialMs        int    `mapstructure:"analytics_backoff_initial_ms" yaml:"analytics_backoff_initial_ms"` // First retry delay when no Retry-After is given; doubles per attempt
+	AnalyticsBackoffMaxMs            int    `mapstructure:"analytics_backoff_max_ms" yaml:"analytics_backoff_max_ms"`         // Upper bound for the retry delay
 	TargetNameField                  string `mapstructure:"target_name_field" yaml:"target_name_field"`
 	TargetsArrayPath                 string `mapstructure:"targets_array_path" yaml:"targets_array_path"` // GJSON path to the array of targets in the targets_url response
 	FindingsEnabled                  bool   `mapstructure:"findings_enabled" yaml:"findings_enabled"`
@@ -74,6 +521,37 @@ type MissionsConfig struct {
 	ClaimMaxPayout         float64 `mapstructure:"claim_max_payout" yaml:"claim_max_payout"`   // Maximum payout to consider claiming (e.g., up to $50)
 }
 
+// SynackWatcherConfig holds settings for polling Synack for new targets and missions.
+type SynackWatcherConfig struct {
+	Enabled             bool     `mapstructure:"enabled" yaml:"enabled"`
+	PollIntervalSeconds int      `mapstructure:"poll_interval_seconds" yaml:"poll_interval_seconds"` // Minimum 30
+	WatchTargets        bool     `mapstructure:"watch_targets" yaml:"watch_targets"`                 // Poll synack.targets_url and notify about new targets
+	WatchMissions       bool     `mapstructure:"watch_missions" yaml:"watch_missions"`               // Poll missions.list_url and notify about new missions
+	TargetCategories    []string `mapstructure:"target_categories" yaml:"target_categories"`         // Notify only for new targets in these categories (empty = all)
+	MissionMinPayout    float64  `mapstructure:"mission_min_payout" yaml:"mission_min_payout"`       // Notify only for missions paying at least this
+}
+
+// NotificationsConfig holds the notification sinks and the events sent to them.
+type NotificationsConfig struct {
+	Desktop           bool                     `mapstructure:"desktop" yaml:"desktop"`         // notify-send on Linux, osascript on macOS
+	WebhookURL        string                   `mapstructure:"webhook_url" yaml:"webhook_url"` // Generic sink: receives the notification as a JSON POST
+	SlackWebhookURL   string                   `mapstructure:"slack_webhook_url" yaml:"slack_webhook_url"`
+	DiscordWebhookURL string                   `mapstructure:"discord_webhook_url" yaml:"discord_webhook_url"`
+	TimeoutSeconds    int                      `mapstructure:"timeout_seconds" yaml:"timeout_seconds"` // Timeout for webhook requests
+	Events            NotificationEventsConfig `mapstructure:"events" yaml:"events"`
+}
+
+// NotificationEventsConfig enables or disables notifications per event.
+type NotificationEventsConfig struct {
+	NewSubdomain  bool `mapstructure:"new_subdomain" yaml:"new_subdomain"`     // Subdomain discovery found new subdomains
+	ScanFinished  bool `mapstructure:"scan_finished" yaml:"scan_finished"`     // A subfinder or httpx scan finished
+	ScopeChanged  bool `mapstructure:"scope_changed" yaml:"scope_changed"`     // Scope rules were added, removed or imported
+	SynackTarget  bool `mapstructure:"synack_target" yaml:"synack_target"`     // The Synack watcher saw a new target
+	SynackMission bool `mapstructure:"synack_mission" yaml:"synack_mission"`   // The Synack watcher saw a new mission
+	CTInteresting bool `mapstructure:"ct_interesting" yaml:"ct_interesting"`   // The CT watcher saw a new name matching ct_watch.interesting_keywords
+	Engagement    bool `mapstructure:"engagement_time" yaml:"engagement_time"` // An engagement session ran past its planned time or deadline
+}
+
 // UIConfig holds UI related configuration.
 type UIConfig struct {
 	ShowSynackSection bool   `mapstructure:"showSynackSection" yaml:"showSynackSection"`
@@ -82,13 +560,57 @@ type UIConfig struct {
 
 // Configuration is the main application configuration struct.
 type Configuration struct {
-	Database DatabaseConfig `mapstructure:"database" yaml:"database"`
-	Server   ServerConfig   `mapstructure:"server" yaml:"server"`
-	Proxy    ProxyConfig    `mapstructure:"proxy" yaml:"proxy"`
-	Logging  LoggingConfig  `mapstructure:"logging" yaml:"logging"`
-	Synack   SynackConfig   `mapstructure:"synack" yaml:"synack"`
-	Missions MissionsConfig `mapstructure:"missions" yaml:"missions"`
-	UI       UIConfig       `mapstructure:"ui" yaml:"ui"`
+	Database     DatabaseConfig         `mapstructure:"database" yaml:"database"`
+	Server       ServerConfig           `mapstructure:"server" yaml:"server"`
+	Proxy        ProxyConfig            `mapstructure:"proxy" yaml:"proxy"`
+	RateLimit    RateLimitConfig        `mapstructure:"rate_limit" yaml:"rate_limit"`
+	Cache        ResponseCacheConfig    `mapstructure:"response_cache" yaml:"response_cache"`
+	Secrets      SecretsConfig          `mapstructure:"secrets" yaml:"secrets"`
+	JSAnalysis   JSAnalysisConfig       `mapstructure:"js_analysis" yaml:"js_analysis"`
+	GraphQL      GraphQLConfig          `mapstructure:"graphql" yaml:"graphql"`
+	Commands     CommandRunnerConfig    `mapstructure:"command_runner" yaml:"command_runner"`
+	Tools        ExternalToolsConfig    `mapstructure:"tools" yaml:"tools"`
+	Plugins      PluginsConfig          `mapstructure:"plugins" yaml:"plugins"`
+	ProxyScripts ProxyScriptsConfig     `mapstructure:"proxy_scripts" yaml:"proxy_scripts"`
+	Sync         SyncConfig             `mapstructure:"sync" yaml:"sync"`
+	Httpx        HttpxConfig            `mapstructure:"httpx" yaml:"httpx"`
+	IPAssets     IPAssetsConfig         `mapstructure:"ip_assets" yaml:"ip_assets"`
+	CTWatch      CTWatchConfig          `mapstructure:"ct_watch" yaml:"ct_watch"`
+	Permute      PermutationConfig      `mapstructure:"permutation" yaml:"permutation"`
+	DNS          DNSConfig              `mapstructure:"dns" yaml:"dns"`
+	Enrichment   EnrichmentConfig       `mapstructure:"enrichment" yaml:"enrichment"`
+	Takeover     TakeoverConfig         `mapstructure:"takeover" yaml:"takeover"`
+	CloudStorage CloudStorageConfig     `mapstructure:"cloud_storage" yaml:"cloud_storage"`
+	Vhost        VhostConfig            `mapstructure:"vhost" yaml:"vhost"`
+	Harvester    HarvesterConfig        `mapstructure:"harvester" yaml:"harvester"`
+	Baseline     ResponseBaselineConfig `mapstructure:"response_baseline" yaml:"response_baseline"`
+	Redaction    RedactionConfig        `mapstructure:"redaction" yaml:"redaction"`
+	APIAccess    APIAccessConfig        `mapstructure:"api_access" yaml:"api_access"`
+	Collab       CollaborationConfig    `mapstructure:"collaboration" yaml:"collaboration"`
+	Analysis     AnalysisConfig         `mapstructure:"analysis" yaml:"analysis"`
+	URLs         URLCanonicalConfig     `mapstructure:"url_canonical" yaml:"url_canonical"`
+	CookieJar    CookieJarConfig        `mapstructure:"cookie_jar" yaml:"cookie_jar"`
+	AuthTokens   AuthTokensConfig       `mapstructure:"auth_tokens" yaml:"auth_tokens"`
+	Inbox        InboxConfig            `mapstructure:"inbox" yaml:"inbox"`
+	CORSCheck    CORSCheckConfig        `mapstructure:"cors_check" yaml:"cors_check"`
+	Redirects    RedirectCheckConfig    `mapstructure:"redirect_check" yaml:"redirect_check"`
+	ParamMining  ParamMiningConfig      `mapstructure:"param_mining" yaml:"param_mining"`
+	IDOR         IDORConfig             `mapstructure:"idor" yaml:"idor"`
+	AuthSchemes  AuthSchemeConfig       `mapstructure:"auth_schemes" yaml:"auth_schemes"`
+	Beautify     BeautifyConfig         `mapstructure:"beautify" yaml:"beautify"`
+	Anomalies    AnomalyConfig          `mapstructure:"anomalies" yaml:"anomalies"`
+	Engagement   EngagementConfig       `mapstructure:"engagement" yaml:"engagement"`
+	Payloads     PayloadsConfig         `mapstructure:"payloads" yaml:"payloads"`
+	Profiler     RateProfilerConfig     `mapstructure:"rate_profiler" yaml:"rate_profiler"`
+	Attachment   AttachmentsConfig      `mapstructure:"attachments" yaml:"attachments"`
+	Wordlists    WordlistsConfig        `mapstructure:"wordlists" yaml:"wordlists"`
+	Platforms    PlatformImportConfig   `mapstructure:"platform_import" yaml:"platform_import"`
+	Logging      LoggingConfig          `mapstructure:"logging" yaml:"logging"`
+	Synack       SynackConfig           `mapstructure:"synack" yaml:"synack"`
+	Missions     MissionsConfig         `mapstructure:"missions" yaml:"missions"`
+	Watcher      SynackWatcherConfig    `mapstructure:"synack_watcher" yaml:"synack_watcher"`
+	Notify       NotificationsConfig    `mapstructure:"notifications" yaml:"notifications"`
+	UI           UIConfig               `mapstructure:"ui" yaml:"ui"`
 }
 
 var AppConfig Configuration
@@ -105,21 +627,11 @@ func expandTilde(path string) (string, error) {
 	return filepath.Join(home, path[1:]), nil
 }
 
+// GetDefaultConfigPaths returns the default paths of the active config profile. Each profile keeps
+// its config file, database, CA, state and logs in its own directory (see ProfileDir).
 func GetDefaultConfigPaths() DefaultPaths {
 	var paths DefaultPaths
-	userConfigDirBase, err := os.UserConfigDir()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not get user config dir: %v. Using current directory.\n", err)
-		userConfigDirBase = "."
-	}
-
-	userConfigDir, err := expandTilde(userConfigDirBase)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in user config dir '%s': %v. Using potentially literal path.\n", userConfigDirBase, err)
-		userConfigDir = userConfigDirBase
-	}
-
-	paths.ConfigDir = filepath.Join(userConfigDir, "toolkit")
+	paths.ConfigDir = ProfileDir(ActiveProfile())
 	logDir := filepath.Join(paths.ConfigDir, "logs")
 
 	paths.StateFilePath = filepath.Join(paths.ConfigDir, "state.json")
@@ -140,12 +652,211 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 	v.SetDefault("database.path", defaults.DBPath)
 	v.SetDefault("server.port", "8778") // UPDATED default server port
 	v.SetDefault("server.log_path", defaults.LogPathApp)
+	v.SetDefault("server.read_only", false)
 	v.SetDefault("proxy.port", "8777") // UPDATED default proxy port
 	v.SetDefault("proxy.ca_cert_path", defaults.CACertPath)
 	v.SetDefault("proxy.ca_key_path", defaults.CAKeyPath)
 	v.SetDefault("proxy.log_path", defaults.LogPathProxy)
 	v.SetDefault("proxy.modifier_skip_tls_verify", false) // Default to secure: verify TLS
 	v.SetDefault("proxy.modifier_allow_loopback", false)  // Default to secure: disallow loopback
+	v.SetDefault("proxy.ca_key_type", "rsa")
+	v.SetDefault("proxy.leaf_cert_cache_ttl_minutes", 60)
+	v.SetDefault("proxy.leaf_cert_cache_size", 1000)
+	v.SetDefault("proxy.socks5_port", "")
+	v.SetDefault("proxy.transparent_port", "")
+	v.SetDefault("proxy.max_capture_bytes", 10*1024*1024)
+	v.SetDefault("proxy.performance_mode", false)
+	v.SetDefault("proxy.sample_rate", 10)
+	v.SetDefault("proxy.upstream_proxy", "")
+	v.SetDefault("proxy.script_max_body_bytes", 1024*1024)
+	v.SetDefault("rate_limit.enabled", false)
+	v.SetDefault("rate_limit.requests_per_second", 10.0)
+	v.SetDefault("rate_limit.burst", 5)
+	v.SetDefault("rate_limit.max_concurrency", 20)
+	v.SetDefault("rate_limit.per_host_concurrency", 5)
+	v.SetDefault("rate_limit.apply_to_proxy", false)
+	v.SetDefault("rate_limit.max_retries", 3)
+	v.SetDefault("rate_limit.backoff_initial_ms", 1000)
+	v.SetDefault("rate_limit.backoff_max_ms", 30000)
+	v.SetDefault("response_cache.enabled", false)
+	v.SetDefault("response_cache.window_seconds", 300)
+	v.SetDefault("response_cache.methods", []string{"GET", "HEAD"})
+	v.SetDefault("secrets.enabled", true)
+	v.SetDefault("secrets.max_body_bytes", 5*1024*1024)
+	v.SetDefault("secrets.scan_request_body", false)
+	v.SetDefault("js_analysis.auto_analyze", false)
+	v.SetDefault("js_analysis.fetch_source_maps", true)
+	v.SetDefault("js_analysis.max_source_map_bytes", 20*1024*1024)
+	v.SetDefault("js_analysis.skip_vendor_sources", true)
+	v.SetDefault("graphql.capture_operations", true)
+	v.SetDefault("command_runner.enabled", false)
+	v.SetDefault("command_runner.allowed_commands", []string{"nmap", "dirsearch", "sqlmap", "ffuf", "nuclei", "httpx", "subfinder", "curl", "whatweb", "nikto"})
+	v.SetDefault("command_runner.timeout_seconds", 600)
+	v.SetDefault("command_runner.max_output_bytes", 1024*1024)
+	v.SetDefault("command_runner.work_dir", filepath.Join(defaults.ConfigDir, "runs"))
+	v.SetDefault("command_runner.use_proxy", true)
+	v.SetDefault("tools.bin_dir", filepath.Join(defaults.ConfigDir, "bin"))
+	v.SetDefault("plugins.dir", filepath.Join(defaults.ConfigDir, "plugins"))
+	v.SetDefault("plugins.timeout_seconds", 30)
+	v.SetDefault("plugins.max_output_bytes", 16*1024*1024)
+	v.SetDefault("proxy_scripts.enabled", false)
+	v.SetDefault("proxy_scripts.dir", filepath.Join(defaults.ConfigDir, "scripts"))
+	v.SetDefault("collaboration.presence_ttl_seconds", 60)
+	v.SetDefault("collaboration.lock_ttl_seconds", 300)
+	v.SetDefault("collaboration.activity_retention_days", 90)
+	v.SetDefault("sync.enabled", false)
+	v.SetDefault("sync.interval_minutes", 0)
+	v.SetDefault("sync.traffic_tags", []string{"sync"})
+	v.SetDefault("sync.favorite_traffic", true)
+	v.SetDefault("sync.tombstone_days", 90)
+	v.SetDefault("sync.batch_size", 500)
+	v.SetDefault("tools.versions", map[string]string{})
+	v.SetDefault("tools.release_base_url", "https://github.com")
+
+	v.SetDefault("httpx.chunk_size", 200)
+	v.SetDefault("httpx.max_retries", 2)
+	v.SetDefault("httpx.retry_backoff_ms", 2000)
+	v.SetDefault("httpx.threads", 25)
+	v.SetDefault("httpx.timeout_minutes", 60)
+
+	v.SetDefault("ip_assets.resolve_after_discovery", true)
+	v.SetDefault("ip_assets.enrich", true)
+	v.SetDefault("ip_assets.resolver_workers", 10)
+	v.SetDefault("ip_assets.lookup_timeout_seconds", 5)
+
+	v.SetDefault("dns.resolvers", []string{})
+	v.SetDefault("dns.resolvers_file", "")
+	v.SetDefault("dns.queries_per_second", 0.0)
+	v.SetDefault("dns.timeout_seconds", 5)
+	v.SetDefault("dns.max_failures", 3)
+	v.SetDefault("dns.cooldown_seconds", 60)
+	v.SetDefault("dns.health_check_interval_seconds", 300)
+	v.SetDefault("dns.health_check_domain", "example.com")
+
+	v.SetDefault("permutation.wordlist", "")
+	v.SetDefault("permutation.workers", 50)
+	v.SetDefault("permutation.lookup_timeout_seconds", 3)
+	v.SetDefault("permutation.max_candidates", 50000)
+
+	v.SetDefault("enrichment.workers", 5)
+	v.SetDefault("enrichment.timeout_seconds", 15)
+	v.SetDefault("enrichment.max_related_hosts", 25)
+	v.SetDefault("enrichment.shodan.api_key", "")
+	v.SetDefault("enrichment.shodan.base_url", "https://api.shodan.io")
+	v.SetDefault("enrichment.shodan.search_favicon", false)
+	v.SetDefault("enrichment.censys.api_id", "")
+	v.SetDefault("enrichment.censys.api_secret", "")
+	v.SetDefault("enrichment.censys.base_url", "https://search.censys.io/api")
+
+	v.SetDefault("takeover.workers", 10)
+	v.SetDefault("takeover.timeout_seconds", 10)
+
+	v.SetDefault("cloud_storage.workers", 10)
+	v.SetDefault("cloud_storage.timeout_seconds", 10)
+	v.SetDefault("cloud_storage.max_candidates", 300)
+	v.SetDefault("cloud_storage.suffixes", []string{})
+	v.SetDefault("cloud_storage.azure_containers", []string{"public", "assets", "static", "images", "media", "uploads", "files", "backup", "backups", "data", "$web"})
+
+	v.SetDefault("vhost.workers", 20)
+	v.SetDefault("vhost.timeout_seconds", 10)
+	v.SetDefault("vhost.ports", []int{443, 80})
+	v.SetDefault("vhost.max_candidates", 2000)
+	v.SetDefault("harvester.workers", 5)
+	v.SetDefault("harvester.timeout_seconds", 15)
+	v.SetDefault("harvester.max_sitemaps", 10)
+	v.SetDefault("harvester.max_sitemap_urls", 5000)
+	v.SetDefault("response_baseline.workers", 5)
+	v.SetDefault("response_baseline.timeout_seconds", 15)
+	v.SetDefault("response_baseline.probe_count", 3)
+	v.SetDefault("response_baseline.max_distance", 6)
+	v.SetDefault("response_baseline.min_cluster_paths", 5)
+	v.SetDefault("response_baseline.max_responses", 2000)
+	v.SetDefault("url_canonical.strip_params", []string{"utm_*", "gclid", "gclsrc", "dclid", "fbclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid",
+		"_ga", "_gl", "_hsenc", "_hsmi", "ref_src", "jsessionid", "phpsessid", "aspsessionid*", "sid", "sessionid", "cfid", "cftoken", "_"})
+	v.SetDefault("cookie_jar.enabled", true)
+	v.SetDefault("cookie_jar.session_cookies", []string{"*sess*", "sid", "*_sid", "*token*", "auth*", "*auth", "connect.sid", "remember*"})
+	v.SetDefault("cookie_jar.login_paths", []string{"login", "signin", "sign-in", "sign_in", "logon", "/auth", "/sso", "/oauth"})
+	v.SetDefault("cookie_jar.auto_update_sessions", false)
+	v.SetDefault("auth_tokens.capture", true)
+	v.SetDefault("inbox.enabled", false)
+	v.SetDefault("inbox.poll_interval_seconds", 30)
+	v.SetDefault("inbox.imap.address", "")
+	v.SetDefault("inbox.imap.username", "")
+	v.SetDefault("inbox.imap.password", "")
+	v.SetDefault("inbox.imap.mailbox", "INBOX")
+	v.SetDefault("inbox.imap.tls", true)
+	v.SetDefault("inbox.imap.timeout_seconds", 30)
+	v.SetDefault("inbox.webhook_token", "")
+	v.SetDefault("inbox.code_patterns", []string{
+		`(?i)(?:code|otp|passcode|pin|verification|one-time|token)[^0-9A-Za-z]{0,40}([0-9]{4,8})\b`,
+		`(?i)(?:code|otp|passcode)(?: is)?[^0-9A-Za-z]{1,10}([A-Z0-9]{5,10})\b`,
+		`\b([0-9]{6})\b`, // Bare six-digit codes, after the keyword matches
+	})
+	v.SetDefault("inbox.max_messages", 500)
+	v.SetDefault("cors_check.attacker_domain", "attacker.com")
+	v.SetDefault("cors_check.workers", 5)
+	v.SetDefault("cors_check.timeout_seconds", 15)
+	v.SetDefault("cors_check.max_endpoints", 200)
+	v.SetDefault("redirect_check.marker_domain", "redirect-check.example.com")
+	v.SetDefault("redirect_check.workers", 5)
+	v.SetDefault("redirect_check.timeout_seconds", 15)
+	v.SetDefault("redirect_check.max_tests", 200)
+	v.SetDefault("param_mining.workers", 3)
+	v.SetDefault("param_mining.timeout_seconds", 15)
+	v.SetDefault("param_mining.chunk_size", 40)
+	v.SetDefault("param_mining.max_endpoints", 50)
+	v.SetDefault("idor.max_logs", 5000)
+	v.SetDefault("auth_schemes.max_logs", 5000)
+	v.SetDefault("beautify.on_capture", false)
+	v.SetDefault("beautify.analysis", true)
+	v.SetDefault("auth_schemes.api_key_headers", []string{"X-API-Key", "API-Key", "X-Api-Token", "X-Auth-Token", "X-Access-Token", "X-Token", "Private-Token", "Ocp-Apim-Subscription-Key"})
+	v.SetDefault("auth_schemes.api_key_params", []string{"api_key", "apikey", "api-key", "access_token", "auth_token", "token"})
+	v.SetDefault("engagement.auto_track", true)
+	v.SetDefault("engagement.idle_timeout_minutes", 15)
+	v.SetDefault("payloads.oob_domain", "")
+	v.SetDefault("payloads.random_length", 12)
+	v.SetDefault("anomalies.max_logs", 50000)
+	v.SetDefault("anomalies.min_samples", 5)
+	v.SetDefault("anomalies.size_factor", 10.0)
+	v.SetDefault("anomalies.latency_factor", 50.0)
+	v.SetDefault("anomalies.min_size_delta", 1024)
+	v.SetDefault("anomalies.min_latency_ms", 1000)
+	v.SetDefault("rate_profiler.start_rps", 1.0)
+	v.SetDefault("rate_profiler.max_rps", 20.0)
+	v.SetDefault("rate_profiler.requests_per_step", 10)
+	v.SetDefault("rate_profiler.safety_factor", 0.8)
+	v.SetDefault("rate_profiler.timeout_seconds", 15)
+	v.SetDefault("attachments.dir", filepath.Join(defaults.ConfigDir, "attachments"))
+	v.SetDefault("attachments.max_file_bytes", 50*1024*1024)
+	v.SetDefault("attachments.max_total_bytes", 0)
+	v.SetDefault("wordlists.dir", filepath.Join(defaults.ConfigDir, "wordlists"))
+	v.SetDefault("wordlists.max_file_bytes", 200*1024*1024)
+	v.SetDefault("wordlists.max_logs", 50000)
+	v.SetDefault("redaction.enabled", false)
+	v.SetDefault("redaction.apply_at", "export")
+	v.SetDefault("redaction.replacement", "[REDACTED]")
+	v.SetDefault("redaction.headers", []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"})
+	v.SetDefault("redaction.json_paths", []string{"$..password", "$..access_token", "$..refresh_token", "$..client_secret"})
+	v.SetDefault("redaction.parameters", []string{"password", "access_token", "refresh_token", "client_secret", "api_key"})
+	v.SetDefault("api_access.default_role", "admin")
+	v.SetDefault("api_access.roles.read_only.redact_credentials", true)
+	v.SetDefault("api_access.roles.read_only.redact_secrets", true)
+	v.SetDefault("api_access.roles.read_only.max_body_bytes", 65536)
+	v.SetDefault("analysis.analyzers", []string{"jsluice", "secrets", "comments", "endpoints", "buckets"})
+	v.SetDefault("analysis.workers", 4)
+
+	v.SetDefault("ct_watch.enabled", false)
+	v.SetDefault("ct_watch.poll_interval_minutes", 360)
+	v.SetDefault("ct_watch.crtsh_url", "https://crt.sh")
+	v.SetDefault("ct_watch.timeout_seconds", 60)
+	v.SetDefault("ct_watch.max_retries", 2)
+	v.SetDefault("ct_watch.interesting_keywords", []string{"vpn", "staging", "admin", "dev", "internal", "jenkins", "gitlab", "jira", "sso", "vault"})
+	v.SetDefault("platform_import.hackerone.api_username", "")
+	v.SetDefault("platform_import.hackerone.api_token", "")
+	v.SetDefault("platform_import.hackerone.base_url", "https://api.hackerone.com/v1")
+	v.SetDefault("platform_import.bugcrowd.api_token", "")
+	v.SetDefault("platform_import.bugcrowd.base_url", "https://api.bugcrowd.com")
+	v.SetDefault("platform_import.timeout_seconds", 30)
 	v.SetDefault("logging.level", defaults.LogLevel)
 	v.SetDefault("synack.targets_url", defaults.SynackTargetsURL)
 	v.SetDefault("synack.target_id_field", "id")
@@ -155,6 +866,10 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 	v.SetDefault("synack.analytics_enabled", false)
 	v.SetDefault("synack.analytics_base_url", "https://platform.synack.com")
 	v.SetDefault("synack.analytics_path_pattern", "/api/listing_analytics/categories?listing_id=%s&status=accepted")
+	v.SetDefault("synack.analytics_max_age_hours", 24)
+	v.SetDefault("synack.analytics_max_retries", 3)
+	v.SetDefault("synack.analytics_backoff_initial_ms", 1000)
+	v.SetDefault("synack.analytics_backoff_max_ms", 30000)
 	// Defaults for new findings fields
 	v.SetDefault("synack.findings_enabled", false)                                                         // Default to false
 	v.SetDefault("synack.findings_base_url", "https://platform.synack.com")                                // Example, adjust as needed
@@ -168,6 +883,24 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 	v.SetDefault("missions.claim_url_pattern", "https://platform.synack.com/api/tasks/v1/organizations/%s/listings/%s/campaigns/%s/tasks/%s/transitions") // orgId, listingId, campaignId, taskId
 	v.SetDefault("missions.claim_min_payout", 0.0)                                                                                                        // Default to claim any mission with a payout (can be set higher)
 	v.SetDefault("missions.claim_max_payout", 50.0)                                                                                                       // Default to claim missions with payout $50 or less
+	v.SetDefault("synack_watcher.enabled", false)
+	v.SetDefault("synack_watcher.poll_interval_seconds", 120)
+	v.SetDefault("synack_watcher.watch_targets", true)
+	v.SetDefault("synack_watcher.watch_missions", true)
+	v.SetDefault("synack_watcher.target_categories", []string{})
+	v.SetDefault("synack_watcher.mission_min_payout", 0.0)
+	v.SetDefault("notifications.desktop", false)
+	v.SetDefault("notifications.webhook_url", "")
+	v.SetDefault("notifications.slack_webhook_url", "")
+	v.SetDefault("notifications.discord_webhook_url", "")
+	v.SetDefault("notifications.timeout_seconds", 10)
+	v.SetDefault("notifications.events.new_subdomain", true)
+	v.SetDefault("notifications.events.scan_finished", true)
+	v.SetDefault("notifications.events.scope_changed", true)
+	v.SetDefault("notifications.events.synack_target", true)
+	v.SetDefault("notifications.events.synack_mission", true)
+	v.SetDefault("notifications.events.ct_interesting", true)
+	v.SetDefault("notifications.events.engagement_time", true)
 
 	if cfgFile != "" {
 		expandedCfgFile, err := expandTilde(cfgFile)
@@ -179,7 +912,9 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 		v.SetConfigType("yaml")
 	} else {
 		v.AddConfigPath(defaults.ConfigDir)
-		v.AddConfigPath(".")
+		if ActiveProfile() == DefaultProfile {
+			v.AddConfigPath(".") // A named profile never picks up a config file from the working directory
+		}
 		v.SetConfigName("config")
 		v.SetConfigType("yaml")
 	}
@@ -262,6 +997,30 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in proxy.ca_cert_path '%s': %v.\n", AppConfig.Proxy.CACertPath, err)
 	}
+	AppConfig.Commands.WorkDir, err = expandTilde(AppConfig.Commands.WorkDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in command_runner.work_dir '%s': %v.\n", AppConfig.Commands.WorkDir, err)
+	}
+	AppConfig.Tools.BinDir, err = expandTilde(AppConfig.Tools.BinDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in tools.bin_dir '%s': %v.\n", AppConfig.Tools.BinDir, err)
+	}
+	AppConfig.Plugins.Dir, err = expandTilde(AppConfig.Plugins.Dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in plugins.dir '%s': %v.\n", AppConfig.Plugins.Dir, err)
+	}
+	AppConfig.ProxyScripts.Dir, err = expandTilde(AppConfig.ProxyScripts.Dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in proxy_scripts.dir '%s': %v.\n", AppConfig.ProxyScripts.Dir, err)
+	}
+	AppConfig.Attachment.Dir, err = expandTilde(AppConfig.Attachment.Dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in attachments.dir '%s': %v.\n", AppConfig.Attachment.Dir, err)
+	}
+	AppConfig.Wordlists.Dir, err = expandTilde(AppConfig.Wordlists.Dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in wordlists.dir '%s': %v.\n", AppConfig.Wordlists.Dir, err)
+	}
 	AppConfig.Proxy.CAKeyPath, err = expandTilde(AppConfig.Proxy.CAKeyPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in proxy.ca_key_path '%s': %v.\n", AppConfig.Proxy.CAKeyPath, err)
@@ -286,6 +1045,9 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 	}
 
 	logger.Info(configUsedMsg)
+	if profile := ActiveProfile(); profile != DefaultProfile {
+		logger.Info("Using config profile '%s' (%s)", profile, defaults.ConfigDir)
+	}
 	if readErr != nil && cfgFile != "" {
 		logger.Error("Error occurred reading specified config file '%s': %v", cfgFile, readErr)
 	}
@@ -315,6 +1077,11 @@ func Init(cfgFile string, flagAppLogPath, flagProxyLogPath, flagLogLevel string)
 	if AppConfig.Proxy.ModifierAllowLoopback {
 		logger.Warn("Modifier: Requests to loopback addresses are ALLOWED.")
 	}
+	if AppConfig.RateLimit.Enabled {
+		logger.Info("Rate limiting ENABLED: %.2f req/s per host (burst %d), max concurrency %d, per-host concurrency %d, apply to proxy: %t",
+			AppConfig.RateLimit.RequestsPerSecond, AppConfig.RateLimit.Burst, AppConfig.RateLimit.MaxConcurrency,
+			AppConfig.RateLimit.PerHostConcurrency, AppConfig.RateLimit.ApplyToProxy)
+	}
 
 	logger.Debug("Final AppConfig Initialized: %+v", AppConfig)
 	return nil
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileEnvVar selects the config profile when --profile is not given.
+const ProfileEnvVar = "TOOLKIT_PROFILE"
+
+// DefaultProfile is the name of the profile kept directly in the toolkit's config directory.
+const DefaultProfile = "default"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+var selectedProfile string // Set by SetProfile; empty means ProfileEnvVar, then DefaultProfile
+
+// SetProfile selects the named config profile for GetDefaultConfigPaths and Init. An empty name
+// leaves the choice to ProfileEnvVar.
+func SetProfile(name string) error {
+	name = strings.TrimSpace(name)
+	if name != "" && !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	selectedProfile = name
+	return nil
+}
+
+// ActiveProfile returns the name of the selected config profile.
+func ActiveProfile() string {
+	name := selectedProfile
+	if name == "" {
+		name = strings.TrimSpace(os.Getenv(ProfileEnvVar))
+	}
+	if name == "" || !profileNamePattern.MatchString(name) {
+		return DefaultProfile
+	}
+	return name
+}
+
+// baseConfigDir returns the toolkit's config directory, which holds the default profile and the
+// directories of the named ones.
+func baseConfigDir() string {
+	userConfigDirBase, err := os.UserConfigDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not get user config dir: %v. Using current directory.\n", err)
+		userConfigDirBase = "."
+	}
+
+	userConfigDir, err := expandTilde(userConfigDirBase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not expand tilde in user config dir '%s': %v. Using potentially literal path.\n", userConfigDirBase, err)
+		userConfigDir = userConfigDirBase
+	}
+	return filepath.Join(userConfigDir, "toolkit")
+}
+
+// ProfileDir returns the config directory of a profile: the toolkit's config directory for the
+// default profile, profiles/<name> inside it for the others.
+func ProfileDir(name string) string {
+	if name == "" || name == DefaultProfile {
+		return baseConfigDir()
+	}
+	return filepath.Join(baseConfigDir(), "profiles", name)
+}
+
+// ListProfiles returns the default profile followed by the named profiles found on disk.
+func ListProfiles() ([]string, error) {
+	names := []string{DefaultProfile}
+	entries, err := os.ReadDir(filepath.Join(baseConfigDir(), "profiles"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, fmt.Errorf("listing profiles: %w", err)
+	}
+	var named []string
+	for _, e := range entries {
+		if e.IsDir() && profileNamePattern.MatchString(e.Name()) && e.Name() != DefaultProfile {
+			named = append(named, e.Name())
+		}
+	}
+	sort.Strings(named)
+	return append(names, named...), nil
+}
+
+// ReadProfileConfig returns the configuration of a profile as its config file sets it, without
+// defaults. ok is false when the profile has no config file.
+func ReadProfileConfig(name string) (conf Configuration, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(ProfileDir(name), "config.yaml"))
+	if os.IsNotExist(err) {
+		return conf, false, nil
+	}
+	if err != nil {
+		return conf, false, fmt.Errorf("reading config of profile '%s': %w", name, err)
+	}
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return conf, false, fmt.Errorf("parsing config of profile '%s': %w", name, err)
+	}
+	return conf, true, nil
+}
+
+// CreateProfile creates the directory of a named profile with a config file holding the given
+// settings, keyed like "proxy.port", with values written as YAML scalars. Unset options take their
+// defaults on the profile's first use.
+// It returns the path of the config file.
+func CreateProfile(name string, settings map[string]string) (string, error) {
+	if !profileNamePattern.MatchString(name) || name == DefaultProfile {
+		return "", fmt.Errorf("invalid profile name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	dir := ProfileDir(name)
+	path := filepath.Join(dir, "config.yaml")
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("profile '%s' already exists (%s)", name, path)
+	}
+
+	tree := map[string]interface{}{}
+	for key, value := range settings {
+		if value == "" {
+			continue
+		}
+		node := tree
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[part] = child
+			}
+			node = child
+		}
+		var typed interface{} = value
+		if err := yaml.Unmarshal([]byte(value), &typed); err != nil || typed == nil {
+			typed = value
+		}
+		node[parts[len(parts)-1]] = typed
+	}
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return "", fmt.Errorf("encoding config of profile '%s': %w", name, err)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("creating directory of profile '%s': %w", name, err)
+	}
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		return "", fmt.Errorf("writing config of profile '%s': %w", name, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types of runtime settings, as reported by the settings API.
+const (
+	RuntimeSettingBool  = "bool"
+	RuntimeSettingInt   = "int"
+	RuntimeSettingFloat = "float"
+	RuntimeSettingPort  = "port"
+)
+
+// RuntimeSetting describes an option that can be changed through the settings API while the
+// toolkit runs. Its key is the dotted path of the option in config.yaml.
+type RuntimeSetting struct {
+	Key             string  `json:"key"`
+	Type            string  `json:"type"`
+	Description     string  `json:"description"`
+	Min             float64 `json:"min"`              // Lowest accepted value of int and float settings
+	PerTarget       bool    `json:"per_target"`       // Can be overridden for a single target
+	RequiresRestart bool    `json:"requires_restart"` // Stored values only take effect on the next start
+	field           func(c *Configuration) interface{}
+}
+
+// Section is the config section of the setting ("rate_limit" for "rate_limit.burst").
+func (s RuntimeSetting) Section() string {
+	section, _, _ := strings.Cut(s.Key, ".")
+	return section
+}
+
+var runtimeSettings = []RuntimeSetting{
+	{Key: "proxy.port", Type: RuntimeSettingPort, Description: "Port of the MITM proxy", RequiresRestart: true,
+		field: func(c *Configuration) interface{} { return &c.Proxy.Port }},
+	{Key: "proxy.max_capture_bytes", Type: RuntimeSettingInt, Description: "Response body bytes stored per request (0 = unlimited)", PerTarget: true,
+		field: func(c *Configuration) interface{} { return &c.Proxy.MaxCaptureBytes }},
+	{Key: "proxy.performance_mode", Type: RuntimeSettingBool, Description: "Log only a sample of out-of-scope and static-asset proxy traffic",
+		field: func(c *Configuration) interface{} { return &c.Proxy.PerformanceMode }},
+	{Key: "proxy.sample_rate", Type: RuntimeSettingInt, Description: "In performance mode, 1 in this many out-of-scope or static-asset requests is logged", Min: 1,
+		field: func(c *Configuration) interface{} { return &c.Proxy.SampleRate }},
+	{Key: "secrets.max_body_bytes", Type: RuntimeSettingInt, Description: "Bodies larger than this are scanned for secrets only up to this size (0 = unlimited)", PerTarget: true,
+		field: func(c *Configuration) interface{} { return &c.Secrets.MaxBodyBytes }},
+	{Key: "secrets.scan_request_body", Type: RuntimeSettingBool, Description: "Also scan request bodies for secrets", PerTarget: true,
+		field: func(c *Configuration) interface{} { return &c.Secrets.ScanRequestBody }},
+	{Key: "rate_limit.enabled", Type: RuntimeSettingBool, Description: "Throttle toolkit-initiated traffic",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.Enabled }},
+	{Key: "rate_limit.requests_per_second", Type: RuntimeSettingFloat, Description: "Requests per second per host (0 = unlimited)",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.RequestsPerSecond }},
+	{Key: "rate_limit.burst", Type: RuntimeSettingInt, Description: "Requests a host may receive in a burst",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.Burst }},
+	{Key: "rate_limit.max_concurrency", Type: RuntimeSettingInt, Description: "Requests in flight across all hosts (0 = unlimited)",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.MaxConcurrency }},
+	{Key: "rate_limit.per_host_concurrency", Type: RuntimeSettingInt, Description: "Requests in flight per host (0 = unlimited)",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.PerHostConcurrency }},
+	{Key: "rate_limit.apply_to_proxy", Type: RuntimeSettingBool, Description: "Also throttle browser traffic passing through the proxy",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.ApplyToProxy }},
+	{Key: "rate_limit.max_retries", Type: RuntimeSettingInt, Description: "Retries of a request answered with 429",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.MaxRetries }},
+	{Key: "rate_limit.backoff_initial_ms", Type: RuntimeSettingInt, Description: "First backoff after a 429, doubled on each retry",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.BackoffInitialMs }},
+	{Key: "rate_limit.backoff_max_ms", Type: RuntimeSettingInt, Description: "Longest backoff after a 429",
+		field: func(c *Configuration) interface{} { return &c.RateLimit.BackoffMaxMs }},
+	{Key: "response_cache.enabled", Type: RuntimeSettingBool, Description: "Reuse logged responses of repeated toolkit-initiated requests",
+		field: func(c *Configuration) interface{} { return &c.Cache.Enabled }},
+	{Key: "response_cache.window_seconds", Type: RuntimeSettingInt, Description: "How long a logged response is reused", Min: 1,
+		field: func(c *Configuration) interface{} { return &c.Cache.WindowSeconds }},
+	{Key: "synack.analytics_enabled", Type: RuntimeSettingBool, Description: "Fetch Synack target analytics",
+		field: func(c *Configuration) interface{} { return &c.Synack.AnalyticsEnabled }},
+	{Key: "synack.findings_enabled", Type: RuntimeSettingBool, Description: "Store individual Synack findings from the analytics",
+		field: func(c *Configuration) interface{} { return &c.Synack.FindingsEnabled }},
+	{Key: "missions.enabled", Type: RuntimeSettingBool, Description: "Poll for and claim Synack missions",
+		field: func(c *Configuration) interface{} { return &c.Missions.Enabled }},
+	{Key: "synack_watcher.enabled", Type: RuntimeSettingBool, Description: "Watch Synack for new targets and missions",
+		field: func(c *Configuration) interface{} { return &c.Watcher.Enabled }},
+	{Key: "synack_watcher.watch_targets", Type: RuntimeSettingBool, Description: "Announce new Synack targets",
+		field: func(c *Configuration) interface{} { return &c.Watcher.WatchTargets }},
+	{Key: "synack_watcher.watch_missions", Type: RuntimeSettingBool, Description: "Announce new Synack missions",
+		field: func(c *Configuration) interface{} { return &c.Watcher.WatchMissions }},
+}
+
+// RuntimeSettings lists every option that can be changed at runtime.
+func RuntimeSettings() []RuntimeSetting {
+	return runtimeSettings
+}
+
+// LookupRuntimeSetting returns the runtime setting with the given key.
+func LookupRuntimeSetting(key string) (RuntimeSetting, bool) {
+	for _, s := range runtimeSettings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return RuntimeSetting{}, false
+}
+
+// Value returns the current value of the setting in c.
+func (s RuntimeSetting) Value(c *Configuration) interface{} {
+	switch p := s.field(c).(type) {
+	case *bool:
+		return *p
+	case *int:
+		return *p
+	case *int64:
+		return *p
+	case *float64:
+		return *p
+	case *string:
+		return *p
+	}
+	return nil
+}
+
+// Parse validates a JSON value for the setting and returns it in the type Apply expects.
+func (s RuntimeSetting) Parse(raw json.RawMessage) (interface{}, error) {
+	switch s.Type {
+	case RuntimeSettingBool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, fmt.Errorf("%s must be true or false", s.Key)
+		}
+		return b, nil
+	case RuntimeSettingInt, RuntimeSettingFloat:
+		var f float64
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return nil, fmt.Errorf("%s must be a number", s.Key)
+		}
+		if s.Type == RuntimeSettingInt && f != float64(int64(f)) {
+			return nil, fmt.Errorf("%s must be a whole number", s.Key)
+		}
+		if f < s.Min {
+			return nil, fmt.Errorf("%s cannot be lower than %g", s.Key, s.Min)
+		}
+		if s.Type == RuntimeSettingInt {
+			return int64(f), nil
+		}
+		return f, nil
+	case RuntimeSettingPort:
+		// Accept both "8777" and 8777
+		var port string
+		if err := json.Unmarshal(raw, &port); err != nil {
+			var n int
+			if errNum := json.Unmarshal(raw, &n); errNum != nil {
+				return nil, fmt.Errorf("%s must be a port number", s.Key)
+			}
+			port = strconv.Itoa(n)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(port))
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("%s must be a port between 1 and 65535", s.Key)
+		}
+		return strconv.Itoa(n), nil
+	}
+	return nil, fmt.Errorf("%s has unknown type %s", s.Key, s.Type)
+}
+
+// Apply sets the setting in c to a value returned by Parse.
+func (s RuntimeSetting) Apply(c *Configuration, value interface{}) error {
+	switch p := s.field(c).(type) {
+	case *bool:
+		if v, ok := value.(bool); ok {
+			*p = v
+			return nil
+		}
+	case *int:
+		if v, ok := value.(int64); ok {
+			*p = int(v)
+			return nil
+		}
+	case *int64:
+		if v, ok := value.(int64); ok {
+			*p = v
+			return nil
+		}
+	case *float64:
+		if v, ok := value.(float64); ok {
+			*p = v
+			return nil
+		}
+	case *string:
+		if v, ok := value.(string); ok {
+			*p = v
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %v for %s", value, s.Key)
+}
+
+// Copy sets the setting in dst to its value in src.
+func (s RuntimeSetting) Copy(dst, src *Configuration) {
+	switch p := s.field(dst).(type) {
+	case *bool:
+		*p = *s.field(src).(*bool)
+	case *int:
+		*p = *s.field(src).(*int)
+	case *int64:
+		*p = *s.field(src).(*int64)
+	case *float64:
+		*p = *s.field(src).(*float64)
+	case *string:
+		*p = *s.field(src).(*string)
+	}
+}
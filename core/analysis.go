@@ -2,7 +2,6 @@ package core
 
 import (
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath" // For cleaning paths
@@ -11,6 +10,7 @@ import (
 	"strings"
 	"toolkit/database"
 	"toolkit/logger"
+	"toolkit/models"
 
 	"github.com/BishopFox/jsluice" // Correct casing
 )
@@ -44,15 +44,12 @@ func processSlice(items []string) []string {
 // It saves discovered URLs to the database and returns the extracted data map.
 func AnalyzeJSContent(jsContentBytes []byte, httpLogID int64) (map[string][]string, error) {
 	logger.Info("Analyzing JS content for log ID %d (%d bytes)", httpLogID, len(jsContentBytes))
-	results := make(map[string][]string)
-	// Convert jsContent to string for custom regex operations
-	jsContentStr := string(jsContentBytes)
-
-	analyzer := jsluice.NewAnalyzer(jsContentBytes)
+	results := extractJSluiceResults(jsContentBytes)
 
 	// --- Fetch TargetID associated with this log entry ---
 	var targetID sql.NullInt64
-	err := database.DB.QueryRow("SELECT target_id FROM http_traffic_log WHERE id = ?", httpLogID).Scan(&targetID)
+	var requestURL sql.NullString
+	err := database.DB.QueryRow("SELECT target_id, request_url FROM http_traffic_log WHERE id = ?", httpLogID).Scan(&targetID, &requestURL)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Error("AnalyzeJSContent: Could not find http_traffic_log entry with ID %d", httpLogID)
@@ -63,18 +60,45 @@ func AnalyzeJSContent(jsContentBytes []byte, httpLogID int64) (map[string][]stri
 	logger.Debug("AnalyzeJSContent: Log ID %d is associated with Target ID %v (Valid: %t)", httpLogID, targetID.Int64, targetID.Valid)
 	// --- End Fetch TargetID ---
 
-	// Prepare statement for inserting discovered URLs
-	stmt, err := database.DB.Prepare(`
-		INSERT INTO discovered_urls (target_id, http_traffic_log_id, url, source_type)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(http_traffic_log_id, url, source_type) DO NOTHING`)
-	if err != nil {
-		logger.Error("AnalyzeJSContent: Error preparing discovered_urls insert statement: %v", err)
-		// Continue analysis but cannot save URLs
+	// Save discovered URLs with their canonical form, resolved against the script's URL
+	var rules []models.ScopeRule
+	if targetID.Valid {
+		if rules, err = database.GetScopeRulesByTargetID(targetID.Int64); err != nil {
+			logger.Error("AnalyzeJSContent: Error loading scope rules of target %d: %v", targetID.Int64, err)
+		}
+	}
+	entries := canonicalURLEntries(results["URLs"], requestURL.String, rules)
+	if added, err := database.InsertDiscoveredURLs(targetID.Int64, httpLogID, "jsluice", entries); err != nil {
+		logger.Error("AnalyzeJSContent: Error saving discovered URLs for log %d: %v", httpLogID, err)
+	} else {
+		logger.Debug("AnalyzeJSContent: Saved %d new discovered URLs of %d for log %d", added, len(entries), httpLogID)
+	}
+
+	if len(results) == 0 {
+		logger.Info("No interesting items found by jsluice for log ID %d", httpLogID)
+		return nil, fmt.Errorf("no interesting items found")
+	}
+
+	// Store processed results in the database
+	if err := saveAnalyzerFindings(httpLogID, jsluiceAnalyzerName, findingsFromCategories(results)); err != nil {
+		// Return the results even if storing them failed.
+		logger.Error("AnalyzeJSContent: %v", err)
 	} else {
-		defer stmt.Close()
+		logger.Info("AnalyzeJSContent: Successfully stored jsluice analysis results for log ID %d", httpLogID)
 	}
 
+	return results, nil
+}
+
+// extractJSluiceResults runs jsluice and the path regexes over JavaScript content and returns
+// the extracted items by category.
+func extractJSluiceResults(jsContentBytes []byte) map[string][]string {
+	results := make(map[string][]string)
+	// Convert jsContent to string for custom regex operations
+	jsContentStr := string(jsContentBytes)
+
+	analyzer := jsluice.NewAnalyzer(jsContentBytes)
+
 	// Extract URLs
 	urlsFound := []string{}
 	for _, urlMatch := range analyzer.GetURLs() {
@@ -85,19 +109,6 @@ func AnalyzeJSContent(jsContentBytes []byte, httpLogID int64) (map[string][]stri
 		// Allow only http and https schemes
 		if urlStr != "" && (strings.HasPrefix(strings.ToLower(urlStr), "http:") || strings.HasPrefix(strings.ToLower(urlStr), "https:")) {
 			urlsFound = append(urlsFound, urlStr)
-
-			if stmt != nil {
-				var targetIDArg interface{}
-				if targetID.Valid {
-					targetIDArg = targetID.Int64
-				}
-				_, execErr := stmt.Exec(targetIDArg, httpLogID, urlStr, "jsluice")
-				if execErr != nil {
-					logger.Error("AnalyzeJSContent: Error inserting discovered URL '%s' for log %d: %v", urlStr, httpLogID, execErr)
-				} else {
-					logger.Debug("AnalyzeJSContent: Saved discovered URL '%s' for log %d", urlStr, httpLogID)
-				}
-			}
 		}
 	}
 	if len(urlsFound) > 0 {
@@ -193,31 +204,5 @@ func AnalyzeJSContent(jsContentBytes []byte, httpLogID int64) (map[string][]stri
 	// 	results["DOM XSS Sinks"] = processSlice(domXSSSinks)
 	// }
 
-	if len(results) == 0 {
-		logger.Info("No interesting items found by jsluice for log ID %d", httpLogID)
-		return nil, fmt.Errorf("no interesting items found")
-	}
-
-	// Store processed results in the database
-	analysisType := "jsluice"
-	resultDataBytes, err := json.Marshal(results)
-	if err != nil {
-		logger.Error("AnalyzeJSContent: Error marshalling analysis results for log ID %d: %v", httpLogID, err)
-		// Proceed to return results even if marshalling/DB store fails, but log the error.
-	} else {
-		// Delete old results for this log_id and analysis_type, then insert new
-		_, delErr := database.DB.Exec("DELETE FROM analysis_results WHERE http_log_id = ? AND analysis_type = ?", httpLogID, analysisType)
-		if delErr != nil {
-			logger.Error("AnalyzeJSContent: Error deleting old analysis results for log ID %d: %v", httpLogID, delErr)
-		}
-
-		_, insErr := database.DB.Exec("INSERT INTO analysis_results (http_log_id, analysis_type, result_data) VALUES (?, ?, ?)", httpLogID, analysisType, string(resultDataBytes))
-		if insErr != nil {
-			logger.Error("AnalyzeJSContent: Error inserting analysis results for log ID %d: %v", httpLogID, insErr)
-		} else {
-			logger.Info("AnalyzeJSContent: Successfully stored jsluice analysis results for log ID %d", httpLogID)
-		}
-	}
-
-	return results, nil
+	return results
 }
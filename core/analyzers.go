@@ -0,0 +1,339 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// AnalyzerFinding is one item an analyzer extracted from a response body.
+type AnalyzerFinding struct {
+	Category string `json:"category"`
+	Value    string `json:"value"`
+	Context  string `json:"context,omitempty"`
+}
+
+// Analyzer extracts findings from captured response bodies. Results are stored in
+// analysis_results with the analyzer name as analysis_type.
+type Analyzer interface {
+	Name() string
+	// Supports reports whether the analyzer applies to a response of this Content-Type.
+	Supports(contentType string) bool
+	Analyze(body []byte) ([]AnalyzerFinding, error)
+}
+
+const (
+	jsluiceAnalyzerName  = "jsluice"
+	secretsAnalyzerName  = "secrets"
+	commentsAnalyzerName = "comments"
+	endpointAnalyzerName = "endpoints"
+	bucketsAnalyzerName  = "buckets"
+
+	maxAnalyzerContext = 200
+)
+
+var (
+	analyzersMu sync.RWMutex
+	analyzers   = map[string]Analyzer{}
+)
+
+func init() {
+	RegisterAnalyzer(jsluiceAnalyzer{})
+	RegisterAnalyzer(secretsAnalyzer{})
+	RegisterAnalyzer(commentsAnalyzer{})
+	RegisterAnalyzer(endpointAnalyzer{})
+	RegisterAnalyzer(bucketsAnalyzer{})
+}
+
+// RegisterAnalyzer makes an analyzer available to AnalyzeTrafficLog, replacing any analyzer
+// registered under the same name.
+func RegisterAnalyzer(a Analyzer) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	analyzers[a.Name()] = a
+}
+
+// AnalyzerNames returns the names of the registered analyzers and enabled analyzer plugins,
+// sorted.
+func AnalyzerNames() []string {
+	analyzersMu.RLock()
+	names := make([]string, 0, len(analyzers))
+	for name := range analyzers {
+		names = append(names, name)
+	}
+	analyzersMu.RUnlock()
+	for _, a := range pluginAnalyzers() {
+		names = append(names, a.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetAnalyzer returns the analyzer registered under name, or the enabled analyzer plugin of that
+// name.
+func GetAnalyzer(name string) (Analyzer, bool) {
+	name = strings.ToLower(name)
+	analyzersMu.RLock()
+	a, ok := analyzers[name]
+	analyzersMu.RUnlock()
+	if ok {
+		return a, true
+	}
+	for _, a := range pluginAnalyzers() {
+		if a.Name() == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// AnalyzeTrafficLog runs the named analyzers, or all registered ones when names is empty, over
+// the response body of a traffic log entry (its beautified copy for JavaScript and HTML with
+// beautify.analysis) and stores their findings. Analyzers that do not
+// support the response Content-Type are skipped. The findings are returned by analyzer name.
+func AnalyzeTrafficLog(logID int64, names []string) (map[string][]AnalyzerFinding, error) {
+	if len(names) == 0 {
+		names = AnalyzerNames()
+	}
+	selected := make([]Analyzer, 0, len(names))
+	for _, name := range names {
+		a, ok := GetAnalyzer(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown analyzer '%s' (available: %s)", name, strings.Join(AnalyzerNames(), ", "))
+		}
+		selected = append(selected, a)
+	}
+
+	logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		return nil, err
+	}
+	if logEntry.CachedFromLogID.Valid {
+		return nil, fmt.Errorf("response of log %d was reused from log %d by the response cache; analyze log %d instead", logID, logEntry.CachedFromLogID.Int64, logEntry.CachedFromLogID.Int64)
+	}
+	if len(logEntry.ResponseBody) == 0 {
+		return nil, fmt.Errorf("response body of log %d is empty", logID)
+	}
+	body, err := DecodeContentEncoding(logEntry.ResponseBody, HeadersFromJSON(logEntry.ResponseHeaders.String).Get("Content-Encoding"))
+	if err != nil {
+		logger.Debug("AnalyzeTrafficLog: Could not decode body of log %d, analyzing as stored: %v", logID, err)
+	}
+	contentType := logEntry.ResponseContentType.String
+	// Minified code puts everything on one line, which line-based rules (comments) miss and
+	// contexts make unreadable.
+	if config.AppConfig.Beautify.Analysis && (IsJavaScriptContentType(contentType) || strings.Contains(strings.ToLower(contentType), "html")) {
+		body = ReadableResponseBody(logEntry)
+	}
+
+	results := make(map[string][]AnalyzerFinding)
+	for _, a := range selected {
+		if !a.Supports(contentType) {
+			continue
+		}
+		findings, err := a.Analyze(body)
+		if err != nil {
+			logger.Error("AnalyzeTrafficLog: %s analyzer failed on log %d: %v", a.Name(), logID, err)
+			continue
+		}
+		if err := saveAnalyzerFindings(logID, a.Name(), findings); err != nil {
+			return results, err
+		}
+		results[a.Name()] = findings
+	}
+	return results, nil
+}
+
+// saveAnalyzerFindings stores the findings of an analyzer for a traffic log entry.
+func saveAnalyzerFindings(logID int64, analyzer string, findings []AnalyzerFinding) error {
+	if findings == nil {
+		findings = []AnalyzerFinding{}
+	}
+	data, err := json.Marshal(findings)
+	if err != nil {
+		return fmt.Errorf("encoding %s findings of log %d: %w", analyzer, logID, err)
+	}
+	return database.SaveAnalysisResult(logID, analyzer, string(data))
+}
+
+// findingsFromCategories flattens results grouped by category, sorted by category.
+func findingsFromCategories(results map[string][]string) []AnalyzerFinding {
+	categories := make([]string, 0, len(results))
+	for category := range results {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	var findings []AnalyzerFinding
+	for _, category := range categories {
+		for _, value := range results[category] {
+			findings = append(findings, AnalyzerFinding{Category: category, Value: value})
+		}
+	}
+	return findings
+}
+
+// jsluiceAnalyzer extracts URLs, secrets, strings and paths from JavaScript with jsluice.
+type jsluiceAnalyzer struct{}
+
+func (jsluiceAnalyzer) Name() string { return jsluiceAnalyzerName }
+
+func (jsluiceAnalyzer) Supports(contentType string) bool { return IsJavaScriptContentType(contentType) }
+
+func (jsluiceAnalyzer) Analyze(body []byte) ([]AnalyzerFinding, error) {
+	return findingsFromCategories(extractJSluiceResults(body)), nil
+}
+
+// secretsAnalyzer runs the secret detection rules. Findings hold the redacted secret, never the
+// secret itself.
+type secretsAnalyzer struct{}
+
+func (secretsAnalyzer) Name() string { return secretsAnalyzerName }
+
+func (secretsAnalyzer) Supports(contentType string) bool { return isScannableContentType(contentType) }
+
+func (secretsAnalyzer) Analyze(body []byte) ([]AnalyzerFinding, error) {
+	var findings []AnalyzerFinding
+	for _, m := range ScanForSecrets(body) {
+		findings = append(findings, AnalyzerFinding{
+			Category: m.RuleName,
+			Value:    RedactSecret(m.Secret),
+			Context:  m.Excerpt,
+		})
+	}
+	return findings, nil
+}
+
+var (
+	htmlCommentRegex  = regexp.MustCompile(`(?s)<!--(.*?)-->`)
+	blockCommentRegex = regexp.MustCompile(`(?s)/\*(.*?)\*/`)
+	// Only comments starting a line, so "//" inside URLs is not taken for a comment.
+	lineCommentRegex = regexp.MustCompile(`(?m)^[ \t]*//(.*)$`)
+)
+
+// commentsAnalyzer extracts developer comments from HTML, JavaScript and CSS, which often leak
+// internal hosts, TODOs or disabled features.
+type commentsAnalyzer struct{}
+
+func (commentsAnalyzer) Name() string { return commentsAnalyzerName }
+
+func (commentsAnalyzer) Supports(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return strings.Contains(ct, "html") || strings.Contains(ct, "xml") || strings.Contains(ct, "css") || IsJavaScriptContentType(ct)
+}
+
+func (commentsAnalyzer) Analyze(body []byte) ([]AnalyzerFinding, error) {
+	content := string(body)
+	var findings []AnalyzerFinding
+	seen := make(map[string]bool)
+	collect := func(category string, re *regexp.Regexp) {
+		for _, match := range re.FindAllStringSubmatch(content, -1) {
+			comment := strings.ToValidUTF8(strings.Join(strings.Fields(match[1]), " "), "")
+			if comment == "" || seen[category+"\x00"+comment] {
+				continue
+			}
+			seen[category+"\x00"+comment] = true
+			findings = append(findings, AnalyzerFinding{Category: category, Value: truncateString(comment, maxAnalyzerContext)})
+		}
+	}
+	collect("HTML Comment", htmlCommentRegex)
+	collect("Block Comment", blockCommentRegex)
+	collect("Line Comment", lineCommentRegex)
+	return findings, nil
+}
+
+var (
+	absoluteURLRegex   = regexp.MustCompile(`https?://[^\s"'<>\\)\]]+`)
+	htmlAttributeRegex = regexp.MustCompile(`(?i)\b(?:href|src|action|formaction|data-url)\s*=\s*["']([^"'#][^"']*)["']`)
+	inlineScriptRegex  = regexp.MustCompile(`(?is)<script[^>]*>(.*?)</script>`)
+)
+
+// endpointAnalyzer extracts URLs and paths from any text response: jsluice endpoints for
+// JavaScript, link attributes for HTML and quoted paths and absolute URLs for everything else.
+type endpointAnalyzer struct{}
+
+func (endpointAnalyzer) Name() string { return endpointAnalyzerName }
+
+func (endpointAnalyzer) Supports(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	if ct == "" {
+		return true
+	}
+	for _, textual := range []string{"text/", "json", "xml", "javascript", "ecmascript"} {
+		if strings.Contains(ct, textual) {
+			return true
+		}
+	}
+	return false
+}
+
+func (endpointAnalyzer) Analyze(body []byte) ([]AnalyzerFinding, error) {
+	var findings []AnalyzerFinding
+	seen := make(map[string]bool)
+	add := func(category, value, context string) {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[category+"\x00"+value] {
+			return
+		}
+		seen[category+"\x00"+value] = true
+		findings = append(findings, AnalyzerFinding{Category: category, Value: value, Context: truncateString(context, maxAnalyzerContext)})
+	}
+
+	content := string(body)
+	var scripts []string
+	switch trimmed := strings.TrimSpace(content); {
+	case strings.HasPrefix(trimmed, "<"):
+		for _, match := range inlineScriptRegex.FindAllStringSubmatch(content, -1) {
+			scripts = append(scripts, match[1])
+		}
+	case strings.HasPrefix(trimmed, "{"), strings.HasPrefix(trimmed, "["):
+		// JSON: only the regexes below apply.
+	default:
+		scripts = append(scripts, content)
+	}
+	for _, script := range scripts {
+		for _, e := range ExtractJSEndpoints([]byte(script), "") {
+			add(e.Kind, e.Value, e.Context.String)
+		}
+	}
+	for _, match := range htmlAttributeRegex.FindAllStringSubmatch(content, -1) {
+		if strings.HasPrefix(strings.ToLower(match[1]), "javascript:") || strings.HasPrefix(strings.ToLower(match[1]), "data:") {
+			continue
+		}
+		if strings.Contains(match[1], "://") {
+			add(models.JSEndpointKindURL, match[1], match[0])
+		} else {
+			add(models.JSEndpointKindPath, match[1], match[0])
+		}
+	}
+	for _, match := range absoluteURLRegex.FindAllString(content, -1) {
+		add(models.JSEndpointKindURL, strings.TrimRight(match, ".,;"), "")
+	}
+	for _, match := range generalPathRegexGlobal.FindAllStringSubmatch(content, -1) {
+		add(models.JSEndpointKindPath, match[1], match[0])
+	}
+	return findings, nil
+}
+
+// bucketsAnalyzer extracts S3, GCS and Azure Blob bucket references (URLs, s3:// and gs:// URIs)
+// from text responses. The cloud storage checker picks them up from analysis_results.
+type bucketsAnalyzer struct{}
+
+func (bucketsAnalyzer) Name() string { return bucketsAnalyzerName }
+
+func (bucketsAnalyzer) Supports(contentType string) bool {
+	return endpointAnalyzer{}.Supports(contentType)
+}
+
+func (bucketsAnalyzer) Analyze(body []byte) ([]AnalyzerFinding, error) {
+	var findings []AnalyzerFinding
+	for _, ref := range ExtractCloudBuckets(string(body)) {
+		findings = append(findings, AnalyzerFinding{Category: ref.Provider, Value: ref.Name, Context: truncateString(ref.Match, maxAnalyzerContext)})
+	}
+	return findings, nil
+}
@@ -0,0 +1,355 @@
+package core
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"toolkit/config"
+	"toolkit/models"
+)
+
+// APIRoleAdmin is the role that API responses withhold nothing from.
+const APIRoleAdmin = "admin"
+
+// credentialNameParts mark field, header, variable and setting names holding credentials.
+var credentialNameParts = []string{"token", "password", "passwd", "secret", "api_key", "apikey", "authorization", "cookie", "credential", "private_key"}
+
+// nonCredentialNameSuffixes mark names about a credential rather than holding one (token_id,
+// cookie_name, secret_hash, ...).
+var nonCredentialNameSuffixes = []string{"_id", "_ids", "_at", "_count", "_name", "_type", "_kind", "_hash", "_bits", "_source", "_status"}
+
+// secretMatchFields are the fields of secrets scanner results holding (part of) a match.
+var secretMatchFields = map[string]bool{"match_excerpt": true, "secret_hash": true, "matched_value": true}
+
+// credentialValueRoutes are the API routes whose "value" fields hold credentials whatever their
+// names, such as the cookies of a target's cookie jar.
+var credentialValueRoutes = []string{"/targets/{target_id}/sessions"}
+
+// ResolveAPIRole returns the role of an API request from the bearer token of its Authorization
+// header, and who holds the token. Requests without a token have api_access.default_role.
+func ResolveAPIRole(authorization string) (role, holder string, err error) {
+	conf := config.AppConfig.APIAccess
+	token, ok := strings.CutPrefix(strings.TrimSpace(authorization), "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		if conf.DefaultRole == "" {
+			return APIRoleAdmin, "", nil
+		}
+		return strings.ToLower(conf.DefaultRole), "", nil
+	}
+	token = strings.TrimSpace(token)
+	for _, t := range conf.Tokens {
+		if t.Token != "" && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return strings.ToLower(t.Role), t.Name, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown API token")
+}
+
+// APIRoleRedaction returns what API responses withhold from a role, and whether that is anything.
+// Roles missing from api_access.roles, other than admin, get every redaction.
+func APIRoleRedaction(role string) (config.APIRoleConfig, bool) {
+	if role == APIRoleAdmin {
+		return config.APIRoleConfig{}, false
+	}
+	policy, ok := config.AppConfig.APIAccess.Roles[role]
+	if !ok {
+		policy = config.APIRoleConfig{RedactCredentials: true, RedactSecrets: true, MaxBodyBytes: 65536}
+	}
+	return policy, policy.RedactCredentials || policy.RedactSecrets || policy.MaxBodyBytes > 0
+}
+
+// RedactAPIResponse applies a role's redaction to a JSON response of the API route with the given
+// pattern. It reports whether anything was withheld.
+func RedactAPIResponse(route string, body []byte, policy config.APIRoleConfig) ([]byte, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return body, false, err
+	}
+	r := newAPIRedactor(policy)
+	for _, pattern := range credentialValueRoutes {
+		r.valueIsCredential = r.valueIsCredential || route == pattern
+	}
+	doc = r.walk(doc, r.valueIsCredential)
+	if !r.changed {
+		return body, false, nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return body, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// RedactAPIText applies a role's redaction to a text response of the API (exports, previews and
+// pretty-printed bodies): credential header values are masked and the text is cut to the body limit.
+func RedactAPIText(body []byte, policy config.APIRoleConfig) ([]byte, bool) {
+	r := newAPIRedactor(policy)
+	text := string(body)
+	if policy.RedactCredentials {
+		text = r.redactHeaderLines(text)
+	}
+	if policy.MaxBodyBytes > 0 && len(text) > policy.MaxBodyBytes {
+		text = cutUTF8(text, policy.MaxBodyBytes) + fmt.Sprintf("\n[%d bytes withheld]\n", len(text)-policy.MaxBodyBytes)
+		r.changed = true
+	}
+	return []byte(text), r.changed
+}
+
+// apiRedactor walks a decoded JSON response applying a role's redaction.
+type apiRedactor struct {
+	policy            config.APIRoleConfig
+	rules             models.RedactionRules // Credential headers and URL parameters of the redaction config
+	headerLine        *regexp.Regexp        // "Name: value" of a credential header in raw HTTP or curl commands
+	valueIsCredential bool
+	changed           bool
+}
+
+func newAPIRedactor(policy config.APIRoleConfig) *apiRedactor {
+	rules := GlobalRedactionRules()
+	names := make([]string, 0, len(rules.Headers))
+	for _, h := range rules.Headers {
+		names = append(names, regexp.QuoteMeta(h))
+	}
+	r := &apiRedactor{policy: policy, rules: rules}
+	if len(names) > 0 {
+		r.headerLine = regexp.MustCompile(`(?im)\b(` + strings.Join(names, "|") + `)(\s*:[ \t]*)[^'"\r\n]+`)
+	}
+	return r
+}
+
+// isCredentialName reports whether a field, header, variable or setting name (the last part of a
+// dotted key) holds a credential.
+func isCredentialName(name string) bool {
+	name = strings.ToLower(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ReplaceAll(name, "-", "_")
+	for _, suffix := range nonCredentialNameSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	for _, part := range credentialNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBodyField reports whether a field holds a request or response body.
+func isBodyField(name string) bool {
+	return name == "body" || strings.HasSuffix(name, "_body") || name == "body_text" || name == "body_html"
+}
+
+// walk redacts a JSON value. Within credential containers, "value" fields are credentials too.
+func (r *apiRedactor) walk(node interface{}, valueIsCredential bool) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		r.redactObject(v, valueIsCredential)
+	case []interface{}:
+		for i := range v {
+			v[i] = r.walk(v[i], valueIsCredential)
+		}
+	}
+	return node
+}
+
+func (r *apiRedactor) redactObject(obj map[string]interface{}, valueIsCredential bool) {
+	withheld := map[string]int{}
+	for key, value := range obj {
+		var n int
+		if wrapper, ok := value.(map[string]interface{}); ok && isNullStringObject(wrapper) {
+			wrapper["String"], n = r.redactField(obj, strings.ToLower(key), wrapper["String"], valueIsCredential)
+		} else {
+			obj[key], n = r.redactField(obj, strings.ToLower(key), value, valueIsCredential)
+		}
+		if n > 0 {
+			withheld[key] = n
+		}
+	}
+	for key, n := range withheld {
+		obj[key+"_withheld_bytes"] = n
+	}
+}
+
+// isNullStringObject reports whether an object is a serialized sql.NullString, which is how most
+// fields of log entries are served.
+func isNullStringObject(obj map[string]interface{}) bool {
+	_, hasString := obj["String"].(string)
+	_, hasValid := obj["Valid"].(bool)
+	return hasString && hasValid && len(obj) == 2
+}
+
+// redactField redacts the value of a field of obj and returns it with the number of body bytes
+// withheld.
+func (r *apiRedactor) redactField(obj map[string]interface{}, name string, value interface{}, valueIsCredential bool) (interface{}, int) {
+	s, isString := value.(string)
+	switch {
+	case r.policy.RedactSecrets && secretMatchFields[name]:
+		return r.mask(value), 0
+	case r.policy.RedactCredentials && name == "value" && (valueIsCredential || isNamedCredential(obj)):
+		return r.mask(value), 0
+	case r.policy.RedactCredentials && strings.HasSuffix(name, "headers"):
+		return r.redactHeaders(value), 0
+	case r.policy.RedactCredentials && isCredentialName(name):
+		return r.mask(value), 0
+	case r.policy.RedactCredentials && isString && (name == "url" || strings.HasSuffix(name, "_url") || strings.Contains(name, "_url_")):
+		if redacted := redactURLParameters(s, r.rules); redacted != s {
+			r.changed = true
+			return redacted, 0
+		}
+		return s, 0
+	case r.policy.RedactCredentials && isString && (name == "raw" || strings.HasSuffix(name, "_raw")):
+		return r.redactHeaderLines(s), 0
+	case r.policy.MaxBodyBytes > 0 && isString && isBodyField(name):
+		return r.cutBody(s)
+	default:
+		return r.walk(value, valueIsCredential), 0
+	}
+}
+
+// isNamedCredential reports whether an object is a named value (variable, setting, header) whose
+// name marks a credential.
+func isNamedCredential(obj map[string]interface{}) bool {
+	for _, field := range []string{"key", "name"} {
+		if s, ok := obj[field].(string); ok && isCredentialName(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask replaces the strings of a credential value; objects within it are walked with their
+// "value" fields taken as credentials.
+func (r *apiRedactor) mask(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" || v == r.rules.Replacement {
+			return v
+		}
+		r.changed = true
+		return r.rules.Replacement
+	case []interface{}:
+		for i := range v {
+			v[i] = r.mask(v[i])
+		}
+	case map[string]interface{}:
+		if isStringMap(v) {
+			for key := range v {
+				v[key] = r.mask(v[key])
+			}
+			return v
+		}
+		r.redactObject(v, true)
+	}
+	return value
+}
+
+// isStringMap reports whether an object maps names to strings or string lists, like headers or
+// cookies by name, rather than being a record.
+func isStringMap(obj map[string]interface{}) bool {
+	for _, value := range obj {
+		switch v := value.(type) {
+		case string:
+		case []interface{}:
+			for _, item := range v {
+				if _, ok := item.(string); !ok {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return len(obj) > 0
+}
+
+// redactHeaders masks the credential headers of a header map, given as an object or as a JSON
+// string (how logged headers are stored), or of a raw header block.
+func (r *apiRedactor) redactHeaders(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		var headers map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &headers); err == nil && headers != nil {
+			before := r.changed
+			r.changed = false
+			r.redactHeaderMap(headers)
+			if !r.changed {
+				r.changed = before
+				return v
+			}
+			data, _ := json.Marshal(headers)
+			return string(data)
+		}
+		return r.redactHeaderLines(v)
+	case map[string]interface{}:
+		r.redactHeaderMap(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = r.walk(v[i], false)
+		}
+	}
+	return value
+}
+
+func (r *apiRedactor) redactHeaderMap(headers map[string]interface{}) {
+	for name, values := range headers {
+		if containsFold(r.rules.Headers, name) || isCredentialName(name) {
+			headers[name] = r.mask(values)
+		}
+	}
+}
+
+// redactHeaderLines masks the values of credential headers in raw HTTP messages and curl commands.
+func (r *apiRedactor) redactHeaderLines(s string) string {
+	if r.headerLine == nil {
+		return s
+	}
+	redacted := r.headerLine.ReplaceAllString(s, "${1}${2}"+r.rules.Replacement)
+	if redacted != s {
+		r.changed = true
+	}
+	return redacted
+}
+
+// cutBody cuts a body to the role's limit and returns how many bytes were withheld. Bodies of
+// log entries are base64-encoded in API responses and stay valid base64.
+func (r *apiRedactor) cutBody(s string) (string, int) {
+	limit := r.policy.MaxBodyBytes
+	if len(s) <= limit {
+		return s, 0
+	}
+	if len(s)%4 == 0 {
+		if data, err := base64.StdEncoding.DecodeString(s); err == nil {
+			if len(data) <= limit {
+				return s, 0
+			}
+			r.changed = true
+			return base64.StdEncoding.EncodeToString(data[:limit]), len(data) - limit
+		}
+	}
+	r.changed = true
+	cut := cutUTF8(s, limit)
+	return cut, len(s) - len(cut)
+}
+
+// cutUTF8 cuts s to at most n bytes without splitting a character.
+func cutUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
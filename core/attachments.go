@@ -0,0 +1,288 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// attachmentTempPrefix names uploads being written to the attachments directory.
+const attachmentTempPrefix = ".upload-"
+
+// attachmentPath returns where the file with the given SHA-256 is stored.
+func attachmentPath(sum string) string {
+	return filepath.Join(config.AppConfig.Attachment.Dir, sum[:2], sum)
+}
+
+// isAttachmentHash reports whether name is a stored file's name, a hex SHA-256.
+func isAttachmentHash(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+// AttachFile stores a file and attaches it to a target, finding, note or checklist item. Files
+// are stored once per SHA-256: attaching content already on disk only adds a row, and attaching
+// the same file to the same entity again returns the existing attachment. Files larger than
+// attachments.max_file_bytes, or that would take the stored files past attachments.max_total_bytes,
+// are rejected.
+func AttachFile(entityType string, entityID int64, fileName, contentType, description string, content io.Reader) (models.Attachment, error) {
+	targetID, err := database.GetAttachmentEntityTargetID(entityType, entityID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	conf := config.AppConfig.Attachment
+	if err := os.MkdirAll(conf.Dir, 0750); err != nil {
+		return models.Attachment{}, fmt.Errorf("creating attachments directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(conf.Dir, attachmentTempPrefix+"*")
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("creating upload file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed into place
+
+	hash := sha256.New()
+	sniff := &headBuffer{limit: 512}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, sniff), io.LimitReader(content, conf.MaxFileBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("storing upload: %w", err)
+	}
+	if size > conf.MaxFileBytes {
+		return models.Attachment{}, fmt.Errorf("attachment too large: files are limited to %d bytes (attachments.max_file_bytes)", conf.MaxFileBytes)
+	}
+	if size == 0 {
+		return models.Attachment{}, fmt.Errorf("invalid attachment: the file is empty")
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	path := attachmentPath(sum)
+	stored := false
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if conf.MaxTotalBytes > 0 {
+			used, err := attachmentStorageBytes()
+			if err != nil {
+				return models.Attachment{}, err
+			}
+			if used+size > conf.MaxTotalBytes {
+				return models.Attachment{}, fmt.Errorf("attachment too large: stored files would exceed %d bytes (attachments.max_total_bytes)", conf.MaxTotalBytes)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return models.Attachment{}, fmt.Errorf("creating attachments directory: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return models.Attachment{}, fmt.Errorf("storing upload: %w", err)
+		}
+		stored = true
+	}
+
+	fileName = attachmentFileName(fileName)
+	id, existing, err := database.CreateAttachment(models.Attachment{
+		TargetID:    targetID,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		FileName:    fileName,
+		ContentType: attachmentContentType(fileName, contentType, sniff.buf),
+		Size:        size,
+		SHA256:      sum,
+		Description: strings.TrimSpace(description),
+	})
+	if err != nil {
+		if stored {
+			removeUnusedAttachmentFile(sum)
+		}
+		return models.Attachment{}, err
+	}
+	attachment, err := database.GetAttachmentByID(id)
+	attachment.Existing = existing
+	if err == nil && !existing {
+		logger.Info("Attached %s (%d bytes, %s) to %s %d", attachment.FileName, size, sum[:12], entityType, entityID)
+	}
+	return attachment, err
+}
+
+// headBuffer keeps the first bytes written to it, for content type detection.
+type headBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if room := h.limit - len(h.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		h.buf = append(h.buf, p[:room]...)
+	}
+	return len(p), nil
+}
+
+// attachmentFileName strips directories and control characters from an uploaded file's name.
+func attachmentFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// attachmentContentType keeps the declared content type unless it is missing or generic, then
+// guesses from the file extension and the content.
+func attachmentContentType(fileName, declared string, head []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(declared); err == nil && mediaType != "application/octet-stream" {
+		return declared
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExt != "" {
+		return byExt
+	}
+	return http.DetectContentType(head)
+}
+
+// attachmentStorageBytes returns the size of the stored files.
+func attachmentStorageBytes() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(config.AppConfig.Attachment.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isAttachmentHash(d.Name()) {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("measuring attachments directory: %w", err)
+	}
+	return total, nil
+}
+
+// GetAttachments lists attachments, newest first.
+func GetAttachments(filters models.AttachmentFilters) ([]models.Attachment, error) {
+	if filters.EntityType != "" && !slices.Contains(models.AttachmentEntityTypes, filters.EntityType) {
+		return nil, fmt.Errorf("unsupported entity type '%s'", filters.EntityType)
+	}
+	return database.GetAttachments(filters)
+}
+
+// GetAttachment returns the metadata of an attachment.
+func GetAttachment(id int64) (models.Attachment, error) {
+	return database.GetAttachmentByID(id)
+}
+
+// OpenAttachment returns an attachment and its content, which the caller must close.
+func OpenAttachment(id int64) (models.Attachment, *os.File, error) {
+	attachment, err := database.GetAttachmentByID(id)
+	if err != nil {
+		return attachment, nil, err
+	}
+	f, err := os.Open(attachmentPath(attachment.SHA256))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return attachment, nil, fmt.Errorf("file of attachment %d not found in %s", id, config.AppConfig.Attachment.Dir)
+		}
+		return attachment, nil, fmt.Errorf("opening attachment %d: %w", id, err)
+	}
+	return attachment, f, nil
+}
+
+// UpdateAttachment renames an attachment and replaces its description.
+func UpdateAttachment(id int64, fileName, description string) (models.Attachment, error) {
+	if strings.TrimSpace(fileName) == "" {
+		current, err := database.GetAttachmentByID(id)
+		if err != nil {
+			return current, err
+		}
+		fileName = current.FileName
+	}
+	if err := database.UpdateAttachment(id, attachmentFileName(fileName), strings.TrimSpace(description)); err != nil {
+		return models.Attachment{}, err
+	}
+	return database.GetAttachmentByID(id)
+}
+
+// DeleteAttachment removes an attachment, and its file when nothing else is attached with it.
+func DeleteAttachment(id int64) error {
+	attachment, err := database.GetAttachmentByID(id)
+	if err != nil {
+		return err
+	}
+	if err := database.DeleteAttachment(id); err != nil {
+		return err
+	}
+	removeUnusedAttachmentFile(attachment.SHA256)
+	return nil
+}
+
+// removeUnusedAttachmentFile deletes a stored file no attachment refers to anymore.
+func removeUnusedAttachmentFile(sum string) {
+	n, err := database.CountAttachmentsBySHA256(sum)
+	if err != nil {
+		logger.Error("removeUnusedAttachmentFile: %v", err)
+		return
+	}
+	if n > 0 {
+		return
+	}
+	if err := os.Remove(attachmentPath(sum)); err != nil && !os.IsNotExist(err) {
+		logger.Error("removeUnusedAttachmentFile: Removing %s: %v", sum, err)
+	}
+}
+
+// PruneAttachmentFiles deletes stored files whose attachments were all removed along with their
+// entities, and leftovers of interrupted uploads. It is called when the server starts.
+func PruneAttachmentFiles() {
+	dir := config.AppConfig.Attachment.Dir
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+	used, err := database.GetAttachmentSHA256s()
+	if err != nil {
+		logger.Error("PruneAttachmentFiles: %v", err)
+		return
+	}
+	var removed int
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, attachmentTempPrefix) || (isAttachmentHash(name) && !used[name]) {
+			if err := os.Remove(path); err != nil {
+				logger.Error("PruneAttachmentFiles: Removing %s: %v", path, err)
+			} else {
+				removed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("PruneAttachmentFiles: Walking %s: %v", dir, err)
+	}
+	if removed > 0 {
+		logger.Info("PruneAttachmentFiles: Removed %d unused attachment files.", removed)
+	}
+}
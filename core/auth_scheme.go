@@ -0,0 +1,244 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// authSchemeOrder ranks the schemes of a request carrying several credentials: the one an API
+// client attaches on purpose comes before the cookies a browser sends along.
+var authSchemeOrder = []string{models.AuthSchemeBearer, models.AuthSchemeBasic, models.AuthSchemeAPIKey, models.AuthSchemeCookie}
+
+// requestAuthSchemes returns the credentials a request carries: its authentication schemes, in
+// authSchemeOrder, and the headers and query parameters holding API keys.
+func requestAuthSchemes(headers http.Header, query url.Values, cfg config.AuthSchemeConfig, cookiePatterns []string) (schemes, keyNames []string) {
+	found := map[string]bool{}
+	if auth := strings.TrimSpace(headers.Get("Authorization")); auth != "" {
+		scheme, _, _ := strings.Cut(auth, " ")
+		switch strings.ToLower(scheme) {
+		case "bearer":
+			found[models.AuthSchemeBearer] = true
+		case "basic":
+			found[models.AuthSchemeBasic] = true
+		default:
+			found[models.AuthSchemeAPIKey] = true
+			keyNames = append(keyNames, "Authorization")
+		}
+	}
+	for _, name := range cfg.APIKeyHeaders {
+		if strings.TrimSpace(headers.Get(name)) != "" {
+			found[models.AuthSchemeAPIKey] = true
+			keyNames = append(keyNames, http.CanonicalHeaderKey(name))
+		}
+	}
+	for _, name := range cfg.APIKeyParams {
+		if strings.TrimSpace(query.Get(name)) != "" {
+			found[models.AuthSchemeAPIKey] = true
+			keyNames = append(keyNames, name)
+		}
+	}
+	for _, c := range (&http.Request{Header: headers}).Cookies() {
+		if c.Value != "" && isSessionCookie(c.Name, cookiePatterns) {
+			found[models.AuthSchemeCookie] = true
+			break
+		}
+	}
+	for _, s := range authSchemeOrder {
+		if found[s] {
+			schemes = append(schemes, s)
+		}
+	}
+	return schemes, keyNames
+}
+
+// endpointAuthState accumulates the requests to one endpoint.
+type endpointAuthState struct {
+	auth     models.EndpointAuth
+	schemes  map[string]int // Requests per primary scheme
+	keyNames map[string]bool
+	seen     map[string]bool // All schemes
+	dir      string          // Host and parent path of the endpoint, its sibling group
+}
+
+// GetAuthSchemeReport classifies the endpoints of the target's latest captured requests
+// (auth_schemes.max_logs), optionally only on host, by the credentials they carried: session
+// cookies (cookie_jar.session_cookies), a bearer or basic Authorization header, or an API key
+// header or query parameter (auth_schemes.api_key_headers and api_key_params). An endpoint that
+// answered a request without credentials with a 2xx is flagged as possibly unauthenticated when
+// sibling endpoints require credentials: those under the same parent path, or on the same host
+// when it has none. An endpoint requires credentials when it answered a request without them
+// with a 401 or 403, or was only ever requested with an Authorization header or API key; browsers
+// send cookies to public pages too, so cookies alone do not tell.
+func GetAuthSchemeReport(targetID int64, host string) (models.AuthSchemeReport, error) {
+	report := models.AuthSchemeReport{TargetID: targetID, Schemes: map[string]int{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return report, err
+	}
+	cfg := config.AppConfig.AuthSchemes
+	maxLogs := cfg.MaxLogs
+	if maxLogs <= 0 {
+		maxLogs = 5000
+	}
+	patterns := config.AppConfig.CookieJar.SessionCookies
+	host = strings.ToLower(strings.TrimSpace(host))
+
+	states := map[string]*endpointAuthState{}
+	var order []string
+	err := database.ForEachTargetBrowserRequest(targetID, maxLogs, func(e models.HTTPTrafficLog) {
+		report.LogsScanned++
+		method := strings.ToUpper(e.RequestMethod.String)
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" || (host != "" && strings.ToLower(u.Hostname()) != host) ||
+			method == http.MethodOptions || isStaticAssetPath(u) || isStaticAssetContentType(e.ResponseContentType.String) {
+			return
+		}
+		headers := HeadersFromJSON(e.RequestHeaders.String)
+		template, _ := requestIdentifiers(u, "", nil)
+		key := method + " " + strings.ToLower(u.Host) + template
+		state, ok := states[key]
+		if !ok {
+			dir := template[:strings.LastIndex(template, "/")+1]
+			state = &endpointAuthState{
+				auth:     models.EndpointAuth{Method: method, Host: strings.ToLower(u.Host), Endpoint: template},
+				schemes:  map[string]int{},
+				keyNames: map[string]bool{},
+				seen:     map[string]bool{},
+				dir:      strings.ToLower(u.Host) + dir,
+			}
+			states[key] = state
+			order = append(order, key)
+		}
+		a := &state.auth
+		a.Requests++
+		// Requests come oldest first; the latest is the example.
+		a.ExampleLogID = e.ID
+
+		schemes, keyNames := requestAuthSchemes(headers, u.Query(), cfg, patterns)
+		if len(schemes) > 0 {
+			a.AuthenticatedRequests++
+			state.schemes[schemes[0]]++
+			for _, s := range schemes {
+				state.seen[s] = true
+			}
+			for _, n := range keyNames {
+				state.keyNames[n] = true
+			}
+			return
+		}
+		a.UnauthenticatedRequests++
+		state.seen[models.AuthSchemeNone] = true
+		switch {
+		case e.ResponseStatusCode >= 200 && e.ResponseStatusCode < 300:
+			a.UnauthenticatedSuccess++
+			a.UnauthenticatedLogID = e.ID
+		case e.ResponseStatusCode == http.StatusUnauthorized || e.ResponseStatusCode == http.StatusForbidden:
+			a.AuthRequired++
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+
+	byDir := map[string][]*endpointAuthState{}
+	byHost := map[string][]*endpointAuthState{}
+	for _, key := range order {
+		s := states[key]
+		finishEndpointAuth(s)
+		byDir[s.dir] = append(byDir[s.dir], s)
+		byHost[s.auth.Host] = append(byHost[s.auth.Host], s)
+	}
+
+	// Flagged endpoints with siblings under their parent path come before those flagged by
+	// the rest of their host.
+	dirFlagged := map[string]bool{}
+	for _, key := range order {
+		s := states[key]
+		a := &s.auth
+		if a.UnauthenticatedSuccess == 0 {
+			continue
+		}
+		group, where := byDir[s.dir], "under "+s.dir[len(a.Host):]
+		if len(group) == 1 {
+			group, where = byHost[a.Host], "on "+a.Host
+		} else {
+			dirFlagged[key] = true
+		}
+		var requiring []string
+		for _, sibling := range group {
+			if sibling != s && sibling.auth.RequiresAuth {
+				requiring = append(requiring, sibling.auth.Method+" "+sibling.auth.Endpoint)
+			}
+		}
+		if len(requiring) == 0 {
+			delete(dirFlagged, key)
+			continue
+		}
+		a.Flagged = true
+		a.Reason = fmt.Sprintf("answered %d requests without credentials with a 2xx while %d sibling endpoints %s require them (%s)",
+			a.UnauthenticatedSuccess, len(requiring), where, strings.Join(requiring[:min(3, len(requiring))], ", "))
+	}
+
+	for _, key := range order {
+		a := states[key].auth
+		report.Schemes[a.Scheme]++
+		report.Endpoints = append(report.Endpoints, a)
+		if a.Flagged {
+			report.Unauthenticated = append(report.Unauthenticated, a)
+		}
+	}
+	sort.SliceStable(report.Endpoints, func(i, j int) bool {
+		a, b := report.Endpoints[i], report.Endpoints[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return a.Method < b.Method
+	})
+	sort.SliceStable(report.Unauthenticated, func(i, j int) bool {
+		a, b := report.Unauthenticated[i], report.Unauthenticated[j]
+		ka, kb := a.Method+" "+a.Host+a.Endpoint, b.Method+" "+b.Host+b.Endpoint
+		if dirFlagged[ka] != dirFlagged[kb] {
+			return dirFlagged[ka]
+		}
+		return a.UnauthenticatedSuccess > b.UnauthenticatedSuccess
+	})
+	if report.Endpoints == nil {
+		report.Endpoints = []models.EndpointAuth{}
+	}
+	if report.Unauthenticated == nil {
+		report.Unauthenticated = []models.EndpointAuth{}
+	}
+	return report, nil
+}
+
+// finishEndpointAuth sets the schemes of an endpoint and whether it requires credentials.
+func finishEndpointAuth(s *endpointAuthState) {
+	a := &s.auth
+	a.Scheme = models.AuthSchemeNone
+	best := 0
+	for _, scheme := range authSchemeOrder {
+		if n := s.schemes[scheme]; n > best {
+			a.Scheme, best = scheme, n
+		}
+	}
+	a.Schemes = []string{}
+	for _, scheme := range append(authSchemeOrder, models.AuthSchemeNone) {
+		if s.seen[scheme] {
+			a.Schemes = append(a.Schemes, scheme)
+		}
+	}
+	for name := range s.keyNames {
+		a.APIKeyNames = append(a.APIKeyNames, name)
+	}
+	sort.Strings(a.APIKeyNames)
+	a.RequiresAuth = a.AuthRequired > 0 ||
+		(a.UnauthenticatedRequests == 0 && a.Scheme != models.AuthSchemeNone && a.Scheme != models.AuthSchemeCookie)
+}
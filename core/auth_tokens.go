@@ -0,0 +1,381 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// samlParams are the parameters SAML bindings carry messages in.
+var samlParams = []string{"SAMLResponse", "SAMLRequest"}
+
+// samlFormFieldRegex matches the hidden inputs of an HTTP-POST binding auto-submit form, with the
+// name before or after the value.
+var samlFormFieldRegex = regexp.MustCompile(`(?is)<input[^>]*?name\s*=\s*["']?(SAMLResponse|SAMLRequest)["']?[^>]*?value\s*=\s*["']([^"']+)["']` +
+	`|<input[^>]*?value\s*=\s*["']([^"']+)["'][^>]*?name\s*=\s*["']?(SAMLResponse|SAMLRequest)["']?`)
+
+// foundAuthToken is a token found in a log entry and where it was.
+type foundAuthToken struct {
+	token    models.AuthToken
+	location string
+}
+
+// findAuthTokens returns the JWTs and SAML messages a log entry carries in its URL, headers and
+// bodies. Candidates that do not decode are left out.
+func findAuthTokens(entry *models.HTTPTrafficLog) []foundAuthToken {
+	var found []foundAuthToken
+	seen := make(map[string]bool)
+	add := func(kind, token, location string) {
+		key := kind + "\x00" + token + "\x00" + location
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		t, ok := describeAuthToken(kind, token)
+		if !ok {
+			return
+		}
+		found = append(found, foundAuthToken{token: t, location: location})
+	}
+	addJWTs := func(text, location string) {
+		for _, token := range jwtRegex.FindAllString(text, -1) {
+			add(models.AuthTokenKindJWT, token, location)
+		}
+	}
+	addSAMLParams := func(values url.Values, location string) {
+		for _, name := range samlParams {
+			for _, v := range values[name] {
+				add(models.AuthTokenKindSAML, v, location)
+			}
+		}
+	}
+
+	if u, err := url.Parse(entry.RequestURL.String); err == nil {
+		addJWTs(entry.RequestURL.String, "url")
+		addSAMLParams(u.Query(), "url")
+	}
+	requestHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+	for _, name := range sortedHeaderNames(requestHeaders) {
+		for _, v := range requestHeaders[name] {
+			addJWTs(v, "request_header:"+name)
+		}
+	}
+	if len(entry.RequestBody) > 0 {
+		body, err := DecodeContentEncoding(entry.RequestBody, requestHeaders.Get("Content-Encoding"))
+		if err != nil {
+			body = entry.RequestBody
+		}
+		addJWTs(string(body), "request_body")
+		if strings.Contains(string(body), "SAML") {
+			if values, err := url.ParseQuery(string(body)); err == nil {
+				addSAMLParams(values, "request_body")
+			}
+		}
+	}
+	responseHeaders := HeadersFromJSON(entry.ResponseHeaders.String)
+	for _, name := range sortedHeaderNames(responseHeaders) {
+		for _, v := range responseHeaders[name] {
+			addJWTs(v, "response_header:"+name)
+			if name == "Location" {
+				// HTTP-Redirect binding
+				if u, err := url.Parse(v); err == nil {
+					addSAMLParams(u.Query(), "response_header:"+name)
+				}
+			}
+		}
+	}
+	if len(entry.ResponseBody) > 0 {
+		addJWTs(string(entry.ResponseBody), "response_body")
+		for _, m := range samlFormFieldRegex.FindAllStringSubmatch(string(entry.ResponseBody), -1) {
+			value := m[2]
+			if value == "" {
+				value = m[3]
+			}
+			add(models.AuthTokenKindSAML, html.UnescapeString(value), "response_body")
+		}
+	}
+	return found
+}
+
+func sortedHeaderNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// describeAuthToken decodes a token and fills in the fields tracked for it.
+func describeAuthToken(kind, token string) (models.AuthToken, bool) {
+	t := models.AuthToken{Kind: kind, Token: token}
+	switch kind {
+	case models.AuthTokenKindJWT:
+		decoded, err := DecodeJWT(token)
+		if err != nil {
+			return t, false
+		}
+		t.Algorithm = decoded.Algorithm
+		t.Issuer = jwtStringClaim(decoded.Claims, "iss")
+		t.Subject = jwtStringClaim(decoded.Claims, "sub")
+		t.ExpiresAt = decoded.ExpiresAt
+		t.Signed = decoded.Signature != "" && !strings.EqualFold(decoded.Algorithm, "none")
+	case models.AuthTokenKindSAML:
+		decoded, err := DecodeSAML(token)
+		if err != nil {
+			return t, false
+		}
+		if i := strings.LastIndex(decoded.SignatureAlgorithm, "#"); i >= 0 {
+			t.Algorithm = decoded.SignatureAlgorithm[i+1:]
+		}
+		t.Issuer = decoded.Issuer
+		t.Subject = decoded.NameID
+		t.ExpiresAt = decoded.NotOnOrAfter
+		t.Signed = len(decoded.SignedElements) > 0
+	default:
+		return t, false
+	}
+	return t, true
+}
+
+func jwtStringClaim(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// CaptureAuthTokensFromLog records the tokens a target's log entry carries. It returns the number
+// of occurrences recorded.
+func CaptureAuthTokensFromLog(entry *models.HTTPTrafficLog) (int, error) {
+	if entry.TargetID == nil || *entry.TargetID == 0 || !ConfigForTarget(entry.TargetID).AuthTokens.Capture {
+		return 0, nil
+	}
+	return recordAuthTokens(entry)
+}
+
+func recordAuthTokens(entry *models.HTTPTrafficLog) (int, error) {
+	recorded := 0
+	for _, f := range findAuthTokens(entry) {
+		f.token.TargetID = *entry.TargetID
+		f.token.LastSeenAt = entry.Timestamp
+		if _, err := database.RecordAuthToken(f.token, entry.ID, f.location); err != nil {
+			return recorded, err
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+// ScanTargetAuthTokens looks for tokens in all of a target's captured traffic, for traffic logged
+// before capture was enabled. Tokens already tracked are kept.
+func ScanTargetAuthTokens(targetID int64) (models.AuthTokenScanResult, error) {
+	result := models.AuthTokenScanResult{TargetID: targetID}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	var afterID int64
+	for {
+		entries, err := database.GetAuthTokenTraffic(targetID, afterID, 500)
+		if err != nil {
+			return result, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for i := range entries {
+			recorded, err := recordAuthTokens(&entries[i])
+			if err != nil {
+				return result, fmt.Errorf("log %d: %w", entries[i].ID, err)
+			}
+			result.Occurrences += recorded
+			afterID = entries[i].ID
+		}
+		result.LogsScanned += len(entries)
+	}
+	tokens, err := database.GetAuthTokens(targetID, "")
+	if err != nil {
+		return result, err
+	}
+	result.Tokens = len(tokens)
+	logger.Info("ScanTargetAuthTokens: Target %d: %d logs scanned, %d tokens, %d occurrences",
+		targetID, result.LogsScanned, result.Tokens, result.Occurrences)
+	return result, nil
+}
+
+// GetAuthTokenDetail returns a tracked token decoded, with the log entries it appeared in.
+func GetAuthTokenDetail(id int64) (*models.AuthTokenDetail, error) {
+	token, err := database.GetAuthToken(id)
+	if err != nil {
+		return nil, err
+	}
+	detail := &models.AuthTokenDetail{AuthToken: token}
+	switch token.Kind {
+	case models.AuthTokenKindJWT:
+		detail.JWT, _ = DecodeJWT(token.Token)
+	case models.AuthTokenKindSAML:
+		detail.SAML, _ = DecodeSAML(token.Token)
+	}
+	if detail.Occurrences, err = database.GetAuthTokenOccurrences(id); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// DecodeAuthToken decodes a JWT or SAML message given as is.
+func DecodeAuthToken(token string) (*models.DecodedAuthToken, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("invalid token: empty")
+	}
+	if decoded, err := DecodeJWT(token); err == nil {
+		return &models.DecodedAuthToken{Kind: models.AuthTokenKindJWT, JWT: decoded}, nil
+	}
+	if decoded, err := DecodeSAML(token); err == nil {
+		return &models.DecodedAuthToken{Kind: models.AuthTokenKindSAML, SAML: decoded}, nil
+	}
+	return nil, fmt.Errorf("invalid token: not a JWT or SAML message")
+}
+
+// resolveForgeToken returns the token to forge: the one given, or the tracked token with ID.
+func resolveForgeToken(token string, tokenID int64, kind string) (string, error) {
+	if tokenID == 0 {
+		return strings.TrimSpace(token), nil
+	}
+	tracked, err := database.GetAuthToken(tokenID)
+	if err != nil {
+		return "", err
+	}
+	if tracked.Kind != kind {
+		return "", fmt.Errorf("invalid token_id: token %d is a %s token", tokenID, tracked.Kind)
+	}
+	return tracked.Token, nil
+}
+
+// ForgeJWTToken edits and re-signs a JWT. With a modifier_log_id, it also creates a Modifier task
+// from that log entry with the forged token in place of the original.
+func ForgeJWTToken(req models.JWTForgeRequest) (*models.ForgedAuthToken, error) {
+	original, err := resolveForgeToken(req.Token, req.TokenID, models.AuthTokenKindJWT)
+	if err != nil {
+		return nil, err
+	}
+	if original == "" {
+		return nil, fmt.Errorf("invalid request: a token or token_id is required")
+	}
+	forged, err := ForgeJWT(original, req)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ForgedAuthToken{Kind: models.AuthTokenKindJWT, Token: forged}
+	if result.JWT, err = DecodeJWT(forged); err != nil {
+		return nil, err
+	}
+	if req.ModifierLogID != 0 {
+		if result.ModifierTaskID, err = createForgedTokenTask(req.ModifierLogID, original, forged, "JWT"); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ForgeSAMLToken edits a SAML message and keeps, strips or redoes its signatures. With a
+// modifier_log_id, it also creates a Modifier task from that log entry with the forged message in
+// place of the original.
+func ForgeSAMLToken(req models.SAMLForgeRequest) (*models.ForgedAuthToken, error) {
+	original, err := resolveForgeToken(req.Token, req.TokenID, models.AuthTokenKindSAML)
+	if err != nil {
+		return nil, err
+	}
+	forged, decoded, err := ForgeSAML(original, req)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ForgedAuthToken{Kind: models.AuthTokenKindSAML, Token: forged, SAML: decoded}
+	if req.ModifierLogID != 0 {
+		if original == "" {
+			return nil, fmt.Errorf("invalid modifier_log_id: the original token or token_id is needed to find it in the log")
+		}
+		if result.ModifierTaskID, err = createForgedTokenTask(req.ModifierLogID, original, forged, "SAML"); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// createForgedTokenTask creates a Modifier task from a log entry, replacing the original token in
+// its URL, headers and body (raw and URL-encoded) with the forged one.
+func createForgedTokenTask(logID int64, original, forged, label string) (int64, error) {
+	entry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		return 0, err
+	}
+	replacements := []string{original, forged}
+	if escaped := url.QueryEscape(original); escaped != original {
+		replacements = append(replacements, escaped, url.QueryEscape(forged))
+	}
+	replacer := strings.NewReplacer(replacements...)
+	count := 0
+	replace := func(s string) string {
+		for i := 0; i < len(replacements); i += 2 {
+			count += strings.Count(s, replacements[i])
+		}
+		return replacer.Replace(s)
+	}
+
+	reqURL := replace(entry.RequestURL.String)
+	requestHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+	headers := http.Header{}
+	for k, vals := range requestHeaders {
+		canonical := http.CanonicalHeaderKey(k)
+		if replaySkippedHeaders[canonical] || isToolkitHeader(canonical) || canonical == "Content-Encoding" {
+			continue
+		}
+		for _, v := range vals {
+			headers.Add(canonical, replace(v))
+		}
+	}
+	body, err := DecodeContentEncoding(entry.RequestBody, requestHeaders.Get("Content-Encoding"))
+	if err != nil {
+		return 0, fmt.Errorf("decoding request body of log %d: %w", logID, err)
+	}
+	body = []byte(replace(string(body)))
+	if count == 0 {
+		return 0, fmt.Errorf("invalid modifier_log_id: log %d does not contain the token", logID)
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return 0, fmt.Errorf("encoding headers: %w", err)
+	}
+
+	task := models.ModifierTask{
+		Name:               fmt.Sprintf("Forged %s - From Log %d", label, logID),
+		BaseRequestMethod:  entry.RequestMethod.String,
+		BaseRequestURL:     reqURL,
+		BaseRequestHeaders: models.NullString(string(headersJSON)),
+		SourceLogID:        sql.NullInt64{Int64: logID, Valid: true},
+	}
+	if len(body) > 0 {
+		task.BaseRequestBody = models.NullString(base64.StdEncoding.EncodeToString(body))
+	}
+	if entry.TargetID != nil {
+		task.TargetID = sql.NullInt64{Int64: *entry.TargetID, Valid: true}
+	}
+	created, err := database.CreateModifierTask(task)
+	if err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// beautifyBatchSize is the number of responses a beautify job loads at a time.
+const beautifyBatchSize = 200
+
+// beautifiableLanguages are the body languages that get a beautified copy.
+var beautifiableLanguages = map[string]bool{
+	BodyLanguageJSON: true, BodyLanguageJavaScript: true, BodyLanguageHTML: true, BodyLanguageXML: true,
+}
+
+// isBeautifiableContentType reports whether a response of this Content-Type gets a beautified copy
+// when captured.
+func isBeautifiableContentType(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return IsJavaScriptContentType(ct) || strings.Contains(ct, "json") || strings.Contains(ct, "html") || strings.Contains(ct, "xml")
+}
+
+// decodedResponseBody returns the response body of a log entry decoded from its Content-Encoding,
+// with its content type.
+func decodedResponseBody(entry models.HTTPTrafficLog) ([]byte, string) {
+	headers := HeadersFromJSON(entry.ResponseHeaders.String)
+	body, err := DecodeContentEncoding(entry.ResponseBody, headers.Get("Content-Encoding"))
+	if err != nil {
+		logger.Debug("Beautify: Could not decode body of log %d, using it as stored: %v", entry.ID, err)
+	}
+	contentType := entry.ResponseContentType.String
+	if contentType == "" {
+		contentType = headers.Get("Content-Type")
+	}
+	return body, contentType
+}
+
+// BeautifyTrafficResponse returns the beautified copy of a log entry's response body, making it on
+// first use. A body another entry already has is formatted once. ok is false when the body is
+// empty or not JavaScript, JSON, HTML or XML; a body that could not be formatted has a copy
+// without Data that records why, so it is not tried again.
+func BeautifyTrafficResponse(entry models.HTTPTrafficLog) (b models.BeautifiedBody, ok bool, err error) {
+	if b, found, err := database.GetTrafficLogBeautifiedBody(entry.ID); err != nil || found {
+		return b, found, err
+	}
+	body, contentType := decodedResponseBody(entry)
+	if len(body) == 0 {
+		return b, false, nil
+	}
+	language := DetectBodyLanguage(contentType, body)
+	if !beautifiableLanguages[language] {
+		return b, false, nil
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	b, found, err := database.GetBeautifiedBodyByHash(hash, language)
+	if err != nil {
+		return b, false, err
+	}
+	if !found {
+		pretty := PrettyPrintBody(body, contentType)
+		b = models.BeautifiedBody{SHA256: hash, Language: language, OriginalSize: len(body)}
+		if pretty.Formatted {
+			b.Data = []byte(pretty.Body)
+		} else {
+			b.Error = pretty.Error
+		}
+	}
+	if b, err = database.LinkBeautifiedBody(entry.ID, b); err != nil {
+		return b, false, err
+	}
+	return b, true, nil
+}
+
+// ReadableResponseBody returns the beautified copy of a log entry's response body, or the body
+// decoded from its Content-Encoding when it has none.
+func ReadableResponseBody(entry models.HTTPTrafficLog) []byte {
+	b, ok, err := BeautifyTrafficResponse(entry)
+	if err != nil {
+		logger.Error("Beautify: Log %d: %v", entry.ID, err)
+	}
+	if ok && b.Data != nil {
+		return b.Data
+	}
+	body, _ := decodedResponseBody(entry)
+	return body
+}
+
+// PrettyTrafficResponse formats the response body of a log entry for display, from its stored
+// beautified copy when the body gets one.
+func PrettyTrafficResponse(entry models.HTTPTrafficLog) PrettyBody {
+	body, contentType := decodedResponseBody(entry)
+	b, ok, err := BeautifyTrafficResponse(entry)
+	if err != nil {
+		logger.Error("Beautify: Log %d: %v", entry.ID, err)
+	}
+	if !ok {
+		return PrettyPrintBody(body, contentType)
+	}
+	pretty := PrettyBody{ContentType: contentType, Language: b.Language, Body: string(body), Error: b.Error}
+	if b.Data != nil {
+		pretty.Body, pretty.Formatted = string(b.Data), true
+	}
+	return pretty
+}
+
+// DiffBeautifiedTrafficLogs compares two traffic log entries like DiffTrafficLogs, with their
+// response bodies replaced by their beautified copies, so changes in minified bundles show as the
+// lines that changed rather than one replaced line.
+func DiffBeautifiedTrafficLogs(from, to models.HTTPTrafficLog) TrafficDiff {
+	from.ResponseBody = ReadableResponseBody(from)
+	to.ResponseBody = ReadableResponseBody(to)
+	diff := DiffTrafficLogs(from, to)
+	diff.Beautified = true
+	return diff
+}
+
+// beautifyCapturedResponse stores the beautified copy of a response as it is captured
+// (beautify.on_capture).
+func beautifyCapturedResponse(entry models.HTTPTrafficLog) {
+	if _, _, err := BeautifyTrafficResponse(entry); err != nil {
+		logger.ProxyError("Beautify: Log %d: %v", entry.ID, err)
+	}
+}
+
+// StartTrafficBeautify starts a job storing beautified copies of the JavaScript, JSON, HTML and XML
+// responses that have none yet, of one target or of all.
+func StartTrafficBeautify(req models.BeautifyRequest) (models.Job, error) {
+	latest, err := LatestJob(models.JobTypeBeautify, req.TargetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("beautifying is already running (job %d)", latest.ID)
+	}
+	var targetID *int64
+	if req.TargetID > 0 {
+		if _, err := database.GetTargetByID(req.TargetID); err != nil {
+			return models.Job{}, err
+		}
+		targetID = &req.TargetID
+	}
+	total, err := database.CountBeautifyPending(req.TargetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	return StartJob(models.JobTypeBeautify, targetID, fmt.Sprintf("Beautifying %d responses...", total), func(ctx context.Context, job *JobHandle) error {
+		job.SetTotal(total)
+		var afterID int64
+		var formatted, failed int
+		for {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			entries, err := database.GetBeautifyPending(req.TargetID, afterID, beautifyBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				break
+			}
+			for _, e := range entries {
+				afterID = e.ID
+				b, ok, err := BeautifyTrafficResponse(e)
+				if err != nil {
+					return err
+				}
+				switch {
+				case ok && b.Data != nil:
+					formatted++
+					job.AddProgress(1, 1)
+				case ok:
+					failed++
+					job.AddProgress(1, 0)
+				default:
+					job.AddProgress(1, 0)
+				}
+			}
+		}
+		job.SetMessage("Beautified %d responses; %d could not be formatted", formatted, failed)
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// bodyDedupBatchSize is the number of log entries whose bodies are moved per transaction.
+const bodyDedupBatchSize = 500
+
+// GetBodyBlobStats summarizes how the traffic log bodies are stored.
+func GetBodyBlobStats() (models.BodyBlobStats, error) {
+	return database.GetBodyBlobStats()
+}
+
+// StartTrafficBodyDedup starts a job moving the bodies of entries captured before bodies were
+// deduplicated into blobs. With req.Vacuum the database file is rebuilt afterwards so the freed
+// space is returned to the file system.
+func StartTrafficBodyDedup(req models.BodyDedupRequest) (models.Job, error) {
+	latest, err := LatestJob(models.JobTypeBodyDedup, 0)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("body deduplication is already running (job %d)", latest.ID)
+	}
+	stats, err := database.GetBodyBlobStats()
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	return StartJob(models.JobTypeBodyDedup, nil, fmt.Sprintf("Moving bodies of %d log entries to blobs...", stats.MovableEntries), func(ctx context.Context, job *JobHandle) error {
+		job.SetTotal(stats.MovableEntries)
+		var afterID, moved int64
+		for {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastID, n, bytes, err := database.MoveTrafficBodiesToBlobs(afterID, bodyDedupBatchSize)
+			if err != nil {
+				return err
+			}
+			if lastID == 0 {
+				break
+			}
+			afterID = lastID
+			moved += bytes
+			job.AddProgress(int64(n), int64(n))
+		}
+		if req.Vacuum {
+			job.SetMessage("Vacuuming database...")
+			if err := database.Vacuum(); err != nil {
+				return err
+			}
+		}
+		after, err := database.GetBodyBlobStats()
+		if err != nil {
+			return err
+		}
+		job.SetMessage("Moved %.1f MB of bodies to %d blobs; deduplication saves %.1f MB",
+			float64(moved)/(1<<20), after.Blobs, float64(after.SavedBytes)/(1<<20))
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxDecodedBodySize bounds decompression output to guard against compression bombs.
+const maxDecodedBodySize = 64 * 1024 * 1024
+
+// DecodeContentEncoding reverses the Content-Encoding of a logged body (gzip, deflate, br).
+// Bodies stored by the proxy keep the encoding chosen by the server, so analyzers must decode
+// them first. Unknown or empty encodings return the body unchanged.
+func DecodeContentEncoding(body []byte, contentEncoding string) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+	// Multiple encodings are listed in the order they were applied; undo them in reverse.
+	encodings := strings.Split(contentEncoding, ",")
+	decoded := body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var reader io.Reader
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(bytes.NewReader(decoded))
+			if err != nil {
+				return body, fmt.Errorf("gzip: %w", err)
+			}
+			defer gz.Close()
+			reader = gz
+		case "deflate":
+			// "deflate" is zlib-wrapped per the RFC, but some servers send raw DEFLATE.
+			if zr, err := zlib.NewReader(bytes.NewReader(decoded)); err == nil {
+				defer zr.Close()
+				reader = zr
+			} else {
+				reader = flate.NewReader(bytes.NewReader(decoded))
+			}
+		case "br":
+			reader = brotli.NewReader(bytes.NewReader(decoded))
+		default:
+			return body, fmt.Errorf("unsupported content encoding '%s'", encodings[i])
+		}
+		out, err := io.ReadAll(io.LimitReader(reader, maxDecodedBodySize))
+		if err != nil {
+			return body, fmt.Errorf("decoding %s body: %w", encodings[i], err)
+		}
+		decoded = out
+	}
+	return decoded, nil
+}
+
+// decodeLoggedResponseBody stores a content-encoded response body decoded, so regex search and
+// the analyzers see plain text. The original encoding is kept in ResponseContentEncoding and the
+// Content-Encoding and Content-Length headers are dropped from the stored headers. Bodies that do
+// not decode, such as compressed bodies cut short by the capture limit, are stored as received.
+func decodeLoggedResponseBody(entry *models.HTTPTrafficLog, captureLimit int64) {
+	headers := HeadersFromJSON(entry.ResponseHeaders.String)
+	encoding := headers.Get("Content-Encoding")
+	if len(entry.ResponseBody) == 0 || encoding == "" || strings.EqualFold(encoding, "identity") {
+		return
+	}
+	decoded, err := DecodeContentEncoding(entry.ResponseBody, encoding)
+	if err != nil {
+		logger.ProxyDebug("Storing %s response body of %s undecoded: %v", encoding, entry.RequestURL.String, err)
+		return
+	}
+	if captureLimit > 0 && int64(len(decoded)) > captureLimit {
+		decoded = decoded[:captureLimit]
+		entry.ResponseBodyTruncated = true
+	}
+	entry.ResponseBody = decoded
+	entry.ResponseContentEncoding = models.NullString(encoding)
+	headers.Del("Content-Encoding")
+	headers.Del("Content-Length")
+	entry.ResponseHeaders = models.NullString(headersToJSON(headers))
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"strings"
+	"time"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// maxCaptureSessionNameLength caps the length of capture session names.
+const maxCaptureSessionNameLength = 200
+
+// StartCaptureSession starts a named capture session for a target now. It fails when the target
+// already has a running session.
+func StartCaptureSession(targetID int64, req models.CaptureSessionRequest) (models.CaptureSession, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.CaptureSession{}, err
+	}
+	if running, found, err := database.GetRunningCaptureSession(targetID); err != nil {
+		return models.CaptureSession{}, err
+	} else if found {
+		return models.CaptureSession{}, models.ConflictError("target %d already has a running capture session ('%s', %d); stop it first",
+			targetID, running.Name, running.ID)
+	}
+	s := models.CaptureSession{TargetID: targetID, StartedAt: time.Now()}
+	if err := applyCaptureSessionRequest(&s, req); err != nil {
+		return s, err
+	}
+	if s.Name == "" {
+		return s, models.InvalidRequestError("name is required")
+	}
+	id, err := database.CreateCaptureSession(s)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return s, models.ConflictError("target %d already has a running capture session; stop it first", targetID)
+		}
+		return s, err
+	}
+	return GetCaptureSession(id)
+}
+
+// StopCaptureSession ends the running capture session of a target now. notes, when not nil,
+// replace its notes.
+func StopCaptureSession(targetID int64, notes *string) (models.CaptureSession, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.CaptureSession{}, err
+	}
+	s, found, err := database.GetRunningCaptureSession(targetID)
+	if err != nil {
+		return s, err
+	}
+	if !found {
+		return s, models.ConflictError("target %d has no running capture session", targetID)
+	}
+	if notes != nil {
+		s.Notes = strings.TrimSpace(*notes)
+	}
+	now := time.Now()
+	s.EndedAt = &now
+	if err := database.EndCaptureSession(s); err != nil {
+		return s, err
+	}
+	return GetCaptureSession(s.ID)
+}
+
+// applyCaptureSessionRequest copies the fields set in req to s.
+func applyCaptureSessionRequest(s *models.CaptureSession, req models.CaptureSessionRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return models.InvalidRequestError("name must not be empty")
+		}
+		if len(name) > maxCaptureSessionNameLength {
+			return models.InvalidRequestError("name is longer than %d characters", maxCaptureSessionNameLength)
+		}
+		s.Name = name
+	}
+	if req.Notes != nil {
+		s.Notes = strings.TrimSpace(*req.Notes)
+	}
+	return nil
+}
+
+// GetCaptureSession returns a capture session with its request counts.
+func GetCaptureSession(id int64) (models.CaptureSession, error) {
+	s, err := database.GetCaptureSession(id)
+	if err != nil {
+		return s, err
+	}
+	fillCaptureSessionDuration(&s, time.Now())
+	return s, nil
+}
+
+// UpdateCaptureSession renames a capture session or changes its notes.
+func UpdateCaptureSession(id int64, req models.CaptureSessionRequest) (models.CaptureSession, error) {
+	s, err := database.GetCaptureSession(id)
+	if err != nil {
+		return s, err
+	}
+	if err := applyCaptureSessionRequest(&s, req); err != nil {
+		return s, err
+	}
+	if err := database.UpdateCaptureSession(s); err != nil {
+		return s, err
+	}
+	return GetCaptureSession(id)
+}
+
+// DeleteCaptureSession deletes a capture session, keeping its traffic.
+func DeleteCaptureSession(id int64) error {
+	return database.DeleteCaptureSession(id)
+}
+
+// ListCaptureSessions returns the capture sessions of a target with their request counts, latest
+// first. limit 0 means no limit.
+func ListCaptureSessions(targetID int64, limit int) ([]models.CaptureSession, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return nil, err
+	}
+	sessions, err := database.ListCaptureSessions(targetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range sessions {
+		fillCaptureSessionDuration(&sessions[i], now)
+	}
+	return sessions, nil
+}
+
+// fillCaptureSessionDuration sets the duration of a session, up to now while it runs.
+func fillCaptureSessionDuration(s *models.CaptureSession, now time.Time) {
+	end := now
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	s.DurationSeconds = int64(end.Sub(s.StartedAt).Seconds())
+}
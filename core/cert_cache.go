@@ -0,0 +1,111 @@
+package core
+
+import (
+	"container/list"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"time"
+	"toolkit/config"
+)
+
+// leafCertCache keeps the certificates the proxy signs per host, so repeated connections to a host
+// skip key generation and signing. It implements goproxy.CertStorage.
+type leafCertCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	order   *list.List // Least recently used at the back
+	entries map[string]*list.Element
+	pending map[string]*leafCertCall // Hosts being signed, so concurrent handshakes sign once
+}
+
+type leafCertEntry struct {
+	host    string
+	cert    *tls.Certificate
+	expires time.Time
+}
+
+type leafCertCall struct {
+	done chan struct{}
+	cert *tls.Certificate
+	err  error
+}
+
+var proxyLeafCerts = newLeafCertCache(time.Hour, 1000)
+
+func newLeafCertCache(ttl time.Duration, maxSize int) *leafCertCache {
+	return &leafCertCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		pending: make(map[string]*leafCertCall),
+	}
+}
+
+// configureLeafCertCache applies the proxy config to the cache and drops the certificates it holds,
+// which were signed by the previously loaded CA.
+func configureLeafCertCache() {
+	ttl := time.Duration(config.AppConfig.Proxy.LeafCertCacheTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	size := config.AppConfig.Proxy.LeafCertCacheSize
+	if size <= 0 {
+		size = 1000
+	}
+	c := proxyLeafCerts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.maxSize = ttl, size
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// Fetch returns the cached certificate of hostname, or signs one with gen.
+func (c *leafCertCache) Fetch(hostname string, gen func() (*tls.Certificate, error)) (*tls.Certificate, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[hostname]; ok {
+		entry := el.Value.(*leafCertEntry)
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.cert, nil
+		}
+		c.order.Remove(el)
+		delete(c.entries, hostname)
+	}
+	if call, ok := c.pending[hostname]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.cert, call.err
+	}
+	call := &leafCertCall{done: make(chan struct{})}
+	c.pending[hostname] = call
+	c.mu.Unlock()
+
+	call.cert, call.err = gen()
+	if call.err == nil && call.cert.Leaf == nil && len(call.cert.Certificate) > 0 {
+		// Parsed once here instead of on every handshake.
+		call.cert.Leaf, _ = x509.ParseCertificate(call.cert.Certificate[0])
+	}
+
+	c.mu.Lock()
+	delete(c.pending, hostname)
+	if call.err == nil {
+		expires := time.Now().Add(c.ttl)
+		if call.cert.Leaf != nil && call.cert.Leaf.NotAfter.Before(expires) {
+			expires = call.cert.Leaf.NotAfter
+		}
+		c.entries[hostname] = c.order.PushFront(&leafCertEntry{host: hostname, cert: call.cert, expires: expires})
+		for c.order.Len() > c.maxSize {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*leafCertEntry).host)
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.cert, call.err
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// checklistCustomTemplate names the template group of checklist items added by hand.
+const checklistCustomTemplate = "Custom"
+
+// checklistMaxUntested is the most untested endpoints listed per completed checklist item.
+const checklistMaxUntested = 50
+
+// checklistCategoryCode matches item texts starting with a category code, such as
+// "API4:2023 - Unrestricted Resource Consumption".
+var checklistCategoryCode = regexp.MustCompile(`^([A-Z][A-Za-z]*\d+(?::\d{4})?)\s+[-:]\s+`)
+
+// checklistItemCategory returns the category of a checklist item: the "Category:" line of its
+// notes (as in the Juice Shop template), else the code its text starts with.
+func checklistItemCategory(text, notes string) string {
+	for _, line := range strings.Split(notes, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "Category:"); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	if m := checklistCategoryCode.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return models.ChecklistUncategorized
+}
+
+// checklistPercent returns n of total as a percentage with one decimal.
+func checklistPercent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*1000/float64(total)) / 10
+}
+
+// GetChecklistAnalytics computes the checklist completion of a target overall, per template and
+// per category, and at the end of every day or week (interval) since its first item was added.
+func GetChecklistAnalytics(targetID int64, interval string) (models.ChecklistAnalytics, error) {
+	analytics := models.ChecklistAnalytics{TargetID: targetID, Interval: interval}
+	if analytics.Interval == "" {
+		analytics.Interval = "day"
+	}
+	if analytics.Interval != "day" && analytics.Interval != "week" {
+		return analytics, models.InvalidRequestError("invalid interval '%s', expected 'day' or 'week'", interval)
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return analytics, err
+	}
+	items, err := database.GetChecklistProgressItems(targetID)
+	if err != nil {
+		return analytics, err
+	}
+
+	templates := map[string]*models.ChecklistCompletion{}
+	categories := map[string]*models.ChecklistCompletion{}
+	count := func(groups map[string]*models.ChecklistCompletion, key string, group models.ChecklistCompletion, completed bool) {
+		g, ok := groups[key]
+		if !ok {
+			g = &group
+			groups[key] = g
+		}
+		g.Total++
+		if completed {
+			g.Completed++
+		}
+	}
+	for _, item := range items {
+		analytics.Total++
+		if item.IsCompleted {
+			analytics.Completed++
+		}
+		template := models.ChecklistCompletion{Name: checklistCustomTemplate}
+		if item.TemplateID.Valid {
+			template = models.ChecklistCompletion{TemplateID: item.TemplateID.Int64, Name: item.TemplateName.String}
+		}
+		count(templates, template.Name, template, item.IsCompleted)
+		category := checklistItemCategory(item.ItemText, item.Notes.String)
+		count(categories, category, models.ChecklistCompletion{Name: category}, item.IsCompleted)
+	}
+	analytics.Percent = checklistPercent(analytics.Completed, analytics.Total)
+	analytics.Templates = sortedChecklistCompletion(templates)
+	analytics.Categories = sortedChecklistCompletion(categories)
+	analytics.Timeline = checklistTimeline(items, analytics.Interval, time.Now().UTC())
+	return analytics, nil
+}
+
+// sortedChecklistCompletion returns groups by name, with their percentages.
+func sortedChecklistCompletion(groups map[string]*models.ChecklistCompletion) []models.ChecklistCompletion {
+	sorted := []models.ChecklistCompletion{}
+	for _, g := range groups {
+		g.Percent = checklistPercent(g.Completed, g.Total)
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// checklistTimeline counts the items added and completed by the end of each day or week (from
+// Monday) between the first item's and now, in UTC.
+func checklistTimeline(items []models.ChecklistProgressItem, interval string, now time.Time) []models.ChecklistTimelinePoint {
+	timeline := []models.ChecklistTimelinePoint{}
+	if len(items) == 0 {
+		return timeline
+	}
+	first := items[0].CreatedAt.UTC()
+	for _, item := range items {
+		if c := item.CreatedAt.UTC(); c.Before(first) {
+			first = c
+		}
+	}
+	start := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	days := 1
+	if interval == "week" {
+		days = 7
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	}
+	for period := start; !period.After(now); period = period.AddDate(0, 0, days) {
+		end := period.AddDate(0, 0, days)
+		point := models.ChecklistTimelinePoint{Date: period.Format(time.DateOnly)}
+		for _, item := range items {
+			if item.CreatedAt.Before(end) {
+				point.Total++
+			}
+			if !item.IsCompleted || !item.CompletedAt.Valid || !item.CompletedAt.Time.Before(end) {
+				continue
+			}
+			point.Completed++
+			if !item.CompletedAt.Time.Before(period) {
+				point.CompletedInPeriod++
+			}
+		}
+		point.Percent = checklistPercent(point.Completed, point.Total)
+		timeline = append(timeline, point)
+	}
+	return timeline
+}
+
+// GetChecklistCoverage maps the checklist items of a target to the endpoints of its captured
+// traffic (leaving out static assets and preflights) they were tested against: those of the
+// requests linked as evidence, of the findings linked as evidence, and of the requests tagged
+// with the item's category or its code (a request tagged "API4" counts for "API4:2023 - ...").
+func GetChecklistCoverage(targetID int64) (models.ChecklistCoverageReport, error) {
+	report := models.ChecklistCoverageReport{TargetID: targetID, Items: []models.ChecklistItemCoverage{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return report, err
+	}
+	items, err := database.GetChecklistProgressItems(targetID)
+	if err != nil {
+		return report, err
+	}
+
+	endpoints := map[int64]string{}
+	var discovered []string
+	seen := map[string]bool{}
+	err = database.ForEachTargetRequestLine(targetID, func(e models.HTTPTrafficLog) {
+		method := strings.ToUpper(e.RequestMethod.String)
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" || method == http.MethodOptions || isStaticAssetPath(u) || isStaticAssetContentType(e.ResponseContentType.String) {
+			return
+		}
+		template, _ := requestIdentifiers(u, "", nil)
+		key := method + " " + strings.ToLower(u.Host) + template
+		endpoints[e.ID] = key
+		if !seen[key] {
+			seen[key] = true
+			discovered = append(discovered, key)
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+	sort.Strings(discovered)
+	report.DiscoveredEndpoints = len(discovered)
+
+	evidence, err := database.GetChecklistEvidenceLogIDs(targetID)
+	if err != nil {
+		return report, err
+	}
+	tagged, err := database.GetTargetTrafficLogIDsByTag(targetID)
+	if err != nil {
+		return report, err
+	}
+
+	for _, item := range items {
+		category := checklistItemCategory(item.ItemText, item.Notes.String)
+		logIDs := append([]int64{}, evidence[item.ID]...)
+		if category != models.ChecklistUncategorized {
+			tag := strings.ToLower(category)
+			logIDs = append(logIDs, tagged[tag]...)
+			if code, _, found := strings.Cut(tag, ":"); found {
+				logIDs = append(logIDs, tagged[code]...)
+			}
+		}
+		tested := map[string]bool{}
+		for _, id := range logIDs {
+			if key, ok := endpoints[id]; ok {
+				tested[key] = true
+			}
+		}
+
+		coverage := models.ChecklistItemCoverage{
+			ItemID:              item.ID,
+			ItemText:            item.ItemText,
+			Category:            category,
+			Template:            checklistCustomTemplate,
+			IsCompleted:         item.IsCompleted,
+			TestedEndpoints:     len(tested),
+			DiscoveredEndpoints: len(discovered),
+			Percent:             checklistPercent(len(tested), len(discovered)),
+			Tested:              []string{},
+		}
+		if item.TemplateID.Valid {
+			coverage.Template = item.TemplateName.String
+		}
+		coverage.Summary = fmt.Sprintf("%s: tested against %d/%d discovered endpoints", item.ItemText, len(tested), len(discovered))
+		for _, key := range discovered {
+			switch {
+			case tested[key]:
+				coverage.Tested = append(coverage.Tested, key)
+			case item.IsCompleted && len(coverage.Untested) < checklistMaxUntested:
+				coverage.Untested = append(coverage.Untested, key)
+			}
+		}
+		report.Items = append(report.Items, coverage)
+	}
+	return report, nil
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// clientProfiles caches the per-target client profiles applied to every request the toolkit sends.
+// Entries hold nil for targets without a profile.
+var clientProfiles sync.Map // map[int64]*models.TargetClientProfile
+
+// clientProfileReservedHeaders are set by the HTTP client or the toolkit itself, so a profile
+// cannot supply them.
+var clientProfileReservedHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Transfer-Encoding": true, "Connection": true,
+}
+
+// ValidateClientProfile checks the header names and values of a client profile.
+func ValidateClientProfile(p models.TargetClientProfile) error {
+	for name, value := range p.Headers {
+		canonical := http.CanonicalHeaderKey(strings.TrimSpace(name))
+		switch {
+		case !httpguts.ValidHeaderFieldName(canonical):
+			return fmt.Errorf("invalid header name '%s'", name)
+		case canonical == "Cookie":
+			return fmt.Errorf("invalid header 'Cookie': use the cookies field")
+		case canonical == "User-Agent":
+			return fmt.Errorf("invalid header 'User-Agent': use the user_agent field")
+		case clientProfileReservedHeaders[canonical] || isToolkitHeader(canonical):
+			return fmt.Errorf("invalid header '%s': it is set by the toolkit", canonical)
+		case !httpguts.ValidHeaderFieldValue(value):
+			return fmt.Errorf("invalid value for header '%s'", canonical)
+		}
+	}
+	if !httpguts.ValidHeaderFieldValue(p.UserAgent) {
+		return fmt.Errorf("invalid user_agent")
+	}
+	if !httpguts.ValidHeaderFieldValue(p.Cookies) {
+		return fmt.Errorf("invalid cookies")
+	}
+	return nil
+}
+
+// SaveTargetClientProfile validates and stores the client profile of a target. Header names are
+// stored in canonical form.
+func SaveTargetClientProfile(p models.TargetClientProfile) (models.TargetClientProfile, error) {
+	if _, err := database.GetTargetByID(p.TargetID); err != nil {
+		return p, err
+	}
+	if err := ValidateClientProfile(p); err != nil {
+		return p, err
+	}
+	headers := make(map[string]string, len(p.Headers))
+	for name, value := range p.Headers {
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	p.Headers = headers
+	p.UserAgent = strings.TrimSpace(p.UserAgent)
+	p.Cookies = strings.TrimSpace(p.Cookies)
+	if err := database.SaveTargetClientProfile(p); err != nil {
+		return p, err
+	}
+	clientProfiles.Delete(p.TargetID)
+	logger.Info("SaveTargetClientProfile: Target %d client profile has %d headers, user agent set %t, cookies set %t",
+		p.TargetID, len(p.Headers), p.UserAgent != "", p.Cookies != "")
+	return GetTargetClientProfile(p.TargetID)
+}
+
+// DeleteTargetClientProfile removes the client profile of a target.
+func DeleteTargetClientProfile(targetID int64) error {
+	if err := database.DeleteTargetClientProfile(targetID); err != nil {
+		return err
+	}
+	clientProfiles.Delete(targetID)
+	return nil
+}
+
+// GetTargetClientProfile returns the client profile of a target, empty when it has none.
+func GetTargetClientProfile(targetID int64) (models.TargetClientProfile, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.TargetClientProfile{}, err
+	}
+	p, err := database.GetTargetClientProfile(targetID)
+	if err != nil {
+		return models.TargetClientProfile{}, err
+	}
+	if p == nil {
+		return models.TargetClientProfile{TargetID: targetID, Headers: map[string]string{}}, nil
+	}
+	return *p, nil
+}
+
+// targetClientProfile returns the cached client profile of a target, or nil when it has none.
+func targetClientProfile(targetID int64) *models.TargetClientProfile {
+	if cached, ok := clientProfiles.Load(targetID); ok {
+		return cached.(*models.TargetClientProfile)
+	}
+	p, err := database.GetTargetClientProfile(targetID)
+	if err != nil {
+		logger.Error("Client profile: %v", err)
+		return nil
+	}
+	clientProfiles.Store(targetID, p)
+	return p
+}
+
+// ApplyClientProfile adds the client profile of a target to a request: profile headers and the
+// user agent are set unless the request already has them, and profile cookies are added to the
+// Cookie header unless the request sends a cookie of the same name.
+func ApplyClientProfile(req *http.Request, targetID int64) {
+	p := targetClientProfile(targetID)
+	if p == nil || p.IsEmpty() {
+		return
+	}
+	applyClientProfile(req.Header, *p)
+}
+
+func applyClientProfile(headers http.Header, p models.TargetClientProfile) {
+	for name, value := range p.Headers {
+		if len(headers.Values(name)) == 0 {
+			headers.Set(name, value)
+		}
+	}
+	if p.UserAgent != "" && headers.Get("User-Agent") == "" {
+		headers.Set("User-Agent", p.UserAgent)
+	}
+	if p.Cookies == "" {
+		return
+	}
+	sent := map[string]bool{}
+	for _, c := range (&http.Request{Header: headers}).Cookies() {
+		sent[c.Name] = true
+	}
+	var added []string
+	for _, pair := range strings.Split(p.Cookies, ";") {
+		pair = strings.TrimSpace(pair)
+		name, _, _ := strings.Cut(pair, "=")
+		if pair != "" && !sent[strings.TrimSpace(name)] {
+			added = append(added, pair)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	if existing := headers.Get("Cookie"); existing != "" {
+		added = append([]string{existing}, added...)
+	}
+	headers.Set("Cookie", strings.Join(added, "; "))
+}
+
+// ClientProfileHeaders returns the headers the client profile of a target adds, for tools run as
+// separate processes (httpx -H).
+func ClientProfileHeaders(targetID int64) http.Header {
+	headers := http.Header{}
+	if p := targetClientProfile(targetID); p != nil {
+		applyClientProfile(headers, *p)
+	}
+	return headers
+}
+
+// clientProfileTransport applies a target's client profile to every request of an HTTP client,
+// redirects included.
+type clientProfileTransport struct {
+	base     http.RoundTripper
+	targetID int64
+}
+
+// NewClientProfileTransport wraps base so the client profile of targetID is applied to each request.
+// The program constraints of the target bind the requests as well (see NewProgramConstraintsTransport).
+func NewClientProfileTransport(base http.RoundTripper, targetID int64) http.RoundTripper {
+	return &clientProfileTransport{base: NewProgramConstraintsTransport(base, targetID), targetID: targetID}
+}
+
+func (t *clientProfileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := targetClientProfile(t.targetID)
+	if p == nil || p.IsEmpty() {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the caller's request.
+	clone := req.Clone(req.Context())
+	applyClientProfile(clone.Header, *p)
+	return t.base.RoundTrip(clone)
+}
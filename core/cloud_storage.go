@@ -0,0 +1,924 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// azureStorageVersion is the Blob service version sent with Azure requests.
+const azureStorageVersion = "2021-08-06"
+
+// defaultBucketSuffixes are appended to the target's names when no suffixes are configured.
+var defaultBucketSuffixes = []string{
+	"dev", "development", "staging", "stage", "prod", "production", "test", "qa", "uat", "backup", "backups", "bak",
+	"assets", "static", "media", "images", "img", "uploads", "files", "data", "logs", "public", "private", "internal",
+	"cdn", "web", "www", "docs", "downloads", "archive", "config", "db", "terraform",
+}
+
+// Bucket references. Path-style hosts must start the match, so a virtual-hosted URL is not read
+// twice; the leading character they consume is not part of any name.
+var (
+	s3HostRegex   = regexp.MustCompile(`(?i)\b([a-z0-9][a-z0-9.\-]{1,61}[a-z0-9])\.s3(?:[.\-][a-z0-9\-]+)*\.amazonaws\.com`)
+	s3PathRegex   = regexp.MustCompile(`(?i)(?:^|[^a-z0-9.\-])s3(?:[.\-][a-z0-9\-]+)*\.amazonaws\.com/([a-z0-9][a-z0-9.\-]{1,61}[a-z0-9])`)
+	s3URIRegex    = regexp.MustCompile(`(?i)\bs3://([a-z0-9][a-z0-9.\-]{1,61}[a-z0-9])`)
+	gcsHostRegex  = regexp.MustCompile(`(?i)\b([a-z0-9][a-z0-9._\-]{1,61}[a-z0-9])\.storage\.googleapis\.com`)
+	gcsPathRegex  = regexp.MustCompile(`(?i)(?:^|[^a-z0-9.\-])storage\.(?:googleapis|cloud\.google)\.com/([a-z0-9][a-z0-9._\-]{1,61}[a-z0-9])`)
+	gcsAPIRegex   = regexp.MustCompile(`(?i)(?:storage\.googleapis\.com/(?:download/|upload/)?storage/v1|firebasestorage\.googleapis\.com/v0)/b/([a-z0-9][a-z0-9._\-]{1,61}[a-z0-9])`)
+	gcsURIRegex   = regexp.MustCompile(`(?i)\bgs://([a-z0-9][a-z0-9._\-]{1,61}[a-z0-9])`)
+	azureRegex    = regexp.MustCompile(`(?i)\b([a-z0-9]{3,24})\.blob\.core\.windows\.net/(\$?[a-z0-9][a-z0-9\-]{1,62})`)
+	s3NameRegex   = regexp.MustCompile(`^[a-z0-9][a-z0-9.\-]{1,61}[a-z0-9]$`)
+	gcsNameRegex  = regexp.MustCompile(`^[a-z0-9][a-z0-9._\-]{1,61}[a-z0-9]$`)
+	azureRefRegex = regexp.MustCompile(`^([a-z0-9]{3,24})/(\$?[a-z0-9][a-z0-9\-]{1,62})$`)
+	xmlCodeRegex  = regexp.MustCompile(`<Code>([^<]*)</Code>`)
+	xmlKeyRegex   = regexp.MustCompile(`<Key>([^<]*)</Key>`)
+	xmlBlobRegex  = regexp.MustCompile(`<Blob>\s*<Name>([^<]*)</Name>`)
+)
+
+// gcsReservedPaths are first path segments of storage.googleapis.com that are APIs, not buckets.
+var gcsReservedPaths = map[string]bool{"storage": true, "download": true, "upload": true, "batch": true}
+
+// CloudBucketRef is a bucket mentioned in a piece of text.
+type CloudBucketRef struct {
+	Provider string
+	Name     string // account/container for Azure
+	Match    string
+}
+
+// ExtractCloudBuckets returns the S3, GCS and Azure Blob buckets referenced in text, each once.
+func ExtractCloudBuckets(text string) []CloudBucketRef {
+	var refs []CloudBucketRef
+	seen := make(map[string]bool)
+	add := func(provider, name, match string) {
+		name = strings.ToLower(name)
+		if !validBucketName(provider, name) || seen[provider+"\x00"+name] {
+			return
+		}
+		seen[provider+"\x00"+name] = true
+		refs = append(refs, CloudBucketRef{Provider: provider, Name: name, Match: strings.TrimLeft(match, "/\"'=( \t\n")})
+	}
+	for _, re := range []*regexp.Regexp{s3HostRegex, s3PathRegex, s3URIRegex} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			add(models.CloudProviderS3, m[1], m[0])
+		}
+	}
+	for _, m := range gcsAPIRegex.FindAllStringSubmatch(text, -1) {
+		add(models.CloudProviderGCS, m[1], m[0])
+	}
+	for _, re := range []*regexp.Regexp{gcsHostRegex, gcsPathRegex, gcsURIRegex} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if !gcsReservedPaths[strings.ToLower(m[1])] {
+				add(models.CloudProviderGCS, m[1], m[0])
+			}
+		}
+	}
+	for _, m := range azureRegex.FindAllStringSubmatch(text, -1) {
+		add(models.CloudProviderAzure, m[1]+"/"+m[2], m[0])
+	}
+	return refs
+}
+
+// validBucketName reports whether name follows the provider's naming rules. Names that look like
+// IP addresses or AWS endpoints are rejected.
+func validBucketName(provider, name string) bool {
+	switch provider {
+	case models.CloudProviderS3:
+		return s3NameRegex.MatchString(name) && !strings.Contains(name, "..") && net.ParseIP(name) == nil &&
+			!strings.HasPrefix(name, "s3.") && !strings.HasPrefix(name, "s3-")
+	case models.CloudProviderGCS:
+		return gcsNameRegex.MatchString(name) && !strings.Contains(name, "..") && net.ParseIP(name) == nil &&
+			!strings.HasPrefix(name, "goog")
+	case models.CloudProviderAzure:
+		return azureRefRegex.MatchString(name)
+	}
+	return false
+}
+
+// cloudBucketURL returns the listing URL of a bucket. Path-style S3 and GCS URLs also work for
+// names with dots, which break the TLS certificates of virtual-hosted URLs.
+func cloudBucketURL(provider, name string) string {
+	switch provider {
+	case models.CloudProviderS3:
+		return "https://s3.amazonaws.com/" + name
+	case models.CloudProviderGCS:
+		return "https://storage.googleapis.com/" + name
+	case models.CloudProviderAzure:
+		account, container, _ := strings.Cut(name, "/")
+		return "https://" + account + ".blob.core.windows.net/" + container
+	}
+	return ""
+}
+
+// cloudBucketLabel names the kind of bucket of a provider in messages and finding titles.
+func cloudBucketLabel(provider string) string {
+	switch provider {
+	case models.CloudProviderS3:
+		return "S3 bucket"
+	case models.CloudProviderGCS:
+		return "GCS bucket"
+	case models.CloudProviderAzure:
+		return "Azure Blob container"
+	}
+	return provider + " bucket"
+}
+
+// cloudStorageItem is one unit of work of the checker: a bucket, or a candidate Azure storage
+// account whose containers are tried once the account is known to exist.
+type cloudStorageItem struct {
+	bucket       models.CloudBucket
+	azureAccount string
+}
+
+type cloudStorageChecker struct {
+	targetID   int64
+	client     *http.Client
+	resolver   *net.Resolver
+	containers []string
+	testWrite  bool
+
+	mu        sync.Mutex
+	titles    map[string]bool
+	checked   int
+	public    int
+	writable  []string
+	unclaimed []string
+	findings  int
+}
+
+// StartCloudStorageCheck starts a job checking a target's cloud storage buckets: candidate names
+// derived from its codename and in-scope domains, buckets referenced in its captured traffic, JS
+// endpoints and analyzer results, and the names of the request. Each bucket is checked for
+// existence and anonymous listing; GCS write access is read from its testPermissions API and S3
+// and Azure writes are only tried, with a marker object deleted right away, when the request opts
+// in. Candidates that do not exist are not stored. Writable buckets and referenced buckets that
+// do not exist (anyone can claim the name) become Draft findings.
+func StartCloudStorageCheck(targetID int64, req models.CloudStorageCheckRequest) (models.Job, error) {
+	target, err := database.GetTargetByID(targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	providers := make(map[string]bool)
+	for _, p := range req.Providers {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !containsString(models.CloudProviders, p) {
+			return models.Job{}, fmt.Errorf("invalid provider '%s' (use %s)", p, strings.Join(models.CloudProviders, ", "))
+		}
+		providers[p] = true
+	}
+	if len(providers) == 0 {
+		for _, p := range models.CloudProviders {
+			providers[p] = true
+		}
+	}
+
+	conf := config.AppConfig.CloudStorage
+	items, err := cloudStorageItems(target, req, providers, conf)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(items) == 0 {
+		return models.Job{}, fmt.Errorf("no buckets to check for target %d (pass names, or add domains or traffic referencing buckets)", targetID)
+	}
+	latest, err := LatestJob(models.JobTypeCloudStorage, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a cloud storage check is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	c := &cloudStorageChecker{
+		targetID: targetID,
+		// No client profile: the target's credentials must not reach the cloud providers. The
+		// program's constraints still apply.
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: NewRateLimitedTransport(NewProgramConstraintsTransport(&http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}, targetID)),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		resolver:   reconResolver(),
+		containers: conf.AzureContainers,
+		testWrite:  req.TestWrite,
+	}
+	workers := conf.Workers
+	return StartJob(models.JobTypeCloudStorage, &targetID, fmt.Sprintf("Checking %d cloud storage buckets...", len(items)), func(ctx context.Context, job *JobHandle) error {
+		return c.run(ctx, job, items, workers)
+	})
+}
+
+// cloudStorageItems collects the requested names, the referenced buckets and the candidates,
+// each bucket once in that order of precedence.
+func cloudStorageItems(target models.Target, req models.CloudStorageCheckRequest, providers map[string]bool, conf config.CloudStorageConfig) ([]cloudStorageItem, error) {
+	var items []cloudStorageItem
+	seen := make(map[string]bool)
+	add := func(provider, name, source string, logID int64) {
+		if !providers[provider] || seen[provider+"\x00"+name] {
+			return
+		}
+		seen[provider+"\x00"+name] = true
+		b := models.CloudBucket{TargetID: target.ID, Provider: provider, Name: name, URL: cloudBucketURL(provider, name), Source: source}
+		if logID != 0 {
+			id := logID
+			b.SourceLogID = &id
+		}
+		items = append(items, cloudStorageItem{bucket: b})
+	}
+
+	for _, name := range req.Names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if refs := ExtractCloudBuckets(name); len(refs) > 0 {
+			for _, ref := range refs {
+				add(ref.Provider, ref.Name, models.CloudBucketSourceManual, 0)
+			}
+			continue
+		}
+		valid := false
+		for _, provider := range models.CloudProviders {
+			if validBucketName(provider, name) {
+				valid = true
+				add(provider, name, models.CloudBucketSourceManual, 0)
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid bucket name '%s' (use a bucket name, account/container for Azure, or a bucket URL)", name)
+		}
+	}
+
+	if !req.SkipDiscovered {
+		refs, err := database.GetCloudStorageReferences(target.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			for _, bucket := range ExtractCloudBuckets(ref.Text) {
+				add(bucket.Provider, bucket.Name, ref.Source, ref.LogID)
+			}
+		}
+	}
+
+	if !req.SkipCandidates {
+		domains, err := takeoverDomains(target.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		names, accounts := cloudStorageCandidates(target, domains, conf)
+		for _, provider := range []string{models.CloudProviderS3, models.CloudProviderGCS} {
+			for _, name := range names {
+				if validBucketName(provider, name) {
+					add(provider, name, models.CloudBucketSourceCandidate, 0)
+				}
+			}
+		}
+		if providers[models.CloudProviderAzure] && len(conf.AzureContainers) > 0 {
+			for _, account := range accounts {
+				items = append(items, cloudStorageItem{azureAccount: account})
+			}
+		}
+	}
+	return items, nil
+}
+
+// cloudStorageCandidates derives candidate bucket names and Azure storage accounts from the
+// target's codename and the registrable and full names of its domains, with the configured
+// suffixes and prefixes, up to max_candidates of each.
+func cloudStorageCandidates(target models.Target, domains []models.Domain, conf config.CloudStorageConfig) ([]string, []string) {
+	max := conf.MaxCandidates
+	if max <= 0 {
+		max = 300
+	}
+	suffixes := conf.Suffixes
+	if len(suffixes) == 0 {
+		suffixes = defaultBucketSuffixes
+	}
+
+	var words, bases, hosts []string
+	addUnique := func(list *[]string, v string) {
+		if v != "" && !containsString(*list, v) {
+			*list = append(*list, v)
+		}
+	}
+	slug := strings.Trim(strings.ToLower(target.Slug), "-")
+	if slug == "" {
+		slug = strings.Trim(nonAlnumRegex.ReplaceAllString(strings.ToLower(target.Codename), "-"), "-")
+	}
+	addUnique(&words, slug)
+	addUnique(&words, strings.ReplaceAll(slug, "-", ""))
+	for _, d := range domains {
+		host := strings.ToLower(strings.TrimSuffix(d.DomainName, "."))
+		base := corsBaseDomain(host)
+		addUnique(&words, strings.SplitN(base, ".", 2)[0])
+		addUnique(&bases, base)
+		if host != base {
+			addUnique(&hosts, host)
+		}
+	}
+
+	var names []string
+	add := func(name string) {
+		if len(names) < max && !containsString(names, name) && validBucketName(models.CloudProviderS3, name) {
+			names = append(names, name)
+		}
+	}
+	for _, w := range words {
+		add(w)
+	}
+	for _, b := range bases {
+		add(b)
+		add(strings.ReplaceAll(b, ".", "-"))
+	}
+	// S3 website buckets are named after the host they serve.
+	for _, h := range hosts {
+		add(h)
+	}
+	for _, s := range suffixes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		for _, w := range words {
+			add(w + "-" + s)
+			add(w + s)
+			add(s + "-" + w)
+		}
+	}
+
+	var accounts []string
+	for _, name := range append([]string{}, names...) {
+		account := nonAlnumRegex.ReplaceAllString(name, "")
+		if len(accounts) < max && len(account) >= 3 && len(account) <= 24 && !containsString(accounts, account) {
+			accounts = append(accounts, account)
+		}
+	}
+	return names, accounts
+}
+
+var nonAlnumRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+func (c *cloudStorageChecker) run(ctx context.Context, job *JobHandle, items []cloudStorageItem, workers int) error {
+	job.SetTotal(int64(len(items)))
+	existing, err := database.GetTargetFindingsByTargetID(c.targetID)
+	if err != nil {
+		return err
+	}
+	c.titles = make(map[string]bool, len(existing))
+	for _, f := range existing {
+		c.titles[f.Title] = true
+	}
+	if workers <= 0 {
+		workers = 10
+	}
+
+	ch := make(chan cloudStorageItem)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range ch {
+				var exposed bool
+				var err error
+				if item.azureAccount != "" {
+					exposed, err = c.checkAzureAccount(ctx, item.azureAccount)
+				} else {
+					exposed, err = c.checkBucket(ctx, item.bucket)
+				}
+				if err != nil {
+					job.RecordError(err)
+				}
+				if exposed {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			break feed
+		case ch <- item:
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	job.SetMessage("Checked %d buckets: %d public read, %d writable, %d unclaimed references, %d new findings.",
+		c.checked, c.public, len(c.writable), len(c.unclaimed), c.findings)
+	if c.findings > 0 {
+		exposed := append(append([]string{}, c.writable...), c.unclaimed...)
+		NotifyTargetEvent(models.NotificationScanFinished, c.targetID,
+			fmt.Sprintf("Cloud storage check found %d writable and %d unclaimed buckets", len(c.writable), len(c.unclaimed)),
+			SubdomainNotificationList(exposed, 10), exposed)
+	}
+	return ctx.Err()
+}
+
+// checkAzureAccount tries the configured containers of a candidate storage account that resolves.
+func (c *cloudStorageChecker) checkAzureAccount(ctx context.Context, account string) (bool, error) {
+	exists, err := c.resolves(ctx, account+".blob.core.windows.net")
+	if err != nil || !exists {
+		return false, err
+	}
+	var exposed bool
+	var errs []error
+	for _, container := range c.containers {
+		name := account + "/" + strings.ToLower(strings.TrimSpace(container))
+		if !validBucketName(models.CloudProviderAzure, name) {
+			continue
+		}
+		b := models.CloudBucket{TargetID: c.targetID, Provider: models.CloudProviderAzure, Name: name,
+			URL: cloudBucketURL(models.CloudProviderAzure, name), Source: models.CloudBucketSourceCandidate}
+		found, err := c.checkBucket(ctx, b)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		exposed = exposed || found
+	}
+	return exposed, errors.Join(errs...)
+}
+
+// checkBucket checks one bucket, stores the result and files findings. It reports whether the
+// bucket can be listed or written, or is referenced by the target but free to claim.
+func (c *cloudStorageChecker) checkBucket(ctx context.Context, b models.CloudBucket) (bool, error) {
+	candidate := b.Source == models.CloudBucketSourceCandidate
+	var evidence, writeEvidence models.HTTPTrafficLog
+	var err error
+	switch b.Provider {
+	case models.CloudProviderS3:
+		evidence, writeEvidence, err = c.checkS3(ctx, &b, !candidate)
+	case models.CloudProviderGCS:
+		evidence, writeEvidence, err = c.checkGCS(ctx, &b, !candidate)
+	case models.CloudProviderAzure:
+		evidence, writeEvidence, err = c.checkAzure(ctx, &b, !candidate)
+	}
+	if err != nil {
+		b.Status = models.CloudBucketStatusError
+		b.Details = err.Error()
+		err = fmt.Errorf("%s %s: %w", b.Provider, b.Name, err)
+	}
+	if candidate && b.Status != models.CloudBucketStatusPrivate && b.Status != models.CloudBucketStatusPublicRead {
+		return false, err
+	}
+	if evidence.ID != 0 {
+		b.EvidenceLogID = &evidence.ID
+	}
+
+	id, _, upsertErr := database.UpsertCloudBucket(b)
+	if upsertErr != nil {
+		return false, upsertErr
+	}
+	b.ID = id
+	if updateErr := database.UpdateCloudBucketCheck(b); updateErr != nil {
+		return false, updateErr
+	}
+
+	label := b.Provider + ":" + b.Name
+	c.mu.Lock()
+	c.checked++
+	if b.Status == models.CloudBucketStatusPublicRead {
+		c.public++
+	}
+	writable := b.PublicWrite != nil && *b.PublicWrite
+	// A missing Azure container can be private; only a missing storage account is free to claim.
+	unclaimed := !candidate && b.Status == models.CloudBucketStatusNotFound &&
+		(b.Provider != models.CloudProviderAzure || strings.HasPrefix(b.Details, "storage account"))
+	if writable {
+		c.writable = append(c.writable, label)
+	}
+	if unclaimed {
+		c.unclaimed = append(c.unclaimed, label)
+	}
+	c.mu.Unlock()
+
+	switch {
+	case writable:
+		logger.Info("Cloud storage check: %s %s is publicly writable", cloudBucketLabel(b.Provider), b.Name)
+		return true, errors.Join(err, c.fileWritableFinding(b, writeEvidence))
+	case unclaimed:
+		logger.Info("Cloud storage check: %s %s is referenced by the target but does not exist", cloudBucketLabel(b.Provider), b.Name)
+		return true, errors.Join(err, c.fileUnclaimedFinding(b, evidence))
+	}
+	return b.Status == models.CloudBucketStatusPublicRead, err
+}
+
+// send performs a request and logs it to the traffic log when logAll is set or the response is not
+// a 404, so the misses of candidate names stay out of the log. The entry of an unlogged response
+// has no ID.
+func (c *cloudStorageChecker) send(req *http.Request, logAll bool) (models.HTTPTrafficLog, http.Header, error) {
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return models.HTTPTrafficLog{}, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, probeMaxBody))
+	if err != nil {
+		return models.HTTPTrafficLog{}, nil, fmt.Errorf("reading response: %w", err)
+	}
+	if logAll || resp.StatusCode != http.StatusNotFound {
+		return logProbe(c.targetID, req, resp, body, start, models.CloudStorageLogSource), resp.Header, nil
+	}
+	return models.HTTPTrafficLog{RequestURL: models.NullString(req.URL.String()), ResponseStatusCode: resp.StatusCode, ResponseBody: body}, resp.Header, nil
+}
+
+func (c *cloudStorageChecker) get(ctx context.Context, rawURL string, header http.Header, logAll bool) (models.HTTPTrafficLog, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return models.HTTPTrafficLog{}, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.send(req, logAll)
+}
+
+// tryWrite uploads a marker object to objectURL and deletes it again. It reports whether the
+// upload succeeded, with its log entry; a refused upload is not an error.
+func (c *cloudStorageChecker) tryWrite(ctx context.Context, objectURL string, header http.Header) (bool, models.HTTPTrafficLog, error) {
+	body := "Written by a security write check; safe to delete.\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, strings.NewReader(body))
+	if err != nil {
+		return false, models.HTTPTrafficLog{}, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	entry, _, err := c.send(req, true)
+	if err != nil {
+		return false, entry, fmt.Errorf("uploading write check object: %w", err)
+	}
+	if entry.ResponseStatusCode < 200 || entry.ResponseStatusCode > 299 {
+		return false, entry, nil
+	}
+	del, err := http.NewRequestWithContext(ctx, http.MethodDelete, objectURL, nil)
+	if err != nil {
+		return true, entry, err
+	}
+	for k, v := range header {
+		if k != "X-Ms-Blob-Type" {
+			del.Header[k] = v
+		}
+	}
+	if deleted, _, err := c.send(del, true); err != nil || deleted.ResponseStatusCode < 200 || deleted.ResponseStatusCode > 299 {
+		logger.Warn("Cloud storage check: Could not delete write check object %s; remove it by hand", objectURL)
+	}
+	return true, entry, nil
+}
+
+// writeCheckObject returns a unique name for the write check marker object.
+func writeCheckObject() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "toolkit-write-check-" + hex.EncodeToString(b) + ".txt"
+}
+
+// checkS3 lists an S3 bucket anonymously through the global endpoint, following the redirect to
+// the bucket's region, and tries a write when enabled.
+func (c *cloudStorageChecker) checkS3(ctx context.Context, b *models.CloudBucket, logAll bool) (models.HTTPTrafficLog, models.HTTPTrafficLog, error) {
+	endpoint := "https://s3.amazonaws.com"
+	var entry models.HTTPTrafficLog
+	var header http.Header
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		entry, header, err = c.get(ctx, endpoint+"/"+b.Name+"?max-keys=5", nil, logAll)
+		if err != nil {
+			return entry, models.HTTPTrafficLog{}, err
+		}
+		region := header.Get("X-Amz-Bucket-Region")
+		if (entry.ResponseStatusCode == http.StatusMovedPermanently || entry.ResponseStatusCode == http.StatusTemporaryRedirect) &&
+			region != "" && attempt == 0 {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+			continue
+		}
+		break
+	}
+	b.URL = endpoint + "/" + b.Name
+	body := string(entry.ResponseBody)
+	code := xmlCode(body)
+	var details []string
+	if region := header.Get("X-Amz-Bucket-Region"); region != "" {
+		details = append(details, "region "+region)
+	}
+	switch {
+	case entry.ResponseStatusCode == http.StatusOK && strings.Contains(body, "<ListBucketResult"):
+		b.Status = models.CloudBucketStatusPublicRead
+		details = append(details, sampleKeys(body, xmlKeyRegex))
+	case entry.ResponseStatusCode == http.StatusForbidden:
+		b.Status = models.CloudBucketStatusPrivate
+		details = append(details, code)
+	case entry.ResponseStatusCode == http.StatusNotFound && code == "NoSuchBucket":
+		b.Status = models.CloudBucketStatusNotFound
+		return entry, models.HTTPTrafficLog{}, nil
+	default:
+		return entry, models.HTTPTrafficLog{}, fmt.Errorf("unexpected %d response %s", entry.ResponseStatusCode, code)
+	}
+
+	var write models.HTTPTrafficLog
+	if c.testWrite {
+		ok, logged, err := c.tryWrite(ctx, b.URL+"/"+writeCheckObject(), nil)
+		if err != nil {
+			details = append(details, err.Error())
+		} else {
+			b.PublicWrite = &ok
+			write = logged
+		}
+	}
+	b.Details = joinDetails(details)
+	return entry, write, nil
+}
+
+// checkGCS lists a GCS bucket anonymously through the XML API and asks the testPermissions API
+// which object permissions anonymous users have, which writes nothing.
+func (c *cloudStorageChecker) checkGCS(ctx context.Context, b *models.CloudBucket, logAll bool) (models.HTTPTrafficLog, models.HTTPTrafficLog, error) {
+	entry, _, err := c.get(ctx, b.URL+"?max-keys=5", nil, logAll)
+	if err != nil {
+		return entry, models.HTTPTrafficLog{}, err
+	}
+	body := string(entry.ResponseBody)
+	code := xmlCode(body)
+	var details []string
+	switch {
+	case entry.ResponseStatusCode == http.StatusOK && strings.Contains(body, "<ListBucketResult"):
+		b.Status = models.CloudBucketStatusPublicRead
+		details = append(details, sampleKeys(body, xmlKeyRegex))
+	case entry.ResponseStatusCode == http.StatusForbidden || entry.ResponseStatusCode == http.StatusUnauthorized:
+		b.Status = models.CloudBucketStatusPrivate
+		details = append(details, code)
+	case entry.ResponseStatusCode == http.StatusNotFound && code == "NoSuchBucket",
+		entry.ResponseStatusCode == http.StatusBadRequest && code == "InvalidBucketName":
+		b.Status = models.CloudBucketStatusNotFound
+		return entry, models.HTTPTrafficLog{}, nil
+	default:
+		return entry, models.HTTPTrafficLog{}, fmt.Errorf("unexpected %d response %s", entry.ResponseStatusCode, code)
+	}
+
+	var write models.HTTPTrafficLog
+	q := url.Values{"permissions": {"storage.objects.list", "storage.objects.get", "storage.objects.create", "storage.objects.delete"}}
+	perms, _, err := c.get(ctx, "https://storage.googleapis.com/storage/v1/b/"+url.PathEscape(b.Name)+"/iam/testPermissions?"+q.Encode(), nil, true)
+	if err == nil && perms.ResponseStatusCode == http.StatusOK {
+		var granted struct {
+			Permissions []string `json:"permissions"`
+		}
+		if json.Unmarshal(perms.ResponseBody, &granted) == nil {
+			writable := containsString(granted.Permissions, "storage.objects.create")
+			b.PublicWrite = &writable
+			if len(granted.Permissions) > 0 {
+				details = append(details, "anonymous permissions: "+strings.Join(granted.Permissions, ", "))
+			}
+			if writable {
+				write = perms
+			}
+		}
+	} else if err != nil {
+		details = append(details, "testPermissions: "+err.Error())
+	}
+	b.Details = joinDetails(details)
+	return entry, write, nil
+}
+
+// checkAzure lists an Azure Blob container anonymously. A missing storage account is told apart
+// from a missing container, since anyone can create an account under an unused name. Containers
+// that are private, or only allow reading single blobs, answer 404 like missing ones.
+func (c *cloudStorageChecker) checkAzure(ctx context.Context, b *models.CloudBucket, logAll bool) (models.HTTPTrafficLog, models.HTTPTrafficLog, error) {
+	account, _, _ := strings.Cut(b.Name, "/")
+	exists, err := c.resolves(ctx, account+".blob.core.windows.net")
+	if err != nil {
+		return models.HTTPTrafficLog{}, models.HTTPTrafficLog{}, err
+	}
+	if !exists {
+		b.Status = models.CloudBucketStatusNotFound
+		b.Details = fmt.Sprintf("storage account %s does not exist", account)
+		return models.HTTPTrafficLog{}, models.HTTPTrafficLog{}, nil
+	}
+	header := http.Header{"X-Ms-Version": {azureStorageVersion}}
+	entry, _, err := c.get(ctx, b.URL+"?restype=container&comp=list&maxresults=5", header, logAll)
+	if err != nil {
+		return entry, models.HTTPTrafficLog{}, err
+	}
+	body := string(entry.ResponseBody)
+	code := xmlCode(body)
+	var details []string
+	switch {
+	case entry.ResponseStatusCode == http.StatusOK && strings.Contains(body, "<EnumerationResults"):
+		b.Status = models.CloudBucketStatusPublicRead
+		details = append(details, sampleKeys(body, xmlBlobRegex))
+	case entry.ResponseStatusCode == http.StatusForbidden || entry.ResponseStatusCode == http.StatusConflict:
+		b.Status = models.CloudBucketStatusPrivate
+		details = append(details, code)
+	case entry.ResponseStatusCode == http.StatusNotFound:
+		b.Status = models.CloudBucketStatusNotFound
+		b.Details = joinDetails([]string{"container missing or private", code})
+		return entry, models.HTTPTrafficLog{}, nil
+	default:
+		return entry, models.HTTPTrafficLog{}, fmt.Errorf("unexpected %d response %s", entry.ResponseStatusCode, code)
+	}
+
+	var write models.HTTPTrafficLog
+	if c.testWrite {
+		writeHeader := http.Header{"X-Ms-Version": {azureStorageVersion}, "X-Ms-Blob-Type": {"BlockBlob"}}
+		ok, logged, err := c.tryWrite(ctx, b.URL+"/"+writeCheckObject(), writeHeader)
+		if err != nil {
+			details = append(details, err.Error())
+		} else {
+			b.PublicWrite = &ok
+			write = logged
+		}
+	}
+	b.Details = joinDetails(details)
+	return entry, write, nil
+}
+
+// resolves reports whether host has addresses; false means NXDOMAIN or no records.
+func (c *cloudStorageChecker) resolves(ctx context.Context, host string) (bool, error) {
+	_, err := c.resolver.LookupHost(ctx, host)
+	if err == nil {
+		return true, nil
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("resolving %s: %w", host, err)
+}
+
+// xmlCode returns the error code of an S3-style XML error response.
+func xmlCode(body string) string {
+	if m := xmlCodeRegex.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// sampleKeys lists the first object names of a bucket listing, matched by re.
+func sampleKeys(body string, re *regexp.Regexp) string {
+	var keys []string
+	for _, m := range re.FindAllStringSubmatch(body, 6) {
+		if m[1] != "" {
+			keys = append(keys, m[1])
+		}
+	}
+	if len(keys) == 0 {
+		return "empty listing"
+	}
+	return "objects: " + strings.Join(keys, ", ")
+}
+
+func joinDetails(details []string) string {
+	var parts []string
+	for _, d := range details {
+		if d != "" {
+			parts = append(parts, d)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// claimFinding reserves a finding title, reporting false when the target already has it.
+func (c *cloudStorageChecker) claimFinding(title string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.titles[title] {
+		return false
+	}
+	c.titles[title] = true
+	return true
+}
+
+// createFinding adds the evidence of entry to a Draft finding and stores it.
+func (c *cloudStorageChecker) createFinding(finding models.TargetFinding, b *strings.Builder, steps string, entry models.HTTPTrafficLog, method string) error {
+	if entry.ID != 0 {
+		if rules := EffectiveRedactionRules(&c.targetID); rules.Enabled && rules.ApplyAt != models.RedactionApplyStorage {
+			RedactTrafficLog(&entry, rules)
+		}
+		b.WriteString("\n")
+		b.WriteString(findingEvidence(entry, method, HeadersFromJSON(entry.RequestHeaders.String)))
+		if !finding.HTTPTrafficLogID.Valid {
+			finding.HTTPTrafficLogID = sql.NullInt64{Int64: entry.ID, Valid: true}
+		}
+	}
+	finding.TargetID = c.targetID
+	finding.Description = models.NullString(b.String())
+	finding.StepsToReproduce = models.NullString(steps)
+	finding.Severity = models.NullString("High")
+	finding.Status = models.FindingStatusDraft
+	if vt, err := FindVulnerabilityType(0, "Security Misconfiguration"); err == nil {
+		finding.VulnerabilityTypeID = sql.NullInt64{Int64: vt.ID, Valid: true}
+	}
+	id, err := database.CreateTargetFinding(finding)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.findings++
+	c.mu.Unlock()
+	logger.Info("Cloud storage check: Draft finding %d: %s", id, finding.Title)
+	return nil
+}
+
+// fileWritableFinding creates a Draft finding for a bucket anonymous users can write to.
+func (c *cloudStorageChecker) fileWritableFinding(bucket models.CloudBucket, evidence models.HTTPTrafficLog) error {
+	label := cloudBucketLabel(bucket.Provider)
+	title := fmt.Sprintf("Publicly writable %s: %s", label, bucket.Name)
+	if !c.claimFinding(title) {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Anonymous users can upload objects to the %s `%s` (`%s`).\n\n", label, bucket.Name, bucket.URL)
+	fmt.Fprintf(&b, "- **Status:** %s\n- **Source:** %s\n", bucket.Status, bucket.Source)
+	if bucket.Details != "" {
+		fmt.Fprintf(&b, "- **Details:** %s\n", bucket.Details)
+	}
+	var steps string
+	method := http.MethodPut
+	if bucket.Provider == models.CloudProviderGCS {
+		method = http.MethodGet
+		steps = fmt.Sprintf("1. Ask GCS which permissions anonymous users have on the bucket; `storage.objects.create` is granted:\n\n```sh\ncurl '%s'\n```\n\n",
+			evidence.RequestURL.String) +
+			fmt.Sprintf("2. Upload a harmless marker object and delete it again:\n\n```sh\ncurl -X PUT --data 'write check' '%s/%s'\n```", bucket.URL, writeCheckObject())
+	} else {
+		headers := ""
+		if bucket.Provider == models.CloudProviderAzure {
+			headers = " -H 'x-ms-blob-type: BlockBlob'"
+		}
+		steps = fmt.Sprintf("1. Upload a harmless marker object without credentials; the upload succeeds:\n\n```sh\ncurl -X PUT%s --data 'write check' '%s/%s'\n```\n\n",
+			headers, bucket.URL, writeCheckObject()) +
+			"2. Delete the marker object again with `curl -X DELETE` on the same URL."
+	}
+	finding := models.TargetFinding{
+		Title: title,
+		Impact: models.NullString(fmt.Sprintf("Anyone can add or overwrite objects in `%s`: files served from it to the target's users "+
+			"(scripts, downloads, images) can be replaced with malicious ones, and the owner pays for whatever is stored.", bucket.Name)),
+		Payload: models.NullString(bucket.URL),
+	}
+	return c.createFinding(finding, &b, steps, evidence, method)
+}
+
+// fileUnclaimedFinding creates a Draft finding for a bucket the target references that does not
+// exist, so anyone can create it and serve content in its place.
+func (c *cloudStorageChecker) fileUnclaimedFinding(bucket models.CloudBucket, evidence models.HTTPTrafficLog) error {
+	label := cloudBucketLabel(bucket.Provider)
+	name := bucket.Name
+	if bucket.Provider == models.CloudProviderAzure {
+		label = "Azure storage account"
+		name, _, _ = strings.Cut(bucket.Name, "/")
+	}
+	title := fmt.Sprintf("Unclaimed %s referenced by the target: %s", label, name)
+	if !c.claimFinding(title) {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "The target references the %s `%s` (`%s`), which does not exist. Anyone can create it under that name.\n\n", label, name, bucket.URL)
+	fmt.Fprintf(&b, "- **Referenced in:** %s", bucket.Source)
+	if bucket.SourceLogID != nil {
+		fmt.Fprintf(&b, " (traffic log entry %d)", *bucket.SourceLogID)
+	}
+	b.WriteString("\n")
+	if bucket.Details != "" {
+		fmt.Fprintf(&b, "- **Details:** %s\n", bucket.Details)
+	}
+	var steps string
+	if bucket.Provider == models.CloudProviderAzure {
+		steps = fmt.Sprintf("1. Observe that the storage account does not resolve:\n\n```sh\ndig %s.blob.core.windows.net\n```\n\n", name)
+	} else {
+		steps = fmt.Sprintf("1. Request the bucket and observe the `NoSuchBucket` error:\n\n```sh\ncurl '%s'\n```\n\n", bucket.URL)
+	}
+	steps += fmt.Sprintf("2. Create `%s` in an account you control and serve a harmless proof-of-concept object where the target expects its files.", name)
+	finding := models.TargetFinding{
+		Title: title,
+		Impact: models.NullString(fmt.Sprintf("Whoever creates `%s` controls the files the target loads from it: scripts served to its users, "+
+			"downloads or uploads sent there by the application.", name)),
+		Payload: models.NullString(bucket.URL),
+	}
+	if bucket.SourceLogID != nil {
+		finding.HTTPTrafficLogID = sql.NullInt64{Int64: *bucket.SourceLogID, Valid: true}
+	}
+	return c.createFinding(finding, &b, steps, evidence, http.MethodGet)
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// LocalUser is the user of API requests that neither carry a named token nor an X-Toolkit-User
+// header.
+const LocalUser = "local"
+
+// maxLockTTL bounds how long a lock may be taken for; a lock is refreshed while editing.
+const maxLockTTL = time.Hour
+
+type apiUserKey struct{}
+
+// WithAPIUser returns a context carrying the user of an API request: the holder of its token,
+// else its X-Toolkit-User header, else LocalUser.
+func WithAPIUser(r *http.Request, holder string) context.Context {
+	user := holder
+	if user == "" {
+		user = sanitizeUserName(r.Header.Get("X-Toolkit-User"))
+	}
+	if user == "" {
+		user = LocalUser
+	}
+	return context.WithValue(r.Context(), apiUserKey{}, user)
+}
+
+// APIUser returns the user of an API request, LocalUser outside one.
+func APIUser(ctx context.Context) string {
+	if user, ok := ctx.Value(apiUserKey{}).(string); ok {
+		return user
+	}
+	return LocalUser
+}
+
+// sanitizeUserName keeps the printable characters of a user name, at most 64 of them.
+func sanitizeUserName(name string) string {
+	var b strings.Builder
+	for _, c := range strings.TrimSpace(name) {
+		if c < 0x20 || c == 0x7f {
+			continue
+		}
+		b.WriteRune(c)
+		if b.Len() >= 64 {
+			break
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func presenceTTL() time.Duration {
+	if s := config.AppConfig.Collab.PresenceTTLSeconds; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return time.Minute
+}
+
+func lockTTL(seconds int) time.Duration {
+	if seconds <= 0 {
+		seconds = config.AppConfig.Collab.LockTTLSeconds
+	}
+	if seconds <= 0 {
+		seconds = 300
+	}
+	if ttl := time.Duration(seconds) * time.Second; ttl < maxLockTTL {
+		return ttl
+	}
+	return maxLockTTL
+}
+
+// TouchPresence records that a user views an entity. Any entity type may be viewed.
+func TouchPresence(user string, req models.PresenceRequest) (models.Presence, error) {
+	req.EntityType = strings.ToLower(strings.TrimSpace(req.EntityType))
+	req.EntityID = strings.TrimSpace(req.EntityID)
+	if req.EntityType == "" || req.EntityID == "" {
+		return models.Presence{}, models.InvalidRequestError("entity_type and entity_id are required")
+	}
+	now := time.Now().UTC()
+	p := models.Presence{User: user, EntityType: req.EntityType, EntityID: req.EntityID, SeenAt: now}
+	if err := database.TouchPresence(p, now.Add(-presenceTTL())); err != nil {
+		return p, models.InternalError(err, "recording presence")
+	}
+	return p, nil
+}
+
+// LeavePresence records that a user no longer views an entity.
+func LeavePresence(user, entityType, entityID string) error {
+	if entityType == "" || entityID == "" {
+		return models.InvalidRequestError("entity_type and entity_id are required")
+	}
+	if err := database.RemovePresence(user, strings.ToLower(entityType), entityID); err != nil {
+		return models.InternalError(err, "removing presence")
+	}
+	return nil
+}
+
+// ListPresence returns who views an entity, or everything when entityType is empty.
+func ListPresence(entityType, entityID string) ([]models.Presence, error) {
+	presence, err := database.ListPresence(strings.ToLower(entityType), entityID, time.Now().Add(-presenceTTL()))
+	if err != nil {
+		return nil, models.InternalError(err, "listing presence")
+	}
+	return presence, nil
+}
+
+// checkLockEntity validates the entity of a lock and that it exists.
+func checkLockEntity(entityType, entityID string) error {
+	id, err := strconv.ParseInt(entityID, 10, 64)
+	if err != nil || id <= 0 {
+		return models.InvalidRequestError("invalid entity_id %q", entityID)
+	}
+	switch entityType {
+	case models.LockEntityFinding:
+		if _, err := database.GetTargetFindingByID(id); err != nil {
+			return models.NotFoundError("finding %d not found", id)
+		}
+	case models.LockEntityNote:
+		if _, err := database.GetNoteByID(id); err != nil {
+			return models.NotFoundError("note %d not found", id)
+		}
+	default:
+		return models.InvalidRequestError("entity_type must be %s or %s", models.LockEntityFinding, models.LockEntityNote)
+	}
+	return nil
+}
+
+// AcquireLock takes or refreshes a soft lock on a finding or note for a user. A lock another
+// user holds is a conflict unless req.Force takes it over.
+func AcquireLock(user string, req models.LockRequest) (models.EntityLock, error) {
+	req.EntityType = strings.ToLower(strings.TrimSpace(req.EntityType))
+	req.EntityID = strings.TrimSpace(req.EntityID)
+	if err := checkLockEntity(req.EntityType, req.EntityID); err != nil {
+		return models.EntityLock{}, err
+	}
+	now := time.Now().UTC()
+	lock := models.EntityLock{
+		EntityType: req.EntityType,
+		EntityID:   req.EntityID,
+		User:       user,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(lockTTL(req.TTLSeconds)),
+	}
+	current, held, err := database.AcquireLock(lock, req.Force)
+	if err != nil {
+		return lock, models.InternalError(err, "taking lock")
+	}
+	if held {
+		return current, models.ConflictError("%s %s is locked by %s until %s", current.EntityType, current.EntityID,
+			current.User, current.ExpiresAt.Format(time.RFC3339))
+	}
+	if req.Force {
+		logger.Info("Collaboration: %s forced the lock on %s %s", user, current.EntityType, current.EntityID)
+	}
+	return current, nil
+}
+
+// ReleaseLock removes a user's lock on an entity. Another user's lock is a conflict unless force
+// is set.
+func ReleaseLock(user, entityType, entityID string, force bool) error {
+	entityType = strings.ToLower(entityType)
+	lock, found, err := database.GetLock(entityType, entityID, time.Now())
+	if err != nil {
+		return models.InternalError(err, "looking up lock")
+	}
+	if !found {
+		return models.NotFoundError("%s %s is not locked", entityType, entityID)
+	}
+	if lock.User != user && !force {
+		return models.ConflictError("%s %s is locked by %s", entityType, entityID, lock.User)
+	}
+	if err := database.ReleaseLock(entityType, entityID); err != nil {
+		return models.InternalError(err, "releasing lock")
+	}
+	return nil
+}
+
+// ListLocks returns the locks that have not expired.
+func ListLocks() ([]models.EntityLock, error) {
+	locks, err := database.ListLocks(time.Now())
+	if err != nil {
+		return nil, models.InternalError(err, "listing locks")
+	}
+	return locks, nil
+}
+
+// LockHolder returns who else than user holds the lock on an entity, "" when nobody does. Edits
+// are not refused; the caller reports the holder.
+func LockHolder(user, entityType string, entityID int64) string {
+	lock, found, err := database.GetLock(entityType, strconv.FormatInt(entityID, 10), time.Now())
+	if err != nil {
+		logger.Error("Collaboration: Looking up lock on %s %d: %v", entityType, entityID, err)
+		return ""
+	}
+	if !found || lock.User == user {
+		return ""
+	}
+	return lock.User
+}
+
+var activityPrune struct {
+	sync.Mutex
+	last time.Time
+}
+
+// RecordActivity stores a mutation made through the API and, at most hourly, prunes the activity
+// older than collaboration.activity_retention_days.
+func RecordActivity(a models.Activity) {
+	if _, err := database.RecordActivity(a); err != nil {
+		logger.Error("Collaboration: %v", err)
+		return
+	}
+	days := config.AppConfig.Collab.ActivityRetentionDays
+	if days <= 0 {
+		return
+	}
+	activityPrune.Lock()
+	due := time.Since(activityPrune.last) >= time.Hour
+	if due {
+		activityPrune.last = time.Now()
+	}
+	activityPrune.Unlock()
+	if !due {
+		return
+	}
+	if n, err := database.PruneActivity(time.Now().AddDate(0, 0, -days)); err != nil {
+		logger.Error("Collaboration: %v", err)
+	} else if n > 0 {
+		logger.Info("Collaboration: Pruned %d activity entries older than %d days", n, days)
+	}
+}
+
+// ListActivity returns the activity feed, newest first, or oldest first after filters.AfterID.
+func ListActivity(filters models.ActivityFilters) ([]models.Activity, error) {
+	if filters.Limit <= 0 || filters.Limit > 1000 {
+		filters.Limit = 100
+	}
+	activity, err := database.ListActivity(filters)
+	if err != nil {
+		return nil, models.InternalError(err, "listing activity")
+	}
+	return activity, nil
+}
+
+// LatestActivityID returns the ID of the latest activity, where an event stream starts by default.
+func LatestActivityID() (int64, error) {
+	id, err := database.LatestActivityID()
+	if err != nil {
+		return 0, fmt.Errorf("collaboration: %w", err)
+	}
+	return id, nil
+}
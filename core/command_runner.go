@@ -0,0 +1,427 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// commandPlaceholderRegex matches {{name}} placeholders and the <name> form used by the seeded checklist templates.
+var commandPlaceholderRegex = regexp.MustCompile(`\{\{\s*([a-zA-Z][a-zA-Z0-9_]*)\s*\}\}|<([a-z][a-z0-9_]*)>`)
+
+// commandSentinelRegex matches the markers placeholders are swapped for while a command line is split.
+var commandSentinelRegex = regexp.MustCompile("\x00([0-9]+)\x00")
+
+// commandRunState tracks a running checklist command so its output can be streamed while it runs.
+type commandRunState struct {
+	mu        sync.Mutex
+	output    []byte
+	truncated bool
+	maxBytes  int
+	done      bool
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// Write implements io.Writer for the command's stdout and stderr, discarding output past the size limit.
+func (s *commandRunState) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remaining := s.maxBytes - len(s.output)
+	if remaining <= 0 {
+		s.truncated = true
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		s.output = append(s.output, p[:remaining]...)
+		s.truncated = true
+		return len(p), nil
+	}
+	s.output = append(s.output, p...)
+	return len(p), nil
+}
+
+var (
+	activeCommandRuns     = make(map[int64]*commandRunState)
+	activeCommandRunsLock = &sync.Mutex{}
+)
+
+// commandVariables returns the placeholder values available to a checklist item's command.
+// Values in overrides take precedence.
+func commandVariables(targetID int64, overrides map[string]string) map[string]string {
+	vars := map[string]string{
+		"target_id":  strconv.FormatInt(targetID, 10),
+		"proxy_port": config.AppConfig.Proxy.Port,
+		"proxy_url":  "http://127.0.0.1:" + config.AppConfig.Proxy.Port,
+	}
+	if target, err := database.GetTargetByID(targetID); err == nil {
+		vars["target_codename"] = target.Codename
+		if target.Link != "" {
+			vars["target_url"] = target.Link
+			if u, errParse := url.Parse(target.Link); errParse == nil && u.Hostname() != "" {
+				vars["target_domain"] = u.Hostname()
+			}
+		}
+	} else {
+		logger.Warn("commandVariables: Could not load target %d: %v", targetID, err)
+	}
+	if vars["target_domain"] == "" {
+		// Fall back to the first in-scope domain rule.
+		if rules, err := database.GetScopeRulesByTargetID(targetID); err == nil {
+			for _, rule := range rules {
+				if rule.IsInScope && (rule.ItemType == "domain" || rule.ItemType == "subdomain") {
+					vars["target_domain"] = strings.TrimPrefix(strings.TrimSpace(rule.Pattern), "*.")
+					break
+				}
+			}
+		}
+	}
+	if vars["target_url"] == "" && vars["target_domain"] != "" {
+		vars["target_url"] = "https://" + vars["target_domain"]
+	}
+	wordlistCommandVariables(targetID, vars)
+	for k, v := range overrides {
+		vars[k] = v
+	}
+	return vars
+}
+
+// BuildChecklistCommand fills the placeholders of a command line and splits it into arguments.
+// Placeholders are substituted after splitting, so a value can never add arguments or shell syntax.
+// Pipes, redirection, command chaining and substitution are rejected because no shell is involved.
+func BuildChecklistCommand(commandLine string, vars map[string]string) ([]string, error) {
+	var values []string
+	var missing []string
+	// Replace each placeholder with a sentinel that survives tokenizing, then swap the values in per argument.
+	masked := commandPlaceholderRegex.ReplaceAllStringFunc(commandLine, func(m string) string {
+		sub := commandPlaceholderRegex.FindStringSubmatch(m)
+		name := sub[1]
+		if name == "" {
+			name = sub[2]
+		}
+		value, ok := vars[name]
+		if !ok || value == "" {
+			missing = append(missing, name)
+			return m
+		}
+		values = append(values, value)
+		return fmt.Sprintf("\x00%d\x00", len(values)-1)
+	})
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("no value for placeholder(s): %s", strings.Join(missing, ", "))
+	}
+
+	args, err := splitCommandLine(masked)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("command is empty")
+	}
+	for i, arg := range args {
+		args[i] = commandSentinelRegex.ReplaceAllStringFunc(arg, func(m string) string {
+			idx, _ := strconv.Atoi(strings.Trim(m, "\x00"))
+			return values[idx]
+		})
+	}
+	return args, nil
+}
+
+// splitCommandLine splits a command line into arguments using POSIX shell quoting rules
+// (single quotes, double quotes, backslash escapes) and rejects unquoted shell operators.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				} else if s[i] == '$' || s[i] == '`' {
+					return nil, errors.New("command substitution and variables are not supported")
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				cur.WriteByte(s[i])
+			}
+			inArg = true
+		case strings.IndexByte("|&;<>`$()", c) >= 0:
+			return nil, fmt.Errorf("shell operator '%c' is not supported; commands run without a shell", c)
+		default:
+			cur.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// resolveAllowedCommand checks the executable against the command_runner allow list and resolves it in
+// tools.bin_dir, then PATH.
+func resolveAllowedCommand(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("executable '%s' must be a bare command name", name)
+	}
+	allowed := false
+	for _, a := range config.AppConfig.Commands.AllowedCommands {
+		if a == name {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("command '%s' is not in command_runner.allowed_commands", name)
+	}
+	path, _, err := lookupTool(name)
+	if err != nil {
+		return "", fmt.Errorf("command '%s' not found in tools.bin_dir or in PATH", name)
+	}
+	return path, nil
+}
+
+// commandEnv is the minimal environment given to checklist commands.
+func commandEnv(workDir string) []string {
+	env := []string{"PATH=" + toolSearchPath(), "HOME=" + workDir, "LANG=C.UTF-8"}
+	if config.AppConfig.Commands.UseProxy && config.AppConfig.Proxy.Port != "" {
+		proxyURL := "http://127.0.0.1:" + config.AppConfig.Proxy.Port
+		env = append(env, "HTTP_PROXY="+proxyURL, "HTTPS_PROXY="+proxyURL, "http_proxy="+proxyURL, "https_proxy="+proxyURL)
+	}
+	return env
+}
+
+// StartChecklistCommand starts a checklist item's command in the background and returns the new run.
+// The command comes from the request or the item's item_command_text.
+func StartChecklistCommand(itemID int64, req models.ChecklistCommandRunRequest) (models.ChecklistCommandRun, error) {
+	var run models.ChecklistCommandRun
+	rc := config.AppConfig.Commands
+	if !rc.Enabled {
+		return run, errors.New("the command runner is disabled (set command_runner.enabled in the config)")
+	}
+	item, err := database.GetChecklistItemByID(itemID)
+	if err != nil {
+		return run, err
+	}
+	commandLine := strings.TrimSpace(req.Command)
+	if commandLine == "" {
+		commandLine = strings.TrimSpace(item.ItemCommandText.String)
+	}
+	if commandLine == "" {
+		return run, fmt.Errorf("checklist item %d has no command", itemID)
+	}
+
+	args, err := BuildChecklistCommand(commandLine, commandVariables(item.TargetID, req.Variables))
+	if err != nil {
+		return run, err
+	}
+	path, err := resolveAllowedCommand(args[0])
+	if err != nil {
+		return run, err
+	}
+
+	runID, err := database.CreateChecklistCommandRun(itemID, strings.Join(args, " "))
+	if err != nil {
+		return run, err
+	}
+	workDir := filepath.Join(rc.WorkDir, fmt.Sprintf("run-%d", runID))
+	if err := os.MkdirAll(workDir, 0750); err != nil {
+		run = models.ChecklistCommandRun{ID: runID, Status: models.CommandRunStatusFailed, ErrorMessage: models.NullString(err.Error())}
+		database.FinishChecklistCommandRun(run)
+		return run, fmt.Errorf("creating working directory: %w", err)
+	}
+
+	timeout := time.Duration(rc.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	maxBytes := rc.MaxOutputBytes
+	if maxBytes <= 0 {
+		maxBytes = 1024 * 1024
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	state := &commandRunState{maxBytes: maxBytes, cancel: cancel}
+
+	cmd := exec.CommandContext(ctx, path, args[1:]...)
+	cmd.Dir = workDir
+	cmd.Env = commandEnv(workDir)
+	cmd.Stdout = state
+	cmd.Stderr = state
+	cmd.WaitDelay = 5 * time.Second
+
+	activeCommandRunsLock.Lock()
+	activeCommandRuns[runID] = state
+	activeCommandRunsLock.Unlock()
+	if err := cmd.Start(); err != nil {
+		cancel()
+		run = models.ChecklistCommandRun{ID: runID, Status: models.CommandRunStatusFailed, ErrorMessage: models.NullString(err.Error())}
+		database.FinishChecklistCommandRun(run)
+		activeCommandRunsLock.Lock()
+		delete(activeCommandRuns, runID)
+		activeCommandRunsLock.Unlock()
+		return run, fmt.Errorf("starting command: %w", err)
+	}
+	logger.Info("Checklist command run %d started for item %d: %s", runID, itemID, strings.Join(args, " "))
+
+	markComplete := req.MarkComplete == nil || *req.MarkComplete
+	go waitChecklistCommand(ctx, cancel, cmd, runID, itemID, state, markComplete)
+
+	return database.GetChecklistCommandRunByID(runID)
+}
+
+func waitChecklistCommand(ctx context.Context, cancel context.CancelFunc, cmd *exec.Cmd, runID, itemID int64, state *commandRunState, markComplete bool) {
+	defer cancel()
+	waitErr := cmd.Wait()
+
+	state.mu.Lock()
+	run := models.ChecklistCommandRun{
+		ID:              runID,
+		ChecklistItemID: itemID,
+		Output:          string(state.output),
+		OutputTruncated: state.truncated,
+	}
+	cancelled := state.cancelled
+	state.mu.Unlock()
+
+	if cmd.ProcessState != nil {
+		run.ExitCode.Int64, run.ExitCode.Valid = int64(cmd.ProcessState.ExitCode()), true
+	}
+	switch {
+	case cancelled:
+		run.Status = models.CommandRunStatusCancelled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Status = models.CommandRunStatusTimedOut
+		run.ErrorMessage = models.NullString("command timed out")
+	case waitErr != nil:
+		run.Status = models.CommandRunStatusFailed
+		run.ErrorMessage = models.NullString(waitErr.Error())
+	default:
+		run.Status = models.CommandRunStatusSucceeded
+	}
+
+	if err := database.FinishChecklistCommandRun(run); err != nil {
+		logger.Error("Checklist command run %d: %v", runID, err)
+	}
+	if run.Status == models.CommandRunStatusSucceeded && markComplete {
+		if err := database.SetChecklistItemCompleted(itemID, true); err != nil {
+			logger.Error("Checklist command run %d: %v", runID, err)
+		}
+	}
+
+	// Only stop tracking the run once the result is in the database so readers never miss the final output.
+	state.mu.Lock()
+	state.done = true
+	state.mu.Unlock()
+	activeCommandRunsLock.Lock()
+	delete(activeCommandRuns, runID)
+	activeCommandRunsLock.Unlock()
+	logger.Info("Checklist command run %d finished: %s", runID, run.Status)
+}
+
+// CancelChecklistCommand stops a running checklist command. It returns false if the run is not active.
+func CancelChecklistCommand(runID int64) bool {
+	activeCommandRunsLock.Lock()
+	state, ok := activeCommandRuns[runID]
+	activeCommandRunsLock.Unlock()
+	if !ok {
+		return false
+	}
+	state.mu.Lock()
+	state.cancelled = true
+	state.mu.Unlock()
+	state.cancel()
+	return true
+}
+
+// ReadChecklistCommandOutput returns the output of a run from offset onwards and whether the run has finished.
+// Active runs are read from memory; finished runs from the database.
+func ReadChecklistCommandOutput(runID int64, offset int) (string, bool, error) {
+	activeCommandRunsLock.Lock()
+	state, ok := activeCommandRuns[runID]
+	activeCommandRunsLock.Unlock()
+	if ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if offset > len(state.output) {
+			offset = len(state.output)
+		}
+		return string(state.output[offset:]), state.done, nil
+	}
+
+	run, err := GetChecklistCommandRun(runID)
+	if err != nil {
+		return "", true, err
+	}
+	if offset > len(run.Output) {
+		offset = len(run.Output)
+	}
+	return run.Output[offset:], run.Status != models.CommandRunStatusRunning, nil
+}
+
+// GetChecklistCommandRun fetches a run, with the output collected so far if it is still running.
+// A run left in the running state by a previous toolkit process is marked as failed.
+func GetChecklistCommandRun(runID int64) (models.ChecklistCommandRun, error) {
+	run, err := database.GetChecklistCommandRunByID(runID)
+	if err != nil || run.Status != models.CommandRunStatusRunning {
+		return run, err
+	}
+	activeCommandRunsLock.Lock()
+	state, ok := activeCommandRuns[runID]
+	activeCommandRunsLock.Unlock()
+	if !ok {
+		// Re-read in case the run finished between the two lookups.
+		if run, err = database.GetChecklistCommandRunByID(runID); err != nil || run.Status != models.CommandRunStatusRunning {
+			return run, err
+		}
+		run.Status = models.CommandRunStatusFailed
+		run.ErrorMessage = models.NullString("interrupted: the toolkit stopped while the command was running")
+		if err := database.FinishChecklistCommandRun(run); err != nil {
+			logger.Error("GetChecklistCommandRun: %v", err)
+		}
+		return run, nil
+	}
+	state.mu.Lock()
+	run.Output, run.OutputTruncated = string(state.output), state.truncated
+	state.mu.Unlock()
+	return run, nil
+}
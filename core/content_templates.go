@@ -0,0 +1,363 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+	"unicode/utf8"
+)
+
+// contentTemplateFields returns the fields of a content template that may hold placeholders.
+func contentTemplateFields(t *models.ContentTemplate) []*string {
+	return []*string{&t.Title, &t.Content, &t.Summary, &t.StepsToReproduce, &t.Impact, &t.Recommendations, &t.Payload}
+}
+
+// withContentTemplateVariables sets the placeholders a content template uses.
+func withContentTemplateVariables(t *models.ContentTemplate) {
+	var used []string
+	for _, field := range contentTemplateFields(t) {
+		_, missing := SubstituteVariables(*field, nil)
+		used = append(used, missing...)
+	}
+	t.Variables = processSlice(used)
+	if t.Variables == nil {
+		t.Variables = []string{}
+	}
+}
+
+// ListContentTemplates lists the finding and note templates, or those of one kind.
+func ListContentTemplates(kind string) ([]models.ContentTemplate, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind != "" && kind != models.ContentTemplateFinding && kind != models.ContentTemplateNote {
+		return nil, models.InvalidRequestError("invalid kind '%s', expected 'finding' or 'note'", kind)
+	}
+	templates, err := database.GetContentTemplates(kind)
+	if err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		withContentTemplateVariables(&templates[i])
+	}
+	return templates, nil
+}
+
+// GetContentTemplate returns a content template with the placeholders it uses.
+func GetContentTemplate(id int64) (models.ContentTemplate, error) {
+	t, err := database.GetContentTemplate(id)
+	if err != nil {
+		return t, err
+	}
+	withContentTemplateVariables(&t)
+	return t, nil
+}
+
+// CreateContentTemplate validates and stores a finding or note template.
+func CreateContentTemplate(t models.ContentTemplate) (models.ContentTemplate, error) {
+	if err := validateContentTemplate(&t); err != nil {
+		return t, err
+	}
+	id, err := database.CreateContentTemplate(t)
+	if err != nil {
+		return t, err
+	}
+	logger.Info("CreateContentTemplate: Created %s template %d '%s'", t.Kind, id, t.Name)
+	return GetContentTemplate(id)
+}
+
+// UpdateContentTemplate validates and replaces the fields of a content template.
+func UpdateContentTemplate(t models.ContentTemplate) (models.ContentTemplate, error) {
+	if _, err := database.GetContentTemplate(t.ID); err != nil {
+		return t, err
+	}
+	if err := validateContentTemplate(&t); err != nil {
+		return t, err
+	}
+	if err := database.UpdateContentTemplate(t); err != nil {
+		return t, err
+	}
+	return GetContentTemplate(t.ID)
+}
+
+// DeleteContentTemplate removes a content template.
+func DeleteContentTemplate(id int64) error {
+	return database.DeleteContentTemplate(id)
+}
+
+// validateContentTemplate checks a content template and normalizes its fields. The finding fields
+// of note templates are cleared.
+func validateContentTemplate(t *models.ContentTemplate) error {
+	t.Kind = strings.ToLower(strings.TrimSpace(t.Kind))
+	t.Name = strings.TrimSpace(t.Name)
+	t.Title = strings.TrimSpace(t.Title)
+	t.VulnerabilityType = strings.TrimSpace(t.VulnerabilityType)
+	if t.Name == "" {
+		return fmt.Errorf("invalid name: required")
+	}
+	switch t.Kind {
+	case models.ContentTemplateNote:
+		if strings.TrimSpace(t.Content) == "" {
+			return fmt.Errorf("invalid content: required for note templates")
+		}
+		t.Summary, t.StepsToReproduce, t.Impact, t.Recommendations, t.Payload = "", "", "", "", ""
+		t.Severity, t.CWEID, t.VulnerabilityType = "", nil, ""
+	case models.ContentTemplateFinding:
+		if t.Title == "" {
+			return fmt.Errorf("invalid title: required for finding templates")
+		}
+		severity, err := normalizeFindingSeverity(t.Severity)
+		if err != nil {
+			return err
+		}
+		t.Severity = severity
+		if t.CWEID != nil && *t.CWEID <= 0 {
+			return fmt.Errorf("invalid cwe_id %d", *t.CWEID)
+		}
+		if t.VulnerabilityType != "" {
+			vt, err := FindVulnerabilityType(0, t.VulnerabilityType)
+			if err != nil {
+				return models.InvalidRequestError("invalid vulnerability_type: %v", err)
+			}
+			t.VulnerabilityType = vt.Name
+		}
+	default:
+		return fmt.Errorf("invalid kind '%s', expected 'finding' or 'note'", t.Kind)
+	}
+	return nil
+}
+
+// InstantiateContentTemplate fills in the placeholders of a content template and creates the
+// finding (as a Draft, linked to the log entry) or note, unless req.Preview is set. Placeholders
+// without a value are left in place and listed as unresolved.
+func InstantiateContentTemplate(id int64, req models.ContentTemplateInstantiateRequest) (models.ContentTemplateInstance, error) {
+	instance := models.ContentTemplateInstance{TemplateID: id, Preview: req.Preview, Unresolved: []string{}}
+	t, err := database.GetContentTemplate(id)
+	if err != nil {
+		return instance, err
+	}
+	instance.Kind = t.Kind
+
+	var entry *models.HTTPTrafficLog
+	targetID := req.TargetID
+	if req.HTTPTrafficLogID != 0 {
+		e, err := database.GetHTTPTrafficLogEntryByID(req.HTTPTrafficLogID)
+		if err != nil {
+			return instance, err
+		}
+		if e.TargetID != nil {
+			if targetID != 0 && targetID != *e.TargetID {
+				return instance, models.InvalidRequestError("log entry %d belongs to target %d, not %d", e.ID, *e.TargetID, targetID)
+			}
+			targetID = *e.TargetID
+		}
+		entry = &e
+	}
+	if targetID != 0 {
+		if _, err := database.GetTargetByID(targetID); err != nil {
+			return instance, err
+		}
+	} else if t.Kind == models.ContentTemplateFinding {
+		return instance, models.InvalidRequestError("finding templates need a target_id or the http_traffic_log_id of an entry of a target")
+	}
+
+	values, err := contentTemplateValues(t, targetID, entry, req)
+	if err != nil {
+		return instance, err
+	}
+	if _, ok := values["payload"]; !ok && t.Payload != "" {
+		payload, _ := SubstituteVariables(t.Payload, values)
+		values["payload"] = payload
+	}
+	instance.Unresolved = SubstituteVariablesInPlace(values, contentTemplateFields(&t)...)
+	if v, ok := values["payload"]; ok {
+		t.Payload = v
+	}
+	if instance.Unresolved == nil {
+		instance.Unresolved = []string{}
+	}
+
+	if t.Kind == models.ContentTemplateNote {
+		note := models.Note{Title: models.NullString(t.Title), Content: t.Content}
+		if targetID != 0 {
+			note.TargetID = sql.NullInt64{Int64: targetID, Valid: true}
+		}
+		if !req.Preview {
+			if note, err = createTemplateNote(note); err != nil {
+				return instance, err
+			}
+			logger.Info("InstantiateContentTemplate: Note %d created from template %d '%s'", note.ID, t.ID, t.Name)
+		}
+		instance.Note = &note
+		return instance, nil
+	}
+
+	finding := models.TargetFinding{
+		TargetID:         targetID,
+		Title:            t.Title,
+		Summary:          models.NullString(t.Summary),
+		Description:      models.NullString(t.Content),
+		StepsToReproduce: models.NullString(t.StepsToReproduce),
+		Impact:           models.NullString(t.Impact),
+		Recommendations:  models.NullString(t.Recommendations),
+		Payload:          models.NullString(t.Payload),
+		Severity:         models.NullString(t.Severity),
+		Status:           models.FindingStatusDraft,
+	}
+	if t.CWEID != nil {
+		finding.CWEID = sql.NullInt64{Int64: *t.CWEID, Valid: true}
+	}
+	if entry != nil {
+		finding.HTTPTrafficLogID = sql.NullInt64{Int64: entry.ID, Valid: true}
+	}
+	if t.VulnerabilityType != "" {
+		if vt, err := FindVulnerabilityType(0, t.VulnerabilityType); err == nil {
+			finding.VulnerabilityTypeID = sql.NullInt64{Int64: vt.ID, Valid: true}
+		} else {
+			logger.Warn("InstantiateContentTemplate: Template %d: %v", t.ID, err)
+		}
+	}
+	if !req.Preview {
+		findingID, err := database.CreateTargetFinding(finding)
+		if err != nil {
+			return instance, err
+		}
+		if finding, err = database.GetTargetFindingByID(findingID); err != nil {
+			return instance, err
+		}
+		logger.Info("InstantiateContentTemplate: Draft finding %d of target %d created from template %d '%s'", findingID, targetID, t.ID, t.Name)
+	}
+	instance.Finding = &finding
+	return instance, nil
+}
+
+// createTemplateNote stores a note created from a template, with its wiki links.
+func createTemplateNote(note models.Note) (models.Note, error) {
+	id, err := database.CreateNote(note)
+	if err != nil {
+		return note, err
+	}
+	created, err := database.GetNoteByID(id)
+	if err != nil {
+		return note, err
+	}
+	if err := database.ReplaceNoteLinks(created, ParseWikiLinks(created.Content)); err != nil {
+		logger.Error("createTemplateNote: Error storing links of note %d: %v", id, err)
+	}
+	return created, nil
+}
+
+// contentTemplateValues collects the values of the placeholders of a content template, later
+// sources winning: the built-in target variables, the target's variables, the log entry, the
+// parameter, the vulnerability type, the date and the request's variables.
+func contentTemplateValues(t models.ContentTemplate, targetID int64, entry *models.HTTPTrafficLog, req models.ContentTemplateInstantiateRequest) (map[string]string, error) {
+	values := map[string]string{}
+	if targetID != 0 {
+		values = commandVariables(targetID, nil)
+		values["target_name"] = values["target_codename"]
+		variables, err := database.GetTargetVariableMap(targetID)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range variables {
+			values[k] = v
+		}
+	}
+	if entry != nil {
+		trafficLogTemplateValues(*entry, values)
+	}
+	if p := strings.TrimSpace(req.Parameter); p != "" {
+		values["parameter"] = p
+		if entry != nil {
+			if v, ok := requestParameterValue(*entry, p); ok {
+				values["parameter_value"] = v
+			}
+		}
+	}
+	if t.VulnerabilityType != "" {
+		values["vulnerability_type"] = t.VulnerabilityType
+	}
+	values["date"] = time.Now().Format(time.DateOnly)
+	for k, v := range req.Variables {
+		if !IsValidVariableName(k) {
+			return nil, models.InvalidRequestError("invalid variable name '%s'", k)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// trafficLogTemplateValues adds the placeholder values of a log entry, with export-time redaction
+// rules applied: its URL, method, host, path, endpoint ("GET /path"), status code, a curl command
+// and the raw request and response as markdown evidence.
+func trafficLogTemplateValues(entry models.HTTPTrafficLog, values map[string]string) {
+	if rules := EffectiveRedactionRules(entry.TargetID); rules.Enabled && rules.ApplyAt != models.RedactionApplyStorage {
+		RedactTrafficLog(&entry, rules)
+	}
+	method := entry.RequestMethod.String
+	if method == "" {
+		method = http.MethodGet
+	}
+	requestURL := entry.RequestURL.String
+	path := requestURL
+	if u, err := url.Parse(requestURL); err == nil {
+		values["host"] = u.Host
+		if u.Path != "" {
+			path = u.Path
+		}
+	}
+	reqHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+	values["log_id"] = strconv.FormatInt(entry.ID, 10)
+	values["url"] = requestURL
+	values["method"] = method
+	values["path"] = path
+	values["endpoint"] = method + " " + path
+	if entry.ResponseStatusCode != 0 {
+		values["status_code"] = strconv.Itoa(entry.ResponseStatusCode)
+	}
+	values["curl"] = BuildCurlCommand(method, requestURL, reqHeaders, entry.RequestBody)
+	// Without the "Promoted from ..." line of promoted findings.
+	evidence := findingEvidence(entry, method, reqHeaders)
+	if _, rest, found := strings.Cut(evidence, "\n\n"); found {
+		evidence = rest
+	}
+	values["evidence"] = evidence
+}
+
+// requestParameterValue returns the value of a parameter of a logged request: from its query, its
+// form body, or a top-level field of its JSON body.
+func requestParameterValue(entry models.HTTPTrafficLog, name string) (string, bool) {
+	if u, err := url.Parse(entry.RequestURL.String); err == nil {
+		if q := u.Query(); q.Has(name) {
+			return q.Get(name), true
+		}
+	}
+	if len(entry.RequestBody) == 0 || !utf8.Valid(entry.RequestBody) {
+		return "", false
+	}
+	contentType := strings.ToLower(HeadersFromJSON(entry.RequestHeaders.String).Get("Content-Type"))
+	switch {
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		if form, err := url.ParseQuery(string(entry.RequestBody)); err == nil && form.Has(name) {
+			return form.Get(name), true
+		}
+	case strings.Contains(contentType, "json"):
+		var fields map[string]interface{}
+		if json.Unmarshal(entry.RequestBody, &fields) != nil {
+			return "", false
+		}
+		switch v := fields[name].(type) {
+		case string:
+			return v, true
+		case float64, bool:
+			return fmt.Sprint(v), true
+		}
+	}
+	return "", false
+}
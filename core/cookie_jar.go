@@ -0,0 +1,380 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// sessionAuthFailureWindow suppresses repeated 401 and login redirect events: a dead session
+// typically fails every request the page makes.
+const sessionAuthFailureWindow = 5 * time.Minute
+
+// cookieJarMu serializes cookie jar updates, which read and then write a cookie.
+var cookieJarMu sync.Mutex
+
+// TrackCookiesFromLog updates the cookie jar of the log entry's target from its Set-Cookie
+// headers and records session events: session cookies rotated or cleared by the server, and
+// requests that carried a session cookie or Authorization header answered with 401 or a redirect
+// to a login page. With cookie_jar.auto_update_sessions, rotated session cookies are written to
+// the target's replay sessions. An ended session replays the target's login flow when it has
+// auto_refresh.
+func TrackCookiesFromLog(entry *models.HTTPTrafficLog) error {
+	if entry.TargetID == nil || *entry.TargetID == 0 {
+		return nil
+	}
+	conf := ConfigForTarget(entry.TargetID).CookieJar
+	if !conf.Enabled {
+		return nil
+	}
+	cookieJarMu.Lock()
+	defer cookieJarMu.Unlock()
+	_, ended, err := trackCookies(entry, conf, conf.AutoUpdateSessions)
+	if ended {
+		go refreshLoginOnSessionEnd(*entry.TargetID)
+	}
+	return err
+}
+
+// trackCookies applies one log entry to its target's cookie jar and returns the number of
+// session events recorded and whether one of them reports the session ended (401 or login
+// redirect).
+func trackCookies(entry *models.HTTPTrafficLog, conf config.CookieJarConfig, updateVault bool) (int, bool, error) {
+	targetID := *entry.TargetID
+	reqURL, err := url.Parse(entry.RequestURL.String)
+	if err != nil || reqURL.Host == "" {
+		return 0, false, nil
+	}
+	at := entry.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	logID := sql.NullInt64{Int64: entry.ID, Valid: entry.ID != 0}
+	events := 0
+	record := func(e models.SessionEvent) error {
+		e.TargetID, e.HTTPTrafficLogID, e.CreatedAt = targetID, logID, at
+		events++
+		return database.CreateSessionEvent(e)
+	}
+
+	respHeaders := HeadersFromJSON(entry.ResponseHeaders.String)
+	for _, c := range (&http.Response{Header: respHeaders}).Cookies() {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if domain == "" {
+			domain = strings.ToLower(reqURL.Hostname())
+		}
+		cookiePath := c.Path
+		if cookiePath == "" || !strings.HasPrefix(cookiePath, "/") {
+			cookiePath = defaultCookiePath(reqURL.Path)
+		}
+		cleared := c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(at))
+
+		existing, err := database.GetTargetCookie(targetID, domain, cookiePath, c.Name)
+		if err != nil {
+			return events, false, err
+		}
+		cookie := models.TargetCookie{TargetID: targetID, Domain: domain, Path: cookiePath, Name: c.Name, FirstSeenAt: at, LastSetAt: at}
+		if existing != nil {
+			cookie = *existing
+		}
+		cookie.IsSession = isSessionCookie(c.Name, conf.SessionCookies)
+		cookie.SetCount++
+		cookie.SourceLogID = logID
+
+		rotated := false
+		switch {
+		case cleared:
+			// Keep the value and attributes the server invalidated.
+			if existing != nil && !existing.Expired && cookie.IsSession {
+				err = record(models.SessionEvent{CookieName: c.Name, EventType: models.SessionEventExpired,
+					Details: fmt.Sprintf("cleared by %s %s (%d) after %s", entry.RequestMethod.String, reqURL.Path, entry.ResponseStatusCode, roundAge(at.Sub(existing.LastSetAt)))})
+			}
+			cookie.Expired = true
+		default:
+			rotated = existing != nil && existing.Value != c.Value
+			if rotated {
+				cookie.ChangeCount++
+			}
+			cookie.Value, cookie.Expired, cookie.LastSetAt = c.Value, false, at
+			cookie.Secure, cookie.HTTPOnly, cookie.SameSite = c.Secure, c.HttpOnly, cookieSameSite(c.SameSite)
+			cookie.ExpiresAt = nil
+			if c.MaxAge > 0 {
+				expires := at.Add(time.Duration(c.MaxAge) * time.Second)
+				cookie.ExpiresAt = &expires
+			} else if !c.Expires.IsZero() {
+				expires := c.Expires
+				cookie.ExpiresAt = &expires
+			}
+			if rotated && cookie.IsSession {
+				err = record(models.SessionEvent{CookieName: c.Name, EventType: models.SessionEventRotated,
+					Details: fmt.Sprintf("new value set by %s %s after %s", entry.RequestMethod.String, reqURL.Path, roundAge(at.Sub(existing.LastSetAt)))})
+			}
+		}
+		if err != nil {
+			return events, false, err
+		}
+		if err := database.SaveTargetCookie(cookie); err != nil {
+			return events, false, err
+		}
+		if rotated && cookie.IsSession && updateVault {
+			if err := updateSessionVault(targetID, c.Name, c.Value, record); err != nil {
+				logger.Error("Cookie jar: Updating replay sessions of target %d: %v", targetID, err)
+			}
+		}
+	}
+
+	failure := sessionAuthFailure(entry, reqURL, respHeaders, conf)
+	if failure == nil {
+		return events, false, nil
+	}
+	last, err := database.LastSessionEventAt(targetID, models.SessionEventUnauthorized, models.SessionEventLoginRedirect)
+	if err != nil {
+		return events, false, err
+	}
+	if last != nil && at.Sub(*last) < sessionAuthFailureWindow {
+		return events, false, nil
+	}
+	if err := record(*failure); err != nil {
+		return events, false, err
+	}
+	return events, true, nil
+}
+
+// sessionAuthFailure returns the event for a request that carried credentials (a session cookie
+// or an Authorization header) and was answered with 401 or a redirect to a login page, or nil.
+func sessionAuthFailure(entry *models.HTTPTrafficLog, reqURL *url.URL, respHeaders http.Header, conf config.CookieJarConfig) *models.SessionEvent {
+	status := entry.ResponseStatusCode
+	if status != http.StatusUnauthorized && (status < 300 || status > 399) {
+		return nil
+	}
+	reqHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+	var sent []string
+	for _, c := range (&http.Request{Header: reqHeaders}).Cookies() {
+		if isSessionCookie(c.Name, conf.SessionCookies) {
+			sent = append(sent, c.Name)
+		}
+	}
+	credentials := strings.Join(sent, ", ")
+	if reqHeaders.Get("Authorization") != "" {
+		if credentials != "" {
+			credentials += " and "
+		}
+		credentials += "Authorization header"
+	}
+	if credentials == "" {
+		return nil
+	}
+	cookieName := ""
+	if len(sent) > 0 {
+		cookieName = sent[0]
+	}
+	requestLine := fmt.Sprintf("%s %s", entry.RequestMethod.String, reqURL.Path)
+	if status == http.StatusUnauthorized {
+		return &models.SessionEvent{CookieName: cookieName, EventType: models.SessionEventUnauthorized,
+			Details: fmt.Sprintf("%s returned 401 with %s", requestLine, credentials)}
+	}
+	location := respHeaders.Get("Location")
+	if location == "" || isLoginPath(reqURL.Path, conf.LoginPaths) {
+		return nil
+	}
+	target, err := reqURL.Parse(location)
+	if err != nil || !isLoginPath(target.Path, conf.LoginPaths) {
+		return nil
+	}
+	return &models.SessionEvent{CookieName: cookieName, EventType: models.SessionEventLoginRedirect,
+		Details: fmt.Sprintf("%s redirected to %s with %s", requestLine, target.Path, credentials)}
+}
+
+// updateSessionVault replaces the value of a rotated session cookie in the target's replay
+// sessions that send it.
+func updateSessionVault(targetID int64, name, value string, record func(models.SessionEvent) error) error {
+	sessions, err := database.GetReplaySessions(targetID)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		cookies, changed := replaceCookieValue(s.Cookies, name, value)
+		if !changed {
+			continue
+		}
+		if err := database.UpdateReplaySessionCookies(s.ID, cookies); err != nil {
+			return err
+		}
+		logger.Info("Cookie jar: Updated cookie %s of replay session %d ('%s')", name, s.ID, s.Name)
+		if err := record(models.SessionEvent{CookieName: name, EventType: models.SessionEventVaultUpdated,
+			Details: fmt.Sprintf("replay session %d ('%s') updated", s.ID, s.Name)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceCookieValue sets the value of an existing cookie in a Cookie header value and reports
+// whether it changed.
+func replaceCookieValue(header, name, value string) (string, bool) {
+	pairs := strings.Split(header, ";")
+	changed := false
+	for i, pair := range pairs {
+		n, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if strings.TrimSpace(n) == name && v != value {
+			pairs[i] = name + "=" + value
+			changed = true
+		}
+	}
+	if !changed {
+		return header, false
+	}
+	for i := range pairs {
+		pairs[i] = strings.TrimSpace(pairs[i])
+	}
+	return strings.Join(pairs, "; "), true
+}
+
+// GetTargetSessions returns the cookie jar of a target with each cookie's status and age, and its
+// most recent session events. Only session cookies are listed unless all is set.
+func GetTargetSessions(targetID int64, all bool, eventLimit int) (models.TargetSessions, error) {
+	result := models.TargetSessions{TargetID: targetID}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	if eventLimit <= 0 {
+		eventLimit = 50
+	}
+	cookies, err := database.GetTargetCookies(targetID, !all)
+	if err != nil {
+		return result, err
+	}
+	events, err := database.GetSessionEvents(targetID, eventLimit)
+	if err != nil {
+		return result, err
+	}
+	lastFailure, err := database.LastSessionEventAt(targetID, models.SessionEventUnauthorized, models.SessionEventLoginRedirect)
+	if err != nil {
+		return result, err
+	}
+	now := time.Now()
+	for i := range cookies {
+		c := &cookies[i]
+		c.AgeSeconds = int64(now.Sub(c.LastSetAt).Seconds())
+		switch {
+		case c.Expired || (c.ExpiresAt != nil && c.ExpiresAt.Before(now)):
+			c.Status = models.CookieStatusExpired
+		case c.IsSession && lastFailure != nil && lastFailure.After(c.LastSetAt):
+			c.Status = models.CookieStatusSuspect
+		default:
+			c.Status = models.CookieStatusActive
+		}
+	}
+	result.Cookies, result.Events, result.LastAuthFailureAt = cookies, events, lastFailure
+	return result, nil
+}
+
+// RebuildTargetCookieJar clears the cookie jar and session events of a target and replays its
+// captured traffic into them, for traffic logged before tracking was enabled or after
+// cookie_jar.session_cookies changed. Replay sessions are not updated.
+func RebuildTargetCookieJar(targetID int64) (models.CookieJarRebuildResult, error) {
+	result := models.CookieJarRebuildResult{TargetID: targetID}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	conf := ConfigForTarget(&targetID).CookieJar
+
+	cookieJarMu.Lock()
+	defer cookieJarMu.Unlock()
+	if err := database.ClearTargetCookieJar(targetID); err != nil {
+		return result, err
+	}
+	var afterID int64
+	for {
+		entries, err := database.GetCookieJarTraffic(targetID, afterID, 500)
+		if err != nil {
+			return result, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for i := range entries {
+			events, _, err := trackCookies(&entries[i], conf, false)
+			if err != nil {
+				return result, fmt.Errorf("log %d: %w", entries[i].ID, err)
+			}
+			result.Events += events
+			afterID = entries[i].ID
+		}
+		result.LogsScanned += len(entries)
+	}
+	cookies, err := database.GetTargetCookies(targetID, false)
+	if err != nil {
+		return result, err
+	}
+	result.Cookies = len(cookies)
+	for _, c := range cookies {
+		if c.IsSession {
+			result.SessionCookies++
+		}
+	}
+	logger.Info("RebuildTargetCookieJar: Target %d: %d logs scanned, %d cookies (%d session), %d session events",
+		targetID, result.LogsScanned, result.Cookies, result.SessionCookies, result.Events)
+	return result, nil
+}
+
+// isSessionCookie reports whether a cookie name matches one of the cookie_jar.session_cookies
+// patterns, case-insensitively.
+func isSessionCookie(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoginPath reports whether a URL path contains one of the cookie_jar.login_paths fragments.
+func isLoginPath(p string, fragments []string) bool {
+	p = strings.ToLower(p)
+	for _, f := range fragments {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" && strings.Contains(p, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultCookiePath is the path of a cookie set without a Path attribute (RFC 6265 5.1.4).
+func defaultCookiePath(requestPath string) string {
+	i := strings.LastIndex(requestPath, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+func cookieSameSite(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+// roundAge formats how long a cookie value lived.
+func roundAge(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Minute).String()
+}
@@ -0,0 +1,378 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// corsSkippedHeaders are not copied from the captured request into the probes, on top of
+// replaySkippedHeaders: the Origin is crafted, and conditional requests would get a 304
+// without CORS headers.
+var corsSkippedHeaders = map[string]bool{
+	"Origin":            true,
+	"If-None-Match":     true,
+	"If-Modified-Since": true,
+}
+
+// corsOrigin is a crafted Origin header and what it means when the server trusts it.
+type corsOrigin struct {
+	kind        string
+	origin      string
+	title       string
+	description string
+	severity    string // With Access-Control-Allow-Credentials: true
+	anonymous   string // Without credentials
+}
+
+// corsResult is a probe whose crafted origin the server trusted.
+type corsResult struct {
+	origin      corsOrigin
+	credentials bool
+	entry       models.HTTPTrafficLog
+}
+
+func (r corsResult) severity() string {
+	if r.credentials {
+		return r.origin.severity
+	}
+	return r.origin.anonymous
+}
+
+// corsOrigins returns the crafted origins tried against an endpoint on host: an arbitrary
+// origin, null, the host as a prefix and the base domain as an unanchored suffix of attacker
+// domains, the host with an unescaped regex dot, an arbitrary subdomain and, for HTTPS
+// endpoints, the host (with its port, hostPort) over plain HTTP.
+func corsOrigins(scheme, host, hostPort, attacker string) []corsOrigin {
+	origins := []corsOrigin{
+		{"arbitrary", "https://" + attacker, "arbitrary origin reflected", "any origin is reflected", "High", "Low"},
+		{"null", "null", "null origin trusted", "the null origin (sandboxed iframes, data: URLs) is trusted", "High", "Low"},
+		{"prefix", fmt.Sprintf("https://%s.%s", host, attacker), "origin prefix match", "origins starting with the host are trusted", "High", "Low"},
+	}
+	// The remaining variants rewrite domain labels, which means nothing for an IP address.
+	if net.ParseIP(host) == nil {
+		base := corsBaseDomain(host)
+		origins = append(origins, corsOrigin{"suffix", fmt.Sprintf("https://%s%s", strings.SplitN(attacker, ".", 2)[0], base),
+			"unanchored origin suffix match", "origins ending with the domain name are trusted", "High", "Low"})
+		if labels := strings.Split(host, "."); len(labels) >= 3 {
+			origins = append(origins, corsOrigin{"unescaped_dot", fmt.Sprintf("https://%sx%s", labels[0], strings.Join(labels[1:], ".")),
+				"unescaped dot in origin pattern", "the origin is matched by a pattern with an unescaped dot", "High", "Low"})
+		}
+		origins = append(origins, corsOrigin{"subdomain", "https://cors-check." + base,
+			"any subdomain trusted", "any subdomain is trusted, so XSS or a takeover on one exposes this endpoint", "Low", "Informational"})
+	}
+	if scheme == "https" {
+		origins = append(origins, corsOrigin{"http", "http://" + hostPort, "plain-HTTP origin trusted", "the plain-HTTP origin is trusted, so a network attacker can read responses", "Medium", "Low"})
+	}
+	return origins
+}
+
+// corsBaseDomain approximates the registrable domain of host: its last two labels, or three
+// under a two-letter country code with a short second level (example.co.uk).
+func corsBaseDomain(host string) string {
+	labels := strings.Split(host, ".")
+	n := 2
+	if len(labels) >= 3 && len(labels[len(labels)-1]) == 2 && len(labels[len(labels)-2]) <= 3 {
+		n = 3
+	}
+	if len(labels) <= n {
+		return host
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+// StartCORSCheck starts a job replaying captured GET endpoints of a target with crafted Origin
+// headers. An endpoint is misconfigured when Access-Control-Allow-Origin echoes a crafted origin;
+// each one becomes a Draft finding with the probes' requests and responses, which are also
+// logged to the traffic log.
+func StartCORSCheck(targetID int64, req models.CORSCheckRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	conf := config.AppConfig.CORSCheck
+	endpoints, err := corsCheckEndpoints(targetID, req, conf)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(endpoints) == 0 {
+		return models.Job{}, fmt.Errorf("no captured GET endpoints with a 2xx response to check for target %d", targetID)
+	}
+	latest, err := LatestJob(models.JobTypeCORSCheck, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a CORS check is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}, targetID)),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	attacker := strings.TrimSpace(conf.AttackerDomain)
+	if attacker == "" {
+		attacker = "attacker.com"
+	}
+	c := &corsChecker{targetID: targetID, client: client, attacker: attacker, workers: conf.Workers}
+	return StartJob(models.JobTypeCORSCheck, &targetID, fmt.Sprintf("Checking CORS of %d endpoints...", len(endpoints)), func(ctx context.Context, job *JobHandle) error {
+		return c.run(ctx, job, endpoints)
+	})
+}
+
+// corsCheckEndpoints resolves the captured requests to replay.
+func corsCheckEndpoints(targetID int64, req models.CORSCheckRequest, conf config.CORSCheckConfig) ([]models.HTTPTrafficLog, error) {
+	if len(req.LogIDs) > 0 {
+		var endpoints []models.HTTPTrafficLog
+		for _, id := range req.LogIDs {
+			entry, err := database.GetHTTPTrafficLogEntryByID(id)
+			if err != nil {
+				return nil, err
+			}
+			if entry.TargetID == nil || *entry.TargetID != targetID {
+				return nil, fmt.Errorf("invalid log entry %d: it does not belong to target %d", id, targetID)
+			}
+			endpoints = append(endpoints, entry)
+		}
+		return endpoints, nil
+	}
+
+	max := req.MaxEndpoints
+	if max <= 0 {
+		max = conf.MaxEndpoints
+	}
+	if max <= 0 {
+		max = 200
+	}
+	candidates, err := database.GetCORSCheckCandidates(targetID)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.ToLower(strings.TrimSpace(req.Host))
+	seen := map[string]bool{}
+	var endpoints []models.HTTPTrafficLog
+	for _, e := range candidates {
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" || (host != "" && strings.ToLower(u.Hostname()) != host) {
+			continue
+		}
+		canonical := CanonicalizeURL(e.RequestURL.String, "")
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		endpoints = append(endpoints, e)
+		if len(endpoints) >= max {
+			break
+		}
+	}
+	return endpoints, nil
+}
+
+type corsChecker struct {
+	targetID int64
+	client   *http.Client
+	attacker string
+	workers  int
+
+	mu       sync.Mutex
+	titles   map[string]bool // Titles of the target's findings, to not file one twice
+	findings int
+	flagged  []string
+}
+
+func (c *corsChecker) run(ctx context.Context, job *JobHandle, endpoints []models.HTTPTrafficLog) error {
+	job.SetTotal(int64(len(endpoints)))
+	existing, err := database.GetTargetFindingsByTargetID(c.targetID)
+	if err != nil {
+		return err
+	}
+	c.titles = make(map[string]bool, len(existing))
+	for _, f := range existing {
+		c.titles[f.Title] = true
+	}
+	workers := c.workers
+	if workers <= 0 {
+		workers = 5
+	}
+
+	ch := make(chan models.HTTPTrafficLog)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for endpoint := range ch {
+				misconfigured, err := c.checkEndpoint(ctx, endpoint)
+				if err != nil {
+					job.RecordError(fmt.Errorf("%s: %w", endpoint.RequestURL.String, err))
+				}
+				if misconfigured {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, endpoint := range endpoints {
+		select {
+		case <-ctx.Done():
+			break feed
+		case ch <- endpoint:
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	job.SetMessage("Checked %d endpoints: %d misconfigured, %d new findings.", len(endpoints), len(c.flagged), c.findings)
+	if c.findings > 0 {
+		NotifyTargetEvent(models.NotificationScanFinished, c.targetID, fmt.Sprintf("CORS check found %d misconfigured endpoints", len(c.flagged)),
+			SubdomainNotificationList(c.flagged, 10), c.flagged)
+	}
+	return ctx.Err()
+}
+
+// checkEndpoint sends the crafted origins to one endpoint and files a finding when the server
+// trusts any of them.
+func (c *corsChecker) checkEndpoint(ctx context.Context, endpoint models.HTTPTrafficLog) (bool, error) {
+	u, err := url.Parse(endpoint.RequestURL.String)
+	if err != nil {
+		return false, err
+	}
+	headers := http.Header{}
+	for name, values := range HeadersFromJSON(endpoint.RequestHeaders.String) {
+		canonical := http.CanonicalHeaderKey(name)
+		if replaySkippedHeaders[canonical] || corsSkippedHeaders[canonical] || isToolkitHeader(canonical) || canonical == "Host" {
+			continue
+		}
+		headers[canonical] = values
+	}
+
+	var trusted []corsResult
+	for _, origin := range corsOrigins(u.Scheme, strings.ToLower(u.Hostname()), strings.ToLower(u.Host), c.attacker) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		entry, resp, err := c.probe(ctx, endpoint.RequestURL.String, headers, origin.origin)
+		if err != nil {
+			return false, err
+		}
+		if resp.Get("Access-Control-Allow-Origin") == origin.origin {
+			trusted = append(trusted, corsResult{origin: origin, credentials: strings.EqualFold(strings.TrimSpace(resp.Get("Access-Control-Allow-Credentials")), "true"), entry: entry})
+		}
+	}
+	if len(trusted) == 0 {
+		return false, nil
+	}
+	return true, c.fileFinding(endpoint, u, trusted)
+}
+
+// probe sends one request with a crafted Origin, logs it and returns the log entry and the
+// response headers.
+func (c *corsChecker) probe(ctx context.Context, rawURL string, headers http.Header, origin string) (models.HTTPTrafficLog, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return models.HTTPTrafficLog{}, nil, err
+	}
+	req.Header = headers.Clone()
+	req.Header.Set("Origin", origin)
+	return sendLoggedProbe(c.client, c.targetID, req, models.CORSCheckLogSource)
+}
+
+// fileFinding creates a Draft finding for an endpoint that trusted crafted origins, with the
+// most severe probe as evidence. Endpoints already filed for the same issue are skipped.
+func (c *corsChecker) fileFinding(endpoint models.HTTPTrafficLog, u *url.URL, trusted []corsResult) error {
+	worst := trusted[0]
+	for _, r := range trusted[1:] {
+		if severityRank(r.severity()) > severityRank(worst.severity()) {
+			worst = r
+		}
+	}
+	title := fmt.Sprintf("CORS misconfiguration: %s on GET %s%s", worst.origin.title, u.Host, u.Path)
+
+	c.mu.Lock()
+	c.flagged = append(c.flagged, u.Host+u.Path)
+	if c.titles[title] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.titles[title] = true
+	c.mu.Unlock()
+
+	evidence := worst.entry
+	if rules := EffectiveRedactionRules(&c.targetID); rules.Enabled && rules.ApplyAt != models.RedactionApplyStorage {
+		RedactTrafficLog(&evidence, rules)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "`%s` trusts crafted origins (captured request: log entry %d):\n\n", endpoint.RequestURL.String, endpoint.ID)
+	for _, r := range trusted {
+		credentials := "without credentials"
+		if r.credentials {
+			credentials = "with `Access-Control-Allow-Credentials: true`"
+		}
+		fmt.Fprintf(&b, "- `Origin: %s` is allowed %s: %s (log entry %d)\n", r.origin.origin, credentials, r.origin.description, r.entry.ID)
+	}
+	b.WriteString("\n")
+	reqHeaders := HeadersFromJSON(evidence.RequestHeaders.String)
+	b.WriteString(findingEvidence(evidence, http.MethodGet, reqHeaders))
+
+	impact := "The response can be read cross-origin, but without credentials only data available to anyone who can reach the endpoint is exposed."
+	if worst.credentials {
+		impact = fmt.Sprintf("A page on %s can make the victim's browser send this request with their cookies and read the response.", worst.origin.origin)
+	}
+	finding := models.TargetFinding{
+		TargetID:         c.targetID,
+		HTTPTrafficLogID: sql.NullInt64{Int64: evidence.ID, Valid: evidence.ID != 0},
+		Title:            title,
+		Description:      models.NullString(b.String()),
+		StepsToReproduce: models.NullString(fmt.Sprintf("1. Send the request:\n\n```sh\n%s\n```\n\n2. Observe `Access-Control-Allow-Origin: %s` in the response.",
+			BuildCurlCommand(http.MethodGet, evidence.RequestURL.String, reqHeaders, nil), worst.origin.origin)),
+		Impact:   models.NullString(impact),
+		Payload:  models.NullString("Origin: " + worst.origin.origin),
+		Severity: models.NullString(worst.severity()),
+		Status:   models.FindingStatusDraft,
+	}
+	if vt, err := FindVulnerabilityType(0, "CORS Misconfiguration"); err == nil {
+		finding.VulnerabilityTypeID = sql.NullInt64{Int64: vt.ID, Valid: true}
+	}
+	id, err := database.CreateTargetFinding(finding)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.findings++
+	c.mu.Unlock()
+	logger.Info("CORS check: Draft finding %d for %s (%s)", id, endpoint.RequestURL.String, worst.origin.kind)
+	return nil
+}
+
+// severityRank orders finding severities, unknown ones lowest.
+func severityRank(severity string) int {
+	for i, s := range models.FindingSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,356 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// ctWatcherMinInterval keeps the watcher from hammering crt.sh.
+const ctWatcherMinInterval = 15 * time.Minute
+
+// ctDomainSource is the source recorded for domains found in certificate transparency logs.
+const ctDomainSource = "ct-log"
+
+var (
+	ctWatcherMu     sync.Mutex
+	ctWatcherStatus models.CTWatcherStatus
+	ctWatcherPollMu sync.Mutex // Serializes polls (ticker and manual)
+)
+
+// crtshEntry is one certificate in a crt.sh JSON answer.
+type crtshEntry struct {
+	ID        int64  `json:"id"`
+	NameValue string `json:"name_value"` // SAN names, newline-separated
+}
+
+// RunCTWatcher looks up newly issued certificates for the wildcard scope rules of every target
+// until ctx is cancelled.
+func RunCTWatcher(ctx context.Context) {
+	interval := time.Duration(config.AppConfig.CTWatch.PollIntervalMinutes) * time.Minute
+	if interval < ctWatcherMinInterval {
+		interval = ctWatcherMinInterval
+	}
+	setCTWatcherRunning(true)
+	defer setCTWatcherRunning(false)
+	logger.Info("CT watcher: Polling %s every %s", config.AppConfig.CTWatch.CrtshURL, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := PollCTLogsNow(ctx, 0); err != nil {
+			logger.Warn("CT watcher: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("CT watcher: Stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollCTLogsNow checks the wildcard scope rules of one target (every target when targetID is 0)
+// for new certificates. Names of new certificates that are in scope become domains with source
+// ct-log. The first check of a pattern only records the current position and adds the names it
+// finds without notifying.
+func PollCTLogsNow(ctx context.Context, targetID int64) (models.CTPollResult, error) {
+	ctWatcherPollMu.Lock()
+	defer ctWatcherPollMu.Unlock()
+
+	result := models.CTPollResult{DomainsAdded: []string{}, InterestingNames: []string{}}
+	var targets []models.Target
+	if targetID != 0 {
+		target, err := database.GetTargetByID(targetID)
+		if err != nil {
+			return result, err
+		}
+		targets = []models.Target{target}
+	} else {
+		all, err := database.GetTargets(nil)
+		if err != nil {
+			return result, err
+		}
+		targets = all
+	}
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := pollCTLogsForTarget(ctx, target, &result); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+
+	ctWatcherMu.Lock()
+	defer ctWatcherMu.Unlock()
+	now := time.Now()
+	ctWatcherStatus.LastPollAt = &now
+	ctWatcherStatus.Polls++
+	ctWatcherStatus.CertificatesNew += int64(result.CertificatesNew)
+	ctWatcherStatus.DomainsAdded += int64(len(result.DomainsAdded))
+	ctWatcherStatus.InterestingNames += int64(len(result.InterestingNames))
+	if len(result.Errors) > 0 {
+		ctWatcherStatus.LastError = strings.Join(result.Errors, "; ")
+		ctWatcherStatus.LastErrorAt = &now
+		if result.Patterns == len(result.Errors) {
+			return result, fmt.Errorf("CT watcher poll failed: %s", ctWatcherStatus.LastError)
+		}
+	}
+	return result, nil
+}
+
+// GetCTWatcherStatus returns the watcher counters and the position of every watched pattern.
+func GetCTWatcherStatus() (models.CTWatcherStatus, error) {
+	states, err := database.ListCTWatchStates()
+	if err != nil {
+		return models.CTWatcherStatus{}, err
+	}
+	ctWatcherMu.Lock()
+	defer ctWatcherMu.Unlock()
+	status := ctWatcherStatus
+	status.Enabled = config.AppConfig.CTWatch.Enabled
+	status.Patterns = states
+	return status, nil
+}
+
+func setCTWatcherRunning(running bool) {
+	ctWatcherMu.Lock()
+	ctWatcherStatus.Running = running
+	ctWatcherMu.Unlock()
+}
+
+// ctWildcardBases returns the base domains of a target's in-scope wildcard rules
+// ('*.example.com' -> 'example.com').
+func ctWildcardBases(rules []models.ScopeRule) []string {
+	seen := make(map[string]bool)
+	var bases []string
+	for _, rule := range rules {
+		if !rule.IsInScope || (rule.ItemType != "domain" && rule.ItemType != "subdomain") {
+			continue
+		}
+		pattern := strings.ToLower(strings.TrimSpace(rule.Pattern))
+		if !strings.HasPrefix(pattern, "*.") {
+			continue
+		}
+		base := strings.TrimPrefix(pattern, "*.")
+		if base == "" || strings.ContainsAny(base, "*^$()[]+?\\") || seen[base] {
+			continue
+		}
+		seen[base] = true
+		bases = append(bases, base)
+	}
+	return bases
+}
+
+func pollCTLogsForTarget(ctx context.Context, target models.Target, result *models.CTPollResult) error {
+	rules, err := database.GetScopeRulesByTargetID(target.ID)
+	if err != nil {
+		return err
+	}
+	added := 0
+	var errs []string
+	for _, base := range ctWildcardBases(rules) {
+		result.Patterns++
+		names, interesting, err := pollCTLogsForBase(ctx, target, base, rules, result)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		added += names
+		if len(interesting) > 0 {
+			NotifyTargetEvent(models.NotificationCTInteresting, target.ID, fmt.Sprintf("Interesting names in new certificates for %s", base),
+				SubdomainNotificationList(interesting, 10), interesting)
+		}
+	}
+	if added > 0 {
+		ResolveIPAssetsAfterDiscovery(target.ID)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// pollCTLogsForBase checks one wildcard pattern and returns the number of domains added and the
+// added names worth a notification.
+func pollCTLogsForBase(ctx context.Context, target models.Target, base string, rules []models.ScopeRule, result *models.CTPollResult) (int, []string, error) {
+	state, found, err := database.GetCTWatchState(target.ID, base)
+	if err != nil {
+		return 0, nil, err
+	}
+	entries, fetchErr := fetchCrtshEntries(ctx, base)
+	now := time.Now()
+	state.LastCheckedAt = &now
+	if fetchErr != nil {
+		state.LastError = fetchErr.Error()
+		if err := database.SaveCTWatchState(state); err != nil {
+			logger.Error("CT watcher: %v", err)
+		}
+		return 0, nil, fetchErr
+	}
+	state.LastError = ""
+
+	newNames := make(map[string]bool)
+	maxID := state.LastCertID
+	newCerts := 0
+	for _, entry := range entries {
+		if entry.ID <= state.LastCertID {
+			continue
+		}
+		newCerts++
+		if entry.ID > maxID {
+			maxID = entry.ID
+		}
+		for _, name := range strings.Split(entry.NameValue, "\n") {
+			if name = ctNormalizeName(name, base); name != "" {
+				newNames[name] = true
+			}
+		}
+	}
+	state.LastCertID = maxID
+	state.CertificatesSeen += int64(newCerts)
+	result.CertificatesNew += newCerts
+
+	var added []string
+	for name := range newNames {
+		if !ctNameInScope(name, rules) {
+			continue
+		}
+		_, err := database.CreateDomain(models.Domain{TargetID: target.ID, DomainName: name, Source: models.NullString(ctDomainSource), IsInScope: true})
+		if err != nil {
+			if !strings.Contains(err.Error(), "already exists") {
+				logger.Error("CT watcher: Failed to store '%s' for target %d: %v", name, target.ID, err)
+			}
+			continue
+		}
+		added = append(added, name)
+	}
+	sort.Strings(added)
+	state.DomainsAdded += int64(len(added))
+	if err := database.SaveCTWatchState(state); err != nil {
+		return len(added), nil, err
+	}
+	result.DomainsAdded = append(result.DomainsAdded, added...)
+	logger.Info("CT watcher: %s (target %d): %d new certificates, %d domains added", base, target.ID, newCerts, len(added))
+
+	if !found {
+		// First check of the pattern: everything is new, so nothing is announced.
+		return len(added), nil, nil
+	}
+	var interesting []string
+	for _, name := range added {
+		if ctNameInteresting(name, base) {
+			interesting = append(interesting, name)
+		}
+	}
+	result.InterestingNames = append(result.InterestingNames, interesting...)
+	if len(added) > 0 {
+		NotifyTargetEvent(models.NotificationNewSubdomain, target.ID, fmt.Sprintf("%d new subdomains of %s in CT logs", len(added), base),
+			SubdomainNotificationList(added, 10), added)
+	}
+	return len(added), interesting, nil
+}
+
+// ctNormalizeName lowercases a certificate name and returns it when it is base or one of its
+// subdomains; wildcard names are reduced to the part after '*.'.
+func ctNormalizeName(name, base string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	name = strings.TrimPrefix(name, "*.")
+	if name == "" || strings.ContainsAny(name, "@* ") {
+		return ""
+	}
+	if name != base && !strings.HasSuffix(name, "."+base) {
+		return ""
+	}
+	return name
+}
+
+// ctNameInScope reports whether no out-of-scope rule of the target excludes the name.
+func ctNameInScope(name string, rules []models.ScopeRule) bool {
+	u := &url.URL{Scheme: "https", Host: name, Path: "/"}
+	for _, rule := range rules {
+		if rule.IsInScope || (rule.ItemType != "domain" && rule.ItemType != "subdomain") {
+			continue
+		}
+		if matchesRule(u, name, u.Path, rule) {
+			return false
+		}
+	}
+	return true
+}
+
+// ctNameInteresting reports whether the part of name below base contains one of the configured
+// keywords.
+func ctNameInteresting(name, base string) bool {
+	sub := strings.TrimSuffix(strings.TrimSuffix(name, base), ".")
+	if sub == "" {
+		return false
+	}
+	for _, keyword := range config.AppConfig.CTWatch.InterestingKeywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(sub, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchCrtshEntries returns the unexpired certificates crt.sh knows for the subdomains of base.
+func fetchCrtshEntries(ctx context.Context, base string) ([]crtshEntry, error) {
+	conf := config.AppConfig.CTWatch
+	baseURL := strings.TrimRight(conf.CrtshURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("ct_watch.crtsh_url is not configured")
+	}
+	rawURL := fmt.Sprintf("%s/?q=%s&output=json&exclude=expired", baseURL, url.QueryEscape("%."+base))
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %w", rawURL, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	policy := providerRetryPolicy{MaxRetries: conf.MaxRetries, InitialBackoff: 5 * time.Second, MaxBackoff: time.Minute}
+	resp, err := doWithRetry(ctx, &http.Client{Timeout: timeout}, req, policy, nil)
+	if err != nil {
+		return nil, fmt.Errorf("requesting crt.sh for %s: %w", base, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading crt.sh answer for %s: %w", base, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crt.sh returned status %d for %s", resp.StatusCode, base)
+	}
+	var entries []crtshEntry
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("decoding crt.sh answer for %s: %w", base, err)
+	}
+	return entries, nil
+}
+
+// SubdomainNotificationList lists up to max names, one per line, noting how many were left out.
+func SubdomainNotificationList(names []string, max int) string {
+	if len(names) <= max {
+		return strings.Join(names, "\n")
+	}
+	return strings.Join(names[:max], "\n") + fmt.Sprintf("\n... and %d more", len(names)-max)
+}
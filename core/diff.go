@@ -0,0 +1,332 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"toolkit/models"
+	"unicode/utf8"
+)
+
+// maxLineDiffCells bounds the LCS table used for line diffs (lines(from) * lines(to)).
+// Larger inputs fall back to a single replace hunk after trimming the common prefix/suffix.
+const maxLineDiffCells = 4_000_000
+
+// TrafficDiff describes what changed between two traffic log entries.
+type TrafficDiff struct {
+	FromID          int64          `json:"from_id"`
+	ToID            int64          `json:"to_id"`
+	RequestLine     *ValueChange   `json:"request_line,omitempty"`
+	StatusCode      *ValueChange   `json:"status_code,omitempty"`
+	RequestHeaders  []HeaderChange `json:"request_headers"`
+	ResponseHeaders []HeaderChange `json:"response_headers"`
+	RequestBody     BodyDiff       `json:"request_body"`
+	ResponseBody    BodyDiff       `json:"response_body"`
+	FromCachedLogID int64          `json:"from_cached_log_id,omitempty"` // The from response was reused from this entry by the response cache
+	ToCachedLogID   int64          `json:"to_cached_log_id,omitempty"`   // The to response was reused from this entry by the response cache
+	Beautified      bool           `json:"beautified,omitempty"`         // Response bodies were compared as their beautified copies
+}
+
+// ValueChange is a scalar value that differs between the two entries.
+type ValueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// HeaderChange is one header that was added, removed or changed.
+type HeaderChange struct {
+	Name   string   `json:"name"`
+	Change string   `json:"change"` // added, removed, changed
+	From   []string `json:"from,omitempty"`
+	To     []string `json:"to,omitempty"`
+}
+
+// BodyDiff is the diff of a request or response body.
+// Mode is "identical", "json", "text" or "binary".
+type BodyDiff struct {
+	Mode        string       `json:"mode"`
+	FromSize    int          `json:"from_size"`
+	ToSize      int          `json:"to_size"`
+	JSONChanges []JSONChange `json:"json_changes,omitempty"`
+	Lines       []DiffLine   `json:"lines,omitempty"`
+	Truncated   bool         `json:"truncated,omitempty"` // Line diff was too large for an exact LCS
+}
+
+// JSONChange is a value that differs at a JSON path (e.g. $.data.items[2].id).
+type JSONChange struct {
+	Path   string      `json:"path"`
+	Change string      `json:"change"` // added, removed, changed
+	From   interface{} `json:"from,omitempty"`
+	To     interface{} `json:"to,omitempty"`
+}
+
+// DiffLine is one line of a line diff. Op is " " (unchanged), "-" (removed) or "+" (added).
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// HasChanges reports whether anything differs between the two entries.
+func (d *TrafficDiff) HasChanges() bool {
+	return d.RequestLine != nil || d.StatusCode != nil || len(d.RequestHeaders) > 0 || len(d.ResponseHeaders) > 0 ||
+		d.RequestBody.Mode != "identical" || d.ResponseBody.Mode != "identical"
+}
+
+// DiffTrafficLogs compares two traffic log entries: request line, status, headers and bodies.
+// Bodies are diffed structurally when both sides are JSON, line by line otherwise. A side answered
+// by the response cache names the entry its response was reused from.
+func DiffTrafficLogs(from, to models.HTTPTrafficLog) TrafficDiff {
+	diff := TrafficDiff{FromID: from.ID, ToID: to.ID, FromCachedLogID: from.CachedFromLogID.Int64, ToCachedLogID: to.CachedFromLogID.Int64}
+
+	fromLine := strings.TrimSpace(from.RequestMethod.String + " " + from.RequestURL.String)
+	toLine := strings.TrimSpace(to.RequestMethod.String + " " + to.RequestURL.String)
+	if fromLine != toLine {
+		diff.RequestLine = &ValueChange{From: fromLine, To: toLine}
+	}
+	if from.ResponseStatusCode != to.ResponseStatusCode {
+		diff.StatusCode = &ValueChange{From: fmt.Sprint(from.ResponseStatusCode), To: fmt.Sprint(to.ResponseStatusCode)}
+	}
+
+	diff.RequestHeaders = DiffHeaders(from.RequestHeaders.String, to.RequestHeaders.String)
+	diff.ResponseHeaders = DiffHeaders(from.ResponseHeaders.String, to.ResponseHeaders.String)
+
+	reqContentType := headerValue(from.RequestHeaders.String, "Content-Type")
+	diff.RequestBody = DiffBodies(from.RequestBody, to.RequestBody, reqContentType)
+	diff.ResponseBody = DiffBodies(from.ResponseBody, to.ResponseBody, from.ResponseContentType.String)
+	return diff
+}
+
+// DiffHeaders compares two header sets stored as JSON (map[string][]string).
+func DiffHeaders(fromJSON, toJSON string) []HeaderChange {
+	fromHeaders := parseHeaderJSON(fromJSON)
+	toHeaders := parseHeaderJSON(toJSON)
+
+	names := make(map[string]bool)
+	for k := range fromHeaders {
+		names[k] = true
+	}
+	for k := range toHeaders {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	changes := []HeaderChange{}
+	for _, name := range sorted {
+		f, inFrom := fromHeaders[name]
+		t, inTo := toHeaders[name]
+		switch {
+		case inFrom && !inTo:
+			changes = append(changes, HeaderChange{Name: name, Change: "removed", From: f})
+		case !inFrom && inTo:
+			changes = append(changes, HeaderChange{Name: name, Change: "added", To: t})
+		case !reflect.DeepEqual(f, t):
+			changes = append(changes, HeaderChange{Name: name, Change: "changed", From: f, To: t})
+		}
+	}
+	return changes
+}
+
+func parseHeaderJSON(headersJSON string) map[string][]string {
+	result := make(map[string][]string)
+	if headersJSON == "" {
+		return result
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal([]byte(headersJSON), &raw); err != nil {
+		return result
+	}
+	for k, v := range raw {
+		canonical := strings.ToLower(k)
+		result[canonical] = append(result[canonical], v...)
+	}
+	return result
+}
+
+func headerValue(headersJSON, name string) string {
+	if values := parseHeaderJSON(headersJSON)[strings.ToLower(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// DiffBodies diffs two bodies. contentType (of the "from" side) selects JSON-aware diffing;
+// bodies that both parse as JSON are diffed structurally even without a JSON content type.
+func DiffBodies(from, to []byte, contentType string) BodyDiff {
+	diff := BodyDiff{FromSize: len(from), ToSize: len(to)}
+	if bytes.Equal(from, to) {
+		diff.Mode = "identical"
+		return diff
+	}
+
+	looksJSON := strings.Contains(strings.ToLower(contentType), "json") || (json.Valid(bytes.TrimSpace(from)) && json.Valid(bytes.TrimSpace(to)))
+	if looksJSON {
+		var fromVal, toVal interface{}
+		if json.Unmarshal(from, &fromVal) == nil && json.Unmarshal(to, &toVal) == nil {
+			diff.Mode = "json"
+			diff.JSONChanges = []JSONChange{}
+			diffJSONValues("$", fromVal, toVal, &diff.JSONChanges)
+			if len(diff.JSONChanges) == 0 {
+				diff.Mode = "identical" // Only whitespace/key order differed
+			}
+			return diff
+		}
+	}
+
+	if !utf8.Valid(from) || !utf8.Valid(to) {
+		diff.Mode = "binary"
+		return diff
+	}
+
+	diff.Mode = "text"
+	diff.Lines, diff.Truncated = DiffLines(string(from), string(to))
+	return diff
+}
+
+func diffJSONValues(path string, from, to interface{}, changes *[]JSONChange) {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		t, ok := to.(map[string]interface{})
+		if !ok {
+			*changes = append(*changes, JSONChange{Path: path, Change: "changed", From: from, To: to})
+			return
+		}
+		keys := make([]string, 0, len(f)+len(t))
+		for k := range f {
+			keys = append(keys, k)
+		}
+		for k := range t {
+			if _, seen := f[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := path + "." + k
+			fv, inFrom := f[k]
+			tv, inTo := t[k]
+			switch {
+			case inFrom && !inTo:
+				*changes = append(*changes, JSONChange{Path: childPath, Change: "removed", From: fv})
+			case !inFrom && inTo:
+				*changes = append(*changes, JSONChange{Path: childPath, Change: "added", To: tv})
+			default:
+				diffJSONValues(childPath, fv, tv, changes)
+			}
+		}
+	case []interface{}:
+		t, ok := to.([]interface{})
+		if !ok {
+			*changes = append(*changes, JSONChange{Path: path, Change: "changed", From: from, To: to})
+			return
+		}
+		for i := 0; i < len(f) || i < len(t); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(t):
+				*changes = append(*changes, JSONChange{Path: childPath, Change: "removed", From: f[i]})
+			case i >= len(f):
+				*changes = append(*changes, JSONChange{Path: childPath, Change: "added", To: t[i]})
+			default:
+				diffJSONValues(childPath, f[i], t[i], changes)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(from, to) {
+			*changes = append(*changes, JSONChange{Path: path, Change: "changed", From: from, To: to})
+		}
+	}
+}
+
+// DiffLines returns a line diff of two texts based on their longest common subsequence.
+// The second return value is true when the inputs were too large for an exact diff and the
+// differing middle section is reported as a single removed/added block.
+func DiffLines(from, to string) ([]DiffLine, bool) {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// Trim the common prefix and suffix; this keeps the LCS table small for typical edits.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]DiffLine, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		lines = append(lines, DiffLine{Op: " ", Text: l})
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	truncated := false
+	if len(midA)*len(midB) > maxLineDiffCells {
+		truncated = true
+		for _, l := range midA {
+			lines = append(lines, DiffLine{Op: "-", Text: l})
+		}
+		for _, l := range midB {
+			lines = append(lines, DiffLine{Op: "+", Text: l})
+		}
+	} else {
+		lines = append(lines, lcsDiff(midA, midB)...)
+	}
+
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, DiffLine{Op: " ", Text: l})
+	}
+	return lines, truncated
+}
+
+func lcsDiff(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	// table[i][j] = LCS length of a[i:] and b[j:]
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] >= table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			lines = append(lines, DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		lines = append(lines, DiffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < m; j++ {
+		lines = append(lines, DiffLine{Op: "+", Text: b[j]})
+	}
+	return lines
+}
@@ -0,0 +1,561 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"toolkit/config"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// dnsHealthCheckMinInterval keeps periodic health checks from adding noticeable load.
+const dnsHealthCheckMinInterval = 30 * time.Second
+
+// dnsMaxMessageSize is the largest DNS message a DoH server may answer with.
+const dnsMaxMessageSize = 65535
+
+var (
+	dnsPoolMu sync.Mutex
+	dnsPool   *dnsResolverPool
+)
+
+// dnsUpstream is a configured resolver with its rate limit and health.
+type dnsUpstream struct {
+	spec       string
+	protocol   string
+	address    string // host:port, except for DoH
+	url        string // DoH endpoint
+	serverName string // TLS server name of DoT resolvers
+
+	mu        sync.Mutex
+	next      time.Time // Earliest time of the next query under queries_per_second
+	status    models.DNSResolverStatus
+	downUntil time.Time
+}
+
+// dnsResolverPool rotates the recon DNS lookups over the configured resolvers.
+type dnsResolverPool struct {
+	source    string // Settings the pool was built from
+	upstreams []*dnsUpstream
+	errors    []string
+	counter   atomic.Uint32
+	resolver  *net.Resolver
+	client    *http.Client // DoH requests
+	timeout   time.Duration
+}
+
+// reconResolver returns the resolver of the recon DNS lookups: the configured resolvers, or the
+// system resolver when there are none.
+func reconResolver() *net.Resolver {
+	if pool := currentDNSPool(); len(pool.upstreams) > 0 {
+		return pool.resolver
+	}
+	return net.DefaultResolver
+}
+
+// currentDNSPool returns the resolver pool of the dns settings, building it when they changed.
+// A resolvers_file is read when the pool is built.
+func currentDNSPool() *dnsResolverPool {
+	cfg := config.AppConfig.DNS
+	source := fmt.Sprintf("%s|%s|%d", strings.Join(cfg.Resolvers, ","), cfg.ResolversFile, cfg.TimeoutSeconds)
+	dnsPoolMu.Lock()
+	defer dnsPoolMu.Unlock()
+	if dnsPool != nil && dnsPool.source == source {
+		return dnsPool
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	pool := &dnsResolverPool{source: source, timeout: timeout, client: &http.Client{Timeout: timeout, Transport: transport}}
+	specs := append([]string{}, cfg.Resolvers...)
+	if cfg.ResolversFile != "" {
+		fileSpecs, err := readDNSResolversFile(cfg.ResolversFile)
+		if err != nil {
+			pool.errors = append(pool.errors, err.Error())
+		}
+		specs = append(specs, fileSpecs...)
+	}
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		u, err := parseDNSResolver(spec)
+		if err != nil {
+			pool.errors = append(pool.errors, err.Error())
+			continue
+		}
+		if seen[u.spec] {
+			continue
+		}
+		seen[u.spec] = true
+		u.status = models.DNSResolverStatus{Resolver: u.spec, Protocol: u.protocol}
+		pool.upstreams = append(pool.upstreams, u)
+	}
+	for _, e := range pool.errors {
+		logger.Warn("DNS resolvers: %s", e)
+	}
+	pool.resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		u, err := pool.pick()
+		if err != nil {
+			return nil, err
+		}
+		return pool.dial(ctx, u, network)
+	}}
+	if len(pool.upstreams) > 0 {
+		logger.Info("DNS resolvers: Recon lookups use %d configured resolvers", len(pool.upstreams))
+	}
+	dnsPool = pool
+	return pool
+}
+
+// readDNSResolversFile reads a resolver list, one per line. Empty lines and # comments are skipped.
+func readDNSResolversFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading resolvers file: %w", err)
+	}
+	defer f.Close()
+	var specs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			specs = append(specs, line)
+		}
+	}
+	return specs, scanner.Err()
+}
+
+// parseDNSResolver parses a resolver entry: 1.1.1.1, 8.8.8.8:53, udp://, tcp://, tls://host[:853]
+// or an https:// DoH endpoint.
+func parseDNSResolver(spec string) (*dnsUpstream, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("invalid resolver: empty")
+	}
+	if strings.HasPrefix(strings.ToLower(spec), "https://") {
+		parsed, err := url.Parse(spec)
+		if err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid DoH resolver '%s'", spec)
+		}
+		return &dnsUpstream{spec: spec, protocol: models.DNSProtocolHTTPS, url: spec}, nil
+	}
+	protocol, hostport := models.DNSProtocolUDP, spec
+	if scheme, rest, ok := strings.Cut(spec, "://"); ok {
+		protocol, hostport = strings.ToLower(scheme), rest
+	}
+	port := "53"
+	switch protocol {
+	case models.DNSProtocolUDP, models.DNSProtocolTCP:
+	case models.DNSProtocolTLS:
+		port = "853"
+	default:
+		return nil, fmt.Errorf("invalid resolver '%s': expected udp://, tcp://, tls:// or https://", spec)
+	}
+	hostport = strings.TrimSuffix(hostport, "/")
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, p = strings.Trim(hostport, "[]"), port
+	}
+	if host == "" || strings.ContainsAny(host, "/ ") {
+		return nil, fmt.Errorf("invalid resolver '%s'", spec)
+	}
+	u := &dnsUpstream{protocol: protocol, address: net.JoinHostPort(host, p), serverName: host}
+	u.spec = u.address
+	if protocol != models.DNSProtocolUDP {
+		u.spec = protocol + "://" + u.address
+	}
+	return u, nil
+}
+
+// pick returns the next healthy resolver, in turn. When every resolver is down, the one coming
+// back first is used rather than failing the lookup.
+func (p *dnsResolverPool) pick() (*dnsUpstream, error) {
+	n := uint32(len(p.upstreams))
+	if n == 0 {
+		return nil, fmt.Errorf("no DNS resolvers configured")
+	}
+	start := p.counter.Add(1)
+	now := time.Now()
+	var fallback *dnsUpstream
+	var fallbackUntil time.Time
+	for i := uint32(0); i < n; i++ {
+		u := p.upstreams[(start+i)%n]
+		u.mu.Lock()
+		down := u.downUntil
+		u.mu.Unlock()
+		if !now.Before(down) {
+			return u, nil
+		}
+		if fallback == nil || down.Before(fallbackUntil) {
+			fallback, fallbackUntil = u, down
+		}
+	}
+	return fallback, nil
+}
+
+// dial connects to a resolver once its rate limit allows a query. Plain resolvers are asked over
+// the network the Go resolver requests (UDP, or TCP for truncated answers); DoT and DoH always
+// carry the query as a TCP-style stream.
+func (p *dnsResolverPool) dial(ctx context.Context, u *dnsUpstream, network string) (net.Conn, error) {
+	if err := u.wait(ctx, config.AppConfig.DNS.QueriesPerSecond); err != nil {
+		return nil, err
+	}
+	exchange := &dnsExchange{upstream: u}
+	dialer := &net.Dialer{Timeout: p.timeout} // The system resolver looks up DoT and DoH host names
+	var conn net.Conn
+	var err error
+	switch u.protocol {
+	case models.DNSProtocolHTTPS:
+		return &dnsStreamConn{Conn: &dohConn{ctx: ctx, client: p.client, url: u.url}, exchange: exchange}, nil
+	case models.DNSProtocolTLS:
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.serverName}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", u.address)
+	case models.DNSProtocolTCP:
+		conn, err = dialer.DialContext(ctx, "tcp", u.address)
+	default:
+		if strings.HasPrefix(network, "udp") {
+			conn, err = dialer.DialContext(ctx, "udp", u.address)
+			if udp, ok := conn.(*net.UDPConn); ok && err == nil {
+				return &dnsPacketConn{UDPConn: udp, exchange: exchange}, nil
+			}
+		} else {
+			conn, err = dialer.DialContext(ctx, "tcp", u.address)
+		}
+	}
+	if err != nil {
+		u.record(err)
+		return nil, err
+	}
+	return &dnsStreamConn{Conn: conn, exchange: exchange}, nil
+}
+
+// wait blocks until the resolver may be sent another query under qps.
+func (u *dnsUpstream) wait(ctx context.Context, qps float64) error {
+	if qps <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / qps)
+	u.mu.Lock()
+	now := time.Now()
+	slot := u.next
+	if slot.Before(now) {
+		slot = now
+	}
+	u.next = slot.Add(interval)
+	u.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// record counts a query to the resolver. After max_failures consecutive failures the resolver is
+// skipped for cooldown_seconds.
+func (u *dnsUpstream) record(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status.Queries++
+	if err == nil {
+		u.status.ConsecutiveFailures = 0
+		u.downUntil = time.Time{}
+		return
+	}
+	now := time.Now()
+	u.status.Failures++
+	u.status.ConsecutiveFailures++
+	u.status.LastError = err.Error()
+	u.status.LastErrorAt = &now
+	maxFailures := config.AppConfig.DNS.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	if u.status.ConsecutiveFailures >= maxFailures && !now.Before(u.downUntil) {
+		u.markDown(now)
+		logger.Warn("DNS resolvers: Skipping %s for %s after %d consecutive failures (%v)", u.spec,
+			u.downUntil.Sub(now), u.status.ConsecutiveFailures, err)
+	}
+}
+
+// markDown skips the resolver for cooldown_seconds. u.mu must be held.
+func (u *dnsUpstream) markDown(now time.Time) {
+	cooldown := time.Duration(config.AppConfig.DNS.CooldownSeconds) * time.Second
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	u.downUntil = now.Add(cooldown)
+}
+
+func (u *dnsUpstream) snapshot() models.DNSResolverStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	status := u.status
+	status.Healthy = !time.Now().Before(u.downUntil)
+	if !status.Healthy {
+		down := u.downUntil
+		status.DownUntil = &down
+	}
+	return status
+}
+
+// dnsExchange records the outcome of one query, once its connection is closed.
+type dnsExchange struct {
+	upstream *dnsUpstream
+	mu       sync.Mutex
+	answered bool
+	err      error
+	done     bool
+}
+
+func (e *dnsExchange) read(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return
+	}
+	e.answered = true
+}
+
+func (e *dnsExchange) close() {
+	e.mu.Lock()
+	if e.done || (!e.answered && e.err == nil) { // Closed before reading, e.g. the lookup was cancelled
+		e.done = true
+		e.mu.Unlock()
+		return
+	}
+	e.done = true
+	err := e.err
+	e.mu.Unlock()
+	e.upstream.record(err)
+}
+
+// dnsPacketConn is a UDP connection to a resolver. It stays a net.PacketConn, which the Go
+// resolver needs to exchange datagrams rather than length-prefixed messages.
+type dnsPacketConn struct {
+	*net.UDPConn
+	exchange *dnsExchange
+}
+
+func (c *dnsPacketConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	c.exchange.read(err)
+	return n, err
+}
+
+func (c *dnsPacketConn) Close() error {
+	c.exchange.close()
+	return c.UDPConn.Close()
+}
+
+// dnsStreamConn is a TCP, TLS or DoH connection to a resolver.
+type dnsStreamConn struct {
+	net.Conn
+	exchange *dnsExchange
+}
+
+func (c *dnsStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.exchange.read(err)
+	return n, err
+}
+
+func (c *dnsStreamConn) Close() error {
+	c.exchange.close()
+	return c.Conn.Close()
+}
+
+// dohConn carries length-prefixed DNS messages, as the Go resolver writes them over TCP, to a DoH
+// endpoint: each message written is POSTed as application/dns-message and the answer is read
+// back with its length prefix.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	deadline time.Time
+	request  bytes.Buffer
+	response bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	return c.request.Write(b)
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.response.Len() == 0 {
+		if err := c.exchange(); err != nil {
+			return 0, err
+		}
+	}
+	return c.response.Read(b)
+}
+
+func (c *dohConn) exchange() error {
+	raw := c.request.Bytes()
+	if len(raw) < 2 {
+		return io.EOF
+	}
+	size := int(binary.BigEndian.Uint16(raw))
+	if len(raw) < 2+size {
+		return io.ErrUnexpectedEOF
+	}
+	msg := append([]byte(nil), raw[2:2+size]...)
+	c.request.Next(2 + size)
+
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DoH server %s answered %s", c.url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dnsMaxMessageSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > dnsMaxMessageSize || len(body) < 12 {
+		return fmt.Errorf("DoH server %s answered a %d byte message", c.url, len(body))
+	}
+	var prefix [2]byte
+	binary.BigEndian.PutUint16(prefix[:], uint16(len(body)))
+	c.response.Write(prefix[:])
+	c.response.Write(body)
+	return nil
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { c.deadline = t; return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// dohAddr is the address of a DoH endpoint.
+type dohAddr string
+
+func (a dohAddr) Network() string { return models.DNSProtocolHTTPS }
+func (a dohAddr) String() string  { return string(a) }
+
+// GetDNSResolversStatus reports the health and usage of the configured resolvers.
+func GetDNSResolversStatus() models.DNSResolversStatus {
+	pool := currentDNSPool()
+	status := models.DNSResolversStatus{
+		SystemResolver:   len(pool.upstreams) == 0,
+		QueriesPerSecond: config.AppConfig.DNS.QueriesPerSecond,
+		Resolvers:        []models.DNSResolverStatus{},
+		Errors:           pool.errors,
+	}
+	for _, u := range pool.upstreams {
+		status.Resolvers = append(status.Resolvers, u.snapshot())
+	}
+	return status
+}
+
+// CheckDNSResolvers looks up dns.health_check_domain through every configured resolver. Failing
+// resolvers are skipped for cooldown_seconds; answering ones are put back in rotation.
+func CheckDNSResolvers(ctx context.Context) models.DNSResolversStatus {
+	pool := currentDNSPool()
+	domain := config.AppConfig.DNS.HealthCheckDomain
+	if domain == "" {
+		domain = "example.com"
+	}
+	var wg sync.WaitGroup
+	for _, u := range pool.upstreams {
+		wg.Add(1)
+		go func(u *dnsUpstream) {
+			defer wg.Done()
+			pinned := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return pool.dial(ctx, u, network)
+			}}
+			lookupCtx, cancel := context.WithTimeout(ctx, 2*pool.timeout)
+			defer cancel()
+			start := time.Now()
+			_, err := pinned.LookupHost(lookupCtx, domain)
+			elapsed := time.Since(start)
+
+			u.mu.Lock()
+			defer u.mu.Unlock()
+			now := time.Now()
+			u.status.LastCheckAt = &now
+			u.status.LastCheckMs = elapsed.Milliseconds()
+			if err != nil {
+				u.status.LastError = fmt.Sprintf("health check: %v", err)
+				u.status.LastErrorAt = &now
+				u.markDown(now)
+				return
+			}
+			u.status.ConsecutiveFailures = 0
+			u.downUntil = time.Time{}
+		}(u)
+	}
+	wg.Wait()
+	return GetDNSResolversStatus()
+}
+
+// RunDNSHealthChecks checks the configured resolvers every health_check_interval_seconds until
+// ctx is cancelled.
+func RunDNSHealthChecks(ctx context.Context) {
+	interval := time.Duration(config.AppConfig.DNS.HealthCheckIntervalSeconds) * time.Second
+	if interval < dnsHealthCheckMinInterval {
+		interval = dnsHealthCheckMinInterval
+	}
+	logger.Info("DNS resolvers: Checking resolver health every %s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status := CheckDNSResolvers(ctx)
+		healthy := 0
+		for _, r := range status.Resolvers {
+			if r.Healthy {
+				healthy++
+			}
+		}
+		if healthy < len(status.Resolvers) {
+			logger.Warn("DNS resolvers: %d of %d resolvers healthy", healthy, len(status.Resolvers))
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("DNS resolvers: Health checks stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxEnrichmentIPs bounds the host lookups (and API credits) spent on one domain.
+const maxEnrichmentIPs = 4
+
+func shodanConfigured() bool {
+	return config.AppConfig.Enrichment.Shodan.APIKey != ""
+}
+
+func censysConfigured() bool {
+	conf := config.AppConfig.Enrichment.Censys
+	return conf.APIID != "" && conf.APISecret != ""
+}
+
+// domainEnricher runs the lookups of one enrichment job.
+type domainEnricher struct {
+	opts   models.DomainEnrichmentRequest
+	web    *http.Client // Favicon fetches (certificates are not verified, as in httpx)
+	api    *http.Client // Shodan/Censys
+	conf   config.EnrichmentConfig
+	mu     sync.Mutex
+	byHash map[int32][]models.RelatedHost // Favicon searches already made in this job
+}
+
+// StartDomainEnrichment starts a job hashing the favicons of a target's domains and looking their
+// hosts up on Shodan and Censys. Results are stored on the domains next to the httpx results.
+func StartDomainEnrichment(targetID int64, req models.DomainEnrichmentRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	if !req.Favicon && !req.Shodan && !req.Censys {
+		req.Favicon, req.Shodan, req.Censys = true, shodanConfigured(), censysConfigured()
+	}
+	if req.Shodan && !shodanConfigured() {
+		return models.Job{}, fmt.Errorf("shodan is not configured (enrichment.shodan.api_key)")
+	}
+	if req.Censys && !censysConfigured() {
+		return models.Job{}, fmt.Errorf("censys is not configured (enrichment.censys.api_id, api_secret)")
+	}
+
+	domains, err := domainsToEnrich(targetID, req.DomainIDs)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(domains) == 0 {
+		return models.Job{}, fmt.Errorf("no domains to enrich (run httpx first or pass domain_ids)")
+	}
+	latest, err := LatestJob(models.JobTypeDomainEnrich, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a domain enrichment job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	conf := config.AppConfig.Enrichment
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	e := &domainEnricher{
+		opts: req,
+		web: &http.Client{
+			Timeout:   timeout,
+			Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, targetID)),
+		},
+		api:    &http.Client{Timeout: timeout},
+		conf:   conf,
+		byHash: make(map[int32][]models.RelatedHost),
+	}
+	lookups := joinNonEmpty(", ", boolLabel(req.Favicon, "favicon"), boolLabel(req.Shodan, "shodan"), boolLabel(req.Censys, "censys"))
+	return StartJob(models.JobTypeDomainEnrich, &targetID, fmt.Sprintf("Enriching %d domains (%s)...", len(domains), lookups), func(ctx context.Context, job *JobHandle) error {
+		return e.run(ctx, job, domains)
+	})
+}
+
+func boolLabel(on bool, label string) string {
+	if on {
+		return label
+	}
+	return ""
+}
+
+// domainsToEnrich returns the requested domains of the target, or every domain with an httpx
+// result when none are requested. Domains never probed fall back to https://<domain>/ for the
+// favicon.
+func domainsToEnrich(targetID int64, ids []int64) ([]models.Domain, error) {
+	if len(ids) == 0 {
+		scanned, err := database.GetDomainIDsByFilters(models.DomainFilters{TargetID: targetID, HttpxScanStatus: "scanned"})
+		if err != nil {
+			return nil, err
+		}
+		ids = scanned
+	}
+	domains, err := database.GetDomainsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	var selected []models.Domain
+	for _, d := range domains {
+		if d.TargetID != targetID {
+			continue
+		}
+		selected = append(selected, d)
+	}
+	return selected, nil
+}
+
+func (e *domainEnricher) run(ctx context.Context, job *JobHandle, domains []models.Domain) error {
+	job.SetTotal(int64(len(domains)))
+	workers := e.conf.Workers
+	if workers <= 0 {
+		workers = 5
+	}
+	domainCh := make(chan models.Domain)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range domainCh {
+				ok, err := e.enrichDomain(ctx, d)
+				if err != nil {
+					job.RecordError(err)
+				}
+				if ok {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, d := range domains {
+		select {
+		case <-ctx.Done():
+			break feed
+		case domainCh <- d:
+		}
+	}
+	close(domainCh)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	snap := job.Snapshot()
+	job.SetMessage("Enriched %d of %d domains (%d errors).", snap.ItemsSucceeded, len(domains), snap.ErrorCount)
+	return nil
+}
+
+// enrichDomain runs the selected lookups for one domain and stores what they found. ok is true
+// when something was stored.
+func (e *domainEnricher) enrichDomain(ctx context.Context, d models.Domain) (bool, error) {
+	update := models.Domain{ID: d.ID}
+	var errs []string
+
+	if e.opts.Favicon {
+		pageURL := gjson.Get(d.HttpxFullJson.String, "url").String()
+		if pageURL == "" {
+			pageURL = "https://" + d.DomainName + "/"
+		}
+		hash, iconURL, err := fetchFavicon(ctx, e.web, pageURL)
+		if err != nil {
+			logger.Debug("Domain enrichment: No favicon for %s: %v", d.DomainName, err)
+		} else {
+			update.FaviconHash = sql.NullInt64{Int64: int64(hash), Valid: true}
+			update.FaviconURL = models.NullString(iconURL)
+		}
+	}
+
+	if e.opts.Shodan || e.opts.Censys {
+		enrichment := models.DomainEnrichment{IPs: []string{}, Ports: []int{}, Hostnames: []string{}, Certificates: []models.CertificateInfo{}, RelatedHosts: []models.RelatedHost{}, Sources: []string{}}
+		ips, err := e.domainIPs(ctx, d.DomainName)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		enrichment.IPs = ips
+		if e.opts.Shodan {
+			enrichment.Sources = append(enrichment.Sources, "shodan")
+			for _, ip := range ips {
+				if err := e.shodanHost(ctx, ip, &enrichment); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+			if update.FaviconHash.Valid && e.conf.Shodan.SearchFavicon {
+				related, err := e.shodanFaviconHosts(ctx, int32(update.FaviconHash.Int64))
+				if err != nil {
+					errs = append(errs, err.Error())
+				}
+				for _, host := range related {
+					if !containsString(ips, host.IP) {
+						enrichment.RelatedHosts = append(enrichment.RelatedHosts, host)
+					}
+				}
+			}
+		}
+		if e.opts.Censys {
+			enrichment.Sources = append(enrichment.Sources, "censys")
+			for _, ip := range ips {
+				if err := e.censysHost(ctx, ip, &enrichment); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+		}
+		enrichment.Ports = uniqueSortedInts(enrichment.Ports)
+		enrichment.Hostnames = uniqueSortedStrings(enrichment.Hostnames, d.DomainName)
+		enrichment.Errors = errs
+		if raw, err := json.Marshal(enrichment); err == nil {
+			update.EnrichmentJson = models.NullString(string(raw))
+		}
+		ports := make([]string, len(enrichment.Ports))
+		for i, p := range enrichment.Ports {
+			ports[i] = strconv.Itoa(p)
+		}
+		update.OpenPorts = sql.NullString{String: strings.Join(ports, ","), Valid: true}
+	}
+
+	if !update.FaviconHash.Valid && !update.EnrichmentJson.Valid {
+		if len(errs) > 0 {
+			return false, fmt.Errorf("enriching %s: %s", d.DomainName, strings.Join(errs, "; "))
+		}
+		return false, nil
+	}
+	if err := database.UpdateDomainEnrichment(update); err != nil {
+		return false, err
+	}
+	if len(errs) > 0 {
+		return true, fmt.Errorf("enriching %s: %s", d.DomainName, strings.Join(errs, "; "))
+	}
+	return true, nil
+}
+
+// domainIPs returns the IPv4 addresses of a domain, from the IP asset inventory when it has them.
+func (e *domainEnricher) domainIPs(ctx context.Context, name string) ([]string, error) {
+	var ips []string
+	if known, err := database.GetResolvedIPsForHost(name); err == nil {
+		ips = known
+	}
+	if len(ips) == 0 {
+		addrs, err := ipAssetLookup(ctx, func(ctx context.Context) ([]net.IPAddr, error) {
+			return reconResolver().LookupIPAddr(ctx, name)
+		})
+		if err != nil {
+			if isDNSNotFound(err) {
+				return []string{}, nil
+			}
+			return []string{}, fmt.Errorf("resolving %s: %w", name, err)
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP.String())
+		}
+	}
+	var v4 []string
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil && !containsString(v4, ip) {
+			v4 = append(v4, ip)
+		}
+	}
+	if len(v4) > maxEnrichmentIPs {
+		v4 = v4[:maxEnrichmentIPs]
+	}
+	if v4 == nil {
+		v4 = []string{}
+	}
+	return v4, nil
+}
+
+// getAPIJSON GETs an API URL; a 404 (no data for the host) returns a nil body and no error.
+func (e *domainEnricher) getAPIJSON(ctx context.Context, service, rawURL string, setAuth func(*http.Request)) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: creating request: %w", service, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if setAuth != nil {
+		setAuth(req)
+	}
+	policy := providerRetryPolicy{MaxRetries: 2, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+	resp, err := doWithRetry(ctx, e.api, req, policy, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: request failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading response: %w", service, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		msg := gjson.GetBytes(body, "error").String()
+		if msg == "" {
+			msg = strings.TrimSpace(string(body))
+			if len(msg) > 200 {
+				msg = msg[:200]
+			}
+		}
+		return nil, fmt.Errorf("%s returned status %d: %s", service, resp.StatusCode, msg)
+	}
+	return body, nil
+}
+
+// shodanHost adds what Shodan knows about ip: open ports, hostnames and TLS certificates.
+func (e *domainEnricher) shodanHost(ctx context.Context, ip string, out *models.DomainEnrichment) error {
+	conf := e.conf.Shodan
+	rawURL := fmt.Sprintf("%s/shodan/host/%s?key=%s", strings.TrimRight(conf.BaseURL, "/"), url.PathEscape(ip), url.QueryEscape(conf.APIKey))
+	body, err := e.getAPIJSON(ctx, "shodan", rawURL, nil)
+	if err != nil || body == nil {
+		return err
+	}
+	host := gjson.ParseBytes(body)
+	for _, p := range host.Get("ports").Array() {
+		out.Ports = append(out.Ports, int(p.Int()))
+	}
+	for _, h := range host.Get("hostnames").Array() {
+		out.Hostnames = append(out.Hostnames, h.String())
+	}
+	for _, service := range host.Get("data").Array() {
+		cert := service.Get("ssl.cert")
+		if !cert.Exists() {
+			continue
+		}
+		out.Certificates = append(out.Certificates, models.CertificateInfo{
+			Source:   "shodan",
+			IP:       ip,
+			Port:     int(service.Get("port").Int()),
+			Subject:  cert.Get("subject.CN").String(),
+			Issuer:   joinNonEmpty(", ", cert.Get("issuer.CN").String(), cert.Get("issuer.O").String()),
+			NotAfter: cert.Get("expires").String(),
+			SHA256:   cert.Get("fingerprint.sha256").String(),
+		})
+	}
+	return nil
+}
+
+// shodanFaviconHosts searches Shodan for other hosts serving a favicon with the same hash.
+func (e *domainEnricher) shodanFaviconHosts(ctx context.Context, hash int32) ([]models.RelatedHost, error) {
+	e.mu.Lock()
+	cached, ok := e.byHash[hash]
+	e.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+	conf := e.conf.Shodan
+	rawURL := fmt.Sprintf("%s/shodan/host/search?key=%s&minify=true&query=%s", strings.TrimRight(conf.BaseURL, "/"),
+		url.QueryEscape(conf.APIKey), url.QueryEscape(fmt.Sprintf("http.favicon.hash:%d", hash)))
+	body, err := e.getAPIJSON(ctx, "shodan", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	limit := e.conf.MaxRelatedHosts
+	if limit <= 0 {
+		limit = 25
+	}
+	var related []models.RelatedHost
+	for _, match := range gjson.GetBytes(body, "matches").Array() {
+		if len(related) >= limit {
+			break
+		}
+		host := models.RelatedHost{Source: "shodan", Reason: "favicon", IP: match.Get("ip_str").String(), Port: int(match.Get("port").Int())}
+		for _, h := range match.Get("hostnames").Array() {
+			host.Hostnames = append(host.Hostnames, h.String())
+		}
+		related = append(related, host)
+	}
+	e.mu.Lock()
+	e.byHash[hash] = related
+	e.mu.Unlock()
+	return related, nil
+}
+
+// censysHost adds what Censys knows about ip: services, reverse DNS names and TLS certificates.
+func (e *domainEnricher) censysHost(ctx context.Context, ip string, out *models.DomainEnrichment) error {
+	conf := e.conf.Censys
+	rawURL := fmt.Sprintf("%s/v2/hosts/%s", strings.TrimRight(conf.BaseURL, "/"), url.PathEscape(ip))
+	body, err := e.getAPIJSON(ctx, "censys", rawURL, func(req *http.Request) {
+		req.SetBasicAuth(conf.APIID, conf.APISecret)
+	})
+	if err != nil || body == nil {
+		return err
+	}
+	host := gjson.GetBytes(body, "result")
+	for _, name := range host.Get("dns.reverse_dns.names").Array() {
+		out.Hostnames = append(out.Hostnames, name.String())
+	}
+	for _, name := range host.Get("dns.names").Array() {
+		out.Hostnames = append(out.Hostnames, name.String())
+	}
+	for _, service := range host.Get("services").Array() {
+		port := int(service.Get("port").Int())
+		out.Ports = append(out.Ports, port)
+		leaf := service.Get("tls.certificates.leaf_data")
+		if !leaf.Exists() {
+			continue
+		}
+		cert := models.CertificateInfo{
+			Source:  "censys",
+			IP:      ip,
+			Port:    port,
+			Subject: leaf.Get("subject_dn").String(),
+			Issuer:  leaf.Get("issuer_dn").String(),
+			SHA256:  leaf.Get("fingerprint").String(),
+		}
+		for _, name := range leaf.Get("names").Array() {
+			cert.Names = append(cert.Names, name.String())
+			out.Hostnames = append(out.Hostnames, strings.TrimPrefix(name.String(), "*."))
+		}
+		out.Certificates = append(out.Certificates, cert)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueSortedInts(values []int) []int {
+	seen := make(map[int]bool)
+	out := []int{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// uniqueSortedStrings deduplicates names case-insensitively, leaving out exclude.
+func uniqueSortedStrings(values []string, exclude string) []string {
+	seen := map[string]bool{strings.ToLower(exclude): true}
+	out := []string{}
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+// DomainExportFormats are the formats ExportDomains writes.
+var DomainExportFormats = []string{"txt", "csv", "json"}
+
+// domainExportCSVHeader is the header row of the CSV export.
+var domainExportCSVHeader = []string{"domain_name", "source", "is_in_scope", "is_wildcard_scope", "is_favorite", "http_status_code", "http_content_length",
+	"http_title", "http_server", "http_tech", "favicon_hash", "open_ports", "takeover_status", "notes", "created_at"}
+
+// DomainFiltersFromQuery reads the domain list filters and sorting from query parameters, the same
+// ones GET /targets/{target_id}/domains takes. Page and Limit are left to the caller.
+func DomainFiltersFromQuery(targetID int64, q url.Values) models.DomainFilters {
+	filters := models.DomainFilters{TargetID: targetID}
+	filters.SortBy = q.Get("sort_by")
+	if filters.SortBy == "" {
+		filters.SortBy = "domain_name"
+	}
+	filters.SortOrder = strings.ToUpper(q.Get("sort_order"))
+	if filters.SortOrder != "ASC" && filters.SortOrder != "DESC" {
+		filters.SortOrder = "ASC"
+	}
+
+	filters.DomainNameSearch = q.Get("domain_name_search")
+	filters.SourceSearch = q.Get("source_search")
+	if v, err := strconv.ParseBool(q.Get("is_in_scope")); err == nil {
+		filters.IsInScope = &v
+	}
+	if v, err := strconv.ParseBool(q.Get("is_favorite")); err == nil {
+		filters.IsFavorite = &v
+	}
+	filters.HttpxScanStatus = q.Get("httpx_scan_status")
+	if filters.HttpxScanStatus == "" {
+		filters.HttpxScanStatus = "all"
+	}
+	filters.FilterHTTPStatusCode = q.Get("filter_http_status_code")
+	filters.FilterHTTPServer = q.Get("filter_http_server")
+	filters.FilterHTTPTech = q.Get("filter_http_tech")
+	filters.FilterFaviconHash = q.Get("filter_favicon_hash")
+	filters.FilterOpenPort = q.Get("filter_open_port")
+	filters.FilterTakeoverStatus = q.Get("filter_takeover_status")
+	filters.FilterRootDomain = q.Get("filter_root_domain")
+	filters.FilterBusinessUnit = q.Get("filter_business_unit")
+	return filters
+}
+
+// DomainExportContentType returns the Content-Type and file extension of an export format.
+func DomainExportContentType(format string) (string, string) {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8", "csv"
+	case "json":
+		return "application/json", "json"
+	}
+	return "text/plain; charset=utf-8", "txt"
+}
+
+// ExportDomains writes domains as plain host names one per line ("txt", for tools such as nuclei or
+// ffuf), as CSV with the httpx and enrichment columns, or as a JSON array. Unknown formats are
+// rejected with an error starting with "invalid format".
+func ExportDomains(w io.Writer, format string, domains []models.Domain) error {
+	switch format {
+	case "", "txt":
+		for _, d := range domains {
+			if _, err := fmt.Fprintln(w, d.DomainName); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(domainExportCSVHeader)
+		for _, d := range domains {
+			r := domainExportRecord(d)
+			cw.Write([]string{r.DomainName, r.Source, strconv.FormatBool(r.IsInScope), strconv.FormatBool(r.IsWildcardScope), strconv.FormatBool(r.IsFavorite),
+				optionalInt(r.HTTPStatusCode), optionalInt(r.HTTPContentLength), r.HTTPTitle, r.HTTPServer, r.HTTPTech, optionalInt(r.FaviconHash),
+				r.OpenPorts, r.TakeoverStatus, r.Notes, r.CreatedAt.UTC().Format(time.RFC3339)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		records := make([]models.DomainExportRecord, len(domains))
+		for i, d := range domains {
+			records[i] = domainExportRecord(d)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	return fmt.Errorf("invalid format %q (use %s)", format, strings.Join(DomainExportFormats, ", "))
+}
+
+// domainExportRecord flattens a domain's nullable columns for export.
+func domainExportRecord(d models.Domain) models.DomainExportRecord {
+	r := models.DomainExportRecord{
+		ID:              d.ID,
+		DomainName:      d.DomainName,
+		Source:          d.Source.String,
+		IsInScope:       d.IsInScope,
+		IsWildcardScope: d.IsWildcardScope,
+		IsFavorite:      d.IsFavorite,
+		HTTPTitle:       d.HTTPTitle.String,
+		HTTPServer:      d.HTTPServer.String,
+		HTTPTech:        d.HTTPTech.String,
+		OpenPorts:       d.OpenPorts.String,
+		TakeoverStatus:  d.TakeoverStatus.String,
+		Notes:           d.Notes.String,
+		CreatedAt:       d.CreatedAt,
+	}
+	if d.HTTPStatusCode.Valid {
+		r.HTTPStatusCode = &d.HTTPStatusCode.Int64
+	}
+	if d.HTTPContentLength.Valid {
+		r.HTTPContentLength = &d.HTTPContentLength.Int64
+	}
+	if d.FaviconHash.Valid {
+		r.FaviconHash = &d.FaviconHash.Int64
+	}
+	return r
+}
+
+func optionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"toolkit/database"
+	"toolkit/models"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxBusinessUnitLength caps the length of business unit names.
+const maxBusinessUnitLength = 100
+
+// RootDomain returns the registrable domain (eTLD+1) of a domain name by the public suffix list,
+// such as example.co.uk for api.eu.example.co.uk. Wildcard prefixes and trailing dots are ignored;
+// IP addresses and names that are themselves public suffixes are their own root.
+func RootDomain(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	name = strings.TrimPrefix(name, "*.")
+	if net.ParseIP(strings.Trim(name, "[]")) != nil {
+		return name
+	}
+	root, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return name
+	}
+	return root
+}
+
+// GetDomainGroups groups the domains of a target by root domain with per-group counts, and rolls
+// the groups up by business unit. businessUnit ("NULL" for none) and search (part of the root
+// domain) narrow the groups listed.
+func GetDomainGroups(targetID int64, businessUnit, search string) (models.DomainGroupsReport, error) {
+	report := models.DomainGroupsReport{TargetID: targetID, Groups: []models.DomainGroup{}, BusinessUnits: []models.BusinessUnitRollup{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return report, err
+	}
+	labels, err := database.GetDomainGroupLabels(targetID)
+	if err != nil {
+		return report, err
+	}
+
+	groups := map[string]*models.DomainGroup{}
+	err = database.ForEachTargetDomainSummary(targetID, func(d models.Domain) {
+		root := RootDomain(d.DomainName)
+		g, ok := groups[root]
+		if !ok {
+			g = &models.DomainGroup{RootDomain: root, StatusClasses: map[string]int{}}
+			groups[root] = g
+		}
+		g.Domains++
+		if d.IsInScope {
+			g.InScope++
+		}
+		if d.IsWildcardScope {
+			g.Wildcards++
+		}
+		if d.IsFavorite {
+			g.Favorites++
+		}
+		if d.HTTPStatusCode.Valid {
+			g.Live++
+			g.StatusClasses[fmt.Sprintf("%dxx", d.HTTPStatusCode.Int64/100)]++
+		}
+		switch d.TakeoverStatus.String {
+		case models.TakeoverStatusVulnerable:
+			g.TakeoverVulnerable++
+		case models.TakeoverStatusDangling:
+			g.TakeoverDangling++
+		}
+		if g.LastAddedAt == nil || d.CreatedAt.After(*g.LastAddedAt) {
+			created := d.CreatedAt
+			g.LastAddedAt = &created
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+	// Labeled root domains are listed even before any of their domains are found.
+	for root, label := range labels {
+		g, ok := groups[root]
+		if !ok {
+			g = &models.DomainGroup{RootDomain: root, StatusClasses: map[string]int{}}
+			groups[root] = g
+		}
+		g.BusinessUnit, g.Notes = label.BusinessUnit, label.Notes
+	}
+
+	units := map[string]*models.BusinessUnitRollup{}
+	search = strings.ToLower(strings.TrimSpace(search))
+	businessUnit = strings.TrimSpace(businessUnit)
+	for _, g := range groups {
+		report.TotalDomains += g.Domains
+		name := g.BusinessUnit
+		if name == "" {
+			name = models.DomainGroupUnassigned
+		}
+		u, ok := units[strings.ToLower(name)]
+		if !ok {
+			u = &models.BusinessUnitRollup{Name: name}
+			units[strings.ToLower(name)] = u
+		}
+		u.RootDomains = append(u.RootDomains, g.RootDomain)
+		u.Domains += g.Domains
+		u.InScope += g.InScope
+		u.Live += g.Live
+		u.TakeoverVulnerable += g.TakeoverVulnerable
+
+		if search != "" && !strings.Contains(g.RootDomain, search) {
+			continue
+		}
+		if businessUnit != "" && !(strings.EqualFold(businessUnit, "NULL") && g.BusinessUnit == "") && !strings.EqualFold(businessUnit, g.BusinessUnit) {
+			continue
+		}
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Domains != report.Groups[j].Domains {
+			return report.Groups[i].Domains > report.Groups[j].Domains
+		}
+		return report.Groups[i].RootDomain < report.Groups[j].RootDomain
+	})
+	for _, u := range units {
+		sort.Strings(u.RootDomains)
+		report.BusinessUnits = append(report.BusinessUnits, *u)
+	}
+	// Named business units by name, then the unassigned root domains.
+	sort.Slice(report.BusinessUnits, func(i, j int) bool {
+		a, b := report.BusinessUnits[i].Name, report.BusinessUnits[j].Name
+		if (a == models.DomainGroupUnassigned) != (b == models.DomainGroupUnassigned) {
+			return b == models.DomainGroupUnassigned
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	})
+	return report, nil
+}
+
+// SetDomainGroup assigns a root domain of a target to a business unit, with notes; empty values
+// remove the assignment. It returns the group as GetDomainGroups lists it.
+func SetDomainGroup(targetID int64, rootDomain string, update models.DomainGroupUpdate) (models.DomainGroup, error) {
+	rootDomain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rootDomain)), ".")
+	if rootDomain == "" {
+		return models.DomainGroup{}, models.InvalidRequestError("root domain is required")
+	}
+	if _, err := publicsuffix.EffectiveTLDPlusOne(rootDomain); err != nil && net.ParseIP(rootDomain) == nil {
+		return models.DomainGroup{}, models.InvalidRequestError("'%s' is a public suffix, not a root domain", rootDomain)
+	}
+	if root := RootDomain(rootDomain); root != rootDomain {
+		return models.DomainGroup{}, models.InvalidRequestError("'%s' is not a root domain; its root domain is '%s'", rootDomain, root)
+	}
+	update.BusinessUnit = strings.TrimSpace(update.BusinessUnit)
+	update.Notes = strings.TrimSpace(update.Notes)
+	if len(update.BusinessUnit) > maxBusinessUnitLength {
+		return models.DomainGroup{}, models.InvalidRequestError("business_unit is longer than %d characters", maxBusinessUnitLength)
+	}
+	if strings.EqualFold(update.BusinessUnit, models.DomainGroupUnassigned) || strings.EqualFold(update.BusinessUnit, "NULL") {
+		return models.DomainGroup{}, models.InvalidRequestError("'%s' is reserved for root domains without a business unit", update.BusinessUnit)
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.DomainGroup{}, err
+	}
+	labels, err := database.GetDomainGroupLabels(targetID)
+	if err != nil {
+		return models.DomainGroup{}, err
+	}
+	// Business units are matched case-insensitively; keep the spelling already in use.
+	for _, label := range labels {
+		if label.RootDomain != rootDomain && strings.EqualFold(label.BusinessUnit, update.BusinessUnit) {
+			update.BusinessUnit = label.BusinessUnit
+			break
+		}
+	}
+	if err := database.SetDomainGroupLabel(targetID, rootDomain, update); err != nil {
+		return models.DomainGroup{}, err
+	}
+	report, err := GetDomainGroups(targetID, "", "")
+	if err != nil {
+		return models.DomainGroup{}, err
+	}
+	for _, g := range report.Groups {
+		if g.RootDomain == rootDomain {
+			return g, nil
+		}
+	}
+	// Unlabeled and without domains.
+	return models.DomainGroup{RootDomain: rootDomain, StatusClasses: map[string]int{}}, nil
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// domainImportHeaders are the CSV column names recognized as holding the domain.
+var domainImportHeaders = map[string]bool{
+	"domain": true, "domain_name": true, "domains": true, "subdomain": true, "subdomains": true,
+	"host": true, "hostname": true, "hosts": true, "fqdn": true, "name": true,
+}
+
+// ImportDomains adds the domains listed in r to a target. The input is either one domain per line
+// (the output of subfinder, amass, assetfinder and the like) or CSV; a header row naming a domain
+// column selects it, otherwise the first field of a row that is a host name is used. URLs, ports,
+// trailing dots and "*." prefixes are stripped. Rows repeated in the input, domains the target
+// already has and, with ValidateScope, domains outside the target's scope are skipped.
+// Malformed input is rejected with an error starting with "invalid import file".
+func ImportDomains(targetID int64, r io.Reader, opts models.DomainImportOptions) (models.DomainImportResult, error) {
+	source := strings.TrimSpace(opts.Source)
+	if source == "" {
+		source = models.DomainImportDefaultSource
+	}
+	result := models.DomainImportResult{TargetID: targetID, Source: source, DryRun: opts.DryRun, Skipped: []models.DomainImportSkip{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	names, err := database.GetDomainNamesForTarget(targetID)
+	if err != nil {
+		return result, err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[strings.ToLower(name)] = true
+	}
+	var rules []models.ScopeRule
+	if opts.ValidateScope {
+		if rules, err = database.GetScopeRulesByTargetID(targetID); err != nil {
+			return result, err
+		}
+	}
+
+	skip := func(line int, value, reason string) {
+		switch reason {
+		case models.DomainImportSkipInvalid:
+			result.Invalid++
+		case models.DomainImportSkipDuplicate:
+			result.Duplicates++
+		case models.DomainImportSkipExisting:
+			result.Existing++
+		case models.DomainImportSkipOutOfScope:
+			result.OutOfScope++
+		}
+		if len(result.Skipped) < models.DomainImportMaxSkipped {
+			result.Skipped = append(result.Skipped, models.DomainImportSkip{Line: line, Value: value, Reason: reason})
+		} else {
+			result.Truncated = true
+		}
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	column := -1
+	seen := map[string]bool{}
+	var toAdd []models.Domain
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("invalid import file: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if first {
+			if i := domainImportHeaderColumn(record); i >= 0 {
+				column = i
+				continue
+			}
+		}
+		value := strings.TrimSpace(strings.Join(record, ","))
+		if value == "" {
+			continue
+		}
+		result.Rows++
+
+		var name string
+		if column >= 0 {
+			if column < len(record) {
+				name = normalizeImportedDomain(record[column])
+			}
+		} else {
+			for _, field := range record {
+				for _, token := range strings.Fields(field) {
+					if name = normalizeImportedDomain(token); name != "" {
+						break
+					}
+				}
+				if name != "" {
+					break
+				}
+			}
+		}
+		switch {
+		case name == "":
+			skip(line, value, models.DomainImportSkipInvalid)
+		case seen[name]:
+			skip(line, name, models.DomainImportSkipDuplicate)
+		case existing[name]:
+			seen[name] = true
+			skip(line, name, models.DomainImportSkipExisting)
+		case opts.ValidateScope && !isRequestEffectivelyInScope(&url.URL{Scheme: "https", Host: name, Path: "/"}, rules):
+			seen[name] = true
+			skip(line, name, models.DomainImportSkipOutOfScope)
+		default:
+			seen[name] = true
+			toAdd = append(toAdd, models.Domain{TargetID: targetID, DomainName: name, Source: models.NullString(source), IsInScope: opts.ValidateScope})
+		}
+	}
+
+	if opts.DryRun {
+		result.Added = len(toAdd)
+	} else if len(toAdd) > 0 {
+		added, err := database.CreateDomains(toAdd)
+		if err != nil {
+			return result, err
+		}
+		result.Added = len(added)
+		// Domains added by someone else since the existing names were read
+		result.Existing += len(toAdd) - len(added)
+		if result.Added > 0 {
+			ResolveIPAssetsAfterDiscovery(targetID)
+		}
+	}
+	logger.Info("ImportDomains: target %d, source '%s': %d rows, %d added, %d existing, %d duplicates, %d out of scope, %d invalid (dry run: %t)",
+		targetID, source, result.Rows, result.Added, result.Existing, result.Duplicates, result.OutOfScope, result.Invalid, opts.DryRun)
+	return result, nil
+}
+
+// domainImportHeaderColumn returns the index of the domain column when record is a CSV header row,
+// or -1.
+func domainImportHeaderColumn(record []string) int {
+	for i, field := range record {
+		if domainImportHeaders[strings.ToLower(strings.Trim(strings.TrimSpace(field), `"`))] {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeImportedDomain turns a value of an imported row into a lower-case host name, or returns
+// "" when it does not name a domain (IP addresses included).
+func normalizeImportedDomain(value string) string {
+	value = strings.ToLower(strings.Trim(strings.TrimSpace(value), `"'`))
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return ""
+		}
+		value = u.Hostname()
+	} else {
+		if i := strings.IndexAny(value, "/?#"); i >= 0 {
+			value = value[:i]
+		}
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+	}
+	value = strings.TrimPrefix(strings.TrimSuffix(value, "."), "*.")
+	if len(value) > 253 || !strings.Contains(value, ".") || net.ParseIP(value) != nil {
+		return ""
+	}
+	labels := strings.Split(value, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return ""
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+				return ""
+			}
+		}
+	}
+	if tld := labels[len(labels)-1]; strings.Trim(tld, "0123456789") == "" {
+		return ""
+	}
+	return value
+}
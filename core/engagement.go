@@ -0,0 +1,397 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// engagementTouchInterval is how often the user's traffic to a target updates its running
+// engagement session; requests in between only count in the traffic log.
+const engagementTouchInterval = 30 * time.Second
+
+// engagementTouches holds when each target's engagement session was last updated from traffic.
+var engagementTouches sync.Map // map[int64]time.Time
+
+// engagementIdleTimeout is engagement.idle_timeout_minutes, 15 minutes when unset.
+func engagementIdleTimeout() time.Duration {
+	if minutes := config.AppConfig.Engagement.IdleTimeoutMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// noteEngagementActivity records a request of the user to a target at the given time in the
+// target's running engagement session. With engagement.auto_track, a target without one gets an
+// automatic session, and an automatic session idle for longer than the idle timeout ends at its
+// last activity before a new one starts.
+func noteEngagementActivity(targetID int64, at time.Time) {
+	if last, ok := engagementTouches.Load(targetID); ok && at.Sub(last.(time.Time)) < engagementTouchInterval {
+		return
+	}
+	engagementTouches.Store(targetID, at)
+
+	session, found, err := database.GetRunningEngagementSession(targetID)
+	if err != nil {
+		logger.ProxyError("Engagement: %v", err)
+		return
+	}
+	if found && session.Source == models.EngagementSourceAuto && sessionIdleAt(session, at) {
+		if err := finishEngagementSession(&session, *lastEngagementActivity(session)); err != nil {
+			logger.ProxyError("Engagement: %v", err)
+			return
+		}
+		found = false
+	}
+	if found {
+		if err := database.TouchEngagementSession(session.ID, at); err != nil {
+			logger.ProxyError("Engagement: %v", err)
+		}
+		checkEngagementSchedule(session, at)
+		return
+	}
+	if !config.AppConfig.Engagement.AutoTrack {
+		return
+	}
+	session = models.EngagementSession{TargetID: targetID, Source: models.EngagementSourceAuto, StartedAt: at, LastActivityAt: &at}
+	if _, err := database.CreateEngagementSession(session); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return // Started meanwhile, e.g. by the timer in another process
+		}
+		logger.ProxyError("Engagement: %v", err)
+		return
+	}
+	logger.ProxyInfo("Engagement: Started an automatic session for target %d", targetID)
+}
+
+// lastEngagementActivity returns when a session was last active: its latest activity, or its
+// start when it had none.
+func lastEngagementActivity(s models.EngagementSession) *time.Time {
+	if s.LastActivityAt != nil {
+		return s.LastActivityAt
+	}
+	return &s.StartedAt
+}
+
+// sessionIdleAt reports whether an automatic session had no activity within the idle timeout
+// before the given time.
+func sessionIdleAt(s models.EngagementSession, at time.Time) bool {
+	return at.Sub(*lastEngagementActivity(s)) > engagementIdleTimeout()
+}
+
+// checkEngagementSchedule sends the engagement_time notification once a running session passes
+// its planned time or its deadline.
+func checkEngagementSchedule(s models.EngagementSession, at time.Time) {
+	if s.ScheduleAlerted {
+		return
+	}
+	var message string
+	switch {
+	case s.DueAt != nil && at.After(*s.DueAt):
+		message = fmt.Sprintf("The deadline of %s passed at %s.", engagementSessionName(s), s.DueAt.Local().Format("2006-01-02 15:04"))
+	case s.PlannedMinutes > 0 && at.Sub(s.StartedAt) > time.Duration(s.PlannedMinutes)*time.Minute:
+		message = fmt.Sprintf("%s has run past its planned %d minutes.", engagementSessionName(s), s.PlannedMinutes)
+	default:
+		return
+	}
+	if err := database.MarkEngagementScheduleAlerted(s.ID); err != nil {
+		logger.Error("Engagement: %v", err)
+		return
+	}
+	NotifyTargetEvent(models.NotificationEngagement, s.TargetID, "Engagement session over schedule", message, s)
+}
+
+// engagementSessionName names a session in messages.
+func engagementSessionName(s models.EngagementSession) string {
+	if s.Label != "" {
+		return fmt.Sprintf("Engagement session %d (%s)", s.ID, s.Label)
+	}
+	return fmt.Sprintf("Engagement session %d", s.ID)
+}
+
+// finishEngagementSession ends a running session at the given time with its counts from the
+// traffic log.
+func finishEngagementSession(s *models.EngagementSession, endedAt time.Time) error {
+	if endedAt.Before(s.StartedAt) {
+		endedAt = s.StartedAt
+	}
+	s.EndedAt = &endedAt
+	if err := countEngagementActivity(s, endedAt); err != nil {
+		return err
+	}
+	if err := database.EndEngagementSession(*s); err != nil {
+		return err
+	}
+	s.Running = false
+	fillEngagementSchedule(s, endedAt)
+	return nil
+}
+
+// countEngagementActivity sets the request counts and active time of a session from the requests
+// logged for its target between its start and the given time.
+func countEngagementActivity(s *models.EngagementSession, until time.Time) error {
+	idle := engagementIdleTimeout()
+	s.Requests, s.ManualRequests, s.ActiveSeconds = 0, 0, 0
+	var active time.Duration
+	var previous time.Time
+	err := database.ForEachTargetRequestTime(s.TargetID, s.StartedAt, until, func(at time.Time, logSource string) {
+		s.Requests++
+		if models.TrafficOrigin(logSource) != models.TrafficOriginManual {
+			return
+		}
+		s.ManualRequests++
+		if !previous.IsZero() {
+			gap := at.Sub(previous)
+			if gap > idle {
+				gap = idle
+			}
+			active += gap
+		}
+		previous = at
+	})
+	s.ActiveSeconds = int64(active.Seconds())
+	return err
+}
+
+// fillEngagementSchedule sets the duration of a session as of now (for a running one) and how it
+// stands against its time budget and deadline.
+func fillEngagementSchedule(s *models.EngagementSession, now time.Time) {
+	end := now
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	duration := end.Sub(s.StartedAt)
+	s.DurationSeconds = int64(duration.Seconds())
+	s.RemainingSeconds = nil
+	if s.PlannedMinutes > 0 {
+		remaining := int64((time.Duration(s.PlannedMinutes)*time.Minute - duration).Seconds())
+		s.RemainingSeconds = &remaining
+		s.OverBudget = remaining < 0
+	}
+	s.Overdue = s.DueAt != nil && end.After(*s.DueAt)
+}
+
+// closeIdleEngagementSessions ends the automatic sessions idle for longer than the idle timeout
+// at their last activity.
+func closeIdleEngagementSessions() error {
+	sessions, err := database.ListIdleEngagementSessions(time.Now().Add(-engagementIdleTimeout()))
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if err := finishEngagementSession(&s, *lastEngagementActivity(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartEngagementSession starts the timer of a target. It fails when the target already has a
+// running session.
+func StartEngagementSession(targetID int64, req models.EngagementSessionRequest) (models.EngagementSession, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.EngagementSession{}, err
+	}
+	if err := closeIdleEngagementSessions(); err != nil {
+		return models.EngagementSession{}, err
+	}
+	if running, found, err := database.GetRunningEngagementSession(targetID); err != nil {
+		return models.EngagementSession{}, err
+	} else if found {
+		return models.EngagementSession{}, fmt.Errorf("target %d already has a running engagement session (%d); stop it first", targetID, running.ID)
+	}
+	s := models.EngagementSession{TargetID: targetID, Source: models.EngagementSourceManual, StartedAt: time.Now()}
+	if err := applyEngagementSessionRequest(&s, req); err != nil {
+		return s, err
+	}
+	id, err := database.CreateEngagementSession(s)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return s, fmt.Errorf("target %d already has a running engagement session; stop it first", targetID)
+		}
+		return s, err
+	}
+	return GetEngagementSession(id)
+}
+
+// StopEngagementSession stops the running session of a target, automatic or not, now. notes,
+// when not nil, replace its notes.
+func StopEngagementSession(targetID int64, notes *string) (models.EngagementSession, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.EngagementSession{}, err
+	}
+	if err := closeIdleEngagementSessions(); err != nil {
+		return models.EngagementSession{}, err
+	}
+	s, found, err := database.GetRunningEngagementSession(targetID)
+	if err != nil {
+		return s, err
+	}
+	if !found {
+		return s, fmt.Errorf("target %d has no running engagement session", targetID)
+	}
+	if notes != nil {
+		s.Notes = *notes
+	}
+	engagementTouches.Delete(targetID)
+	if err := finishEngagementSession(&s, time.Now()); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// applyEngagementSessionRequest copies the fields set in req to s.
+func applyEngagementSessionRequest(s *models.EngagementSession, req models.EngagementSessionRequest) error {
+	if req.Label != nil {
+		s.Label = strings.TrimSpace(*req.Label)
+	}
+	if req.PlannedMinutes != nil {
+		if *req.PlannedMinutes < 0 {
+			return fmt.Errorf("invalid planned_minutes %d: must not be negative", *req.PlannedMinutes)
+		}
+		s.PlannedMinutes = *req.PlannedMinutes
+	}
+	if req.DueAt != nil {
+		if req.DueAt.IsZero() {
+			s.DueAt = nil
+		} else {
+			due := *req.DueAt
+			s.DueAt = &due
+		}
+	}
+	if req.Notes != nil {
+		s.Notes = *req.Notes
+	}
+	return nil
+}
+
+// GetEngagementSession returns an engagement session with its counts and schedule state.
+func GetEngagementSession(id int64) (models.EngagementSession, error) {
+	if err := closeIdleEngagementSessions(); err != nil {
+		return models.EngagementSession{}, err
+	}
+	s, err := database.GetEngagementSession(id)
+	if err != nil {
+		return s, err
+	}
+	return s, completeEngagementSession(&s, time.Now())
+}
+
+// completeEngagementSession counts the activity of a running session and sets the schedule state.
+func completeEngagementSession(s *models.EngagementSession, now time.Time) error {
+	if s.Running {
+		if err := countEngagementActivity(s, now); err != nil {
+			return err
+		}
+	}
+	fillEngagementSchedule(s, now)
+	return nil
+}
+
+// UpdateEngagementSession changes the label, time budget, deadline or notes of a session. A zero
+// due_at clears the deadline.
+func UpdateEngagementSession(id int64, req models.EngagementSessionRequest) (models.EngagementSession, error) {
+	s, err := database.GetEngagementSession(id)
+	if err != nil {
+		return s, err
+	}
+	if err := applyEngagementSessionRequest(&s, req); err != nil {
+		return s, err
+	}
+	if err := database.UpdateEngagementSession(s); err != nil {
+		return s, err
+	}
+	return GetEngagementSession(id)
+}
+
+// DeleteEngagementSession deletes an engagement session.
+func DeleteEngagementSession(id int64) error {
+	return database.DeleteEngagementSession(id)
+}
+
+// ListEngagementSessions returns the engagement sessions of a target started within [since,
+// until) (either may be nil), latest first, with their counts and schedule state. limit 0 means
+// no limit.
+func ListEngagementSessions(targetID int64, since, until *time.Time, limit int) ([]models.EngagementSession, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return nil, err
+	}
+	if err := closeIdleEngagementSessions(); err != nil {
+		return nil, err
+	}
+	sessions, err := database.ListEngagementSessions(targetID, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range sessions {
+		if err := completeEngagementSession(&sessions[i], now); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// GetEngagementSummary totals the time spent and requests made in the engagement sessions of a
+// target started within [since, until) (either may be nil), per day they started on.
+func GetEngagementSummary(targetID int64, since, until *time.Time) (models.EngagementSummary, error) {
+	summary := models.EngagementSummary{TargetID: targetID, Since: since, Until: until, Days: []models.EngagementDay{}}
+	sessions, err := ListEngagementSessions(targetID, since, until, 0)
+	if err != nil {
+		return summary, err
+	}
+	days := map[string]*models.EngagementDay{}
+	for i := range sessions {
+		s := &sessions[i]
+		summary.Sessions++
+		summary.DurationSeconds += s.DurationSeconds
+		summary.ActiveSeconds += s.ActiveSeconds
+		summary.Requests += s.Requests
+		summary.ManualRequests += s.ManualRequests
+		if s.Running {
+			summary.Running = s
+		}
+		date := s.StartedAt.Local().Format("2006-01-02")
+		day := days[date]
+		if day == nil {
+			day = &models.EngagementDay{Date: date}
+			days[date] = day
+		}
+		day.Sessions++
+		day.DurationSeconds += s.DurationSeconds
+		day.ActiveSeconds += s.ActiveSeconds
+		day.Requests += s.Requests
+		day.ManualRequests += s.ManualRequests
+	}
+	for _, day := range days {
+		day.Hours = engagementHours(day.DurationSeconds)
+		summary.Days = append(summary.Days, *day)
+	}
+	sort.Slice(summary.Days, func(i, j int) bool { return summary.Days[i].Date < summary.Days[j].Date })
+	summary.Hours = engagementHours(summary.DurationSeconds)
+	return summary, nil
+}
+
+// engagementHours converts seconds to hours rounded to the hundredth.
+func engagementHours(seconds int64) float64 {
+	return math.Round(float64(seconds)/36) / 100
+}
+
+// ParseEngagementTime parses the bound of a summary period: an RFC 3339 time, or a date
+// (YYYY-MM-DD) meaning its local midnight.
+func ParseEngagementTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected RFC3339 or YYYY-MM-DD", value)
+}
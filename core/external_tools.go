@@ -0,0 +1,409 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// maxToolArchiveBytes caps the size of a downloaded release archive.
+const maxToolArchiveBytes = 256 * 1024 * 1024
+
+// externalTool is an external binary the toolkit runs, or offers to checklist commands.
+type externalTool struct {
+	name          string
+	purpose       string
+	required      bool
+	versionArgs   []string // Make it print its version
+	minVersion    string
+	pinnedVersion string
+	repo          string                                    // GitHub owner/name its releases come from
+	asset         func(version, goos, goarch string) string // Release archive holding the binary
+}
+
+// externalTools lists the tools 'toolkit tools' manages, the required ones first.
+var externalTools = []externalTool{
+	{name: "subfinder", purpose: "Subdomain discovery (domains discover)", required: true, versionArgs: []string{"-version"},
+		minVersion: "2.5.0", pinnedVersion: "2.6.6", repo: "projectdiscovery/subfinder", asset: projectDiscoveryAsset("subfinder")},
+	{name: "httpx", purpose: "Probing domains for web servers (domains httpx)", required: true, versionArgs: []string{"-version"},
+		minVersion: "1.3.0", pinnedVersion: "1.6.8", repo: "projectdiscovery/httpx", asset: projectDiscoveryAsset("httpx")},
+	{name: "nuclei", purpose: "Template scans from checklist commands", versionArgs: []string{"-version"},
+		minVersion: "3.0.0", pinnedVersion: "3.3.2", repo: "projectdiscovery/nuclei", asset: projectDiscoveryAsset("nuclei")},
+	{name: "ffuf", purpose: "Fuzzing and content discovery from checklist commands", versionArgs: []string{"-V"},
+		minVersion: "2.0.0", pinnedVersion: "2.1.0", repo: "ffuf/ffuf", asset: ffufAsset},
+}
+
+var (
+	toolVersionPattern = regexp.MustCompile(`\bv?(\d+\.\d+\.\d+)`)
+	toolReleasePattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+	toolVersionsCheckedMu sync.Mutex
+	toolVersionsChecked   = map[string]bool{} // Binaries (path and modification time) whose version was checked
+	toolInstallMu         sync.Mutex
+)
+
+// releaseOS returns the operating system name used in release asset names.
+func releaseOS(goos string) string {
+	if goos == "darwin" {
+		return "macOS"
+	}
+	return goos
+}
+
+// projectDiscoveryAsset names the release zips of ProjectDiscovery tools, e.g. subfinder_2.6.6_linux_amd64.zip.
+func projectDiscoveryAsset(name string) func(version, goos, goarch string) string {
+	return func(version, goos, goarch string) string {
+		return fmt.Sprintf("%s_%s_%s_%s.zip", name, version, releaseOS(goos), goarch)
+	}
+}
+
+// ffufAsset names the release archives of ffuf: tarballs, except for zips on Windows.
+func ffufAsset(version, goos, goarch string) string {
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("ffuf_%s_%s_%s.%s", version, releaseOS(goos), goarch, ext)
+}
+
+func findExternalTool(name string) (externalTool, bool) {
+	for _, t := range externalTools {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return externalTool{}, false
+}
+
+func executableName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// lookupTool finds an executable in tools.bin_dir, then in PATH. managed reports the former.
+func lookupTool(name string) (path string, managed bool, err error) {
+	if dir := config.AppConfig.Tools.BinDir; dir != "" {
+		p := filepath.Join(dir, executableName(name))
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() && (runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0) {
+			return p, true, nil
+		}
+	}
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return "", false, fmt.Errorf("%s not found in tools.bin_dir or PATH", name)
+	}
+	return p, false, nil
+}
+
+// toolSearchPath returns PATH with tools.bin_dir in front, for commands the toolkit starts.
+func toolSearchPath() string {
+	if dir := config.AppConfig.Tools.BinDir; dir != "" {
+		return dir + string(os.PathListSeparator) + os.Getenv("PATH")
+	}
+	return os.Getenv("PATH")
+}
+
+// ResolveTool returns the binary of an external tool for a runner: the one in tools.bin_dir, else
+// the one in PATH. The version of a tool 'toolkit tools' manages is checked the first time a binary
+// is used, and an incompatible one is logged as a warning.
+func ResolveTool(name string) (string, error) {
+	p, managed, err := lookupTool(name)
+	if err != nil {
+		return "", fmt.Errorf("%w; install it with 'toolkit tools install %s'", err, name)
+	}
+	if tool, ok := findExternalTool(name); ok {
+		key := p
+		if info, err := os.Stat(p); err == nil {
+			key += "@" + info.ModTime().String()
+		}
+		toolVersionsCheckedMu.Lock()
+		checked := toolVersionsChecked[key]
+		toolVersionsChecked[key] = true
+		toolVersionsCheckedMu.Unlock()
+		if !checked {
+			if status := externalToolStatus(context.Background(), tool, p, managed); status.Status != models.ExternalToolOK {
+				logger.Warn("External tools: %s", status.Message)
+			}
+		}
+	}
+	return p, nil
+}
+
+// readToolVersion runs a tool with its version arguments and returns the first version in its output.
+func readToolVersion(ctx context.Context, binary string, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Env = append(os.Environ(), "PATH="+toolSearchPath())
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if m := toolVersionPattern.FindSubmatch(out); m != nil {
+		return string(m[1]), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("running %s %s: %w", binary, strings.Join(args, " "), err)
+	}
+	return "", errors.New("no version in its output")
+}
+
+// compareVersions compares two dotted versions numerically.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// toolRelease returns the release of a tool to install: tools.versions, else the pinned one.
+func toolRelease(tool externalTool) string {
+	if v := strings.TrimPrefix(strings.TrimSpace(config.AppConfig.Tools.Versions[tool.name]), "v"); v != "" {
+		return v
+	}
+	return tool.pinnedVersion
+}
+
+func externalToolStatus(ctx context.Context, tool externalTool, binary string, managed bool) models.ExternalToolStatus {
+	status := models.ExternalToolStatus{
+		Name: tool.name, Required: tool.required, Purpose: tool.purpose, Path: binary, Managed: managed,
+		MinVersion: tool.minVersion, PinnedVersion: toolRelease(tool),
+	}
+	if binary == "" {
+		status.Status = models.ExternalToolMissing
+		status.Message = fmt.Sprintf("%s is not installed; install it with 'toolkit tools install %s'", tool.name, tool.name)
+		return status
+	}
+	version, err := readToolVersion(ctx, binary, tool.versionArgs)
+	if err != nil {
+		status.Status = models.ExternalToolUnknownVersion
+		status.Message = fmt.Sprintf("could not read the version of %s (%v); another program named %s may shadow it, see 'toolkit tools install %s'",
+			binary, err, tool.name, tool.name)
+		return status
+	}
+	status.Version = version
+	if compareVersions(version, tool.minVersion) < 0 {
+		status.Status = models.ExternalToolIncompatible
+		status.Message = fmt.Sprintf("%s %s at %s is older than the minimum %s; upgrade it with 'toolkit tools install %s'",
+			tool.name, version, binary, tool.minVersion, tool.name)
+		return status
+	}
+	status.Status = models.ExternalToolOK
+	return status
+}
+
+// ExternalToolStatuses reports, for every managed tool, the binary the runners use and its version.
+func ExternalToolStatuses(ctx context.Context) []models.ExternalToolStatus {
+	statuses := make([]models.ExternalToolStatus, 0, len(externalTools))
+	for _, tool := range externalTools {
+		binary, managed, _ := lookupTool(tool.name)
+		statuses = append(statuses, externalToolStatus(ctx, tool, binary, managed))
+	}
+	return statuses
+}
+
+// ExternalToolNames lists the tools 'toolkit tools' manages.
+func ExternalToolNames() []string {
+	names := make([]string, 0, len(externalTools))
+	for _, t := range externalTools {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// InstallExternalTool downloads a release of a tool for this platform into tools.bin_dir, after
+// checking the archive against the release's checksums. An empty version installs the release from
+// tools.versions, else the pinned one.
+func InstallExternalTool(ctx context.Context, name, version string) (models.ExternalToolStatus, error) {
+	tool, ok := findExternalTool(name)
+	if !ok {
+		return models.ExternalToolStatus{}, fmt.Errorf("unknown tool '%s': known tools are %s", name, strings.Join(ExternalToolNames(), ", "))
+	}
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if version == "" {
+		version = toolRelease(tool)
+	}
+	if !toolReleasePattern.MatchString(version) {
+		return models.ExternalToolStatus{}, fmt.Errorf("invalid version '%s' for %s: expected major.minor.patch", version, name)
+	}
+	if compareVersions(version, tool.minVersion) < 0 {
+		return models.ExternalToolStatus{}, fmt.Errorf("invalid version %s for %s: it is older than the minimum %s", version, name, tool.minVersion)
+	}
+	dir := config.AppConfig.Tools.BinDir
+	if dir == "" {
+		return models.ExternalToolStatus{}, errors.New("tools.bin_dir is not configured")
+	}
+
+	toolInstallMu.Lock()
+	defer toolInstallMu.Unlock()
+
+	asset := tool.asset(version, runtime.GOOS, runtime.GOARCH)
+	base := fmt.Sprintf("%s/%s/releases/download/v%s/", strings.TrimRight(config.AppConfig.Tools.ReleaseBaseURL, "/"), tool.repo, version)
+	checksums, err := downloadToolFile(ctx, base+fmt.Sprintf("%s_%s_checksums.txt", tool.name, version), 1024*1024)
+	if err != nil {
+		return models.ExternalToolStatus{}, err
+	}
+	want := releaseChecksum(checksums, asset)
+	if want == "" {
+		return models.ExternalToolStatus{}, fmt.Errorf("the checksums of %s %s do not list %s: no release for %s/%s", name, version, asset, runtime.GOOS, runtime.GOARCH)
+	}
+	archive, err := downloadToolFile(ctx, base+asset, maxToolArchiveBytes)
+	if err != nil {
+		return models.ExternalToolStatus{}, err
+	}
+	if sum := sha256.Sum256(archive); !strings.EqualFold(hex.EncodeToString(sum[:]), want) {
+		return models.ExternalToolStatus{}, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset, want, hex.EncodeToString(sum[:]))
+	}
+	binary, err := extractToolBinary(asset, archive, executableName(tool.name))
+	if err != nil {
+		return models.ExternalToolStatus{}, err
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return models.ExternalToolStatus{}, fmt.Errorf("creating tools.bin_dir: %w", err)
+	}
+	target := filepath.Join(dir, executableName(tool.name))
+	f, err := os.CreateTemp(dir, "."+tool.name+"-*")
+	if err != nil {
+		return models.ExternalToolStatus{}, fmt.Errorf("installing %s: %w", name, err)
+	}
+	_, err = f.Write(binary)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0755)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), target)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return models.ExternalToolStatus{}, fmt.Errorf("installing %s: %w", name, err)
+	}
+	logger.Info("External tools: Installed %s %s to %s", name, version, target)
+	return externalToolStatus(ctx, tool, target, true), nil
+}
+
+// downloadToolFile fetches a release file of at most limit bytes.
+func downloadToolFile(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download of %s: %w", url, err)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Minute}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download of %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of %s failed: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("download of %s failed: %w", url, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("download of %s failed: larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
+
+// releaseChecksum returns the SHA-256 a checksums file ("<hex>  <file>" lines) lists for a file.
+func releaseChecksum(checksums []byte, file string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == file && len(fields[0]) == sha256.Size*2 {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// extractToolBinary returns the file named binary from a release zip or tarball.
+func extractToolBinary(asset string, archive []byte, binary string) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(asset, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", asset, err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || path.Base(f.Name) != binary {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("reading %s from %s: %w", binary, asset, err)
+			}
+			defer rc.Close()
+			data, err := io.ReadAll(io.LimitReader(rc, maxToolArchiveBytes))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s from %s: %w", binary, asset, err)
+			}
+			return data, nil
+		}
+	case strings.HasSuffix(asset, ".tar.gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(archive))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", asset, err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", asset, err)
+			}
+			if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != binary {
+				continue
+			}
+			data, err := io.ReadAll(io.LimitReader(tr, maxToolArchiveBytes))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s from %s: %w", binary, asset, err)
+			}
+			return data, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported release archive %s", asset)
+	}
+	return nil, fmt.Errorf("%s has no %s binary", asset, binary)
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxFaviconBytes bounds the page and icon bodies read while looking for a favicon.
+const maxFaviconBytes = 1 << 20
+
+var (
+	iconLinkRegex = regexp.MustCompile(`(?is)<link\b[^>]*\brel\s*=\s*["']?[^"'>]*\bicon\b[^>]*>`)
+	hrefRegex     = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// FaviconHash returns the hash Shodan indexes favicons by (http.favicon.hash): the 32-bit
+// murmur3 of the base64 encoding with a newline after every 76 characters, as Python's
+// base64.encodebytes produces it.
+func FaviconHash(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	if encoded != "" {
+		b.WriteString(encoded)
+		b.WriteByte('\n')
+	}
+	return int32(murmur3Sum32([]byte(b.String()), 0))
+}
+
+// murmur3Sum32 is MurmurHash3 x86_32.
+func murmur3Sum32(data []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	tail := data[n*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// fetchFavicon loads pageURL, follows its <link rel="icon"> (or falls back to /favicon.ico) and
+// returns the icon's hash and URL.
+func fetchFavicon(ctx context.Context, client *http.Client, pageURL string) (int32, string, error) {
+	page, finalURL, _, err := fetchLimited(ctx, client, pageURL)
+	if err != nil {
+		return 0, "", err
+	}
+	var candidates []string
+	if href := iconHref(page); href != "" && !strings.HasPrefix(strings.ToLower(href), "data:") {
+		if ref, err := url.Parse(href); err == nil {
+			candidates = append(candidates, finalURL.ResolveReference(ref).String())
+		}
+	}
+	candidates = append(candidates, finalURL.ResolveReference(&url.URL{Path: "/favicon.ico"}).String())
+
+	var lastErr error
+	for _, iconURL := range candidates {
+		body, _, contentType, err := fetchLimited(ctx, client, iconURL)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(body) == 0 || strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+			lastErr = fmt.Errorf("no favicon at %s", iconURL)
+			continue
+		}
+		return FaviconHash(body), iconURL, nil
+	}
+	return 0, "", lastErr
+}
+
+// iconHref returns the href of the first icon link of an HTML page.
+func iconHref(page []byte) string {
+	link := iconLinkRegex.Find(page)
+	if link == nil {
+		return ""
+	}
+	m := hrefRegex.FindSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	for _, g := range m[1:] {
+		if len(g) > 0 {
+			return strings.TrimSpace(string(g))
+		}
+	}
+	return ""
+}
+
+// fetchLimited GETs rawURL and returns up to maxFaviconBytes of a 200 response body, the final URL
+// after redirects and the content type.
+func fetchLimited(ctx context.Context, client *http.Client, rawURL string) ([]byte, *url.URL, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("creating request for %s: %w", rawURL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("requesting %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, "", fmt.Errorf("%s returned status %d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBytes))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("reading %s: %w", rawURL, err)
+	}
+	return body, resp.Request.URL, resp.Header.Get("Content-Type"), nil
+}
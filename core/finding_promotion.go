@@ -0,0 +1,201 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+	"unicode/utf8"
+)
+
+// findingEvidenceBodyBytes caps each body quoted in a promoted finding's evidence.
+const findingEvidenceBodyBytes = 8 << 10
+
+// PromoteTrafficToFinding creates a draft finding from a traffic log entry: the title names the
+// vulnerability type and request line, the description holds the URL and the raw request and
+// response as evidence, and the steps to reproduce a curl command. Export-time redaction rules
+// apply to the evidence. The entry must belong to a target.
+func PromoteTrafficToFinding(logID int64, req models.FindingPromotionRequest) (models.TargetFinding, error) {
+	entry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		return models.TargetFinding{}, err
+	}
+	if entry.TargetID == nil {
+		return models.TargetFinding{}, fmt.Errorf("log entry %d has no target; assign it to a target first", logID)
+	}
+	var vulnType *models.VulnerabilityType
+	if req.VulnerabilityTypeID != 0 || strings.TrimSpace(req.VulnerabilityType) != "" {
+		vt, err := FindVulnerabilityType(req.VulnerabilityTypeID, req.VulnerabilityType)
+		if err != nil {
+			return models.TargetFinding{}, err
+		}
+		vulnType = &vt
+	}
+	severity, err := normalizeFindingSeverity(req.Severity)
+	if err != nil {
+		return models.TargetFinding{}, err
+	}
+
+	if rules := EffectiveRedactionRules(entry.TargetID); rules.Enabled && rules.ApplyAt != models.RedactionApplyStorage {
+		RedactTrafficLog(&entry, rules)
+	}
+	method := entry.RequestMethod.String
+	if method == "" {
+		method = http.MethodGet
+	}
+	requestURL := entry.RequestURL.String
+	path := requestURL
+	if u, err := url.Parse(requestURL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = fmt.Sprintf("%s %s", method, path)
+		if vulnType != nil {
+			title = fmt.Sprintf("%s in %s", vulnType.Name, title)
+		}
+	}
+
+	reqHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+	finding := models.TargetFinding{
+		TargetID:         *entry.TargetID,
+		HTTPTrafficLogID: sql.NullInt64{Int64: entry.ID, Valid: true},
+		Title:            title,
+		Description:      models.NullString(findingEvidence(entry, method, reqHeaders)),
+		StepsToReproduce: models.NullString(fmt.Sprintf("1. Send the request:\n\n```sh\n%s\n```\n\n2. Observe the response shown in the description.", BuildCurlCommand(method, requestURL, reqHeaders, entry.RequestBody))),
+		Severity:         models.NullString(severity),
+		Status:           models.FindingStatusDraft,
+	}
+	if len(entry.RequestBody) > 0 && utf8.Valid(entry.RequestBody) {
+		finding.Payload = models.NullString(truncateEvidence(entry.RequestBody))
+	}
+	if vulnType != nil {
+		finding.VulnerabilityTypeID = sql.NullInt64{Int64: vulnType.ID, Valid: true}
+	}
+	id, err := database.CreateTargetFinding(finding)
+	if err != nil {
+		return models.TargetFinding{}, err
+	}
+	logger.Info("PromoteTrafficToFinding: Log entry %d promoted to draft finding %d of target %d", logID, id, finding.TargetID)
+	return database.GetTargetFindingByID(id)
+}
+
+// FindVulnerabilityType returns the vulnerability type with the given ID or, when id is 0, the
+// given name (case-insensitive).
+func FindVulnerabilityType(id int64, name string) (models.VulnerabilityType, error) {
+	if id != 0 {
+		return database.GetVulnerabilityTypeByID(id)
+	}
+	types, err := database.GetAllVulnerabilityTypes()
+	if err != nil {
+		return models.VulnerabilityType{}, err
+	}
+	name = strings.TrimSpace(name)
+	for _, vt := range types {
+		if strings.EqualFold(vt.Name, name) {
+			return vt, nil
+		}
+	}
+	return models.VulnerabilityType{}, fmt.Errorf("vulnerability type '%s' not found", name)
+}
+
+// normalizeFindingSeverity returns the accepted severity matching s (case-insensitive), or "" when s
+// is empty.
+func normalizeFindingSeverity(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	for _, known := range models.FindingSeverities {
+		if strings.EqualFold(s, known) {
+			return known, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity '%s' (use %s)", s, strings.Join(models.FindingSeverities, ", "))
+}
+
+// findingEvidence renders the markdown description of a promoted finding.
+func findingEvidence(entry models.HTTPTrafficLog, method string, reqHeaders http.Header) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Promoted from traffic log entry %d, captured %s.\n\n", entry.ID, entry.Timestamp.UTC().Format("2006-01-02 15:04:05 UTC"))
+	fmt.Fprintf(&b, "- **URL:** `%s`\n- **Method:** `%s`\n", entry.RequestURL.String, method)
+	if entry.ResponseStatusCode != 0 {
+		fmt.Fprintf(&b, "- **Status:** %d", entry.ResponseStatusCode)
+		if entry.ResponseContentType.String != "" {
+			fmt.Fprintf(&b, ", `%s`", entry.ResponseContentType.String)
+		}
+		fmt.Fprintf(&b, ", %d bytes\n", entry.ResponseBodySize)
+	}
+
+	b.WriteString("\n### Request\n\n")
+	rawRequest, err := BuildRawHTTPRequest(method, entry.RequestURL.String, reqHeaders, nil)
+	if err != nil {
+		rawRequest = fmt.Sprintf("%s %s HTTP/1.1\r\n\r\n", method, entry.RequestURL.String)
+	}
+	writeEvidenceBlock(&b, strings.ReplaceAll(rawRequest, "\r\n", "\n")+evidenceBody(entry.RequestBody))
+
+	b.WriteString("\n### Response\n\n")
+	if entry.ResponseStatusCode == 0 {
+		b.WriteString("No response was recorded.\n")
+		return b.String()
+	}
+	version := entry.ResponseHTTPVersion.String
+	if version == "" {
+		version = "HTTP/1.1"
+	}
+	reason := entry.ResponseReasonPhrase.String
+	if reason == "" {
+		reason = http.StatusText(entry.ResponseStatusCode)
+	}
+	var resp strings.Builder
+	fmt.Fprintf(&resp, "%s %d %s\n", version, entry.ResponseStatusCode, reason)
+	respHeaders := HeadersFromJSON(entry.ResponseHeaders.String)
+	for _, name := range sortedExportHeaderNames(respHeaders) {
+		for _, v := range respHeaders[name] {
+			fmt.Fprintf(&resp, "%s: %s\n", name, v)
+		}
+	}
+	resp.WriteString("\n")
+	resp.WriteString(evidenceBody(entry.ResponseBody))
+	if entry.ResponseBodyTruncated {
+		resp.WriteString("\n[body was truncated at capture]")
+	}
+	writeEvidenceBlock(&b, resp.String())
+	return b.String()
+}
+
+// evidenceBody returns a body as text for the evidence, shortened to findingEvidenceBodyBytes.
+func evidenceBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("[binary body, %d bytes]", len(body))
+	}
+	return truncateEvidence(body)
+}
+
+func truncateEvidence(body []byte) string {
+	if len(body) <= findingEvidenceBodyBytes {
+		return string(body)
+	}
+	cut := findingEvidenceBodyBytes
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n[... %d more bytes]", body[:cut], len(body)-cut)
+}
+
+// writeEvidenceBlock writes text as a fenced code block, with a fence longer than any backtick
+// run inside it.
+func writeEvidenceBlock(b *strings.Builder, text string) {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "%shttp\n%s\n%s\n", fence, strings.TrimRight(text, "\n"), fence)
+}
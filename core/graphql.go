@@ -0,0 +1,675 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// graphQLIntrospectionSource is the X-Toolkit-Source value for introspection requests made by the toolkit.
+const graphQLIntrospectionSource = "GraphQL-Introspection"
+
+// graphQLIntrospectionQuery is the standard introspection query (as sent by GraphiQL).
+const graphQLIntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives { name description locations args { ...InputValue } }
+  }
+}
+fragment FullType on __Type {
+  kind name description
+  fields(includeDeprecated: true) { name description args { ...InputValue } type { ...TypeRef } isDeprecated deprecationReason }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+  possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } defaultValue }
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } } }
+}`
+
+// GraphQLRequestOperation is one operation sent in a GraphQL request (a batched request carries several).
+type GraphQLRequestOperation struct {
+	OperationName      string
+	Query              string
+	Variables          string // Raw JSON, empty if none were sent
+	PersistedQueryHash string // Automatic persisted query sha256Hash, if any
+}
+
+// GraphQLDocumentOperation is an operation definition parsed from a GraphQL document.
+type GraphQLDocumentOperation struct {
+	Type                string
+	Name                string
+	VariableDefinitions []models.GraphQLVariableDefinition
+	RootFields          []string
+}
+
+// GraphQLSchemaSummary lists the root types and fields of an introspected schema.
+type GraphQLSchemaSummary struct {
+	QueryType        string   `json:"query_type,omitempty"`
+	MutationType     string   `json:"mutation_type,omitempty"`
+	SubscriptionType string   `json:"subscription_type,omitempty"`
+	TypeCount        int      `json:"type_count"`
+	Queries          []string `json:"queries"`
+	Mutations        []string `json:"mutations"`
+	Subscriptions    []string `json:"subscriptions"`
+}
+
+type graphQLToken struct {
+	kind byte // 'n' name, 'p' punctuator, 's' string, 'v' other value (numbers)
+	text string
+}
+
+// tokenizeGraphQL splits a GraphQL document into tokens, dropping whitespace, commas and comments.
+func tokenizeGraphQL(doc string) []graphQLToken {
+	var tokens []graphQLToken
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			start := i
+			if strings.HasPrefix(doc[i:], `"""`) {
+				end := strings.Index(doc[i+3:], `"""`)
+				if end < 0 {
+					i = len(doc)
+				} else {
+					i += end + 6
+				}
+			} else {
+				i++
+				for i < len(doc) && doc[i] != '"' && doc[i] != '\n' {
+					if doc[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				i++
+			}
+			if i > len(doc) {
+				i = len(doc)
+			}
+			tokens = append(tokens, graphQLToken{'s', doc[start:i]})
+		case c == '.' && strings.HasPrefix(doc[i:], "..."):
+			tokens = append(tokens, graphQLToken{'p', "..."})
+			i += 3
+		case strings.IndexByte("!$&()/:=@[]{}|", c) >= 0:
+			tokens = append(tokens, graphQLToken{'p', string(c)})
+			i++
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(doc) && (doc[i] == '_' || (doc[i] >= 'a' && doc[i] <= 'z') || (doc[i] >= 'A' && doc[i] <= 'Z') || (doc[i] >= '0' && doc[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, graphQLToken{'n', doc[start:i]})
+		default:
+			start := i
+			for i < len(doc) && strings.IndexByte(" \t\n\r,#\"!$&()/:=@[]{}|", doc[i]) < 0 {
+				i++
+			}
+			if i == start {
+				i++
+			}
+			tokens = append(tokens, graphQLToken{'v', doc[start:i]})
+		}
+	}
+	return tokens
+}
+
+// ParseGraphQLDocument returns the operation definitions of a GraphQL document. Fragments are skipped.
+// It is a lightweight parser: enough to name operations and list their variables and root fields,
+// not a validator.
+func ParseGraphQLDocument(doc string) []GraphQLDocumentOperation {
+	tokens := tokenizeGraphQL(doc)
+	var operations []GraphQLDocumentOperation
+	for i := 0; i < len(tokens); {
+		t := tokens[i]
+		switch {
+		case t.kind == 'p' && t.text == "{": // Query shorthand
+			op := GraphQLDocumentOperation{Type: models.GraphQLOperationQuery}
+			op.RootFields, i = parseGraphQLSelectionSet(tokens, i)
+			operations = append(operations, op)
+		case t.kind == 'n' && (t.text == models.GraphQLOperationQuery || t.text == models.GraphQLOperationMutation || t.text == models.GraphQLOperationSubscription):
+			op := GraphQLDocumentOperation{Type: t.text}
+			i++
+			if i < len(tokens) && tokens[i].kind == 'n' {
+				op.Name = tokens[i].text
+				i++
+			}
+			if i < len(tokens) && tokens[i].text == "(" {
+				op.VariableDefinitions, i = parseGraphQLVariableDefinitions(tokens, i)
+			}
+			for i < len(tokens) && tokens[i].text != "{" { // Directives
+				i++
+			}
+			op.RootFields, i = parseGraphQLSelectionSet(tokens, i)
+			operations = append(operations, op)
+		case t.kind == 'n' && t.text == "fragment":
+			for i < len(tokens) && tokens[i].text != "{" {
+				i++
+			}
+			_, i = parseGraphQLSelectionSet(tokens, i)
+		default:
+			// Not a GraphQL document (or a definition we don't track, e.g. schema SDL).
+			if len(operations) == 0 && t.kind != 'n' {
+				return nil
+			}
+			i++
+		}
+	}
+	return operations
+}
+
+// parseGraphQLVariableDefinitions parses "($a: Int = 1, $b: [ID!]!)" starting at the "(" token.
+func parseGraphQLVariableDefinitions(tokens []graphQLToken, i int) ([]models.GraphQLVariableDefinition, int) {
+	defs := []models.GraphQLVariableDefinition{}
+	i++ // "("
+	for i < len(tokens) && tokens[i].text != ")" {
+		if tokens[i].text != "$" || i+1 >= len(tokens) {
+			i++
+			continue
+		}
+		def := models.GraphQLVariableDefinition{Name: tokens[i+1].text}
+		i += 2
+		if i < len(tokens) && tokens[i].text == ":" {
+			i++
+		}
+		var typeParts []string
+		for i < len(tokens) && tokens[i].text != "=" && tokens[i].text != "$" && tokens[i].text != ")" && tokens[i].text != "@" {
+			typeParts = append(typeParts, tokens[i].text)
+			i++
+		}
+		def.Type = strings.Join(typeParts, "")
+		if i < len(tokens) && tokens[i].text == "=" {
+			i++
+			var defaultParts []string
+			depth := 0
+			for i < len(tokens) && (depth > 0 || (tokens[i].text != "$" && tokens[i].text != ")" && tokens[i].text != "@")) {
+				switch tokens[i].text {
+				case "[", "{":
+					depth++
+				case "]", "}":
+					depth--
+				}
+				defaultParts = append(defaultParts, tokens[i].text)
+				i++
+			}
+			def.DefaultValue = strings.Join(defaultParts, " ")
+		}
+		for i < len(tokens) && tokens[i].text != "$" && tokens[i].text != ")" { // Variable directives
+			i++
+		}
+		defs = append(defs, def)
+	}
+	return defs, i + 1
+}
+
+// parseGraphQLSelectionSet returns the field names (not aliases) at the top level of the selection set
+// starting at the "{" token, and the index after its closing "}".
+func parseGraphQLSelectionSet(tokens []graphQLToken, i int) ([]string, int) {
+	fields := []string{}
+	braces, parens := 0, 0
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == 'p' {
+			switch t.text {
+			case "{":
+				braces++
+			case "}":
+				braces--
+				if braces == 0 {
+					return fields, i + 1
+				}
+			case "(":
+				parens++
+			case ")":
+				parens--
+			case "...", "@", "$":
+				i++ // Skip the fragment name, "on", directive or variable name that follows
+			}
+			continue
+		}
+		if braces != 1 || parens != 0 || t.kind != 'n' {
+			continue
+		}
+		if i+2 < len(tokens) && tokens[i+1].text == ":" && tokens[i+2].kind == 'n' { // alias: field
+			i += 2
+			t = tokens[i]
+		}
+		fields = append(fields, t.text)
+	}
+	return fields, i
+}
+
+// ParseGraphQLRequest extracts the GraphQL operations sent in an HTTP request: JSON bodies
+// (single or batched), application/graphql bodies and GET requests with a query parameter.
+// It returns nil when the request is not GraphQL.
+func ParseGraphQLRequest(method, rawURL string, headers http.Header, body []byte) []GraphQLRequestOperation {
+	var ops []GraphQLRequestOperation
+	if method == http.MethodGet {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil
+		}
+		q := u.Query()
+		op := GraphQLRequestOperation{
+			OperationName:      q.Get("operationName"),
+			Query:              q.Get("query"),
+			Variables:          q.Get("variables"),
+			PersistedQueryHash: gjson.Get(q.Get("extensions"), "persistedQuery.sha256Hash").String(),
+		}
+		ops = append(ops, op)
+	} else {
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) == 0 {
+			return nil
+		}
+		if strings.Contains(strings.ToLower(headers.Get("Content-Type")), "application/graphql") {
+			ops = append(ops, GraphQLRequestOperation{Query: string(trimmed)})
+		} else if trimmed[0] == '{' || trimmed[0] == '[' {
+			if !gjson.ValidBytes(trimmed) {
+				return nil
+			}
+			parsed := gjson.ParseBytes(trimmed)
+			items := []gjson.Result{parsed}
+			if parsed.IsArray() {
+				items = parsed.Array()
+			}
+			for _, item := range items {
+				if !item.IsObject() {
+					return nil
+				}
+				op := GraphQLRequestOperation{
+					OperationName:      item.Get("operationName").String(),
+					Query:              item.Get("query").String(),
+					PersistedQueryHash: item.Get("extensions.persistedQuery.sha256Hash").String(),
+				}
+				if v := item.Get("variables"); v.Exists() && v.Type != gjson.Null {
+					op.Variables = v.Raw
+				}
+				ops = append(ops, op)
+			}
+		}
+	}
+
+	// Keep only operations that really look like GraphQL.
+	valid := ops[:0]
+	for _, op := range ops {
+		if op.Query != "" && len(ParseGraphQLDocument(op.Query)) > 0 {
+			valid = append(valid, op)
+		} else if op.Query == "" && len(op.PersistedQueryHash) == 64 {
+			valid = append(valid, op)
+		}
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+	return valid
+}
+
+// selectGraphQLOperation picks the operation of a document that a request executes.
+func selectGraphQLOperation(doc []GraphQLDocumentOperation, operationName string) GraphQLDocumentOperation {
+	for _, op := range doc {
+		if operationName != "" && op.Name == operationName {
+			return op
+		}
+	}
+	if len(doc) > 0 {
+		return doc[0]
+	}
+	return GraphQLDocumentOperation{Type: models.GraphQLOperationQuery, Name: operationName}
+}
+
+// graphQLQueryHash hashes a query with insignificant whitespace, commas and comments removed,
+// so reformatted copies of the same operation are stored once.
+func graphQLQueryHash(query string) string {
+	tokens := tokenizeGraphQL(query)
+	parts := make([]string, len(tokens))
+	for i, t := range tokens {
+		parts[i] = t.text
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// graphQLEndpointURL is the endpoint URL without query string or fragment.
+func graphQLEndpointURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
+// CaptureGraphQLFromLog parses the GraphQL operations of a logged request into graphql_operations.
+// When the request was an introspection query and the response carries the schema, the schema is
+// stored on the endpoint too. It returns the number of operations recorded.
+func CaptureGraphQLFromLog(logEntry *models.HTTPTrafficLog) (int, error) {
+	requestHeaders := HeadersFromJSON(logEntry.RequestHeaders.String)
+	body, err := DecodeContentEncoding(logEntry.RequestBody, requestHeaders.Get("Content-Encoding"))
+	if err != nil {
+		logger.Debug("GraphQL capture: Could not decode request body of log %d: %v", logEntry.ID, err)
+	}
+	requestOps := ParseGraphQLRequest(logEntry.RequestMethod.String, logEntry.RequestURL.String, requestHeaders, body)
+	if len(requestOps) == 0 {
+		return 0, nil
+	}
+
+	var targetID sql.NullInt64
+	if logEntry.TargetID != nil {
+		targetID = sql.NullInt64{Int64: *logEntry.TargetID, Valid: true}
+	}
+	endpointID, err := database.GetOrCreateGraphQLEndpoint(targetID, graphQLEndpointURL(logEntry.RequestURL.String))
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	introspection := false
+	for _, reqOp := range requestOps {
+		docOp := selectGraphQLOperation(ParseGraphQLDocument(reqOp.Query), reqOp.OperationName)
+		op := models.GraphQLOperation{
+			GraphQLEndpointID:    endpointID,
+			TargetID:             targetID,
+			OperationType:        docOp.Type,
+			OperationName:        docOp.Name,
+			Query:                reqOp.Query,
+			PersistedQueryHash:   models.NullString(reqOp.PersistedQueryHash),
+			Variables:            models.NullString(reqOp.Variables),
+			LastHTTPTrafficLogID: sql.NullInt64{Int64: logEntry.ID, Valid: logEntry.ID != 0},
+		}
+		if reqOp.OperationName != "" {
+			op.OperationName = reqOp.OperationName
+		}
+		if reqOp.Query != "" {
+			op.QueryHash = graphQLQueryHash(reqOp.Query)
+		} else {
+			op.QueryHash = "apq:" + reqOp.PersistedQueryHash
+		}
+		if _, err := database.RecordGraphQLOperation(op); err != nil {
+			return recorded, err
+		}
+		recorded++
+		if strings.Contains(reqOp.Query, "__schema") {
+			introspection = true
+		}
+	}
+
+	if introspection && requestHeaders.Get("X-Toolkit-Source") != graphQLIntrospectionSource {
+		responseHeaders := HeadersFromJSON(logEntry.ResponseHeaders.String)
+		respBody, _ := DecodeContentEncoding(logEntry.ResponseBody, responseHeaders.Get("Content-Encoding"))
+		if data := gjson.GetBytes(respBody, "data"); data.Get("__schema.types").IsArray() {
+			if err := database.StoreGraphQLSchema(endpointID, data.Raw, "captured"); err != nil {
+				logger.Error("GraphQL capture: %v", err)
+			} else {
+				logger.Info("GraphQL capture: Stored introspection schema seen in log %d for endpoint %d", logEntry.ID, endpointID)
+			}
+		}
+	}
+	return recorded, nil
+}
+
+// IntrospectGraphQLEndpoint sends the introspection query to an endpoint through the proxy and stores the schema.
+func IntrospectGraphQLEndpoint(endpointID int64, options models.GraphQLIntrospectRequest) (GraphQLSchemaSummary, error) {
+	endpoint, err := database.GetGraphQLEndpointByID(endpointID)
+	if err != nil {
+		return GraphQLSchemaSummary{}, err
+	}
+	fail := func(err error) (GraphQLSchemaSummary, error) {
+		if errStore := database.SetGraphQLIntrospectionError(endpointID, err.Error()); errStore != nil {
+			logger.Error("IntrospectGraphQLEndpoint: %v", errStore)
+		}
+		return GraphQLSchemaSummary{}, err
+	}
+
+	payload, _ := json.Marshal(map[string]string{"operationName": "IntrospectionQuery", "query": graphQLIntrospectionQuery})
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fail(fmt.Errorf("creating introspection request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if err := applyGraphQLSessionHeaders(req.Header, options.SessionID, options.Headers); err != nil {
+		return GraphQLSchemaSummary{}, err
+	}
+	if endpoint.TargetID.Valid {
+		ApplyClientProfile(req, endpoint.TargetID.Int64)
+	}
+	req.Header.Set("X-Toolkit-Initiated", "true")
+	req.Header.Set("X-Toolkit-Source", graphQLIntrospectionSource)
+	req.Header.Set("X-Toolkit-Full-URL", endpoint.URL)
+
+	client, err := newProxyClient(endpoint.TargetID.Int64)
+	if err != nil {
+		return fail(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(fmt.Errorf("sending introspection request: %w", err))
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024*1024))
+	if err != nil {
+		return fail(fmt.Errorf("reading introspection response: %w", err))
+	}
+
+	data := gjson.GetBytes(respBody, "data")
+	if !data.Get("__schema.types").IsArray() {
+		if msgs := gjson.GetBytes(respBody, "errors.#.message").Array(); len(msgs) > 0 {
+			return fail(fmt.Errorf("introspection returned errors (status %d): %s", resp.StatusCode, msgs[0].String()))
+		}
+		return fail(fmt.Errorf("introspection response (status %d) contains no schema", resp.StatusCode))
+	}
+	if err := database.StoreGraphQLSchema(endpointID, data.Raw, "introspection"); err != nil {
+		return GraphQLSchemaSummary{}, err
+	}
+	logger.Info("IntrospectGraphQLEndpoint: Stored schema for endpoint %d (%s)", endpointID, endpoint.URL)
+	return SummarizeGraphQLSchema(data.Raw)
+}
+
+// applyGraphQLSessionHeaders sets the cookies/headers of a replay session, then the explicit headers.
+func applyGraphQLSessionHeaders(headers http.Header, sessionID int64, extra map[string]string) error {
+	if sessionID != 0 {
+		session, err := database.GetReplaySessionByID(sessionID)
+		if err != nil {
+			return err
+		}
+		for k, v := range session.Headers {
+			headers.Set(k, v)
+		}
+		if session.Cookies != "" {
+			headers.Set("Cookie", session.Cookies)
+		}
+	}
+	for k, v := range extra {
+		headers.Set(k, v)
+	}
+	return nil
+}
+
+// SummarizeGraphQLSchema lists the root types and their fields from stored introspection JSON
+// ({"__schema": ...}, optionally wrapped in "data").
+func SummarizeGraphQLSchema(schemaJSON string) (GraphQLSchemaSummary, error) {
+	summary := GraphQLSchemaSummary{Queries: []string{}, Mutations: []string{}, Subscriptions: []string{}}
+	schema := gjson.Get(schemaJSON, "__schema")
+	if !schema.Exists() {
+		schema = gjson.Get(schemaJSON, "data.__schema")
+	}
+	if !schema.Exists() {
+		return summary, fmt.Errorf("no __schema in introspection result")
+	}
+	summary.QueryType = schema.Get("queryType.name").String()
+	summary.MutationType = schema.Get("mutationType.name").String()
+	summary.SubscriptionType = schema.Get("subscriptionType.name").String()
+
+	types := schema.Get("types").Array()
+	summary.TypeCount = len(types)
+	fieldsOf := func(typeName string) []string {
+		names := []string{}
+		if typeName == "" {
+			return names
+		}
+		for _, t := range types {
+			if t.Get("name").String() == typeName {
+				for _, f := range t.Get("fields").Array() {
+					names = append(names, f.Get("name").String())
+				}
+				break
+			}
+		}
+		return names
+	}
+	summary.Queries = fieldsOf(summary.QueryType)
+	summary.Mutations = fieldsOf(summary.MutationType)
+	summary.Subscriptions = fieldsOf(summary.SubscriptionType)
+	return summary, nil
+}
+
+// GetGraphQLOperationDetail returns an operation with its parsed variable definitions and root fields.
+func GetGraphQLOperationDetail(operationID int64) (models.GraphQLOperationDetail, error) {
+	op, err := database.GetGraphQLOperationByID(operationID)
+	if err != nil {
+		return models.GraphQLOperationDetail{}, err
+	}
+	docOp := selectGraphQLOperation(ParseGraphQLDocument(op.Query), op.OperationName)
+	detail := models.GraphQLOperationDetail{GraphQLOperation: op, VariableDefinitions: docOp.VariableDefinitions, RootFields: docOp.RootFields}
+	if detail.VariableDefinitions == nil {
+		detail.VariableDefinitions = []models.GraphQLVariableDefinition{}
+	}
+	if detail.RootFields == nil {
+		detail.RootFields = []string{}
+	}
+	return detail, nil
+}
+
+// graphQLVariablesTemplate builds placeholder variables for an operation's non-null variables.
+func graphQLVariablesTemplate(defs []models.GraphQLVariableDefinition) map[string]interface{} {
+	vars := make(map[string]interface{})
+	for _, def := range defs {
+		if !strings.HasSuffix(def.Type, "!") || def.DefaultValue != "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(def.Type, "["):
+			vars[def.Name] = []interface{}{}
+		case strings.HasPrefix(def.Type, "Int") || strings.HasPrefix(def.Type, "Float"):
+			vars[def.Name] = 0
+		case strings.HasPrefix(def.Type, "Boolean"):
+			vars[def.Name] = false
+		case strings.HasPrefix(def.Type, "String") || strings.HasPrefix(def.Type, "ID"):
+			vars[def.Name] = ""
+		default: // Input objects and custom scalars
+			vars[def.Name] = nil
+		}
+	}
+	return vars
+}
+
+// BuildGraphQLModifierTask creates a modifier task that sends a stored operation as a JSON POST.
+// Headers come from the last request that carried the operation (without hop-by-hop headers),
+// optionally overridden by a replay session and explicit headers. Variables default to the latest
+// ones seen, or to placeholders for the operation's required variables.
+func BuildGraphQLModifierTask(operationID int64, options models.GraphQLBuildTaskRequest) (*models.ModifierTask, error) {
+	detail, err := GetGraphQLOperationDetail(operationID)
+	if err != nil {
+		return nil, err
+	}
+	query := detail.Query
+	if options.Query != "" {
+		query = options.Query
+		docOp := selectGraphQLOperation(ParseGraphQLDocument(query), options.OperationName)
+		detail.VariableDefinitions = docOp.VariableDefinitions
+	}
+	if query == "" {
+		return nil, fmt.Errorf("operation %d is a persisted query without query text; provide 'query'", operationID)
+	}
+	operationName := detail.OperationName
+	if options.OperationName != "" {
+		operationName = options.OperationName
+	}
+
+	var variables json.RawMessage
+	switch {
+	case len(options.Variables) > 0:
+		if !gjson.ValidBytes(options.Variables) || !gjson.ParseBytes(options.Variables).IsObject() {
+			return nil, fmt.Errorf("variables must be a JSON object")
+		}
+		variables = options.Variables
+	case detail.Variables.Valid && detail.Variables.String != "":
+		variables = json.RawMessage(detail.Variables.String)
+	default:
+		variables, _ = json.Marshal(graphQLVariablesTemplate(detail.VariableDefinitions))
+	}
+
+	body, err := json.Marshal(struct {
+		OperationName string          `json:"operationName,omitempty"`
+		Query         string          `json:"query"`
+		Variables     json.RawMessage `json:"variables"`
+	}{operationName, query, variables})
+	if err != nil {
+		return nil, fmt.Errorf("encoding request body: %w", err)
+	}
+
+	headers := http.Header{}
+	if detail.LastHTTPTrafficLogID.Valid {
+		if logEntry, err := database.GetHTTPTrafficLogEntryByID(detail.LastHTTPTrafficLogID.Int64); err == nil {
+			for k, vals := range HeadersFromJSON(logEntry.RequestHeaders.String) {
+				canonical := http.CanonicalHeaderKey(k)
+				if replaySkippedHeaders[canonical] || isToolkitHeader(canonical) || canonical == "Content-Encoding" {
+					continue
+				}
+				headers[canonical] = vals
+			}
+		}
+	}
+	headers.Set("Content-Type", "application/json")
+	if err := applyGraphQLSessionHeaders(headers, options.SessionID, options.Headers); err != nil {
+		return nil, err
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("encoding headers: %w", err)
+	}
+
+	name := options.Name
+	if name == "" {
+		label := operationName
+		if label == "" {
+			label = "(anonymous)"
+		}
+		name = fmt.Sprintf("GraphQL %s %s", detail.OperationType, label)
+	}
+	task := models.ModifierTask{
+		TargetID:           detail.TargetID,
+		Name:               name,
+		BaseRequestMethod:  http.MethodPost,
+		BaseRequestURL:     detail.EndpointURL,
+		BaseRequestHeaders: models.NullString(string(headersJSON)),
+		BaseRequestBody:    models.NullString(base64.StdEncoding.EncodeToString(body)),
+	}
+	return database.CreateModifierTask(task)
+}
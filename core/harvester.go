@@ -0,0 +1,433 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxHarvestBytes bounds each robots.txt, sitemap or security.txt body read by the harvester.
+const maxHarvestBytes = 5 << 20
+
+// harvester runs the fetches of one harvest job.
+type harvester struct {
+	client *http.Client // Certificates are not verified, as in httpx
+	conf   config.HarvesterConfig
+}
+
+// harvestedFile is one response fetched by the harvester.
+type harvestedFile struct {
+	url         string
+	status      int
+	body        []byte
+	contentType string
+	headers     http.Header
+	logID       int64 // http_traffic_log row the response was saved to
+}
+
+// isText reports whether the response is a 200 that is not an HTML page (soft 404s and login
+// redirects usually are) nor one of the domain's known soft-404 pages.
+func (f *harvestedFile) isText(softNotFound *SoftNotFoundFilter) bool {
+	if f == nil || f.status != http.StatusOK || len(f.body) == 0 {
+		return false
+	}
+	if softNotFound.Matches(f.url, f.status, f.headers, f.body) {
+		return false
+	}
+	if strings.Contains(strings.ToLower(f.contentType), "html") {
+		return false
+	}
+	return !bytes.HasPrefix(bytes.ToLower(bytes.TrimSpace(f.body)), []byte("<!doctype html")) &&
+		!bytes.HasPrefix(bytes.ToLower(bytes.TrimSpace(f.body)), []byte("<html"))
+}
+
+// StartHarvest starts a job fetching robots.txt, the sitemaps and security.txt of a target's live
+// domains. Disallowed paths and sitemap URLs are added to the discovered URL inventory; the rest
+// is stored per domain.
+func StartHarvest(targetID int64, req models.HarvestRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	domains, err := domainsToEnrich(targetID, req.DomainIDs)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(domains) == 0 {
+		return models.Job{}, fmt.Errorf("no domains to harvest (run httpx first or pass domain_ids)")
+	}
+	latest, err := LatestJob(models.JobTypeHarvest, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a harvest job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	conf := config.AppConfig.Harvester
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	h := &harvester{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, targetID)),
+		},
+		conf: conf,
+	}
+	return StartJob(models.JobTypeHarvest, &targetID, fmt.Sprintf("Harvesting robots.txt, sitemaps and security.txt of %d domains...", len(domains)), func(ctx context.Context, job *JobHandle) error {
+		return h.run(ctx, job, domains)
+	})
+}
+
+func (h *harvester) run(ctx context.Context, job *JobHandle, domains []models.Domain) error {
+	job.SetTotal(int64(len(domains)))
+	workers := h.conf.Workers
+	if workers <= 0 {
+		workers = 5
+	}
+	var mu sync.Mutex
+	urlsAdded := 0
+	domainCh := make(chan models.Domain)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range domainCh {
+				added, err := h.harvestDomain(ctx, d)
+				mu.Lock()
+				urlsAdded += added
+				mu.Unlock()
+				if err != nil {
+					job.RecordError(err)
+					job.AddProgress(1, 0)
+				} else {
+					job.AddProgress(1, 1)
+				}
+			}
+		}()
+	}
+feed:
+	for _, d := range domains {
+		select {
+		case <-ctx.Done():
+			break feed
+		case domainCh <- d:
+		}
+	}
+	close(domainCh)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	snap := job.Snapshot()
+	job.SetMessage("Harvested %d of %d domains, %d new URLs (%d errors).", snap.ItemsSucceeded, len(domains), urlsAdded, snap.ErrorCount)
+	return nil
+}
+
+// domainBaseURL returns the origin httpx reached the domain at, or https://<domain>.
+func domainBaseURL(d models.Domain) string {
+	if u, err := url.Parse(gjson.Get(d.HttpxFullJson.String, "url").String()); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return "https://" + d.DomainName
+}
+
+// harvestDomain fetches and stores the files of one domain. It returns how many URLs were added to
+// the discovered URL inventory.
+func (h *harvester) harvestDomain(ctx context.Context, d models.Domain) (int, error) {
+	base := domainBaseURL(d)
+	result := models.DomainHarvest{DomainID: d.ID, TargetID: d.TargetID, BaseURL: base}
+	added := 0
+	softNotFound, err := LoadSoftNotFoundFilter(d.ID)
+	if err != nil {
+		logger.Debug("Harvester: No soft-404 filter for %s: %v", base, err)
+	}
+	rules, err := database.GetScopeRulesByTargetID(d.TargetID)
+	if err != nil {
+		return 0, err
+	}
+	addURLs := func(f *harvestedFile, sourceType string, urls []string) {
+		n, err := database.InsertDiscoveredURLs(d.TargetID, f.logID, sourceType, canonicalURLEntries(urls, f.url, rules))
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		added += n
+	}
+
+	var sitemapQueue []string
+	robots, err := h.fetch(ctx, d.TargetID, base+"/robots.txt")
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.RobotsStatus = &robots.status
+	}
+	if robots.isText(softNotFound) {
+		result.RobotsTxt = string(robots.body)
+		var disallowURLs []string
+		result.DisallowedPaths, disallowURLs, sitemapQueue = parseRobotsTxt(base, robots.body)
+		addURLs(robots, models.DiscoveredSourceRobotsDisallow, disallowURLs)
+		addURLs(robots, models.DiscoveredSourceRobotsSitemap, sitemapQueue)
+	}
+	if ctx.Err() != nil {
+		return added, ctx.Err()
+	}
+
+	sitemapQueue = append(sitemapQueue, base+"/sitemap.xml")
+	seen := make(map[string]bool)
+	for len(sitemapQueue) > 0 && len(seen) < h.maxSitemaps() && ctx.Err() == nil {
+		sitemapURL := sitemapQueue[0]
+		sitemapQueue = sitemapQueue[1:]
+		if seen[sitemapURL] {
+			continue
+		}
+		seen[sitemapURL] = true
+		f, err := h.fetch(ctx, d.TargetID, sitemapURL)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		if !f.isText(softNotFound) {
+			continue
+		}
+		pageURLs, children, err := parseSitemap(f.body)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("parsing sitemap %s: %v", sitemapURL, err))
+			continue
+		}
+		result.Sitemaps = append(result.Sitemaps, sitemapURL)
+		sitemapQueue = append(sitemapQueue, children...)
+		if room := h.maxSitemapURLs() - result.SitemapURLCount; len(pageURLs) > room {
+			pageURLs = pageURLs[:max(room, 0)]
+		}
+		result.SitemapURLCount += len(pageURLs)
+		addURLs(f, models.DiscoveredSourceSitemap, pageURLs)
+	}
+	if ctx.Err() != nil {
+		return added, ctx.Err()
+	}
+
+	for _, path := range []string{"/.well-known/security.txt", "/security.txt"} {
+		f, err := h.fetch(ctx, d.TargetID, base+path)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		if !f.isText(softNotFound) {
+			continue
+		}
+		result.SecurityTxtURL = f.url
+		result.SecurityTxt = string(f.body)
+		result.SecurityContacts, result.SecurityFields = parseSecurityTxt(f.body)
+		break
+	}
+
+	if err := database.SaveDomainHarvest(result); err != nil {
+		return added, err
+	}
+	if result.RobotsStatus == nil && len(result.Errors) > 0 {
+		// The host did not answer at all; the job reports it rather than counting it as harvested.
+		return added, fmt.Errorf("harvesting %s: %s", base, result.Errors[0])
+	}
+	logger.Debug("Harvester: %s: %d disallowed paths, %d sitemap URLs, %d security contacts", base, len(result.DisallowedPaths), result.SitemapURLCount, len(result.SecurityContacts))
+	return added, nil
+}
+
+func (h *harvester) maxSitemaps() int {
+	if h.conf.MaxSitemaps <= 0 {
+		return 10
+	}
+	return h.conf.MaxSitemaps
+}
+
+func (h *harvester) maxSitemapURLs() int {
+	if h.conf.MaxSitemapURLs <= 0 {
+		return 5000
+	}
+	return h.conf.MaxSitemapURLs
+}
+
+// fetch GETs rawURL and saves the response to http_traffic_log. Gzipped sitemaps are decompressed.
+func (h *harvester) fetch(ctx context.Context, targetID int64, rawURL string) (*harvestedFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %w", rawURL, err)
+	}
+	start := time.Now()
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHarvestBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", rawURL, err)
+	}
+	headers, _ := json.Marshal(resp.Header)
+	logEntry := &models.HTTPTrafficLog{
+		TargetID:            &targetID,
+		Timestamp:           start,
+		RequestMethod:       models.NullString(http.MethodGet),
+		RequestURL:          models.NullString(rawURL),
+		ResponseStatusCode:  resp.StatusCode,
+		ResponseHeaders:     models.NullString(string(headers)),
+		ResponseBody:        body,
+		ResponseContentType: models.NullString(resp.Header.Get("Content-Type")),
+		ResponseBodySize:    int64(len(body)),
+		DurationMs:          time.Since(start).Milliseconds(),
+		IsHTTPS:             req.URL.Scheme == "https",
+	}
+	RedactForStorage(logEntry)
+	logID, err := database.LogHarvestedResponse(logEntry)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		if zr, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if unzipped, err := io.ReadAll(io.LimitReader(zr, maxHarvestBytes)); err == nil {
+				body = unzipped
+			}
+		}
+	}
+	return &harvestedFile{url: rawURL, status: resp.StatusCode, body: body, contentType: resp.Header.Get("Content-Type"), headers: resp.Header, logID: logID}, nil
+}
+
+// parseRobotsTxt returns the Disallow paths of a robots.txt as written, the URLs they map to under
+// base (wildcards and $ anchors cut off), and the Sitemap URLs it lists.
+func parseRobotsTxt(base string, body []byte) (paths, urls, sitemaps []string) {
+	seenURL := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "disallow":
+			if !containsString(paths, value) {
+				paths = append(paths, value)
+			}
+			path := value
+			if i := strings.IndexAny(path, "*$"); i >= 0 {
+				path = path[:i]
+			}
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			if u := base + path; !seenURL[u] {
+				seenURL[u] = true
+				urls = append(urls, u)
+			}
+		case "sitemap":
+			if u, err := url.Parse(value); err == nil && u.IsAbs() && !containsString(sitemaps, value) {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+	return paths, urls, sitemaps
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents.
+type sitemapDocument struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// parseSitemap returns the page URLs and child sitemap URLs of an XML sitemap or sitemap index.
+// Plain text sitemaps (one URL per line) are accepted too.
+func parseSitemap(body []byte) (pages, children []string, err error) {
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+				pages = append(pages, line)
+			}
+		}
+		if len(pages) == 0 {
+			return nil, nil, fmt.Errorf("not a sitemap")
+		}
+		return pages, nil, nil
+	}
+	var doc sitemapDocument
+	if err := xml.Unmarshal(trimmed, &doc); err != nil {
+		return nil, nil, err
+	}
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			pages = append(pages, loc)
+		}
+	}
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			children = append(children, loc)
+		}
+	}
+	return pages, children, nil
+}
+
+// parseSecurityTxt returns the Contact values of a security.txt (RFC 9116) and its other fields.
+// Comments and the PGP signature wrapping a signed file are skipped.
+func parseSecurityTxt(body []byte) ([]string, map[string]string) {
+	var contacts []string
+	fields := make(map[string]string)
+	inSignature := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "-----BEGIN PGP SIGNATURE"):
+			inSignature = true
+			continue
+		case strings.HasPrefix(line, "-----END PGP SIGNATURE"):
+			inSignature = false
+			continue
+		case inSignature, line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, "-----"):
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" || strings.ContainsAny(name, " \t") || strings.EqualFold(name, "Hash") {
+			continue
+		}
+		if strings.EqualFold(name, "Contact") {
+			if !containsString(contacts, value) {
+				contacts = append(contacts, value)
+			}
+			continue
+		}
+		if prev, exists := fields[name]; exists {
+			fields[name] = prev + ", " + value
+		} else {
+			fields[name] = value
+		}
+	}
+	return contacts, fields
+}
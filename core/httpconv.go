@@ -0,0 +1,492 @@
+package core
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParsedHTTPRequest is a request parsed from a curl command or a raw HTTP message.
+type ParsedHTTPRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+// exportSkippedHeaders are left out of exported requests; they are recomputed by the client.
+var exportSkippedHeaders = map[string]bool{
+	"Content-Length":   true,
+	"Connection":       true,
+	"Proxy-Connection": true,
+}
+
+// HeadersFromJSON decodes headers stored as JSON (map[string][]string), as saved in
+// http_traffic_log and modifier_tasks.
+func HeadersFromJSON(headersJSON string) http.Header {
+	headers := http.Header{}
+	if strings.TrimSpace(headersJSON) == "" {
+		return headers
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal([]byte(headersJSON), &raw); err != nil {
+		return headers
+	}
+	for k, values := range raw {
+		for _, v := range values {
+			headers.Add(k, v)
+		}
+	}
+	return headers
+}
+
+// DecodeStoredBody returns the bytes of a body stored as base64 (ModifierTask bodies),
+// falling back to the raw string when it is not valid base64.
+func DecodeStoredBody(stored string) []byte {
+	if stored == "" {
+		return nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(stored); err == nil {
+		return decoded
+	}
+	return []byte(stored)
+}
+
+func sortedExportHeaderNames(headers http.Header) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		canonical := http.CanonicalHeaderKey(name)
+		if exportSkippedHeaders[canonical] || strings.HasPrefix(canonical, "X-Toolkit-") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildCurlCommand renders a request as a copy-pasteable curl command.
+func BuildCurlCommand(method, rawURL string, headers http.Header, body []byte) string {
+	var b strings.Builder
+	b.WriteString("curl")
+	if method == "" {
+		method = http.MethodGet
+	}
+	// curl defaults to GET, or POST when data is given
+	impliedMethod := http.MethodGet
+	if len(body) > 0 {
+		impliedMethod = http.MethodPost
+	}
+	if method != impliedMethod {
+		b.WriteString(" -X " + method)
+	}
+	b.WriteString(" " + shellQuote([]byte(rawURL)))
+	for _, name := range sortedExportHeaderNames(headers) {
+		for _, v := range headers[name] {
+			b.WriteString(" \\\n  -H " + shellQuote([]byte(name+": "+v)))
+		}
+	}
+	if len(body) > 0 {
+		b.WriteString(" \\\n  --data-binary " + shellQuote(body))
+	}
+	return b.String()
+}
+
+// BuildRawHTTPRequest renders a request as a raw HTTP/1.1 message.
+func BuildRawHTTPRequest(method, rawURL string, headers http.Header, body []byte) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL '%s': %w", rawURL, err)
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	target := u.RequestURI()
+	if u.Fragment != "" {
+		target += "#" + u.Fragment
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, target)
+	if headers.Get("Host") == "" && u.Host != "" {
+		fmt.Fprintf(&b, "Host: %s\r\n", u.Host)
+	}
+	for _, name := range sortedExportHeaderNames(headers) {
+		for _, v := range headers[name] {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, v)
+		}
+	}
+	if len(body) > 0 {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.String(), nil
+}
+
+// shellQuote quotes s for POSIX shells. Printable text uses single quotes; anything with
+// control or non-UTF-8 bytes uses ANSI-C $'...' quoting so it survives copy and paste.
+func shellQuote(s []byte) string {
+	printable := utf8.Valid(s)
+	if printable {
+		for _, c := range s {
+			if c < 0x20 && c != '\n' && c != '\t' || c == 0x7f {
+				printable = false
+				break
+			}
+		}
+	}
+	if printable {
+		return "'" + strings.ReplaceAll(string(s), "'", `'\''`) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("$'")
+	for _, c := range s {
+		switch {
+		case c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// splitShellWords tokenizes a shell command line, handling single, double and $'...' quotes,
+// backslash escapes and line continuations (including the "^" continuations of cmd.exe copies).
+func splitShellWords(cmd string) ([]string, error) {
+	cmd = strings.ReplaceAll(cmd, "\r\n", "\n")
+	cmd = strings.ReplaceAll(cmd, "\\\n", " ")
+	cmd = strings.ReplaceAll(cmd, "^\n", " ")
+
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	runes := []rune(cmd)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			inWord = true
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			cur.WriteString(string(runes[i+1 : end]))
+			i = end
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			inWord = true
+			i += 2
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					closed = true
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						cur.WriteByte('\n')
+					case 'r':
+						cur.WriteByte('\r')
+					case 't':
+						cur.WriteByte('\t')
+					case 'x':
+						var v byte
+						n := 0
+						for n < 2 && i+1 < len(runes) && strings.ContainsRune("0123456789abcdefABCDEF", runes[i+1]) {
+							i++
+							v = v*16 + hexVal(runes[i])
+							n++
+						}
+						cur.WriteByte(v)
+					default:
+						cur.WriteRune(runes[i])
+					}
+					continue
+				}
+				cur.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated $' quote")
+			}
+		case c == '"':
+			inWord = true
+			i++
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '"' {
+					closed = true
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				cur.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+		case c == '\\' && i+1 < len(runes):
+			inWord = true
+			i++
+			cur.WriteRune(runes[i])
+		default:
+			inWord = true
+			cur.WriteRune(c)
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+func hexVal(r rune) byte {
+	switch {
+	case r >= '0' && r <= '9':
+		return byte(r - '0')
+	case r >= 'a' && r <= 'f':
+		return byte(r-'a') + 10
+	case r >= 'A' && r <= 'F':
+		return byte(r-'A') + 10
+	}
+	return 0
+}
+
+// curlFlagsWithValue lists curl options that take an argument but are otherwise ignored.
+var curlFlagsWithValue = map[string]bool{
+	"-o": true, "--output": true, "-x": true, "--proxy": true, "-m": true, "--max-time": true,
+	"--connect-timeout": true, "-w": true, "--write-out": true, "--retry": true, "-c": true, "--cookie-jar": true,
+	"--resolve": true, "--cacert": true, "--cert": true, "--key": true, "-U": true, "--proxy-user": true,
+}
+
+// ParseCurlCommand parses a curl command line (as produced by browser "Copy as cURL") into a request.
+func ParseCurlCommand(cmd string) (*ParsedHTTPRequest, error) {
+	words, err := splitShellWords(strings.TrimSpace(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("parsing curl command: %w", err)
+	}
+	if len(words) == 0 || (words[0] != "curl" && !strings.HasSuffix(words[0], "/curl") && words[0] != "curl.exe") {
+		return nil, fmt.Errorf("input does not start with 'curl'")
+	}
+
+	req := &ParsedHTTPRequest{Headers: http.Header{}}
+	var dataParts []string
+	getWithData := false
+	head := false
+
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		// Attached short-option values, e.g. -XPOST or -H'Accept: */*'
+		attached := ""
+		if len(w) > 2 && w[0] == '-' && w[1] != '-' && strings.ContainsRune("XHdbAeu", rune(w[1])) {
+			attached = w[2:]
+			w = w[:2]
+		}
+		next := func() (string, error) {
+			if attached != "" {
+				return attached, nil
+			}
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("option %s requires a value", w)
+			}
+			i++
+			return words[i], nil
+		}
+
+		var val string
+		switch w {
+		case "-X", "--request":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			req.Method = strings.ToUpper(val)
+		case "-H", "--header":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			name, value, found := strings.Cut(val, ":")
+			if !found {
+				continue
+			}
+			req.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii", "--data-urlencode":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(val, "@") && w != "--data-raw" {
+				return nil, fmt.Errorf("reading request data from a file (%s %s) is not supported", w, val)
+			}
+			if w == "--data-urlencode" {
+				if name, value, found := strings.Cut(val, "="); found {
+					val = name + "=" + url.QueryEscape(value)
+				} else {
+					val = url.QueryEscape(val)
+				}
+			}
+			dataParts = append(dataParts, val)
+		case "-F", "--form":
+			return nil, fmt.Errorf("multipart form data (-F) is not supported; paste the raw request instead")
+		case "-b", "--cookie":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			req.Headers.Add("Cookie", val)
+		case "-A", "--user-agent":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			req.Headers.Set("User-Agent", val)
+		case "-e", "--referer":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			req.Headers.Set("Referer", val)
+		case "-u", "--user":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			req.Headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(val)))
+		case "--url":
+			if val, err = next(); err != nil {
+				return nil, err
+			}
+			req.URL = val
+		case "-G", "--get":
+			getWithData = true
+		case "-I", "--head":
+			head = true
+		default:
+			if curlFlagsWithValue[w] {
+				if _, err = next(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if strings.HasPrefix(w, "-") {
+				continue // Flags without effect on the request (--compressed, -k, -s, -L, ...)
+			}
+			if req.URL == "" {
+				req.URL = w
+			}
+		}
+	}
+
+	if req.URL == "" {
+		return nil, fmt.Errorf("no URL found in curl command")
+	}
+	if !strings.Contains(req.URL, "://") {
+		req.URL = "http://" + req.URL
+	}
+	if _, err := url.Parse(req.URL); err != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %w", req.URL, err)
+	}
+
+	data := strings.Join(dataParts, "&")
+	switch {
+	case getWithData && len(dataParts) > 0:
+		sep := "?"
+		if strings.Contains(req.URL, "?") {
+			sep = "&"
+		}
+		req.URL += sep + data
+	case len(dataParts) > 0:
+		req.Body = []byte(data)
+		if req.Headers.Get("Content-Type") == "" {
+			req.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if req.Method == "" {
+		switch {
+		case head:
+			req.Method = http.MethodHead
+		case len(req.Body) > 0:
+			req.Method = http.MethodPost
+		default:
+			req.Method = http.MethodGet
+		}
+	}
+	return req, nil
+}
+
+// ParseRawHTTPRequest parses a raw HTTP request message (request line, headers, blank line, body).
+// Origin-form targets are resolved against the Host header using defaultScheme ("https" if empty).
+func ParseRawHTTPRequest(raw string, defaultScheme string) (*ParsedHTTPRequest, error) {
+	if defaultScheme == "" {
+		defaultScheme = "https"
+	}
+	raw = strings.TrimLeft(raw, "\r\n\t ")
+	head, body, _ := strings.Cut(strings.ReplaceAll(raw, "\r\n", "\n"), "\n\n")
+
+	scanner := bufio.NewScanner(strings.NewReader(head))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty request")
+	}
+	parts := strings.Fields(scanner.Text())
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("malformed request line: %q", scanner.Text())
+	}
+
+	req := &ParsedHTTPRequest{Method: strings.ToUpper(parts[0]), Headers: http.Header{}}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("malformed header line: %q", line)
+		}
+		req.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	target := parts[1]
+	if strings.Contains(target, "://") {
+		req.URL = target
+	} else {
+		host := req.Headers.Get("Host")
+		if host == "" {
+			return nil, fmt.Errorf("request target '%s' is relative and no Host header is present", target)
+		}
+		req.URL = defaultScheme + "://" + host + target
+	}
+	if _, err := url.Parse(req.URL); err != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %w", req.URL, err)
+	}
+
+	req.Headers.Del("Content-Length") // Recomputed when the request is sent
+	if body != "" {
+		req.Body = []byte(body)
+	}
+	return req, nil
+}
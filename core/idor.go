@@ -0,0 +1,441 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// idorIgnoredParams are query and form parameters whose numeric values are paging, sizes or
+// cache busters rather than object identifiers, normalized by normalizeParamName.
+var idorIgnoredParams = map[string]bool{
+	"page": true, "perpage": true, "pagesize": true, "limit": true, "offset": true, "size": true,
+	"count": true, "start": true, "end": true, "max": true, "min": true, "from": true, "to": true,
+	"t": true, "ts": true, "timestamp": true, "time": true, "v": true, "ver": true, "version": true,
+	"width": true, "height": true, "w": true, "h": true, "zoom": true, "year": true, "month": true,
+	"day": true, "sort": true, "order": true, "q": true, "lang": true, "": true,
+}
+
+// maxIDORTestValues caps the other sessions' identifiers suggested per candidate.
+const maxIDORTestValues = 3
+
+// idKind classifies a value as a numeric or UUID identifier, or returns "" for anything else.
+func idKind(value string) string {
+	switch {
+	case uuidPattern.MatchString(value):
+		return models.IDKindUUID
+	case value != "" && len(value) <= 19 && strings.Trim(value, "0123456789") == "":
+		return models.IDKindNumeric
+	}
+	return ""
+}
+
+// isIDLikeName reports whether a parameter or field name names an identifier: id, uuid, guid,
+// or a name ending in them (user_id, userId, account-uuid, orderIds).
+func isIDLikeName(name string) bool {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "[]"), "s")
+	lower := strings.ToLower(name)
+	switch lower {
+	case "id", "uuid", "guid":
+		return true
+	}
+	for _, suffix := range []string{"_id", "-id", ".id", "_uuid", "-uuid", "_guid"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "ID") || strings.HasSuffix(name, "Uuid") || strings.HasSuffix(name, "UUID")
+}
+
+// paramIDKind classifies a query or form parameter value. Numeric values of paging and similar
+// parameters, and long numbers (timestamps) of parameters not named like identifiers, are not
+// identifiers.
+func paramIDKind(name, value string) string {
+	kind := idKind(value)
+	if kind == models.IDKindNumeric && !isIDLikeName(name) && (idorIgnoredParams[normalizeParamName(name)] || len(value) > 12) {
+		return ""
+	}
+	return kind
+}
+
+// sessionFingerprint identifies the credentials of a request from its session cookies (per
+// cookie_jar.session_cookies) and Authorization header. Requests without any are "anonymous".
+func sessionFingerprint(headers http.Header, cookieHeader string, patterns []string) string {
+	var parts []string
+	req := &http.Request{Header: http.Header{"Cookie": {cookieHeader}}}
+	if cookieHeader == "" {
+		req.Header = headers
+	}
+	for _, c := range req.Cookies() {
+		if c.Value != "" && isSessionCookie(c.Name, patterns) {
+			parts = append(parts, c.Name+"="+c.Value)
+		}
+	}
+	if auth := headers.Get("Authorization"); auth != "" {
+		parts = append(parts, "authorization="+auth)
+	}
+	if len(parts) == 0 {
+		return "anonymous"
+	}
+	sort.Strings(parts)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// idOccurrence is an identifier found in a request.
+type idOccurrence struct {
+	location string
+	name     string
+	kind     string
+	value    string
+	segment  int // Index of the path segment, for path identifiers
+}
+
+// requestIdentifiers finds the identifiers in a request's path, query and body, and returns
+// them with the path turned into an endpoint template.
+func requestIdentifiers(u *url.URL, contentType string, body []byte) (string, []idOccurrence) {
+	var found []idOccurrence
+	segments := strings.Split(u.Path, "/")
+	template := make([]string, len(segments))
+	for i, segment := range segments {
+		template[i] = segment
+		kind := idKind(segment)
+		if kind == "" {
+			continue
+		}
+		template[i] = "{" + map[string]string{models.IDKindNumeric: "id", models.IDKindUUID: "uuid"}[kind] + "}"
+		name := fmt.Sprintf("segment %d", i)
+		if i > 0 && segments[i-1] != "" && idKind(segments[i-1]) == "" {
+			name = segments[i-1]
+		}
+		found = append(found, idOccurrence{location: models.IDLocationPath, name: name, kind: kind, value: segment, segment: i})
+	}
+	for name, values := range u.Query() {
+		for _, value := range values {
+			if kind := paramIDKind(name, value); kind != "" {
+				found = append(found, idOccurrence{location: models.IDLocationQuery, name: name, kind: kind, value: value})
+				break
+			}
+		}
+	}
+	if len(body) > 0 {
+		switch {
+		case strings.Contains(contentType, "json"):
+			var v interface{}
+			if json.Unmarshal(body, &v) == nil {
+				walkJSONIdentifiers(v, "", func(field, kind, value string) {
+					found = append(found, idOccurrence{location: models.IDLocationBody, name: field, kind: kind, value: value})
+				})
+			}
+		case strings.Contains(contentType, "x-www-form-urlencoded"):
+			if form, err := url.ParseQuery(string(body)); err == nil {
+				for name, values := range form {
+					if len(values) > 0 {
+						if kind := paramIDKind(name, values[0]); kind != "" {
+							found = append(found, idOccurrence{location: models.IDLocationBody, name: name, kind: kind, value: values[0]})
+						}
+					}
+				}
+			}
+		}
+	}
+	return strings.Join(template, "/"), found
+}
+
+// walkJSONIdentifiers calls fn for the identifiers in a decoded JSON document: whole numbers of
+// fields named like identifiers, and UUID strings of any field. Fields are named by their
+// dotted path, with [] for array elements.
+func walkJSONIdentifiers(v interface{}, field string, fn func(field, kind, value string)) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, child := range t {
+			name := key
+			if field != "" {
+				name = field + "." + key
+			}
+			walkJSONIdentifiers(child, name, fn)
+		}
+	case []interface{}:
+		for _, child := range t {
+			walkJSONIdentifiers(child, field+"[]", fn)
+		}
+	case float64:
+		leaf := field[strings.LastIndex(field, ".")+1:]
+		if t >= 0 && t == float64(int64(t)) && isIDLikeName(leaf) {
+			fn(field, models.IDKindNumeric, strconv.FormatInt(int64(t), 10))
+		}
+	case string:
+		if uuidPattern.MatchString(t) {
+			fn(field, models.IDKindUUID, t)
+		}
+	}
+}
+
+// idorCandidateState accumulates the requests of one candidate.
+type idorCandidateState struct {
+	candidate models.IDORCandidate
+	segment   int
+	example   models.HTTPTrafficLog
+	exampleIn string                     // Session of the example request
+	values    map[string]map[string]bool // Session -> values sent
+}
+
+// GetIDORWorklist tracks the numeric and UUID identifiers in the target's latest captured
+// requests (idor.max_logs) per session, and lists the places they appear, optionally only on
+// host, with suggested tests: the identifier plus and minus one, identifiers other sessions
+// sent to the same place, and the request replayed with each other replay session. limit caps
+// the candidates returned (0 for all).
+func GetIDORWorklist(targetID int64, host string, limit int) (models.IDORWorklist, error) {
+	worklist := models.IDORWorklist{TargetID: targetID}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return worklist, err
+	}
+	replaySessions, err := database.GetReplaySessions(targetID)
+	if err != nil {
+		return worklist, err
+	}
+	patterns := config.AppConfig.CookieJar.SessionCookies
+	maxLogs := config.AppConfig.IDOR.MaxLogs
+	if maxLogs <= 0 {
+		maxLogs = 5000
+	}
+	host = strings.ToLower(strings.TrimSpace(host))
+
+	sessions := map[string]*models.IDORSession{}
+	var sessionOrder []string
+	for _, rs := range replaySessions {
+		key := sessionFingerprint(headerFromMap(rs.Headers), rs.Cookies, patterns)
+		if _, ok := sessions[key]; ok || key == "anonymous" {
+			continue
+		}
+		sessions[key] = &models.IDORSession{Key: key, Label: rs.Name, ReplaySessionID: rs.ID}
+		sessionOrder = append(sessionOrder, key)
+	}
+	seenIDs := map[string]map[string]bool{}  // Session -> identifiers in its requests and responses
+	ownedIDs := map[string]map[string]bool{} // Session -> identifiers in its JSON responses
+	states := map[string]*idorCandidateState{}
+	var order []string
+
+	err = database.ForEachTargetBrowserRequest(targetID, maxLogs, func(e models.HTTPTrafficLog) {
+		worklist.LogsScanned++
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" || (host != "" && strings.ToLower(u.Hostname()) != host) {
+			return
+		}
+		headers := HeadersFromJSON(e.RequestHeaders.String)
+		key := sessionFingerprint(headers, "", patterns)
+		session, ok := sessions[key]
+		if !ok {
+			label := "anonymous"
+			if key != "anonymous" {
+				label = "session " + key
+			}
+			session = &models.IDORSession{Key: key, Label: label}
+			sessions[key] = session
+			sessionOrder = append(sessionOrder, key)
+		}
+		session.Requests++
+		if seenIDs[key] == nil {
+			seenIDs[key] = map[string]bool{}
+			ownedIDs[key] = map[string]bool{}
+		}
+
+		method := strings.ToUpper(e.RequestMethod.String)
+		template, found := requestIdentifiers(u, strings.ToLower(headers.Get("Content-Type")), e.RequestBody)
+		counted := map[string]bool{}
+		for _, id := range found {
+			seenIDs[key][id.value] = true
+			stateKey := strings.Join([]string{method, strings.ToLower(u.Host), template, id.location, id.name, strconv.Itoa(id.segment)}, " ")
+			// Array fields can hold several identifiers; the request counts once.
+			if counted[stateKey] {
+				continue
+			}
+			counted[stateKey] = true
+			state, ok := states[stateKey]
+			if !ok {
+				state = &idorCandidateState{
+					candidate: models.IDORCandidate{Method: method, Host: strings.ToLower(u.Host), Endpoint: template, Location: id.location, Name: id.name, Kind: id.kind},
+					segment:   id.segment,
+					values:    map[string]map[string]bool{},
+				}
+				states[stateKey] = state
+				order = append(order, stateKey)
+			}
+			c := &state.candidate
+			c.Requests++
+			if e.ResponseStatusCode >= 200 && e.ResponseStatusCode < 300 {
+				c.SuccessResponses++
+			}
+			if state.values[key] == nil {
+				state.values[key] = map[string]bool{}
+				c.Sessions = append(c.Sessions, session.Label)
+			}
+			state.values[key][id.value] = true
+			// The latest request is the example; requests come oldest first.
+			c.ExampleLogID, c.ExampleValue = e.ID, id.value
+			state.example, state.exampleIn = e, key
+		}
+		if len(e.ResponseBody) > 0 {
+			var v interface{}
+			if json.Unmarshal(e.ResponseBody, &v) == nil {
+				walkJSONIdentifiers(v, "", func(field, kind, value string) {
+					seenIDs[key][value] = true
+					ownedIDs[key][value] = true
+				})
+			}
+		}
+	})
+	if err != nil {
+		return worklist, err
+	}
+
+	for _, key := range sessionOrder {
+		s := sessions[key]
+		s.IDsSeen = len(seenIDs[key])
+		worklist.Sessions = append(worklist.Sessions, *s)
+	}
+	for _, stateKey := range order {
+		worklist.Candidates = append(worklist.Candidates, buildIDORCandidate(states[stateKey], worklist.Sessions, ownedIDs))
+	}
+	sort.SliceStable(worklist.Candidates, func(i, j int) bool {
+		a, b := worklist.Candidates[i], worklist.Candidates[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.Requests > b.Requests
+	})
+	if limit > 0 && len(worklist.Candidates) > limit {
+		worklist.Candidates = worklist.Candidates[:limit]
+	}
+	if worklist.Sessions == nil {
+		worklist.Sessions = []models.IDORSession{}
+	}
+	if worklist.Candidates == nil {
+		worklist.Candidates = []models.IDORCandidate{}
+	}
+	return worklist, nil
+}
+
+// buildIDORCandidate scores a candidate and suggests its tests. ownedIDs are the identifiers in
+// each session's JSON responses.
+func buildIDORCandidate(state *idorCandidateState, sessions []models.IDORSession, ownedIDs map[string]map[string]bool) models.IDORCandidate {
+	c := state.candidate
+	distinct := map[string]bool{}
+	for _, values := range state.values {
+		for v := range values {
+			distinct[v] = true
+		}
+	}
+	c.DistinctValues = len(distinct)
+
+	if c.Kind == models.IDKindNumeric {
+		c.Priority += 2
+		c.Reasons = append(c.Reasons, "numeric identifiers can be guessed")
+	}
+	if c.SuccessResponses > 0 {
+		c.Priority++
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%d of %d requests succeeded", c.SuccessResponses, c.Requests))
+	}
+	if c.Method != http.MethodGet && c.Method != http.MethodHead {
+		c.Priority++
+		c.Reasons = append(c.Reasons, c.Method+" requests change data")
+	}
+	if len(state.values) > 1 {
+		c.Priority++
+		c.Reasons = append(c.Reasons, fmt.Sprintf("sent by %d sessions, so their identifiers can be swapped", len(state.values)))
+	}
+	if state.exampleIn != "anonymous" && ownedIDs[state.exampleIn][c.ExampleValue] {
+		c.Priority++
+		c.Reasons = append(c.Reasons, "the identifier belongs to the sending session's own data")
+	}
+
+	if c.Kind == models.IDKindNumeric {
+		if n, err := strconv.ParseInt(c.ExampleValue, 10, 64); err == nil {
+			c.Tests = append(c.Tests, idorValueTest(state, models.IDORTestIncrement, strconv.FormatInt(n+1, 10), "the next identifier"))
+			if n > 1 {
+				c.Tests = append(c.Tests, idorValueTest(state, models.IDORTestDecrement, strconv.FormatInt(n-1, 10), "the previous identifier"))
+			}
+		}
+	}
+	var swaps []string
+	for key, values := range state.values {
+		if key == state.exampleIn || key == "anonymous" {
+			continue
+		}
+		for v := range values {
+			if v != c.ExampleValue {
+				swaps = append(swaps, v)
+			}
+		}
+	}
+	sort.Strings(swaps)
+	for i, v := range swaps {
+		if i == maxIDORTestValues {
+			break
+		}
+		c.Tests = append(c.Tests, idorValueTest(state, models.IDORTestSwapID, v, "an identifier another session sent here"))
+	}
+	for _, s := range sessions {
+		if s.ReplaySessionID == 0 || s.Key == state.exampleIn {
+			continue
+		}
+		c.Tests = append(c.Tests, models.IDORTest{
+			Type:            models.IDORTestSwapSession,
+			Description:     fmt.Sprintf("Replay log %d with replay session %q", state.example.ID, s.Label),
+			LogID:           state.example.ID,
+			ReplaySessionID: s.ReplaySessionID,
+		})
+	}
+	if c.Tests == nil {
+		c.Tests = []models.IDORTest{}
+	}
+	return c
+}
+
+// idorValueTest suggests sending the example request with value in place of its identifier.
+func idorValueTest(state *idorCandidateState, testType, value, what string) models.IDORTest {
+	c := state.candidate
+	test := models.IDORTest{Type: testType, LogID: state.example.ID, Value: value}
+	u, err := url.Parse(state.example.RequestURL.String)
+	switch {
+	case err != nil:
+	case c.Location == models.IDLocationPath:
+		segments := strings.Split(u.Path, "/")
+		if state.segment < len(segments) {
+			segments[state.segment] = value
+			swapped := *u
+			swapped.Path = strings.Join(segments, "/")
+			swapped.RawPath = ""
+			test.URL = swapped.String()
+		}
+	case c.Location == models.IDLocationQuery:
+		query := u.Query()
+		query.Set(c.Name, value)
+		swapped := *u
+		swapped.RawQuery = query.Encode()
+		test.URL = swapped.String()
+	}
+	test.Description = fmt.Sprintf("Send log %d with %s %s set to %s, %s", state.example.ID, c.Location, c.Name, value, what)
+	return test
+}
+
+// headerFromMap turns the headers of a replay session into an http.Header.
+func headerFromMap(m map[string]string) http.Header {
+	h := http.Header{}
+	for name, value := range m {
+		h.Set(name, value)
+	}
+	return h
+}
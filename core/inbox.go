@@ -0,0 +1,562 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// inboxMinInterval keeps the poller from hammering the IMAP server.
+const inboxMinInterval = 10 * time.Second
+
+const (
+	inboxMaxPartSize = 2 << 20 // Bytes read from one MIME part
+	inboxMaxLinks    = 50
+	inboxMaxCodes    = 10
+	inboxMaxDepth    = 5 // Nesting of multipart bodies
+)
+
+var (
+	inboxMu       sync.Mutex
+	inboxStatus   models.InboxStatus
+	inboxPollMu   sync.Mutex // Serializes polls (ticker, manual and waits)
+	inboxPatterns struct {
+		sync.Mutex
+		source   string
+		compiled []*regexp.Regexp
+	}
+
+	inboxTagRe      = regexp.MustCompile(`(?i)\+t(\d+)@`)
+	inboxHrefRe     = regexp.MustCompile(`(?i)\bhref\s*=\s*["']([^"']+)["']`)
+	inboxURLRe      = regexp.MustCompile(`https?://[^\s<>"'\]\[]+`)
+	inboxDropRe     = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)\s*>`)
+	inboxBreakRe    = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|tr|li|h[1-6]|table)\s*>`)
+	inboxTagStripRe = regexp.MustCompile(`(?s)<[^>]*>`)
+	inboxBlankRe    = regexp.MustCompile(`[ \t\r\f\v]+`)
+	inboxLinesRe    = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// inboxMagicLinkKeywords mark links that verify an account, log in or reset a password.
+var inboxMagicLinkKeywords = []string{"verif", "confirm", "activat", "magic", "token=", "code=", "otp", "login", "log-in",
+	"signin", "sign-in", "reset", "auth", "invit", "validate"}
+
+// RunInboxPoller reads new messages of the configured IMAP mailbox until ctx is cancelled.
+func RunInboxPoller(ctx context.Context) {
+	interval := time.Duration(config.AppConfig.Inbox.PollIntervalSeconds) * time.Second
+	if interval < inboxMinInterval {
+		interval = inboxMinInterval
+	}
+	setInboxPollerRunning(true)
+	defer setInboxPollerRunning(false)
+	logger.Info("Inbox: Polling %s every %s", config.AppConfig.Inbox.IMAP.Address, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := PollInboxNow(ctx); err != nil {
+			logger.Warn("Inbox: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Inbox: Stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollInboxNow reads the messages that arrived in the IMAP mailbox since the last poll and stores
+// them with their codes and links.
+func PollInboxNow(ctx context.Context) (models.InboxPollResult, error) {
+	result := models.InboxPollResult{IDs: []int64{}}
+	cfg := config.AppConfig.Inbox
+	if !cfg.Enabled {
+		return result, fmt.Errorf("the inbox is disabled (inbox.enabled)")
+	}
+	if cfg.IMAP.Address == "" {
+		return result, fmt.Errorf("invalid inbox settings: no IMAP address configured (inbox.imap.address)")
+	}
+	inboxPollMu.Lock()
+	defer inboxPollMu.Unlock()
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	raws, err := fetchNewIMAPMessages(cfg.IMAP)
+	for _, raw := range raws {
+		result.Fetched++
+		m, perr := parseInboxEmail(raw.Raw)
+		if perr != nil {
+			logger.Warn("Inbox: Skipping IMAP message %d: %v", raw.UID, perr)
+			continue
+		}
+		m.Source = models.InboxSourceIMAP
+		if !raw.InternalDate.IsZero() && m.SentAt == nil {
+			m.SentAt = &raw.InternalDate
+		}
+		if m.MessageID == "" {
+			m.MessageID = fmt.Sprintf("imap-uid-%d@%s", raw.UID, cfg.IMAP.Address)
+		}
+		id, created, serr := storeInboxMessage(m)
+		if serr != nil {
+			err = serr
+			continue
+		}
+		if created {
+			result.Stored++
+			result.IDs = append(result.IDs, id)
+		}
+	}
+
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+	now := time.Now()
+	inboxStatus.LastPollAt = &now
+	inboxStatus.Polls++
+	inboxStatus.MessagesStored += int64(result.Stored)
+	if err != nil {
+		inboxStatus.LastError = err.Error()
+		inboxStatus.LastErrorAt = &now
+		return result, fmt.Errorf("inbox poll failed: %w", err)
+	}
+	return result, nil
+}
+
+// GetInboxStatus returns the inbox configuration and the poller counters.
+func GetInboxStatus() models.InboxStatus {
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+	status := inboxStatus
+	status.Enabled = config.AppConfig.Inbox.Enabled
+	status.IMAPConfigured = config.AppConfig.Inbox.IMAP.Address != ""
+	status.WebhookToken = config.AppConfig.Inbox.WebhookToken != ""
+	return status
+}
+
+func setInboxPollerRunning(running bool) {
+	inboxMu.Lock()
+	inboxStatus.Running = running
+	inboxMu.Unlock()
+}
+
+// ReceiveInboxWebhook stores an email posted to the inbox webhook: the raw RFC 822 message when
+// raw is set, otherwise the parsed fields. created is false when the message was already stored.
+func ReceiveInboxWebhook(msg models.InboxWebhookMessage) (m models.InboxMessage, created bool, err error) {
+	if !config.AppConfig.Inbox.Enabled {
+		return m, false, fmt.Errorf("the inbox is disabled (inbox.enabled)")
+	}
+	if strings.TrimSpace(msg.Raw) != "" {
+		m, err = parseInboxEmail([]byte(msg.Raw))
+		if err != nil {
+			return m, false, fmt.Errorf("invalid raw message: %w", err)
+		}
+	} else {
+		if msg.Text == "" && msg.HTML == "" && msg.Subject == "" {
+			return m, false, fmt.Errorf("invalid message: expected raw, or a subject, text or html")
+		}
+		m = models.InboxMessage{
+			MessageID: strings.Trim(strings.TrimSpace(msg.MessageID), "<>"),
+			From:      inboxAddress(msg.From),
+			Subject:   msg.Subject,
+			BodyText:  msg.Text,
+			BodyHTML:  msg.HTML,
+		}
+		switch to := msg.To.(type) {
+		case string:
+			m.To = inboxAddressList(to)
+		case []interface{}:
+			for _, v := range to {
+				if s, ok := v.(string); ok {
+					m.To = append(m.To, inboxAddressList(s)...)
+				}
+			}
+		}
+		extractInboxContent(&m)
+	}
+	m.Source = models.InboxSourceWebhook
+	id, created, err := storeInboxMessage(m)
+	if err != nil {
+		return m, false, err
+	}
+	inboxMu.Lock()
+	if created {
+		inboxStatus.MessagesStored++
+	}
+	inboxMu.Unlock()
+	stored, err := database.GetInboxMessage(id)
+	return stored, created, err
+}
+
+// WaitForInboxCode returns the newest message of a target received since since that carries a
+// verification code or link, waiting up to wait for one to arrive. The IMAP mailbox is polled
+// while waiting when it is configured.
+func WaitForInboxCode(ctx context.Context, targetID int64, since time.Time, wait time.Duration) (*models.InboxMessage, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(wait)
+	pollIMAP := config.AppConfig.Inbox.Enabled && config.AppConfig.Inbox.IMAP.Address != ""
+	var lastPoll time.Time
+	for {
+		m, err := database.GetLatestInboxCodeMessage(targetID, since)
+		if err != nil || m != nil {
+			return m, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("inbox message with a code or link for target %d since %s not found", targetID, since.Format(time.RFC3339))
+		}
+		if pollIMAP && time.Since(lastPoll) >= 5*time.Second {
+			lastPoll = time.Now()
+			if _, err := PollInboxNow(ctx); err != nil {
+				logger.Warn("Inbox: %v", err)
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// storeInboxMessage correlates a parsed message to a target and stores it. The codes and links of
+// a new correlated message become the target's inbox_code and inbox_link variables.
+func storeInboxMessage(m models.InboxMessage) (int64, bool, error) {
+	if targetID, reason := correlateInboxMessage(m); targetID != 0 {
+		m.TargetID.Int64, m.TargetID.Valid = targetID, true
+		m.MatchReason = reason
+	}
+	m.ReceivedAt = time.Now()
+	id, created, err := database.CreateInboxMessage(m)
+	if err != nil || !created {
+		return id, created, err
+	}
+	logger.Info("Inbox: Stored message %d %q from %s (%d codes, %d magic links)", id, m.Subject, m.From, len(m.Codes), len(m.MagicLinks))
+
+	if m.TargetID.Valid {
+		if len(m.Codes) > 0 {
+			if err := database.SetTargetVariableValue(m.TargetID.Int64, models.InboxCodeVariable, m.Codes[0]); err != nil {
+				logger.Warn("Inbox: Setting %s of target %d: %v", models.InboxCodeVariable, m.TargetID.Int64, err)
+			}
+		}
+		if len(m.MagicLinks) > 0 {
+			if err := database.SetTargetVariableValue(m.TargetID.Int64, models.InboxLinkVariable, m.MagicLinks[0]); err != nil {
+				logger.Warn("Inbox: Setting %s of target %d: %v", models.InboxLinkVariable, m.TargetID.Int64, err)
+			}
+		}
+	}
+	if err := database.PruneInboxMessages(config.AppConfig.Inbox.MaxMessages); err != nil {
+		logger.Warn("Inbox: %v", err)
+	}
+	return id, true, nil
+}
+
+// correlateInboxMessage finds the target a message belongs to: the target tagged in a recipient
+// address (hunter+t12@example.com), else the target whose in-scope rules match most of the link
+// and sender hosts, else the current target.
+func correlateInboxMessage(m models.InboxMessage) (int64, string) {
+	for _, addr := range m.To {
+		if match := inboxTagRe.FindStringSubmatch(addr); match != nil {
+			id, _ := strconv.ParseInt(match[1], 10, 64)
+			if _, err := database.GetTargetByID(id); err == nil {
+				return id, models.InboxMatchAddressTag
+			}
+		}
+	}
+
+	hosts := make(map[string]bool)
+	for _, link := range m.Links {
+		if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+			hosts[strings.ToLower(u.Hostname())] = true
+		}
+	}
+	if at := strings.LastIndex(m.From, "@"); at >= 0 {
+		hosts[strings.ToLower(strings.Trim(m.From[at+1:], "> "))] = true
+	}
+	if len(hosts) > 0 {
+		if targets, err := database.GetTargets(nil); err == nil {
+			var bestID int64
+			best := 0
+			for _, target := range targets {
+				if n := inboxHostsInScope(target.ID, hosts); n > best {
+					bestID, best = target.ID, n
+				}
+			}
+			if bestID != 0 {
+				return bestID, models.InboxMatchScope
+			}
+		}
+	}
+
+	if current, err := database.GetSetting(models.CurrentTargetIDKey); err == nil && current != "" {
+		if id, err := strconv.ParseInt(current, 10, 64); err == nil && id != 0 {
+			return id, models.InboxMatchCurrentTarget
+		}
+	}
+	return 0, ""
+}
+
+// inboxHostsInScope counts the hosts explicitly in scope of a target. Targets without in-scope
+// rules match nothing, unlike for proxied traffic.
+func inboxHostsInScope(targetID int64, hosts map[string]bool) int {
+	rules, err := database.GetAllScopeRulesForTarget(targetID)
+	if err != nil {
+		return 0
+	}
+	hasInScope := false
+	for _, rule := range rules {
+		hasInScope = hasInScope || rule.IsInScope
+	}
+	if !hasInScope {
+		return 0
+	}
+	n := 0
+	for host := range hosts {
+		if isRequestEffectivelyInScope(&url.URL{Scheme: "https", Host: host, Path: "/"}, rules) {
+			n++
+		}
+	}
+	return n
+}
+
+// parseInboxEmail parses a raw RFC 822 message and extracts its codes and links.
+func parseInboxEmail(raw []byte) (models.InboxMessage, error) {
+	var m models.InboxMessage
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return m, err
+	}
+	dec := new(mime.WordDecoder)
+	decode := func(s string) string {
+		if d, err := dec.DecodeHeader(s); err == nil {
+			return d
+		}
+		return s
+	}
+	m.MessageID = strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>")
+	m.Subject = decode(msg.Header.Get("Subject"))
+	m.From = inboxAddress(decode(msg.Header.Get("From")))
+	seen := make(map[string]bool)
+	for _, field := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, value := range msg.Header[field] {
+			for _, addr := range inboxAddressList(value) {
+				if !seen[addr] {
+					seen[addr] = true
+					m.To = append(m.To, addr)
+				}
+			}
+		}
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		m.SentAt = &date
+	}
+
+	var text, htmlBody strings.Builder
+	readInboxPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, &text, &htmlBody, 0)
+	m.BodyText, m.BodyHTML = strings.TrimSpace(text.String()), strings.TrimSpace(htmlBody.String())
+	extractInboxContent(&m)
+	return m, nil
+}
+
+// readInboxPart appends the text/plain and text/html parts of a (multipart) body to text and
+// htmlBody. Attachments and other types are skipped.
+func readInboxPart(contentType, encoding string, body io.Reader, text, htmlBody *strings.Builder, depth int) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= inboxMaxDepth || params["boundary"] == "" {
+			return
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart() // Decodes quoted-printable parts
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(strings.ToLower(part.Header.Get("Content-Disposition")), "attachment") {
+				continue
+			}
+			readInboxPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, text, htmlBody, depth+1)
+		}
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, _ := io.ReadAll(io.LimitReader(body, inboxMaxPartSize))
+	content := inboxDecodeCharset(data, params["charset"])
+	out := text
+	if mediaType == "text/html" {
+		out = htmlBody
+	}
+	if out.Len() > 0 {
+		out.WriteString("\n")
+	}
+	out.WriteString(content)
+}
+
+// inboxDecodeCharset converts Latin-1 and Windows-1252 text to UTF-8; other charsets are kept.
+func inboxDecodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+// extractInboxContent fills the codes, links and magic links of a message from its subject and
+// bodies.
+func extractInboxContent(m *models.InboxMessage) {
+	text := m.BodyText
+	if strings.TrimSpace(text) == "" && m.BodyHTML != "" {
+		text = inboxHTMLText(m.BodyHTML)
+	}
+	m.Codes = findInboxCodes(m.Subject + "\n" + text)
+
+	m.Links = []string{}
+	m.MagicLinks = []string{}
+	seen := make(map[string]bool)
+	add := func(link string) {
+		link = strings.TrimRight(html.UnescapeString(strings.TrimSpace(link)), ".,;:!?)'\"")
+		lower := strings.ToLower(link)
+		if seen[link] || len(m.Links) >= inboxMaxLinks || (!strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://")) {
+			return
+		}
+		seen[link] = true
+		m.Links = append(m.Links, link)
+		if inboxIsMagicLink(lower) {
+			m.MagicLinks = append(m.MagicLinks, link)
+		}
+	}
+	for _, match := range inboxHrefRe.FindAllStringSubmatch(m.BodyHTML, -1) {
+		add(match[1])
+	}
+	for _, link := range inboxURLRe.FindAllString(m.BodyText, -1) {
+		add(link)
+	}
+	if m.BodyText == "" {
+		for _, link := range inboxURLRe.FindAllString(text, -1) {
+			add(link)
+		}
+	}
+}
+
+func inboxIsMagicLink(lower string) bool {
+	if strings.Contains(lower, "unsubscribe") {
+		return false
+	}
+	for _, keyword := range inboxMagicLinkKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// findInboxCodes returns the verification codes the configured patterns find in text, in pattern
+// order. Codes must contain a digit, which keeps words following "code" out.
+func findInboxCodes(text string) []string {
+	codes := []string{}
+	seen := make(map[string]bool)
+	for _, re := range inboxCodePatterns() {
+		for _, match := range re.FindAllStringSubmatch(text, -1) {
+			if len(match) < 2 || match[1] == "" || seen[match[1]] || !strings.ContainsAny(match[1], "0123456789") {
+				continue
+			}
+			seen[match[1]] = true
+			codes = append(codes, match[1])
+			if len(codes) >= inboxMaxCodes {
+				return codes
+			}
+		}
+	}
+	return codes
+}
+
+// inboxCodePatterns compiles inbox.code_patterns, once per change of the setting. Invalid
+// patterns are logged and skipped.
+func inboxCodePatterns() []*regexp.Regexp {
+	patterns := config.AppConfig.Inbox.CodePatterns
+	source := strings.Join(patterns, "\x00")
+	inboxPatterns.Lock()
+	defer inboxPatterns.Unlock()
+	if inboxPatterns.compiled != nil && inboxPatterns.source == source {
+		return inboxPatterns.compiled
+	}
+	compiled := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Warn("Inbox: Skipping invalid code pattern %q: %v", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	inboxPatterns.source, inboxPatterns.compiled = source, compiled
+	return compiled
+}
+
+// inboxHTMLText renders an HTML body as plain text for code extraction.
+func inboxHTMLText(body string) string {
+	s := inboxDropRe.ReplaceAllString(body, "")
+	s = inboxBreakRe.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(inboxTagStripRe.ReplaceAllString(s, " "))
+	s = inboxBlankRe.ReplaceAllString(s, " ")
+	return strings.TrimSpace(inboxLinesRe.ReplaceAllString(s, "\n"))
+}
+
+// inboxAddress returns the bare, lower-cased address of "Name <addr>".
+func inboxAddress(s string) string {
+	if addr, err := mail.ParseAddress(strings.TrimSpace(s)); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return strings.ToLower(strings.Trim(strings.TrimSpace(s), "<>"))
+}
+
+// inboxAddressList returns the bare addresses of a comma-separated address header.
+func inboxAddressList(s string) []string {
+	if list, err := mail.ParseAddressList(s); err == nil {
+		addrs := make([]string, 0, len(list))
+		for _, addr := range list {
+			addrs = append(addrs, strings.ToLower(addr.Address))
+		}
+		return addrs
+	}
+	var addrs []string
+	for _, part := range strings.Split(s, ",") {
+		if addr := inboxAddress(part); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+)
+
+// inboxIMAPStateKey is the app setting holding the position of the IMAP poller in the mailbox.
+const inboxIMAPStateKey = "inbox_imap_state"
+
+// inboxIMAPMaxFetch caps the messages fetched by one poll; the next poll continues.
+const inboxIMAPMaxFetch = 50
+
+var (
+	imapLiteralRe     = regexp.MustCompile(`\{(\d+)\}$`)
+	imapUIDValidityRe = regexp.MustCompile(`(?i)\[UIDVALIDITY (\d+)\]`)
+	imapUIDNextRe     = regexp.MustCompile(`(?i)\[UIDNEXT (\d+)\]`)
+	imapFetchUIDRe    = regexp.MustCompile(`(?i)\bUID (\d+)`)
+	imapInternalRe    = regexp.MustCompile(`(?i)INTERNALDATE "([^"]+)"`)
+)
+
+// inboxIMAPState is the position of the poller: the last UID read under a UIDVALIDITY.
+type inboxIMAPState struct {
+	UIDValidity uint32 `json:"uidvalidity"`
+	LastUID     uint32 `json:"last_uid"`
+}
+
+// imapResponse is an untagged server response, with the literals ({N} strings) it carried.
+type imapResponse struct {
+	Line     string
+	Literals [][]byte
+}
+
+// imapClient is a minimal IMAP4rev1 client: enough to log in, select a mailbox and fetch new
+// messages by UID.
+type imapClient struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	tag     int
+	timeout time.Duration
+}
+
+// dialIMAP connects to the configured IMAP server and reads its greeting.
+func dialIMAP(cfg config.InboxIMAPConfig) (*imapClient, error) {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if cfg.TLS {
+		host, _, _ := net.SplitHostPort(cfg.Address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Address, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to IMAP server %s: %w", cfg.Address, err)
+	}
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	conn.SetDeadline(time.Now().Add(timeout))
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading IMAP greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting: %s", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readResponse reads one response line, with the literals announced at the end of its parts.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	var line strings.Builder
+	for {
+		part, err := c.readLine()
+		if err != nil {
+			return resp, err
+		}
+		line.WriteString(part)
+		m := imapLiteralRe.FindStringSubmatch(part)
+		if m == nil {
+			break
+		}
+		size, err := strconv.Atoi(m[1])
+		if err != nil || size > 50<<20 {
+			return resp, fmt.Errorf("invalid IMAP literal size %s", m[1])
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return resp, fmt.Errorf("reading IMAP literal: %w", err)
+		}
+		resp.Literals = append(resp.Literals, literal)
+	}
+	resp.Line = line.String()
+	return resp, nil
+}
+
+// command sends a command and returns the untagged responses preceding its tagged completion. A
+// completion other than OK is an error.
+func (c *imapClient) command(cmd string) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("T%03d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, fmt.Errorf("sending IMAP command: %w", err)
+	}
+	var untagged []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, fmt.Errorf("reading IMAP response: %w", err)
+		}
+		if !strings.HasPrefix(resp.Line, tag+" ") {
+			untagged = append(untagged, resp)
+			continue
+		}
+		status := strings.TrimPrefix(resp.Line, tag+" ")
+		if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+			verb, _, _ := strings.Cut(cmd, " ")
+			return untagged, fmt.Errorf("IMAP %s failed: %s", verb, status)
+		}
+		return untagged, nil
+	}
+}
+
+// imapQuote quotes a string argument.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// imapRawMessage is a message fetched from the mailbox.
+type imapRawMessage struct {
+	UID          uint32
+	InternalDate time.Time
+	Raw          []byte
+}
+
+// fetchNewIMAPMessages logs in to the configured mailbox and fetches the messages that arrived
+// since the last poll. The first poll of a mailbox only reads the messages of the past day.
+func fetchNewIMAPMessages(cfg config.InboxIMAPConfig) ([]imapRawMessage, error) {
+	if strings.ContainsAny(cfg.Username+cfg.Password+cfg.Mailbox, "\r\n") {
+		return nil, fmt.Errorf("invalid IMAP settings: line breaks in username, password or mailbox")
+	}
+	var state inboxIMAPState
+	if raw, err := database.GetSetting(inboxIMAPStateKey); err != nil {
+		return nil, err
+	} else if raw != "" {
+		json.Unmarshal([]byte(raw), &state)
+	}
+
+	c, err := dialIMAP(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if _, err := c.command("LOGIN " + imapQuote(cfg.Username) + " " + imapQuote(cfg.Password)); err != nil {
+		return nil, err
+	}
+	defer c.command("LOGOUT")
+
+	mailbox := cfg.Mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	selected, err := c.command("EXAMINE " + imapQuote(mailbox)) // Read-only; fetching leaves messages unseen
+	if err != nil {
+		return nil, err
+	}
+	var uidValidity, uidNext uint32
+	for _, resp := range selected {
+		if m := imapUIDValidityRe.FindStringSubmatch(resp.Line); m != nil {
+			v, _ := strconv.ParseUint(m[1], 10, 32)
+			uidValidity = uint32(v)
+		}
+		if m := imapUIDNextRe.FindStringSubmatch(resp.Line); m != nil {
+			v, _ := strconv.ParseUint(m[1], 10, 32)
+			uidNext = uint32(v)
+		}
+	}
+
+	firstPoll := state.UIDValidity == 0 || state.UIDValidity != uidValidity
+	search := fmt.Sprintf("UID SEARCH UID %d:*", state.LastUID+1)
+	if firstPoll {
+		state = inboxIMAPState{UIDValidity: uidValidity}
+		search = "UID SEARCH SINCE " + time.Now().AddDate(0, 0, -1).Format("2-Jan-2006")
+	}
+	found, err := c.command(search)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, resp := range found {
+		fields := strings.Fields(resp.Line)
+		if len(fields) < 2 || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, f := range fields[2:] {
+			// "UID n:*" always matches the last message, even when it is older than n
+			if uid, err := strconv.ParseUint(f, 10, 32); err == nil && uint32(uid) > state.LastUID {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	if len(uids) > inboxIMAPMaxFetch {
+		if firstPoll {
+			uids = uids[len(uids)-inboxIMAPMaxFetch:] // Only the most recent ones matter
+		} else {
+			uids = uids[:inboxIMAPMaxFetch]
+		}
+	}
+	if firstPoll && uidNext > 0 {
+		state.LastUID = uidNext - 1 // Older messages are not read, even when none arrived in the past day
+	}
+
+	var messages []imapRawMessage
+	var fetchErr error
+	for _, uid := range uids {
+		fetched, err := c.command(fmt.Sprintf("UID FETCH %d (UID INTERNALDATE BODY.PEEK[])", uid))
+		if err != nil {
+			fetchErr = err
+			break
+		}
+		for _, resp := range fetched {
+			if len(resp.Literals) == 0 || !strings.Contains(strings.ToUpper(resp.Line), "FETCH") {
+				continue
+			}
+			msg := imapRawMessage{UID: uid, Raw: resp.Literals[0]}
+			if m := imapFetchUIDRe.FindStringSubmatch(resp.Line); m != nil {
+				if v, err := strconv.ParseUint(m[1], 10, 32); err == nil && uint32(v) != uid {
+					continue
+				}
+			}
+			if m := imapInternalRe.FindStringSubmatch(resp.Line); m != nil {
+				msg.InternalDate, _ = time.Parse("2-Jan-2006 15:04:05 -0700", strings.TrimSpace(m[1]))
+			}
+			messages = append(messages, msg)
+		}
+		if uid > state.LastUID {
+			state.LastUID = uid
+		}
+	}
+
+	// Keep the position of the messages fetched before a failure
+	if raw, err := json.Marshal(state); err == nil {
+		if err := database.SetSetting(inboxIMAPStateKey, string(raw)); err != nil && fetchErr == nil {
+			fetchErr = err
+		}
+	}
+	return messages, fetchErr
+}
@@ -0,0 +1,570 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// ParseIPAssetAddress canonicalizes an IP address or CIDR block. Host bits of a CIDR are cleared
+// and a full-length prefix (/32, /128) is treated as a single IP.
+func ParseIPAssetAddress(s string) (address string, isCIDR bool, version int, err error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", false, 0, fmt.Errorf("invalid CIDR '%s': %w", s, err)
+		}
+		prefix = prefix.Masked()
+		addr := prefix.Addr()
+		if prefix.Bits() < addr.BitLen() {
+			return prefix.String(), true, ipVersion(addr), nil
+		}
+		return addr.String(), false, ipVersion(addr), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", false, 0, fmt.Errorf("invalid IP address '%s': %w", s, err)
+	}
+	addr = addr.Unmap().WithZone("")
+	return addr.String(), false, ipVersion(addr), nil
+}
+
+func ipVersion(addr netip.Addr) int {
+	if addr.Is4() {
+		return 4
+	}
+	return 6
+}
+
+// ipAssetPrefix returns an asset address as a prefix (a single IP as a full-length prefix).
+func ipAssetPrefix(address string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		return prefix.Masked(), true
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// scopeRuleCoversPrefix reports whether an ip_address or cidr scope rule contains the whole prefix.
+func scopeRuleCoversPrefix(rule models.ScopeRule, prefix netip.Prefix) bool {
+	var rulePrefix netip.Prefix
+	switch rule.ItemType {
+	case "ip_address":
+		addr, err := netip.ParseAddr(strings.TrimSpace(rule.Pattern))
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		rulePrefix = netip.PrefixFrom(addr, addr.BitLen())
+	case "cidr":
+		p, err := netip.ParsePrefix(strings.TrimSpace(rule.Pattern))
+		if err != nil {
+			return false
+		}
+		rulePrefix = p.Masked()
+	default:
+		return false
+	}
+	return rulePrefix.Addr().Is4() == prefix.Addr().Is4() && rulePrefix.Bits() <= prefix.Bits() && rulePrefix.Contains(prefix.Addr())
+}
+
+// ipAssetScope decides whether an IP asset is in scope from the target's ip_address and cidr rules.
+// Out-of-scope rules win, as in the proxy; an asset no rule covers is out of scope.
+func ipAssetScope(address string, rules []models.ScopeRule) (bool, *int64) {
+	prefix, ok := ipAssetPrefix(address)
+	if !ok {
+		return false, nil
+	}
+	var inScopeRule *int64
+	for _, rule := range rules {
+		if !scopeRuleCoversPrefix(rule, prefix) {
+			continue
+		}
+		id := rule.ID
+		if !rule.IsInScope {
+			return false, &id
+		}
+		if inScopeRule == nil {
+			inScopeRule = &id
+		}
+	}
+	return inScopeRule != nil, inScopeRule
+}
+
+// RescopeIPAssets recomputes is_in_scope of every IP asset of a target from its current scope rules.
+func RescopeIPAssets(targetID int64) (models.IPAssetRescopeResult, error) {
+	var result models.IPAssetRescopeResult
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return result, err
+	}
+	assets, err := database.ListAllIPAssetsForTarget(targetID)
+	if err != nil {
+		return result, err
+	}
+	for _, asset := range assets {
+		inScope, ruleID := ipAssetScope(asset.Address, rules)
+		result.Assets++
+		if inScope {
+			result.InScope++
+		} else {
+			result.OutOfScope++
+		}
+		if inScope == asset.IsInScope && equalInt64Ptr(ruleID, asset.ScopeRuleID) {
+			continue
+		}
+		if err := database.UpdateIPAssetScope(asset.ID, inScope, ruleID); err != nil {
+			return result, err
+		}
+		result.Changed++
+	}
+	return result, nil
+}
+
+func equalInt64Ptr(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// RefreshIPAssetScope brings a target's IP assets in line with its scope rules after they change:
+// in-scope ip_address/cidr rules become assets and every asset is rescoped. Errors are logged.
+func RefreshIPAssetScope(targetID int64) {
+	if _, err := syncScopeRuleIPAssets(targetID); err != nil {
+		logger.Error("RefreshIPAssetScope: Syncing scope rule assets of target %d: %v", targetID, err)
+		return
+	}
+	if _, err := RescopeIPAssets(targetID); err != nil {
+		logger.Error("RefreshIPAssetScope: Rescoping IP assets of target %d: %v", targetID, err)
+	}
+}
+
+// syncScopeRuleIPAssets adds the in-scope ip_address and cidr rules of a target to its IP assets.
+func syncScopeRuleIPAssets(targetID int64) (int, error) {
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, rule := range rules {
+		if !rule.IsInScope || (rule.ItemType != "ip_address" && rule.ItemType != "cidr") {
+			continue
+		}
+		address, isCIDR, version, err := ParseIPAssetAddress(rule.Pattern)
+		if err != nil {
+			logger.Warn("syncScopeRuleIPAssets: Skipping scope rule %d of target %d: %v", rule.ID, targetID, err)
+			continue
+		}
+		_, isNew, err := database.UpsertIPAsset(models.IPAsset{TargetID: targetID, Address: address, IsCIDR: isCIDR, IPVersion: version, Source: models.IPAssetSourceScopeRule})
+		if err != nil {
+			return added, err
+		}
+		if isNew {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// AddIPAsset adds an IP or CIDR to a target's inventory by hand.
+func AddIPAsset(ctx context.Context, targetID int64, req models.IPAssetRequest) (models.IPAsset, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.IPAsset{}, err
+	}
+	address, isCIDR, version, err := ParseIPAssetAddress(req.Address)
+	if err != nil {
+		return models.IPAsset{}, err
+	}
+	id, isNew, err := database.UpsertIPAsset(models.IPAsset{TargetID: targetID, Address: address, IsCIDR: isCIDR, IPVersion: version, Source: models.IPAssetSourceManual, Notes: req.Notes})
+	if err != nil {
+		return models.IPAsset{}, err
+	}
+	if !isNew {
+		return models.IPAsset{}, fmt.Errorf("IP asset '%s' already exists for target %d (ID: %d)", address, targetID, id)
+	}
+	if _, err := RescopeIPAssets(targetID); err != nil {
+		return models.IPAsset{}, err
+	}
+	asset, err := database.GetIPAssetByID(id)
+	if err != nil {
+		return asset, err
+	}
+	if req.Enrich {
+		if enriched, err := EnrichIPAsset(ctx, asset); err != nil {
+			logger.Warn("AddIPAsset: Enriching %s: %v", address, err)
+		} else {
+			asset = enriched
+		}
+	}
+	return asset, nil
+}
+
+// IPAssetResolutionOptions controls a resolution job.
+type IPAssetResolutionOptions struct {
+	InScopeOnly bool // Resolve only domains marked in scope
+	Enrich      bool // Reverse DNS and ASN lookups for assets not enriched yet
+}
+
+// StartIPAssetResolution starts a job resolving the domains of a target into IP assets. The
+// target's in-scope ip_address/cidr rules are added too, and every asset is rescoped.
+func StartIPAssetResolution(targetID int64, opts IPAssetResolutionOptions) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	latest, err := LatestJob(models.JobTypeIPResolve, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("an IP resolution job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+	return StartJob(models.JobTypeIPResolve, &targetID, "Loading domains...", func(ctx context.Context, job *JobHandle) error {
+		return runIPAssetResolution(ctx, job, targetID, opts)
+	})
+}
+
+// ResolveIPAssetsAfterDiscovery starts a resolution job for a target after recon found new
+// domains, when ip_assets.resolve_after_discovery is on.
+func ResolveIPAssetsAfterDiscovery(targetID int64) {
+	conf := config.AppConfig.IPAssets
+	if !conf.ResolveAfterDiscovery {
+		return
+	}
+	job, err := StartIPAssetResolution(targetID, IPAssetResolutionOptions{Enrich: conf.Enrich})
+	if err != nil {
+		logger.Warn("ResolveIPAssetsAfterDiscovery: %v", err)
+		return
+	}
+	logger.Info("ResolveIPAssetsAfterDiscovery: Started IP resolution job %d for target %d", job.ID, targetID)
+}
+
+func runIPAssetResolution(ctx context.Context, job *JobHandle, targetID int64, opts IPAssetResolutionOptions) error {
+	filters := models.DomainFilters{TargetID: targetID}
+	if opts.InScopeOnly {
+		inScope := true
+		filters.IsInScope = &inScope
+	}
+	ids, err := database.GetDomainIDsByFilters(filters)
+	if err != nil {
+		return err
+	}
+	var domains []models.Domain
+	if len(ids) > 0 {
+		if domains, err = database.GetDomainsByIDs(ids); err != nil {
+			return err
+		}
+	}
+	job.SetTotal(int64(len(domains)))
+	job.SetMessage("Resolving %d domains...", len(domains))
+
+	conf := config.AppConfig.IPAssets
+	workers := conf.ResolverWorkers
+	if workers <= 0 {
+		workers = 10
+	}
+	domainCh := make(chan models.Domain)
+	var wg sync.WaitGroup
+	var newMu sync.Mutex
+	newIPs := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range domainCh {
+				added, resolved, err := resolveDomainToIPAssets(ctx, targetID, d)
+				if err != nil {
+					job.RecordError(err)
+				}
+				newMu.Lock()
+				newIPs += added
+				newMu.Unlock()
+				if resolved {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, d := range domains {
+		select {
+		case <-ctx.Done():
+			break feed
+		case domainCh <- d:
+		}
+	}
+	close(domainCh)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	fromRules, err := syncScopeRuleIPAssets(targetID)
+	if err != nil {
+		return err
+	}
+	scope, err := RescopeIPAssets(targetID)
+	if err != nil {
+		return err
+	}
+
+	enriched := 0
+	if opts.Enrich {
+		pending, err := database.ListUnenrichedIPAssets(targetID)
+		if err != nil {
+			return err
+		}
+		for i, asset := range pending {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			job.SetMessage("Enriching IP assets (%d/%d)...", i+1, len(pending))
+			if _, err := EnrichIPAsset(ctx, asset); err != nil {
+				job.RecordError(err)
+				continue
+			}
+			enriched++
+		}
+	}
+
+	resetHostIPCache()
+	job.SetMessage("Resolved %d domains: %d new IPs, %d from scope rules, %d enriched. %d of %d assets in scope.",
+		len(domains), newIPs, fromRules, enriched, scope.InScope, scope.Assets)
+	return nil
+}
+
+// resolveDomainToIPAssets looks up a domain's addresses and stores them as IP assets linked to the
+// domain. Domains that do not exist are not errors.
+func resolveDomainToIPAssets(ctx context.Context, targetID int64, d models.Domain) (added int, resolved bool, err error) {
+	addrs, err := ipAssetLookup(ctx, func(ctx context.Context) ([]net.IPAddr, error) {
+		return reconResolver().LookupIPAddr(ctx, d.DomainName)
+	})
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("resolving %s: %w", d.DomainName, err)
+	}
+	for _, a := range addrs {
+		address, isCIDR, version, err := ParseIPAssetAddress(a.IP.String())
+		if err != nil {
+			continue
+		}
+		id, isNew, err := database.UpsertIPAsset(models.IPAsset{TargetID: targetID, Address: address, IsCIDR: isCIDR, IPVersion: version, Source: models.IPAssetSourceDNS})
+		if err != nil {
+			return added, true, err
+		}
+		if isNew {
+			added++
+		}
+		if err := database.LinkIPAssetDomain(id, d.ID); err != nil {
+			return added, true, err
+		}
+	}
+	return added, len(addrs) > 0, nil
+}
+
+// ipAssetLookup runs one DNS lookup with the configured timeout.
+func ipAssetLookup[T any](ctx context.Context, lookup func(ctx context.Context) (T, error)) (T, error) {
+	timeout := time.Duration(config.AppConfig.IPAssets.LookupTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return lookup(ctx)
+}
+
+// EnrichIPAsset looks up the reverse DNS names (single IPs only) and the origin ASN of an asset
+// (of its network address for a CIDR) and stores them. Lookups that find nothing are not errors;
+// the asset is only marked enriched when no lookup failed.
+func EnrichIPAsset(ctx context.Context, asset models.IPAsset) (models.IPAsset, error) {
+	prefix, ok := ipAssetPrefix(asset.Address)
+	if !ok {
+		return asset, fmt.Errorf("IP asset %d has an invalid address '%s'", asset.ID, asset.Address)
+	}
+	addr := prefix.Addr()
+	var errs []error
+
+	if !asset.IsCIDR {
+		names, err := ipAssetLookup(ctx, func(ctx context.Context) ([]string, error) {
+			return reconResolver().LookupAddr(ctx, addr.String())
+		})
+		if err != nil && !isDNSNotFound(err) {
+			errs = append(errs, fmt.Errorf("reverse lookup: %w", err))
+		}
+		for i := range names {
+			names[i] = strings.TrimSuffix(names[i], ".")
+		}
+		asset.ReverseDNS = strings.Join(names, ",")
+	}
+
+	origin, err := cymruOrigin(ctx, addr)
+	if err != nil && !isDNSNotFound(err) {
+		errs = append(errs, fmt.Errorf("ASN lookup: %w", err))
+	}
+	if origin.asn != 0 {
+		asn := origin.asn
+		asset.ASN = &asn
+		asset.ASPrefix = origin.prefix
+		asset.ASCountry = origin.country
+		org, err := cymruASOrg(ctx, origin.asn)
+		if err != nil && !isDNSNotFound(err) {
+			errs = append(errs, fmt.Errorf("AS name lookup: %w", err))
+		}
+		asset.ASOrg = org
+	}
+
+	if len(errs) > 0 {
+		return asset, fmt.Errorf("enriching %s: %w", asset.Address, errors.Join(errs...))
+	}
+	if err := database.UpdateIPAssetEnrichment(asset); err != nil {
+		return asset, err
+	}
+	return database.GetIPAssetByID(asset.ID)
+}
+
+// EnrichIPAssetByID enriches one stored IP asset.
+func EnrichIPAssetByID(ctx context.Context, id int64) (models.IPAsset, error) {
+	asset, err := database.GetIPAssetByID(id)
+	if err != nil {
+		return asset, err
+	}
+	return EnrichIPAsset(ctx, asset)
+}
+
+func isDNSNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+type cymruOriginRecord struct {
+	asn     int64
+	prefix  string
+	country string
+}
+
+// cymruOrigin queries Team Cymru's IP-to-ASN DNS service, e.g. TXT 10.2.0.192.origin.asn.cymru.com
+// answers "64496 | 192.0.2.0/24 | US | arin | 2010-01-01".
+func cymruOrigin(ctx context.Context, addr netip.Addr) (cymruOriginRecord, error) {
+	var name string
+	if addr.Is4() {
+		b := addr.As4()
+		name = fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", b[3], b[2], b[1], b[0])
+	} else {
+		b := addr.As16()
+		const hexDigits = "0123456789abcdef"
+		nibbles := make([]string, 0, 32)
+		for i := len(b) - 1; i >= 0; i-- {
+			nibbles = append(nibbles, string(hexDigits[b[i]&0x0f]), string(hexDigits[b[i]>>4]))
+		}
+		name = strings.Join(nibbles, ".") + ".origin6.asn.cymru.com"
+	}
+	records, err := ipAssetLookup(ctx, func(ctx context.Context) ([]string, error) {
+		return reconResolver().LookupTXT(ctx, name)
+	})
+	if err != nil || len(records) == 0 {
+		return cymruOriginRecord{}, err
+	}
+	fields := cymruFields(records[0])
+	if len(fields) < 3 {
+		return cymruOriginRecord{}, fmt.Errorf("unexpected origin record %q", records[0])
+	}
+	// Prefixes announced by several ASes list them all; keep the first.
+	asn, err := strconv.ParseInt(strings.Fields(fields[0])[0], 10, 64)
+	if err != nil {
+		return cymruOriginRecord{}, fmt.Errorf("unexpected ASN in origin record %q", records[0])
+	}
+	return cymruOriginRecord{asn: asn, prefix: fields[1], country: fields[2]}, nil
+}
+
+// cymruASOrg returns the AS name, e.g. TXT AS64496.asn.cymru.com answers
+// "64496 | US | arin | 2010-01-01 | EXAMPLE-AS - Example Inc., US".
+func cymruASOrg(ctx context.Context, asn int64) (string, error) {
+	records, err := ipAssetLookup(ctx, func(ctx context.Context) ([]string, error) {
+		return reconResolver().LookupTXT(ctx, fmt.Sprintf("AS%d.asn.cymru.com", asn))
+	})
+	if err != nil || len(records) == 0 {
+		return "", err
+	}
+	fields := cymruFields(records[0])
+	if len(fields) < 5 {
+		return "", fmt.Errorf("unexpected AS record %q", records[0])
+	}
+	return fields[4], nil
+}
+
+func cymruFields(record string) []string {
+	fields := strings.Split(record, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) > 0 && fields[0] == "" {
+		return nil
+	}
+	return fields
+}
+
+// hostIPCacheTTL bounds how long the proxy trusts the resolved IPs it loaded for a hostname.
+const hostIPCacheTTL = time.Minute
+
+type hostIPCacheEntry struct {
+	addrs    []netip.Addr
+	loadedAt time.Time
+}
+
+var (
+	hostIPCache     = make(map[string]hostIPCacheEntry)
+	hostIPCacheLock = &sync.Mutex{}
+)
+
+// knownHostIPs returns the IPs a hostname resolved to according to the IP asset inventory, so
+// ip_address and cidr scope rules apply to requests made by name.
+func knownHostIPs(hostname string) []netip.Addr {
+	hostname = strings.ToLower(hostname)
+	hostIPCacheLock.Lock()
+	entry, ok := hostIPCache[hostname]
+	hostIPCacheLock.Unlock()
+	if ok && time.Since(entry.loadedAt) < hostIPCacheTTL {
+		return entry.addrs
+	}
+
+	entry = hostIPCacheEntry{loadedAt: time.Now()}
+	if database.DB != nil {
+		ips, err := database.GetResolvedIPsForHost(hostname)
+		if err != nil {
+			logger.ProxyError("knownHostIPs: %v", err)
+		}
+		for _, ip := range ips {
+			if addr, err := netip.ParseAddr(ip); err == nil {
+				entry.addrs = append(entry.addrs, addr)
+			}
+		}
+	}
+	hostIPCacheLock.Lock()
+	hostIPCache[hostname] = entry
+	hostIPCacheLock.Unlock()
+	return entry.addrs
+}
+
+// resetHostIPCache drops the cached resolutions after the inventory changed.
+func resetHostIPCache() {
+	hostIPCacheLock.Lock()
+	hostIPCache = make(map[string]hostIPCacheEntry)
+	hostIPCacheLock.Unlock()
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// jobSaveInterval limits how often the progress of a running job is written to the database.
+const jobSaveInterval = time.Second
+
+// JobHandle is passed to a job function to report progress. Progress is kept in memory (served by
+// GetJob while the job runs) and written to the jobs table at most once per jobSaveInterval.
+type JobHandle struct {
+	mu        sync.Mutex
+	job       models.Job
+	cancel    context.CancelFunc
+	cancelled bool
+	started   time.Time // started_at is stored with second precision; durations use this
+	lastSaved time.Time
+}
+
+var (
+	activeJobs     = make(map[int64]*JobHandle)
+	activeJobsLock = &sync.Mutex{}
+)
+
+// StartJob records a job and runs fn in the background. The job succeeds when fn returns nil,
+// is cancelled when CancelJob was called, and fails otherwise.
+func StartJob(jobType string, targetID *int64, message string, fn func(ctx context.Context, job *JobHandle) error) (models.Job, error) {
+	job, err := database.CreateJob(jobType, targetID, message)
+	if err != nil {
+		return job, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &JobHandle{job: job, cancel: cancel, started: time.Now(), lastSaved: time.Now()}
+	activeJobsLock.Lock()
+	activeJobs[job.ID] = h
+	activeJobsLock.Unlock()
+
+	go func() {
+		defer cancel()
+		runErr := fn(ctx, h)
+		h.finish(runErr)
+		activeJobsLock.Lock()
+		delete(activeJobs, job.ID)
+		activeJobsLock.Unlock()
+	}()
+	return job, nil
+}
+
+func (h *JobHandle) finish(runErr error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	duration := now.Sub(h.started).Milliseconds()
+	h.job.FinishedAt = &now
+	h.job.DurationMs = &duration
+	switch {
+	case h.cancelled:
+		h.job.Status = models.JobStatusCancelled
+		h.job.Message = "Cancelled by user."
+	case runErr != nil:
+		h.job.Status = models.JobStatusFailed
+		h.job.LastError = runErr.Error()
+	default:
+		h.job.Status = models.JobStatusSucceeded
+	}
+	if err := database.UpdateJobProgress(h.job); err != nil {
+		logger.Error("Job %d (%s): %v", h.job.ID, h.job.Type, err)
+	}
+	logger.Info("Job %d (%s) %s after %dms: %d/%d processed, %d errors", h.job.ID, h.job.Type, h.job.Status, duration,
+		h.job.ItemsProcessed, h.job.ItemsTotal, h.job.ErrorCount)
+}
+
+// saveLocked writes the job progress when force is set or jobSaveInterval has passed.
+func (h *JobHandle) saveLocked(force bool) {
+	if !force && time.Since(h.lastSaved) < jobSaveInterval {
+		return
+	}
+	h.lastSaved = time.Now()
+	if err := database.UpdateJobProgress(h.job); err != nil {
+		logger.Error("Job %d (%s): %v", h.job.ID, h.job.Type, err)
+	}
+}
+
+// ID returns the job ID.
+func (h *JobHandle) ID() int64 {
+	return h.job.ID
+}
+
+// SetTotal sets the number of items the job will process.
+func (h *JobHandle) SetTotal(total int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.job.ItemsTotal = total
+	h.saveLocked(true)
+}
+
+// SetMessage replaces the human-readable progress message.
+func (h *JobHandle) SetMessage(format string, args ...interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.job.Message = fmt.Sprintf(format, args...)
+	h.saveLocked(false)
+}
+
+// AddProgress adds to the processed and succeeded item counters.
+func (h *JobHandle) AddProgress(processed, succeeded int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.job.ItemsProcessed += processed
+	h.job.ItemsSucceeded += succeeded
+	h.saveLocked(false)
+}
+
+// RecordError counts a non-fatal error and keeps it as the job's last error.
+func (h *JobHandle) RecordError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.job.ErrorCount++
+	h.job.LastError = err.Error()
+	h.saveLocked(false)
+}
+
+// RecordRetry counts a retried operation.
+func (h *JobHandle) RecordRetry() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.job.RetryCount++
+	h.saveLocked(false)
+}
+
+// Snapshot returns the current state of the job.
+func (h *JobHandle) Snapshot() models.Job {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.job
+}
+
+func activeJob(id int64) *JobHandle {
+	activeJobsLock.Lock()
+	defer activeJobsLock.Unlock()
+	return activeJobs[id]
+}
+
+// GetJob returns a job, with live progress while it is running.
+func GetJob(id int64) (models.Job, error) {
+	if h := activeJob(id); h != nil {
+		return h.Snapshot(), nil
+	}
+	return database.GetJobByID(id)
+}
+
+// ListJobs lists jobs matching the filters, newest first, with live progress for running jobs.
+func ListJobs(filters models.JobFilters) ([]models.Job, error) {
+	jobs, err := database.ListJobs(filters)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		if h := activeJob(jobs[i].ID); h != nil {
+			jobs[i] = h.Snapshot()
+		}
+	}
+	return jobs, nil
+}
+
+// LatestJob returns the most recent job of a type for a target, or nil when there is none.
+func LatestJob(jobType string, targetID int64) (*models.Job, error) {
+	jobs, err := ListJobs(models.JobFilters{Type: jobType, TargetID: targetID, Limit: 1})
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// CancelJob asks a running job to stop. The job records status 'cancelled' once its function returns.
+func CancelJob(id int64) error {
+	h := activeJob(id)
+	if h == nil {
+		if _, err := database.GetJobByID(id); err != nil {
+			return err
+		}
+		return fmt.Errorf("job %d is not running", id)
+	}
+	h.mu.Lock()
+	h.cancelled = true
+	h.job.Message = "Cancellation requested..."
+	h.mu.Unlock()
+	h.cancel()
+	return nil
+}
+
+// FailInterruptedJobs marks jobs left 'running' by a previous process as failed. It is called when
+// the server starts.
+func FailInterruptedJobs() {
+	n, err := database.FailInterruptedJobs()
+	if err != nil {
+		logger.Error("FailInterruptedJobs: %v", err)
+		return
+	}
+	if n > 0 {
+		logger.Info("FailInterruptedJobs: Marked %d interrupted jobs as failed.", n)
+	}
+}
@@ -0,0 +1,400 @@
+package core
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/BishopFox/jsluice"
+)
+
+// jsSourceMapSource is the X-Toolkit-Source value for source map fetches made by the pipeline.
+const jsSourceMapSource = "JS-Sourcemap"
+
+// maxJSEndpointContext bounds the code excerpt stored with each endpoint.
+const maxJSEndpointContext = 160
+
+var (
+	sourceMappingURLRegex = regexp.MustCompile(`(?m)^\s*//[#@]\s*sourceMappingURL=(\S+)\s*$`)
+
+	// Filename segments that look like bundler content hashes (main.3f2a1b9c.js, chunk-ABCD1234.js).
+	jsHashSegmentRegex = regexp.MustCompile(`^(?:[0-9a-f]{6,}|[0-9A-Z]{8,}|[0-9A-Za-z_]{16,})$`)
+
+	// domSinkRegexes find sinks that commonly lead to DOM XSS. The key is the sink name stored as the endpoint value prefix.
+	domSinkRegexes = []struct {
+		name  string
+		regex *regexp.Regexp
+	}{
+		{"innerHTML", regexp.MustCompile(`\.innerHTML\s*\+?=[^=]`)},
+		{"outerHTML", regexp.MustCompile(`\.outerHTML\s*\+?=[^=]`)},
+		{"document.write", regexp.MustCompile(`document\.write(?:ln)?\s*\(`)},
+		{"insertAdjacentHTML", regexp.MustCompile(`\.insertAdjacentHTML\s*\(`)},
+		{"eval", regexp.MustCompile(`(?:^|[^\w.$])eval\s*\(`)},
+		{"setTimeout(string)", regexp.MustCompile(`\bset(?:Timeout|Interval)\s*\(\s*["'\x60]`)},
+		{"new Function", regexp.MustCompile(`\bnew\s+Function\s*\(`)},
+		{"location assignment", regexp.MustCompile(`\blocation(?:\.href)?\s*=[^=]|\blocation\.(?:assign|replace)\s*\(`)},
+		{"jQuery.html", regexp.MustCompile(`\.html\s*\(\s*[^)\s]`)},
+		{"dangerouslySetInnerHTML", regexp.MustCompile(`dangerouslySetInnerHTML`)},
+		{"postMessage listener", regexp.MustCompile(`addEventListener\s*\(\s*["']message["']`)},
+	}
+)
+
+// JSPipelineResult summarizes what the JS analysis pipeline stored for one traffic log entry.
+type JSPipelineResult struct {
+	LogID                 int64                `json:"log_id"`
+	JSFileID              int64                `json:"js_file_id"`
+	VersionID             int64                `json:"version_id"`
+	PreviousVersionID     int64                `json:"previous_version_id,omitempty"`
+	IsNewVersion          bool                 `json:"is_new_version"`
+	EndpointCount         int                  `json:"endpoint_count"`
+	SourceMapURL          string               `json:"sourcemap_url,omitempty"`
+	SourceMapStatus       string               `json:"sourcemap_status"` // none, inline, fetched, failed
+	SourceMapSourcesCount int                  `json:"sourcemap_sources_count"`
+	Diff                  *models.JSBundleDiff `json:"diff,omitempty"` // Set when a new version replaced an earlier one
+}
+
+// sourceMap holds the parts of a source map (v3) the pipeline uses.
+type sourceMap struct {
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+// RunJSPipeline analyzes the JavaScript response of a traffic log entry. It runs the legacy
+// jsluice analysis (AnalyzeJSContent), applies the file's source map when one is available,
+// stores extracted endpoints, paths and DOM sinks in js_endpoints and, when the bundle changed
+// since the last capture, diffs the endpoints against the previous version.
+func RunJSPipeline(logID int64) (map[string][]string, *JSPipelineResult, error) {
+	logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(logEntry.ResponseBody) == 0 {
+		return nil, nil, fmt.Errorf("response body of log %d is empty", logID)
+	}
+	responseHeaders := HeadersFromJSON(logEntry.ResponseHeaders.String)
+	body, err := DecodeContentEncoding(logEntry.ResponseBody, responseHeaders.Get("Content-Encoding"))
+	if err != nil {
+		logger.Debug("RunJSPipeline: Could not decode body of log %d, analyzing as stored: %v", logID, err)
+	}
+
+	results, err := AnalyzeJSContent(body, logID)
+	if err != nil {
+		// An empty legacy analysis must not stop endpoint tracking.
+		logger.Debug("RunJSPipeline: AnalyzeJSContent for log %d: %v", logID, err)
+		results = make(map[string][]string)
+	}
+
+	var targetID sql.NullInt64
+	if logEntry.TargetID != nil {
+		targetID = sql.NullInt64{Int64: *logEntry.TargetID, Valid: true}
+	}
+	jsURL := logEntry.RequestURL.String
+
+	hash := sha256.Sum256(body)
+	version, isNew, previousVersionID, err := database.RecordJSFileCapture(targetID, JSURLKey(jsURL), jsURL, logID, hex.EncodeToString(hash[:]), int64(len(body)))
+	if err != nil {
+		return results, nil, err
+	}
+	result := &JSPipelineResult{
+		LogID:                 logID,
+		JSFileID:              version.JSFileID,
+		VersionID:             version.ID,
+		IsNewVersion:          isNew,
+		EndpointCount:         version.EndpointCount,
+		SourceMapURL:          version.SourceMapURL.String,
+		SourceMapStatus:       version.SourceMapStatus,
+		SourceMapSourcesCount: version.SourceMapSourcesCount,
+	}
+	if !isNew {
+		logger.Info("RunJSPipeline: Log %d matches stored version %d of JS file %d; endpoints unchanged", logID, version.ID, version.JSFileID)
+		return results, result, nil
+	}
+	result.PreviousVersionID = previousVersionID
+
+	endpoints := ExtractJSEndpoints(body, "")
+	smURL, smStatus, sources := loadSourceMap(body, responseHeaders, jsURL, targetID.Int64)
+	result.SourceMapURL, result.SourceMapStatus, result.SourceMapSourcesCount = smURL, smStatus, len(sources)
+	for name, content := range sources {
+		endpoints = append(endpoints, ExtractJSEndpoints([]byte(content), name)...)
+	}
+	for i := range endpoints {
+		endpoints[i].TargetID = targetID
+		endpoints[i].JSFileID = version.JSFileID
+		endpoints[i].JSFileVersionID = version.ID
+	}
+	if _, err := database.InsertJSEndpoints(endpoints); err != nil {
+		return results, result, err
+	}
+
+	result.EndpointCount = countVersionEndpoints(version.ID)
+
+	added, removed := 0, 0
+	if previousVersionID != 0 {
+		diff, err := database.DiffJSFileVersions(version.JSFileID, previousVersionID, version.ID)
+		if err != nil {
+			logger.Error("RunJSPipeline: Diffing versions %d -> %d of JS file %d: %v", previousVersionID, version.ID, version.JSFileID, err)
+		} else {
+			result.Diff = &diff
+			added, removed = len(diff.Added), len(diff.Removed)
+		}
+	}
+	if err := database.UpdateJSFileVersionResults(version.ID, smURL, smStatus, len(sources), result.EndpointCount, added, removed); err != nil {
+		logger.Error("RunJSPipeline: %v", err)
+	}
+
+	logger.Info("RunJSPipeline: Log %d stored as version %d of JS file %d: %d endpoints (source map: %s, +%d/-%d)",
+		logID, version.ID, version.JSFileID, result.EndpointCount, smStatus, added, removed)
+	return results, result, nil
+}
+
+// IsJavaScriptContentType reports whether a response Content-Type is JavaScript.
+func IsJavaScriptContentType(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return strings.Contains(ct, "javascript") || strings.Contains(ct, "ecmascript")
+}
+
+func countVersionEndpoints(versionID int64) int {
+	_, total, err := database.GetJSEndpoints(models.JSEndpointFilters{VersionID: versionID, Limit: 1})
+	if err != nil {
+		logger.Error("countVersionEndpoints: %v", err)
+		return 0
+	}
+	return int(total)
+}
+
+// ExtractJSEndpoints extracts URLs, paths and DOM sinks from JavaScript source.
+// sourceFile is recorded on each endpoint (the original file name when the source came from a source map).
+func ExtractJSEndpoints(js []byte, sourceFile string) []models.JSEndpoint {
+	var endpoints []models.JSEndpoint
+	seen := make(map[string]bool)
+	add := func(kind, value, method, context string) {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[kind+"\x00"+value] {
+			return
+		}
+		seen[kind+"\x00"+value] = true
+		endpoints = append(endpoints, models.JSEndpoint{
+			Kind:       kind,
+			Value:      value,
+			Method:     models.NullString(method),
+			SourceFile: models.NullString(sourceFile),
+			Context:    models.NullString(strings.ToValidUTF8(truncateString(context, maxJSEndpointContext), "")),
+		})
+	}
+
+	analyzer := jsluice.NewAnalyzer(js)
+	for _, u := range analyzer.GetURLs() {
+		if u == nil {
+			continue
+		}
+		lower := strings.ToLower(u.URL)
+		switch {
+		case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+			add(models.JSEndpointKindURL, u.URL, u.Method, u.Source)
+		case strings.HasPrefix(u.URL, "/") || strings.HasPrefix(u.URL, "./") || strings.HasPrefix(u.URL, "../"):
+			add(models.JSEndpointKindPath, u.URL, u.Method, u.Source)
+		}
+	}
+
+	content := string(js)
+	for _, match := range routerLinkRegexGlobal.FindAllStringSubmatch(content, -1) {
+		add(models.JSEndpointKindPath, path.Clean(match[1]), "", match[0])
+	}
+	for _, match := range generalPathRegexGlobal.FindAllStringSubmatch(content, -1) {
+		add(models.JSEndpointKindPath, path.Clean(match[1]), "", match[0])
+	}
+
+	for _, sink := range domSinkRegexes {
+		for _, loc := range sink.regex.FindAllStringIndex(content, -1) {
+			// The value is the sink statement only, so unrelated code around it doesn't make versions differ.
+			add(models.JSEndpointKindDOMSink, sink.name+": "+statementExcerpt(content, loc[0]), "", lineExcerpt(content, loc[0], loc[1]))
+		}
+	}
+	return endpoints
+}
+
+// lineExcerpt returns the trimmed text around a match, limited to its line and maxJSEndpointContext bytes.
+func lineExcerpt(content string, start, end int) string {
+	from := strings.LastIndexByte(content[:start], '\n') + 1
+	if start-from > maxJSEndpointContext/2 {
+		from = start - maxJSEndpointContext/2
+	}
+	to := len(content)
+	if nl := strings.IndexByte(content[end:], '\n'); nl >= 0 {
+		to = end + nl
+	}
+	if to-end > maxJSEndpointContext/2 {
+		to = end + maxJSEndpointContext/2
+	}
+	return strings.ToValidUTF8(strings.TrimSpace(content[from:to]), "")
+}
+
+// statementExcerpt returns the code from start up to the end of the statement (';' or newline), at most 100 bytes.
+func statementExcerpt(content string, start int) string {
+	end := len(content)
+	if i := strings.IndexAny(content[start:], ";\n"); i >= 0 {
+		end = start + i
+	}
+	return strings.ToValidUTF8(truncateString(strings.TrimSpace(strings.TrimLeft(content[start:end], "(=+ \t")), 100), "")
+}
+
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
+
+// JSURLKey identifies a JS file across captures: scheme, host and path without the query string,
+// with content-hash-like filename segments replaced by "[hash]" so new builds map to the same file.
+func JSURLKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	dir, file := path.Split(u.Path)
+	segments := strings.Split(file, ".")
+	for i := 0; i < len(segments)-1; i++ { // Keep the extension
+		parts := strings.Split(segments[i], "-")
+		for j, part := range parts {
+			if (i > 0 || j > 0) && isHashLike(part) { // The leading name itself is never replaced
+				parts[j] = "[hash]"
+			}
+		}
+		segments[i] = strings.Join(parts, "-")
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + dir + strings.Join(segments, ".")
+}
+
+func isHashLike(s string) bool {
+	return jsHashSegmentRegex.MatchString(s) && strings.ContainsAny(s, "0123456789")
+}
+
+// loadSourceMap finds and loads the source map of a JS file. It returns the map URL, the status
+// (none, inline, fetched, failed) and the original sources keyed by file name.
+func loadSourceMap(js []byte, headers http.Header, jsURL string, targetID int64) (string, string, map[string]string) {
+	conf := config.AppConfig.JSAnalysis
+	mapURL := headers.Get("SourceMap")
+	if mapURL == "" {
+		mapURL = headers.Get("X-SourceMap")
+	}
+	if mapURL == "" {
+		if matches := sourceMappingURLRegex.FindAllSubmatch(js, -1); len(matches) > 0 {
+			mapURL = string(matches[len(matches)-1][1])
+		}
+	}
+	if mapURL == "" {
+		return "", "none", nil
+	}
+
+	var data []byte
+	status := "fetched"
+	if strings.HasPrefix(mapURL, "data:") {
+		status = "inline"
+		decoded, err := decodeDataURL(mapURL)
+		if err != nil {
+			logger.Debug("loadSourceMap: Invalid inline source map in %s: %v", jsURL, err)
+			return "", "failed", nil
+		}
+		data = decoded
+		mapURL = "" // Don't store the whole data URL
+	} else {
+		if base, err := url.Parse(jsURL); err == nil {
+			if ref, err := base.Parse(mapURL); err == nil {
+				mapURL = ref.String()
+			}
+		}
+		if !conf.FetchSourceMaps {
+			return mapURL, "none", nil
+		}
+		fetched, err := fetchSourceMap(mapURL, conf.MaxSourceMapBytes, targetID)
+		if err != nil {
+			logger.Info("loadSourceMap: Could not fetch source map %s: %v", mapURL, err)
+			return mapURL, "failed", nil
+		}
+		data = fetched
+	}
+
+	var sm sourceMap
+	if err := json.Unmarshal(data, &sm); err != nil {
+		logger.Debug("loadSourceMap: Could not parse source map for %s: %v", jsURL, err)
+		return mapURL, "failed", nil
+	}
+	sources := make(map[string]string)
+	for i, name := range sm.Sources {
+		if i >= len(sm.SourcesContent) || sm.SourcesContent[i] == nil {
+			continue
+		}
+		if conf.SkipVendorSources && isVendorSource(name) {
+			continue
+		}
+		sources[name] = *sm.SourcesContent[i]
+	}
+	return mapURL, status, sources
+}
+
+func isVendorSource(name string) bool {
+	return strings.Contains(name, "node_modules/") || strings.HasPrefix(name, "webpack/") ||
+		strings.Contains(name, "webpack/bootstrap") || strings.Contains(name, "webpack/runtime")
+}
+
+func decodeDataURL(dataURL string) ([]byte, error) {
+	comma := strings.IndexByte(dataURL, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("missing ',' in data URL")
+	}
+	meta, payload := dataURL[len("data:"):comma], dataURL[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	unescaped, err := url.PathUnescape(payload)
+	return []byte(unescaped), err
+}
+
+// fetchSourceMap downloads a source map through the proxy so the request shows up in the traffic log.
+func fetchSourceMap(mapURL string, maxBytes int, targetID int64) ([]byte, error) {
+	client, err := newProxyClient(targetID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, mapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Toolkit-Initiated", "true")
+	req.Header.Set("X-Toolkit-Source", jsSourceMapSource)
+	req.Header.Set("X-Toolkit-Full-URL", mapURL)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if maxBytes <= 0 {
+		maxBytes = 20 * 1024 * 1024
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("source map larger than %d bytes", maxBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+// jwtRegex matches compact JWTs (JWS) in text: a header and payload that are base64url JSON
+// objects ("eyJ" is the encoding of `{"`) and an optional signature.
+var jwtRegex = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`)
+
+// jwtHashes maps the hash size suffix of a JWT algorithm to the hash function.
+var jwtHashes = map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+// DecodeJWT parses a compact JWT without checking its signature.
+func DecodeJWT(token string) (*models.DecodedJWT, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid JWT: expected 3 parts, got %d", len(parts))
+	}
+	decoded := &models.DecodedJWT{Signature: parts[2]}
+	if err := decodeJWTPart(parts[0], &decoded.Header); err != nil {
+		return nil, fmt.Errorf("invalid JWT header: %w", err)
+	}
+	if err := decodeJWTPart(parts[1], &decoded.Claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	decoded.Algorithm, _ = decoded.Header["alg"].(string)
+	decoded.IssuedAt = jwtTimeClaim(decoded.Claims, "iat")
+	decoded.NotBefore = jwtTimeClaim(decoded.Claims, "nbf")
+	decoded.ExpiresAt = jwtTimeClaim(decoded.Claims, "exp")
+	decoded.Expired = decoded.ExpiresAt != nil && decoded.ExpiresAt.Before(time.Now())
+	return decoded, nil
+}
+
+func decodeJWTPart(part string, v *map[string]interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // Keep large numeric IDs as they are
+	return dec.Decode(v)
+}
+
+// jwtTimeClaim returns a NumericDate claim as a time.
+func jwtTimeClaim(claims map[string]interface{}, name string) *time.Time {
+	n, ok := claims[name].(json.Number)
+	if !ok {
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(int64(f), 0).UTC()
+	return &t
+}
+
+// ForgeJWT edits the header and claims of a JWT and signs it with the requested algorithm, or
+// keeps its original signature.
+func ForgeJWT(token string, req models.JWTForgeRequest) (string, error) {
+	original, err := DecodeJWT(token)
+	if err != nil {
+		return "", err
+	}
+	header := original.Header
+	for k, v := range req.Header {
+		if v == nil {
+			delete(header, k)
+		} else {
+			header[k] = v
+		}
+	}
+	claims := original.Claims
+	if req.ReplaceClaims {
+		claims = make(map[string]interface{})
+	}
+	for k, v := range req.Claims {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	alg := req.Algorithm
+	if alg == "" {
+		alg, _ = header["alg"].(string)
+	}
+	if !req.KeepSignature {
+		// The header must name the algorithm the token is signed with; "none" keeps the casing asked for.
+		header["alg"] = alg
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("invalid header: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("invalid claims: %w", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	if req.KeepSignature {
+		return signingInput + "." + original.Signature, nil
+	}
+	signature, err := signJWT(alg, req.Key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signJWT signs the signing input of a JWT. key is the HMAC secret or a PEM private key.
+func signJWT(alg, key string, input []byte) ([]byte, error) {
+	if strings.EqualFold(alg, "none") {
+		return nil, nil
+	}
+	if len(alg) != 5 {
+		return nil, fmt.Errorf("invalid algorithm '%s': expected none, HS256/384/512, RS256/384/512, PS256/384/512 or ES256/384/512", alg)
+	}
+	hash, ok := jwtHashes[alg[2:]]
+	if !ok {
+		return nil, fmt.Errorf("invalid algorithm '%s': unsupported hash size", alg)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("invalid key: %s needs a key", alg)
+	}
+	if alg[:2] == "HS" {
+		mac := hmac.New(hash.New, []byte(key))
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	signer, err := parsePrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := signer.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %s needs an RSA private key", alg)
+		}
+		if alg[:2] == "PS" {
+			return rsa.SignPSS(rand.Reader, rsaKey, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, rsaKey, hash, digest)
+	case "ES":
+		ecKey, ok := signer.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %s needs an ECDSA private key", alg)
+		}
+		return signECDSAFixed(ecKey, digest)
+	}
+	return nil, fmt.Errorf("invalid algorithm '%s': expected none, HS256/384/512, RS256/384/512, PS256/384/512 or ES256/384/512", alg)
+}
+
+// signECDSAFixed signs a digest and returns r and s as fixed-size big-endian integers, the format
+// of JWS and XML signatures.
+func signECDSAFixed(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*size)
+	r.FillBytes(out[:size])
+	s.FillBytes(out[size:])
+	return out, nil
+}
+
+// parsePrivateKeyPEM parses an RSA or ECDSA private key in PKCS #8, PKCS #1 or SEC 1 PEM form.
+func parsePrivateKeyPEM(key string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, fmt.Errorf("invalid key: expected a PEM private key")
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := k.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("invalid key: unsupported private key type %T", k)
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("invalid key: could not parse the %s PEM block", block.Type)
+}
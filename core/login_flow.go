@@ -0,0 +1,521 @@
+package core
+
+import (
+	"bytes"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// loginFlowSkippedHeaders are the recorded request headers a login flow step does not replay:
+// cookies come from the flow's own cookie jar, and the client sets the others itself.
+// Accept-Encoding is left to the client so it decodes the responses the extractors read.
+var loginFlowSkippedHeaders = map[string]bool{
+	"Cookie": true, "Content-Length": true, "Host": true, "Connection": true, "Proxy-Connection": true,
+	"Keep-Alive": true, "Transfer-Encoding": true, "Te": true, "Upgrade": true, "Accept-Encoding": true,
+	"X-Toolkit-Initiated": true, toolkitTargetIDHeader: true,
+}
+
+// loginFlowsRunning holds the targets whose login flow is running, so that session failures
+// detected meanwhile do not start it again.
+var loginFlowsRunning = struct {
+	sync.Mutex
+	targets map[int64]bool
+}{targets: make(map[int64]bool)}
+
+// loginFlowMinTemplatedValue is the length under which a recorded extractor value is not replaced
+// with its placeholder in later steps, as it could match unrelated text.
+const loginFlowMinTemplatedValue = 4
+
+// SetLoginFlow records the given log entries, in order, as the login flow of a target, with the
+// extractors that pull tokens from their responses and the session headers built from them. The
+// values the extractors find in the recorded responses are replaced with their {{name}}
+// placeholders in the later steps, so replays send the fresh values.
+func SetLoginFlow(targetID int64, req models.LoginFlowRequest) (models.LoginFlow, error) {
+	flow := models.LoginFlow{TargetID: targetID, Extractors: req.Extractors, SessionHeaders: req.SessionHeaders, AutoRefresh: req.AutoRefresh}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return flow, err
+	}
+	if len(req.LogIDs) == 0 {
+		return flow, fmt.Errorf("invalid login flow: no log_ids provided")
+	}
+	for i, e := range req.Extractors {
+		if err := validateLoginFlowExtractor(e, len(req.LogIDs)); err != nil {
+			return flow, err
+		}
+		flow.Extractors[i].Expression = strings.TrimSpace(e.Expression)
+	}
+	for name := range req.SessionHeaders {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\r\n") {
+			return flow, fmt.Errorf("invalid session header name '%s'", name)
+		}
+	}
+	if req.SessionID != nil {
+		session, err := database.GetReplaySessionByID(*req.SessionID)
+		if err != nil {
+			return flow, err
+		}
+		if session.TargetID.Valid && session.TargetID.Int64 != targetID {
+			return flow, fmt.Errorf("invalid session_id %d: the session belongs to target %d", session.ID, session.TargetID.Int64)
+		}
+		flow.SessionID = sql.NullInt64{Int64: session.ID, Valid: true}
+	}
+	entries := make([]models.HTTPTrafficLog, len(req.LogIDs))
+	for i, logID := range req.LogIDs {
+		entry, err := database.GetHTTPTrafficLogEntryByID(logID)
+		if err != nil {
+			return flow, err
+		}
+		if entry.TargetID == nil || *entry.TargetID != targetID {
+			return flow, fmt.Errorf("invalid log_ids: log %d does not belong to target %d", logID, targetID)
+		}
+		step := models.LoginFlowStep{
+			Position:    i + 1,
+			Method:      entry.RequestMethod.String,
+			URL:         entry.RequestURL.String,
+			Headers:     make(map[string]string),
+			Body:        string(entry.RequestBody),
+			SourceLogID: sql.NullInt64{Int64: logID, Valid: true},
+		}
+		if step.Method == "" {
+			step.Method = http.MethodGet
+		}
+		for name, values := range HeadersFromJSON(entry.RequestHeaders.String) {
+			if !loginFlowSkippedHeaders[http.CanonicalHeaderKey(name)] {
+				step.Headers[name] = strings.Join(values, ", ")
+			}
+		}
+		flow.Steps = append(flow.Steps, step)
+		entries[i] = entry
+	}
+	for _, e := range flow.Extractors {
+		position := e.Step
+		if position == 0 {
+			position = len(entries)
+		}
+		recorded := entries[position-1]
+		resp := &http.Response{Header: HeadersFromJSON(recorded.ResponseHeaders.String)}
+		old, ok := extractLoginFlowValue(e, resp, recorded.ResponseBody)
+		if !ok || len(old) < loginFlowMinTemplatedValue {
+			continue
+		}
+		placeholder := "{{" + e.Name + "}}"
+		replacer := strings.NewReplacer(old, placeholder, url.QueryEscape(old), placeholder)
+		for i := position; i < len(flow.Steps); i++ {
+			s := &flow.Steps[i]
+			s.URL, s.Body = replacer.Replace(s.URL), replacer.Replace(s.Body)
+			for name, value := range s.Headers {
+				s.Headers[name] = replacer.Replace(value)
+			}
+		}
+	}
+	return database.SetLoginFlow(flow)
+}
+
+// validateLoginFlowExtractor checks an extractor of a flow with steps steps.
+func validateLoginFlowExtractor(e models.LoginFlowExtractor, steps int) error {
+	if !IsValidVariableName(e.Name) {
+		return fmt.Errorf("invalid extractor name '%s': use letters, digits, '_', '.' and '-'", e.Name)
+	}
+	if e.Step < 0 || e.Step > steps {
+		return fmt.Errorf("invalid step %d of extractor '%s': the flow has %d steps", e.Step, e.Name, steps)
+	}
+	expr := strings.TrimSpace(e.Expression)
+	if expr == "" {
+		return fmt.Errorf("invalid extractor '%s': no expression", e.Name)
+	}
+	switch e.Source {
+	case models.ExtractorSourceBodyRegex:
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid regular expression of extractor '%s': %v", e.Name, err)
+		}
+	case models.ExtractorSourceJSONPath:
+		if _, err := parseJSONPath(expr); err != nil {
+			return fmt.Errorf("invalid extractor '%s': %v", e.Name, err)
+		}
+	case models.ExtractorSourceHeader, models.ExtractorSourceCookie:
+	default:
+		return fmt.Errorf("invalid source '%s' of extractor '%s': expected body_regex, json_path, header or cookie", e.Source, e.Name)
+	}
+	return nil
+}
+
+// RunLoginFlow replays the login flow of a target with a fresh cookie jar. Each step's URL,
+// headers and body may reference target variables and the values extracted by earlier steps as
+// {{name}}. On success, the cookies the flow set and the session headers are written to the
+// flow's replay session (created on the first run) and the extracted values are stored as
+// target variables. A failed run is reported in the result's status, not as an error.
+func RunLoginFlow(targetID int64, trigger string) (models.LoginFlowRun, error) {
+	run := models.LoginFlowRun{TargetID: targetID, Trigger: trigger, Steps: []models.LoginFlowStepResult{},
+		Extracted: make(map[string]string), Cookies: []string{}}
+	flow, err := database.GetLoginFlow(targetID)
+	if err != nil {
+		return run, err
+	}
+	loginFlowsRunning.Lock()
+	if loginFlowsRunning.targets[targetID] {
+		loginFlowsRunning.Unlock()
+		return run, fmt.Errorf("the login flow of target %d is already running", targetID)
+	}
+	loginFlowsRunning.targets[targetID] = true
+	loginFlowsRunning.Unlock()
+	defer func() {
+		loginFlowsRunning.Lock()
+		delete(loginFlowsRunning.targets, targetID)
+		loginFlowsRunning.Unlock()
+	}()
+
+	started := time.Now()
+	event := models.SessionEvent{TargetID: targetID, EventType: models.SessionEventLoginRefreshed}
+	details, errRun := runLoginFlow(&flow, &run)
+	run.Status = models.LoginFlowStatusSucceeded
+	if errRun != nil {
+		run.Status, run.Error = models.LoginFlowStatusFailed, errRun.Error()
+		event.EventType, details = models.SessionEventLoginFailed, errRun.Error()
+		logger.Error("Login flow: Target %d (%s): %v", targetID, trigger, errRun)
+	} else {
+		logger.Info("Login flow: Target %d (%s): %s", targetID, trigger, details)
+	}
+	event.Details = fmt.Sprintf("%s run: %s", trigger, details)
+	event.CreatedAt = time.Now()
+	if n := len(run.Steps); n > 0 && run.Steps[n-1].LogID != 0 {
+		event.HTTPTrafficLogID = sql.NullInt64{Int64: run.Steps[n-1].LogID, Valid: true}
+	}
+	if err := database.CreateSessionEvent(event); err != nil {
+		logger.Error("Login flow: %v", err)
+	}
+	if err := database.RecordLoginFlowRun(flow.ID, started, run.Status, run.Error); err != nil {
+		logger.Error("Login flow: %v", err)
+	}
+	return run, nil
+}
+
+// runLoginFlow sends the steps of a flow, applies its extractors and updates its replay session.
+// It returns a summary of what was updated.
+func runLoginFlow(flow *models.LoginFlow, run *models.LoginFlowRun) (string, error) {
+	if len(flow.Steps) == 0 {
+		return "", fmt.Errorf("the login flow has no steps")
+	}
+	values, err := database.GetTargetVariableMap(flow.TargetID)
+	if err != nil {
+		return "", err
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return "", fmt.Errorf("creating cookie jar: %w", err)
+	}
+	// Login pages carry fresh CSRF tokens and nonces, so the steps are never answered from the cache.
+	transport := NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}, flow.TargetID))
+	transport.NoCache = true
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Jar:       jar,
+		Transport: transport,
+		// Each recorded request is a step of its own, redirects included.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	setCookies := make(map[string]string)
+	var cookieOrder []string
+	for i, step := range flow.Steps {
+		result := models.LoginFlowStepResult{Position: step.Position, Method: step.Method, URL: step.URL}
+		run.Steps = append(run.Steps, result)
+		resp, body, logID, err := sendLoginFlowStep(client, flow.TargetID, step, values)
+		run.Steps[i].LogID = logID
+		if err != nil {
+			return "", fmt.Errorf("step %d (%s %s): %w", step.Position, step.Method, step.URL, err)
+		}
+		run.Steps[i].StatusCode = resp.StatusCode
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("step %d (%s %s) returned %d", step.Position, step.Method, step.URL, resp.StatusCode)
+		}
+		for _, c := range resp.Cookies() {
+			if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(time.Now())) {
+				continue
+			}
+			if _, ok := setCookies[c.Name]; !ok {
+				cookieOrder = append(cookieOrder, c.Name)
+			}
+			setCookies[c.Name] = c.Value
+		}
+		last := i == len(flow.Steps)-1
+		for _, e := range flow.Extractors {
+			if e.Step != step.Position && !(e.Step == 0 && last) {
+				continue
+			}
+			value, ok := extractLoginFlowValue(e, resp, body)
+			if !ok {
+				return "", fmt.Errorf("extractor '%s' found no value in the response of step %d", e.Name, step.Position)
+			}
+			values[e.Name] = value
+			run.Extracted[e.Name] = value
+		}
+	}
+	sort.Strings(cookieOrder)
+	run.Cookies = append(run.Cookies, cookieOrder...)
+
+	session, err := loginFlowSession(flow)
+	if err != nil {
+		return "", err
+	}
+	run.SessionID = session.ID
+	var headerNames []string
+	for name, template := range flow.SessionHeaders {
+		value, missing := SubstituteVariables(template, values)
+		if len(missing) > 0 {
+			return "", fmt.Errorf("session header %s: no value for %s", name, strings.Join(missing, ", "))
+		}
+		session.Headers[name] = value
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	cookies := setCookieValues(session.Cookies, cookieOrder, setCookies)
+	if err := database.UpdateReplaySessionCredentials(session.ID, cookies, session.Headers); err != nil {
+		return "", err
+	}
+	for name, value := range run.Extracted {
+		if err := database.SetTargetVariableValue(flow.TargetID, name, value); err != nil {
+			return "", err
+		}
+	}
+
+	summary := fmt.Sprintf("replay session %d ('%s') updated", session.ID, session.Name)
+	var parts []string
+	if len(run.Cookies) > 0 {
+		parts = append(parts, "cookies "+strings.Join(run.Cookies, ", "))
+	}
+	if len(headerNames) > 0 {
+		parts = append(parts, "headers "+strings.Join(headerNames, ", "))
+	}
+	if len(parts) > 0 {
+		summary += " with " + strings.Join(parts, " and ")
+	}
+	return summary, nil
+}
+
+// sendLoginFlowStep substitutes the variables of a step, sends it and logs it under
+// LoginFlowLogSource. It returns the response with its full body and the log ID.
+func sendLoginFlowStep(client *http.Client, targetID int64, step models.LoginFlowStep, values map[string]string) (*http.Response, []byte, int64, error) {
+	rawURL, body := step.URL, step.Body
+	missing := SubstituteVariablesInPlace(values, &rawURL, &body)
+	headers := make(http.Header)
+	for name, value := range step.Headers {
+		var m []string
+		value, m = SubstituteVariables(value, values)
+		missing = append(missing, m...)
+		headers.Set(name, value)
+	}
+	if missing = processSlice(missing); len(missing) > 0 {
+		return nil, nil, 0, fmt.Errorf("no value for %s", strings.Join(missing, ", "))
+	}
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(step.Method, rawURL, bodyReader)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	req.Header = headers
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, probeMaxBody))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("reading response: %w", err)
+	}
+	// Storage redaction may mask the tokens in the logged copy; extractors read the original.
+	entry := logProbe(targetID, req, resp, bytes.Clone(respBody), start, models.LoginFlowLogSource)
+	return resp, respBody, entry.ID, nil
+}
+
+// extractLoginFlowValue applies an extractor to a step's response.
+func extractLoginFlowValue(e models.LoginFlowExtractor, resp *http.Response, body []byte) (string, bool) {
+	switch e.Source {
+	case models.ExtractorSourceBodyRegex:
+		re, err := regexp.Compile(e.Expression)
+		if err != nil {
+			return "", false
+		}
+		m := re.FindSubmatch(body)
+		if m == nil {
+			return "", false
+		}
+		if len(m) > 1 {
+			return string(m[1]), true
+		}
+		return string(m[0]), true
+	case models.ExtractorSourceJSONPath:
+		path, err := parseJSONPath(e.Expression)
+		if err != nil {
+			return "", false
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return "", false
+		}
+		value, ok := findJSONPath(doc, path)
+		if !ok || value == nil {
+			return "", false
+		}
+		if s, isString := value.(string); isString {
+			return s, s != ""
+		}
+		raw, err := json.Marshal(value)
+		return string(raw), err == nil
+	case models.ExtractorSourceHeader:
+		value := resp.Header.Get(e.Expression)
+		return value, value != ""
+	case models.ExtractorSourceCookie:
+		for _, c := range resp.Cookies() {
+			if c.Name == e.Expression && c.Value != "" {
+				return c.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// findJSONPath returns the first value at path in node. Keys match case-insensitively and in
+// sorted order, so the result does not depend on map iteration.
+func findJSONPath(node interface{}, path []jsonPathSegment) (interface{}, bool) {
+	if len(path) == 0 {
+		return node, true
+	}
+	seg, rest := path[0], path[1:]
+	switch v := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !seg.isIndex && (seg.key == "*" || strings.EqualFold(seg.key, k)) {
+				if found, ok := findJSONPath(v[k], rest); ok {
+					return found, true
+				}
+			}
+		}
+		if seg.recursive {
+			for _, k := range keys {
+				if found, ok := findJSONPath(v[k], path); ok {
+					return found, true
+				}
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			if seg.isIndex && (seg.index < 0 || seg.index == i) {
+				if found, ok := findJSONPath(v[i], rest); ok {
+					return found, true
+				}
+			}
+		}
+		if seg.recursive {
+			for i := range v {
+				if found, ok := findJSONPath(v[i], path); ok {
+					return found, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// loginFlowSession returns the replay session of a flow, creating one for the target when the
+// flow has none or its session was deleted.
+func loginFlowSession(flow *models.LoginFlow) (models.ReplaySession, error) {
+	if flow.SessionID.Valid {
+		session, err := database.GetReplaySessionByID(flow.SessionID.Int64)
+		if err == nil {
+			return session, nil
+		}
+		if !strings.Contains(err.Error(), "not found") {
+			return session, err
+		}
+	}
+	id, err := database.CreateReplaySession(models.ReplaySession{
+		TargetID: sql.NullInt64{Int64: flow.TargetID, Valid: true},
+		Name:     models.LoginFlowLogSource,
+		Headers:  make(map[string]string),
+	})
+	if err != nil {
+		return models.ReplaySession{}, err
+	}
+	if err := database.SetLoginFlowSession(flow.ID, id); err != nil {
+		return models.ReplaySession{}, err
+	}
+	flow.SessionID = sql.NullInt64{Int64: id, Valid: true}
+	return database.GetReplaySessionByID(id)
+}
+
+// setCookieValues sets cookies in a Cookie header value: the values of those it has are
+// replaced, the others are appended in order.
+func setCookieValues(header string, names []string, values map[string]string) string {
+	var pairs []string
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(header, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if value, ok := values[name]; ok {
+			if seen[name] {
+				continue
+			}
+			pair, seen[name] = name+"="+value, true
+		}
+		pairs = append(pairs, pair)
+	}
+	for _, name := range names {
+		if !seen[name] {
+			pairs = append(pairs, name+"="+values[name])
+		}
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// refreshLoginOnSessionEnd runs the login flow of a target whose session ended in captured
+// traffic, when the flow has auto_refresh. Session failures are debounced by
+// sessionAuthFailureWindow, which also bounds how often a failing flow is retried.
+func refreshLoginOnSessionEnd(targetID int64) {
+	flow, err := database.GetLoginFlow(targetID)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			logger.Error("Login flow: Target %d: %v", targetID, err)
+		}
+		return
+	}
+	if !flow.AutoRefresh {
+		return
+	}
+	if _, err := RunLoginFlow(targetID, models.LoginFlowTriggerAuto); err != nil {
+		logger.Info("Login flow: Target %d: %v", targetID, err)
+	}
+}
@@ -0,0 +1,252 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"toolkit/models"
+)
+
+// Wiki links in notes: [[note:12]], [[finding:5|the IDOR]], [[log:123]], [[target:3]], [[domain:7]] or [[Some Note Title]].
+var wikiLinkRegex = regexp.MustCompile(`\[\[([^\[\]|]+?)(?:\|([^\[\]]+?))?\]\]`)
+
+// wikiLinkTypes maps the prefixes accepted in wiki links to note link types.
+var wikiLinkTypes = map[string]string{
+	"note":    models.NoteLinkNote,
+	"target":  models.NoteLinkTarget,
+	"finding": models.NoteLinkFinding,
+	"log":     models.NoteLinkHTTPLog,
+	"httplog": models.NoteLinkHTTPLog,
+	"domain":  models.NoteLinkDomain,
+}
+
+var (
+	mdHeadingRegex      = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdHRRegex           = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdULRegex           = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	mdOLRegex           = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	mdTaskRegex         = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	mdLinkRegex         = regexp.MustCompile(`\[([^\]]+)\]\(([^()\s]*(?:\([^()\s]*\)[^()\s]*)*)\)`)
+	mdBoldRegex         = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdItalicRegex       = regexp.MustCompile(`\*([^*\s][^*]*?)\*|\b_([^_\s][^_]*?)_\b`)
+	mdStrikeRegex       = regexp.MustCompile(`~~(.+?)~~`)
+	mdAnchorMarkerRegex = regexp.MustCompile("\x01([0-9]+)\x01")
+	mdSafeLinkSchemes   = []string{"http://", "https://", "mailto:", "#", "/"}
+)
+
+// ParseWikiLinks returns the distinct [[...]] links in a note's markdown. Links without a known
+// type prefix refer to another note by title.
+func ParseWikiLinks(content string) []models.NoteLink {
+	var links []models.NoteLink
+	seen := make(map[string]bool)
+	for _, m := range wikiLinkRegex.FindAllStringSubmatch(content, -1) {
+		link, ok := parseWikiLinkRef(m[1])
+		if !ok {
+			continue
+		}
+		key := link.LinkType + "\x00" + strconv.FormatInt(link.LinkedID.Int64, 10) + "\x00" + strings.ToLower(link.LinkText)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// parseWikiLinkRef parses the part of a wiki link before the optional |label.
+func parseWikiLinkRef(ref string) (models.NoteLink, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return models.NoteLink{}, false
+	}
+	if prefix, idStr, found := strings.Cut(ref, ":"); found {
+		if linkType, ok := wikiLinkTypes[strings.ToLower(strings.TrimSpace(prefix))]; ok {
+			id, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(idStr), "#")), 10, 64)
+			if err != nil || id <= 0 {
+				return models.NoteLink{}, false
+			}
+			return models.NoteLink{LinkType: linkType, LinkedID: sql.NullInt64{Int64: id, Valid: true}, LinkText: ref}, true
+		}
+	}
+	return models.NoteLink{LinkType: models.NoteLinkNote, LinkText: ref}, true
+}
+
+// RenderMarkdown renders note markdown to HTML. Raw HTML in the source is escaped, link targets are
+// limited to http(s), mailto, relative and fragment URLs, and wiki links become anchors carrying
+// data-link-type / data-link-id attributes for the UI.
+func RenderMarkdown(src string) string {
+	var out strings.Builder
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var para []string
+	listTag := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			out.WriteString("</" + listTag + ">\n")
+			listTag = ""
+		}
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			out.WriteString("<" + tag + ">\n")
+			listTag = tag
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flushPara()
+			closeList()
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(trimmed[3:])
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			if lang != "" {
+				fmt.Fprintf(&out, "<pre><code class=\"language-%s\">", html.EscapeString(lang))
+			} else {
+				out.WriteString("<pre><code>")
+			}
+			out.WriteString(html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flushPara()
+			closeList()
+		case mdHeadingRegex.MatchString(trimmed):
+			flushPara()
+			closeList()
+			m := mdHeadingRegex.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", len(m[1]), renderInline(m[2]), len(m[1]))
+		case mdHRRegex.MatchString(trimmed):
+			flushPara()
+			closeList()
+			out.WriteString("<hr>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flushPara()
+			closeList()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			i--
+			out.WriteString("<blockquote>\n" + RenderMarkdown(strings.Join(quote, "\n")) + "</blockquote>\n")
+		case mdULRegex.MatchString(line):
+			flushPara()
+			openList("ul")
+			item := mdULRegex.FindStringSubmatch(line)[1]
+			if m := mdTaskRegex.FindStringSubmatch(item); m != nil {
+				checked := ""
+				if m[1] != " " {
+					checked = " checked"
+				}
+				fmt.Fprintf(&out, "<li><input type=\"checkbox\" disabled%s> %s</li>\n", checked, renderInline(m[2]))
+			} else {
+				out.WriteString("<li>" + renderInline(item) + "</li>\n")
+			}
+		case mdOLRegex.MatchString(line):
+			flushPara()
+			openList("ol")
+			out.WriteString("<li>" + renderInline(mdOLRegex.FindStringSubmatch(line)[1]) + "</li>\n")
+		default:
+			closeList()
+			para = append(para, trimmed)
+		}
+	}
+	flushPara()
+	closeList()
+	return out.String()
+}
+
+// renderInline renders inline markdown (code spans, links, emphasis, wiki links) in escaped text.
+func renderInline(text string) string {
+	// Code spans are split out first so nothing inside them is formatted.
+	parts := strings.Split(text, "`")
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 && i < len(parts)-1 {
+			b.WriteString("<code>" + html.EscapeString(part) + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			b.WriteString("`") // Unbalanced backtick
+		}
+		b.WriteString(renderInlineText(part))
+	}
+	return strings.ReplaceAll(b.String(), "\n", "<br>\n")
+}
+
+func renderInlineText(text string) string {
+	// Rendered anchors are stashed behind markers so emphasis never rewrites their URLs.
+	var anchors []string
+	stash := func(anchor string) string {
+		anchors = append(anchors, anchor)
+		return fmt.Sprintf("\x01%d\x01", len(anchors)-1)
+	}
+
+	s := html.EscapeString(text)
+	s = wikiLinkRegex.ReplaceAllStringFunc(s, func(m string) string {
+		sub := wikiLinkRegex.FindStringSubmatch(m)
+		link, ok := parseWikiLinkRef(html.UnescapeString(sub[1]))
+		if !ok {
+			return m
+		}
+		label := sub[2]
+		if label == "" {
+			label = sub[1]
+		}
+		attrs := fmt.Sprintf(`class="wiki-link" data-link-type="%s"`, link.LinkType)
+		href := "#"
+		if link.LinkedID.Valid {
+			attrs += fmt.Sprintf(` data-link-id="%d"`, link.LinkedID.Int64)
+			switch link.LinkType {
+			case models.NoteLinkHTTPLog:
+				href = fmt.Sprintf("#proxy-log-detail?id=%d", link.LinkedID.Int64)
+			case models.NoteLinkDomain:
+				href = fmt.Sprintf("#domain-detail?id=%d", link.LinkedID.Int64)
+			}
+		} else {
+			attrs += ` data-link-title="` + html.EscapeString(link.LinkText) + `"`
+		}
+		return stash(fmt.Sprintf(`<a href="%s" %s>%s</a>`, href, attrs, strings.TrimSpace(label)))
+	})
+	s = mdLinkRegex.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdLinkRegex.FindStringSubmatch(m)
+		target := html.UnescapeString(sub[2])
+		safe := false
+		for _, scheme := range mdSafeLinkSchemes {
+			if strings.HasPrefix(strings.ToLower(target), scheme) {
+				safe = true
+				break
+			}
+		}
+		if !safe {
+			return sub[1]
+		}
+		return stash(fmt.Sprintf(`<a href="%s" rel="noopener noreferrer">%s</a>`, html.EscapeString(target), sub[1]))
+	})
+	s = mdBoldRegex.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = mdItalicRegex.ReplaceAllString(s, "<em>$1$2</em>")
+	s = mdStrikeRegex.ReplaceAllString(s, "<del>$1</del>")
+	return mdAnchorMarkerRegex.ReplaceAllStringFunc(s, func(m string) string {
+		idx, _ := strconv.Atoi(strings.Trim(m, "\x01"))
+		return anchors[idx]
+	})
+}
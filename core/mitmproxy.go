@@ -3,6 +3,9 @@ package core
 import (
 	"bytes"
 	"context" // Added for graceful shutdown
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -13,7 +16,6 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io"
-	"log" // Standard log package for goproxy.Logger config
 	"math/big"
 	"net"
 	"net/http"
@@ -21,7 +23,6 @@ import (
 	"os"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 	"toolkit/config"
 	"toolkit/database"
@@ -31,26 +32,12 @@ import (
 	"strconv"
 
 	"github.com/elazarl/goproxy"
-	"github.com/tidwall/gjson"
 )
 
 var (
-	caCert                *x509.Certificate
-	caKey                 *rsa.PrivateKey
-	goproxyTLSConfig      *tls.Config // Exported for use by other modules
-	sessionIsHTTPS        = make(map[int64]bool)
-	muSession             sync.Mutex
-	parsedSynackTargetURL *url.URL
-	parseURLErr           error
-
-	activeTargetID        *int64
-	allActiveScopeRules   []models.ScopeRule
-	scopeMu               sync.RWMutex
-	globalExclusionRules  []models.ProxyExclusionRule
-	activeRecordingPageID *int64
-	// Added for rate limiting analytics calls
-	globalMissionService *SynackMissionService // To make mission service available to proxy handlers
-	analyticsFetchTicker *time.Ticker
+	caCert           *x509.Certificate
+	caKey            crypto.Signer // *rsa.PrivateKey or *ecdsa.PrivateKey
+	goproxyTLSConfig *tls.Config   // Exported for use by other modules
 )
 
 // GetProxyClientTLSConfig returns a *tls.Config that trusts the proxy's CA.
@@ -66,40 +53,31 @@ func GetProxyAddress() string {
 
 // proxyRequestContextData holds data passed between request and response handlers via ctx.UserData
 type proxyRequestContextData struct {
-	TrafficLog      *models.HTTPTrafficLog
-	SynackAuthToken string // To store the Bearer token from Synack target list request
+	TrafficLog       *models.HTTPTrafficLog
+	PlatformProvider PlatformProvider // Provider whose traffic URL the request matched, if any
+	RateLimitRelease func()           // Frees the rate limiter slots taken for passthrough traffic, if any
+	SampleByResponse bool             // In performance mode, sample the response if it turns out to be a static asset
+	ScriptTags       []string         // Tags proxy scripts asked for on the log entry
 }
 
 // SetActivePageSitemapRecordingID is called by the Page Sitemap feature to indicate a recording has started.
 func SetActivePageSitemapRecordingID(pageID int64) {
-	// Using scopeMu for simplicity, or create a new mutex for this
-	scopeMu.Lock()
-	defer scopeMu.Unlock()
 	// Allow clearing by passing 0
 	if pageID == 0 {
-		activeRecordingPageID = nil
+		proxyState.SetRecordingPageID(nil)
 		logger.ProxyInfo("Page Sitemap recording stopped (or no active page).")
 	} else {
-		activeRecordingPageID = &pageID
+		proxyState.SetRecordingPageID(&pageID)
 		logger.ProxyInfo("Page Sitemap recording started for Page ID: %d", pageID)
 	}
 }
 
 // ClearActivePageSitemapRecordingID is called when a recording stops.
 func ClearActivePageSitemapRecordingID() {
-	scopeMu.Lock()
-	defer scopeMu.Unlock()
-	activeRecordingPageID = nil
+	proxyState.SetRecordingPageID(nil)
 	logger.ProxyInfo("Page Sitemap recording explicitly stopped.")
 }
 
-func init() {
-	// Note: config.AppConfig might not be fully populated during Go's init phase.
-	// It's better to parse this lazily or after config is loaded.
-	// We will parse it inside StartMitmProxy instead.
-	analyticsFetchTicker = time.NewTicker(1 * time.Second)
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -120,12 +98,16 @@ func setGoproxyCA(loadedGoproxyCa *tls.Certificate) {
 	logger.ProxyInfo("goproxy CA configured (Simplified Mode - Direct Action Construction).")
 }
 
-func GenerateAndSaveCA(certPath, keyPath string) error {
-	var localCaCert *x509.Certificate
-	var localCaKey *rsa.PrivateKey
-	var err error
+// CA key types supported by GenerateAndSaveCA.
+const (
+	CAKeyTypeRSA   = "rsa"   // RSA-2048
+	CAKeyTypeECDSA = "ecdsa" // ECDSA P-256; leaf certificates get P-256 keys too, which makes handshakes cheaper
+)
 
-	localCaCert, localCaKey, err = generateCA("toolkit MITM Proxy CA")
+// GenerateAndSaveCA generates a CA of the given key type (rsa or ecdsa; empty means rsa) and saves
+// its certificate and PKCS#8 key as PEM.
+func GenerateAndSaveCA(certPath, keyPath, keyType string) error {
+	localCaCert, localCaKey, err := generateCA("toolkit MITM Proxy CA", keyType)
 	if err != nil {
 		logger.Error("Failed to generate CA: %v", err)
 		return fmt.Errorf("failed to generate CA: %w", err)
@@ -151,13 +133,16 @@ func GenerateAndSaveCA(certPath, keyPath string) error {
 	defer keyOut.Close()
 
 	privBytes, err := x509.MarshalPKCS8PrivateKey(localCaKey)
-	if err != nil {
+	if rsaKey, isRSA := localCaKey.(*rsa.PrivateKey); err != nil && isRSA {
 		logger.ProxyInfo("Warning: could not marshal private key to PKCS8: %v. Trying PKCS1.", err)
-		privBytes = x509.MarshalPKCS1PrivateKey(localCaKey)
+		privBytes = x509.MarshalPKCS1PrivateKey(rsaKey)
 		if err := pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: privBytes}); err != nil {
 			logger.Error("Failed to write CA RSA private key to %s: %v", keyPath, err)
 			return fmt.Errorf("failed to write CA RSA private key to %s: %w", keyPath, err)
 		}
+	} else if err != nil {
+		logger.Error("Failed to marshal CA private key: %v", err)
+		return fmt.Errorf("failed to marshal CA private key: %w", err)
 	} else {
 		if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}); err != nil {
 			logger.Error("Failed to write CA private key to %s: %v", keyPath, err)
@@ -168,6 +153,64 @@ func GenerateAndSaveCA(certPath, keyPath string) error {
 	return nil
 }
 
+// RotateCA moves the current CA certificate and key aside (suffixed with the rotation time) and
+// generates a new CA in their place. A running proxy keeps signing with the old CA until it is
+// restarted. It returns the paths of the backups.
+func RotateCA(certPath, keyPath, keyType string) (certBackup, keyBackup string, err error) {
+	suffix := ".rotated-" + time.Now().Format("20060102-150405")
+	for _, path := range []string{certPath, keyPath} {
+		if _, err := os.Stat(path); err != nil {
+			return "", "", fmt.Errorf("cannot rotate CA, %s is missing: %w", path, err)
+		}
+	}
+	certBackup, keyBackup = certPath+suffix, keyPath+suffix
+	if err := os.Rename(certPath, certBackup); err != nil {
+		return "", "", fmt.Errorf("backing up CA certificate: %w", err)
+	}
+	if err := os.Rename(keyPath, keyBackup); err != nil {
+		os.Rename(certBackup, certPath)
+		return "", "", fmt.Errorf("backing up CA key: %w", err)
+	}
+	if err := GenerateAndSaveCA(certPath, keyPath, keyType); err != nil {
+		os.Rename(certBackup, certPath)
+		os.Rename(keyBackup, keyPath)
+		return "", "", err
+	}
+	return certBackup, keyBackup, nil
+}
+
+// WriteCACertificate serves the proxy's CA certificate for installation on a client, as PEM or, with
+// format "der", as DER (which some Android and Windows versions expect).
+func WriteCACertificate(w http.ResponseWriter, format string) {
+	certPEM, err := os.ReadFile(config.AppConfig.Proxy.CACertPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "CA certificate not found, run 'proxy init-ca' first", http.StatusNotFound)
+			return
+		}
+		logger.Error("WriteCACertificate: %v", err)
+		http.Error(w, "Failed to read CA certificate", http.StatusInternalServerError)
+		return
+	}
+	switch format {
+	case "", "pem":
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="toolkit-ca.crt"`)
+		w.Write(certPEM)
+	case "der":
+		block, _ := pem.Decode(certPEM)
+		if block == nil {
+			http.Error(w, "Failed to decode CA certificate", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="toolkit-ca.cer"`)
+		w.Write(block.Bytes)
+	default:
+		http.Error(w, "Invalid format, expected 'pem' or 'der'", http.StatusBadRequest)
+	}
+}
+
 func loadCA(certPath, keyPath string) error {
 	certPEMBlock, err := os.ReadFile(certPath)
 	if err != nil {
@@ -184,7 +227,6 @@ func loadCA(certPath, keyPath string) error {
 		logger.Error("Failed to parse CA certificate from %s: %v", certPath, err)
 		return fmt.Errorf("failed to parse CA certificate from %s: %w", certPath, err)
 	}
-	caCert = loadedCaCert
 
 	keyPEMBlock, err := os.ReadFile(keyPath)
 	if err != nil {
@@ -198,11 +240,14 @@ func loadCA(certPath, keyPath string) error {
 	}
 
 	var parsedKey interface{}
-	if keyDERBlock.Type == "PRIVATE KEY" {
+	switch keyDERBlock.Type {
+	case "PRIVATE KEY":
 		parsedKey, err = x509.ParsePKCS8PrivateKey(keyDERBlock.Bytes)
-	} else if keyDERBlock.Type == "RSA PRIVATE KEY" {
+	case "RSA PRIVATE KEY":
 		parsedKey, err = x509.ParsePKCS1PrivateKey(keyDERBlock.Bytes)
-	} else {
+	case "EC PRIVATE KEY":
+		parsedKey, err = x509.ParseECPrivateKey(keyDERBlock.Bytes)
+	default:
 		logger.Error("Unknown CA key PEM block type '%s' from %s", keyDERBlock.Type, keyPath)
 		return fmt.Errorf("unknown CA key PEM block type '%s' from %s", keyDERBlock.Type, keyPath)
 	}
@@ -212,22 +257,52 @@ func loadCA(certPath, keyPath string) error {
 		return fmt.Errorf("failed to parse CA private key from %s (type %s): %w", keyPath, keyDERBlock.Type, err)
 	}
 
-	var ok bool
-	loadedCaKey, ok := parsedKey.(*rsa.PrivateKey)
-	if !ok {
-		logger.Error("CA key from %s is not an RSA private key after parsing type %s", keyPath, keyDERBlock.Type)
-		return fmt.Errorf("CA key from %s is not an RSA private key after parsing type %s", keyPath, keyDERBlock.Type)
+	var loadedCaKey crypto.Signer
+	switch key := parsedKey.(type) {
+	case *rsa.PrivateKey:
+		loadedCaKey = key
+	case *ecdsa.PrivateKey:
+		loadedCaKey = key
+	default:
+		logger.Error("CA key from %s is not an RSA or ECDSA private key (%T)", keyPath, parsedKey)
+		return fmt.Errorf("CA key from %s is not an RSA or ECDSA private key (%T)", keyPath, parsedKey)
+	}
+	if !publicKeysEqual(loadedCaCert.PublicKey, loadedCaKey.Public()) {
+		logger.Error("CA key from %s does not match the certificate %s", keyPath, certPath)
+		return fmt.Errorf("CA key from %s does not match the certificate %s", keyPath, certPath)
 	}
+	caCert = loadedCaCert
 	caKey = loadedCaKey
 
-	logger.ProxyInfo("CA certificate and key loaded successfully.")
+	logger.ProxyInfo("CA certificate and key (%s) loaded successfully.", caKeyType(caKey))
 	return nil
 }
 
-func generateCA(commonName string) (*x509.Certificate, *rsa.PrivateKey, error) {
-	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+func caKeyType(key crypto.Signer) string {
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		return CAKeyTypeECDSA
+	}
+	return CAKeyTypeRSA
+}
+
+func generateCA(commonName, keyType string) (*x509.Certificate, crypto.Signer, error) {
+	var privKey crypto.Signer
+	var err error
+	switch strings.ToLower(keyType) {
+	case "", CAKeyTypeRSA:
+		privKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case CAKeyTypeECDSA:
+		privKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, nil, fmt.Errorf("unsupported CA key type '%s' (rsa or ecdsa)", keyType)
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate RSA private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate %s private key: %w", keyType, err)
 	}
 
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
@@ -250,7 +325,7 @@ func generateCA(commonName string) (*x509.Certificate, *rsa.PrivateKey, error) {
 		IsCA:                  true,
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privKey.PublicKey, privKey)
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, privKey.Public(), privKey)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
 	}
@@ -339,6 +414,14 @@ func matchesRule(requestURL *url.URL, hostname, path string, rule models.ScopeRu
 		// IP addresses are often used as hostnames
 		if hostname == pattern {
 			match = true
+		} else if ruleIP := net.ParseIP(pattern); ruleIP != nil && net.ParseIP(hostname) == nil {
+			// Requests made by name match when the inventory says the name resolves to the IP.
+			for _, addr := range knownHostIPs(hostname) {
+				if ruleIP.Equal(net.IP(addr.AsSlice())) {
+					match = true
+					break
+				}
+			}
 		}
 	case "cidr":
 		_, cidrNet, err := net.ParseCIDR(pattern)
@@ -346,6 +429,13 @@ func matchesRule(requestURL *url.URL, hostname, path string, rule models.ScopeRu
 			ip := net.ParseIP(hostname)
 			if ip != nil && cidrNet.Contains(ip) {
 				match = true
+			} else if ip == nil {
+				for _, addr := range knownHostIPs(hostname) {
+					if cidrNet.Contains(net.IP(addr.AsSlice())) {
+						match = true
+						break
+					}
+				}
 			}
 		}
 	}
@@ -356,22 +446,39 @@ func matchesGlobalExclusionRule(requestURL *url.URL, rule models.ProxyExclusionR
 	if !rule.IsEnabled || requestURL == nil {
 		return false
 	}
+	match, err := exclusionRuleMatches(requestURL, rule)
+	if err != nil {
+		// A broken rule shouldn't match anything
+		logger.ProxyError("Global exclusion rule ID %s: %v", rule.ID, err)
+	}
+	return match
+}
 
-	// Use the existing matchesRule function, but we need to ensure the inputs are correct.
-	// For global exclusions, the 'pattern' is directly from rule.Pattern.
-
-	// Normalize URL for logging and consistent matching if rule.RuleType is 'url_regex'
-	normalizedFullURL := requestURL.String() // Default to original if normalization fails or not needed
-	if rule.RuleType == "url_regex" {
-		if normURL, err := normalizeURL(requestURL.String()); err == nil {
-			normalizedFullURL = normURL
+// screenProxyRequest applies the program constraints of the target a toolkit request is in scope
+// of, then the global exclusion rules. The constraints come first, so an excluded request obeys
+// them too. resp answers a blocked request, which is not logged; excluded tells that the request
+// is forwarded without being logged.
+func screenProxyRequest(r *http.Request, targetID *int64, inScope bool) (resp *http.Response, excluded bool) {
+	if inScope && r.Header.Get("X-Toolkit-Initiated") == "true" {
+		if resp := enforceProgramConstraints(r, *targetID, toolkitLogSource(r.Header.Get("X-Toolkit-Source"), r.Header.Get(replayBatchIDHeader) != "")); resp != nil {
+			return resp, false
 		}
 	}
+	for _, rule := range proxyState.ExclusionRules() {
+		if matchesGlobalExclusionRule(r.URL, rule) {
+			if !ReadOnly() {
+				recordExclusionHit(rule.ID)
+			}
+			logger.ProxyInfo("REQ: %s %s - GLOBALLY EXCLUDED by rule ID %s (Type: %s, Pattern: %s). Skipping.", r.Method, r.URL.String(), rule.ID, rule.RuleType, rule.Pattern)
+			return nil, true
+		}
+	}
+	return nil, false
+}
 
-	hostname := requestURL.Hostname()
-	path := requestURL.Path
-	// fullURL := requestURL.String() // This was used before, now normalizedFullURL is preferred for regex
-
+// exclusionRuleMatches reports whether the pattern of a global exclusion rule matches a URL,
+// whether or not the rule is enabled. It fails for rules that can never match.
+func exclusionRuleMatches(requestURL *url.URL, rule models.ProxyExclusionRule) (bool, error) {
 	switch rule.RuleType {
 	case "file_extension":
 		pattern := rule.Pattern
@@ -379,26 +486,22 @@ func matchesGlobalExclusionRule(requestURL *url.URL, rule models.ProxyExclusionR
 		if !strings.HasPrefix(pattern, ".") {
 			pattern = "." + pattern
 		}
-		if strings.HasSuffix(strings.ToLower(path), strings.ToLower(pattern)) {
-			return true
-		}
+		return strings.HasSuffix(strings.ToLower(requestURL.Path), strings.ToLower(pattern)), nil
 	case "url_regex":
-		pattern := rule.Pattern
-		// Consider compiling regexes once at startup if performance becomes an issue
-		re, err := regexp.Compile(pattern)
+		re, err := proxyState.ExclusionRegex(rule)
 		if err != nil {
-			logger.ProxyError("Invalid regex pattern in global exclusion rule ID %s: %s", rule.ID, pattern)
-			// Invalid regex shouldn't match anything
-			return false
+			return false, fmt.Errorf("invalid regex pattern %s: %w", rule.Pattern, err)
 		}
-		if re.MatchString(normalizedFullURL) { // Use normalizedFullURL for regex matching
-			return true
+		// Match against the normalized URL, falling back to the original if normalization fails
+		normalizedFullURL := requestURL.String()
+		if normURL, err := normalizeURL(requestURL.String()); err == nil {
+			normalizedFullURL = normURL
 		}
+		return re.MatchString(normalizedFullURL), nil
 	case "domain":
-		pattern := rule.Pattern
-		return strings.ToLower(hostname) == strings.ToLower(pattern)
+		return strings.EqualFold(requestURL.Hostname(), rule.Pattern), nil
 	}
-	return false
+	return false, fmt.Errorf("unknown rule type %q", rule.RuleType)
 }
 
 func isRequestEffectivelyInScope(requestURL *url.URL, allRules []models.ScopeRule) bool {
@@ -468,8 +571,9 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 		Leaf:        caCert,
 	})
 	goproxyTLSConfig.RootCAs.AddCert(caCert) // Add the CA cert to the pool for the client TLS config
+	configureLeafCertCache()
 
-	scopeMu.Lock()
+	var activeTargetID *int64
 	if cliTargetID != 0 {
 		activeTargetID = &cliTargetID
 		logger.ProxyInfo("Proxy started with explicit target ID from CLI: %d", *activeTargetID)
@@ -492,6 +596,7 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 		}
 	}
 
+	var allActiveScopeRules []models.ScopeRule
 	if activeTargetID != nil && *activeTargetID != 0 {
 		var err error
 		allActiveScopeRules, err = database.GetAllScopeRulesForTarget(*activeTargetID)
@@ -501,82 +606,104 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 			logger.ProxyInfo("Loaded %d total scope rules for target %d.", len(allActiveScopeRules), *activeTargetID)
 		}
 	}
-	scopeMu.Unlock()
+	proxyState.SetTarget(activeTargetID, allActiveScopeRules)
 
-	var errLoadExclusions error
-	globalExclusionRules, errLoadExclusions = database.GetProxyExclusionRules()
+	globalExclusionRules, errLoadExclusions := database.GetProxyExclusionRules()
 	if errLoadExclusions != nil {
 		logger.ProxyError("Failed to load global proxy exclusion rules: %v. Proxy will not apply global exclusions.", errLoadExclusions)
 		globalExclusionRules = []models.ProxyExclusionRule{}
 	} else {
 		logger.ProxyInfo("Loaded %d global proxy exclusion rules.", len(globalExclusionRules))
 	}
+	proxyState.SetExclusionRules(globalExclusionRules)
 
-	if config.AppConfig.Synack.TargetsURL != "" {
-		parsedSynackTargetURL, parseURLErr = url.Parse(config.AppConfig.Synack.TargetsURL)
-		if parseURLErr != nil {
-			logger.Error("Failed to parse configured Synack TargetsURL '%s': %v. Synack processing disabled.", config.AppConfig.Synack.TargetsURL, parseURLErr)
-			parsedSynackTargetURL = nil
-		} else {
-			logger.ProxyInfo("Parsed Synack Target URL for matching: Scheme=%s, Host=%s, Path=%s", parsedSynackTargetURL.Scheme, parsedSynackTargetURL.Host, parsedSynackTargetURL.Path)
-		}
-	} else {
-		parsedSynackTargetURL = nil
-	}
-
-	globalMissionService = missionService
+	ConfigurePlatformProviders(missionService)
 
 	proxy := goproxy.NewProxyHttpServer()
-	proxy.Logger = log.New(io.Discard, "", 0)
+	proxy.Logger = proxyLogger{}
+	// Servers offering only TLS 1.0 or 1.1 stay reachable through the proxy; the TLS report flags them.
+	upstreamTLS := proxy.Tr.TLSClientConfig.Clone()
+	upstreamTLS.MinVersion = tls.VersionTLS10
+	proxy.Tr.TLSClientConfig = upstreamTLS
+	if upstream := config.AppConfig.Proxy.UpstreamProxy; upstream != "" {
+		if err := useUpstreamProxy(proxy, upstream); err != nil {
+			return err
+		}
+	}
+	proxy.CertStore = proxyLeafCerts
+	// Plain requests to the proxy itself (not proxied) can download the CA and the iOS profile, so
+	// devices using the proxy can install them from http://<proxy>/proxy/ca.crt.
+	proxy.NonproxyHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/proxy/ca.crt":
+			WriteCACertificate(w, r.URL.Query().Get("format"))
+		case "/proxy/mobileconfig":
+			WriteMobileConfig(w, r)
+		default:
+			http.Error(w, "This is the toolkit proxy. Download its CA certificate at /proxy/ca.crt (iOS profile: /proxy/mobileconfig).", http.StatusNotFound)
+		}
+	})
 
 	proxy.OnRequest().HandleConnect(goproxy.FuncHttpsHandler(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
-		muSession.Lock()
-		sessionIsHTTPS[ctx.Session] = true
-		muSession.Unlock()
+		proxyState.MarkSessionHTTPS(ctx.Session)
 		logger.ProxyDebug("HandleConnect for session %d, host %s", ctx.Session, host)
-		return &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: goproxy.TLSConfigFromCA(&goproxy.GoproxyCa)}, host
+		if ctx.Req.Header.Get(interceptModeHeader) == interceptModeTunnel {
+			return goproxy.OkConnect, host
+		}
+		return &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: mitmTLSConfig}, host
 	}))
 
 	proxy.OnRequest().DoFunc(
 		func(r *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
 			startTime := time.Now()
+			markMITMHandshakeSucceeded(ctx)
+			overrideTargetID, overrideScopeRules, targetOverridden := takeToolkitTargetOverride(r)
+			platformProvider := matchPlatformProvider(r.URL)
 
-			for _, rule := range globalExclusionRules {
-				if matchesGlobalExclusionRule(r.URL, rule) {
-					logger.ProxyInfo("REQ: %s %s - GLOBALLY EXCLUDED by rule ID %s (Type: %s, Pattern: %s). Skipping.", r.Method, r.URL.String(), rule.ID, rule.RuleType, rule.Pattern)
-					return r, nil
-				}
+			currentTargetIDForLog, currentAllScopeRules := proxyState.Target()
+			if listener := requestProxyListener(r, ctx); listener != nil {
+				currentTargetIDForLog, currentAllScopeRules = listener.Target()
 			}
-
-			scopeMu.RLock()
-			isSynackTargetListURL := false
-			if parsedSynackTargetURL != nil && r.URL != nil {
-				reqPathNorm := strings.TrimRight(r.URL.Path, "/")
-				configPathNorm := strings.TrimRight(parsedSynackTargetURL.Path, "/")
-				if r.URL.Scheme == parsedSynackTargetURL.Scheme &&
-					r.URL.Hostname() == parsedSynackTargetURL.Hostname() &&
-					reqPathNorm == configPathNorm {
-					isSynackTargetListURL = true
-				}
+			if targetOverridden {
+				logger.ProxyDebug("REQ: %s %s - Logged against target %d (%s).", r.Method, r.URL.String(), *overrideTargetID, toolkitTargetIDHeader)
+				currentTargetIDForLog, currentAllScopeRules = overrideTargetID, overrideScopeRules
 			}
+			hasTarget := currentTargetIDForLog != nil && *currentTargetIDForLog != 0
+			inScope := hasTarget && isRequestEffectivelyInScope(r.URL, currentAllScopeRules)
 
-			currentTargetIDForLog := activeTargetID
-			currentAllScopeRules := allActiveScopeRules
-			scopeMu.RUnlock()
+			if resp, excluded := screenProxyRequest(r, currentTargetIDForLog, inScope); resp != nil {
+				return r, resp
+			} else if excluded {
+				return r, nil
+			}
+			if inScope && r.Header.Get("X-Toolkit-Initiated") == "true" {
+				startTime = time.Now() // After waiting for the slot under the program's max_rps
+			}
 
-			if currentTargetIDForLog != nil && *currentTargetIDForLog != 0 {
-				if !isRequestEffectivelyInScope(r.URL, currentAllScopeRules) {
-					logger.ProxyDebug("REQ: %s %s (HTTPS: %t) - OUT OF SCOPE for active target %d.", r.Method, r.URL.String(), sessionIsHTTPS[ctx.Session], *currentTargetIDForLog)
-					if !isSynackTargetListURL {
+			if hasTarget {
+				if !inScope {
+					logger.ProxyDebug("REQ: %s %s (HTTPS: %t) - OUT OF SCOPE for active target %d.", r.Method, r.URL.String(), proxyState.IsSessionHTTPS(ctx.Session), *currentTargetIDForLog)
+					if platformProvider == nil {
 						return r, nil
 					}
-					logger.ProxyDebug("Synack target list URL is out of scope for active target %d, but will be processed with no target association.", *currentTargetIDForLog)
+					logger.ProxyDebug("%s traffic URL is out of scope for active target %d, but will be processed with no target association.", platformProvider.Name(), *currentTargetIDForLog)
 					currentTargetIDForLog = nil
 				} else {
-					logger.ProxyDebug("REQ: %s %s (HTTPS: %t) - IN SCOPE for active target %d.", r.Method, r.URL.String(), sessionIsHTTPS[ctx.Session], *currentTargetIDForLog)
+					logger.ProxyDebug("REQ: %s %s (HTTPS: %t) - IN SCOPE for active target %d.", r.Method, r.URL.String(), proxyState.IsSessionHTTPS(ctx.Session), *currentTargetIDForLog)
 				}
-			} else if isSynackTargetListURL {
-				logger.ProxyDebug("Processing Synack target list URL with no active target.")
+			} else if platformProvider != nil {
+				logger.ProxyDebug("Processing %s traffic URL with no active target.", platformProvider.Name())
+			}
+
+			dropped, scriptTags := applyRequestScripts(r, currentTargetIDForLog)
+			if dropped != nil {
+				return r, dropped
+			}
+
+			sampleClass, sampleByResponse := proxySampleClass(r, currentTargetIDForLog, platformProvider)
+			if sampleClass != "" && !sampleProxyRequest(sampleClass) {
+				logger.ProxyDebug("REQ: %s %s - Not logged (performance mode, %s sample).", r.Method, r.URL.String(), sampleClass)
+				return r, nil
 			}
 
 			reqBodyBytes, errReadReq := io.ReadAll(r.Body)
@@ -586,15 +713,25 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 			r.Body.Close()
 			r.Body = io.NopCloser(bytes.NewBuffer(reqBodyBytes))
 
+			var replayBatchID, replaySourceLogID sql.NullInt64
+			if r.Header.Get("X-Toolkit-Initiated") == "true" {
+				if id, errParse := strconv.ParseInt(r.Header.Get(replayBatchIDHeader), 10, 64); errParse == nil {
+					replayBatchID = sql.NullInt64{Int64: id, Valid: true}
+				}
+				if id, errParse := strconv.ParseInt(r.Header.Get(replaySourceLogIDHeader), 10, 64); errParse == nil {
+					replaySourceLogID = sql.NullInt64{Int64: id, Valid: true}
+				}
+			}
+			r.Header.Del(replayBatchIDHeader)
+			r.Header.Del(replaySourceLogIDHeader)
+
 			reqHeadersMap := make(map[string][]string)
 			for k, v := range r.Header {
 				reqHeadersMap[k] = v
 			}
 			reqHeadersJson, _ := json.Marshal(reqHeadersMap)
 
-			muSession.Lock()
-			isCurrentSessionHTTPS := sessionIsHTTPS[ctx.Session]
-			muSession.Unlock()
+			isCurrentSessionHTTPS := proxyState.IsSessionHTTPS(ctx.Session)
 
 			if r.Method == http.MethodConnect {
 				isCurrentSessionHTTPS = true
@@ -610,20 +747,17 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 				logger.ProxyDebug("X-Toolkit-Full-URL found: %s. Server-seen URL: %s", toolkitFullURL, serverSeenURL)
 			}
 
-			logSource := "mitmproxy"
+			logSource := models.LogSourceProxy
 			var pageIDForLog sql.NullInt64
 
-			if r.Header.Get("X-Toolkit-Source") == "JS-Path-Sender" {
-				logSource = "JS Analysis"
+			if r.Header.Get("X-Toolkit-Initiated") == "true" {
+				// The toolkit's own requests are logged as automated traffic, never as browsing.
+				logSource = toolkitLogSource(r.Header.Get("X-Toolkit-Source"), replayBatchID.Valid)
+			} else if recordingPageID := proxyState.RecordingPageID(); recordingPageID != nil {
+				logSource = models.LogSourcePageSitemap
+				pageIDForLog = sql.NullInt64{Int64: *recordingPageID, Valid: true}
 			}
 
-			scopeMu.RLock()
-			if activeRecordingPageID != nil {
-				logSource = "Page Sitemap"
-				pageIDForLog = sql.NullInt64{Int64: *activeRecordingPageID, Valid: true}
-			}
-			scopeMu.RUnlock()
-
 			requestData := &models.HTTPTrafficLog{
 				TargetID:                   currentTargetIDForLog,
 				Timestamp:                  startTime,
@@ -639,20 +773,27 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 
 			requestData.LogSource = models.NullString(logSource)
 			requestData.PageSitemapID = pageIDForLog
-			ctxData := &proxyRequestContextData{TrafficLog: requestData}
-
-			if isSynackTargetListURL {
-				authToken := r.Header.Get("Authorization")
-				if strings.HasPrefix(authToken, "Bearer ") {
-					ctxData.SynackAuthToken = authToken
-					if globalMissionService != nil {
-						globalMissionService.SetAuthToken(authToken)
-					} else {
-						logger.Warn("globalMissionService is nil, cannot set auth token for missions.")
-					}
-				} else if authToken != "" {
-					logger.ProxyInfo("WARNING: Found Authorization header for Synack target list, but it's not a Bearer token.")
+			requestData.ReplayBatchID = replayBatchID
+			requestData.ReplaySourceLogID = replaySourceLogID
+			ctxData := &proxyRequestContextData{TrafficLog: requestData, PlatformProvider: platformProvider, SampleByResponse: sampleByResponse, ScriptTags: scriptTags}
+			if r.Header.Get("X-Toolkit-Initiated") == "true" {
+				key := responseCacheKey(r.Method, serverSeenURL, r.Host, r.Header, reqBodyBytes)
+				if resp, logID := cachedResponse(key, r); resp != nil {
+					// The answer is logged like any other, pointing at the entry it was reused from.
+					requestData.CachedFromLogID = sql.NullInt64{Int64: logID, Valid: true}
+					ctx.UserData = ctxData
+					logger.ProxyInfo("REQ: %s %s (HTTPS: %t) - Answered from the response cache (log %d).", r.Method, serverSeenURL, isCurrentSessionHTTPS, logID)
+					return r, resp
+				}
+				requestData.RequestFingerprint = models.NullString(key)
+			}
+			if config.AppConfig.RateLimit.ApplyToProxy && r.Header.Get("X-Toolkit-Initiated") != "true" {
+				// Toolkit-initiated requests are already throttled by RateLimitedTransport on the client side.
+				release, errWait := GetRateLimiter().Wait(r.Context(), r.URL.Hostname())
+				if errWait != nil {
+					logger.ProxyError("REQ: Rate limiter wait aborted for %s %s: %v", r.Method, serverSeenURL, errWait)
 				}
+				ctxData.RateLimitRelease = release
 			}
 			ctx.UserData = ctxData
 
@@ -668,71 +809,92 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 				return resp
 			}
 			requestData := pCtxData.TrafficLog
+			if pCtxData.RateLimitRelease != nil {
+				pCtxData.RateLimitRelease()
+				if resp != nil {
+					GetRateLimiter().ObserveResponse(ctx.Req.URL.Hostname(), resp.StatusCode, resp.Header.Get("Retry-After"))
+				}
+			}
+			if resp != nil {
+				drop, tags := applyResponseScripts(resp, ctx.Req, requestData)
+				if drop {
+					proxyState.EndSession(ctx.Session)
+					return resp
+				}
+				pCtxData.ScriptTags = append(pCtxData.ScriptTags, tags...)
+			}
+			if ReadOnly() {
+				logger.ProxyDebug("RESP: %s %s - Not logged (read-only mode).", ctx.Req.Method, ctx.Req.URL.String())
+				proxyState.EndSession(ctx.Session)
+				return resp
+			}
 
 			if resp == nil {
 				logger.ProxyError("RESP: Nil response for %s %s", ctx.Req.Method, ctx.Req.URL.String())
 				requestData.ResponseStatusCode = 0
-				logHttpTraffic(requestData)
+				logScriptedHttpTraffic(requestData, pCtxData.ScriptTags)
 				return resp
 			}
 
-			respBodyBytes, errReadResp := io.ReadAll(resp.Body)
-			if errReadResp != nil {
-				logger.ProxyError("RESP: Error reading response body for %s %s: %v", ctx.Req.Method, ctx.Req.URL.String(), errReadResp)
-			}
-			resp.Body.Close()
-			resp.Body = io.NopCloser(bytes.NewBuffer(respBodyBytes))
-
 			respHeadersMap := make(map[string][]string)
 			for k, v := range resp.Header {
 				respHeadersMap[k] = v
 			}
 			respHeadersJson, _ := json.Marshal(respHeadersMap)
 
-			duration := time.Since(requestData.Timestamp)
-
 			requestData.ResponseStatusCode = resp.StatusCode
 			requestData.ResponseReasonPhrase = models.NullString(strings.TrimPrefix(resp.Status, fmt.Sprintf("%d ", resp.StatusCode)))
 			requestData.ResponseHTTPVersion = models.NullString(resp.Proto)
 			requestData.ResponseHeaders = models.NullString(string(respHeadersJson))
-			requestData.ResponseBody = respBodyBytes
 			requestData.ResponseContentType = models.NullString(resp.Header.Get("Content-Type"))
-			requestData.ResponseBodySize = int64(len(respBodyBytes))
-			requestData.DurationMs = duration.Milliseconds()
+			requestData.TLSConnectionID = recordTLSConnection(ctx.Req.URL.Hostname(), resp.TLS)
 
 			if requestData.ResponseContentType.Valid && strings.Contains(strings.ToLower(requestData.ResponseContentType.String), "text/html") {
 				requestData.IsPageCandidate = true
 			}
 
-			logHttpTraffic(requestData)
-
-			isSynackTargetListResp := false
-			var reqPathNorm, configPathNorm string
-
-			if parsedSynackTargetURL != nil && ctx.Req != nil && ctx.Req.URL != nil {
-				reqPathNorm = strings.TrimRight(ctx.Req.URL.Path, "/")
-				configPathNorm = strings.TrimRight(parsedSynackTargetURL.Path, "/")
-				if ctx.Req.URL.Scheme == parsedSynackTargetURL.Scheme &&
-					ctx.Req.URL.Hostname() == parsedSynackTargetURL.Hostname() &&
-					reqPathNorm == configPathNorm {
-					isSynackTargetListResp = true
-				}
+			if pCtxData.SampleByResponse && isStaticAssetContentType(requestData.ResponseContentType.String) && !sampleProxyRequest(sampleStaticAsset) {
+				logger.ProxyDebug("RESP: %s %s - Not logged (performance mode, %s sample).", ctx.Req.Method, ctx.Req.URL.String(), sampleStaticAsset)
+				proxyState.EndSession(ctx.Session)
+				return resp
 			}
 
-			if isSynackTargetListResp &&
-				resp.StatusCode == http.StatusOK &&
-				requestData.ResponseContentType.Valid && strings.Contains(strings.ToLower(requestData.ResponseContentType.String), "application/json") {
-
-				authTokenForAnalytics := pCtxData.SynackAuthToken
-				logger.ProxyInfo("Synack target list URL detected: %s. Using auth token: %t", ctx.Req.URL.String(), authTokenForAnalytics != "")
-				go processSynackTargetList(respBodyBytes, authTokenForAnalytics)
+			if resp.Body == nil {
+				resp.Body = http.NoBody
 			}
+			// The body is streamed to the client as it arrives; the entry is logged once the stream ends.
+			req := ctx.Req
+			captureLimit := ConfigForTarget(requestData.TargetID).Proxy.MaxCaptureBytes
+			resp.Body = newCapturedBody(resp.Body, captureLimit, func(body *capturedBody, errStream error) {
+				if errStream != nil && errStream != errCaptureIncomplete {
+					logger.ProxyError("RESP: Error reading response body for %s %s: %v", req.Method, req.URL.String(), errStream)
+				}
+				duration := time.Since(requestData.Timestamp)
+				requestData.ResponseBody = body.Captured()
+				requestData.ResponseBodySize = body.Size()
+				requestData.ResponseBodyTruncated = body.Truncated()
+				requestData.ResponseBodySHA256 = models.NullString(body.SHA256())
+				requestData.DurationMs = duration.Milliseconds()
+				decodeLoggedResponseBody(requestData, captureLimit)
+
+				logScriptedHttpTraffic(requestData, pCtxData.ScriptTags)
+
+				if provider := pCtxData.PlatformProvider; provider != nil && req != nil {
+					if requestData.ResponseBodyTruncated {
+						logger.ProxyDebug("Platform provider %s: skipping %s, its body was not captured whole.", provider.Name(), req.URL.String())
+					} else {
+						go func(statusCode int, contentType string, body []byte) {
+							if err := provider.ProcessCapturedResponse(req, statusCode, contentType, body); err != nil {
+								logger.ProxyError("Platform provider %s: %v", provider.Name(), err)
+							}
+						}(resp.StatusCode, requestData.ResponseContentType.String, requestData.ResponseBody)
+					}
+				}
 
-			logger.ProxyInfo("RESP: %d %s for %s %s (Size: %d, Duration: %s)", resp.StatusCode, requestData.ResponseContentType.String, ctx.Req.Method, ctx.Req.URL.String(), requestData.ResponseBodySize, duration)
+				logger.ProxyInfo("RESP: %d %s for %s %s (Size: %d, Truncated: %t, Duration: %s)", resp.StatusCode, requestData.ResponseContentType.String, req.Method, req.URL.String(), requestData.ResponseBodySize, requestData.ResponseBodyTruncated, duration)
+			})
 
-			muSession.Lock()
-			delete(sessionIsHTTPS, ctx.Session)
-			muSession.Unlock()
+			proxyState.EndSession(ctx.Session)
 
 			return resp
 		})
@@ -743,6 +905,14 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 		Handler: proxy,
 	}
 
+	go proxyState.runSessionJanitor(ctx)
+	go runExclusionHitFlusher(ctx)
+
+	if err := startInterceptListeners(ctx, proxy); err != nil {
+		return err
+	}
+	startProxyListeners(ctx, port, proxy)
+
 	go func() {
 		<-ctx.Done() // Wait for cancellation signal
 		logger.ProxyInfo("MITM Proxy server shutting down...")
@@ -759,274 +929,113 @@ func StartMitmProxy(ctx context.Context, port string, cliTargetID int64, caCertP
 	return nil
 }
 
-func logHttpTraffic(logEntry *models.HTTPTrafficLog) {
-	if database.DB == nil {
-		logger.ProxyError("logHttpTraffic: Database is not initialized.")
-		return
+// jsPathSenderSource is the X-Toolkit-Source value for paths found in JavaScript and sent through
+// the proxy by SendGETRequestsThroughProxy.
+const jsPathSenderSource = "JS-Path-Sender"
+
+// toolkitLogSource returns the log_source of a toolkit-initiated request reaching the proxy, from
+// its X-Toolkit-Source header. Sources without one of their own are logged as LogSourceToolkit.
+func toolkitLogSource(toolkitSource string, replay bool) string {
+	switch {
+	case replay:
+		return models.LogSourceReplay
+	case toolkitSource == jsPathSenderSource || toolkitSource == jsSourceMapSource:
+		return models.LogSourceJSAnalysis
+	case toolkitSource == graphQLIntrospectionSource:
+		return models.LogSourceGraphQL
 	}
-	logger.Debug("logHttpTraffic: Attempting to save log entry. RequestURL: '%s', RequestFullURLWithFragment: {String: '%s', Valid: %t}",
-		logEntry.RequestURL.String, logEntry.RequestFullURLWithFragment.String, logEntry.RequestFullURLWithFragment.Valid)
-	_, err := database.DB.Exec(`INSERT INTO http_traffic_log (
-		target_id, timestamp, request_method, request_url, request_http_version, request_headers, request_body, request_full_url_with_fragment,
-		response_status_code, response_reason_phrase, response_http_version, response_headers, response_body, response_content_type,
-		response_body_size, duration_ms, client_ip, is_https, is_page_candidate, notes, log_source, page_sitemap_id
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		logEntry.TargetID, logEntry.Timestamp, logEntry.RequestMethod, logEntry.RequestURL,
-		logEntry.RequestHTTPVersion, logEntry.RequestHeaders, logEntry.RequestBody,
-		logEntry.RequestFullURLWithFragment,
-		logEntry.ResponseStatusCode, logEntry.ResponseReasonPhrase, logEntry.ResponseHTTPVersion,
-		logEntry.ResponseHeaders, logEntry.ResponseBody, logEntry.ResponseContentType,
-		logEntry.ResponseBodySize, logEntry.DurationMs, logEntry.ClientIP, logEntry.IsHTTPS,
-		logEntry.IsPageCandidate, logEntry.Notes,
-		logEntry.LogSource, logEntry.PageSitemapID)
-	if err != nil {
-		logger.ProxyError("DB log error on response for %s %s: %v", logEntry.RequestMethod.String, logEntry.RequestURL.String, err)
-	}
-}
-
-type rawSynackFindingItem struct {
-	Type        string          `json:"type"`
-	Value       string          `json:"value"`
-	CreatedAt   int64           `json:"created_at"`
-	Status      string          `json:"status"`
-	OutOfScope  *bool           `json:"outOfScope"`
-	ID          string          `json:"id"`
-	Title       string          `json:"title"`
-	Category    string          `json:"category"`
-	Severity    string          `json:"severity"`
-	FullDetails json.RawMessage `json:"-"`
+	return models.LogSourceToolkit
 }
 
-func processSynackTargetList(jsonData []byte, authToken string) {
-	logger.ProxyInfo("Processing Synack target list JSON (%d bytes)...", len(jsonData))
-
-	var rawTargets []map[string]interface{}
-	httpClient := &http.Client{Timeout: 20 * time.Second}
-
-	if !config.AppConfig.Synack.AnalyticsEnabled {
-		logger.ProxyInfo("Synack target analytics fetching is disabled in config.")
+// logScriptedHttpTraffic logs an entry and adds the tags proxy scripts asked for.
+func logScriptedHttpTraffic(logEntry *models.HTTPTrafficLog, scriptTags []string) {
+	if id := logHttpTraffic(logEntry); id != 0 && len(scriptTags) > 0 {
+		go tagProxyScriptLog(id, scriptTags)
 	}
+}
 
-	arrayPath := config.AppConfig.Synack.TargetsArrayPath
-	if arrayPath == "" {
-		arrayPath = "@this"
+// logHttpTraffic stores a log entry and starts its analyses. It returns the ID of the entry, 0 when
+// it could not be stored.
+func logHttpTraffic(logEntry *models.HTTPTrafficLog) int64 {
+	if database.DB == nil {
+		logger.ProxyError("logHttpTraffic: Database is not initialized.")
+		return 0
 	}
-
-	result := gjson.GetBytes(jsonData, arrayPath)
-	if !result.IsArray() {
-		logger.ProxyError("Synack target list: Expected an array at path '%s', but got: %s", arrayPath, result.Type.String())
-		if config.AppConfig.Synack.TargetsArrayPath != "" {
-			resultRoot := gjson.ParseBytes(jsonData)
-			if resultRoot.IsArray() {
-				logger.ProxyInfo("Synack target list: Falling back to parsing root as array.")
-				result = resultRoot
-			} else {
-				logger.ProxyError("Synack target list: Root is also not an array. Type: %s", resultRoot.Type.String())
-				return
-			}
-		} else {
-			return
-		}
+	logger.Debug("logHttpTraffic: Attempting to save log entry. RequestURL: '%s', RequestFullURLWithFragment: {String: '%s', Valid: %t}",
+		logEntry.RequestURL.String, logEntry.RequestFullURLWithFragment.String, logEntry.RequestFullURLWithFragment.Valid)
+	if RedactForStorage(logEntry) {
+		logEntry.RequestFingerprint = sql.NullString{} // The stored response no longer stands in for the one received
 	}
-
-	err := json.Unmarshal([]byte(result.Raw), &rawTargets)
+	id, err := database.LogProxyTraffic(logEntry)
 	if err != nil {
-		logger.ProxyError("Synack target list: Error unmarshalling into []map[string]interface{}: %v", err)
-		return
+		logger.ProxyError("DB log error on response for %s %s: %v", logEntry.RequestMethod.String, logEntry.RequestURL.String, err)
+		return 0
 	}
-
-	if len(rawTargets) == 0 {
-		logger.ProxyInfo("Synack target list: No targets found in JSON after parsing.")
-		if err := database.DeactivateMissingSynackTargets([]string{}, time.Now()); err != nil {
-			logger.ProxyError("Synack target list: Error deactivating all targets on empty list: %v", err)
+	if logEntry.TargetID != nil && models.TrafficOrigin(logEntry.LogSource.String) == models.TrafficOriginManual {
+		targetID, at := *logEntry.TargetID, logEntry.Timestamp
+		if at.IsZero() {
+			at = time.Now()
 		}
-		return
+		go noteEngagementActivity(targetID, at)
+	}
+	if logEntry.CachedFromLogID.Valid {
+		return id // The response was scanned and captured when its original entry was logged
 	}
 
-	var currentSeenIDs []string
-	for _, targetMap := range rawTargets {
-		idField := config.AppConfig.Synack.TargetIDField
-		if idField == "" {
-			idField = "id"
-		}
-		synackID, ok := targetMap[idField].(string)
-		if !ok {
-			if idNum, numOk := targetMap[idField].(float64); numOk {
-				synackID = fmt.Sprintf("%.0f", idNum)
-				ok = true
-			} else {
-				logger.ProxyError("Synack target list: Target missing or has invalid type for configured ID field '%s'. Skipping. Data: %+v", idField, targetMap)
-				continue
+	if config.AppConfig.Secrets.Enabled {
+		entry := *logEntry
+		entry.ID = id
+		go func() {
+			if _, errScan := scanAndStoreSecrets(&entry); errScan != nil {
+				logger.ProxyError("Secrets scanner: %v", errScan)
 			}
-		}
-		currentSeenIDs = append(currentSeenIDs, synackID)
-
-		dbID, errUpsert := database.UpsertSynackTarget(targetMap)
-		if errUpsert != nil {
-			logger.ProxyError("Synack target list: Error upserting target with Synack ID '%s': %v", synackID, errUpsert)
-			continue
-		}
-
-		if config.AppConfig.Synack.AnalyticsEnabled && config.AppConfig.Synack.AnalyticsBaseURL != "" && config.AppConfig.Synack.AnalyticsPathPattern != "" {
-			analyticsURL := fmt.Sprintf(config.AppConfig.Synack.AnalyticsBaseURL+config.AppConfig.Synack.AnalyticsPathPattern, synackID)
-			logger.ProxyInfo("Fetching analytics for Synack target '%s' from URL: %s", synackID, analyticsURL)
+		}()
+	}
 
-			req, err := http.NewRequest("GET", analyticsURL, nil)
-			if err != nil {
-				logger.ProxyError("Synack Analytics: Error creating request for target '%s': %v", synackID, err)
-			}
-			if authToken != "" {
-				req.Header.Set("Authorization", authToken)
-				logger.ProxyDebug("Synack Analytics: Added Authorization header to request for target '%s'", synackID)
+	if config.AppConfig.GraphQL.CaptureOperations && (len(logEntry.RequestBody) > 0 || strings.Contains(logEntry.RequestURL.String, "query=")) {
+		entry := *logEntry
+		entry.ID = id
+		go func() {
+			if _, errCapture := CaptureGraphQLFromLog(&entry); errCapture != nil {
+				logger.ProxyError("GraphQL capture: Log %d: %v", id, errCapture)
 			}
+		}()
+	}
 
-			lastFetchTime, errCheck := database.GetSynackTargetAnalyticsFetchTime(dbID)
-			if errCheck != nil {
-				logger.ProxyError("Synack Analytics: Error checking existing analytics for target DB_ID %d (Synack ID %s): %v", dbID, synackID, errCheck)
+	if config.AppConfig.CookieJar.Enabled && logEntry.TargetID != nil {
+		entry := *logEntry
+		entry.ID = id
+		go func() {
+			if errTrack := TrackCookiesFromLog(&entry); errTrack != nil {
+				logger.ProxyError("Cookie jar: Log %d: %v", id, errTrack)
 			}
+		}()
+	}
 
-			if lastFetchTime != nil {
-				logger.ProxyInfo("Synack Analytics: Analytics data already exists for target DB_ID %d (Synack ID %s), fetched at %s. Skipping fetch.", dbID, synackID, lastFetchTime.Format(time.RFC3339))
-			} else {
-				logger.ProxyDebug("Synack Analytics: Waiting for analyticsFetchTicker for target DB_ID %d (Synack ID %s)...", dbID, synackID)
-				<-analyticsFetchTicker.C
-				logger.ProxyInfo("Synack Analytics: No existing analytics found for target DB_ID %d (Synack ID %s) or fetch time is nil. Proceeding to fetch.", dbID, synackID)
-				resp, errDo := httpClient.Do(req)
-				if errDo != nil {
-					logger.ProxyError("Synack Analytics: Error fetching analytics for target DB_ID %d (Synack ID %s) from %s: %v", dbID, synackID, analyticsURL, errDo)
-				}
-				defer resp.Body.Close()
-
-				body, errRead := io.ReadAll(resp.Body)
-				if errRead != nil {
-					logger.ProxyError("Synack Analytics: Error reading analytics response body for target DB_ID %d (Synack ID %s) (status %d): %v", dbID, synackID, resp.StatusCode, errRead)
-				}
-				logger.ProxyInfo("Synack Analytics: Received status %d for target DB_ID %d (Synack ID %s). Response (first 500 chars): %s", resp.StatusCode, dbID, synackID, string(body[:min(len(body), 500)]))
-
-				analyticsArrayPath := "value"
-				categoriesResult := gjson.GetBytes(body, analyticsArrayPath)
-
-				if !categoriesResult.Exists() {
-					logger.ProxyError("Synack Analytics: Expected key '%s' not found in analytics JSON response for target DB_ID %d (Synack ID %s). The response might be a direct array or use a different key. Response (first 500 chars): %s", analyticsArrayPath, dbID, synackID, string(body[:min(len(body), 500)]))
-				} else if !categoriesResult.IsArray() {
-					logger.ProxyError("Synack Analytics: Value at key '%s' in analytics JSON is not an array (type: %s) for target DB_ID %d (Synack ID %s). Response (first 500 chars): %s", analyticsArrayPath, categoriesResult.Type.String(), dbID, synackID, string(body[:min(len(body), 500)]))
-				} else {
-					var rawAnalyticsItems []struct {
-						Categories           []string        `json:"categories"`
-						ExploitableLocations json.RawMessage `json:"exploitable_locations"`
-					}
-
-					if errUnmarshal := json.Unmarshal([]byte(categoriesResult.Raw), &rawAnalyticsItems); errUnmarshal != nil {
-						logger.ProxyError("Synack Analytics: Error unmarshalling analytics items from key '%s' for target DB_ID %d (Synack ID %s): %v", analyticsArrayPath, dbID, synackID, errUnmarshal)
-					} else {
-						aggregatedCategories := make(map[string]int64)
-						for _, rawItem := range rawAnalyticsItems {
-
-							var exploitableLocationsArray []interface{}
-							if rawItem.ExploitableLocations != nil {
-								if errParseEL := json.Unmarshal(rawItem.ExploitableLocations, &exploitableLocationsArray); errParseEL != nil {
-									logger.Error("Synack Analytics (Proxy): Could not parse exploitable_locations for category group (target DB_ID %d): %v", dbID, errParseEL)
-								}
-							}
-							findingCountInGroup := int64(len(exploitableLocationsArray))
-
-							if len(rawItem.Categories) > 0 {
-								for _, categoryName := range rawItem.Categories {
-									aggregatedCategories[categoryName] += findingCountInGroup
-								}
-							}
-
-							if config.AppConfig.Synack.FindingsEnabled {
-								logger.ProxyInfo("Synack Findings: Processing 'exploitable_locations' for target DB_ID %d, category group with categories: %v", dbID, rawItem.Categories)
-								if config.AppConfig.Synack.FindingsArrayPathInAnalyticsJson != "" {
-									logger.ProxyInfo("Warning: Synack Findings: Config key 'findings_array_path_in_analytics_json' is set but currently ignored. Findings are sourced from 'exploitable_locations' within each category group.")
-								}
-
-								if rawItem.ExploitableLocations != nil {
-									var exploitableLocationsList []rawSynackFindingItem
-
-									logger.ProxyDebug("Synack Findings: Raw exploitable_locations JSON for target DB_ID %d (first 500 chars): %s", dbID, string(rawItem.ExploitableLocations[:min(len(rawItem.ExploitableLocations), 500)]))
-
-									if errUnmarshalEL := json.Unmarshal(rawItem.ExploitableLocations, &exploitableLocationsList); errUnmarshalEL != nil {
-										logger.ProxyError("Synack Findings: Error unmarshalling exploitable_locations for category group (target DB_ID %d): %v. Raw JSON: %s", dbID, errUnmarshalEL, string(rawItem.ExploitableLocations[:min(len(rawItem.ExploitableLocations), 200)]))
-									} else {
-										logger.ProxyInfo("Synack Findings: Found %d exploitable_locations for this category group (target DB_ID %d).", len(exploitableLocationsList), dbID)
-										if len(exploitableLocationsList) == 0 && len(rawItem.ExploitableLocations) > 2 {
-											logger.ProxyInfo("Warning: Synack Findings: Unmarshalled 0 exploitable_locations, but raw JSON was not empty for target DB_ID %d. Check rawSynackFindingItem struct against API response.", dbID)
-										}
-										primaryCategoryName := ""
-										if len(rawItem.Categories) > 0 {
-											primaryCategoryName = rawItem.Categories[0]
-										}
-
-										for i, exploitableLocation := range exploitableLocationsList {
-											logger.ProxyDebug("Synack Findings: Processing exploitable_location #%d: URL='%s', Status='%s', CreatedAt=%d", i+1, exploitableLocation.Value, exploitableLocation.Status, exploitableLocation.CreatedAt)
-
-											findingToStore := models.SynackFinding{
-												SynackTargetDBID: dbID,
-												SynackFindingID:  exploitableLocation.Value,
-												Title:            fmt.Sprintf("Finding at %s", exploitableLocation.Value),
-												CategoryName:     primaryCategoryName,
-												Status:           exploitableLocation.Status,
-												VulnerabilityURL: exploitableLocation.Value,
-											}
-
-											rawExploitableLocationJsonBytes, errJson := json.Marshal(exploitableLocation)
-											if errJson == nil {
-												findingToStore.RawJSONDetails = string(rawExploitableLocationJsonBytes)
-											} else {
-												logger.ProxyError("Synack Findings: Could not marshal exploitableLocation to JSON for finding URL '%s': %v", exploitableLocation.Value, errJson)
-											}
-
-											if exploitableLocation.CreatedAt > 0 {
-												t := time.Unix(exploitableLocation.CreatedAt, 0).UTC()
-												findingToStore.ReportedAt = &t
-											}
-
-											logger.ProxyDebug("Synack Findings: Attempting to upsert finding: TargetDBID=%d, FindingID(URL)='%s', Category='%s', Status='%s'",
-												findingToStore.SynackTargetDBID, findingToStore.SynackFindingID, findingToStore.CategoryName, findingToStore.Status)
-
-											insertedDBID, errStoreFinding := database.UpsertSynackFinding(findingToStore)
-											if errStoreFinding != nil {
-												logger.ProxyError("Synack Findings: Error upserting finding (URL: '%s') for target DB_ID %d: %v", exploitableLocation.Value, dbID, errStoreFinding)
-											} else {
-												logger.ProxyInfo("Synack Findings: Successfully upserted finding (URL: '%s'), DB ID: %d, for target DB_ID %d.", exploitableLocation.Value, insertedDBID, dbID)
-											}
-										}
-									}
-								} else {
-									logger.ProxyDebug("Synack Findings: No 'exploitable_locations' data for this category group (target DB_ID %d).", dbID)
-								}
-							} else {
-								logger.ProxyInfo("Synack Findings: Individual findings processing is DISABLED in config for target DB_ID %d.", dbID)
-							}
-						}
-
-						var finalAnalyticsCategories []models.SynackTargetAnalyticsCategory
-						for name, count := range aggregatedCategories {
-							finalAnalyticsCategories = append(finalAnalyticsCategories, models.SynackTargetAnalyticsCategory{CategoryName: name, Count: count})
-						}
-
-						if errStore := database.StoreSynackTargetAnalytics(dbID, finalAnalyticsCategories); errStore != nil {
-							logger.ProxyError("Synack Analytics: Error storing analytics for target DB_ID %d (Synack ID %s): %v", dbID, synackID, errStore)
-						} else {
-							logger.ProxyInfo("Synack Analytics: Successfully stored %d analytics categories for target DB_ID %d (Synack ID %s)", len(finalAnalyticsCategories), dbID, synackID)
-						}
-					}
-				}
+	if config.AppConfig.AuthTokens.Capture && logEntry.TargetID != nil {
+		entry := *logEntry
+		entry.ID = id
+		go func() {
+			if _, errCapture := CaptureAuthTokensFromLog(&entry); errCapture != nil {
+				logger.ProxyError("Auth tokens: Log %d: %v", id, errCapture)
 			}
-		}
+		}()
 	}
 
-	if len(currentSeenIDs) > 0 {
-		if err := database.DeactivateMissingSynackTargets(currentSeenIDs, time.Now()); err != nil {
-			logger.ProxyError("Synack target list: Error deactivating missing targets: %v", err)
-		}
-	} else {
-		logger.ProxyInfo("Synack target list: No valid target IDs found in the current list, skipping deactivation step.")
+	if config.AppConfig.Beautify.OnCapture && len(logEntry.ResponseBody) > 0 && isBeautifiableContentType(logEntry.ResponseContentType.String) {
+		entry := *logEntry
+		entry.ID = id
+		go beautifyCapturedResponse(entry)
 	}
 
-	logger.ProxyInfo("Finished processing Synack target list. Saw %d targets.", len(currentSeenIDs))
+	if config.AppConfig.JSAnalysis.AutoAnalyze && logEntry.ResponseStatusCode == http.StatusOK && IsJavaScriptContentType(logEntry.ResponseContentType.String) {
+		go func() {
+			if _, _, errPipeline := RunJSPipeline(id); errPipeline != nil {
+				logger.ProxyError("JS pipeline: Log %d: %v", id, errPipeline)
+			}
+		}()
+	}
+	return id
 }
 
 func SendGETRequestsThroughProxy(targetID int64, urls []string) error {
@@ -1048,10 +1057,10 @@ func SendGETRequestsThroughProxy(targetID int64, urls []string) error {
 	}
 
 	client := &http.Client{
-		Transport: &http.Transport{
+		Transport: NewRateLimitedTransport(&http.Transport{
 			Proxy:           http.ProxyURL(proxyURL),
 			TLSClientConfig: &tls.Config{RootCAs: customCAPool},
-		},
+		}),
 		Timeout: 15 * time.Second,
 	}
 
@@ -1066,9 +1075,13 @@ func SendGETRequestsThroughProxy(targetID int64, urls []string) error {
 		}
 
 		req.Header.Set("X-Toolkit-Initiated", "true")
-		req.Header.Set("X-Toolkit-Source", "JS-Path-Sender")
+		req.Header.Set("X-Toolkit-Source", jsPathSenderSource)
 		req.Header.Set("X-Toolkit-Full-URL", u)
-		req.Header.Set("User-Agent", "Toolkit-Path-Tester/1.0")
+		setToolkitTargetHeader(req.Header, targetID)
+		ApplyClientProfile(req, targetID)
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", "Toolkit-Path-Tester/1.0")
+		}
 
 		logger.Info("Core: SendGETRequestsThroughProxy - Sending request with X-Toolkit-Full-URL header set to: %s", u)
 
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"toolkit/config"
+	"toolkit/logger"
+
+	"github.com/google/uuid"
+)
+
+// MobileConfigOptions selects the proxy settings of a generated iOS configuration profile.
+type MobileConfigOptions struct {
+	ProxyHost string // Address devices reach the proxy at
+	ProxyPort int
+	SSID      string // Wi-Fi network to set the proxy on; without it the profile only installs the CA
+}
+
+var mobileConfigTemplate = template.Must(template.New("mobileconfig").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>toolkit-ca.cer</string>
+			<key>PayloadContent</key>
+			<data>{{.CertBase64}}</data>
+			<key>PayloadDescription</key>
+			<string>Root certificate of the toolkit MITM proxy</string>
+			<key>PayloadDisplayName</key>
+			<string>{{xml .CertName}}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.toolkit.proxy.ca</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>{{.CertUUID}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+{{- if .SSID}}
+		<dict>
+			<key>AutoJoin</key>
+			<true/>
+			<key>EncryptionType</key>
+			<string>Any</string>
+			<key>HIDDEN_NETWORK</key>
+			<false/>
+			<key>PayloadDisplayName</key>
+			<string>Wi-Fi proxy ({{xml .SSID}})</string>
+			<key>PayloadIdentifier</key>
+			<string>com.toolkit.proxy.wifi</string>
+			<key>PayloadType</key>
+			<string>com.apple.wifi.managed</string>
+			<key>PayloadUUID</key>
+			<string>{{.WiFiUUID}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>ProxyServer</key>
+			<string>{{xml .ProxyHost}}</string>
+			<key>ProxyServerPort</key>
+			<integer>{{.ProxyPort}}</integer>
+			<key>ProxyType</key>
+			<string>Manual</string>
+			<key>SSID_STR</key>
+			<string>{{xml .SSID}}</string>
+		</dict>
+{{- end}}
+	</array>
+	<key>PayloadDescription</key>
+	<string>Installs the toolkit proxy CA{{if .SSID}} and routes {{xml .SSID}} through {{xml .ProxyHost}}:{{.ProxyPort}}{{end}}. Enable full trust for the certificate under Settings &gt; General &gt; About &gt; Certificate Trust Settings.</string>
+	<key>PayloadDisplayName</key>
+	<string>toolkit proxy</string>
+	<key>PayloadIdentifier</key>
+	<string>com.toolkit.proxy</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>{{.ProfileUUID}}</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`))
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// BuildMobileConfig returns an iOS configuration profile (.mobileconfig) installing the proxy CA
+// and, when opts.SSID is set, the proxy settings of that Wi-Fi network.
+func BuildMobileConfig(opts MobileConfigOptions) ([]byte, error) {
+	certPEM, err := os.ReadFile(config.AppConfig.Proxy.CACertPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificate: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode CA certificate PEM block from %s", config.AppConfig.Proxy.CACertPath)
+	}
+	if opts.SSID != "" && (opts.ProxyHost == "" || opts.ProxyPort <= 0 || opts.ProxyPort > 65535) {
+		return nil, fmt.Errorf("invalid proxy address '%s:%d' for the Wi-Fi payload", opts.ProxyHost, opts.ProxyPort)
+	}
+	// UUIDs derived from the certificate keep the profile identical across downloads.
+	certUUID := uuid.NewSHA1(uuid.NameSpaceOID, block.Bytes)
+	var out bytes.Buffer
+	err = mobileConfigTemplate.Execute(&out, map[string]interface{}{
+		"CertBase64":  base64.StdEncoding.EncodeToString(block.Bytes),
+		"CertName":    "toolkit MITM Proxy CA",
+		"CertUUID":    certUUID.String(),
+		"WiFiUUID":    uuid.NewSHA1(certUUID, []byte("wifi:"+opts.SSID)).String(),
+		"ProfileUUID": uuid.NewSHA1(certUUID, []byte("profile:"+opts.SSID+"@"+opts.ProxyHost+":"+strconv.Itoa(opts.ProxyPort))).String(),
+		"SSID":        opts.SSID,
+		"ProxyHost":   opts.ProxyHost,
+		"ProxyPort":   opts.ProxyPort,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rendering mobileconfig: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// WriteMobileConfig serves the iOS configuration profile. Query parameters: ssid (Wi-Fi network to
+// set the proxy on), host and port (proxy address; default the host the request was sent to and
+// the configured proxy port).
+func WriteMobileConfig(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := MobileConfigOptions{ProxyHost: q.Get("host"), SSID: q.Get("ssid")}
+	if opts.ProxyHost == "" {
+		opts.ProxyHost = r.Host
+		if host, _, err := net.SplitHostPort(r.Host); err == nil {
+			opts.ProxyHost = host
+		}
+	}
+	portStr := q.Get("port")
+	if portStr == "" {
+		portStr = config.AppConfig.Proxy.Port
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		http.Error(w, "Invalid port", http.StatusBadRequest)
+		return
+	}
+	opts.ProxyPort = port
+
+	profile, err := BuildMobileConfig(opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "CA certificate not found, run 'proxy init-ca' first", http.StatusNotFound)
+		case strings.Contains(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			logger.Error("WriteMobileConfig: %v", err)
+			http.Error(w, "Failed to build configuration profile", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-apple-aspen-config")
+	w.Header().Set("Content-Disposition", `attachment; filename="toolkit-proxy.mobileconfig"`)
+	w.Write(profile)
+}
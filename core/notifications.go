@@ -0,0 +1,216 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// NotificationSink delivers notifications to one channel.
+type NotificationSink interface {
+	Name() string
+	Send(ctx context.Context, n models.Notification) error
+}
+
+// notificationSinks builds the sinks enabled in the notifications config section and the enabled
+// notifier plugins.
+func notificationSinks() []NotificationSink {
+	conf := config.AppConfig.Notify
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var sinks []NotificationSink
+	if conf.Desktop {
+		sinks = append(sinks, desktopSink{})
+	}
+	if conf.WebhookURL != "" {
+		sinks = append(sinks, webhookSink{name: "webhook", url: conf.WebhookURL, client: client, payload: genericWebhookPayload})
+	}
+	if conf.SlackWebhookURL != "" {
+		sinks = append(sinks, webhookSink{name: "slack", url: conf.SlackWebhookURL, client: client, payload: slackWebhookPayload})
+	}
+	if conf.DiscordWebhookURL != "" {
+		sinks = append(sinks, webhookSink{name: "discord", url: conf.DiscordWebhookURL, client: client, payload: discordWebhookPayload})
+	}
+	for _, p := range enabledPlugins(models.PluginKindNotifier) {
+		sinks = append(sinks, pluginSink{plugin: p})
+	}
+	return sinks
+}
+
+// NotificationsConfigured reports whether at least one notification sink is enabled.
+func NotificationsConfigured() bool {
+	return len(notificationSinks()) > 0
+}
+
+// NotificationEventEnabled reports whether notifications of the given kind are switched on.
+func NotificationEventEnabled(kind string) bool {
+	events := config.AppConfig.Notify.Events
+	switch kind {
+	case models.NotificationNewSubdomain:
+		return events.NewSubdomain
+	case models.NotificationScanFinished:
+		return events.ScanFinished
+	case models.NotificationScopeChanged:
+		return events.ScopeChanged
+	case models.NotificationSynackTarget:
+		return events.SynackTarget
+	case models.NotificationSynackMission:
+		return events.SynackMission
+	case models.NotificationCTInteresting:
+		return events.CTInteresting
+	case models.NotificationEngagement:
+		return events.Engagement
+	case models.NotificationTest:
+		return true
+	}
+	return false
+}
+
+// GetNotificationSettings returns the configured sink names and the per-event switches.
+func GetNotificationSettings() models.NotificationSettings {
+	settings := models.NotificationSettings{Sinks: []string{}, Events: map[string]bool{}}
+	for _, sink := range notificationSinks() {
+		settings.Sinks = append(settings.Sinks, sink.Name())
+	}
+	for _, kind := range []string{models.NotificationNewSubdomain, models.NotificationScanFinished, models.NotificationScopeChanged, models.NotificationSynackTarget, models.NotificationSynackMission, models.NotificationCTInteresting, models.NotificationEngagement} {
+		settings.Events[kind] = NotificationEventEnabled(kind)
+	}
+	return settings
+}
+
+// SendNotification delivers n to every configured sink, whatever its kind. Every sink is tried;
+// the errors of failed sinks are joined.
+func SendNotification(ctx context.Context, n models.Notification) error {
+	sinks := notificationSinks()
+	if len(sinks) == 0 {
+		return fmt.Errorf("notifications are not configured (notifications.desktop, webhook_url, slack_webhook_url, discord_webhook_url or a notifier plugin)")
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Send(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s notification: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notify sends an event notification in the background when its kind is enabled and a sink is
+// configured. Failures are logged.
+func Notify(n models.Notification) {
+	if !NotificationEventEnabled(n.Kind) || !NotificationsConfigured() {
+		return
+	}
+	go func() {
+		if err := SendNotification(context.Background(), n); err != nil {
+			logger.Error("Notify: Sending '%s' notification '%s': %v", n.Kind, n.Title, err)
+		}
+	}()
+}
+
+// NotifyTargetEvent is Notify for an event about a target; the title is prefixed with the target
+// codename.
+func NotifyTargetEvent(kind string, targetID int64, title, message string, data interface{}) {
+	name := fmt.Sprintf("target %d", targetID)
+	if t, err := database.GetTargetByID(targetID); err == nil && t.Codename != "" {
+		name = t.Codename
+	}
+	Notify(models.Notification{Kind: kind, Title: fmt.Sprintf("[%s] %s", name, title), Message: message, Data: data})
+}
+
+// desktopSink shows a desktop notification with notify-send (Linux) or osascript (macOS).
+type desktopSink struct{}
+
+func (desktopSink) Name() string { return "desktop" }
+
+func (desktopSink) Send(ctx context.Context, n models.Notification) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=toolkit", n.Title, n.Message)
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(n.Message), appleScriptString(n.Title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w (%s)", cmd.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// webhookSink POSTs a JSON payload built from the notification to a URL.
+type webhookSink struct {
+	name    string
+	url     string
+	client  *http.Client
+	payload func(n models.Notification) interface{}
+}
+
+func (s webhookSink) Name() string { return s.name }
+
+func (s webhookSink) Send(ctx context.Context, n models.Notification) error {
+	body, err := json.Marshal(s.payload(n))
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func genericWebhookPayload(n models.Notification) interface{} {
+	return n
+}
+
+func slackWebhookPayload(n models.Notification) interface{} {
+	text := "*" + n.Title + "*"
+	if n.Message != "" {
+		text += "\n" + n.Message
+	}
+	return map[string]string{"text": text}
+}
+
+func discordWebhookPayload(n models.Notification) interface{} {
+	content := "**" + n.Title + "**"
+	if n.Message != "" {
+		content += "\n" + n.Message
+	}
+	return map[string]string{"content": content}
+}
@@ -0,0 +1,685 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// defaultParamNames are common parameter names tried when param_mining.wordlist is not set. Debug
+// switches, identifiers and URL-like names come first, as they are the most productive.
+var defaultParamNames = []string{
+	"debug", "test", "admin", "dev", "verbose", "trace", "preview", "draft", "internal", "beta",
+	"id", "user", "user_id", "userid", "uid", "account", "account_id", "email", "username", "role",
+	"url", "redirect", "redirect_uri", "redirect_url", "next", "return", "return_to", "returnUrl", "callback", "continue",
+	"q", "query", "search", "s", "keyword", "filter", "sort", "order", "orderby", "dir",
+	"page", "limit", "offset", "per_page", "size", "count", "start", "from", "to", "max",
+	"format", "type", "view", "mode", "output", "lang", "locale", "language", "template", "theme",
+	"file", "filename", "path", "folder", "doc", "document", "include", "page_id", "load",
+	"token", "key", "api_key", "apikey", "access_token", "auth", "session", "sid", "secret", "signature",
+	"action", "cmd", "command", "exec", "method", "function", "func", "do", "op", "task",
+	"name", "title", "message", "msg", "text", "content", "body", "data", "value", "comment",
+	"category", "cat", "tag", "group", "org", "team", "project", "item", "product", "order_id",
+	"code", "state", "status", "ref", "source", "src", "dest", "target", "host", "domain",
+	"jsonp", "cb", "fields", "expand", "embed", "include_deleted", "show", "hidden", "all", "raw",
+	"version", "v", "ver", "api", "client", "client_id", "app", "platform", "device", "channel",
+	"date", "time", "timestamp", "year", "month", "day", "since", "until", "before", "after",
+	"price", "amount", "currency", "quantity", "discount", "coupon", "promo", "plan", "tier", "level",
+	"edit", "delete", "remove", "update", "create", "add", "save", "enable", "disable", "force",
+	"cache", "nocache", "refresh", "reload", "reset", "config", "settings", "options", "env", "log",
+}
+
+var (
+	paramInputNameRegex = regexp.MustCompile(`(?i)<(?:input|select|textarea|button)\b[^>]*?\sname\s*=\s*["']?([A-Za-z0-9_.\[\]-]{1,40})`)
+	paramJSONKeyRegex   = regexp.MustCompile(`"([A-Za-z_][A-Za-z0-9_]{0,39})"\s*:`)
+	paramJSVarRegex     = regexp.MustCompile(`\b(?:var|let|const)\s+([A-Za-z_$][A-Za-z0-9_$]{0,39})\s*=`)
+)
+
+const (
+	// maxMinedParamsPerEndpoint stops mining an endpoint that reacts to this many names, which
+	// means it reacts to almost any name.
+	maxMinedParamsPerEndpoint = 20
+	// maxPageParamNames bounds the names each pattern takes from the baseline response of an endpoint.
+	maxPageParamNames = 200
+)
+
+// StartParamMining starts a job probing captured endpoints of a target with batches of candidate
+// parameter names, Arjun-style. Each endpoint is first requested as captured and with one random
+// parameter; a batch whose response reflects a value or differs from those baselines is halved
+// until the names that caused it remain. Confirmed names are added to the parameter inventory
+// with source 'param_mining' and a confidence level, and their confirming request is logged to
+// the traffic log.
+func StartParamMining(targetID int64, req models.ParamMiningRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	conf := config.AppConfig.ParamMining
+	endpoints, err := paramMiningEndpoints(targetID, req, conf)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(endpoints) == 0 {
+		return models.Job{}, fmt.Errorf("no captured GET endpoints with a 2xx HTML, JSON or XML response to mine for target %d", targetID)
+	}
+	words, err := paramMiningWords(req)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(words) == 0 {
+		return models.Job{}, fmt.Errorf("no parameter names to try: pass words or wordlist_id with words_only")
+	}
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = conf.ChunkSize
+	}
+	if chunkSize <= 0 {
+		chunkSize = 40
+	}
+	inventory, err := database.GetTargetParameterizedURLs(targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	known := make(map[string]map[string]bool)
+	for _, p := range inventory {
+		key := strings.ToUpper(p.RequestMethod.String) + " " + p.RequestPath.String
+		if known[key] == nil {
+			known[key] = make(map[string]bool)
+		}
+		for _, name := range strings.Split(p.ParamKeys, ",") {
+			known[key][name] = true
+		}
+	}
+	latest, err := LatestJob(models.JobTypeParamMining, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a parameter mining job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	m := &paramMiner{
+		targetID: targetID,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}, targetID)),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		words:     words,
+		chunkSize: chunkSize,
+		workers:   conf.Workers,
+		known:     known,
+	}
+	return StartJob(models.JobTypeParamMining, &targetID, fmt.Sprintf("Mining parameters of %d endpoints with %d names...", len(endpoints), len(words)), func(ctx context.Context, job *JobHandle) error {
+		return m.run(ctx, job, endpoints)
+	})
+}
+
+// paramMiningEndpoints resolves the captured requests to mine, like corsCheckEndpoints.
+func paramMiningEndpoints(targetID int64, req models.ParamMiningRequest, conf config.ParamMiningConfig) ([]models.HTTPTrafficLog, error) {
+	if len(req.LogIDs) > 0 {
+		var endpoints []models.HTTPTrafficLog
+		for _, id := range req.LogIDs {
+			entry, err := database.GetHTTPTrafficLogEntryByID(id)
+			if err != nil {
+				return nil, err
+			}
+			if entry.TargetID == nil || *entry.TargetID != targetID {
+				return nil, fmt.Errorf("invalid log entry %d: it does not belong to target %d", id, targetID)
+			}
+			endpoints = append(endpoints, entry)
+		}
+		return endpoints, nil
+	}
+
+	max := req.MaxEndpoints
+	if max <= 0 {
+		max = conf.MaxEndpoints
+	}
+	if max <= 0 {
+		max = 50
+	}
+	candidates, err := database.GetParamMiningCandidates(targetID)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.ToLower(strings.TrimSpace(req.Host))
+	seen := map[string]bool{}
+	var endpoints []models.HTTPTrafficLog
+	for _, e := range candidates {
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" || (host != "" && strings.ToLower(u.Hostname()) != host) || isStaticAssetPath(u) {
+			continue
+		}
+		canonical := CanonicalizeURL(e.RequestURL.String, "")
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		endpoints = append(endpoints, e)
+		if len(endpoints) >= max {
+			break
+		}
+	}
+	return endpoints, nil
+}
+
+// paramMiningWords returns the configured or built-in parameter names followed by the request's
+// words and stored wordlist, without duplicates.
+func paramMiningWords(req models.ParamMiningRequest) ([]string, error) {
+	var raw []string
+	if !req.WordsOnly {
+		if path := config.AppConfig.ParamMining.Wordlist; path != "" {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading param_mining.wordlist: %w", err)
+			}
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				raw = append(raw, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("reading param_mining.wordlist: %w", err)
+			}
+		} else {
+			raw = append(raw, defaultParamNames...)
+		}
+	}
+	raw = append(raw, req.Words...)
+	if req.WordlistID != 0 {
+		stored, err := ReadWordlist(req.WordlistID)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, stored...)
+	}
+	seen := map[string]bool{}
+	var words []string
+	for _, w := range raw {
+		w = strings.TrimSpace(w)
+		if w == "" || strings.HasPrefix(w, "#") || seen[w] || !validParamName(w) {
+			continue
+		}
+		seen[w] = true
+		words = append(words, w)
+	}
+	return words, nil
+}
+
+// validParamName accepts names of up to 40 letters, digits and _ . - [ ] characters.
+func validParamName(name string) bool {
+	if len(name) > 40 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && !strings.ContainsRune("_.-[]", c) {
+			return false
+		}
+	}
+	return true
+}
+
+type paramMiner struct {
+	targetID  int64
+	client    *http.Client
+	words     []string
+	chunkSize int
+	workers   int
+	known     map[string]map[string]bool // Inventory parameter names by "METHOD path"
+
+	mu    sync.Mutex
+	found int
+	added []string
+}
+
+// paramEndpoint is a captured request that candidate parameters are added to.
+type paramEndpoint struct {
+	entry    models.HTTPTrafficLog
+	method   string
+	url      *url.URL
+	location string
+	headers  http.Header
+	body     []byte                 // Captured body, sent unchanged with query parameters
+	form     url.Values             // Captured form body
+	object   map[string]interface{} // Captured JSON object body
+}
+
+// paramProbe is a request with candidate parameters and its response.
+type paramProbe struct {
+	req      *http.Request
+	resp     *http.Response
+	body     []byte
+	start    time.Time
+	canaries map[string]string // Value sent for each name
+	sig      models.ResponseSignature
+}
+
+// paramBaseline is what an endpoint answers without candidates: as captured and with one random
+// parameter.
+type paramBaseline struct {
+	plain, junk *paramProbe
+	stable      bool // Both answers match, so content changes are meaningful
+	echoesAll   bool // The random value is reflected, so reflections mean nothing
+}
+
+// paramHit is a parameter confirmed by a request with it alone.
+type paramHit struct {
+	name       string
+	confidence string
+	reason     string
+	probe      *paramProbe
+}
+
+func (m *paramMiner) run(ctx context.Context, job *JobHandle, endpoints []models.HTTPTrafficLog) error {
+	job.SetTotal(int64(len(endpoints)))
+	workers := m.workers
+	if workers <= 0 {
+		workers = 3
+	}
+
+	ch := make(chan models.HTTPTrafficLog)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range ch {
+				hits, err := m.mineEndpoint(ctx, entry)
+				if err != nil {
+					job.RecordError(fmt.Errorf("%s: %w", entry.RequestURL.String, err))
+				}
+				if hits > 0 {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, entry := range endpoints {
+		select {
+		case <-ctx.Done():
+			break feed
+		case ch <- entry:
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	job.SetMessage("Mined %d endpoints: %d hidden parameters found, %d new in the parameter inventory.", len(endpoints), m.found, len(m.added))
+	if len(m.added) > 0 {
+		NotifyTargetEvent(models.NotificationScanFinished, m.targetID, fmt.Sprintf("Parameter mining found %d hidden parameters", len(m.added)),
+			SubdomainNotificationList(m.added, 10), m.added)
+	}
+	return ctx.Err()
+}
+
+// newParamEndpoint prepares a captured request for mining. Form and JSON object bodies of
+// non-GET requests take the candidates; any other request takes them in its query string.
+func newParamEndpoint(entry models.HTTPTrafficLog) (*paramEndpoint, error) {
+	u, err := url.Parse(entry.RequestURL.String)
+	if err != nil {
+		return nil, err
+	}
+	ep := &paramEndpoint{entry: entry, method: strings.ToUpper(entry.RequestMethod.String), url: u, location: models.ParamLocationQuery, headers: http.Header{}, body: entry.RequestBody}
+	if ep.method == "" {
+		ep.method = http.MethodGet
+	}
+	for name, values := range HeadersFromJSON(entry.RequestHeaders.String) {
+		canonical := http.CanonicalHeaderKey(name)
+		// Conditional requests would get a 304 without a body to compare.
+		if replaySkippedHeaders[canonical] || isToolkitHeader(canonical) || canonical == "Host" || canonical == "If-None-Match" || canonical == "If-Modified-Since" {
+			continue
+		}
+		ep.headers[canonical] = values
+	}
+	if ep.method == http.MethodGet || ep.method == http.MethodHead {
+		return ep, nil
+	}
+	contentType := strings.ToLower(ep.headers.Get("Content-Type"))
+	switch {
+	case strings.Contains(contentType, "json"):
+		var object map[string]interface{}
+		if err := json.Unmarshal(entry.RequestBody, &object); err == nil && object != nil {
+			ep.location, ep.object = models.ParamLocationJSON, object
+		}
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		if form, err := url.ParseQuery(string(entry.RequestBody)); err == nil {
+			ep.location, ep.form = models.ParamLocationForm, form
+		}
+	}
+	return ep, nil
+}
+
+// knownNames returns the parameters the endpoint already sends or the inventory lists for it.
+func (m *paramMiner) knownNames(ep *paramEndpoint) map[string]bool {
+	names := make(map[string]bool)
+	for name := range m.known[ep.method+" "+ep.url.Path] {
+		names[name] = true
+	}
+	for name := range ep.url.Query() {
+		names[name] = true
+	}
+	for name := range ep.form {
+		names[name] = true
+	}
+	for name := range ep.object {
+		names[name] = true
+	}
+	return names
+}
+
+// mineEndpoint mines one captured request and stores the parameters it finds. It returns how
+// many were found.
+func (m *paramMiner) mineEndpoint(ctx context.Context, entry models.HTTPTrafficLog) (int, error) {
+	ep, err := newParamEndpoint(entry)
+	if err != nil {
+		return 0, err
+	}
+	base, err := m.baseline(ctx, ep)
+	if err != nil {
+		return 0, err
+	}
+
+	// Names from the page itself (form fields, JSON keys, script variables) are tried first.
+	known := m.knownNames(ep)
+	var names []string
+	add := func(name string) {
+		if !known[name] && validParamName(name) {
+			known[name] = true
+			names = append(names, name)
+		}
+	}
+	page := string(base.plain.body)
+	for _, re := range []*regexp.Regexp{paramInputNameRegex, paramJSONKeyRegex, paramJSVarRegex} {
+		for _, match := range re.FindAllStringSubmatch(page, maxPageParamNames) {
+			add(match[1])
+		}
+	}
+	for _, name := range m.words {
+		add(name)
+	}
+
+	var hits []paramHit
+	for start := 0; start < len(names); start += m.chunkSize {
+		if ctx.Err() != nil {
+			return len(hits), ctx.Err()
+		}
+		end := min(start+m.chunkSize, len(names))
+		if err := m.search(ctx, ep, base, names[start:end], &hits); err != nil {
+			return len(hits), err
+		}
+		if len(hits) >= maxMinedParamsPerEndpoint {
+			logger.Warn("Parameter mining: %s reacts to at least %d names, stopping there", entry.RequestURL.String, len(hits))
+			break
+		}
+	}
+	for _, hit := range hits {
+		if err := m.store(ep, hit); err != nil {
+			return len(hits), err
+		}
+	}
+	return len(hits), nil
+}
+
+// baseline requests the endpoint as captured and with one random parameter.
+func (m *paramMiner) baseline(ctx context.Context, ep *paramEndpoint) (*paramBaseline, error) {
+	plain, err := m.send(ctx, ep, nil)
+	if err != nil {
+		return nil, err
+	}
+	junkName, err := randomHex(4)
+	if err != nil {
+		return nil, err
+	}
+	junk, err := m.send(ctx, ep, []string{"tk" + junkName})
+	if err != nil {
+		return nil, err
+	}
+	base := &paramBaseline{plain: plain, junk: junk, stable: SignaturesMatch(plain.sig, junk.sig, baselineMaxDistance())}
+	value := junk.canaries["tk"+junkName]
+	base.echoesAll = bytes.Contains(junk.body, []byte(value)) || strings.Contains(junk.resp.Header.Get("Location"), value)
+	return base, nil
+}
+
+// search sends a batch of names and halves it while its response still differs from the
+// baseline, recording the single names that keep making a difference.
+func (m *paramMiner) search(ctx context.Context, ep *paramEndpoint, base *paramBaseline, names []string, hits *[]paramHit) error {
+	if len(names) == 0 || len(*hits) >= maxMinedParamsPerEndpoint || ctx.Err() != nil {
+		return ctx.Err()
+	}
+	probe, err := m.send(ctx, ep, names)
+	if err != nil {
+		return err
+	}
+	reflected, _, _ := m.judge(base, probe)
+	changed := m.changed(base, probe)
+	if len(reflected) == 0 && !changed {
+		return nil
+	}
+	// A reflected value names its parameter; only the rest needs halving.
+	rest := names
+	if len(reflected) > 0 {
+		rest = nil
+		isReflected := make(map[string]bool, len(reflected))
+		for _, name := range reflected {
+			isReflected[name] = true
+			if err := m.confirm(ctx, ep, base, name, hits); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			if !isReflected[name] {
+				rest = append(rest, name)
+			}
+		}
+	}
+	if !changed || len(rest) == 0 {
+		return nil
+	}
+	if len(rest) == 1 {
+		return m.confirm(ctx, ep, base, rest[0], hits)
+	}
+	half := len(rest) / 2
+	if err := m.search(ctx, ep, base, rest[:half], hits); err != nil {
+		return err
+	}
+	return m.search(ctx, ep, base, rest[half:], hits)
+}
+
+// confirm sends one name alone, with a fresh value, and records it when the response still
+// differs from the baseline.
+func (m *paramMiner) confirm(ctx context.Context, ep *paramEndpoint, base *paramBaseline, name string, hits *[]paramHit) error {
+	if len(*hits) >= maxMinedParamsPerEndpoint {
+		return nil
+	}
+	probe, err := m.send(ctx, ep, []string{name})
+	if err != nil {
+		return err
+	}
+	reflected, confidence, reason := m.judge(base, probe)
+	if len(reflected) == 0 && confidence == "" {
+		return nil
+	}
+	*hits = append(*hits, paramHit{name: name, confidence: confidence, reason: reason, probe: probe})
+	return nil
+}
+
+// judge compares a response with the baseline. It returns the names whose value is reflected and,
+// for a single-name probe, the confidence and reason of the strongest difference.
+func (m *paramMiner) judge(base *paramBaseline, probe *paramProbe) ([]string, string, string) {
+	var reflected []string
+	if !base.echoesAll {
+		location := probe.resp.Header.Get("Location")
+		for name, value := range probe.canaries {
+			if bytes.Contains(probe.body, []byte(value)) || strings.Contains(location, value) {
+				reflected = append(reflected, name)
+			}
+		}
+	}
+	status := probe.resp.StatusCode
+	switch {
+	case len(reflected) > 0:
+		return reflected, models.ConfidenceHigh, fmt.Sprintf("its value is reflected in the %d response", status)
+	case status != base.plain.resp.StatusCode && status != base.junk.resp.StatusCode:
+		return nil, models.ConfidenceMedium, fmt.Sprintf("the status changes from %d to %d", base.junk.resp.StatusCode, status)
+	case m.changed(base, probe):
+		return nil, models.ConfidenceLow, fmt.Sprintf("the response content changes (%d bytes instead of %d)", len(probe.body), len(base.junk.body))
+	}
+	return nil, "", ""
+}
+
+// changed reports whether a response differs from the baseline by its status code or, on an
+// endpoint whose baseline answers match, by its content.
+func (m *paramMiner) changed(base *paramBaseline, probe *paramProbe) bool {
+	status := probe.resp.StatusCode
+	if status != base.plain.resp.StatusCode && status != base.junk.resp.StatusCode {
+		return true
+	}
+	return base.stable && !SignaturesMatch(base.junk.sig, probe.sig, baselineMaxDistance()) &&
+		!SignaturesMatch(base.plain.sig, probe.sig, baselineMaxDistance())
+}
+
+// send requests the endpoint with a random value for each name and signs the response with the
+// values blanked out, so reflections alone do not count as content changes.
+func (m *paramMiner) send(ctx context.Context, ep *paramEndpoint, names []string) (*paramProbe, error) {
+	canaries := make(map[string]string, len(names))
+	for _, name := range names {
+		value, err := randomHex(5)
+		if err != nil {
+			return nil, err
+		}
+		canaries[name] = value
+	}
+
+	u := *ep.url
+	body := ep.body
+	switch ep.location {
+	case models.ParamLocationQuery:
+		query := u.Query()
+		for name, value := range canaries {
+			query.Set(name, value)
+		}
+		u.RawQuery = query.Encode()
+	case models.ParamLocationForm:
+		form := url.Values{}
+		for name, values := range ep.form {
+			form[name] = values
+		}
+		for name, value := range canaries {
+			form.Set(name, value)
+		}
+		body = []byte(form.Encode())
+	case models.ParamLocationJSON:
+		object := make(map[string]interface{}, len(ep.object)+len(canaries))
+		for name, value := range ep.object {
+			object[name] = value
+		}
+		for name, value := range canaries {
+			object[name] = value
+		}
+		encoded, err := json.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		body = encoded
+	}
+	var bodyReader io.Reader
+	if len(body) > 0 {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, ep.method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = ep.headers.Clone()
+
+	start := time.Now()
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, probeMaxBody))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	stripped := respBody
+	headers := resp.Header.Clone()
+	for _, value := range canaries {
+		stripped = bytes.ReplaceAll(stripped, []byte(value), nil)
+		if location := headers.Get("Location"); location != "" {
+			headers.Set("Location", strings.ReplaceAll(location, value, ""))
+		}
+	}
+	return &paramProbe{
+		req:      req,
+		resp:     resp,
+		body:     respBody,
+		start:    start,
+		canaries: canaries,
+		sig:      SignResponse(models.SignatureSourceProbe, u.String(), resp.StatusCode, headers, stripped),
+	}, nil
+}
+
+// store logs a hit's confirming request and adds the parameter to the inventory.
+func (m *paramMiner) store(ep *paramEndpoint, hit paramHit) error {
+	entry := logProbe(m.targetID, hit.probe.req, hit.probe.resp, hit.probe.body, hit.probe.start, models.ParamMiningLogSource)
+	_, created, err := database.UpsertMinedParameter(models.ParameterizedURL{
+		TargetID:         sql.NullInt64{Int64: m.targetID, Valid: true},
+		HTTPTrafficLogID: sql.NullInt64{Int64: entry.ID, Valid: entry.ID != 0},
+		RequestMethod:    models.NullString(ep.method),
+		RequestPath:      models.NullString(ep.url.Path),
+		ParamKeys:        hit.name,
+		ExampleFullURL:   models.NullString(hit.probe.req.URL.String()),
+		Notes:            models.NullString(fmt.Sprintf("Mined %s parameter: %s", ep.location, hit.reason)),
+		Confidence:       models.NullString(hit.confidence),
+	})
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.found++
+	if created {
+		m.added = append(m.added, fmt.Sprintf("%s on %s %s%s", hit.name, ep.method, ep.url.Host, ep.url.Path))
+	}
+	m.mu.Unlock()
+	logger.Info("Parameter mining: %s on %s %s (%s confidence: %s)", hit.name, ep.method, ep.url.String(), hit.confidence, hit.reason)
+	return nil
+}
+
+// randomHex returns n random bytes as hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random value: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// maxExpandedPayloads bounds the payloads expanded at once, so a large wordlist cannot flood the
+// payload_expansions table.
+const maxExpandedPayloads = 100000
+
+// GeneratePayloadValues returns values for the payload placeholders ({{oob}}, {{random}},
+// {{timestamp}}, {{target.domain}}) used in fields and not defined by variables, generated for one
+// request: a placeholder used twice gets the same value. {{oob}} needs payloads.oob_domain and
+// {{target.domain}} a target with a link; without them they are left out.
+func GeneratePayloadValues(targetID int64, variables map[string]string, fields ...string) map[string]string {
+	generated := map[string]string{}
+	for _, field := range fields {
+		for _, m := range variablePlaceholderRegex.FindAllStringSubmatch(field, -1) {
+			name := m[1]
+			if _, defined := variables[name]; defined {
+				continue
+			}
+			if _, done := generated[name]; done {
+				continue
+			}
+			if value, ok := payloadValue(targetID, name); ok {
+				generated[name] = value
+			}
+		}
+	}
+	return generated
+}
+
+// payloadValue generates the value of a payload placeholder. ok is false for other names and when
+// the value cannot be generated.
+func payloadValue(targetID int64, name string) (string, bool) {
+	switch name {
+	case models.PlaceholderOOB:
+		domain := strings.Trim(strings.ToLower(strings.TrimSpace(config.AppConfig.Payloads.OOBDomain)), ".")
+		if domain == "" {
+			return "", false
+		}
+		id, err := randomHex(8)
+		if err != nil {
+			logger.Error("Payload placeholders: %v", err)
+			return "", false
+		}
+		return id + "." + domain, true
+	case models.PlaceholderRandom:
+		length := config.AppConfig.Payloads.RandomLength
+		if length <= 0 {
+			length = 12
+		}
+		value, err := randomAlphanumeric(length)
+		if err != nil {
+			logger.Error("Payload placeholders: %v", err)
+			return "", false
+		}
+		return value, true
+	case models.PlaceholderTimestamp:
+		return strconv.FormatInt(time.Now().Unix(), 10), true
+	case models.PlaceholderTargetDomain:
+		if targetID == 0 {
+			return "", false
+		}
+		target, err := database.GetTargetByID(targetID)
+		if err != nil || strings.TrimSpace(target.Link) == "" {
+			return "", false
+		}
+		link := strings.TrimSpace(target.Link)
+		if !strings.Contains(link, "://") {
+			link = "https://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			return "", false
+		}
+		return strings.ToLower(u.Hostname()), true
+	}
+	return "", false
+}
+
+// randomAlphanumeric returns n random lowercase letters and digits.
+func randomAlphanumeric(n int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random value: %w", err)
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b), nil
+}
+
+// WithPayloadValues returns variables with the generated payload values added. Neither map is
+// changed.
+func WithPayloadValues(variables, generated map[string]string) map[string]string {
+	values := make(map[string]string, len(variables)+len(generated))
+	for name, value := range variables {
+		values[name] = value
+	}
+	for name, value := range generated {
+		values[name] = value
+	}
+	return values
+}
+
+// RecordPayloadValues stores the values generated for a request with its log entry (logID 0 for
+// none), so an out-of-band interaction or a reflected value can be traced back to it. Errors are
+// logged.
+func RecordPayloadValues(targetID *int64, logID int64, source, context string, generated map[string]string) {
+	if err := database.CreatePayloadExpansions(payloadExpansions(targetID, logID, source, context, generated)); err != nil {
+		logger.Error("Payload placeholders: recording values: %v", err)
+	}
+}
+
+func payloadExpansions(targetID *int64, logID int64, source, context string, generated map[string]string) []models.PayloadExpansion {
+	var expansions []models.PayloadExpansion
+	for name, value := range generated {
+		e := models.PayloadExpansion{TargetID: targetID, Source: source, Placeholder: name, Value: value, Context: context}
+		if logID != 0 {
+			e.LogID = &logID
+		}
+		if name == models.PlaceholderOOB {
+			e.OOBID, _, _ = strings.Cut(value, ".")
+		}
+		expansions = append(expansions, e)
+	}
+	return expansions
+}
+
+// ExpandPayloads expands the placeholders of payloads for a fuzzer that sends them itself: each
+// payload gets its own values ({{oob}} identifiers included), which are recorded with the payload
+// as written. The target's variables are substituted too.
+func ExpandPayloads(targetID int64, req models.PayloadExpandRequest) (models.PayloadExpandResult, error) {
+	result := models.PayloadExpandResult{Payloads: []string{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	payloads := req.Payloads
+	if req.WordlistID != 0 {
+		words, err := ReadWordlist(req.WordlistID)
+		if err != nil {
+			return result, err
+		}
+		payloads = append(payloads, words...)
+	}
+	if len(payloads) == 0 {
+		return result, fmt.Errorf("invalid request: no payloads given")
+	}
+	if len(payloads) > maxExpandedPayloads {
+		return result, fmt.Errorf("invalid request: %d payloads, at most %d are expanded at once", len(payloads), maxExpandedPayloads)
+	}
+	variables, err := database.GetTargetVariableMap(targetID)
+	if err != nil {
+		return result, err
+	}
+
+	var expansions []models.PayloadExpansion
+	var unresolved []string
+	for _, payload := range payloads {
+		generated := GeneratePayloadValues(targetID, variables, payload)
+		expanded, missing := SubstituteVariables(payload, WithPayloadValues(variables, generated))
+		result.Payloads = append(result.Payloads, expanded)
+		unresolved = append(unresolved, missing...)
+		expansions = append(expansions, payloadExpansions(&targetID, 0, models.PayloadSourcePayloadList, payload, generated)...)
+	}
+	if err := database.CreatePayloadExpansions(expansions); err != nil {
+		return result, err
+	}
+	result.Recorded = len(expansions)
+	result.Unresolved = processSlice(unresolved)
+	return result, nil
+}
+
+// FindPayloadExpansions looks up recorded payload values, e.g. by the name an out-of-band
+// interaction came in for.
+func FindPayloadExpansions(filters models.PayloadExpansionFilters) ([]models.PayloadExpansion, error) {
+	if filters.Limit <= 0 {
+		filters.Limit = 100
+	}
+	return database.FindPayloadExpansions(filters)
+}
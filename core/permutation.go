@@ -0,0 +1,423 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// defaultPermutationWords is the wordlist used unless permutation.wordlist names a file.
+var defaultPermutationWords = []string{
+	"dev", "development", "stage", "staging", "stg", "test", "testing", "qa", "uat", "sandbox", "demo", "beta", "alpha",
+	"preprod", "pre", "prod", "production", "live", "old", "new", "legacy", "backup", "bak", "tmp", "temp",
+	"int", "internal", "corp", "intranet", "ext", "external", "private", "public", "partner", "partners",
+	"admin", "administrator", "manage", "management", "console", "dashboard", "panel", "portal", "backend", "backoffice",
+	"api", "apis", "gateway", "gw", "graphql", "rest", "ws", "rpc", "service", "services", "svc", "app", "apps", "mobile", "m",
+	"auth", "sso", "login", "id", "identity", "oauth", "accounts", "account", "vpn", "remote", "proxy",
+	"cdn", "static", "assets", "media", "img", "images", "files", "upload", "uploads", "download", "docs", "help", "support",
+	"mail", "smtp", "webmail", "git", "gitlab", "jenkins", "ci", "cd", "build", "jira", "confluence", "wiki", "grafana",
+	"kibana", "elastic", "prometheus", "monitor", "monitoring", "status", "metrics", "logs", "db", "sql", "redis", "cache",
+	"k8s", "kube", "docker", "registry", "vault", "s3", "storage", "v1", "v2", "v3", "us", "eu", "uk", "east", "west", "1", "2",
+}
+
+// StartPermutation starts a job that generates subdomain candidates under the base domains from the
+// target's known domains and a wordlist (altdns/dnsgen style: inserted and joined words, replaced
+// labels, bumped numbers, word.base), resolves them concurrently and adds live ones as domains with
+// source 'permutation'. Names matching an out-of-scope rule are never generated, and answers of
+// wildcard DNS zones are discarded. With DryRun the candidates are returned instead of resolved.
+func StartPermutation(targetID int64, req models.PermutationRequest) (models.Job, *models.PermutationPreview, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, nil, err
+	}
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return models.Job{}, nil, err
+	}
+	bases := permutationBases(req.Bases, rules)
+	if len(bases) == 0 {
+		return models.Job{}, nil, fmt.Errorf("no base domains: pass bases or add an in-scope wildcard rule such as *.example.com")
+	}
+	words, err := permutationWords(req)
+	if err != nil {
+		return models.Job{}, nil, err
+	}
+	if len(words) == 0 {
+		return models.Job{}, nil, fmt.Errorf("no words to permute with")
+	}
+	known, err := database.GetDomainNamesForTarget(targetID)
+	if err != nil {
+		return models.Job{}, nil, err
+	}
+	maxCandidates := config.AppConfig.Permute.MaxCandidates
+	if maxCandidates <= 0 {
+		maxCandidates = 50000
+	}
+	if req.MaxCandidates > 0 && req.MaxCandidates < maxCandidates {
+		maxCandidates = req.MaxCandidates
+	}
+	candidates, seeds, truncated := generatePermutations(bases, known, words, maxCandidates, func(name string) bool { return ctNameInScope(name, rules) })
+	if req.DryRun {
+		return models.Job{}, &models.PermutationPreview{Bases: bases, Seeds: seeds, Words: len(words), Candidates: candidates, Truncated: truncated}, nil
+	}
+	if len(candidates) == 0 {
+		return models.Job{}, nil, fmt.Errorf("no new candidates for %s", strings.Join(bases, ", "))
+	}
+
+	latest, err := LatestJob(models.JobTypePermutation, targetID)
+	if err != nil {
+		return models.Job{}, nil, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, nil, fmt.Errorf("a permutation job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+	conf := config.AppConfig.Permute
+	timeout := time.Duration(conf.LookupTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	p := &permuter{targetID: targetID, workers: conf.Workers, timeout: timeout, wildcards: map[string]*wildcardZone{}}
+	message := fmt.Sprintf("Resolving %d candidates under %s (%d known domains, %d words)...", len(candidates), strings.Join(bases, ", "), seeds, len(words))
+	job, err := StartJob(models.JobTypePermutation, &targetID, message, func(ctx context.Context, job *JobHandle) error {
+		return p.run(ctx, job, candidates)
+	})
+	return job, nil, err
+}
+
+// permutationBases returns the requested base domains, or the target's in-scope wildcard bases.
+func permutationBases(requested []string, rules []models.ScopeRule) []string {
+	if len(requested) == 0 {
+		return ctWildcardBases(rules)
+	}
+	seen := map[string]bool{}
+	var bases []string
+	for _, b := range requested {
+		if b = normalizeImportedDomain(b); b != "" && !seen[b] {
+			seen[b] = true
+			bases = append(bases, b)
+		}
+	}
+	return bases
+}
+
+// permutationWords returns the lower-cased, deduplicated words of a request: the configured or
+// built-in wordlist (unless WordsOnly) followed by the request's own words and those of its stored
+// wordlist.
+func permutationWords(req models.PermutationRequest) ([]string, error) {
+	var raw []string
+	if !req.WordsOnly {
+		if path := config.AppConfig.Permute.Wordlist; path != "" {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading permutation.wordlist: %w", err)
+			}
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				raw = append(raw, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("reading permutation.wordlist: %w", err)
+			}
+		} else {
+			raw = append(raw, defaultPermutationWords...)
+		}
+	}
+	raw = append(raw, req.Words...)
+	if req.WordlistID != 0 {
+		stored, err := ReadWordlist(req.WordlistID)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, stored...)
+	}
+	seen := map[string]bool{}
+	var words []string
+	for _, w := range raw {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || strings.HasPrefix(w, "#") || seen[w] || !validPermutationLabel(w) {
+			continue
+		}
+		seen[w] = true
+		words = append(words, w)
+	}
+	return words, nil
+}
+
+func validPermutationLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// generatePermutations derives candidates under each base from the known names below it. Cheap,
+// productive candidates come first (word.base, bumped numbers), then word by word the insertions,
+// joins and replacements for every known name, so a cap cuts the least likely ones. Known names and
+// names allow rejects are left out. It returns the candidates, how many known names were used and
+// whether the cap was hit.
+func generatePermutations(bases, known, words []string, max int, allow func(string) bool) ([]string, int, bool) {
+	exists := make(map[string]bool, len(known))
+	for _, name := range known {
+		exists[strings.ToLower(name)] = true
+	}
+	seen := map[string]bool{}
+	var candidates []string
+	add := func(labels []string, base string) bool {
+		if len(candidates) >= max {
+			return false
+		}
+		name := strings.Join(append(labels[:len(labels):len(labels)], base), ".")
+		if seen[name] || exists[name] || len(name) > 253 {
+			return true
+		}
+		for _, l := range labels {
+			if !validPermutationLabel(l) {
+				return true
+			}
+		}
+		seen[name] = true
+		if allow(name) {
+			candidates = append(candidates, name)
+		}
+		return true
+	}
+
+	// Labels below the base of every known name, per base
+	type seed struct {
+		base   string
+		labels []string
+	}
+	var seeds []seed
+	for _, base := range bases {
+		var names []string
+		for name := range exists {
+			if strings.HasSuffix(name, "."+base) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			seeds = append(seeds, seed{base: base, labels: strings.Split(strings.TrimSuffix(name, "."+base), ".")})
+		}
+	}
+
+	for _, base := range bases {
+		for _, w := range words {
+			if !add([]string{w}, base) {
+				return candidates, len(seeds), true
+			}
+		}
+	}
+	for _, s := range seeds {
+		for i, label := range s.labels {
+			for _, bumped := range bumpNumbers(label) {
+				if !add(replaceLabel(s.labels, i, bumped), s.base) {
+					return candidates, len(seeds), true
+				}
+			}
+		}
+	}
+	for _, w := range words {
+		for _, s := range seeds {
+			for pos := 0; pos <= len(s.labels); pos++ {
+				labels := append(append(append([]string{}, s.labels[:pos]...), w), s.labels[pos:]...)
+				if !add(labels, s.base) {
+					return candidates, len(seeds), true
+				}
+			}
+			for i, label := range s.labels {
+				for _, variant := range []string{w, label + "-" + w, w + "-" + label, label + w, w + label} {
+					if !add(replaceLabel(s.labels, i, variant), s.base) {
+						return candidates, len(seeds), true
+					}
+				}
+			}
+		}
+	}
+	return candidates, len(seeds), false
+}
+
+func replaceLabel(labels []string, i int, label string) []string {
+	out := append([]string{}, labels...)
+	out[i] = label
+	return out
+}
+
+// bumpNumbers returns a label with its trailing number incremented and decremented, keeping zero
+// padding: api2 gives api3 and api1, node09 gives node10 and node08.
+func bumpNumbers(label string) []string {
+	end := len(label)
+	start := end
+	for start > 0 && label[start-1] >= '0' && label[start-1] <= '9' {
+		start--
+	}
+	if start == end || end-start > 6 {
+		return nil
+	}
+	n, _ := strconv.Atoi(label[start:end])
+	width := end - start
+	var out []string
+	for _, v := range []int{n + 1, n - 1} {
+		if v < 0 {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s%0*d", label[:start], width, v))
+	}
+	return out
+}
+
+// permuter resolves the candidates of a permutation job.
+type permuter struct {
+	targetID  int64
+	workers   int
+	timeout   time.Duration
+	mu        sync.Mutex
+	wildcards map[string]*wildcardZone
+}
+
+// wildcardZone holds the answer of a zone to a random name, which is what every name gets when the
+// zone has a wildcard record.
+type wildcardZone struct {
+	once  sync.Once
+	addrs map[string]bool
+}
+
+func (p *permuter) run(ctx context.Context, job *JobHandle, candidates []string) error {
+	job.SetTotal(int64(len(candidates)))
+	workers := p.workers
+	if workers <= 0 {
+		workers = 50
+	}
+	var mu sync.Mutex
+	var added []string
+	wildcardHits := 0
+	nameCh := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range nameCh {
+				live, wildcard, err := p.resolve(ctx, name)
+				if err != nil {
+					job.RecordError(err)
+				}
+				if wildcard {
+					mu.Lock()
+					wildcardHits++
+					mu.Unlock()
+				}
+				if !live {
+					job.AddProgress(1, 0)
+					continue
+				}
+				_, err = database.CreateDomain(models.Domain{TargetID: p.targetID, DomainName: name, Source: models.NullString(models.DomainSourcePermutation), IsInScope: true})
+				if err != nil && !strings.Contains(err.Error(), "already exists") {
+					job.RecordError(err)
+				} else if err == nil {
+					mu.Lock()
+					added = append(added, name)
+					mu.Unlock()
+				}
+				job.AddProgress(1, 1)
+			}
+		}()
+	}
+feed:
+	for _, name := range candidates {
+		select {
+		case <-ctx.Done():
+			break feed
+		case nameCh <- name:
+		}
+	}
+	close(nameCh)
+	wg.Wait()
+
+	sort.Strings(added)
+	job.SetMessage("Resolved %d candidates: %d new live domains added, %d wildcard DNS answers ignored.", len(candidates), len(added), wildcardHits)
+	if len(added) > 0 {
+		NotifyTargetEvent(models.NotificationNewSubdomain, p.targetID, fmt.Sprintf("%d new subdomains found by permutation", len(added)),
+			SubdomainNotificationList(added, 10), added)
+		ResolveIPAssetsAfterDiscovery(p.targetID)
+	}
+	return ctx.Err()
+}
+
+// resolve reports whether name resolves to something other than its zone's wildcard answer.
+// Lookups that fail because the name does not exist are not errors.
+func (p *permuter) resolve(ctx context.Context, name string) (live, wildcard bool, err error) {
+	addrs, err := p.lookup(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && (dnsErr.IsNotFound || dnsErr.IsTimeout) || ctx.Err() != nil {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("resolving %s: %w", name, err)
+	}
+	if len(addrs) == 0 {
+		return false, false, nil
+	}
+	_, zone, _ := strings.Cut(name, ".")
+	if wild := p.wildcardAddrs(ctx, zone); len(wild) > 0 {
+		for _, a := range addrs {
+			if !wild[a] {
+				return true, false, nil
+			}
+		}
+		return false, true, nil
+	}
+	return true, false, nil
+}
+
+func (p *permuter) lookup(ctx context.Context, name string) ([]string, error) {
+	lookupCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return reconResolver().LookupHost(lookupCtx, name)
+}
+
+// wildcardAddrs returns the addresses a random name in zone resolves to, looked up once per zone.
+func (p *permuter) wildcardAddrs(ctx context.Context, zone string) map[string]bool {
+	p.mu.Lock()
+	z, ok := p.wildcards[zone]
+	if !ok {
+		z = &wildcardZone{}
+		p.wildcards[zone] = z
+	}
+	p.mu.Unlock()
+	z.once.Do(func() {
+		token := make([]byte, 8)
+		if _, err := rand.Read(token); err != nil {
+			return
+		}
+		addrs, err := p.lookup(ctx, hex.EncodeToString(token)+"."+zone)
+		if err != nil || len(addrs) == 0 {
+			return
+		}
+		z.addrs = make(map[string]bool, len(addrs))
+		for _, a := range addrs {
+			z.addrs[a] = true
+		}
+		logger.Info("Permutation: %s has a wildcard DNS record (%v); names resolving only to it are ignored", zone, addrs)
+	})
+	return z.addrs
+}
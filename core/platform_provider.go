@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// PlatformProvider is a bug bounty platform integration fed by proxied traffic. The proxy hands
+// every response to a URL the provider matches over to ProcessCapturedResponse; the provider then
+// stores what it finds and fetches follow-up data (analytics, findings) from the platform API.
+// Matching responses are processed even when they are out of scope for the active target.
+type PlatformProvider interface {
+	Name() string
+	MatchesTrafficURL(u *url.URL) bool
+	// ProcessCapturedResponse is called in its own goroutine with the request (for credentials)
+	// and the decoded response body.
+	ProcessCapturedResponse(req *http.Request, statusCode int, contentType string, body []byte) error
+	// FetchAnalytics refreshes the platform analytics of one program, identified by the platform's ID.
+	FetchAnalytics(ctx context.Context, externalID string) error
+	Status() models.PlatformProviderStatus
+}
+
+var (
+	platformProviders   []PlatformProvider
+	platformProvidersMu sync.RWMutex
+)
+
+// ConfigurePlatformProviders (re)builds the provider list from the current configuration. It is
+// called when the proxy starts.
+func ConfigurePlatformProviders(missionService *SynackMissionService) {
+	providers := []PlatformProvider{NewSynackProvider(missionService)}
+	platformProvidersMu.Lock()
+	platformProviders = providers
+	platformProvidersMu.Unlock()
+}
+
+// PlatformProviders returns the configured providers.
+func PlatformProviders() []PlatformProvider {
+	platformProvidersMu.RLock()
+	defer platformProvidersMu.RUnlock()
+	return append([]PlatformProvider(nil), platformProviders...)
+}
+
+// GetPlatformProvider returns the provider with the given name (case-insensitive).
+func GetPlatformProvider(name string) (PlatformProvider, error) {
+	for _, p := range PlatformProviders() {
+		if strings.EqualFold(p.Name(), name) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("platform provider '%s' not found", name)
+}
+
+// matchPlatformProvider returns the provider interested in traffic to u, or nil.
+func matchPlatformProvider(u *url.URL) PlatformProvider {
+	if u == nil {
+		return nil
+	}
+	for _, p := range PlatformProviders() {
+		if p.MatchesTrafficURL(u) {
+			return p
+		}
+	}
+	return nil
+}
+
+// trafficURLMatches reports whether u points at the same scheme, host and path as pattern
+// (trailing slashes ignored, query ignored).
+func trafficURLMatches(pattern, u *url.URL) bool {
+	return pattern != nil && u != nil &&
+		u.Scheme == pattern.Scheme &&
+		u.Hostname() == pattern.Hostname() &&
+		strings.TrimRight(u.Path, "/") == strings.TrimRight(pattern.Path, "/")
+}
+
+// providerStats tracks the counters behind models.PlatformProviderStatus. Providers embed it.
+type providerStats struct {
+	mu     sync.Mutex
+	status models.PlatformProviderStatus
+}
+
+func (s *providerStats) recordProcessed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.status.ResponsesProcessed++
+	s.status.LastProcessedAt = &now
+}
+
+func (s *providerStats) recordAuthToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.status.HasAuthToken = true
+	s.status.AuthTokenSeenAt = &now
+}
+
+func (s *providerStats) recordAnalytics(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.status.AnalyticsFetched++
+		return
+	}
+	s.status.AnalyticsFailed++
+	s.recordErrorLocked(err)
+}
+
+func (s *providerStats) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordErrorLocked(err)
+}
+
+func (s *providerStats) recordErrorLocked(err error) {
+	now := time.Now()
+	s.status.LastError = err.Error()
+	s.status.LastErrorAt = &now
+}
+
+func (s *providerStats) recordRetry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Retries++
+}
+
+func (s *providerStats) snapshot() models.PlatformProviderStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// providerRetryPolicy controls how platform API requests are retried.
+type providerRetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// doWithRetry sends a body-less request, retrying network errors, 429 and 5xx responses with
+// exponential backoff (or the server's Retry-After). The last response is returned even when it is
+// still an error status; onRetry is called before each retry.
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, policy providerRetryPolicy, onRetry func()) (*http.Response, error) {
+	backoff := policy.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req.Clone(ctx))
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= policy.MaxRetries {
+			return resp, err
+		}
+
+		delay := backoff
+		if err == nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = d
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+			delay = policy.MaxBackoff
+		}
+		if err != nil {
+			logger.ProxyInfo("Platform API: %s %s failed (%v), retrying in %s (attempt %d/%d)", req.Method, req.URL.String(), err, delay, attempt+1, policy.MaxRetries)
+		} else {
+			logger.ProxyInfo("Platform API: %s %s returned %d, retrying in %s (attempt %d/%d)", req.Method, req.URL.String(), resp.StatusCode, delay, attempt+1, policy.MaxRetries)
+		}
+		if onRetry != nil {
+			onRetry()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
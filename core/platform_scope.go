@@ -0,0 +1,332 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// platformScopeMaxPages bounds how many pages of structured scope are followed for one program.
+const platformScopeMaxPages = 50
+
+// hackerOneNetworkAssets are the HackerOne asset types that map to scope rules.
+var hackerOneNetworkAssets = map[string]bool{"URL": true, "DOMAIN": true, "WILDCARD": true, "CIDR": true, "IP_ADDRESS": true, "API": true}
+
+// bugcrowdNetworkCategories are the Bugcrowd target categories that map to scope rules.
+var bugcrowdNetworkCategories = map[string]bool{"website": true, "api": true, "network": true, "other": true, "": true}
+
+// FetchPlatformScope fetches the structured scope of a HackerOne or Bugcrowd program and converts
+// each entry to a scope rule pattern. Credentials come from the platform_import config section.
+func FetchPlatformScope(ctx context.Context, platform, program string) ([]models.PlatformScopeEntry, error) {
+	program = strings.TrimSpace(program)
+	if program == "" {
+		return nil, fmt.Errorf("program is required")
+	}
+	conf := config.AppConfig.Platforms
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	switch strings.ToLower(strings.TrimSpace(platform)) {
+	case models.ScopePlatformHackerOne:
+		if conf.HackerOne.APIUsername == "" || conf.HackerOne.APIToken == "" {
+			return nil, fmt.Errorf("HackerOne API credentials are not configured (platform_import.hackerone.api_username / api_token)")
+		}
+		return fetchHackerOneScope(ctx, client, conf.HackerOne, program)
+	case models.ScopePlatformBugcrowd:
+		if conf.Bugcrowd.APIToken == "" {
+			return nil, fmt.Errorf("Bugcrowd API token is not configured (platform_import.bugcrowd.api_token)")
+		}
+		return fetchBugcrowdScope(ctx, client, conf.Bugcrowd, program)
+	default:
+		return nil, fmt.Errorf("unsupported platform '%s' (expected %s or %s)", platform, models.ScopePlatformHackerOne, models.ScopePlatformBugcrowd)
+	}
+}
+
+// fetchHackerOneScope pages through /hackers/programs/{handle}/structured_scopes.
+func fetchHackerOneScope(ctx context.Context, client *http.Client, conf config.HackerOneConfig, handle string) ([]models.PlatformScopeEntry, error) {
+	next := fmt.Sprintf("%s/hackers/programs/%s/structured_scopes?page%%5Bsize%%5D=100", strings.TrimRight(conf.BaseURL, "/"), url.PathEscape(handle))
+	var entries []models.PlatformScopeEntry
+	for page := 0; next != "" && page < platformScopeMaxPages; page++ {
+		body, err := getPlatformJSON(ctx, client, next, func(req *http.Request) {
+			req.SetBasicAuth(conf.APIUsername, conf.APIToken)
+			req.Header.Set("Accept", "application/json")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetching HackerOne scope for '%s': %w", handle, err)
+		}
+		gjson.GetBytes(body, "data").ForEach(func(_, item gjson.Result) bool {
+			attrs := item.Get("attributes")
+			assetType := strings.ToUpper(attrs.Get("asset_type").String())
+			base := models.PlatformScopeEntry{
+				AssetType:      assetType,
+				InScope:        attrs.Get("eligible_for_submission").Bool(),
+				BountyEligible: attrs.Get("eligible_for_bounty").Bool(),
+				MaxSeverity:    attrs.Get("max_severity").String(),
+				Instruction:    attrs.Get("instruction").String(),
+			}
+			entries = append(entries, splitPlatformScopeEntry(base, attrs.Get("asset_identifier").String(), hackerOneNetworkAssets[assetType])...)
+			return true
+		})
+		next = gjson.GetBytes(body, "links.next").String()
+	}
+	return entries, nil
+}
+
+// fetchBugcrowdScope reads the target groups of a program's current brief. Targets in a group share
+// its in-scope flag and reward eligibility.
+func fetchBugcrowdScope(ctx context.Context, client *http.Client, conf config.BugcrowdConfig, program string) ([]models.PlatformScopeEntry, error) {
+	endpoint := fmt.Sprintf("%s/programs/%s?include=current_brief.target_groups.targets", strings.TrimRight(conf.BaseURL, "/"), url.PathEscape(program))
+	body, err := getPlatformJSON(ctx, client, endpoint, func(req *http.Request) {
+		req.Header.Set("Authorization", "Token "+conf.APIToken)
+		req.Header.Set("Accept", "application/vnd.bugcrowd+json")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching Bugcrowd scope for '%s': %w", program, err)
+	}
+
+	targets := make(map[string]gjson.Result)
+	var groups []gjson.Result
+	gjson.GetBytes(body, "included").ForEach(func(_, item gjson.Result) bool {
+		switch item.Get("type").String() {
+		case "target":
+			targets[item.Get("id").String()] = item.Get("attributes")
+		case "target_group":
+			groups = append(groups, item)
+		}
+		return true
+	})
+
+	var entries []models.PlatformScopeEntry
+	for _, group := range groups {
+		inScope := group.Get("attributes.in_scope").Bool()
+		rewarded := inScope && !group.Get("attributes.non_rewardable").Bool()
+		group.Get("relationships.targets.data").ForEach(func(_, ref gjson.Result) bool {
+			attrs, ok := targets[ref.Get("id").String()]
+			if !ok {
+				return true
+			}
+			category := strings.ToLower(attrs.Get("category").String())
+			identifier := attrs.Get("uri").String()
+			if identifier == "" {
+				identifier = attrs.Get("name").String()
+			}
+			base := models.PlatformScopeEntry{
+				AssetType:      category,
+				InScope:        inScope,
+				BountyEligible: rewarded,
+				Instruction:    attrs.Get("description").String(),
+			}
+			entries = append(entries, splitPlatformScopeEntry(base, identifier, bugcrowdNetworkCategories[category])...)
+			return true
+		})
+	}
+	return entries, nil
+}
+
+// getPlatformJSON performs an authenticated GET against a platform API and returns the body.
+func getPlatformJSON(ctx context.Context, client *http.Client, endpoint string, authorize func(*http.Request)) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	authorize(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		snippet := string(body)
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		return nil, fmt.Errorf("platform API returned status %d: %s", resp.StatusCode, strings.TrimSpace(snippet))
+	}
+	if !gjson.ValidBytes(body) {
+		return nil, fmt.Errorf("platform API returned invalid JSON")
+	}
+	return body, nil
+}
+
+// splitPlatformScopeEntry converts an identifier (which may list several comma-separated assets)
+// into entries based on base.
+func splitPlatformScopeEntry(base models.PlatformScopeEntry, identifier string, networkAsset bool) []models.PlatformScopeEntry {
+	var entries []models.PlatformScopeEntry
+	for _, part := range strings.Split(identifier, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		entry := base
+		entry.Identifier = part
+		if !networkAsset {
+			entry.SkipReason = fmt.Sprintf("asset type '%s' has no scope rule equivalent", base.AssetType)
+		} else if itemType, pattern, ok := convertPlatformIdentifier(part); ok {
+			entry.ItemType, entry.Pattern = itemType, pattern
+		} else {
+			entry.SkipReason = "identifier is not a host, IP address or CIDR range"
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// convertPlatformIdentifier maps a platform asset identifier (URL, host, wildcard, IP or CIDR) to
+// a scope rule item type and pattern.
+func convertPlatformIdentifier(identifier string) (string, string, bool) {
+	s := strings.TrimSpace(identifier)
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return "cidr", ipNet.String(), true
+	}
+	if strings.Contains(s, "://") {
+		// Wildcards are not valid URL hosts, so swap them out while parsing.
+		u, err := url.Parse(strings.ReplaceAll(s, "*", "wildcard-placeholder"))
+		if err != nil || u.Host == "" {
+			return "", "", false
+		}
+		s = strings.ReplaceAll(u.Hostname(), "wildcard-placeholder", "*")
+	} else {
+		if i := strings.IndexAny(s, "/?#"); i >= 0 {
+			s = s[:i]
+		}
+		if host, _, err := net.SplitHostPort(s); err == nil {
+			s = host
+		}
+	}
+	s = strings.TrimSuffix(strings.ToLower(strings.Trim(s, "[]")), ".")
+	if ip := net.ParseIP(s); ip != nil {
+		return "ip_address", ip.String(), true
+	}
+	if s == "" || !strings.Contains(s, ".") || strings.ContainsAny(s, " \t()<>\"'@") {
+		return "", "", false
+	}
+	if strings.Contains(s, "*") {
+		return "subdomain", s, true
+	}
+	return "domain", s, true
+}
+
+// ImportPlatformScope fetches a program's scope from a platform and adds the converted entries to
+// a target as scope rules (and, when requested, in-scope hosts as domains). Rules and domains that
+// already exist are counted but left alone. Unless DryRun is set, the target's scope fetch time is
+// updated.
+func ImportPlatformScope(ctx context.Context, targetID int64, req models.PlatformScopeImportRequest) (models.PlatformScopeImportResult, error) {
+	result := models.PlatformScopeImportResult{TargetID: targetID, Platform: strings.ToLower(strings.TrimSpace(req.Platform)), Program: strings.TrimSpace(req.Program), DryRun: req.DryRun}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	entries, err := FetchPlatformScope(ctx, req.Platform, req.Program)
+	if err != nil {
+		return result, err
+	}
+	result.Entries = entries
+	if result.Entries == nil {
+		result.Entries = []models.PlatformScopeEntry{}
+	}
+
+	existing, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return result, err
+	}
+	ruleKey := func(itemType, pattern string, inScope bool) string {
+		return fmt.Sprintf("%s\x00%s\x00%t", itemType, strings.ToLower(pattern), inScope)
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, rule := range existing {
+		seen[ruleKey(rule.ItemType, rule.Pattern, rule.IsInScope)] = true
+	}
+
+	for _, entry := range entries {
+		if entry.SkipReason != "" {
+			result.Skipped++
+			continue
+		}
+		key := ruleKey(entry.ItemType, entry.Pattern, entry.InScope)
+		if seen[key] {
+			result.RulesExisting++
+		} else {
+			seen[key] = true
+			if !req.DryRun {
+				rule := models.ScopeRule{TargetID: targetID, ItemType: entry.ItemType, Pattern: entry.Pattern, IsInScope: entry.InScope, Description: platformScopeDescription(result.Platform, entry)}
+				if _, err := database.AddScopeRule(rule); err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("rule %s: %v", entry.Pattern, err))
+					continue
+				}
+			}
+			result.RulesAdded++
+		}
+
+		if req.ImportDomains && !req.DryRun && entry.InScope && (entry.ItemType == "domain" || entry.ItemType == "subdomain") {
+			domain := models.Domain{TargetID: targetID, DomainName: entry.Pattern, Source: models.NullString("scope_import"), IsInScope: true}
+			if strings.HasPrefix(entry.Pattern, "*.") {
+				domain.DomainName = strings.TrimPrefix(entry.Pattern, "*.")
+				domain.IsWildcardScope = true
+				domain.Notes = models.NullString(fmt.Sprintf("Imported from wildcard scope: %s", entry.Pattern))
+			}
+			if strings.Contains(domain.DomainName, "*") {
+				continue // Wildcards inside a label (app-*.example.com) don't name a single domain
+			}
+			if _, err := database.CreateDomain(domain); err != nil {
+				if strings.Contains(err.Error(), "already exists") {
+					result.DomainsExisting++
+				} else {
+					result.Errors = append(result.Errors, fmt.Sprintf("domain %s: %v", domain.DomainName, err))
+				}
+				continue
+			}
+			result.DomainsAdded++
+		}
+	}
+
+	if !req.DryRun {
+		now := time.Now()
+		if err := database.UpdateTargetProgram(targetID, models.TargetProgramRequest{ScopeFetchedAt: &now}); err != nil {
+			return result, err
+		}
+	}
+	if !req.DryRun && result.RulesAdded > 0 {
+		NotifyTargetEvent(models.NotificationScopeChanged, targetID, fmt.Sprintf("%d scope rules imported from %s", result.RulesAdded, result.Platform),
+			fmt.Sprintf("Program '%s': %d rules added, %d already present.", result.Program, result.RulesAdded, result.RulesExisting), result)
+		RefreshIPAssetScope(targetID)
+	}
+	logger.Info("ImportPlatformScope: %s program '%s' -> target %d: %d entries, %d rules added, %d existing, %d domains added, %d skipped (dry run: %t)",
+		result.Platform, result.Program, targetID, len(entries), result.RulesAdded, result.RulesExisting, result.DomainsAdded, result.Skipped, req.DryRun)
+	return result, nil
+}
+
+// platformScopeDescription is the scope rule description recorded for an imported entry.
+func platformScopeDescription(platform string, entry models.PlatformScopeEntry) string {
+	desc := fmt.Sprintf("Imported from %s (%s", platform, entry.AssetType)
+	if entry.InScope && !entry.BountyEligible {
+		desc += ", no bounty"
+	}
+	if entry.MaxSeverity != "" {
+		desc += ", max severity " + entry.MaxSeverity
+	}
+	desc += ")"
+	if instruction := strings.Join(strings.Fields(entry.Instruction), " "); instruction != "" {
+		if len(instruction) > 200 {
+			instruction = instruction[:200] + "..."
+		}
+		desc += ": " + instruction
+	}
+	return desc
+}
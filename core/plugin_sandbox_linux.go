@@ -0,0 +1,17 @@
+//go:build linux
+
+package core
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// sandboxPluginCommand starts a plugin in its own process group, so that a call timing out kills
+// the processes the plugin started as well.
+func sandboxPluginCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
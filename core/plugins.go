@@ -0,0 +1,441 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+	"unicode/utf8"
+)
+
+const (
+	pluginManifestFile      = "plugin.json"
+	defaultPluginTimeout    = 30 * time.Second
+	maxPluginTimeoutSeconds = 3600
+	defaultPluginOutput     = 16 * 1024 * 1024 // Bytes of stdout read from a plugin unless plugins.max_output_bytes is set
+	maxPluginStderr         = 16 * 1024        // Bytes of stderr kept for errors
+)
+
+var (
+	pluginNameRegex    = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	pluginEnvNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// plugins caches the plugins found in plugins.dir for the analyzer registry and notification
+// sinks. ListPlugins rescans the directory.
+var plugins = struct {
+	sync.Mutex
+	loaded bool
+	list   []models.Plugin
+}{}
+
+// ListPlugins scans plugins.dir and returns the plugins found, sorted by name, with their state.
+// Plugins with an invalid manifest are listed with the reason in Error.
+func ListPlugins() ([]models.Plugin, error) {
+	list, err := scanPlugins()
+	if err != nil {
+		return nil, err
+	}
+	plugins.Lock()
+	plugins.list, plugins.loaded = list, true
+	plugins.Unlock()
+	return list, nil
+}
+
+// GetPlugin returns the plugin named name.
+func GetPlugin(name string) (models.Plugin, error) {
+	list, err := ListPlugins()
+	if err != nil {
+		return models.Plugin{}, err
+	}
+	for _, p := range list {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return models.Plugin{}, fmt.Errorf("plugin '%s' not found in %s", name, config.AppConfig.Plugins.Dir)
+}
+
+// SetPluginEnabled turns a plugin on or off. Enabling records the hash of its plugin.json; when
+// the manifest changes afterwards the plugin does not run until it is enabled again.
+func SetPluginEnabled(name string, enabled bool) (models.Plugin, error) {
+	p, err := GetPlugin(name)
+	if err != nil {
+		return p, err
+	}
+	sum := ""
+	if enabled {
+		if p.Error != "" {
+			return p, fmt.Errorf("invalid plugin '%s': %s", name, p.Error)
+		}
+		data, err := os.ReadFile(filepath.Join(p.Dir, pluginManifestFile))
+		if err != nil {
+			return p, fmt.Errorf("reading manifest of plugin '%s': %w", name, err)
+		}
+		sum = pluginManifestSHA256(data)
+	}
+	if err := database.SetPluginState(name, enabled, sum); err != nil {
+		return p, err
+	}
+	logger.Info("SetPluginEnabled: Plugin '%s' (%s) enabled: %t", name, strings.Join(p.Kinds, ", "), enabled)
+	return GetPlugin(name)
+}
+
+// scanPlugins reads the manifests in plugins.dir. A missing directory has no plugins.
+func scanPlugins() ([]models.Plugin, error) {
+	dir := config.AppConfig.Plugins.Dir
+	list := []models.Plugin{}
+	if dir == "" {
+		return list, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading plugins directory %s: %w", dir, err)
+	}
+	states, err := database.GetPluginStates()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		pluginDir := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(pluginDir); err != nil || !info.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(pluginDir, pluginManifestFile))
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				logger.Error("scanPlugins: Reading manifest of %s: %v", pluginDir, err)
+			}
+			continue
+		}
+		p := models.Plugin{Dir: pluginDir}
+		if err := json.Unmarshal(data, &p.PluginManifest); err != nil {
+			p.Error = fmt.Sprintf("invalid %s: %v", pluginManifestFile, err)
+		} else if err := validatePluginManifest(&p.PluginManifest); err != nil {
+			p.Error = err.Error()
+		}
+		if p.Name == "" || !pluginNameRegex.MatchString(p.Name) {
+			p.Name = entry.Name()
+		}
+		if seen[p.Name] {
+			p.Error = fmt.Sprintf("another plugin in %s is named '%s'", dir, p.Name)
+		}
+		seen[p.Name] = true
+		if state, ok := states[p.Name]; ok {
+			p.Enabled = state.Enabled
+			p.Changed = state.Enabled && state.ManifestSHA256 != pluginManifestSHA256(data)
+			updated := state.UpdatedAt
+			p.UpdatedAt = &updated
+		}
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func pluginManifestSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// validatePluginManifest checks a manifest and normalizes its kinds.
+func validatePluginManifest(m *models.PluginManifest) error {
+	m.Name = strings.TrimSpace(m.Name)
+	if !pluginNameRegex.MatchString(m.Name) {
+		return fmt.Errorf("invalid name '%s': lower-case letters, digits, - and _ only", m.Name)
+	}
+	if m.Protocol == 0 {
+		m.Protocol = models.PluginProtocolVersion
+	}
+	if m.Protocol > models.PluginProtocolVersion {
+		return fmt.Errorf("plugin speaks protocol %d; this toolkit supports %d", m.Protocol, models.PluginProtocolVersion)
+	}
+	if len(m.Kinds) == 0 {
+		return fmt.Errorf("invalid kinds: at least one of analyzer, recon, notifier required")
+	}
+	var kinds []string
+	for _, kind := range m.Kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		switch kind {
+		case models.PluginKindAnalyzer:
+			analyzersMu.RLock()
+			_, builtIn := analyzers[m.Name]
+			analyzersMu.RUnlock()
+			if builtIn {
+				return fmt.Errorf("invalid name '%s': taken by a built-in analyzer", m.Name)
+			}
+		case models.PluginKindRecon, models.PluginKindNotifier:
+		default:
+			return fmt.Errorf("invalid kind '%s' (analyzer, recon or notifier)", kind)
+		}
+		kinds = append(kinds, kind)
+	}
+	m.Kinds = processSlice(kinds)
+	if len(m.Command) == 0 || strings.TrimSpace(m.Command[0]) == "" {
+		return fmt.Errorf("invalid command: required")
+	}
+	if m.TimeoutSeconds < 0 || m.TimeoutSeconds > maxPluginTimeoutSeconds {
+		return fmt.Errorf("invalid timeout_seconds %d (0 to %d)", m.TimeoutSeconds, maxPluginTimeoutSeconds)
+	}
+	for _, name := range m.Env {
+		if !pluginEnvNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid env variable name '%s'", name)
+		}
+	}
+	return nil
+}
+
+// enabledPlugins returns the usable enabled plugins of a kind, scanning plugins.dir the first
+// time.
+func enabledPlugins(kind string) []models.Plugin {
+	plugins.Lock()
+	if !plugins.loaded {
+		list, err := scanPlugins()
+		if err != nil {
+			logger.Error("enabledPlugins: %v", err)
+		}
+		plugins.list, plugins.loaded = list, true
+	}
+	list := plugins.list
+	plugins.Unlock()
+
+	var enabled []models.Plugin
+	for _, p := range list {
+		if p.Enabled && !p.Changed && p.Error == "" && containsFold(p.Kinds, kind) {
+			enabled = append(enabled, p)
+		}
+	}
+	return enabled
+}
+
+// usablePlugin returns the plugin named name when it is enabled and of the given kind.
+func usablePlugin(name, kind string) (models.Plugin, error) {
+	p, err := GetPlugin(name)
+	switch {
+	case err != nil:
+		return p, err
+	case p.Error != "":
+		return p, fmt.Errorf("invalid plugin '%s': %s", name, p.Error)
+	case !containsFold(p.Kinds, kind):
+		return p, fmt.Errorf("invalid plugin '%s': not a %s plugin (%s)", name, kind, strings.Join(p.Kinds, ", "))
+	case !p.Enabled:
+		return p, models.ConflictError("plugin '%s' is disabled", name)
+	case p.Changed:
+		return p, models.ConflictError("%s of plugin '%s' changed since it was enabled; enable it again", pluginManifestFile, name)
+	}
+	return p, nil
+}
+
+// runPlugin calls a plugin: the request goes to its stdin as JSON and the response is read from
+// its stdout. The plugin runs in its directory with a minimal environment (PATH, a temporary
+// HOME and TMPDIR removed afterwards, and the variables its manifest lists), is killed when it
+// takes longer than its timeout, and fails when it writes more than plugins.max_output_bytes.
+// The returned string holds the start of its stderr.
+func runPlugin(ctx context.Context, p models.Plugin, req models.PluginRequest) (models.PluginResponse, string, error) {
+	var resp models.PluginResponse
+	timeout := time.Duration(config.AppConfig.Plugins.TimeoutSeconds) * time.Second
+	if p.TimeoutSeconds > 0 {
+		timeout = time.Duration(p.TimeoutSeconds) * time.Second
+	}
+	if timeout <= 0 {
+		timeout = defaultPluginTimeout
+	}
+	maxOutput := config.AppConfig.Plugins.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = defaultPluginOutput
+	}
+	req.Protocol = models.PluginProtocolVersion
+	req.Plugin = p.Name
+	stdin, err := json.Marshal(req)
+	if err != nil {
+		return resp, "", fmt.Errorf("encoding plugin request: %w", err)
+	}
+	home, err := os.MkdirTemp("", "toolkit-plugin-"+p.Name+"-")
+	if err != nil {
+		return resp, "", fmt.Errorf("creating home directory of plugin %s: %w", p.Name, err)
+	}
+	defer os.RemoveAll(home)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Env = pluginEnv(p, home)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout bytes.Buffer
+	stderr := &commandRunState{maxBytes: maxPluginStderr}
+	cmd.Stdout = &limitedWriter{w: &stdout, remaining: maxOutput}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+	sandboxPluginCommand(cmd)
+	errRun := cmd.Run()
+	stderrText := strings.TrimSpace(string(stderr.output))
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("plugin %s timed out after %v", p.Name, timeout)
+	case ctx.Err() != nil:
+		err = fmt.Errorf("plugin %s: %w", p.Name, ctx.Err())
+	case errRun != nil:
+		err = fmt.Errorf("plugin %s failed: %v", p.Name, errRun)
+		if stderrText != "" {
+			err = fmt.Errorf("%w: %s", err, firstLine(stderrText))
+		}
+	case len(bytes.TrimSpace(stdout.Bytes())) == 0:
+	default:
+		if errJSON := json.Unmarshal(stdout.Bytes(), &resp); errJSON != nil {
+			err = fmt.Errorf("plugin %s wrote invalid JSON: %v", p.Name, errJSON)
+		} else if resp.Error != "" {
+			err = fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+		}
+	}
+	return resp, stderrText, err
+}
+
+// pluginEnv is the environment of a plugin: none of the toolkit's own besides PATH and the
+// variables the manifest lists.
+func pluginEnv(p models.Plugin, home string) []string {
+	env := []string{"PATH=" + toolSearchPath(), "HOME=" + home, "TMPDIR=" + home, "LANG=C.UTF-8",
+		"TOOLKIT_PLUGIN=" + p.Name, fmt.Sprintf("TOOLKIT_PLUGIN_PROTOCOL=%d", models.PluginProtocolVersion)}
+	for _, name := range p.Env {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// pluginAnalyzer runs an analyzer plugin on response bodies.
+type pluginAnalyzer struct {
+	plugin models.Plugin
+}
+
+// pluginAnalyzers returns the enabled analyzer plugins.
+func pluginAnalyzers() []Analyzer {
+	var list []Analyzer
+	for _, p := range enabledPlugins(models.PluginKindAnalyzer) {
+		list = append(list, pluginAnalyzer{plugin: p})
+	}
+	return list
+}
+
+func (a pluginAnalyzer) Name() string { return a.plugin.Name }
+
+func (a pluginAnalyzer) Supports(contentType string) bool {
+	if len(a.plugin.ContentTypes) == 0 {
+		return isScannableContentType(contentType)
+	}
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range a.plugin.ContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a pluginAnalyzer) Analyze(body []byte) ([]AnalyzerFinding, error) {
+	input := &models.PluginAnalyzeInput{Body: string(body)}
+	if !utf8.Valid(body) {
+		input.Body, input.BodyBase64 = base64.StdEncoding.EncodeToString(body), true
+	}
+	resp, _, err := runPlugin(context.Background(), a.plugin, models.PluginRequest{Kind: models.PluginKindAnalyzer, Analyze: input})
+	if err != nil {
+		return nil, err
+	}
+	var findings []AnalyzerFinding
+	for _, f := range resp.Findings {
+		if f.Value == "" {
+			continue
+		}
+		if len(f.Context) > maxAnalyzerContext {
+			f.Context = f.Context[:maxAnalyzerContext]
+		}
+		findings = append(findings, AnalyzerFinding{Category: f.Category, Value: f.Value, Context: f.Context})
+	}
+	return findings, nil
+}
+
+// pluginSink delivers notifications through a notifier plugin.
+type pluginSink struct {
+	plugin models.Plugin
+}
+
+func (s pluginSink) Name() string { return "plugin:" + s.plugin.Name }
+
+func (s pluginSink) Send(ctx context.Context, n models.Notification) error {
+	_, _, err := runPlugin(ctx, s.plugin, models.PluginRequest{Kind: models.PluginKindNotifier, Notification: &n})
+	return err
+}
+
+// StartPluginRecon starts a job running a recon plugin for a target. The domains it returns are
+// imported like a subfinder list, with source plugin:<name>.
+func StartPluginRecon(name string, req models.PluginReconRequest) (models.Job, error) {
+	p, err := usablePlugin(name, models.PluginKindRecon)
+	if err != nil {
+		return models.Job{}, err
+	}
+	target, err := database.GetTargetByID(req.TargetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	rules, err := database.GetScopeRulesByTargetID(req.TargetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	domains, err := database.GetDomainNamesForTarget(req.TargetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	input := &models.PluginReconInput{TargetID: req.TargetID, Codename: target.Codename, Scope: []string{}, Domains: domains}
+	for _, rule := range rules {
+		if rule.IsInScope && (rule.ItemType == "domain" || rule.ItemType == "subdomain") {
+			input.Scope = append(input.Scope, rule.Pattern)
+		}
+	}
+	if input.Domains == nil {
+		input.Domains = []string{}
+	}
+
+	return StartJob(models.JobTypePluginRecon, &req.TargetID, fmt.Sprintf("Running recon plugin %s...", p.Name), func(ctx context.Context, job *JobHandle) error {
+		resp, _, err := runPlugin(ctx, p, models.PluginRequest{Kind: models.PluginKindRecon, Recon: input})
+		if err != nil {
+			return err
+		}
+		job.SetTotal(int64(len(resp.Domains)))
+		result, err := ImportDomains(req.TargetID, strings.NewReader(strings.Join(resp.Domains, "\n")),
+			models.DomainImportOptions{Source: "plugin:" + p.Name, ValidateScope: req.ValidateScope, DryRun: req.DryRun})
+		if err != nil {
+			return err
+		}
+		job.AddProgress(int64(len(resp.Domains)), int64(result.Added))
+		verb := "added"
+		if req.DryRun {
+			verb = "would be added"
+		}
+		job.SetMessage("Plugin %s found %d domains: %d %s, %d existing, %d duplicates, %d out of scope, %d invalid.",
+			p.Name, len(resp.Domains), result.Added, verb, result.Existing, result.Duplicates, result.OutOfScope, result.Invalid)
+		return nil
+	})
+}
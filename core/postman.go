@@ -0,0 +1,103 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"toolkit/models"
+
+	"github.com/google/uuid"
+)
+
+const postmanSchemaV21 = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+}
+
+type postmanInfo struct {
+	PostmanID   string `json:"_postman_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      string `json:"schema"`
+}
+
+type postmanItem struct {
+	Name    string         `json:"name"`
+	Request postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanHeader `json:"header"`
+	Body   *postmanBody    `json:"body,omitempty"`
+	URL    string          `json:"url"`
+}
+
+type postmanHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type postmanBody struct {
+	Mode    string                 `json:"mode"`
+	Raw     string                 `json:"raw"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+type postmanVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// BuildPostmanCollection renders a modifier collection as a Postman v2.1 collection. Tasks keep
+// their {{name}} placeholders and the target variables become collection variables, so the
+// requests run in Postman the way they do in the modifier. The redaction rules of the collection's
+// target are applied to each request as for curl/raw exports.
+func BuildPostmanCollection(collection models.ModifierCollection, tasks []models.ModifierTask, variables []models.TargetVariable) ([]byte, error) {
+	var targetID *int64
+	if collection.TargetID.Valid {
+		targetID = &collection.TargetID.Int64
+	}
+	out := postmanCollection{
+		Info: postmanInfo{
+			PostmanID:   uuid.New().String(),
+			Name:        collection.Name,
+			Description: collection.Description,
+			Schema:      postmanSchemaV21,
+		},
+		Item: []postmanItem{},
+	}
+	for _, task := range tasks {
+		rawURL, headers, body := RedactRequestForExport(targetID, task.BaseRequestURL,
+			HeadersFromJSON(task.BaseRequestHeaders.String), DecodeStoredBody(task.BaseRequestBody.String))
+		req := postmanRequest{Method: strings.ToUpper(task.BaseRequestMethod), Header: []postmanHeader{}, URL: rawURL}
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+		for _, name := range sortedExportHeaderNames(headers) {
+			for _, v := range headers[name] {
+				req.Header = append(req.Header, postmanHeader{Key: name, Value: v})
+			}
+		}
+		if len(body) > 0 {
+			req.Body = &postmanBody{Mode: "raw", Raw: string(body)}
+			if strings.Contains(strings.ToLower(headers.Get("Content-Type")), "json") {
+				req.Body.Options = map[string]interface{}{"raw": map[string]string{"language": "json"}}
+			}
+		}
+		out.Item = append(out.Item, postmanItem{Name: task.Name, Request: req})
+	}
+	for _, v := range variables {
+		out.Variable = append(out.Variable, postmanVariable{Key: v.Name, Value: v.Value, Type: "string"})
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding Postman collection: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,462 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ditashi/jsbeautifier-go/jsbeautifier"
+	nethtml "golang.org/x/net/html"
+)
+
+// maxPrettyPrintSize bounds the bodies that are reformatted; larger ones are returned as stored.
+const maxPrettyPrintSize = 2 * 1024 * 1024
+
+// Body languages reported by PrettyPrintBody, named after common syntax highlighter modes.
+const (
+	BodyLanguageJSON       = "json"
+	BodyLanguageXML        = "xml"
+	BodyLanguageHTML       = "html"
+	BodyLanguageJavaScript = "javascript"
+	BodyLanguageCSS        = "css"
+	BodyLanguageText       = "text"
+	BodyLanguageBinary     = "binary"
+)
+
+// previewDroppedElements are removed from previews together with their content.
+var previewDroppedElements = map[string]bool{
+	"script": true, "iframe": true, "frame": true, "frameset": true, "object": true,
+	"embed": true, "applet": true, "base": true, "portal": true,
+}
+
+// previewURLAttributes are attributes holding URLs, checked for javascript: and similar schemes.
+var previewURLAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "data": true, "poster": true,
+	"background": true, "xlink:href": true, "srcset": true, "ping": true, "lowsrc": true, "dynsrc": true,
+}
+
+// PreviewContentSecurityPolicy returns the policy a preview is served with. The sandbox directive
+// (without allow-scripts or allow-same-origin) is what makes a preview safe; stripping scripts in
+// SanitizeHTMLForPreview is defense in depth. Unless allowRemote is set, the page cannot load
+// anything, so viewing a preview sends no request to the target.
+func PreviewContentSecurityPolicy(allowRemote bool) string {
+	if allowRemote {
+		return "sandbox; default-src 'none'; img-src * data: blob:; style-src * 'unsafe-inline'; font-src * data:; media-src *; form-action 'none'"
+	}
+	return "sandbox; default-src 'none'; img-src data:; style-src 'unsafe-inline'; font-src data:; form-action 'none'"
+}
+
+// SanitizeHTMLForPreview makes a captured HTML page safe to render: scripts, frames, plugins and
+// meta refreshes are removed, as are event handler attributes, srcdoc and javascript:/vbscript:
+// URLs. A <base> pointing at baseURL is added so relative stylesheets and images resolve against
+// the original site. Everything else is kept byte for byte.
+func SanitizeHTMLForPreview(body []byte, baseURL string) []byte {
+	var out bytes.Buffer
+	out.Grow(len(body) + 128)
+	baseTag := ""
+	if baseURL != "" {
+		baseTag = `<base href="` + html.EscapeString(baseURL) + `">`
+	}
+	baseWritten := baseTag == ""
+	writeBase := func() {
+		if !baseWritten {
+			out.WriteString(baseTag)
+			baseWritten = true
+		}
+	}
+
+	z := nethtml.NewTokenizer(bytes.NewReader(body))
+	skipping := "" // Name of the dropped element whose content is being skipped
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break // io.EOF, or a read error on an in-memory reader that cannot happen
+		}
+		raw := z.Raw()
+		if skipping != "" {
+			if tt == nethtml.EndTagToken {
+				if name, _ := z.TagName(); string(name) == skipping {
+					skipping = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			token := z.Token()
+			name := token.Data
+			if previewDroppedElements[name] {
+				if tt == nethtml.StartTagToken && name != "base" && name != "embed" {
+					skipping = name
+				}
+				continue
+			}
+			if name == "meta" && isMetaRefresh(token) {
+				continue
+			}
+			if name == "html" || name == "head" {
+				out.Write(raw)
+				if name == "head" {
+					writeBase()
+				}
+				continue
+			}
+			writeBase()
+			if sanitized, changed := sanitizePreviewAttributes(token.Attr); changed {
+				token.Attr = sanitized
+				out.WriteString(token.String())
+			} else {
+				out.Write(raw)
+			}
+		case nethtml.DoctypeToken, nethtml.CommentToken:
+			out.Write(raw)
+		default:
+			if tt == nethtml.TextToken && !baseWritten && len(bytes.TrimSpace(raw)) > 0 {
+				writeBase()
+			}
+			out.Write(raw)
+		}
+	}
+	return out.Bytes()
+}
+
+func isMetaRefresh(token nethtml.Token) bool {
+	for _, a := range token.Attr {
+		if strings.EqualFold(a.Key, "http-equiv") && strings.EqualFold(strings.TrimSpace(a.Val), "refresh") {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizePreviewAttributes drops event handlers, srcdoc and script URLs. It reports whether
+// anything was removed.
+func sanitizePreviewAttributes(attrs []nethtml.Attribute) ([]nethtml.Attribute, bool) {
+	kept := attrs[:0:0]
+	changed := false
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" {
+			key = strings.ToLower(a.Namespace + ":" + a.Key)
+		}
+		if strings.HasPrefix(key, "on") || key == "srcdoc" || (previewURLAttributes[key] && isScriptURL(a.Val)) {
+			changed = true
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept, changed
+}
+
+// isScriptURL reports whether a URL runs script when followed, ignoring the whitespace and
+// control characters browsers strip from schemes.
+func isScriptURL(value string) bool {
+	var b strings.Builder
+	for _, r := range value {
+		if r > ' ' {
+			b.WriteRune(r)
+		}
+		if b.Len() >= 16 {
+			break
+		}
+	}
+	v := strings.ToLower(b.String())
+	return strings.HasPrefix(v, "javascript:") || strings.HasPrefix(v, "vbscript:") || strings.HasPrefix(v, "data:text/html")
+}
+
+// PrettyBody is a body formatted for display with a syntax highlighter.
+type PrettyBody struct {
+	ContentType string `json:"content_type"`
+	Language    string `json:"language"`
+	Body        string `json:"body"`
+	Formatted   bool   `json:"formatted"`       // False when the body is returned as stored
+	Error       string `json:"error,omitempty"` // Why the body could not be formatted
+}
+
+// DetectBodyLanguage picks the language of a body from its Content-Type, falling back to
+// sniffing the content.
+func DetectBodyLanguage(contentType string, body []byte) string {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		return BodyLanguageJSON
+	case strings.Contains(ct, "html"):
+		return BodyLanguageHTML
+	case strings.Contains(ct, "xml"):
+		return BodyLanguageXML
+	case IsJavaScriptContentType(ct):
+		return BodyLanguageJavaScript
+	case strings.Contains(ct, "css"):
+		return BodyLanguageCSS
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(trimmed) == 0:
+		return BodyLanguageText
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed):
+		return BodyLanguageJSON
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		return BodyLanguageXML
+	case trimmed[0] == '<':
+		return BodyLanguageHTML
+	case !utf8.Valid(body):
+		return BodyLanguageBinary
+	}
+	return BodyLanguageText
+}
+
+// PrettyPrintBody formats JSON, XML, HTML and JavaScript bodies. Other languages, bodies that fail
+// to parse and bodies over 2 MiB are returned unchanged, with Formatted false.
+func PrettyPrintBody(body []byte, contentType string) PrettyBody {
+	result := PrettyBody{ContentType: contentType, Language: DetectBodyLanguage(contentType, body), Body: string(body)}
+	if result.Language == BodyLanguageBinary {
+		result.Body = ""
+		result.Error = fmt.Sprintf("binary body (%d bytes) is not shown", len(body))
+		return result
+	}
+	if len(body) > maxPrettyPrintSize {
+		result.Error = fmt.Sprintf("body is larger than %d bytes and is not formatted", maxPrettyPrintSize)
+		return result
+	}
+
+	var pretty string
+	var err error
+	switch result.Language {
+	case BodyLanguageJSON:
+		var buf bytes.Buffer
+		if err = json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err == nil {
+			pretty = buf.String()
+		}
+	case BodyLanguageXML:
+		pretty, err = prettyPrintXML(body)
+	case BodyLanguageHTML:
+		pretty, err = prettyPrintHTML(body)
+	case BodyLanguageJavaScript:
+		pretty, err = prettyPrintJavaScript(string(body))
+	default:
+		return result
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Body, result.Formatted = pretty, true
+	return result
+}
+
+// prettyPrintJavaScript formats JavaScript with jsbeautifier, which panics on some inputs.
+func prettyPrintJavaScript(src string) (pretty string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("formatting JavaScript: %v", r)
+		}
+	}()
+	return jsbeautifier.Beautify(&src, jsbeautifier.DefaultOptions())
+}
+
+// htmlVoidElements have no end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlImpliedEndElements are closed by the start of a sibling of the same name when their end tag
+// is left out.
+var htmlImpliedEndElements = map[string]bool{
+	"p": true, "li": true, "dt": true, "dd": true, "option": true, "tr": true, "td": true, "th": true,
+}
+
+// htmlParagraphClosers close an open p element when it has no end tag.
+var htmlParagraphClosers = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true, "dl": true, "fieldset": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "ul": true,
+}
+
+// prettyPrintHTML puts each tag, comment and text run of an HTML document on its own line,
+// indented by nesting. Tags are written as they appear in the source; inline scripts are formatted
+// like JavaScript bodies, and pre and textarea content is kept as is.
+func prettyPrintHTML(body []byte) (string, error) {
+	var b strings.Builder
+	var open []string
+	newline := func() {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat("  ", len(open)))
+	}
+	// writeLines writes the non-blank lines of a text run, keeping their own indentation when
+	// formatted (inline scripts).
+	writeLines := func(text string, formatted bool) {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			newline()
+			if formatted {
+				b.WriteString(strings.TrimRight(line, " \t\r"))
+			} else {
+				b.WriteString(strings.TrimSpace(line))
+			}
+		}
+	}
+
+	z := nethtml.NewTokenizer(bytes.NewReader(body))
+	verbatim := "" // pre or textarea whose content is written as is
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return "", fmt.Errorf("parsing HTML: %w", err)
+			}
+			break
+		}
+		raw := string(z.Raw())
+		if verbatim != "" {
+			if name, _ := z.TagName(); tt == nethtml.EndTagToken && string(name) == verbatim {
+				verbatim = ""
+				open = open[:len(open)-1]
+			}
+			b.WriteString(raw)
+			continue
+		}
+		switch tt {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			nameBytes, _ := z.TagName()
+			name := string(nameBytes)
+			if n := len(open); n > 0 && ((htmlImpliedEndElements[name] && open[n-1] == name) || (htmlParagraphClosers[name] && open[n-1] == "p")) {
+				open = open[:n-1]
+			}
+			newline()
+			b.WriteString(raw)
+			if tt == nethtml.StartTagToken && !htmlVoidElements[name] {
+				open = append(open, name)
+				if name == "pre" || name == "textarea" {
+					verbatim = name
+				}
+			}
+		case nethtml.EndTagToken:
+			nameBytes, _ := z.TagName()
+			name := string(nameBytes)
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					open = open[:i]
+					break
+				}
+			}
+			newline()
+			b.WriteString(raw)
+		case nethtml.TextToken:
+			formatted := false
+			if n := len(open); n > 0 && open[n-1] == "script" {
+				if script, err := prettyPrintJavaScript(raw); err == nil {
+					raw, formatted = script, true
+				}
+			}
+			writeLines(raw, formatted)
+		default: // Comments and the doctype
+			newline()
+			b.WriteString(raw)
+		}
+	}
+	return b.String(), nil
+}
+
+// prettyPrintXML re-indents an XML document. Namespace prefixes are written as they appear in the
+// source, and elements holding only text stay on one line.
+func prettyPrintXML(body []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	var tokens []xml.Token
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("parsing XML: %w", err)
+		}
+		if cd, ok := tok.(xml.CharData); ok && len(bytes.TrimSpace(cd)) == 0 {
+			continue
+		}
+		tokens = append(tokens, xml.CopyToken(tok))
+	}
+
+	var b strings.Builder
+	depth := 0
+	newline := func() {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat("  ", depth))
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i].(type) {
+		case xml.StartElement:
+			newline()
+			b.WriteString("<" + xmlName(t.Name))
+			for _, a := range t.Attr {
+				b.WriteString(" " + xmlName(a.Name) + `="`)
+				xml.EscapeText(&b, []byte(a.Value))
+				b.WriteString(`"`)
+			}
+			// <a/> for empty elements, <a>text</a> for text-only elements
+			if i+1 < len(tokens) {
+				if _, ok := tokens[i+1].(xml.EndElement); ok {
+					b.WriteString("/>")
+					i++
+					continue
+				}
+				if cd, ok := tokens[i+1].(xml.CharData); ok && i+2 < len(tokens) {
+					if _, ok := tokens[i+2].(xml.EndElement); ok {
+						b.WriteString(">")
+						xml.EscapeText(&b, bytes.TrimSpace(cd))
+						b.WriteString("</" + xmlName(t.Name) + ">")
+						i += 2
+						continue
+					}
+				}
+			}
+			b.WriteString(">")
+			depth++
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			}
+			newline()
+			b.WriteString("</" + xmlName(t.Name) + ">")
+		case xml.CharData:
+			newline()
+			xml.EscapeText(&b, bytes.TrimSpace(t))
+		case xml.Comment:
+			newline()
+			b.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			newline()
+			b.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				b.WriteString(" " + string(t.Inst))
+			}
+			b.WriteString("?>")
+		case xml.Directive:
+			newline()
+			b.WriteString("<!" + string(t) + ">")
+		}
+	}
+	return b.String(), nil
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
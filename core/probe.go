@@ -0,0 +1,82 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// probeMaxBody caps the response body kept for each request of the active checks.
+const probeMaxBody = 256 << 10
+
+// sendLoggedProbe sends a request of an active check, stores it in the target's traffic log
+// under source and returns the log entry and the response headers.
+func sendLoggedProbe(client *http.Client, targetID int64, req *http.Request, source string) (models.HTTPTrafficLog, http.Header, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return models.HTTPTrafficLog{}, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, probeMaxBody))
+	if err != nil {
+		return models.HTTPTrafficLog{}, nil, fmt.Errorf("reading response: %w", err)
+	}
+	entry := logProbe(targetID, req, resp, body, start, source)
+	return entry, resp.Header, nil
+}
+
+// logProbe stores a request of an active check and its response in the target's traffic log
+// under source and returns the log entry.
+func logProbe(targetID int64, req *http.Request, resp *http.Response, body []byte, start time.Time, source string) models.HTTPTrafficLog {
+	reqHeaders, _ := json.Marshal(req.Header)
+	storedRespHeaders := resp.Header.Clone()
+	storedRespHeaders.Del(responseCacheLogIDHeader)
+	respHeaders, _ := json.Marshal(storedRespHeaders)
+	entry := models.HTTPTrafficLog{
+		TargetID:             &targetID,
+		Timestamp:            start,
+		RequestMethod:        models.NullString(req.Method),
+		RequestURL:           models.NullString(req.URL.String()),
+		RequestHTTPVersion:   models.NullString("HTTP/1.1"),
+		RequestHeaders:       models.NullString(string(reqHeaders)),
+		ResponseStatusCode:   resp.StatusCode,
+		ResponseReasonPhrase: models.NullString(strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode)))),
+		ResponseHTTPVersion:  models.NullString(resp.Proto),
+		ResponseHeaders:      models.NullString(string(respHeaders)),
+		ResponseBody:         body,
+		ResponseContentType:  models.NullString(resp.Header.Get("Content-Type")),
+		ResponseBodySize:     int64(len(body)),
+		DurationMs:           time.Since(start).Milliseconds(),
+		IsHTTPS:              req.URL.Scheme == "https",
+	}
+	// Bodies built from a bytes or strings reader can be read again for the log.
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			entry.RequestBody, _ = io.ReadAll(io.LimitReader(body, probeMaxBody))
+			body.Close()
+		}
+	}
+	// A body cut at probeMaxBody cannot stand in for the full response.
+	key := ""
+	if len(body) < probeMaxBody {
+		key = requestCacheKey(req)
+	}
+	setResponseCacheFields(&entry, resp.Header, key)
+	if RedactForStorage(&entry) {
+		entry.RequestFingerprint = sql.NullString{}
+	}
+	id, err := database.LogToolkitRequest(&entry, source)
+	if err != nil {
+		logger.Error("%s: %v", source, err)
+	}
+	entry.ID = id
+	return entry
+}
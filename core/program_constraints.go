@@ -0,0 +1,274 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/elazarl/goproxy"
+	"golang.org/x/net/http/httpguts"
+)
+
+// programConstraints caches the per-target program constraints the proxy enforces on toolkit
+// requests. Entries hold nil for targets without constraints.
+var programConstraints sync.Map // map[int64]*models.TargetProgramConstraints
+
+// programThrottle spaces the toolkit requests of each target to its max_rps.
+var programThrottle = struct {
+	sync.Mutex
+	next map[int64]time.Time // Earliest time the next request of a target may be sent
+}{next: map[int64]time.Time{}}
+
+// SaveTargetProgramConstraints validates and stores the program constraints of a target. Header
+// names are stored in canonical form and banned paths are trimmed, deduplicated and sorted.
+func SaveTargetProgramConstraints(c models.TargetProgramConstraints) (models.TargetProgramConstraints, error) {
+	if _, err := database.GetTargetByID(c.TargetID); err != nil {
+		return c, err
+	}
+	if c.MaxRPS < 0 {
+		return c, fmt.Errorf("invalid max_rps: must not be negative")
+	}
+	var paths []string
+	for _, p := range c.BannedPaths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "*") {
+			return c, fmt.Errorf("invalid banned path '%s': must start with /", p)
+		}
+		paths = append(paths, p)
+	}
+	headers := make(map[string]string, len(c.RequiredHeaders))
+	for name, value := range c.RequiredHeaders {
+		canonical := http.CanonicalHeaderKey(strings.TrimSpace(name))
+		switch {
+		case !httpguts.ValidHeaderFieldName(canonical):
+			return c, fmt.Errorf("invalid header name '%s'", name)
+		case clientProfileReservedHeaders[canonical] || isToolkitHeader(canonical):
+			return c, fmt.Errorf("invalid header '%s': it is set by the toolkit", canonical)
+		case !httpguts.ValidHeaderFieldValue(value):
+			return c, fmt.Errorf("invalid value for header '%s'", canonical)
+		}
+		headers[canonical] = strings.TrimSpace(value)
+	}
+	c.BannedPaths = processSlice(paths)
+	c.RequiredHeaders = headers
+	if err := database.SaveTargetProgramConstraints(c); err != nil {
+		return c, err
+	}
+	programConstraints.Delete(c.TargetID)
+	logger.Info("SaveTargetProgramConstraints: Target %d max %.2f req/s, %d banned paths, %d required headers",
+		c.TargetID, c.MaxRPS, len(c.BannedPaths), len(c.RequiredHeaders))
+	return GetTargetProgramConstraints(c.TargetID)
+}
+
+// DeleteTargetProgramConstraints removes the program constraints of a target.
+func DeleteTargetProgramConstraints(targetID int64) error {
+	if err := database.DeleteTargetProgramConstraints(targetID); err != nil {
+		return err
+	}
+	programConstraints.Delete(targetID)
+	return nil
+}
+
+// GetTargetProgramConstraints returns the program constraints of a target, empty when it has none.
+func GetTargetProgramConstraints(targetID int64) (models.TargetProgramConstraints, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.TargetProgramConstraints{}, err
+	}
+	c, err := database.GetTargetProgramConstraints(targetID)
+	if err != nil {
+		return models.TargetProgramConstraints{}, err
+	}
+	if c == nil {
+		return models.TargetProgramConstraints{TargetID: targetID, BannedPaths: []string{}, RequiredHeaders: map[string]string{}}, nil
+	}
+	return *c, nil
+}
+
+// ListProgramConstraintEvents returns the latest enforcement events of a target, newest first.
+func ListProgramConstraintEvents(targetID int64, action string, limit int) ([]models.ProgramConstraintEvent, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return nil, err
+	}
+	switch action {
+	case "", models.ConstraintActionBlocked, models.ConstraintActionHeaderSet, models.ConstraintActionDelayed:
+	default:
+		return nil, fmt.Errorf("invalid action '%s'", action)
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	return database.ListProgramConstraintEvents(targetID, action, limit)
+}
+
+// targetProgramConstraints returns the cached program constraints of a target, or nil when it has
+// none.
+func targetProgramConstraints(targetID int64) *models.TargetProgramConstraints {
+	if cached, ok := programConstraints.Load(targetID); ok {
+		return cached.(*models.TargetProgramConstraints)
+	}
+	c, err := database.GetTargetProgramConstraints(targetID)
+	if err != nil {
+		logger.Error("Program constraints: %v", err)
+		return nil
+	}
+	programConstraints.Store(targetID, c)
+	return c
+}
+
+// bannedPathPattern returns the banned path pattern matching urlPath, or "" when none does.
+// Patterns match case-insensitively against the cleaned path: * matches any characters and a
+// pattern without one also covers the paths below it.
+func bannedPathPattern(patterns []string, urlPath string) string {
+	if urlPath == "" {
+		urlPath = "/"
+	}
+	cleaned := strings.ToLower(path.Clean(urlPath))
+	for _, pattern := range patterns {
+		p := strings.ToLower(pattern)
+		if !strings.Contains(p, "*") {
+			p = strings.TrimSuffix(p, "/")
+			if cleaned == p || strings.HasPrefix(cleaned, p+"/") || (p == "" && cleaned == "/") {
+				return pattern
+			}
+			continue
+		}
+		re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*") + "$")
+		if err == nil && re.MatchString(cleaned) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// reserveProgramSlot returns how long a request of a target must wait to keep to maxRPS, and
+// reserves its slot.
+func reserveProgramSlot(targetID int64, maxRPS float64) time.Duration {
+	interval := time.Duration(float64(time.Second) / maxRPS)
+	programThrottle.Lock()
+	defer programThrottle.Unlock()
+	now := time.Now()
+	slot := programThrottle.next[targetID]
+	if slot.Before(now) {
+		slot = now
+	}
+	programThrottle.next[targetID] = slot.Add(interval)
+	return slot.Sub(now)
+}
+
+// enforceProgramConstraints applies the program constraints of a target to a toolkit request
+// passing through the proxy. A request to a banned path is answered with 403 and never reaches
+// the host.
+func enforceProgramConstraints(r *http.Request, targetID int64, source string) *http.Response {
+	c := targetProgramConstraints(targetID)
+	if c == nil || c.IsEmpty() {
+		return nil
+	}
+	if pattern := applyProgramConstraints(r, targetID, *c, source); pattern != "" {
+		logger.ProxyInfo("REQ: %s %s - BLOCKED, matches banned path %s of target %d.", r.Method, r.URL.String(), pattern, targetID)
+		return goproxy.NewResponse(r, goproxy.ContentTypeText, http.StatusForbidden,
+			fmt.Sprintf("Blocked by the toolkit: the program of target %d bans testing %s (pattern %s).\n", targetID, r.URL.Path, pattern))
+	}
+	return nil
+}
+
+// programConstraintsTransport holds every request of an HTTP client to the program constraints
+// of a target.
+type programConstraintsTransport struct {
+	base     http.RoundTripper
+	targetID int64
+}
+
+// NewProgramConstraintsTransport wraps base so the program constraints of targetID bind each
+// request, redirects included: a request to a banned path fails with a forbidden error without
+// reaching the host, required headers are set and requests wait for their slot under max_rps.
+// NewClientProfileTransport includes it.
+func NewProgramConstraintsTransport(base http.RoundTripper, targetID int64) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &programConstraintsTransport{base: base, targetID: targetID}
+}
+
+func (t *programConstraintsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests sent through the proxy are held to the constraints there, so each is counted once.
+	if t.targetID == 0 || req.Header.Get("X-Toolkit-Initiated") == "true" {
+		return t.base.RoundTrip(req)
+	}
+	c := targetProgramConstraints(t.targetID)
+	if c == nil || c.IsEmpty() {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the caller's request.
+	clone := req.Clone(req.Context())
+	if pattern := applyProgramConstraints(clone, t.targetID, *c, toolkitLogSource(req.Header.Get("X-Toolkit-Source"), false)); pattern != "" {
+		logger.Info("Program constraints: %s %s blocked, matches banned path %s of target %d", req.Method, req.URL.String(), pattern, t.targetID)
+		return nil, models.NewAppError(models.ErrCodeForbidden, "blocked by the toolkit: the program of target %d bans testing %s (pattern %s)",
+			t.targetID, req.URL.Path, pattern)
+	}
+	return t.base.RoundTrip(clone)
+}
+
+// applyProgramConstraints applies program constraints c of a target to a toolkit request. It
+// returns the banned path pattern the request matches, if any; otherwise the required headers are
+// set on r and the request waits for its slot under max_rps. Each action is recorded as an event,
+// except in read-only mode.
+func applyProgramConstraints(r *http.Request, targetID int64, c models.TargetProgramConstraints, source string) string {
+	event := func(action, detail string, delay time.Duration) {
+		if ReadOnly() {
+			return
+		}
+		e := models.ProgramConstraintEvent{TargetID: targetID, Action: action, Method: r.Method, URL: r.URL.String(),
+			Source: source, Detail: detail, DelayMs: delay.Milliseconds()}
+		go func() {
+			if err := database.CreateProgramConstraintEvent(e); err != nil {
+				logger.Error("Program constraints: %v", err)
+			}
+		}()
+	}
+
+	if pattern := bannedPathPattern(c.BannedPaths, r.URL.Path); pattern != "" {
+		event(models.ConstraintActionBlocked, "matches banned path "+pattern, 0)
+		return pattern
+	}
+
+	var set []string
+	for name, value := range c.RequiredHeaders {
+		if values := r.Header.Values(name); len(values) != 1 || values[0] != value {
+			r.Header.Set(name, value)
+			set = append(set, name)
+		}
+	}
+	if len(set) > 0 {
+		event(models.ConstraintActionHeaderSet, "set "+strings.Join(processSlice(set), ", "), 0)
+	}
+
+	if c.MaxRPS > 0 {
+		if delay := reserveProgramSlot(targetID, c.MaxRPS); delay > 0 {
+			logger.ProxyDebug("REQ: %s %s - Delayed %v to keep to %.2f req/s for target %d.", r.Method, r.URL.String(), delay, c.MaxRPS, targetID)
+			event(models.ConstraintActionDelayed, fmt.Sprintf("max %.2f req/s", c.MaxRPS), delay)
+			waitProgramSlot(r.Context(), delay)
+		}
+	}
+	return ""
+}
+
+// waitProgramSlot sleeps for delay or until ctx is done.
+func waitProgramSlot(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"toolkit/config"
+	"toolkit/models"
+)
+
+// withProgramConstraints caches constraints for target 42, which has no client profile. The
+// toolkit runs read-only so enforcement events need no database.
+func withProgramConstraints(t *testing.T, c models.TargetProgramConstraints) int64 {
+	t.Helper()
+	const targetID = 42
+	c.TargetID = targetID
+	programConstraints.Store(int64(targetID), &c)
+	clientProfiles.Store(int64(targetID), (*models.TargetClientProfile)(nil))
+	saved := config.AppConfig.Server.ReadOnly
+	config.AppConfig.Server.ReadOnly = true
+	t.Cleanup(func() {
+		programConstraints.Delete(int64(targetID))
+		clientProfiles.Delete(int64(targetID))
+		programThrottle.Lock()
+		delete(programThrottle.next, targetID)
+		programThrottle.Unlock()
+		config.AppConfig.Server.ReadOnly = saved
+	})
+	return targetID
+}
+
+func TestScreenProxyRequestEnforcesConstraintsBeforeExclusions(t *testing.T) {
+	targetID := withProgramConstraints(t, models.TargetProgramConstraints{
+		BannedPaths:     []string{"/logout"},
+		RequiredHeaders: map[string]string{"X-Bug-Bounty": "researcher"},
+	})
+	proxyState.SetExclusionRules([]models.ProxyExclusionRule{{ID: "all", RuleType: "url_regex", Pattern: `example\.com`, IsEnabled: true}})
+	t.Cleanup(func() { proxyState.SetExclusionRules(nil) })
+
+	toolkitRequest := func(path string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		r.Header.Set("X-Toolkit-Initiated", "true")
+		return r
+	}
+	if resp, excluded := screenProxyRequest(toolkitRequest("/logout"), &targetID, true); resp == nil || resp.StatusCode != http.StatusForbidden || excluded {
+		t.Errorf("an excluded toolkit request to a banned path was not blocked: %v, excluded %t", resp, excluded)
+	}
+	r := toolkitRequest("/account")
+	if resp, excluded := screenProxyRequest(r, &targetID, true); resp != nil || !excluded {
+		t.Errorf("screenProxyRequest(/account) = %v, excluded %t; want it excluded", resp, excluded)
+	}
+	if got := r.Header.Get("X-Bug-Bounty"); got != "researcher" {
+		t.Errorf("an excluded toolkit request was sent without the required header (%q)", got)
+	}
+
+	// Browsing is not bound by the program's constraints.
+	browsing := httptest.NewRequest(http.MethodGet, "http://example.com/logout", nil)
+	if resp, excluded := screenProxyRequest(browsing, &targetID, true); resp != nil || !excluded {
+		t.Errorf("screenProxyRequest(browsing) = %v, excluded %t; want it excluded", resp, excluded)
+	}
+}
+
+func TestClientProfileTransportEnforcesConstraints(t *testing.T) {
+	targetID := withProgramConstraints(t, models.TargetProgramConstraints{
+		MaxRPS:          10,
+		BannedPaths:     []string{"/api/*/delete"},
+		RequiredHeaders: map[string]string{"X-Bug-Bounty": "researcher"},
+	})
+	var mu sync.Mutex
+	var seen []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewClientProfileTransport(nil, targetID)}
+
+	_, err := client.Get(server.URL + "/api/users/delete")
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) || appErr.Code != models.ErrCodeForbidden {
+		t.Errorf("a request to a banned path returned %v, want a forbidden error", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/users", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if req.Header.Get("X-Bug-Bounty") != "" {
+			t.Error("the transport changed the caller's request")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("3 requests at max 10 req/s took %v", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 3 {
+		t.Fatalf("the server got %d requests, want 3 (the banned one never reaches it)", len(seen))
+	}
+	for _, r := range seen {
+		if r.Header.Get("X-Bug-Bounty") != "researcher" {
+			t.Errorf("%s was sent without the required header", r.URL.Path)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/google/uuid"
+)
+
+// exclusionHitFlushInterval is how often the hit counters of the exclusion rules are persisted.
+const exclusionHitFlushInterval = 30 * time.Second
+
+// pendingExclusionHits holds the hits counted since the last flush, by rule ID. Counting happens on
+// every excluded request, so it stays in memory rather than writing to the database each time.
+var (
+	pendingExclusionHitsMu sync.Mutex
+	pendingExclusionHits   = map[string]models.ProxyExclusionHits{}
+)
+
+// recordExclusionHit counts a request skipped by a global exclusion rule.
+func recordExclusionHit(ruleID string) {
+	pendingExclusionHitsMu.Lock()
+	defer pendingExclusionHitsMu.Unlock()
+	h := pendingExclusionHits[ruleID]
+	h.Hits++
+	h.LastHitAt = time.Now()
+	pendingExclusionHits[ruleID] = h
+}
+
+// takePendingExclusionHits returns the hits counted since the last flush and resets them.
+func takePendingExclusionHits() map[string]models.ProxyExclusionHits {
+	pendingExclusionHitsMu.Lock()
+	defer pendingExclusionHitsMu.Unlock()
+	hits := pendingExclusionHits
+	pendingExclusionHits = map[string]models.ProxyExclusionHits{}
+	return hits
+}
+
+// FlushExclusionHits persists the hits counted since the last flush. Hits that fail to persist are
+// counted again for the next flush.
+func FlushExclusionHits() error {
+	hits := takePendingExclusionHits()
+	if err := database.AddProxyExclusionRuleHits(hits); err != nil {
+		pendingExclusionHitsMu.Lock()
+		for ruleID, h := range hits {
+			pending := pendingExclusionHits[ruleID]
+			pending.Hits += h.Hits
+			if h.LastHitAt.After(pending.LastHitAt) {
+				pending.LastHitAt = h.LastHitAt
+			}
+			pendingExclusionHits[ruleID] = pending
+		}
+		pendingExclusionHitsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// runExclusionHitFlusher persists the hit counters periodically and once more when ctx is done.
+func runExclusionHitFlusher(ctx context.Context) {
+	ticker := time.NewTicker(exclusionHitFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := FlushExclusionHits(); err != nil {
+				logger.ProxyError("Persisting exclusion rule hits on shutdown: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := FlushExclusionHits(); err != nil {
+				logger.ProxyError("Persisting exclusion rule hits: %v", err)
+			}
+		}
+	}
+}
+
+// ListProxyExclusionRuleStats returns the global exclusion rules with their hit counters, most
+// hits first. Hits not yet persisted are included.
+func ListProxyExclusionRuleStats() ([]models.ProxyExclusionRuleStats, error) {
+	rules, err := database.GetProxyExclusionRules()
+	if err != nil {
+		return nil, err
+	}
+	hits, err := database.GetProxyExclusionRuleHits()
+	if err != nil {
+		return nil, err
+	}
+	pendingExclusionHitsMu.Lock()
+	for ruleID, h := range pendingExclusionHits {
+		persisted := hits[ruleID]
+		persisted.Hits += h.Hits
+		persisted.LastHitAt = h.LastHitAt
+		hits[ruleID] = persisted
+	}
+	pendingExclusionHitsMu.Unlock()
+
+	stats := make([]models.ProxyExclusionRuleStats, len(rules))
+	for i, rule := range rules {
+		stats[i] = models.ProxyExclusionRuleStats{ProxyExclusionRule: rule}
+		if h, ok := hits[rule.ID]; ok {
+			stats[i].Hits = h.Hits
+			if !h.LastHitAt.IsZero() {
+				lastHit := h.LastHitAt
+				stats[i].LastHitAt = &lastHit
+			}
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Hits > stats[j].Hits })
+	return stats, nil
+}
+
+// validateExclusionRule rejects rules that could never match, such as a url_regex rule whose
+// pattern does not compile.
+func validateExclusionRule(rule models.ProxyExclusionRule) error {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	switch rule.RuleType {
+	case "file_extension", "domain":
+		return nil
+	case "url_regex":
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("pattern %s is not a valid regular expression (Go RE2 syntax; lookarounds and backreferences are not supported): %w", rule.Pattern, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown rule type %q (use file_extension, url_regex or domain)", rule.RuleType)
+}
+
+// SaveProxyExclusionRules stores the global exclusion rules and applies them to the running proxy.
+// Rules without an ID get one, so their hits can be counted; counters of removed rules are dropped.
+// Invalid rules are rejected with an error starting with "invalid proxy exclusion rule".
+func SaveProxyExclusionRules(rules []models.ProxyExclusionRule) ([]models.ProxyExclusionRule, error) {
+	for i, rule := range rules {
+		if err := validateExclusionRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid proxy exclusion rule %d: %w", i+1, err)
+		}
+	}
+	ids := make([]string, 0, len(rules))
+	for i := range rules {
+		if strings.TrimSpace(rules[i].ID) == "" {
+			rules[i].ID = uuid.NewString()
+		}
+		ids = append(ids, rules[i].ID)
+	}
+	if err := database.SetProxyExclusionRules(rules); err != nil {
+		return nil, err
+	}
+	proxyState.SetExclusionRules(rules)
+	if err := database.DeleteProxyExclusionRuleHitsExcept(ids); err != nil {
+		logger.Error("SaveProxyExclusionRules: %v", err)
+	}
+	return rules, nil
+}
+
+// TestProxyExclusionRules evaluates a URL against the exclusion rules without sending anything,
+// reporting which rules match and whether the proxy would skip the request. With no rules given,
+// the saved rules are tested.
+func TestProxyExclusionRules(rawURL string, rules []models.ProxyExclusionRule) (models.ProxyExclusionTestResult, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if rawURL == "" || err != nil || u.Host == "" {
+		return models.ProxyExclusionTestResult{}, fmt.Errorf("invalid url: an absolute URL such as https://example.com/path is required")
+	}
+	if rules == nil {
+		if rules, err = database.GetProxyExclusionRules(); err != nil {
+			return models.ProxyExclusionTestResult{}, err
+		}
+	}
+
+	result := models.ProxyExclusionTestResult{URL: u.String(), Rules: make([]models.ProxyExclusionRuleMatch, len(rules))}
+	for i, rule := range rules {
+		match, err := exclusionRuleMatches(u, rule)
+		result.Rules[i] = models.ProxyExclusionRuleMatch{ProxyExclusionRule: rule, Matches: match}
+		if err != nil {
+			result.Rules[i].Error = err.Error()
+		}
+		// The proxy stops at the first enabled rule that matches
+		if match && rule.IsEnabled && !result.Excluded {
+			result.Excluded = true
+			result.MatchedRuleID = rule.ID
+		}
+	}
+	return result, nil
+}
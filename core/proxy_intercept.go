@@ -0,0 +1,392 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/logger"
+
+	"github.com/elazarl/goproxy"
+)
+
+// Connections arriving through the SOCKS5 and transparent listeners carry no HTTP proxy request, so
+// their destination comes from the SOCKS5 handshake or the redirect. TLS connections are fed to
+// goproxy as a synthetic CONNECT (and MITMed like any other), plain HTTP is served with absolute
+// URLs, and anything else is tunneled untouched.
+
+// interceptModeHeader marks synthetic CONNECT requests that must be tunneled instead of MITMed.
+const interceptModeHeader = "X-Toolkit-Intercept"
+
+const (
+	interceptModeTunnel = "tunnel"
+	// interceptPeekTimeout bounds the wait for the client's first bytes; protocols where the server
+	// speaks first are tunneled when it runs out.
+	interceptPeekTimeout = 3 * time.Second
+)
+
+type interceptDestKey struct{}
+
+// interceptedConn is a client connection whose destination is known from outside HTTP.
+type interceptedConn struct {
+	net.Conn
+	r    *bufio.Reader
+	dest string // host:port; host may be empty when a transparent redirect could not be resolved
+
+	swallowConnectReply bool // Drop goproxy's "200 Connection established", meant for HTTP proxy clients
+}
+
+func (c *interceptedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *interceptedConn) Write(p []byte) (int, error) {
+	if c.swallowConnectReply {
+		c.swallowConnectReply = false
+		if bytes.HasPrefix(p, []byte("HTTP/1.0 200")) {
+			return len(p), nil
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// hijackedWriter hands an intercepted connection to goproxy's CONNECT handling.
+type hijackedWriter struct {
+	conn   *interceptedConn
+	header http.Header
+}
+
+func (w *hijackedWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+func (w *hijackedWriter) Write(p []byte) (int, error) { return w.conn.Write(p) }
+func (w *hijackedWriter) WriteHeader(int)             {}
+func (w *hijackedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, bufio.NewReadWriter(w.conn.r, bufio.NewWriter(w.conn)), nil
+}
+
+// connListener is a net.Listener fed with connections accepted elsewhere.
+type connListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+	addr  net.Addr
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{conns: make(chan net.Conn), done: make(chan struct{}), addr: addr}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr { return l.addr }
+
+func (l *connListener) push(c net.Conn) {
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		c.Close()
+	}
+}
+
+// interceptServer dispatches the connections of the SOCKS5 and transparent listeners to the proxy.
+type interceptServer struct {
+	proxy    *goproxy.ProxyHttpServer
+	httpConn *connListener
+	http     *http.Server
+}
+
+// startInterceptListeners starts the SOCKS5 and transparent listeners enabled in the config. They
+// stop when ctx is done.
+func startInterceptListeners(ctx context.Context, proxy *goproxy.ProxyHttpServer) error {
+	socksPort, transparentPort := config.AppConfig.Proxy.SOCKS5Port, config.AppConfig.Proxy.TransparentPort
+	if socksPort == "" && transparentPort == "" {
+		return nil
+	}
+	s := &interceptServer{proxy: proxy, httpConn: newConnListener(&net.TCPAddr{})}
+	s.http = &http.Server{
+		Handler: http.HandlerFunc(s.serveHTTP),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, interceptDestKey{}, c.(*interceptedConn).dest)
+		},
+	}
+	go s.http.Serve(s.httpConn)
+
+	var listeners []net.Listener
+	start := func(port, name string, handle func(net.Conn)) error {
+		ln, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			return fmt.Errorf("starting %s listener on port %s: %w", name, port, err)
+		}
+		listeners = append(listeners, ln)
+		logger.ProxyInfo("%s proxy listening on port %s", name, port)
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					if !errors.Is(err, net.ErrClosed) {
+						logger.ProxyError("%s listener accept error: %v", name, err)
+					}
+					return
+				}
+				go handle(conn)
+			}
+		}()
+		return nil
+	}
+	stop := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		s.http.Close()
+	}
+	if socksPort != "" {
+		if err := start(socksPort, "SOCKS5", s.handleSOCKS5); err != nil {
+			stop()
+			return err
+		}
+	}
+	if transparentPort != "" {
+		if err := start(transparentPort, "Transparent", s.handleTransparent); err != nil {
+			stop()
+			return err
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return nil
+}
+
+func (s *interceptServer) handleSOCKS5(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	dest, err := socks5Handshake(conn)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		logger.ProxyDebug("SOCKS5 handshake with %s failed: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	s.dispatch(conn, dest)
+}
+
+func (s *interceptServer) handleTransparent(conn net.Conn) {
+	dest, err := originalDestination(conn)
+	if err != nil {
+		logger.ProxyDebug("Transparent proxy: no original destination for %s (%v), using SNI/Host", conn.RemoteAddr(), err)
+		dest = ""
+	} else if dest == conn.LocalAddr().String() {
+		// Connected to the listener directly, not through a redirect.
+		dest = ""
+	}
+	s.dispatch(conn, dest)
+}
+
+// dispatch peeks at the first bytes of an intercepted connection and routes it by protocol.
+func (s *interceptServer) dispatch(conn net.Conn, dest string) {
+	c := &interceptedConn{Conn: conn, r: bufio.NewReaderSize(conn, 16*1024), dest: dest}
+	conn.SetReadDeadline(time.Now().Add(interceptPeekTimeout))
+	first, err := c.r.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+
+	host, port, _ := net.SplitHostPort(dest)
+	switch {
+	case err == nil && first[0] == 0x16: // TLS handshake record
+		if serverName := peekServerName(c.r); serverName != "" {
+			host = serverName
+		}
+		if port == "" {
+			port = "443"
+		}
+		if host == "" {
+			logger.ProxyDebug("Intercepted TLS connection from %s has no destination or SNI, closing.", conn.RemoteAddr())
+			conn.Close()
+			return
+		}
+		s.connect(c, net.JoinHostPort(host, port), "")
+	case err == nil && looksLikeHTTP(c.r):
+		s.httpConn.push(c)
+	case host != "":
+		s.connect(c, dest, interceptModeTunnel)
+	default:
+		logger.ProxyDebug("Intercepted connection from %s is not HTTP(S) and has no destination, closing.", conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+// connect runs an intercepted connection through goproxy's CONNECT handling.
+func (s *interceptServer) connect(c *interceptedConn, hostPort, mode string) {
+	c.swallowConnectReply = true
+	req := &http.Request{
+		Method:     http.MethodConnect,
+		URL:        &url.URL{Host: hostPort},
+		Host:       hostPort,
+		Header:     http.Header{},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		RemoteAddr: c.RemoteAddr().String(),
+	}
+	if mode != "" {
+		req.Header.Set(interceptModeHeader, mode)
+	}
+	s.proxy.ServeHTTP(&hijackedWriter{conn: c}, req)
+}
+
+// serveHTTP proxies a plain HTTP request of an intercepted connection, whose URL is origin-form.
+func (s *interceptServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.IsAbs() {
+		host := r.Host
+		if host == "" {
+			host, _ = r.Context().Value(interceptDestKey{}).(string)
+		}
+		if host == "" {
+			http.Error(w, "Cannot determine the destination of the request", http.StatusBadRequest)
+			return
+		}
+		r.URL.Scheme = "http"
+		r.URL.Host = host
+	}
+	r.RequestURI = ""
+	s.proxy.ServeHTTP(w, r)
+}
+
+// looksLikeHTTP reports whether a connection starts with an HTTP/1.x request line.
+func looksLikeHTTP(r *bufio.Reader) bool {
+	prefix, _ := r.Peek(8)
+	for _, method := range []string{"GET ", "POST ", "PUT ", "HEAD ", "DELETE ", "OPTIONS ", "PATCH ", "TRACE "} {
+		m := []byte(method)
+		if len(prefix) >= len(m) && bytes.Equal(prefix[:len(m)], m) {
+			return true
+		}
+	}
+	return false
+}
+
+// peekServerName returns the SNI of a buffered TLS ClientHello without consuming it.
+func peekServerName(r *bufio.Reader) string {
+	header, err := r.Peek(5)
+	if err != nil {
+		return ""
+	}
+	length := int(binary.BigEndian.Uint16(header[3:5]))
+	record, err := r.Peek(5 + length)
+	if err != nil {
+		return ""
+	}
+	var serverName string
+	errDone := errors.New("client hello read")
+	tls.Server(&readOnlyConn{r: bytes.NewReader(record)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errDone
+		},
+	}).Handshake()
+	return serverName
+}
+
+// readOnlyConn lets crypto/tls parse a ClientHello from a buffer.
+type readOnlyConn struct {
+	r io.Reader
+}
+
+func (c *readOnlyConn) Read(p []byte) (int, error)       { return c.r.Read(p) }
+func (c *readOnlyConn) Write(p []byte) (int, error)      { return len(p), nil }
+func (c *readOnlyConn) Close() error                     { return nil }
+func (c *readOnlyConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *readOnlyConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (c *readOnlyConn) SetDeadline(time.Time) error      { return nil }
+func (c *readOnlyConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *readOnlyConn) SetWriteDeadline(time.Time) error { return nil }
+
+// socks5Handshake negotiates a no-auth SOCKS5 CONNECT (RFC 1928) and returns its destination. Success
+// is reported before the destination is dialed, since the proxy only dials once it has read a request.
+func socks5Handshake(conn net.Conn) (string, error) {
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", err
+	}
+	if buf[0] != 5 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", buf[0])
+	}
+	methods := buf[2 : 2+int(buf[1])]
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	if !bytes.Contains(methods, []byte{0}) {
+		conn.Write([]byte{5, 0xff})
+		return "", fmt.Errorf("client does not offer the no-auth method")
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return "", err
+	}
+	reply := func(code byte) {
+		conn.Write([]byte{5, code, 0, 1, 0, 0, 0, 0, 0, 0})
+	}
+	if buf[1] != 1 {
+		reply(7) // Command not supported
+		return "", fmt.Errorf("unsupported SOCKS command %d", buf[1])
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return "", err
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return "", err
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return "", err
+		}
+		host = string(buf[:n])
+	case 4:
+		if _, err := io.ReadFull(conn, buf[:16]); err != nil {
+			return "", err
+		}
+		host = net.IP(buf[:16]).String()
+	default:
+		reply(8) // Address type not supported
+		return "", fmt.Errorf("unsupported SOCKS address type %d", buf[3])
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+	reply(0)
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
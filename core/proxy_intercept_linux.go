@@ -0,0 +1,40 @@
+//go:build linux
+
+package core
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// soOriginalDst is the netfilter socket option holding the destination of a REDIRECTed connection.
+const soOriginalDst = 80
+
+// originalDestination returns the address an iptables REDIRECT/DNAT rule sent the connection away
+// from (IPv4 only).
+func originalDestination(conn net.Conn) (string, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return "", fmt.Errorf("not a TCP connection")
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+	var addr *syscall.IPv6Mreq
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		addr, sockErr = syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+	}); err != nil {
+		return "", err
+	}
+	if sockErr != nil {
+		return "", sockErr
+	}
+	// The option fills a sockaddr_in: port then IPv4 address, in network byte order.
+	port := int(addr.Multiaddr[2])<<8 | int(addr.Multiaddr[3])
+	ip := net.IPv4(addr.Multiaddr[4], addr.Multiaddr[5], addr.Multiaddr[6], addr.Multiaddr[7])
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package core
+
+import (
+	"fmt"
+	"net"
+)
+
+// originalDestination is not available outside Linux (pf redirects are not looked up); the
+// transparent listener falls back to the TLS SNI and HTTP Host header.
+func originalDestination(conn net.Conn) (string, error) {
+	return "", fmt.Errorf("original destination lookup is only supported on Linux")
+}
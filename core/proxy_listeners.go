@@ -0,0 +1,255 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/elazarl/goproxy"
+)
+
+// proxyListenerKey is the context key under which requests accepted by a bound listener carry it.
+type proxyListenerKey struct{}
+
+// boundListener is a running proxy listener bound to a target. Its requests are checked against
+// the target's scope and logged for it instead of the active target.
+type boundListener struct {
+	port   string
+	server *http.Server
+
+	mu       sync.RWMutex
+	targetID int64
+	rules    []models.ScopeRule
+}
+
+// Target returns the target the listener is bound to and its scope rules.
+func (l *boundListener) Target() (*int64, []models.ScopeRule) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	targetID := l.targetID
+	return &targetID, l.rules
+}
+
+// bind loads the scope rules of a target and binds the listener to it.
+func (l *boundListener) bind(targetID int64) error {
+	rules, err := database.GetAllScopeRulesForTarget(targetID)
+	if err != nil {
+		return fmt.Errorf("loading scope rules of target %d: %w", targetID, err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.targetID = targetID
+	l.rules = rules
+	return nil
+}
+
+// requestProxyListener returns the bound listener a proxied request came through, or nil for the
+// main listener. Requests read from a MITMed connection carry it in the handshake user data, as
+// goproxy does not keep the CONNECT request's context for them.
+func requestProxyListener(r *http.Request, ctx *goproxy.ProxyCtx) *boundListener {
+	if ctx != nil {
+		if hs, ok := ctx.UserData.(*mitmHandshake); ok {
+			return hs.listener
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	l, _ := r.Context().Value(proxyListenerKey{}).(*boundListener)
+	return l
+}
+
+// proxyListeners holds the bound listeners of the proxy running in this process.
+var proxyListeners = struct {
+	mu       sync.Mutex
+	proxy    http.Handler // nil while the proxy is not running
+	mainPort string
+	running  map[string]*boundListener
+	errors   map[string]string // Why stored listeners are not running, by port
+}{running: make(map[string]*boundListener), errors: make(map[string]string)}
+
+// startProxyListeners starts the stored listeners for the proxy listening on mainPort. Listeners
+// that cannot start are logged and reported by ListProxyListeners. All of them stop when ctx is
+// done.
+func startProxyListeners(ctx context.Context, mainPort string, proxy http.Handler) {
+	stored, err := database.GetProxyListeners()
+	if err != nil {
+		logger.ProxyError("Loading proxy listeners: %v", err)
+	}
+	proxyListeners.mu.Lock()
+	proxyListeners.proxy = proxy
+	proxyListeners.mainPort = mainPort
+	for _, l := range stored {
+		if err := startBoundListener(l.Port, l.TargetID); err != nil {
+			logger.ProxyError("Proxy listener on port %s (target %d): %v", l.Port, l.TargetID, err)
+			proxyListeners.errors[l.Port] = err.Error()
+		}
+	}
+	proxyListeners.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		proxyListeners.mu.Lock()
+		defer proxyListeners.mu.Unlock()
+		for port := range proxyListeners.running {
+			stopBoundListener(port)
+		}
+		proxyListeners.proxy = nil
+		proxyListeners.errors = make(map[string]string)
+	}()
+}
+
+// startBoundListener starts serving the proxy on port for a target. proxyListeners.mu must be held.
+func startBoundListener(port string, targetID int64) error {
+	l := &boundListener{port: port}
+	if err := l.bind(targetID); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("port %s is already in use", port)
+		}
+		return fmt.Errorf("listening on port %s: %w", port, err)
+	}
+	l.server = &http.Server{
+		Handler: proxyListeners.proxy,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, proxyListenerKey{}, l)
+		},
+	}
+	go func() {
+		if err := l.server.Serve(ln); err != http.ErrServerClosed {
+			logger.ProxyError("Proxy listener on port %s: %v", port, err)
+		}
+	}()
+	proxyListeners.running[port] = l
+	delete(proxyListeners.errors, port)
+	logger.ProxyInfo("Proxy listener on port %s bound to target %d", port, targetID)
+	return nil
+}
+
+// stopBoundListener stops the listener on port, if it runs. proxyListeners.mu must be held.
+func stopBoundListener(port string) {
+	l, ok := proxyListeners.running[port]
+	if !ok {
+		return
+	}
+	if err := l.server.Shutdown(context.Background()); err != nil {
+		logger.ProxyError("Stopping proxy listener on port %s: %v", port, err)
+	}
+	delete(proxyListeners.running, port)
+	logger.ProxyInfo("Proxy listener on port %s stopped", port)
+}
+
+// ListProxyListeners returns the stored proxy listeners and whether they run in this process.
+func ListProxyListeners() ([]models.ProxyListener, error) {
+	listeners, err := database.GetProxyListeners()
+	if err != nil {
+		return nil, err
+	}
+	proxyListeners.mu.Lock()
+	defer proxyListeners.mu.Unlock()
+	for i := range listeners {
+		_, listeners[i].Running = proxyListeners.running[listeners[i].Port]
+		listeners[i].Error = proxyListeners.errors[listeners[i].Port]
+	}
+	return listeners, nil
+}
+
+// validateProxyListenerPort rejects ports that are not numbers or belong to the proxy's own
+// listeners.
+func validateProxyListenerPort(port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port '%s': expected a number from 1 to 65535", port)
+	}
+	reserved := map[string]string{
+		config.AppConfig.Proxy.Port:            "the proxy's port",
+		proxyListeners.mainPort:                "the proxy's port",
+		config.AppConfig.Proxy.SOCKS5Port:      "the SOCKS5 listener's port",
+		config.AppConfig.Proxy.TransparentPort: "the transparent listener's port",
+	}
+	if what, ok := reserved[port]; ok {
+		return fmt.Errorf("invalid port '%s': it is %s", port, what)
+	}
+	return nil
+}
+
+// AddProxyListener stores a listener bound to a target and starts it when the proxy runs in this
+// process. A listener that cannot start is not stored.
+func AddProxyListener(req models.ProxyListenerRequest) (models.ProxyListener, error) {
+	proxyListeners.mu.Lock()
+	defer proxyListeners.mu.Unlock()
+	if err := validateProxyListenerPort(req.Port); err != nil {
+		return models.ProxyListener{}, err
+	}
+	if _, err := database.GetTargetByID(req.TargetID); err != nil {
+		return models.ProxyListener{}, err
+	}
+	if err := database.CreateProxyListener(req.Port, req.TargetID); err != nil {
+		return models.ProxyListener{}, err
+	}
+	if proxyListeners.proxy != nil {
+		if err := startBoundListener(req.Port, req.TargetID); err != nil {
+			if errDelete := database.DeleteProxyListener(req.Port); errDelete != nil {
+				logger.Error("AddProxyListener: %v", errDelete)
+			}
+			return models.ProxyListener{}, err
+		}
+	}
+	return proxyListenerStatus(req.Port)
+}
+
+// SetProxyListenerTarget binds a listener to another target. A running listener switches to the
+// target's scope for its next requests.
+func SetProxyListenerTarget(port string, targetID int64) (models.ProxyListener, error) {
+	proxyListeners.mu.Lock()
+	defer proxyListeners.mu.Unlock()
+	if _, err := database.GetProxyListener(port); err != nil {
+		return models.ProxyListener{}, err
+	}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.ProxyListener{}, err
+	}
+	if l, ok := proxyListeners.running[port]; ok {
+		if err := l.bind(targetID); err != nil {
+			return models.ProxyListener{}, err
+		}
+	}
+	if err := database.SetProxyListenerTarget(port, targetID); err != nil {
+		return models.ProxyListener{}, err
+	}
+	return proxyListenerStatus(port)
+}
+
+// RemoveProxyListener stops a listener and forgets it.
+func RemoveProxyListener(port string) error {
+	proxyListeners.mu.Lock()
+	defer proxyListeners.mu.Unlock()
+	if err := database.DeleteProxyListener(port); err != nil {
+		return err
+	}
+	stopBoundListener(port)
+	delete(proxyListeners.errors, port)
+	return nil
+}
+
+// proxyListenerStatus returns a stored listener with its state. proxyListeners.mu must be held.
+func proxyListenerStatus(port string) (models.ProxyListener, error) {
+	l, err := database.GetProxyListener(port)
+	if err != nil {
+		return l, err
+	}
+	_, l.Running = proxyListeners.running[port]
+	l.Error = proxyListeners.errors[port]
+	return l, nil
+}
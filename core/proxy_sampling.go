@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/models"
+)
+
+// Classes of proxy traffic logged only in part in performance mode.
+const (
+	sampleOutOfScope  = "out_of_scope"
+	sampleStaticAsset = "static_asset"
+)
+
+// staticAssetExtensions are the path extensions of static assets. Scripts are not among them:
+// they are what the JS analysis works on.
+var staticAssetExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".avif": true, ".svg": true,
+	".ico": true, ".bmp": true, ".css": true, ".woff": true, ".woff2": true, ".ttf": true, ".otf": true,
+	".eot": true, ".mp4": true, ".webm": true, ".mp3": true, ".ogg": true, ".wav": true, ".m4a": true,
+}
+
+// proxySampler counts the sampled requests of each class and picks the ones to log.
+var proxySampler = struct {
+	mu     sync.Mutex
+	counts map[string]*models.ProxySampleCounts
+	since  time.Time
+}{
+	counts: map[string]*models.ProxySampleCounts{sampleOutOfScope: {}, sampleStaticAsset: {}},
+	since:  time.Now(),
+}
+
+// isStaticAssetPath reports whether a URL path names an image, font, stylesheet or media file.
+func isStaticAssetPath(u *url.URL) bool {
+	return staticAssetExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// isStaticAssetContentType reports whether a response content type is an image, font, stylesheet
+// or media type.
+func isStaticAssetContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/css":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "font/"),
+		strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return true
+	}
+	return false
+}
+
+// proxySampleClass returns the sampled class of a proxied request, or "" when it is always logged:
+// requests the toolkit sent, Page Sitemap recordings and platform traffic are, as is in-scope
+// traffic other than static assets. byResponse is set for in-scope requests whose path does not
+// tell whether they fetch a static asset, so the response's content type is checked instead.
+func proxySampleClass(r *http.Request, targetID *int64, provider PlatformProvider) (class string, byResponse bool) {
+	if !config.AppConfig.Proxy.PerformanceMode || provider != nil || r.Header.Get("X-Toolkit-Initiated") == "true" ||
+		proxyState.RecordingPageID() != nil {
+		return "", false
+	}
+	switch {
+	case targetID == nil:
+		return sampleOutOfScope, false
+	case isStaticAssetPath(r.URL):
+		return sampleStaticAsset, false
+	}
+	return "", true
+}
+
+// sampleProxyRequest reports whether a request of a sampled class is logged: all of them outside
+// performance mode, otherwise the first of every proxy.sample_rate.
+func sampleProxyRequest(class string) bool {
+	conf := config.AppConfig.Proxy
+	if !conf.PerformanceMode {
+		return true
+	}
+	rate := int64(conf.SampleRate)
+	if rate < 1 {
+		rate = 1
+	}
+	proxySampler.mu.Lock()
+	defer proxySampler.mu.Unlock()
+	c := proxySampler.counts[class]
+	keep := c.Seen%rate == 0
+	c.Seen++
+	if keep {
+		c.Logged++
+	} else {
+		c.Skipped++
+	}
+	return keep
+}
+
+// GetProxyPerformanceStatus returns the performance mode settings and how many requests were
+// sampled out since the proxy started or the mode was last changed.
+func GetProxyPerformanceStatus() models.ProxyPerformanceStatus {
+	proxySampler.mu.Lock()
+	defer proxySampler.mu.Unlock()
+	return models.ProxyPerformanceStatus{
+		Enabled:     config.AppConfig.Proxy.PerformanceMode,
+		SampleRate:  config.AppConfig.Proxy.SampleRate,
+		OutOfScope:  *proxySampler.counts[sampleOutOfScope],
+		StaticAsset: *proxySampler.counts[sampleStaticAsset],
+		Since:       proxySampler.since,
+	}
+}
+
+// SetProxyPerformanceMode turns the performance mode on or off and changes its sample rate while
+// the proxy runs. The values are stored as runtime settings, so they also apply on later starts,
+// and the counters are reset.
+func SetProxyPerformanceMode(update models.ProxyPerformanceUpdate) (models.ProxyPerformanceStatus, error) {
+	settings := models.RuntimeSettingsUpdate{}
+	if update.Enabled != nil {
+		settings["proxy.performance_mode"], _ = json.Marshal(*update.Enabled)
+	}
+	if update.SampleRate != nil {
+		if *update.SampleRate < 1 {
+			return models.ProxyPerformanceStatus{}, fmt.Errorf("invalid sample_rate %d: it must be at least 1", *update.SampleRate)
+		}
+		settings["proxy.sample_rate"], _ = json.Marshal(*update.SampleRate)
+	}
+	if len(settings) == 0 {
+		return models.ProxyPerformanceStatus{}, fmt.Errorf("invalid update: give enabled or sample_rate")
+	}
+	if _, err := UpdateRuntimeSettings(settings); err != nil {
+		return models.ProxyPerformanceStatus{}, err
+	}
+	proxySampler.mu.Lock()
+	for _, c := range proxySampler.counts {
+		*c = models.ProxySampleCounts{}
+	}
+	proxySampler.since = time.Now()
+	proxySampler.mu.Unlock()
+	return GetProxyPerformanceStatus(), nil
+}
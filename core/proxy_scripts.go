@@ -0,0 +1,690 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+	"unicode/utf8"
+
+	"github.com/elazarl/goproxy"
+)
+
+const (
+	defaultProxyScriptTimeout = 2 * time.Second
+	maxProxyScriptTimeout     = time.Minute
+	maxProxyScriptOutput      = 64 * 1024 * 1024 // Bytes of stdout read from a script
+	maxProxyScriptStderr      = 16 * 1024        // Bytes of stderr kept for errors and tests
+)
+
+// proxyScriptInterpreters run script files by extension. Other files must be executable.
+// Interpreters are never taken from the script's settings.
+var proxyScriptInterpreters = map[string][]string{
+	".lua":  {"lua"},
+	".js":   {"node"},
+	".mjs":  {"node"},
+	".star": {"starlark"},
+	".py":   {"python3"},
+	".rb":   {"ruby"},
+	".sh":   {"sh"},
+}
+
+// proxyScripts caches the enabled proxy scripts read on the proxy's hot path.
+var proxyScripts = struct {
+	sync.RWMutex
+	loaded bool
+	list   []cachedProxyScript
+}{}
+
+// cachedProxyScript is an enabled proxy script with its compiled host pattern.
+type cachedProxyScript struct {
+	models.ProxyScript
+	host *regexp.Regexp // Nil for all hosts
+}
+
+// proxyScriptStats holds the counters of a script in this process.
+type proxyScriptStats struct {
+	sync.Mutex
+	runs, failures int64
+	lastError      string
+	lastRunAt      time.Time
+}
+
+var proxyScriptStatsByID sync.Map // map[int64]*proxyScriptStats
+
+// ListProxyScripts returns the proxy scripts in the order they run, with their counters.
+func ListProxyScripts() ([]models.ProxyScript, error) {
+	scripts, err := database.GetProxyScripts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range scripts {
+		withProxyScriptStats(&scripts[i])
+	}
+	return scripts, nil
+}
+
+// GetProxyScript returns a proxy script with its counters.
+func GetProxyScript(id int64) (models.ProxyScript, error) {
+	s, err := database.GetProxyScript(id)
+	if err != nil {
+		return s, err
+	}
+	withProxyScriptStats(&s)
+	return s, nil
+}
+
+// proxyScriptsDisabled returns the error of adding, enabling or running a script while
+// proxy_scripts.enabled is off.
+func proxyScriptsDisabled() error {
+	if config.AppConfig.ProxyScripts.Enabled {
+		return nil
+	}
+	return models.NewAppError(models.ErrCodeForbidden, "proxy scripts are disabled (set proxy_scripts.enabled in the config)")
+}
+
+// CreateProxyScript validates and stores a new proxy script. It runs on the next matching message.
+func CreateProxyScript(s models.ProxyScript) (models.ProxyScript, error) {
+	if err := proxyScriptsDisabled(); err != nil {
+		return s, err
+	}
+	if err := validateProxyScript(&s); err != nil {
+		return s, err
+	}
+	id, err := database.CreateProxyScript(s)
+	if err != nil {
+		return s, err
+	}
+	invalidateProxyScripts()
+	logger.Info("CreateProxyScript: Script %d '%s' runs %s on %s events", id, s.Name, s.Path, strings.Join(s.Events, ", "))
+	return GetProxyScript(id)
+}
+
+// UpdateProxyScript validates and replaces the settings of a proxy script.
+func UpdateProxyScript(s models.ProxyScript) (models.ProxyScript, error) {
+	if err := proxyScriptsDisabled(); err != nil {
+		return s, err
+	}
+	if _, err := database.GetProxyScript(s.ID); err != nil {
+		return s, err
+	}
+	if err := validateProxyScript(&s); err != nil {
+		return s, err
+	}
+	if err := database.UpdateProxyScript(s); err != nil {
+		return s, err
+	}
+	invalidateProxyScripts()
+	return GetProxyScript(s.ID)
+}
+
+// SetProxyScriptEnabled turns a proxy script on or off.
+func SetProxyScriptEnabled(id int64, enabled bool) (models.ProxyScript, error) {
+	if enabled {
+		if err := proxyScriptsDisabled(); err != nil {
+			return models.ProxyScript{}, err
+		}
+	}
+	s, err := database.GetProxyScript(id)
+	if err != nil {
+		return s, err
+	}
+	s.Enabled = enabled
+	if err := database.UpdateProxyScript(s); err != nil {
+		return s, err
+	}
+	invalidateProxyScripts()
+	return GetProxyScript(id)
+}
+
+// DeleteProxyScript removes a proxy script.
+func DeleteProxyScript(id int64) error {
+	if err := database.DeleteProxyScript(id); err != nil {
+		return err
+	}
+	invalidateProxyScripts()
+	proxyScriptStatsByID.Delete(id)
+	return nil
+}
+
+// validateProxyScript checks a script and normalizes its settings.
+func validateProxyScript(s *models.ProxyScript) error {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Path = strings.TrimSpace(s.Path)
+	s.Interpreter = strings.TrimSpace(s.Interpreter)
+	s.HostPattern = strings.ToLower(strings.TrimSpace(s.HostPattern))
+	if s.Name == "" {
+		return fmt.Errorf("invalid name: required")
+	}
+	path, err := proxyScriptFile(s.Path)
+	if err != nil {
+		return err
+	}
+	s.Path = path
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("invalid path '%s': %v", path, err)
+	}
+	interpreter := proxyScriptInterpreters[strings.ToLower(filepath.Ext(path))]
+	if interpreter == nil && info.Mode()&0o111 == 0 {
+		return fmt.Errorf("invalid path '%s': not executable and not a .lua, .js, .mjs, .star, .py, .rb or .sh file", path)
+	}
+	if interpreter == nil {
+		interpreter = []string{""}
+	}
+	if s.Interpreter != "" && s.Interpreter != interpreter[0] {
+		return fmt.Errorf("invalid interpreter '%s': scripts run with the interpreter of their extension or by themselves", s.Interpreter)
+	}
+	s.Interpreter = interpreter[0]
+	if len(s.Events) == 0 {
+		s.Events = []string{models.ProxyScriptEventRequest}
+	}
+	var events []string
+	for _, e := range s.Events {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != models.ProxyScriptEventRequest && e != models.ProxyScriptEventResponse {
+			return fmt.Errorf("invalid event '%s' (request or response)", e)
+		}
+		events = append(events, e)
+	}
+	s.Events = processSlice(events)
+	if s.TargetID != nil {
+		if *s.TargetID == 0 {
+			s.TargetID = nil
+		} else if _, err := database.GetTargetByID(*s.TargetID); err != nil {
+			return err
+		}
+	}
+	if s.HostPattern != "" {
+		if _, err := hostPatternRegex(s.HostPattern); err != nil {
+			return fmt.Errorf("invalid host_pattern '%s': %v", s.HostPattern, err)
+		}
+	}
+	if s.TimeoutMs < 0 || time.Duration(s.TimeoutMs)*time.Millisecond > maxProxyScriptTimeout {
+		return fmt.Errorf("invalid timeout_ms: must be between 0 and %d", maxProxyScriptTimeout.Milliseconds())
+	}
+	return nil
+}
+
+// proxyScriptFile resolves the path of a script file, a relative path in proxy_scripts.dir. The
+// file must be in that directory after following symbolic links; the resolved path is returned.
+func proxyScriptFile(path string) (string, error) {
+	dir := config.AppConfig.ProxyScripts.Dir
+	if path == "" {
+		return "", fmt.Errorf("invalid path: required")
+	}
+	if dir == "" {
+		return "", fmt.Errorf("invalid path '%s': proxy_scripts.dir is not set", path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid path '%s': proxy_scripts.dir: %v", path, err)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path '%s': not a file", path)
+	}
+	rel, err := filepath.Rel(realDir, realPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path '%s': not in proxy_scripts.dir (%s)", path, dir)
+	}
+	return realPath, nil
+}
+
+// hostPatternRegex compiles a host pattern in which * matches any characters.
+func hostPatternRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+func invalidateProxyScripts() {
+	proxyScripts.Lock()
+	proxyScripts.loaded = false
+	proxyScripts.list = nil
+	proxyScripts.Unlock()
+}
+
+// matchingProxyScripts returns the enabled scripts running on an event of a target's traffic to a
+// host, in the order they run.
+func matchingProxyScripts(event string, targetID *int64, host string) []models.ProxyScript {
+	if !config.AppConfig.ProxyScripts.Enabled {
+		return nil
+	}
+	proxyScripts.RLock()
+	loaded, list := proxyScripts.loaded, proxyScripts.list
+	proxyScripts.RUnlock()
+	if !loaded {
+		scripts, err := database.GetProxyScripts()
+		if err != nil {
+			logger.ProxyError("Proxy scripts: %v", err)
+			return nil
+		}
+		list = nil
+		for _, s := range scripts {
+			if !s.Enabled {
+				continue
+			}
+			c := cachedProxyScript{ProxyScript: s}
+			if s.HostPattern != "" {
+				if c.host, err = hostPatternRegex(s.HostPattern); err != nil {
+					logger.ProxyError("Proxy scripts: %s: invalid host_pattern '%s': %v", s.Name, s.HostPattern, err)
+					continue
+				}
+			}
+			list = append(list, c)
+		}
+		proxyScripts.Lock()
+		proxyScripts.loaded, proxyScripts.list = true, list
+		proxyScripts.Unlock()
+	}
+
+	host = strings.ToLower(host)
+	var matching []models.ProxyScript
+	for _, s := range list {
+		if !containsFold(s.Events, event) {
+			continue
+		}
+		if s.TargetID != nil && (targetID == nil || *targetID != *s.TargetID) {
+			continue
+		}
+		if s.host != nil && !s.host.MatchString(host) {
+			continue
+		}
+		matching = append(matching, s.ProxyScript)
+	}
+	return matching
+}
+
+// runProxyScript runs a script with input on stdin and parses its stdout. stderr is returned for
+// tests and error messages. The script runs in its directory with a minimal environment (PATH and
+// a temporary HOME and TMPDIR removed afterwards) and is killed when it takes longer than its
+// timeout.
+func runProxyScript(ctx context.Context, s models.ProxyScript, input models.ProxyScriptInput) (models.ProxyScriptOutput, string, error) {
+	var output models.ProxyScriptOutput
+	if err := proxyScriptsDisabled(); err != nil {
+		return output, "", err
+	}
+	// The file is checked again: the script may predate proxy_scripts.dir or the file may have moved.
+	path, err := proxyScriptFile(s.Path)
+	if err != nil {
+		return output, "", fmt.Errorf("script %s: %w", s.Name, err)
+	}
+	timeout := defaultProxyScriptTimeout
+	if s.TimeoutMs > 0 {
+		timeout = time.Duration(s.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append(append([]string{}, proxyScriptInterpreters[strings.ToLower(filepath.Ext(path))]...), path)
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return output, "", fmt.Errorf("encoding script input: %w", err)
+	}
+	home, err := os.MkdirTemp("", "toolkit-script-")
+	if err != nil {
+		return output, "", fmt.Errorf("creating home directory of script %s: %w", s.Name, err)
+	}
+	defer os.RemoveAll(home)
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = proxyScriptEnv(s, home)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout bytes.Buffer
+	stderr := &commandRunState{maxBytes: maxProxyScriptStderr}
+	cmd.Stdout = &limitedWriter{w: &stdout, remaining: maxProxyScriptOutput}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+	sandboxPluginCommand(cmd)
+	errRun := cmd.Run()
+	stderrText := strings.TrimSpace(string(stderr.output))
+
+	defer recordProxyScriptRun(s.ID, &err)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("script %s timed out after %v", s.Name, timeout)
+	case errRun != nil:
+		err = fmt.Errorf("script %s failed: %v", s.Name, errRun)
+		if stderrText != "" {
+			err = fmt.Errorf("%w: %s", err, firstLine(stderrText))
+		}
+	case len(bytes.TrimSpace(stdout.Bytes())) == 0:
+		output.Action = models.ProxyScriptActionContinue
+	default:
+		if errJSON := json.Unmarshal(stdout.Bytes(), &output); errJSON != nil {
+			err = fmt.Errorf("script %s wrote invalid JSON: %v", s.Name, errJSON)
+			break
+		}
+		switch output.Action {
+		case "":
+			output.Action = models.ProxyScriptActionContinue
+		case models.ProxyScriptActionContinue, models.ProxyScriptActionDrop:
+		default:
+			err = fmt.Errorf("script %s returned invalid action '%s' (continue or drop)", s.Name, output.Action)
+		}
+	}
+	return output, stderrText, err
+}
+
+// proxyScriptEnv is the environment of a proxy script: none of the toolkit's own besides PATH, so
+// API keys and tokens in it don't reach scripts.
+func proxyScriptEnv(s models.ProxyScript, home string) []string {
+	return []string{"PATH=" + toolSearchPath(), "HOME=" + home, "TMPDIR=" + home, "LANG=C.UTF-8", "TOOLKIT_PROXY_SCRIPT=" + s.Name}
+}
+
+// limitedWriter stops writing after remaining bytes.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, errors.New("output too large")
+	}
+	l.remaining -= int64(len(p))
+	return l.w.Write(p)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// recordProxyScriptRun counts a run of a script and its error, if any.
+func recordProxyScriptRun(id int64, err *error) {
+	v, _ := proxyScriptStatsByID.LoadOrStore(id, &proxyScriptStats{})
+	stats := v.(*proxyScriptStats)
+	stats.Lock()
+	defer stats.Unlock()
+	stats.runs++
+	stats.lastRunAt = time.Now()
+	if *err != nil {
+		stats.failures++
+		stats.lastError = (*err).Error()
+	}
+}
+
+func withProxyScriptStats(s *models.ProxyScript) {
+	v, ok := proxyScriptStatsByID.Load(s.ID)
+	if !ok {
+		return
+	}
+	stats := v.(*proxyScriptStats)
+	stats.Lock()
+	defer stats.Unlock()
+	s.Runs, s.Failures, s.LastError = stats.runs, stats.failures, stats.lastError
+	at := stats.lastRunAt
+	s.LastRunAt = &at
+}
+
+// scriptBody returns a body as a script gets it.
+func scriptBody(body []byte) (*string, bool) {
+	if utf8.Valid(body) {
+		text := string(body)
+		return &text, false
+	}
+	encoded := base64.StdEncoding.EncodeToString(body)
+	return &encoded, true
+}
+
+// scriptReturnedBody decodes the body a script returned.
+func scriptReturnedBody(m *models.ProxyScriptMessage) ([]byte, error) {
+	if !m.BodyBase64 {
+		return []byte(*m.Body), nil
+	}
+	body, err := base64.StdEncoding.DecodeString(*m.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 body: %v", err)
+	}
+	return body, nil
+}
+
+// scriptRequestMessage returns a request as a script gets it.
+func scriptRequestMessage(method, rawURL string, headers http.Header, body []byte) models.ProxyScriptMessage {
+	m := models.ProxyScriptMessage{Method: method, URL: rawURL, Headers: map[string][]string(headers.Clone())}
+	m.Body, m.BodyBase64 = scriptBody(body)
+	return m
+}
+
+// applyRequestScripts runs the request scripts matching a request passing through the proxy, which
+// may change it. A dropped request gets a 403 response that is returned to the client instead of
+// sending the request. The tags the scripts asked for are returned for the log entry.
+func applyRequestScripts(r *http.Request, targetID *int64) (*http.Response, []string) {
+	scripts := matchingProxyScripts(models.ProxyScriptEventRequest, targetID, r.URL.Hostname())
+	if len(scripts) == 0 {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		logger.ProxyError("Proxy scripts: reading request body of %s %s: %v", r.Method, r.URL.String(), err)
+	}
+	var tags []string
+	for _, s := range scripts {
+		input := models.ProxyScriptInput{Event: models.ProxyScriptEventRequest, Script: s.Name, TargetID: targetID,
+			Request: scriptRequestMessage(r.Method, r.URL.String(), r.Header, body)}
+		output, _, err := runProxyScript(r.Context(), s, input)
+		if err != nil {
+			// A failing script leaves the request as it was rather than breaking the browsing session.
+			logger.ProxyError("Proxy scripts: %s %s: %v", r.Method, r.URL.String(), err)
+			continue
+		}
+		tags = append(tags, output.Tags...)
+		if output.Action == models.ProxyScriptActionDrop {
+			logger.ProxyInfo("REQ: %s %s - DROPPED by proxy script %s.", r.Method, r.URL.String(), s.Name)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			return goproxy.NewResponse(r, goproxy.ContentTypeText, http.StatusForbidden,
+				fmt.Sprintf("Dropped by the toolkit: proxy script %s dropped %s %s.\n", s.Name, r.Method, r.URL.String())), nil
+		}
+		if output.Request != nil {
+			if body, err = applyScriptRequest(r, body, output.Request); err != nil {
+				logger.ProxyError("Proxy scripts: %s returned an unusable request for %s: %v", s.Name, r.URL.String(), err)
+			}
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+	return nil, tags
+}
+
+// applyScriptRequest applies the request fields a script returned and returns the new body.
+func applyScriptRequest(r *http.Request, body []byte, m *models.ProxyScriptMessage) ([]byte, error) {
+	if m.URL != "" && m.URL != r.URL.String() {
+		u, err := r.URL.Parse(m.URL)
+		if err != nil || u.Host == "" {
+			return body, fmt.Errorf("invalid url '%s'", m.URL)
+		}
+		r.URL = u
+		r.Host = u.Host
+	}
+	if m.Method != "" {
+		r.Method = strings.ToUpper(m.Method)
+	}
+	if m.Headers != nil {
+		r.Header = http.Header{}
+		for name, values := range m.Headers {
+			for _, v := range values {
+				r.Header.Add(name, v)
+			}
+		}
+	}
+	if m.Body != nil {
+		return scriptReturnedBody(m)
+	}
+	return body, nil
+}
+
+// applyResponseScripts runs the response scripts matching the response to req passing through the
+// proxy, which may change it. entry is the request as it is logged. drop tells that a script asked
+// for the exchange not to be logged. The tags the scripts asked for are returned for the log entry.
+func applyResponseScripts(resp *http.Response, req *http.Request, entry *models.HTTPTrafficLog) (drop bool, tags []string) {
+	targetID := entry.TargetID
+	scripts := matchingProxyScripts(models.ProxyScriptEventResponse, targetID, req.URL.Hostname())
+	if len(scripts) == 0 {
+		return false, nil
+	}
+	limit := config.AppConfig.Proxy.ScriptMaxBodyBytes
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		logger.ProxyError("Proxy scripts: reading response body of %s: %v", req.URL.String(), err)
+	}
+	omitted := int64(len(raw)) > limit
+	if omitted {
+		// Too large for scripts: the body streams through unchanged.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(raw), resp.Body), resp.Body}
+	} else {
+		resp.Body.Close()
+	}
+	body := raw
+	encoding := resp.Header.Get("Content-Encoding")
+	if !omitted && encoding != "" {
+		if decoded, errDecode := DecodeContentEncoding(raw, encoding); errDecode == nil {
+			body = decoded
+		}
+	}
+
+	request := scriptRequestMessage(entry.RequestMethod.String, entry.RequestURL.String, HeadersFromJSON(entry.RequestHeaders.String), entry.RequestBody)
+	bodyChanged := false
+	for _, s := range scripts {
+		response := models.ProxyScriptMessage{Status: resp.StatusCode, Headers: map[string][]string(resp.Header.Clone()), BodyOmitted: omitted}
+		if !omitted {
+			response.Body, response.BodyBase64 = scriptBody(body)
+		}
+		input := models.ProxyScriptInput{Event: models.ProxyScriptEventResponse, Script: s.Name, TargetID: targetID, Request: request, Response: &response}
+		output, _, err := runProxyScript(req.Context(), s, input)
+		if err != nil {
+			logger.ProxyError("Proxy scripts: %s: %v", req.URL.String(), err)
+			continue
+		}
+		tags = append(tags, output.Tags...)
+		if output.Action == models.ProxyScriptActionDrop {
+			logger.ProxyDebug("RESP: %s - Not logged, dropped by proxy script %s.", req.URL.String(), s.Name)
+			drop = true
+		}
+		if m := output.Response; m != nil {
+			if m.Status != 0 {
+				resp.StatusCode = m.Status
+				resp.Status = fmt.Sprintf("%d %s", m.Status, http.StatusText(m.Status))
+			}
+			if m.Headers != nil {
+				resp.Header = http.Header{}
+				for name, values := range m.Headers {
+					for _, v := range values {
+						resp.Header.Add(name, v)
+					}
+				}
+			}
+			if m.Body != nil && !omitted {
+				newBody, errBody := scriptReturnedBody(m)
+				if errBody != nil {
+					logger.ProxyError("Proxy scripts: %s returned an unusable response for %s: %v", s.Name, req.URL.String(), errBody)
+				} else {
+					body, bodyChanged = newBody, true
+				}
+			}
+		}
+	}
+
+	if omitted {
+		return drop, tags
+	}
+	if bodyChanged {
+		// The changed body goes out decoded.
+		resp.Header.Del("Content-Encoding")
+		raw = body
+	} else if resp.Header.Get("Content-Encoding") == "" && encoding != "" {
+		raw = body // A script removed the encoding header
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	resp.TransferEncoding = nil
+	return drop, tags
+}
+
+// tagProxyScriptLog adds the tags proxy scripts asked for to a log entry, creating missing tags.
+func tagProxyScriptLog(logID int64, tags []string) {
+	for _, name := range processSlice(tags) {
+		tag, err := database.CreateTag(models.Tag{Name: name})
+		if err == nil {
+			_, err = database.AssociateTagWithItem(tag.ID, logID, models.TagItemHTTPLog)
+		}
+		if err != nil {
+			logger.ProxyError("Proxy scripts: tagging log %d with '%s': %v", logID, name, err)
+		}
+	}
+}
+
+// TestProxyScript runs a proxy script against a logged request (and response, for response
+// events) and returns what it got and returned. Nothing is sent or changed.
+func TestProxyScript(id int64, req models.ProxyScriptTestRequest) (models.ProxyScriptTestResult, error) {
+	var result models.ProxyScriptTestResult
+	if err := proxyScriptsDisabled(); err != nil {
+		return result, err
+	}
+	s, err := database.GetProxyScript(id)
+	if err != nil {
+		return result, err
+	}
+	entry, err := database.GetHTTPTrafficLogEntryByID(req.LogID)
+	if err != nil {
+		return result, err
+	}
+	event := strings.ToLower(strings.TrimSpace(req.Event))
+	if event == "" && len(s.Events) > 0 {
+		event = s.Events[0]
+	}
+	if event != models.ProxyScriptEventRequest && event != models.ProxyScriptEventResponse {
+		return result, fmt.Errorf("invalid event '%s' (request or response)", req.Event)
+	}
+
+	result.Input = models.ProxyScriptInput{Event: event, Script: s.Name, TargetID: entry.TargetID,
+		Request: scriptRequestMessage(entry.RequestMethod.String, entry.RequestURL.String, HeadersFromJSON(entry.RequestHeaders.String), entry.RequestBody)}
+	if event == models.ProxyScriptEventResponse {
+		headers := HeadersFromJSON(entry.ResponseHeaders.String)
+		response := models.ProxyScriptMessage{Status: entry.ResponseStatusCode, Headers: map[string][]string(headers)}
+		body := entry.ResponseBody
+		if decoded, errDecode := DecodeContentEncoding(body, headers.Get("Content-Encoding")); errDecode == nil {
+			body = decoded
+		}
+		if int64(len(body)) > config.AppConfig.Proxy.ScriptMaxBodyBytes {
+			response.BodyOmitted = true
+		} else {
+			response.Body, response.BodyBase64 = scriptBody(body)
+		}
+		result.Input.Response = &response
+	}
+
+	start := time.Now()
+	output, stderr, err := runProxyScript(context.Background(), s, result.Input)
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Output, result.Stderr = output, stderr
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"toolkit/config"
+	"toolkit/models"
+)
+
+// withProxyScripts sets proxy_scripts for a test and returns its scripts directory, which holds an
+// executable env.sh tagging messages with $TOOLKIT_TEST_SECRET and $TOOLKIT_PROXY_SCRIPT.
+func withProxyScripts(t *testing.T, enabled bool) string {
+	t.Helper()
+	saved := config.AppConfig.ProxyScripts
+	t.Cleanup(func() { config.AppConfig.ProxyScripts = saved })
+	dir := t.TempDir()
+	config.AppConfig.ProxyScripts = config.ProxyScriptsConfig{Enabled: enabled, Dir: dir}
+	script := "#!/bin/sh\ncat >/dev/null\nprintf '{\"tags\":[\"secret:%s\",\"script:%s\"]}' \"${TOOLKIT_TEST_SECRET:-none}\" \"$TOOLKIT_PROXY_SCRIPT\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "env.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestProxyScriptsDisabledByDefault(t *testing.T) {
+	withProxyScripts(t, false)
+	_, err := CreateProxyScript(models.ProxyScript{Name: "env", Path: "env.sh"})
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) || appErr.Code != models.ErrCodeForbidden {
+		t.Fatalf("CreateProxyScript while disabled = %v, want a forbidden error", err)
+	}
+	if _, err := SetProxyScriptEnabled(1, true); !errors.As(err, &appErr) || appErr.Code != models.ErrCodeForbidden {
+		t.Errorf("SetProxyScriptEnabled while disabled = %v, want a forbidden error", err)
+	}
+	if scripts := matchingProxyScripts(models.ProxyScriptEventRequest, nil, "example.com"); scripts != nil {
+		t.Errorf("scripts match while disabled: %+v", scripts)
+	}
+	if _, _, err := runProxyScript(context.Background(), models.ProxyScript{Name: "env", Path: "env.sh"}, models.ProxyScriptInput{}); err == nil {
+		t.Error("a script ran while proxy scripts are disabled")
+	}
+}
+
+func TestValidateProxyScriptConfinesFilesAndInterpreters(t *testing.T) {
+	dir := withProxyScripts(t, true)
+	outside := filepath.Join(t.TempDir(), "outside.sh")
+	if err := os.WriteFile(outside, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link.sh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plain.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []models.ProxyScript{
+		{Name: "abs", Path: outside},
+		{Name: "dotdot", Path: "../" + filepath.Base(filepath.Dir(outside)) + "/outside.sh"},
+		{Name: "link", Path: "link.sh"},
+		{Name: "dir", Path: dir},
+		{Name: "missing", Path: "missing.py"},
+		{Name: "plain", Path: "plain.txt"},
+		{Name: "interpreter", Path: "env.sh", Interpreter: "python3 -c 'import os; os.system(\"id\")'"},
+		{Name: "other-interpreter", Path: "env.sh", Interpreter: "bash"},
+	} {
+		if err := validateProxyScript(&s); err == nil || !strings.HasPrefix(err.Error(), "invalid") {
+			t.Errorf("%s: validateProxyScript(%q, %q) = %v, want an invalid error", s.Name, s.Path, s.Interpreter, err)
+		}
+	}
+
+	s := models.ProxyScript{Name: "env", Path: "env.sh", Interpreter: "sh"}
+	if err := validateProxyScript(&s); err != nil {
+		t.Fatalf("validateProxyScript(env.sh) = %v", err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	if s.Path != filepath.Join(realDir, "env.sh") || s.Interpreter != "sh" {
+		t.Errorf("validated script has path %q and interpreter %q", s.Path, s.Interpreter)
+	}
+}
+
+func TestRunProxyScriptGetsMinimalEnvironment(t *testing.T) {
+	withProxyScripts(t, true)
+	t.Setenv("TOOLKIT_TEST_SECRET", "s3cr3t")
+	// An interpreter stored before interpreters were chosen by extension is ignored.
+	s := models.ProxyScript{ID: -1, Name: "env", Path: "env.sh", Interpreter: "false"}
+	output, stderr, err := runProxyScript(context.Background(), s, models.ProxyScriptInput{Event: models.ProxyScriptEventRequest})
+	if err != nil {
+		t.Fatalf("runProxyScript = %v (stderr %q)", err, stderr)
+	}
+	if strings.Join(output.Tags, ",") != "secret:none,script:env" {
+		t.Errorf("script tags = %v, want the secret withheld and the script's name", output.Tags)
+	}
+}
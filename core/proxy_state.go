@@ -0,0 +1,184 @@
+package core
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// proxySessionTTL is how long a goproxy session entry is kept after it was last touched. Sessions
+// whose connection is aborted never reach OnResponse, so the janitor drops them once they expire.
+const proxySessionTTL = 10 * time.Minute
+
+// proxySession is what the proxy remembers about a goproxy session.
+type proxySession struct {
+	isHTTPS  bool
+	lastSeen time.Time
+}
+
+// ProxyState holds the in-memory state shared by the proxy handlers. Each kind of state has its own
+// lock so that unrelated updates do not contend with each other. Slices are replaced, never
+// modified in place, so the ones handed out by the getters are safe to read without a lock.
+type ProxyState struct {
+	scopeMu    sync.RWMutex
+	targetID   *int64
+	scopeRules []models.ScopeRule
+
+	exclusionsMu     sync.RWMutex
+	exclusions       []models.ProxyExclusionRule
+	exclusionRegexes map[string]compiledExclusionRegex // By rule ID
+
+	recordingMu     sync.RWMutex
+	recordingPageID *int64
+
+	sessionsMu sync.Mutex
+	sessions   map[int64]proxySession
+	sessionTTL time.Duration
+}
+
+// proxyState is the state of the running proxy.
+var proxyState = NewProxyState(proxySessionTTL)
+
+// NewProxyState returns an empty ProxyState whose sessions expire after sessionTTL.
+func NewProxyState(sessionTTL time.Duration) *ProxyState {
+	return &ProxyState{
+		sessions:   make(map[int64]proxySession),
+		sessionTTL: sessionTTL,
+	}
+}
+
+// SetTarget sets the active target and its scope rules. A nil targetID logs traffic unassociated.
+func (s *ProxyState) SetTarget(targetID *int64, rules []models.ScopeRule) {
+	s.scopeMu.Lock()
+	defer s.scopeMu.Unlock()
+	s.targetID = targetID
+	s.scopeRules = rules
+}
+
+// Target returns the active target, or nil, along with its scope rules.
+func (s *ProxyState) Target() (*int64, []models.ScopeRule) {
+	s.scopeMu.RLock()
+	defer s.scopeMu.RUnlock()
+	return s.targetID, s.scopeRules
+}
+
+// compiledExclusionRegex is the compiled pattern of a url_regex exclusion rule. The pattern it was
+// compiled from tells whether it is still current for a rule.
+type compiledExclusionRegex struct {
+	pattern string
+	re      *regexp.Regexp
+	err     error
+}
+
+// SetExclusionRules replaces the global proxy exclusion rules and compiles their regexes once, so
+// requests don't compile them again.
+func (s *ProxyState) SetExclusionRules(rules []models.ProxyExclusionRule) {
+	regexes := make(map[string]compiledExclusionRegex)
+	for _, rule := range rules {
+		if rule.RuleType == "url_regex" {
+			re, err := regexp.Compile(rule.Pattern)
+			regexes[rule.ID] = compiledExclusionRegex{pattern: rule.Pattern, re: re, err: err}
+		}
+	}
+	s.exclusionsMu.Lock()
+	defer s.exclusionsMu.Unlock()
+	s.exclusions = rules
+	s.exclusionRegexes = regexes
+}
+
+// ExclusionRegex returns the compiled pattern of a url_regex exclusion rule. Rules that are not
+// among the active ones, or whose pattern differs from the active rule with the same ID (such as
+// unsaved edits being dry-run), are compiled without being cached.
+func (s *ProxyState) ExclusionRegex(rule models.ProxyExclusionRule) (*regexp.Regexp, error) {
+	s.exclusionsMu.RLock()
+	compiled, ok := s.exclusionRegexes[rule.ID]
+	s.exclusionsMu.RUnlock()
+	if ok && compiled.pattern == rule.Pattern {
+		return compiled.re, compiled.err
+	}
+	return regexp.Compile(rule.Pattern)
+}
+
+// ExclusionRules returns the global proxy exclusion rules.
+func (s *ProxyState) ExclusionRules() []models.ProxyExclusionRule {
+	s.exclusionsMu.RLock()
+	defer s.exclusionsMu.RUnlock()
+	return s.exclusions
+}
+
+// SetRecordingPageID sets the page a Page Sitemap recording is running for, or nil when none is.
+func (s *ProxyState) SetRecordingPageID(pageID *int64) {
+	s.recordingMu.Lock()
+	defer s.recordingMu.Unlock()
+	s.recordingPageID = pageID
+}
+
+// RecordingPageID returns the page a Page Sitemap recording is running for, or nil.
+func (s *ProxyState) RecordingPageID() *int64 {
+	s.recordingMu.RLock()
+	defer s.recordingMu.RUnlock()
+	return s.recordingPageID
+}
+
+// MarkSessionHTTPS records that a goproxy session carries HTTPS traffic.
+func (s *ProxyState) MarkSessionHTTPS(session int64) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	s.sessions[session] = proxySession{isHTTPS: true, lastSeen: time.Now()}
+}
+
+// IsSessionHTTPS reports whether a goproxy session was marked as HTTPS, refreshing its expiry.
+func (s *ProxyState) IsSessionHTTPS(session int64) bool {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	entry, ok := s.sessions[session]
+	if !ok {
+		return false
+	}
+	entry.lastSeen = time.Now()
+	s.sessions[session] = entry
+	return entry.isHTTPS
+}
+
+// EndSession forgets a goproxy session.
+func (s *ProxyState) EndSession(session int64) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	delete(s.sessions, session)
+}
+
+// PruneSessions forgets the sessions not touched since before now minus the session TTL and
+// returns how many were dropped.
+func (s *ProxyState) PruneSessions(now time.Time) int {
+	cutoff := now.Add(-s.sessionTTL)
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	pruned := 0
+	for session, entry := range s.sessions {
+		if entry.lastSeen.Before(cutoff) {
+			delete(s.sessions, session)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// runSessionJanitor prunes expired sessions until ctx is done.
+func (s *ProxyState) runSessionJanitor(ctx context.Context) {
+	ticker := time.NewTicker(s.sessionTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := s.PruneSessions(now); n > 0 {
+				logger.ProxyDebug("Pruned %d expired proxy sessions.", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"toolkit/models"
+)
+
+// sessionCount returns how many sessions a ProxyState remembers, without touching them.
+func sessionCount(s *ProxyState) int {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	return len(s.sessions)
+}
+
+func TestProxyStateConcurrentTarget(t *testing.T) {
+	s := NewProxyState(time.Minute)
+	var wg sync.WaitGroup
+	for i := int64(1); i <= 8; i++ {
+		wg.Add(2)
+		go func(id int64) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				s.SetTarget(&id, []models.ScopeRule{{TargetID: id, Pattern: "example.com"}})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				// A target and its rules are set together, so they always belong to each other.
+				target, rules := s.Target()
+				if target != nil && (len(rules) != 1 || rules[0].TargetID != *target) {
+					t.Errorf("Target() = %d with rules %+v", *target, rules)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.SetTarget(nil, nil)
+	if target, rules := s.Target(); target != nil || rules != nil {
+		t.Errorf("Target() after clearing = %v, %v; want nil, nil", target, rules)
+	}
+}
+
+func TestProxyStateConcurrentSessions(t *testing.T) {
+	s := NewProxyState(time.Minute)
+	var wg sync.WaitGroup
+	for w := int64(0); w < 8; w++ {
+		wg.Add(1)
+		go func(base int64) {
+			defer wg.Done()
+			for i := base * 1000; i < base*1000+500; i++ {
+				s.MarkSessionHTTPS(i)
+				if !s.IsSessionHTTPS(i) {
+					t.Errorf("session %d was not marked HTTPS", i)
+					return
+				}
+				if i%2 == 0 {
+					s.EndSession(i)
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			// Nothing has expired yet.
+			if n := s.PruneSessions(time.Now()); n != 0 {
+				t.Errorf("PruneSessions pruned %d fresh sessions", n)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if n := sessionCount(s); n != 8*250 {
+		t.Fatalf("%d sessions remembered, want %d", n, 8*250)
+	}
+	if s.IsSessionHTTPS(0) {
+		t.Error("ended session 0 is still HTTPS")
+	}
+	if n := s.PruneSessions(time.Now().Add(2 * time.Minute)); n != 8*250 {
+		t.Errorf("PruneSessions after the TTL pruned %d sessions, want %d", n, 8*250)
+	}
+}
+
+func TestProxyStatePruneKeepsTouchedSessions(t *testing.T) {
+	s := NewProxyState(time.Minute)
+	s.MarkSessionHTTPS(1)
+	s.MarkSessionHTTPS(2)
+	s.sessionsMu.Lock()
+	for id, entry := range s.sessions {
+		entry.lastSeen = entry.lastSeen.Add(-50 * time.Second)
+		s.sessions[id] = entry
+	}
+	s.sessionsMu.Unlock()
+
+	s.IsSessionHTTPS(2) // Refreshes session 2
+	if n := s.PruneSessions(time.Now().Add(20 * time.Second)); n != 1 {
+		t.Fatalf("PruneSessions pruned %d sessions, want 1", n)
+	}
+	if s.IsSessionHTTPS(1) || !s.IsSessionHTTPS(2) {
+		t.Error("PruneSessions dropped the touched session instead of the stale one")
+	}
+}
+
+func TestProxyStateSessionJanitorEvictsExpired(t *testing.T) {
+	s := NewProxyState(40 * time.Millisecond)
+	for i := int64(1); i <= 10; i++ {
+		s.MarkSessionHTTPS(i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.runSessionJanitor(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for sessionCount(s) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d expired sessions", sessionCount(s))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop when its context was done")
+	}
+}
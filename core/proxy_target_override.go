@@ -0,0 +1,80 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// toolkitTargetIDHeader names the target a toolkit-initiated request sent through the proxy is
+// logged against, whichever target is active. The proxy honors it only on requests that carry
+// X-Toolkit-Initiated and come from the loopback interface, and never forwards it.
+const toolkitTargetIDHeader = "X-Toolkit-Target-ID"
+
+// setToolkitTargetHeader makes the proxy log a toolkit-initiated request against targetID.
+func setToolkitTargetHeader(header http.Header, targetID int64) {
+	if targetID != 0 {
+		header.Set(toolkitTargetIDHeader, strconv.FormatInt(targetID, 10))
+	}
+}
+
+// toolkitTargetTransport sets the target header on every request sent through it.
+type toolkitTargetTransport struct {
+	base     http.RoundTripper
+	targetID int64
+}
+
+func (t *toolkitTargetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request.
+	clone := req.Clone(req.Context())
+	setToolkitTargetHeader(clone.Header, t.targetID)
+	return t.base.RoundTrip(clone)
+}
+
+// isLoopbackAddr reports whether a host:port remote address is on the loopback interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// takeToolkitTargetOverride removes the target header from a request reaching the proxy and
+// returns the target it names with that target's scope rules, when the request may override the
+// active target: it is toolkit-initiated, comes from the loopback interface and names an
+// existing target. ok is false otherwise, and the active target applies.
+func takeToolkitTargetOverride(r *http.Request) (targetID *int64, rules []models.ScopeRule, ok bool) {
+	value := r.Header.Get(toolkitTargetIDHeader)
+	if value == "" {
+		return nil, nil, false
+	}
+	r.Header.Del(toolkitTargetIDHeader)
+	if r.Header.Get("X-Toolkit-Initiated") != "true" || !isLoopbackAddr(r.RemoteAddr) {
+		logger.ProxyInfo("REQ: %s %s - Ignoring %s from %s: only toolkit-initiated requests from this machine may set it.",
+			r.Method, r.URL.String(), toolkitTargetIDHeader, r.RemoteAddr)
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		logger.ProxyError("REQ: %s %s - Invalid %s '%s'.", r.Method, r.URL.String(), toolkitTargetIDHeader, value)
+		return nil, nil, false
+	}
+	if active, activeRules := proxyState.Target(); active != nil && *active == id {
+		return active, activeRules, true
+	}
+	if _, err := database.GetTargetByID(id); err != nil {
+		logger.ProxyError("REQ: %s %s - %s: %v", r.Method, r.URL.String(), toolkitTargetIDHeader, err)
+		return nil, nil, false
+	}
+	rules, err = database.GetAllScopeRulesForTarget(id)
+	if err != nil {
+		logger.ProxyError("REQ: %s %s - Loading scope rules of target %d: %v", r.Method, r.URL.String(), id, err)
+		return nil, nil, false
+	}
+	return &id, rules, true
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"toolkit/logger"
+
+	"github.com/elazarl/goproxy"
+	xproxy "golang.org/x/net/proxy"
+)
+
+// useUpstreamProxy makes the MITM proxy send its traffic through an upstream proxy (proxy.upstream_proxy):
+// intercepted requests through its transport and tunneled CONNECTs over the upstream, with CONNECT
+// for HTTP(S) upstreams or a SOCKS5 dial for socks5:// ones.
+func useUpstreamProxy(proxy *goproxy.ProxyHttpServer, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy.upstream_proxy '%s': expected a URL such as http://127.0.0.1:8080 or socks5://127.0.0.1:1080", raw)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		proxy.ConnectDial = proxy.NewConnectDialToProxy(u.String())
+	case "socks5", "socks5h":
+		dialer, err := xproxy.FromURL(u, xproxy.Direct)
+		if err != nil {
+			return fmt.Errorf("invalid proxy.upstream_proxy '%s': %w", raw, err)
+		}
+		proxy.ConnectDial = dialer.Dial
+	default:
+		return fmt.Errorf("invalid proxy.upstream_proxy '%s': scheme must be http, https or socks5", raw)
+	}
+	proxy.Tr.Proxy = http.ProxyURL(u)
+	logger.ProxyInfo("MITM proxy forwards its traffic through upstream proxy %s", u.Redacted())
+	return nil
+}
@@ -0,0 +1,470 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// wafSignature recognizes a WAF or CDN security layer from its responses.
+type wafSignature struct {
+	name    string
+	server  []string // Substrings of the Server header, lower-case
+	headers []string // Header names
+	cookies []string // Cookie name prefixes
+	body    []string // Body substrings, lower-case
+}
+
+var wafSignatures = []wafSignature{
+	{name: "Cloudflare", server: []string{"cloudflare"}, headers: []string{"Cf-Ray", "Cf-Mitigated"},
+		cookies: []string{"__cf_bm", "cf_clearance", "__cfduid"}, body: []string{"attention required! | cloudflare", "cf-error-details", "cf-chl-"}},
+	{name: "Akamai", server: []string{"akamaighost", "akamainetstorage"}, headers: []string{"Akamai-Grn", "X-Akamai-Transformed"},
+		cookies: []string{"ak_bmsc", "bm_sz", "_abck"}, body: []string{"errors.edgesuite.net"}},
+	{name: "AWS WAF", headers: []string{"X-Amzn-Waf-Action"}, cookies: []string{"aws-waf-token"}},
+	{name: "Amazon CloudFront", server: []string{"cloudfront"}, headers: []string{"X-Amz-Cf-Id"}, body: []string{"generated by cloudfront"}},
+	{name: "Imperva Incapsula", headers: []string{"X-Iinfo"}, cookies: []string{"incap_ses_", "visid_incap_", "nlbi_"},
+		body: []string{"incapsula incident id", "_incapsula_resource"}},
+	{name: "Sucuri", server: []string{"sucuri"}, headers: []string{"X-Sucuri-Id", "X-Sucuri-Cache"}, body: []string{"sucuri website firewall"}},
+	{name: "F5 BIG-IP", server: []string{"big-ip", "bigip"}, cookies: []string{"BIGipServer", "TS01", "F5_"},
+		body: []string{"the requested url was rejected. please consult with your administrator."}},
+	{name: "Azure Front Door", headers: []string{"X-Azure-Ref"}, body: []string{"azure front door"}},
+	{name: "Fastly", headers: []string{"X-Fastly-Request-Id"}},
+	{name: "Barracuda", cookies: []string{"barra_counter_session", "BNI__BARRACUDA_LB_COOKIE"}, body: []string{"barracuda networks"}},
+	{name: "ModSecurity", server: []string{"mod_security"}, body: []string{"mod_security", "modsecurity"}},
+	{name: "Wordfence", body: []string{"generated by wordfence"}},
+	{name: "DDoS-Guard", server: []string{"ddos-guard"}, cookies: []string{"__ddg"}},
+}
+
+// rateProfilerMaxBody caps the response body read for WAF signatures.
+const rateProfilerMaxBody = 64 << 10
+
+// detectWAFs matches a response against the WAF signatures.
+func detectWAFs(headers http.Header, body []byte) []models.WAFDetection {
+	server := strings.ToLower(headers.Get("Server"))
+	lowerBody := strings.ToLower(string(body))
+	cookies := (&http.Response{Header: headers}).Cookies()
+	var found []models.WAFDetection
+	for _, sig := range wafSignatures {
+		var evidence []string
+		for _, s := range sig.server {
+			if strings.Contains(server, s) {
+				evidence = append(evidence, "Server: "+headers.Get("Server"))
+				break
+			}
+		}
+		for _, h := range sig.headers {
+			if headers.Get(h) != "" {
+				evidence = append(evidence, "header "+h)
+			}
+		}
+		for _, prefix := range sig.cookies {
+			for _, c := range cookies {
+				if strings.HasPrefix(c.Name, prefix) {
+					evidence = append(evidence, "cookie "+c.Name)
+					break
+				}
+			}
+		}
+		for _, marker := range sig.body {
+			if strings.Contains(lowerBody, marker) {
+				evidence = append(evidence, fmt.Sprintf("body contains %q", marker))
+			}
+		}
+		if len(evidence) > 0 {
+			found = append(found, models.WAFDetection{Name: sig.name, Evidence: evidence})
+		}
+	}
+	return found
+}
+
+// mergeWAFs adds detections to known ones, keeping each piece of evidence once.
+func mergeWAFs(known, detected []models.WAFDetection) []models.WAFDetection {
+	for _, d := range detected {
+		i := 0
+		for i < len(known) && known[i].Name != d.Name {
+			i++
+		}
+		if i == len(known) {
+			known = append(known, models.WAFDetection{Name: d.Name})
+		}
+		for _, e := range d.Evidence {
+			dup := false
+			for _, k := range known[i].Evidence {
+				dup = dup || k == e
+			}
+			if !dup {
+				known[i].Evidence = append(known[i].Evidence, e)
+			}
+		}
+	}
+	sort.Slice(known, func(i, j int) bool { return known[i].Name < known[j].Name })
+	return known
+}
+
+// rateProfileRequest is the request the profiler sends repeatedly.
+type rateProfileRequest struct {
+	method  string
+	url     string
+	headers http.Header
+	body    []byte
+}
+
+// StartRateProfile starts a job profiling the rate limiting of an endpoint's host: after one
+// baseline request, bursts of requests are sent at a rate doubling from the start rate up to
+// the maximum, and profiling stops at the first burst answered with 429, 503 or (unlike the
+// baseline) 403. The host's safe rate, a share (rate_profiler.safety_factor) of the highest
+// unthrottled rate, is stored and paces every later toolkit request to the host. WAFs are
+// recognized from the responses' headers, cookies and bodies.
+func StartRateProfile(targetID int64, req models.RateProfileRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	target, err := rateProfileTarget(targetID, req)
+	if err != nil {
+		return models.Job{}, err
+	}
+	conf := config.AppConfig.Profiler
+	start, max, perStep := req.StartRPS, req.MaxRPS, req.Requests
+	if start <= 0 {
+		start = conf.StartRPS
+	}
+	if max <= 0 {
+		max = conf.MaxRPS
+	}
+	if perStep <= 0 {
+		perStep = conf.RequestsPerStep
+	}
+	if start <= 0 || max < start || perStep <= 0 {
+		return models.Job{}, fmt.Errorf("invalid rates: start %.2f and max %.2f req/s with %d requests per step", start, max, perStep)
+	}
+	latest, err := LatestJob(models.JobTypeRateProfile, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a rate profile is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	var rates []float64
+	for rate := start; ; rate *= 2 {
+		if rate >= max {
+			rates = append(rates, max)
+			break
+		}
+		rates = append(rates, rate)
+	}
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	factor := conf.SafetyFactor
+	if factor <= 0 || factor > 1 {
+		factor = 0.8
+	}
+	// The profiler paces itself, so it bypasses the rate limiter. The program's max_rps still caps it.
+	p := &rateProfiler{
+		targetID: targetID,
+		target:   target,
+		rates:    rates,
+		perStep:  perStep,
+		factor:   factor,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: NewClientProfileTransport(&http.Transport{
+				TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+				MaxIdleConnsPerHost: 64,
+			}, targetID),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	host := normalizeLimiterHost(urlHostname(target.url))
+	return StartJob(models.JobTypeRateProfile, &targetID, fmt.Sprintf("Profiling the rate limits of %s...", host), func(ctx context.Context, job *JobHandle) error {
+		return p.run(ctx, job)
+	})
+}
+
+// rateProfileTarget resolves the request to send: a captured request of the target, or a GET of
+// the URL.
+func rateProfileTarget(targetID int64, req models.RateProfileRequest) (rateProfileRequest, error) {
+	if req.LogID != 0 {
+		entry, err := database.GetHTTPTrafficLogEntryByID(req.LogID)
+		if err != nil {
+			return rateProfileRequest{}, err
+		}
+		if entry.TargetID == nil || *entry.TargetID != targetID {
+			return rateProfileRequest{}, fmt.Errorf("invalid log entry %d: it does not belong to target %d", req.LogID, targetID)
+		}
+		headers := http.Header{}
+		for name, values := range HeadersFromJSON(entry.RequestHeaders.String) {
+			canonical := http.CanonicalHeaderKey(name)
+			if replaySkippedHeaders[canonical] || isToolkitHeader(canonical) || canonical == "Host" {
+				continue
+			}
+			headers[canonical] = values
+		}
+		return rateProfileRequest{method: entry.RequestMethod.String, url: entry.RequestURL.String, headers: headers, body: entry.RequestBody}, nil
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return rateProfileRequest{}, fmt.Errorf("invalid url %q: an http(s) URL or a log_id is required", req.URL)
+	}
+	return rateProfileRequest{method: http.MethodGet, url: u.String(), headers: http.Header{}}, nil
+}
+
+func urlHostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+type rateProfiler struct {
+	targetID int64
+	target   rateProfileRequest
+	rates    []float64
+	perStep  int
+	factor   float64
+	client   *http.Client
+
+	mu          sync.Mutex
+	wafs        []models.WAFDetection
+	blockLogged bool // The first blocked response was stored in the traffic log
+}
+
+// rateProbeResult is the outcome of one profiler request.
+type rateProbeResult struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+func (p *rateProfiler) run(ctx context.Context, job *JobHandle) error {
+	job.SetTotal(int64(1 + len(p.rates)*p.perStep))
+	profile := models.HostRateProfile{
+		Host:     normalizeLimiterHost(urlHostname(p.target.url)),
+		TargetID: &p.targetID,
+		Endpoint: p.target.url,
+		Method:   p.target.method,
+		WAFs:     []models.WAFDetection{},
+		Steps:    []models.RateBurstStep{},
+	}
+
+	baseline := p.send(ctx, true)
+	job.AddProgress(1, 1)
+	if baseline.err != nil {
+		return fmt.Errorf("baseline request: %w", baseline.err)
+	}
+	profile.BaselineStatus = baseline.status
+	profile.RequestsSent = 1
+	throttled := func(status int) bool {
+		return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable ||
+			(status == http.StatusForbidden && baseline.status != http.StatusForbidden)
+	}
+
+	lastClean := 0.0
+	for _, rate := range p.rates {
+		if ctx.Err() != nil {
+			break
+		}
+		step := models.RateBurstStep{RPS: rate, Statuses: map[int]int{}}
+		results := p.burst(ctx, job, rate, throttled)
+		throttleStatuses := map[int]int{}
+		for _, r := range results {
+			step.Requests++
+			step.Statuses[r.status]++
+			if throttled(r.status) {
+				step.Throttled++
+				throttleStatuses[r.status]++
+				if profile.RetryAfter == "" {
+					profile.RetryAfter = r.retryAfter
+				}
+			}
+		}
+		profile.Steps = append(profile.Steps, step)
+		profile.RequestsSent += step.Requests
+		profile.MaxTestedRPS = rate
+		if step.Throttled > 0 {
+			r, status := rate, 0
+			for s, n := range throttleStatuses {
+				if n > throttleStatuses[status] {
+					status = s
+				}
+			}
+			profile.ThrottleRPS, profile.ThrottleStatus = &r, &status
+			break
+		}
+		lastClean = rate
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	job.SetTotal(int64(profile.RequestsSent)) // Profiling stops at the first throttled burst
+
+	if profile.ThrottleRPS != nil {
+		safe := lastClean * p.factor
+		if lastClean == 0 {
+			safe = p.rates[0] / 2 * p.factor
+		}
+		safe = math.Round(safe*100) / 100
+		profile.SafeRPS = &safe
+	}
+	profile.WAFs = p.wafs
+	if profile.WAFs == nil {
+		profile.WAFs = []models.WAFDetection{}
+	}
+	now := time.Now()
+	profile.ProfiledAt = &now
+	if err := database.SaveHostRateProfile(profile); err != nil {
+		return err
+	}
+	ReloadHostSafeRates()
+
+	wafNames := make([]string, len(profile.WAFs))
+	for i, w := range profile.WAFs {
+		wafNames[i] = w.Name
+	}
+	waf := "none detected"
+	if len(wafNames) > 0 {
+		waf = strings.Join(wafNames, ", ")
+	}
+	if profile.ThrottleRPS != nil {
+		job.SetMessage("%s: throttled at %.2f req/s (HTTP %d); safe rate %.2f req/s. WAF: %s.", profile.Host, *profile.ThrottleRPS, *profile.ThrottleStatus, *profile.SafeRPS, waf)
+	} else {
+		job.SetMessage("%s: no throttling up to %.2f req/s. WAF: %s.", profile.Host, profile.MaxTestedRPS, waf)
+	}
+	logger.Info("Rate profile of %s: %d requests, max %.2f req/s, safe rate %v", profile.Host, profile.RequestsSent, profile.MaxTestedRPS, profile.SafeRPS)
+	return nil
+}
+
+// burst sends the step's requests at rate, each on its own goroutine so slow responses do not
+// lower the rate, and returns their outcomes.
+func (p *rateProfiler) burst(ctx context.Context, job *JobHandle, rate float64, throttled func(int) bool) []rateProbeResult {
+	interval := time.Duration(float64(time.Second) / rate)
+	results := make([]rateProbeResult, p.perStep)
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; i < p.perStep; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return results[:i]
+			case <-ticker.C:
+			}
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = p.send(ctx, false)
+			if results[i].err != nil {
+				job.RecordError(results[i].err)
+			}
+			if throttled(results[i].status) {
+				job.AddProgress(1, 0)
+			} else {
+				job.AddProgress(1, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// send sends the profiled request once and checks the response for WAF signatures. The baseline
+// response and the first throttled one are stored in the traffic log.
+func (p *rateProfiler) send(ctx context.Context, baseline bool) rateProbeResult {
+	var body io.Reader
+	if len(p.target.body) > 0 {
+		body = bytes.NewReader(p.target.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, p.target.method, p.target.url, body)
+	if err != nil {
+		return rateProbeResult{err: err}
+	}
+	req.Header = p.target.headers.Clone()
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return rateProbeResult{err: err}
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, rateProfilerMaxBody))
+	result := rateProbeResult{status: resp.StatusCode, retryAfter: resp.Header.Get("Retry-After")}
+
+	detected := detectWAFs(resp.Header, respBody)
+	p.mu.Lock()
+	p.wafs = mergeWAFs(p.wafs, detected)
+	blocked := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusForbidden
+	logIt := baseline || (blocked && !p.blockLogged)
+	if blocked {
+		p.blockLogged = true
+	}
+	p.mu.Unlock()
+	if logIt {
+		logProbe(p.targetID, req, resp, respBody, start, models.RateProfileLogSource)
+	}
+	return result
+}
+
+// GetHostRateProfiles lists the rate profiles of a target's hosts, or of every host when
+// targetID is 0.
+func GetHostRateProfiles(targetID int64) ([]models.HostRateProfile, error) {
+	if targetID != 0 {
+		if _, err := database.GetTargetByID(targetID); err != nil {
+			return nil, err
+		}
+	}
+	profiles, err := database.GetHostRateProfiles(targetID)
+	if profiles == nil {
+		profiles = []models.HostRateProfile{}
+	}
+	return profiles, err
+}
+
+// GetHostRateProfile returns the rate profile of a host.
+func GetHostRateProfile(host string) (models.HostRateProfile, error) {
+	return database.GetHostRateProfile(normalizeLimiterHost(host))
+}
+
+// SetHostSafeRate sets a host's safe rate by hand (0 clears it) and applies it right away.
+func SetHostSafeRate(host string, rps float64) (models.HostRateProfile, error) {
+	host = normalizeLimiterHost(host)
+	if host == "" {
+		return models.HostRateProfile{}, fmt.Errorf("invalid host: a host name is required")
+	}
+	if rps < 0 {
+		return models.HostRateProfile{}, fmt.Errorf("invalid safe rate %.2f: it cannot be negative", rps)
+	}
+	if err := database.SetHostSafeRate(host, rps); err != nil {
+		return models.HostRateProfile{}, err
+	}
+	ReloadHostSafeRates()
+	return database.GetHostRateProfile(host)
+}
+
+// DeleteHostRateProfile removes a host's rate profile, so its safe rate no longer applies.
+func DeleteHostRateProfile(host string) error {
+	if err := database.DeleteHostRateProfile(normalizeLimiterHost(host)); err != nil {
+		return err
+	}
+	ReloadHostSafeRates()
+	return nil
+}
@@ -0,0 +1,453 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+)
+
+// hostBucket is a token bucket plus an optional concurrency semaphore for a single host.
+type hostBucket struct {
+	tokens      float64
+	lastRefill  time.Time
+	blockedTill time.Time // Set when the host answers 429; no requests are released before this
+	backoff     time.Duration
+	inFlight    chan struct{}
+}
+
+// RateLimiter enforces per-host request rates, per-host and global concurrency caps,
+// and 429-aware backoff for toolkit-initiated traffic.
+type RateLimiter struct {
+	mu      sync.Mutex
+	conf    config.RateLimitConfig
+	hosts   map[string]*hostBucket
+	global  chan struct{}
+	waiting int64
+}
+
+// RateLimiterStats is a snapshot of the limiter state, exposed through the settings API.
+type RateLimiterStats struct {
+	Enabled      bool                       `json:"enabled"`
+	GlobalActive int                        `json:"global_active"`
+	Waiting      int64                      `json:"waiting"`
+	Hosts        map[string]HostLimiterStat `json:"hosts"`
+}
+
+// HostLimiterStat describes the throttling state of one host.
+type HostLimiterStat struct {
+	Active       int        `json:"active"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+	BackoffMs    int64      `json:"backoff_ms"`
+	SafeRPS      float64    `json:"safe_rps,omitempty"` // Safe rate from the host's rate profile
+}
+
+var (
+	globalRateLimiter   *RateLimiter
+	globalRateLimiterMu sync.Mutex
+
+	// Safe rates of the host rate profiles, loaded on first use.
+	hostSafeRatesMu     sync.RWMutex
+	hostSafeRates       map[string]float64
+	hostSafeRatesLoaded bool
+)
+
+// hostSafeRate returns the safe rate the rate profiler (or the user) stored for host.
+func hostSafeRate(host string) (float64, bool) {
+	hostSafeRatesMu.RLock()
+	loaded := hostSafeRatesLoaded
+	rps, ok := hostSafeRates[host]
+	hostSafeRatesMu.RUnlock()
+	if loaded {
+		return rps, ok
+	}
+	ReloadHostSafeRates()
+	hostSafeRatesMu.RLock()
+	defer hostSafeRatesMu.RUnlock()
+	rps, ok = hostSafeRates[host]
+	return rps, ok
+}
+
+// ReloadHostSafeRates reloads the per-host safe rates from the database after a rate profile
+// changed.
+func ReloadHostSafeRates() {
+	if database.DB == nil {
+		return
+	}
+	rates, err := database.GetHostSafeRates()
+	if err != nil {
+		logger.Error("RateLimiter: Could not load host safe rates: %v", err)
+		rates = map[string]float64{}
+	}
+	hostSafeRatesMu.Lock()
+	hostSafeRates = rates
+	hostSafeRatesLoaded = true
+	hostSafeRatesMu.Unlock()
+}
+
+// NewRateLimiter creates a limiter from the given configuration.
+func NewRateLimiter(conf config.RateLimitConfig) *RateLimiter {
+	rl := &RateLimiter{
+		conf:  conf,
+		hosts: make(map[string]*hostBucket),
+	}
+	if conf.MaxConcurrency > 0 {
+		rl.global = make(chan struct{}, conf.MaxConcurrency)
+	}
+	return rl
+}
+
+// GetRateLimiter returns the shared limiter, creating it from config.AppConfig on first use.
+func GetRateLimiter() *RateLimiter {
+	globalRateLimiterMu.Lock()
+	defer globalRateLimiterMu.Unlock()
+	if globalRateLimiter == nil {
+		globalRateLimiter = NewRateLimiter(config.AppConfig.RateLimit)
+	}
+	return globalRateLimiter
+}
+
+// ReconfigureRateLimiter replaces the shared limiter after the rate limit settings changed.
+// Requests already holding a slot in the old limiter finish normally.
+func ReconfigureRateLimiter(conf config.RateLimitConfig) {
+	globalRateLimiterMu.Lock()
+	globalRateLimiter = NewRateLimiter(conf)
+	globalRateLimiterMu.Unlock()
+	logger.Info("Rate limiter reconfigured: enabled=%t, %.2f req/s per host, burst %d, max concurrency %d, per-host concurrency %d",
+		conf.Enabled, conf.RequestsPerSecond, conf.Burst, conf.MaxConcurrency, conf.PerHostConcurrency)
+}
+
+// Enabled reports whether throttling is active.
+func (rl *RateLimiter) Enabled() bool {
+	return rl != nil && rl.conf.Enabled
+}
+
+func (rl *RateLimiter) bucketFor(host string) *hostBucket {
+	b, ok := rl.hosts[host]
+	if !ok {
+		b = &hostBucket{tokens: float64(rl.burst()), lastRefill: time.Now()}
+		if rl.conf.PerHostConcurrency > 0 {
+			b.inFlight = make(chan struct{}, rl.conf.PerHostConcurrency)
+		}
+		rl.hosts[host] = b
+	}
+	return b
+}
+
+func (rl *RateLimiter) burst() int {
+	if rl.conf.Burst < 1 {
+		return 1
+	}
+	return rl.conf.Burst
+}
+
+// hostRate returns the request rate and burst for host: the configured ones, lowered to the
+// host's safe rate with a burst of 1 when its rate profile has one. Safe rates apply even when
+// throttling is disabled.
+func (rl *RateLimiter) hostRate(host string) (float64, int) {
+	var rate float64
+	if rl.Enabled() {
+		rate = rl.conf.RequestsPerSecond
+	}
+	if safe, ok := hostSafeRate(host); ok && (rate <= 0 || safe < rate) {
+		return safe, 1
+	}
+	return rate, rl.burst()
+}
+
+// reserve takes a token for host if one is available, otherwise it returns how long to wait.
+func (rl *RateLimiter) reserve(host string) time.Duration {
+	rate, burst := rl.hostRate(host)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b := rl.bucketFor(host)
+	now := time.Now()
+	if now.Before(b.blockedTill) {
+		return b.blockedTill.Sub(now)
+	}
+	if rate <= 0 {
+		return 0
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens += elapsed * rate
+	if max := float64(burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.lastRefill = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	missing := 1 - b.tokens
+	return time.Duration(missing / rate * float64(time.Second))
+}
+
+// Wait blocks until a request to host may be sent. The returned release function must be
+// called once the response has been received (it frees the concurrency slots). When throttling
+// is disabled, only hosts with a safe rate are paced, without concurrency caps.
+func (rl *RateLimiter) Wait(ctx context.Context, host string) (func(), error) {
+	noop := func() {}
+	host = normalizeLimiterHost(host)
+	enabled := rl.Enabled()
+	if _, safe := hostSafeRate(host); !enabled && !safe {
+		return noop, nil
+	}
+
+	rl.mu.Lock()
+	rl.waiting++
+	rl.mu.Unlock()
+	defer func() {
+		rl.mu.Lock()
+		rl.waiting--
+		rl.mu.Unlock()
+	}()
+
+	for {
+		delay := rl.reserve(host)
+		if delay <= 0 {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return noop, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if !enabled {
+		return noop, nil
+	}
+	if rl.global != nil {
+		select {
+		case rl.global <- struct{}{}:
+		case <-ctx.Done():
+			return noop, ctx.Err()
+		}
+	}
+
+	rl.mu.Lock()
+	hostSem := rl.bucketFor(host).inFlight
+	rl.mu.Unlock()
+	if hostSem != nil {
+		select {
+		case hostSem <- struct{}{}:
+		case <-ctx.Done():
+			if rl.global != nil {
+				<-rl.global
+			}
+			return noop, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if hostSem != nil {
+				<-hostSem
+			}
+			if rl.global != nil {
+				<-rl.global
+			}
+		})
+	}, nil
+}
+
+// ObserveResponse records the outcome of a request to host. A 429 (or 503 with Retry-After)
+// pauses the host for the Retry-After period or an exponentially growing backoff, and the
+// chosen delay is returned. Any other status resets the backoff.
+func (rl *RateLimiter) ObserveResponse(host string, statusCode int, retryAfter string) time.Duration {
+	if !rl.Enabled() {
+		return 0
+	}
+	host = normalizeLimiterHost(host)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.bucketFor(host)
+
+	throttled := statusCode == http.StatusTooManyRequests || (statusCode == http.StatusServiceUnavailable && retryAfter != "")
+	if !throttled {
+		b.backoff = 0
+		return 0
+	}
+
+	delay, ok := parseRetryAfter(retryAfter)
+	if !ok {
+		initial := time.Duration(rl.conf.BackoffInitialMs) * time.Millisecond
+		if initial <= 0 {
+			initial = time.Second
+		}
+		if b.backoff == 0 {
+			b.backoff = initial
+		} else {
+			b.backoff *= 2
+		}
+		delay = b.backoff
+	}
+	if maxDelay := time.Duration(rl.conf.BackoffMaxMs) * time.Millisecond; maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	b.blockedTill = time.Now().Add(delay)
+	b.tokens = 0
+	logger.Warn("RateLimiter: Host %s returned %d, backing off for %s", host, statusCode, delay)
+	return delay
+}
+
+// Stats returns a snapshot of the limiter state.
+func (rl *RateLimiter) Stats() RateLimiterStats {
+	stats := RateLimiterStats{Hosts: make(map[string]HostLimiterStat)}
+	if rl == nil {
+		return stats
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	stats.Enabled = rl.conf.Enabled
+	stats.Waiting = rl.waiting
+	if rl.global != nil {
+		stats.GlobalActive = len(rl.global)
+	}
+	now := time.Now()
+	for host, b := range rl.hosts {
+		hs := HostLimiterStat{BackoffMs: b.backoff.Milliseconds()}
+		hs.SafeRPS, _ = hostSafeRate(host)
+		if b.inFlight != nil {
+			hs.Active = len(b.inFlight)
+		}
+		if b.blockedTill.After(now) {
+			t := b.blockedTill
+			hs.BlockedUntil = &t
+		}
+		stats.Hosts[host] = hs
+	}
+	return stats
+}
+
+func normalizeLimiterHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, found := strings.Cut(host, ":"); found && !strings.Contains(h, "[") {
+		return h
+	}
+	return host
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// RateLimitedTransport wraps an http.RoundTripper with the shared RateLimiter.
+// Requests answered with 429 are retried (up to RateLimitConfig.MaxRetries) when
+// their body can be replayed. When the response cache is enabled, a request logged
+// within its window is answered with the logged response instead of being sent.
+// A request holds its concurrency slots until its response body is read to the end
+// or closed.
+type RateLimitedTransport struct {
+	Base    http.RoundTripper
+	NoCache bool // Always send requests, e.g. those a user sends by hand
+}
+
+// NewRateLimitedTransport wraps base (http.DefaultTransport when nil).
+func NewRateLimitedTransport(base http.RoundTripper) *RateLimitedTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitedTransport{Base: base}
+}
+
+// releasingBody is a response body releasing the limiter slots of its request once it is read to
+// the end, fails or is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests bound for the proxy are answered from the cache there, so that the answer is logged.
+	if !t.NoCache && req.Header.Get("X-Toolkit-Initiated") != "true" {
+		if resp, logID := cachedResponse(requestCacheKey(req), req); resp != nil {
+			resp.Header.Set(responseCacheLogIDHeader, strconv.FormatInt(logID, 10))
+			return resp, nil
+		}
+	}
+
+	rl := GetRateLimiter()
+	host := req.URL.Hostname()
+	if _, safe := hostSafeRate(normalizeLimiterHost(host)); !rl.Enabled() && !safe {
+		return t.Base.RoundTrip(req)
+	}
+
+	maxRetries := rl.conf.MaxRetries
+	for attempt := 0; ; attempt++ {
+		release, err := rl.Wait(req.Context(), host)
+		if err != nil {
+			return nil, fmt.Errorf("rate limiter wait for %s: %w", host, err)
+		}
+		resp, err := t.Base.RoundTrip(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		// The slots are held until the body is read, so the concurrency caps cover the download.
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+		delay := rl.ObserveResponse(host, resp.StatusCode, resp.Header.Get("Retry-After"))
+		if delay == 0 || attempt >= maxRetries {
+			return resp, nil
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil // Body cannot be replayed, hand the 429 back to the caller
+		}
+
+		resp.Body.Close()
+		logger.Info("RateLimitedTransport: Retrying %s %s after 429 (attempt %d/%d)", req.Method, req.URL.String(), attempt+1, maxRetries)
+		if req.GetBody != nil {
+			body, errBody := req.GetBody()
+			if errBody != nil {
+				return nil, fmt.Errorf("could not rewind request body for retry: %w", errBody)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"toolkit/config"
+)
+
+// withRateLimiter makes the shared limiter one with the given concurrency caps and no rate to
+// speak of.
+func withRateLimiter(t *testing.T, maxConcurrency, perHost int) *RateLimiter {
+	t.Helper()
+	ReconfigureRateLimiter(config.RateLimitConfig{Enabled: true, RequestsPerSecond: 1000, Burst: 1000,
+		MaxConcurrency: maxConcurrency, PerHostConcurrency: perHost})
+	t.Cleanup(func() {
+		globalRateLimiterMu.Lock()
+		globalRateLimiter = nil
+		globalRateLimiterMu.Unlock()
+	})
+	return GetRateLimiter()
+}
+
+func TestRateLimitedTransportHoldsSlotsUntilBodyIsDone(t *testing.T) {
+	rl := withRateLimiter(t, 2, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 64*1024))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &RateLimitedTransport{Base: http.DefaultTransport, NoCache: true}}
+
+	active := func() int { return rl.Stats().GlobalActive }
+	read, err := client.Get(server.URL + "/read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := client.Get(server.URL + "/closed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := active(); n != 2 {
+		t.Fatalf("%d slots held while two bodies are unread, want 2", n)
+	}
+
+	if _, err := io.Copy(io.Discard, read.Body); err != nil {
+		t.Fatal(err)
+	}
+	if n := active(); n != 1 {
+		t.Errorf("%d slots held after a body was read to the end, want 1", n)
+	}
+	closed.Body.Close()
+	read.Body.Close() // Releasing twice must not free a slot of another request
+	if n := active(); n != 0 {
+		t.Errorf("%d slots held after both bodies are done, want 0", n)
+	}
+
+	server.Close()
+	if _, err := client.Get(server.URL + "/down"); err == nil {
+		t.Fatal("a request to a closed server succeeded")
+	}
+	if n := active(); n != 0 {
+		t.Errorf("%d slots held after a failed request, want 0", n)
+	}
+}
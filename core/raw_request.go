@@ -0,0 +1,253 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"toolkit/logger"
+)
+
+const (
+	defaultRawRequestTimeout     = 30 * time.Second
+	defaultRawRequestIdleTimeout = 2 * time.Second
+	defaultRawMaxResponseBytes   = 10 << 20
+	// rawResponseSettleTime is how long the connection is still read once everything received
+	// parses as complete responses, to catch responses to smuggled or pipelined requests.
+	rawResponseSettleTime = 250 * time.Millisecond
+)
+
+// RawRequestOptions controls how SendRawHTTPRequest connects and when it stops reading.
+type RawRequestOptions struct {
+	Address            string // host:port to connect to
+	TLS                bool
+	ServerName         string        // SNI, defaults to the host of Address
+	InsecureSkipVerify bool          // Do not verify the server certificate
+	Timeout            time.Duration // Overall deadline, default 30s
+	IdleTimeout        time.Duration // Stop once the server is silent this long mid-response, default 2s
+	MaxResponseBytes   int64         // Stop reading after this many bytes, default 10 MiB
+}
+
+// RawHTTPResponse is one response parsed from the bytes a server sent back.
+type RawHTTPResponse struct {
+	StatusCode int
+	Status     string
+	Proto      string
+	Header     http.Header
+	Body       []byte // De-chunked but not decompressed
+}
+
+// RawHTTPExchange is the outcome of SendRawHTTPRequest.
+type RawHTTPExchange struct {
+	BytesSent        int
+	Raw              []byte            // Every byte the server sent, as received
+	Responses        []RawHTTPResponse // Responses parsed from Raw, in order
+	ParseError       string            // Why Raw could not be parsed (completely) as HTTP responses
+	ConnectionClosed bool              // The server closed the connection
+	Truncated        bool              // MaxResponseBytes was reached
+	Duration         time.Duration
+}
+
+// RawRequestMethod returns the method of a raw request's request line, as written.
+func RawRequestMethod(raw []byte) string {
+	line, _, _ := bytes.Cut(raw, []byte("\n"))
+	method, _, _ := strings.Cut(strings.TrimSpace(string(line)), " ")
+	return method
+}
+
+// SendRawHTTPRequest writes raw to a new connection byte for byte, without the normalization
+// net/http applies, so malformed requests (custom verbs, duplicate Content-Length, obsolete line
+// folding, bare LF line endings) reach the server as written. The response is read until the
+// server closes the connection, goes idle or the deadline passes; everything received is returned
+// together with the responses it parses into.
+func SendRawHTTPRequest(ctx context.Context, raw []byte, opts RawRequestOptions) (*RawHTTPExchange, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("raw request is empty")
+	}
+	host, _, err := net.SplitHostPort(opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address '%s': %w", opts.Address, err)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRawRequestTimeout
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultRawRequestIdleTimeout
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = defaultRawMaxResponseBytes
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	rl := GetRateLimiter()
+	release, err := rl.Wait(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("rate limiter wait for %s: %w", host, err)
+	}
+	defer release()
+
+	start := time.Now()
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if opts.TLS {
+		serverName := opts.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+			NextProtos:         []string{"http/1.1"}, // Raw requests are HTTP/1.x; never negotiate h2
+		}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", opts.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", opts.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", opts.Address, err)
+	}
+	defer conn.Close()
+	// Unblock reads and writes as soon as the caller gives up.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	exchange := &RawHTTPExchange{}
+	conn.SetWriteDeadline(deadline)
+	exchange.BytesSent, err = conn.Write(raw)
+	if err != nil {
+		return nil, fmt.Errorf("writing request to %s (%d of %d bytes sent): %w", opts.Address, exchange.BytesSent, len(raw), err)
+	}
+
+	method := RawRequestMethod(raw)
+	var received bytes.Buffer
+	buf := make([]byte, 32*1024)
+	conn.SetReadDeadline(deadline)
+	for {
+		n, errRead := conn.Read(buf)
+		if n > 0 {
+			keep := min64(int64(n), opts.MaxResponseBytes-int64(received.Len()))
+			received.Write(buf[:keep])
+			if int64(received.Len()) >= opts.MaxResponseBytes {
+				exchange.Truncated = true
+				break
+			}
+			// Wait longer for the rest of a partial response than for further responses.
+			wait := opts.IdleTimeout
+			if _, complete, _ := parseRawHTTPResponses(received.Bytes(), method); complete {
+				wait = rawResponseSettleTime
+			}
+			readDeadline := time.Now().Add(wait)
+			if readDeadline.After(deadline) {
+				readDeadline = deadline
+			}
+			conn.SetReadDeadline(readDeadline)
+		}
+		if errRead == nil {
+			continue
+		}
+		if errors.Is(errRead, io.EOF) {
+			exchange.ConnectionClosed = true
+		} else if received.Len() == 0 {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("no response from %s within %s: %w", opts.Address, opts.Timeout, ctx.Err())
+			}
+			return nil, fmt.Errorf("reading response from %s: %w", opts.Address, errRead)
+		} else if ne, ok := errRead.(net.Error); !ok || !ne.Timeout() {
+			logger.Debug("SendRawHTTPRequest: Read from %s ended with %v after %d bytes", opts.Address, errRead, received.Len())
+		}
+		break
+	}
+	exchange.Duration = time.Since(start)
+	exchange.Raw = received.Bytes()
+
+	responses, complete, errParse := parseRawHTTPResponses(exchange.Raw, method)
+	exchange.Responses = responses
+	if errParse != nil {
+		exchange.ParseError = errParse.Error()
+	} else if !complete && !exchange.ConnectionClosed {
+		exchange.ParseError = "the last response is incomplete"
+	}
+	if len(responses) > 0 {
+		rl.ObserveResponse(host, responses[0].StatusCode, responses[0].Header.Get("Retry-After"))
+	}
+	return exchange, nil
+}
+
+// parseRawHTTPResponses parses data as a sequence of HTTP responses to a request with the given
+// method. complete reports whether data ends exactly after the last response; a response whose
+// body is delimited by the connection closing is never complete.
+func parseRawHTTPResponses(data []byte, method string) ([]RawHTTPResponse, bool, error) {
+	var responses []RawHTTPResponse
+	br := bufio.NewReader(bytes.NewReader(data))
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return responses, len(responses) > 0, nil
+		}
+		resp, err := http.ReadResponse(br, &http.Request{Method: method})
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				return responses, false, nil
+			}
+			return responses, false, fmt.Errorf("parsing response %d: %w", len(responses)+1, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		responses = append(responses, RawHTTPResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Proto:      resp.Proto,
+			Header:     resp.Header,
+			Body:       body,
+		})
+		if err != nil {
+			return responses, false, nil
+		}
+		if resp.ContentLength < 0 && len(resp.TransferEncoding) == 0 && bodyAllowedForRawResponse(method, resp.StatusCode) {
+			return responses, false, nil // Delimited by the connection closing
+		}
+	}
+}
+
+func bodyAllowedForRawResponse(method string, status int) bool {
+	if strings.EqualFold(method, http.MethodHead) {
+		return false
+	}
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// SplitRawHTTPRequest splits a raw request into its request line parts, headers and body without
+// rejecting anything, for logging requests that ParseRawHTTPRequest would refuse. Header lines
+// without a colon are skipped; duplicate headers are all kept.
+func SplitRawHTTPRequest(raw []byte) (method, target string, headers http.Header, body []byte) {
+	headers = http.Header{}
+	head := raw
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		head, body = raw[:i], raw[i+4:]
+	} else if i := bytes.Index(raw, []byte("\n\n")); i >= 0 {
+		head, body = raw[:i], raw[i+2:]
+	}
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	if parts := strings.Fields(lines[0]); len(parts) > 0 {
+		method = parts[0]
+		if len(parts) > 1 {
+			target = parts[1]
+		}
+	}
+	for _, line := range lines[1:] {
+		name, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(name)] = append(headers[strings.TrimSpace(name)], strings.TrimSpace(value))
+	}
+	return method, target, headers, body
+}
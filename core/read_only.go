@@ -0,0 +1,35 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+	"toolkit/config"
+)
+
+// readOnlyPostRoutes are POST endpoints that change nothing: they only compute a result from the
+// request body.
+var readOnlyPostRoutes = []string{"/auth-tokens/decode", "/notes/render", "/proxy/exclusions/test"}
+
+// ReadOnly reports whether the toolkit runs in read-only mode (server.read_only or --read-only):
+// the API refuses every request that would change data and the proxy forwards traffic without
+// logging it.
+func ReadOnly() bool {
+	return config.AppConfig.Server.ReadOnly
+}
+
+// ReadOnlyAllows reports whether an API request may be served in read-only mode. path is the path
+// below the API prefix.
+func ReadOnlyAllows(method, path string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		path = strings.TrimSuffix(path, "/")
+		for _, route := range readOnlyPostRoutes {
+			if path == route {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,511 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// redactionOverrides caches the per-target overrides read on the proxy's hot path. Entries hold
+// nil for targets without an override.
+var redactionOverrides sync.Map // map[int64]*models.TargetRedactionOverride
+
+// GlobalRedactionRules returns the redaction rules of the config file.
+func GlobalRedactionRules() models.RedactionRules {
+	conf := config.AppConfig.Redaction
+	return models.RedactionRules{
+		Enabled:     conf.Enabled,
+		ApplyAt:     normalizeRedactionApplyAt(conf.ApplyAt),
+		Replacement: redactionReplacement(conf.Replacement),
+		Headers:     append([]string{}, conf.Headers...),
+		JSONPaths:   append([]string{}, conf.JSONPaths...),
+		Parameters:  append([]string{}, conf.Parameters...),
+	}
+}
+
+func normalizeRedactionApplyAt(applyAt string) string {
+	switch applyAt = strings.ToLower(strings.TrimSpace(applyAt)); applyAt {
+	case models.RedactionApplyStorage, models.RedactionApplyBoth:
+		return applyAt
+	default:
+		return models.RedactionApplyExport
+	}
+}
+
+func redactionReplacement(replacement string) string {
+	if replacement == "" {
+		return "[REDACTED]"
+	}
+	return replacement
+}
+
+// ValidateRedactionOverride checks the apply_at value and JSON paths of an override.
+func ValidateRedactionOverride(o models.TargetRedactionOverride) error {
+	switch o.ApplyAt {
+	case "", models.RedactionApplyStorage, models.RedactionApplyExport, models.RedactionApplyBoth:
+	default:
+		return fmt.Errorf("invalid apply_at '%s' (storage, export or both)", o.ApplyAt)
+	}
+	for _, p := range o.JSONPaths {
+		if _, err := parseJSONPath(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveTargetRedactionOverride validates and stores the redaction override of a target.
+func SaveTargetRedactionOverride(o models.TargetRedactionOverride) error {
+	if _, err := database.GetTargetByID(o.TargetID); err != nil {
+		return err
+	}
+	if err := ValidateRedactionOverride(o); err != nil {
+		return err
+	}
+	if err := database.SaveTargetRedactionOverride(o); err != nil {
+		return err
+	}
+	redactionOverrides.Delete(o.TargetID)
+	return nil
+}
+
+// DeleteTargetRedactionOverride removes the redaction override of a target.
+func DeleteTargetRedactionOverride(targetID int64) error {
+	if err := database.DeleteTargetRedactionOverride(targetID); err != nil {
+		return err
+	}
+	redactionOverrides.Delete(targetID)
+	return nil
+}
+
+// GetTargetRedactionView returns the global rules, the override and the effective rules of a target.
+func GetTargetRedactionView(targetID int64) (models.TargetRedactionView, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.TargetRedactionView{}, err
+	}
+	override, err := database.GetTargetRedactionOverride(targetID)
+	if err != nil {
+		return models.TargetRedactionView{}, err
+	}
+	global := GlobalRedactionRules()
+	return models.TargetRedactionView{Global: global, Override: override, Effective: mergeRedactionRules(global, override)}, nil
+}
+
+// EffectiveRedactionRules returns the rules that apply to traffic of a target (the global rules
+// when targetID is nil).
+func EffectiveRedactionRules(targetID *int64) models.RedactionRules {
+	global := GlobalRedactionRules()
+	if targetID == nil {
+		return global
+	}
+	var override *models.TargetRedactionOverride
+	if cached, ok := redactionOverrides.Load(*targetID); ok {
+		override = cached.(*models.TargetRedactionOverride)
+	} else {
+		loaded, err := database.GetTargetRedactionOverride(*targetID)
+		if err != nil {
+			logger.Error("Redaction: %v", err)
+			return global
+		}
+		redactionOverrides.Store(*targetID, loaded)
+		override = loaded
+	}
+	return mergeRedactionRules(global, override)
+}
+
+func mergeRedactionRules(global models.RedactionRules, o *models.TargetRedactionOverride) models.RedactionRules {
+	if o == nil {
+		return global
+	}
+	rules := global
+	if o.Enabled != nil {
+		rules.Enabled = *o.Enabled
+	}
+	if o.ApplyAt != "" {
+		rules.ApplyAt = o.ApplyAt
+	}
+	if o.Replace {
+		rules.Headers, rules.JSONPaths, rules.Parameters = nil, nil, nil
+	}
+	rules.Headers = appendUniqueFold(rules.Headers, o.Headers...)
+	rules.JSONPaths = appendUniqueFold(rules.JSONPaths, o.JSONPaths...)
+	rules.Parameters = appendUniqueFold(rules.Parameters, o.Parameters...)
+	return rules
+}
+
+func appendUniqueFold(list []string, values ...string) []string {
+	out := append([]string{}, list...)
+next:
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		for _, existing := range out {
+			if strings.EqualFold(existing, v) {
+				continue next
+			}
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactForStorage masks credentials in a log entry about to be saved, when the effective rules of
+// its target are applied at storage. It reports whether anything changed.
+func RedactForStorage(entry *models.HTTPTrafficLog) bool {
+	rules := EffectiveRedactionRules(entry.TargetID)
+	if !rules.Enabled || rules.ApplyAt == models.RedactionApplyExport {
+		return false
+	}
+	return RedactTrafficLog(entry, rules)
+}
+
+// RedactRequestForExport masks credentials in a request about to be exported as curl/raw, when the
+// effective rules of its target are applied at export.
+func RedactRequestForExport(targetID *int64, requestURL string, headers http.Header, body []byte) (string, http.Header, []byte) {
+	rules := EffectiveRedactionRules(targetID)
+	if !rules.Enabled || rules.ApplyAt == models.RedactionApplyStorage {
+		return requestURL, headers, body
+	}
+	redacted := headers.Clone()
+	if redacted == nil {
+		redacted = http.Header{}
+	}
+	redactHeaders(redacted, rules)
+	newBody, _ := redactBody(body, redacted.Get("Content-Type"), rules)
+	return redactURLParameters(requestURL, rules), redacted, newBody
+}
+
+// RedactTrafficLog masks credentials in the URL, headers and bodies of a log entry. Compressed
+// response bodies that contain a redacted value are stored decoded, without Content-Encoding. It
+// reports whether anything changed.
+func RedactTrafficLog(entry *models.HTTPTrafficLog, rules models.RedactionRules) bool {
+	changed := false
+	if u := redactURLParameters(entry.RequestURL.String, rules); u != entry.RequestURL.String {
+		entry.RequestURL.String, changed = u, true
+	}
+	if u := redactURLParameters(entry.RequestFullURLWithFragment.String, rules); u != entry.RequestFullURLWithFragment.String {
+		entry.RequestFullURLWithFragment.String, changed = u, true
+	}
+
+	reqHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+	if redactHeaders(reqHeaders, rules) {
+		entry.RequestHeaders = models.NullString(headersToJSON(reqHeaders))
+		changed = true
+	}
+	if body, ok := redactBody(entry.RequestBody, reqHeaders.Get("Content-Type"), rules); ok {
+		entry.RequestBody, changed = body, true
+	}
+
+	respHeaders := HeadersFromJSON(entry.ResponseHeaders.String)
+	respHeadersChanged := redactHeaders(respHeaders, rules)
+	if len(entry.ResponseBody) > 0 {
+		encoding := respHeaders.Get("Content-Encoding")
+		decoded, err := DecodeContentEncoding(entry.ResponseBody, encoding)
+		if err == nil {
+			if body, ok := redactBody(decoded, respHeaders.Get("Content-Type"), rules); ok {
+				entry.ResponseBody = body
+				if !entry.ResponseBodyTruncated {
+					entry.ResponseBodySize = int64(len(body))
+				}
+				if encoding != "" {
+					respHeaders.Del("Content-Encoding")
+					respHeadersChanged = true
+				}
+				changed = true
+			}
+		}
+	}
+	if respHeadersChanged {
+		entry.ResponseHeaders = models.NullString(headersToJSON(respHeaders))
+		changed = true
+	}
+	return changed
+}
+
+func headersToJSON(headers http.Header) string {
+	data, _ := json.Marshal(map[string][]string(headers))
+	return string(data)
+}
+
+// redactHeaders replaces the values of the rule headers.
+func redactHeaders(headers http.Header, rules models.RedactionRules) bool {
+	changed := false
+	for name, values := range headers {
+		if !containsFold(rules.Headers, name) {
+			continue
+		}
+		for i := range values {
+			if values[i] != rules.Replacement {
+				values[i] = rules.Replacement
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// redactURLParameters replaces the values of rule parameters in a URL's query string, keeping the
+// order and encoding of the other parameters.
+func redactURLParameters(rawURL string, rules models.RedactionRules) string {
+	if len(rules.Parameters) == 0 {
+		return rawURL
+	}
+	base, query, ok := strings.Cut(rawURL, "?")
+	if !ok {
+		return rawURL
+	}
+	query, fragment, hasFragment := strings.Cut(query, "#")
+	redacted, changed := redactFormEncoded(query, rules)
+	if !changed {
+		return rawURL
+	}
+	out := base + "?" + redacted
+	if hasFragment {
+		out += "#" + fragment
+	}
+	return out
+}
+
+// redactFormEncoded replaces the values of rule parameters in a&b=c encoded data.
+func redactFormEncoded(data string, rules models.RedactionRules) (string, bool) {
+	pairs := strings.Split(data, "&")
+	changed := false
+	for i, pair := range pairs {
+		key, value, hasValue := strings.Cut(pair, "=")
+		if !hasValue {
+			continue
+		}
+		name := key
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			name = unescaped
+		}
+		if containsFold(rules.Parameters, name) && value != rules.Replacement {
+			pairs[i] = key + "=" + rules.Replacement
+			changed = true
+		}
+	}
+	return strings.Join(pairs, "&"), changed
+}
+
+// redactBody masks rule JSON paths in JSON bodies and rule parameters in form bodies.
+func redactBody(body []byte, contentType string, rules models.RedactionRules) ([]byte, bool) {
+	if len(body) == 0 {
+		return body, false
+	}
+	ct := strings.ToLower(contentType)
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(rules.JSONPaths) > 0 && (strings.Contains(ct, "json") || len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')):
+		return redactJSONBody(body, rules)
+	case len(rules.Parameters) > 0 && strings.Contains(ct, "x-www-form-urlencoded"):
+		redacted, changed := redactFormEncoded(string(body), rules)
+		return []byte(redacted), changed
+	}
+	return body, false
+}
+
+func redactJSONBody(body []byte, rules models.RedactionRules) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return body, false
+	}
+	changed := false
+	for _, p := range rules.JSONPaths {
+		path, err := parseJSONPath(p)
+		if err != nil {
+			logger.Debug("Redaction: Skipping JSON path: %v", err)
+			continue
+		}
+		if redactJSONPath(doc, path, rules.Replacement) {
+			changed = true
+		}
+	}
+	if !changed {
+		return body, false
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return body, false
+	}
+	return bytes.TrimRight(out.Bytes(), "\n"), true
+}
+
+// jsonPathSegment is one step of a JSON path: a key (or * for any key), an array index (-1 for
+// [*]), optionally matched at any depth (..).
+type jsonPathSegment struct {
+	key       string
+	index     int
+	isIndex   bool
+	recursive bool
+}
+
+// parseJSONPath parses the JSON path subset used by redaction rules: $.a.b, $.a[0], $.a[*].b,
+// $['a-b'] and $..name.
+func parseJSONPath(p string) ([]jsonPathSegment, error) {
+	invalid := fmt.Errorf("invalid JSON path '%s'", p)
+	if !strings.HasPrefix(p, "$") || len(p) < 2 {
+		return nil, invalid
+	}
+	var segments []jsonPathSegment
+	rest := p[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."), strings.HasPrefix(rest, "."):
+			recursive := strings.HasPrefix(rest, "..")
+			rest = strings.TrimLeft(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, invalid
+			}
+			segments = append(segments, jsonPathSegment{key: rest[:end], recursive: recursive})
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, invalid
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				segments = append(segments, jsonPathSegment{isIndex: true, index: -1})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, invalid
+				}
+				segments = append(segments, jsonPathSegment{isIndex: true, index: n})
+			}
+		default:
+			return nil, invalid
+		}
+	}
+	return segments, nil
+}
+
+// redactJSONPath replaces the values at path in node. Keys match case-insensitively.
+func redactJSONPath(node interface{}, path []jsonPathSegment, replacement string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	seg, rest := path[0], path[1:]
+	changed := false
+	apply := func(get func() interface{}, set func(interface{})) {
+		if len(rest) == 0 {
+			if s, ok := get().(string); !ok || s != replacement {
+				set(replacement)
+				changed = true
+			}
+			return
+		}
+		if redactJSONPath(get(), rest, replacement) {
+			changed = true
+		}
+	}
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			key := k
+			if !seg.isIndex && (seg.key == "*" || strings.EqualFold(seg.key, key)) {
+				apply(func() interface{} { return v[key] }, func(x interface{}) { v[key] = x })
+			}
+			if seg.recursive && redactJSONPath(v[key], path, replacement) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			idx := i
+			if seg.isIndex && (seg.index < 0 || seg.index == idx) {
+				apply(func() interface{} { return v[idx] }, func(x interface{}) { v[idx] = x })
+			}
+			if seg.recursive && redactJSONPath(v[idx], path, replacement) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// StartTrafficRedaction starts a job applying a target's effective redaction rules to the traffic
+// already stored for it, whatever their apply_at setting.
+func StartTrafficRedaction(targetID int64) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	rules := EffectiveRedactionRules(&targetID)
+	if len(rules.Headers)+len(rules.JSONPaths)+len(rules.Parameters) == 0 {
+		return models.Job{}, fmt.Errorf("no redaction rules configured for target %d", targetID)
+	}
+	latest, err := LatestJob(models.JobTypeRedaction, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a redaction job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+	return StartJob(models.JobTypeRedaction, &targetID, "Redacting stored traffic...", func(ctx context.Context, job *JobHandle) error {
+		const batchSize = 200
+		var afterID int64
+		redacted := 0
+		for ctx.Err() == nil {
+			entries, err := database.ListTrafficLogsForRedaction(targetID, afterID, batchSize)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				break
+			}
+			for _, e := range entries {
+				afterID = e.ID
+				if !RedactTrafficLog(&e, rules) {
+					job.AddProgress(1, 0)
+					continue
+				}
+				if err := database.UpdateRedactedTrafficLog(e); err != nil {
+					job.RecordError(err)
+					job.AddProgress(1, 0)
+					continue
+				}
+				redacted++
+				job.AddProgress(1, 1)
+			}
+			job.SetMessage("Redacted %d log entries so far...", redacted)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		snap := job.Snapshot()
+		job.SetMessage("Redacted %d of %d log entries (%d errors).", redacted, snap.ItemsProcessed, snap.ErrorCount)
+		return nil
+	})
+}
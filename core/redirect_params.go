@@ -0,0 +1,536 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// redirectParamNames are parameter names, normalized by normalizeParamName, that usually hold
+// a URL the user is sent to.
+var redirectParamNames = map[string]bool{
+	"next": true, "url": true, "uri": true, "continue": true, "dest": true, "destination": true,
+	"go": true, "goto": true, "to": true, "out": true, "forward": true, "target": true,
+	"back": true, "backurl": true, "rurl": true, "r": true, "u": true, "relaystate": true,
+	"checkouturl": true, "successurl": true, "cancelurl": true, "loginurl": true, "logouturl": true,
+	"callback": true, "callbackurl": true,
+}
+
+// ssrfParamNames are parameter names, normalized by normalizeParamName, that usually hold a
+// URL or host the server fetches.
+var ssrfParamNames = map[string]bool{
+	"url": true, "uri": true, "link": true, "src": true, "source": true, "feed": true, "host": true,
+	"domain": true, "site": true, "proxy": true, "fetch": true, "load": true, "image": true,
+	"img": true, "avatar": true, "file": true, "document": true, "pdf": true, "preview": true,
+	"webhook": true, "endpoint": true, "server": true, "callback": true, "callbackurl": true,
+	"target": true, "dest": true, "destination": true,
+}
+
+// normalizeParamName lowercases a parameter name and keeps only its letters and digits, so
+// return_to, returnTo and return-to compare equal.
+func normalizeParamName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// redirectParamKinds classifies a parameter by its name.
+func redirectParamKinds(name string) []string {
+	n := normalizeParamName(name)
+	redirect := redirectParamNames[n] || strings.Contains(n, "redir") || strings.HasPrefix(n, "return") || strings.HasPrefix(n, "continue")
+	ssrf := ssrfParamNames[n] || strings.Contains(n, "webhook") || strings.Contains(n, "proxy") ||
+		(!redirect && (strings.HasSuffix(n, "url") || strings.HasSuffix(n, "uri")))
+	var kinds []string
+	if redirect {
+		kinds = append(kinds, models.ParamKindOpenRedirect)
+	}
+	if ssrf {
+		kinds = append(kinds, models.ParamKindSSRF)
+	}
+	return kinds
+}
+
+// urlValueKind describes a captured parameter value that points somewhere ("an absolute URL",
+// "a scheme-relative URL", "a URL" or "a path"), or returns "" for any other value. Values are
+// URL-decoded up to twice first.
+func urlValueKind(value string) string {
+	v := strings.TrimSpace(value)
+	for i := 0; i < 2 && strings.Contains(v, "%"); i++ {
+		decoded, err := url.QueryUnescape(v)
+		if err != nil {
+			break
+		}
+		v = decoded
+	}
+	lower := strings.ToLower(v)
+	switch {
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		return "an absolute URL"
+	case strings.HasPrefix(v, "//"), strings.HasPrefix(v, `\\`), strings.HasPrefix(v, `/\`):
+		return "a scheme-relative URL"
+	case strings.Contains(lower, "://"):
+		return "a URL"
+	case strings.HasPrefix(v, "/"):
+		return "a path"
+	}
+	return ""
+}
+
+// classifyRedirectParam decides whether a parameter is a redirect or SSRF candidate and how
+// confident that is. ok is false for parameters that are neither.
+func classifyRedirectParam(name, value string) (kinds, reasons []string, confidence string, ok bool) {
+	kinds = redirectParamKinds(name)
+	valueKind := urlValueKind(value)
+	for _, kind := range kinds {
+		if kind == models.ParamKindOpenRedirect {
+			reasons = append(reasons, fmt.Sprintf("the name %q usually holds a redirect target", name))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("the name %q usually holds a URL the server fetches", name))
+		}
+	}
+	if valueKind != "" {
+		reasons = append(reasons, "the captured value is "+valueKind)
+	}
+
+	switch {
+	case len(kinds) == 0 && (valueKind == "" || valueKind == "a path"):
+		return nil, nil, "", false
+	case len(kinds) == 0:
+		// A URL in a parameter of any name may be followed either way.
+		return []string{models.ParamKindOpenRedirect, models.ParamKindSSRF}, reasons, models.ConfidenceLow, true
+	case valueKind == "":
+		return kinds, reasons, models.ConfidenceLow, true
+	case valueKind == "a path" && kinds[0] != models.ParamKindOpenRedirect:
+		return kinds, reasons, models.ConfidenceLow, true
+	}
+	return kinds, reasons, models.ConfidenceMedium, true
+}
+
+// confidenceRank orders confidence levels, unknown ones lowest.
+func confidenceRank(confidence string) int {
+	for i, c := range models.Confidences {
+		if c == confidence {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindRedirectParamCandidates scans the target's parameter inventory (built by parameter
+// analysis) for parameters that may take a URL the server redirects to or fetches, optionally
+// only on host. Each method, host, path and parameter is reported once, most confident first.
+func FindRedirectParamCandidates(targetID int64, host string) ([]models.RedirectParamCandidate, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return nil, err
+	}
+	inventory, err := database.GetTargetParameterizedURLs(targetID)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.ToLower(strings.TrimSpace(host))
+
+	seen := map[string]bool{}
+	var candidates []models.RedirectParamCandidate
+	for _, p := range inventory {
+		u, err := url.Parse(p.ExampleFullURL.String)
+		if err != nil || u.Hostname() == "" || (host != "" && strings.ToLower(u.Hostname()) != host) {
+			continue
+		}
+		method := strings.ToUpper(p.RequestMethod.String)
+		for name, values := range u.Query() {
+			value := ""
+			if len(values) > 0 {
+				value = values[0]
+			}
+			kinds, reasons, confidence, ok := classifyRedirectParam(name, value)
+			if !ok {
+				continue
+			}
+			key := strings.Join([]string{method, strings.ToLower(u.Host), u.Path, name}, " ")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			candidates = append(candidates, models.RedirectParamCandidate{
+				ParameterizedURLID: p.ID,
+				LogID:              p.HTTPTrafficLogID.Int64,
+				Method:             method,
+				URL:                p.ExampleFullURL.String,
+				Host:               strings.ToLower(u.Host),
+				Path:               u.Path,
+				Param:              name,
+				Value:              value,
+				Kinds:              kinds,
+				Reasons:            reasons,
+				Confidence:         confidence,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Confidence != b.Confidence {
+			return confidenceRank(a.Confidence) > confidenceRank(b.Confidence)
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Param < b.Param
+	})
+	return candidates, nil
+}
+
+// StartRedirectParamScan starts a job filing the target's redirect and SSRF parameter
+// candidates as Draft findings. With req.Test, GET redirect candidates are first replayed with
+// the marker domain as their value; a redirect to it confirms an open redirect.
+func StartRedirectParamScan(targetID int64, req models.RedirectParamScanRequest) (models.Job, error) {
+	minConfidence := strings.ToLower(strings.TrimSpace(req.MinConfidence))
+	if minConfidence == "" {
+		minConfidence = models.ConfidenceMedium
+	}
+	if confidenceRank(minConfidence) < 0 {
+		return models.Job{}, fmt.Errorf("invalid min_confidence %q: use low, medium or high", req.MinConfidence)
+	}
+	candidates, err := FindRedirectParamCandidates(targetID, req.Host)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(candidates) == 0 {
+		return models.Job{}, fmt.Errorf("no redirect or SSRF parameter candidates for target %d: run parameter analysis first to build the parameter inventory", targetID)
+	}
+	latest, err := LatestJob(models.JobTypeRedirectParams, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a redirect parameter scan is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	conf := config.AppConfig.Redirects
+	maxTests := req.MaxTests
+	if maxTests <= 0 {
+		maxTests = conf.MaxTests
+	}
+	if maxTests <= 0 {
+		maxTests = 200
+	}
+	marker := strings.ToLower(strings.TrimSpace(conf.MarkerDomain))
+	if marker == "" {
+		marker = "redirect-check.example.com"
+	}
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	s := &redirectParamScanner{
+		targetID:      targetID,
+		marker:        marker,
+		test:          req.Test,
+		testsLeft:     maxTests,
+		minConfidence: minConfidence,
+		workers:       conf.Workers,
+	}
+	if req.Test {
+		s.client = &http.Client{
+			Timeout: timeout,
+			Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}, targetID)),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return StartJob(models.JobTypeRedirectParams, &targetID, fmt.Sprintf("Scanning %d redirect and SSRF parameter candidates...", len(candidates)), func(ctx context.Context, job *JobHandle) error {
+		return s.run(ctx, job, candidates)
+	})
+}
+
+type redirectParamScanner struct {
+	targetID      int64
+	client        *http.Client
+	marker        string
+	test          bool
+	minConfidence string
+	workers       int
+
+	mu        sync.Mutex
+	testsLeft int
+	tested    int
+	confirmed []string
+	titles    map[string]bool // Titles of the target's findings, to not file one twice
+	findings  int
+}
+
+func (s *redirectParamScanner) run(ctx context.Context, job *JobHandle, candidates []models.RedirectParamCandidate) error {
+	job.SetTotal(int64(len(candidates)))
+	existing, err := database.GetTargetFindingsByTargetID(s.targetID)
+	if err != nil {
+		return err
+	}
+	s.titles = make(map[string]bool, len(existing))
+	for _, f := range existing {
+		s.titles[f.Title] = true
+	}
+	workers := s.workers
+	if workers <= 0 {
+		workers = 5
+	}
+
+	ch := make(chan models.RedirectParamCandidate)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for candidate := range ch {
+				filed, err := s.scan(ctx, &candidate)
+				if err != nil {
+					job.RecordError(fmt.Errorf("%s on %s%s: %w", candidate.Param, candidate.Host, candidate.Path, err))
+				}
+				if filed {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, candidate := range candidates {
+		select {
+		case <-ctx.Done():
+			break feed
+		case ch <- candidate:
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	job.SetMessage("Scanned %d parameter candidates: %d tested, %d confirmed open redirects, %d new findings.", len(candidates), s.tested, len(s.confirmed), s.findings)
+	if len(s.confirmed) > 0 {
+		NotifyTargetEvent(models.NotificationScanFinished, s.targetID, fmt.Sprintf("Redirect parameter scan confirmed %d open redirects", len(s.confirmed)),
+			SubdomainNotificationList(s.confirmed, 10), s.confirmed)
+	}
+	return ctx.Err()
+}
+
+// scan tests one candidate when asked to and files it as a finding when it is confident enough.
+func (s *redirectParamScanner) scan(ctx context.Context, candidate *models.RedirectParamCandidate) (bool, error) {
+	var testErr error
+	if s.test && candidate.Method == http.MethodGet && candidate.Kinds[0] == models.ParamKindOpenRedirect && s.takeTest() {
+		testErr = s.testRedirect(ctx, candidate)
+	}
+	if confidenceRank(candidate.Confidence) < confidenceRank(s.minConfidence) {
+		return false, testErr
+	}
+	filed, err := s.fileFinding(*candidate)
+	if err != nil {
+		return filed, err
+	}
+	return filed, testErr
+}
+
+// takeTest claims one of the run's redirect tests, reporting false once they are used up.
+func (s *redirectParamScanner) takeTest() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.testsLeft <= 0 {
+		return false
+	}
+	s.testsLeft--
+	s.tested++
+	return true
+}
+
+// redirectPayloads returns the values injected into a redirect candidate: the marker domain as
+// an absolute and as a scheme-relative URL.
+func redirectPayloads(marker string) []string {
+	return []string{"https://" + marker + "/", "//" + marker + "/"}
+}
+
+// testRedirect replays the candidate's captured request with each payload as the parameter's
+// value and raises its confidence to high when the response redirects to the marker domain.
+func (s *redirectParamScanner) testRedirect(ctx context.Context, candidate *models.RedirectParamCandidate) error {
+	u, err := url.Parse(candidate.URL)
+	if err != nil {
+		return err
+	}
+	headers := http.Header{}
+	if candidate.LogID != 0 {
+		if entry, err := database.GetHTTPTrafficLogEntryByID(candidate.LogID); err == nil {
+			for name, values := range HeadersFromJSON(entry.RequestHeaders.String) {
+				canonical := http.CanonicalHeaderKey(name)
+				if replaySkippedHeaders[canonical] || isToolkitHeader(canonical) || canonical == "Host" {
+					continue
+				}
+				headers[canonical] = values
+			}
+		}
+	}
+
+	candidate.Tested = true
+	for _, payload := range redirectPayloads(s.marker) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		query := u.Query()
+		query.Set(candidate.Param, payload)
+		probeURL := *u
+		probeURL.RawQuery = query.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL.String(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header = headers.Clone()
+		entry, respHeaders, err := sendLoggedProbe(s.client, s.targetID, req, models.RedirectCheckLogSource)
+		if err != nil {
+			return err
+		}
+		if redirectsToMarker(u, entry, respHeaders, s.marker) {
+			candidate.Confirmed = true
+			candidate.Confidence = models.ConfidenceHigh
+			candidate.TestLogID = entry.ID
+			candidate.Value = payload
+			candidate.Reasons = append(candidate.Reasons, fmt.Sprintf("setting it to %s redirected to the marker domain (log entry %d)", payload, entry.ID))
+			s.mu.Lock()
+			s.confirmed = append(s.confirmed, candidate.Host+candidate.Path)
+			s.mu.Unlock()
+			return nil
+		}
+	}
+	// The name alone no longer suggests a redirect once injecting a URL did not cause one.
+	if candidate.Confidence == models.ConfidenceMedium {
+		candidate.Confidence = models.ConfidenceLow
+		candidate.Reasons = append(candidate.Reasons, "setting it to the marker domain did not redirect there")
+	}
+	return nil
+}
+
+// redirectsToMarker reports whether a response sends the browser to the marker domain through
+// its Location or Refresh header or a meta refresh tag.
+func redirectsToMarker(base *url.URL, entry models.HTTPTrafficLog, headers http.Header, marker string) bool {
+	if location := headers.Get("Location"); location != "" && entry.ResponseStatusCode >= 300 && entry.ResponseStatusCode < 400 {
+		if target, err := base.Parse(strings.TrimSpace(location)); err == nil && strings.EqualFold(target.Hostname(), marker) {
+			return true
+		}
+	}
+	if refresh := strings.ToLower(headers.Get("Refresh")); strings.Contains(refresh, "//"+marker) {
+		return true
+	}
+	body := strings.ToLower(string(entry.ResponseBody))
+	return strings.Contains(body, `http-equiv="refresh"`) && strings.Contains(body, "//"+marker)
+}
+
+// fileFinding creates a Draft finding for a candidate. Candidates already filed with the same
+// title are skipped.
+func (s *redirectParamScanner) fileFinding(candidate models.RedirectParamCandidate) (bool, error) {
+	vulnType := "Open Redirect"
+	label := "Open redirect"
+	if candidate.Kinds[0] == models.ParamKindSSRF {
+		vulnType = "Server-Side Request Forgery (SSRF)"
+		label = "SSRF"
+	}
+	var title string
+	if candidate.Confirmed {
+		title = fmt.Sprintf("Open redirect via %s on %s %s%s", candidate.Param, candidate.Method, candidate.Host, candidate.Path)
+	} else {
+		title = fmt.Sprintf("%s candidate (%s confidence): %s on %s %s%s", label, candidate.Confidence, candidate.Param, candidate.Method, candidate.Host, candidate.Path)
+	}
+
+	s.mu.Lock()
+	if s.titles[title] {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.titles[title] = true
+	s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The `%s` parameter of `%s %s` may take a URL", candidate.Param, candidate.Method, candidate.URL)
+	if len(candidate.Kinds) > 1 {
+		b.WriteString(" the server redirects to or fetches")
+	} else if candidate.Kinds[0] == models.ParamKindSSRF {
+		b.WriteString(" the server fetches")
+	} else {
+		b.WriteString(" the user is redirected to")
+	}
+	fmt.Fprintf(&b, ".\n\n**Confidence:** %s\n\n", candidate.Confidence)
+	for _, reason := range candidate.Reasons {
+		fmt.Fprintf(&b, "- %s\n", reason)
+	}
+	if candidate.Value != "" && !candidate.Confirmed {
+		fmt.Fprintf(&b, "\nCaptured value: `%s`\n", candidate.Value)
+	}
+
+	logID := candidate.LogID
+	severity := "Informational"
+	steps := fmt.Sprintf("1. Replace the value of `%s` in `%s` with a URL on a domain you control.\n2. ", candidate.Param, candidate.URL)
+	if candidate.Kinds[0] == models.ParamKindSSRF {
+		steps += "Watch for an incoming request, for example with an out-of-band interaction server."
+	} else {
+		steps += "Check whether the response redirects there."
+	}
+	var impact string
+	if candidate.Confirmed {
+		logID = candidate.TestLogID
+		severity = "Low"
+		entry, err := database.GetHTTPTrafficLogEntryByID(candidate.TestLogID)
+		if err == nil {
+			if rules := EffectiveRedactionRules(&s.targetID); rules.Enabled && rules.ApplyAt != models.RedactionApplyStorage {
+				RedactTrafficLog(&entry, rules)
+			}
+			reqHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+			b.WriteString("\n")
+			b.WriteString(findingEvidence(entry, http.MethodGet, reqHeaders))
+			steps = fmt.Sprintf("1. Send the request:\n\n```sh\n%s\n```\n\n2. Observe the redirect to `%s`.",
+				BuildCurlCommand(http.MethodGet, entry.RequestURL.String, reqHeaders, nil), s.marker)
+		}
+		impact = "Links on the trusted domain can send users to any site, which helps phishing and can leak OAuth codes or tokens passed to the redirect target."
+	}
+
+	finding := models.TargetFinding{
+		TargetID:         s.targetID,
+		HTTPTrafficLogID: sql.NullInt64{Int64: logID, Valid: logID != 0},
+		Title:            title,
+		Summary:          models.NullString(fmt.Sprintf("%s candidate with %s confidence.", label, candidate.Confidence)),
+		Description:      models.NullString(b.String()),
+		StepsToReproduce: models.NullString(steps),
+		Impact:           models.NullString(impact),
+		Payload:          models.NullString(candidate.Param + "=" + candidate.Value),
+		Severity:         models.NullString(severity),
+		Status:           models.FindingStatusDraft,
+	}
+	if candidate.Confirmed {
+		finding.Summary = models.NullString(fmt.Sprintf("Confirmed open redirect (high confidence): %s=%s redirects to the marker domain.", candidate.Param, candidate.Value))
+	}
+	if vt, err := FindVulnerabilityType(0, vulnType); err == nil {
+		finding.VulnerabilityTypeID = sql.NullInt64{Int64: vt.ID, Valid: true}
+	}
+	id, err := database.CreateTargetFinding(finding)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	s.findings++
+	s.mu.Unlock()
+	logger.Info("Redirect parameter scan: Draft finding %d for %s on %s%s (%s confidence)", id, candidate.Param, candidate.Host, candidate.Path, candidate.Confidence)
+	return true, nil
+}
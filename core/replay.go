@@ -0,0 +1,339 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// Headers used to tag replayed requests so the proxy can link the resulting log entries.
+// They are stripped by the proxy before the request is forwarded upstream.
+const (
+	replayBatchIDHeader     = "X-Toolkit-Replay-Batch-ID"
+	replaySourceLogIDHeader = "X-Toolkit-Replay-Source-Log-ID"
+)
+
+// replaySkippedHeaders are not copied from the original logged request.
+var replaySkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Connection":        true,
+	"Proxy-Connection":  true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Accept-Encoding":   true,
+	"Te":                true,
+	"Upgrade":           true,
+}
+
+// maxPreservedReplayDelay caps the recorded gap waited between two requests of a batch replayed
+// with preserve_timing, so a recording left idle for a while does not stall the replay.
+const maxPreservedReplayDelay = 30 * time.Second
+
+var (
+	replayCancelFuncs     = make(map[int64]context.CancelFunc)
+	replayCancelFuncsLock = &sync.Mutex{}
+)
+
+// StartReplayBatch runs a queued replay batch in the background.
+func StartReplayBatch(batchID int64) error {
+	replayCancelFuncsLock.Lock()
+	if _, running := replayCancelFuncs[batchID]; running {
+		replayCancelFuncsLock.Unlock()
+		return fmt.Errorf("replay batch %d is already running", batchID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	replayCancelFuncs[batchID] = cancel
+	replayCancelFuncsLock.Unlock()
+
+	go func() {
+		defer func() {
+			replayCancelFuncsLock.Lock()
+			delete(replayCancelFuncs, batchID)
+			replayCancelFuncsLock.Unlock()
+			cancel()
+		}()
+		runReplayBatch(ctx, batchID)
+	}()
+	return nil
+}
+
+// CancelReplayBatch stops a running replay batch. It returns false if the batch was not running.
+func CancelReplayBatch(batchID int64) bool {
+	replayCancelFuncsLock.Lock()
+	defer replayCancelFuncsLock.Unlock()
+	cancel, ok := replayCancelFuncs[batchID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// newProxyClient returns a client sending requests through the proxy. With a target ID, the proxy
+// logs them against that target even when another target is active.
+func newProxyClient(targetID int64) (*http.Client, error) {
+	proxyURL, err := url.Parse(fmt.Sprintf("http://localhost:%s", config.AppConfig.Proxy.Port))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL from config: %w", err)
+	}
+	customCAPool := x509.NewCertPool()
+	if caCert != nil {
+		customCAPool.AddCert(caCert)
+	} else {
+		logger.Error("Core: newProxyClient - caCert is nil, cannot add to custom CA pool. HTTPS requests might fail verification.")
+	}
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: customCAPool},
+	}
+	if targetID != 0 {
+		transport = &toolkitTargetTransport{base: transport, targetID: targetID}
+	}
+	return &http.Client{
+		Transport: NewRateLimitedTransport(transport),
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+func runReplayBatch(ctx context.Context, batchID int64) {
+	detail, err := database.GetReplayBatchDetail(batchID)
+	if err != nil {
+		logger.Error("runReplayBatch: Could not load batch %d: %v", batchID, err)
+		return
+	}
+
+	var session *models.ReplaySession
+	if detail.SessionID.Valid {
+		s, errSession := database.GetReplaySessionByID(detail.SessionID.Int64)
+		if errSession != nil {
+			logger.Error("runReplayBatch: Could not load session %d for batch %d: %v", detail.SessionID.Int64, batchID, errSession)
+			database.UpdateReplayBatchStatus(batchID, "failed", errSession.Error())
+			return
+		}
+		session = &s
+	}
+
+	client, err := newProxyClient(detail.TargetID.Int64)
+	if err != nil {
+		database.UpdateReplayBatchStatus(batchID, "failed", err.Error())
+		return
+	}
+	if detail.TargetID.Valid {
+		client.Transport = NewClientProfileTransport(client.Transport, detail.TargetID.Int64)
+	}
+
+	items := detail.Items
+	if detail.Shuffle {
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+	}
+	delay := time.Duration(detail.DelayMs) * time.Millisecond
+
+	var recordedAt map[int64]time.Time
+	if detail.PreserveTiming {
+		recordedAt, err = database.GetReplayBatchSourceTimestamps(batchID)
+		if err != nil {
+			logger.Error("runReplayBatch: %v; falling back to a fixed delay", err)
+		}
+	}
+	var jar http.CookieJar
+	if detail.CarryCookies {
+		jar, _ = cookiejar.New(nil) // Only fails for invalid options
+	}
+
+	if err := database.UpdateReplayBatchStatus(batchID, "running", ""); err != nil {
+		logger.Error("runReplayBatch: %v", err)
+	}
+	logger.Info("runReplayBatch: Starting batch %d (%d items, delay %s, shuffle %t, preserve timing %t, carry cookies %t)",
+		batchID, len(items), delay, detail.Shuffle, detail.PreserveTiming, detail.CarryCookies)
+
+	for i, item := range items {
+		if item.Status != "queued" {
+			continue
+		}
+		wait := delay
+		if recordedAt != nil && i > 0 {
+			wait = preservedReplayDelay(recordedAt, items[i-1], item, delay)
+		}
+		if i > 0 && wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+		if ctx.Err() != nil {
+			logger.Info("runReplayBatch: Batch %d cancelled", batchID)
+			database.UpdateReplayBatchStatus(batchID, "cancelled", "")
+			return
+		}
+
+		statusCode, errItem := replayItem(ctx, client, batchID, item, session, jar)
+		if errItem != nil {
+			logger.Error("runReplayBatch: Batch %d item %d (%s %s) failed: %v", batchID, item.ID, item.RequestMethod, item.RequestURL, errItem)
+		}
+		if errRecord := database.RecordReplayBatchItemResult(item.ID, batchID, statusCode, errItem); errRecord != nil {
+			logger.Error("runReplayBatch: %v", errRecord)
+		}
+	}
+
+	database.UpdateReplayBatchStatus(batchID, "completed", "")
+	logger.Info("runReplayBatch: Finished batch %d", batchID)
+}
+
+// buildReplayRequest recreates the request for a batch item. Items sourced from a log entry keep
+// the original headers and body, the target's client profile fills in headers they lack, and the
+// session's cookies and headers override them.
+func buildReplayRequest(ctx context.Context, batchID int64, item models.ReplayBatchItem, session *models.ReplaySession) (*http.Request, error) {
+	var body []byte
+	var sourceURL string
+	headers := http.Header{}
+	if item.SourceLogID.Valid {
+		logEntry, err := database.GetHTTPTrafficLogEntryByID(item.SourceLogID.Int64)
+		if err != nil {
+			return nil, err
+		}
+		body = logEntry.RequestBody
+		sourceURL = logEntry.RequestURL.String
+		if logEntry.RequestHeaders.Valid && logEntry.RequestHeaders.String != "" {
+			var logged map[string][]string
+			if err := json.Unmarshal([]byte(logEntry.RequestHeaders.String), &logged); err != nil {
+				logger.Warn("buildReplayRequest: Could not parse headers of log %d: %v", item.SourceLogID.Int64, err)
+			}
+			for k, vals := range logged {
+				canonical := http.CanonicalHeaderKey(k)
+				if replaySkippedHeaders[canonical] || isToolkitHeader(canonical) {
+					continue
+				}
+				for _, v := range vals {
+					headers.Add(canonical, v)
+				}
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, item.RequestMethod, item.RequestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header = headers
+	if sourceURL != "" {
+		retargetOriginHeaders(req, sourceURL)
+	}
+
+	if session != nil {
+		for k, v := range session.Headers {
+			req.Header.Set(k, v)
+		}
+		if session.Cookies != "" {
+			req.Header.Set("Cookie", session.Cookies)
+		}
+	}
+
+	req.Header.Set("X-Toolkit-Initiated", "true")
+	req.Header.Set("X-Toolkit-Source", "Replay")
+	req.Header.Set("X-Toolkit-Full-URL", item.RequestURL)
+	req.Header.Set(replayBatchIDHeader, strconv.FormatInt(batchID, 10))
+	if item.SourceLogID.Valid {
+		req.Header.Set(replaySourceLogIDHeader, strconv.FormatInt(item.SourceLogID.Int64, 10))
+	}
+	return req, nil
+}
+
+// retargetOriginHeaders points the Origin and Referer headers of a request sent to another
+// environment than its captured URL (a batch with a base URL) at that environment.
+func retargetOriginHeaders(req *http.Request, sourceURL string) {
+	source, err := url.Parse(sourceURL)
+	if err != nil || source.Host == "" || strings.EqualFold(source.Host, req.URL.Host) && source.Scheme == req.URL.Scheme {
+		return
+	}
+	from, to := source.Scheme+"://"+source.Host, req.URL.Scheme+"://"+req.URL.Host
+	for _, name := range []string{"Origin", "Referer"} {
+		if v := req.Header.Get(name); v == from || strings.HasPrefix(v, from+"/") {
+			req.Header.Set(name, to+strings.TrimPrefix(v, from))
+		}
+	}
+}
+
+// preservedReplayDelay is the recorded gap between the source log entries of two consecutive items,
+// capped at maxPreservedReplayDelay. fallback is used when either item has no source log entry.
+func preservedReplayDelay(recordedAt map[int64]time.Time, prev, cur models.ReplayBatchItem, fallback time.Duration) time.Duration {
+	prevAt, okPrev := recordedAt[prev.ID]
+	curAt, okCur := recordedAt[cur.ID]
+	if !okPrev || !okCur {
+		return fallback
+	}
+	gap := curAt.Sub(prevAt)
+	if gap < 0 {
+		return 0
+	}
+	if gap > maxPreservedReplayDelay {
+		return maxPreservedReplayDelay
+	}
+	return gap
+}
+
+// applyReplayCookies overrides cookies of the request with the ones the jar holds for its URL,
+// keeping recorded cookies the replay has not received a new value for.
+func applyReplayCookies(req *http.Request, jar http.CookieJar) {
+	fresh := jar.Cookies(req.URL)
+	if len(fresh) == 0 {
+		return
+	}
+	overridden := make(map[string]bool, len(fresh))
+	for _, c := range fresh {
+		overridden[c.Name] = true
+	}
+	var pairs []string
+	for _, c := range req.Cookies() {
+		if !overridden[c.Name] {
+			pairs = append(pairs, c.Name+"="+c.Value)
+		}
+	}
+	for _, c := range fresh {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	req.Header.Set("Cookie", strings.Join(pairs, "; "))
+}
+
+// replayItem sends one batch item. With a jar, cookies set by earlier responses of the batch are
+// sent along and the cookies this response sets are stored for later items.
+func replayItem(ctx context.Context, client *http.Client, batchID int64, item models.ReplayBatchItem, session *models.ReplaySession, jar http.CookieJar) (int, error) {
+	req, err := buildReplayRequest(ctx, batchID, item, session)
+	if err != nil {
+		return 0, err
+	}
+	if jar != nil {
+		applyReplayCookies(req, jar)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if jar != nil {
+		jar.SetCookies(req.URL, resp.Cookies())
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func isToolkitHeader(canonical string) bool {
+	return strings.HasPrefix(canonical, "X-Toolkit-")
+}
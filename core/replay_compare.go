@@ -0,0 +1,225 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// replayCompareScanLimit bounds the captured requests scanned when picking the endpoints of an
+// environment comparison.
+const replayCompareScanLimit = 20000
+
+// volatileResponseHeaders differ between any two responses and are left out of environment
+// comparisons.
+var volatileResponseHeaders = map[string]bool{
+	"date": true, "age": true, "expires": true, "last-modified": true, "etag": true, "content-length": true,
+	"set-cookie": true, "x-request-id": true, "x-amzn-requestid": true, "x-amz-cf-id": true, "x-amz-request-id": true,
+	"cf-ray": true, "server-timing": true, "x-runtime": true, "x-trace-id": true, "traceparent": true,
+}
+
+// StartReplayComparison replays captured requests of a target against another environment and
+// returns the started batch. Its report is served by GetReplayComparison.
+func StartReplayComparison(targetID int64, req models.ReplayCompareRequest) (models.ReplayBatchDetail, error) {
+	var detail models.ReplayBatchDetail
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return detail, err
+	}
+	if strings.TrimSpace(req.BaseURL) == "" {
+		return detail, fmt.Errorf("invalid base_url: required")
+	}
+	if req.SessionID != 0 {
+		if _, err := database.GetReplaySessionByID(req.SessionID); err != nil {
+			return detail, err
+		}
+	}
+	logIDs := req.LogIDs
+	if len(logIDs) == 0 {
+		var err error
+		if logIDs, err = pickComparisonEndpoints(targetID, req); err != nil {
+			return detail, err
+		}
+		if len(logIDs) == 0 {
+			return detail, fmt.Errorf("no captured requests of target %d match the comparison", targetID)
+		}
+	}
+	name := req.Name
+	if name == "" {
+		name = "Compare with " + strings.TrimSpace(req.BaseURL)
+	}
+	detail, err := database.CreateReplayBatch(models.CreateReplayBatchRequest{
+		TargetID:  targetID,
+		SessionID: req.SessionID,
+		Name:      name,
+		LogIDs:    logIDs,
+		DelayMs:   req.DelayMs,
+		BaseURL:   req.BaseURL,
+	})
+	if err != nil {
+		return detail, err
+	}
+	return detail, StartReplayBatch(detail.ID)
+}
+
+// pickComparisonEndpoints returns the latest captured request of each endpoint (method, host and
+// path with IDs as {id}) of a target, oldest endpoint first.
+func pickComparisonEndpoints(targetID int64, req models.ReplayCompareRequest) ([]int64, error) {
+	host := strings.ToLower(strings.TrimSpace(req.Host))
+	logs, err := database.ListTargetCapturedRequests(targetID, host, replayCompareScanLimit)
+	if err != nil {
+		return nil, err
+	}
+	max := req.MaxEndpoints
+	if max <= 0 {
+		max = 100
+	}
+	seen := map[string]bool{}
+	var ids []int64
+	for _, l := range logs {
+		u, err := url.Parse(l.RequestURL.String)
+		if err != nil || u.Host == "" || (req.PathContains != "" && !strings.Contains(u.Path, req.PathContains)) {
+			continue
+		}
+		key := strings.ToUpper(l.RequestMethod.String) + " " + strings.ToLower(u.Host) + endpointPath(u.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ids = append(ids, l.ID)
+		if len(ids) == max {
+			break
+		}
+	}
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return ids, nil
+}
+
+// GetReplayComparison compares the responses of a replay batch with the captured responses it
+// replays, per endpoint. Volatile headers (Date, ETag, Set-Cookie, request IDs, ...) are ignored.
+func GetReplayComparison(batchID int64) (models.ReplayComparison, error) {
+	detail, err := database.GetReplayBatchDetail(batchID)
+	if err != nil {
+		return models.ReplayComparison{}, err
+	}
+	report := models.ReplayComparison{BatchID: batchID, BaseURL: detail.BaseURL.String, Status: detail.Status, Endpoints: []models.EndpointComparison{}}
+	replays, err := database.GetHTTPTrafficLogsByReplayBatch(batchID)
+	if err != nil {
+		return report, err
+	}
+	replayLogs := map[int64][]int64{} // Source log ID -> replay log IDs, in the order they were sent
+	for _, l := range replays {
+		if l.ReplaySourceLogID.Valid {
+			replayLogs[l.ReplaySourceLogID.Int64] = append(replayLogs[l.ReplaySourceLogID.Int64], l.ID)
+		}
+	}
+
+	byKey := map[string]*models.EndpointComparison{}
+	var endpoints []*models.EndpointComparison
+	for _, item := range detail.Items {
+		if !item.SourceLogID.Valid {
+			continue
+		}
+		source, err := database.GetHTTPTrafficLogEntryByID(item.SourceLogID.Int64)
+		if err != nil {
+			continue // The captured request was deleted
+		}
+		c := models.RequestComparison{ItemID: item.ID, SourceLogID: source.ID, URL: item.RequestURL, SourceStatus: source.ResponseStatusCode, SourceSize: len(source.ResponseBody)}
+		if ids := replayLogs[source.ID]; len(ids) > 0 && item.Status == "completed" {
+			replayLogs[source.ID] = ids[1:]
+			replay, err := database.GetHTTPTrafficLogEntryByID(ids[0])
+			if err != nil {
+				return report, err
+			}
+			compareReplayedResponse(&c, source, replay)
+		} else {
+			c.Error = item.Error.String
+			if c.Error == "" {
+				c.Error = "not replayed (" + item.Status + ")"
+			}
+		}
+
+		method := strings.ToUpper(source.RequestMethod.String)
+		path := source.RequestURL.String
+		if u, err := url.Parse(path); err == nil {
+			path = endpointPath(u.Path)
+		}
+		ep := byKey[method+" "+path]
+		if ep == nil {
+			ep = &models.EndpointComparison{Method: method, Path: path}
+			byKey[method+" "+path] = ep
+			endpoints = append(endpoints, ep)
+		}
+		ep.Requests = append(ep.Requests, c)
+		switch {
+		case c.Error != "":
+			report.Failed++
+			continue
+		case c.StatusChanged:
+			ep.StatusDiffers++
+			report.StatusDiffers++
+		}
+		if c.BodyMode != "identical" {
+			ep.BodyDiffers++
+			report.BodyDiffers++
+		}
+		if len(c.HeaderChanges) > 0 {
+			ep.HeadersDiffer++
+			report.HeadersDiffer++
+		}
+		report.Compared++
+		if !c.StatusChanged && c.BodyMode == "identical" && len(c.HeaderChanges) == 0 {
+			report.Identical++
+		}
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		a, b := endpoints[i], endpoints[j]
+		if (a.StatusDiffers > 0) != (b.StatusDiffers > 0) {
+			return a.StatusDiffers > 0
+		}
+		if da, db := a.BodyDiffers+a.HeadersDiffer, b.BodyDiffers+b.HeadersDiffer; (da > 0) != (db > 0) {
+			return da > 0
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
+	})
+	for _, ep := range endpoints {
+		report.Endpoints = append(report.Endpoints, *ep)
+	}
+	return report, nil
+}
+
+// compareReplayedResponse fills in how the response of the other environment differs from the
+// captured one.
+func compareReplayedResponse(c *models.RequestComparison, source, replay models.HTTPTrafficLog) {
+	diff := DiffTrafficLogs(source, replay)
+	c.ReplayLogID = replay.ID
+	c.ReplayStatus = replay.ResponseStatusCode
+	c.ReplaySize = len(replay.ResponseBody)
+	c.StatusChanged = diff.StatusCode != nil
+	c.BodyMode = diff.ResponseBody.Mode
+	switch diff.ResponseBody.Mode {
+	case "json":
+		c.BodyChanges = len(diff.ResponseBody.JSONChanges)
+	case "text":
+		for _, line := range diff.ResponseBody.Lines {
+			if line.Op != " " {
+				c.BodyChanges++
+			}
+		}
+	case "binary":
+		c.BodyChanges = 1
+	}
+	for _, h := range diff.ResponseHeaders {
+		if !volatileResponseHeaders[strings.ToLower(h.Name)] {
+			c.HeaderChanges = append(c.HeaderChanges, h.Change+": "+h.Name)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// maxEnumeratedRequests caps the requests generated from one URL template, before they are sent
+// once per session.
+const maxEnumeratedRequests = 1000
+
+var (
+	// urlTemplateParamRegex matches the {name} parameters of a URL template. {{name}} variables
+	// are filled in before it is applied.
+	urlTemplateParamRegex = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+	httpMethodRegex       = regexp.MustCompile(`^[A-Z]+$`)
+)
+
+// enumerationVerdictOrder lists the verdicts in the order reports list their requests: the ones
+// more than one session could read first.
+var enumerationVerdictOrder = []string{
+	models.EnumerationVerdictShared, models.EnumerationVerdictDiffers, models.EnumerationVerdictSingle,
+	models.EnumerationVerdictPending, models.EnumerationVerdictDenied, models.EnumerationVerdictFailed,
+}
+
+// StartReplayEnumeration generates requests from a URL template, each {name} parameter taking
+// the values given for it, and sends them through the proxy as a replay batch per replay session
+// (the target's by default), so the answers of each account can be compared with
+// GetReplayEnumerationReport. With dry_run, the requests are only returned.
+func StartReplayEnumeration(targetID int64, req models.ReplayEnumerationRequest) (models.ReplayEnumeration, error) {
+	e := models.ReplayEnumeration{TargetID: targetID, URLTemplate: strings.TrimSpace(req.URLTemplate), DryRun: req.DryRun, BatchIDs: []int64{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return e, err
+	}
+	if e.URLTemplate == "" {
+		return e, models.InvalidRequestError("url_template is required")
+	}
+	e.Method = strings.ToUpper(strings.TrimSpace(req.Method))
+	var origin string
+	if req.SourceLogID != 0 {
+		entry, err := database.GetHTTPTrafficLogEntryByID(req.SourceLogID)
+		if err != nil {
+			return e, err
+		}
+		if entry.TargetID != nil && *entry.TargetID != targetID {
+			return e, models.InvalidRequestError("log %d belongs to target %d, not %d", entry.ID, *entry.TargetID, targetID)
+		}
+		e.SourceLogID.Int64, e.SourceLogID.Valid = entry.ID, true
+		if e.Method == "" {
+			e.Method = strings.ToUpper(entry.RequestMethod.String)
+		}
+		if u, err := url.Parse(entry.RequestURL.String); err == nil && u.Host != "" {
+			origin = u.Scheme + "://" + u.Host
+		}
+	}
+	if e.Method == "" {
+		e.Method = http.MethodGet
+	}
+	if !httpMethodRegex.MatchString(e.Method) {
+		return e, models.InvalidRequestError("invalid method '%s'", e.Method)
+	}
+
+	variables := commandVariables(targetID, nil)
+	targetVariables, err := database.GetTargetVariableMap(targetID)
+	if err != nil {
+		return e, err
+	}
+	for k, v := range targetVariables {
+		variables[k] = v
+	}
+	template, missing := SubstituteVariables(e.URLTemplate, variables)
+	if len(missing) > 0 {
+		return e, models.InvalidRequestError("url_template uses variables without a value: %s", strings.Join(missing, ", "))
+	}
+	if strings.HasPrefix(template, "/") {
+		if origin == "" {
+			if u, err := url.Parse(variables["target_url"]); err == nil && u.Host != "" {
+				origin = u.Scheme + "://" + u.Host
+			}
+		}
+		if origin == "" {
+			return e, models.InvalidRequestError("url_template is relative, but neither a source_log_id nor the target's URL gives its host")
+		}
+		template = origin + template
+	}
+
+	for _, m := range urlTemplateParamRegex.FindAllStringSubmatch(template, -1) {
+		e.Parameters = append(e.Parameters, m[1])
+	}
+	e.Parameters = uniqueInOrder(e.Parameters)
+	if len(e.Parameters) == 0 {
+		return e, models.InvalidRequestError("url_template has no {name} parameters to enumerate")
+	}
+	values, err := enumerationValues(e.Parameters, req)
+	if err != nil {
+		return e, err
+	}
+	combinations, err := combineEnumerationValues(e.Parameters, values, req.Mode)
+	if err != nil {
+		return e, err
+	}
+	seen := map[string]bool{}
+	for _, combination := range combinations {
+		u := expandURLTemplate(template, combination)
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return e, models.InvalidRequestError("url_template does not give an http(s) URL: %s", u)
+		}
+		if !seen[u] {
+			seen[u] = true
+			e.Requests = append(e.Requests, models.EnumeratedRequest{URL: u, Values: combination})
+		}
+	}
+
+	e.Name = strings.TrimSpace(req.Name)
+	if e.Name == "" {
+		e.Name = fmt.Sprintf("Enumerate %s %s", e.Method, e.URLTemplate)
+	}
+	if req.DryRun {
+		return e, nil
+	}
+
+	sessionIDs, sessionNames, err := enumerationSessions(targetID, req.SessionIDs)
+	if err != nil {
+		return e, err
+	}
+	items := make([]models.ReplayBatchItem, len(e.Requests))
+	for i, r := range e.Requests {
+		items[i] = models.ReplayBatchItem{SourceLogID: e.SourceLogID, RequestMethod: e.Method, RequestURL: r.URL}
+	}
+	for _, sessionID := range sessionIDs {
+		name := e.Name
+		if sessionID != 0 {
+			name += " as " + sessionNames[sessionID]
+		}
+		batch, err := database.CreateReplayBatch(models.CreateReplayBatchRequest{
+			TargetID:  targetID,
+			SessionID: sessionID,
+			Name:      name,
+			DelayMs:   req.DelayMs,
+			Requests:  items,
+		})
+		if err != nil {
+			return e, err
+		}
+		e.BatchIDs = append(e.BatchIDs, batch.ID)
+	}
+	id, err := database.CreateReplayEnumeration(e)
+	if err != nil {
+		return e, err
+	}
+	for _, batchID := range e.BatchIDs {
+		if err := StartReplayBatch(batchID); err != nil {
+			return e, fmt.Errorf("starting replay batch %d: %w", batchID, err)
+		}
+	}
+	return database.GetReplayEnumeration(id)
+}
+
+// GetReplayEnumerations lists the replay enumerations of a target, newest first.
+func GetReplayEnumerations(targetID int64) ([]models.ReplayEnumeration, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return nil, err
+	}
+	return database.GetReplayEnumerations(targetID)
+}
+
+// enumerationValues returns the values of each parameter: its values, then its range, then the
+// words of its wordlist, without duplicates.
+func enumerationValues(params []string, req models.ReplayEnumerationRequest) (map[string][]string, error) {
+	known := map[string]bool{}
+	for _, p := range params {
+		known[p] = true
+	}
+	for _, given := range []map[string]bool{keysOf(req.Values), keysOf(req.Ranges), keysOf(req.WordlistIDs)} {
+		for p := range given {
+			if !known[p] {
+				return nil, models.InvalidRequestError("values are given for {%s}, which url_template does not use", p)
+			}
+		}
+	}
+
+	values := map[string][]string{}
+	for _, p := range params {
+		list := append([]string(nil), req.Values[p]...)
+		if r, ok := req.Ranges[p]; ok {
+			step := r.Step
+			if step == 0 {
+				step = 1
+			}
+			if step < 0 || r.From > r.To {
+				return nil, models.InvalidRequestError("invalid range of {%s}: from must not exceed to, and step must be positive", p)
+			}
+			if (r.To-r.From)/step >= maxEnumeratedRequests {
+				return nil, models.InvalidRequestError("the range of {%s} has more than %d values", p, maxEnumeratedRequests)
+			}
+			for v := r.From; v <= r.To; v += step {
+				list = append(list, strconv.FormatInt(v, 10))
+			}
+		}
+		if wordlistID, ok := req.WordlistIDs[p]; ok {
+			words, err := ReadWordlist(wordlistID)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, words...)
+		}
+		list = uniqueInOrder(list)
+		if len(list) == 0 {
+			return nil, models.InvalidRequestError("no values for {%s}; give values, a range or a wordlist for it", p)
+		}
+		values[p] = list
+	}
+	return values, nil
+}
+
+// combineEnumerationValues returns the parameter values of each request to generate, in order.
+func combineEnumerationValues(params []string, values map[string][]string, mode string) ([]map[string]string, error) {
+	switch mode {
+	case "", models.EnumerationModeCartesian:
+		total := 1
+		for _, p := range params {
+			total *= len(values[p])
+			if total > maxEnumeratedRequests {
+				return nil, models.InvalidRequestError("the values give more than %d requests", maxEnumeratedRequests)
+			}
+		}
+		combinations := []map[string]string{{}}
+		for _, p := range params {
+			var next []map[string]string
+			for _, c := range combinations {
+				for _, v := range values[p] {
+					combination := make(map[string]string, len(c)+1)
+					for k, cv := range c {
+						combination[k] = cv
+					}
+					combination[p] = v
+					next = append(next, combination)
+				}
+			}
+			combinations = next
+		}
+		return combinations, nil
+	case models.EnumerationModeZip:
+		n := len(values[params[0]])
+		for _, p := range params[1:] {
+			if len(values[p]) != n {
+				return nil, models.InvalidRequestError("zip mode needs as many values for every parameter: {%s} has %d, {%s} has %d",
+					params[0], n, p, len(values[p]))
+			}
+		}
+		if n > maxEnumeratedRequests {
+			return nil, models.InvalidRequestError("the values give more than %d requests", maxEnumeratedRequests)
+		}
+		combinations := make([]map[string]string, n)
+		for i := range combinations {
+			combinations[i] = map[string]string{}
+			for _, p := range params {
+				combinations[i][p] = values[p][i]
+			}
+		}
+		return combinations, nil
+	default:
+		return nil, models.InvalidRequestError("invalid mode '%s': expected cartesian or zip", mode)
+	}
+}
+
+// expandURLTemplate fills in the {name} parameters of a URL template, escaped for the path or,
+// after the '?', for the query.
+func expandURLTemplate(template string, values map[string]string) string {
+	query := strings.Index(template, "?")
+	var b strings.Builder
+	last := 0
+	for _, m := range urlTemplateParamRegex.FindAllStringSubmatchIndex(template, -1) {
+		b.WriteString(template[last:m[0]])
+		v := values[template[m[2]:m[3]]]
+		if query >= 0 && m[0] > query {
+			b.WriteString(url.QueryEscape(v))
+		} else {
+			b.WriteString(url.PathEscape(v))
+		}
+		last = m[1]
+	}
+	b.WriteString(template[last:])
+	return b.String()
+}
+
+// enumerationSessions returns the replay sessions an enumeration is sent as, with their names:
+// the given ones, or the target's, or a single 0 (no session) when the target has none.
+func enumerationSessions(targetID int64, ids []int64) ([]int64, map[int64]string, error) {
+	names := map[int64]string{}
+	if len(ids) == 0 {
+		sessions, err := database.GetReplaySessions(targetID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+			names[s.ID] = s.Name
+		}
+		if len(ids) == 0 {
+			return []int64{0}, names, nil
+		}
+		return ids, names, nil
+	}
+	var unique []int64
+	for _, id := range ids {
+		if _, ok := names[id]; ok {
+			continue
+		}
+		s, err := database.GetReplaySessionByID(id)
+		if err != nil {
+			return nil, nil, models.NotFoundError("replay session %d not found", id)
+		}
+		names[id] = s.Name
+		unique = append(unique, id)
+	}
+	return unique, names, nil
+}
+
+// GetReplayEnumerationReport groups the answers to a replay enumeration's requests by request,
+// across the sessions they were sent as. A request several sessions got the same successful
+// answer to is "shared", a likely authorization gap when the sessions are different accounts.
+func GetReplayEnumerationReport(id int64) (models.ReplayEnumerationReport, error) {
+	e, err := database.GetReplayEnumeration(id)
+	if err != nil {
+		return models.ReplayEnumerationReport{}, err
+	}
+	report := models.ReplayEnumerationReport{
+		ReplayEnumeration: e,
+		Status:            "completed",
+		Sessions:          []models.EnumerationSessionSummary{},
+		Verdicts:          map[string]int{},
+		Results:           make([]models.EnumeratedRequestResult, len(e.Requests)),
+	}
+	report.Requests = nil
+	for i, r := range e.Requests {
+		report.Results[i] = models.EnumeratedRequestResult{EnumeratedRequest: r, Responses: []models.EnumerationResponse{}}
+	}
+	pending := make([]bool, len(e.Requests))
+
+	for _, batchID := range e.BatchIDs {
+		detail, err := database.GetReplayBatchDetail(batchID)
+		if err != nil {
+			return report, err
+		}
+		summary := models.EnumerationSessionSummary{BatchID: batchID, SessionName: "No session", Status: detail.Status, StatusClasses: map[string]int{}}
+		if detail.SessionID.Valid {
+			summary.SessionID = detail.SessionID.Int64
+			summary.SessionName = fmt.Sprintf("Session %d", summary.SessionID)
+			if s, err := database.GetReplaySessionByID(summary.SessionID); err == nil {
+				summary.SessionName = s.Name
+			}
+		}
+		active := detail.Status == "queued" || detail.Status == "running"
+		if active {
+			report.Status = "running"
+		}
+		logs, err := database.GetHTTPTrafficLogsByReplayBatch(batchID)
+		if err != nil {
+			return report, err
+		}
+		logsByURL := map[string]int64{}
+		for _, l := range logs {
+			u := l.RequestFullURLWithFragment.String
+			if u == "" {
+				u = l.RequestURL.String
+			}
+			logsByURL[u] = l.ID
+		}
+
+		// Items are stored in the order of the enumeration's requests.
+		for i, item := range detail.Items {
+			if i >= len(report.Results) {
+				break
+			}
+			resp := models.EnumerationResponse{BatchID: batchID, SessionName: summary.SessionName}
+			switch item.Status {
+			case "completed":
+				resp.StatusCode = int(item.ResponseStatusCode.Int64)
+				if logID, ok := logsByURL[item.RequestURL]; ok {
+					if entry, err := database.GetHTTPTrafficLogEntryByID(logID); err == nil {
+						sum := sha256.Sum256(entry.ResponseBody)
+						resp.LogID, resp.Size, resp.BodyHash = entry.ID, len(entry.ResponseBody), hex.EncodeToString(sum[:8])
+					}
+				}
+				summary.StatusClasses[fmt.Sprintf("%dxx", resp.StatusCode/100)]++
+			case "failed":
+				resp.Error = item.Error.String
+				summary.Failed++
+			default:
+				resp.Error = "not sent (" + item.Status + ")"
+				if active {
+					pending[i] = true
+				}
+			}
+			report.Results[i].Responses = append(report.Results[i].Responses, resp)
+		}
+		report.Sessions = append(report.Sessions, summary)
+	}
+
+	for i := range report.Results {
+		r := &report.Results[i]
+		r.Verdict = enumerationVerdict(r.Responses, pending[i])
+		report.Verdicts[r.Verdict]++
+	}
+	rank := map[string]int{}
+	for i, v := range enumerationVerdictOrder {
+		rank[v] = i
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return rank[report.Results[i].Verdict] < rank[report.Results[j].Verdict]
+	})
+	return report, nil
+}
+
+// enumerationVerdict tells how the sessions' answers to one request compare.
+func enumerationVerdict(responses []models.EnumerationResponse, pending bool) string {
+	if pending {
+		return models.EnumerationVerdictPending
+	}
+	successes, answers := 0, 0
+	bodies := map[string]int{}
+	for _, r := range responses {
+		if r.Error == "" {
+			answers++
+		}
+		if r.StatusCode >= 200 && r.StatusCode < 300 {
+			successes++
+			if r.BodyHash != "" {
+				bodies[r.BodyHash]++
+			}
+		}
+	}
+	switch {
+	case answers == 0:
+		return models.EnumerationVerdictFailed
+	case successes == 0:
+		return models.EnumerationVerdictDenied
+	case successes == 1:
+		return models.EnumerationVerdictSingle
+	}
+	for _, n := range bodies {
+		if n > 1 {
+			return models.EnumerationVerdictShared
+		}
+	}
+	return models.EnumerationVerdictDiffers
+}
+
+// uniqueInOrder drops repeated values, keeping the first of each.
+func uniqueInOrder(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// keysOf returns the set of keys of a map.
+func keysOf[V any](m map[string]V) map[string]bool {
+	keys := make(map[string]bool, len(m))
+	for k := range m {
+		keys[k] = true
+	}
+	return keys
+}
@@ -0,0 +1,435 @@
+package core
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// plainShellWord matches command arguments that need no quoting.
+var plainShellWord = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+const (
+	defaultGenerationMaxEndpoints = 500
+	defaultGenerationNameTemplate = "{method} {host}{path}"
+	defaultFfufValueTemplate      = "FUZZ"
+)
+
+// inventoryEndpoint is an endpoint of the parameter inventory: a method, host and path with every
+// parameter seen or mined for it.
+type inventoryEndpoint struct {
+	method string
+	url    *url.URL                // Example URL
+	entry  models.ParameterizedURL // Entry whose captured request the example comes from
+	ids    []int64
+	names  []string
+}
+
+// GenerateEndpointRequests bootstraps coverage of a target's endpoints: every endpoint (method,
+// host and path) of the parameter inventory gets a modifier task or an ffuf command built from its
+// latest captured request, with the value template applied to each of its parameters. Parameters
+// known only from mining are added where the captured request sends its parameters.
+func GenerateEndpointRequests(targetID int64, req models.RequestGenerationRequest) (models.RequestGenerationResult, error) {
+	result := models.RequestGenerationResult{TargetID: targetID, Requests: []models.GeneratedRequest{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = models.RequestGenerationModifier
+	}
+	if format != models.RequestGenerationModifier && format != models.RequestGenerationFfuf {
+		return result, fmt.Errorf("invalid format '%s': expected %s or %s", req.Format, models.RequestGenerationModifier, models.RequestGenerationFfuf)
+	}
+	result.Format = format
+	if req.Source != "" && req.Source != models.ParameterSourceTraffic && req.Source != models.ParameterSourceMining {
+		return result, fmt.Errorf("invalid source '%s': expected %s or %s", req.Source, models.ParameterSourceTraffic, models.ParameterSourceMining)
+	}
+	valueTemplate := req.ValueTemplate
+	if format == models.RequestGenerationFfuf {
+		if valueTemplate == "" {
+			valueTemplate = defaultFfufValueTemplate
+		}
+		if !strings.Contains(valueTemplate, "FUZZ") {
+			return result, fmt.Errorf("invalid value_template '%s': ffuf needs the FUZZ keyword in it", valueTemplate)
+		}
+	}
+	nameTemplate := req.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = defaultGenerationNameTemplate
+	}
+	maxEndpoints := req.MaxEndpoints
+	if maxEndpoints <= 0 {
+		maxEndpoints = defaultGenerationMaxEndpoints
+	}
+
+	wordlist := "{{target_wordlist}}"
+	if format == models.RequestGenerationFfuf && req.WordlistID != 0 {
+		w, err := GetWordlist(req.WordlistID)
+		if err != nil {
+			return result, err
+		}
+		wordlist = w.Path
+	}
+	if format == models.RequestGenerationModifier && req.CollectionID != 0 {
+		if _, err := database.GetModifierCollectionByID(req.CollectionID); err != nil {
+			return result, err
+		}
+		result.CollectionID = req.CollectionID
+	}
+	generated := map[int64]bool{} // Inventory entries that already have a task
+	if format == models.RequestGenerationModifier && !req.IncludeExisting {
+		tasks, err := database.GetModifierTasks(targetID, 0)
+		if err != nil {
+			return result, err
+		}
+		for _, t := range tasks {
+			if t.SourceParameterizedURLID.Valid {
+				generated[t.SourceParameterizedURLID.Int64] = true
+			}
+		}
+	}
+
+	endpoints, err := inventoryEndpoints(targetID, req)
+	if err != nil {
+		return result, err
+	}
+	result.Endpoints = len(endpoints)
+	for _, ep := range endpoints {
+		if len(result.Requests) >= maxEndpoints {
+			break
+		}
+		if slices.ContainsFunc(ep.ids, func(id int64) bool { return generated[id] }) {
+			result.Skipped++
+			continue
+		}
+		gen, task, err := buildEndpointRequest(targetID, ep, valueTemplate, nameTemplate)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s %s: %v", ep.method, ep.url.String(), err))
+			continue
+		}
+		switch format {
+		case models.RequestGenerationFfuf:
+			gen.Args = ffufArgs(gen, task, wordlist)
+			gen.Command = ffufCommandLine(gen.Args)
+		case models.RequestGenerationModifier:
+			if req.DryRun {
+				break
+			}
+			if result.CollectionID == 0 {
+				name := strings.TrimSpace(req.CollectionName)
+				if name == "" {
+					name = "Generated " + time.Now().Format("2006-01-02 15:04:05")
+				}
+				id, err := database.CreateModifierCollection(models.ModifierCollection{TargetID: task.TargetID, Name: name,
+					Description: "Requests generated from the parameter inventory"})
+				if err != nil {
+					return result, err
+				}
+				result.CollectionID = id
+			}
+			task.CollectionID = sql.NullInt64{Int64: result.CollectionID, Valid: true}
+			created, err := database.CreateModifierTask(task)
+			if err != nil {
+				return result, err
+			}
+			gen.TaskID = created.ID
+		}
+		result.Requests = append(result.Requests, gen)
+	}
+	result.Generated = len(result.Requests)
+	logger.Info("Generated %d %s requests from the parameter inventory of target %d (%d endpoints, %d skipped)",
+		result.Generated, format, targetID, result.Endpoints, result.Skipped)
+	return result, nil
+}
+
+// inventoryEndpoints groups the target's parameter inventory by method, host and path. The example
+// of an endpoint is its latest entry with a captured request, preferring captured traffic over the
+// confirming requests of parameter mining.
+func inventoryEndpoints(targetID int64, req models.RequestGenerationRequest) ([]*inventoryEndpoint, error) {
+	inventory, err := database.GetTargetParameterizedURLs(targetID) // Most recently seen first
+	if err != nil {
+		return nil, err
+	}
+	host := strings.ToLower(strings.TrimSpace(req.Host))
+	var methods []string
+	for _, m := range req.Methods {
+		methods = append(methods, strings.ToUpper(strings.TrimSpace(m)))
+	}
+
+	byKey := map[string]*inventoryEndpoint{}
+	var endpoints []*inventoryEndpoint
+	for _, p := range inventory {
+		if req.Source != "" && p.Source != req.Source {
+			continue
+		}
+		u, err := url.Parse(p.ExampleFullURL.String)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		method := strings.ToUpper(p.RequestMethod.String)
+		if method == "" {
+			method = "GET"
+		}
+		if (host != "" && strings.ToLower(u.Hostname()) != host) || (len(methods) > 0 && !slices.Contains(methods, method)) ||
+			(req.PathContains != "" && !strings.Contains(u.Path, req.PathContains)) {
+			continue
+		}
+		key := strings.Join([]string{method, u.Scheme, strings.ToLower(u.Host), u.Path}, " ")
+		ep := byKey[key]
+		if ep == nil {
+			ep = &inventoryEndpoint{method: method, url: u, entry: p}
+			byKey[key] = ep
+			endpoints = append(endpoints, ep)
+		} else if p.HTTPTrafficLogID.Valid && (!ep.entry.HTTPTrafficLogID.Valid ||
+			(ep.entry.Source == models.ParameterSourceMining && p.Source != models.ParameterSourceMining)) {
+			ep.url, ep.entry = u, p
+		}
+		ep.ids = append(ep.ids, p.ID)
+		for _, name := range strings.Split(p.ParamKeys, ",") {
+			if name = strings.TrimSpace(name); name != "" && !slices.Contains(ep.names, name) {
+				ep.names = append(ep.names, name)
+			}
+		}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		a, b := endpoints[i], endpoints[j]
+		if a.url.Host != b.url.Host {
+			return a.url.Host < b.url.Host
+		}
+		if a.url.Path != b.url.Path {
+			return a.url.Path < b.url.Path
+		}
+		return a.method < b.method
+	})
+	return endpoints, nil
+}
+
+// fillValueTemplate applies a value template to a parameter. An empty template keeps the value.
+func fillValueTemplate(template, name, value string) string {
+	if template == "" {
+		return value
+	}
+	return strings.NewReplacer("{name}", name, "{value}", value).Replace(template)
+}
+
+// templateRawPairs applies the value template to the parameters of a query string or form body,
+// keeping the order and encoding of the others, and appends the names it does not send yet. The
+// template's own text is not escaped so that {{variables}} and FUZZ stay intact.
+func templateRawPairs(raw string, names []string, template string) string {
+	seen := map[string]bool{}
+	var pairs []string
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(k)
+		if err != nil {
+			name = k
+		}
+		if slices.Contains(names, name) {
+			value, err := url.QueryUnescape(v)
+			if err != nil {
+				value = v
+			}
+			v = fillValueTemplate(template, url.QueryEscape(name), url.QueryEscape(value))
+			seen[name] = true
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+	for _, name := range names {
+		if !seen[name] {
+			pairs = append(pairs, url.QueryEscape(name)+"="+fillValueTemplate(template, url.QueryEscape(name), ""))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// buildEndpointRequest builds the request of an endpoint from its example: the captured request's
+// headers and body when it is still logged, else a bare request of the example URL.
+func buildEndpointRequest(targetID int64, ep *inventoryEndpoint, valueTemplate, nameTemplate string) (models.GeneratedRequest, models.ModifierTask, error) {
+	entry := models.HTTPTrafficLog{RequestMethod: models.NullString(ep.method), RequestURL: models.NullString(ep.url.String())}
+	if ep.entry.HTTPTrafficLogID.Valid {
+		if logged, err := database.GetHTTPTrafficLogEntryByID(ep.entry.HTTPTrafficLogID.Int64); err == nil {
+			entry = logged
+			entry.RequestMethod = models.NullString(ep.method)
+			entry.RequestURL = models.NullString(ep.url.String())
+		}
+	}
+	pe, err := newParamEndpoint(entry)
+	if err != nil {
+		return models.GeneratedRequest{}, models.ModifierTask{}, err
+	}
+
+	// Each parameter is filled where the request sends it; new ones go where its parameters go. The
+	// fields of a captured form or JSON body are parameters too, though the inventory only lists
+	// query strings.
+	names := slices.Clone(ep.names)
+	var bodyNames []string
+	for name := range pe.form {
+		bodyNames = append(bodyNames, name)
+	}
+	for name := range pe.object {
+		bodyNames = append(bodyNames, name)
+	}
+	sort.Strings(bodyNames)
+	for _, name := range bodyNames {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	query := pe.url.Query()
+	var queryNames, formNames, jsonNames []string
+	for _, name := range names {
+		switch {
+		case query.Has(name):
+			queryNames = append(queryNames, name)
+		case pe.form != nil && pe.form.Has(name):
+			formNames = append(formNames, name)
+		case hasJSONKey(pe.object, name):
+			jsonNames = append(jsonNames, name)
+		case pe.location == models.ParamLocationForm:
+			formNames = append(formNames, name)
+		case pe.location == models.ParamLocationJSON:
+			jsonNames = append(jsonNames, name)
+		default:
+			queryNames = append(queryNames, name)
+		}
+	}
+	body := pe.body
+	if len(queryNames) > 0 && (valueTemplate != "" || !allIn(query, queryNames)) {
+		pe.url.RawQuery = templateRawPairs(pe.url.RawQuery, queryNames, valueTemplate)
+	}
+	if len(formNames) > 0 && (valueTemplate != "" || !allIn(pe.form, formNames)) {
+		body = []byte(templateRawPairs(string(pe.body), formNames, valueTemplate))
+	}
+	if len(jsonNames) > 0 {
+		changed := false
+		for _, name := range jsonNames {
+			current, ok := pe.object[name]
+			switch {
+			case !ok:
+				pe.object[name] = fillValueTemplate(valueTemplate, name, "")
+				changed = true
+			case valueTemplate == "":
+			case isJSONScalar(current):
+				pe.object[name] = fillValueTemplate(valueTemplate, name, fmt.Sprint(current))
+				changed = true
+			}
+		}
+		if changed {
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(pe.object); err != nil {
+				return models.GeneratedRequest{}, models.ModifierTask{}, fmt.Errorf("encoding JSON body: %w", err)
+			}
+			body = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+		}
+	}
+
+	params := append(append(append([]string{}, queryNames...), formNames...), jsonNames...)
+	gen := models.GeneratedRequest{
+		Method:              ep.method,
+		URL:                 pe.url.String(),
+		Parameters:          params,
+		ParameterizedURLIDs: ep.ids,
+		SourceLogID:         entry.ID,
+		Name: strings.NewReplacer("{method}", ep.method, "{host}", pe.url.Host, "{path}", pe.url.Path,
+			"{params}", strings.Join(params, ",")).Replace(nameTemplate),
+	}
+	headersJSON, err := json.Marshal(pe.headers)
+	if err != nil {
+		return gen, models.ModifierTask{}, fmt.Errorf("encoding headers: %w", err)
+	}
+	task := models.ModifierTask{
+		TargetID:                 sql.NullInt64{Int64: targetID, Valid: true},
+		Name:                     gen.Name,
+		BaseRequestMethod:        ep.method,
+		BaseRequestURL:           gen.URL,
+		BaseRequestHeaders:       models.NullString(string(headersJSON)),
+		BaseRequestBody:          models.NullString(base64.StdEncoding.EncodeToString(body)),
+		SourceParameterizedURLID: sql.NullInt64{Int64: ep.entry.ID, Valid: true},
+	}
+	if entry.ID != 0 {
+		task.SourceLogID = sql.NullInt64{Int64: entry.ID, Valid: true}
+	}
+	return gen, task, nil
+}
+
+func allIn(values url.Values, names []string) bool {
+	for _, name := range names {
+		if !values.Has(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasJSONKey(object map[string]interface{}, name string) bool {
+	_, ok := object[name]
+	return ok
+}
+
+func isJSONScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// ffufArgs returns the ffuf arguments of a generated request. -ac filters the responses that match
+// ffuf's calibration requests, so only the words that change the answer remain.
+func ffufArgs(gen models.GeneratedRequest, task models.ModifierTask, wordlist string) []string {
+	body, err := base64.StdEncoding.DecodeString(task.BaseRequestBody.String)
+	if err != nil {
+		body = nil
+	}
+	return ffufRequestArgs(gen.Method, gen.URL, HeadersFromJSON(task.BaseRequestHeaders.String), body, wordlist)
+}
+
+// ffufRequestArgs returns the ffuf arguments sending a request with FUZZ placed in its URL, headers
+// or body.
+func ffufRequestArgs(method, rawURL string, headers http.Header, body []byte, wordlist string) []string {
+	args := []string{"-u", rawURL, "-X", method, "-w", wordlist}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range headers[name] {
+			args = append(args, "-H", name+": "+v)
+		}
+	}
+	if len(body) > 0 {
+		args = append(args, "-d", string(body))
+	}
+	return append(args, "-ac")
+}
+
+// ffufCommandLine quotes ffuf arguments into a command line for a shell.
+func ffufCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = a
+		if !plainShellWord.MatchString(a) {
+			quoted[i] = shellQuote([]byte(a))
+		}
+	}
+	return "ffuf " + strings.Join(quoted, " ")
+}
@@ -0,0 +1,501 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// maxSignatureBytes bounds the body read from a probe and the part of a body that is fingerprinted.
+const maxSignatureBytes = 512 << 10
+
+var (
+	signatureTokenRegex = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+	signatureDigitRegex = regexp.MustCompile(`\d+`)
+	titleRegex          = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	probeSuffixes       = []string{"", ".html", "/", ".php", ".json", ".aspx"}
+)
+
+// SignResponse fingerprints a response. The requested path is blanked out of the body and the
+// redirect target first, so pages that echo it ("/xyz was not found") still match each other.
+func SignResponse(source, rawURL string, status int, headers http.Header, body []byte) models.ResponseSignature {
+	if len(body) > maxSignatureBytes {
+		body = body[:maxSignatureBytes]
+	}
+	text := string(body)
+	reflected := reflectedPathForms(rawURL)
+	for _, form := range reflected {
+		text = strings.ReplaceAll(text, form, " ")
+	}
+	sig := models.ResponseSignature{
+		Source:        source,
+		StatusCode:    status,
+		SimHash:       simHash(text),
+		ContentLength: len(body),
+		WordCount:     len(strings.Fields(text)),
+		SampleURL:     rawURL,
+	}
+	if m := titleRegex.FindStringSubmatch(text); m != nil {
+		sig.Title = strings.Join(strings.Fields(m[1]), " ")
+	}
+	if loc := headers.Get("Location"); loc != "" {
+		if base, err := url.Parse(rawURL); err == nil {
+			if ref, err := url.Parse(loc); err == nil {
+				loc = base.ResolveReference(ref).String()
+			}
+		}
+		for _, form := range reflected {
+			loc = strings.ReplaceAll(loc, form, "{path}")
+		}
+		sig.Location = loc
+	}
+	return sig
+}
+
+// reflectedPathForms returns the ways a page may echo the requested path, longest first.
+func reflectedPathForms(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return nil
+	}
+	forms := []string{u.EscapedPath(), u.Path, strings.TrimPrefix(u.Path, "/")}
+	if segment := u.Path[strings.LastIndex(strings.TrimSuffix(u.Path, "/"), "/")+1:]; len(segment) >= 4 {
+		forms = append(forms, strings.TrimSuffix(segment, "/"))
+	}
+	var out []string
+	for _, f := range forms {
+		if len(f) >= 4 && !containsString(out, f) {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	return out
+}
+
+// simHash is the 64-bit simhash of the word bigrams of text, with numbers normalized so counters,
+// timestamps and request IDs do not split otherwise identical pages.
+func simHash(text string) uint64 {
+	tokens := signatureTokenRegex.FindAllString(strings.ToLower(text), -1)
+	if len(tokens) == 0 {
+		return 0
+	}
+	for i, t := range tokens {
+		tokens[i] = signatureDigitRegex.ReplaceAllString(t, "0")
+	}
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		v := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if v&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	if len(tokens) == 1 {
+		add(tokens[0])
+	}
+	for i := 0; i+1 < len(tokens); i++ {
+		add(tokens[i] + " " + tokens[i+1])
+	}
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// SignaturesMatch reports whether two signatures describe the same page: same status, same
+// redirect target for redirects, otherwise bodies at most maxDistance simhash bits apart.
+func SignaturesMatch(a, b models.ResponseSignature, maxDistance int) bool {
+	if a.StatusCode != b.StatusCode {
+		return false
+	}
+	if a.StatusCode >= 300 && a.StatusCode < 400 && (a.Location != "" || b.Location != "") {
+		return a.Location == b.Location
+	}
+	if a.ContentLength == 0 || b.ContentLength == 0 {
+		return a.ContentLength == b.ContentLength
+	}
+	return bits.OnesCount64(a.SimHash^b.SimHash) <= maxDistance
+}
+
+// SoftNotFoundFilter recognises a domain's soft-404 and wildcard pages. Tools requesting guessed
+// paths (brute-forcers, active checks) drop the responses it matches. A nil filter matches nothing.
+type SoftNotFoundFilter struct {
+	baseline    models.ResponseBaseline
+	maxDistance int
+}
+
+// NewSoftNotFoundFilter returns a filter for the signatures of a baseline.
+func NewSoftNotFoundFilter(baseline models.ResponseBaseline) *SoftNotFoundFilter {
+	return &SoftNotFoundFilter{baseline: baseline, maxDistance: baselineMaxDistance()}
+}
+
+// LoadSoftNotFoundFilter returns the filter of a domain, or nil when it has not been baselined.
+func LoadSoftNotFoundFilter(domainID int64) (*SoftNotFoundFilter, error) {
+	baseline, found, err := database.GetResponseBaseline(domainID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return NewSoftNotFoundFilter(baseline), nil
+}
+
+// LoadSoftNotFoundFilterForURL returns the filter of the target's domain serving rawURL, or nil
+// when that domain has not been baselined.
+func LoadSoftNotFoundFilterForURL(targetID int64, rawURL string) (*SoftNotFoundFilter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid URL '%s'", rawURL)
+	}
+	baseline, found, err := database.GetResponseBaselineForHost(targetID, u.Hostname())
+	if err != nil || !found {
+		return nil, err
+	}
+	return NewSoftNotFoundFilter(baseline), nil
+}
+
+// Matches reports whether a response is one of the domain's soft-404/wildcard pages.
+func (f *SoftNotFoundFilter) Matches(rawURL string, status int, headers http.Header, body []byte) bool {
+	if f == nil || len(f.baseline.Signatures) == 0 {
+		return false
+	}
+	sig := SignResponse("", rawURL, status, headers, body)
+	for _, known := range f.baseline.Signatures {
+		if SignaturesMatch(known, sig, f.maxDistance) {
+			return true
+		}
+	}
+	return false
+}
+
+func baselineMaxDistance() int {
+	if d := config.AppConfig.Baseline.MaxDistance; d > 0 {
+		return d
+	}
+	return 6
+}
+
+// ClusterDomainResponses groups the logged responses of a domain into families of near-identical
+// pages and flags the ones that are soft-404 or wildcard pages.
+func ClusterDomainResponses(domainID int64) (models.DomainResponseClusters, error) {
+	domain, err := database.GetDomainByID(domainID)
+	if err != nil {
+		return models.DomainResponseClusters{}, err
+	}
+	baseline, _, err := database.GetResponseBaseline(domainID)
+	if err != nil {
+		return models.DomainResponseClusters{}, err
+	}
+	var probes []models.ResponseSignature
+	for _, sig := range baseline.Signatures {
+		if sig.Source == models.SignatureSourceProbe {
+			probes = append(probes, sig)
+		}
+	}
+	return clusterLoggedResponses(*domain, probes)
+}
+
+type responseCluster struct {
+	leader   models.ResponseSignature
+	members  int
+	totalLen int
+	paths    map[string]bool
+	tops     map[string]bool // First path segments
+	samples  []string
+}
+
+func clusterLoggedResponses(domain models.Domain, probes []models.ResponseSignature) (models.DomainResponseClusters, error) {
+	conf := config.AppConfig.Baseline
+	base := domainBaseURL(domain)
+	limit := conf.MaxResponses
+	if limit <= 0 {
+		limit = 2000
+	}
+	minPaths := conf.MinClusterPaths
+	if minPaths <= 0 {
+		minPaths = 5
+	}
+	maxDistance := baselineMaxDistance()
+	result := models.DomainResponseClusters{DomainID: domain.ID, BaseURL: base, Clusters: []models.ResponseCluster{}}
+
+	entries, err := database.GetLoggedResponsesForHost(domain.TargetID, base, limit)
+	if err != nil {
+		return result, err
+	}
+	var clusters []*responseCluster
+	for _, e := range entries {
+		if !isPageContentType(e.ResponseContentType.String) {
+			continue
+		}
+		headers := HeadersFromJSON(e.ResponseHeaders.String)
+		body, err := DecodeContentEncoding(e.ResponseBody, headers.Get("Content-Encoding"))
+		if err != nil {
+			logger.Debug("Response clustering: Could not decode body of log %d: %v", e.ID, err)
+		}
+		sig := SignResponse(models.SignatureSourceCluster, e.RequestURL.String, e.ResponseStatusCode, headers, body)
+		result.Responses++
+
+		var cluster *responseCluster
+		for _, c := range clusters {
+			if SignaturesMatch(c.leader, sig, maxDistance) {
+				cluster = c
+				break
+			}
+		}
+		if cluster == nil {
+			cluster = &responseCluster{leader: sig, paths: make(map[string]bool), tops: make(map[string]bool)}
+			clusters = append(clusters, cluster)
+		}
+		cluster.members++
+		cluster.totalLen += sig.ContentLength
+		if u, err := url.Parse(e.RequestURL.String); err == nil {
+			path := u.EscapedPath()
+			if !cluster.paths[path] && len(cluster.samples) < 5 {
+				cluster.samples = append(cluster.samples, e.RequestURL.String)
+			}
+			cluster.paths[path] = true
+			top, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+			cluster.tops[top] = true
+		}
+	}
+
+	for _, c := range clusters {
+		rc := models.ResponseCluster{
+			StatusCode: c.leader.StatusCode,
+			SimHash:    c.leader.SimHash,
+			Title:      c.leader.Title,
+			Responses:  c.members,
+			PathCount:  len(c.paths),
+			AvgLength:  c.totalLen / c.members,
+			SampleURLs: c.samples,
+		}
+		if rc.StatusCode != http.StatusNotFound {
+			for _, p := range probes {
+				if SignaturesMatch(p, c.leader, maxDistance) {
+					rc.IsSoftNotFound = true
+					break
+				}
+			}
+			// The same page for many unrelated top-level paths is a catch-all (SPA fallback, wildcard vhost).
+			if len(c.tops) >= minPaths && rc.StatusCode < 400 {
+				rc.IsSoftNotFound = true
+			}
+		}
+		result.Clusters = append(result.Clusters, rc)
+	}
+	sort.SliceStable(result.Clusters, func(i, j int) bool { return result.Clusters[i].Responses > result.Clusters[j].Responses })
+	return result, nil
+}
+
+// isPageContentType reports whether responses of a content type are pages a guessed path could
+// return, as opposed to images, fonts, scripts and the like.
+func isPageContentType(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	if ct == "" {
+		return true
+	}
+	for _, page := range []string{"html", "json", "xml", "text/plain"} {
+		if strings.Contains(ct, page) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartResponseBaseline starts a job probing random paths of a target's domains and clustering
+// their logged responses, storing the soft-404/wildcard signatures of each domain.
+func StartResponseBaseline(targetID int64, req models.ResponseBaselineRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	domains, err := domainsToEnrich(targetID, req.DomainIDs)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(domains) == 0 {
+		return models.Job{}, fmt.Errorf("no domains to baseline (run httpx first or pass domain_ids)")
+	}
+	latest, err := LatestJob(models.JobTypeResponseBaseline, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a response baseline job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	conf := config.AppConfig.Baseline
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, targetID)),
+		// The redirect itself is the soft-404 signature of hosts that bounce unknown paths to /login or /.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return StartJob(models.JobTypeResponseBaseline, &targetID, fmt.Sprintf("Baselining responses of %d domains...", len(domains)), func(ctx context.Context, job *JobHandle) error {
+		job.SetTotal(int64(len(domains)))
+		workers := conf.Workers
+		if workers <= 0 {
+			workers = 5
+		}
+		var mu sync.Mutex
+		wildcards := 0
+		domainCh := make(chan models.Domain)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range domainCh {
+					baseline, err := baselineDomain(ctx, client, d)
+					if err != nil {
+						job.RecordError(err)
+						job.AddProgress(1, 0)
+						continue
+					}
+					if baseline.IsWildcard {
+						mu.Lock()
+						wildcards++
+						mu.Unlock()
+					}
+					job.AddProgress(1, 1)
+				}
+			}()
+		}
+	feed:
+		for _, d := range domains {
+			select {
+			case <-ctx.Done():
+				break feed
+			case domainCh <- d:
+			}
+		}
+		close(domainCh)
+		wg.Wait()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		snap := job.Snapshot()
+		job.SetMessage("Baselined %d of %d domains, %d serve soft-404 or wildcard pages (%d errors).", snap.ItemsSucceeded, len(domains), wildcards, snap.ErrorCount)
+		return nil
+	})
+}
+
+// baselineDomain probes random paths of a domain, clusters its logged responses and stores the
+// resulting signatures.
+func baselineDomain(ctx context.Context, client *http.Client, d models.Domain) (models.ResponseBaseline, error) {
+	maxDistance := baselineMaxDistance()
+	baseline := models.ResponseBaseline{DomainID: d.ID, TargetID: d.TargetID, BaseURL: domainBaseURL(d), Signatures: []models.ResponseSignature{}}
+	addSignature := func(sig models.ResponseSignature) {
+		for _, known := range baseline.Signatures {
+			if SignaturesMatch(known, sig, maxDistance) {
+				return
+			}
+		}
+		baseline.Signatures = append(baseline.Signatures, sig)
+	}
+
+	probeCount := config.AppConfig.Baseline.ProbeCount
+	if probeCount <= 0 {
+		probeCount = 3
+	}
+	var probeErrors []string
+	for i := 0; i < probeCount && ctx.Err() == nil; i++ {
+		sig, err := probeRandomPath(ctx, client, baseline.BaseURL, probeSuffixes[i%len(probeSuffixes)])
+		if err != nil {
+			probeErrors = append(probeErrors, err.Error())
+			continue
+		}
+		if sig.StatusCode != http.StatusNotFound {
+			baseline.IsWildcard = true
+		}
+		addSignature(sig)
+	}
+	if ctx.Err() != nil {
+		return baseline, ctx.Err()
+	}
+	if len(probeErrors) == probeCount {
+		baseline.LastError = probeErrors[0]
+	}
+
+	probes := append([]models.ResponseSignature(nil), baseline.Signatures...)
+	clusters, err := clusterLoggedResponses(d, probes)
+	if err != nil {
+		return baseline, err
+	}
+	baseline.ResponsesClustered = clusters.Responses
+	for _, c := range clusters.Clusters {
+		if !c.IsSoftNotFound {
+			continue
+		}
+		baseline.IsWildcard = true
+		sample := ""
+		if len(c.SampleURLs) > 0 {
+			sample = c.SampleURLs[0]
+		}
+		addSignature(models.ResponseSignature{
+			Source:        models.SignatureSourceCluster,
+			StatusCode:    c.StatusCode,
+			SimHash:       c.SimHash,
+			ContentLength: c.AvgLength,
+			Title:         c.Title,
+			SampleURL:     sample,
+			PathCount:     c.PathCount,
+		})
+	}
+
+	if err := database.SaveResponseBaseline(baseline); err != nil {
+		return baseline, err
+	}
+	if baseline.LastError != "" && clusters.Responses == 0 {
+		return baseline, fmt.Errorf("baselining %s: %s", baseline.BaseURL, baseline.LastError)
+	}
+	return baseline, nil
+}
+
+// probeRandomPath requests a path that should not exist and fingerprints the answer.
+func probeRandomPath(ctx context.Context, client *http.Client, base, suffix string) (models.ResponseSignature, error) {
+	token := make([]byte, 12)
+	if _, err := rand.Read(token); err != nil {
+		return models.ResponseSignature{}, fmt.Errorf("generating probe path: %w", err)
+	}
+	probeURL := base + "/" + hex.EncodeToString(token) + suffix
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return models.ResponseSignature{}, fmt.Errorf("creating request for %s: %w", probeURL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return models.ResponseSignature{}, fmt.Errorf("requesting %s: %w", probeURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureBytes))
+	if err != nil {
+		return models.ResponseSignature{}, fmt.Errorf("reading %s: %w", probeURL, err)
+	}
+	return SignResponse(models.SignatureSourceProbe, probeURL, resp.StatusCode, resp.Header, body), nil
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// responseCacheLogIDHeader marks a response answered by the response cache to a client of
+// RateLimitedTransport, with the ID of the log entry it was reused from. It is not stored.
+const responseCacheLogIDHeader = "X-Toolkit-Cache-Log-ID"
+
+// fingerprintSkippedHeaders differ between otherwise identical requests without changing what the
+// target answers, so they are left out of request fingerprints.
+var fingerprintSkippedHeaders = map[string]bool{
+	"Connection": true, "Proxy-Connection": true, "Keep-Alive": true, "Content-Length": true,
+	"Date": true, "Traceparent": true, "Tracestate": true, "X-Request-Id": true,
+	"X-Correlation-Id": true, "X-Amzn-Trace-Id": true,
+}
+
+// responseCacheKey returns the fingerprint of a request whose response the response cache may reuse:
+// a hash of its method, URL, Host, headers other than the toolkit's own and body. It is empty when
+// the cache is disabled or does not cover the method.
+func responseCacheKey(method, rawURL, host string, header http.Header, body []byte) string {
+	conf := config.AppConfig.Cache
+	if !conf.Enabled || !slices.ContainsFunc(conf.Methods, func(m string) bool { return strings.EqualFold(m, method) }) {
+		return ""
+	}
+	names := make([]string, 0, len(header))
+	for name := range header {
+		canonical := http.CanonicalHeaderKey(name)
+		if fingerprintSkippedHeaders[canonical] || isToolkitHeader(canonical) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\nHost: %s\n", strings.ToUpper(method), rawURL, host)
+	for _, name := range names {
+		for _, v := range header[name] {
+			fmt.Fprintf(h, "%s: %s\n", http.CanonicalHeaderKey(name), v)
+		}
+	}
+	h.Write([]byte("\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestCacheKey returns the response cache fingerprint of an outgoing request. Requests whose
+// body cannot be read again have none.
+func requestCacheKey(req *http.Request) string {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return ""
+		}
+		rc, err := req.GetBody()
+		if err != nil {
+			return ""
+		}
+		body, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return ""
+		}
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	return responseCacheKey(req.Method, req.URL.String(), host, req.Header, body)
+}
+
+// cachedResponse returns the response logged for a request with the fingerprint within the
+// response_cache window, rebuilt for req, and the ID of the log entry it comes from. It is nil
+// when there is none.
+func cachedResponse(key string, req *http.Request) (*http.Response, int64) {
+	if key == "" {
+		return nil, 0
+	}
+	window := time.Duration(config.AppConfig.Cache.WindowSeconds) * time.Second
+	entry, found, err := database.GetReusableResponse(key, time.Now().Add(-window))
+	if err != nil {
+		logger.Error("Response cache: %v", err)
+		return nil, 0
+	}
+	if !found {
+		return nil, 0
+	}
+
+	header := HeadersFromJSON(entry.ResponseHeaders.String)
+	header.Del("Transfer-Encoding")
+	if req.Method != http.MethodHead {
+		header.Set("Content-Length", strconv.Itoa(len(entry.ResponseBody)))
+	}
+	proto := entry.ResponseHTTPVersion.String
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+	status := strconv.Itoa(entry.ResponseStatusCode)
+	if entry.ResponseReasonPhrase.String != "" {
+		status += " " + entry.ResponseReasonPhrase.String
+	}
+	logger.Debug("Response cache: %s %s answered with the response of log %d", req.Method, req.URL.String(), entry.ID)
+	return &http.Response{
+		Status:        status,
+		StatusCode:    entry.ResponseStatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.ResponseBody)),
+		ContentLength: int64(len(entry.ResponseBody)),
+		Request:       req,
+	}, entry.ID
+}
+
+// setResponseCacheFields records on a log entry about to be stored either the entry its response
+// was reused from, taken from the responseCacheLogIDHeader of the response, or the fingerprint
+// under which the response may be reused later.
+func setResponseCacheFields(entry *models.HTTPTrafficLog, respHeader http.Header, key string) {
+	if id, err := strconv.ParseInt(respHeader.Get(responseCacheLogIDHeader), 10, 64); err == nil {
+		entry.CachedFromLogID = sql.NullInt64{Int64: id, Valid: true}
+		return
+	}
+	entry.RequestFingerprint = models.NullString(key)
+}
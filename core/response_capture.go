@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// errCaptureIncomplete is reported when a captured body is closed before it was read to the end,
+// typically because the client went away mid-download.
+var errCaptureIncomplete = errors.New("body closed before it was fully read")
+
+// capturedBody wraps a response body on its way to the client. It keeps the first limit bytes for
+// the traffic log and hashes and counts the whole stream, so large downloads pass through without
+// being held in memory. onDone runs once, when the body reaches EOF, fails or is closed.
+type capturedBody struct {
+	body       io.ReadCloser
+	limit      int64 // <= 0 keeps the whole body
+	buf        bytes.Buffer
+	hash       hash.Hash
+	size       int64
+	incomplete bool // The stream failed or was closed before EOF
+	once       sync.Once
+	onDone     func(c *capturedBody, err error)
+}
+
+func newCapturedBody(body io.ReadCloser, limit int64, onDone func(c *capturedBody, err error)) *capturedBody {
+	return &capturedBody{body: body, limit: limit, hash: sha256.New(), onDone: onDone}
+}
+
+func (c *capturedBody) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	if n > 0 {
+		c.hash.Write(p[:n])
+		c.size += int64(n)
+		keep := int64(n)
+		if c.limit > 0 {
+			keep = min64(keep, c.limit-int64(c.buf.Len()))
+		}
+		if keep > 0 {
+			c.buf.Write(p[:keep])
+		}
+	}
+	if err == io.EOF {
+		c.finish(nil)
+	} else if err != nil {
+		c.finish(err)
+	}
+	return n, err
+}
+
+func (c *capturedBody) Close() error {
+	err := c.body.Close()
+	if c.body == http.NoBody {
+		// Responses without a body (HEAD, 204, 304) are closed without being read
+		c.finish(nil)
+	}
+	c.finish(errCaptureIncomplete)
+	return err
+}
+
+func (c *capturedBody) finish(err error) {
+	c.once.Do(func() {
+		c.incomplete = err != nil
+		c.onDone(c, err)
+	})
+}
+
+// Captured returns the stored prefix of the body.
+func (c *capturedBody) Captured() []byte { return c.buf.Bytes() }
+
+// Size returns the number of bytes streamed so far.
+func (c *capturedBody) Size() int64 { return c.size }
+
+// Truncated reports whether the stored prefix is not the whole body: bytes were streamed past the
+// capture limit, or the stream failed or was closed before EOF.
+func (c *capturedBody) Truncated() bool { return c.incomplete || c.size > int64(c.buf.Len()) }
+
+// SHA256 returns the hex hash of the bytes streamed so far.
+func (c *capturedBody) SHA256() string { return hex.EncodeToString(c.hash.Sum(nil)) }
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
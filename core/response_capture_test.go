@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCapturedBodyMarksIncompleteBodiesTruncated(t *testing.T) {
+	capture := func(body io.ReadCloser, limit int64) (*capturedBody, *error, *int) {
+		var got error
+		calls := 0
+		return newCapturedBody(body, limit, func(_ *capturedBody, err error) {
+			got = err
+			calls++
+		}), &got, &calls
+	}
+
+	// The client goes away after part of a body that fits under the limit.
+	c, got, calls := capture(io.NopCloser(strings.NewReader("0123456789")), 1024)
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if *calls != 1 || !errors.Is(*got, errCaptureIncomplete) || !c.Truncated() || c.Size() != 4 {
+		t.Errorf("closed early: onDone ran %d times with %v, truncated %t, size %d", *calls, *got, c.Truncated(), c.Size())
+	}
+
+	// A body read to EOF stays complete when it is closed afterwards.
+	c, got, calls = capture(io.NopCloser(strings.NewReader("0123456789")), 1024)
+	if _, err := io.ReadAll(c); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if *calls != 1 || *got != nil || c.Truncated() || string(c.Captured()) != "0123456789" {
+		t.Errorf("read to EOF: onDone ran %d times with %v, truncated %t, captured %q", *calls, *got, c.Truncated(), c.Captured())
+	}
+
+	// Bytes past the limit still truncate a complete body, and a body-less response is complete.
+	c, _, _ = capture(io.NopCloser(strings.NewReader("0123456789")), 4)
+	io.ReadAll(c)
+	if !c.Truncated() || string(c.Captured()) != "0123" {
+		t.Errorf("over the limit: truncated %t, captured %q", c.Truncated(), c.Captured())
+	}
+	c, got, _ = capture(http.NoBody, 4)
+	c.Close()
+	if *got != nil || c.Truncated() {
+		t.Errorf("no body: onDone got %v, truncated %t", *got, c.Truncated())
+	}
+}
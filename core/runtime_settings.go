@@ -0,0 +1,397 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+var (
+	runtimeSettingsMu sync.Mutex
+	// fileConfig is config.AppConfig as loaded from config.yaml, before stored runtime settings
+	// were applied over it. Resetting a setting restores its value from here.
+	fileConfig       config.Configuration
+	fileConfigLoaded bool
+
+	targetRuntimeOverridesMu sync.RWMutex
+	targetRuntimeOverrides   = make(map[int64]map[string]interface{}) // Parsed overrides per target, loaded on first use
+
+	runtimeServicesCtx    context.Context
+	runtimeMissionService *SynackMissionService
+)
+
+// runtimeSettingListeners are notified once per update when settings of their config section
+// changed, so running subsystems pick up the new values without a restart. Sections without a
+// listener are read from config.AppConfig on each use.
+var runtimeSettingListeners = map[string]func(){
+	"rate_limit":     func() { ReconfigureRateLimiter(config.AppConfig.RateLimit) },
+	"missions":       applyMissionsRuntimeSettings,
+	"synack_watcher": applySynackWatcherRuntimeSettings,
+}
+
+// RegisterRuntimeServices gives the settings API the long-running services it starts and stops
+// when their toggles change. ctx is the lifetime of the services.
+func RegisterRuntimeServices(ctx context.Context, missions *SynackMissionService) {
+	runtimeSettingsMu.Lock()
+	defer runtimeSettingsMu.Unlock()
+	runtimeServicesCtx = ctx
+	runtimeMissionService = missions
+}
+
+// ApplyStoredRuntimeSettings applies the runtime settings stored through the settings API over the
+// configuration loaded from file. It is called once the database is initialized.
+func ApplyStoredRuntimeSettings() error {
+	runtimeSettingsMu.Lock()
+	defer runtimeSettingsMu.Unlock()
+	fileConfig = config.AppConfig
+	fileConfigLoaded = true
+
+	stored, err := database.GetGlobalRuntimeSettings()
+	if err != nil {
+		return err
+	}
+	for key, raw := range stored {
+		s, ok := config.LookupRuntimeSetting(key)
+		if !ok {
+			logger.Warn("Runtime settings: Ignoring unknown stored setting '%s'", key)
+			continue
+		}
+		value, err := s.Parse(json.RawMessage(raw))
+		if err != nil {
+			logger.Warn("Runtime settings: Ignoring stored value of %s: %v", key, err)
+			continue
+		}
+		if err := s.Apply(&config.AppConfig, value); err != nil {
+			logger.Warn("Runtime settings: %v", err)
+			continue
+		}
+		logger.Info("Runtime settings: %s = %s (stored, overrides config file)", key, raw)
+	}
+	return nil
+}
+
+func ensureFileConfigLocked() {
+	if !fileConfigLoaded {
+		fileConfig = config.AppConfig
+		fileConfigLoaded = true
+	}
+}
+
+// GetRuntimeSettings returns every runtime setting with its current, file and stored value.
+func GetRuntimeSettings() ([]models.RuntimeSettingView, error) {
+	runtimeSettingsMu.Lock()
+	defer runtimeSettingsMu.Unlock()
+	return runtimeSettingViewsLocked()
+}
+
+func runtimeSettingViewsLocked() ([]models.RuntimeSettingView, error) {
+	ensureFileConfigLocked()
+	stored, err := database.GetGlobalRuntimeSettings()
+	if err != nil {
+		return nil, err
+	}
+	views := make([]models.RuntimeSettingView, 0, len(config.RuntimeSettings()))
+	for _, s := range config.RuntimeSettings() {
+		view := newRuntimeSettingView(s)
+		view.Value = s.Value(&config.AppConfig)
+		view.FileValue = s.Value(&fileConfig)
+		if raw, ok := stored[s.Key]; ok {
+			view.Overridden = true
+			if s.RequiresRestart {
+				if pending, err := s.Parse(json.RawMessage(raw)); err == nil && fmt.Sprint(pending) != fmt.Sprint(view.Value) {
+					view.PendingValue = pending
+					view.PendingRestart = true
+				}
+			}
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func newRuntimeSettingView(s config.RuntimeSetting) models.RuntimeSettingView {
+	return models.RuntimeSettingView{
+		Key:             s.Key,
+		Type:            s.Type,
+		Description:     s.Description,
+		Min:             s.Min,
+		PerTarget:       s.PerTarget,
+		RequiresRestart: s.RequiresRestart,
+	}
+}
+
+// parsedRuntimeSetting is one validated entry of a settings update. value is nil for a reset.
+type parsedRuntimeSetting struct {
+	setting config.RuntimeSetting
+	raw     string
+	value   interface{}
+}
+
+// parseRuntimeSettingsUpdate validates every entry of an update before anything is stored, in key
+// order so results and errors are stable.
+func parseRuntimeSettingsUpdate(update models.RuntimeSettingsUpdate, perTarget bool) ([]parsedRuntimeSetting, error) {
+	if len(update) == 0 {
+		return nil, fmt.Errorf("invalid runtime settings: no settings given")
+	}
+	keys := make([]string, 0, len(update))
+	for key := range update {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parsed := make([]parsedRuntimeSetting, 0, len(keys))
+	for _, key := range keys {
+		s, ok := config.LookupRuntimeSetting(key)
+		if !ok {
+			return nil, fmt.Errorf("invalid runtime settings: unknown setting '%s'", key)
+		}
+		if perTarget && !s.PerTarget {
+			return nil, fmt.Errorf("invalid runtime settings: %s cannot be set per target", key)
+		}
+		raw := update[key]
+		if len(raw) == 0 || string(raw) == "null" {
+			parsed = append(parsed, parsedRuntimeSetting{setting: s})
+			continue
+		}
+		value, err := s.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid runtime settings: %w", err)
+		}
+		encoded, _ := json.Marshal(value)
+		parsed = append(parsed, parsedRuntimeSetting{setting: s, raw: string(encoded), value: value})
+	}
+	return parsed, nil
+}
+
+// UpdateRuntimeSettings validates, stores and applies global runtime settings, then notifies the
+// subsystems whose settings changed. Settings that require a restart are stored but left as they
+// are in the running process.
+func UpdateRuntimeSettings(update models.RuntimeSettingsUpdate) (models.RuntimeSettingsUpdateResult, error) {
+	result := models.RuntimeSettingsUpdateResult{Updated: []string{}, Reset: []string{}, RestartRequired: []string{}}
+	parsed, err := parseRuntimeSettingsUpdate(update, false)
+	if err != nil {
+		return result, err
+	}
+
+	runtimeSettingsMu.Lock()
+	ensureFileConfigLocked()
+	changedSections := make(map[string]bool)
+	for _, p := range parsed {
+		s := p.setting
+		if p.value == nil {
+			if err := database.DeleteGlobalRuntimeSetting(s.Key); err != nil {
+				runtimeSettingsMu.Unlock()
+				return result, err
+			}
+			result.Reset = append(result.Reset, s.Key)
+		} else {
+			if err := database.SetGlobalRuntimeSetting(s.Key, p.raw); err != nil {
+				runtimeSettingsMu.Unlock()
+				return result, err
+			}
+			result.Updated = append(result.Updated, s.Key)
+		}
+
+		if s.RequiresRestart {
+			result.RestartRequired = append(result.RestartRequired, s.Key)
+			continue
+		}
+		if p.value == nil {
+			s.Copy(&config.AppConfig, &fileConfig)
+		} else if err := s.Apply(&config.AppConfig, p.value); err != nil {
+			runtimeSettingsMu.Unlock()
+			return result, err
+		}
+		changedSections[s.Section()] = true
+	}
+	runtimeSettingsMu.Unlock()
+
+	logger.Info("Runtime settings: Updated %v, reset %v", result.Updated, result.Reset)
+	for section := range changedSections {
+		NotifyRuntimeSettingsChanged(section)
+	}
+
+	result.Settings, err = GetRuntimeSettings()
+	return result, err
+}
+
+// NotifyRuntimeSettingsChanged tells the subsystem of a config section that its settings in
+// config.AppConfig changed.
+func NotifyRuntimeSettingsChanged(section string) {
+	if listener, ok := runtimeSettingListeners[section]; ok {
+		listener()
+	}
+}
+
+// RuntimeSettingsSavedToFile is called when another settings endpoint saved a section of
+// config.AppConfig to config.yaml. Stored values of that section are dropped so the file is not
+// overridden with stale values on the next start.
+func RuntimeSettingsSavedToFile(section string) error {
+	runtimeSettingsMu.Lock()
+	defer runtimeSettingsMu.Unlock()
+	ensureFileConfigLocked()
+	for _, s := range config.RuntimeSettings() {
+		if s.Section() != section || s.RequiresRestart {
+			continue
+		}
+		if err := database.DeleteGlobalRuntimeSetting(s.Key); err != nil {
+			return err
+		}
+		s.Copy(&fileConfig, &config.AppConfig)
+	}
+	return nil
+}
+
+// GetTargetRuntimeSettings returns the settings that can be overridden per target with their
+// effective value for the target.
+func GetTargetRuntimeSettings(targetID int64) ([]models.RuntimeSettingView, error) {
+	overrides, err := loadTargetRuntimeOverrides(targetID)
+	if err != nil {
+		return nil, err
+	}
+	runtimeSettingsMu.Lock()
+	ensureFileConfigLocked()
+	fileConf := fileConfig
+	runtimeSettingsMu.Unlock()
+	effective := ConfigForTarget(&targetID)
+
+	var views []models.RuntimeSettingView
+	for _, s := range config.RuntimeSettings() {
+		if !s.PerTarget {
+			continue
+		}
+		view := newRuntimeSettingView(s)
+		view.Value = s.Value(&effective)
+		view.FileValue = s.Value(&fileConf)
+		view.GlobalValue = s.Value(&config.AppConfig)
+		_, view.Overridden = overrides[s.Key]
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// UpdateTargetRuntimeSettings validates and stores per-target overrides. They apply to traffic
+// of the target from the next request on.
+func UpdateTargetRuntimeSettings(targetID int64, update models.RuntimeSettingsUpdate) (models.RuntimeSettingsUpdateResult, error) {
+	result := models.RuntimeSettingsUpdateResult{Updated: []string{}, Reset: []string{}, RestartRequired: []string{}}
+	parsed, err := parseRuntimeSettingsUpdate(update, true)
+	if err != nil {
+		return result, err
+	}
+
+	for _, p := range parsed {
+		if p.value == nil {
+			err = database.DeleteTargetRuntimeSetting(targetID, p.setting.Key)
+			result.Reset = append(result.Reset, p.setting.Key)
+		} else {
+			err = database.SetTargetRuntimeSetting(targetID, p.setting.Key, p.raw)
+			result.Updated = append(result.Updated, p.setting.Key)
+		}
+		if err != nil {
+			break
+		}
+	}
+	// Dropped even on error so the next use reloads whatever was stored
+	targetRuntimeOverridesMu.Lock()
+	delete(targetRuntimeOverrides, targetID)
+	targetRuntimeOverridesMu.Unlock()
+	if err != nil {
+		return result, err
+	}
+
+	logger.Info("Runtime settings: Target %d updated %v, reset %v", targetID, result.Updated, result.Reset)
+	result.Settings, err = GetTargetRuntimeSettings(targetID)
+	return result, err
+}
+
+// loadTargetRuntimeOverrides returns the parsed overrides of a target, from the cache when loaded.
+func loadTargetRuntimeOverrides(targetID int64) (map[string]interface{}, error) {
+	targetRuntimeOverridesMu.RLock()
+	overrides, ok := targetRuntimeOverrides[targetID]
+	targetRuntimeOverridesMu.RUnlock()
+	if ok {
+		return overrides, nil
+	}
+
+	stored, err := database.GetTargetRuntimeSettings(targetID)
+	if err != nil {
+		return nil, err
+	}
+	overrides = make(map[string]interface{}, len(stored))
+	for key, raw := range stored {
+		s, ok := config.LookupRuntimeSetting(key)
+		if !ok || !s.PerTarget {
+			logger.Warn("Runtime settings: Ignoring stored setting '%s' of target %d", key, targetID)
+			continue
+		}
+		value, err := s.Parse(json.RawMessage(raw))
+		if err != nil {
+			logger.Warn("Runtime settings: Ignoring stored value of %s for target %d: %v", key, targetID, err)
+			continue
+		}
+		overrides[key] = value
+	}
+	targetRuntimeOverridesMu.Lock()
+	targetRuntimeOverrides[targetID] = overrides
+	targetRuntimeOverridesMu.Unlock()
+	return overrides, nil
+}
+
+// ConfigForTarget returns config.AppConfig with the runtime setting overrides of the target
+// applied. A nil or zero target returns the global configuration.
+func ConfigForTarget(targetID *int64) config.Configuration {
+	conf := config.AppConfig
+	if targetID == nil || *targetID == 0 {
+		return conf
+	}
+	overrides, err := loadTargetRuntimeOverrides(*targetID)
+	if err != nil {
+		logger.Error("Runtime settings: Using global settings for target %d: %v", *targetID, err)
+		return conf
+	}
+	for key, value := range overrides {
+		if s, ok := config.LookupRuntimeSetting(key); ok {
+			s.Apply(&conf, value)
+		}
+	}
+	return conf
+}
+
+func applyMissionsRuntimeSettings() {
+	runtimeSettingsMu.Lock()
+	missions := runtimeMissionService
+	runtimeSettingsMu.Unlock()
+	if missions == nil {
+		logger.Info("Runtime settings: Mission polling changes apply on the next start (no mission service in this process)")
+		return
+	}
+	// A disabled service leaves its poll loop on the next tick
+	if config.AppConfig.Missions.Enabled {
+		missions.Start()
+	}
+}
+
+func applySynackWatcherRuntimeSettings() {
+	if !config.AppConfig.Watcher.Enabled {
+		if StopSynackWatcher() {
+			logger.Info("Runtime settings: Synack watcher stopped")
+		}
+		return
+	}
+	runtimeSettingsMu.Lock()
+	ctx := runtimeServicesCtx
+	runtimeSettingsMu.Unlock()
+	if ctx == nil {
+		logger.Info("Runtime settings: Synack watcher changes apply on the next start (no services running in this process)")
+		return
+	}
+	if StartSynackWatcher(ctx) {
+		logger.Info("Runtime settings: Synack watcher started")
+	}
+}
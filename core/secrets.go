@@ -0,0 +1,286 @@
+package core
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// SecretRule is a compiled secret detection rule.
+type SecretRule struct {
+	Name        string   `json:"name"`
+	Pattern     string   `json:"pattern"`
+	SecretGroup int      `json:"secret_group"`
+	MinEntropy  float64  `json:"min_entropy,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	Custom      bool     `json:"custom"`
+	generic     bool     // Catch-all rule; evaluated last and skipped for secrets another rule already matched
+	re          *regexp.Regexp
+}
+
+// SecretMatch is a single hit of a rule in a body.
+type SecretMatch struct {
+	RuleName string
+	Secret   string
+	Excerpt  string
+	Entropy  float64
+}
+
+// builtinSecretRules are gitleaks-style rules for common providers. Generic rules rely on
+// an entropy threshold to keep placeholder values out.
+var builtinSecretRules = []SecretRule{
+	{Name: "AWS Access Key ID", Pattern: `\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16})\b`, SecretGroup: 1},
+	{Name: "AWS Secret Access Key", Pattern: `(?i)aws.{0,20}(?:secret|private).{0,20}['"]([0-9a-zA-Z/+]{40})['"]`, SecretGroup: 1, MinEntropy: 4.0, Keywords: []string{"aws"}},
+	{Name: "GitHub Token", Pattern: `\b(gh[pousr]_[A-Za-z0-9]{36,255})\b`, SecretGroup: 1, Keywords: []string{"gh"}},
+	{Name: "GitHub Fine-Grained Token", Pattern: `\b(github_pat_[A-Za-z0-9_]{82})\b`, SecretGroup: 1, Keywords: []string{"github_pat_"}},
+	{Name: "GitLab Personal Access Token", Pattern: `\b(glpat-[A-Za-z0-9\-_]{20})\b`, SecretGroup: 1, Keywords: []string{"glpat-"}},
+	{Name: "Slack Token", Pattern: `\b(xox[baprs]-[0-9A-Za-z-]{10,})\b`, SecretGroup: 1, Keywords: []string{"xox"}},
+	{Name: "Slack Webhook", Pattern: `https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+`, Keywords: []string{"hooks.slack.com"}},
+	{Name: "Stripe Secret Key", Pattern: `\b((?:sk|rk)_live_[0-9a-zA-Z]{24,})\b`, SecretGroup: 1, Keywords: []string{"_live_"}},
+	{Name: "Google API Key", Pattern: `\b(AIza[0-9A-Za-z\-_]{35})\b`, SecretGroup: 1, Keywords: []string{"aiza"}},
+	{Name: "Google OAuth Client Secret", Pattern: `\b(GOCSPX-[A-Za-z0-9_-]{28})\b`, SecretGroup: 1, Keywords: []string{"gocspx-"}},
+	{Name: "Private Key", Pattern: `-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`, Keywords: []string{"private key"}},
+	{Name: "JSON Web Token", Pattern: `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`, SecretGroup: 1, Keywords: []string{"eyj"}},
+	{Name: "Twilio API Key", Pattern: `\b(SK[0-9a-fA-F]{32})\b`, SecretGroup: 1, MinEntropy: 3.0},
+	{Name: "SendGrid API Key", Pattern: `\b(SG\.[A-Za-z0-9_-]{22}\.[A-Za-z0-9_-]{43})\b`, SecretGroup: 1, Keywords: []string{"sg."}},
+	{Name: "Mailgun API Key", Pattern: `\b(key-[0-9a-zA-Z]{32})\b`, SecretGroup: 1, MinEntropy: 3.5, Keywords: []string{"key-"}},
+	{Name: "npm Access Token", Pattern: `\b(npm_[A-Za-z0-9]{36})\b`, SecretGroup: 1, Keywords: []string{"npm_"}},
+	{Name: "Generic API Key", Pattern: `(?i)(?:api[_-]?key|apikey|secret[_-]?key|client[_-]?secret|access[_-]?token|auth[_-]?token|private[_-]?key)["']?\s*[:=]\s*["']([A-Za-z0-9_\-./+=]{16,128})["']`, SecretGroup: 1, MinEntropy: 3.5, generic: true},
+}
+
+var (
+	secretRules     []SecretRule
+	secretRulesOnce sync.Once
+	secretRulesMu   sync.RWMutex
+)
+
+// ReloadSecretRules compiles the built-in rules plus the custom rules from config.AppConfig.Secrets.
+// Invalid custom rules are logged and skipped.
+func ReloadSecretRules() {
+	conf := config.AppConfig.Secrets
+	disabled := make(map[string]bool)
+	for _, name := range conf.DisabledRules {
+		disabled[strings.ToLower(name)] = true
+	}
+
+	var rules, genericRules []SecretRule
+	for _, r := range builtinSecretRules {
+		if disabled[strings.ToLower(r.Name)] {
+			continue
+		}
+		r.re = regexp.MustCompile(r.Pattern)
+		if r.generic {
+			genericRules = append(genericRules, r)
+		} else {
+			rules = append(rules, r)
+		}
+	}
+	for _, c := range conf.CustomRules {
+		re, err := regexp.Compile(c.Regex)
+		if err != nil {
+			logger.Error("ReloadSecretRules: Invalid regex for custom rule '%s': %v", c.Name, err)
+			continue
+		}
+		if c.SecretGroup > re.NumSubexp() {
+			logger.Error("ReloadSecretRules: Custom rule '%s' uses secret_group %d but the regex has only %d groups", c.Name, c.SecretGroup, re.NumSubexp())
+			continue
+		}
+		rules = append(rules, SecretRule{
+			Name: c.Name, Pattern: c.Regex, SecretGroup: c.SecretGroup, MinEntropy: c.MinEntropy,
+			Keywords: c.Keywords, Custom: true, re: re,
+		})
+	}
+
+	rules = append(rules, genericRules...)
+
+	secretRulesMu.Lock()
+	secretRules = rules
+	secretRulesMu.Unlock()
+	logger.Info("Secrets scanner: %d rules loaded (%d custom)", len(rules), len(conf.CustomRules))
+}
+
+// GetSecretRules returns the active rules.
+func GetSecretRules() []SecretRule {
+	secretRulesOnce.Do(ReloadSecretRules)
+	secretRulesMu.RLock()
+	defer secretRulesMu.RUnlock()
+	return secretRules
+}
+
+// ShannonEntropy returns the Shannon entropy (bits per character) of s.
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// ScanForSecrets runs all active rules against body.
+func ScanForSecrets(body []byte) []SecretMatch {
+	if len(body) == 0 {
+		return nil
+	}
+	text := string(body)
+	lower := strings.ToLower(text)
+
+	var matches []SecretMatch
+	seen := make(map[string]bool)
+	for _, rule := range GetSecretRules() {
+		if len(rule.Keywords) > 0 {
+			found := false
+			for _, kw := range rule.Keywords {
+				if strings.Contains(lower, strings.ToLower(kw)) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		for _, loc := range rule.re.FindAllStringSubmatchIndex(text, 100) {
+			start, end := loc[0], loc[1]
+			if rule.SecretGroup > 0 && loc[2*rule.SecretGroup] >= 0 {
+				start, end = loc[2*rule.SecretGroup], loc[2*rule.SecretGroup+1]
+			}
+			secret := text[start:end]
+			entropy := ShannonEntropy(secret)
+			if rule.MinEntropy > 0 && entropy < rule.MinEntropy {
+				continue
+			}
+			if seen[secret] {
+				continue // Already reported by a more specific rule
+			}
+			seen[secret] = true
+			matches = append(matches, SecretMatch{
+				RuleName: rule.Name,
+				Secret:   secret,
+				Excerpt:  secretExcerpt(text, start, end),
+				Entropy:  entropy,
+			})
+		}
+	}
+	return matches
+}
+
+// secretExcerpt returns the match with up to 30 characters of context on each side.
+// The secret itself is redacted except for its first and last four characters.
+func secretExcerpt(text string, start, end int) string {
+	const context = 30
+	from := start - context
+	if from < 0 {
+		from = 0
+	}
+	to := end + context
+	if to > len(text) {
+		to = len(text)
+	}
+	excerpt := text[from:start] + RedactSecret(text[start:end]) + text[end:to]
+	return strings.ToValidUTF8(strings.Join(strings.Fields(excerpt), " "), "")
+}
+
+// RedactSecret masks the middle of a secret, keeping enough to recognise it.
+func RedactSecret(secret string) string {
+	if len(secret) <= 12 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-8) + secret[len(secret)-4:]
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// isScannableContentType reports whether a body of this type may contain text secrets.
+func isScannableContentType(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	for _, skip := range []string{"image/", "video/", "audio/", "font/", "application/octet-stream", "application/pdf", "application/zip", "application/wasm"} {
+		if strings.HasPrefix(ct, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ScanTrafficLogForSecrets scans a stored log entry's bodies and stores any hits.
+// It returns the number of new secrets recorded.
+func ScanTrafficLogForSecrets(logID int64) (int, error) {
+	logEntry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		return 0, err
+	}
+	return scanAndStoreSecrets(&logEntry)
+}
+
+func scanAndStoreSecrets(logEntry *models.HTTPTrafficLog) (int, error) {
+	conf := ConfigForTarget(logEntry.TargetID).Secrets
+	var targetID sql.NullInt64
+	if logEntry.TargetID != nil {
+		targetID = sql.NullInt64{Int64: *logEntry.TargetID, Valid: true}
+	}
+
+	var found []models.Secret
+	collect := func(location string, body []byte, headersJSON string, contentType string) {
+		if len(body) == 0 || !isScannableContentType(contentType) {
+			return
+		}
+		decoded, err := DecodeContentEncoding(body, HeadersFromJSON(headersJSON).Get("Content-Encoding"))
+		if err != nil {
+			logger.Debug("Secrets scanner: Could not decode %s of log %d: %v", location, logEntry.ID, err)
+		}
+		if conf.MaxBodyBytes > 0 && len(decoded) > conf.MaxBodyBytes {
+			decoded = decoded[:conf.MaxBodyBytes]
+		}
+		for _, m := range ScanForSecrets(decoded) {
+			found = append(found, models.Secret{
+				TargetID:         targetID,
+				HTTPTrafficLogID: logEntry.ID,
+				RuleName:         m.RuleName,
+				MatchExcerpt:     m.Excerpt,
+				SecretHash:       hashSecret(m.Secret),
+				Entropy:          sql.NullFloat64{Float64: m.Entropy, Valid: true},
+				Location:         location,
+			})
+		}
+	}
+
+	collect("response_body", logEntry.ResponseBody, logEntry.ResponseHeaders.String, logEntry.ResponseContentType.String)
+	if conf.ScanRequestBody {
+		reqHeaders := HeadersFromJSON(logEntry.RequestHeaders.String)
+		collect("request_body", logEntry.RequestBody, logEntry.RequestHeaders.String, reqHeaders.Get("Content-Type"))
+	}
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	inserted, err := database.InsertSecrets(found)
+	if err != nil {
+		return inserted, fmt.Errorf("storing secrets for log %d: %w", logEntry.ID, err)
+	}
+	if inserted > 0 {
+		logger.Info("Secrets scanner: %d new potential secret(s) found in log %d (%s)", inserted, logEntry.ID, logEntry.RequestURL.String)
+	}
+	return inserted, nil
+}
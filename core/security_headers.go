@@ -0,0 +1,390 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const (
+	// securityHeaderExamples caps the example log IDs listed per check.
+	securityHeaderExamples = 3
+	// securityHeaderValues caps the distinct values tracked per check; CSP nonces make every
+	// value unique.
+	securityHeaderValues = 50
+	// hstsMinMaxAge is the shortest HSTS max-age not reported as weak (180 days).
+	hstsMinMaxAge = 180 * 24 * 60 * 60
+)
+
+// securityHeaderResponse is a captured response as seen by the security header checks.
+type securityHeaderResponse struct {
+	headers http.Header
+	https   bool
+	html    bool // 2xx HTML document
+	success bool // 2xx
+}
+
+// securityHeaderRule checks one header. evaluate returns the value shown in the report (empty
+// when the header is missing) and the weaknesses of a present value, or for disclosure rules
+// what is disclosed.
+type securityHeaderRule struct {
+	header     string
+	weight     int
+	disclosure bool
+	applies    func(r securityHeaderResponse) bool
+	evaluate   func(h http.Header) (value string, issues []string)
+}
+
+var securityHeaderRules = []securityHeaderRule{
+	{header: "Strict-Transport-Security", weight: 20, applies: func(r securityHeaderResponse) bool { return r.https }, evaluate: checkHSTS},
+	{header: "Content-Security-Policy", weight: 25, applies: func(r securityHeaderResponse) bool { return r.html }, evaluate: checkCSP},
+	{header: "X-Frame-Options", weight: 15, applies: func(r securityHeaderResponse) bool { return r.html }, evaluate: checkFrameOptions},
+	{header: "X-Content-Type-Options", weight: 15, applies: func(r securityHeaderResponse) bool { return r.success }, evaluate: checkContentTypeOptions},
+	{header: "Referrer-Policy", weight: 10, applies: func(r securityHeaderResponse) bool { return r.html }, evaluate: checkReferrerPolicy},
+	{header: "Permissions-Policy", weight: 5, applies: func(r securityHeaderResponse) bool { return r.html }, evaluate: func(h http.Header) (string, []string) {
+		return h.Get("Permissions-Policy"), nil
+	}},
+	{header: "Server / X-Powered-By", weight: 10, disclosure: true, applies: func(securityHeaderResponse) bool { return true }, evaluate: checkVersionDisclosure},
+}
+
+// securityHeaderTally accumulates one check over the responses of a host.
+type securityHeaderTally struct {
+	check  models.SecurityHeaderCheck
+	values map[string]int
+	issues map[string]int
+}
+
+func (t *securityHeaderTally) example(logID int64) {
+	if len(t.check.ExampleLogIDs) < securityHeaderExamples {
+		t.check.ExampleLogIDs = append(t.check.ExampleLogIDs, logID)
+	}
+}
+
+// GetSecurityHeaderReport grades, per host, the security headers of a target's captured
+// responses: HSTS on HTTPS responses, CSP, framing protection, Referrer-Policy and
+// Permissions-Policy on HTML documents, X-Content-Type-Options on successful responses, and
+// version disclosure in Server and X-Powered-By on all of them. Each check lists what is missing
+// or weak with example log IDs. host limits the report to one host.
+func GetSecurityHeaderReport(targetID int64, host string) (models.SecurityHeaderReport, error) {
+	report := models.SecurityHeaderReport{TargetID: targetID, Hosts: []models.HostSecurityHeaders{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return report, err
+	}
+	host = strings.ToLower(strings.TrimSpace(host))
+
+	type hostTally struct {
+		summary models.HostSecurityHeaders
+		checks  []*securityHeaderTally
+	}
+	hosts := map[string]*hostTally{}
+	err := database.ForEachTargetResponseHeaders(targetID, func(e models.HTTPTrafficLog) {
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" {
+			return
+		}
+		name := strings.ToLower(u.Hostname())
+		if host != "" && name != host {
+			return
+		}
+		report.ResponsesScanned++
+		ht := hosts[name]
+		if ht == nil {
+			ht = &hostTally{summary: models.HostSecurityHeaders{Host: name}}
+			for _, rule := range securityHeaderRules {
+				ht.checks = append(ht.checks, &securityHeaderTally{
+					check:  models.SecurityHeaderCheck{Header: rule.header},
+					values: map[string]int{},
+					issues: map[string]int{},
+				})
+			}
+			hosts[name] = ht
+		}
+		resp := securityHeaderResponse{
+			headers: HeadersFromJSON(e.ResponseHeaders.String),
+			https:   e.IsHTTPS || u.Scheme == "https",
+			success: e.ResponseStatusCode >= 200 && e.ResponseStatusCode < 300,
+		}
+		resp.html = resp.success && strings.Contains(strings.ToLower(e.ResponseContentType.String), "html")
+		ht.summary.Responses++
+		if resp.html {
+			ht.summary.HTMLResponses++
+		}
+		if resp.https {
+			ht.summary.HTTPSResponses++
+		}
+
+		for i, rule := range securityHeaderRules {
+			if !rule.applies(resp) {
+				continue
+			}
+			t := ht.checks[i]
+			t.check.Applicable++
+			value, issues := rule.evaluate(resp.headers)
+			if value != "" {
+				t.check.Present++
+				if _, ok := t.values[value]; ok || len(t.values) < securityHeaderValues {
+					t.values[value]++
+				}
+			}
+			switch {
+			case rule.disclosure:
+				if value == "" {
+					continue
+				}
+			case value == "":
+				t.issues["missing"]++
+			case len(issues) > 0:
+				t.check.Weak++
+			default:
+				continue
+			}
+			for _, issue := range issues {
+				t.issues[issue]++
+			}
+			t.example(e.ID)
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, ht := range hosts {
+		summary := ht.summary
+		points, total := 0, 0
+		for i, rule := range securityHeaderRules {
+			check := finishSecurityHeaderCheck(ht.checks[i], rule)
+			summary.Checks = append(summary.Checks, check)
+			if check.Status == models.HeaderStatusNotApplicable {
+				continue
+			}
+			total += rule.weight
+			switch check.Status {
+			case models.HeaderStatusOK:
+				points += rule.weight
+			case models.HeaderStatusWeak, models.HeaderStatusInconsistent:
+				points += rule.weight / 2
+			}
+		}
+		if total > 0 {
+			summary.Score = int(math.Round(100 * float64(points) / float64(total)))
+		}
+		summary.Grade = securityHeaderGrade(summary.Score)
+		report.Hosts = append(report.Hosts, summary)
+	}
+	sort.Slice(report.Hosts, func(i, j int) bool {
+		a, b := report.Hosts[i], report.Hosts[j]
+		if a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		if a.Responses != b.Responses {
+			return a.Responses > b.Responses
+		}
+		return a.Host < b.Host
+	})
+	logger.Debug("GetSecurityHeaderReport: Target %d: %d responses on %d hosts", targetID, report.ResponsesScanned, len(report.Hosts))
+	return report, nil
+}
+
+// finishSecurityHeaderCheck sets the status, most common values and issue list of a check.
+func finishSecurityHeaderCheck(t *securityHeaderTally, rule securityHeaderRule) models.SecurityHeaderCheck {
+	check := t.check
+	switch {
+	case check.Applicable == 0:
+		check.Status = models.HeaderStatusNotApplicable
+	case rule.disclosure && check.Present > 0:
+		check.Status = models.HeaderStatusDisclosed
+	case rule.disclosure:
+		check.Status = models.HeaderStatusOK
+	case check.Present == 0:
+		check.Status = models.HeaderStatusMissing
+	case check.Present < check.Applicable:
+		check.Status = models.HeaderStatusInconsistent
+	case check.Weak > 0:
+		check.Status = models.HeaderStatusWeak
+	default:
+		check.Status = models.HeaderStatusOK
+	}
+
+	check.Values = topCounted(t.values, 3)
+	for _, issue := range topCounted(t.issues, len(t.issues)) {
+		check.Issues = append(check.Issues, fmt.Sprintf("%s (%d of %d responses)", issue, t.issues[issue], check.Applicable))
+	}
+	return check
+}
+
+// topCounted returns up to n keys of counts, most frequent first.
+func topCounted(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+func securityHeaderGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 65:
+		return "C"
+	case score >= 50:
+		return "D"
+	}
+	return "F"
+}
+
+func checkHSTS(h http.Header) (string, []string) {
+	value := strings.TrimSpace(h.Get("Strict-Transport-Security"))
+	if value == "" {
+		return "", nil
+	}
+	maxAge := -1
+	for _, directive := range strings.Split(value, ";") {
+		name, v, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			if n, err := strconv.Atoi(strings.Trim(strings.TrimSpace(v), `"`)); err == nil {
+				maxAge = n
+			}
+		}
+	}
+	switch {
+	case maxAge < 0:
+		return value, []string{"no valid max-age"}
+	case maxAge < hstsMinMaxAge:
+		return value, []string{"max-age below 180 days"}
+	}
+	return value, nil
+}
+
+// cspDirectives parses a Content-Security-Policy into lower-cased directive names and sources.
+func cspDirectives(policy string) map[string][]string {
+	directives := map[string][]string{}
+	for _, d := range strings.Split(policy, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, seen := directives[name]; !seen { // Browsers ignore repeated directives
+			directives[name] = fields[1:]
+		}
+	}
+	return directives
+}
+
+func checkCSP(h http.Header) (string, []string) {
+	value := strings.TrimSpace(h.Get("Content-Security-Policy"))
+	if value == "" {
+		if h.Get("Content-Security-Policy-Report-Only") != "" {
+			return "", []string{"only Content-Security-Policy-Report-Only is sent"}
+		}
+		return "", nil
+	}
+	directives := cspDirectives(value)
+	sources, ok := directives["script-src"]
+	directive := "script-src"
+	if !ok {
+		sources, ok = directives["default-src"]
+		directive = "default-src"
+	}
+	if !ok {
+		return value, []string{"no script-src or default-src"}
+	}
+	var issues []string
+	nonceOrHash := false
+	for _, s := range sources {
+		s = strings.ToLower(s)
+		if strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha256-") || strings.HasPrefix(s, "'sha384-") || strings.HasPrefix(s, "'sha512-") {
+			nonceOrHash = true
+		}
+	}
+	for _, s := range sources {
+		switch s = strings.ToLower(s); s {
+		case "'unsafe-inline'":
+			if !nonceOrHash { // Ignored by browsers when a nonce or hash is present
+				issues = append(issues, directive+" allows 'unsafe-inline'")
+			}
+		case "'unsafe-eval'", "*", "data:", "http:", "https:":
+			issues = append(issues, fmt.Sprintf("%s allows %s", directive, s))
+		}
+	}
+	return value, issues
+}
+
+func checkFrameOptions(h http.Header) (string, []string) {
+	if ancestors, ok := cspDirectives(h.Get("Content-Security-Policy"))["frame-ancestors"]; ok {
+		value := "frame-ancestors " + strings.Join(ancestors, " ")
+		for _, s := range ancestors {
+			if s == "*" || strings.EqualFold(s, "https:") || strings.EqualFold(s, "http:") {
+				return value, []string{"frame-ancestors allows " + s}
+			}
+		}
+		return value, nil
+	}
+	value := strings.TrimSpace(h.Get("X-Frame-Options"))
+	switch strings.ToUpper(value) {
+	case "", "DENY", "SAMEORIGIN":
+		return value, nil
+	}
+	if strings.HasPrefix(strings.ToUpper(value), "ALLOW-FROM") {
+		return value, []string{"ALLOW-FROM is ignored by current browsers"}
+	}
+	return value, []string{"invalid value"}
+}
+
+func checkContentTypeOptions(h http.Header) (string, []string) {
+	value := strings.TrimSpace(h.Get("X-Content-Type-Options"))
+	if value != "" && !strings.EqualFold(value, "nosniff") {
+		return value, []string{"value is not nosniff"}
+	}
+	return value, nil
+}
+
+func checkReferrerPolicy(h http.Header) (string, []string) {
+	value := strings.TrimSpace(h.Get("Referrer-Policy"))
+	if value == "" {
+		return "", nil
+	}
+	// With a list, browsers use the last policy they support.
+	policies := strings.Split(value, ",")
+	switch last := strings.ToLower(strings.TrimSpace(policies[len(policies)-1])); last {
+	case "unsafe-url", "no-referrer-when-downgrade":
+		return value, []string{"leaks full URLs cross-origin (" + last + ")"}
+	}
+	return value, nil
+}
+
+// checkVersionDisclosure returns the headers disclosing server software versions.
+func checkVersionDisclosure(h http.Header) (string, []string) {
+	var disclosed []string
+	if server := strings.TrimSpace(h.Get("Server")); strings.ContainsAny(server, "0123456789") {
+		disclosed = append(disclosed, "Server: "+server)
+	}
+	for _, name := range []string{"X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version"} {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			disclosed = append(disclosed, name+": "+v)
+		}
+	}
+	if len(disclosed) == 0 {
+		return "", nil
+	}
+	return strings.Join(disclosed, ", "), disclosed
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+var (
+	synackRefreshMu     sync.Mutex
+	synackRefreshStatus models.SynackAnalyticsRefreshStatus
+)
+
+// activeSynackProvider returns the Synack provider of the running proxy, ready to call the
+// analytics API.
+func activeSynackProvider() (*SynackProvider, error) {
+	provider, err := GetPlatformProvider("synack")
+	if err != nil {
+		return nil, fmt.Errorf("synack provider is not running (analytics are fetched by the proxy)")
+	}
+	synack, ok := provider.(*SynackProvider)
+	if !ok {
+		return nil, fmt.Errorf("synack provider is not running (analytics are fetched by the proxy)")
+	}
+	if !synack.analyticsConfigured() {
+		return nil, fmt.Errorf("synack analytics are not configured (synack.analytics_enabled, analytics_base_url, analytics_path_pattern)")
+	}
+	if synack.currentAuthToken() == "" {
+		return nil, fmt.Errorf("no synack auth token captured yet; load the target list in the proxied browser first")
+	}
+	return synack, nil
+}
+
+// RefreshSynackTargetAnalytics refetches the analytics and findings of one Synack target now.
+// Stored findings are upserted, so nothing is lost when the platform returns fewer.
+func RefreshSynackTargetAnalytics(ctx context.Context, synackTargetDBID int64) error {
+	provider, err := activeSynackProvider()
+	if err != nil {
+		return err
+	}
+	return provider.RefreshTargetAnalytics(ctx, synackTargetDBID)
+}
+
+// StartSynackStaleAnalyticsRefresh refreshes, in the background, the analytics of every active
+// Synack target that has none or whose analytics are older than maxAgeHours (the configured
+// synack.analytics_max_age_hours when maxAgeHours <= 0; every active target when both are 0).
+func StartSynackStaleAnalyticsRefresh(maxAgeHours int) (models.SynackAnalyticsRefreshStatus, error) {
+	provider, err := activeSynackProvider()
+	if err != nil {
+		return models.SynackAnalyticsRefreshStatus{}, err
+	}
+	if maxAgeHours <= 0 {
+		maxAgeHours = provider.conf.AnalyticsMaxAgeHours
+	}
+
+	synackRefreshMu.Lock()
+	defer synackRefreshMu.Unlock()
+	if synackRefreshStatus.Running {
+		return synackRefreshStatus, fmt.Errorf("a synack analytics refresh is already running")
+	}
+	targets, err := database.ListStaleSynackTargets(time.Now().Add(-time.Duration(maxAgeHours) * time.Hour))
+	if err != nil {
+		return synackRefreshStatus, err
+	}
+
+	now := time.Now()
+	synackRefreshStatus = models.SynackAnalyticsRefreshStatus{Running: true, StartedAt: &now, MaxAgeHours: maxAgeHours, Total: len(targets)}
+	logger.Info("StartSynackStaleAnalyticsRefresh: Refreshing analytics of %d Synack targets (max age %dh)", len(targets), maxAgeHours)
+	go runSynackStaleAnalyticsRefresh(provider, targets)
+	return synackRefreshStatus, nil
+}
+
+func runSynackStaleAnalyticsRefresh(provider *SynackProvider, targets []models.SynackTarget) {
+	for _, t := range targets {
+		err := provider.fetchTargetAnalytics(context.Background(), t.DBID, t.SynackTargetIDStr)
+		synackRefreshMu.Lock()
+		if err != nil {
+			synackRefreshStatus.Failed++
+			synackRefreshStatus.LastError = err.Error()
+		} else {
+			synackRefreshStatus.Refreshed++
+		}
+		synackRefreshMu.Unlock()
+		if err != nil {
+			logger.Error("Synack analytics refresh: %v", err)
+		}
+	}
+
+	synackRefreshMu.Lock()
+	defer synackRefreshMu.Unlock()
+	finished := time.Now()
+	synackRefreshStatus.Running = false
+	synackRefreshStatus.FinishedAt = &finished
+	logger.Info("Synack analytics refresh finished: %d refreshed, %d failed", synackRefreshStatus.Refreshed, synackRefreshStatus.Failed)
+}
+
+// GetSynackStaleAnalyticsRefreshStatus returns the progress of the current or last bulk refresh.
+func GetSynackStaleAnalyticsRefreshStatus() models.SynackAnalyticsRefreshStatus {
+	synackRefreshMu.Lock()
+	defer synackRefreshMu.Unlock()
+	return synackRefreshStatus
+}
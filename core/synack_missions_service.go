@@ -93,6 +93,11 @@ func (s *SynackMissionService) Start() {
 				logger.Info("Synack Mission Polling Service: context cancelled, exiting poll loop.")
 				return
 			case <-ticker.C:
+				if !s.conf.Enabled {
+					// Disabled through the settings API; Start is called again when re-enabled
+					logger.Info("Synack Mission Polling Service: disabled, exiting poll loop.")
+					return
+				}
 				s.fetchAndProcessMissions()
 			}
 		}
@@ -0,0 +1,434 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// synackAnalyticsTicker spaces out analytics requests to Synack (one per second across targets).
+var synackAnalyticsTicker = time.NewTicker(1 * time.Second)
+
+type rawSynackFindingItem struct {
+	Type        string          `json:"type"`
+	Value       string          `json:"value"`
+	CreatedAt   int64           `json:"created_at"`
+	Status      string          `json:"status"`
+	OutOfScope  *bool           `json:"outOfScope"`
+	ID          string          `json:"id"`
+	Title       string          `json:"title"`
+	Category    string          `json:"category"`
+	Severity    string          `json:"severity"`
+	FullDetails json.RawMessage `json:"-"`
+}
+
+// SynackProvider processes the Synack target list seen through the proxy: targets are upserted,
+// targets missing from the list deactivated, and analytics/findings fetched for new or stale
+// targets with the Bearer token captured from the target list request.
+type SynackProvider struct {
+	providerStats
+	conf           config.SynackConfig
+	targetsURL     *url.URL
+	missionService *SynackMissionService
+	httpClient     *http.Client
+
+	tokenMu   sync.Mutex
+	authToken string
+}
+
+// NewSynackProvider creates the Synack provider from the synack config section. The mission
+// service (may be nil) receives every captured auth token.
+func NewSynackProvider(missionService *SynackMissionService) *SynackProvider {
+	p := &SynackProvider{
+		conf:           config.AppConfig.Synack,
+		missionService: missionService,
+		httpClient:     &http.Client{Timeout: 20 * time.Second},
+	}
+	if p.conf.TargetsURL != "" {
+		parsed, err := url.Parse(p.conf.TargetsURL)
+		if err != nil {
+			logger.Error("Failed to parse configured Synack TargetsURL '%s': %v. Synack processing disabled.", p.conf.TargetsURL, err)
+		} else {
+			p.targetsURL = parsed
+			logger.ProxyInfo("Parsed Synack Target URL for matching: Scheme=%s, Host=%s, Path=%s", parsed.Scheme, parsed.Host, parsed.Path)
+		}
+	}
+	p.status.Name = p.Name()
+	p.status.Enabled = p.targetsURL != nil
+	p.status.TrafficURL = p.conf.TargetsURL
+	return p
+}
+
+// Name implements PlatformProvider.
+func (p *SynackProvider) Name() string {
+	return "synack"
+}
+
+// MatchesTrafficURL reports whether u is the configured Synack target list URL.
+func (p *SynackProvider) MatchesTrafficURL(u *url.URL) bool {
+	return trafficURLMatches(p.targetsURL, u)
+}
+
+// Status implements PlatformProvider.
+func (p *SynackProvider) Status() models.PlatformProviderStatus {
+	return p.snapshot()
+}
+
+// ProcessCapturedResponse captures the Bearer token of the target list request and, for
+// successful JSON responses, processes the target list.
+func (p *SynackProvider) ProcessCapturedResponse(req *http.Request, statusCode int, contentType string, body []byte) error {
+	authToken := req.Header.Get("Authorization")
+	if strings.HasPrefix(authToken, "Bearer ") {
+		p.setAuthToken(authToken)
+	} else if authToken != "" {
+		logger.ProxyInfo("WARNING: Found Authorization header for Synack target list, but it's not a Bearer token.")
+		authToken = ""
+	}
+
+	if statusCode != http.StatusOK || !strings.Contains(strings.ToLower(contentType), "application/json") {
+		return nil
+	}
+	logger.ProxyInfo("Synack target list URL detected: %s. Using auth token: %t", req.URL.String(), authToken != "")
+	err := p.processTargetList(body)
+	p.recordProcessed()
+	if err != nil {
+		p.recordError(err)
+	}
+	return err
+}
+
+func (p *SynackProvider) setAuthToken(token string) {
+	p.tokenMu.Lock()
+	p.authToken = token
+	p.tokenMu.Unlock()
+	p.recordAuthToken()
+	if p.missionService != nil {
+		p.missionService.SetAuthToken(token)
+	} else {
+		logger.Warn("SynackProvider: mission service is nil, cannot set auth token for missions.")
+	}
+}
+
+func (p *SynackProvider) currentAuthToken() string {
+	p.tokenMu.Lock()
+	defer p.tokenMu.Unlock()
+	return p.authToken
+}
+
+func (p *SynackProvider) analyticsConfigured() bool {
+	return p.conf.AnalyticsEnabled && p.conf.AnalyticsBaseURL != "" && p.conf.AnalyticsPathPattern != ""
+}
+
+// processTargetList upserts the targets in the list, fetches analytics for targets that have
+// none yet (or whose analytics are past synack.analytics_max_age_hours), announces new targets
+// and deactivates targets no longer listed.
+func (p *SynackProvider) processTargetList(jsonData []byte) error {
+	logger.ProxyInfo("Processing Synack target list JSON (%d bytes)...", len(jsonData))
+	var rawTargets []map[string]interface{}
+
+	if !p.conf.AnalyticsEnabled {
+		logger.ProxyInfo("Synack target analytics fetching is disabled in config.")
+	}
+
+	arrayPath := p.conf.TargetsArrayPath
+	if arrayPath == "" {
+		arrayPath = "@this"
+	}
+
+	result := gjson.GetBytes(jsonData, arrayPath)
+	if !result.IsArray() {
+		logger.ProxyError("Synack target list: Expected an array at path '%s', but got: %s", arrayPath, result.Type.String())
+		if p.conf.TargetsArrayPath == "" {
+			return fmt.Errorf("target list is not an array")
+		}
+		resultRoot := gjson.ParseBytes(jsonData)
+		if !resultRoot.IsArray() {
+			logger.ProxyError("Synack target list: Root is also not an array. Type: %s", resultRoot.Type.String())
+			return fmt.Errorf("target list has no array at '%s' or the root", arrayPath)
+		}
+		logger.ProxyInfo("Synack target list: Falling back to parsing root as array.")
+		result = resultRoot
+	}
+
+	if err := json.Unmarshal([]byte(result.Raw), &rawTargets); err != nil {
+		logger.ProxyError("Synack target list: Error unmarshalling into []map[string]interface{}: %v", err)
+		return fmt.Errorf("decoding target list: %w", err)
+	}
+
+	if len(rawTargets) == 0 {
+		logger.ProxyInfo("Synack target list: No targets found in JSON after parsing.")
+		if err := database.DeactivateMissingSynackTargets([]string{}, time.Now()); err != nil {
+			logger.ProxyError("Synack target list: Error deactivating all targets on empty list: %v", err)
+		}
+		return nil
+	}
+
+	idField := p.conf.TargetIDField
+	if idField == "" {
+		idField = "id"
+	}
+	// The first list seen fills an empty database; its targets are not announced as new.
+	initialSync := false
+	if count, err := database.CountSynackTargets(); err == nil && count == 0 {
+		initialSync = true
+	}
+	var currentSeenIDs []string
+	var newTargets []map[string]interface{}
+	for _, targetMap := range rawTargets {
+		synackID, ok := targetMap[idField].(string)
+		if !ok {
+			idNum, numOk := targetMap[idField].(float64)
+			if !numOk {
+				logger.ProxyError("Synack target list: Target missing or has invalid type for configured ID field '%s'. Skipping. Data: %+v", idField, targetMap)
+				continue
+			}
+			synackID = fmt.Sprintf("%.0f", idNum)
+		}
+		currentSeenIDs = append(currentSeenIDs, synackID)
+
+		exists, errExists := database.SynackTargetExists(synackID)
+		if errExists != nil {
+			logger.ProxyError("Synack target list: %v", errExists)
+		}
+		dbID, errUpsert := database.UpsertSynackTarget(targetMap)
+		if errUpsert != nil {
+			logger.ProxyError("Synack target list: Error upserting target with Synack ID '%s': %v", synackID, errUpsert)
+			continue
+		}
+		if errExists == nil && !exists && !initialSync {
+			newTargets = append(newTargets, targetMap)
+		}
+
+		if !p.analyticsConfigured() {
+			continue
+		}
+		lastFetchTime, errCheck := database.GetSynackTargetAnalyticsFetchTime(dbID)
+		if errCheck != nil {
+			logger.ProxyError("Synack Analytics: Error checking existing analytics for target DB_ID %d (Synack ID %s): %v", dbID, synackID, errCheck)
+		}
+		if lastFetchTime != nil && !p.analyticsStale(*lastFetchTime) {
+			logger.ProxyInfo("Synack Analytics: Analytics data for target DB_ID %d (Synack ID %s) fetched at %s is still fresh. Skipping fetch.", dbID, synackID, lastFetchTime.Format(time.RFC3339))
+			continue
+		}
+		if lastFetchTime != nil {
+			logger.ProxyInfo("Synack Analytics: Analytics for target DB_ID %d (Synack ID %s) fetched at %s are stale. Refetching.", dbID, synackID, lastFetchTime.Format(time.RFC3339))
+		} else {
+			logger.ProxyInfo("Synack Analytics: No existing analytics found for target DB_ID %d (Synack ID %s) or fetch time is nil. Proceeding to fetch.", dbID, synackID)
+		}
+		if err := p.fetchTargetAnalytics(context.Background(), dbID, synackID); err != nil {
+			logger.ProxyError("Synack Analytics: %v", err)
+		}
+	}
+
+	if len(currentSeenIDs) > 0 {
+		if err := database.DeactivateMissingSynackTargets(currentSeenIDs, time.Now()); err != nil {
+			logger.ProxyError("Synack target list: Error deactivating missing targets: %v", err)
+		}
+	} else {
+		logger.ProxyInfo("Synack target list: No valid target IDs found in the current list, skipping deactivation step.")
+	}
+
+	if len(newTargets) > 0 {
+		logger.ProxyInfo("Synack target list: %d new targets.", len(newTargets))
+		notifyNewSynackTargets(newTargets)
+	}
+	logger.ProxyInfo("Finished processing Synack target list. Saw %d targets.", len(currentSeenIDs))
+	return nil
+}
+
+// analyticsMaxAge is the freshness window for analytics; 0 means analytics are fetched only once.
+func (p *SynackProvider) analyticsMaxAge() time.Duration {
+	return time.Duration(p.conf.AnalyticsMaxAgeHours) * time.Hour
+}
+
+// analyticsStale reports whether analytics fetched at fetchedAt are past the freshness window.
+func (p *SynackProvider) analyticsStale(fetchedAt time.Time) bool {
+	maxAge := p.analyticsMaxAge()
+	return maxAge > 0 && time.Since(fetchedAt) >= maxAge
+}
+
+// FetchAnalytics refreshes the analytics (and findings, when enabled) of one Synack target,
+// regardless of when they were last fetched. It needs an auth token captured from the proxy.
+func (p *SynackProvider) FetchAnalytics(ctx context.Context, synackID string) error {
+	if !p.analyticsConfigured() {
+		return fmt.Errorf("synack analytics are not configured (synack.analytics_enabled, analytics_base_url, analytics_path_pattern)")
+	}
+	if p.currentAuthToken() == "" {
+		return fmt.Errorf("no synack auth token captured yet; load the target list in the proxied browser first")
+	}
+	dbID, err := database.GetSynackTargetDBID(synackID)
+	if err != nil {
+		return err
+	}
+	return p.fetchTargetAnalytics(ctx, dbID, synackID)
+}
+
+// RefreshTargetAnalytics refetches the analytics of the Synack target with the given database ID.
+func (p *SynackProvider) RefreshTargetAnalytics(ctx context.Context, dbID int64) error {
+	synackID, err := database.GetSynackTargetIDStr(dbID)
+	if err != nil {
+		return err
+	}
+	return p.FetchAnalytics(ctx, synackID)
+}
+
+// fetchTargetAnalytics requests the analytics of one target (with retries), aggregates the
+// category counts, stores the exploitable locations as findings and records the fetch.
+func (p *SynackProvider) fetchTargetAnalytics(ctx context.Context, dbID int64, synackID string) (err error) {
+	defer func() { p.recordAnalytics(err) }()
+
+	analyticsURL := fmt.Sprintf(p.conf.AnalyticsBaseURL+p.conf.AnalyticsPathPattern, synackID)
+	logger.ProxyInfo("Fetching analytics for Synack target '%s' from URL: %s", synackID, analyticsURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, analyticsURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating analytics request for target '%s': %w", synackID, err)
+	}
+	if authToken := p.currentAuthToken(); authToken != "" {
+		req.Header.Set("Authorization", authToken)
+		logger.ProxyDebug("Synack Analytics: Added Authorization header to request for target '%s'", synackID)
+	}
+
+	logger.ProxyDebug("Synack Analytics: Waiting for analytics ticker for target DB_ID %d (Synack ID %s)...", dbID, synackID)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-synackAnalyticsTicker.C:
+	}
+	policy := providerRetryPolicy{
+		MaxRetries:     p.conf.AnalyticsMaxRetries,
+		InitialBackoff: time.Duration(p.conf.AnalyticsBackoffInitialMs) * time.Millisecond,
+		MaxBackoff:     time.Duration(p.conf.AnalyticsBackoffMaxMs) * time.Millisecond,
+	}
+	resp, err := doWithRetry(ctx, p.httpClient, req, policy, p.recordRetry)
+	if err != nil {
+		return fmt.Errorf("fetching analytics for target DB_ID %d (Synack ID %s) from %s: %w", dbID, synackID, analyticsURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading analytics response body for target DB_ID %d (Synack ID %s) (status %d): %w", dbID, synackID, resp.StatusCode, err)
+	}
+	logger.ProxyInfo("Synack Analytics: Received status %d for target DB_ID %d (Synack ID %s). Response (first 500 chars): %s", resp.StatusCode, dbID, synackID, string(body[:min(len(body), 500)]))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("analytics request for target DB_ID %d (Synack ID %s) returned status %d", dbID, synackID, resp.StatusCode)
+	}
+
+	analyticsArrayPath := "value"
+	categoriesResult := gjson.GetBytes(body, analyticsArrayPath)
+	if !categoriesResult.Exists() {
+		return fmt.Errorf("expected key '%s' not found in analytics JSON response for target DB_ID %d (Synack ID %s)", analyticsArrayPath, dbID, synackID)
+	}
+	if !categoriesResult.IsArray() {
+		return fmt.Errorf("value at key '%s' in analytics JSON is not an array (type: %s) for target DB_ID %d (Synack ID %s)", analyticsArrayPath, categoriesResult.Type.String(), dbID, synackID)
+	}
+
+	var rawAnalyticsItems []struct {
+		Categories           []string        `json:"categories"`
+		ExploitableLocations json.RawMessage `json:"exploitable_locations"`
+	}
+	if err := json.Unmarshal([]byte(categoriesResult.Raw), &rawAnalyticsItems); err != nil {
+		return fmt.Errorf("unmarshalling analytics items from key '%s' for target DB_ID %d (Synack ID %s): %w", analyticsArrayPath, dbID, synackID, err)
+	}
+
+	aggregatedCategories := make(map[string]int64)
+	for _, rawItem := range rawAnalyticsItems {
+		var exploitableLocationsArray []interface{}
+		if rawItem.ExploitableLocations != nil {
+			if errParseEL := json.Unmarshal(rawItem.ExploitableLocations, &exploitableLocationsArray); errParseEL != nil {
+				logger.Error("Synack Analytics (Proxy): Could not parse exploitable_locations for category group (target DB_ID %d): %v", dbID, errParseEL)
+			}
+		}
+		findingCountInGroup := int64(len(exploitableLocationsArray))
+		for _, categoryName := range rawItem.Categories {
+			aggregatedCategories[categoryName] += findingCountInGroup
+		}
+
+		if !p.conf.FindingsEnabled {
+			logger.ProxyInfo("Synack Findings: Individual findings processing is DISABLED in config for target DB_ID %d.", dbID)
+			continue
+		}
+		p.storeExploitableLocations(dbID, rawItem.Categories, rawItem.ExploitableLocations)
+	}
+
+	var finalAnalyticsCategories []models.SynackTargetAnalyticsCategory
+	for name, count := range aggregatedCategories {
+		finalAnalyticsCategories = append(finalAnalyticsCategories, models.SynackTargetAnalyticsCategory{CategoryName: name, Count: count})
+	}
+	if err := database.StoreSynackTargetAnalytics(dbID, finalAnalyticsCategories); err != nil {
+		return fmt.Errorf("storing analytics for target DB_ID %d (Synack ID %s): %w", dbID, synackID, err)
+	}
+	logger.ProxyInfo("Synack Analytics: Successfully stored %d analytics categories for target DB_ID %d (Synack ID %s)", len(finalAnalyticsCategories), dbID, synackID)
+	return nil
+}
+
+// storeExploitableLocations upserts the exploitable locations of one analytics category group as
+// Synack findings.
+func (p *SynackProvider) storeExploitableLocations(dbID int64, categories []string, rawLocations json.RawMessage) {
+	logger.ProxyInfo("Synack Findings: Processing 'exploitable_locations' for target DB_ID %d, category group with categories: %v", dbID, categories)
+	if p.conf.FindingsArrayPathInAnalyticsJson != "" {
+		logger.ProxyInfo("Warning: Synack Findings: Config key 'findings_array_path_in_analytics_json' is set but currently ignored. Findings are sourced from 'exploitable_locations' within each category group.")
+	}
+	if rawLocations == nil {
+		logger.ProxyDebug("Synack Findings: No 'exploitable_locations' data for this category group (target DB_ID %d).", dbID)
+		return
+	}
+
+	var exploitableLocationsList []rawSynackFindingItem
+	logger.ProxyDebug("Synack Findings: Raw exploitable_locations JSON for target DB_ID %d (first 500 chars): %s", dbID, string(rawLocations[:min(len(rawLocations), 500)]))
+	if err := json.Unmarshal(rawLocations, &exploitableLocationsList); err != nil {
+		logger.ProxyError("Synack Findings: Error unmarshalling exploitable_locations for category group (target DB_ID %d): %v. Raw JSON: %s", dbID, err, string(rawLocations[:min(len(rawLocations), 200)]))
+		return
+	}
+	logger.ProxyInfo("Synack Findings: Found %d exploitable_locations for this category group (target DB_ID %d).", len(exploitableLocationsList), dbID)
+	if len(exploitableLocationsList) == 0 && len(rawLocations) > 2 {
+		logger.ProxyInfo("Warning: Synack Findings: Unmarshalled 0 exploitable_locations, but raw JSON was not empty for target DB_ID %d. Check rawSynackFindingItem struct against API response.", dbID)
+	}
+	primaryCategoryName := ""
+	if len(categories) > 0 {
+		primaryCategoryName = categories[0]
+	}
+
+	for i, exploitableLocation := range exploitableLocationsList {
+		logger.ProxyDebug("Synack Findings: Processing exploitable_location #%d: URL='%s', Status='%s', CreatedAt=%d", i+1, exploitableLocation.Value, exploitableLocation.Status, exploitableLocation.CreatedAt)
+
+		findingToStore := models.SynackFinding{
+			SynackTargetDBID: dbID,
+			SynackFindingID:  exploitableLocation.Value,
+			Title:            fmt.Sprintf("Finding at %s", exploitableLocation.Value),
+			CategoryName:     primaryCategoryName,
+			Status:           exploitableLocation.Status,
+			VulnerabilityURL: exploitableLocation.Value,
+		}
+		if rawJSON, errJSON := json.Marshal(exploitableLocation); errJSON == nil {
+			findingToStore.RawJSONDetails = string(rawJSON)
+		} else {
+			logger.ProxyError("Synack Findings: Could not marshal exploitableLocation to JSON for finding URL '%s': %v", exploitableLocation.Value, errJSON)
+		}
+		if exploitableLocation.CreatedAt > 0 {
+			t := time.Unix(exploitableLocation.CreatedAt, 0).UTC()
+			findingToStore.ReportedAt = &t
+		}
+
+		logger.ProxyDebug("Synack Findings: Attempting to upsert finding: TargetDBID=%d, FindingID(URL)='%s', Category='%s', Status='%s'",
+			findingToStore.SynackTargetDBID, findingToStore.SynackFindingID, findingToStore.CategoryName, findingToStore.Status)
+		insertedDBID, errStore := database.UpsertSynackFinding(findingToStore)
+		if errStore != nil {
+			logger.ProxyError("Synack Findings: Error upserting finding (URL: '%s') for target DB_ID %d: %v", exploitableLocation.Value, dbID, errStore)
+		} else {
+			logger.ProxyInfo("Synack Findings: Successfully upserted finding (URL: '%s'), DB ID: %d, for target DB_ID %d.", exploitableLocation.Value, insertedDBID, dbID)
+		}
+	}
+}
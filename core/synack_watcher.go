@@ -0,0 +1,319 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// synackWatcherMinInterval keeps the watcher from hammering the Synack API.
+const synackWatcherMinInterval = 30 * time.Second
+
+var (
+	synackWatcherMu     sync.Mutex
+	synackWatcherStatus models.SynackWatcherStatus
+	synackWatcherPollMu sync.Mutex // Serializes polls (ticker and manual)
+	synackWatcherRun    *synackWatcherHandle
+)
+
+// synackWatcherHandle stops a watcher started by StartSynackWatcher.
+type synackWatcherHandle struct {
+	cancel context.CancelFunc
+}
+
+// RunSynackWatcher polls Synack for new targets and missions with the auth token captured by the
+// proxy until ctx is cancelled. New targets and missions are stored and, when they pass the
+// synack_watcher filters, announced through the configured notification channels.
+func RunSynackWatcher(ctx context.Context) {
+	conf := config.AppConfig.Watcher
+	interval := time.Duration(conf.PollIntervalSeconds) * time.Second
+	if interval < synackWatcherMinInterval {
+		interval = synackWatcherMinInterval
+	}
+	setSynackWatcherRunning(true)
+	defer setSynackWatcherRunning(false)
+	logger.Info("Synack watcher: Polling every %s (targets: %t, missions: %t)", interval, conf.WatchTargets, conf.WatchMissions)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := PollSynackWatcherNow(ctx); err != nil {
+			logger.Debug("Synack watcher: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Synack watcher: Stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StartSynackWatcher runs RunSynackWatcher in the background until ctx is cancelled or
+// StopSynackWatcher is called. It returns false if the watcher is already running.
+func StartSynackWatcher(ctx context.Context) bool {
+	synackWatcherMu.Lock()
+	defer synackWatcherMu.Unlock()
+	if synackWatcherRun != nil {
+		return false
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	run := &synackWatcherHandle{cancel: cancel}
+	synackWatcherRun = run
+	go func() {
+		RunSynackWatcher(watchCtx)
+		cancel()
+		synackWatcherMu.Lock()
+		if synackWatcherRun == run {
+			synackWatcherRun = nil
+		}
+		synackWatcherMu.Unlock()
+	}()
+	return true
+}
+
+// StopSynackWatcher stops the watcher started by StartSynackWatcher. It returns false if it was not
+// running.
+func StopSynackWatcher() bool {
+	synackWatcherMu.Lock()
+	defer synackWatcherMu.Unlock()
+	if synackWatcherRun == nil {
+		return false
+	}
+	synackWatcherRun.cancel()
+	synackWatcherRun = nil
+	return true
+}
+
+// PollSynackWatcherNow runs one watcher poll immediately.
+func PollSynackWatcherNow(ctx context.Context) error {
+	synackWatcherPollMu.Lock()
+	defer synackWatcherPollMu.Unlock()
+
+	provider, err := synackProviderWithToken()
+	if err != nil {
+		return err
+	}
+	conf := config.AppConfig.Watcher
+	var errs []string
+	if conf.WatchTargets {
+		if err := pollSynackTargets(ctx, provider); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if conf.WatchMissions {
+		if err := pollSynackMissions(ctx, provider); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	synackWatcherMu.Lock()
+	defer synackWatcherMu.Unlock()
+	now := time.Now()
+	synackWatcherStatus.LastPollAt = &now
+	synackWatcherStatus.Polls++
+	if len(errs) > 0 {
+		synackWatcherStatus.LastError = strings.Join(errs, "; ")
+		synackWatcherStatus.LastErrorAt = &now
+		return fmt.Errorf("synack watcher poll failed: %s", synackWatcherStatus.LastError)
+	}
+	return nil
+}
+
+// GetSynackWatcherStatus returns the watcher counters.
+func GetSynackWatcherStatus() models.SynackWatcherStatus {
+	synackWatcherMu.Lock()
+	defer synackWatcherMu.Unlock()
+	status := synackWatcherStatus
+	status.Enabled = config.AppConfig.Watcher.Enabled
+	return status
+}
+
+func setSynackWatcherRunning(running bool) {
+	synackWatcherMu.Lock()
+	synackWatcherStatus.Running = running
+	synackWatcherMu.Unlock()
+}
+
+// synackProviderWithToken returns the Synack provider of the running proxy once it has captured an
+// auth token.
+func synackProviderWithToken() (*SynackProvider, error) {
+	provider, err := GetPlatformProvider("synack")
+	if err != nil {
+		return nil, fmt.Errorf("synack provider is not running (the auth token is captured by the proxy)")
+	}
+	synack, ok := provider.(*SynackProvider)
+	if !ok {
+		return nil, fmt.Errorf("synack provider is not running (the auth token is captured by the proxy)")
+	}
+	if synack.currentAuthToken() == "" {
+		return nil, fmt.Errorf("no synack auth token captured yet; load the target list in the proxied browser first")
+	}
+	return synack, nil
+}
+
+// getSynackJSON requests rawURL with the captured auth token and returns the body of a 200 response.
+func (p *SynackProvider) getSynackJSON(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %w", rawURL, err)
+	}
+	req.Header.Set("Authorization", p.currentAuthToken())
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response from %s: %w", rawURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", rawURL, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// pollSynackTargets fetches the target list; processTargetList stores it and notifies new targets.
+func pollSynackTargets(ctx context.Context, provider *SynackProvider) error {
+	if provider.conf.TargetsURL == "" {
+		return fmt.Errorf("synack.targets_url is not configured")
+	}
+	body, err := provider.getSynackJSON(ctx, provider.conf.TargetsURL)
+	if err != nil {
+		return fmt.Errorf("polling synack targets: %w", err)
+	}
+	provider.recordProcessed()
+	if err := provider.processTargetList(body); err != nil {
+		provider.recordError(err)
+		return fmt.Errorf("processing synack targets: %w", err)
+	}
+	return nil
+}
+
+// pollSynackMissions fetches the available missions, stores unseen ones and notifies those paying
+// at least synack_watcher.mission_min_payout.
+func pollSynackMissions(ctx context.Context, provider *SynackProvider) error {
+	listURL := config.AppConfig.Missions.ListURL
+	if listURL == "" {
+		return fmt.Errorf("missions.list_url is not configured")
+	}
+	body, err := provider.getSynackJSON(ctx, listURL)
+	if err != nil {
+		return fmt.Errorf("polling synack missions: %w", err)
+	}
+	var rawMissions []json.RawMessage
+	if err := json.Unmarshal(body, &rawMissions); err != nil {
+		return fmt.Errorf("decoding synack missions: %w", err)
+	}
+
+	minPayout := config.AppConfig.Watcher.MissionMinPayout
+	for _, raw := range rawMissions {
+		var mission models.SynackAPIMission
+		if err := json.Unmarshal(raw, &mission); err != nil || mission.ID == "" {
+			logger.Error("Synack watcher: Skipping undecodable mission: %s", string(raw[:min(len(raw), 200)]))
+			continue
+		}
+		isNew, err := database.UpsertObservedSynackMission(mission, string(raw))
+		if err != nil {
+			logger.Error("Synack watcher: %v", err)
+			continue
+		}
+		if !isNew {
+			continue
+		}
+		synackWatcherMu.Lock()
+		synackWatcherStatus.NewMissions++
+		synackWatcherMu.Unlock()
+		logger.Info("Synack watcher: New mission '%s' (ID: %s, payout %.2f %s)", mission.Title, mission.ID, mission.Payout.Amount, mission.Payout.Currency)
+		if mission.Payout.Amount < minPayout {
+			continue
+		}
+		notifySynackWatcher(models.Notification{
+			Kind:    models.NotificationSynackMission,
+			Title:   fmt.Sprintf("New Synack mission: %s", mission.Title),
+			Message: joinNonEmpty(" - ", mission.ListingCodename, mission.CampaignName, fmt.Sprintf("%.2f %s", mission.Payout.Amount, mission.Payout.Currency)),
+			Data:    mission,
+		})
+	}
+	return nil
+}
+
+// notifyNewSynackTargets announces targets seen for the first time, filtered by
+// synack_watcher.target_categories. It does nothing unless the watcher watches targets.
+func notifyNewSynackTargets(targets []map[string]interface{}) {
+	conf := config.AppConfig.Watcher
+	if !conf.Enabled || !conf.WatchTargets {
+		return
+	}
+	synackWatcherMu.Lock()
+	synackWatcherStatus.NewTargets += int64(len(targets))
+	synackWatcherMu.Unlock()
+
+	for _, target := range targets {
+		codename, _ := target["codename"].(string)
+		name, _ := target["name"].(string)
+		category, _ := target["category"].(string)
+		if !synackCategoryWatched(conf.TargetCategories, category) {
+			logger.Debug("Synack watcher: New target '%s' in category '%s' does not match the category filter.", codename, category)
+			continue
+		}
+		notifySynackWatcher(models.Notification{
+			Kind:    models.NotificationSynackTarget,
+			Title:   fmt.Sprintf("New Synack target: %s", codename),
+			Message: joinNonEmpty(" - ", name, category),
+			Data:    target,
+		})
+	}
+}
+
+func synackCategoryWatched(categories []string, category string) bool {
+	if len(categories) == 0 {
+		return true
+	}
+	for _, c := range categories {
+		if strings.EqualFold(strings.TrimSpace(c), category) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// notifySynackWatcher sends n synchronously so the watcher status counts the outcome.
+func notifySynackWatcher(n models.Notification) {
+	if !NotificationEventEnabled(n.Kind) || !NotificationsConfigured() {
+		return
+	}
+	err := SendNotification(context.Background(), n)
+	synackWatcherMu.Lock()
+	defer synackWatcherMu.Unlock()
+	if err != nil {
+		now := time.Now()
+		synackWatcherStatus.NotificationErrors++
+		synackWatcherStatus.LastError = err.Error()
+		synackWatcherStatus.LastErrorAt = &now
+		logger.Error("Synack watcher: Sending notification '%s': %v", n.Title, err)
+		return
+	}
+	synackWatcherStatus.NotificationsSent++
+}
@@ -0,0 +1,501 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const (
+	// SyncMaxMessageBytes caps an encrypted sync request or response.
+	SyncMaxMessageBytes = 64 << 20
+	// syncMaxPlainBytes caps a sync message once decompressed.
+	syncMaxPlainBytes = 256 << 20
+	// syncBatchBytes is about how much row data a message carries; large traffic bodies make
+	// batches smaller than sync.batch_size.
+	syncBatchBytes = 16 << 20
+	// syncMaxClockSkew is how far the clocks of two instances may be apart. Older requests are
+	// refused, which with the request IDs seen keeps requests from being replayed.
+	syncMaxClockSkew = 10 * time.Minute
+	// syncRequestTimeout is the time an exchange with a peer may take.
+	syncRequestTimeout = 5 * time.Minute
+	// syncMaxRequests keeps a run from going on forever when a peer keeps changing.
+	syncMaxRequests = 1000
+	// syncMinInterval keeps the background loop from syncing more often than this.
+	syncMinInterval = time.Minute
+	// syncInstanceIDSetting is the app_settings key of the ID this instance sends to peers.
+	syncInstanceIDSetting = "sync_instance_id"
+	// syncEnvelopeVersion is the first byte of encrypted messages.
+	syncEnvelopeVersion = 1
+)
+
+var (
+	syncRunMu    sync.Mutex // Serializes runs against peers
+	syncServeMu  sync.Mutex // Serializes requests from peers
+	syncSeenMu   sync.Mutex
+	syncSeen     = map[string]time.Time{} // Request IDs received within syncMaxClockSkew, refused when replayed
+	syncPruneMu  sync.Mutex
+	syncPrunedAt time.Time
+)
+
+// GenerateSyncKey returns a new random sync.key.
+func GenerateSyncKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// syncCipher returns the AES-256-GCM cipher derived from sync.key.
+func syncCipher() (cipher.AEAD, error) {
+	encoded := strings.TrimSpace(config.AppConfig.Sync.Key)
+	if encoded == "" {
+		return nil, models.NewAppError(models.ErrCodeUnavailable, "sync.key is not set; generate one with 'toolkit sync keygen'")
+	}
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(secret) < 32 {
+		return nil, models.NewAppError(models.ErrCodeUnavailable, "sync.key must be base64 of at least 32 bytes; generate one with 'toolkit sync keygen'")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("toolkit-sync/1 aes-256-gcm"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSync encrypts a sync message: gzipped JSON sealed with AES-256-GCM, bound to its direction
+// (request or response) so a message can't be passed back as the other.
+func sealSync(v interface{}, direction string) ([]byte, error) {
+	aead, err := syncCipher()
+	if err != nil {
+		return nil, err
+	}
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		return nil, fmt.Errorf("encoding sync %s: %w", direction, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{syncEnvelopeVersion}, nonce...)
+	return aead.Seal(out, nonce, plain.Bytes(), []byte("toolkit-sync/1 "+direction)), nil
+}
+
+// openSync decrypts a sync message sealed by sealSync into v.
+func openSync(data []byte, direction string, v interface{}) error {
+	aead, err := syncCipher()
+	if err != nil {
+		return err
+	}
+	if len(data) < 1+aead.NonceSize() || data[0] != syncEnvelopeVersion {
+		return models.InvalidRequestError("invalid sync %s: not a sync message", direction)
+	}
+	nonce, sealed := data[1:1+aead.NonceSize()], data[1+aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte("toolkit-sync/1 "+direction))
+	if err != nil {
+		return models.NewAppError(models.ErrCodeUnauthorized, "could not decrypt the sync %s; do both instances have the same sync.key?", direction)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return models.InvalidRequestError("invalid sync %s: %v", direction, err)
+	}
+	dec := json.NewDecoder(io.LimitReader(zr, syncMaxPlainBytes))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return models.InvalidRequestError("invalid sync %s: %v", direction, err)
+	}
+	return nil
+}
+
+// SyncInstanceID returns the ID this instance identifies itself with to peers, created on first
+// use.
+func SyncInstanceID() (string, error) {
+	id, err := database.GetSetting(syncInstanceIDSetting)
+	if err != nil || id != "" {
+		return id, err
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id = hex.EncodeToString(buf)
+	return id, database.SetSetting(syncInstanceIDSetting, id)
+}
+
+func syncBatchSize() int {
+	if n := config.AppConfig.Sync.BatchSize; n > 0 {
+		return n
+	}
+	return 500
+}
+
+// prepareSync selects the traffic to sync and prunes old deletions, at most hourly.
+func prepareSync() error {
+	cfg := config.AppConfig.Sync
+	if _, err := database.SelectSyncTraffic(cfg.TrafficTags, cfg.FavoriteTraffic); err != nil {
+		return err
+	}
+	syncPruneMu.Lock()
+	defer syncPruneMu.Unlock()
+	if cfg.TombstoneDays <= 0 || time.Since(syncPrunedAt) < time.Hour {
+		return nil
+	}
+	n, err := database.PruneSyncTombstones(time.Now().AddDate(0, 0, -cfg.TombstoneDays))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.Info("Sync: Pruned %d deletions older than %d days", n, cfg.TombstoneDays)
+	}
+	syncPrunedAt = time.Now()
+	return nil
+}
+
+// checkSyncAvailable returns why this instance can't sync, if it can't.
+func checkSyncAvailable() error {
+	if !config.AppConfig.Sync.Enabled {
+		return models.NotFoundError("sync is not enabled (sync.enabled)")
+	}
+	if ReadOnly() {
+		return models.NewAppError(models.ErrCodeForbidden, "the toolkit is in read-only mode")
+	}
+	_, err := syncCipher()
+	return err
+}
+
+// ServeSync answers an encrypted sync request from a peer: it applies the peer's changes and
+// returns the local changes since the point the peer asked for, encrypted.
+func ServeSync(body []byte) ([]byte, error) {
+	if err := checkSyncAvailable(); err != nil {
+		return nil, err
+	}
+	var req models.SyncRequest
+	if err := openSync(body, "request", &req); err != nil {
+		return nil, err
+	}
+	if req.Protocol != models.SyncProtocolVersion {
+		return nil, models.InvalidRequestError("invalid sync request: protocol %d, this instance speaks %d", req.Protocol, models.SyncProtocolVersion)
+	}
+	if skew := time.Since(req.SentAt); skew > syncMaxClockSkew || skew < -syncMaxClockSkew {
+		return nil, models.NewAppError(models.ErrCodeUnauthorized, "sync request sent at %s is too old or too far ahead; check the clocks of both instances",
+			req.SentAt.Format(time.RFC3339))
+	}
+	instanceID, err := SyncInstanceID()
+	if err != nil {
+		return nil, err
+	}
+	if req.InstanceID == instanceID {
+		return nil, models.InvalidRequestError("invalid sync request: it comes from this instance; check the peer URL")
+	}
+	if !markSyncRequestSeen(req.RequestID) {
+		return nil, models.ConflictError("sync request %s was already received", req.RequestID)
+	}
+
+	syncServeMu.Lock()
+	defer syncServeMu.Unlock()
+	if err := prepareSync(); err != nil {
+		return nil, err
+	}
+	applied, _, err := database.ApplySyncRecords(req.Records, -1)
+	if err != nil {
+		return nil, err
+	}
+	received := make(map[string]bool, len(req.Records))
+	for _, r := range req.Records {
+		received[r.SyncID] = true
+	}
+	records, until, more, err := database.ReadSyncChanges(req.Since, syncBatchSize(), syncBatchBytes, received)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Sync: Peer %s sent %d records (%d inserted, %d updated, %d deleted, %d skipped), received %d",
+		req.InstanceID, len(req.Records), applied.Inserted, applied.Updated, applied.Deleted, applied.Skipped, len(records))
+	return sealSync(models.SyncResponse{Protocol: models.SyncProtocolVersion, RequestID: req.RequestID, InstanceID: instanceID,
+		Records: records, Until: until, More: more, Applied: applied}, "response")
+}
+
+// markSyncRequestSeen records a request ID, reporting false when it was seen before.
+func markSyncRequestSeen(id string) bool {
+	syncSeenMu.Lock()
+	defer syncSeenMu.Unlock()
+	for seen, at := range syncSeen {
+		if time.Since(at) > 2*syncMaxClockSkew {
+			delete(syncSeen, seen)
+		}
+	}
+	if id == "" || !syncSeen[id].IsZero() {
+		return false
+	}
+	syncSeen[id] = time.Now()
+	return true
+}
+
+// syncPeers returns the peers of sync.peers named name, or all of them when name is empty.
+func syncPeers(name string) ([]config.SyncPeerConfig, error) {
+	var peers []config.SyncPeerConfig
+	for _, p := range config.AppConfig.Sync.Peers {
+		if name == "" || p.Name == name {
+			peers = append(peers, p)
+		}
+	}
+	if name != "" && len(peers) == 0 {
+		return nil, models.NotFoundError("sync peer '%s' not found in sync.peers", name)
+	}
+	if len(peers) == 0 {
+		return nil, models.InvalidRequestError("invalid sync run: no peers in sync.peers")
+	}
+	return peers, nil
+}
+
+// StartSync starts a job syncing with a peer, or with all peers of sync.peers when req.Peer is
+// empty.
+func StartSync(req models.SyncRunRequest) (models.Job, error) {
+	if err := checkSyncAvailable(); err != nil {
+		return models.Job{}, err
+	}
+	peers, err := syncPeers(req.Peer)
+	if err != nil {
+		return models.Job{}, err
+	}
+	return StartJob(models.JobTypeSync, nil, fmt.Sprintf("Syncing with %d peer(s)...", len(peers)), func(ctx context.Context, job *JobHandle) error {
+		job.SetTotal(int64(len(peers)))
+		var summary []string
+		var lastErr error
+		for _, peer := range peers {
+			job.SetMessage("Syncing with %s...", peer.Name)
+			result, err := SyncWithPeer(ctx, peer)
+			if err != nil {
+				job.RecordError(err)
+				job.AddProgress(1, 0)
+				summary = append(summary, fmt.Sprintf("%s: %v", peer.Name, err))
+				lastErr = err
+				continue
+			}
+			job.AddProgress(1, 1)
+			summary = append(summary, fmt.Sprintf("%s: sent %d, received %d", peer.Name, result.Pushed, result.Pulled))
+		}
+		job.SetMessage("%s", strings.Join(summary, "; "))
+		if len(peers) == 1 {
+			return lastErr
+		}
+		return nil
+	})
+}
+
+// RunSync syncs with every peer of sync.peers, logging the outcome.
+func RunSync(ctx context.Context) {
+	peers, err := syncPeers("")
+	if err != nil {
+		logger.Warn("Sync: %v", err)
+		return
+	}
+	for _, peer := range peers {
+		result, err := SyncWithPeer(ctx, peer)
+		if err != nil {
+			logger.Warn("Sync: %s: %v", peer.Name, err)
+			continue
+		}
+		logger.Info("Sync: %s: sent %d records, received %d (%d inserted, %d updated, %d deleted)", peer.Name, result.Pushed, result.Pulled,
+			result.Local.Inserted, result.Local.Updated, result.Local.Deleted)
+	}
+}
+
+// RunSyncLoop syncs with every peer every sync.interval_minutes until ctx is cancelled.
+func RunSyncLoop(ctx context.Context) {
+	interval := time.Duration(config.AppConfig.Sync.IntervalMinutes) * time.Minute
+	if interval < syncMinInterval {
+		interval = syncMinInterval
+	}
+	logger.Info("Sync: Syncing with %d peer(s) every %s", len(config.AppConfig.Sync.Peers), interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := checkSyncAvailable(); err != nil {
+			logger.Warn("Sync: %v", err)
+		} else {
+			RunSync(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Sync: Stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncWithPeer exchanges changes with a peer until neither side has more: local changes are sent
+// and applied by the peer, which answers with its changes. The progress is stored after every
+// exchange, so an interrupted run resumes where it stopped.
+func SyncWithPeer(ctx context.Context, peer config.SyncPeerConfig) (models.SyncRunResult, error) {
+	result := models.SyncRunResult{Peer: peer.Name}
+	if err := checkSyncAvailable(); err != nil {
+		return result, err
+	}
+	syncRunMu.Lock()
+	defer syncRunMu.Unlock()
+
+	states, err := database.GetSyncPeerStates()
+	if err != nil {
+		return result, err
+	}
+	state := states[peer.Name]
+	pulled, pushed := state.PulledUntil, state.PushedUntil
+	runErr := func() error {
+		if err := prepareSync(); err != nil {
+			return err
+		}
+		instanceID, err := SyncInstanceID()
+		if err != nil {
+			return err
+		}
+		for result.Requests < syncMaxRequests {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			records, localUntil, localMore, err := database.ReadSyncChanges(pushed, syncBatchSize(), syncBatchBytes, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := exchangeSync(ctx, peer, models.SyncRequest{Protocol: models.SyncProtocolVersion, RequestID: newSyncRequestID(),
+				InstanceID: instanceID, SentAt: time.Now().UTC(), Since: pulled, Records: records})
+			if err != nil {
+				return err
+			}
+			result.Requests++
+			result.Pushed += len(records)
+			addSyncApplyResult(&result.Remote, resp.Applied)
+			pushed = localUntil
+
+			applied, sentUntil, err := database.ApplySyncRecords(resp.Records, pushed)
+			if err != nil {
+				return err
+			}
+			result.Pulled += len(resp.Records)
+			addSyncApplyResult(&result.Local, applied)
+			pulled, pushed = resp.Until, sentUntil
+			if err := database.SaveSyncPeerState(peer.Name, pulled, pushed, nil, nil); err != nil {
+				return err
+			}
+			if !localMore && !resp.More {
+				return nil
+			}
+		}
+		return fmt.Errorf("still changing after %d requests; the rest is sent next time", syncMaxRequests)
+	}()
+	if err := database.SaveSyncPeerState(peer.Name, pulled, pushed, &result, runErr); err != nil {
+		logger.Error("Sync: %v", err)
+	}
+	return result, runErr
+}
+
+func newSyncRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func addSyncApplyResult(total *models.SyncApplyResult, r models.SyncApplyResult) {
+	total.Inserted += r.Inserted
+	total.Updated += r.Updated
+	total.Deleted += r.Deleted
+	total.Unchanged += r.Unchanged
+	total.Skipped += r.Skipped
+}
+
+// exchangeSync sends an encrypted request to a peer's POST /sync and decrypts the answer.
+func exchangeSync(ctx context.Context, peer config.SyncPeerConfig, req models.SyncRequest) (models.SyncResponse, error) {
+	var out models.SyncResponse
+	body, err := sealSync(req, "request")
+	if err != nil {
+		return out, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, syncRequestTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(peer.URL, "/")+"/sync", bytes.NewReader(body))
+	if err != nil {
+		return out, fmt.Errorf("invalid URL of sync peer '%s': %w", peer.Name, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	if peer.APIToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+peer.APIToken)
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return out, fmt.Errorf("contacting sync peer '%s': %w", peer.Name, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, SyncMaxMessageBytes+1))
+	if err != nil {
+		return out, fmt.Errorf("reading answer of sync peer '%s': %w", peer.Name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var problem models.ProblemDetails
+		if json.Unmarshal(data, &problem) == nil && problem.Detail != "" {
+			return out, fmt.Errorf("sync peer '%s' answered %d: %s", peer.Name, resp.StatusCode, problem.Detail)
+		}
+		return out, fmt.Errorf("sync peer '%s' answered %d: %s", peer.Name, resp.StatusCode, firstLine(string(data)))
+	}
+	if len(data) > SyncMaxMessageBytes {
+		return out, fmt.Errorf("answer of sync peer '%s' is too large", peer.Name)
+	}
+	if err := openSync(data, "response", &out); err != nil {
+		return out, fmt.Errorf("sync peer '%s': %w", peer.Name, err)
+	}
+	if out.RequestID != req.RequestID {
+		return out, fmt.Errorf("sync peer '%s' answered another request", peer.Name)
+	}
+	return out, nil
+}
+
+// GetSyncStatus describes the sync setup and the progress with each peer.
+func GetSyncStatus() (models.SyncStatus, error) {
+	_, keyErr := syncCipher()
+	status := models.SyncStatus{Enabled: config.AppConfig.Sync.Enabled, KeyConfigured: keyErr == nil, Peers: []models.SyncPeerStatus{}}
+	var err error
+	if status.InstanceID, err = database.GetSetting(syncInstanceIDSetting); err != nil {
+		return status, err
+	}
+	if status.Sequence, err = database.SyncSequence(); err != nil {
+		return status, err
+	}
+	states, err := database.GetSyncPeerStates()
+	if err != nil {
+		return status, err
+	}
+	for _, peer := range config.AppConfig.Sync.Peers {
+		s := states[peer.Name]
+		s.Name, s.URL = peer.Name, peer.URL
+		if s.Pending, err = database.CountSyncChanges(s.PushedUntil); err != nil {
+			return status, err
+		}
+		status.Peers = append(status.Peers, s)
+	}
+	return status, nil
+}
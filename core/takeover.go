@@ -0,0 +1,441 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// maxTakeoverCNAMEHops bounds the CNAME chain followed from a domain.
+const maxTakeoverCNAMEHops = 10
+
+// takeoverFingerprint describes a service whose unclaimed resources can be taken over: the CNAME
+// targets it is recognized by, and how an unclaimed resource shows itself.
+type takeoverFingerprint struct {
+	service      string
+	cnames       []string // Substrings of the service's CNAME targets
+	fingerprints []string // Substrings of the response of an unclaimed resource
+	status       int      // Required status of that response (0 = any)
+	nxdomain     bool     // A CNAME target that does not resolve can be registered by anyone
+}
+
+// takeoverFingerprints are the built-in services, after can-i-take-over-xyz. Only services
+// listed there as vulnerable are included; a fingerprint alone is never trusted without a CNAME
+// to the service.
+var takeoverFingerprints = []takeoverFingerprint{
+	{service: "AWS S3", cnames: []string{"s3.amazonaws.com", "s3-website", "s3.dualstack"}, fingerprints: []string{"NoSuchBucket", "The specified bucket does not exist"}, status: 404},
+	{service: "AWS Elastic Beanstalk", cnames: []string{"elasticbeanstalk.com"}, nxdomain: true},
+	{service: "GitHub Pages", cnames: []string{"github.io"}, fingerprints: []string{"There isn't a GitHub Pages site here."}, status: 404},
+	{service: "Heroku", cnames: []string{"herokuapp.com", "herokudns.com", "herokussl.com"}, fingerprints: []string{"No such app", "herokucdn.com/error-pages/no-such-app.html"}, status: 404},
+	{service: "Microsoft Azure", cnames: []string{"azurewebsites.net", "cloudapp.net", "cloudapp.azure.com", "trafficmanager.net", "blob.core.windows.net",
+		"azureedge.net", "azure-api.net", "azurehdinsight.net", "azurecontainer.io", "azurefd.net", "database.windows.net", "search.windows.net",
+		"servicebus.windows.net", "redis.cache.windows.net", "azurestaticapps.net"}, nxdomain: true},
+	{service: "Fastly", cnames: []string{"fastly.net"}, fingerprints: []string{"Fastly error: unknown domain"}},
+	{service: "Shopify", cnames: []string{"myshopify.com"}, fingerprints: []string{"Sorry, this shop is currently unavailable"}},
+	{service: "Ghost", cnames: []string{"ghost.io"}, fingerprints: []string{"Failed to resolve DNS path for this host"}},
+	{service: "Netlify", cnames: []string{"netlify.app", "netlify.com"}, fingerprints: []string{"Not Found - Request ID:"}, status: 404},
+	{service: "Zendesk", cnames: []string{"zendesk.com"}, fingerprints: []string{"Help Center Closed"}},
+	{service: "Unbounce", cnames: []string{"unbouncepages.com"}, fingerprints: []string{"The requested URL was not found on this server"}, status: 404},
+	{service: "Readme.io", cnames: []string{"readme.io"}, fingerprints: []string{"Project doesnt exist... yet!"}},
+	{service: "Surge.sh", cnames: []string{"surge.sh"}, fingerprints: []string{"project not found"}},
+	{service: "Bitbucket", cnames: []string{"bitbucket.io"}, fingerprints: []string{"Repository not found"}},
+	{service: "Tumblr", cnames: []string{"domains.tumblr.com"}, fingerprints: []string{"Whatever you were looking for doesn't currently exist at this address"}},
+	{service: "WordPress.com", cnames: []string{"wordpress.com"}, fingerprints: []string{"Do you want to register"}},
+	{service: "Pantheon", cnames: []string{"pantheonsite.io"}, fingerprints: []string{"The gods are wise, but do not know of the site which you seek."}},
+	{service: "Agile CRM", cnames: []string{"agilecrm.com"}, fingerprints: []string{"Sorry, this page is no longer available."}},
+	{service: "Help Scout", cnames: []string{"helpscoutdocs.com"}, fingerprints: []string{"No settings were found for this company:"}},
+	{service: "Helpjuice", cnames: []string{"helpjuice.com"}, fingerprints: []string{"We could not find what you're looking for."}},
+	{service: "Launchrock", cnames: []string{"launchrock.com"}, fingerprints: []string{"It looks like you may have taken a wrong turn somewhere."}},
+	{service: "Ngrok", cnames: []string{"ngrok.io"}, fingerprints: []string{"ngrok.io not found"}},
+	{service: "Uberflip", cnames: []string{"read.uberflip.com"}, fingerprints: []string{"The URL you've accessed does not provide a hub."}},
+	{service: "Kinsta", cnames: []string{"kinsta.cloud"}, fingerprints: []string{"No Site For Domain"}},
+	{service: "Pingdom", cnames: []string{"stats.pingdom.com"}, fingerprints: []string{"Sorry, couldn't find the status page"}},
+	{service: "SurveySparrow", cnames: []string{"surveysparrow.com"}, fingerprints: []string{"Account not found."}},
+	{service: "Canny", cnames: []string{"cname.canny.io"}, fingerprints: []string{"Company Not Found"}},
+	{service: "Worksites", cnames: []string{"worksites.net"}, fingerprints: []string{"Hello! Sorry, but the website you&rsquo;re looking for doesn&rsquo;t exist."}},
+}
+
+// activeTakeoverFingerprints returns the configured fingerprints followed by the built-in ones.
+func activeTakeoverFingerprints() []takeoverFingerprint {
+	var fps []takeoverFingerprint
+	for _, c := range config.AppConfig.Takeover.Fingerprints {
+		fp := takeoverFingerprint{service: c.Service, fingerprints: c.Fingerprints, status: c.StatusCode, nxdomain: c.NXDomain}
+		for _, cname := range c.CNAMEs {
+			if cname = strings.ToLower(strings.Trim(strings.TrimSpace(cname), ".")); cname != "" {
+				fp.cnames = append(fp.cnames, cname)
+			}
+		}
+		if fp.service == "" || len(fp.cnames) == 0 {
+			logger.Warn("Takeover check: Ignoring configured fingerprint %q without service or cnames", c.Service)
+			continue
+		}
+		fps = append(fps, fp)
+	}
+	return append(fps, takeoverFingerprints...)
+}
+
+// takeoverChecker runs one takeover check job.
+type takeoverChecker struct {
+	targetID     int64
+	client       *http.Client
+	resolver     *net.Resolver
+	fingerprints []takeoverFingerprint
+	workers      int
+
+	mu         sync.Mutex
+	titles     map[string]bool // Finding titles of the target, to skip duplicates
+	findings   int
+	vulnerable []string
+	dangling   int
+}
+
+// StartTakeoverCheck starts a job verifying subdomain takeovers of a target's domains. The CNAME
+// chain of each domain is followed through the recon resolvers; a chain into a known service is
+// confirmed by the service's fingerprint in the domain's response, or by its last target not
+// resolving for services whose names anyone can register. The result is stored on the domain,
+// fingerprinted responses are logged to the traffic log and confirmed takeovers become Draft
+// findings. CNAMEs that do not resolve without a matching service are flagged as dangling.
+func StartTakeoverCheck(targetID int64, req models.TakeoverCheckRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	domains, err := takeoverDomains(targetID, req.DomainIDs)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(domains) == 0 {
+		return models.Job{}, fmt.Errorf("no domains to check for target %d (add domains or pass domain_ids)", targetID)
+	}
+	latest, err := LatestJob(models.JobTypeTakeoverCheck, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a takeover check is already running for target %d (job %d)", targetID, latest.ID)
+	}
+
+	conf := config.AppConfig.Takeover
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	c := &takeoverChecker{
+		targetID: targetID,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}, targetID)),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		resolver:     reconResolver(),
+		fingerprints: activeTakeoverFingerprints(),
+		workers:      conf.Workers,
+	}
+	return StartJob(models.JobTypeTakeoverCheck, &targetID, fmt.Sprintf("Checking %d domains for subdomain takeover...", len(domains)), func(ctx context.Context, job *JobHandle) error {
+		return c.run(ctx, job, domains)
+	})
+}
+
+// takeoverDomains returns the requested domains of the target, or every domain within its scope
+// rules when none are requested. Wildcard entries are skipped.
+func takeoverDomains(targetID int64, ids []int64) ([]models.Domain, error) {
+	var rules []models.ScopeRule
+	if len(ids) == 0 {
+		all, err := database.GetDomainIDsByFilters(models.DomainFilters{TargetID: targetID, HttpxScanStatus: "all"})
+		if err != nil {
+			return nil, err
+		}
+		if rules, err = database.GetScopeRulesByTargetID(targetID); err != nil {
+			return nil, err
+		}
+		ids = all
+	}
+	domains, err := database.GetDomainsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	var selected []models.Domain
+	for _, d := range domains {
+		if d.TargetID != targetID || strings.Contains(d.DomainName, "*") {
+			continue
+		}
+		if rules != nil && !isRequestEffectivelyInScope(&url.URL{Scheme: "https", Host: d.DomainName, Path: "/"}, rules) {
+			continue
+		}
+		selected = append(selected, d)
+	}
+	return selected, nil
+}
+
+func (c *takeoverChecker) run(ctx context.Context, job *JobHandle, domains []models.Domain) error {
+	job.SetTotal(int64(len(domains)))
+	existing, err := database.GetTargetFindingsByTargetID(c.targetID)
+	if err != nil {
+		return err
+	}
+	c.titles = make(map[string]bool, len(existing))
+	for _, f := range existing {
+		c.titles[f.Title] = true
+	}
+	workers := c.workers
+	if workers <= 0 {
+		workers = 10
+	}
+
+	ch := make(chan models.Domain)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range ch {
+				vulnerable, err := c.checkDomain(ctx, d)
+				if err != nil {
+					job.RecordError(fmt.Errorf("%s: %w", d.DomainName, err))
+				}
+				if vulnerable {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for _, d := range domains {
+		select {
+		case <-ctx.Done():
+			break feed
+		case ch <- d:
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	job.SetMessage("Checked %d domains: %d vulnerable, %d dangling, %d new findings.", len(domains), len(c.vulnerable), c.dangling, c.findings)
+	if c.findings > 0 {
+		NotifyTargetEvent(models.NotificationScanFinished, c.targetID, fmt.Sprintf("Takeover check confirmed %d vulnerable subdomains", len(c.vulnerable)),
+			SubdomainNotificationList(c.vulnerable, 10), c.vulnerable)
+	}
+	return ctx.Err()
+}
+
+// checkDomain follows the CNAME chain of one domain, verifies a takeover of the service it points
+// to and stores the result on the domain. Lookup failures other than a missing name leave the
+// domain's previous result in place.
+func (c *takeoverChecker) checkDomain(ctx context.Context, d models.Domain) (bool, error) {
+	name := strings.ToLower(strings.TrimSuffix(d.DomainName, "."))
+	chain, err := c.cnameChain(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	result := models.Domain{ID: d.ID, TakeoverStatus: models.NullString(models.TakeoverStatusNotVulnerable)}
+	if len(chain) == 0 {
+		return false, database.UpdateDomainTakeover(result)
+	}
+	result.TakeoverCNAME = models.NullString(strings.Join(append([]string{name}, chain...), " -> "))
+	last := chain[len(chain)-1]
+	fp := c.matchService(chain)
+	if fp != nil {
+		result.TakeoverService = models.NullString(fp.service)
+	}
+	resolves, err := c.resolves(ctx, last)
+	if err != nil {
+		return false, err
+	}
+
+	var evidence *models.HTTPTrafficLog
+	var matched string
+	switch {
+	case !resolves && fp != nil && fp.nxdomain:
+		result.TakeoverStatus = models.NullString(models.TakeoverStatusVulnerable)
+	case !resolves:
+		result.TakeoverStatus = models.NullString(models.TakeoverStatusDangling)
+	case fp != nil && len(fp.fingerprints) > 0:
+		entry, fingerprint, err := c.fetchFingerprint(ctx, name, fp)
+		if err != nil {
+			logger.Debug("Takeover check: No response from %s: %v", name, err)
+		}
+		if fingerprint != "" {
+			result.TakeoverStatus = models.NullString(models.TakeoverStatusVulnerable)
+			result.TakeoverEvidenceLogID = sql.NullInt64{Int64: entry.ID, Valid: entry.ID != 0}
+			evidence, matched = &entry, fingerprint
+		}
+	}
+	if err := database.UpdateDomainTakeover(result); err != nil {
+		return false, err
+	}
+
+	switch result.TakeoverStatus.String {
+	case models.TakeoverStatusDangling:
+		c.mu.Lock()
+		c.dangling++
+		c.mu.Unlock()
+		logger.Info("Takeover check: %s has a dangling CNAME (%s)", name, result.TakeoverCNAME.String)
+		return false, nil
+	case models.TakeoverStatusVulnerable:
+		c.mu.Lock()
+		c.vulnerable = append(c.vulnerable, name)
+		c.mu.Unlock()
+		return true, c.fileFinding(d, chain, fp, evidence, matched)
+	}
+	return false, nil
+}
+
+// cnameChain follows the CNAMEs of name until a name is its own canonical name, so the chain is
+// recorded whether or not its last target resolves. Resolvers may skip intermediate aliases. It
+// is empty when name has no CNAME or does not exist.
+func (c *takeoverChecker) cnameChain(ctx context.Context, name string) ([]string, error) {
+	var chain []string
+	seen := map[string]bool{name: true}
+	current := name
+	for i := 0; i < maxTakeoverCNAMEHops; i++ {
+		cname, err := c.resolver.LookupCNAME(ctx, current)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				break
+			}
+			return nil, fmt.Errorf("looking up CNAME of %s: %w", current, err)
+		}
+		cname = strings.ToLower(strings.TrimSuffix(cname, "."))
+		if cname == "" || seen[cname] {
+			break
+		}
+		seen[cname] = true
+		chain = append(chain, cname)
+		current = cname
+	}
+	return chain, nil
+}
+
+// resolves reports whether host has addresses; false means NXDOMAIN or no records.
+func (c *takeoverChecker) resolves(ctx context.Context, host string) (bool, error) {
+	_, err := c.resolver.LookupHost(ctx, host)
+	if err == nil {
+		return true, nil
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("resolving %s: %w", host, err)
+}
+
+// matchService returns the fingerprint of the service the chain points into, matching the
+// deepest alias first, or nil.
+func (c *takeoverChecker) matchService(chain []string) *takeoverFingerprint {
+	for i := len(chain) - 1; i >= 0; i-- {
+		for j := range c.fingerprints {
+			for _, cname := range c.fingerprints[j].cnames {
+				if strings.Contains(chain[i], cname) {
+					return &c.fingerprints[j]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fetchFingerprint requests the domain over HTTPS, then plain HTTP, and returns the first logged
+// response carrying one of the service's fingerprints with the fingerprint, or the last response
+// and an empty fingerprint.
+func (c *takeoverChecker) fetchFingerprint(ctx context.Context, name string, fp *takeoverFingerprint) (models.HTTPTrafficLog, string, error) {
+	var entry models.HTTPTrafficLog
+	var lastErr error
+	for _, scheme := range []string{"https", "http"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+name+"/", nil)
+		if err != nil {
+			return entry, "", err
+		}
+		logged, _, err := sendLoggedProbe(c.client, c.targetID, req, models.TakeoverCheckLogSource)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		entry = logged
+		if fp.status != 0 && logged.ResponseStatusCode != fp.status {
+			continue
+		}
+		for _, fingerprint := range fp.fingerprints {
+			if fingerprint != "" && strings.Contains(string(logged.ResponseBody), fingerprint) {
+				return logged, fingerprint, nil
+			}
+		}
+	}
+	return entry, "", lastErr
+}
+
+// fileFinding creates a Draft finding for a confirmed takeover, unless the target already has one
+// with the same title.
+func (c *takeoverChecker) fileFinding(d models.Domain, chain []string, fp *takeoverFingerprint, evidence *models.HTTPTrafficLog, fingerprint string) error {
+	name := strings.ToLower(strings.TrimSuffix(d.DomainName, "."))
+	last := chain[len(chain)-1]
+	title := fmt.Sprintf("Subdomain takeover: %s (%s)", name, fp.service)
+	c.mu.Lock()
+	if c.titles[title] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.titles[title] = true
+	c.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "`%s` points to `%s` on %s, which is not claimed:\n\n", name, last, fp.service)
+	fmt.Fprintf(&b, "- **CNAME chain:** `%s`\n", strings.Join(append([]string{name}, chain...), "` -> `"))
+	steps := fmt.Sprintf("1. Resolve the CNAME chain:\n\n```sh\ndig %s CNAME\n```\n\n", name)
+	finding := models.TargetFinding{
+		TargetID: c.targetID,
+		Title:    title,
+		Impact: models.NullString(fmt.Sprintf("Whoever claims `%s` on %s serves content on `%s`: phishing under the target's domain, "+
+			"cookies scoped to the parent domain, and CSP, CORS or OAuth redirect allowlists that trust its subdomains.", last, fp.service, name)),
+		Payload:  models.NullString(last),
+		Severity: models.NullString("High"),
+		Status:   models.FindingStatusDraft,
+	}
+	if evidence != nil {
+		entry := *evidence
+		if rules := EffectiveRedactionRules(&c.targetID); rules.Enabled && rules.ApplyAt != models.RedactionApplyStorage {
+			RedactTrafficLog(&entry, rules)
+		}
+		reqHeaders := HeadersFromJSON(entry.RequestHeaders.String)
+		fmt.Fprintf(&b, "- **Fingerprint:** `%s` in the %d response (log entry %d)\n\n", fingerprint, entry.ResponseStatusCode, entry.ID)
+		b.WriteString(findingEvidence(entry, http.MethodGet, reqHeaders))
+		steps += fmt.Sprintf("2. Request the domain and observe `%s` in the response:\n\n```sh\n%s\n```\n\n",
+			fingerprint, BuildCurlCommand(http.MethodGet, entry.RequestURL.String, reqHeaders, nil))
+		finding.HTTPTrafficLogID = sql.NullInt64{Int64: entry.ID, Valid: entry.ID != 0}
+	} else {
+		fmt.Fprintf(&b, "- **Resolution:** `%s` does not resolve, and %s names can be registered by anyone\n", last, fp.service)
+		steps += fmt.Sprintf("2. Observe that `%s` does not resolve:\n\n```sh\ndig %s\n```\n\n", last, last)
+	}
+	steps += fmt.Sprintf("3. Claim `%s` on %s and serve a harmless proof-of-concept page on `%s`.", last, fp.service, name)
+	finding.Description = models.NullString(b.String())
+	finding.StepsToReproduce = models.NullString(steps)
+	if vt, err := FindVulnerabilityType(0, "Subdomain Takeover"); err == nil {
+		finding.VulnerabilityTypeID = sql.NullInt64{Int64: vt.ID, Valid: true}
+	}
+	id, err := database.CreateTargetFinding(finding)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.findings++
+	c.mu.Unlock()
+	logger.Info("Takeover check: Draft finding %d for %s (%s)", id, name, fp.service)
+	return nil
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// certificateExpiryWarning is how long before its expiry a certificate is reported as expiring.
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// tlsConnectionIDs caches the IDs of stored TLS connections by fingerprint, so only connections
+// with new parameters reach the database.
+var tlsConnectionIDs sync.Map // map[string]int64
+
+// recordTLSConnection stores the TLS parameters of the connection a response to host came over and
+// returns their ID, which is invalid for responses that did not come over TLS.
+func recordTLSConnection(host string, state *tls.ConnectionState) sql.NullInt64 {
+	if state == nil || !state.HandshakeComplete {
+		return sql.NullInt64{}
+	}
+	host = strings.ToLower(host)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d\x00%s", host, state.ServerName, state.Version, state.CipherSuite, state.NegotiatedProtocol)
+	for _, cert := range state.PeerCertificates {
+		h.Write(cert.Raw)
+	}
+	fingerprint := hex.EncodeToString(h.Sum(nil))
+	if id, ok := tlsConnectionIDs.Load(fingerprint); ok {
+		return sql.NullInt64{Int64: id.(int64), Valid: true}
+	}
+	id, err := database.GetOrCreateTLSConnection(fingerprint, describeTLSConnection(host, state))
+	if err != nil {
+		logger.ProxyError("%v", err)
+		return sql.NullInt64{}
+	}
+	tlsConnectionIDs.Store(fingerprint, id)
+	return sql.NullInt64{Int64: id, Valid: true}
+}
+
+// describeTLSConnection summarizes a connection state. The chain is verified against the system
+// roots at a time the leaf is valid, so an expired certificate is not also reported as untrusted.
+func describeTLSConnection(host string, state *tls.ConnectionState) models.TLSConnection {
+	c := models.TLSConnection{
+		Host:             host,
+		ServerName:       state.ServerName,
+		Version:          tls.VersionName(state.Version),
+		CipherSuite:      tls.CipherSuiteName(state.CipherSuite),
+		ALPN:             state.NegotiatedProtocol,
+		CertificateChain: []models.TLSCertificateSummary{},
+	}
+	for _, cert := range state.PeerCertificates {
+		c.CertificateChain = append(c.CertificateChain, summarizeCertificate(cert))
+	}
+	if len(state.PeerCertificates) == 0 {
+		c.UntrustedReason = "the server sent no certificate"
+		return c
+	}
+	leaf := state.PeerCertificates[0]
+	c.CertificateChain[0].DNSNames = leaf.DNSNames
+	for _, ip := range leaf.IPAddresses {
+		c.CertificateChain[0].DNSNames = append(c.CertificateChain[0].DNSNames, ip.String())
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	at := time.Now()
+	if at.After(leaf.NotAfter) {
+		at = leaf.NotAfter
+	} else if at.Before(leaf.NotBefore) {
+		at = leaf.NotBefore
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, CurrentTime: at}); err != nil {
+		c.UntrustedReason = err.Error()
+	}
+	c.HostnameMismatch = leaf.VerifyHostname(host) != nil
+	return c
+}
+
+// summarizeCertificate describes a certificate of a server's chain.
+func summarizeCertificate(cert *x509.Certificate) models.TLSCertificateSummary {
+	sum := sha256.Sum256(cert.Raw)
+	s := models.TLSCertificateSummary{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SelfSigned:         bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil,
+		SHA256:             hex.EncodeToString(sum[:]),
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		s.KeyType, s.KeyBits = "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		s.KeyType, s.KeyBits = "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		s.KeyType, s.KeyBits = "Ed25519", 256
+	default:
+		s.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+	return s
+}
+
+var (
+	legacyTLSVersions   = map[string]bool{"SSLv3": true, "TLS 1.0": true, "TLS 1.1": true}
+	weakSignatures      = map[string]bool{"MD2-RSA": true, "MD5-RSA": true, "SHA1-RSA": true, "DSA-SHA1": true, "ECDSA-SHA1": true}
+	insecureCipherNames = func() map[string]bool {
+		names := map[string]bool{}
+		for _, suite := range tls.InsecureCipherSuites() {
+			names[suite.Name] = true
+		}
+		return names
+	}()
+)
+
+// tlsConnectionIssues returns the weaknesses of a connection's version, cipher suite and
+// certificates as of now.
+func tlsConnectionIssues(c models.TLSConnection, now time.Time) []models.TLSIssue {
+	var issues []models.TLSIssue
+	if legacyTLSVersions[c.Version] {
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: c.Version + " negotiated"})
+	}
+	switch {
+	case insecureCipherNames[c.CipherSuite]:
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "insecure cipher suite " + c.CipherSuite})
+	case strings.HasPrefix(c.CipherSuite, "TLS_RSA_"):
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueMedium, Issue: "cipher suite without forward secrecy " + c.CipherSuite})
+	case strings.Contains(c.CipherSuite, "_CBC_"):
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueLow, Issue: "CBC-mode cipher suite " + c.CipherSuite})
+	}
+	if len(c.CertificateChain) == 0 {
+		return append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "no certificate"})
+	}
+
+	leaf := c.CertificateChain[0]
+	switch {
+	case now.After(leaf.NotAfter):
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "certificate expired on " + leaf.NotAfter.Format("2006-01-02")})
+	case now.Before(leaf.NotBefore):
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "certificate not valid before " + leaf.NotBefore.Format("2006-01-02")})
+	case leaf.NotAfter.Sub(now) < certificateExpiryWarning:
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueLow, Issue: "certificate expires on " + leaf.NotAfter.Format("2006-01-02")})
+	}
+	switch {
+	case leaf.SelfSigned:
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "self-signed certificate"})
+	case c.UntrustedReason != "":
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "untrusted certificate chain: " + c.UntrustedReason})
+	}
+	if c.HostnameMismatch {
+		issues = append(issues, models.TLSIssue{Severity: models.TLSIssueHigh, Issue: "certificate does not cover " + c.Host})
+	}
+	for i, cert := range c.CertificateChain {
+		switch {
+		case cert.KeyType == "RSA" && cert.KeyBits < 2048, cert.KeyType == "ECDSA" && cert.KeyBits < 256:
+			issues = append(issues, models.TLSIssue{Severity: models.TLSIssueMedium, Issue: fmt.Sprintf("weak %s key (%d bits) in %s", cert.KeyType, cert.KeyBits, chainPosition(i))})
+		}
+		// Self-signed roots are trusted as they are, whatever their own signature.
+		if weakSignatures[cert.SignatureAlgorithm] && !(cert.SelfSigned && i > 0) {
+			issues = append(issues, models.TLSIssue{Severity: models.TLSIssueMedium, Issue: fmt.Sprintf("%s signed with %s", chainPosition(i), cert.SignatureAlgorithm)})
+		}
+	}
+	sortTLSIssues(issues)
+	return issues
+}
+
+// chainPosition names a certificate by its index in a chain.
+func chainPosition(i int) string {
+	if i == 0 {
+		return "leaf certificate"
+	}
+	return fmt.Sprintf("chain certificate %d", i+1)
+}
+
+var tlsSeverityRank = map[string]int{models.TLSIssueHigh: 0, models.TLSIssueMedium: 1, models.TLSIssueLow: 2}
+
+// sortTLSIssues orders issues by severity, highest first.
+func sortTLSIssues(issues []models.TLSIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		return tlsSeverityRank[issues[i].Severity] < tlsSeverityRank[issues[j].Severity]
+	})
+}
+
+// GetTLSReport aggregates, per host, the TLS connections a target's logged requests were sent over
+// through the proxy: the versions, cipher suites, SNI and ALPN protocols negotiated, the latest
+// certificate, and the weaknesses found. A host is weak when it has a high or medium issue. host
+// limits the report to one host and weakOnly to weak hosts.
+func GetTLSReport(targetID int64, host string, weakOnly bool) (models.TLSReport, error) {
+	report := models.TLSReport{TargetID: targetID, Hosts: []models.HostTLSState{}}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return report, err
+	}
+	connections, err := database.ListTargetTLSConnections(targetID, strings.TrimSpace(host))
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now()
+	var order []string
+	byHost := map[string][]models.TLSConnection{}
+	for _, c := range connections {
+		if _, ok := byHost[c.Host]; !ok {
+			order = append(order, c.Host)
+		}
+		byHost[c.Host] = append(byHost[c.Host], c)
+	}
+	for _, name := range order {
+		state := models.HostTLSState{Host: name, Connections: byHost[name]}
+		versions, ciphers, serverNames, alpn := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+		issueIndex := map[string]int{}
+		for i := range state.Connections {
+			c := &state.Connections[i]
+			state.Requests += c.Requests
+			versions[c.Version] += c.Requests
+			ciphers[c.CipherSuite] += c.Requests
+			if c.ServerName != "" {
+				serverNames[c.ServerName] += c.Requests
+			}
+			if c.ALPN != "" {
+				alpn[c.ALPN] += c.Requests
+			}
+			if c.LastSeenAt != nil && (state.LastSeenAt == nil || c.LastSeenAt.After(*state.LastSeenAt)) {
+				state.LastSeenAt = c.LastSeenAt
+			}
+			if state.Certificate == nil && len(c.CertificateChain) > 0 {
+				leaf := c.CertificateChain[0] // Connections are listed most recent first
+				state.Certificate = &leaf
+			}
+			c.Issues = tlsConnectionIssues(*c, now)
+			for _, issue := range c.Issues {
+				j, ok := issueIndex[issue.Issue]
+				if !ok {
+					j = len(state.Issues)
+					issueIndex[issue.Issue] = j
+					state.Issues = append(state.Issues, models.TLSIssue{Severity: issue.Severity, Issue: issue.Issue})
+				}
+				state.Issues[j].ConnectionIDs = append(state.Issues[j].ConnectionIDs, c.ID)
+				state.Weak = state.Weak || issue.Severity != models.TLSIssueLow
+			}
+		}
+		if weakOnly && !state.Weak {
+			continue
+		}
+		state.Versions = topCounted(versions, len(versions))
+		state.CipherSuites = topCounted(ciphers, len(ciphers))
+		state.ServerNames = topCounted(serverNames, len(serverNames))
+		state.ALPN = topCounted(alpn, len(alpn))
+		sortTLSIssues(state.Issues)
+		report.Hosts = append(report.Hosts, state)
+	}
+	sort.SliceStable(report.Hosts, func(i, j int) bool {
+		a, b := report.Hosts[i], report.Hosts[j]
+		if a.Weak != b.Weak {
+			return a.Weak
+		}
+		if len(a.Issues) != len(b.Issues) {
+			return len(a.Issues) > len(b.Issues)
+		}
+		return a.Requests > b.Requests
+	})
+	logger.Debug("GetTLSReport: Target %d: %d TLS connections on %d hosts", targetID, len(connections), len(report.Hosts))
+	return report, nil
+}
+
+// GetTLSConnection returns the TLS parameters of a stored connection with its weaknesses.
+func GetTLSConnection(id int64) (models.TLSConnection, error) {
+	c, err := database.GetTLSConnection(id)
+	if err != nil {
+		return c, err
+	}
+	c.Issues = tlsConnectionIssues(c, time.Now())
+	return c, nil
+}
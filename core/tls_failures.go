@@ -0,0 +1,163 @@
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	"github.com/elazarl/goproxy"
+)
+
+// pinnedMinFailures is how many failed handshakes, without any intercepted request, mark a host as
+// likely pinned.
+const pinnedMinFailures = 3
+
+// failingHosts holds the hosts with recorded handshake failures, so successful connections are
+// only written to the database for them.
+var (
+	failingHosts     sync.Map // map[string]bool
+	failingHostsOnce sync.Once
+)
+
+// mitmHandshake is set as the UserData of a CONNECT context that is MITMed. goproxy copies it to
+// the contexts of the requests read from the connection, which mark the handshake as successful
+// and take the proxy listener the connection came through from it.
+type mitmHandshake struct {
+	host      string
+	succeeded atomic.Bool
+	listener  *boundListener // nil for the main listener
+}
+
+// mitmTLSConfig signs the certificate for a MITMed host and starts tracking its handshake.
+func mitmTLSConfig(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = host
+	}
+	ctx.UserData = &mitmHandshake{host: strings.ToLower(hostname), listener: requestProxyListener(ctx.Req, nil)}
+	return goproxy.TLSConfigFromCA(&goproxy.GoproxyCa)(host, ctx)
+}
+
+// markMITMHandshakeSucceeded records that a request arrived over a MITMed connection.
+func markMITMHandshakeSucceeded(ctx *goproxy.ProxyCtx) {
+	hs, ok := ctx.UserData.(*mitmHandshake)
+	if !ok || hs.succeeded.Swap(true) {
+		return
+	}
+	loadFailingHosts()
+	if _, failing := failingHosts.Load(hs.host); !failing {
+		return
+	}
+	if err := database.RecordTLSHandshakeSuccess(hs.host); err != nil {
+		logger.ProxyError("%v", err)
+	}
+}
+
+func loadFailingHosts() {
+	failingHostsOnce.Do(func() {
+		failures, err := database.ListTLSHandshakeFailures()
+		if err != nil {
+			logger.ProxyError("Loading TLS handshake failures: %v", err)
+			return
+		}
+		for _, f := range failures {
+			failingHosts.Store(f.Host, true)
+		}
+	})
+}
+
+// recordMITMHandshakeFailure stores a client's failed handshake with the proxy certificate.
+func recordMITMHandshakeFailure(hostPort string, handshakeErr error) {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	host = strings.ToLower(host)
+	if host == "" {
+		return
+	}
+	rejected := isCertificateRejection(handshakeErr)
+	loadFailingHosts()
+	failingHosts.Store(host, true)
+	if err := database.RecordTLSHandshakeFailure(host, handshakeErr.Error(), rejected); err != nil {
+		logger.ProxyError("%v", err)
+		return
+	}
+	logger.ProxyDebug("Client TLS handshake for %s failed: %v", host, handshakeErr)
+}
+
+// isCertificateRejection reports whether a handshake error is a certificate alert sent by the
+// client, the usual reaction of a pinning app to the proxy certificate.
+func isCertificateRejection(err error) bool {
+	var alert tls.AlertError
+	if errors.As(err, &alert) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range []string{"bad certificate", "unknown certificate authority", "certificate unknown", "unsupported certificate", "certificate revoked", "certificate expired", "access denied"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyLogger receives goproxy's log output. Everything is discarded except the failed client
+// handshakes, which goproxy only reports through its log.
+type proxyLogger struct{}
+
+func (proxyLogger) Printf(format string, v ...any) {
+	// Logged as "[%03d] WARN: Cannot handshake client %v %v" with the session, CONNECT host and error.
+	if !strings.Contains(format, "Cannot handshake client") || len(v) < 3 {
+		return
+	}
+	hostPort := fmt.Sprint(v[1])
+	handshakeErr, ok := v[2].(error)
+	if !ok {
+		handshakeErr = fmt.Errorf("%v", v[2])
+	}
+	recordMITMHandshakeFailure(hostPort, handshakeErr)
+}
+
+// ListTLSHandshakeFailures returns the hosts whose clients failed the TLS handshake with the
+// proxy, flagging the ones that likely pin their certificates.
+func ListTLSHandshakeFailures(likelyPinnedOnly bool) ([]models.TLSHandshakeFailure, error) {
+	failures, err := database.ListTLSHandshakeFailures()
+	if err != nil {
+		return nil, err
+	}
+	filtered := []models.TLSHandshakeFailure{}
+	for _, f := range failures {
+		switch {
+		case f.Failures >= pinnedMinFailures && f.Successes == 0:
+			f.LikelyPinned = true
+			f.Hint = "Clients never completed a handshake with the proxy certificate for this host: the app likely pins its certificate. Bypass pinning on the device (e.g. Frida/objection) to intercept it."
+		case f.Successes > 0 && f.CertificateRejections > 0:
+			f.Hint = "Some clients accept the proxy certificate for this host and others reject it: only some apps (or SDKs within an app) pin it, or the CA is not trusted everywhere."
+		case f.CertificateRejections == 0:
+			f.Hint = "Clients closed the connection without a certificate alert: check that the proxy CA is installed and trusted, or the app may pin silently."
+		}
+		if likelyPinnedOnly && !f.LikelyPinned {
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered, nil
+}
+
+// ResetTLSHandshakeFailures forgets the handshake failures of a host, e.g. after bypassing pinning.
+func ResetTLSHandshakeFailures(host string) error {
+	host = strings.ToLower(host)
+	if err := database.DeleteTLSHandshakeFailure(host); err != nil {
+		return err
+	}
+	failingHosts.Delete(host)
+	return nil
+}
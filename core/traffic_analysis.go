@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/models"
+)
+
+// StartTrafficAnalysis starts a job running analyzers over every stored response of a target that
+// matches the request. Analyzers default to analysis.analyzers from the config; analysis.workers
+// log entries are analyzed concurrently.
+func StartTrafficAnalysis(targetID int64, req models.TrafficAnalysisRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	names := append([]string(nil), req.Analyzers...)
+	if len(names) == 0 {
+		names = append(names, config.AppConfig.Analysis.Analyzers...)
+	}
+	if len(names) == 0 {
+		names = AnalyzerNames()
+	}
+	for i, name := range names {
+		names[i] = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := GetAnalyzer(names[i]); !ok {
+			return models.Job{}, fmt.Errorf("invalid analyzer '%s' (available: %s)", name, strings.Join(AnalyzerNames(), ", "))
+		}
+	}
+	latest, err := LatestJob(models.JobTypeTrafficAnalysis, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a traffic analysis job is already running for target %d (job %d)", targetID, latest.ID)
+	}
+	contentType := strings.TrimSpace(req.ContentType)
+	logIDs, err := database.ListTrafficLogIDsForAnalysis(targetID, contentType)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(logIDs) == 0 {
+		if contentType != "" {
+			return models.Job{}, fmt.Errorf("no stored responses of target %d with a Content-Type containing '%s'", targetID, contentType)
+		}
+		return models.Job{}, fmt.Errorf("no stored responses for target %d", targetID)
+	}
+
+	workers := config.AppConfig.Analysis.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	message := fmt.Sprintf("Analyzing %d stored responses with %s...", len(logIDs), strings.Join(names, ", "))
+	return StartJob(models.JobTypeTrafficAnalysis, &targetID, message, func(ctx context.Context, job *JobHandle) error {
+		job.SetTotal(int64(len(logIDs)))
+		var mu sync.Mutex
+		findings := 0
+		idCh := make(chan int64)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for logID := range idCh {
+					results, err := AnalyzeTrafficLog(logID, names)
+					if err != nil {
+						job.RecordError(fmt.Errorf("log %d: %w", logID, err))
+						job.AddProgress(1, 0)
+						continue
+					}
+					mu.Lock()
+					for _, f := range results {
+						findings += len(f)
+					}
+					mu.Unlock()
+					job.AddProgress(1, 1)
+				}
+			}()
+		}
+	feed:
+		for _, logID := range logIDs {
+			select {
+			case <-ctx.Done():
+				break feed
+			case idCh <- logID:
+			}
+		}
+		close(idCh)
+		wg.Wait()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		snap := job.Snapshot()
+		job.SetMessage("Analyzed %d of %d stored responses: %d findings (%d errors).", snap.ItemsSucceeded, len(logIDs), findings, snap.ErrorCount)
+		return nil
+	})
+}
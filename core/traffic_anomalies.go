@@ -0,0 +1,184 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// Floors of the medians anomalies are measured against, so endpoints answering with empty bodies
+// or in a few milliseconds don't turn every ordinary response into a huge factor.
+const (
+	anomalyMinMedianSize     = 64
+	anomalyMinMedianDuration = 10
+)
+
+// endpointSamples collects the responses of one endpoint.
+type endpointSamples struct {
+	baseline  models.EndpointBaseline
+	logs      []models.HTTPTrafficLog
+	sizes     []int64
+	durations []int64
+}
+
+// endpointPath returns the path of a URL with numeric and UUID segments replaced by {id} and
+// {uuid}, so requests for different objects count as the same endpoint.
+func endpointPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		switch idKind(segment) {
+		case models.IDKindNumeric:
+			segments[i] = "{id}"
+		case models.IDKindUUID:
+			segments[i] = "{uuid}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// median returns the median of values, sorting them.
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// GetTrafficAnomalies builds a baseline of each endpoint from a target's latest captured
+// responses (anomalies.max_logs): the median response size and latency of endpoints with at least
+// min_samples responses. Responses more than size_factor times the median size, or latency_factor
+// times the median latency, are flagged when they also exceed the median by min_size_delta bytes
+// or min_latency_ms milliseconds. Such outliers often are verbose errors, debug output or the
+// side effects of an injection. Zero filter thresholds use the anomalies config.
+func GetTrafficAnomalies(filters models.TrafficAnomalyFilters) (models.TrafficAnomalyReport, error) {
+	conf := config.AppConfig.Anomalies
+	if filters.SizeFactor <= 0 {
+		filters.SizeFactor = conf.SizeFactor
+	}
+	if filters.LatencyFactor <= 0 {
+		filters.LatencyFactor = conf.LatencyFactor
+	}
+	if filters.MinSamples <= 0 {
+		filters.MinSamples = conf.MinSamples
+	}
+	report := models.TrafficAnomalyReport{
+		TargetID:      filters.TargetID,
+		SizeFactor:    filters.SizeFactor,
+		LatencyFactor: filters.LatencyFactor,
+		MinSamples:    filters.MinSamples,
+		Anomalies:     []models.TrafficAnomaly{},
+	}
+	switch filters.Kind {
+	case "", models.AnomalyKindSize, models.AnomalyKindLatency:
+	default:
+		return report, fmt.Errorf("invalid kind '%s' (size or latency)", filters.Kind)
+	}
+	if filters.SizeFactor <= 1 || filters.LatencyFactor <= 1 {
+		return report, fmt.Errorf("invalid factors: size %.2f and latency %.2f must be above 1", filters.SizeFactor, filters.LatencyFactor)
+	}
+	if _, err := database.GetTargetByID(filters.TargetID); err != nil {
+		return report, err
+	}
+	host := strings.ToLower(strings.TrimSpace(filters.Host))
+
+	endpoints := map[string]*endpointSamples{}
+	var order []*endpointSamples
+	err := database.ForEachTargetResponseMetrics(filters.TargetID, conf.MaxLogs, func(e models.HTTPTrafficLog) {
+		u, err := url.Parse(e.RequestURL.String)
+		if err != nil || u.Hostname() == "" {
+			return
+		}
+		if host != "" && strings.ToLower(u.Hostname()) != host {
+			return
+		}
+		report.ResponsesScanned++
+		method := strings.ToUpper(e.RequestMethod.String)
+		endpoint := strings.ToLower(u.Scheme+"://"+u.Host) + endpointPath(u.Path)
+		key := method + " " + endpoint
+		s := endpoints[key]
+		if s == nil {
+			s = &endpointSamples{baseline: models.EndpointBaseline{Method: method, Endpoint: endpoint}}
+			endpoints[key] = s
+			order = append(order, s)
+		}
+		s.logs = append(s.logs, e)
+		s.sizes = append(s.sizes, e.ResponseBodySize)
+		if e.DurationMs >= 0 {
+			s.durations = append(s.durations, e.DurationMs)
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, s := range order {
+		if len(s.logs) < filters.MinSamples {
+			continue
+		}
+		report.Endpoints++
+		s.baseline.Samples = len(s.logs)
+		s.baseline.MedianSize = median(s.sizes)
+		s.baseline.MedianDurationMs = median(s.durations)
+		checkDuration := len(s.durations) >= filters.MinSamples
+		for _, e := range s.logs {
+			if filters.Kind != models.AnomalyKindLatency {
+				if a, ok := anomalyOf(e, models.AnomalyKindSize, e.ResponseBodySize, s.baseline.MedianSize, anomalyMinMedianSize,
+					filters.SizeFactor, conf.MinSizeDelta); ok {
+					a.Baseline = s.baseline
+					report.Anomalies = append(report.Anomalies, a)
+				}
+			}
+			if filters.Kind != models.AnomalyKindSize && checkDuration && e.DurationMs >= 0 {
+				if a, ok := anomalyOf(e, models.AnomalyKindLatency, e.DurationMs, s.baseline.MedianDurationMs, anomalyMinMedianDuration,
+					filters.LatencyFactor, conf.MinLatencyMs); ok {
+					a.Baseline = s.baseline
+					report.Anomalies = append(report.Anomalies, a)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(report.Anomalies, func(i, j int) bool {
+		a, b := report.Anomalies[i], report.Anomalies[j]
+		if a.Factor != b.Factor {
+			return a.Factor > b.Factor
+		}
+		return a.LogID > b.LogID
+	})
+	report.Total = len(report.Anomalies)
+	if filters.Limit > 0 && len(report.Anomalies) > filters.Limit {
+		report.Anomalies = report.Anomalies[:filters.Limit]
+	}
+	logger.Debug("GetTrafficAnomalies: Target %d: %d responses, %d endpoints with a baseline, %d anomalies",
+		filters.TargetID, report.ResponsesScanned, report.Endpoints, report.Total)
+	return report, nil
+}
+
+// anomalyOf flags value when it is at least factor times the median (floored at minMedian) and
+// exceeds the median by at least minDelta.
+func anomalyOf(e models.HTTPTrafficLog, kind string, value, median, minMedian int64, factor float64, minDelta int64) (models.TrafficAnomaly, bool) {
+	ratio := float64(value) / float64(max(median, minMedian))
+	if ratio < factor || value-median < minDelta {
+		return models.TrafficAnomaly{}, false
+	}
+	return models.TrafficAnomaly{
+		LogID:      e.ID,
+		Timestamp:  e.Timestamp,
+		Kind:       kind,
+		URL:        e.RequestURL.String,
+		StatusCode: e.ResponseStatusCode,
+		LogSource:  e.LogSource.String,
+		Value:      value,
+		Factor:     ratio,
+	}, true
+}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// SendToDestinations lists the destinations of SendTrafficTo.
+var SendToDestinations = []string{
+	models.SendToModifier, models.SendToReplay, models.SendToAuthMatrix, models.SendToFuzzer,
+	models.SendToScanner, models.SendToCurl, models.SendToRaw,
+}
+
+// SendTrafficTo sends a traffic log entry to another module in one call: a modifier task, a replay
+// batch, an auth matrix (a replay batch per session), an ffuf command, the response analyzers, or
+// a curl or raw export. The send is recorded for the entry with the user who made it; created
+// tasks and batch items point back to the entry through their source_log_id.
+func SendTrafficTo(logID int64, user string, req models.TrafficSendRequest) (models.TrafficSendResult, error) {
+	destination := strings.ToLower(strings.TrimSpace(req.Destination))
+	result := models.TrafficSendResult{TrafficSend: models.TrafficSend{LogID: logID, Destination: destination, User: user}}
+	if !slices.Contains(SendToDestinations, destination) {
+		return result, models.InvalidRequestError("invalid destination '%s' (available: %s)", req.Destination, strings.Join(SendToDestinations, ", "))
+	}
+	entry, err := database.GetHTTPTrafficLogEntryByID(logID)
+	if err != nil {
+		return result, models.NotFoundError("traffic log entry %d not found", logID)
+	}
+
+	switch destination {
+	case models.SendToModifier:
+		err = sendToModifier(&result, req)
+	case models.SendToReplay:
+		err = sendToReplay(&result, entry, req.Name, []int64{req.SessionID})
+	case models.SendToAuthMatrix:
+		err = sendToAuthMatrix(&result, entry, req)
+	case models.SendToFuzzer:
+		err = sendToFuzzer(&result, entry, req)
+	case models.SendToScanner:
+		err = sendToScanner(&result, req)
+	case models.SendToCurl, models.SendToRaw:
+		err = sendToExport(&result, entry, destination)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	send, err := database.RecordTrafficSend(result.TrafficSend)
+	if err != nil {
+		// What was created stays; only the record of the send is missing.
+		logger.Error("SendTrafficTo: %v", err)
+	} else {
+		result.TrafficSend = send
+	}
+	logger.Info("Traffic: %s sent log %d to %s", user, logID, destination)
+	return result, nil
+}
+
+// GetTrafficSends lists where a traffic log entry was sent, newest first.
+func GetTrafficSends(logID int64) ([]models.TrafficSend, error) {
+	if _, err := database.GetHTTPTrafficLogEntryByID(logID); err != nil {
+		return nil, models.NotFoundError("traffic log entry %d not found", logID)
+	}
+	return database.GetTrafficSends(logID)
+}
+
+func sendToModifier(result *models.TrafficSendResult, req models.TrafficSendRequest) error {
+	if req.CollectionID != 0 {
+		if _, err := database.GetModifierCollectionByID(req.CollectionID); err != nil {
+			return models.NotFoundError("modifier collection %d not found", req.CollectionID)
+		}
+	}
+	task, err := database.CreateModifierTaskFromSource(models.AddModifierTaskRequest{HTTPTrafficLogID: result.LogID})
+	if err != nil {
+		return fmt.Errorf("creating modifier task from log %d: %w", result.LogID, err)
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if task, err = database.UpdateModifierTaskName(task.ID, name); err != nil {
+			return err
+		}
+	}
+	if req.CollectionID != 0 {
+		if err := database.SetModifierTaskCollection(task.ID, req.CollectionID); err != nil {
+			return err
+		}
+		if task, err = database.GetModifierTaskByID(task.ID); err != nil {
+			return err
+		}
+	}
+	result.Task = task
+	result.ArtifactType = models.SendArtifactModifierTask
+	result.ArtifactIDs = []int64{task.ID}
+	return nil
+}
+
+// sendToReplay replays the entry once per session; a session ID of 0 replays it as captured.
+func sendToReplay(result *models.TrafficSendResult, entry models.HTTPTrafficLog, name string, sessionIDs []int64) error {
+	var targetID int64
+	if entry.TargetID != nil {
+		targetID = *entry.TargetID
+	}
+	sessionNames := map[int64]string{}
+	for _, id := range sessionIDs {
+		if id == 0 {
+			continue
+		}
+		s, err := database.GetReplaySessionByID(id)
+		if err != nil {
+			return models.NotFoundError("replay session %d not found", id)
+		}
+		sessionNames[id] = s.Name
+	}
+	result.ArtifactType = models.SendArtifactReplayBatch
+	for _, sessionID := range sessionIDs {
+		batchName := strings.TrimSpace(name)
+		if batchName == "" {
+			batchName = fmt.Sprintf("Log %d", entry.ID)
+		}
+		if sessionID != 0 && len(sessionIDs) > 1 {
+			batchName += " as " + sessionNames[sessionID]
+		}
+		batch, err := database.CreateReplayBatch(models.CreateReplayBatchRequest{
+			TargetID:  targetID,
+			SessionID: sessionID,
+			Name:      batchName,
+			LogIDs:    []int64{entry.ID},
+		})
+		if err != nil {
+			return fmt.Errorf("creating replay batch of log %d: %w", entry.ID, err)
+		}
+		if err := StartReplayBatch(batch.ID); err != nil {
+			return fmt.Errorf("starting replay batch %d: %w", batch.ID, err)
+		}
+		result.Batches = append(result.Batches, batch)
+		result.ArtifactIDs = append(result.ArtifactIDs, batch.ID)
+	}
+	return nil
+}
+
+// sendToAuthMatrix replays the entry with each replay session, the target's by default, so the
+// answers each account gets can be compared.
+func sendToAuthMatrix(result *models.TrafficSendResult, entry models.HTTPTrafficLog, req models.TrafficSendRequest) error {
+	sessionIDs := req.SessionIDs
+	if len(sessionIDs) == 0 {
+		if entry.TargetID == nil {
+			return models.InvalidRequestError("log %d has no target; give session_ids", entry.ID)
+		}
+		sessions, err := database.GetReplaySessions(*entry.TargetID)
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			sessionIDs = append(sessionIDs, s.ID)
+		}
+	}
+	if len(sessionIDs) == 0 {
+		return models.InvalidRequestError("no replay sessions to compare; create one per account with POST /replay/sessions")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = fmt.Sprintf("Auth matrix of log %d", entry.ID)
+	}
+	return sendToReplay(result, entry, name, sessionIDs)
+}
+
+// sendToFuzzer builds an ffuf command with FUZZ as the value of the selected query, form and
+// top-level JSON parameters, all of them by default. A request without parameters gets FUZZ as
+// its last path segment, for content discovery.
+func sendToFuzzer(result *models.TrafficSendResult, entry models.HTTPTrafficLog, req models.TrafficSendRequest) error {
+	wordlist := "{{target_wordlist}}"
+	if req.WordlistID != 0 {
+		w, err := GetWordlist(req.WordlistID)
+		if err != nil {
+			return err
+		}
+		wordlist = w.Path
+	}
+	rawURL, headers, body := exportedRequest(entry)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return models.InvalidRequestError("invalid URL of log %d: %v", entry.ID, err)
+	}
+	selected := func(name string) bool { return len(req.Params) == 0 || slices.Contains(req.Params, name) }
+	found := map[string]bool{}
+
+	var fuzzed bool
+	u.RawQuery, fuzzed = fuzzFormParams(u.RawQuery, selected, found)
+	contentType := strings.ToLower(headers.Get("Content-Type"))
+	switch {
+	case strings.Contains(contentType, "application/x-www-form-urlencoded"):
+		var f bool
+		var fuzzedBody string
+		fuzzedBody, f = fuzzFormParams(string(body), selected, found)
+		body, fuzzed = []byte(fuzzedBody), fuzzed || f
+	case strings.Contains(contentType, "json"):
+		var doc map[string]interface{}
+		if json.Unmarshal(body, &doc) == nil {
+			changed := false
+			for name, v := range doc {
+				switch v.(type) {
+				case map[string]interface{}, []interface{}:
+					continue
+				}
+				if selected(name) {
+					doc[name], found[name], changed = "FUZZ", true, true
+				}
+			}
+			if changed {
+				if body, err = json.Marshal(doc); err != nil {
+					return err
+				}
+				fuzzed = true
+			}
+		}
+	}
+	for _, name := range req.Params {
+		if !found[name] {
+			return models.InvalidRequestError("parameter '%s' is not in the query, form or JSON body of log %d", name, entry.ID)
+		}
+	}
+	if !fuzzed {
+		dir := u.Path
+		if !strings.HasSuffix(dir, "/") {
+			dir = dir[:strings.LastIndex(dir, "/")+1]
+		}
+		if dir == "" {
+			dir = "/"
+		}
+		u.Path, u.RawPath = dir+"FUZZ", ""
+	}
+	headers.Del("Content-Length")
+	u.Fragment, u.RawFragment = "", ""
+	args := ffufRequestArgs(entry.RequestMethod.String, u.String(), headers, body, wordlist)
+	result.Command = ffufCommandLine(args)
+	result.ArtifactType = models.SendArtifactCommand
+	return nil
+}
+
+// fuzzFormParams sets the selected parameters of a query string or form body to FUZZ, keeping the
+// order and encoding of the others.
+func fuzzFormParams(encoded string, selected func(string) bool, found map[string]bool) (string, bool) {
+	if encoded == "" {
+		return encoded, false
+	}
+	fuzzed := false
+	pairs := strings.Split(encoded, "&")
+	for i, pair := range pairs {
+		rawName, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil || name == "" || !selected(name) {
+			continue
+		}
+		pairs[i] = rawName + "=FUZZ"
+		found[name], fuzzed = true, true
+	}
+	return strings.Join(pairs, "&"), fuzzed
+}
+
+func sendToScanner(result *models.TrafficSendResult, req models.TrafficSendRequest) error {
+	names := req.Analyzers
+	if len(names) == 0 {
+		names = config.AppConfig.Analysis.Analyzers
+	}
+	findings, err := AnalyzeTrafficLog(result.LogID, names)
+	if err != nil {
+		return models.InvalidRequestError("%v", err)
+	}
+	result.Findings = map[string]int{}
+	for analyzer, f := range findings {
+		result.Findings[analyzer] = len(f)
+	}
+	result.ArtifactType = models.SendArtifactAnalysisResult
+	return nil
+}
+
+func sendToExport(result *models.TrafficSendResult, entry models.HTTPTrafficLog, format string) error {
+	rawURL, headers, body := exportedRequest(entry)
+	if format == models.SendToCurl {
+		result.Command = BuildCurlCommand(entry.RequestMethod.String, rawURL, headers, body)
+	} else {
+		raw, err := BuildRawHTTPRequest(entry.RequestMethod.String, rawURL, headers, body)
+		if err != nil {
+			return models.InvalidRequestError("%v", err)
+		}
+		result.Command = raw
+	}
+	result.ArtifactType = models.SendArtifactCommand
+	return nil
+}
+
+// exportedRequest returns the request of a log entry as it may leave the toolkit: with the
+// redaction rules of its target applied.
+func exportedRequest(entry models.HTTPTrafficLog) (string, http.Header, []byte) {
+	rawURL := entry.RequestURL.String
+	if entry.RequestFullURLWithFragment.Valid && entry.RequestFullURLWithFragment.String != "" {
+		rawURL = entry.RequestFullURLWithFragment.String
+	}
+	return RedactRequestForExport(entry.TargetID, rawURL, HeadersFromJSON(entry.RequestHeaders.String), bytes.Clone(entry.RequestBody))
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"time"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const (
+	trafficTailInterval = time.Second // How often a tail looks for new log entries
+	trafficTailBatch    = 200         // Most log entries a tail reads at a time
+)
+
+// TailTrafficLogs follows the traffic log like tail -f: it calls emit with the log entries
+// matching conditions (on http_traffic_log alone) logged after afterID, oldest first, looking
+// every second until ctx is done or emit returns an error. emit is also called without entries
+// when none were logged, so streams can send keep-alives. Entries are read from the database, so
+// the proxy of another toolkit process is followed too. With full, entries have their headers and
+// bodies; otherwise only what the traffic list shows.
+func TailTrafficLogs(ctx context.Context, conditions database.Conditions, afterID int64, full bool, emit func([]models.HTTPTrafficLog) error) error {
+	for ctx.Err() == nil {
+		entries, err := database.GetTrafficLogsAfter(conditions, afterID, trafficTailBatch)
+		if err != nil {
+			return err
+		}
+		for i, e := range entries {
+			afterID = e.ID
+			if full {
+				entry, err := database.GetHTTPTrafficLogEntryByID(e.ID)
+				if err != nil {
+					// Deleted since; the summary is all there is.
+					logger.Debug("TailTrafficLogs: Could not load log %d: %v", e.ID, err)
+				} else {
+					entries[i] = entry
+				}
+			}
+			entries[i].Origin = models.TrafficOrigin(entries[i].LogSource.String)
+		}
+		if err := emit(entries); err != nil {
+			return err
+		}
+		if len(entries) == trafficTailBatch {
+			continue // More are waiting
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(trafficTailInterval):
+		}
+	}
+	return nil
+}
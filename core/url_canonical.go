@@ -0,0 +1,184 @@
+package core
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// CanonicalizeURL returns the form URLs are deduplicated by: scheme and host lower-cased, default
+// ports, user info and the fragment dropped, the url_canonical.strip_params parameters (session
+// IDs, utm_* and other tracking parameters) removed from the query and from ;param path segments,
+// and the remaining query parameters sorted. A relative URL is first resolved against base, the
+// URL of the response it was found in, when one is given. Unparsable URLs are returned trimmed.
+func CanonicalizeURL(raw, base string) string {
+	return canonicalizeURL(raw, base, config.AppConfig.URLs.StripParams)
+}
+
+func canonicalizeURL(raw, base string, strip []string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if !u.IsAbs() && base != "" {
+		if b, err := url.Parse(base); err == nil && b.IsAbs() {
+			u = b.ResolveReference(u)
+		}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.User = nil
+	u.Fragment, u.RawFragment = "", ""
+	if u.Host != "" {
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		port := u.Port()
+		if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			port = ""
+		}
+		if port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			u.Host = "[" + host + "]"
+		} else {
+			u.Host = host
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+
+	if escaped := u.EscapedPath(); strings.Contains(escaped, ";") {
+		segments := strings.Split(escaped, "/")
+		for i, segment := range segments {
+			parts := strings.Split(segment, ";")
+			kept := parts[:1]
+			for _, param := range parts[1:] {
+				name, _, _ := strings.Cut(param, "=")
+				if !strippedURLParam(name, strip) {
+					kept = append(kept, param)
+				}
+			}
+			segments[i] = strings.Join(kept, ";")
+		}
+		escaped = strings.Join(segments, "/")
+		if p, err := url.PathUnescape(escaped); err == nil {
+			u.Path, u.RawPath = p, escaped
+		}
+	}
+
+	type queryParam struct{ name, raw string }
+	var params []queryParam
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawName, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		if !strippedURLParam(name, strip) {
+			params = append(params, queryParam{name: name, raw: pair})
+		}
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].raw < params[j].raw
+	})
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.raw
+	}
+	u.RawQuery = strings.Join(pairs, "&")
+	u.ForceQuery = false
+	return u.String()
+}
+
+// strippedURLParam reports whether a parameter is in the strip list; entries ending in * match by
+// prefix.
+func strippedURLParam(name string, strip []string) bool {
+	name = strings.ToLower(name)
+	for _, s := range strip {
+		s = strings.ToLower(s)
+		if prefix, ok := strings.CutSuffix(s, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == s {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalURLEntries canonicalizes URLs found in the response to base and checks the absolute
+// ones against a target's scope rules.
+func canonicalURLEntries(urls []string, base string, rules []models.ScopeRule) []models.CanonicalURLEntry {
+	entries := make([]models.CanonicalURLEntry, 0, len(urls))
+	for _, raw := range urls {
+		entry := models.CanonicalURLEntry{URL: raw, CanonicalURL: CanonicalizeURL(raw, base)}
+		if u, err := url.Parse(entry.CanonicalURL); err == nil && u.IsAbs() && u.Host != "" {
+			entry.IsInScope = isRequestEffectivelyInScope(u, rules)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// RecordTrafficURLs adds endpoints seen in a target's proxied traffic to its canonical URLs. It
+// returns how many were new.
+func RecordTrafficURLs(targetID int64, urls []string) (int, error) {
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return 0, err
+	}
+	return database.RecordCanonicalURLs(targetID, models.CanonicalSourceTraffic, canonicalURLEntries(urls, "", rules))
+}
+
+// RebuildCanonicalURLs recomputes the canonical form of every discovered URL and traffic endpoint
+// of a target, after url_canonical.strip_params or the scope rules changed or for URLs stored
+// before canonicalization existed.
+func RebuildCanonicalURLs(targetID int64) (models.CanonicalURLRebuildResult, error) {
+	result := models.CanonicalURLRebuildResult{TargetID: targetID}
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return result, err
+	}
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return result, err
+	}
+	discovered, err := database.GetDiscoveredURLsForTarget(targetID)
+	if err != nil {
+		return result, err
+	}
+	trafficURLs, err := database.GetParameterizedURLExamples(targetID)
+	if err != nil {
+		return result, err
+	}
+
+	updates := make(map[int64]string, len(discovered))
+	bySource := map[string][]models.CanonicalURLEntry{}
+	for _, du := range discovered {
+		entry := canonicalURLEntries([]string{du.URL}, du.SourceURL, rules)[0]
+		if entry.CanonicalURL != du.CanonicalURL {
+			updates[du.ID] = entry.CanonicalURL
+		}
+		bySource[du.SourceType] = append(bySource[du.SourceType], entry)
+	}
+	bySource[models.CanonicalSourceTraffic] = append(bySource[models.CanonicalSourceTraffic], canonicalURLEntries(trafficURLs, "", rules)...)
+	result.DiscoveredURLs = len(discovered)
+	result.TrafficURLs = len(trafficURLs)
+	if result.UniqueURLs, err = database.ReplaceCanonicalURLs(targetID, updates, bySource); err != nil {
+		return result, err
+	}
+	logger.Info("RebuildCanonicalURLs: Target %d: %d discovered URLs (%d changed) and %d traffic endpoints give %d unique URLs",
+		targetID, result.DiscoveredURLs, len(updates), result.TrafficURLs, result.UniqueURLs)
+	return result, nil
+}
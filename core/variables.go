@@ -0,0 +1,42 @@
+package core
+
+import "regexp"
+
+var (
+	// variablePlaceholderRegex matches {{name}} placeholders, the syntax Postman uses as well.
+	variablePlaceholderRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+	variableNameRegex        = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// IsValidVariableName reports whether name can be referenced as a {{name}} placeholder.
+func IsValidVariableName(name string) bool {
+	return variableNameRegex.MatchString(name)
+}
+
+// SubstituteVariables replaces {{name}} placeholders with their values. Placeholders without a
+// value are left as they are and returned, sorted and without duplicates, so callers can warn
+// about them.
+func SubstituteVariables(s string, values map[string]string) (string, []string) {
+	var missing []string
+	out := variablePlaceholderRegex.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := variablePlaceholderRegex.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return placeholder
+	})
+	return out, processSlice(missing)
+}
+
+// SubstituteVariablesInPlace runs SubstituteVariables over each field and returns the
+// placeholders without a value across all of them.
+func SubstituteVariablesInPlace(values map[string]string, fields ...*string) []string {
+	var missing []string
+	for _, field := range fields {
+		var m []string
+		*field, m = SubstituteVariables(*field, values)
+		missing = append(missing, m...)
+	}
+	return processSlice(missing)
+}
@@ -0,0 +1,419 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// maxVhostBaselineBases bounds the bases whose random names are part of an endpoint's baseline.
+const maxVhostBaselineBases = 5
+
+// StartVhostDiscovery starts a job sending candidate Host headers (and SNI names) to a target's
+// in-scope IP assets. Candidates are the target's in-scope domains and word.base names generated
+// like permutation candidates. Each IP and port is first asked for the IP itself and random names,
+// which gives what it serves for unknown hosts; a candidate answered with a different page is a
+// virtual host. Hits are logged to the traffic log, added as domains with source 'vhost' and
+// linked to the IP asset.
+func StartVhostDiscovery(targetID int64, req models.VhostDiscoveryRequest) (models.Job, error) {
+	if _, err := database.GetTargetByID(targetID); err != nil {
+		return models.Job{}, err
+	}
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	assets, err := vhostIPAssets(targetID, req.IPAssetIDs)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(assets) == 0 {
+		return models.Job{}, fmt.Errorf("no IP assets to probe for target %d (resolve its domains into IP assets or pass ip_asset_ids)", targetID)
+	}
+	conf := config.AppConfig.Vhost
+	ports := req.Ports
+	if len(ports) == 0 {
+		ports = conf.Ports
+	}
+	if len(ports) == 0 {
+		ports = []int{443, 80}
+	}
+	for _, port := range ports {
+		if port <= 0 || port > 65535 {
+			return models.Job{}, fmt.Errorf("invalid port %d", port)
+		}
+	}
+	words, err := permutationWords(models.PermutationRequest{Words: req.Words, WordlistID: req.WordlistID, WordsOnly: req.WordsOnly})
+	if err != nil {
+		return models.Job{}, err
+	}
+	known, err := database.GetDomainNamesForTarget(targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	maxCandidates := conf.MaxCandidates
+	if maxCandidates <= 0 {
+		maxCandidates = 2000
+	}
+	if req.MaxCandidates > 0 && req.MaxCandidates < maxCandidates {
+		maxCandidates = req.MaxCandidates
+	}
+	candidates, bases := vhostCandidates(known, words, rules, maxCandidates)
+	if len(candidates) == 0 {
+		return models.Job{}, fmt.Errorf("no candidate hosts for target %d (add domains or an in-scope wildcard rule such as *.example.com)", targetID)
+	}
+
+	latest, err := LatestJob(models.JobTypeVhostDiscovery, targetID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if latest != nil && latest.Status == models.JobStatusRunning {
+		return *latest, fmt.Errorf("a vhost discovery is already running for target %d (job %d)", targetID, latest.ID)
+	}
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if len(bases) > maxVhostBaselineBases {
+		bases = bases[:maxVhostBaselineBases]
+	}
+	d := &vhostDiscoverer{targetID: targetID, timeout: timeout, workers: conf.Workers, bases: bases}
+	message := fmt.Sprintf("Trying %d hosts on %d IP(s) (ports %s)...", len(candidates), len(assets), joinInts(ports))
+	return StartJob(models.JobTypeVhostDiscovery, &targetID, message, func(ctx context.Context, job *JobHandle) error {
+		return d.run(ctx, job, assets, ports, candidates)
+	})
+}
+
+// vhostIPAssets returns the requested IP assets of the target, or all its in-scope single
+// addresses when none are requested.
+func vhostIPAssets(targetID int64, ids []int64) ([]models.IPAsset, error) {
+	if len(ids) == 0 {
+		all, err := database.ListAllIPAssetsForTarget(targetID)
+		if err != nil {
+			return nil, err
+		}
+		var assets []models.IPAsset
+		for _, a := range all {
+			if a.IsInScope && !a.IsCIDR {
+				assets = append(assets, a)
+			}
+		}
+		return assets, nil
+	}
+	var assets []models.IPAsset
+	for _, id := range ids {
+		a, err := database.GetIPAssetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if a.TargetID != targetID {
+			return nil, fmt.Errorf("IP asset with ID %d not found for target %d", id, targetID)
+		}
+		if a.IsCIDR {
+			return nil, fmt.Errorf("invalid IP asset %d: %s is a CIDR range", id, a.Address)
+		}
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+// vhostCandidates returns the target's in-scope domains followed by permutation candidates under
+// its in-scope wildcard bases (or the registrable domains of its domains without such rules), up
+// to max, and the bases.
+func vhostCandidates(known, words []string, rules []models.ScopeRule, max int) ([]string, []string) {
+	allow := func(name string) bool { return ctNameInScope(name, rules) }
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, name := range known {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name == "" || strings.Contains(name, "*") || seen[name] || !allow(name) || len(candidates) >= max {
+			continue
+		}
+		seen[name] = true
+		candidates = append(candidates, name)
+	}
+	bases := ctWildcardBases(rules)
+	if len(bases) == 0 {
+		for _, name := range candidates {
+			if base := corsBaseDomain(name); !containsString(bases, base) {
+				bases = append(bases, base)
+			}
+		}
+		sort.Strings(bases)
+	}
+	if len(candidates) < max && len(bases) > 0 {
+		generated, _, _ := generatePermutations(bases, known, words, max-len(candidates), allow)
+		candidates = append(candidates, generated...)
+	}
+	return candidates, bases
+}
+
+type vhostDiscoverer struct {
+	targetID int64
+	timeout  time.Duration
+	workers  int
+	bases    []string
+
+	mu    sync.Mutex
+	hits  int
+	added []string
+}
+
+// vhostEndpoint is a port of an IP asset with the responses it gives for unknown hosts.
+type vhostEndpoint struct {
+	asset    models.IPAsset
+	port     int
+	scheme   string
+	client   *http.Client
+	baseline []models.ResponseSignature
+}
+
+type vhostTask struct {
+	endpoint *vhostEndpoint
+	host     string
+}
+
+func (d *vhostDiscoverer) run(ctx context.Context, job *JobHandle, assets []models.IPAsset, ports []int, candidates []string) error {
+	job.SetTotal(int64(len(assets) * len(ports) * len(candidates)))
+	workers := d.workers
+	if workers <= 0 {
+		workers = 20
+	}
+
+	ch := make(chan vhostTask)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range ch {
+				hit, err := d.probe(ctx, t.endpoint, t.host)
+				if err != nil {
+					job.RecordError(err)
+				}
+				if hit {
+					job.AddProgress(1, 1)
+				} else {
+					job.AddProgress(1, 0)
+				}
+			}
+		}()
+	}
+	var endpoints []*vhostEndpoint
+feed:
+	for _, asset := range assets {
+		// Hosts already resolving to the IP are known virtual hosts of it.
+		linked := make(map[string]bool, len(asset.Domains))
+		for _, name := range asset.Domains {
+			linked[strings.ToLower(name)] = true
+		}
+		for _, port := range ports {
+			if ctx.Err() != nil {
+				break feed
+			}
+			ep, err := d.endpoint(ctx, asset, port)
+			if err != nil {
+				job.RecordError(err)
+				job.AddProgress(int64(len(candidates)), 0)
+				continue
+			}
+			endpoints = append(endpoints, ep)
+			for _, host := range candidates {
+				if linked[host] {
+					job.AddProgress(1, 0)
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					break feed
+				case ch <- vhostTask{endpoint: ep, host: host}:
+				}
+			}
+		}
+	}
+	close(ch)
+	wg.Wait()
+	for _, ep := range endpoints {
+		ep.client.CloseIdleConnections()
+	}
+
+	sort.Strings(d.added)
+	job.SetMessage("Tried %d hosts on %d IP(s): %d vhosts found, %d new domains added.", len(candidates), len(assets), d.hits, len(d.added))
+	if len(d.added) > 0 {
+		NotifyTargetEvent(models.NotificationNewSubdomain, d.targetID, fmt.Sprintf("%d new subdomains found by vhost discovery", len(d.added)),
+			SubdomainNotificationList(d.added, 10), d.added)
+	}
+	return ctx.Err()
+}
+
+// endpoint connects a client to one port of an IP, whatever host a request names, and records what
+// the port serves for the IP itself and for random names. It fails when none of them is answered.
+func (d *vhostDiscoverer) endpoint(ctx context.Context, asset models.IPAsset, port int) (*vhostEndpoint, error) {
+	addr := net.JoinHostPort(asset.Address, strconv.Itoa(port))
+	scheme := "http"
+	if port == 443 || port == 8443 {
+		scheme = "https"
+	}
+	dialer := &net.Dialer{Timeout: d.timeout}
+	ep := &vhostEndpoint{
+		asset:  asset,
+		port:   port,
+		scheme: scheme,
+		client: &http.Client{
+			Timeout: d.timeout,
+			Transport: NewRateLimitedTransport(NewClientProfileTransport(&http.Transport{
+				// The request's host goes into the Host header and SNI; the connection goes to the IP.
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, network, addr)
+				},
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}, d.targetID)),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	token := make([]byte, 6)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("generating baseline host: %w", err)
+	}
+	random := "vh-" + hex.EncodeToString(token)
+	hosts := []string{asset.Address, random + ".invalid"}
+	if strings.Contains(asset.Address, ":") {
+		hosts[0] = "[" + asset.Address + "]"
+	}
+	for _, base := range d.bases {
+		hosts = append(hosts, random+"."+base)
+	}
+	var lastErr error
+	for _, host := range hosts {
+		resp, body, err := ep.get(ctx, host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ep.baseline = append(ep.baseline, vhostSignature(host, ep.url(host), resp.StatusCode, resp.Header, body))
+	}
+	if len(ep.baseline) == 0 {
+		return nil, fmt.Errorf("no response from %s: %w", addr, lastErr)
+	}
+	return ep, nil
+}
+
+// url returns the root URL of host on the endpoint's port.
+func (ep *vhostEndpoint) url(host string) string {
+	if ep.scheme == "https" && ep.port == 443 || ep.scheme == "http" && ep.port == 80 {
+		return ep.scheme + "://" + host + "/"
+	}
+	return ep.scheme + "://" + host + ":" + strconv.Itoa(ep.port) + "/"
+}
+
+func (ep *vhostEndpoint) get(ctx context.Context, host string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.url(host), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := ep.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return resp, body, nil
+}
+
+// vhostSignature fingerprints the root page of a host with the host blanked out, so pages that
+// echo the Host header ("Welcome to x.example.com", redirects to https://x.example.com/) match.
+func vhostSignature(host, rawURL string, status int, headers http.Header, body []byte) models.ResponseSignature {
+	sig := SignResponse(models.SignatureSourceProbe, rawURL, status, headers, bytes.ReplaceAll(body, []byte(host), nil))
+	sig.Location = strings.ReplaceAll(sig.Location, host, "{host}")
+	return sig
+}
+
+// probe requests host on an endpoint and records it when the answer matches none of the
+// endpoint's baseline responses. Hosts the server refuses (TLS or connection errors, 400 and 421
+// answers) are misses, not errors.
+func (d *vhostDiscoverer) probe(ctx context.Context, ep *vhostEndpoint, host string) (bool, error) {
+	rawURL := ep.url(host)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+	start := time.Now()
+	resp, err := ep.client.Do(req)
+	if err != nil {
+		return false, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureBytes))
+	if err != nil {
+		return false, nil
+	}
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusMisdirectedRequest {
+		return false, nil
+	}
+	sig := vhostSignature(host, rawURL, resp.StatusCode, resp.Header, body)
+	for _, known := range ep.baseline {
+		if SignaturesMatch(known, sig, baselineMaxDistance()) {
+			return false, nil
+		}
+	}
+
+	entry := logProbe(d.targetID, req, resp, body, start, models.VhostDiscoveryLogSource)
+	d.mu.Lock()
+	d.hits++
+	d.mu.Unlock()
+	logger.Info("Vhost discovery: %s is served by %s:%d (%d, log entry %d)", host, ep.asset.Address, ep.port, resp.StatusCode, entry.ID)
+
+	domainID, err := database.CreateDomain(models.Domain{
+		TargetID:   d.targetID,
+		DomainName: host,
+		Source:     models.NullString(models.DomainSourceVhost),
+		IsInScope:  true,
+		Notes:      models.NullString(fmt.Sprintf("Virtual host of %s:%d (%d response, traffic log entry %d)", ep.asset.Address, ep.port, resp.StatusCode, entry.ID)),
+	})
+	switch {
+	case err == nil:
+		d.mu.Lock()
+		d.added = append(d.added, host)
+		d.mu.Unlock()
+	case strings.Contains(err.Error(), "already exists"):
+		if domainID, err = database.GetDomainIDByName(d.targetID, host); err != nil {
+			return true, err
+		}
+	default:
+		return true, err
+	}
+	if domainID == 0 {
+		return true, nil
+	}
+	return true, database.LinkIPAssetDomain(ep.asset.ID, domainID)
+}
+
+// joinInts formats ports as a comma-separated list.
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
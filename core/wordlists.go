@@ -0,0 +1,631 @@
+package core
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"toolkit/config"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const (
+	// wordlistTempPrefix names wordlists being written to the wordlists directory.
+	wordlistTempPrefix = ".wordlist-"
+	// wordlistMaxLine is the longest line read from a wordlist.
+	wordlistMaxLine = 1 << 20
+	// generatedWordMaxLen is the longest word kept when generating a wordlist; longer path
+	// segments are tokens or encoded data rather than names worth guessing.
+	generatedWordMaxLen = 100
+)
+
+// wordlistPath returns where the words of a wordlist are stored.
+func wordlistPath(id int64) string {
+	return filepath.Join(config.AppConfig.Wordlists.Dir, strconv.FormatInt(id, 10)+".txt")
+}
+
+// withWordlistPath fills in the file path of a wordlist.
+func withWordlistPath(w models.Wordlist, err error) (models.Wordlist, error) {
+	if err == nil {
+		w.Path = wordlistPath(w.ID)
+	}
+	return w, err
+}
+
+// wordlistWriter writes words, one per line, to a temporary file in the wordlists directory,
+// counting and hashing them. With dedupe, words already written are skipped.
+type wordlistWriter struct {
+	file       *os.File
+	buf        *bufio.Writer
+	hash       hash.Hash
+	seen       map[string]bool
+	fold       bool
+	words      int64
+	duplicates int64
+	size       int64
+}
+
+func newWordlistWriter(dedupe, caseInsensitive bool) (*wordlistWriter, error) {
+	dir := config.AppConfig.Wordlists.Dir
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("creating wordlists directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, wordlistTempPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("creating wordlist file: %w", err)
+	}
+	ww := &wordlistWriter{file: f, buf: bufio.NewWriter(f), hash: sha256.New(), fold: caseInsensitive}
+	if dedupe {
+		ww.seen = map[string]bool{}
+	}
+	return ww, nil
+}
+
+// add writes a word, trimmed; empty words are skipped.
+func (ww *wordlistWriter) add(word string) error {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		return nil
+	}
+	if ww.seen != nil {
+		key := word
+		if ww.fold {
+			key = strings.ToLower(word)
+		}
+		if ww.seen[key] {
+			ww.duplicates++
+			return nil
+		}
+		ww.seen[key] = true
+	}
+	ww.size += int64(len(word)) + 1
+	if limit := config.AppConfig.Wordlists.MaxFileBytes; ww.size > limit {
+		return fmt.Errorf("wordlist too large: wordlists are limited to %d bytes (wordlists.max_file_bytes)", limit)
+	}
+	ww.hash.Write([]byte(word + "\n"))
+	ww.words++
+	_, err := ww.buf.WriteString(word + "\n")
+	return err
+}
+
+// commit moves the written words into place as the file of wordlist id.
+func (ww *wordlistWriter) commit(id int64) error {
+	err := ww.buf.Flush()
+	if closeErr := ww.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(ww.file.Name(), wordlistPath(id))
+	}
+	if err != nil {
+		os.Remove(ww.file.Name())
+		return fmt.Errorf("storing wordlist %d: %w", id, err)
+	}
+	return nil
+}
+
+// discard removes the written words.
+func (ww *wordlistWriter) discard() {
+	ww.file.Close()
+	os.Remove(ww.file.Name())
+}
+
+func (ww *wordlistWriter) sum() string {
+	return hex.EncodeToString(ww.hash.Sum(nil))
+}
+
+// readWords calls fn with each line of r.
+func readWords(r io.Reader, fn func(word string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), wordlistMaxLine)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("invalid wordlist: lines are limited to %d bytes", wordlistMaxLine)
+	}
+	return scanner.Err()
+}
+
+// readWordlistFile calls fn with each word of a stored wordlist.
+func readWordlistFile(id int64, fn func(word string) error) error {
+	f, err := os.Open(wordlistPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file of wordlist %d not found in %s", id, config.AppConfig.Wordlists.Dir)
+		}
+		return fmt.Errorf("opening wordlist %d: %w", id, err)
+	}
+	defer f.Close()
+	return readWords(f, fn)
+}
+
+// wordlistName validates and trims a wordlist name.
+func wordlistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("invalid wordlist: a name is required")
+	}
+	if strings.ContainsAny(name, "\r\n\t/\\") {
+		return "", fmt.Errorf("invalid wordlist name '%s': tabs, line breaks and slashes are not allowed", name)
+	}
+	return name, nil
+}
+
+// storeWordlist records a wordlist whose words ww holds and moves them into place.
+func storeWordlist(ww *wordlistWriter, w models.Wordlist) (models.Wordlist, error) {
+	w.WordCount, w.Size, w.SHA256 = ww.words, ww.size, ww.sum()
+	id, err := database.CreateWordlist(w)
+	if err != nil {
+		ww.discard()
+		return models.Wordlist{}, err
+	}
+	if err := ww.commit(id); err != nil {
+		if errDelete := database.DeleteWordlist(id); errDelete != nil {
+			logger.Error("storeWordlist: Removing wordlist %d: %v", id, errDelete)
+		}
+		return models.Wordlist{}, err
+	}
+	logger.Info("Stored wordlist %d '%s' (%s, %d words)", id, w.Name, w.Source, w.WordCount)
+	return GetWordlist(id)
+}
+
+// wordlistTargetID checks that a target exists and returns it as the wordlist's target.
+func wordlistTargetID(targetID int64) (models.Wordlist, error) {
+	var w models.Wordlist
+	if targetID != 0 {
+		if _, err := database.GetTargetByID(targetID); err != nil {
+			return w, err
+		}
+		w.TargetID.Int64, w.TargetID.Valid = targetID, true
+	}
+	return w, nil
+}
+
+// CreateWordlist stores an uploaded wordlist, one word per line. Lines are trimmed and empty
+// ones dropped; with dedupe, repeated words are dropped as well. A target ID of 0 leaves the
+// wordlist unassigned. Wordlists larger than wordlists.max_file_bytes are rejected.
+func CreateWordlist(name, description string, targetID int64, dedupe bool, content io.Reader) (models.Wordlist, error) {
+	name, err := wordlistName(name)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	w, err := wordlistTargetID(targetID)
+	if err != nil {
+		return w, err
+	}
+	w.Name, w.Description, w.Source = name, strings.TrimSpace(description), models.WordlistSourceUpload
+
+	ww, err := newWordlistWriter(dedupe, false)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	// Lines are trimmed, so the upload may be a little larger than the stored wordlist.
+	limit := config.AppConfig.Wordlists.MaxFileBytes * 2
+	limited := &io.LimitedReader{R: content, N: limit + 1}
+	if err := readWords(limited, ww.add); err != nil {
+		ww.discard()
+		return models.Wordlist{}, err
+	}
+	if limited.N <= 0 {
+		ww.discard()
+		return models.Wordlist{}, fmt.Errorf("wordlist too large: uploads are limited to %d bytes", limit)
+	}
+	if ww.words == 0 {
+		ww.discard()
+		return models.Wordlist{}, fmt.Errorf("invalid wordlist: no words found")
+	}
+	return storeWordlist(ww, w)
+}
+
+// GetWordlists lists wordlists by name.
+func GetWordlists(filters models.WordlistFilters) ([]models.Wordlist, error) {
+	if filters.Source != "" && !slices.Contains([]string{models.WordlistSourceUpload, models.WordlistSourceMerge, models.WordlistSourceGenerated}, filters.Source) {
+		return nil, fmt.Errorf("invalid source '%s' (upload, merge or generated)", filters.Source)
+	}
+	wordlists, err := database.GetWordlists(filters)
+	for i := range wordlists {
+		wordlists[i].Path = wordlistPath(wordlists[i].ID)
+	}
+	return wordlists, err
+}
+
+// GetWordlist returns the metadata of a wordlist.
+func GetWordlist(id int64) (models.Wordlist, error) {
+	return withWordlistPath(database.GetWordlistByID(id))
+}
+
+// GetWordlistByName returns the metadata of a wordlist by its name.
+func GetWordlistByName(name string) (models.Wordlist, error) {
+	return withWordlistPath(database.GetWordlistByName(strings.TrimSpace(name)))
+}
+
+// OpenWordlist returns a wordlist and its content, which the caller must close.
+func OpenWordlist(id int64) (models.Wordlist, *os.File, error) {
+	w, err := GetWordlist(id)
+	if err != nil {
+		return w, nil, err
+	}
+	f, err := os.Open(w.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return w, nil, fmt.Errorf("file of wordlist %d not found in %s", id, config.AppConfig.Wordlists.Dir)
+		}
+		return w, nil, fmt.Errorf("opening wordlist %d: %w", id, err)
+	}
+	return w, f, nil
+}
+
+// ReadWordlist returns the words of a wordlist.
+func ReadWordlist(id int64) ([]string, error) {
+	var words []string
+	err := readWordlistFile(id, func(word string) error {
+		words = append(words, word)
+		return nil
+	})
+	return words, err
+}
+
+// UpdateWordlist renames a wordlist and replaces its description. An empty name keeps the current one.
+func UpdateWordlist(id int64, name, description string) (models.Wordlist, error) {
+	if strings.TrimSpace(name) == "" {
+		current, err := database.GetWordlistByID(id)
+		if err != nil {
+			return current, err
+		}
+		name = current.Name
+	}
+	name, err := wordlistName(name)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	if err := database.UpdateWordlist(id, name, strings.TrimSpace(description)); err != nil {
+		return models.Wordlist{}, err
+	}
+	return GetWordlist(id)
+}
+
+// DeleteWordlist removes a wordlist and its file.
+func DeleteWordlist(id int64) error {
+	if err := database.DeleteWordlist(id); err != nil {
+		return err
+	}
+	if err := os.Remove(wordlistPath(id)); err != nil && !os.IsNotExist(err) {
+		logger.Error("DeleteWordlist: Removing the file of wordlist %d: %v", id, err)
+	}
+	return nil
+}
+
+// TagWordlist adds tags, by name, to a wordlist, creating tags that don't exist yet. With
+// remove, the tags are taken off the wordlist instead.
+func TagWordlist(id int64, names []string, remove bool) (models.Wordlist, error) {
+	if _, err := database.GetWordlistByID(id); err != nil {
+		return models.Wordlist{}, err
+	}
+	tags, err := database.GetAllTags()
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		idx := slices.IndexFunc(tags, func(t models.Tag) bool { return strings.EqualFold(t.Name, name) })
+		switch {
+		case remove && idx < 0:
+			continue
+		case remove:
+			err = database.RemoveTagAssociation(tags[idx].ID, id, models.TagItemWordlist)
+		default:
+			if idx < 0 {
+				created, errCreate := database.CreateTag(models.Tag{Name: name})
+				if errCreate != nil {
+					return models.Wordlist{}, errCreate
+				}
+				tags = append(tags, created)
+				idx = len(tags) - 1
+			}
+			_, err = database.AssociateTagWithItem(tags[idx].ID, id, models.TagItemWordlist)
+		}
+		if err != nil {
+			return models.Wordlist{}, err
+		}
+	}
+	return GetWordlist(id)
+}
+
+// DedupeWordlist removes repeated words from a wordlist, keeping the first occurrence of each.
+// With caseInsensitive, words differing only in case are repeats as well.
+func DedupeWordlist(id int64, caseInsensitive bool) (models.WordlistDedupeResult, error) {
+	var result models.WordlistDedupeResult
+	if _, err := database.GetWordlistByID(id); err != nil {
+		return result, err
+	}
+	ww, err := newWordlistWriter(true, caseInsensitive)
+	if err != nil {
+		return result, err
+	}
+	if err := readWordlistFile(id, ww.add); err != nil {
+		ww.discard()
+		return result, err
+	}
+	if err := ww.commit(id); err != nil {
+		return result, err
+	}
+	if err := database.SetWordlistContent(id, ww.words, ww.size, ww.sum()); err != nil {
+		return result, err
+	}
+	result.Removed = ww.duplicates
+	result.Wordlist, err = GetWordlist(id)
+	return result, err
+}
+
+// MergeWordlists combines wordlists, in the given order, into a new wordlist.
+func MergeWordlists(req models.WordlistMergeRequest) (models.Wordlist, error) {
+	name, err := wordlistName(req.Name)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	var ids []int64
+	for _, id := range req.IDs {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 {
+		return models.Wordlist{}, fmt.Errorf("invalid merge: at least two different wordlists are required")
+	}
+	w, err := wordlistTargetID(req.TargetID)
+	if err != nil {
+		return w, err
+	}
+	var names []string
+	for _, id := range ids {
+		source, err := database.GetWordlistByID(id)
+		if err != nil {
+			return models.Wordlist{}, err
+		}
+		names = append(names, source.Name)
+	}
+	w.Name, w.Source = name, models.WordlistSourceMerge
+	w.Description = strings.TrimSpace(req.Description)
+	if w.Description == "" {
+		w.Description = "Merged from " + strings.Join(names, ", ")
+	}
+
+	ww, err := newWordlistWriter(req.Dedupe, req.CaseInsensitive)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	for _, id := range ids {
+		if err := readWordlistFile(id, ww.add); err != nil {
+			ww.discard()
+			return models.Wordlist{}, err
+		}
+	}
+	return storeWordlist(ww, w)
+}
+
+// wordCounter counts how often each word is seen, in order of first sight.
+type wordCounter struct {
+	counts map[string]int
+	order  []string
+}
+
+func (c *wordCounter) add(word string) {
+	if word == "" || len(word) > generatedWordMaxLen || strings.IndexFunc(word, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return
+	}
+	if c.counts[word] == 0 {
+		c.order = append(c.order, word)
+	}
+	c.counts[word]++
+}
+
+// addURL adds the path, path segments and query parameter names of a URL, or of a path with an
+// optional query string, as enabled.
+func (c *wordCounter) addURL(raw string, paths, segments, params bool) {
+	u, err := url.Parse(strings.TrimPrefix(strings.TrimSpace(raw), "./"))
+	if err != nil {
+		return
+	}
+	c.addPath(u.Path, paths, segments)
+	if params {
+		for name := range u.Query() {
+			c.add(name)
+		}
+	}
+}
+
+// addPath adds a path without its leading slash, when it holds no IDs or route parameters, and
+// its segments other than IDs and route parameters.
+func (c *wordCounter) addPath(p string, paths, segments bool) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return
+	}
+	whole := true
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." || idKind(segment) != "" ||
+			strings.ContainsAny(segment, "{}") || strings.HasPrefix(segment, ":") {
+			whole = false
+			continue
+		}
+		if segments {
+			c.add(segment)
+		}
+	}
+	if paths && whole {
+		c.add(p)
+	}
+}
+
+// GenerateTargetWordlist builds a wordlist from what was learned about a target: the paths, path
+// segments and query parameter names of its captured requests (the latest wordlists.max_logs),
+// discovered and canonical URLs and API endpoints, and the paths and parameters of URLs found in
+// its JavaScript. Words are ordered by how often they were seen. A generated wordlist of the same
+// target and name is replaced, so regenerating keeps the wordlist's ID and tags.
+func GenerateTargetWordlist(targetID int64, req models.WordlistGenerateRequest) (models.Wordlist, error) {
+	kinds := req.Kinds
+	if len(kinds) == 0 {
+		kinds = models.WordlistKinds
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(models.WordlistKinds, kind) {
+			return models.Wordlist{}, fmt.Errorf("invalid kind '%s' (%s)", kind, strings.Join(models.WordlistKinds, ", "))
+		}
+	}
+	target, err := database.GetTargetByID(targetID)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		req.Name = target.Codename + "-generated"
+	}
+	name, err := wordlistName(req.Name)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	existing, err := database.GetWordlistByName(name)
+	switch {
+	case err == nil:
+		if existing.Source != models.WordlistSourceGenerated || !existing.TargetID.Valid || existing.TargetID.Int64 != targetID {
+			return models.Wordlist{}, fmt.Errorf("a wordlist named '%s' already exists", name)
+		}
+	case strings.Contains(err.Error(), "not found"):
+		existing = models.Wordlist{}
+	default:
+		return models.Wordlist{}, err
+	}
+
+	paths, segments := slices.Contains(kinds, models.WordlistKindPaths), slices.Contains(kinds, models.WordlistKindSegments)
+	params, js := slices.Contains(kinds, models.WordlistKindParams), slices.Contains(kinds, models.WordlistKindJS)
+	c := &wordCounter{counts: map[string]int{}}
+	err = database.ForEachTargetWordSource(targetID, config.AppConfig.Wordlists.MaxLogs, func(kind, value string) {
+		switch kind {
+		case database.WordSourceURL:
+			c.addURL(value, paths, segments, params)
+		case database.WordSourcePath:
+			c.addPath(value, paths, segments)
+		case database.WordSourceParamKeys:
+			if params {
+				for _, key := range strings.Split(value, ",") {
+					c.add(strings.TrimSpace(key))
+				}
+			}
+		case database.WordSourceJSURL, database.WordSourceJSPath:
+			if js {
+				c.addURL(value, true, true, true)
+			}
+		}
+	})
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+
+	minCount := max(req.MinCount, 1)
+	words := slices.DeleteFunc(c.order, func(word string) bool { return c.counts[word] < minCount })
+	sort.SliceStable(words, func(i, j int) bool { return c.counts[words[i]] > c.counts[words[j]] })
+	if req.MaxWords > 0 && len(words) > req.MaxWords {
+		words = words[:req.MaxWords]
+	}
+	if len(words) == 0 {
+		return models.Wordlist{}, fmt.Errorf("invalid generation: no words found for target %d (capture some traffic first)", targetID)
+	}
+
+	ww, err := newWordlistWriter(false, false)
+	if err != nil {
+		return models.Wordlist{}, err
+	}
+	for _, word := range words {
+		if err := ww.add(word); err != nil {
+			ww.discard()
+			return models.Wordlist{}, err
+		}
+	}
+	if existing.ID != 0 {
+		if err := ww.commit(existing.ID); err != nil {
+			return models.Wordlist{}, err
+		}
+		if err := database.SetWordlistContent(existing.ID, ww.words, ww.size, ww.sum()); err != nil {
+			return models.Wordlist{}, err
+		}
+		logger.Info("Regenerated wordlist %d '%s' for target %d (%d words)", existing.ID, name, targetID, ww.words)
+		return GetWordlist(existing.ID)
+	}
+	w := models.Wordlist{
+		Name:        name,
+		Description: "Generated from the " + strings.Join(kinds, ", ") + " of " + target.Codename,
+		Source:      models.WordlistSourceGenerated,
+	}
+	w.TargetID.Int64, w.TargetID.Valid = targetID, true
+	return storeWordlist(ww, w)
+}
+
+// wordlistCommandVariables adds the command placeholders of the stored wordlists: wordlist_<id>
+// for each of them and target_wordlist for the wordlist last generated for the target.
+func wordlistCommandVariables(targetID int64, vars map[string]string) {
+	wordlists, err := database.GetWordlists(models.WordlistFilters{})
+	if err != nil {
+		logger.Warn("wordlistCommandVariables: %v", err)
+		return
+	}
+	for _, w := range wordlists {
+		vars["wordlist_"+strconv.FormatInt(w.ID, 10)] = wordlistPath(w.ID)
+	}
+	if generated, err := database.GetLatestGeneratedWordlist(targetID); err != nil {
+		logger.Warn("wordlistCommandVariables: %v", err)
+	} else if generated != nil {
+		vars["target_wordlist"] = wordlistPath(generated.ID)
+	}
+}
+
+// PruneWordlistFiles deletes the files of wordlists that no longer exist and leftovers of
+// interrupted writes. It is called when the server starts.
+func PruneWordlistFiles() {
+	dir := config.AppConfig.Wordlists.Dir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	ids, err := database.GetWordlistIDs()
+	if err != nil {
+		logger.Error("PruneWordlistFiles: %v", err)
+		return
+	}
+	var removed int
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			continue
+		}
+		id, errParse := strconv.ParseInt(strings.TrimSuffix(name, ".txt"), 10, 64)
+		stale := strings.HasPrefix(name, wordlistTempPrefix) || (strings.HasSuffix(name, ".txt") && errParse == nil && !ids[id])
+		if !stale {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			logger.Error("PruneWordlistFiles: Removing %s: %v", name, err)
+		} else {
+			removed++
+		}
+	}
+	if removed > 0 {
+		logger.Info("PruneWordlistFiles: Removed %d unused wordlist files.", removed)
+	}
+}
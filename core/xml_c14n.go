@@ -0,0 +1,341 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// xmlNamespaceURI is the namespace bound to the reserved xml prefix.
+const xmlNamespaceURI = "http://www.w3.org/XML/1998/namespace"
+
+// xmlNode is an element of a parsed XML document. Unlike encoding/xml's resolved names, it keeps
+// namespace prefixes and declarations as written, so the document can be written back faithfully
+// and canonicalized for XML signatures.
+type xmlNode struct {
+	prefix   string
+	local    string
+	attrs    []xmlAttr // In document order, namespace declarations included
+	children []xmlChild
+	parent   *xmlNode
+}
+
+// xmlAttr is an attribute as written. Namespace declarations have the prefix xmlns (xmlns:p) or
+// no prefix and the local name xmlns (the default namespace).
+type xmlAttr struct {
+	prefix string
+	local  string
+	value  string
+}
+
+// xmlChild is a child element, text or comment of an element.
+type xmlChild struct {
+	elem    *xmlNode
+	text    string
+	comment bool
+}
+
+func (a xmlAttr) isNamespaceDecl() bool {
+	return a.prefix == "xmlns" || (a.prefix == "" && a.local == "xmlns")
+}
+
+// parseXMLTree parses a document into its root element. Comments are kept; the XML declaration,
+// processing instructions and DOCTYPE are dropped.
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = true
+	var root, current *xmlNode
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{prefix: t.Name.Space, local: t.Name.Local, parent: current}
+			for _, a := range t.Attr {
+				n.attrs = append(n.attrs, xmlAttr{prefix: a.Name.Space, local: a.Name.Local, value: a.Value})
+			}
+			if current == nil {
+				if root != nil {
+					return nil, fmt.Errorf("invalid XML: more than one root element")
+				}
+				root = n
+			} else {
+				current.children = append(current.children, xmlChild{elem: n})
+			}
+			current = n
+		case xml.EndElement:
+			if current == nil || current.prefix != t.Name.Space || current.local != t.Name.Local {
+				return nil, fmt.Errorf("invalid XML: unexpected end element %s", t.Name.Local)
+			}
+			current = current.parent
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, xmlChild{text: string(t)})
+			}
+		case xml.Comment:
+			if current != nil {
+				current.children = append(current.children, xmlChild{text: string(t), comment: true})
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("invalid XML: no root element")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("invalid XML: element %s is not closed", current.local)
+	}
+	return root, nil
+}
+
+func (n *xmlNode) qname() string {
+	if n.prefix == "" {
+		return n.local
+	}
+	return n.prefix + ":" + n.local
+}
+
+// namespaceURI resolves a prefix ("" for the default namespace) in the scope of the element.
+func (n *xmlNode) namespaceURI(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespaceURI
+	}
+	for e := n; e != nil; e = e.parent {
+		for _, a := range e.attrs {
+			if (prefix == "" && a.prefix == "" && a.local == "xmlns") || (prefix != "" && a.prefix == "xmlns" && a.local == prefix) {
+				return a.value
+			}
+		}
+	}
+	return ""
+}
+
+// space returns the namespace URI of the element.
+func (n *xmlNode) space() string {
+	return n.namespaceURI(n.prefix)
+}
+
+// attr returns the value of an unqualified attribute.
+func (n *xmlNode) attr(local string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.prefix == "" && a.local == local {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// setAttr sets an unqualified attribute, appending it when missing.
+func (n *xmlNode) setAttr(local, value string) {
+	for i, a := range n.attrs {
+		if a.prefix == "" && a.local == local {
+			n.attrs[i].value = value
+			return
+		}
+	}
+	n.attrs = append(n.attrs, xmlAttr{local: local, value: value})
+}
+
+// text returns the concatenated text content of the element.
+func (n *xmlNode) text() string {
+	var b strings.Builder
+	for _, c := range n.children {
+		switch {
+		case c.elem != nil:
+			b.WriteString(c.elem.text())
+		case !c.comment:
+			b.WriteString(c.text)
+		}
+	}
+	return b.String()
+}
+
+// setText replaces the content of the element with text.
+func (n *xmlNode) setText(text string) {
+	n.children = []xmlChild{{text: text}}
+}
+
+// appendChild adds an element as the last child.
+func (n *xmlNode) appendChild(c *xmlNode) {
+	c.parent = n
+	n.children = append(n.children, xmlChild{elem: c})
+}
+
+// insertChild adds an element as the child at index i.
+func (n *xmlNode) insertChild(i int, c *xmlNode) {
+	c.parent = n
+	n.children = append(n.children, xmlChild{})
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = xmlChild{elem: c}
+}
+
+// removeChildren removes the child elements remove returns true for.
+func (n *xmlNode) removeChildren(remove func(*xmlNode) bool) {
+	kept := n.children[:0]
+	for _, c := range n.children {
+		if c.elem != nil && remove(c.elem) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	n.children = kept
+}
+
+// find returns the descendants (the element included) with a namespace and local name, in
+// document order.
+func (n *xmlNode) find(space, local string) []*xmlNode {
+	var found []*xmlNode
+	var walk func(*xmlNode)
+	walk = func(e *xmlNode) {
+		if e.local == local && e.space() == space {
+			found = append(found, e)
+		}
+		for _, c := range e.children {
+			if c.elem != nil {
+				walk(c.elem)
+			}
+		}
+	}
+	walk(n)
+	return found
+}
+
+// findFirst returns the first descendant with a namespace and local name, or nil.
+func (n *xmlNode) findFirst(space, local string) *xmlNode {
+	if found := n.find(space, local); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// childElements returns the child elements with a namespace and local name.
+func (n *xmlNode) childElements(space, local string) []*xmlNode {
+	var found []*xmlNode
+	for _, c := range n.children {
+		if c.elem != nil && c.elem.local == local && c.elem.space() == space {
+			found = append(found, c.elem)
+		}
+	}
+	return found
+}
+
+// serialize writes the element as it was parsed (edits included), with its namespace
+// declarations where they were written.
+func (n *xmlNode) serialize(buf *bytes.Buffer) {
+	buf.WriteString("<" + n.qname())
+	for _, a := range n.attrs {
+		name := a.local
+		if a.prefix != "" {
+			name = a.prefix + ":" + a.local
+		}
+		buf.WriteString(" " + name + `="` + escapeC14NAttr(a.value) + `"`)
+	}
+	buf.WriteString(">")
+	for _, c := range n.children {
+		switch {
+		case c.elem != nil:
+			c.elem.serialize(buf)
+		case c.comment:
+			buf.WriteString("<!--" + c.text + "-->")
+		default:
+			buf.WriteString(escapeC14NText(c.text))
+		}
+	}
+	buf.WriteString("</" + n.qname() + ">")
+}
+
+// canonicalize writes the element in Exclusive XML Canonicalization form without comments
+// (http://www.w3.org/2001/10/xml-exc-c14n#), leaving out the exclude element (the signature of an
+// enveloped-signature transform). rendered holds the namespace declarations in effect in the
+// output so far, by prefix.
+func (n *xmlNode) canonicalize(buf *bytes.Buffer, rendered map[string]string, exclude *xmlNode) {
+	// Namespaces are declared where they are visibly utilized: by the element or its attributes.
+	utilized := map[string]bool{n.prefix: true}
+	type canonAttr struct{ space, local, name, value string }
+	var attrs []canonAttr
+	for _, a := range n.attrs {
+		if a.isNamespaceDecl() {
+			continue
+		}
+		name, space := a.local, ""
+		if a.prefix != "" {
+			name, space = a.prefix+":"+a.local, n.namespaceURI(a.prefix)
+			if a.prefix != "xml" {
+				utilized[a.prefix] = true
+			}
+		}
+		attrs = append(attrs, canonAttr{space: space, local: a.local, name: name, value: a.value})
+	}
+	var prefixes []string
+	scope := rendered
+	copied := false
+	for prefix := range utilized {
+		uri := n.namespaceURI(prefix)
+		current, ok := scope[prefix]
+		if (ok && current == uri) || (!ok && prefix == "" && uri == "") {
+			continue
+		}
+		if !copied {
+			scope = make(map[string]string, len(rendered)+len(utilized))
+			for k, v := range rendered {
+				scope[k] = v
+			}
+			copied = true
+		}
+		scope[prefix] = uri
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes) // The default namespace ("") sorts first
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	buf.WriteString("<" + n.qname())
+	for _, p := range prefixes {
+		if p == "" {
+			buf.WriteString(` xmlns="` + escapeC14NAttr(scope[p]) + `"`)
+		} else {
+			buf.WriteString(" xmlns:" + p + `="` + escapeC14NAttr(scope[p]) + `"`)
+		}
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + a.name + `="` + escapeC14NAttr(a.value) + `"`)
+	}
+	buf.WriteString(">")
+	for _, c := range n.children {
+		switch {
+		case c.elem != nil:
+			if c.elem != exclude {
+				c.elem.canonicalize(buf, scope, exclude)
+			}
+		case !c.comment:
+			buf.WriteString(escapeC14NText(c.text))
+		}
+	}
+	buf.WriteString("</" + n.qname() + ">")
+}
+
+// canonicalBytes returns the exclusive canonical form of the element as a document subset.
+func (n *xmlNode) canonicalBytes(exclude *xmlNode) []byte {
+	var buf bytes.Buffer
+	n.canonicalize(&buf, map[string]string{}, exclude)
+	return buf.Bytes()
+}
+
+var (
+	c14nTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	c14nAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeC14NText(s string) string { return c14nTextEscaper.Replace(s) }
+func escapeC14NAttr(s string) string { return c14nAttrEscaper.Replace(s) }
@@ -0,0 +1,38 @@
+package database
+
+import "fmt"
+
+// SaveAnalysisResult stores the output of an analyzer for a traffic log entry, replacing the
+// previous output of the same analyzer.
+func SaveAnalysisResult(httpLogID int64, analysisType, resultData string) error {
+	_, err := DB.Exec(`INSERT INTO analysis_results (http_log_id, analysis_type, result_data) VALUES (?, ?, ?)
+		ON CONFLICT(http_log_id, analysis_type) DO UPDATE SET result_data = excluded.result_data, timestamp = CURRENT_TIMESTAMP`,
+		httpLogID, analysisType, resultData)
+	if err != nil {
+		return fmt.Errorf("storing %s analysis of log %d: %w", analysisType, httpLogID, err)
+	}
+	return nil
+}
+
+// ListTrafficLogIDsForAnalysis returns, in ID order, the log entries of a target that have a
+// response body, optionally only those whose Content-Type contains contentType. Entries answered by
+// the response cache repeat an earlier response and are left out.
+func ListTrafficLogIDsForAnalysis(targetID int64, contentType string) ([]int64, error) {
+	rows, err := DB.Query(`SELECT id FROM http_traffic_log
+		WHERE target_id = ? AND LENGTH(`+logResponseBody+`) > 0 AND LOWER(COALESCE(response_content_type, '')) LIKE LOWER(?)
+			AND cached_from_log_id IS NULL
+		ORDER BY id`, targetID, "%"+contentType+"%")
+	if err != nil {
+		return nil, fmt.Errorf("listing traffic of target %d for analysis: %w", targetID, err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning traffic log ID for analysis: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+// annotationEntityTables maps each annotation entity type to its table and the column used as its display label.
+var annotationEntityTables = map[string]struct{ table, label string }{
+	models.AnnotationEntityHTTPLog:       {"http_traffic_log", "request_method || ' ' || request_url"},
+	models.AnnotationEntityDomain:        {"domains", "domain_name"},
+	models.AnnotationEntityFinding:       {"target_findings", "title"},
+	models.AnnotationEntityChecklistItem: {"target_checklist_items", "item_text"},
+	models.AnnotationEntityModifierTask:  {"modifier_tasks", "name"},
+}
+
+// annotationLabelExpr resolves the display label of an annotation's entity.
+var annotationLabelExpr = func() string {
+	var b strings.Builder
+	b.WriteString("COALESCE(CASE a.entity_type")
+	for _, entityType := range models.AnnotationEntityTypes {
+		t := annotationEntityTables[entityType]
+		fmt.Fprintf(&b, " WHEN '%s' THEN (SELECT %s FROM %s WHERE id = a.entity_id)", entityType, t.label, t.table)
+	}
+	b.WriteString(" END, '')")
+	return b.String()
+}()
+
+var annotationColumns = `a.id, a.target_id, a.entity_type, a.entity_id, ` + annotationLabelExpr + `, a.content, a.created_at, a.updated_at`
+
+func scanAnnotation(scanner interface{ Scan(...interface{}) error }) (models.Annotation, error) {
+	var a models.Annotation
+	err := scanner.Scan(&a.ID, &a.TargetID, &a.EntityType, &a.EntityID, &a.EntityLabel, &a.Content, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// GetAnnotationEntityTargetID checks that an annotatable entity exists and returns its target ID.
+func GetAnnotationEntityTargetID(entityType string, entityID int64) (sql.NullInt64, error) {
+	var targetID sql.NullInt64
+	t, ok := annotationEntityTables[entityType]
+	if !ok {
+		return targetID, fmt.Errorf("unsupported entity type '%s'", entityType)
+	}
+	err := DB.QueryRow(`SELECT target_id FROM `+t.table+` WHERE id = ?`, entityID).Scan(&targetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return targetID, fmt.Errorf("%s with ID %d not found", entityType, entityID)
+		}
+		return targetID, fmt.Errorf("looking up %s %d: %w", entityType, entityID, err)
+	}
+	return targetID, nil
+}
+
+// CreateAnnotation adds an annotation to an entity, copying the entity's target, and returns its ID.
+func CreateAnnotation(entityType string, entityID int64, content string) (int64, error) {
+	targetID, err := GetAnnotationEntityTargetID(entityType, entityID)
+	if err != nil {
+		return 0, err
+	}
+	res, err := DB.Exec(`INSERT INTO annotations (target_id, entity_type, entity_id, content) VALUES (?, ?, ?, ?)`,
+		targetID, entityType, entityID, content)
+	if err != nil {
+		return 0, fmt.Errorf("inserting annotation: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// UpdateAnnotation replaces the content of an annotation.
+func UpdateAnnotation(id int64, content string) error {
+	res, err := DB.Exec(`UPDATE annotations SET content = ? WHERE id = ?`, content, id)
+	if err != nil {
+		return fmt.Errorf("updating annotation %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("annotation with ID %d not found", id)
+	}
+	return nil
+}
+
+// DeleteAnnotation removes an annotation.
+func DeleteAnnotation(id int64) error {
+	res, err := DB.Exec(`DELETE FROM annotations WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting annotation %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("annotation with ID %d not found", id)
+	}
+	return nil
+}
+
+// GetAnnotationByID fetches an annotation.
+func GetAnnotationByID(id int64) (models.Annotation, error) {
+	a, err := scanAnnotation(DB.QueryRow(`SELECT `+annotationColumns+` FROM annotations a WHERE a.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return a, fmt.Errorf("annotation with ID %d not found", id)
+		}
+		return a, fmt.Errorf("scanning annotation %d: %w", id, err)
+	}
+	return a, nil
+}
+
+// GetAnnotations lists annotations with filters and pagination, oldest first unless SortOrder is DESC.
+func GetAnnotations(filters models.AnnotationFilters) ([]models.Annotation, int64, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "a.target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.EntityType != "" {
+		conditions = append(conditions, "a.entity_type = ?")
+		args = append(args, filters.EntityType)
+	}
+	if filters.EntityID != 0 {
+		conditions = append(conditions, "a.entity_id = ?")
+		args = append(args, filters.EntityID)
+	}
+	if !filters.Since.IsZero() {
+		conditions = append(conditions, "a.created_at >= ?")
+		args = append(args, filters.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !filters.Until.IsZero() {
+		conditions = append(conditions, "a.created_at < ?")
+		args = append(args, filters.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if filters.Search != "" {
+		conditions = append(conditions, "a.content LIKE ?")
+		args = append(args, "%"+filters.Search+"%")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := DB.QueryRow("SELECT COUNT(*) FROM annotations a"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting annotations: %w", err)
+	}
+	if filters.Limit <= 0 {
+		filters.Limit = 50
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	order := "ASC"
+	if strings.ToUpper(filters.SortOrder) == "DESC" {
+		order = "DESC"
+	}
+	rows, err := DB.Query(`SELECT `+annotationColumns+` FROM annotations a`+where+` ORDER BY a.created_at `+order+`, a.id `+order+` LIMIT ? OFFSET ?`,
+		append(args, filters.Limit, (filters.Page-1)*filters.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := []models.Annotation{}
+	for rows.Next() {
+		a, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning annotation: %w", err)
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, total, rows.Err()
+}
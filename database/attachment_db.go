@@ -0,0 +1,180 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+// attachmentEntityTables maps each attachment entity type to its table, the expression of its
+// target ID and the column used as its display label.
+var attachmentEntityTables = map[string]struct{ table, targetID, label string }{
+	models.AttachmentEntityTarget:        {"targets", "id", "codename"},
+	models.AttachmentEntityFinding:       {"target_findings", "target_id", "title"},
+	models.AttachmentEntityNote:          {"notes", "NULL", "COALESCE(title, '')"},
+	models.AttachmentEntityChecklistItem: {"target_checklist_items", "target_id", "item_text"},
+}
+
+// attachmentLabelExpr resolves the display label of an attachment's entity.
+var attachmentLabelExpr = func() string {
+	var b strings.Builder
+	b.WriteString("COALESCE(CASE a.entity_type")
+	for _, entityType := range models.AttachmentEntityTypes {
+		t := attachmentEntityTables[entityType]
+		fmt.Fprintf(&b, " WHEN '%s' THEN (SELECT %s FROM %s WHERE id = a.entity_id)", entityType, t.label, t.table)
+	}
+	b.WriteString(" END, '')")
+	return b.String()
+}()
+
+var attachmentColumns = `a.id, a.target_id, a.entity_type, a.entity_id, ` + attachmentLabelExpr + `, a.file_name, a.content_type, a.size,
+	a.sha256, a.description, a.created_at`
+
+func scanAttachment(scanner interface{ Scan(...interface{}) error }) (models.Attachment, error) {
+	var a models.Attachment
+	err := scanner.Scan(&a.ID, &a.TargetID, &a.EntityType, &a.EntityID, &a.EntityLabel, &a.FileName, &a.ContentType, &a.Size,
+		&a.SHA256, &a.Description, &a.CreatedAt)
+	return a, err
+}
+
+// GetAttachmentEntityTargetID checks that an entity files can be attached to exists and returns
+// its target ID.
+func GetAttachmentEntityTargetID(entityType string, entityID int64) (sql.NullInt64, error) {
+	var targetID sql.NullInt64
+	t, ok := attachmentEntityTables[entityType]
+	if !ok {
+		return targetID, fmt.Errorf("unsupported entity type '%s'", entityType)
+	}
+	err := DB.QueryRow(`SELECT `+t.targetID+` FROM `+t.table+` WHERE id = ?`, entityID).Scan(&targetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return targetID, fmt.Errorf("%s with ID %d not found", entityType, entityID)
+		}
+		return targetID, fmt.Errorf("looking up %s %d: %w", entityType, entityID, err)
+	}
+	return targetID, nil
+}
+
+// CreateAttachment records a stored file as attached to an entity and returns the attachment ID.
+// When the same file is already attached to the entity, the existing attachment's ID is returned
+// with existing set.
+func CreateAttachment(a models.Attachment) (id int64, existing bool, err error) {
+	err = DB.QueryRow(`SELECT id FROM attachments WHERE entity_type = ? AND entity_id = ? AND sha256 = ?`,
+		a.EntityType, a.EntityID, a.SHA256).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("looking up attachment: %w", err)
+	}
+	res, err := DB.Exec(`INSERT INTO attachments (target_id, entity_type, entity_id, file_name, content_type, size, sha256, description)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.TargetID, a.EntityType, a.EntityID, a.FileName, a.ContentType, a.Size, a.SHA256, a.Description)
+	if err != nil {
+		return 0, false, fmt.Errorf("inserting attachment: %w", err)
+	}
+	id, err = res.LastInsertId()
+	return id, false, err
+}
+
+// GetAttachmentByID fetches an attachment.
+func GetAttachmentByID(id int64) (models.Attachment, error) {
+	a, err := scanAttachment(DB.QueryRow(`SELECT `+attachmentColumns+` FROM attachments a WHERE a.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return a, fmt.Errorf("attachment with ID %d not found", id)
+		}
+		return a, fmt.Errorf("scanning attachment %d: %w", id, err)
+	}
+	return a, nil
+}
+
+// GetAttachments lists attachments, newest first.
+func GetAttachments(filters models.AttachmentFilters) ([]models.Attachment, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "a.target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.EntityType != "" {
+		conditions = append(conditions, "a.entity_type = ?")
+		args = append(args, filters.EntityType)
+	}
+	if filters.EntityID != 0 {
+		conditions = append(conditions, "a.entity_id = ?")
+		args = append(args, filters.EntityID)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := DB.Query(`SELECT `+attachmentColumns+` FROM attachments a`+where+` ORDER BY a.created_at DESC, a.id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// UpdateAttachment renames an attachment and replaces its description.
+func UpdateAttachment(id int64, fileName, description string) error {
+	res, err := DB.Exec(`UPDATE attachments SET file_name = ?, description = ? WHERE id = ?`, fileName, description, id)
+	if err != nil {
+		return fmt.Errorf("updating attachment %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("attachment with ID %d not found", id)
+	}
+	return nil
+}
+
+// DeleteAttachment removes an attachment.
+func DeleteAttachment(id int64) error {
+	res, err := DB.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting attachment %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("attachment with ID %d not found", id)
+	}
+	return nil
+}
+
+// CountAttachmentsBySHA256 returns how many attachments share a stored file.
+func CountAttachmentsBySHA256(sha256 string) (int64, error) {
+	var n int64
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM attachments WHERE sha256 = ?`, sha256).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting attachments of %s: %w", sha256, err)
+	}
+	return n, nil
+}
+
+// GetAttachmentSHA256s returns the hashes of every stored file still attached to something.
+func GetAttachmentSHA256s() (map[string]bool, error) {
+	rows, err := DB.Query(`SELECT DISTINCT sha256 FROM attachments`)
+	if err != nil {
+		return nil, fmt.Errorf("querying attachment hashes: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]bool)
+	for rows.Next() {
+		var sha256 string
+		if err := rows.Scan(&sha256); err != nil {
+			return nil, fmt.Errorf("scanning attachment hash: %w", err)
+		}
+		hashes[sha256] = true
+	}
+	return hashes, rows.Err()
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+	"toolkit/models"
+)
+
+// RecordAuthToken stores a token seen in a log entry, or widens the first/last seen window of the
+// known token, and records where the entry carried it. It returns the token ID.
+func RecordAuthToken(token models.AuthToken, logID int64, location string) (int64, error) {
+	sum := sha256.Sum256([]byte(token.Token))
+	seen := token.LastSeenAt
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+	var expiresAt sql.NullTime
+	if token.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *token.ExpiresAt, Valid: true}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`INSERT INTO auth_tokens (target_id, kind, token_hash, token, algorithm, issuer, subject, expires_at, signed, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(target_id, token_hash) DO UPDATE SET first_seen_at = MIN(first_seen_at, excluded.first_seen_at),
+			last_seen_at = MAX(last_seen_at, excluded.last_seen_at)
+		RETURNING id`, token.TargetID, token.Kind, hex.EncodeToString(sum[:]), token.Token, token.Algorithm, token.Issuer,
+		token.Subject, expiresAt, token.Signed, seen, seen).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("saving %s token of target %d: %w", token.Kind, token.TargetID, err)
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO auth_token_occurrences (token_id, http_traffic_log_id, location) VALUES (?, ?, ?)`,
+		id, logID, location); err != nil {
+		return 0, fmt.Errorf("recording occurrence of auth token %d in log %d: %w", id, logID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing auth token %d: %w", id, err)
+	}
+	return id, nil
+}
+
+const authTokenColumns = `t.id, t.target_id, t.kind, t.token, t.algorithm, t.issuer, t.subject, t.expires_at, t.signed,
+	t.first_seen_at, t.last_seen_at, (SELECT COUNT(*) FROM auth_token_occurrences o WHERE o.token_id = t.id)`
+
+func scanAuthToken(scanner interface{ Scan(...interface{}) error }) (models.AuthToken, error) {
+	var t models.AuthToken
+	var expiresAt sql.NullTime
+	err := scanner.Scan(&t.ID, &t.TargetID, &t.Kind, &t.Token, &t.Algorithm, &t.Issuer, &t.Subject, &expiresAt, &t.Signed,
+		&t.FirstSeenAt, &t.LastSeenAt, &t.OccurrenceCount)
+	if expiresAt.Valid {
+		t.ExpiresAt = &expiresAt.Time
+	}
+	return t, err
+}
+
+// GetAuthTokens lists the tokens seen in a target's traffic, most recently seen first. kind
+// filters on jwt or saml when not empty.
+func GetAuthTokens(targetID int64, kind string) ([]models.AuthToken, error) {
+	query := `SELECT ` + authTokenColumns + ` FROM auth_tokens t WHERE t.target_id = ?`
+	args := []interface{}{targetID}
+	if kind != "" {
+		query += ` AND t.kind = ?`
+		args = append(args, kind)
+	}
+	rows, err := DB.Query(query+` ORDER BY t.last_seen_at DESC, t.id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying auth tokens of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	tokens := []models.AuthToken{}
+	for rows.Next() {
+		t, err := scanAuthToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning auth token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAuthToken fetches a tracked token by ID.
+func GetAuthToken(id int64) (models.AuthToken, error) {
+	t, err := scanAuthToken(DB.QueryRow(`SELECT `+authTokenColumns+` FROM auth_tokens t WHERE t.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return t, fmt.Errorf("auth token with ID %d not found", id)
+		}
+		return t, fmt.Errorf("scanning auth token %d: %w", id, err)
+	}
+	return t, nil
+}
+
+// GetAuthTokenOccurrences lists the log entries a token appeared in, oldest first.
+func GetAuthTokenOccurrences(tokenID int64) ([]models.AuthTokenOccurrence, error) {
+	rows, err := DB.Query(`SELECT o.http_traffic_log_id, o.location, COALESCE(l.request_method, ''), COALESCE(l.request_url, ''), l.timestamp
+		FROM auth_token_occurrences o JOIN http_traffic_log l ON l.id = o.http_traffic_log_id
+		WHERE o.token_id = ? ORDER BY o.http_traffic_log_id, o.location`, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("querying occurrences of auth token %d: %w", tokenID, err)
+	}
+	defer rows.Close()
+
+	occurrences := []models.AuthTokenOccurrence{}
+	for rows.Next() {
+		var o models.AuthTokenOccurrence
+		if err := rows.Scan(&o.HTTPTrafficLogID, &o.Location, &o.Method, &o.URL, &o.Timestamp); err != nil {
+			return nil, fmt.Errorf("scanning auth token occurrence: %w", err)
+		}
+		occurrences = append(occurrences, o)
+	}
+	return occurrences, rows.Err()
+}
+
+// GetAuthTokenTraffic returns up to limit log entries of a target after afterID, in ID order,
+// that may carry a JWT or SAML message. Only the fields the token finder reads are loaded.
+func GetAuthTokenTraffic(targetID, afterID int64, limit int) ([]models.HTTPTrafficLog, error) {
+	rows, err := DB.Query(`SELECT id, timestamp, request_method, request_url, request_headers, `+logRequestBody+`,
+			response_headers, `+logResponseBody+`
+		FROM http_traffic_log
+		WHERE target_id = ? AND id > ? AND (request_url LIKE '%eyJ%' OR request_url LIKE '%SAML%'
+			OR request_headers LIKE '%eyJ%' OR response_headers LIKE '%eyJ%'
+			OR `+logRequestBody+` LIKE '%eyJ%' OR `+logRequestBody+` LIKE '%SAML%'
+			OR `+logResponseBody+` LIKE '%eyJ%' OR `+logResponseBody+` LIKE '%SAML%')
+		ORDER BY id LIMIT ?`, targetID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying auth token traffic of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		e := models.HTTPTrafficLog{TargetID: &targetID}
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.RequestMethod, &e.RequestURL, &e.RequestHeaders, &e.RequestBody,
+			&e.ResponseHeaders, &e.ResponseBody); err != nil {
+			return nil, fmt.Errorf("scanning auth token traffic: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+const beautifiedBodyColumns = `bb.id, bb.sha256, bb.language, bb.data, bb.original_size, bb.size, bb.error, bb.created_at`
+
+// beautifiableContentTypes are the response content types the beautify job picks up, as LIKE
+// patterns. Bodies of other types are beautified on demand when they sniff as one of these.
+var beautifiableContentTypes = []string{"%javascript%", "%ecmascript%", "%json%", "%html%", "%xml%"}
+
+func scanBeautifiedBody(row interface{ Scan(...interface{}) error }) (models.BeautifiedBody, error) {
+	var b models.BeautifiedBody
+	var errMsg sql.NullString
+	if err := row.Scan(&b.ID, &b.SHA256, &b.Language, &b.Data, &b.OriginalSize, &b.Size, &errMsg, &b.CreatedAt); err != nil {
+		return b, err
+	}
+	b.Error = errMsg.String
+	return b, nil
+}
+
+// GetTrafficLogBeautifiedBody returns the beautified copy of a log entry's response body, with
+// found false when it has none yet.
+func GetTrafficLogBeautifiedBody(logID int64) (models.BeautifiedBody, bool, error) {
+	b, err := scanBeautifiedBody(DB.QueryRow(`SELECT `+beautifiedBodyColumns+` FROM http_traffic_log htl
+		JOIN beautified_bodies bb ON bb.id = htl.response_beautified_id WHERE htl.id = ?`, logID))
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+	if err != nil {
+		return b, false, fmt.Errorf("looking up beautified body of log %d: %w", logID, err)
+	}
+	return b, true, nil
+}
+
+// GetBeautifiedBodyByHash returns the beautified copy of a body another log entry already has.
+func GetBeautifiedBodyByHash(sha256, language string) (models.BeautifiedBody, bool, error) {
+	b, err := scanBeautifiedBody(DB.QueryRow(`SELECT `+beautifiedBodyColumns+` FROM beautified_bodies bb
+		WHERE bb.sha256 = ? AND bb.language = ?`, sha256, language))
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+	if err != nil {
+		return b, false, fmt.Errorf("looking up beautified body %s: %w", sha256, err)
+	}
+	return b, true, nil
+}
+
+// LinkBeautifiedBody stores a beautified copy unless one of the same body exists, and makes it the
+// copy of a log entry's response body. The copy's reference count is raised by the triggers.
+func LinkBeautifiedBody(logID int64, b models.BeautifiedBody) (models.BeautifiedBody, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return b, fmt.Errorf("starting beautified body transaction: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO beautified_bodies (sha256, language, data, original_size, size, error) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(sha256, language) DO NOTHING`, b.SHA256, b.Language, b.Data, b.OriginalSize, len(b.Data), nullString(b.Error))
+	if err != nil {
+		return b, fmt.Errorf("storing beautified body of log %d: %w", logID, err)
+	}
+	stored, err := scanBeautifiedBody(tx.QueryRow(`SELECT `+beautifiedBodyColumns+` FROM beautified_bodies bb
+		WHERE bb.sha256 = ? AND bb.language = ?`, b.SHA256, b.Language))
+	if err != nil {
+		return b, fmt.Errorf("looking up beautified body of log %d: %w", logID, err)
+	}
+	if _, err := tx.Exec(`UPDATE http_traffic_log SET response_beautified_id = ? WHERE id = ?`, stored.ID, logID); err != nil {
+		return b, fmt.Errorf("linking beautified body of log %d: %w", logID, err)
+	}
+	return stored, tx.Commit()
+}
+
+// beautifyPendingConditions selects the log entries with a response body of a beautifiable content
+// type but no beautified copy, optionally of one target.
+func beautifyPendingConditions(targetID int64) Conditions {
+	var c Conditions
+	c.Add("response_beautified_id IS NULL")
+	c.Add("(response_body IS NOT NULL OR response_body_blob_id IS NOT NULL)")
+	types := ""
+	var args []interface{}
+	for i, pattern := range beautifiableContentTypes {
+		if i > 0 {
+			types += " OR "
+		}
+		types += "LOWER(response_content_type) LIKE ?"
+		args = append(args, pattern)
+	}
+	c.Add("("+types+")", args...)
+	if targetID > 0 {
+		c.Add("target_id = ?", targetID)
+	}
+	return c
+}
+
+// CountBeautifyPending counts the log entries whose response a beautify job would beautify.
+func CountBeautifyPending(targetID int64) (int64, error) {
+	c := beautifyPendingConditions(targetID)
+	var n int64
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM http_traffic_log `+c.Where(), c.Args()...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting responses to beautify: %w", err)
+	}
+	return n, nil
+}
+
+// GetBeautifyPending returns up to limit log entries after afterID whose response a beautify job
+// would beautify, with their ID, response content type, headers and body.
+func GetBeautifyPending(targetID, afterID int64, limit int) ([]models.HTTPTrafficLog, error) {
+	c := beautifyPendingConditions(targetID)
+	c.Add("id > ?", afterID)
+	rows, err := DB.Query(`SELECT id, response_content_type, response_headers, `+logResponseBody+` FROM http_traffic_log `+
+		c.Where()+` ORDER BY id LIMIT ?`, c.Args(limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("querying responses to beautify: %w", err)
+	}
+	defer rows.Close()
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		if err := rows.Scan(&e.ID, &e.ResponseContentType, &e.ResponseHeaders, &e.ResponseBody); err != nil {
+			return nil, fmt.Errorf("scanning response to beautify: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"toolkit/models"
+)
+
+// bodyBlobMinBytes is the smallest body stored as a blob; smaller bodies stay inline, where they
+// take less room than a blob row and its hash.
+const bodyBlobMinBytes = 512
+
+// TrafficBodyExpr returns the SQL expression reading a body column (request_body or
+// response_body) of http_traffic_log under the given table name or alias, whether the body is
+// stored inline or as a blob.
+func TrafficBodyExpr(table, column string) string {
+	return fmt.Sprintf("COALESCE(%[1]s.%[2]s, (SELECT data FROM body_blobs WHERE id = %[1]s.%[2]s_blob_id))", table, column)
+}
+
+// Body expressions of the usual http_traffic_log aliases.
+var (
+	htlRequestBody  = TrafficBodyExpr("htl", "request_body")
+	htlResponseBody = TrafficBodyExpr("htl", "response_body")
+	logRequestBody  = TrafficBodyExpr("http_traffic_log", "request_body")
+	logResponseBody = TrafficBodyExpr("http_traffic_log", "response_body")
+)
+
+// trafficBodies holds the bodies of a log entry as written to http_traffic_log: each one either
+// inline or as a blob ID.
+type trafficBodies struct {
+	request, response         []byte
+	requestBlob, responseBlob sql.NullInt64
+}
+
+// storeTrafficBodies stores the bodies of a log entry that are large enough as blobs.
+func storeTrafficBodies(tx *sql.Tx, request, response []byte) (trafficBodies, error) {
+	b := trafficBodies{request: request, response: response}
+	var err error
+	if b.requestBlob, err = storeBodyBlob(tx, request); err != nil {
+		return b, err
+	}
+	if b.requestBlob.Valid {
+		b.request = nil
+	}
+	if b.responseBlob, err = storeBodyBlob(tx, response); err != nil {
+		return b, err
+	}
+	if b.responseBlob.Valid {
+		b.response = nil
+	}
+	return b, nil
+}
+
+// storeBodyBlob returns the ID of the blob holding body, adding it when no log entry has the same
+// body yet. Bodies under bodyBlobMinBytes are not stored. The blob's reference count is raised by
+// the triggers when a log entry references it.
+func storeBodyBlob(tx *sql.Tx, body []byte) (sql.NullInt64, error) {
+	var id sql.NullInt64
+	if len(body) < bodyBlobMinBytes {
+		return id, nil
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	if _, err := tx.Exec(`INSERT INTO body_blobs (sha256, data, size) VALUES (?, ?, ?) ON CONFLICT(sha256) DO NOTHING`,
+		hash, body, len(body)); err != nil {
+		return id, fmt.Errorf("storing body blob: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT id FROM body_blobs WHERE sha256 = ?`, hash).Scan(&id); err != nil {
+		return id, fmt.Errorf("looking up body blob: %w", err)
+	}
+	return id, nil
+}
+
+// insertTrafficLog stores the bodies of a log entry as blobs and runs insert, which must write
+// the given bodies and blob IDs, in the same transaction. It returns the new entry's ID.
+func insertTrafficLog(request, response []byte, insert func(tx *sql.Tx, b trafficBodies) (sql.Result, error)) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting log transaction: %w", err)
+	}
+	defer tx.Rollback()
+	bodies, err := storeTrafficBodies(tx, request, response)
+	if err != nil {
+		return 0, err
+	}
+	result, err := insert(tx, bodies)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// setTrafficLogBodies replaces the bodies of a log entry, storing them as blobs when large enough,
+// and drops the beautified copy of the old response body. Blobs and copies no longer referenced
+// are removed by the triggers.
+func setTrafficLogBodies(tx *sql.Tx, logID int64, request, response []byte) error {
+	bodies, err := storeTrafficBodies(tx, request, response)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE http_traffic_log SET request_body = ?, request_body_blob_id = ?, response_body = ?, response_body_blob_id = ?,
+		response_beautified_id = NULL WHERE id = ?`, bodies.request, bodies.requestBlob, bodies.response, bodies.responseBlob, logID)
+	if err != nil {
+		return fmt.Errorf("updating bodies of log entry %d: %w", logID, err)
+	}
+	return nil
+}
+
+// MoveTrafficBodiesToBlobs moves the inline bodies large enough to be blobs of up to limit log
+// entries after afterID into blobs, for entries captured before bodies were deduplicated. It
+// returns the last entry ID examined (0 when none is left), the number of entries changed and
+// the inline bytes moved.
+func MoveTrafficBodiesToBlobs(afterID int64, limit int) (lastID int64, moved int, bytes int64, err error) {
+	rows, err := DB.Query(`SELECT id, request_body, response_body FROM http_traffic_log
+		WHERE id > ? AND (LENGTH(request_body) >= ? OR LENGTH(response_body) >= ?)
+		ORDER BY id LIMIT ?`, afterID, bodyBlobMinBytes, bodyBlobMinBytes, limit)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("querying inline bodies: %w", err)
+	}
+	type entry struct {
+		id                int64
+		request, response []byte
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.request, &e.response); err != nil {
+			rows.Close()
+			return 0, 0, 0, fmt.Errorf("scanning inline bodies: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(entries) == 0 {
+		return 0, 0, 0, err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("starting body transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, e := range entries {
+		// Bodies too small for a blob stay inline; only their blob ID is cleared, which it is already.
+		bodies, err := storeTrafficBodies(tx, e.request, e.response)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		_, err = tx.Exec(`UPDATE http_traffic_log SET request_body = ?, request_body_blob_id = COALESCE(?, request_body_blob_id),
+			response_body = ?, response_body_blob_id = COALESCE(?, response_body_blob_id) WHERE id = ?`,
+			bodies.request, bodies.requestBlob, bodies.response, bodies.responseBlob, e.id)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("moving bodies of log entry %d: %w", e.id, err)
+		}
+		bytes += int64(len(e.request) - len(bodies.request) + len(e.response) - len(bodies.response))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, 0, fmt.Errorf("committing body blobs: %w", err)
+	}
+	return entries[len(entries)-1].id, len(entries), bytes, nil
+}
+
+// GetBodyBlobStats summarizes how the traffic log bodies are stored.
+func GetBodyBlobStats() (models.BodyBlobStats, error) {
+	var s models.BodyBlobStats
+	err := DB.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(ref_count), 0),
+			COALESCE(SUM(size * (ref_count - 1)), 0) FROM body_blobs`).Scan(&s.Blobs, &s.BlobBytes, &s.References, &s.SavedBytes)
+	if err != nil {
+		return s, fmt.Errorf("summarizing body blobs: %w", err)
+	}
+	err = DB.QueryRow(`SELECT COUNT(*), COALESCE(SUM(LENGTH(request_body)), 0) + COALESCE(SUM(LENGTH(response_body)), 0)
+		FROM http_traffic_log WHERE request_body IS NOT NULL OR response_body IS NOT NULL`).Scan(&s.InlineEntries, &s.InlineBytes)
+	if err != nil {
+		return s, fmt.Errorf("summarizing inline bodies: %w", err)
+	}
+	err = DB.QueryRow(`SELECT COUNT(*) FROM http_traffic_log WHERE LENGTH(request_body) >= ? OR LENGTH(response_body) >= ?`,
+		bodyBlobMinBytes, bodyBlobMinBytes).Scan(&s.MovableEntries)
+	if err != nil {
+		return s, fmt.Errorf("counting inline bodies: %w", err)
+	}
+	return s, nil
+}
+
+// Vacuum rebuilds the database file, returning the space freed by deleted rows to the file system.
+func Vacuum() error {
+	if _, err := DB.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+	return nil
+}
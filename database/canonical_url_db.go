@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"toolkit/models"
+)
+
+// RecordCanonicalURLs adds URLs seen from source to the target's canonical URLs. URLs already
+// there get the source added and their last-seen time updated. It returns how many were new.
+func RecordCanonicalURLs(targetID int64, source string, urls []models.CanonicalURLEntry) (int, error) {
+	if len(urls) == 0 {
+		return 0, nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting canonical URL transaction: %w", err)
+	}
+	defer tx.Rollback()
+	added, err := recordCanonicalURLs(tx, targetID, source, urls)
+	if err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+func recordCanonicalURLs(tx *sql.Tx, targetID int64, source string, urls []models.CanonicalURLEntry) (int, error) {
+	exists, err := tx.Prepare(`SELECT COUNT(*) FROM canonical_urls WHERE target_id = ? AND canonical_url = ?`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing canonical URL lookup: %w", err)
+	}
+	defer exists.Close()
+	stmt, err := tx.Prepare(`INSERT INTO canonical_urls (target_id, canonical_url, example_url, sources, is_in_scope) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(target_id, canonical_url) DO UPDATE SET last_seen_at = CURRENT_TIMESTAMP, is_in_scope = excluded.is_in_scope,
+			sources = CASE WHEN instr(',' || sources || ',', ',' || excluded.sources || ',') > 0 THEN sources
+				WHEN sources = '' THEN excluded.sources ELSE sources || ',' || excluded.sources END`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing canonical URL insert: %w", err)
+	}
+	defer stmt.Close()
+	added := 0
+	for _, u := range urls {
+		if u.CanonicalURL == "" {
+			continue
+		}
+		var n int
+		if err := exists.QueryRow(targetID, u.CanonicalURL).Scan(&n); err != nil {
+			return added, fmt.Errorf("looking up canonical URL '%s': %w", u.CanonicalURL, err)
+		}
+		if _, err := stmt.Exec(targetID, u.CanonicalURL, u.URL, source, u.IsInScope); err != nil {
+			return added, fmt.Errorf("recording canonical URL '%s': %w", u.CanonicalURL, err)
+		}
+		if n == 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// GetCanonicalURLs returns a page of a target's canonical URLs, sorted by URL, and how many match.
+func GetCanonicalURLs(filters models.CanonicalURLFilters) ([]models.CanonicalURL, int, error) {
+	where := "WHERE target_id = ?"
+	args := []interface{}{filters.TargetID}
+	if filters.Search != "" {
+		where += " AND canonical_url LIKE ?"
+		args = append(args, "%"+filters.Search+"%")
+	}
+	if filters.Source != "" {
+		where += " AND instr(',' || sources || ',', ',' || ? || ',') > 0"
+		args = append(args, filters.Source)
+	}
+	if filters.IsInScope != nil {
+		where += " AND is_in_scope = ?"
+		args = append(args, *filters.IsInScope)
+	}
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM canonical_urls "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting canonical URLs of target %d: %w", filters.TargetID, err)
+	}
+	query := `SELECT id, target_id, canonical_url, example_url, sources, is_in_scope, first_seen_at, last_seen_at
+		FROM canonical_urls ` + where + " ORDER BY canonical_url"
+	if filters.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filters.Limit, (max(filters.Page, 1)-1)*filters.Limit)
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing canonical URLs of target %d: %w", filters.TargetID, err)
+	}
+	defer rows.Close()
+	urls := []models.CanonicalURL{}
+	for rows.Next() {
+		var u models.CanonicalURL
+		var sources string
+		if err := rows.Scan(&u.ID, &u.TargetID, &u.CanonicalURL, &u.ExampleURL, &sources, &u.IsInScope, &u.FirstSeenAt, &u.LastSeenAt); err != nil {
+			return nil, 0, fmt.Errorf("scanning canonical URL: %w", err)
+		}
+		u.Sources = splitCanonicalSources(sources)
+		urls = append(urls, u)
+	}
+	return urls, total, rows.Err()
+}
+
+// CountDiscoveredURLs returns how many discovered URL rows a target has and how many unique
+// canonical URLs.
+func CountDiscoveredURLs(targetID int64) (int64, int64, error) {
+	var discovered, unique int64
+	err := DB.QueryRow(`SELECT (SELECT COUNT(*) FROM discovered_urls WHERE target_id = ?1),
+		(SELECT COUNT(*) FROM canonical_urls WHERE target_id = ?1)`, targetID).Scan(&discovered, &unique)
+	if err != nil {
+		return 0, 0, fmt.Errorf("counting discovered URLs of target %d: %w", targetID, err)
+	}
+	return discovered, unique, nil
+}
+
+// GetDiscoveredURLsForTarget returns every discovered URL of a target with the URL of the response
+// it was found in.
+func GetDiscoveredURLsForTarget(targetID int64) ([]models.DiscoveredURL, error) {
+	rows, err := DB.Query(`SELECT du.id, du.http_traffic_log_id, du.url, COALESCE(du.canonical_url, ''), du.source_type,
+			COALESCE(l.request_url, ''), du.discovered_at
+		FROM discovered_urls du LEFT JOIN http_traffic_log l ON l.id = du.http_traffic_log_id
+		WHERE du.target_id = ? ORDER BY du.id`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("listing discovered URLs of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	var urls []models.DiscoveredURL
+	for rows.Next() {
+		du := models.DiscoveredURL{TargetID: &targetID}
+		if err := rows.Scan(&du.ID, &du.HTTPTrafficLogID, &du.URL, &du.CanonicalURL, &du.SourceType, &du.SourceURL, &du.DiscoveredAt); err != nil {
+			return nil, fmt.Errorf("scanning discovered URL: %w", err)
+		}
+		urls = append(urls, du)
+	}
+	return urls, rows.Err()
+}
+
+// GetParameterizedURLExamples returns the example URLs of a target's parameterized URLs.
+func GetParameterizedURLExamples(targetID int64) ([]string, error) {
+	rows, err := DB.Query(`SELECT example_full_url FROM parameterized_urls WHERE target_id = ? AND example_full_url IS NOT NULL`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("listing parameterized URLs of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, fmt.Errorf("scanning parameterized URL: %w", err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// ReplaceCanonicalURLs stores recomputed canonical forms: discovered maps discovered URL IDs to
+// their canonical URL, and urls holds every URL of the target by source. Canonical URLs not in
+// urls are removed; the others keep their first-seen time. It returns how many are stored.
+func ReplaceCanonicalURLs(targetID int64, discovered map[int64]string, urls map[string][]models.CanonicalURLEntry) (int, error) {
+	type canonical struct {
+		example   string
+		sources   []string
+		isInScope bool
+	}
+	merged := map[string]*canonical{}
+	sourceNames := make([]string, 0, len(urls))
+	for source := range urls {
+		sourceNames = append(sourceNames, source)
+	}
+	sort.Strings(sourceNames)
+	for _, source := range sourceNames {
+		for _, u := range urls[source] {
+			if u.CanonicalURL == "" {
+				continue
+			}
+			c, ok := merged[u.CanonicalURL]
+			if !ok {
+				c = &canonical{example: u.URL, isInScope: u.IsInScope}
+				merged[u.CanonicalURL] = c
+			}
+			if len(c.sources) == 0 || c.sources[len(c.sources)-1] != source {
+				c.sources = append(c.sources, source)
+			}
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting canonical URL transaction: %w", err)
+	}
+	defer tx.Rollback()
+	update, err := tx.Prepare(`UPDATE discovered_urls SET canonical_url = ? WHERE id = ?`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing discovered URL update: %w", err)
+	}
+	defer update.Close()
+	for id, canonicalURL := range discovered {
+		if _, err := update.Exec(canonicalURL, id); err != nil {
+			return 0, fmt.Errorf("updating discovered URL %d: %w", id, err)
+		}
+	}
+
+	rows, err := tx.Query(`SELECT id, canonical_url FROM canonical_urls WHERE target_id = ?`, targetID)
+	if err != nil {
+		return 0, fmt.Errorf("listing canonical URLs of target %d: %w", targetID, err)
+	}
+	var stale []int64
+	for rows.Next() {
+		var id int64
+		var u string
+		if err := rows.Scan(&id, &u); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning canonical URL: %w", err)
+		}
+		if merged[u] == nil {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	for _, id := range stale {
+		if _, err := tx.Exec(`DELETE FROM canonical_urls WHERE id = ?`, id); err != nil {
+			return 0, fmt.Errorf("deleting canonical URL %d: %w", id, err)
+		}
+	}
+
+	upsert, err := tx.Prepare(`INSERT INTO canonical_urls (target_id, canonical_url, example_url, sources, is_in_scope) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(target_id, canonical_url) DO UPDATE SET sources = excluded.sources, is_in_scope = excluded.is_in_scope`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing canonical URL insert: %w", err)
+	}
+	defer upsert.Close()
+	for u, c := range merged {
+		if _, err := upsert.Exec(targetID, u, c.example, strings.Join(c.sources, ","), c.isInScope); err != nil {
+			return 0, fmt.Errorf("storing canonical URL '%s': %w", u, err)
+		}
+	}
+	return len(merged), tx.Commit()
+}
+
+func splitCanonicalSources(sources string) []string {
+	if sources == "" {
+		return []string{}
+	}
+	return strings.Split(sources, ",")
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+const captureSessionColumns = `id, target_id, name, notes, started_at, ended_at`
+
+// captureSessionCounts counts the requests within the window of the capture session aliased cs,
+// all of them and the user's.
+var captureSessionCounts = func() string {
+	manual, _ := TrafficOriginCondition("h.log_source", models.TrafficOriginManual)
+	return `(SELECT COUNT(*) FROM http_traffic_log h WHERE ` + captureSessionWindow("h", "cs") + `),
+	(SELECT COUNT(*) FROM http_traffic_log h WHERE ` + captureSessionWindow("h", "cs") + ` AND ` + manual + `)`
+}()
+
+// captureSessionWindow matches the log entries (aliased log) logged for the target of a capture
+// session (aliased session) while it ran, or since it started while it runs.
+func captureSessionWindow(log, session string) string {
+	return log + `.target_id = ` + session + `.target_id AND julianday(` + log + `.timestamp) >= julianday(` + session + `.started_at) AND (` +
+		session + `.ended_at IS NULL OR julianday(` + log + `.timestamp) <= julianday(` + session + `.ended_at))`
+}
+
+// CreateCaptureSession stores a running capture session and returns its ID. It fails when the
+// target already has a running session.
+func CreateCaptureSession(s models.CaptureSession) (int64, error) {
+	result, err := DB.Exec(`INSERT INTO capture_sessions (target_id, name, notes, started_at) VALUES (?, ?, ?, ?)`,
+		s.TargetID, s.Name, s.Notes, s.StartedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("starting capture session for target %d: %w", s.TargetID, err)
+	}
+	return result.LastInsertId()
+}
+
+// GetCaptureSession returns a capture session with its request counts.
+func GetCaptureSession(id int64) (models.CaptureSession, error) {
+	s, err := scanCaptureSession(DB.QueryRow(`SELECT `+captureSessionColumns+`, `+captureSessionCounts+`
+		FROM capture_sessions cs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("capture session with ID %d not found", id)
+	}
+	if err != nil {
+		return s, fmt.Errorf("loading capture session %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// GetRunningCaptureSession returns the running capture session of a target. found is false when
+// there is none.
+func GetRunningCaptureSession(targetID int64) (s models.CaptureSession, found bool, err error) {
+	s, err = scanCaptureSession(DB.QueryRow(`SELECT `+captureSessionColumns+`, `+captureSessionCounts+`
+		FROM capture_sessions cs WHERE target_id = ? AND ended_at IS NULL`, targetID))
+	if err == sql.ErrNoRows {
+		return s, false, nil
+	}
+	if err != nil {
+		return s, false, fmt.Errorf("loading running capture session of target %d: %w", targetID, err)
+	}
+	return s, true, nil
+}
+
+// ListCaptureSessions returns the capture sessions of a target with their request counts, latest
+// first. limit 0 means no limit.
+func ListCaptureSessions(targetID int64, limit int) ([]models.CaptureSession, error) {
+	query := `SELECT ` + captureSessionColumns + `, ` + captureSessionCounts + `
+		FROM capture_sessions cs WHERE target_id = ? ORDER BY started_at DESC, id DESC`
+	args := []interface{}{targetID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing capture sessions of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	sessions := []models.CaptureSession{}
+	for rows.Next() {
+		s, err := scanCaptureSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning capture session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// EndCaptureSession ends a running capture session. It fails when the session is not running.
+func EndCaptureSession(s models.CaptureSession) error {
+	result, err := DB.Exec(`UPDATE capture_sessions SET ended_at = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND ended_at IS NULL`, nullableTime(s.EndedAt), s.Notes, s.ID)
+	if err != nil {
+		return fmt.Errorf("ending capture session %d: %w", s.ID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("capture session %d is not running", s.ID)
+	}
+	return nil
+}
+
+// UpdateCaptureSession changes the name and notes of a capture session.
+func UpdateCaptureSession(s models.CaptureSession) error {
+	result, err := DB.Exec(`UPDATE capture_sessions SET name = ?, notes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		s.Name, s.Notes, s.ID)
+	if err != nil {
+		return fmt.Errorf("updating capture session %d: %w", s.ID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("capture session with ID %d not found", s.ID)
+	}
+	return nil
+}
+
+// DeleteCaptureSession deletes a capture session. Its traffic is kept.
+func DeleteCaptureSession(id int64) error {
+	result, err := DB.Exec(`DELETE FROM capture_sessions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting capture session %d: %w", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("capture session with ID %d not found", id)
+	}
+	return nil
+}
+
+func scanCaptureSession(row interface{ Scan(...any) error }) (models.CaptureSession, error) {
+	var s models.CaptureSession
+	var endedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.TargetID, &s.Name, &s.Notes, &s.StartedAt, &endedAt, &s.Requests, &s.ManualRequests)
+	if err != nil {
+		return s, err
+	}
+	if endedAt.Valid {
+		s.EndedAt = &endedAt.Time
+	}
+	s.Running = s.EndedAt == nil
+	return s, nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+// GetChecklistProgressItems returns the checklist items of a target with the template they were
+// copied from and when they were added and completed, oldest first.
+func GetChecklistProgressItems(targetID int64) ([]models.ChecklistProgressItem, error) {
+	rows, err := DB.Query(`SELECT tci.id, tci.item_text, tci.notes, tci.is_completed, tci.template_id, ct.name, tci.created_at, tci.completed_at
+		FROM target_checklist_items tci LEFT JOIN checklist_templates ct ON ct.id = tci.template_id
+		WHERE tci.target_id = ? ORDER BY tci.created_at, tci.id`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying checklist items of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	var items []models.ChecklistProgressItem
+	for rows.Next() {
+		var item models.ChecklistProgressItem
+		if err := rows.Scan(&item.ID, &item.ItemText, &item.Notes, &item.IsCompleted, &item.TemplateID, &item.TemplateName,
+			&item.CreatedAt, &item.CompletedAt); err != nil {
+			return nil, fmt.Errorf("scanning checklist item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetChecklistEvidenceLogIDs returns the requests backing the checklist items of a target, by
+// item ID: those linked as evidence and those of the findings linked as evidence.
+func GetChecklistEvidenceLogIDs(targetID int64) (map[int64][]int64, error) {
+	rows, err := DB.Query(`SELECT ev.checklist_item_id, COALESCE(ev.http_traffic_log_id, tf.http_traffic_log_id)
+		FROM checklist_item_evidence ev
+		JOIN target_checklist_items tci ON tci.id = ev.checklist_item_id
+		LEFT JOIN target_findings tf ON tf.id = ev.finding_id
+		WHERE tci.target_id = ? AND COALESCE(ev.http_traffic_log_id, tf.http_traffic_log_id) IS NOT NULL`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying checklist evidence of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	logIDs := map[int64][]int64{}
+	for rows.Next() {
+		var itemID, logID int64
+		if err := rows.Scan(&itemID, &logID); err != nil {
+			return nil, fmt.Errorf("scanning checklist evidence: %w", err)
+		}
+		logIDs[itemID] = append(logIDs[itemID], logID)
+	}
+	return logIDs, rows.Err()
+}
+
+// GetTargetTrafficLogIDsByTag returns the log entries of a target that have tags, by lower-cased
+// tag name.
+func GetTargetTrafficLogIDsByTag(targetID int64) (map[string][]int64, error) {
+	rows, err := DB.Query(`SELECT t.name, ta.item_id FROM tag_associations ta
+		JOIN tags t ON t.id = ta.tag_id
+		JOIN http_traffic_log htl ON htl.id = ta.item_id
+		WHERE ta.item_type = 'httplog' AND htl.target_id = ?`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying tagged traffic of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	logIDs := map[string][]int64{}
+	for rows.Next() {
+		var name string
+		var logID int64
+		if err := rows.Scan(&name, &logID); err != nil {
+			return nil, fmt.Errorf("scanning tagged traffic: %w", err)
+		}
+		name = strings.ToLower(name)
+		logIDs[name] = append(logIDs[name], logID)
+	}
+	return logIDs, rows.Err()
+}
+
+// ForEachTargetRequestLine calls fn for every log entry of a target, in ID order, with only the
+// ID, method, URL and response Content-Type loaded.
+func ForEachTargetRequestLine(targetID int64, fn func(models.HTTPTrafficLog)) error {
+	rows, err := DB.Query(`SELECT id, request_method, request_url, response_content_type
+		FROM http_traffic_log WHERE target_id = ? ORDER BY id`, targetID)
+	if err != nil {
+		return fmt.Errorf("querying requests of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		if err := rows.Scan(&e.ID, &e.RequestMethod, &e.RequestURL, &e.ResponseContentType); err != nil {
+			return fmt.Errorf("scanning request: %w", err)
+		}
+		fn(e)
+	}
+	return rows.Err()
+}
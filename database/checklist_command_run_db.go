@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+const checklistCommandRunColumns = `id, checklist_item_id, command, status, exit_code, IFNULL(output, ''), output_truncated, error_message,
+	started_at, finished_at`
+
+func scanChecklistCommandRun(scanner interface{ Scan(...interface{}) error }) (models.ChecklistCommandRun, error) {
+	var run models.ChecklistCommandRun
+	err := scanner.Scan(&run.ID, &run.ChecklistItemID, &run.Command, &run.Status, &run.ExitCode, &run.Output, &run.OutputTruncated,
+		&run.ErrorMessage, &run.StartedAt, &run.FinishedAt)
+	return run, err
+}
+
+// CreateChecklistCommandRun records the start of a checklist command and returns the run ID.
+func CreateChecklistCommandRun(itemID int64, command string) (int64, error) {
+	res, err := DB.Exec(`INSERT INTO checklist_command_runs (checklist_item_id, command, status) VALUES (?, ?, ?)`,
+		itemID, command, models.CommandRunStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("inserting checklist command run: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// FinishChecklistCommandRun stores the outcome and output of a checklist command run.
+func FinishChecklistCommandRun(run models.ChecklistCommandRun) error {
+	_, err := DB.Exec(`UPDATE checklist_command_runs SET status = ?, exit_code = ?, output = ?, output_truncated = ?, error_message = ?,
+		finished_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		run.Status, run.ExitCode, run.Output, run.OutputTruncated, run.ErrorMessage, run.ID)
+	if err != nil {
+		return fmt.Errorf("updating checklist command run %d: %w", run.ID, err)
+	}
+	return nil
+}
+
+// GetChecklistCommandRunByID fetches a checklist command run including its output.
+func GetChecklistCommandRunByID(id int64) (models.ChecklistCommandRun, error) {
+	run, err := scanChecklistCommandRun(DB.QueryRow(`SELECT `+checklistCommandRunColumns+` FROM checklist_command_runs WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return run, fmt.Errorf("command run with ID %d not found", id)
+		}
+		return run, fmt.Errorf("scanning checklist command run %d: %w", id, err)
+	}
+	return run, nil
+}
+
+// GetChecklistCommandRuns lists the command runs of a checklist item, newest first.
+func GetChecklistCommandRuns(itemID int64) ([]models.ChecklistCommandRun, error) {
+	rows, err := DB.Query(`SELECT `+checklistCommandRunColumns+` FROM checklist_command_runs WHERE checklist_item_id = ?
+		ORDER BY started_at DESC, id DESC`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("querying command runs for checklist item %d: %w", itemID, err)
+	}
+	defer rows.Close()
+
+	runs := []models.ChecklistCommandRun{}
+	for rows.Next() {
+		run, err := scanChecklistCommandRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning checklist command run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// SetChecklistItemCompleted marks a checklist item as completed (or not).
+func SetChecklistItemCompleted(itemID int64, completed bool) error {
+	_, err := DB.Exec(`UPDATE target_checklist_items SET is_completed = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, completed, itemID)
+	if err != nil {
+		return fmt.Errorf("updating completion of checklist item %d: %w", itemID, err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const checklistEvidenceColumns = `ev.id, ev.checklist_item_id, ev.evidence_type, ev.http_traffic_log_id, ev.finding_id, ev.screenshot_path, ev.caption,
+	COALESCE(CASE ev.evidence_type
+		WHEN 'httplog' THEN (SELECT request_method || ' ' || request_url || ' -> ' || IFNULL(response_status_code, '?') FROM http_traffic_log WHERE id = ev.http_traffic_log_id)
+		WHEN 'finding' THEN (SELECT title || IFNULL(' (' || severity || ')', '') FROM target_findings WHERE id = ev.finding_id)
+		ELSE ev.screenshot_path END, ''),
+	ev.created_at`
+
+func scanChecklistEvidence(scanner interface{ Scan(...interface{}) error }) (models.ChecklistItemEvidence, error) {
+	var e models.ChecklistItemEvidence
+	err := scanner.Scan(&e.ID, &e.ChecklistItemID, &e.EvidenceType, &e.HTTPTrafficLogID, &e.FindingID, &e.ScreenshotPath, &e.Caption,
+		&e.Label, &e.CreatedAt)
+	return e, err
+}
+
+// checkEvidenceTarget verifies that a referenced log or finding exists and belongs to the checklist item's target.
+// Logs captured without a target are accepted.
+func checkEvidenceTarget(table, entity string, id int64, itemTargetID int64) error {
+	var targetID sql.NullInt64
+	if err := DB.QueryRow(`SELECT target_id FROM `+table+` WHERE id = ?`, id).Scan(&targetID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s with ID %d not found", entity, id)
+		}
+		return fmt.Errorf("looking up %s %d: %w", entity, id, err)
+	}
+	if targetID.Valid && targetID.Int64 != itemTargetID {
+		return fmt.Errorf("%s %d belongs to target %d, not the checklist item's target %d", entity, id, targetID.Int64, itemTargetID)
+	}
+	return nil
+}
+
+// AddChecklistItemEvidence links evidence to a checklist item and returns the link ID.
+func AddChecklistItemEvidence(itemID int64, req models.ChecklistEvidenceRequest) (int64, error) {
+	item, err := GetChecklistItemByID(itemID)
+	if err != nil {
+		return 0, err
+	}
+
+	evidence := models.ChecklistItemEvidence{ChecklistItemID: itemID, EvidenceType: req.EvidenceType, Caption: models.NullString(req.Caption)}
+	switch req.EvidenceType {
+	case models.ChecklistEvidenceHTTPLog:
+		if err := checkEvidenceTarget("http_traffic_log", "traffic log", req.HTTPTrafficLogID, item.TargetID); err != nil {
+			return 0, err
+		}
+		evidence.HTTPTrafficLogID = sql.NullInt64{Int64: req.HTTPTrafficLogID, Valid: true}
+	case models.ChecklistEvidenceFinding:
+		if err := checkEvidenceTarget("target_findings", "finding", req.FindingID, item.TargetID); err != nil {
+			return 0, err
+		}
+		evidence.FindingID = sql.NullInt64{Int64: req.FindingID, Valid: true}
+	case models.ChecklistEvidenceScreenshot:
+		if strings.TrimSpace(req.ScreenshotPath) == "" {
+			return 0, fmt.Errorf("screenshot_path is required for screenshot evidence")
+		}
+		evidence.ScreenshotPath = models.NullString(strings.TrimSpace(req.ScreenshotPath))
+	default:
+		return 0, fmt.Errorf("invalid evidence_type '%s', expected '%s', '%s' or '%s'", req.EvidenceType,
+			models.ChecklistEvidenceHTTPLog, models.ChecklistEvidenceFinding, models.ChecklistEvidenceScreenshot)
+	}
+
+	res, err := DB.Exec(`INSERT INTO checklist_item_evidence (checklist_item_id, evidence_type, http_traffic_log_id, finding_id, screenshot_path, caption)
+		VALUES (?, ?, ?, ?, ?, ?)`, evidence.ChecklistItemID, evidence.EvidenceType, evidence.HTTPTrafficLogID, evidence.FindingID,
+		evidence.ScreenshotPath, evidence.Caption)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("this evidence is already linked to checklist item %d", itemID)
+		}
+		return 0, fmt.Errorf("inserting checklist evidence: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetChecklistItemEvidenceByID fetches a single evidence link.
+func GetChecklistItemEvidenceByID(id int64) (models.ChecklistItemEvidence, error) {
+	e, err := scanChecklistEvidence(DB.QueryRow(`SELECT `+checklistEvidenceColumns+` FROM checklist_item_evidence ev WHERE ev.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return e, fmt.Errorf("checklist evidence with ID %d not found", id)
+		}
+		return e, fmt.Errorf("scanning checklist evidence %d: %w", id, err)
+	}
+	return e, nil
+}
+
+// GetChecklistItemEvidence lists the evidence linked to a checklist item, oldest first.
+func GetChecklistItemEvidence(itemID int64) ([]models.ChecklistItemEvidence, error) {
+	return queryChecklistEvidence(`WHERE ev.checklist_item_id = ?`, itemID)
+}
+
+// DeleteChecklistItemEvidence unlinks evidence from a checklist item.
+func DeleteChecklistItemEvidence(itemID, evidenceID int64) error {
+	res, err := DB.Exec(`DELETE FROM checklist_item_evidence WHERE id = ? AND checklist_item_id = ?`, evidenceID, itemID)
+	if err != nil {
+		return fmt.Errorf("deleting checklist evidence %d: %w", evidenceID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("checklist evidence with ID %d not found for item %d", evidenceID, itemID)
+	}
+	return nil
+}
+
+// GetChecklistReport returns all checklist items of a target with their linked evidence.
+func GetChecklistReport(targetID int64) ([]models.ChecklistReportItem, error) {
+	items, err := GetChecklistItemsByTargetID(targetID)
+	if err != nil {
+		return nil, err
+	}
+	evidence, err := queryChecklistEvidence(`JOIN target_checklist_items ci ON ci.id = ev.checklist_item_id WHERE ci.target_id = ?`, targetID)
+	if err != nil {
+		return nil, err
+	}
+	byItem := make(map[int64][]models.ChecklistItemEvidence)
+	for _, e := range evidence {
+		byItem[e.ChecklistItemID] = append(byItem[e.ChecklistItemID], e)
+	}
+
+	report := make([]models.ChecklistReportItem, 0, len(items))
+	for _, item := range items {
+		itemEvidence := byItem[item.ID]
+		if itemEvidence == nil {
+			itemEvidence = []models.ChecklistItemEvidence{}
+		}
+		report = append(report, models.ChecklistReportItem{TargetChecklistItem: item, Evidence: itemEvidence})
+	}
+	return report, nil
+}
+
+func queryChecklistEvidence(clause string, args ...interface{}) ([]models.ChecklistItemEvidence, error) {
+	rows, err := DB.Query(`SELECT `+checklistEvidenceColumns+` FROM checklist_item_evidence ev `+clause+` ORDER BY ev.created_at ASC, ev.id ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying checklist evidence: %w", err)
+	}
+	defer rows.Close()
+
+	evidence := []models.ChecklistItemEvidence{}
+	for rows.Next() {
+		e, err := scanChecklistEvidence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning checklist evidence: %w", err)
+		}
+		evidence = append(evidence, e)
+	}
+	return evidence, rows.Err()
+}
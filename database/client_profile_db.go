@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"toolkit/models"
+)
+
+// GetTargetClientProfile returns the client profile of a target, or nil when it has none.
+func GetTargetClientProfile(targetID int64) (*models.TargetClientProfile, error) {
+	p := models.TargetClientProfile{TargetID: targetID}
+	var headers string
+	err := DB.QueryRow(`SELECT user_agent, headers, cookies, updated_at FROM target_client_profiles WHERE target_id = ?`, targetID).
+		Scan(&p.UserAgent, &headers, &p.Cookies, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading client profile of target %d: %w", targetID, err)
+	}
+	json.Unmarshal([]byte(headers), &p.Headers)
+	if p.Headers == nil {
+		p.Headers = map[string]string{}
+	}
+	return &p, nil
+}
+
+// SaveTargetClientProfile stores the client profile of a target.
+func SaveTargetClientProfile(p models.TargetClientProfile) error {
+	if p.Headers == nil {
+		p.Headers = map[string]string{}
+	}
+	headers, _ := json.Marshal(p.Headers)
+	_, err := DB.Exec(`INSERT INTO target_client_profiles (target_id, user_agent, headers, cookies, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(target_id) DO UPDATE SET user_agent = excluded.user_agent, headers = excluded.headers,
+			cookies = excluded.cookies, updated_at = CURRENT_TIMESTAMP`,
+		p.TargetID, p.UserAgent, string(headers), p.Cookies)
+	if err != nil {
+		return fmt.Errorf("saving client profile of target %d: %w", p.TargetID, err)
+	}
+	return nil
+}
+
+// DeleteTargetClientProfile removes the client profile of a target.
+func DeleteTargetClientProfile(targetID int64) error {
+	result, err := DB.Exec(`DELETE FROM target_client_profiles WHERE target_id = ?`, targetID)
+	if err != nil {
+		return fmt.Errorf("deleting client profile of target %d: %w", targetID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("client profile of target %d not found", targetID)
+	}
+	return nil
+}
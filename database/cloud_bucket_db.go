@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+const cloudBucketColumns = `id, target_id, provider, name, url, source, source_log_id, status, public_write, details,
+	evidence_log_id, first_seen_at, checked_at`
+
+func scanCloudBucket(scanner interface{ Scan(...interface{}) error }) (models.CloudBucket, error) {
+	var bucket models.CloudBucket
+	var sourceLogID, evidenceLogID sql.NullInt64
+	var publicWrite sql.NullBool
+	var checkedAt sql.NullTime
+	err := scanner.Scan(&bucket.ID, &bucket.TargetID, &bucket.Provider, &bucket.Name, &bucket.URL, &bucket.Source, &sourceLogID,
+		&bucket.Status, &publicWrite, &bucket.Details, &evidenceLogID, &bucket.FirstSeenAt, &checkedAt)
+	if sourceLogID.Valid {
+		bucket.SourceLogID = &sourceLogID.Int64
+	}
+	if publicWrite.Valid {
+		bucket.PublicWrite = &publicWrite.Bool
+	}
+	if evidenceLogID.Valid {
+		bucket.EvidenceLogID = &evidenceLogID.Int64
+	}
+	if checkedAt.Valid {
+		bucket.CheckedAt = &checkedAt.Time
+	}
+	return bucket, err
+}
+
+// UpsertCloudBucket stores a bucket of a target. A known bucket keeps its row; a bucket first
+// stored as a candidate takes the source of a later reference in traffic or JavaScript. It returns
+// the bucket ID and whether the bucket is new.
+func UpsertCloudBucket(bucket models.CloudBucket) (int64, bool, error) {
+	var id int64
+	var source string
+	err := DB.QueryRow(`SELECT id, source FROM cloud_buckets WHERE target_id = ? AND provider = ? AND name = ?`,
+		bucket.TargetID, bucket.Provider, bucket.Name).Scan(&id, &source)
+	if err == nil {
+		if source == models.CloudBucketSourceCandidate && bucket.Source != models.CloudBucketSourceCandidate {
+			if _, err := DB.Exec(`UPDATE cloud_buckets SET source = ?, source_log_id = ? WHERE id = ?`,
+				bucket.Source, bucket.SourceLogID, id); err != nil {
+				return id, false, fmt.Errorf("updating cloud bucket %d: %w", id, err)
+			}
+		}
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("looking up %s bucket '%s' of target %d: %w", bucket.Provider, bucket.Name, bucket.TargetID, err)
+	}
+	res, err := DB.Exec(`INSERT INTO cloud_buckets (target_id, provider, name, url, source, source_log_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		bucket.TargetID, bucket.Provider, bucket.Name, bucket.URL, bucket.Source, bucket.SourceLogID)
+	if err != nil {
+		return 0, false, fmt.Errorf("inserting %s bucket '%s' of target %d: %w", bucket.Provider, bucket.Name, bucket.TargetID, err)
+	}
+	id, err = res.LastInsertId()
+	return id, true, err
+}
+
+// UpdateCloudBucketCheck records the outcome of checking a bucket.
+func UpdateCloudBucketCheck(bucket models.CloudBucket) error {
+	var publicWrite sql.NullBool
+	if bucket.PublicWrite != nil {
+		publicWrite = sql.NullBool{Bool: *bucket.PublicWrite, Valid: true}
+	}
+	_, err := DB.Exec(`UPDATE cloud_buckets SET url = ?, status = ?, public_write = ?, details = ?, evidence_log_id = ?, checked_at = ? WHERE id = ?`,
+		bucket.URL, bucket.Status, publicWrite, bucket.Details, bucket.EvidenceLogID, time.Now().UTC(), bucket.ID)
+	if err != nil {
+		return fmt.Errorf("updating cloud bucket %d: %w", bucket.ID, err)
+	}
+	return nil
+}
+
+// ListCloudBuckets lists the cloud buckets of a target matching the filters, exposed buckets first.
+func ListCloudBuckets(filters models.CloudBucketFilters) ([]models.CloudBucket, error) {
+	conditions := []string{"target_id = ?"}
+	args := []interface{}{filters.TargetID}
+	if filters.Provider != "" {
+		conditions = append(conditions, "provider = ?")
+		args = append(args, filters.Provider)
+	}
+	if filters.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filters.Status)
+	}
+	if filters.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, filters.Source)
+	}
+	if filters.ExistingOnly {
+		conditions = append(conditions, "status NOT IN (?, ?)")
+		args = append(args, models.CloudBucketStatusNotFound, models.CloudBucketStatusUnchecked)
+	}
+	rows, err := DB.Query(`SELECT `+cloudBucketColumns+` FROM cloud_buckets WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY IFNULL(public_write, 0) DESC, status = 'public_read' DESC, provider, name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying cloud buckets of target %d: %w", filters.TargetID, err)
+	}
+	defer rows.Close()
+	buckets := []models.CloudBucket{}
+	for rows.Next() {
+		bucket, err := scanCloudBucket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning cloud bucket row: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// cloudStorageLike matches the columns that mention a storage host of a known cloud provider.
+func cloudStorageLike(column string) string {
+	return fmt.Sprintf(`(%[1]s LIKE '%%amazonaws.com%%' OR %[1]s LIKE '%%s3://%%' OR %[1]s LIKE '%%storage.googleapis.com%%'
+		OR %[1]s LIKE '%%storage.cloud.google.com%%' OR %[1]s LIKE '%%gs://%%' OR %[1]s LIKE '%%blob.core.windows.net%%')`, column)
+}
+
+// GetCloudStorageReferences returns the captured URLs, JavaScript endpoints and analyzer results of
+// a target that mention cloud storage hosts. The cloud storage checker's own requests are left out.
+func GetCloudStorageReferences(targetID int64) ([]models.CloudStorageReference, error) {
+	rows, err := DB.Query(`
+		SELECT 'traffic', id, request_url FROM http_traffic_log
+			WHERE target_id = ? AND IFNULL(log_source, '') != ? AND `+cloudStorageLike("request_url")+`
+		UNION ALL
+		SELECT CASE WHEN u.source_type = 'jsluice' THEN 'js' ELSE 'traffic' END, u.http_traffic_log_id, u.url FROM discovered_urls u
+			WHERE u.target_id = ? AND `+cloudStorageLike("u.url")+`
+		UNION ALL
+		SELECT 'js', IFNULL(v.http_traffic_log_id, 0), e.value || ' ' || IFNULL(e.context, '') FROM js_endpoints e
+			JOIN js_file_versions v ON v.id = e.js_file_version_id
+			WHERE e.target_id = ? AND (`+cloudStorageLike("e.value")+` OR `+cloudStorageLike("e.context")+`)
+		UNION ALL
+		SELECT CASE WHEN l.response_content_type LIKE '%javascript%' THEN 'js' ELSE 'traffic' END, r.http_log_id, r.result_data
+			FROM analysis_results r
+			JOIN http_traffic_log l ON l.id = r.http_log_id
+			WHERE l.target_id = ? AND IFNULL(l.log_source, '') != ? AND `+cloudStorageLike("r.result_data"),
+		targetID, models.CloudStorageLogSource, targetID, targetID, targetID, models.CloudStorageLogSource)
+	if err != nil {
+		return nil, fmt.Errorf("querying cloud storage references of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	var refs []models.CloudStorageReference
+	for rows.Next() {
+		var ref models.CloudStorageReference
+		var text sql.NullString
+		if err := rows.Scan(&ref.Source, &ref.LogID, &text); err != nil {
+			return nil, fmt.Errorf("scanning cloud storage reference row: %w", err)
+		}
+		ref.Text = text.String
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+const activityColumns = `id, user, action, entity_type, entity_id, target_id, method, path, route, status, created_at`
+
+// RecordActivity stores a mutation made through the API and returns its ID.
+func RecordActivity(a models.Activity) (int64, error) {
+	result, err := DB.Exec(`INSERT INTO activity_log (user, action, entity_type, entity_id, target_id, method, path, route, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.User, a.Action, nullString(a.EntityType), nullString(a.EntityID), a.TargetID, a.Method, a.Path, nullString(a.Route), a.Status)
+	if err != nil {
+		return 0, fmt.Errorf("recording activity: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// ListActivity returns the activity feed, newest first, or oldest first after f.AfterID.
+func ListActivity(f models.ActivityFilters) ([]models.Activity, error) {
+	var conds []string
+	var args []interface{}
+	if f.User != "" {
+		conds = append(conds, "user = ?")
+		args = append(args, f.User)
+	}
+	if f.EntityType != "" {
+		conds = append(conds, "entity_type = ?")
+		args = append(args, f.EntityType)
+	}
+	if f.EntityID != "" {
+		conds = append(conds, "entity_id = ?")
+		args = append(args, f.EntityID)
+	}
+	if f.TargetID > 0 {
+		conds = append(conds, "target_id = ?")
+		args = append(args, f.TargetID)
+	}
+	order := "id DESC"
+	if f.AfterID > 0 {
+		conds = append(conds, "id > ?")
+		args = append(args, f.AfterID)
+		order = "id ASC"
+	}
+	query := `SELECT ` + activityColumns + ` FROM activity_log`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY " + order + " LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying activity: %w", err)
+	}
+	defer rows.Close()
+	activity := []models.Activity{}
+	for rows.Next() {
+		var (
+			a                           models.Activity
+			entityType, entityID, route sql.NullString
+			targetID                    sql.NullInt64
+		)
+		if err := rows.Scan(&a.ID, &a.User, &a.Action, &entityType, &entityID, &targetID, &a.Method, &a.Path, &route, &a.Status, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning activity: %w", err)
+		}
+		a.EntityType, a.EntityID, a.Route = entityType.String, entityID.String, route.String
+		if targetID.Valid {
+			a.TargetID = &targetID.Int64
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+// LatestActivityID returns the ID of the latest activity, 0 when there is none.
+func LatestActivityID() (int64, error) {
+	var id sql.NullInt64
+	if err := DB.QueryRow(`SELECT MAX(id) FROM activity_log`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("querying latest activity: %w", err)
+	}
+	return id.Int64, nil
+}
+
+// PruneActivity removes the activity recorded before a time.
+func PruneActivity(before time.Time) (int64, error) {
+	result, err := DB.Exec(`DELETE FROM activity_log WHERE created_at < ?`, before.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, fmt.Errorf("pruning activity: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// TouchPresence records that a user views an entity, and forgets the viewers not seen since
+// expiredBefore.
+func TouchPresence(p models.Presence, expiredBefore time.Time) error {
+	if _, err := DB.Exec(`DELETE FROM presence WHERE seen_at < ?`, expiredBefore.UTC()); err != nil {
+		return fmt.Errorf("pruning presence: %w", err)
+	}
+	_, err := DB.Exec(`INSERT INTO presence (user, entity_type, entity_id, seen_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user, entity_type, entity_id) DO UPDATE SET seen_at = excluded.seen_at`,
+		p.User, p.EntityType, p.EntityID, p.SeenAt.UTC())
+	if err != nil {
+		return fmt.Errorf("recording presence: %w", err)
+	}
+	return nil
+}
+
+// RemovePresence records that a user no longer views an entity.
+func RemovePresence(user, entityType, entityID string) error {
+	if _, err := DB.Exec(`DELETE FROM presence WHERE user = ? AND entity_type = ? AND entity_id = ?`, user, entityType, entityID); err != nil {
+		return fmt.Errorf("removing presence: %w", err)
+	}
+	return nil
+}
+
+// ListPresence returns the viewers seen since a time, of an entity or (with an empty entityType)
+// of everything.
+func ListPresence(entityType, entityID string, since time.Time) ([]models.Presence, error) {
+	query := `SELECT user, entity_type, entity_id, seen_at FROM presence WHERE seen_at >= ?`
+	args := []interface{}{since.UTC()}
+	if entityType != "" {
+		query += " AND entity_type = ?"
+		args = append(args, entityType)
+	}
+	if entityID != "" {
+		query += " AND entity_id = ?"
+		args = append(args, entityID)
+	}
+	rows, err := DB.Query(query+" ORDER BY entity_type, entity_id, user", args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying presence: %w", err)
+	}
+	defer rows.Close()
+	presence := []models.Presence{}
+	for rows.Next() {
+		var p models.Presence
+		if err := rows.Scan(&p.User, &p.EntityType, &p.EntityID, &p.SeenAt); err != nil {
+			return nil, fmt.Errorf("scanning presence: %w", err)
+		}
+		presence = append(presence, p)
+	}
+	return presence, rows.Err()
+}
+
+// AcquireLock takes a lock or refreshes one the same user holds. When another user holds an
+// unexpired lock and force is not set, nothing changes and that lock is returned with held set.
+func AcquireLock(lock models.EntityLock, force bool) (current models.EntityLock, held bool, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return lock, false, fmt.Errorf("starting lock transaction: %w", err)
+	}
+	defer tx.Rollback()
+	existing, found, err := getLock(tx, lock.EntityType, lock.EntityID, lock.AcquiredAt)
+	if err != nil {
+		return lock, false, err
+	}
+	if found && existing.User != lock.User && !force {
+		return existing, true, nil
+	}
+	if found && existing.User == lock.User {
+		lock.AcquiredAt = existing.AcquiredAt
+	}
+	_, err = tx.Exec(`INSERT INTO entity_locks (entity_type, entity_id, user, acquired_at, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(entity_type, entity_id) DO UPDATE SET user = excluded.user, acquired_at = excluded.acquired_at, expires_at = excluded.expires_at`,
+		lock.EntityType, lock.EntityID, lock.User, lock.AcquiredAt.UTC(), lock.ExpiresAt.UTC())
+	if err != nil {
+		return lock, false, fmt.Errorf("saving lock on %s %s: %w", lock.EntityType, lock.EntityID, err)
+	}
+	return lock, false, tx.Commit()
+}
+
+// ReleaseLock removes the lock on an entity.
+func ReleaseLock(entityType, entityID string) error {
+	if _, err := DB.Exec(`DELETE FROM entity_locks WHERE entity_type = ? AND entity_id = ?`, entityType, entityID); err != nil {
+		return fmt.Errorf("releasing lock on %s %s: %w", entityType, entityID, err)
+	}
+	return nil
+}
+
+// GetLock returns the lock on an entity unless it expired by now.
+func GetLock(entityType, entityID string, now time.Time) (models.EntityLock, bool, error) {
+	return getLock(DB, entityType, entityID, now)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getLock(q queryRower, entityType, entityID string, now time.Time) (models.EntityLock, bool, error) {
+	var l models.EntityLock
+	err := q.QueryRow(`SELECT entity_type, entity_id, user, acquired_at, expires_at FROM entity_locks
+		WHERE entity_type = ? AND entity_id = ? AND expires_at > ?`, entityType, entityID, now.UTC()).
+		Scan(&l.EntityType, &l.EntityID, &l.User, &l.AcquiredAt, &l.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return l, false, nil
+	}
+	if err != nil {
+		return l, false, fmt.Errorf("looking up lock on %s %s: %w", entityType, entityID, err)
+	}
+	return l, true, nil
+}
+
+// ListLocks returns the locks that have not expired by now, removing the expired ones.
+func ListLocks(now time.Time) ([]models.EntityLock, error) {
+	if _, err := DB.Exec(`DELETE FROM entity_locks WHERE expires_at <= ?`, now.UTC()); err != nil {
+		return nil, fmt.Errorf("pruning locks: %w", err)
+	}
+	rows, err := DB.Query(`SELECT entity_type, entity_id, user, acquired_at, expires_at FROM entity_locks
+		ORDER BY entity_type, entity_id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying locks: %w", err)
+	}
+	defer rows.Close()
+	locks := []models.EntityLock{}
+	for rows.Next() {
+		var l models.EntityLock
+		if err := rows.Scan(&l.EntityType, &l.EntityID, &l.User, &l.AcquiredAt, &l.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scanning lock: %w", err)
+		}
+		locks = append(locks, l)
+	}
+	return locks, rows.Err()
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const contentTemplateColumns = `id, kind, name, title, content, summary, steps_to_reproduce, impact, recommendations, payload,
+	severity, cwe_id, vulnerability_type, created_at, updated_at`
+
+func scanContentTemplate(scanner interface{ Scan(...interface{}) error }) (models.ContentTemplate, error) {
+	var t models.ContentTemplate
+	var cweID sql.NullInt64
+	err := scanner.Scan(&t.ID, &t.Kind, &t.Name, &t.Title, &t.Content, &t.Summary, &t.StepsToReproduce, &t.Impact,
+		&t.Recommendations, &t.Payload, &t.Severity, &cweID, &t.VulnerabilityType, &t.CreatedAt, &t.UpdatedAt)
+	if cweID.Valid {
+		t.CWEID = &cweID.Int64
+	}
+	return t, err
+}
+
+// CreateContentTemplate stores a finding or note template and returns its ID.
+func CreateContentTemplate(t models.ContentTemplate) (int64, error) {
+	res, err := DB.Exec(`INSERT INTO content_templates (kind, name, title, content, summary, steps_to_reproduce, impact,
+		recommendations, payload, severity, cwe_id, vulnerability_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.Kind, t.Name, t.Title, t.Content, t.Summary, t.StepsToReproduce, t.Impact, t.Recommendations, t.Payload,
+		t.Severity, t.CWEID, t.VulnerabilityType)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("a %s template named '%s' already exists", t.Kind, t.Name)
+		}
+		return 0, fmt.Errorf("inserting %s template '%s': %w", t.Kind, t.Name, err)
+	}
+	return res.LastInsertId()
+}
+
+// UpdateContentTemplate replaces the fields of a content template.
+func UpdateContentTemplate(t models.ContentTemplate) error {
+	res, err := DB.Exec(`UPDATE content_templates SET kind = ?, name = ?, title = ?, content = ?, summary = ?,
+		steps_to_reproduce = ?, impact = ?, recommendations = ?, payload = ?, severity = ?, cwe_id = ?, vulnerability_type = ?,
+		updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		t.Kind, t.Name, t.Title, t.Content, t.Summary, t.StepsToReproduce, t.Impact, t.Recommendations, t.Payload,
+		t.Severity, t.CWEID, t.VulnerabilityType, t.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("a %s template named '%s' already exists", t.Kind, t.Name)
+		}
+		return fmt.Errorf("updating content template %d: %w", t.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("content template %d not found", t.ID)
+	}
+	return nil
+}
+
+// GetContentTemplate fetches a content template.
+func GetContentTemplate(id int64) (models.ContentTemplate, error) {
+	t, err := scanContentTemplate(DB.QueryRow(`SELECT `+contentTemplateColumns+` FROM content_templates WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return t, fmt.Errorf("content template %d not found", id)
+	}
+	if err != nil {
+		return t, fmt.Errorf("scanning content template %d: %w", id, err)
+	}
+	return t, nil
+}
+
+// GetContentTemplates lists the content templates of one kind, or all when kind is empty, by kind
+// and name.
+func GetContentTemplates(kind string) ([]models.ContentTemplate, error) {
+	rows, err := DB.Query(`SELECT `+contentTemplateColumns+` FROM content_templates
+		WHERE ? = '' OR kind = ? ORDER BY kind, name COLLATE NOCASE`, kind, kind)
+	if err != nil {
+		return nil, fmt.Errorf("querying content templates: %w", err)
+	}
+	defer rows.Close()
+	templates := []models.ContentTemplate{}
+	for rows.Next() {
+		t, err := scanContentTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning content template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// DeleteContentTemplate removes a content template. Findings and notes created from it are kept.
+func DeleteContentTemplate(id int64) error {
+	res, err := DB.Exec(`DELETE FROM content_templates WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting content template %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("content template %d not found", id)
+	}
+	return nil
+}
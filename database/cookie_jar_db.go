@@ -0,0 +1,170 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+const targetCookieColumns = `id, target_id, domain, path, name, value, is_session, secure, http_only, same_site, expires_at,
+	expired, set_count, change_count, source_log_id, first_seen_at, last_set_at`
+
+func scanTargetCookie(scanner interface{ Scan(...interface{}) error }) (models.TargetCookie, error) {
+	var c models.TargetCookie
+	var expiresAt sql.NullTime
+	err := scanner.Scan(&c.ID, &c.TargetID, &c.Domain, &c.Path, &c.Name, &c.Value, &c.IsSession, &c.Secure, &c.HTTPOnly, &c.SameSite,
+		&expiresAt, &c.Expired, &c.SetCount, &c.ChangeCount, &c.SourceLogID, &c.FirstSeenAt, &c.LastSetAt)
+	if expiresAt.Valid {
+		c.ExpiresAt = &expiresAt.Time
+	}
+	return c, err
+}
+
+// GetTargetCookie returns a cookie of a target's cookie jar, or nil when it was never set.
+func GetTargetCookie(targetID int64, domain, path, name string) (*models.TargetCookie, error) {
+	row := DB.QueryRow(`SELECT `+targetCookieColumns+` FROM target_cookies WHERE target_id = ? AND domain = ? AND path = ? AND name = ?`,
+		targetID, domain, path, name)
+	c, err := scanTargetCookie(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading cookie %s of target %d: %w", name, targetID, err)
+	}
+	return &c, nil
+}
+
+// SaveTargetCookie inserts or replaces a cookie of a target's cookie jar.
+func SaveTargetCookie(c models.TargetCookie) error {
+	var expiresAt interface{}
+	if c.ExpiresAt != nil {
+		expiresAt = c.ExpiresAt.UTC()
+	}
+	_, err := DB.Exec(`INSERT INTO target_cookies (target_id, domain, path, name, value, is_session, secure, http_only, same_site,
+			expires_at, expired, set_count, change_count, source_log_id, first_seen_at, last_set_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(target_id, domain, path, name) DO UPDATE SET value = excluded.value, is_session = excluded.is_session,
+			secure = excluded.secure, http_only = excluded.http_only, same_site = excluded.same_site, expires_at = excluded.expires_at,
+			expired = excluded.expired, set_count = excluded.set_count, change_count = excluded.change_count,
+			source_log_id = excluded.source_log_id, last_set_at = excluded.last_set_at`,
+		c.TargetID, c.Domain, c.Path, c.Name, c.Value, c.IsSession, c.Secure, c.HTTPOnly, c.SameSite,
+		expiresAt, c.Expired, c.SetCount, c.ChangeCount, c.SourceLogID, c.FirstSeenAt.UTC(), c.LastSetAt.UTC())
+	if err != nil {
+		return fmt.Errorf("saving cookie %s of target %d: %w", c.Name, c.TargetID, err)
+	}
+	return nil
+}
+
+// GetTargetCookies lists the cookie jar of a target, session cookies first, most recently set first.
+func GetTargetCookies(targetID int64, sessionOnly bool) ([]models.TargetCookie, error) {
+	query := `SELECT ` + targetCookieColumns + ` FROM target_cookies WHERE target_id = ?`
+	if sessionOnly {
+		query += " AND is_session = 1"
+	}
+	query += " ORDER BY is_session DESC, last_set_at DESC, name"
+	rows, err := DB.Query(query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying cookies of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	cookies := []models.TargetCookie{}
+	for rows.Next() {
+		c, err := scanTargetCookie(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning target cookie: %w", err)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, rows.Err()
+}
+
+// CreateSessionEvent stores a session event.
+func CreateSessionEvent(e models.SessionEvent) error {
+	_, err := DB.Exec(`INSERT INTO session_events (target_id, cookie_name, event_type, http_traffic_log_id, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, e.TargetID, e.CookieName, e.EventType, e.HTTPTrafficLogID, e.Details, e.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("saving %s session event of target %d: %w", e.EventType, e.TargetID, err)
+	}
+	return nil
+}
+
+// GetSessionEvents lists the most recent session events of a target, newest first.
+func GetSessionEvents(targetID int64, limit int) ([]models.SessionEvent, error) {
+	rows, err := DB.Query(`SELECT id, target_id, cookie_name, event_type, http_traffic_log_id, details, created_at
+		FROM session_events WHERE target_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`, targetID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying session events of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	events := []models.SessionEvent{}
+	for rows.Next() {
+		var e models.SessionEvent
+		if err := rows.Scan(&e.ID, &e.TargetID, &e.CookieName, &e.EventType, &e.HTTPTrafficLogID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning session event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// LastSessionEventAt returns when the latest event of one of the given types was recorded for a
+// target, or nil when there is none.
+func LastSessionEventAt(targetID int64, eventTypes ...string) (*time.Time, error) {
+	query := `SELECT created_at FROM session_events WHERE target_id = ? AND event_type IN (?` + strings.Repeat(", ?", len(eventTypes)-1) + `)
+		ORDER BY created_at DESC LIMIT 1`
+	args := []interface{}{targetID}
+	for _, t := range eventTypes {
+		args = append(args, t)
+	}
+	var at time.Time
+	err := DB.QueryRow(query, args...).Scan(&at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading last session event of target %d: %w", targetID, err)
+	}
+	return &at, nil
+}
+
+// ClearTargetCookieJar removes the cookies and session events of a target.
+func ClearTargetCookieJar(targetID int64) error {
+	if _, err := DB.Exec(`DELETE FROM session_events WHERE target_id = ?`, targetID); err != nil {
+		return fmt.Errorf("clearing session events of target %d: %w", targetID, err)
+	}
+	if _, err := DB.Exec(`DELETE FROM target_cookies WHERE target_id = ?`, targetID); err != nil {
+		return fmt.Errorf("clearing cookies of target %d: %w", targetID, err)
+	}
+	return nil
+}
+
+// GetCookieJarTraffic returns up to limit log entries of a target after afterID, in ID order,
+// that can affect its cookie jar: responses setting cookies, 401s and redirects. Only the fields
+// the cookie tracker reads are loaded.
+func GetCookieJarTraffic(targetID, afterID int64, limit int) ([]models.HTTPTrafficLog, error) {
+	rows, err := DB.Query(`SELECT id, timestamp, request_method, request_url, request_headers, response_status_code, response_headers
+		FROM http_traffic_log
+		WHERE target_id = ? AND id > ? AND (response_headers LIKE '%set-cookie%' OR response_status_code = 401
+			OR response_status_code BETWEEN 300 AND 399)
+		ORDER BY id LIMIT ?`, targetID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying cookie traffic of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		e := models.HTTPTrafficLog{TargetID: &targetID}
+		var status sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.RequestMethod, &e.RequestURL, &e.RequestHeaders, &status, &e.ResponseHeaders); err != nil {
+			return nil, fmt.Errorf("scanning cookie traffic: %w", err)
+		}
+		e.ResponseStatusCode = int(status.Int64)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+// GetCTWatchState returns the watch position of a target's wildcard pattern. found is false when
+// the pattern has never been checked.
+func GetCTWatchState(targetID int64, baseDomain string) (state models.CTWatchState, found bool, err error) {
+	var checkedAt sql.NullTime
+	err = DB.QueryRow(`SELECT target_id, base_domain, last_cert_id, certificates_seen, domains_added, last_checked_at, last_error
+		FROM ct_watch_state WHERE target_id = ? AND base_domain = ?`, targetID, baseDomain).
+		Scan(&state.TargetID, &state.BaseDomain, &state.LastCertID, &state.CertificatesSeen, &state.DomainsAdded, &checkedAt, &state.LastError)
+	if err == sql.ErrNoRows {
+		return models.CTWatchState{TargetID: targetID, BaseDomain: baseDomain}, false, nil
+	}
+	if err != nil {
+		return state, false, fmt.Errorf("loading CT watch state of %s (target %d): %w", baseDomain, targetID, err)
+	}
+	if checkedAt.Valid {
+		state.LastCheckedAt = &checkedAt.Time
+	}
+	return state, true, nil
+}
+
+// SaveCTWatchState stores the watch position of a target's wildcard pattern.
+func SaveCTWatchState(state models.CTWatchState) error {
+	_, err := DB.Exec(`INSERT INTO ct_watch_state (target_id, base_domain, last_cert_id, certificates_seen, domains_added, last_checked_at, last_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(target_id, base_domain) DO UPDATE SET last_cert_id = excluded.last_cert_id, certificates_seen = excluded.certificates_seen,
+			domains_added = excluded.domains_added, last_checked_at = excluded.last_checked_at, last_error = excluded.last_error`,
+		state.TargetID, state.BaseDomain, state.LastCertID, state.CertificatesSeen, state.DomainsAdded, state.LastCheckedAt, state.LastError)
+	if err != nil {
+		return fmt.Errorf("saving CT watch state of %s (target %d): %w", state.BaseDomain, state.TargetID, err)
+	}
+	return nil
+}
+
+// ListCTWatchStates returns the watch positions of every checked pattern.
+func ListCTWatchStates() ([]models.CTWatchState, error) {
+	rows, err := DB.Query(`SELECT target_id, base_domain, last_cert_id, certificates_seen, domains_added, last_checked_at, last_error
+		FROM ct_watch_state ORDER BY target_id, base_domain`)
+	if err != nil {
+		return nil, fmt.Errorf("listing CT watch states: %w", err)
+	}
+	defer rows.Close()
+	states := []models.CTWatchState{}
+	for rows.Next() {
+		var state models.CTWatchState
+		var checkedAt sql.NullTime
+		if err := rows.Scan(&state.TargetID, &state.BaseDomain, &state.LastCertID, &state.CertificatesSeen, &state.DomainsAdded, &checkedAt, &state.LastError); err != nil {
+			return nil, fmt.Errorf("scanning CT watch state: %w", err)
+		}
+		if checkedAt.Valid {
+			state.LastCheckedAt = &checkedAt.Time
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"toolkit/models"
+)
+
+// ForEachTargetDomainSummary calls fn for every domain of a target, with only the name, scope,
+// favorite, httpx status, takeover status and creation time loaded.
+func ForEachTargetDomainSummary(targetID int64, fn func(models.Domain)) error {
+	rows, err := DB.Query(`SELECT id, domain_name, is_in_scope, is_wildcard_scope, is_favorite, http_status_code, takeover_status, created_at
+		FROM domains WHERE target_id = ? ORDER BY id`, targetID)
+	if err != nil {
+		return fmt.Errorf("querying domains of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		d := models.Domain{TargetID: targetID}
+		if err := rows.Scan(&d.ID, &d.DomainName, &d.IsInScope, &d.IsWildcardScope, &d.IsFavorite, &d.HTTPStatusCode,
+			&d.TakeoverStatus, &d.CreatedAt); err != nil {
+			return fmt.Errorf("scanning domain: %w", err)
+		}
+		fn(d)
+	}
+	return rows.Err()
+}
+
+// GetDomainGroupLabels returns the business unit labels of a target's root domains, by root domain.
+func GetDomainGroupLabels(targetID int64) (map[string]models.DomainGroupLabel, error) {
+	rows, err := DB.Query(`SELECT root_domain, business_unit, notes, updated_at FROM domain_groups WHERE target_id = ?`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying domain groups of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	labels := map[string]models.DomainGroupLabel{}
+	for rows.Next() {
+		var l models.DomainGroupLabel
+		if err := rows.Scan(&l.RootDomain, &l.BusinessUnit, &l.Notes, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning domain group: %w", err)
+		}
+		labels[l.RootDomain] = l
+	}
+	return labels, rows.Err()
+}
+
+// SetDomainGroupLabel stores the business unit and notes of a target's root domain, or removes
+// them when both are empty.
+func SetDomainGroupLabel(targetID int64, rootDomain string, update models.DomainGroupUpdate) error {
+	if update.BusinessUnit == "" && update.Notes == "" {
+		if _, err := DB.Exec(`DELETE FROM domain_groups WHERE target_id = ? AND root_domain = ?`, targetID, rootDomain); err != nil {
+			return fmt.Errorf("removing domain group %s of target %d: %w", rootDomain, targetID, err)
+		}
+		return nil
+	}
+	_, err := DB.Exec(`INSERT INTO domain_groups (target_id, root_domain, business_unit, notes) VALUES (?, ?, ?, ?)
+		ON CONFLICT (target_id, root_domain) DO UPDATE SET business_unit = excluded.business_unit, notes = excluded.notes,
+		updated_at = CURRENT_TIMESTAMP`, targetID, rootDomain, update.BusinessUnit, update.Notes)
+	if err != nil {
+		return fmt.Errorf("storing domain group %s of target %d: %w", rootDomain, targetID, err)
+	}
+	return nil
+}
@@ -21,71 +21,42 @@ type DistinctDomainValues struct {
 // GetDomains retrieves a paginated list of domains for a specific target, with filtering and sorting based on DomainFilters.
 // It now also returns distinct values for certain filterable columns.
 func GetDomains(filters models.DomainFilters) ([]models.Domain, int64, *DistinctDomainValues, error) {
+	return getDomains(filters, nil)
+}
+
+// GetDomainsByCursor is GetDomains paged by cursor instead of page number, which also returns the
+// cursors of the previous and next pages.
+func GetDomainsByCursor(filters models.DomainFilters, page KeysetPage) (domains []models.Domain, totalRecords int64, distinctValues *DistinctDomainValues, prevCursor, nextCursor string, err error) {
+	domains, totalRecords, distinctValues, err = getDomains(filters, &page)
+	if err != nil {
+		return domains, totalRecords, distinctValues, "", "", err
+	}
+	domains, prevCursor, nextCursor, err = KeysetRows(page, domains, func(d models.Domain) int64 { return d.ID })
+	return domains, totalRecords, distinctValues, prevCursor, nextCursor, err
+}
+
+// getDomains reads the domains of GetDomains, or of a cursor page (one row too many, see
+// KeysetPage.LimitClause) when keyset is set.
+func getDomains(filters models.DomainFilters, keyset *KeysetPage) ([]models.Domain, int64, *DistinctDomainValues, error) {
 	if DB == nil {
 		return nil, 0, nil, errors.New("database connection is not initialized")
 	}
 
 	var domains []models.Domain
 	var totalRecords int64
-	args := []interface{}{filters.TargetID}
-	countArgs := []interface{}{filters.TargetID} // Initialize countArgs with TargetID
-
-	whereClause := "WHERE target_id = ?"
-
-	// Apply search filters
-	if filters.DomainNameSearch != "" {
-		whereClause += " AND LOWER(domain_name) LIKE LOWER(?)"
-		args = append(args, "%"+filters.DomainNameSearch+"%")
-		countArgs = append(countArgs, "%"+filters.DomainNameSearch+"%") // Ensure this is added
-	}
-	if filters.SourceSearch != "" {
-		whereClause += " AND LOWER(source) LIKE LOWER(?)"
-		args = append(args, "%"+filters.SourceSearch+"%")
-		countArgs = append(countArgs, "%"+filters.SourceSearch+"%")
-	}
 
-	if filters.IsInScope != nil {
-		whereClause += " AND is_in_scope = ?"
-		args = append(args, *filters.IsInScope)
-		countArgs = append(countArgs, *filters.IsInScope)
-	}
-	// Only apply is_favorite filter if the pointer is non-nil AND the value is true
-	if filters.IsFavorite != nil {
-		if *filters.IsFavorite { // Check if the boolean value is true
-			whereClause += " AND is_favorite = ?"
-			args = append(args, true)           // Add the boolean value 'true' to args
-			countArgs = append(countArgs, true) // Add to countArgs as well
-		}
-	}
-
-	// Temporary whereClause and args for distinct value queries,
-	// so they are not affected by the specific column filters applied later for the main query.
-	distinctWhereClause := whereClause
-	distinctCountArgs := make([]interface{}, len(countArgs))
-	copy(distinctCountArgs, countArgs)
-
-	if filters.HttpxScanStatus == "scanned" {
-		// This filter applies to both distinct value queries and the main record query
-		additionalFilter := " AND (httpx_full_json IS NOT NULL AND httpx_full_json != '')"
-		whereClause += additionalFilter
-		distinctWhereClause += additionalFilter
-		// No new arguments needed for countArgs or distinctCountArgs for this filter
-	} else if filters.HttpxScanStatus == "not_scanned" {
-		additionalFilter := " AND (httpx_full_json IS NULL OR httpx_full_json = '')"
-		whereClause += additionalFilter
-		distinctWhereClause += additionalFilter
-		// No new arguments needed for countArgs or distinctCountArgs for this filter
-	}
+	// The distinct value queries are not narrowed by the column filters added for the records below.
+	distinctConditions := domainBaseConditions(filters)
 
 	// --- Fetch Distinct Values ---
 	distinctValues := &DistinctDomainValues{}
 	var err error
 
 	// Distinct HTTP Status Codes
-	distinctQueryStatusCode := "SELECT DISTINCT http_status_code FROM domains " + distinctWhereClause + " AND http_status_code IS NOT NULL ORDER BY http_status_code ASC"
-	rowsDistinctStatus, err := DB.Query(distinctQueryStatusCode, distinctCountArgs...)
+	distinctQueryStatusCode := "SELECT DISTINCT http_status_code FROM domains " + distinctConditions.Where() + " AND http_status_code IS NOT NULL ORDER BY http_status_code ASC"
+	rowsDistinctStatus, err := DB.Query(distinctQueryStatusCode, distinctConditions.Args()...)
 	if err != nil {
-		logger.Error("Error fetching distinct http_status_code: %v. Query: %s, Args: %v", err, distinctQueryStatusCode, distinctCountArgs)
+		logger.Error("Error fetching distinct http_status_code: %v. Query: %s, Args: %v", err, distinctQueryStatusCode, distinctConditions.Args())
 	} else {
 		for rowsDistinctStatus.Next() {
 			var val sql.NullInt64
@@ -97,10 +68,10 @@ func GetDomains(filters models.DomainFilters) ([]models.Domain, int64, *Distinct
 	}
 
 	// Distinct HTTP Servers
-	distinctQueryServer := "SELECT DISTINCT http_server FROM domains " + distinctWhereClause + " AND http_server IS NOT NULL AND http_server != '' ORDER BY http_server ASC"
-	rowsDistinctServer, err := DB.Query(distinctQueryServer, distinctCountArgs...)
+	distinctQueryServer := "SELECT DISTINCT http_server FROM domains " + distinctConditions.Where() + " AND http_server IS NOT NULL AND http_server != '' ORDER BY http_server ASC"
+	rowsDistinctServer, err := DB.Query(distinctQueryServer, distinctConditions.Args()...)
 	if err != nil {
-		logger.Error("Error fetching distinct http_server: %v. Query: %s, Args: %v", err, distinctQueryServer, distinctCountArgs)
+		logger.Error("Error fetching distinct http_server: %v. Query: %s, Args: %v", err, distinctQueryServer, distinctConditions.Args())
 	} else {
 		for rowsDistinctServer.Next() {
 			var val sql.NullString
@@ -112,10 +83,10 @@ func GetDomains(filters models.DomainFilters) ([]models.Domain, int64, *Distinct
 	}
 
 	// Distinct HTTP Tech
-	distinctQueryTech := "SELECT DISTINCT http_tech FROM domains " + distinctWhereClause + " AND http_tech IS NOT NULL AND http_tech != '' ORDER BY http_tech ASC"
-	rowsDistinctTech, err := DB.Query(distinctQueryTech, distinctCountArgs...)
+	distinctQueryTech := "SELECT DISTINCT http_tech FROM domains " + distinctConditions.Where() + " AND http_tech IS NOT NULL AND http_tech != '' ORDER BY http_tech ASC"
+	rowsDistinctTech, err := DB.Query(distinctQueryTech, distinctConditions.Args()...)
 	if err != nil {
-		logger.Error("Error fetching distinct http_tech: %v. Query: %s, Args: %v", err, distinctQueryTech, distinctCountArgs)
+		logger.Error("Error fetching distinct http_tech: %v. Query: %s, Args: %v", err, distinctQueryTech, distinctConditions.Args())
 	} else {
 		for rowsDistinctTech.Next() {
 			var val sql.NullString
@@ -127,74 +98,26 @@ func GetDomains(filters models.DomainFilters) ([]models.Domain, int64, *Distinct
 	}
 	// --- End Fetch Distinct Values ---
 
-	// Now, apply the specific column filters to the main whereClause for fetching records
-	// and also update countArgs for the final count query.
-	finalCountArgs := make([]interface{}, len(countArgs)) // Create a new slice for final count args
-	copy(finalCountArgs, countArgs)
+	conditions := distinctConditions.Clone()
+	addDomainColumnConditions(&conditions, filters)
 
-	if filters.FilterHTTPStatusCode != "" {
-		if strings.ToUpper(filters.FilterHTTPStatusCode) == "NULL" || strings.ToUpper(filters.FilterHTTPStatusCode) == "N/A" {
-			whereClause += " AND http_status_code IS NULL"
-		} else {
-			statusCodeInt, err := strconv.ParseInt(filters.FilterHTTPStatusCode, 10, 64)
-			if err == nil {
-				whereClause += " AND http_status_code = ?"
-				args = append(args, statusCodeInt)
-				finalCountArgs = append(finalCountArgs, statusCodeInt) // Add to final count args
-			} else {
-				logger.Warn("GetDomains: Invalid non-numeric http_status_code filter value '%s', ignoring filter.", filters.FilterHTTPStatusCode)
-			}
-		}
-	}
-	if filters.FilterHTTPServer != "" {
-		if strings.ToUpper(filters.FilterHTTPServer) == "NULL" || strings.ToUpper(filters.FilterHTTPServer) == "N/A" {
-			whereClause += " AND (http_server IS NULL OR http_server = '')"
-		} else {
-			whereClause += " AND http_server = ?"
-			args = append(args, filters.FilterHTTPServer)
-			finalCountArgs = append(finalCountArgs, filters.FilterHTTPServer) // Add to final count args
-		}
-	}
-	if filters.FilterHTTPTech != "" {
-		if strings.ToUpper(filters.FilterHTTPTech) == "NULL" || strings.ToUpper(filters.FilterHTTPTech) == "N/A" {
-			whereClause += " AND (http_tech IS NULL OR http_tech = '')"
-		} else {
-			whereClause += " AND http_tech = ?"
-			args = append(args, filters.FilterHTTPTech)
-			finalCountArgs = append(finalCountArgs, filters.FilterHTTPTech) // Add to final count args
-		}
-	}
-
-	countQuery := "SELECT COUNT(*) FROM domains " + whereClause
-	err = DB.QueryRow(countQuery, finalCountArgs...).Scan(&totalRecords) // Use finalCountArgs
+	countQuery := "SELECT COUNT(*) FROM domains " + conditions.Where()
+	err = DB.QueryRow(countQuery, conditions.Args()...).Scan(&totalRecords)
 	if err != nil {
-		logger.Error("Error counting domains: %v. Query: %s, Args: %v", err, countQuery, finalCountArgs)
+		logger.Error("Error counting domains: %v. Query: %s, Args: %v", err, countQuery, conditions.Args())
 		return nil, 0, distinctValues, fmt.Errorf("counting domains failed: %w", err)
 	}
 
-	selectQuery := "SELECT id, target_id, domain_name, source, is_in_scope, is_wildcard_scope, notes, created_at, updated_at, is_favorite, http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json FROM domains " + whereClause
-
-	allowedSortCols := map[string]bool{
-		"id": true, "domain_name": true, "source": true, "is_in_scope": true,
-		"is_wildcard_scope": true, "notes": true, "created_at": true, "updated_at": true,
-		"is_favorite": true, "http_status_code": true, "http_content_length": true,
-		"http_title": true, "http_server": true, "http_tech": true,
-	}
-	if !allowedSortCols[filters.SortBy] {
-		filters.SortBy = "domain_name"
-	}
-	if strings.ToUpper(filters.SortOrder) != "ASC" && strings.ToUpper(filters.SortOrder) != "DESC" {
-		filters.SortOrder = "ASC"
-	}
-	orderByClause := fmt.Sprintf("ORDER BY %s %s, id %s", filters.SortBy, filters.SortOrder, filters.SortOrder)
-
-	selectQuery += " " + orderByClause // Add space before ORDER BY
-
-	if filters.Limit > 0 {
-		offset := (filters.Page - 1) * filters.Limit
-		selectQuery += " LIMIT ? OFFSET ?" // Add space before LIMIT
-		args = append(args, filters.Limit, offset)
+	// A limit of 0 returns all domains.
+	orderBy := DomainSort.OrderBy(filters.SortBy, filters.SortOrder)
+	limitClause, limitArgs := NewPage(filters.Page, filters.Limit, 0, 0, true).LimitClause()
+	if keyset != nil {
+		orderBy = keyset.Apply(&conditions, "")
+		limitClause, limitArgs = keyset.LimitClause()
 	}
+	selectQuery := "SELECT id, target_id, domain_name, source, is_in_scope, is_wildcard_scope, notes, created_at, updated_at, is_favorite, http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json, favicon_hash, favicon_url, open_ports, enrichment_json, enriched_at, takeover_status, takeover_service, takeover_cname, takeover_evidence_log_id, takeover_checked_at FROM domains " +
+		conditions.Where() + " " + orderBy + limitClause
+	args := conditions.Args(limitArgs...)
 
 	rows, err := DB.Query(selectQuery, args...)
 	if err != nil {
@@ -207,7 +130,7 @@ func GetDomains(filters models.DomainFilters) ([]models.Domain, int64, *Distinct
 		var d models.Domain
 		var createdAtStr string
 		var updatedAtStr string
-		if err := rows.Scan(&d.ID, &d.TargetID, &d.DomainName, &d.Source, &d.IsInScope, &d.IsWildcardScope, &d.Notes, &createdAtStr, &updatedAtStr, &d.IsFavorite, &d.HTTPStatusCode, &d.HTTPContentLength, &d.HTTPTitle, &d.HTTPServer, &d.HTTPTech, &d.HttpxFullJson); err != nil {
+		if err := rows.Scan(&d.ID, &d.TargetID, &d.DomainName, &d.Source, &d.IsInScope, &d.IsWildcardScope, &d.Notes, &createdAtStr, &updatedAtStr, &d.IsFavorite, &d.HTTPStatusCode, &d.HTTPContentLength, &d.HTTPTitle, &d.HTTPServer, &d.HTTPTech, &d.HttpxFullJson, &d.FaviconHash, &d.FaviconURL, &d.OpenPorts, &d.EnrichmentJson, &d.EnrichedAt, &d.TakeoverStatus, &d.TakeoverService, &d.TakeoverCNAME, &d.TakeoverEvidenceLogID, &d.TakeoverCheckedAt); err != nil {
 			logger.Error("Error scanning domain row: %v", err)
 			return nil, 0, distinctValues, fmt.Errorf("scanning domain row failed: %w", err)
 		}
@@ -296,7 +219,8 @@ func UpdateDomainWithHttpxResult(domain models.Domain) error {
 	stmt, err := DB.Prepare(`
 		UPDATE domains
 		SET http_status_code = ?, http_content_length = ?, http_title = ?,
-		    http_server = ?, http_tech = ?, httpx_full_json = ?, updated_at = CURRENT_TIMESTAMP
+		    http_server = ?, http_tech = ?, httpx_full_json = ?,
+		    favicon_hash = COALESCE(?, favicon_hash), favicon_url = COALESCE(?, favicon_url), updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`)
 	if err != nil {
@@ -306,7 +230,8 @@ func UpdateDomainWithHttpxResult(domain models.Domain) error {
 	defer stmt.Close()
 	_, err = stmt.Exec(
 		domain.HTTPStatusCode, domain.HTTPContentLength, domain.HTTPTitle,
-		domain.HTTPServer, domain.HTTPTech, domain.HttpxFullJson, domain.ID,
+		domain.HTTPServer, domain.HTTPTech, domain.HttpxFullJson,
+		domain.FaviconHash, domain.FaviconURL, domain.ID,
 	)
 	if err != nil {
 		logger.Error("Error executing update for domain with httpx results (ID %d): %v", domain.ID, err)
@@ -315,6 +240,162 @@ func UpdateDomainWithHttpxResult(domain models.Domain) error {
 	return nil
 }
 
+// UpdateDomainEnrichment stores the favicon hash and Shodan/Censys enrichment of a domain. Fields
+// that are not valid keep their stored value.
+func UpdateDomainEnrichment(domain models.Domain) error {
+	if DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+	_, err := DB.Exec(`
+		UPDATE domains
+		SET favicon_hash = COALESCE(?, favicon_hash), favicon_url = COALESCE(?, favicon_url),
+		    open_ports = COALESCE(?, open_ports), enrichment_json = COALESCE(?, enrichment_json),
+		    enriched_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, domain.FaviconHash, domain.FaviconURL, domain.OpenPorts, domain.EnrichmentJson, domain.ID)
+	if err != nil {
+		logger.Error("Error updating enrichment of domain ID %d: %v", domain.ID, err)
+		return fmt.Errorf("domain enrichment update failed: %w", err)
+	}
+	return nil
+}
+
+// UpdateDomainTakeover stores the result of a takeover check on a domain, replacing the previous
+// one.
+func UpdateDomainTakeover(domain models.Domain) error {
+	if DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+	_, err := DB.Exec(`
+		UPDATE domains
+		SET takeover_status = ?, takeover_service = ?, takeover_cname = ?, takeover_evidence_log_id = ?,
+		    takeover_checked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, domain.TakeoverStatus, domain.TakeoverService, domain.TakeoverCNAME, domain.TakeoverEvidenceLogID, domain.ID)
+	if err != nil {
+		logger.Error("Error updating takeover result of domain ID %d: %v", domain.ID, err)
+		return fmt.Errorf("domain takeover update failed: %w", err)
+	}
+	return nil
+}
+
+// takeoverFilterClause builds the WHERE clause of the takeover_status filter; NULL selects the
+// DomainSort lists the columns domain listings can be sorted by.
+var DomainSort = SortSpec{
+	Columns: map[string]string{
+		"id": "id", "domain_name": "domain_name", "source": "source", "is_in_scope": "is_in_scope",
+		"is_wildcard_scope": "is_wildcard_scope", "notes": "notes", "created_at": "created_at", "updated_at": "updated_at",
+		"is_favorite": "is_favorite", "http_status_code": "http_status_code", "http_content_length": "http_content_length",
+		"http_title": "http_title", "http_server": "http_server", "http_tech": "http_tech",
+		"favicon_hash": "favicon_hash", "enriched_at": "enriched_at", "takeover_status": "takeover_status", "takeover_checked_at": "takeover_checked_at",
+	},
+	Default:      "domain_name",
+	DefaultOrder: "ASC",
+	TieBreaker:   "id",
+}
+
+// DomainKeyset pages domain listings by cursor, on when domains were added or last changed.
+var DomainKeyset = Keyset{Table: "domains", Columns: []string{"created_at", "updated_at"}}
+
+// domainBaseConditions returns the conditions of the domain filters the filter dropdowns follow:
+// target, name and source search, scope, favorites and httpx scan status.
+func domainBaseConditions(filters models.DomainFilters) Conditions {
+	var c Conditions
+	c.Add("target_id = ?", filters.TargetID)
+	if filters.DomainNameSearch != "" {
+		c.Add("LOWER(domain_name) LIKE LOWER(?)", "%"+filters.DomainNameSearch+"%")
+	}
+	if filters.SourceSearch != "" {
+		c.Add("LOWER(source) LIKE LOWER(?)", "%"+filters.SourceSearch+"%")
+	}
+	if filters.IsInScope != nil {
+		c.Add("is_in_scope = ?", *filters.IsInScope)
+	}
+	// Only favorites_only=true filters; false lists all domains.
+	if filters.IsFavorite != nil && *filters.IsFavorite {
+		c.Add("is_favorite = ?", true)
+	}
+	switch filters.HttpxScanStatus {
+	case "scanned":
+		c.Add("(httpx_full_json IS NOT NULL AND httpx_full_json != '')")
+	case "not_scanned":
+		c.Add("(httpx_full_json IS NULL OR httpx_full_json = '')")
+	}
+	return c
+}
+
+// addDomainColumnConditions adds the conditions of the column filters (status, server, tech,
+// favicon hash, open port, takeover status, root domain and business unit). "NULL" or "N/A" selects
+// domains without a value; invalid numbers are ignored.
+func addDomainColumnConditions(c *Conditions, filters models.DomainFilters) {
+	isNull := func(value string) bool {
+		return strings.ToUpper(value) == "NULL" || strings.ToUpper(value) == "N/A"
+	}
+	if value := filters.FilterHTTPStatusCode; value != "" {
+		if isNull(value) {
+			c.Add("http_status_code IS NULL")
+		} else if statusCode, err := strconv.ParseInt(value, 10, 64); err == nil {
+			c.Add("http_status_code = ?", statusCode)
+		} else {
+			logger.Warn("Invalid non-numeric http_status_code filter value '%s', ignoring filter.", value)
+		}
+	}
+	if value := filters.FilterHTTPServer; value != "" {
+		if isNull(value) {
+			c.Add("(http_server IS NULL OR http_server = '')")
+		} else {
+			c.Add("http_server = ?", value)
+		}
+	}
+	if value := filters.FilterHTTPTech; value != "" {
+		if isNull(value) {
+			c.Add("(http_tech IS NULL OR http_tech = '')")
+		} else {
+			c.Add("http_tech = ?", value)
+		}
+	}
+	addEnrichmentCondition(c, "favicon_hash", filters.FilterFaviconHash)
+	addEnrichmentCondition(c, "open_port", filters.FilterOpenPort)
+	switch value := strings.TrimSpace(filters.FilterTakeoverStatus); {
+	case value == "":
+	case strings.ToUpper(value) == "NULL":
+		c.Add("takeover_status IS NULL")
+	default:
+		c.Add("takeover_status = ?", strings.ToLower(value))
+	}
+	if root := strings.ToLower(strings.TrimSpace(filters.FilterRootDomain)); root != "" {
+		c.Add("(LOWER(domain_name) = ? OR substr(LOWER(domain_name), -?) = ?)", root, len(root)+1, "."+root)
+	}
+	// A domain belongs to the business unit of the labeled root domain it equals or ends with.
+	const inLabeledGroup = `SELECT 1 FROM domain_groups g WHERE g.target_id = domains.target_id AND g.business_unit != ''
+		AND (LOWER(domains.domain_name) = g.root_domain OR substr(LOWER(domains.domain_name), -length(g.root_domain) - 1) = '.' || g.root_domain)`
+	switch value := strings.TrimSpace(filters.FilterBusinessUnit); {
+	case value == "":
+	case isNull(value):
+		c.Add("NOT EXISTS (" + inLabeledGroup + ")")
+	default:
+		c.Add("EXISTS ("+inLabeledGroup+" AND g.business_unit = ? COLLATE NOCASE)", value)
+	}
+}
+
+// addEnrichmentCondition adds the condition of the favicon_hash and open_port filters. Invalid
+// (non-numeric) values are ignored like the other numeric filters.
+func addEnrichmentCondition(c *Conditions, filter, value string) {
+	if value == "" {
+		return
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		logger.Warn("Invalid non-numeric %s filter value '%s', ignoring filter.", filter, value)
+		return
+	}
+	if filter == "open_port" {
+		c.Add("(',' || open_ports || ',') LIKE ?", "%,"+strconv.FormatInt(n, 10)+",%")
+		return
+	}
+	c.Add("favicon_hash = ?", n)
+}
+
 // DeleteDomain deletes a domain by its ID.
 func DeleteDomain(id int64) error {
 	if DB == nil {
@@ -402,65 +483,11 @@ func GetDomainIDsByFilters(filters models.DomainFilters) ([]int64, error) {
 		return nil, errors.New("database connection is not initialized")
 	}
 
-	args := []interface{}{filters.TargetID}
-	whereClause := "WHERE target_id = ?"
-
-	if filters.DomainNameSearch != "" {
-		whereClause += " AND LOWER(domain_name) LIKE LOWER(?)"
-		args = append(args, "%"+filters.DomainNameSearch+"%")
-	}
-	if filters.SourceSearch != "" {
-		whereClause += " AND LOWER(source) LIKE LOWER(?)"
-		args = append(args, "%"+filters.SourceSearch+"%")
-	}
-	if filters.IsInScope != nil {
-		whereClause += " AND is_in_scope = ?"
-		args = append(args, *filters.IsInScope)
-	}
-	// Only apply is_favorite filter if the pointer is non-nil AND the value is true
-	if filters.IsFavorite != nil {
-		if *filters.IsFavorite { // Check if the boolean value is true
-			whereClause += " AND is_favorite = ?"
-			args = append(args, true) // Add the boolean value 'true' to args
-		}
-	}
-	if filters.HttpxScanStatus == "scanned" {
-		whereClause += " AND (httpx_full_json IS NOT NULL AND httpx_full_json != '')"
-	} else if filters.HttpxScanStatus == "not_scanned" {
-		whereClause += " AND (httpx_full_json IS NULL OR httpx_full_json = '')"
-	}
-	if filters.FilterHTTPStatusCode != "" {
-		if strings.ToUpper(filters.FilterHTTPStatusCode) == "NULL" || strings.ToUpper(filters.FilterHTTPStatusCode) == "N/A" {
-			whereClause += " AND http_status_code IS NULL"
-		} else {
-			statusCodeInt, err := strconv.ParseInt(filters.FilterHTTPStatusCode, 10, 64)
-			if err == nil {
-				whereClause += " AND http_status_code = ?"
-				args = append(args, statusCodeInt)
-			} else {
-				logger.Warn("GetDomainIDsByFilters: Invalid non-numeric http_status_code filter value '%s', ignoring filter.", filters.FilterHTTPStatusCode)
-			}
-		}
-	}
-	if filters.FilterHTTPServer != "" {
-		if strings.ToUpper(filters.FilterHTTPServer) == "NULL" || strings.ToUpper(filters.FilterHTTPServer) == "N/A" {
-			whereClause += " AND (http_server IS NULL OR http_server = '')"
-		} else {
-			whereClause += " AND http_server = ?"
-			args = append(args, filters.FilterHTTPServer)
-		}
-	}
-	if filters.FilterHTTPTech != "" {
-		if strings.ToUpper(filters.FilterHTTPTech) == "NULL" || strings.ToUpper(filters.FilterHTTPTech) == "N/A" {
-			whereClause += " AND (http_tech IS NULL OR http_tech = '')"
-		} else {
-			whereClause += " AND http_tech = ?"
-			args = append(args, filters.FilterHTTPTech)
-		}
-	}
-
-	logger.Debug("GetDomainIDsByFilters: Executing query: SELECT id FROM domains %s with args: %v", whereClause, args)
-	query := "SELECT id FROM domains " + whereClause
+	conditions := domainBaseConditions(filters)
+	addDomainColumnConditions(&conditions, filters)
+	query := "SELECT id FROM domains " + conditions.Where()
+	args := conditions.Args()
+	logger.Debug("GetDomainIDsByFilters: Executing query: %s with args: %v", query, args)
 
 	rows, err := DB.Query(query, args...)
 	if err != nil {
@@ -492,7 +519,9 @@ func GetDomainsByIDs(ids []int64) ([]models.Domain, error) {
 	}
 	logger.Debug("GetDomainsByIDs: Attempting to fetch %d domain IDs.", len(ids))
 	query := `SELECT id, target_id, domain_name, source, is_in_scope, is_wildcard_scope, notes, created_at, updated_at, is_favorite,
-	                 http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json
+	                 http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json,
+	                 favicon_hash, favicon_url, open_ports, enrichment_json, enriched_at,
+	                 takeover_status, takeover_service, takeover_cname, takeover_evidence_log_id, takeover_checked_at
 	          FROM domains WHERE id IN (?` + strings.Repeat(",?", len(ids)-1) + `)`
 	args := make([]interface{}, len(ids))
 	for i, id := range ids {
@@ -508,7 +537,7 @@ func GetDomainsByIDs(ids []int64) ([]models.Domain, error) {
 	for rows.Next() {
 		var d models.Domain
 		var createdAtStr, updatedAtStr string
-		if err := rows.Scan(&d.ID, &d.TargetID, &d.DomainName, &d.Source, &d.IsInScope, &d.IsWildcardScope, &d.Notes, &createdAtStr, &updatedAtStr, &d.IsFavorite, &d.HTTPStatusCode, &d.HTTPContentLength, &d.HTTPTitle, &d.HTTPServer, &d.HTTPTech, &d.HttpxFullJson); err != nil {
+		if err := rows.Scan(&d.ID, &d.TargetID, &d.DomainName, &d.Source, &d.IsInScope, &d.IsWildcardScope, &d.Notes, &createdAtStr, &updatedAtStr, &d.IsFavorite, &d.HTTPStatusCode, &d.HTTPContentLength, &d.HTTPTitle, &d.HTTPServer, &d.HTTPTech, &d.HttpxFullJson, &d.FaviconHash, &d.FaviconURL, &d.OpenPorts, &d.EnrichmentJson, &d.EnrichedAt, &d.TakeoverStatus, &d.TakeoverService, &d.TakeoverCNAME, &d.TakeoverEvidenceLogID, &d.TakeoverCheckedAt); err != nil {
 			logger.Error("GetDomainsByIDs: Error scanning domain row: %v", err)
 			return nil, fmt.Errorf("scanning domain row failed: %w", err)
 		}
@@ -528,11 +557,15 @@ func GetDomainByID(id int64) (*models.Domain, error) {
 	var d models.Domain
 	var createdAtStr, updatedAtStr string
 	query := `SELECT id, target_id, domain_name, source, is_in_scope, is_wildcard_scope, notes, created_at, updated_at, is_favorite,
-	                 http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json
+	                 http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json,
+	                 favicon_hash, favicon_url, open_ports, enrichment_json, enriched_at,
+	                 takeover_status, takeover_service, takeover_cname, takeover_evidence_log_id, takeover_checked_at
 	          FROM domains WHERE id = ?`
 	err := DB.QueryRow(query, id).Scan(
 		&d.ID, &d.TargetID, &d.DomainName, &d.Source, &d.IsInScope, &d.IsWildcardScope, &d.Notes, &createdAtStr, &updatedAtStr, &d.IsFavorite,
 		&d.HTTPStatusCode, &d.HTTPContentLength, &d.HTTPTitle, &d.HTTPServer, &d.HTTPTech, &d.HttpxFullJson,
+		&d.FaviconHash, &d.FaviconURL, &d.OpenPorts, &d.EnrichmentJson, &d.EnrichedAt,
+		&d.TakeoverStatus, &d.TakeoverService, &d.TakeoverCNAME, &d.TakeoverEvidenceLogID, &d.TakeoverCheckedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -578,3 +611,70 @@ func FavoriteAllFilteredDomainsDB(targetID int64, domainNameSearch, sourceSearch
 	}
 	return result.RowsAffected()
 }
+
+// GetDomainNamesForTarget returns the names of all domains tracked for a target.
+func GetDomainNamesForTarget(targetID int64) ([]string, error) {
+	if DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+	rows, err := DB.Query("SELECT domain_name FROM domains WHERE target_id = ?", targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying domain names for target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning domain name: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// GetDomainIDByName returns the ID of a target's domain, or 0 when the target does not have it.
+func GetDomainIDByName(targetID int64, name string) (int64, error) {
+	var id int64
+	err := DB.QueryRow("SELECT id FROM domains WHERE target_id = ? AND domain_name = ?", targetID, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("looking up domain '%s' of target %d: %w", name, targetID, err)
+	}
+	return id, nil
+}
+
+// CreateDomains inserts many domains in one transaction, skipping names the target already has.
+// It returns the names that were added.
+func CreateDomains(domains []models.Domain) ([]string, error) {
+	if DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting domain import transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO domains (target_id, domain_name, source, is_in_scope, is_wildcard_scope, notes, created_at, updated_at, is_favorite) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("preparing domain import failed: %w", err)
+	}
+	defer stmt.Close()
+	var added []string
+	for _, d := range domains {
+		result, err := stmt.Exec(d.TargetID, d.DomainName, d.Source, d.IsInScope, d.IsWildcardScope, d.Notes, d.IsFavorite)
+		if err != nil {
+			return nil, fmt.Errorf("inserting domain %s: %w", d.DomainName, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added = append(added, d.DomainName)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing domain import: %w", err)
+	}
+	logger.Info("Imported %d of %d domains", len(added), len(domains))
+	return added, nil
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"toolkit/models"
+)
+
+const engagementSessionColumns = `id, target_id, label, source, started_at, ended_at, last_activity_at, planned_minutes,
+	due_at, schedule_alerted, requests, manual_requests, active_seconds, notes`
+
+// CreateEngagementSession stores a running engagement session and returns its ID. It fails when
+// the target already has a running session.
+func CreateEngagementSession(s models.EngagementSession) (int64, error) {
+	result, err := DB.Exec(`INSERT INTO engagement_sessions (target_id, label, source, started_at, last_activity_at,
+			planned_minutes, due_at, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.TargetID, s.Label, s.Source, s.StartedAt.UTC(), nullableTime(s.LastActivityAt), s.PlannedMinutes, nullableTime(s.DueAt), s.Notes)
+	if err != nil {
+		return 0, fmt.Errorf("starting engagement session for target %d: %w", s.TargetID, err)
+	}
+	return result.LastInsertId()
+}
+
+// GetEngagementSession returns an engagement session.
+func GetEngagementSession(id int64) (models.EngagementSession, error) {
+	s, err := scanEngagementSession(DB.QueryRow(`SELECT `+engagementSessionColumns+` FROM engagement_sessions WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("engagement session with ID %d not found", id)
+	}
+	if err != nil {
+		return s, fmt.Errorf("loading engagement session %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// GetRunningEngagementSession returns the running engagement session of a target. found is false
+// when there is none.
+func GetRunningEngagementSession(targetID int64) (s models.EngagementSession, found bool, err error) {
+	s, err = scanEngagementSession(DB.QueryRow(`SELECT `+engagementSessionColumns+` FROM engagement_sessions
+		WHERE target_id = ? AND ended_at IS NULL`, targetID))
+	if err == sql.ErrNoRows {
+		return s, false, nil
+	}
+	if err != nil {
+		return s, false, fmt.Errorf("loading running engagement session of target %d: %w", targetID, err)
+	}
+	return s, true, nil
+}
+
+// ListEngagementSessions returns the engagement sessions of a target started within [since, until)
+// (either may be nil), latest first. targetID 0 lists the sessions of every target. limit 0 means
+// no limit.
+func ListEngagementSessions(targetID int64, since, until *time.Time, limit int) ([]models.EngagementSession, error) {
+	query := `SELECT ` + engagementSessionColumns + ` FROM engagement_sessions WHERE 1 = 1`
+	var args []interface{}
+	if targetID != 0 {
+		query += ` AND target_id = ?`
+		args = append(args, targetID)
+	}
+	if since != nil {
+		query += ` AND julianday(started_at) >= julianday(?)`
+		args = append(args, since.UTC().Format(time.RFC3339Nano))
+	}
+	if until != nil {
+		query += ` AND julianday(started_at) < julianday(?)`
+		args = append(args, until.UTC().Format(time.RFC3339Nano))
+	}
+	query += ` ORDER BY started_at DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing engagement sessions: %w", err)
+	}
+	defer rows.Close()
+	sessions := []models.EngagementSession{}
+	for rows.Next() {
+		s, err := scanEngagementSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning engagement session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// ListIdleEngagementSessions returns the running automatic sessions without activity since the
+// given time.
+func ListIdleEngagementSessions(idleSince time.Time) ([]models.EngagementSession, error) {
+	rows, err := DB.Query(`SELECT `+engagementSessionColumns+` FROM engagement_sessions
+		WHERE ended_at IS NULL AND source = ? AND julianday(COALESCE(last_activity_at, started_at)) < julianday(?)`,
+		models.EngagementSourceAuto, idleSince.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return nil, fmt.Errorf("listing idle engagement sessions: %w", err)
+	}
+	defer rows.Close()
+	sessions := []models.EngagementSession{}
+	for rows.Next() {
+		s, err := scanEngagementSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning engagement session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// EndEngagementSession ends a running engagement session with its final counts. It fails when the
+// session is not running.
+func EndEngagementSession(s models.EngagementSession) error {
+	result, err := DB.Exec(`UPDATE engagement_sessions SET ended_at = ?, requests = ?, manual_requests = ?, active_seconds = ?, notes = ?
+		WHERE id = ? AND ended_at IS NULL`,
+		nullableTime(s.EndedAt), s.Requests, s.ManualRequests, s.ActiveSeconds, s.Notes, s.ID)
+	if err != nil {
+		return fmt.Errorf("ending engagement session %d: %w", s.ID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("engagement session %d is not running", s.ID)
+	}
+	return nil
+}
+
+// TouchEngagementSession records activity at the given time in a running engagement session.
+func TouchEngagementSession(id int64, at time.Time) error {
+	_, err := DB.Exec(`UPDATE engagement_sessions SET last_activity_at = ?
+		WHERE id = ? AND (last_activity_at IS NULL OR julianday(last_activity_at) < julianday(?))`,
+		at.UTC(), id, at.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("recording activity in engagement session %d: %w", id, err)
+	}
+	return nil
+}
+
+// MarkEngagementScheduleAlerted records that the over-budget or deadline notification of an
+// engagement session was sent.
+func MarkEngagementScheduleAlerted(id int64) error {
+	if _, err := DB.Exec(`UPDATE engagement_sessions SET schedule_alerted = 1 WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("updating engagement session %d: %w", id, err)
+	}
+	return nil
+}
+
+// UpdateEngagementSession changes the label, time budget, deadline and notes of an engagement
+// session. A changed budget or deadline may be alerted about again.
+func UpdateEngagementSession(s models.EngagementSession) error {
+	result, err := DB.Exec(`UPDATE engagement_sessions SET label = ?, planned_minutes = ?, due_at = ?, notes = ?,
+			schedule_alerted = CASE WHEN planned_minutes != ? OR COALESCE(julianday(due_at), 0) != COALESCE(julianday(?), 0) THEN 0 ELSE schedule_alerted END
+		WHERE id = ?`,
+		s.Label, s.PlannedMinutes, nullableTime(s.DueAt), s.Notes, s.PlannedMinutes, nullableTimeString(s.DueAt), s.ID)
+	if err != nil {
+		return fmt.Errorf("updating engagement session %d: %w", s.ID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("engagement session with ID %d not found", s.ID)
+	}
+	return nil
+}
+
+// DeleteEngagementSession deletes an engagement session.
+func DeleteEngagementSession(id int64) error {
+	result, err := DB.Exec(`DELETE FROM engagement_sessions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting engagement session %d: %w", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("engagement session with ID %d not found", id)
+	}
+	return nil
+}
+
+// ForEachTargetRequestTime calls fn with the time and log_source of every request logged for a
+// target within [from, to], oldest first.
+func ForEachTargetRequestTime(targetID int64, from, to time.Time, fn func(at time.Time, logSource string)) error {
+	rows, err := DB.Query(`SELECT strftime('%Y-%m-%dT%H:%M:%fZ', timestamp), COALESCE(log_source, '') FROM http_traffic_log
+		WHERE target_id = ? AND julianday(timestamp) BETWEEN julianday(?) AND julianday(?)
+		ORDER BY julianday(timestamp)`,
+		targetID, from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("querying request times of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var at sql.NullString
+		var source string
+		if err := rows.Scan(&at, &source); err != nil {
+			return fmt.Errorf("scanning request time: %w", err)
+		}
+		t, err := time.Parse(time.RFC3339Nano, at.String)
+		if err != nil {
+			continue
+		}
+		fn(t, source)
+	}
+	return rows.Err()
+}
+
+func scanEngagementSession(row interface{ Scan(...any) error }) (models.EngagementSession, error) {
+	var s models.EngagementSession
+	var endedAt, lastActivityAt, dueAt sql.NullTime
+	err := row.Scan(&s.ID, &s.TargetID, &s.Label, &s.Source, &s.StartedAt, &endedAt, &lastActivityAt, &s.PlannedMinutes,
+		&dueAt, &s.ScheduleAlerted, &s.Requests, &s.ManualRequests, &s.ActiveSeconds, &s.Notes)
+	if err != nil {
+		return s, err
+	}
+	if endedAt.Valid {
+		s.EndedAt = &endedAt.Time
+	}
+	if lastActivityAt.Valid {
+		s.LastActivityAt = &lastActivityAt.Time
+	}
+	if dueAt.Valid {
+		s.DueAt = &dueAt.Time
+	}
+	s.Running = s.EndedAt == nil
+	return s, nil
+}
+
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func nullableTimeString(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
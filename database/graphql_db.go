@@ -0,0 +1,215 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const graphQLEndpointColumns = `id, target_id, url, operation_count, schema_json IS NOT NULL, schema_source, schema_fetched_at,
+	introspection_error, first_seen_at, last_seen_at`
+
+const graphQLOperationColumns = `o.id, o.graphql_endpoint_id, e.url, o.target_id, o.operation_type, o.operation_name, o.query, o.query_hash,
+	o.persisted_query_hash, o.variables, o.last_http_traffic_log_id, o.seen_count, o.first_seen_at, o.last_seen_at`
+
+func scanGraphQLEndpoint(scanner interface{ Scan(...interface{}) error }) (models.GraphQLEndpoint, error) {
+	var e models.GraphQLEndpoint
+	err := scanner.Scan(&e.ID, &e.TargetID, &e.URL, &e.OperationCount, &e.HasSchema, &e.SchemaSource, &e.SchemaFetchedAt,
+		&e.IntrospectionError, &e.FirstSeenAt, &e.LastSeenAt)
+	return e, err
+}
+
+func scanGraphQLOperation(scanner interface{ Scan(...interface{}) error }) (models.GraphQLOperation, error) {
+	var o models.GraphQLOperation
+	err := scanner.Scan(&o.ID, &o.GraphQLEndpointID, &o.EndpointURL, &o.TargetID, &o.OperationType, &o.OperationName, &o.Query, &o.QueryHash,
+		&o.PersistedQueryHash, &o.Variables, &o.LastHTTPTrafficLogID, &o.SeenCount, &o.FirstSeenAt, &o.LastSeenAt)
+	return o, err
+}
+
+// GetOrCreateGraphQLEndpoint returns the ID of the GraphQL endpoint for a target and URL, creating it if needed.
+func GetOrCreateGraphQLEndpoint(targetID sql.NullInt64, url string) (int64, error) {
+	// INSERT OR IGNORE keeps this safe when several logged requests race for the same endpoint.
+	if _, err := DB.Exec(`INSERT OR IGNORE INTO graphql_endpoints (target_id, url) VALUES (?, ?)`, targetID, url); err != nil {
+		return 0, fmt.Errorf("inserting graphql endpoint %s: %w", url, err)
+	}
+	var id int64
+	if err := DB.QueryRow(`SELECT id FROM graphql_endpoints WHERE IFNULL(target_id, 0) = ? AND url = ?`, targetID.Int64, url).Scan(&id); err != nil {
+		return 0, fmt.Errorf("looking up graphql endpoint %s: %w", url, err)
+	}
+	return id, nil
+}
+
+// RecordGraphQLOperation stores an operation seen in traffic. Operations are unique per endpoint,
+// query hash and operation name; seeing one again bumps its count and keeps the latest variables.
+func RecordGraphQLOperation(op models.GraphQLOperation) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO graphql_operations (graphql_endpoint_id, target_id, operation_type, operation_name, query, query_hash,
+			persisted_query_hash, variables, last_http_traffic_log_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(graphql_endpoint_id, query_hash, operation_name) DO UPDATE SET
+			seen_count = seen_count + 1,
+			variables = excluded.variables,
+			last_http_traffic_log_id = excluded.last_http_traffic_log_id,
+			last_seen_at = CURRENT_TIMESTAMP`,
+		op.GraphQLEndpointID, op.TargetID, op.OperationType, op.OperationName, op.Query, op.QueryHash,
+		op.PersistedQueryHash, op.Variables, op.LastHTTPTrafficLogID)
+	if err != nil {
+		return 0, fmt.Errorf("upserting graphql operation: %w", err)
+	}
+	var id int64
+	if err := tx.QueryRow(`SELECT id FROM graphql_operations WHERE graphql_endpoint_id = ? AND query_hash = ? AND operation_name = ?`,
+		op.GraphQLEndpointID, op.QueryHash, op.OperationName).Scan(&id); err != nil {
+		return 0, fmt.Errorf("reading back graphql operation: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE graphql_endpoints SET last_seen_at = CURRENT_TIMESTAMP,
+		operation_count = (SELECT COUNT(*) FROM graphql_operations WHERE graphql_endpoint_id = ?) WHERE id = ?`,
+		op.GraphQLEndpointID, op.GraphQLEndpointID); err != nil {
+		return 0, fmt.Errorf("updating graphql endpoint %d: %w", op.GraphQLEndpointID, err)
+	}
+	return id, tx.Commit()
+}
+
+// StoreGraphQLSchema saves an introspection result for an endpoint and clears any previous introspection error.
+// source is "captured" or "introspection".
+func StoreGraphQLSchema(endpointID int64, schemaJSON string, source string) error {
+	_, err := DB.Exec(`UPDATE graphql_endpoints SET schema_json = ?, schema_source = ?, schema_fetched_at = CURRENT_TIMESTAMP,
+		introspection_error = NULL WHERE id = ?`, schemaJSON, source, endpointID)
+	if err != nil {
+		return fmt.Errorf("storing schema for graphql endpoint %d: %w", endpointID, err)
+	}
+	return nil
+}
+
+// SetGraphQLIntrospectionError records why introspection against an endpoint failed.
+func SetGraphQLIntrospectionError(endpointID int64, message string) error {
+	_, err := DB.Exec(`UPDATE graphql_endpoints SET introspection_error = ? WHERE id = ?`, message, endpointID)
+	if err != nil {
+		return fmt.Errorf("updating graphql endpoint %d: %w", endpointID, err)
+	}
+	return nil
+}
+
+// GetGraphQLEndpoints lists GraphQL endpoints, most recently seen first.
+func GetGraphQLEndpoints(targetID int64) ([]models.GraphQLEndpoint, error) {
+	query := `SELECT ` + graphQLEndpointColumns + ` FROM graphql_endpoints`
+	var args []interface{}
+	if targetID != 0 {
+		query += " WHERE target_id = ?"
+		args = append(args, targetID)
+	}
+	query += " ORDER BY last_seen_at DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying graphql endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := []models.GraphQLEndpoint{}
+	for rows.Next() {
+		e, err := scanGraphQLEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning graphql endpoint: %w", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+// GetGraphQLEndpointByID fetches a GraphQL endpoint (without its schema).
+func GetGraphQLEndpointByID(id int64) (models.GraphQLEndpoint, error) {
+	e, err := scanGraphQLEndpoint(DB.QueryRow(`SELECT `+graphQLEndpointColumns+` FROM graphql_endpoints WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return e, fmt.Errorf("GraphQL endpoint with ID %d not found", id)
+		}
+		return e, fmt.Errorf("scanning graphql endpoint %d: %w", id, err)
+	}
+	return e, nil
+}
+
+// GetGraphQLSchema returns the stored introspection JSON of an endpoint ("" if none).
+func GetGraphQLSchema(endpointID int64) (string, error) {
+	var schema sql.NullString
+	err := DB.QueryRow(`SELECT schema_json FROM graphql_endpoints WHERE id = ?`, endpointID).Scan(&schema)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("GraphQL endpoint with ID %d not found", endpointID)
+		}
+		return "", fmt.Errorf("querying schema of graphql endpoint %d: %w", endpointID, err)
+	}
+	return schema.String, nil
+}
+
+// GetGraphQLOperations lists GraphQL operations with filters and pagination, most recently seen first.
+func GetGraphQLOperations(filters models.GraphQLOperationFilters) ([]models.GraphQLOperation, int64, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "o.target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.EndpointID != 0 {
+		conditions = append(conditions, "o.graphql_endpoint_id = ?")
+		args = append(args, filters.EndpointID)
+	}
+	if filters.OperationType != "" {
+		conditions = append(conditions, "o.operation_type = ?")
+		args = append(args, filters.OperationType)
+	}
+	if filters.Search != "" {
+		conditions = append(conditions, "(o.operation_name LIKE ? OR o.query LIKE ?)")
+		args = append(args, "%"+filters.Search+"%", "%"+filters.Search+"%")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	from := ` FROM graphql_operations o JOIN graphql_endpoints e ON e.id = o.graphql_endpoint_id`
+
+	var total int64
+	if err := DB.QueryRow("SELECT COUNT(*)"+from+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting graphql operations: %w", err)
+	}
+	if filters.Limit <= 0 {
+		filters.Limit = 50
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	rows, err := DB.Query(`SELECT `+graphQLOperationColumns+from+where+` ORDER BY o.last_seen_at DESC, o.id DESC LIMIT ? OFFSET ?`,
+		append(args, filters.Limit, (filters.Page-1)*filters.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying graphql operations: %w", err)
+	}
+	defer rows.Close()
+
+	operations := []models.GraphQLOperation{}
+	for rows.Next() {
+		o, err := scanGraphQLOperation(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning graphql operation: %w", err)
+		}
+		operations = append(operations, o)
+	}
+	return operations, total, rows.Err()
+}
+
+// GetGraphQLOperationByID fetches a GraphQL operation.
+func GetGraphQLOperationByID(id int64) (models.GraphQLOperation, error) {
+	o, err := scanGraphQLOperation(DB.QueryRow(`SELECT `+graphQLOperationColumns+`
+		FROM graphql_operations o JOIN graphql_endpoints e ON e.id = o.graphql_endpoint_id WHERE o.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return o, fmt.Errorf("GraphQL operation with ID %d not found", id)
+		}
+		return o, fmt.Errorf("scanning graphql operation %d: %w", id, err)
+	}
+	return o, nil
+}
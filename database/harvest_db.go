@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+// LogHarvestedResponse saves a response fetched by the harvester to http_traffic_log, so the URLs
+// extracted from it can reference it in discovered_urls.
+func LogHarvestedResponse(logEntry *models.HTTPTrafficLog) (int64, error) {
+	id, err := insertTrafficLog(nil, logEntry.ResponseBody, func(tx *sql.Tx, b trafficBodies) (sql.Result, error) {
+		return tx.Exec(`INSERT INTO http_traffic_log (
+			target_id, timestamp, request_method, request_url, request_full_url_with_fragment,
+			response_status_code, response_headers, response_body, response_body_blob_id, response_content_type,
+			response_body_size, duration_ms, is_https, log_source
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			logEntry.TargetID, logEntry.Timestamp, logEntry.RequestMethod, logEntry.RequestURL, logEntry.RequestURL,
+			logEntry.ResponseStatusCode, logEntry.ResponseHeaders, b.response, b.responseBlob, logEntry.ResponseContentType,
+			logEntry.ResponseBodySize, logEntry.DurationMs, logEntry.IsHTTPS, models.NullString(models.HarvesterLogSource),
+		)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("logging harvested response %s: %w", logEntry.RequestURL.String, err)
+	}
+	return id, nil
+}
+
+// InsertDiscoveredURLs adds URLs extracted from a logged response to the discovered URL inventory
+// and records them in the target's canonical URLs. URLs whose canonical form the target already
+// has with the same source are skipped. Without a target (targetID 0) the URLs are only
+// deduplicated within the log entry. It returns how many were new.
+func InsertDiscoveredURLs(targetID, logID int64, sourceType string, urls []models.CanonicalURLEntry) (int, error) {
+	if len(urls) == 0 {
+		return 0, nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting discovered URL transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO discovered_urls (target_id, http_traffic_log_id, url, canonical_url, source_type)
+		SELECT ?1, ?2, ?3, ?4, ?5 WHERE NOT EXISTS (
+			SELECT 1 FROM discovered_urls WHERE (target_id = ?1 OR (?1 IS NULL AND http_traffic_log_id = ?2))
+				AND (canonical_url = ?4 OR (canonical_url IS NULL AND url = ?3)) AND source_type = ?5)`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing discovered URL insert: %w", err)
+	}
+	defer stmt.Close()
+	var targetArg interface{}
+	if targetID != 0 {
+		targetArg = targetID
+	}
+	added := 0
+	for _, u := range urls {
+		res, err := stmt.Exec(targetArg, logID, u.URL, u.CanonicalURL, sourceType)
+		if err != nil {
+			return added, fmt.Errorf("inserting discovered URL '%s': %w", u.URL, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	if targetID != 0 {
+		if _, err := recordCanonicalURLs(tx, targetID, sourceType, urls); err != nil {
+			return added, err
+		}
+	}
+	return added, tx.Commit()
+}
+
+// SaveDomainHarvest stores the latest harvest of a domain, replacing the previous one.
+func SaveDomainHarvest(h models.DomainHarvest) error {
+	disallowed, _ := json.Marshal(nonNilStrings(h.DisallowedPaths))
+	sitemaps, _ := json.Marshal(nonNilStrings(h.Sitemaps))
+	contacts, _ := json.Marshal(nonNilStrings(h.SecurityContacts))
+	errs, _ := json.Marshal(nonNilStrings(h.Errors))
+	fields := h.SecurityFields
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	fieldsJSON, _ := json.Marshal(fields)
+	_, err := DB.Exec(`INSERT INTO domain_harvests (domain_id, target_id, base_url, robots_status, robots_txt, disallowed_paths, sitemaps,
+			sitemap_url_count, security_txt_url, security_txt, security_contacts, security_fields, errors, harvested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(domain_id) DO UPDATE SET base_url = excluded.base_url, robots_status = excluded.robots_status,
+			robots_txt = excluded.robots_txt, disallowed_paths = excluded.disallowed_paths, sitemaps = excluded.sitemaps,
+			sitemap_url_count = excluded.sitemap_url_count, security_txt_url = excluded.security_txt_url,
+			security_txt = excluded.security_txt, security_contacts = excluded.security_contacts,
+			security_fields = excluded.security_fields, errors = excluded.errors, harvested_at = CURRENT_TIMESTAMP`,
+		h.DomainID, h.TargetID, h.BaseURL, h.RobotsStatus, h.RobotsTxt, string(disallowed), string(sitemaps),
+		h.SitemapURLCount, h.SecurityTxtURL, h.SecurityTxt, string(contacts), string(fieldsJSON), string(errs))
+	if err != nil {
+		return fmt.Errorf("saving harvest of domain %d: %w", h.DomainID, err)
+	}
+	return nil
+}
+
+// GetDomainHarvest returns the latest harvest of a domain.
+func GetDomainHarvest(domainID int64) (models.DomainHarvest, error) {
+	var h models.DomainHarvest
+	var robotsStatus sql.NullInt64
+	var disallowed, sitemaps, contacts, fields, errs string
+	err := DB.QueryRow(`SELECT domain_id, target_id, base_url, robots_status, robots_txt, disallowed_paths, sitemaps, sitemap_url_count,
+			security_txt_url, security_txt, security_contacts, security_fields, errors, harvested_at
+		FROM domain_harvests WHERE domain_id = ?`, domainID).
+		Scan(&h.DomainID, &h.TargetID, &h.BaseURL, &robotsStatus, &h.RobotsTxt, &disallowed, &sitemaps, &h.SitemapURLCount,
+			&h.SecurityTxtURL, &h.SecurityTxt, &contacts, &fields, &errs, &h.HarvestedAt)
+	if err == sql.ErrNoRows {
+		return h, fmt.Errorf("no harvest found for domain %d", domainID)
+	}
+	if err != nil {
+		return h, fmt.Errorf("loading harvest of domain %d: %w", domainID, err)
+	}
+	if robotsStatus.Valid {
+		status := int(robotsStatus.Int64)
+		h.RobotsStatus = &status
+	}
+	json.Unmarshal([]byte(disallowed), &h.DisallowedPaths)
+	json.Unmarshal([]byte(sitemaps), &h.Sitemaps)
+	json.Unmarshal([]byte(contacts), &h.SecurityContacts)
+	json.Unmarshal([]byte(fields), &h.SecurityFields)
+	json.Unmarshal([]byte(errs), &h.Errors)
+	return h, nil
+}
+
+// ListHarvestedURLs returns the URLs the harvester discovered from responses under baseURL.
+func ListHarvestedURLs(targetID int64, baseURL string) ([]models.HarvestedURL, error) {
+	like := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.TrimRight(baseURL, "/")) + "/%"
+	rows, err := DB.Query(`SELECT du.url, du.source_type, MIN(du.discovered_at)
+		FROM discovered_urls du JOIN http_traffic_log l ON l.id = du.http_traffic_log_id
+		WHERE du.target_id = ? AND l.log_source = ? AND l.request_url LIKE ? ESCAPE '\'
+		GROUP BY du.url, du.source_type ORDER BY du.source_type, du.url`, targetID, models.HarvesterLogSource, like)
+	if err != nil {
+		return nil, fmt.Errorf("listing harvested URLs of %s: %w", baseURL, err)
+	}
+	defer rows.Close()
+	urls := []models.HarvestedURL{}
+	for rows.Next() {
+		var u models.HarvestedURL
+		var discoveredAt string
+		if err := rows.Scan(&u.URL, &u.SourceType, &discoveredAt); err != nil {
+			return nil, fmt.Errorf("scanning harvested URL: %w", err)
+		}
+		// MIN() loses the column's DATETIME type, so the driver hands back SQLite's text format.
+		u.DiscoveredAt, _ = time.Parse("2006-01-02 15:04:05", discoveredAt)
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
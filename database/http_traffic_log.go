@@ -12,116 +12,139 @@ import (
 	"toolkit/models"
 )
 
-// GetHTTPTrafficLogEntries retrieves paginated and filtered HTTP traffic log entries.
-// It's a simplified version of what's in GetTrafficLogHandler, focused on fetching.
-func GetHTTPTrafficLogEntries(filters models.ProxyLogFilters) ([]models.HTTPTrafficLog, int64, error) {
-	var logs []models.HTTPTrafficLog
-	var totalRecords int64
-
-	if filters.TargetID == 0 {
-		return nil, 0, fmt.Errorf("TargetID is required to fetch HTTP traffic logs")
-	}
+// TrafficLogSort lists the columns traffic log listings can be sorted by; newest first by default.
+// Queries select from http_traffic_log as htl joined with pages as p.
+var TrafficLogSort = SortSpec{
+	Columns: map[string]string{
+		"id":                    "htl.id",
+		"timestamp":             "htl.timestamp",
+		"request_method":        "htl.request_method",
+		"request_url":           "htl.request_url",
+		"response_status_code":  "htl.response_status_code",
+		"response_content_type": "htl.response_content_type",
+		"response_body_size":    "htl.response_body_size",
+		"page_sitemap_id":       "htl.page_sitemap_id",
+		"page_sitemap_name":     "p.name",
+		"duration_ms":           "htl.duration_ms",
+		"tags":                  "(SELECT GROUP_CONCAT(t_sort.name ORDER BY t_sort.name ASC) FROM tags t_sort JOIN tag_associations ta_sort ON t_sort.id = ta_sort.tag_id WHERE ta_sort.item_id = htl.id AND ta_sort.item_type = 'httplog')",
+	},
+	Default:      "timestamp",
+	DefaultOrder: "DESC",
+	TieBreaker:   "htl.id",
+}
 
-	// Select from http_traffic_log and LEFT JOIN pages
-	selectBase := "SELECT htl.id, htl.target_id, htl.timestamp, htl.request_method, htl.request_url, htl.request_full_url_with_fragment, htl.response_status_code, htl.response_content_type, htl.response_body_size, htl.duration_ms, htl.is_favorite, htl.log_source, htl.page_sitemap_id, p.name AS page_sitemap_name"
-	fromAndJoinBase := "FROM http_traffic_log htl LEFT JOIN pages p ON htl.page_sitemap_id = p.id"
-	whereClauses := []string{"htl.target_id = ?"} // Explicitly use htl.target_id
-	args := []interface{}{filters.TargetID}
-	countArgs := []interface{}{filters.TargetID} // Initialize countArgs here
+// TrafficLogKeyset pages traffic log listings by cursor, on the capture time.
+var TrafficLogKeyset = Keyset{Table: "http_traffic_log", Columns: []string{"timestamp"}}
 
+// TrafficLogConditions returns the conditions selecting the log entries that match filters (of
+// filters.TargetID unless it is 0). alias qualifies the columns: "htl" for queries joining other
+// tables, "" for queries on http_traffic_log alone. Non-numeric status filters are ignored; an
+// unknown origin is an error.
+func TrafficLogConditions(alias string, filters models.ProxyLogFilters) (Conditions, error) {
+	col := func(name string) string {
+		if alias == "" {
+			return name
+		}
+		return alias + "." + name
+	}
+	var c Conditions
+	if filters.TargetID != 0 {
+		c.Add(col("target_id")+" = ?", filters.TargetID)
+	}
 	if filters.FilterFavoritesOnly {
-		whereClauses = append(whereClauses, "is_favorite = TRUE")
+		c.Add(col("is_favorite") + " = TRUE")
 	}
-	if filters.FilterMethod != "" {
-		whereClauses = append(whereClauses, "UPPER(request_method) = ?")
-		args = append(args, strings.ToUpper(filters.FilterMethod))
+	if method := strings.ToUpper(strings.TrimSpace(filters.FilterMethod)); method != "" {
+		c.Add("UPPER("+col("request_method")+") = ?", method)
 	}
 	if filters.FilterStatus != "" {
-		statusCode, err := strconv.ParseInt(filters.FilterStatus, 10, 64)
-		if err == nil {
-			whereClauses = append(whereClauses, "response_status_code = ?")
-			args = append(args, statusCode)
+		if statusCode, err := strconv.ParseInt(filters.FilterStatus, 10, 64); err == nil {
+			c.Add(col("response_status_code")+" = ?", statusCode)
 		}
 	}
 	if filters.FilterContentType != "" {
-		whereClauses = append(whereClauses, "LOWER(response_content_type) LIKE LOWER(?)")
-		args = append(args, "%"+filters.FilterContentType+"%")
-	}
-	logger.Debug("GetHTTPTrafficLogEntries: FilterDomain received: '%s'", filters.FilterDomain)
-	// Filter by domain (hostname part of the URL)
-	if filters.FilterDomain != "" {
-		// This pattern attempts to match the exact domain name as the hostname.
-		// It covers:
-		// - http://domain.com
-		// - https://domain.com
-		// - http://domain.com/path
-		// - https://domain.com?query
-		// - http://domain.com:port
-		// - https://domain.com:port
-		whereClauses = append(whereClauses, `(
-            request_url LIKE 'http://' || ? || '%' OR
-            request_url LIKE 'https://' || ? || '%'
-        )`)
-		args = append(args, filters.FilterDomain, filters.FilterDomain)
-		countArgs = append(countArgs, filters.FilterDomain, filters.FilterDomain)
+		c.Add("LOWER("+col("response_content_type")+") LIKE LOWER(?)", "%"+filters.FilterContentType+"%")
+	}
+	if filters.FilterOrigin != "" {
+		condition, ok := TrafficOriginCondition(col("log_source"), filters.FilterOrigin)
+		if !ok {
+			return c, fmt.Errorf("invalid origin '%s', expected '%s' or '%s'", filters.FilterOrigin, models.TrafficOriginManual, models.TrafficOriginAutomated)
+		}
+		c.Add(condition)
+	}
+	if filters.FilterSource != "" {
+		c.Add("COALESCE(NULLIF("+col("log_source")+", ''), ?) = ?", models.LogSourceProxy, filters.FilterSource)
+	}
+	if domain := filters.FilterDomain; domain != "" {
+		// The domain must be the whole host of the URL, with or without a port.
+		u := col("request_url")
+		c.Add("("+u+" LIKE 'http://' || ? || '/%' OR "+u+" LIKE 'https://' || ? || '/%' OR "+
+			u+" = 'http://' || ? OR "+u+" = 'https://' || ? OR "+
+			u+" LIKE 'http://' || ? || ':%' OR "+u+" LIKE 'https://' || ? || ':%')",
+			domain, domain, domain, domain, domain, domain)
+	}
+	if filters.FilterURLContains != "" {
+		c.Add(col("request_url")+" LIKE ?", "%"+filters.FilterURLContains+"%")
 	}
 	if filters.FilterSearchText != "" {
-		// Expand search to include headers and bodies.
-		// SQLite's LIKE operator works on BLOBs containing text.
-		searchClause := `(
-			LOWER(request_url) LIKE LOWER(?) OR
-			UPPER(request_method) LIKE UPPER(?) OR
-			LOWER(response_content_type) LIKE LOWER(?) OR
-			CAST(response_status_code AS TEXT) LIKE ? OR
-			LOWER(request_headers) LIKE LOWER(?) OR -- Search request headers (JSON string)
-			request_body LIKE ? OR -- Search request body (BLOB, assumes text content)
-			LOWER(response_headers) LIKE LOWER(?) OR -- Search response headers (JSON string)
-			response_body LIKE ? -- Search response body (BLOB, assumes text content)
-		)`
-		whereClauses = append(whereClauses, searchClause)
-		searchPattern := "%" + filters.FilterSearchText + "%"
-		args = append(args, searchPattern, searchPattern, searchPattern, searchPattern)
-	}
-
-	finalWhereClause := ""
-	if len(whereClauses) > 0 {
-		finalWhereClause = "WHERE " + strings.Join(whereClauses, " AND ")
-	}
-
-	countQuery := fmt.Sprintf("SELECT COUNT(htl.id) %s %s", fromAndJoinBase, finalWhereClause)
-	err := DB.QueryRow(countQuery, args...).Scan(&totalRecords)
+		pattern := "%" + filters.FilterSearchText + "%"
+		c.Add("(LOWER("+col("request_url")+") LIKE LOWER(?) OR UPPER("+col("request_method")+") LIKE UPPER(?) OR LOWER("+
+			col("response_content_type")+") LIKE LOWER(?) OR CAST("+col("response_status_code")+" AS TEXT) LIKE ?)",
+			pattern, pattern, pattern, pattern)
+	}
+	if filters.FilterBodyContains != "" {
+		// The beautified copy matches across what minification put on one line or spaced out.
+		table := alias
+		if table == "" {
+			table = "http_traffic_log"
+		}
+		pattern := "%" + filters.FilterBodyContains + "%"
+		c.Add("(CAST("+TrafficBodyExpr(table, "response_body")+" AS TEXT) LIKE ? OR EXISTS (SELECT 1 FROM beautified_bodies bb WHERE bb.id = "+
+			col("response_beautified_id")+" AND CAST(bb.data AS TEXT) LIKE ?))", pattern, pattern)
+	}
+	if filters.CaptureSessionID != 0 {
+		table := alias
+		if table == "" {
+			table = "http_traffic_log"
+		}
+		c.Add("EXISTS (SELECT 1 FROM capture_sessions cs WHERE cs.id = ? AND "+captureSessionWindow(table, "cs")+")", filters.CaptureSessionID)
+	}
+	if len(filters.FilterTagIDs) > 0 {
+		var tags Conditions
+		tags.AddIn("tag_id", filters.FilterTagIDs)
+		c.Add(col("id")+" IN (SELECT DISTINCT item_id FROM tag_associations WHERE item_type = 'httplog' AND "+tags.SQL()+")", tags.Args()...)
+	}
+	return c, nil
+}
+
+// GetHTTPTrafficLogEntries retrieves a page of the log entries of a target matching filters, and
+// how many match. Filters, sorting and pages work as in the traffic log API.
+func GetHTTPTrafficLogEntries(filters models.ProxyLogFilters) ([]models.HTTPTrafficLog, int64, error) {
+	var logs []models.HTTPTrafficLog
+	var totalRecords int64
+
+	if filters.TargetID == 0 {
+		return nil, 0, fmt.Errorf("TargetID is required to fetch HTTP traffic logs")
+	}
+	conditions, err := TrafficLogConditions("htl", filters)
 	if err != nil {
-		logger.Error("GetHTTPTrafficLogEntries: Error counting records: %v", err)
 		return nil, 0, err
 	}
+	fromAndJoin := "FROM http_traffic_log htl LEFT JOIN pages p ON htl.page_sitemap_id = p.id " + conditions.Where()
 
+	if err := DB.QueryRow("SELECT COUNT(htl.id) "+fromAndJoin, conditions.Args()...).Scan(&totalRecords); err != nil {
+		logger.Error("GetHTTPTrafficLogEntries: Error counting records: %v", err)
+		return nil, 0, err
+	}
 	if totalRecords == 0 {
 		return logs, 0, nil
 	}
 
-	sortOrder := "DESC"
-	if strings.ToUpper(filters.SortOrder) == "ASC" {
-		sortOrder = "ASC"
-	}
-	orderBy := "timestamp" // Default sort
-	// Add page_sitemap_name to valid sort columns, mapping to p.name
-	validSortColumns := map[string]string{
-		"id": "htl.id", "timestamp": "htl.timestamp", "request_method": "htl.request_method", // Prefix with htl
-		"request_url": "htl.request_url", "response_status_code": "htl.response_status_code",
-		"response_content_type": "htl.response_content_type", "response_body_size": "htl.response_body_size",
-		"page_sitemap_id": "htl.page_sitemap_id", "page_sitemap_name": "p.name", // p.name is already specific
-		"duration_ms": "htl.duration_ms",
-	}
-	if col, ok := validSortColumns[filters.SortBy]; ok {
-		orderBy = col
-	} else {
-		orderBy = "htl.timestamp" // Default to htl.timestamp if sortBy is invalid
-	}
-
-	// Define query and queryArgs before they are potentially used in logging
-	query := fmt.Sprintf("%s %s %s ORDER BY %s %s, htl.id %s LIMIT ? OFFSET ?",
-		selectBase, fromAndJoinBase, finalWhereClause, orderBy, sortOrder, sortOrder)
-	queryArgs := append(args, filters.Limit, (filters.Page-1)*filters.Limit)
+	page := NewPage(filters.Page, filters.Limit, 20, 0, false)
+	limitClause, limitArgs := page.LimitClause()
+	query := "SELECT htl.id, htl.target_id, htl.timestamp, htl.request_method, htl.request_url, htl.request_full_url_with_fragment, htl.response_status_code, htl.response_content_type, htl.response_body_size, htl.duration_ms, htl.is_favorite, htl.log_source, htl.page_sitemap_id, p.name AS page_sitemap_name " +
+		fromAndJoin + " " + TrafficLogSort.OrderBy(filters.SortBy, filters.SortOrder) + limitClause
+	queryArgs := conditions.Args(limitArgs...)
 
 	rows, err := DB.Query(query, queryArgs...)
 	if err != nil {
@@ -134,11 +157,7 @@ func GetHTTPTrafficLogEntries(filters models.ProxyLogFilters) ([]models.HTTPTraf
 		var u models.HTTPTrafficLog
 		var timestampStr string
 		if err := rows.Scan(&u.ID, &u.TargetID, &timestampStr, &u.RequestMethod, &u.RequestURL, &u.RequestFullURLWithFragment, &u.ResponseStatusCode, &u.ResponseContentType, &u.ResponseBodySize, &u.DurationMs, &u.IsFavorite, &u.LogSource, &u.PageSitemapID, &u.PageSitemapName); err != nil {
-			// If RequestMethod or RequestURL are now sql.NullString, this Scan will fail if they are not sql.NullString in the struct.
-			// Assuming the struct models.HTTPTrafficLog is updated, this Scan should be fine.
-			// The fields u.RequestMethod and u.RequestURL are now sql.NullString.
 			logger.Error("GetHTTPTrafficLogEntries: Error scanning row for log ID %d: %v", u.ID, err)
-			// Decide whether to return partial results or error out
 			continue
 		}
 		parsedTime, _ := time.Parse(time.RFC3339, timestampStr) // Error handling for time parsing can be added
@@ -148,15 +167,69 @@ func GetHTTPTrafficLogEntries(filters models.ProxyLogFilters) ([]models.HTTPTraf
 	return logs, totalRecords, rows.Err()
 }
 
+// GetTrafficLogsAfter returns up to limit log entries matching c (conditions on http_traffic_log
+// alone) with an ID above afterID, oldest first, without headers and bodies.
+func GetTrafficLogsAfter(c Conditions, afterID int64, limit int) ([]models.HTTPTrafficLog, error) {
+	rows, err := DB.Query(`SELECT id, target_id, timestamp, request_method, request_url, response_status_code,
+			response_content_type, response_body_size, duration_ms, is_https, log_source
+		FROM http_traffic_log WHERE `+c.SQL()+` AND id > ? ORDER BY id LIMIT ?`, c.Args(afterID, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("querying log entries after %d: %w", afterID, err)
+	}
+	defer rows.Close()
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		var timestamp string
+		var status, size, duration sql.NullInt64
+		var isHTTPS sql.NullBool
+		if err := rows.Scan(&e.ID, &e.TargetID, &timestamp, &e.RequestMethod, &e.RequestURL, &status,
+			&e.ResponseContentType, &size, &duration, &isHTTPS, &e.LogSource); err != nil {
+			return nil, fmt.Errorf("scanning log entry: %w", err)
+		}
+		if e.Timestamp, err = time.Parse(time.RFC3339, timestamp); err != nil {
+			e.Timestamp, _ = time.Parse("2006-01-02 15:04:05", timestamp)
+		}
+		e.ResponseStatusCode = int(status.Int64)
+		e.ResponseBodySize = size.Int64
+		e.DurationMs = duration.Int64
+		e.IsHTTPS = isHTTPS.Bool
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// TrafficTailStart returns the ID a tail of the log entries matching c starts after so that it
+// shows the last n of them first: 0 when fewer match, the latest entry's ID when n is 0.
+func TrafficTailStart(c Conditions, n int) (int64, error) {
+	var id int64
+	if n <= 0 {
+		if err := DB.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM http_traffic_log`).Scan(&id); err != nil {
+			return 0, fmt.Errorf("looking up the latest log entry: %w", err)
+		}
+		return id, nil
+	}
+	err := DB.QueryRow(`SELECT id FROM http_traffic_log `+c.Where()+` ORDER BY id DESC LIMIT 1 OFFSET ?`, c.Args(n)...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("looking up the last %d log entries: %w", n, err)
+	}
+	return id, nil
+}
+
 // GetHTTPTrafficLogEntryByID retrieves a single HTTP traffic log entry by its ID.
 func GetHTTPTrafficLogEntryByID(id int64) (models.HTTPTrafficLog, error) {
 	var log models.HTTPTrafficLog
 	// Select all fields that might be needed for the modifier's base request
 	query := `SELECT htl.id, htl.target_id, htl.timestamp, htl.request_method, htl.request_url, htl.request_full_url_with_fragment, 
-	                 htl.request_http_version, htl.request_headers, htl.request_body, 
+	                 htl.request_http_version, htl.request_headers, ` + htlRequestBody + `, 
 	                 htl.response_status_code, htl.response_content_type, htl.response_body_size, htl.response_http_version, 
-	                 htl.response_headers, htl.response_body, htl.duration_ms, htl.is_favorite, htl.notes, 
-	                 htl.log_source, htl.page_sitemap_id, p.name AS page_sitemap_name
+	                 htl.response_headers, ` + htlResponseBody + `, htl.duration_ms, htl.is_favorite, htl.notes, 
+	                 htl.log_source, htl.page_sitemap_id, p.name AS page_sitemap_name,
+	                 htl.replay_batch_id, htl.replay_source_log_id, htl.response_body_sha256, htl.response_body_truncated,
+	                 htl.response_content_encoding, htl.cached_from_log_id, htl.tls_connection_id
 	          FROM http_traffic_log htl LEFT JOIN pages p ON htl.page_sitemap_id = p.id WHERE htl.id = ?`
 	var timestampStr string
 	err := DB.QueryRow(query, id).Scan(
@@ -166,7 +239,9 @@ func GetHTTPTrafficLogEntryByID(id int64) (models.HTTPTrafficLog, error) {
 		&log.RequestBody,
 		&log.ResponseStatusCode, &log.ResponseContentType, &log.ResponseBodySize, &log.ResponseHTTPVersion, &log.ResponseHeaders, &log.ResponseBody,
 		&log.DurationMs, &log.IsFavorite, &log.Notes, &log.LogSource, &log.PageSitemapID,
-		&log.PageSitemapName) // Scan the page name
+		&log.PageSitemapName, // Scan the page name
+		&log.ReplayBatchID, &log.ReplaySourceLogID, &log.ResponseBodySHA256, &log.ResponseBodyTruncated,
+		&log.ResponseContentEncoding, &log.CachedFromLogID, &log.TLSConnectionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			logger.Info("GetHTTPTrafficLogEntryByID: No log entry found for ID %d", id)
@@ -215,33 +290,47 @@ func GetHTTPTrafficLogEntryByID(id int64) (models.HTTPTrafficLog, error) {
 	return log, nil
 }
 
+// SetHTTPTrafficLogFavorite marks or unmarks a log entry as favorite.
+func SetHTTPTrafficLogFavorite(logID int64, isFavorite bool) error {
+	result, err := DB.Exec("UPDATE http_traffic_log SET is_favorite = ? WHERE id = ?", isFavorite, logID)
+	if err != nil {
+		return fmt.Errorf("updating favorite status of log entry %d: %w", logID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("log entry with ID %d not found", logID)
+	}
+	return nil
+}
+
 // LogExecutedModifierRequest saves an HTTPTrafficLog entry generated from the modifier.
 func LogExecutedModifierRequest(logEntry *models.HTTPTrafficLog) (int64, error) {
 	if DB == nil {
 		logger.Error("LogExecutedModifierRequest: Database is not initialized.")
 		return 0, fmt.Errorf("database not initialized")
 	}
-	result, err := DB.Exec(`INSERT INTO http_traffic_log (
-		target_id, timestamp, request_method, request_url, request_http_version, request_headers, request_body, request_full_url_with_fragment,
-		response_status_code, response_reason_phrase, response_http_version, response_headers, response_body, response_content_type,
-		response_body_size, duration_ms, client_ip, is_https, is_page_candidate, notes, source_modifier_task_id,
-		log_source, page_sitemap_id
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, // Added placeholders
-		logEntry.TargetID, logEntry.Timestamp, logEntry.RequestMethod, logEntry.RequestURL,
-		logEntry.RequestHTTPVersion, logEntry.RequestHeaders, logEntry.RequestBody,
-		logEntry.RequestFullURLWithFragment, // Ensure this is passed if applicable
-		logEntry.ResponseStatusCode, logEntry.ResponseReasonPhrase, logEntry.ResponseHTTPVersion,
-		logEntry.ResponseHeaders, logEntry.ResponseBody, logEntry.ResponseContentType,
-		logEntry.ResponseBodySize, logEntry.DurationMs, logEntry.ClientIP, logEntry.IsHTTPS,
-		logEntry.IsPageCandidate, logEntry.Notes, logEntry.SourceModifierTaskID, // Existing fields
-		models.NullString("Modifier"), sql.NullInt64{Valid: false}, // Set log_source to "Modifier", page_sitemap_id to NULL
-	)
+	id, err := insertTrafficLog(logEntry.RequestBody, logEntry.ResponseBody, func(tx *sql.Tx, b trafficBodies) (sql.Result, error) {
+		return tx.Exec(`INSERT INTO http_traffic_log (
+			target_id, timestamp, request_method, request_url, request_http_version, request_headers, request_body, request_body_blob_id,
+			request_full_url_with_fragment, response_status_code, response_reason_phrase, response_http_version, response_headers,
+			response_body, response_body_blob_id, response_content_type, response_body_size, duration_ms, client_ip, is_https,
+			is_page_candidate, notes, source_modifier_task_id, log_source, page_sitemap_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			logEntry.TargetID, logEntry.Timestamp, logEntry.RequestMethod, logEntry.RequestURL,
+			logEntry.RequestHTTPVersion, logEntry.RequestHeaders, b.request, b.requestBlob,
+			logEntry.RequestFullURLWithFragment, // Ensure this is passed if applicable
+			logEntry.ResponseStatusCode, logEntry.ResponseReasonPhrase, logEntry.ResponseHTTPVersion,
+			logEntry.ResponseHeaders, b.response, b.responseBlob, logEntry.ResponseContentType,
+			logEntry.ResponseBodySize, logEntry.DurationMs, logEntry.ClientIP, logEntry.IsHTTPS,
+			logEntry.IsPageCandidate, logEntry.Notes, logEntry.SourceModifierTaskID, // Existing fields
+			models.NullString(models.LogSourceModifier), sql.NullInt64{Valid: false}, // Set log_source to "Modifier", page_sitemap_id to NULL
+		)
+	})
 	// Note: is_favorite defaults to FALSE in schema, not explicitly set here.
 	if err != nil {
 		logger.Error("DB log error for modified request (%s %s): %v", logEntry.RequestMethod.String, logEntry.RequestURL.String, err)
 		return 0, err
 	}
-	return result.LastInsertId()
+	return id, nil
 }
 
 // GetDistinctDomainsFromLogs retrieves a list of distinct hostnames (domains)
@@ -295,3 +384,212 @@ func GetDistinctDomainsFromLogs(targetID int64) ([]string, error) {
 	logger.Debug("GetDistinctDomainsFromLogs: Finished processing for targetID %d. Total distinct URLs processed: %d. Total hostnames extracted: %d. Final distinct domains: %d.", targetID, processedURLsCount, parsedHostnamesCount, len(domains))
 	return domains, nil
 }
+
+// ForEachTargetResponseHeaders calls fn for every captured response of a target, in ID order,
+// with only the ID, URL, status, Content-Type, response headers and HTTPS flag loaded.
+func ForEachTargetResponseHeaders(targetID int64, fn func(models.HTTPTrafficLog)) error {
+	rows, err := DB.Query(`SELECT id, request_url, response_status_code, response_content_type, response_headers, is_https
+		FROM http_traffic_log WHERE target_id = ? AND response_status_code > 0 ORDER BY id`, targetID)
+	if err != nil {
+		return fmt.Errorf("querying response headers of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		var isHTTPS sql.NullBool
+		if err := rows.Scan(&e.ID, &e.RequestURL, &e.ResponseStatusCode, &e.ResponseContentType, &e.ResponseHeaders, &isHTTPS); err != nil {
+			return fmt.Errorf("scanning response headers: %w", err)
+		}
+		e.IsHTTPS = isHTTPS.Bool
+		fn(e)
+	}
+	return rows.Err()
+}
+
+// ForEachTargetResponseMetrics calls fn with the size and duration of the latest limit responses
+// of a target, oldest first. DurationMs is -1 for responses whose duration was not recorded.
+// Bodies and headers are not loaded.
+func ForEachTargetResponseMetrics(targetID int64, limit int, fn func(models.HTTPTrafficLog)) error {
+	rows, err := DB.Query(`SELECT id, timestamp, request_method, request_url, response_status_code, response_body_size, duration_ms, log_source
+		FROM (SELECT * FROM http_traffic_log WHERE target_id = ? AND response_status_code > 0 ORDER BY id DESC LIMIT ?)
+		ORDER BY id`, targetID, limit)
+	if err != nil {
+		return fmt.Errorf("querying response metrics of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		var timestamp string
+		var size, duration sql.NullInt64
+		if err := rows.Scan(&e.ID, &timestamp, &e.RequestMethod, &e.RequestURL, &e.ResponseStatusCode, &size, &duration, &e.LogSource); err != nil {
+			return fmt.Errorf("scanning response metrics: %w", err)
+		}
+		e.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
+		e.ResponseBodySize = size.Int64
+		e.DurationMs = -1
+		if duration.Valid {
+			e.DurationMs = duration.Int64
+		}
+		fn(e)
+	}
+	return rows.Err()
+}
+
+// ForEachTargetBrowserRequest calls fn with the latest limit requests of a target captured by
+// the proxy (leaving out replays and the toolkit's own requests), oldest first. Response bodies
+// are only loaded for JSON responses.
+func ForEachTargetBrowserRequest(targetID int64, limit int, fn func(models.HTTPTrafficLog)) error {
+	rows, err := DB.Query(`SELECT id, request_method, request_url, request_headers, `+logRequestBody+`, response_status_code,
+			response_content_type, CASE WHEN response_content_type LIKE '%json%' THEN `+logResponseBody+` END
+		FROM (SELECT * FROM http_traffic_log
+			WHERE target_id = ? AND (log_source IS NULL OR log_source IN ('`+models.LogSourceProxy+`', '`+models.LogSourcePageSitemap+`'))
+			ORDER BY id DESC LIMIT ?) http_traffic_log
+		ORDER BY id`, targetID, limit)
+	if err != nil {
+		return fmt.Errorf("querying requests of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := models.HTTPTrafficLog{TargetID: &targetID}
+		var status sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.RequestMethod, &e.RequestURL, &e.RequestHeaders, &e.RequestBody, &status, &e.ResponseContentType, &e.ResponseBody); err != nil {
+			return fmt.Errorf("scanning request: %w", err)
+		}
+		e.ResponseStatusCode = int(status.Int64)
+		fn(e)
+	}
+	return rows.Err()
+}
+
+// LogProxyTraffic saves a request captured by the proxy, or sent by the toolkit on its behalf
+// (replays, page sitemap recording), with its response to http_traffic_log.
+func LogProxyTraffic(logEntry *models.HTTPTrafficLog) (int64, error) {
+	return insertTrafficLog(logEntry.RequestBody, logEntry.ResponseBody, func(tx *sql.Tx, b trafficBodies) (sql.Result, error) {
+		return tx.Exec(`INSERT INTO http_traffic_log (
+			target_id, timestamp, request_method, request_url, request_http_version, request_headers, request_body, request_body_blob_id,
+			request_full_url_with_fragment, response_status_code, response_reason_phrase, response_http_version, response_headers,
+			response_body, response_body_blob_id, response_content_type, response_body_size, duration_ms, client_ip, is_https,
+			is_page_candidate, notes, log_source, page_sitemap_id, replay_batch_id, replay_source_log_id, response_body_sha256,
+			response_body_truncated, response_content_encoding, request_fingerprint, cached_from_log_id, tls_connection_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			logEntry.TargetID, logEntry.Timestamp, logEntry.RequestMethod, logEntry.RequestURL,
+			logEntry.RequestHTTPVersion, logEntry.RequestHeaders, b.request, b.requestBlob,
+			logEntry.RequestFullURLWithFragment,
+			logEntry.ResponseStatusCode, logEntry.ResponseReasonPhrase, logEntry.ResponseHTTPVersion,
+			logEntry.ResponseHeaders, b.response, b.responseBlob, logEntry.ResponseContentType,
+			logEntry.ResponseBodySize, logEntry.DurationMs, logEntry.ClientIP, logEntry.IsHTTPS,
+			logEntry.IsPageCandidate, logEntry.Notes,
+			logEntry.LogSource, logEntry.PageSitemapID,
+			logEntry.ReplayBatchID, logEntry.ReplaySourceLogID,
+			logEntry.ResponseBodySHA256, logEntry.ResponseBodyTruncated, logEntry.ResponseContentEncoding,
+			logEntry.RequestFingerprint, logEntry.CachedFromLogID, logEntry.TLSConnectionID)
+	})
+}
+
+// LogToolkitRequest saves a request the toolkit sent itself (not through the proxy), with its
+// response, to http_traffic_log under the given log_source.
+func LogToolkitRequest(logEntry *models.HTTPTrafficLog, source string) (int64, error) {
+	id, err := insertTrafficLog(logEntry.RequestBody, logEntry.ResponseBody, func(tx *sql.Tx, b trafficBodies) (sql.Result, error) {
+		return tx.Exec(`INSERT INTO http_traffic_log (
+			target_id, timestamp, request_method, request_url, request_http_version, request_headers, request_body, request_body_blob_id,
+			response_status_code, response_reason_phrase, response_http_version, response_headers, response_body, response_body_blob_id,
+			response_content_type, response_body_size, duration_ms, is_https, log_source, request_fingerprint, cached_from_log_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			logEntry.TargetID, logEntry.Timestamp, logEntry.RequestMethod, logEntry.RequestURL, logEntry.RequestHTTPVersion,
+			logEntry.RequestHeaders, b.request, b.requestBlob,
+			logEntry.ResponseStatusCode, logEntry.ResponseReasonPhrase, logEntry.ResponseHTTPVersion,
+			logEntry.ResponseHeaders, b.response, b.responseBlob, logEntry.ResponseContentType,
+			logEntry.ResponseBodySize, logEntry.DurationMs, logEntry.IsHTTPS, models.NullString(source),
+			logEntry.RequestFingerprint, logEntry.CachedFromLogID,
+		)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("logging %s request %s: %w", source, logEntry.RequestURL.String, err)
+	}
+	return id, nil
+}
+
+// GetReusableResponse returns the latest entry logged since the given time for a request with the
+// fingerprint, when its full response was stored and is neither a server error nor a 429. Only the
+// response fields are loaded. found is false when there is none.
+func GetReusableResponse(fingerprint string, since time.Time) (entry models.HTTPTrafficLog, found bool, err error) {
+	var timestamp time.Time
+	err = DB.QueryRow(`SELECT id, target_id, timestamp, response_status_code, response_reason_phrase, response_http_version,
+			response_headers, `+logResponseBody+`, response_content_type, response_content_encoding
+		FROM http_traffic_log
+		WHERE request_fingerprint = ? AND response_body_truncated = 0
+			AND response_status_code BETWEEN 100 AND 499 AND response_status_code != 429
+		ORDER BY id DESC LIMIT 1`, fingerprint).Scan(&entry.ID, &entry.TargetID, &timestamp, &entry.ResponseStatusCode,
+		&entry.ResponseReasonPhrase, &entry.ResponseHTTPVersion, &entry.ResponseHeaders, &entry.ResponseBody,
+		&entry.ResponseContentType, &entry.ResponseContentEncoding)
+	if err == sql.ErrNoRows {
+		return entry, false, nil
+	}
+	if err != nil {
+		return entry, false, fmt.Errorf("looking up cached response: %w", err)
+	}
+	entry.Timestamp = timestamp
+	return entry, !timestamp.Before(since), nil
+}
+
+// GetParamMiningCandidates returns the latest captured GET request of each distinct URL of a target
+// browsed through the proxy, Page Sitemap or Modifier that got a 2xx HTML, JSON or XML response,
+// newest first. Only the ID, method, URL, request headers and HTTPS flag are loaded.
+func GetParamMiningCandidates(targetID int64) ([]models.HTTPTrafficLog, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(models.ManualLogSources)), ", ")
+	args := []interface{}{targetID}
+	for _, source := range models.ManualLogSources {
+		args = append(args, source)
+	}
+	rows, err := DB.Query(`SELECT id, request_url, request_headers, is_https FROM http_traffic_log WHERE id IN (
+			SELECT MAX(id) FROM http_traffic_log
+			WHERE target_id = ? AND request_method = 'GET' AND response_status_code BETWEEN 200 AND 299
+				AND log_source IN (`+placeholders+`)
+				AND (response_content_type LIKE '%html%' OR response_content_type LIKE '%json%' OR response_content_type LIKE '%xml%')
+			GROUP BY request_url)
+		ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying parameter mining candidates of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		e := models.HTTPTrafficLog{TargetID: &targetID, RequestMethod: models.NullString("GET")}
+		var isHTTPS sql.NullBool
+		if err := rows.Scan(&e.ID, &e.RequestURL, &e.RequestHeaders, &isHTTPS); err != nil {
+			return nil, fmt.Errorf("scanning parameter mining candidate: %w", err)
+		}
+		e.IsHTTPS = isHTTPS.Bool
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetCORSCheckCandidates returns the latest captured GET request of each distinct URL of a target
+// that got a 2xx response, newest first, leaving out the CORS checker's own requests. Only the ID,
+// URL, request headers and HTTPS flag are loaded.
+func GetCORSCheckCandidates(targetID int64) ([]models.HTTPTrafficLog, error) {
+	rows, err := DB.Query(`SELECT id, request_url, request_headers, is_https FROM http_traffic_log WHERE id IN (
+			SELECT MAX(id) FROM http_traffic_log
+			WHERE target_id = ? AND request_method = 'GET' AND response_status_code BETWEEN 200 AND 299
+				AND COALESCE(log_source, '') != ?
+			GROUP BY request_url)
+		ORDER BY id DESC`, targetID, models.CORSCheckLogSource)
+	if err != nil {
+		return nil, fmt.Errorf("querying CORS check candidates of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		e := models.HTTPTrafficLog{TargetID: &targetID, RequestMethod: models.NullString("GET")}
+		var isHTTPS sql.NullBool
+		if err := rows.Scan(&e.ID, &e.RequestURL, &e.RequestHeaders, &isHTTPS); err != nil {
+			return nil, fmt.Errorf("scanning CORS check candidate: %w", err)
+		}
+		e.IsHTTPS = isHTTPS.Bool
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
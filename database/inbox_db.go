@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"toolkit/models"
+)
+
+// CreateInboxMessage stores a message of the test inbox. A message whose Message-ID is already
+// stored is skipped; created is false then and the ID is the stored message's.
+func CreateInboxMessage(m models.InboxMessage) (id int64, created bool, err error) {
+	to, _ := json.Marshal(nonNilStrings(m.To))
+	codes, _ := json.Marshal(nonNilStrings(m.Codes))
+	links, _ := json.Marshal(nonNilStrings(m.Links))
+	magicLinks, _ := json.Marshal(nonNilStrings(m.MagicLinks))
+	var sentAt sql.NullTime
+	if m.SentAt != nil {
+		sentAt = sql.NullTime{Time: *m.SentAt, Valid: true}
+	}
+	receivedAt := m.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	receivedAt = receivedAt.UTC() // Stored as text; one zone keeps comparisons with since correct
+
+	res, err := DB.Exec(`INSERT OR IGNORE INTO inbox_messages (target_id, match_reason, source, message_id, from_address,
+			to_addresses, subject, body_text, body_html, codes, links, magic_links, sent_at, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.TargetID, m.MatchReason, m.Source, m.MessageID, m.From, string(to), m.Subject, m.BodyText, m.BodyHTML,
+		string(codes), string(links), string(magicLinks), sentAt, receivedAt)
+	if err != nil {
+		return 0, false, fmt.Errorf("saving inbox message %q: %w", m.Subject, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err := DB.QueryRow(`SELECT id FROM inbox_messages WHERE message_id = ?`, m.MessageID).Scan(&id)
+		if err != nil {
+			return 0, false, fmt.Errorf("looking up inbox message %s: %w", m.MessageID, err)
+		}
+		return id, false, nil
+	}
+	id, err = res.LastInsertId()
+	return id, true, err
+}
+
+const inboxMessageColumns = `id, target_id, match_reason, source, message_id, from_address, to_addresses, subject,
+	body_text, body_html, codes, links, magic_links, sent_at, received_at`
+
+// inboxMessageListColumns leaves the bodies out of message lists.
+const inboxMessageListColumns = `id, target_id, match_reason, source, message_id, from_address, to_addresses, subject,
+	'', '', codes, links, magic_links, sent_at, received_at`
+
+func scanInboxMessage(scanner interface{ Scan(...interface{}) error }) (models.InboxMessage, error) {
+	var m models.InboxMessage
+	var to, codes, links, magicLinks string
+	var sentAt sql.NullTime
+	err := scanner.Scan(&m.ID, &m.TargetID, &m.MatchReason, &m.Source, &m.MessageID, &m.From, &to, &m.Subject,
+		&m.BodyText, &m.BodyHTML, &codes, &links, &magicLinks, &sentAt, &m.ReceivedAt)
+	json.Unmarshal([]byte(to), &m.To)
+	json.Unmarshal([]byte(codes), &m.Codes)
+	json.Unmarshal([]byte(links), &m.Links)
+	json.Unmarshal([]byte(magicLinks), &m.MagicLinks)
+	if sentAt.Valid {
+		m.SentAt = &sentAt.Time
+	}
+	return m, err
+}
+
+func queryInboxMessages(query string, args ...interface{}) ([]models.InboxMessage, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying inbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.InboxMessage{}
+	for rows.Next() {
+		m, err := scanInboxMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning inbox message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// GetInboxMessages lists up to limit inbox messages without their bodies, newest first. A
+// targetID of 0 lists the messages of every target, including uncorrelated ones.
+func GetInboxMessages(targetID int64, limit int) ([]models.InboxMessage, error) {
+	if targetID == 0 {
+		return queryInboxMessages(`SELECT `+inboxMessageListColumns+` FROM inbox_messages
+			ORDER BY received_at DESC, id DESC LIMIT ?`, limit)
+	}
+	return queryInboxMessages(`SELECT `+inboxMessageListColumns+` FROM inbox_messages WHERE target_id = ?
+		ORDER BY received_at DESC, id DESC LIMIT ?`, targetID, limit)
+}
+
+// GetInboxMessage fetches an inbox message by ID.
+func GetInboxMessage(id int64) (models.InboxMessage, error) {
+	m, err := scanInboxMessage(DB.QueryRow(`SELECT `+inboxMessageColumns+` FROM inbox_messages WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return m, fmt.Errorf("inbox message with ID %d not found", id)
+		}
+		return m, fmt.Errorf("scanning inbox message %d: %w", id, err)
+	}
+	return m, nil
+}
+
+// GetLatestInboxCodeMessage returns the newest message of a target received at or after since
+// that carries a verification code or a link, or nil when there is none.
+func GetLatestInboxCodeMessage(targetID int64, since time.Time) (*models.InboxMessage, error) {
+	m, err := scanInboxMessage(DB.QueryRow(`SELECT `+inboxMessageColumns+` FROM inbox_messages
+		WHERE target_id = ? AND received_at >= ? AND (codes != '[]' OR links != '[]')
+		ORDER BY received_at DESC, id DESC LIMIT 1`, targetID, since.UTC()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("querying latest inbox message of target %d: %w", targetID, err)
+	}
+	return &m, nil
+}
+
+// DeleteInboxMessage deletes an inbox message.
+func DeleteInboxMessage(id int64) error {
+	res, err := DB.Exec(`DELETE FROM inbox_messages WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting inbox message %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("inbox message with ID %d not found", id)
+	}
+	return nil
+}
+
+// PruneInboxMessages deletes all but the newest max inbox messages.
+func PruneInboxMessages(max int) error {
+	if max <= 0 {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM inbox_messages WHERE id NOT IN
+		(SELECT id FROM inbox_messages ORDER BY received_at DESC, id DESC LIMIT ?)`, max)
+	if err != nil {
+		return fmt.Errorf("pruning inbox messages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+const ipAssetColumns = `a.id, a.target_id, a.address, a.is_cidr, a.ip_version, a.source, a.reverse_dns, a.asn, a.as_org, a.as_country,
+	a.as_prefix, a.enriched_at, a.is_in_scope, a.scope_rule_id, a.notes, a.first_seen_at, a.last_seen_at,
+	IFNULL((SELECT GROUP_CONCAT(d.domain_name, ',') FROM ip_asset_domains iad JOIN domains d ON d.id = iad.domain_id WHERE iad.ip_asset_id = a.id), '')`
+
+func scanIPAsset(scanner interface{ Scan(...interface{}) error }) (models.IPAsset, error) {
+	var asset models.IPAsset
+	var asn, scopeRuleID sql.NullInt64
+	var enrichedAt sql.NullTime
+	var domains string
+	err := scanner.Scan(&asset.ID, &asset.TargetID, &asset.Address, &asset.IsCIDR, &asset.IPVersion, &asset.Source, &asset.ReverseDNS,
+		&asn, &asset.ASOrg, &asset.ASCountry, &asset.ASPrefix, &enrichedAt, &asset.IsInScope, &scopeRuleID, &asset.Notes,
+		&asset.FirstSeenAt, &asset.LastSeenAt, &domains)
+	if asn.Valid {
+		asset.ASN = &asn.Int64
+	}
+	if scopeRuleID.Valid {
+		asset.ScopeRuleID = &scopeRuleID.Int64
+	}
+	if enrichedAt.Valid {
+		asset.EnrichedAt = &enrichedAt.Time
+	}
+	asset.Domains = []string{}
+	if domains != "" {
+		asset.Domains = strings.Split(domains, ",")
+	}
+	return asset, err
+}
+
+// UpsertIPAsset stores an IP asset, or refreshes last_seen_at when the target already has the
+// address. It returns the asset ID and whether the asset is new.
+func UpsertIPAsset(asset models.IPAsset) (int64, bool, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT id FROM ip_assets WHERE target_id = ? AND address = ?`, asset.TargetID, asset.Address).Scan(&id)
+	if err == nil {
+		if _, err := DB.Exec(`UPDATE ip_assets SET last_seen_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+			return id, false, fmt.Errorf("updating IP asset %d: %w", id, err)
+		}
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("looking up IP asset '%s' of target %d: %w", asset.Address, asset.TargetID, err)
+	}
+	res, err := DB.Exec(`INSERT INTO ip_assets (target_id, address, is_cidr, ip_version, source, notes) VALUES (?, ?, ?, ?, ?, ?)`,
+		asset.TargetID, asset.Address, asset.IsCIDR, asset.IPVersion, asset.Source, asset.Notes)
+	if err != nil {
+		return 0, false, fmt.Errorf("inserting IP asset '%s' of target %d: %w", asset.Address, asset.TargetID, err)
+	}
+	id, err = res.LastInsertId()
+	return id, true, err
+}
+
+// LinkIPAssetDomain records that a domain resolved to an IP asset.
+func LinkIPAssetDomain(assetID, domainID int64) error {
+	_, err := DB.Exec(`INSERT INTO ip_asset_domains (ip_asset_id, domain_id) VALUES (?, ?)
+		ON CONFLICT(ip_asset_id, domain_id) DO UPDATE SET resolved_at = CURRENT_TIMESTAMP`, assetID, domainID)
+	if err != nil {
+		return fmt.Errorf("linking IP asset %d to domain %d: %w", assetID, domainID, err)
+	}
+	return nil
+}
+
+// GetIPAssetByID fetches one IP asset with its domains.
+func GetIPAssetByID(id int64) (models.IPAsset, error) {
+	asset, err := scanIPAsset(DB.QueryRow(`SELECT `+ipAssetColumns+` FROM ip_assets a WHERE a.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return asset, fmt.Errorf("IP asset with ID %d not found", id)
+		}
+		return asset, fmt.Errorf("scanning IP asset %d: %w", id, err)
+	}
+	return asset, nil
+}
+
+// ListIPAssets lists the IP assets matching the filters and the total number of matches.
+func ListIPAssets(filters models.IPAssetFilters) ([]models.IPAsset, int64, error) {
+	conditions := []string{"a.target_id = ?"}
+	args := []interface{}{filters.TargetID}
+	if filters.IsInScope != nil {
+		conditions = append(conditions, "a.is_in_scope = ?")
+		args = append(args, *filters.IsInScope)
+	}
+	if filters.Source != "" {
+		conditions = append(conditions, "a.source = ?")
+		args = append(args, filters.Source)
+	}
+	if filters.ASN != 0 {
+		conditions = append(conditions, "a.asn = ?")
+		args = append(args, filters.ASN)
+	}
+	if filters.Search != "" {
+		conditions = append(conditions, "(a.address LIKE ? OR a.reverse_dns LIKE ? OR a.as_org LIKE ?)")
+		like := "%" + filters.Search + "%"
+		args = append(args, like, like, like)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM ip_assets a`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting IP assets of target %d: %w", filters.TargetID, err)
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := DB.Query(`SELECT `+ipAssetColumns+` FROM ip_assets a`+where+` ORDER BY a.is_cidr DESC, a.ip_version, a.address LIMIT ? OFFSET ?`,
+		append(args, limit, filters.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying IP assets of target %d: %w", filters.TargetID, err)
+	}
+	defer rows.Close()
+	assets := []models.IPAsset{}
+	for rows.Next() {
+		asset, err := scanIPAsset(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning IP asset row: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	return assets, total, rows.Err()
+}
+
+// ListAllIPAssetsForTarget returns every IP asset of a target.
+func ListAllIPAssetsForTarget(targetID int64) ([]models.IPAsset, error) {
+	assets, _, err := ListIPAssets(models.IPAssetFilters{TargetID: targetID, Limit: -1})
+	return assets, err
+}
+
+// ListUnenrichedIPAssets returns the IP assets of a target without reverse DNS/ASN data.
+func ListUnenrichedIPAssets(targetID int64) ([]models.IPAsset, error) {
+	rows, err := DB.Query(`SELECT `+ipAssetColumns+` FROM ip_assets a WHERE a.target_id = ? AND a.enriched_at IS NULL ORDER BY a.id`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying unenriched IP assets of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	var assets []models.IPAsset
+	for rows.Next() {
+		asset, err := scanIPAsset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning IP asset row: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	return assets, rows.Err()
+}
+
+// UpdateIPAssetEnrichment stores the reverse DNS and ASN data of an IP asset.
+func UpdateIPAssetEnrichment(asset models.IPAsset) error {
+	_, err := DB.Exec(`UPDATE ip_assets SET reverse_dns = ?, asn = ?, as_org = ?, as_country = ?, as_prefix = ?, enriched_at = ? WHERE id = ?`,
+		asset.ReverseDNS, asset.ASN, asset.ASOrg, asset.ASCountry, asset.ASPrefix, time.Now().UTC(), asset.ID)
+	if err != nil {
+		return fmt.Errorf("updating enrichment of IP asset %d: %w", asset.ID, err)
+	}
+	return nil
+}
+
+// UpdateIPAssetScope stores the scope decision for an IP asset.
+func UpdateIPAssetScope(id int64, inScope bool, scopeRuleID *int64) error {
+	if _, err := DB.Exec(`UPDATE ip_assets SET is_in_scope = ?, scope_rule_id = ? WHERE id = ?`, inScope, scopeRuleID, id); err != nil {
+		return fmt.Errorf("updating scope of IP asset %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteIPAsset removes an IP asset.
+func DeleteIPAsset(id int64) error {
+	res, err := DB.Exec(`DELETE FROM ip_assets WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting IP asset %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("IP asset with ID %d not found", id)
+	}
+	return nil
+}
+
+// GetResolvedIPsForHost returns the IP addresses the given domain name resolved to, across targets.
+func GetResolvedIPsForHost(hostname string) ([]string, error) {
+	rows, err := DB.Query(`SELECT DISTINCT a.address FROM ip_assets a
+		JOIN ip_asset_domains iad ON iad.ip_asset_id = a.id
+		JOIN domains d ON d.id = iad.domain_id
+		WHERE d.domain_name = ? COLLATE NOCASE AND a.is_cidr = FALSE`, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("querying resolved IPs of '%s': %w", hostname, err)
+	}
+	defer rows.Close()
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, fmt.Errorf("scanning resolved IP of '%s': %w", hostname, err)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const jobColumns = `id, job_type, target_id, status, message, items_total, items_processed, items_succeeded, error_count,
+	retry_count, last_error, started_at, updated_at, finished_at, duration_ms`
+
+func scanJob(scanner interface{ Scan(...interface{}) error }) (models.Job, error) {
+	var job models.Job
+	var targetID, durationMs sql.NullInt64
+	var finishedAt sql.NullTime
+	err := scanner.Scan(&job.ID, &job.Type, &targetID, &job.Status, &job.Message, &job.ItemsTotal, &job.ItemsProcessed,
+		&job.ItemsSucceeded, &job.ErrorCount, &job.RetryCount, &job.LastError, &job.StartedAt, &job.UpdatedAt, &finishedAt, &durationMs)
+	if targetID.Valid {
+		job.TargetID = &targetID.Int64
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if durationMs.Valid {
+		job.DurationMs = &durationMs.Int64
+	}
+	return job, err
+}
+
+// CreateJob records the start of a background job and returns it with its ID and start time.
+func CreateJob(jobType string, targetID *int64, message string) (models.Job, error) {
+	res, err := DB.Exec(`INSERT INTO jobs (job_type, target_id, status, message) VALUES (?, ?, ?, ?)`,
+		jobType, targetID, models.JobStatusRunning, message)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("inserting %s job: %w", jobType, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.Job{}, fmt.Errorf("getting ID of new %s job: %w", jobType, err)
+	}
+	return GetJobByID(id)
+}
+
+// UpdateJobProgress stores the status, message and counters of a job.
+func UpdateJobProgress(job models.Job) error {
+	_, err := DB.Exec(`UPDATE jobs SET status = ?, message = ?, items_total = ?, items_processed = ?, items_succeeded = ?,
+		error_count = ?, retry_count = ?, last_error = ?, finished_at = ?, duration_ms = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		job.Status, job.Message, job.ItemsTotal, job.ItemsProcessed, job.ItemsSucceeded, job.ErrorCount, job.RetryCount,
+		job.LastError, job.FinishedAt, job.DurationMs, job.ID)
+	if err != nil {
+		return fmt.Errorf("updating job %d: %w", job.ID, err)
+	}
+	return nil
+}
+
+// GetJobByID fetches one job.
+func GetJobByID(id int64) (models.Job, error) {
+	job, err := scanJob(DB.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return job, fmt.Errorf("job with ID %d not found", id)
+		}
+		return job, fmt.Errorf("scanning job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// ListJobs lists jobs matching the filters, newest first.
+func ListJobs(filters models.JobFilters) ([]models.Job, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.Type != "" {
+		conditions = append(conditions, "job_type = ?")
+		args = append(args, filters.Type)
+	}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filters.Status)
+	}
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " ORDER BY started_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning job row: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// FailInterruptedJobs marks jobs still 'running' from a previous process as failed. It returns
+// the number of jobs updated.
+func FailInterruptedJobs() (int64, error) {
+	res, err := DB.Exec(`UPDATE jobs SET status = ?, last_error = 'interrupted: the toolkit stopped while the job was running',
+		finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE status = ?`, models.JobStatusFailed, models.JobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failing interrupted jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
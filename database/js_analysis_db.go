@@ -0,0 +1,282 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const jsFileColumns = `id, target_id, url_key, latest_url, latest_version_id, version_count, first_seen_at, last_seen_at`
+
+const jsFileVersionColumns = `id, js_file_id, http_traffic_log_id, url, content_hash, size, sourcemap_url, sourcemap_status,
+	sourcemap_sources_count, endpoint_count, added_count, removed_count, created_at`
+
+const jsEndpointColumns = `id, target_id, js_file_id, js_file_version_id, kind, value, method, source_file, context, created_at`
+
+func scanJSFile(scanner interface{ Scan(...interface{}) error }) (models.JSFile, error) {
+	var f models.JSFile
+	err := scanner.Scan(&f.ID, &f.TargetID, &f.URLKey, &f.LatestURL, &f.LatestVersionID, &f.VersionCount, &f.FirstSeenAt, &f.LastSeenAt)
+	return f, err
+}
+
+func scanJSFileVersion(scanner interface{ Scan(...interface{}) error }) (models.JSFileVersion, error) {
+	var v models.JSFileVersion
+	err := scanner.Scan(&v.ID, &v.JSFileID, &v.HTTPTrafficLogID, &v.URL, &v.ContentHash, &v.Size, &v.SourceMapURL, &v.SourceMapStatus,
+		&v.SourceMapSourcesCount, &v.EndpointCount, &v.AddedCount, &v.RemovedCount, &v.CreatedAt)
+	return v, err
+}
+
+func scanJSEndpoints(rows *sql.Rows) ([]models.JSEndpoint, error) {
+	endpoints := []models.JSEndpoint{}
+	for rows.Next() {
+		var e models.JSEndpoint
+		if err := rows.Scan(&e.ID, &e.TargetID, &e.JSFileID, &e.JSFileVersionID, &e.Kind, &e.Value, &e.Method, &e.SourceFile, &e.Context, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning js endpoint: %w", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+// RecordJSFileCapture registers a captured JS file. The file is matched by target and url_key; a new
+// version is created only when the content hash has not been seen for that file before.
+// It returns the version, whether it is new, and the ID of the version that was latest before
+// this capture (0 if none).
+func RecordJSFileCapture(targetID sql.NullInt64, urlKey, url string, logID int64, contentHash string, size int64) (models.JSFileVersion, bool, int64, error) {
+	var version models.JSFileVersion
+	tx, err := DB.Begin()
+	if err != nil {
+		return version, false, 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fileID int64
+	var latestVersionID sql.NullInt64
+	err = tx.QueryRow(`SELECT id, latest_version_id FROM js_files WHERE IFNULL(target_id, 0) = ? AND url_key = ?`, targetID.Int64, urlKey).Scan(&fileID, &latestVersionID)
+	if err == sql.ErrNoRows {
+		res, errIns := tx.Exec(`INSERT INTO js_files (target_id, url_key, latest_url) VALUES (?, ?, ?)`, targetID, urlKey, url)
+		if errIns != nil {
+			return version, false, 0, fmt.Errorf("inserting js file %s: %w", urlKey, errIns)
+		}
+		fileID, _ = res.LastInsertId()
+	} else if err != nil {
+		return version, false, 0, fmt.Errorf("looking up js file %s: %w", urlKey, err)
+	}
+
+	logIDArg := sql.NullInt64{Int64: logID, Valid: logID != 0}
+	version, err = scanJSFileVersion(tx.QueryRow(`SELECT `+jsFileVersionColumns+` FROM js_file_versions WHERE js_file_id = ? AND content_hash = ?`, fileID, contentHash))
+	if err == nil {
+		// Same content captured again; only refresh the file's last-seen information.
+		if _, err := tx.Exec(`UPDATE js_files SET latest_url = ?, last_seen_at = CURRENT_TIMESTAMP WHERE id = ?`, url, fileID); err != nil {
+			return version, false, 0, fmt.Errorf("updating js file %d: %w", fileID, err)
+		}
+		return version, false, latestVersionID.Int64, tx.Commit()
+	} else if err != sql.ErrNoRows {
+		return version, false, 0, fmt.Errorf("looking up js file version: %w", err)
+	}
+
+	res, err := tx.Exec(`INSERT INTO js_file_versions (js_file_id, http_traffic_log_id, url, content_hash, size) VALUES (?, ?, ?, ?, ?)`,
+		fileID, logIDArg, url, contentHash, size)
+	if err != nil {
+		return version, false, 0, fmt.Errorf("inserting js file version: %w", err)
+	}
+	versionID, _ := res.LastInsertId()
+	if _, err := tx.Exec(`UPDATE js_files SET latest_url = ?, latest_version_id = ?, version_count = version_count + 1, last_seen_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		url, versionID, fileID); err != nil {
+		return version, false, 0, fmt.Errorf("updating js file %d: %w", fileID, err)
+	}
+	version, err = scanJSFileVersion(tx.QueryRow(`SELECT `+jsFileVersionColumns+` FROM js_file_versions WHERE id = ?`, versionID))
+	if err != nil {
+		return version, false, 0, fmt.Errorf("reading back js file version %d: %w", versionID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return version, false, 0, fmt.Errorf("committing js file capture: %w", err)
+	}
+	return version, true, latestVersionID.Int64, nil
+}
+
+// UpdateJSFileVersionResults stores the pipeline outcome for a version.
+func UpdateJSFileVersionResults(versionID int64, sourceMapURL, sourceMapStatus string, sourcesCount, endpointCount, added, removed int) error {
+	_, err := DB.Exec(`UPDATE js_file_versions SET sourcemap_url = ?, sourcemap_status = ?, sourcemap_sources_count = ?, endpoint_count = ?,
+		added_count = ?, removed_count = ? WHERE id = ?`,
+		models.NullString(sourceMapURL), sourceMapStatus, sourcesCount, endpointCount, added, removed, versionID)
+	if err != nil {
+		return fmt.Errorf("updating js file version %d: %w", versionID, err)
+	}
+	return nil
+}
+
+// InsertJSEndpoints stores extracted endpoints, ignoring duplicates within a version.
+func InsertJSEndpoints(endpoints []models.JSEndpoint) (int, error) {
+	if len(endpoints) == 0 {
+		return 0, nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO js_endpoints (target_id, js_file_id, js_file_version_id, kind, value, method, source_file, context)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing js endpoint insert: %w", err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, e := range endpoints {
+		res, err := stmt.Exec(e.TargetID, e.JSFileID, e.JSFileVersionID, e.Kind, e.Value, e.Method, e.SourceFile, e.Context)
+		if err != nil {
+			return 0, fmt.Errorf("inserting js endpoint '%s': %w", e.Value, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing js endpoints: %w", err)
+	}
+	return inserted, nil
+}
+
+// GetJSFiles lists tracked JS files, most recently seen first.
+func GetJSFiles(targetID int64) ([]models.JSFile, error) {
+	query := `SELECT ` + jsFileColumns + ` FROM js_files`
+	var args []interface{}
+	if targetID != 0 {
+		query += " WHERE target_id = ?"
+		args = append(args, targetID)
+	}
+	query += " ORDER BY last_seen_at DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying js files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.JSFile{}
+	for rows.Next() {
+		f, err := scanJSFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning js file: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// GetJSFileByID fetches a tracked JS file.
+func GetJSFileByID(id int64) (models.JSFile, error) {
+	f, err := scanJSFile(DB.QueryRow(`SELECT `+jsFileColumns+` FROM js_files WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return f, fmt.Errorf("JS file with ID %d not found", id)
+		}
+		return f, fmt.Errorf("scanning js file %d: %w", id, err)
+	}
+	return f, nil
+}
+
+// GetJSFileVersions lists the versions of a JS file, newest first.
+func GetJSFileVersions(fileID int64) ([]models.JSFileVersion, error) {
+	rows, err := DB.Query(`SELECT `+jsFileVersionColumns+` FROM js_file_versions WHERE js_file_id = ? ORDER BY id DESC`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("querying versions of js file %d: %w", fileID, err)
+	}
+	defer rows.Close()
+
+	versions := []models.JSFileVersion{}
+	for rows.Next() {
+		v, err := scanJSFileVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning js file version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetJSEndpoints lists extracted JS endpoints with filters and pagination.
+func GetJSEndpoints(filters models.JSEndpointFilters) ([]models.JSEndpoint, int64, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.JSFileID != 0 {
+		conditions = append(conditions, "js_file_id = ?")
+		args = append(args, filters.JSFileID)
+	}
+	if filters.VersionID != 0 {
+		conditions = append(conditions, "js_file_version_id = ?")
+		args = append(args, filters.VersionID)
+	} else if !filters.AllVersions {
+		conditions = append(conditions, "js_file_version_id IN (SELECT latest_version_id FROM js_files WHERE latest_version_id IS NOT NULL)")
+	}
+	if filters.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filters.Kind)
+	}
+	if filters.Search != "" {
+		conditions = append(conditions, "value LIKE ?")
+		args = append(args, "%"+filters.Search+"%")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := DB.QueryRow("SELECT COUNT(*) FROM js_endpoints"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting js endpoints: %w", err)
+	}
+	if filters.Limit <= 0 {
+		filters.Limit = 100
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	rows, err := DB.Query(`SELECT `+jsEndpointColumns+` FROM js_endpoints`+where+` ORDER BY kind, value LIMIT ? OFFSET ?`,
+		append(args, filters.Limit, (filters.Page-1)*filters.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying js endpoints: %w", err)
+	}
+	defer rows.Close()
+	endpoints, err := scanJSEndpoints(rows)
+	return endpoints, total, err
+}
+
+// DiffJSFileVersions compares the endpoints of two versions of the same JS file by (kind, value).
+func DiffJSFileVersions(fileID, fromVersionID, toVersionID int64) (models.JSBundleDiff, error) {
+	diff := models.JSBundleDiff{JSFileID: fileID, FromVersionID: fromVersionID, ToVersionID: toVersionID}
+	query := `SELECT ` + jsEndpointColumns + ` FROM js_endpoints
+		WHERE js_file_version_id = ? AND (kind, value) NOT IN (SELECT kind, value FROM js_endpoints WHERE js_file_version_id = ?)
+		ORDER BY kind, value`
+
+	rows, err := DB.Query(query, toVersionID, fromVersionID)
+	if err != nil {
+		return diff, fmt.Errorf("querying added js endpoints: %w", err)
+	}
+	diff.Added, err = scanJSEndpoints(rows)
+	rows.Close()
+	if err != nil {
+		return diff, err
+	}
+
+	rows, err = DB.Query(query, fromVersionID, toVersionID)
+	if err != nil {
+		return diff, fmt.Errorf("querying removed js endpoints: %w", err)
+	}
+	diff.Removed, err = scanJSEndpoints(rows)
+	rows.Close()
+	if err != nil {
+		return diff, err
+	}
+	logger.Debug("DiffJSFileVersions: File %d, versions %d -> %d: +%d / -%d", fileID, fromVersionID, toVersionID, len(diff.Added), len(diff.Removed))
+	return diff, nil
+}
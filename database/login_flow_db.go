@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"toolkit/models"
+)
+
+// SetLoginFlow stores the login flow of a target with its steps, replacing the previous one.
+// The result of the last run is cleared.
+func SetLoginFlow(flow models.LoginFlow) (models.LoginFlow, error) {
+	extractorsJSON, err := json.Marshal(flow.Extractors)
+	if err != nil {
+		return flow, fmt.Errorf("marshalling login flow extractors: %w", err)
+	}
+	headersJSON, err := json.Marshal(flow.SessionHeaders)
+	if err != nil {
+		return flow, fmt.Errorf("marshalling login flow session headers: %w", err)
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return flow, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var flowID int64
+	err = tx.QueryRow(`INSERT INTO login_flows (target_id, session_id, extractors, session_headers, auto_refresh)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(target_id) DO UPDATE SET session_id = excluded.session_id, extractors = excluded.extractors,
+			session_headers = excluded.session_headers, auto_refresh = excluded.auto_refresh,
+			last_run_at = NULL, last_status = '', last_error = '', updated_at = CURRENT_TIMESTAMP
+		RETURNING id`, flow.TargetID, flow.SessionID, string(extractorsJSON), string(headersJSON), flow.AutoRefresh).Scan(&flowID)
+	if err != nil {
+		return flow, fmt.Errorf("saving login flow of target %d: %w", flow.TargetID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM login_flow_steps WHERE flow_id = ?`, flowID); err != nil {
+		return flow, fmt.Errorf("clearing steps of login flow %d: %w", flowID, err)
+	}
+	for _, s := range flow.Steps {
+		stepHeaders, err := json.Marshal(s.Headers)
+		if err != nil {
+			return flow, fmt.Errorf("marshalling headers of login flow step %d: %w", s.Position, err)
+		}
+		_, err = tx.Exec(`INSERT INTO login_flow_steps (flow_id, position, method, url, headers, body, source_log_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			flowID, s.Position, s.Method, s.URL, string(stepHeaders), []byte(s.Body), s.SourceLogID)
+		if err != nil {
+			return flow, fmt.Errorf("inserting login flow step %d: %w", s.Position, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return flow, fmt.Errorf("committing login flow of target %d: %w", flow.TargetID, err)
+	}
+	return GetLoginFlow(flow.TargetID)
+}
+
+// GetLoginFlow fetches the login flow of a target with its steps in order.
+func GetLoginFlow(targetID int64) (models.LoginFlow, error) {
+	flow := models.LoginFlow{TargetID: targetID}
+	var extractorsJSON, headersJSON string
+	var lastRunAt sql.NullTime
+	err := DB.QueryRow(`SELECT id, session_id, extractors, session_headers, auto_refresh, last_run_at, last_status, last_error, created_at, updated_at
+		FROM login_flows WHERE target_id = ?`, targetID).Scan(&flow.ID, &flow.SessionID, &extractorsJSON, &headersJSON,
+		&flow.AutoRefresh, &lastRunAt, &flow.LastStatus, &flow.LastError, &flow.CreatedAt, &flow.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return flow, fmt.Errorf("login flow for target %d not found", targetID)
+		}
+		return flow, fmt.Errorf("scanning login flow of target %d: %w", targetID, err)
+	}
+	if lastRunAt.Valid {
+		flow.LastRunAt = &lastRunAt.Time
+	}
+	if err := json.Unmarshal([]byte(extractorsJSON), &flow.Extractors); err != nil {
+		return flow, fmt.Errorf("parsing extractors of login flow %d: %w", flow.ID, err)
+	}
+	if err := json.Unmarshal([]byte(headersJSON), &flow.SessionHeaders); err != nil {
+		return flow, fmt.Errorf("parsing session headers of login flow %d: %w", flow.ID, err)
+	}
+	if flow.Extractors == nil {
+		flow.Extractors = []models.LoginFlowExtractor{}
+	}
+	if flow.SessionHeaders == nil {
+		flow.SessionHeaders = map[string]string{}
+	}
+
+	rows, err := DB.Query(`SELECT id, position, method, url, headers, body, source_log_id FROM login_flow_steps
+		WHERE flow_id = ? ORDER BY position`, flow.ID)
+	if err != nil {
+		return flow, fmt.Errorf("querying steps of login flow %d: %w", flow.ID, err)
+	}
+	defer rows.Close()
+	flow.Steps = []models.LoginFlowStep{}
+	for rows.Next() {
+		var s models.LoginFlowStep
+		var stepHeaders string
+		var body []byte
+		if err := rows.Scan(&s.ID, &s.Position, &s.Method, &s.URL, &stepHeaders, &body, &s.SourceLogID); err != nil {
+			return flow, fmt.Errorf("scanning login flow step: %w", err)
+		}
+		if err := json.Unmarshal([]byte(stepHeaders), &s.Headers); err != nil {
+			return flow, fmt.Errorf("parsing headers of login flow step %d: %w", s.ID, err)
+		}
+		s.Body = string(body)
+		flow.Steps = append(flow.Steps, s)
+	}
+	return flow, rows.Err()
+}
+
+// SetLoginFlowSession sets the replay session a login flow writes the credentials it mints to.
+func SetLoginFlowSession(flowID, sessionID int64) error {
+	_, err := DB.Exec(`UPDATE login_flows SET session_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, sessionID, flowID)
+	if err != nil {
+		return fmt.Errorf("setting session of login flow %d: %w", flowID, err)
+	}
+	return nil
+}
+
+// RecordLoginFlowRun stores the outcome of the last run of a login flow.
+func RecordLoginFlowRun(flowID int64, at time.Time, status, errMsg string) error {
+	_, err := DB.Exec(`UPDATE login_flows SET last_run_at = ?, last_status = ?, last_error = ? WHERE id = ?`, at.UTC(), status, errMsg, flowID)
+	if err != nil {
+		return fmt.Errorf("recording run of login flow %d: %w", flowID, err)
+	}
+	return nil
+}
+
+// DeleteLoginFlow removes the login flow of a target. Its replay session is kept.
+func DeleteLoginFlow(targetID int64) error {
+	res, err := DB.Exec(`DELETE FROM login_flows WHERE target_id = ?`, targetID)
+	if err != nil {
+		return fmt.Errorf("deleting login flow of target %d: %w", targetID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("login flow for target %d not found", targetID)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_http_traffic_log_replay_batch_id;
+ALTER TABLE http_traffic_log DROP COLUMN replay_source_log_id;
+ALTER TABLE http_traffic_log DROP COLUMN replay_batch_id;
+
+DROP TABLE IF EXISTS replay_batch_items;
+DROP TABLE IF EXISTS replay_batches;
+DROP TRIGGER IF EXISTS replay_sessions_updated_at;
+DROP TABLE IF EXISTS replay_sessions;
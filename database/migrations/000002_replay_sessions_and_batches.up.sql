@@ -0,0 +1,60 @@
+-- Replay Sessions Table (stored cookies/headers used when replaying requests)
+CREATE TABLE IF NOT EXISTS replay_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    name TEXT NOT NULL,
+    cookies TEXT,
+    headers TEXT,
+    source_log_id INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL
+);
+CREATE TRIGGER IF NOT EXISTS replay_sessions_updated_at
+AFTER UPDATE ON replay_sessions FOR EACH ROW
+BEGIN UPDATE replay_sessions SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
+CREATE INDEX IF NOT EXISTS idx_replay_sessions_target_id ON replay_sessions(target_id);
+
+-- Replay Batches Table
+CREATE TABLE IF NOT EXISTS replay_batches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    session_id INTEGER,
+    name TEXT,
+    status TEXT NOT NULL DEFAULT 'queued',
+    total_items INTEGER NOT NULL DEFAULT 0,
+    completed_items INTEGER NOT NULL DEFAULT 0,
+    failed_items INTEGER NOT NULL DEFAULT 0,
+    delay_ms INTEGER NOT NULL DEFAULT 0,
+    shuffle BOOLEAN NOT NULL DEFAULT FALSE,
+    last_error TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (session_id) REFERENCES replay_sessions(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_replay_batches_target_id ON replay_batches(target_id);
+
+-- Replay Batch Items Table (one row per queued request)
+CREATE TABLE IF NOT EXISTS replay_batch_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id INTEGER NOT NULL,
+    source_log_id INTEGER,
+    source_discovered_url_id INTEGER,
+    request_method TEXT NOT NULL,
+    request_url TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    response_status_code INTEGER,
+    error TEXT,
+    executed_at DATETIME,
+    FOREIGN KEY (batch_id) REFERENCES replay_batches(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL,
+    FOREIGN KEY (source_discovered_url_id) REFERENCES discovered_urls(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_replay_batch_items_batch_id ON replay_batch_items(batch_id);
+
+ALTER TABLE http_traffic_log ADD COLUMN replay_batch_id INTEGER REFERENCES replay_batches(id) ON DELETE SET NULL;
+ALTER TABLE http_traffic_log ADD COLUMN replay_source_log_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_replay_batch_id ON http_traffic_log(replay_batch_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS secrets_updated_at;
+DROP TABLE IF EXISTS secrets;
@@ -0,0 +1,24 @@
+-- Secrets Table (API keys/tokens detected in logged bodies)
+CREATE TABLE IF NOT EXISTS secrets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    http_traffic_log_id INTEGER NOT NULL,
+    rule_name TEXT NOT NULL,
+    match_excerpt TEXT NOT NULL,
+    secret_hash TEXT NOT NULL,
+    entropy REAL,
+    location TEXT NOT NULL DEFAULT 'response_body',
+    status TEXT NOT NULL DEFAULT 'new',
+    notes TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (http_traffic_log_id) REFERENCES http_traffic_log(id) ON DELETE CASCADE,
+    UNIQUE (http_traffic_log_id, rule_name, secret_hash, location)
+);
+CREATE TRIGGER IF NOT EXISTS secrets_updated_at
+AFTER UPDATE ON secrets FOR EACH ROW
+BEGIN UPDATE secrets SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
+CREATE INDEX IF NOT EXISTS idx_secrets_target_id ON secrets(target_id);
+CREATE INDEX IF NOT EXISTS idx_secrets_status ON secrets(status);
+CREATE INDEX IF NOT EXISTS idx_secrets_secret_hash ON secrets(secret_hash);
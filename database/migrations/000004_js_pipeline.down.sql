@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS js_endpoints;
+DROP TABLE IF EXISTS js_file_versions;
+DROP INDEX IF EXISTS idx_js_files_target_url_key;
+DROP TABLE IF EXISTS js_files;
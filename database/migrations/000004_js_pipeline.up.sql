@@ -0,0 +1,54 @@
+-- JS Files Table (one row per logical bundle; hashed file names are normalised into url_key)
+CREATE TABLE IF NOT EXISTS js_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    url_key TEXT NOT NULL,
+    latest_url TEXT NOT NULL,
+    latest_version_id INTEGER,
+    version_count INTEGER NOT NULL DEFAULT 0,
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_js_files_target_url_key ON js_files(IFNULL(target_id, 0), url_key);
+
+-- JS File Versions Table (distinct contents of a bundle, in capture order)
+CREATE TABLE IF NOT EXISTS js_file_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    js_file_id INTEGER NOT NULL,
+    http_traffic_log_id INTEGER,
+    url TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    sourcemap_url TEXT,
+    sourcemap_status TEXT NOT NULL DEFAULT 'none',
+    sourcemap_sources_count INTEGER NOT NULL DEFAULT 0,
+    endpoint_count INTEGER NOT NULL DEFAULT 0,
+    added_count INTEGER NOT NULL DEFAULT 0,
+    removed_count INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (js_file_id) REFERENCES js_files(id) ON DELETE CASCADE,
+    FOREIGN KEY (http_traffic_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL,
+    UNIQUE (js_file_id, content_hash)
+);
+CREATE INDEX IF NOT EXISTS idx_js_file_versions_js_file_id ON js_file_versions(js_file_id);
+
+-- JS Endpoints Table (endpoints, paths and DOM sinks extracted from a JS file version)
+CREATE TABLE IF NOT EXISTS js_endpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    js_file_id INTEGER NOT NULL,
+    js_file_version_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    value TEXT NOT NULL,
+    method TEXT,
+    source_file TEXT,
+    context TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (js_file_id) REFERENCES js_files(id) ON DELETE CASCADE,
+    FOREIGN KEY (js_file_version_id) REFERENCES js_file_versions(id) ON DELETE CASCADE,
+    UNIQUE (js_file_version_id, kind, value)
+);
+CREATE INDEX IF NOT EXISTS idx_js_endpoints_target_id ON js_endpoints(target_id);
+CREATE INDEX IF NOT EXISTS idx_js_endpoints_kind ON js_endpoints(kind);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS graphql_operations;
+DROP INDEX IF EXISTS idx_graphql_endpoints_target_url;
+DROP TABLE IF EXISTS graphql_endpoints;
@@ -0,0 +1,38 @@
+-- GraphQL Endpoints Table (URLs that received GraphQL operations; holds the introspected schema)
+CREATE TABLE IF NOT EXISTS graphql_endpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    url TEXT NOT NULL,
+    operation_count INTEGER NOT NULL DEFAULT 0,
+    schema_json TEXT,
+    schema_source TEXT, -- 'captured' (seen in traffic) or 'introspection' (requested by the toolkit)
+    schema_fetched_at DATETIME,
+    introspection_error TEXT,
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_graphql_endpoints_target_url ON graphql_endpoints(IFNULL(target_id, 0), url);
+
+-- GraphQL Operations Table (distinct operations per endpoint, with the latest variables seen)
+CREATE TABLE IF NOT EXISTS graphql_operations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    graphql_endpoint_id INTEGER NOT NULL,
+    target_id INTEGER,
+    operation_type TEXT NOT NULL DEFAULT 'query',
+    operation_name TEXT NOT NULL DEFAULT '', -- Empty for anonymous operations
+    query TEXT NOT NULL,
+    query_hash TEXT NOT NULL,
+    persisted_query_hash TEXT,
+    variables TEXT,
+    last_http_traffic_log_id INTEGER,
+    seen_count INTEGER NOT NULL DEFAULT 1,
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (graphql_endpoint_id) REFERENCES graphql_endpoints(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (last_http_traffic_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL,
+    UNIQUE (graphql_endpoint_id, query_hash, operation_name)
+);
+CREATE INDEX IF NOT EXISTS idx_graphql_operations_endpoint_id ON graphql_operations(graphql_endpoint_id);
+CREATE INDEX IF NOT EXISTS idx_graphql_operations_target_id ON graphql_operations(target_id);
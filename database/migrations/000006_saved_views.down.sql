@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS saved_views_updated_at;
+DROP TABLE IF EXISTS saved_views;
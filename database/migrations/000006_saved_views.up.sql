@@ -0,0 +1,15 @@
+-- Saved Views Table (named filter/sort combinations for list pages; target_id NULL = available for all targets)
+CREATE TABLE IF NOT EXISTS saved_views (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    view_type TEXT NOT NULL, -- 'traffic_log' or 'domains'
+    name TEXT NOT NULL,
+    filters TEXT NOT NULL DEFAULT '{}', -- JSON object of list query parameters
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_views_target_type_name ON saved_views(IFNULL(target_id, 0), view_type, name);
+CREATE TRIGGER IF NOT EXISTS saved_views_updated_at
+AFTER UPDATE ON saved_views FOR EACH ROW
+BEGIN UPDATE saved_views SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
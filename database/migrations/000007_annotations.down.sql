@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS annotations_delete_modifier_task;
+DROP TRIGGER IF EXISTS annotations_delete_checklist_item;
+DROP TRIGGER IF EXISTS annotations_delete_finding;
+DROP TRIGGER IF EXISTS annotations_delete_domain;
+DROP TRIGGER IF EXISTS annotations_delete_httplog;
+DROP TRIGGER IF EXISTS annotations_updated_at;
+DROP TABLE IF EXISTS annotations;
@@ -0,0 +1,38 @@
+-- Annotations Table (timestamped comments attached to traffic logs, domains, findings, checklist items and modifier tasks)
+CREATE TABLE IF NOT EXISTS annotations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    entity_type TEXT NOT NULL, -- 'httplog', 'domain', 'finding', 'checklist_item', 'modifier_task'
+    entity_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_annotations_entity ON annotations(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_annotations_target_created ON annotations(target_id, created_at);
+CREATE TRIGGER IF NOT EXISTS annotations_updated_at
+AFTER UPDATE ON annotations FOR EACH ROW
+BEGIN UPDATE annotations SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
+
+-- Annotations have no foreign key to their entity, so remove them when the entity goes away.
+CREATE TRIGGER IF NOT EXISTS annotations_delete_httplog
+AFTER DELETE ON http_traffic_log FOR EACH ROW
+BEGIN DELETE FROM annotations WHERE entity_type = 'httplog' AND entity_id = OLD.id; END;
+CREATE TRIGGER IF NOT EXISTS annotations_delete_domain
+AFTER DELETE ON domains FOR EACH ROW
+BEGIN DELETE FROM annotations WHERE entity_type = 'domain' AND entity_id = OLD.id; END;
+CREATE TRIGGER IF NOT EXISTS annotations_delete_finding
+AFTER DELETE ON target_findings FOR EACH ROW
+BEGIN DELETE FROM annotations WHERE entity_type = 'finding' AND entity_id = OLD.id; END;
+CREATE TRIGGER IF NOT EXISTS annotations_delete_checklist_item
+AFTER DELETE ON target_checklist_items FOR EACH ROW
+BEGIN DELETE FROM annotations WHERE entity_type = 'checklist_item' AND entity_id = OLD.id; END;
+CREATE TRIGGER IF NOT EXISTS annotations_delete_modifier_task
+AFTER DELETE ON modifier_tasks FOR EACH ROW
+BEGIN DELETE FROM annotations WHERE entity_type = 'modifier_task' AND entity_id = OLD.id; END;
+
+-- Carry the existing single per-log notes over as the first annotation of each log.
+INSERT INTO annotations (target_id, entity_type, entity_id, content, created_at, updated_at)
+SELECT target_id, 'httplog', id, notes, timestamp, timestamp FROM http_traffic_log
+WHERE notes IS NOT NULL AND TRIM(notes) != '';
@@ -0,0 +1,16 @@
+-- Checklist Item Evidence Table (traffic logs, findings and screenshots backing a target checklist item)
+CREATE TABLE IF NOT EXISTS checklist_item_evidence (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    checklist_item_id INTEGER NOT NULL,
+    evidence_type TEXT NOT NULL, -- 'httplog', 'finding' or 'screenshot'
+    http_traffic_log_id INTEGER,
+    finding_id INTEGER,
+    screenshot_path TEXT, -- File path or URL of the screenshot
+    caption TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (checklist_item_id) REFERENCES target_checklist_items(id) ON DELETE CASCADE,
+    FOREIGN KEY (http_traffic_log_id) REFERENCES http_traffic_log(id) ON DELETE CASCADE,
+    FOREIGN KEY (finding_id) REFERENCES target_findings(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checklist_item_evidence_unique ON checklist_item_evidence(
+    checklist_item_id, evidence_type, IFNULL(http_traffic_log_id, 0), IFNULL(finding_id, 0), IFNULL(screenshot_path, ''));
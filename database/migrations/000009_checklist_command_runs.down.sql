@@ -0,0 +1 @@
+DROP TABLE IF EXISTS checklist_command_runs;
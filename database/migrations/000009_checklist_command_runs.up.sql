@@ -0,0 +1,15 @@
+-- Checklist Command Runs Table (executions of a checklist item's item_command_text)
+CREATE TABLE IF NOT EXISTS checklist_command_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    checklist_item_id INTEGER NOT NULL,
+    command TEXT NOT NULL, -- Command line after placeholder substitution
+    status TEXT NOT NULL DEFAULT 'running', -- 'running', 'succeeded', 'failed', 'timed_out', 'cancelled'
+    exit_code INTEGER,
+    output TEXT,
+    output_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    error_message TEXT,
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY (checklist_item_id) REFERENCES target_checklist_items(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_checklist_command_runs_item ON checklist_command_runs(checklist_item_id, started_at);
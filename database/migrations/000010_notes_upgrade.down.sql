@@ -0,0 +1,23 @@
+DROP TRIGGER IF EXISTS notes_fts_after_insert;
+DROP TRIGGER IF EXISTS notes_fts_after_update;
+DROP TRIGGER IF EXISTS notes_fts_before_delete;
+DROP TRIGGER IF EXISTS notes_fts_before_update;
+DROP TABLE IF EXISTS notes_fts;
+DROP TABLE IF EXISTS note_links;
+
+-- SQLite cannot drop a foreign key column, so rebuild the notes table without target_id.
+DROP INDEX IF EXISTS idx_notes_target_id;
+DROP TRIGGER IF EXISTS update_note_updated_at;
+CREATE TABLE notes_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO notes_old (id, title, content, created_at, updated_at) SELECT id, title, content, created_at, updated_at FROM notes;
+DROP TABLE notes;
+ALTER TABLE notes_old RENAME TO notes;
+CREATE TRIGGER IF NOT EXISTS update_note_updated_at
+AFTER UPDATE ON notes FOR EACH ROW
+BEGIN UPDATE notes SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
@@ -0,0 +1,31 @@
+-- Notes: optional target association (NULL = global note)
+ALTER TABLE notes ADD COLUMN target_id INTEGER REFERENCES targets(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_notes_target_id ON notes(target_id);
+
+-- Note Links Table ([[wiki-style]] links parsed from note content; linked_id NULL = note referenced by title)
+CREATE TABLE IF NOT EXISTS note_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id INTEGER NOT NULL,
+    link_type TEXT NOT NULL, -- 'note', 'target', 'finding', 'httplog' or 'domain'
+    linked_id INTEGER,
+    link_text TEXT NOT NULL, -- The link as written, e.g. 'finding:5' or 'Login flow'
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_note_links_note_id ON note_links(note_id);
+CREATE INDEX IF NOT EXISTS idx_note_links_linked ON note_links(link_type, linked_id);
+
+-- Full-text index over note titles and content (external content table kept in sync by triggers)
+CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts4(content="notes", title, content);
+CREATE TRIGGER IF NOT EXISTS notes_fts_before_update BEFORE UPDATE ON notes BEGIN
+    DELETE FROM notes_fts WHERE docid = OLD.id;
+END;
+CREATE TRIGGER IF NOT EXISTS notes_fts_before_delete BEFORE DELETE ON notes BEGIN
+    DELETE FROM notes_fts WHERE docid = OLD.id;
+END;
+CREATE TRIGGER IF NOT EXISTS notes_fts_after_update AFTER UPDATE ON notes BEGIN
+    INSERT INTO notes_fts(docid, title, content) VALUES (NEW.id, NEW.title, NEW.content);
+END;
+CREATE TRIGGER IF NOT EXISTS notes_fts_after_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts(docid, title, content) VALUES (NEW.id, NEW.title, NEW.content);
+END;
+INSERT INTO notes_fts(notes_fts) VALUES ('rebuild');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_tag_associations_item;
+DROP INDEX IF EXISTS idx_tags_parent_id;
+ALTER TABLE tags DROP COLUMN description;
+ALTER TABLE tags DROP COLUMN parent_id;
@@ -0,0 +1,7 @@
+-- Tags can be nested under a parent tag and carry a description.
+ALTER TABLE tags ADD COLUMN parent_id INTEGER REFERENCES tags(id) ON DELETE SET NULL;
+ALTER TABLE tags ADD COLUMN description TEXT;
+CREATE INDEX IF NOT EXISTS idx_tags_parent_id ON tags(parent_id);
+
+-- Listing the items carrying a tag and the tags of an item.
+CREATE INDEX IF NOT EXISTS idx_tag_associations_item ON tag_associations(item_type, item_id);
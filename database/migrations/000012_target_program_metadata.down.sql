@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS scope_rule_history_update;
+DROP TRIGGER IF EXISTS scope_rule_history_delete;
+DROP TRIGGER IF EXISTS scope_rule_history_insert;
+DROP INDEX IF EXISTS idx_scope_rule_history_target_changed;
+DROP TABLE IF EXISTS scope_rule_history;
+
+ALTER TABLE targets DROP COLUMN scope_reviewed_at;
+ALTER TABLE targets DROP COLUMN scope_fetched_at;
+ALTER TABLE targets DROP COLUMN program_rules;
+ALTER TABLE targets DROP COLUMN payout_ranges;
@@ -0,0 +1,51 @@
+-- Bounty program metadata on targets
+ALTER TABLE targets ADD COLUMN payout_ranges TEXT; -- JSON array of {"severity","min","max","currency"}
+ALTER TABLE targets ADD COLUMN program_rules TEXT;
+ALTER TABLE targets ADD COLUMN scope_fetched_at DATETIME;  -- Last time the scope was fetched from the program
+ALTER TABLE targets ADD COLUMN scope_reviewed_at DATETIME; -- Last time scope changes were acknowledged
+
+-- Scope Rule History Table (one row per scope rule added or removed, written by triggers)
+CREATE TABLE IF NOT EXISTS scope_rule_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    scope_rule_id INTEGER, -- Not a foreign key: removed rules keep their history
+    change_type TEXT NOT NULL, -- 'added', 'removed'
+    item_type TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    is_in_scope BOOLEAN NOT NULL,
+    is_wildcard BOOLEAN NOT NULL,
+    description TEXT,
+    changed_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')), -- Millisecond precision so changes right after a review are not missed
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_scope_rule_history_target_changed ON scope_rule_history(target_id, changed_at);
+
+CREATE TRIGGER IF NOT EXISTS scope_rule_history_insert
+AFTER INSERT ON scope_rules FOR EACH ROW
+BEGIN
+    INSERT INTO scope_rule_history (target_id, scope_rule_id, change_type, item_type, pattern, is_in_scope, is_wildcard, description)
+    VALUES (NEW.target_id, NEW.id, 'added', NEW.item_type, NEW.pattern, NEW.is_in_scope, NEW.is_wildcard, NEW.description);
+END;
+
+-- Rows deleted by the targets cascade are skipped; their history goes with the target.
+CREATE TRIGGER IF NOT EXISTS scope_rule_history_delete
+AFTER DELETE ON scope_rules FOR EACH ROW
+WHEN EXISTS (SELECT 1 FROM targets WHERE id = OLD.target_id)
+BEGIN
+    INSERT INTO scope_rule_history (target_id, scope_rule_id, change_type, item_type, pattern, is_in_scope, is_wildcard, description)
+    VALUES (OLD.target_id, OLD.id, 'removed', OLD.item_type, OLD.pattern, OLD.is_in_scope, OLD.is_wildcard, OLD.description);
+END;
+
+-- An edited rule is recorded as the old rule removed and the new one added.
+CREATE TRIGGER IF NOT EXISTS scope_rule_history_update
+AFTER UPDATE OF item_type, pattern, is_in_scope, is_wildcard ON scope_rules FOR EACH ROW
+BEGIN
+    INSERT INTO scope_rule_history (target_id, scope_rule_id, change_type, item_type, pattern, is_in_scope, is_wildcard, description)
+    VALUES (OLD.target_id, OLD.id, 'removed', OLD.item_type, OLD.pattern, OLD.is_in_scope, OLD.is_wildcard, OLD.description);
+    INSERT INTO scope_rule_history (target_id, scope_rule_id, change_type, item_type, pattern, is_in_scope, is_wildcard, description)
+    VALUES (NEW.target_id, NEW.id, 'added', NEW.item_type, NEW.pattern, NEW.is_in_scope, NEW.is_wildcard, NEW.description);
+END;
+
+-- Existing rules become the baseline of the history.
+INSERT INTO scope_rule_history (target_id, scope_rule_id, change_type, item_type, pattern, is_in_scope, is_wildcard, description)
+SELECT target_id, id, 'added', item_type, pattern, is_in_scope, is_wildcard, description FROM scope_rules;
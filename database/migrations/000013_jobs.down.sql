@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_status;
+DROP INDEX IF EXISTS idx_jobs_type_target;
+DROP TABLE IF EXISTS jobs;
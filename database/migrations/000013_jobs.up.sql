@@ -0,0 +1,21 @@
+-- Jobs Table (background operations such as httpx scans, with their progress and outcome)
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_type TEXT NOT NULL, -- 'httpx', ...
+    target_id INTEGER,
+    status TEXT NOT NULL DEFAULT 'running', -- 'running', 'succeeded', 'failed', 'cancelled'
+    message TEXT NOT NULL DEFAULT '',
+    items_total INTEGER NOT NULL DEFAULT 0,
+    items_processed INTEGER NOT NULL DEFAULT 0,
+    items_succeeded INTEGER NOT NULL DEFAULT 0,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    retry_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    duration_ms INTEGER, -- Set when the job finishes
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_type_target ON jobs(job_type, target_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_ip_asset_domains_domain;
+DROP TABLE IF EXISTS ip_asset_domains;
+DROP INDEX IF EXISTS idx_ip_assets_asn;
+DROP INDEX IF EXISTS idx_ip_assets_target_scope;
+DROP TABLE IF EXISTS ip_assets;
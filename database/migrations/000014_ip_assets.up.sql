@@ -0,0 +1,36 @@
+-- IP Assets Table (IPs and CIDRs of a target: resolved from domains, taken from scope rules or added by hand)
+CREATE TABLE IF NOT EXISTS ip_assets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    address TEXT NOT NULL, -- Canonical IP ('192.0.2.10', '2001:db8::1') or CIDR ('192.0.2.0/24')
+    is_cidr BOOLEAN NOT NULL DEFAULT FALSE,
+    ip_version INTEGER NOT NULL, -- 4 or 6
+    source TEXT NOT NULL, -- 'dns', 'scope_rule', 'manual'
+    reverse_dns TEXT NOT NULL DEFAULT '', -- PTR names, comma-separated
+    asn INTEGER,
+    as_org TEXT NOT NULL DEFAULT '',
+    as_country TEXT NOT NULL DEFAULT '',
+    as_prefix TEXT NOT NULL DEFAULT '', -- Announced prefix containing the address
+    enriched_at DATETIME,
+    is_in_scope BOOLEAN NOT NULL DEFAULT FALSE,
+    scope_rule_id INTEGER, -- Scope rule that decided is_in_scope (NULL = no ip_address/cidr rule matched)
+    notes TEXT NOT NULL DEFAULT '',
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (scope_rule_id) REFERENCES scope_rules(id) ON DELETE SET NULL,
+    UNIQUE (target_id, address)
+);
+CREATE INDEX IF NOT EXISTS idx_ip_assets_target_scope ON ip_assets(target_id, is_in_scope);
+CREATE INDEX IF NOT EXISTS idx_ip_assets_asn ON ip_assets(asn);
+
+-- Domains resolving to an IP asset
+CREATE TABLE IF NOT EXISTS ip_asset_domains (
+    ip_asset_id INTEGER NOT NULL,
+    domain_id INTEGER NOT NULL,
+    resolved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ip_asset_id, domain_id),
+    FOREIGN KEY (ip_asset_id) REFERENCES ip_assets(id) ON DELETE CASCADE,
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_ip_asset_domains_domain ON ip_asset_domains(domain_id);
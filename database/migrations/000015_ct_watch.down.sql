@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ct_watch_state;
@@ -0,0 +1,12 @@
+-- Certificate transparency watch state, one row per wildcard scope pattern of a target
+CREATE TABLE IF NOT EXISTS ct_watch_state (
+    target_id INTEGER NOT NULL,
+    base_domain TEXT NOT NULL, -- 'example.com' for the scope rule '*.example.com'
+    last_cert_id INTEGER NOT NULL DEFAULT 0, -- Highest crt.sh certificate ID seen; newer certificates are new
+    certificates_seen INTEGER NOT NULL DEFAULT 0,
+    domains_added INTEGER NOT NULL DEFAULT 0,
+    last_checked_at DATETIME,
+    last_error TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (target_id, base_domain),
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_domains_favicon_hash;
+ALTER TABLE domains DROP COLUMN enriched_at;
+ALTER TABLE domains DROP COLUMN enrichment_json;
+ALTER TABLE domains DROP COLUMN open_ports;
+ALTER TABLE domains DROP COLUMN favicon_url;
+ALTER TABLE domains DROP COLUMN favicon_hash;
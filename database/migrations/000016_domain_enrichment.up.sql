@@ -0,0 +1,7 @@
+-- Favicon hash and Shodan/Censys enrichment of domains, stored next to the httpx results
+ALTER TABLE domains ADD COLUMN favicon_hash INTEGER; -- Shodan-style murmur3 hash (http.favicon.hash)
+ALTER TABLE domains ADD COLUMN favicon_url TEXT;
+ALTER TABLE domains ADD COLUMN open_ports TEXT; -- Comma-separated, from Shodan/Censys
+ALTER TABLE domains ADD COLUMN enrichment_json TEXT; -- Ports, certificates and related hosts (models.DomainEnrichment)
+ALTER TABLE domains ADD COLUMN enriched_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_domains_favicon_hash ON domains(favicon_hash);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_discovered_urls_target_source;
+DROP INDEX IF EXISTS idx_domain_harvests_target;
+DROP TABLE IF EXISTS domain_harvests;
@@ -0,0 +1,21 @@
+-- robots.txt, sitemap.xml and security.txt harvested from live domains, one row per domain
+CREATE TABLE IF NOT EXISTS domain_harvests (
+    domain_id INTEGER PRIMARY KEY,
+    target_id INTEGER NOT NULL,
+    base_url TEXT NOT NULL, -- Origin the files were fetched from, e.g. 'https://app.example.com'
+    robots_status INTEGER, -- HTTP status of /robots.txt (NULL = request failed)
+    robots_txt TEXT NOT NULL DEFAULT '',
+    disallowed_paths TEXT NOT NULL DEFAULT '[]', -- JSON array
+    sitemaps TEXT NOT NULL DEFAULT '[]', -- JSON array of sitemap URLs fetched
+    sitemap_url_count INTEGER NOT NULL DEFAULT 0,
+    security_txt_url TEXT NOT NULL DEFAULT '',
+    security_txt TEXT NOT NULL DEFAULT '',
+    security_contacts TEXT NOT NULL DEFAULT '[]', -- JSON array of Contact: values
+    security_fields TEXT NOT NULL DEFAULT '{}', -- JSON object of the other security.txt fields
+    errors TEXT NOT NULL DEFAULT '[]', -- JSON array
+    harvested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_domain_harvests_target ON domain_harvests(target_id);
+CREATE INDEX IF NOT EXISTS idx_discovered_urls_target_source ON discovered_urls(target_id, source_type);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_response_baselines_target;
+DROP TABLE IF EXISTS response_baselines;
@@ -0,0 +1,14 @@
+-- Per-domain soft-404/wildcard response signatures, from random-path probes and clustering of logged responses
+CREATE TABLE IF NOT EXISTS response_baselines (
+    domain_id INTEGER PRIMARY KEY,
+    target_id INTEGER NOT NULL,
+    base_url TEXT NOT NULL, -- Origin probed, e.g. 'https://app.example.com'
+    is_wildcard BOOLEAN NOT NULL DEFAULT FALSE, -- Unknown paths do not return a plain 404
+    signatures TEXT NOT NULL DEFAULT '[]', -- JSON array of models.ResponseSignature
+    responses_clustered INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_response_baselines_target ON response_baselines(target_id);
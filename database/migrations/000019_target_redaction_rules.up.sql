@@ -0,0 +1,12 @@
+-- Per-target adjustments of the global redaction rules (config redaction section)
+CREATE TABLE IF NOT EXISTS target_redaction_rules (
+    target_id INTEGER PRIMARY KEY,
+    enabled BOOLEAN, -- NULL inherits the global switch
+    apply_at TEXT NOT NULL DEFAULT '', -- '' inherits; 'storage', 'export' or 'both'
+    replace_global BOOLEAN NOT NULL DEFAULT FALSE, -- Use only these lists instead of adding them to the global ones
+    headers TEXT NOT NULL DEFAULT '[]', -- JSON arrays
+    json_paths TEXT NOT NULL DEFAULT '[]',
+    parameters TEXT NOT NULL DEFAULT '[]',
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- Hosts whose clients rejected the proxy's MITM certificate (likely certificate pinning)
+CREATE TABLE IF NOT EXISTS tls_handshake_failures (
+    host TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    successes INTEGER NOT NULL DEFAULT 0, -- Intercepted connections that carried a request, counted once the host has failed
+    certificate_rejections INTEGER NOT NULL DEFAULT 0, -- Failures where the client sent a certificate alert
+    last_error TEXT NOT NULL DEFAULT '',
+    first_failure_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_failure_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_success_at DATETIME
+);
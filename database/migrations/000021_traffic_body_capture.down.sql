@@ -0,0 +1,2 @@
+ALTER TABLE http_traffic_log DROP COLUMN response_body_truncated;
+ALTER TABLE http_traffic_log DROP COLUMN response_body_sha256;
@@ -0,0 +1,2 @@
+ALTER TABLE http_traffic_log ADD COLUMN response_body_sha256 TEXT;
+ALTER TABLE http_traffic_log ADD COLUMN response_body_truncated BOOLEAN NOT NULL DEFAULT 0;
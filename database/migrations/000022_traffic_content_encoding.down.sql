@@ -0,0 +1 @@
+ALTER TABLE http_traffic_log DROP COLUMN response_content_encoding;
@@ -0,0 +1 @@
+ALTER TABLE http_traffic_log ADD COLUMN response_content_encoding TEXT;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS target_variables_updated_at;
+DROP TABLE IF EXISTS target_variables;
+DROP INDEX IF EXISTS idx_modifier_tasks_collection_id;
+ALTER TABLE modifier_tasks DROP COLUMN collection_id;
+DROP TRIGGER IF EXISTS modifier_collections_updated_at;
+DROP TABLE IF EXISTS modifier_collections;
@@ -0,0 +1,33 @@
+-- Folders grouping modifier tasks, exportable as Postman collections
+CREATE TABLE IF NOT EXISTS modifier_collections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_modifier_collections_target_name ON modifier_collections(IFNULL(target_id, 0), name);
+CREATE TRIGGER IF NOT EXISTS modifier_collections_updated_at
+AFTER UPDATE ON modifier_collections FOR EACH ROW
+BEGIN UPDATE modifier_collections SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
+
+-- No REFERENCES clause so the down migration can drop the column; DeleteModifierCollection clears it
+ALTER TABLE modifier_tasks ADD COLUMN collection_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_modifier_tasks_collection_id ON modifier_tasks(collection_id);
+
+-- Per-target variables substituted as {{name}} into modifier requests at execution time
+CREATE TABLE IF NOT EXISTS target_variables (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_id, name),
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE TRIGGER IF NOT EXISTS target_variables_updated_at
+AFTER UPDATE ON target_variables FOR EACH ROW
+BEGIN UPDATE target_variables SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
@@ -0,0 +1 @@
+ALTER TABLE modifier_tasks DROP COLUMN base_request_raw;
@@ -0,0 +1,2 @@
+-- Raw request of the Modifier's raw editing mode, base64 encoded so it is stored byte for byte
+ALTER TABLE modifier_tasks ADD COLUMN base_request_raw TEXT;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_replay_batches_page_id;
+ALTER TABLE replay_batches DROP COLUMN carry_cookies;
+ALTER TABLE replay_batches DROP COLUMN preserve_timing;
+ALTER TABLE replay_batches DROP COLUMN page_id;
@@ -0,0 +1,7 @@
+-- Flow replays: a replay batch built from the logs of a recorded Page Sitemap page.
+-- page_id has no REFERENCES clause so the column can be dropped again; a deleted page leaves
+-- its replays in place as history.
+ALTER TABLE replay_batches ADD COLUMN page_id INTEGER;
+ALTER TABLE replay_batches ADD COLUMN preserve_timing BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE replay_batches ADD COLUMN carry_cookies BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_replay_batches_page_id ON replay_batches(page_id);
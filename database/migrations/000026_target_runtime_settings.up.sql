@@ -0,0 +1,9 @@
+-- Per-target overrides of runtime settings (see config.RuntimeSettings), values JSON encoded
+CREATE TABLE IF NOT EXISTS target_runtime_settings (
+    target_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (target_id, key),
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- How often each global proxy exclusion rule (see app_settings proxy_exclusion_rules) skipped a request
+CREATE TABLE IF NOT EXISTS proxy_exclusion_rule_hits (
+    rule_id TEXT PRIMARY KEY,
+    hits INTEGER NOT NULL DEFAULT 0,
+    last_hit_at DATETIME
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS canonical_urls;
+DROP INDEX IF EXISTS idx_discovered_urls_canonical;
+ALTER TABLE discovered_urls DROP COLUMN canonical_url;
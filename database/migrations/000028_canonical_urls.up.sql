@@ -0,0 +1,17 @@
+-- Canonical form of each discovered URL (see url_canonical.strip_params); NULL for rows stored before it existed
+ALTER TABLE discovered_urls ADD COLUMN canonical_url TEXT;
+CREATE INDEX IF NOT EXISTS idx_discovered_urls_canonical ON discovered_urls(target_id, canonical_url);
+
+-- One row per unique canonical URL of a target, whatever the source and however often it was seen
+CREATE TABLE IF NOT EXISTS canonical_urls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    canonical_url TEXT NOT NULL,
+    example_url TEXT NOT NULL,
+    sources TEXT NOT NULL DEFAULT '',
+    is_in_scope BOOLEAN NOT NULL DEFAULT FALSE,
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    UNIQUE (target_id, canonical_url)
+);
@@ -0,0 +1,9 @@
+-- Per-target HTTP client profile: headers the toolkit adds to the requests it sends for a target
+CREATE TABLE IF NOT EXISTS target_client_profiles (
+    target_id INTEGER PRIMARY KEY,
+    user_agent TEXT NOT NULL DEFAULT '',
+    headers TEXT NOT NULL DEFAULT '{}', -- JSON object of header name to value
+    cookies TEXT NOT NULL DEFAULT '', -- Cookie header value ("a=1; b=2")
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_session_events_target;
+DROP TABLE IF EXISTS session_events;
+DROP TABLE IF EXISTS target_cookies;
@@ -0,0 +1,39 @@
+-- Cookies set by each target's responses in captured traffic, keyed like a browser cookie jar
+CREATE TABLE IF NOT EXISTS target_cookies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    domain TEXT NOT NULL,
+    path TEXT NOT NULL DEFAULT '/',
+    name TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    is_session BOOLEAN NOT NULL DEFAULT 0, -- Name matches cookie_jar.session_cookies
+    secure BOOLEAN NOT NULL DEFAULT 0,
+    http_only BOOLEAN NOT NULL DEFAULT 0,
+    same_site TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME, -- NULL for browser-session cookies
+    expired BOOLEAN NOT NULL DEFAULT 0, -- Cleared by the server (Max-Age <= 0 or an Expires in the past) or past expires_at
+    set_count INTEGER NOT NULL DEFAULT 0,
+    change_count INTEGER NOT NULL DEFAULT 0, -- Times the value was replaced by a different one
+    source_log_id INTEGER, -- Last response that set the cookie
+    first_seen_at DATETIME NOT NULL,
+    last_set_at DATETIME NOT NULL,
+    UNIQUE (target_id, domain, path, name),
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL
+);
+
+-- Session changes seen in captured traffic: rotated or cleared session cookies and authenticated
+-- requests answered with 401 or a redirect to a login page
+CREATE TABLE IF NOT EXISTS session_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    cookie_name TEXT NOT NULL DEFAULT '',
+    event_type TEXT NOT NULL, -- 'rotated', 'expired', 'unauthorized', 'login_redirect', 'vault_updated'
+    http_traffic_log_id INTEGER,
+    details TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (http_traffic_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_events_target ON session_events(target_id, created_at);
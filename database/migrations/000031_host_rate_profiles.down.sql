@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_host_rate_profiles_target;
+DROP TABLE IF EXISTS host_rate_profiles;
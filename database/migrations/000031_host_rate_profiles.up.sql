@@ -0,0 +1,23 @@
+-- Rate-limit and WAF behavior of a host, measured by the rate profiler. safe_rps caps the rate of
+-- every toolkit-initiated request to the host.
+CREATE TABLE IF NOT EXISTS host_rate_profiles (
+    host TEXT PRIMARY KEY, -- Lower-case host name without port
+    target_id INTEGER,
+    endpoint TEXT NOT NULL DEFAULT '', -- URL the bursts were sent to
+    method TEXT NOT NULL DEFAULT 'GET',
+    baseline_status INTEGER NOT NULL DEFAULT 0,
+    safe_rps REAL, -- NULL when no throttling was seen and none was set by hand
+    manual INTEGER NOT NULL DEFAULT 0, -- safe_rps was set by hand
+    throttle_rps REAL, -- Rate of the step at which throttling began
+    throttle_status INTEGER,
+    retry_after TEXT NOT NULL DEFAULT '',
+    max_tested_rps REAL NOT NULL DEFAULT 0,
+    requests_sent INTEGER NOT NULL DEFAULT 0,
+    wafs TEXT NOT NULL DEFAULT '[]', -- JSON array of detected WAFs with evidence
+    steps TEXT NOT NULL DEFAULT '[]', -- JSON array of burst steps and their status counts
+    profiled_at DATETIME,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_host_rate_profiles_target ON host_rate_profiles(target_id);
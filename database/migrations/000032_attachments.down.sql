@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS attachments_delete_checklist_item;
+DROP TRIGGER IF EXISTS attachments_delete_note;
+DROP TRIGGER IF EXISTS attachments_delete_finding;
+DROP INDEX IF EXISTS idx_attachments_sha256;
+DROP INDEX IF EXISTS idx_attachments_target;
+DROP INDEX IF EXISTS idx_attachments_entity_sha256;
+DROP TABLE IF EXISTS attachments;
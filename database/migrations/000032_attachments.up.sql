@@ -0,0 +1,31 @@
+-- Attachments Table (files such as screenshots, PoCs, reports and tool output attached to targets,
+-- findings, notes and checklist items). The content is stored once per SHA-256 in the attachments
+-- directory; rows attaching the same file to several entities share it.
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    entity_type TEXT NOT NULL, -- 'target', 'finding', 'note', 'checklist_item'
+    entity_id INTEGER NOT NULL,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attachments_entity_sha256 ON attachments(entity_type, entity_id, sha256);
+CREATE INDEX IF NOT EXISTS idx_attachments_target ON attachments(target_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_sha256 ON attachments(sha256);
+
+-- Attachments have no foreign key to their entity, so remove them when the entity goes away.
+-- Files no longer referenced are pruned from disk when the server starts.
+CREATE TRIGGER IF NOT EXISTS attachments_delete_finding
+AFTER DELETE ON target_findings FOR EACH ROW
+BEGIN DELETE FROM attachments WHERE entity_type = 'finding' AND entity_id = OLD.id; END;
+CREATE TRIGGER IF NOT EXISTS attachments_delete_note
+AFTER DELETE ON notes FOR EACH ROW
+BEGIN DELETE FROM attachments WHERE entity_type = 'note' AND entity_id = OLD.id; END;
+CREATE TRIGGER IF NOT EXISTS attachments_delete_checklist_item
+AFTER DELETE ON target_checklist_items FOR EACH ROW
+BEGIN DELETE FROM attachments WHERE entity_type = 'checklist_item' AND entity_id = OLD.id; END;
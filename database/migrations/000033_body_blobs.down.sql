@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS body_blobs_ref_update_response;
+DROP TRIGGER IF EXISTS body_blobs_ref_update_request;
+DROP TRIGGER IF EXISTS body_blobs_ref_delete;
+DROP TRIGGER IF EXISTS body_blobs_ref_insert;
+
+-- Move blob bodies back inline before dropping the blobs.
+UPDATE http_traffic_log SET request_body = (SELECT data FROM body_blobs WHERE id = request_body_blob_id)
+WHERE request_body_blob_id IS NOT NULL;
+UPDATE http_traffic_log SET response_body = (SELECT data FROM body_blobs WHERE id = response_body_blob_id)
+WHERE response_body_blob_id IS NOT NULL;
+ALTER TABLE http_traffic_log DROP COLUMN response_body_blob_id;
+ALTER TABLE http_traffic_log DROP COLUMN request_body_blob_id;
+DROP TABLE IF EXISTS body_blobs;
@@ -0,0 +1,47 @@
+-- Body Blobs Table (content-addressed request and response bodies of http_traffic_log). A log
+-- entry keeps a body either inline in request_body/response_body or as a reference to a blob
+-- shared by every entry with the same body.
+CREATE TABLE IF NOT EXISTS body_blobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sha256 TEXT NOT NULL UNIQUE,
+    data BLOB NOT NULL,
+    size INTEGER NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0, -- Log entry bodies referencing the blob, kept by the triggers below
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE http_traffic_log ADD COLUMN request_body_blob_id INTEGER;
+ALTER TABLE http_traffic_log ADD COLUMN response_body_blob_id INTEGER;
+
+-- Reference counting: blobs are deleted when the last log entry referencing them goes away.
+CREATE TRIGGER IF NOT EXISTS body_blobs_ref_insert
+AFTER INSERT ON http_traffic_log FOR EACH ROW
+WHEN NEW.request_body_blob_id IS NOT NULL OR NEW.response_body_blob_id IS NOT NULL
+BEGIN
+    UPDATE body_blobs SET ref_count = ref_count + 1 WHERE id = NEW.request_body_blob_id;
+    UPDATE body_blobs SET ref_count = ref_count + 1 WHERE id = NEW.response_body_blob_id;
+END;
+CREATE TRIGGER IF NOT EXISTS body_blobs_ref_delete
+AFTER DELETE ON http_traffic_log FOR EACH ROW
+WHEN OLD.request_body_blob_id IS NOT NULL OR OLD.response_body_blob_id IS NOT NULL
+BEGIN
+    UPDATE body_blobs SET ref_count = ref_count - 1 WHERE id = OLD.request_body_blob_id;
+    UPDATE body_blobs SET ref_count = ref_count - 1 WHERE id = OLD.response_body_blob_id;
+    DELETE FROM body_blobs WHERE id IN (OLD.request_body_blob_id, OLD.response_body_blob_id) AND ref_count <= 0;
+END;
+CREATE TRIGGER IF NOT EXISTS body_blobs_ref_update_request
+AFTER UPDATE OF request_body_blob_id ON http_traffic_log FOR EACH ROW
+WHEN OLD.request_body_blob_id IS NOT NEW.request_body_blob_id
+BEGIN
+    UPDATE body_blobs SET ref_count = ref_count + 1 WHERE id = NEW.request_body_blob_id;
+    UPDATE body_blobs SET ref_count = ref_count - 1 WHERE id = OLD.request_body_blob_id;
+    DELETE FROM body_blobs WHERE id = OLD.request_body_blob_id AND ref_count <= 0;
+END;
+CREATE TRIGGER IF NOT EXISTS body_blobs_ref_update_response
+AFTER UPDATE OF response_body_blob_id ON http_traffic_log FOR EACH ROW
+WHEN OLD.response_body_blob_id IS NOT NEW.response_body_blob_id
+BEGIN
+    UPDATE body_blobs SET ref_count = ref_count + 1 WHERE id = NEW.response_body_blob_id;
+    UPDATE body_blobs SET ref_count = ref_count - 1 WHERE id = OLD.response_body_blob_id;
+    DELETE FROM body_blobs WHERE id = OLD.response_body_blob_id AND ref_count <= 0;
+END;
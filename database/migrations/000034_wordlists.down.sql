@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS wordlists_delete_tags;
+DROP INDEX IF EXISTS idx_wordlists_target;
+DROP TABLE IF EXISTS wordlists;
@@ -0,0 +1,21 @@
+-- Wordlists Table (uploaded, merged or generated wordlists for brute-forcing and fuzzing). The
+-- words are stored one per line in <wordlists.dir>/<id>.txt; this table holds their metadata.
+CREATE TABLE IF NOT EXISTS wordlists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    target_id INTEGER,
+    source TEXT NOT NULL DEFAULT 'upload', -- 'upload', 'merge', 'generated'
+    word_count INTEGER NOT NULL DEFAULT 0,
+    size INTEGER NOT NULL DEFAULT 0,
+    sha256 TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_wordlists_target ON wordlists(target_id, source);
+
+-- Tags of a wordlist go with it.
+CREATE TRIGGER IF NOT EXISTS wordlists_delete_tags
+AFTER DELETE ON wordlists FOR EACH ROW
+BEGIN DELETE FROM tag_associations WHERE item_type = 'wordlist' AND item_id = OLD.id; END;
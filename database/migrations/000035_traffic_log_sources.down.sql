@@ -0,0 +1,2 @@
+-- The relabelled log sources are kept: they are valid for the previous schema too.
+DROP INDEX IF EXISTS idx_http_traffic_log_target_source;
@@ -0,0 +1,18 @@
+-- Every traffic log entry names its source, so manual browsing (mitmproxy, Page Sitemap, Modifier)
+-- can be told apart from the toolkit's own requests. Entries from before log_source was recorded
+-- were captured by the proxy.
+UPDATE http_traffic_log SET log_source = 'mitmproxy' WHERE log_source IS NULL OR log_source = '';
+
+-- Toolkit-initiated requests the proxy logged as browsing: during a page recording, or from a
+-- sender without a source of its own.
+UPDATE http_traffic_log SET log_source = 'Replay'
+WHERE log_source IN ('mitmproxy', 'Page Sitemap') AND replay_batch_id IS NOT NULL;
+UPDATE http_traffic_log SET log_source = 'JS Analysis'
+WHERE log_source IN ('mitmproxy', 'Page Sitemap')
+    AND (request_headers LIKE '%"X-Toolkit-Source":["JS-Path-Sender"]%' OR request_headers LIKE '%"X-Toolkit-Source":["JS-Sourcemap"]%');
+UPDATE http_traffic_log SET log_source = 'GraphQL Introspection'
+WHERE log_source IN ('mitmproxy', 'Page Sitemap') AND request_headers LIKE '%"X-Toolkit-Source":["GraphQL-Introspection"]%';
+UPDATE http_traffic_log SET log_source = 'Toolkit'
+WHERE log_source IN ('mitmproxy', 'Page Sitemap') AND request_headers LIKE '%"X-Toolkit-Initiated":["true"]%';
+
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_target_source ON http_traffic_log(target_id, log_source);
@@ -0,0 +1,9 @@
+-- Proxy Listeners Table (additional proxy ports, each bound to a target whose scope it checks and
+-- which it logs its traffic for, whichever target is active). They start with the proxy.
+CREATE TABLE IF NOT EXISTS proxy_listeners (
+    port TEXT PRIMARY KEY,
+    target_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_login_flow_steps_flow;
+DROP TABLE IF EXISTS login_flow_steps;
+DROP TABLE IF EXISTS login_flows;
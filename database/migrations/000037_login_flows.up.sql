@@ -0,0 +1,33 @@
+-- Login Flows Table (the requests that log into a target, replayed to mint a fresh session when
+-- the current one ends). One flow per target.
+CREATE TABLE IF NOT EXISTS login_flows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL UNIQUE,
+    session_id INTEGER,                         -- Replay session updated with the minted credentials
+    extractors TEXT NOT NULL DEFAULT '[]',      -- JSON list of token extraction rules
+    session_headers TEXT NOT NULL DEFAULT '{}', -- JSON header templates ({{name}}) set on the session
+    auto_refresh BOOLEAN NOT NULL DEFAULT 0,    -- Run when the target's session ends (401, login redirect)
+    last_run_at DATETIME,
+    last_status TEXT NOT NULL DEFAULT '',       -- 'succeeded' or 'failed'
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (session_id) REFERENCES replay_sessions(id) ON DELETE SET NULL
+);
+
+-- Login Flow Steps Table (the requests of a flow, copied from the traffic log when it was set).
+CREATE TABLE IF NOT EXISTS login_flow_steps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    flow_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    method TEXT NOT NULL,
+    url TEXT NOT NULL,
+    headers TEXT NOT NULL DEFAULT '{}',
+    body BLOB,
+    source_log_id INTEGER,
+    FOREIGN KEY (flow_id) REFERENCES login_flows(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_flow_steps_flow ON login_flow_steps(flow_id, position);
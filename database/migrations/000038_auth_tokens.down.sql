@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_auth_token_occurrences_log;
+DROP TABLE IF EXISTS auth_token_occurrences;
+DROP TABLE IF EXISTS auth_tokens;
@@ -0,0 +1,30 @@
+-- Auth Tokens Table (JWTs and SAML messages seen in a target's captured traffic, one row per
+-- distinct token).
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,                   -- 'jwt' or 'saml'
+    token_hash TEXT NOT NULL,             -- SHA-256 of the token, hex
+    token TEXT NOT NULL,
+    algorithm TEXT NOT NULL DEFAULT '',
+    issuer TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',     -- JWT sub, or SAML NameID
+    expires_at DATETIME,
+    signed BOOLEAN NOT NULL DEFAULT 0,
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_id, token_hash),
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+
+-- Auth Token Occurrences Table (which log entries carried which token, and where).
+CREATE TABLE IF NOT EXISTS auth_token_occurrences (
+    token_id INTEGER NOT NULL,
+    http_traffic_log_id INTEGER NOT NULL,
+    location TEXT NOT NULL,               -- url, request_header:NAME, request_body, response_header:NAME, response_body
+    PRIMARY KEY (token_id, http_traffic_log_id, location),
+    FOREIGN KEY (token_id) REFERENCES auth_tokens(id) ON DELETE CASCADE,
+    FOREIGN KEY (http_traffic_log_id) REFERENCES http_traffic_log(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_token_occurrences_log ON auth_token_occurrences(http_traffic_log_id);
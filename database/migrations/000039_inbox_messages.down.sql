@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inbox_messages_target;
+DROP INDEX IF EXISTS idx_inbox_messages_message_id;
+DROP TABLE IF EXISTS inbox_messages;
@@ -0,0 +1,23 @@
+-- Inbox Messages Table (emails of the test inbox, with the verification codes and links found in
+-- them and the target they were correlated to).
+CREATE TABLE IF NOT EXISTS inbox_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    match_reason TEXT NOT NULL DEFAULT '', -- 'address_tag', 'scope', 'current_target' or '' when uncorrelated
+    source TEXT NOT NULL,                  -- 'imap' or 'webhook'
+    message_id TEXT NOT NULL DEFAULT '',   -- Message-ID header
+    from_address TEXT NOT NULL DEFAULT '',
+    to_addresses TEXT NOT NULL DEFAULT '[]', -- JSON array
+    subject TEXT NOT NULL DEFAULT '',
+    body_text TEXT NOT NULL DEFAULT '',
+    body_html TEXT NOT NULL DEFAULT '',
+    codes TEXT NOT NULL DEFAULT '[]',        -- JSON array
+    links TEXT NOT NULL DEFAULT '[]',        -- JSON array
+    magic_links TEXT NOT NULL DEFAULT '[]',  -- JSON array
+    sent_at DATETIME,
+    received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inbox_messages_message_id ON inbox_messages(message_id) WHERE message_id != '';
+CREATE INDEX IF NOT EXISTS idx_inbox_messages_target ON inbox_messages(target_id, received_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_domains_takeover_status;
+ALTER TABLE domains DROP COLUMN takeover_checked_at;
+ALTER TABLE domains DROP COLUMN takeover_evidence_log_id;
+ALTER TABLE domains DROP COLUMN takeover_cname;
+ALTER TABLE domains DROP COLUMN takeover_service;
+ALTER TABLE domains DROP COLUMN takeover_status;
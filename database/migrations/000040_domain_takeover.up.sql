@@ -0,0 +1,7 @@
+-- Subdomain takeover verification results of domains (POST /targets/{id}/domains/takeover-check)
+ALTER TABLE domains ADD COLUMN takeover_status TEXT; -- vulnerable, dangling or not_vulnerable
+ALTER TABLE domains ADD COLUMN takeover_service TEXT; -- Service whose CNAME or fingerprint matched
+ALTER TABLE domains ADD COLUMN takeover_cname TEXT; -- CNAME chain, e.g. "a.example.com -> x.herokudns.com"
+ALTER TABLE domains ADD COLUMN takeover_evidence_log_id INTEGER; -- http_traffic_log entry of the fingerprinted response
+ALTER TABLE domains ADD COLUMN takeover_checked_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_domains_takeover_status ON domains(takeover_status);
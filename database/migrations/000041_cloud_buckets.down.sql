@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_cloud_buckets_target_status;
+DROP TABLE IF EXISTS cloud_buckets;
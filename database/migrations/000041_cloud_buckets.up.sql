@@ -0,0 +1,22 @@
+-- Cloud storage buckets of a target (S3, GCS, Azure Blob): candidates derived from the target's
+-- names and domains, and buckets referenced in its traffic or JavaScript, with their permissions
+CREATE TABLE IF NOT EXISTS cloud_buckets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    provider TEXT NOT NULL, -- 's3', 'gcs', 'azure'
+    name TEXT NOT NULL, -- Bucket name; 'account/container' for Azure
+    url TEXT NOT NULL, -- Listing URL of the bucket
+    source TEXT NOT NULL, -- 'candidate', 'traffic', 'js', 'manual'
+    source_log_id INTEGER, -- Traffic log entry referencing the bucket
+    status TEXT NOT NULL DEFAULT 'unchecked', -- 'unchecked', 'not_found', 'private', 'public_read', 'error'
+    public_write BOOLEAN, -- NULL = not tested
+    details TEXT NOT NULL DEFAULT '', -- Region, sample keys, granted permissions or the error
+    evidence_log_id INTEGER, -- Traffic log entry of the response that decided the status
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    checked_at DATETIME,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL,
+    FOREIGN KEY (evidence_log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL,
+    UNIQUE (target_id, provider, name)
+);
+CREATE INDEX IF NOT EXISTS idx_cloud_buckets_target_status ON cloud_buckets(target_id, status);
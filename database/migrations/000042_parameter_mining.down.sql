@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_parameterized_urls_source;
+ALTER TABLE parameterized_urls DROP COLUMN confidence;
+ALTER TABLE parameterized_urls DROP COLUMN source;
@@ -0,0 +1,5 @@
+-- Parameters found by parameter mining (POST /targets/{id}/parameters/mine) join the parameter
+-- inventory with the confidence of the response change that revealed them.
+ALTER TABLE parameterized_urls ADD COLUMN source TEXT NOT NULL DEFAULT 'traffic'; -- traffic or param_mining
+ALTER TABLE parameterized_urls ADD COLUMN confidence TEXT; -- low, medium or high for mined parameters
+CREATE INDEX IF NOT EXISTS idx_parameterized_urls_source ON parameterized_urls(target_id, source);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_http_traffic_log_request_fingerprint;
+ALTER TABLE http_traffic_log DROP COLUMN cached_from_log_id;
+ALTER TABLE http_traffic_log DROP COLUMN request_fingerprint;
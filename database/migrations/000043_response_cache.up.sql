@@ -0,0 +1,6 @@
+-- The response cache (response_cache in the config) answers a toolkit-initiated request repeated
+-- within its window with the response logged for it. Entries that may be reused keep the
+-- fingerprint of their request; entries answered from the cache point at the entry reused.
+ALTER TABLE http_traffic_log ADD COLUMN request_fingerprint TEXT;
+ALTER TABLE http_traffic_log ADD COLUMN cached_from_log_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_request_fingerprint ON http_traffic_log(request_fingerprint) WHERE request_fingerprint IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_http_traffic_log_tls_connection_id;
+ALTER TABLE http_traffic_log DROP COLUMN tls_connection_id;
+DROP INDEX IF EXISTS idx_tls_connections_host;
+DROP TABLE IF EXISTS tls_connections;
@@ -0,0 +1,20 @@
+-- TLS parameters the proxy negotiated with servers. Connections to a host that negotiated the same
+-- version, cipher suite and ALPN protocol with the same SNI and certificate chain share a row, and
+-- traffic log entries point at the row of the connection they were sent over.
+CREATE TABLE IF NOT EXISTS tls_connections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fingerprint TEXT NOT NULL UNIQUE, -- SHA-256 of the host, the parameters and the chain
+    host TEXT NOT NULL,
+    server_name TEXT NOT NULL DEFAULT '', -- SNI sent to the server
+    tls_version TEXT NOT NULL,
+    cipher_suite TEXT NOT NULL,
+    alpn TEXT NOT NULL DEFAULT '',
+    certificate_chain TEXT NOT NULL DEFAULT '[]', -- JSON summary of the certificates, leaf first
+    untrusted_reason TEXT NOT NULL DEFAULT '', -- Why the chain does not verify against the system roots
+    hostname_mismatch BOOLEAN NOT NULL DEFAULT 0, -- The leaf certificate does not cover the host
+    first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_tls_connections_host ON tls_connections(host);
+
+ALTER TABLE http_traffic_log ADD COLUMN tls_connection_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_tls_connection_id ON http_traffic_log(tls_connection_id) WHERE tls_connection_id IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_engagement_sessions_running;
+DROP INDEX IF EXISTS idx_engagement_sessions_target;
+DROP TABLE IF EXISTS engagement_sessions;
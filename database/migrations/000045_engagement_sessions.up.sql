@@ -0,0 +1,22 @@
+-- Time spent on a target. Sessions are started and stopped by the user (source 'manual') or
+-- started by browsing the target through the proxy and ended after engagement.idle_timeout_minutes
+-- without any (source 'auto'). A target has at most one running session.
+CREATE TABLE IF NOT EXISTS engagement_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT 'manual',
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME, -- NULL while running
+    last_activity_at DATETIME, -- Latest request of the user to the target during the session
+    planned_minutes INTEGER NOT NULL DEFAULT 0, -- Time budget; 0 for none
+    due_at DATETIME, -- Deadline, e.g. of a patch verification
+    schedule_alerted BOOLEAN NOT NULL DEFAULT 0, -- The over-budget or deadline notification was sent
+    requests INTEGER NOT NULL DEFAULT 0, -- Requests logged for the target; stored when the session ends
+    manual_requests INTEGER NOT NULL DEFAULT 0,
+    active_seconds INTEGER NOT NULL DEFAULT 0, -- Time with requests no further apart than the idle timeout
+    notes TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_engagement_sessions_target ON engagement_sessions(target_id, started_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_engagement_sessions_running ON engagement_sessions(target_id) WHERE ended_at IS NULL;
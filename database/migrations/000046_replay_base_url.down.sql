@@ -0,0 +1 @@
+ALTER TABLE replay_batches DROP COLUMN base_url;
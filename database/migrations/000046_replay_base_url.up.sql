@@ -0,0 +1,3 @@
+-- Environment comparisons: a replay batch sending the captured requests to another base URL
+-- (e.g. staging instead of production).
+ALTER TABLE replay_batches ADD COLUMN base_url TEXT;
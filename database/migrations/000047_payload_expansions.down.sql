@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_payload_expansions_log;
+DROP INDEX IF EXISTS idx_payload_expansions_value;
+DROP INDEX IF EXISTS idx_payload_expansions_oob_id;
+DROP TABLE IF EXISTS payload_expansions;
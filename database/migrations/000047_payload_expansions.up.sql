@@ -0,0 +1,19 @@
+-- Values generated for payload placeholders ({{oob}}, {{random}}, {{timestamp}},
+-- {{target.domain}}) when a Modifier request was sent or a payload list was expanded, so that an
+-- out-of-band interaction or a reflected value can be traced back to the request that carried it.
+CREATE TABLE IF NOT EXISTS payload_expansions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER,
+    log_id INTEGER, -- Log entry of the request; NULL for payload lists
+    source TEXT NOT NULL, -- Modifier, Payload list
+    placeholder TEXT NOT NULL, -- Name without braces, e.g. oob
+    value TEXT NOT NULL,
+    oob_id TEXT, -- Unique identifier inside {{oob}} values
+    context TEXT NOT NULL DEFAULT '', -- Request line, or the payload as written
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE,
+    FOREIGN KEY (log_id) REFERENCES http_traffic_log(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_payload_expansions_oob_id ON payload_expansions(oob_id);
+CREATE INDEX IF NOT EXISTS idx_payload_expansions_value ON payload_expansions(value);
+CREATE INDEX IF NOT EXISTS idx_payload_expansions_log ON payload_expansions(log_id);
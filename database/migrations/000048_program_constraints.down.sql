@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_program_constraint_events_target;
+DROP TABLE IF EXISTS program_constraint_events;
+DROP TABLE IF EXISTS target_program_constraints;
@@ -0,0 +1,24 @@
+-- Limits a program imposes on testing a target, enforced by the proxy on the toolkit's own requests
+CREATE TABLE IF NOT EXISTS target_program_constraints (
+    target_id INTEGER PRIMARY KEY,
+    max_rps REAL NOT NULL DEFAULT 0, -- Requests per second across the target's hosts; 0 for no limit
+    banned_paths TEXT NOT NULL DEFAULT '[]', -- JSON array of path patterns
+    required_headers TEXT NOT NULL DEFAULT '{}', -- JSON object of header name to value
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+
+-- Requests the proxy blocked, changed or delayed to keep to a target's program constraints
+CREATE TABLE IF NOT EXISTS program_constraint_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    action TEXT NOT NULL, -- blocked, header_set, delayed
+    method TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '', -- Log source of the toolkit request
+    detail TEXT NOT NULL DEFAULT '',
+    delay_ms INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_program_constraint_events_target ON program_constraint_events(target_id, id);
@@ -0,0 +1,16 @@
+-- User scripts the proxy runs on request and response events
+CREATE TABLE IF NOT EXISTS proxy_scripts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    path TEXT NOT NULL, -- Script file
+    interpreter TEXT NOT NULL DEFAULT '', -- Command running the file; empty to pick one by extension
+    events TEXT NOT NULL DEFAULT '["request"]', -- JSON array of request, response
+    target_id INTEGER, -- Only traffic of this target; NULL for all traffic
+    host_pattern TEXT NOT NULL DEFAULT '', -- Only hosts matching this pattern (*.example.com); empty for all
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    timeout_ms INTEGER NOT NULL DEFAULT 0, -- 0 for the default
+    position INTEGER NOT NULL DEFAULT 0, -- Scripts run in ascending position, then ID
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- Plugins turned on or off. Plugins are found in plugins.dir; a plugin without a row is disabled.
+CREATE TABLE IF NOT EXISTS plugins (
+    name TEXT PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    manifest_sha256 TEXT NOT NULL DEFAULT '', -- plugin.json when it was enabled; a changed manifest must be enabled again
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,51 @@
+DROP TRIGGER IF EXISTS http_traffic_log_sync_select;
+
+DROP TRIGGER IF EXISTS target_findings_sync_delete;
+DROP TRIGGER IF EXISTS target_findings_sync_update;
+DROP TRIGGER IF EXISTS target_findings_sync_insert;
+DROP INDEX IF EXISTS idx_target_findings_sync_seq;
+DROP INDEX IF EXISTS idx_target_findings_sync_id;
+ALTER TABLE target_findings DROP COLUMN sync_seq;
+ALTER TABLE target_findings DROP COLUMN sync_updated_at;
+ALTER TABLE target_findings DROP COLUMN sync_id;
+
+DROP TRIGGER IF EXISTS http_traffic_log_sync_delete;
+DROP TRIGGER IF EXISTS http_traffic_log_sync_update;
+DROP TRIGGER IF EXISTS http_traffic_log_sync_insert;
+DROP INDEX IF EXISTS idx_http_traffic_log_sync_seq;
+DROP INDEX IF EXISTS idx_http_traffic_log_sync_id;
+ALTER TABLE http_traffic_log DROP COLUMN sync_seq;
+ALTER TABLE http_traffic_log DROP COLUMN sync_updated_at;
+ALTER TABLE http_traffic_log DROP COLUMN sync_id;
+
+DROP TRIGGER IF EXISTS domains_sync_delete;
+DROP TRIGGER IF EXISTS domains_sync_update;
+DROP TRIGGER IF EXISTS domains_sync_insert;
+DROP INDEX IF EXISTS idx_domains_sync_seq;
+DROP INDEX IF EXISTS idx_domains_sync_id;
+ALTER TABLE domains DROP COLUMN sync_seq;
+ALTER TABLE domains DROP COLUMN sync_updated_at;
+ALTER TABLE domains DROP COLUMN sync_id;
+
+DROP TRIGGER IF EXISTS scope_rules_sync_delete;
+DROP TRIGGER IF EXISTS scope_rules_sync_update;
+DROP TRIGGER IF EXISTS scope_rules_sync_insert;
+DROP INDEX IF EXISTS idx_scope_rules_sync_seq;
+DROP INDEX IF EXISTS idx_scope_rules_sync_id;
+ALTER TABLE scope_rules DROP COLUMN sync_seq;
+ALTER TABLE scope_rules DROP COLUMN sync_updated_at;
+ALTER TABLE scope_rules DROP COLUMN sync_id;
+
+DROP TRIGGER IF EXISTS targets_sync_delete;
+DROP TRIGGER IF EXISTS targets_sync_update;
+DROP TRIGGER IF EXISTS targets_sync_insert;
+DROP INDEX IF EXISTS idx_targets_sync_seq;
+DROP INDEX IF EXISTS idx_targets_sync_id;
+ALTER TABLE targets DROP COLUMN sync_seq;
+ALTER TABLE targets DROP COLUMN sync_updated_at;
+ALTER TABLE targets DROP COLUMN sync_id;
+
+DROP TABLE IF EXISTS sync_peers;
+DROP TABLE IF EXISTS sync_aliases;
+DROP TABLE IF EXISTS sync_tombstones;
+DROP TABLE IF EXISTS sync_sequence;
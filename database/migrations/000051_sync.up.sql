@@ -0,0 +1,221 @@
+-- Sync between toolkit instances ('toolkit sync', POST /sync). Synced rows carry a sync_id shared by
+-- all instances, the time of their last change (sync_updated_at, compared last-writer-wins) and
+-- sync_seq, a local change counter the per-peer cursors point into. Traffic log entries only get a
+-- sync_id once selected for sync (favorites, sync.traffic_tags, finding evidence).
+CREATE TABLE IF NOT EXISTS sync_sequence (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    value INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO sync_sequence (id, value) VALUES (1, 0);
+
+-- Deleted synced rows, so deletions reach peers; pruned after sync.tombstone_days
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    entity TEXT NOT NULL,   -- target, scope_rule, domain, traffic, finding
+    sync_id TEXT NOT NULL,
+    deleted_at TEXT NOT NULL,
+    sync_seq INTEGER NOT NULL,
+    PRIMARY KEY (entity, sync_id)
+);
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_seq ON sync_tombstones(sync_seq);
+
+-- sync_ids of rows created on two instances independently (same slug, domain name, ...) that were
+-- merged into the row with the smaller sync_id
+CREATE TABLE IF NOT EXISTS sync_aliases (
+    entity TEXT NOT NULL,
+    alias TEXT NOT NULL,
+    sync_id TEXT NOT NULL,
+    PRIMARY KEY (entity, alias)
+);
+
+-- Progress of the exchanges with each peer of sync.peers
+CREATE TABLE IF NOT EXISTS sync_peers (
+    name TEXT PRIMARY KEY,
+    pulled_until INTEGER NOT NULL DEFAULT 0, -- Peer's sync_seq up to which its changes were received
+    pushed_until INTEGER NOT NULL DEFAULT 0, -- Local sync_seq up to which changes were sent
+    last_sync_at DATETIME,
+    last_error TEXT,
+    last_result TEXT -- JSON of the last run's counts
+);
+
+
+ALTER TABLE targets ADD COLUMN sync_id TEXT;
+ALTER TABLE targets ADD COLUMN sync_updated_at TEXT;
+ALTER TABLE targets ADD COLUMN sync_seq INTEGER;
+
+ALTER TABLE scope_rules ADD COLUMN sync_id TEXT;
+ALTER TABLE scope_rules ADD COLUMN sync_updated_at TEXT;
+ALTER TABLE scope_rules ADD COLUMN sync_seq INTEGER;
+
+ALTER TABLE domains ADD COLUMN sync_id TEXT;
+ALTER TABLE domains ADD COLUMN sync_updated_at TEXT;
+ALTER TABLE domains ADD COLUMN sync_seq INTEGER;
+
+ALTER TABLE http_traffic_log ADD COLUMN sync_id TEXT;
+ALTER TABLE http_traffic_log ADD COLUMN sync_updated_at TEXT;
+ALTER TABLE http_traffic_log ADD COLUMN sync_seq INTEGER;
+
+ALTER TABLE target_findings ADD COLUMN sync_id TEXT;
+ALTER TABLE target_findings ADD COLUMN sync_updated_at TEXT;
+ALTER TABLE target_findings ADD COLUMN sync_seq INTEGER;
+
+UPDATE targets SET sync_id = lower(hex(randomblob(16))), sync_updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), sync_seq = id + (SELECT value FROM sync_sequence WHERE id = 1);
+UPDATE sync_sequence SET value = COALESCE((SELECT MAX(sync_seq) FROM targets), value) WHERE id = 1;
+
+UPDATE scope_rules SET sync_id = lower(hex(randomblob(16))), sync_updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), sync_seq = id + (SELECT value FROM sync_sequence WHERE id = 1);
+UPDATE sync_sequence SET value = COALESCE((SELECT MAX(sync_seq) FROM scope_rules), value) WHERE id = 1;
+
+UPDATE domains SET sync_id = lower(hex(randomblob(16))), sync_updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), sync_seq = id + (SELECT value FROM sync_sequence WHERE id = 1);
+UPDATE sync_sequence SET value = COALESCE((SELECT MAX(sync_seq) FROM domains), value) WHERE id = 1;
+
+UPDATE target_findings SET sync_id = lower(hex(randomblob(16))), sync_updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), sync_seq = id + (SELECT value FROM sync_sequence WHERE id = 1);
+UPDATE sync_sequence SET value = COALESCE((SELECT MAX(sync_seq) FROM target_findings), value) WHERE id = 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_targets_sync_id ON targets(sync_id);
+CREATE INDEX IF NOT EXISTS idx_targets_sync_seq ON targets(sync_seq);
+
+CREATE TRIGGER targets_sync_insert
+AFTER INSERT ON targets FOR EACH ROW
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE targets SET sync_id = COALESCE(NEW.sync_id, lower(hex(randomblob(16)))), sync_updated_at = COALESCE(NEW.sync_updated_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER targets_sync_update
+AFTER UPDATE OF platform_id, slug, codename, link, notes, payout_ranges, program_rules, scope_fetched_at, scope_reviewed_at ON targets FOR EACH ROW
+WHEN NEW.platform_id IS NOT OLD.platform_id OR NEW.slug IS NOT OLD.slug OR NEW.codename IS NOT OLD.codename OR NEW.link IS NOT OLD.link OR NEW.notes IS NOT OLD.notes OR NEW.payout_ranges IS NOT OLD.payout_ranges OR NEW.program_rules IS NOT OLD.program_rules OR NEW.scope_fetched_at IS NOT OLD.scope_fetched_at OR NEW.scope_reviewed_at IS NOT OLD.scope_reviewed_at
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE targets SET sync_updated_at = CASE WHEN NEW.sync_updated_at IS OLD.sync_updated_at THEN strftime('%Y-%m-%dT%H:%M:%fZ', 'now') ELSE NEW.sync_updated_at END,
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER targets_sync_delete
+AFTER DELETE ON targets FOR EACH ROW
+WHEN OLD.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    INSERT OR REPLACE INTO sync_tombstones (entity, sync_id, deleted_at, sync_seq) VALUES ('target', OLD.sync_id, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), (SELECT value FROM sync_sequence WHERE id = 1));
+END;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scope_rules_sync_id ON scope_rules(sync_id);
+CREATE INDEX IF NOT EXISTS idx_scope_rules_sync_seq ON scope_rules(sync_seq);
+
+CREATE TRIGGER scope_rules_sync_insert
+AFTER INSERT ON scope_rules FOR EACH ROW
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE scope_rules SET sync_id = COALESCE(NEW.sync_id, lower(hex(randomblob(16)))), sync_updated_at = COALESCE(NEW.sync_updated_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER scope_rules_sync_update
+AFTER UPDATE OF target_id, item_type, pattern, is_in_scope, is_wildcard, description ON scope_rules FOR EACH ROW
+WHEN NEW.target_id IS NOT OLD.target_id OR NEW.item_type IS NOT OLD.item_type OR NEW.pattern IS NOT OLD.pattern OR NEW.is_in_scope IS NOT OLD.is_in_scope OR NEW.is_wildcard IS NOT OLD.is_wildcard OR NEW.description IS NOT OLD.description
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE scope_rules SET sync_updated_at = CASE WHEN NEW.sync_updated_at IS OLD.sync_updated_at THEN strftime('%Y-%m-%dT%H:%M:%fZ', 'now') ELSE NEW.sync_updated_at END,
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER scope_rules_sync_delete
+AFTER DELETE ON scope_rules FOR EACH ROW
+WHEN OLD.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    INSERT OR REPLACE INTO sync_tombstones (entity, sync_id, deleted_at, sync_seq) VALUES ('scope_rule', OLD.sync_id, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), (SELECT value FROM sync_sequence WHERE id = 1));
+END;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_domains_sync_id ON domains(sync_id);
+CREATE INDEX IF NOT EXISTS idx_domains_sync_seq ON domains(sync_seq);
+
+CREATE TRIGGER domains_sync_insert
+AFTER INSERT ON domains FOR EACH ROW
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE domains SET sync_id = COALESCE(NEW.sync_id, lower(hex(randomblob(16)))), sync_updated_at = COALESCE(NEW.sync_updated_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER domains_sync_update
+AFTER UPDATE OF target_id, domain_name, source, is_in_scope, is_wildcard_scope, notes, is_favorite, http_status_code, http_content_length, http_title, http_server, http_tech, httpx_full_json, favicon_hash, favicon_url, open_ports, enrichment_json, enriched_at, takeover_status, takeover_service, takeover_cname, takeover_checked_at ON domains FOR EACH ROW
+WHEN NEW.target_id IS NOT OLD.target_id OR NEW.domain_name IS NOT OLD.domain_name OR NEW.source IS NOT OLD.source OR NEW.is_in_scope IS NOT OLD.is_in_scope OR NEW.is_wildcard_scope IS NOT OLD.is_wildcard_scope OR NEW.notes IS NOT OLD.notes OR NEW.is_favorite IS NOT OLD.is_favorite OR NEW.http_status_code IS NOT OLD.http_status_code OR NEW.http_content_length IS NOT OLD.http_content_length OR NEW.http_title IS NOT OLD.http_title OR NEW.http_server IS NOT OLD.http_server OR NEW.http_tech IS NOT OLD.http_tech OR NEW.httpx_full_json IS NOT OLD.httpx_full_json OR NEW.favicon_hash IS NOT OLD.favicon_hash OR NEW.favicon_url IS NOT OLD.favicon_url OR NEW.open_ports IS NOT OLD.open_ports OR NEW.enrichment_json IS NOT OLD.enrichment_json OR NEW.enriched_at IS NOT OLD.enriched_at OR NEW.takeover_status IS NOT OLD.takeover_status OR NEW.takeover_service IS NOT OLD.takeover_service OR NEW.takeover_cname IS NOT OLD.takeover_cname OR NEW.takeover_checked_at IS NOT OLD.takeover_checked_at
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE domains SET sync_updated_at = CASE WHEN NEW.sync_updated_at IS OLD.sync_updated_at THEN strftime('%Y-%m-%dT%H:%M:%fZ', 'now') ELSE NEW.sync_updated_at END,
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER domains_sync_delete
+AFTER DELETE ON domains FOR EACH ROW
+WHEN OLD.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    INSERT OR REPLACE INTO sync_tombstones (entity, sync_id, deleted_at, sync_seq) VALUES ('domain', OLD.sync_id, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), (SELECT value FROM sync_sequence WHERE id = 1));
+END;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_http_traffic_log_sync_id ON http_traffic_log(sync_id);
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_sync_seq ON http_traffic_log(sync_seq);
+
+CREATE TRIGGER http_traffic_log_sync_insert
+AFTER INSERT ON http_traffic_log FOR EACH ROW
+WHEN NEW.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE http_traffic_log SET sync_id = COALESCE(NEW.sync_id, lower(hex(randomblob(16)))), sync_updated_at = COALESCE(NEW.sync_updated_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER http_traffic_log_sync_update
+AFTER UPDATE OF target_id, notes, is_favorite ON http_traffic_log FOR EACH ROW
+WHEN OLD.sync_id IS NOT NULL AND (NEW.target_id IS NOT OLD.target_id OR NEW.notes IS NOT OLD.notes OR NEW.is_favorite IS NOT OLD.is_favorite)
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE http_traffic_log SET sync_updated_at = CASE WHEN NEW.sync_updated_at IS OLD.sync_updated_at THEN strftime('%Y-%m-%dT%H:%M:%fZ', 'now') ELSE NEW.sync_updated_at END,
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER http_traffic_log_sync_delete
+AFTER DELETE ON http_traffic_log FOR EACH ROW
+WHEN OLD.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    INSERT OR REPLACE INTO sync_tombstones (entity, sync_id, deleted_at, sync_seq) VALUES ('traffic', OLD.sync_id, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), (SELECT value FROM sync_sequence WHERE id = 1));
+END;
+
+-- Traffic log entries selected for sync get their sync_id by an update
+CREATE TRIGGER http_traffic_log_sync_select
+AFTER UPDATE OF sync_id ON http_traffic_log FOR EACH ROW
+WHEN OLD.sync_id IS NULL AND NEW.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE http_traffic_log SET sync_updated_at = COALESCE(NEW.sync_updated_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now')), sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_target_findings_sync_id ON target_findings(sync_id);
+CREATE INDEX IF NOT EXISTS idx_target_findings_sync_seq ON target_findings(sync_seq);
+
+CREATE TRIGGER target_findings_sync_insert
+AFTER INSERT ON target_findings FOR EACH ROW
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE target_findings SET sync_id = COALESCE(NEW.sync_id, lower(hex(randomblob(16)))), sync_updated_at = COALESCE(NEW.sync_updated_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER target_findings_sync_update
+AFTER UPDATE OF target_id, http_traffic_log_id, vulnerability_type_id, title, summary, description, steps_to_reproduce, impact, recommendations, payload, severity, status, cvss_score, cwe_id, finding_references ON target_findings FOR EACH ROW
+WHEN NEW.target_id IS NOT OLD.target_id OR NEW.http_traffic_log_id IS NOT OLD.http_traffic_log_id OR NEW.vulnerability_type_id IS NOT OLD.vulnerability_type_id OR NEW.title IS NOT OLD.title OR NEW.summary IS NOT OLD.summary OR NEW.description IS NOT OLD.description OR NEW.steps_to_reproduce IS NOT OLD.steps_to_reproduce OR NEW.impact IS NOT OLD.impact OR NEW.recommendations IS NOT OLD.recommendations OR NEW.payload IS NOT OLD.payload OR NEW.severity IS NOT OLD.severity OR NEW.status IS NOT OLD.status OR NEW.cvss_score IS NOT OLD.cvss_score OR NEW.cwe_id IS NOT OLD.cwe_id OR NEW.finding_references IS NOT OLD.finding_references
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    UPDATE target_findings SET sync_updated_at = CASE WHEN NEW.sync_updated_at IS OLD.sync_updated_at THEN strftime('%Y-%m-%dT%H:%M:%fZ', 'now') ELSE NEW.sync_updated_at END,
+        sync_seq = (SELECT value FROM sync_sequence WHERE id = 1) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER target_findings_sync_delete
+AFTER DELETE ON target_findings FOR EACH ROW
+WHEN OLD.sync_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1 WHERE id = 1;
+    INSERT OR REPLACE INTO sync_tombstones (entity, sync_id, deleted_at, sync_seq) VALUES ('finding', OLD.sync_id, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), (SELECT value FROM sync_sequence WHERE id = 1));
+END;
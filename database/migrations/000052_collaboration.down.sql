@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS entity_locks;
+DROP INDEX IF EXISTS idx_presence_entity;
+DROP TABLE IF EXISTS presence;
+DROP INDEX IF EXISTS idx_activity_log_target_id;
+DROP INDEX IF EXISTS idx_activity_log_user;
+DROP INDEX IF EXISTS idx_activity_log_entity;
+DROP INDEX IF EXISTS idx_activity_log_created_at;
+DROP TABLE IF EXISTS activity_log;
@@ -0,0 +1,38 @@
+-- Mutations made through the API, by user (GET /activity, "activity" events of GET /events)
+CREATE TABLE IF NOT EXISTS activity_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user TEXT NOT NULL,         -- Holder of the API token, X-Toolkit-User or "local"
+    action TEXT NOT NULL,       -- create, update, delete or the action of the route (enable, run, ...)
+    entity_type TEXT,           -- finding, note, domain, ... from the route
+    entity_id TEXT,
+    target_id INTEGER,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    route TEXT,                 -- Route pattern, e.g. /findings/{finding_id}
+    status INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_activity_log_created_at ON activity_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_activity_log_entity ON activity_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_activity_log_user ON activity_log(user);
+CREATE INDEX IF NOT EXISTS idx_activity_log_target_id ON activity_log(target_id);
+
+-- Who is viewing what; rows expire when not refreshed within collaboration.presence_ttl_seconds
+CREATE TABLE IF NOT EXISTS presence (
+    user TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    seen_at DATETIME NOT NULL,
+    PRIMARY KEY (user, entity_type, entity_id)
+);
+CREATE INDEX IF NOT EXISTS idx_presence_entity ON presence(entity_type, entity_id);
+
+-- Soft locks on findings and notes being edited; advisory, they don't block updates
+CREATE TABLE IF NOT EXISTS entity_locks (
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    user TEXT NOT NULL,
+    acquired_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (entity_type, entity_id)
+);
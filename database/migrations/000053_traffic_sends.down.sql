@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_traffic_sends_artifact;
+DROP INDEX IF EXISTS idx_traffic_sends_log_id;
+DROP TABLE IF EXISTS traffic_sends;
@@ -0,0 +1,12 @@
+-- Traffic log entries sent to another module (POST /traffic/{id}/send-to), with what it created
+CREATE TABLE IF NOT EXISTS traffic_sends (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    log_id INTEGER NOT NULL REFERENCES http_traffic_log(id) ON DELETE CASCADE,
+    destination TEXT NOT NULL,    -- modifier, replay, authmatrix, fuzzer, scanner, curl or raw
+    artifact_type TEXT NOT NULL,  -- modifier_task, replay_batch, command or analysis_result
+    artifact_ids TEXT,            -- JSON array of the IDs of the created modifier tasks or replay batches
+    user TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_traffic_sends_log_id ON traffic_sends(log_id);
+CREATE INDEX IF NOT EXISTS idx_traffic_sends_artifact ON traffic_sends(artifact_type);
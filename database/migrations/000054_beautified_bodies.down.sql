@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS beautified_bodies_ref_update;
+DROP TRIGGER IF EXISTS beautified_bodies_ref_delete;
+DROP INDEX IF EXISTS idx_http_traffic_log_response_beautified_id;
+ALTER TABLE http_traffic_log DROP COLUMN response_beautified_id;
+DROP TABLE IF EXISTS beautified_bodies;
@@ -0,0 +1,35 @@
+-- Beautified Bodies Table (readable copies of JavaScript, JSON, HTML and XML response bodies of
+-- http_traffic_log, shared by every entry with the same body). Diffs, the body search and the
+-- analyzers read them instead of one-line minified bundles.
+CREATE TABLE IF NOT EXISTS beautified_bodies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sha256 TEXT NOT NULL,           -- Hash of the original body, decoded from its Content-Encoding
+    language TEXT NOT NULL,         -- json, javascript, html or xml
+    data BLOB,                      -- NULL when the body could not be formatted
+    original_size INTEGER NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    error TEXT,                     -- Why the body could not be formatted
+    ref_count INTEGER NOT NULL DEFAULT 0, -- Log entries referencing the copy, kept by the triggers below
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (sha256, language)
+);
+
+ALTER TABLE http_traffic_log ADD COLUMN response_beautified_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_response_beautified_id ON http_traffic_log(response_beautified_id);
+
+-- Reference counting: copies are deleted when the last log entry referencing them goes away.
+CREATE TRIGGER IF NOT EXISTS beautified_bodies_ref_delete
+AFTER DELETE ON http_traffic_log FOR EACH ROW
+WHEN OLD.response_beautified_id IS NOT NULL
+BEGIN
+    UPDATE beautified_bodies SET ref_count = ref_count - 1 WHERE id = OLD.response_beautified_id;
+    DELETE FROM beautified_bodies WHERE id = OLD.response_beautified_id AND ref_count <= 0;
+END;
+CREATE TRIGGER IF NOT EXISTS beautified_bodies_ref_update
+AFTER UPDATE OF response_beautified_id ON http_traffic_log FOR EACH ROW
+WHEN OLD.response_beautified_id IS NOT NEW.response_beautified_id
+BEGIN
+    UPDATE beautified_bodies SET ref_count = ref_count + 1 WHERE id = NEW.response_beautified_id;
+    UPDATE beautified_bodies SET ref_count = ref_count - 1 WHERE id = OLD.response_beautified_id;
+    DELETE FROM beautified_bodies WHERE id = OLD.response_beautified_id AND ref_count <= 0;
+END;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS target_checklist_items_completed_update;
+DROP TRIGGER IF EXISTS target_checklist_items_completed_insert;
+DROP INDEX IF EXISTS idx_target_checklist_items_template_id;
+ALTER TABLE target_checklist_items DROP COLUMN completed_at;
+ALTER TABLE target_checklist_items DROP COLUMN template_id;
@@ -0,0 +1,32 @@
+-- The template a target checklist item was copied from, and when it was completed, for
+-- checklist analytics.
+ALTER TABLE target_checklist_items ADD COLUMN template_id INTEGER;
+ALTER TABLE target_checklist_items ADD COLUMN completed_at DATETIME;
+
+-- Items copied before templates were recorded are matched to a template by their text, and
+-- completed items count as completed when they were last updated. The updated_at trigger is
+-- left out while they are filled in.
+DROP TRIGGER IF EXISTS update_target_checklist_item_updated_at;
+UPDATE target_checklist_items SET completed_at = updated_at WHERE is_completed;
+UPDATE target_checklist_items SET template_id = (
+    SELECT MIN(cti.template_id) FROM checklist_template_items cti WHERE cti.item_text = target_checklist_items.item_text
+);
+CREATE TRIGGER IF NOT EXISTS update_target_checklist_item_updated_at
+AFTER UPDATE ON target_checklist_items FOR EACH ROW
+BEGIN UPDATE target_checklist_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id; END;
+
+CREATE INDEX IF NOT EXISTS idx_target_checklist_items_template_id ON target_checklist_items(template_id);
+
+CREATE TRIGGER IF NOT EXISTS target_checklist_items_completed_insert
+AFTER INSERT ON target_checklist_items FOR EACH ROW
+WHEN NEW.is_completed AND NEW.completed_at IS NULL
+BEGIN
+    UPDATE target_checklist_items SET completed_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS target_checklist_items_completed_update
+AFTER UPDATE OF is_completed ON target_checklist_items FOR EACH ROW
+WHEN NEW.is_completed IS NOT OLD.is_completed
+BEGIN
+    UPDATE target_checklist_items SET completed_at = CASE WHEN NEW.is_completed THEN CURRENT_TIMESTAMP END WHERE id = NEW.id;
+END;
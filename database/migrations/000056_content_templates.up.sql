@@ -0,0 +1,91 @@
+-- Reusable finding and note templates with {{variable}} placeholders (POST /content-templates/{id}/instantiate)
+CREATE TABLE IF NOT EXISTS content_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL CHECK (kind IN ('finding', 'note')),
+    name TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',         -- Note content, or finding description
+    summary TEXT NOT NULL DEFAULT '',         -- Finding templates only, as are the columns below
+    steps_to_reproduce TEXT NOT NULL DEFAULT '',
+    impact TEXT NOT NULL DEFAULT '',
+    recommendations TEXT NOT NULL DEFAULT '',
+    payload TEXT NOT NULL DEFAULT '',
+    severity TEXT NOT NULL DEFAULT '',
+    cwe_id INTEGER,
+    vulnerability_type TEXT NOT NULL DEFAULT '', -- Name, resolved when instantiated
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, name)
+);
+
+INSERT INTO content_templates (kind, name, title, summary, content, steps_to_reproduce, impact, recommendations, payload, severity, cwe_id, vulnerability_type)
+VALUES ('finding', 'Reflected XSS',
+'Reflected XSS in the {{parameter}} parameter of {{endpoint}}',
+'The `{{parameter}}` parameter of `{{url}}` is reflected into the response without output encoding, so a link to it runs attacker-controlled JavaScript in the browser of the {{target_name}} user who opens it.',
+'## Description
+
+The value of the `{{parameter}}` parameter sent to `{{method}} {{url}}` is written into the HTML of the response as it was sent. A payload closing the surrounding context is executed by the browser in the origin of `{{host}}`.
+
+## Evidence
+
+{{evidence}}',
+'1. Log in to {{target_url}} as any user.
+2. Open the following URL in the same browser, with `{{parameter}}` set to the payload `{{payload}}`:
+
+```
+{{url}}
+```
+
+3. Observe that the JavaScript in the payload runs in the origin of `{{host}}`.',
+'An attacker sending the link to a logged-in user runs JavaScript as that user: reading the data the page shows, performing actions on their behalf and, where session tokens are readable from JavaScript, taking over their account.',
+'Encode the value of `{{parameter}}` for the context it is written into (HTML body, attribute or script) and add a Content-Security-Policy that disallows inline scripts.',
+'"><svg onload=alert(document.domain)>',
+'Medium', 79, 'Cross-Site Scripting (XSS) - Reflected');
+
+INSERT INTO content_templates (kind, name, title, summary, content, steps_to_reproduce, impact, recommendations, payload, severity, cwe_id, vulnerability_type)
+VALUES ('finding', 'IDOR',
+'IDOR in {{endpoint}} through the {{parameter}} parameter',
+'`{{method}} {{url}}` returns the objects of other {{target_name}} users when the `{{parameter}}` parameter is changed to their identifier, as the server does not check that the object belongs to the requesting user.',
+'## Description
+
+`{{method}} {{url}}` looks up the object named by the `{{parameter}}` parameter (`{{parameter_value}}` in the request below) without checking that it belongs to the authenticated user. Replacing it with the identifier of an object of another account returns that object.
+
+## Evidence
+
+{{evidence}}',
+'1. Create two accounts on {{target_url}}, user A and user B, and note the `{{parameter}}` of an object owned by user B.
+2. As user A, send the request below with `{{parameter}}` set to user B''s identifier:
+
+```sh
+{{curl}}
+```
+
+3. Observe that the response contains user B''s object.',
+'Any authenticated user can read the objects of every other user by enumerating `{{parameter}}`.',
+'Check on every request that the object named by `{{parameter}}` belongs to the authenticated user, and prefer unguessable identifiers.',
+'',
+'High', 639, 'Insecure Direct Object References (IDOR)');
+
+INSERT INTO content_templates (kind, name, title, content)
+VALUES ('note', 'Endpoint test notes',
+'{{endpoint}} on {{host}}',
+'# {{endpoint}}
+
+- **Target:** {{target_name}}
+- **URL:** `{{url}}`
+- **Tested:** {{date}}
+
+## Parameters
+
+- `{{parameter}}`:
+
+## Tests
+
+- [ ] Authentication required
+- [ ] Authorization (other user''s objects)
+- [ ] Input reflection / XSS
+- [ ] Injection
+- [ ] Rate limiting
+
+## Observations
+');
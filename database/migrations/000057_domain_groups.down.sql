@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_domain_groups_business_unit;
+DROP TABLE IF EXISTS domain_groups;
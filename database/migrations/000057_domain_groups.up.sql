@@ -0,0 +1,12 @@
+-- Business unit labels of the root domains (eTLD+1) a target's domains are grouped by
+CREATE TABLE IF NOT EXISTS domain_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+    root_domain TEXT NOT NULL,                -- Lower-case registrable domain, e.g. example.co.uk
+    business_unit TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_id, root_domain)
+);
+CREATE INDEX IF NOT EXISTS idx_domain_groups_business_unit ON domain_groups(target_id, business_unit);
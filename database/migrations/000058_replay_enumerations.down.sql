@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS replay_enumeration_batches;
+DROP INDEX IF EXISTS idx_replay_enumerations_target_id;
+DROP TABLE IF EXISTS replay_enumerations;
@@ -0,0 +1,20 @@
+-- Requests generated from a URL template with enumerated parameter values, sent as a replay batch
+-- per replay session so the answers each account gets can be compared
+CREATE TABLE IF NOT EXISTS replay_enumerations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    url_template TEXT NOT NULL,                 -- As given, e.g. /api/users/{id}/orders/{orderId}
+    source_log_id INTEGER REFERENCES http_traffic_log(id) ON DELETE SET NULL,
+    parameters TEXT NOT NULL DEFAULT '[]',      -- JSON array of the template's parameter names
+    requests TEXT NOT NULL DEFAULT '[]',        -- JSON array of {url, values}, in the order of the batch items
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_replay_enumerations_target_id ON replay_enumerations(target_id);
+
+CREATE TABLE IF NOT EXISTS replay_enumeration_batches (
+    enumeration_id INTEGER NOT NULL REFERENCES replay_enumerations(id) ON DELETE CASCADE,
+    batch_id INTEGER NOT NULL REFERENCES replay_batches(id) ON DELETE CASCADE,
+    PRIMARY KEY (enumeration_id, batch_id)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_http_traffic_log_target_timestamp;
+DROP INDEX IF EXISTS idx_capture_sessions_running;
+DROP INDEX IF EXISTS idx_capture_sessions_target_id;
+DROP TABLE IF EXISTS capture_sessions;
@@ -0,0 +1,16 @@
+-- Named time windows of a target's testing ("testing checkout flow"); the traffic logged for the
+-- target between started_at and ended_at (or now, while running) belongs to the session
+CREATE TABLE IF NOT EXISTS capture_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL REFERENCES targets(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME,                          -- NULL while the session is running
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_capture_sessions_target_id ON capture_sessions(target_id, started_at);
+-- At most one running session per target
+CREATE UNIQUE INDEX IF NOT EXISTS idx_capture_sessions_running ON capture_sessions(target_id) WHERE ended_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_http_traffic_log_target_timestamp ON http_traffic_log(target_id, timestamp);
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const modifierCollectionColumns = `c.id, c.target_id, c.name, c.description,
+	(SELECT COUNT(*) FROM modifier_tasks t WHERE t.collection_id = c.id), c.created_at, c.updated_at`
+
+func scanModifierCollection(scanner interface{ Scan(...interface{}) error }) (models.ModifierCollection, error) {
+	var c models.ModifierCollection
+	err := scanner.Scan(&c.ID, &c.TargetID, &c.Name, &c.Description, &c.TaskCount, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// CreateModifierCollection stores a new modifier collection and returns its ID.
+func CreateModifierCollection(c models.ModifierCollection) (int64, error) {
+	res, err := DB.Exec(`INSERT INTO modifier_collections (target_id, name, description) VALUES (?, ?, ?)`,
+		c.TargetID, c.Name, c.Description)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("a collection named '%s' already exists", c.Name)
+		}
+		return 0, fmt.Errorf("inserting modifier collection: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("getting last insert ID: %w", err)
+	}
+	logger.Info("Created modifier collection ID %d: %s", id, c.Name)
+	return id, nil
+}
+
+// UpdateModifierCollection replaces the name and description of a modifier collection.
+func UpdateModifierCollection(c models.ModifierCollection) error {
+	res, err := DB.Exec(`UPDATE modifier_collections SET name = ?, description = ? WHERE id = ?`, c.Name, c.Description, c.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("a collection named '%s' already exists", c.Name)
+		}
+		return fmt.Errorf("updating modifier collection %d: %w", c.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("modifier collection with ID %d not found", c.ID)
+	}
+	return nil
+}
+
+// GetModifierCollectionByID fetches a modifier collection.
+func GetModifierCollectionByID(id int64) (models.ModifierCollection, error) {
+	c, err := scanModifierCollection(DB.QueryRow(`SELECT `+modifierCollectionColumns+` FROM modifier_collections c WHERE c.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c, fmt.Errorf("modifier collection with ID %d not found", id)
+		}
+		return c, fmt.Errorf("scanning modifier collection %d: %w", id, err)
+	}
+	return c, nil
+}
+
+// GetModifierCollections lists modifier collections, optionally only those of a target.
+func GetModifierCollections(targetID int64) ([]models.ModifierCollection, error) {
+	query := `SELECT ` + modifierCollectionColumns + ` FROM modifier_collections c`
+	var args []interface{}
+	if targetID != 0 {
+		query += " WHERE c.target_id = ?"
+		args = append(args, targetID)
+	}
+	query += " ORDER BY LOWER(c.name)"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying modifier collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []models.ModifierCollection{}
+	for rows.Next() {
+		c, err := scanModifierCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning modifier collection: %w", err)
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// DeleteModifierCollection removes a modifier collection. Its tasks are kept and no longer filed
+// under any collection.
+func DeleteModifierCollection(id int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction for collection delete: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE modifier_tasks SET collection_id = NULL WHERE collection_id = ?`, id); err != nil {
+		return fmt.Errorf("detaching tasks of collection %d: %w", id, err)
+	}
+	res, err := tx.Exec(`DELETE FROM modifier_collections WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting modifier collection %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("modifier collection with ID %d not found", id)
+	}
+	return tx.Commit()
+}
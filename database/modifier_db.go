@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"toolkit/logger"
 	"toolkit/models"
 )
@@ -47,7 +48,7 @@ func CreateModifierTaskFromSource(req models.AddModifierTaskRequest) (*models.Mo
 		var log models.HTTPTrafficLog
 		// Fetch necessary details from http_traffic_log
 		// Fetch request and response data
-		err := tx.QueryRow(`SELECT target_id, request_method, request_url, request_headers, request_body, response_headers, response_body
+		err := tx.QueryRow(`SELECT target_id, request_method, request_url, request_headers, `+logRequestBody+`, response_headers, `+logResponseBody+`
 							FROM http_traffic_log WHERE id = ?`, req.HTTPTrafficLogID).Scan(
 			&log.TargetID, &log.RequestMethod, &log.RequestURL, &log.RequestHeaders, &log.RequestBody, &log.ResponseHeaders, &log.ResponseBody,
 		)
@@ -91,7 +92,7 @@ func CreateModifierTaskFromSource(req models.AddModifierTaskRequest) (*models.Mo
 			var logBodyBytes []byte                      // Correct type for BLOB
 			var logResBodyBytes []byte                   // Correct type for BLOB
 			var logHeaders, logResHeaders sql.NullString // Only need NullString for headers
-			errLog := tx.QueryRow(`SELECT request_headers, request_body, response_headers, response_body FROM http_traffic_log WHERE id = ?`, pURL.HTTPTrafficLogID.Int64).Scan(&logHeaders, &logBodyBytes, &logResHeaders, &logResBodyBytes)
+			errLog := tx.QueryRow(`SELECT request_headers, `+logRequestBody+`, response_headers, `+logResponseBody+` FROM http_traffic_log WHERE id = ?`, pURL.HTTPTrafficLogID.Int64).Scan(&logHeaders, &logBodyBytes, &logResHeaders, &logResBodyBytes)
 			if errLog == nil {
 				originalReqHeaders = logHeaders                                        // Already JSON string, no change needed
 				originalReqBody = models.NullString(models.Base64Encode(logBodyBytes)) // Base64 encode []byte
@@ -150,16 +151,56 @@ func CreateModifierTaskFromSource(req models.AddModifierTaskRequest) (*models.Mo
 	return &task, nil
 }
 
-// GetModifierTasks retrieves all modifier tasks, optionally filtered by target_id.
-func GetModifierTasks(targetID int64) ([]models.ModifierTask, error) {
+// CreateModifierTask inserts a modifier task built outside of a log/PURL source
+// (e.g. imported from a curl command or raw request, or generated from the parameter inventory)
+// and appends it to the display order.
+func CreateModifierTask(task models.ModifierTask) (*models.ModifierTask, error) {
+	var maxOrder sql.NullInt64
+	queryOrder := "SELECT MAX(display_order) FROM modifier_tasks"
+	argsOrder := []interface{}{}
+	if task.TargetID.Valid {
+		queryOrder += " WHERE target_id = ?"
+		argsOrder = append(argsOrder, task.TargetID.Int64)
+	}
+	if err := DB.QueryRow(queryOrder, argsOrder...).Scan(&maxOrder); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("getting max display_order: %w", err)
+	}
+	task.DisplayOrder = int(maxOrder.Int64) + 1
+
+	res, err := DB.Exec(`INSERT INTO modifier_tasks 
+		(target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, original_request_headers, original_request_body, source_log_id, source_param_url_id, collection_id, display_order, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		task.TargetID, task.Name, task.BaseRequestMethod, task.BaseRequestURL, task.BaseRequestHeaders, task.BaseRequestBody,
+		task.BaseRequestHeaders, task.BaseRequestBody, task.SourceLogID, task.SourceParameterizedURLID, task.CollectionID, task.DisplayOrder)
+	if err != nil {
+		return nil, fmt.Errorf("inserting modifier task: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting last insert ID: %w", err)
+	}
+	logger.Info("Created modifier task ID %d: %s", id, task.Name)
+	return GetModifierTaskByID(id)
+}
+
+// GetModifierTasks retrieves all modifier tasks, optionally filtered by target_id and collection_id.
+func GetModifierTasks(targetID, collectionID int64) ([]models.ModifierTask, error) {
 	var tasks []models.ModifierTask
-	query := `SELECT id, target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, original_request_headers, original_request_body, original_response_headers, original_response_body, last_executed_log_id, source_log_id, source_param_url_id, display_order, created_at, updated_at 
+	query := `SELECT id, target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, base_request_raw, original_request_headers, original_request_body, original_response_headers, original_response_body, last_executed_log_id, source_log_id, source_param_url_id, collection_id, display_order, created_at, updated_at 
 			  FROM modifier_tasks`
 	args := []interface{}{}
+	var conditions []string
 	if targetID != 0 {
-		query += " WHERE target_id = ?"
+		conditions = append(conditions, "target_id = ?")
 		args = append(args, targetID)
 	}
+	if collectionID != 0 {
+		conditions = append(conditions, "collection_id = ?")
+		args = append(args, collectionID)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
 	query += " ORDER BY display_order ASC, created_at DESC"
 
 	rows, err := DB.Query(query, args...)
@@ -169,7 +210,7 @@ func GetModifierTasks(targetID int64) ([]models.ModifierTask, error) {
 	defer rows.Close()
 	for rows.Next() {
 		var t models.ModifierTask // Ensure all new fields are scanned
-		if err := rows.Scan(&t.ID, &t.TargetID, &t.Name, &t.BaseRequestMethod, &t.BaseRequestURL, &t.BaseRequestHeaders, &t.BaseRequestBody, &t.OriginalRequestHeaders, &t.OriginalRequestBody, &t.OriginalResponseHeaders, &t.OriginalResponseBody, &t.LastExecutedLogID, &t.SourceLogID, &t.SourceParameterizedURLID, &t.DisplayOrder, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		if err := rows.Scan(&t.ID, &t.TargetID, &t.Name, &t.BaseRequestMethod, &t.BaseRequestURL, &t.BaseRequestHeaders, &t.BaseRequestBody, &t.BaseRequestRaw, &t.OriginalRequestHeaders, &t.OriginalRequestBody, &t.OriginalResponseHeaders, &t.OriginalResponseBody, &t.LastExecutedLogID, &t.SourceLogID, &t.SourceParameterizedURLID, &t.CollectionID, &t.DisplayOrder, &t.CreatedAt, &t.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning modifier task: %w", err)
 		}
 		tasks = append(tasks, t)
@@ -180,9 +221,9 @@ func GetModifierTasks(targetID int64) ([]models.ModifierTask, error) {
 // GetModifierTaskByID retrieves a single modifier task by its ID.
 func GetModifierTaskByID(taskID int64) (*models.ModifierTask, error) {
 	var t models.ModifierTask
-	err := DB.QueryRow(`SELECT id, target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, original_request_headers, original_request_body, original_response_headers, original_response_body, last_executed_log_id, source_log_id, source_param_url_id, display_order, created_at, updated_at 
+	err := DB.QueryRow(`SELECT id, target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, base_request_raw, original_request_headers, original_request_body, original_response_headers, original_response_body, last_executed_log_id, source_log_id, source_param_url_id, collection_id, display_order, created_at, updated_at 
 					   FROM modifier_tasks WHERE id = ?`, taskID).Scan( // Ensure all new fields are selected
-		&t.ID, &t.TargetID, &t.Name, &t.BaseRequestMethod, &t.BaseRequestURL, &t.BaseRequestHeaders, &t.BaseRequestBody, &t.OriginalRequestHeaders, &t.OriginalRequestBody, &t.OriginalResponseHeaders, &t.OriginalResponseBody, &t.LastExecutedLogID, &t.SourceLogID, &t.SourceParameterizedURLID, &t.DisplayOrder, &t.CreatedAt, &t.UpdatedAt, // Ensure all new fields are scanned
+		&t.ID, &t.TargetID, &t.Name, &t.BaseRequestMethod, &t.BaseRequestURL, &t.BaseRequestHeaders, &t.BaseRequestBody, &t.BaseRequestRaw, &t.OriginalRequestHeaders, &t.OriginalRequestBody, &t.OriginalResponseHeaders, &t.OriginalResponseBody, &t.LastExecutedLogID, &t.SourceLogID, &t.SourceParameterizedURLID, &t.CollectionID, &t.DisplayOrder, &t.CreatedAt, &t.UpdatedAt, // Ensure all new fields are scanned
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -213,6 +254,15 @@ func UpdateModifierTaskBaseRequestDetails(taskID int64, method, url, headersJSON
 	return nil
 }
 
+// UpdateModifierTaskRawRequest stores the raw request of a task's raw editing mode, base64 encoded.
+func UpdateModifierTaskRawRequest(taskID int64, rawBase64 string) error {
+	_, err := DB.Exec(`UPDATE modifier_tasks SET base_request_raw = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, models.NullString(rawBase64), taskID)
+	if err != nil {
+		return fmt.Errorf("updating raw request for task %d: %w", taskID, err)
+	}
+	return nil
+}
+
 // UpdateModifierTaskLastExecutedLogID updates the last_executed_log_id for a task.
 func UpdateModifierTaskLastExecutedLogID(taskID int64, logID int64) error {
 	_, err := DB.Exec("UPDATE modifier_tasks SET last_executed_log_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", logID, taskID)
@@ -261,14 +311,14 @@ func CloneModifierTaskDB(originalTaskID int64) (*models.ModifierTask, error) {
 	clonedTask.DisplayOrder = int(maxOrder.Int64) + 1
 
 	stmt, err := DB.Prepare(`INSERT INTO modifier_tasks 
-		(target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, original_request_headers, original_request_body, original_response_headers, original_response_body, source_log_id, source_param_url_id, display_order, created_at, updated_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`) // Add new columns to INSERT
+		(target_id, name, base_request_method, base_request_url, base_request_headers, base_request_body, base_request_raw, original_request_headers, original_request_body, original_response_headers, original_response_body, source_log_id, source_param_url_id, collection_id, display_order, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`) // Add new columns to INSERT
 	if err != nil {
 		return nil, fmt.Errorf("preparing insert for clone: %w", err)
 	}
 	defer stmt.Close()
 
-	res, err := stmt.Exec(clonedTask.TargetID, clonedTask.Name, clonedTask.BaseRequestMethod, clonedTask.BaseRequestURL, clonedTask.BaseRequestHeaders, clonedTask.BaseRequestBody, clonedTask.OriginalRequestHeaders, clonedTask.OriginalRequestBody, clonedTask.OriginalResponseHeaders, clonedTask.OriginalResponseBody, clonedTask.SourceLogID, clonedTask.SourceParameterizedURLID, clonedTask.DisplayOrder) // Pass original fields
+	res, err := stmt.Exec(clonedTask.TargetID, clonedTask.Name, clonedTask.BaseRequestMethod, clonedTask.BaseRequestURL, clonedTask.BaseRequestHeaders, clonedTask.BaseRequestBody, clonedTask.BaseRequestRaw, clonedTask.OriginalRequestHeaders, clonedTask.OriginalRequestBody, clonedTask.OriginalResponseHeaders, clonedTask.OriginalResponseBody, clonedTask.SourceLogID, clonedTask.SourceParameterizedURLID, clonedTask.CollectionID, clonedTask.DisplayOrder) // Pass original fields
 	if err != nil {
 		return nil, fmt.Errorf("executing insert for clone: %w", err)
 	}
@@ -302,3 +352,16 @@ func UpdateModifierTasksOrder(orders map[int64]int) error {
 	}
 	return tx.Commit()
 }
+
+// SetModifierTaskCollection files a task under a collection; collectionID 0 removes it from its collection.
+func SetModifierTaskCollection(taskID, collectionID int64) error {
+	collection := sql.NullInt64{Int64: collectionID, Valid: collectionID != 0}
+	res, err := DB.Exec("UPDATE modifier_tasks SET collection_id = ? WHERE id = ?", collection, taskID)
+	if err != nil {
+		return fmt.Errorf("updating collection of task %d: %w", taskID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("modifier task with ID %d not found", taskID)
+	}
+	return nil
+}
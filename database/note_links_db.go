@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"toolkit/models"
+)
+
+var noteSearchTokenRegex = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// ReplaceNoteLinks stores the [[wiki-style]] links of a note, replacing the previous set. Links to notes by
+// title are resolved to a note ID when a note with that title exists (preferring one on the same target).
+func ReplaceNoteLinks(note models.Note, links []models.NoteLink) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM note_links WHERE note_id = ?`, note.ID); err != nil {
+		return fmt.Errorf("clearing links of note %d: %w", note.ID, err)
+	}
+	for _, link := range links {
+		if link.LinkType == models.NoteLinkNote && !link.LinkedID.Valid {
+			var id int64
+			err := tx.QueryRow(`SELECT id FROM notes WHERE title = ? COLLATE NOCASE AND id != ?
+				ORDER BY (IFNULL(target_id, 0) = ?) DESC, id ASC LIMIT 1`, link.LinkText, note.ID, note.TargetID.Int64).Scan(&id)
+			if err == nil {
+				link.LinkedID.Int64, link.LinkedID.Valid = id, true
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO note_links (note_id, link_type, linked_id, link_text) VALUES (?, ?, ?, ?)`,
+			note.ID, link.LinkType, link.LinkedID, link.LinkText); err != nil {
+			return fmt.Errorf("inserting link of note %d: %w", note.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNoteLinks returns the outgoing links of a note.
+func GetNoteLinks(noteID int64) ([]models.NoteLink, error) {
+	rows, err := DB.Query(`SELECT note_id, link_type, linked_id, link_text FROM note_links WHERE note_id = ? ORDER BY id`, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("querying links of note %d: %w", noteID, err)
+	}
+	defer rows.Close()
+
+	links := []models.NoteLink{}
+	for rows.Next() {
+		var l models.NoteLink
+		if err := rows.Scan(&l.NoteID, &l.LinkType, &l.LinkedID, &l.LinkText); err != nil {
+			return nil, fmt.Errorf("scanning note link: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// GetNoteBacklinks returns the notes that link to an entity, most recently updated first. For notes, links
+// by title that were written before the note existed (or before it was renamed) are included.
+func GetNoteBacklinks(linkType string, linkedID int64) ([]models.Note, error) {
+	condition := `l.link_type = ? AND l.linked_id = ?`
+	args := []interface{}{linkType, linkedID}
+	if linkType == models.NoteLinkNote {
+		condition = `l.link_type = ? AND (l.linked_id = ? OR (l.linked_id IS NULL AND l.link_text = (SELECT title FROM notes WHERE id = ?) COLLATE NOCASE))`
+		args = append(args, linkedID)
+	}
+	rows, err := DB.Query(`SELECT DISTINCT n.id, n.target_id, n.title, n.content, n.created_at, n.updated_at
+		FROM notes n JOIN note_links l ON l.note_id = n.id
+		WHERE `+condition+` ORDER BY n.updated_at DESC, n.id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying backlinks of %s %d: %w", linkType, linkedID, err)
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(&n.ID, &n.TargetID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning backlinked note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// SearchNotes runs a full-text search over note titles and content. Every word of the query must match
+// (as a prefix). Snippets are HTML-escaped with matches wrapped in <mark>.
+func SearchNotes(query string, targetID int64, limit, offset int) ([]models.NoteSearchResult, int64, error) {
+	tokens := noteSearchTokenRegex.FindAllString(query, -1)
+	if len(tokens) == 0 {
+		return []models.NoteSearchResult{}, 0, nil
+	}
+	for i, t := range tokens {
+		tokens[i] = t + "*"
+	}
+	match := strings.Join(tokens, " ")
+
+	where := `notes_fts MATCH ?`
+	args := []interface{}{match}
+	if targetID != 0 {
+		where += ` AND n.target_id = ?`
+		args = append(args, targetID)
+	}
+	from := ` FROM notes_fts JOIN notes n ON n.id = notes_fts.docid WHERE ` + where
+
+	var total int64
+	if err := DB.QueryRow(`SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting note search results: %w", err)
+	}
+	rows, err := DB.Query(`SELECT n.id, n.target_id, n.title, n.content, n.created_at, n.updated_at,
+		snippet(notes_fts, char(2), char(3), '...', -1, 20)`+from+` ORDER BY n.updated_at DESC, n.id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("searching notes: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.NoteSearchResult{}
+	for rows.Next() {
+		var r models.NoteSearchResult
+		var snippet string
+		if err := rows.Scan(&r.ID, &r.TargetID, &r.Title, &r.Content, &r.CreatedAt, &r.UpdatedAt, &snippet); err != nil {
+			return nil, 0, fmt.Errorf("scanning note search result: %w", err)
+		}
+		r.Snippet = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(html.EscapeString(snippet))
+		results = append(results, r)
+	}
+	return results, total, rows.Err()
+}
@@ -123,14 +123,52 @@ func GetPagesForTarget(targetID int64) ([]models.Page, error) {
 	return pages, rows.Err()
 }
 
+// GetPageByID retrieves a single page.
+func GetPageByID(pageID int64) (models.Page, error) {
+	var p models.Page
+	err := DB.QueryRow(`SELECT id, target_id, name, description, start_timestamp, end_timestamp, created_at, updated_at, display_order
+                        FROM pages WHERE id = ?`, pageID).Scan(&p.ID, &p.TargetID, &p.Name, &p.Description, &p.StartTimestamp, &p.EndTimestamp, &p.CreatedAt, &p.UpdatedAt, &p.DisplayOrder)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return p, fmt.Errorf("page with ID %d not found", pageID)
+		}
+		logger.Error("GetPageByID: Error scanning page %d: %v", pageID, err)
+		return p, fmt.Errorf("scanning page %d: %w", pageID, err)
+	}
+	return p, nil
+}
+
+// GetLogIDsForPage returns the IDs of the logs associated with a page, in the order they were recorded.
+func GetLogIDsForPage(pageID int64) ([]int64, error) {
+	rows, err := DB.Query(`SELECT htl.id FROM http_traffic_log htl
+		JOIN page_http_logs phl ON htl.id = phl.http_traffic_log_id
+		WHERE phl.page_id = ?
+		ORDER BY htl.timestamp ASC, htl.id ASC`, pageID)
+	if err != nil {
+		logger.Error("GetLogIDsForPage: Error querying logs for page %d: %v", pageID, err)
+		return nil, fmt.Errorf("querying logs for page %d: %w", pageID, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning log ID for page %d: %w", pageID, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // GetLogsForPage retrieves all HTTPTrafficLog entries associated with a specific page_id.
 func GetLogsForPage(pageID int64) ([]models.HTTPTrafficLog, error) {
 	query := `
 		SELECT 
 			htl.id, htl.target_id, htl.timestamp, htl.request_method, htl.request_url, 
-			htl.request_http_version, htl.request_headers, htl.request_body, 
+			htl.request_http_version, htl.request_headers, ` + htlRequestBody + `, 
 			htl.response_status_code, htl.response_reason_phrase, htl.response_http_version, 
-			htl.response_headers, htl.response_body, htl.response_content_type, 
+			htl.response_headers, ` + htlResponseBody + `, htl.response_content_type, 
 			htl.response_body_size, htl.duration_ms, htl.client_ip, htl.server_ip, 
 			htl.is_https, htl.is_page_candidate, htl.notes, htl.is_favorite
 		FROM http_traffic_log htl
@@ -227,9 +265,9 @@ func GetLogsForPagePaginatedAndSorted(pageID int64, page int, limit int, sortBy
 	query := fmt.Sprintf(`
 		SELECT
 			htl.id, htl.target_id, htl.timestamp, htl.request_method, htl.request_url,
-			htl.request_full_url_with_fragment, htl.request_http_version, htl.request_headers, htl.request_body,
+			htl.request_full_url_with_fragment, htl.request_http_version, htl.request_headers, `+htlRequestBody+`,
 			htl.response_status_code, htl.response_reason_phrase, htl.response_http_version,
-			htl.response_headers, htl.response_body, htl.response_content_type,
+			htl.response_headers, `+htlResponseBody+`, htl.response_content_type,
 			htl.response_body_size, htl.duration_ms, htl.client_ip, htl.server_ip,
 			htl.is_https, htl.is_page_candidate, htl.notes, htl.is_favorite,
 			htl.log_source, htl.page_sitemap_id 
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -83,6 +84,41 @@ func CreateOrUpdateParameterizedURL(pUrl models.ParameterizedURL) (id int64, cre
 	return id, created, nil
 }
 
+// UpsertMinedParameter adds a parameter found by parameter mining to the inventory as its own entry.
+// A parameter already in the inventory for the method and path keeps its entry; one mined before
+// takes the new evidence when its confidence is at least as high. It returns the entry ID and
+// whether the entry is new.
+func UpsertMinedParameter(pUrl models.ParameterizedURL) (int64, bool, error) {
+	var id int64
+	var source string
+	var confidence sql.NullString
+	err := DB.QueryRow(`SELECT id, source, confidence FROM parameterized_urls
+		WHERE target_id = ? AND request_method = ? AND request_path = ? AND param_keys = ?`,
+		pUrl.TargetID, pUrl.RequestMethod, pUrl.RequestPath, pUrl.ParamKeys).Scan(&id, &source, &confidence)
+	if err == nil {
+		if source != models.ParameterSourceMining || slices.Index(models.Confidences, pUrl.Confidence.String) < slices.Index(models.Confidences, confidence.String) {
+			return id, false, nil
+		}
+		if _, err := DB.Exec(`UPDATE parameterized_urls SET confidence = ?, http_traffic_log_id = ?, example_full_url = ?, notes = ? WHERE id = ?`,
+			pUrl.Confidence, pUrl.HTTPTrafficLogID, pUrl.ExampleFullURL, pUrl.Notes, id); err != nil {
+			return id, false, fmt.Errorf("updating mined parameter %d: %w", id, err)
+		}
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("looking up parameter '%s' of %s %s: %w", pUrl.ParamKeys, pUrl.RequestMethod.String, pUrl.RequestPath.String, err)
+	}
+	res, err := DB.Exec(`INSERT INTO parameterized_urls (target_id, http_traffic_log_id, request_method, request_path, param_keys, example_full_url, notes, source, confidence)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		pUrl.TargetID, pUrl.HTTPTrafficLogID, pUrl.RequestMethod, pUrl.RequestPath, pUrl.ParamKeys, pUrl.ExampleFullURL, pUrl.Notes,
+		models.ParameterSourceMining, pUrl.Confidence)
+	if err != nil {
+		return 0, false, fmt.Errorf("inserting parameter '%s' of %s %s: %w", pUrl.ParamKeys, pUrl.RequestMethod.String, pUrl.RequestPath.String, err)
+	}
+	id, err = res.LastInsertId()
+	return id, true, err
+}
+
 // GetParameterizedURLs retrieves a paginated, sorted, and filtered list of parameterized URLs.
 func GetParameterizedURLs(params models.ParameterizedURLFilters) ([]models.ParameterizedURL, int, error) {
 	var urls []models.ParameterizedURL
@@ -103,6 +139,10 @@ func GetParameterizedURLs(params models.ParameterizedURLFilters) ([]models.Param
 		baseQuery += " AND param_keys LIKE ?"
 		args = append(args, "%"+params.ParamKeysSearch+"%")
 	}
+	if params.Source != "" {
+		baseQuery += " AND source = ?"
+		args = append(args, params.Source)
+	}
 
 	countQuery := "SELECT COUNT(*) " + baseQuery
 	err := DB.QueryRow(countQuery, args...).Scan(&totalRecords)
@@ -124,7 +164,7 @@ func GetParameterizedURLs(params models.ParameterizedURLFilters) ([]models.Param
 		}
 	}
 
-	query := fmt.Sprintf("SELECT id, target_id, http_traffic_log_id, request_method, request_path, param_keys, example_full_url, notes, discovered_at, last_seen_at %s ORDER BY %s %s LIMIT ? OFFSET ?", baseQuery, orderBy, sortOrder)
+	query := fmt.Sprintf("SELECT id, target_id, http_traffic_log_id, request_method, request_path, param_keys, example_full_url, notes, discovered_at, last_seen_at, source, confidence %s ORDER BY %s %s LIMIT ? OFFSET ?", baseQuery, orderBy, sortOrder)
 	args = append(args, params.Limit, (params.Page-1)*params.Limit)
 
 	rows, err := DB.Query(query, args...)
@@ -136,7 +176,7 @@ func GetParameterizedURLs(params models.ParameterizedURLFilters) ([]models.Param
 
 	for rows.Next() {
 		var u models.ParameterizedURL
-		if err := rows.Scan(&u.ID, &u.TargetID, &u.HTTPTrafficLogID, &u.RequestMethod, &u.RequestPath, &u.ParamKeys, &u.ExampleFullURL, &u.Notes, &u.DiscoveredAt, &u.LastSeenAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.TargetID, &u.HTTPTrafficLogID, &u.RequestMethod, &u.RequestPath, &u.ParamKeys, &u.ExampleFullURL, &u.Notes, &u.DiscoveredAt, &u.LastSeenAt, &u.Source, &u.Confidence); err != nil {
 			logger.Error("GetParameterizedURLs: Error scanning row: %v", err)
 			return nil, 0, err
 		}
@@ -145,15 +185,35 @@ func GetParameterizedURLs(params models.ParameterizedURLFilters) ([]models.Param
 	return urls, totalRecords, nil
 }
 
+// GetTargetParameterizedURLs retrieves every parameterized URL of a target, most recently seen first.
+func GetTargetParameterizedURLs(targetID int64) ([]models.ParameterizedURL, error) {
+	rows, err := DB.Query(`SELECT id, target_id, http_traffic_log_id, request_method, request_path, param_keys, example_full_url, notes, discovered_at, last_seen_at, source, confidence
+		FROM parameterized_urls WHERE target_id = ? ORDER BY last_seen_at DESC, id DESC`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying parameterized URLs of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	var urls []models.ParameterizedURL
+	for rows.Next() {
+		var u models.ParameterizedURL
+		if err := rows.Scan(&u.ID, &u.TargetID, &u.HTTPTrafficLogID, &u.RequestMethod, &u.RequestPath, &u.ParamKeys, &u.ExampleFullURL, &u.Notes, &u.DiscoveredAt, &u.LastSeenAt, &u.Source, &u.Confidence); err != nil {
+			return nil, fmt.Errorf("scanning parameterized URL: %w", err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
 // GetParameterizedURLByID retrieves a single parameterized URL by its ID.
 func GetParameterizedURLByID(id int64) (models.ParameterizedURL, error) {
 	var pUrl models.ParameterizedURL
-	query := `SELECT id, target_id, http_traffic_log_id, request_method, request_path, param_keys, example_full_url, notes, discovered_at, last_seen_at
+	query := `SELECT id, target_id, http_traffic_log_id, request_method, request_path, param_keys, example_full_url, notes, discovered_at, last_seen_at, source, confidence
 	          FROM parameterized_urls WHERE id = ?`
 	err := DB.QueryRow(query, id).Scan(
 		&pUrl.ID, &pUrl.TargetID, &pUrl.HTTPTrafficLogID, &pUrl.RequestMethod,
 		&pUrl.RequestPath, &pUrl.ParamKeys, &pUrl.ExampleFullURL, &pUrl.Notes,
-		&pUrl.DiscoveredAt, &pUrl.LastSeenAt,
+		&pUrl.DiscoveredAt, &pUrl.LastSeenAt, &pUrl.Source, &pUrl.Confidence,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const payloadExpansionColumns = `id, target_id, log_id, source, placeholder, value, oob_id, context, created_at`
+
+// CreatePayloadExpansions stores generated placeholder values in one transaction.
+func CreatePayloadExpansions(expansions []models.PayloadExpansion) error {
+	if len(expansions) == 0 {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO payload_expansions (target_id, log_id, source, placeholder, value, oob_id, context)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing payload expansion insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, e := range expansions {
+		var oobID interface{}
+		if e.OOBID != "" {
+			oobID = e.OOBID
+		}
+		if _, err := stmt.Exec(e.TargetID, e.LogID, e.Source, e.Placeholder, e.Value, oobID, e.Context); err != nil {
+			return fmt.Errorf("storing payload expansion of {{%s}}: %w", e.Placeholder, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// FindPayloadExpansions returns the recorded payload expansions matching the filters, latest
+// first. A query matches expansions whose OOB identifier is one of its labels, or whose value is
+// the query itself.
+func FindPayloadExpansions(filters models.PayloadExpansionFilters) ([]models.PayloadExpansion, error) {
+	query := `SELECT ` + payloadExpansionColumns + ` FROM payload_expansions WHERE 1 = 1`
+	var args []interface{}
+	if filters.TargetID != 0 {
+		query += ` AND target_id = ?`
+		args = append(args, filters.TargetID)
+	}
+	if filters.LogID != 0 {
+		query += ` AND log_id = ?`
+		args = append(args, filters.LogID)
+	}
+	if q := strings.TrimSpace(filters.Query); q != "" {
+		labels := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+		})
+		placeholders := make([]string, 0, len(labels))
+		for _, label := range labels {
+			placeholders = append(placeholders, "?")
+			args = append(args, label)
+		}
+		condition := `value = ?`
+		if len(placeholders) > 0 {
+			condition = `oob_id IN (` + strings.Join(placeholders, ",") + `) OR ` + condition
+		}
+		query += ` AND (` + condition + `)`
+		args = append(args, q)
+	}
+	query += ` ORDER BY id DESC`
+	if filters.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filters.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying payload expansions: %w", err)
+	}
+	defer rows.Close()
+	expansions := []models.PayloadExpansion{}
+	for rows.Next() {
+		var e models.PayloadExpansion
+		var targetID, logID sql.NullInt64
+		var oobID sql.NullString
+		if err := rows.Scan(&e.ID, &targetID, &logID, &e.Source, &e.Placeholder, &e.Value, &oobID, &e.Context, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning payload expansion: %w", err)
+		}
+		if targetID.Valid {
+			e.TargetID = &targetID.Int64
+		}
+		if logID.Valid {
+			e.LogID = &logID.Int64
+		}
+		e.OOBID = oobID.String
+		expansions = append(expansions, e)
+	}
+	return expansions, rows.Err()
+}
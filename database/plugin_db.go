@@ -0,0 +1,36 @@
+package database
+
+import (
+	"fmt"
+	"toolkit/models"
+)
+
+// GetPluginStates returns the stored on/off states of plugins by name.
+func GetPluginStates() (map[string]models.PluginState, error) {
+	rows, err := DB.Query(`SELECT name, enabled, manifest_sha256, updated_at FROM plugins`)
+	if err != nil {
+		return nil, fmt.Errorf("querying plugin states: %w", err)
+	}
+	defer rows.Close()
+	states := map[string]models.PluginState{}
+	for rows.Next() {
+		var s models.PluginState
+		if err := rows.Scan(&s.Name, &s.Enabled, &s.ManifestSHA256, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning plugin state: %w", err)
+		}
+		states[s.Name] = s
+	}
+	return states, rows.Err()
+}
+
+// SetPluginState records whether a plugin is enabled, with the hash of the manifest it was
+// enabled with.
+func SetPluginState(name string, enabled bool, manifestSHA256 string) error {
+	_, err := DB.Exec(`INSERT INTO plugins (name, enabled, manifest_sha256, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET enabled = excluded.enabled, manifest_sha256 = excluded.manifest_sha256, updated_at = CURRENT_TIMESTAMP`,
+		name, enabled, manifestSHA256)
+	if err != nil {
+		return fmt.Errorf("saving state of plugin '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"toolkit/models"
+)
+
+// GetTargetProgramConstraints returns the program constraints of a target, or nil when it has none.
+func GetTargetProgramConstraints(targetID int64) (*models.TargetProgramConstraints, error) {
+	c := models.TargetProgramConstraints{TargetID: targetID}
+	var bannedPaths, headers string
+	err := DB.QueryRow(`SELECT max_rps, banned_paths, required_headers, updated_at FROM target_program_constraints WHERE target_id = ?`, targetID).
+		Scan(&c.MaxRPS, &bannedPaths, &headers, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading program constraints of target %d: %w", targetID, err)
+	}
+	json.Unmarshal([]byte(bannedPaths), &c.BannedPaths)
+	json.Unmarshal([]byte(headers), &c.RequiredHeaders)
+	if c.BannedPaths == nil {
+		c.BannedPaths = []string{}
+	}
+	if c.RequiredHeaders == nil {
+		c.RequiredHeaders = map[string]string{}
+	}
+	return &c, nil
+}
+
+// SaveTargetProgramConstraints stores the program constraints of a target.
+func SaveTargetProgramConstraints(c models.TargetProgramConstraints) error {
+	if c.BannedPaths == nil {
+		c.BannedPaths = []string{}
+	}
+	if c.RequiredHeaders == nil {
+		c.RequiredHeaders = map[string]string{}
+	}
+	bannedPaths, _ := json.Marshal(c.BannedPaths)
+	headers, _ := json.Marshal(c.RequiredHeaders)
+	_, err := DB.Exec(`INSERT INTO target_program_constraints (target_id, max_rps, banned_paths, required_headers, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(target_id) DO UPDATE SET max_rps = excluded.max_rps, banned_paths = excluded.banned_paths,
+			required_headers = excluded.required_headers, updated_at = CURRENT_TIMESTAMP`,
+		c.TargetID, c.MaxRPS, string(bannedPaths), string(headers))
+	if err != nil {
+		return fmt.Errorf("saving program constraints of target %d: %w", c.TargetID, err)
+	}
+	return nil
+}
+
+// DeleteTargetProgramConstraints removes the program constraints of a target.
+func DeleteTargetProgramConstraints(targetID int64) error {
+	result, err := DB.Exec(`DELETE FROM target_program_constraints WHERE target_id = ?`, targetID)
+	if err != nil {
+		return fmt.Errorf("deleting program constraints of target %d: %w", targetID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("program constraints of target %d not found", targetID)
+	}
+	return nil
+}
+
+// CreateProgramConstraintEvent records an enforcement of a target's program constraints.
+func CreateProgramConstraintEvent(e models.ProgramConstraintEvent) error {
+	_, err := DB.Exec(`INSERT INTO program_constraint_events (target_id, action, method, url, source, detail, delay_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		e.TargetID, e.Action, e.Method, e.URL, e.Source, e.Detail, e.DelayMs)
+	if err != nil {
+		return fmt.Errorf("recording program constraint event of target %d: %w", e.TargetID, err)
+	}
+	return nil
+}
+
+// ListProgramConstraintEvents returns the latest enforcement events of a target, newest first.
+// action filters by action when not empty.
+func ListProgramConstraintEvents(targetID int64, action string, limit int) ([]models.ProgramConstraintEvent, error) {
+	query := `SELECT id, target_id, action, method, url, source, detail, delay_ms, created_at
+		FROM program_constraint_events WHERE target_id = ?`
+	args := []interface{}{targetID}
+	if action != "" {
+		query += ` AND action = ?`
+		args = append(args, action)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing program constraint events of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	events := []models.ProgramConstraintEvent{}
+	for rows.Next() {
+		var e models.ProgramConstraintEvent
+		if err := rows.Scan(&e.ID, &e.TargetID, &e.Action, &e.Method, &e.URL, &e.Source, &e.Detail, &e.DelayMs, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning program constraint event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
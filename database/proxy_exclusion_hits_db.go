@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+// AddProxyExclusionRuleHits adds counted hits to the persisted counters of the exclusion rules.
+func AddProxyExclusionRuleHits(hits map[string]models.ProxyExclusionHits) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning exclusion hit transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO proxy_exclusion_rule_hits (rule_id, hits, last_hit_at) VALUES (?, ?, ?)
+		ON CONFLICT(rule_id) DO UPDATE SET hits = hits + excluded.hits, last_hit_at = excluded.last_hit_at`)
+	if err != nil {
+		return fmt.Errorf("preparing exclusion hit update: %w", err)
+	}
+	defer stmt.Close()
+	for ruleID, h := range hits {
+		if _, err := stmt.Exec(ruleID, h.Hits, h.LastHitAt); err != nil {
+			return fmt.Errorf("adding hits of exclusion rule %s: %w", ruleID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetProxyExclusionRuleHits returns the persisted hit counters by rule ID.
+func GetProxyExclusionRuleHits() (map[string]models.ProxyExclusionHits, error) {
+	rows, err := DB.Query(`SELECT rule_id, hits, last_hit_at FROM proxy_exclusion_rule_hits`)
+	if err != nil {
+		return nil, fmt.Errorf("listing exclusion rule hits: %w", err)
+	}
+	defer rows.Close()
+	hits := map[string]models.ProxyExclusionHits{}
+	for rows.Next() {
+		var ruleID string
+		var h models.ProxyExclusionHits
+		var lastHit sql.NullTime
+		if err := rows.Scan(&ruleID, &h.Hits, &lastHit); err != nil {
+			return nil, fmt.Errorf("scanning exclusion rule hits: %w", err)
+		}
+		h.LastHitAt = lastHit.Time
+		hits[ruleID] = h
+	}
+	return hits, rows.Err()
+}
+
+// DeleteProxyExclusionRuleHitsExcept drops the counters of rules that no longer exist.
+func DeleteProxyExclusionRuleHitsExcept(ruleIDs []string) error {
+	query := `DELETE FROM proxy_exclusion_rule_hits`
+	args := make([]interface{}, len(ruleIDs))
+	if len(ruleIDs) > 0 {
+		placeholders := make([]string, len(ruleIDs))
+		for i, id := range ruleIDs {
+			placeholders[i] = "?"
+			args[i] = id
+		}
+		query += ` WHERE rule_id NOT IN (` + strings.Join(placeholders, ",") + `)`
+	}
+	if _, err := DB.Exec(query, args...); err != nil {
+		return fmt.Errorf("deleting hits of removed exclusion rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+const proxyListenerColumns = `l.port, l.target_id, t.codename, l.created_at, l.updated_at`
+
+func scanProxyListener(scanner interface{ Scan(...interface{}) error }) (models.ProxyListener, error) {
+	var l models.ProxyListener
+	err := scanner.Scan(&l.Port, &l.TargetID, &l.TargetCodename, &l.CreatedAt, &l.UpdatedAt)
+	return l, err
+}
+
+// CreateProxyListener records a proxy listener.
+func CreateProxyListener(port string, targetID int64) error {
+	res, err := DB.Exec(`INSERT INTO proxy_listeners (port, target_id) VALUES (?, ?) ON CONFLICT(port) DO NOTHING`, port, targetID)
+	if err != nil {
+		return fmt.Errorf("inserting proxy listener on port %s: %w", port, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("a proxy listener on port %s already exists", port)
+	}
+	return nil
+}
+
+// GetProxyListener fetches the proxy listener on a port.
+func GetProxyListener(port string) (models.ProxyListener, error) {
+	l, err := scanProxyListener(DB.QueryRow(`SELECT `+proxyListenerColumns+`
+		FROM proxy_listeners l JOIN targets t ON t.id = l.target_id WHERE l.port = ?`, port))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return l, fmt.Errorf("proxy listener on port %s not found", port)
+		}
+		return l, fmt.Errorf("scanning proxy listener on port %s: %w", port, err)
+	}
+	return l, nil
+}
+
+// GetProxyListeners lists the proxy listeners by port.
+func GetProxyListeners() ([]models.ProxyListener, error) {
+	rows, err := DB.Query(`SELECT ` + proxyListenerColumns + `
+		FROM proxy_listeners l JOIN targets t ON t.id = l.target_id ORDER BY CAST(l.port AS INTEGER), l.port`)
+	if err != nil {
+		return nil, fmt.Errorf("querying proxy listeners: %w", err)
+	}
+	defer rows.Close()
+	listeners := []models.ProxyListener{}
+	for rows.Next() {
+		l, err := scanProxyListener(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning proxy listener: %w", err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, rows.Err()
+}
+
+// SetProxyListenerTarget binds a proxy listener to another target.
+func SetProxyListenerTarget(port string, targetID int64) error {
+	res, err := DB.Exec(`UPDATE proxy_listeners SET target_id = ?, updated_at = CURRENT_TIMESTAMP WHERE port = ?`, targetID, port)
+	if err != nil {
+		return fmt.Errorf("updating proxy listener on port %s: %w", port, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("proxy listener on port %s not found", port)
+	}
+	return nil
+}
+
+// DeleteProxyListener removes a proxy listener.
+func DeleteProxyListener(port string) error {
+	res, err := DB.Exec(`DELETE FROM proxy_listeners WHERE port = ?`, port)
+	if err != nil {
+		return fmt.Errorf("deleting proxy listener on port %s: %w", port, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("proxy listener on port %s not found", port)
+	}
+	return nil
+}
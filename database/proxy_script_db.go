@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const proxyScriptColumns = `id, name, path, interpreter, events, target_id, host_pattern, enabled, timeout_ms, position, created_at, updated_at`
+
+func scanProxyScript(scanner interface{ Scan(...interface{}) error }) (models.ProxyScript, error) {
+	var s models.ProxyScript
+	var events string
+	var targetID sql.NullInt64
+	err := scanner.Scan(&s.ID, &s.Name, &s.Path, &s.Interpreter, &events, &targetID, &s.HostPattern, &s.Enabled,
+		&s.TimeoutMs, &s.Position, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return s, err
+	}
+	json.Unmarshal([]byte(events), &s.Events)
+	if s.Events == nil {
+		s.Events = []string{}
+	}
+	if targetID.Valid {
+		s.TargetID = &targetID.Int64
+	}
+	return s, nil
+}
+
+// CreateProxyScript records a proxy script and returns its ID.
+func CreateProxyScript(s models.ProxyScript) (int64, error) {
+	events, _ := json.Marshal(s.Events)
+	res, err := DB.Exec(`INSERT INTO proxy_scripts (name, path, interpreter, events, target_id, host_pattern, enabled, timeout_ms, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Name, s.Path, s.Interpreter, string(events), s.TargetID, s.HostPattern, s.Enabled, s.TimeoutMs, s.Position)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("a proxy script named '%s' already exists", s.Name)
+		}
+		return 0, fmt.Errorf("inserting proxy script '%s': %w", s.Name, err)
+	}
+	return res.LastInsertId()
+}
+
+// UpdateProxyScript replaces the settings of a proxy script.
+func UpdateProxyScript(s models.ProxyScript) error {
+	events, _ := json.Marshal(s.Events)
+	res, err := DB.Exec(`UPDATE proxy_scripts SET name = ?, path = ?, interpreter = ?, events = ?, target_id = ?, host_pattern = ?,
+		enabled = ?, timeout_ms = ?, position = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		s.Name, s.Path, s.Interpreter, string(events), s.TargetID, s.HostPattern, s.Enabled, s.TimeoutMs, s.Position, s.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("a proxy script named '%s' already exists", s.Name)
+		}
+		return fmt.Errorf("updating proxy script %d: %w", s.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("proxy script %d not found", s.ID)
+	}
+	return nil
+}
+
+// GetProxyScript fetches a proxy script.
+func GetProxyScript(id int64) (models.ProxyScript, error) {
+	s, err := scanProxyScript(DB.QueryRow(`SELECT `+proxyScriptColumns+` FROM proxy_scripts WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("proxy script %d not found", id)
+	}
+	if err != nil {
+		return s, fmt.Errorf("scanning proxy script %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// GetProxyScripts lists the proxy scripts in the order they run.
+func GetProxyScripts() ([]models.ProxyScript, error) {
+	rows, err := DB.Query(`SELECT ` + proxyScriptColumns + ` FROM proxy_scripts ORDER BY position, id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying proxy scripts: %w", err)
+	}
+	defer rows.Close()
+	scripts := []models.ProxyScript{}
+	for rows.Next() {
+		s, err := scanProxyScript(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning proxy script: %w", err)
+		}
+		scripts = append(scripts, s)
+	}
+	return scripts, rows.Err()
+}
+
+// DeleteProxyScript removes a proxy script.
+func DeleteProxyScript(id int64) error {
+	res, err := DB.Exec(`DELETE FROM proxy_scripts WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting proxy script %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("proxy script %d not found", id)
+	}
+	return nil
+}
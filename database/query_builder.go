@@ -0,0 +1,307 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Conditions collects the AND-ed conditions of a WHERE clause together with the arguments of their
+// placeholders, so a condition can never get out of step with its values. The zero value selects
+// every row.
+type Conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// Add appends a condition; args fill its placeholders in order.
+func (c *Conditions) Add(clause string, args ...interface{}) {
+	if n := strings.Count(clause, "?"); n != len(args) {
+		// A mismatch is a programming error; failing loudly beats a query binding the wrong values.
+		panic(fmt.Sprintf("database: condition %q has %d placeholders but %d arguments", clause, n, len(args)))
+	}
+	c.clauses = append(c.clauses, clause)
+	c.args = append(c.args, args...)
+}
+
+// AddIn appends "expr IN (?, ...)" with one placeholder per ID. Without IDs nothing is added.
+func (c *Conditions) AddIn(expr string, ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	c.Add(expr+" IN ("+placeholders(len(ids))+")", args...)
+}
+
+// Where returns the WHERE clause, empty without conditions.
+func (c Conditions) Where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return "WHERE " + c.SQL()
+}
+
+// SQL returns the conditions joined with AND, "1 = 1" without conditions, for queries adding
+// conditions of their own.
+func (c Conditions) SQL() string {
+	if len(c.clauses) == 0 {
+		return "1 = 1"
+	}
+	return strings.Join(c.clauses, " AND ")
+}
+
+// Args returns the arguments of the conditions followed by extra, in a new slice.
+func (c Conditions) Args(extra ...interface{}) []interface{} {
+	args := make([]interface{}, 0, len(c.args)+len(extra))
+	return append(append(args, c.args...), extra...)
+}
+
+// Clone returns a copy that can be extended without changing c.
+func (c Conditions) Clone() Conditions {
+	return Conditions{clauses: append([]string(nil), c.clauses...), args: append([]interface{}(nil), c.args...)}
+}
+
+// placeholders returns n comma-separated placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// SortSpec lists the columns a listing can be sorted by, by the name clients use, with the SQL
+// expression each stands for.
+type SortSpec struct {
+	Columns      map[string]string
+	Default      string // Name of the column used when the requested one is unknown
+	DefaultOrder string // "ASC" or "DESC"
+	TieBreaker   string // Unique expression ordering rows with equal sort values, in the same direction
+}
+
+// Resolve returns the column name and order a request sorts by: unknown columns fall back to the
+// default column and orders other than asc/desc (any case) to the default order.
+func (s SortSpec) Resolve(sortBy, sortOrder string) (string, string) {
+	if _, ok := s.Columns[sortBy]; !ok {
+		sortBy = s.Default
+	}
+	order := strings.ToUpper(strings.TrimSpace(sortOrder))
+	if order != "ASC" && order != "DESC" {
+		order = s.DefaultOrder
+	}
+	return sortBy, order
+}
+
+// OrderBy returns the ORDER BY clause of a request, see Resolve.
+func (s SortSpec) OrderBy(sortBy, sortOrder string) string {
+	sortBy, order := s.Resolve(sortBy, sortOrder)
+	clause := "ORDER BY " + s.Columns[sortBy] + " " + order
+	if s.TieBreaker != "" && s.TieBreaker != s.Columns[sortBy] {
+		clause += ", " + s.TieBreaker + " " + order
+	}
+	return clause
+}
+
+// Page is a page of a listing. Numbers start at 1; a Limit of 0 stands for all rows.
+type Page struct {
+	Number int
+	Limit  int
+}
+
+// NewPage normalizes a requested page: numbers below 1 become 1, limits below 1 become
+// defaultLimit (0 stays 0 when allowAll) and limits above maxLimit become maxLimit.
+func NewPage(number, limit, defaultLimit, maxLimit int, allowAll bool) Page {
+	if number < 1 {
+		number = 1
+	}
+	switch {
+	case limit == 0 && allowAll:
+	case limit < 1:
+		limit = defaultLimit
+	case maxLimit > 0 && limit > maxLimit:
+		limit = maxLimit
+	}
+	return Page{Number: number, Limit: limit}
+}
+
+// Offset returns the number of rows before the page.
+func (p Page) Offset() int {
+	if p.Limit == 0 {
+		return 0
+	}
+	return (p.Number - 1) * p.Limit
+}
+
+// TotalPages returns the number of pages of total rows.
+func (p Page) TotalPages(total int64) int64 {
+	switch {
+	case total <= 0:
+		return 0
+	case p.Limit == 0:
+		return 1
+	}
+	return (total + int64(p.Limit) - 1) / int64(p.Limit)
+}
+
+// Clamp returns the last page instead of a page past the end of total rows.
+func (p Page) Clamp(total int64) Page {
+	if last := p.TotalPages(total); last > 0 && int64(p.Number) > last {
+		p.Number = int(last)
+	}
+	return p
+}
+
+// LimitClause returns the LIMIT/OFFSET clause of the page and its arguments; both are empty when
+// the page holds all rows.
+func (p Page) LimitClause() (string, []interface{}) {
+	if p.Limit == 0 {
+		return "", nil
+	}
+	return " LIMIT ? OFFSET ?", []interface{}{p.Limit, p.Offset()}
+}
+
+// Keyset describes cursor pagination of a listing: rows are ordered by a time column with the
+// row ID as tie-breaker and each page starts after the last row of the previous one, so deep pages
+// of large tables cost no more than the first instead of reading and skipping OFFSET rows.
+type Keyset struct {
+	Table   string   // Table the listing reads, for looking up the values cursors hold
+	Columns []string // Columns the listing can be paged by, the default first
+}
+
+// Cursor is a position in a listing paged by keyset: the column and order of the listing and the
+// column value and ID of the row the page starts after, or before when Before is set. Clients get
+// it as an opaque string.
+type Cursor struct {
+	Column string `json:"c"`
+	Order  string `json:"o"`
+	Value  string `json:"v"`
+	ID     int64  `json:"id"`
+	Before bool   `json:"b,omitempty"`
+}
+
+// String returns the cursor as sent to clients.
+func (c Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// KeysetPage is a page of a listing paged by keyset.
+type KeysetPage struct {
+	Table  string
+	Column string
+	Order  string // "ASC" or "DESC"
+	Limit  int
+	Cursor *Cursor // Position the page starts at; nil for the first page
+}
+
+// Page returns the page a request asks for. A cursor fixes the column and order of the listing;
+// without one they come from sortBy (the default column when empty) and sortOrder (newest first
+// unless "asc"). An unusable cursor or column is an invalid request.
+func (k Keyset) Page(cursor, sortBy, sortOrder string, limit int) (KeysetPage, error) {
+	p := KeysetPage{Table: k.Table, Limit: limit}
+	if cursor != "" {
+		var c Cursor
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err == nil {
+			err = json.Unmarshal(b, &c)
+		}
+		if err != nil || !k.hasColumn(c.Column) || (c.Order != "ASC" && c.Order != "DESC") {
+			return p, fmt.Errorf("invalid cursor")
+		}
+		p.Column, p.Order, p.Cursor = c.Column, c.Order, &c
+		return p, nil
+	}
+	p.Column = sortBy
+	if p.Column == "" {
+		p.Column = k.Columns[0]
+	}
+	if !k.hasColumn(p.Column) {
+		return p, fmt.Errorf("invalid sort_by '%s' for cursor pagination, expected one of %s", sortBy, strings.Join(k.Columns, ", "))
+	}
+	p.Order = "DESC"
+	if strings.EqualFold(strings.TrimSpace(sortOrder), "ASC") {
+		p.Order = "ASC"
+	}
+	return p, nil
+}
+
+func (k Keyset) hasColumn(column string) bool {
+	for _, c := range k.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply adds the condition selecting the rows from the cursor on to c and returns the ORDER BY
+// clause of the page. alias qualifies the columns like in the rest of the query.
+func (p KeysetPage) Apply(c *Conditions, alias string) string {
+	column, id := p.Column, "id"
+	if alias != "" {
+		column, id = alias+"."+column, alias+".id"
+	}
+	order, op := p.Order, "<"
+	if order == "ASC" {
+		op = ">"
+	}
+	if p.Cursor != nil {
+		if p.Cursor.Before {
+			// Rows before the cursor are read backwards from it; KeysetRows restores the order.
+			if order == "ASC" {
+				order, op = "DESC", "<"
+			} else {
+				order, op = "ASC", ">"
+			}
+		}
+		c.Add("("+column+" "+op+" ? OR ("+column+" = ? AND "+id+" "+op+" ?))", p.Cursor.Value, p.Cursor.Value, p.Cursor.ID)
+	}
+	return "ORDER BY " + column + " " + order + ", " + id + " " + order
+}
+
+// LimitClause returns the LIMIT clause of the page and its argument. One row more than the limit
+// is read, telling whether another page follows.
+func (p KeysetPage) LimitClause() (string, []interface{}) {
+	return " LIMIT ?", []interface{}{p.Limit + 1}
+}
+
+// KeysetRows trims the rows read for a page (see Apply) to its limit, in listing order, and returns
+// the cursors of the previous and next pages, empty when there is none.
+func KeysetRows[T any](p KeysetPage, rows []T, id func(T) int64) ([]T, string, string, error) {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+	before := p.Cursor != nil && p.Cursor.Before
+	if before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, "", "", nil
+	}
+	var prev, next string
+	var err error
+	if (p.Cursor != nil && !before) || (before && more) {
+		if prev, err = p.cursorAt(id(rows[0]), true); err != nil {
+			return rows, "", "", err
+		}
+	}
+	if more || before {
+		if next, err = p.cursorAt(id(rows[len(rows)-1]), false); err != nil {
+			return rows, "", "", err
+		}
+	}
+	return rows, prev, next, nil
+}
+
+// cursorAt returns the cursor of the page starting after (or before) a row. The value is read as
+// stored, so it compares with the column exactly like the rows are ordered.
+func (p KeysetPage) cursorAt(id int64, before bool) (string, error) {
+	c := Cursor{Column: p.Column, Order: p.Order, ID: id, Before: before}
+	if err := DB.QueryRow("SELECT CAST("+p.Column+" AS TEXT) FROM "+p.Table+" WHERE id = ?", id).Scan(&c.Value); err != nil {
+		return "", fmt.Errorf("reading cursor of %s %d: %w", p.Table, id, err)
+	}
+	return c.String(), nil
+}
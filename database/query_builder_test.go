@@ -0,0 +1,295 @@
+package database
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"toolkit/models"
+)
+
+func TestConditionsEmpty(t *testing.T) {
+	var c Conditions
+	if c.Where() != "" || c.SQL() != "1 = 1" || len(c.Args()) != 0 {
+		t.Errorf("zero Conditions = %q, %q, %v", c.Where(), c.SQL(), c.Args())
+	}
+	c.AddIn("id", nil)
+	if c.Where() != "" {
+		t.Errorf("AddIn without IDs added %q", c.Where())
+	}
+}
+
+func TestConditionsAddPanicsOnMismatch(t *testing.T) {
+	for _, tc := range []struct {
+		clause string
+		args   []interface{}
+	}{
+		{"a = ? AND b = ?", []interface{}{1}},
+		{"a = ?", []interface{}{1, 2}},
+		{"a = 1", []interface{}{1}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Add(%q, %v) did not panic", tc.clause, tc.args)
+				}
+			}()
+			var c Conditions
+			c.Add(tc.clause, tc.args...)
+		}()
+	}
+}
+
+func TestConditionsCloneAndArgs(t *testing.T) {
+	var c Conditions
+	c.Add("a = ?", 1)
+	clone := c.Clone()
+	clone.AddIn("b", []int64{2, 3})
+	if c.SQL() != "a = ?" || !reflect.DeepEqual(c.Args(), []interface{}{1}) {
+		t.Errorf("extending a clone changed the original: %q %v", c.SQL(), c.Args())
+	}
+	if clone.Where() != "WHERE a = ? AND b IN (?, ?)" {
+		t.Errorf("clone.Where() = %q", clone.Where())
+	}
+	if got := clone.Args("limit"); !reflect.DeepEqual(got, []interface{}{1, int64(2), int64(3), "limit"}) {
+		t.Errorf("clone.Args(extra) = %v", got)
+	}
+}
+
+func TestTrafficLogConditionsCombined(t *testing.T) {
+	c, err := TrafficLogConditions("htl", models.ProxyLogFilters{
+		TargetID:            7,
+		FilterFavoritesOnly: true,
+		FilterMethod:        " post ",
+		FilterStatus:        "404",
+		FilterContentType:   "json",
+		FilterSource:        "Replay",
+		FilterURLContains:   "/api/",
+		CaptureSessionID:    3,
+		FilterTagIDs:        []int64{5, 6},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql := c.SQL()
+	for _, want := range []string{
+		"htl.target_id = ?", "htl.is_favorite = TRUE", "UPPER(htl.request_method) = ?", "htl.response_status_code = ?",
+		"LOWER(htl.response_content_type) LIKE LOWER(?)", "COALESCE(NULLIF(htl.log_source, ''), ?) = ?", "htl.request_url LIKE ?",
+		"capture_sessions cs WHERE cs.id = ?", "htl.id IN (SELECT DISTINCT item_id FROM tag_associations",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("conditions lack %q:\n%s", want, sql)
+		}
+	}
+	// The arguments follow the placeholders in the order the conditions were added.
+	want := []interface{}{int64(7), "POST", int64(404), "%json%", models.LogSourceProxy, "Replay", "%/api/%", int64(3), int64(5), int64(6)}
+	if got := c.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %#v\nwant %#v", got, want)
+	}
+	if n := strings.Count(sql, "?"); n != len(want) {
+		t.Errorf("%d placeholders for %d arguments", n, len(want))
+	}
+}
+
+func TestTrafficLogConditionsDomainAndSearch(t *testing.T) {
+	c, err := TrafficLogConditions("", models.ProxyLogFilters{FilterDomain: "example.com", FilterSearchText: "admin", FilterStatus: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"example.com", "example.com", "example.com", "example.com", "example.com", "example.com", "%admin%", "%admin%", "%admin%", "%admin%"}
+	if got := c.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %#v\nwant %#v", got, want)
+	}
+	if strings.Contains(c.SQL(), "htl.") || strings.Contains(c.SQL(), "response_status_code = ?") {
+		t.Errorf("unaliased conditions with an invalid status = %s", c.SQL())
+	}
+}
+
+func TestTrafficLogConditionsInvalidOrigin(t *testing.T) {
+	if _, err := TrafficLogConditions("", models.ProxyLogFilters{FilterOrigin: "robots"}); err == nil || !strings.HasPrefix(err.Error(), "invalid origin") {
+		t.Errorf("origin 'robots' gave error %v", err)
+	}
+	c, err := TrafficLogConditions("", models.ProxyLogFilters{FilterOrigin: models.TrafficOriginManual})
+	if err != nil || len(c.Args()) != 0 || !strings.Contains(c.SQL(), "log_source") {
+		t.Errorf("manual origin = %q, %v, %v", c.SQL(), c.Args(), err)
+	}
+}
+
+func TestSortSpecOrderBy(t *testing.T) {
+	spec := SortSpec{
+		Columns:      map[string]string{"name": "t.name", "created_at": "t.created_at", "id": "t.id"},
+		Default:      "created_at",
+		DefaultOrder: "DESC",
+		TieBreaker:   "t.id",
+	}
+	for _, tc := range []struct{ sortBy, order, want string }{
+		{"name", "asc", "ORDER BY t.name ASC, t.id ASC"},
+		{"name", " Desc ", "ORDER BY t.name DESC, t.id DESC"},
+		{"", "", "ORDER BY t.created_at DESC, t.id DESC"},
+		{"id", "ASC", "ORDER BY t.id ASC"},
+		// Columns off the whitelist and orders other than asc/desc never reach the SQL.
+		{"password", "ASC", "ORDER BY t.created_at ASC, t.id ASC"},
+		{"name; DROP TABLE t", "ASC", "ORDER BY t.created_at ASC, t.id ASC"},
+		{"t.name", "ASC", "ORDER BY t.created_at ASC, t.id ASC"},
+		{"name", "ASC; --", "ORDER BY t.name DESC, t.id DESC"},
+	} {
+		if got := spec.OrderBy(tc.sortBy, tc.order); got != tc.want {
+			t.Errorf("OrderBy(%q, %q) = %q, want %q", tc.sortBy, tc.order, got, tc.want)
+		}
+	}
+	if got := TrafficLogSort.OrderBy("response_body", "ASC"); strings.Contains(got, "response_body ") {
+		t.Errorf("TrafficLogSort sorted by an unlisted column: %q", got)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	for _, tc := range []struct {
+		number, limit, defaultLimit, maxLimit int
+		allowAll                              bool
+		want                                  Page
+		offset                                int
+	}{
+		{0, 0, 20, 100, false, Page{1, 20}, 0},
+		{-3, -1, 20, 100, false, Page{1, 20}, 0},
+		{3, 500, 20, 100, false, Page{3, 100}, 200},
+		{2, 50, 20, 0, false, Page{2, 50}, 50},
+		{4, 0, 20, 100, true, Page{4, 0}, 0},
+		{2, -1, 20, 100, true, Page{2, 20}, 20},
+	} {
+		p := NewPage(tc.number, tc.limit, tc.defaultLimit, tc.maxLimit, tc.allowAll)
+		if p != tc.want || p.Offset() != tc.offset {
+			t.Errorf("NewPage(%d, %d, %d, %d, %v) = %+v offset %d, want %+v offset %d", tc.number, tc.limit, tc.defaultLimit,
+				tc.maxLimit, tc.allowAll, p, p.Offset(), tc.want, tc.offset)
+		}
+	}
+
+	p := Page{Number: 9, Limit: 10}
+	if p.TotalPages(95) != 10 || p.TotalPages(0) != 0 || (Page{Number: 1}).TotalPages(95) != 1 {
+		t.Errorf("TotalPages = %d, %d, %d", p.TotalPages(95), p.TotalPages(0), (Page{Number: 1}).TotalPages(95))
+	}
+	if got := (Page{Number: 12, Limit: 10}).Clamp(95); got.Number != 10 {
+		t.Errorf("Clamp past the end = page %d, want 10", got.Number)
+	}
+	if clause, args := (Page{Number: 3, Limit: 10}).LimitClause(); clause != " LIMIT ? OFFSET ?" || !reflect.DeepEqual(args, []interface{}{10, 20}) {
+		t.Errorf("LimitClause() = %q, %v", clause, args)
+	}
+	if clause, args := (Page{Number: 3}).LimitClause(); clause != "" || args != nil {
+		t.Errorf("LimitClause() of all rows = %q, %v", clause, args)
+	}
+}
+
+func TestKeysetPageRejectsUnknownColumnsAndCursors(t *testing.T) {
+	k := Keyset{Table: "items", Columns: []string{"created_at", "updated_at"}}
+	if _, err := k.Page("", "id; DROP TABLE items", "", 10); err == nil {
+		t.Error("an unlisted sort_by was accepted")
+	}
+	for _, cursor := range []string{"not base64!", Cursor{Column: "secret", Order: "ASC"}.String(), Cursor{Column: "created_at", Order: "SIDEWAYS"}.String()} {
+		if _, err := k.Page(cursor, "", "", 10); err == nil || err.Error() != "invalid cursor" {
+			t.Errorf("cursor %q gave error %v", cursor, err)
+		}
+	}
+	p, err := k.Page("", "", "asc", 10)
+	if err != nil || p.Column != "created_at" || p.Order != "ASC" || p.Cursor != nil {
+		t.Errorf("Page without cursor = %+v, %v", p, err)
+	}
+}
+
+// withKeysetItems points DB at an in-memory table of items, several sharing a created_at so the
+// ID has to break ties.
+func withKeysetItems(t *testing.T) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // Every connection to :memory: is a database of its own
+	saved := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = saved
+		db.Close()
+	})
+	if _, err := db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, created_at TEXT NOT NULL, updated_at TEXT NOT NULL);
+		INSERT INTO items (id, created_at, updated_at) VALUES
+			(1, '2026-01-01', '2026-01-01'), (2, '2026-01-02', '2026-01-02'), (3, '2026-01-02', '2026-01-02'),
+			(4, '2026-01-02', '2026-01-02'), (5, '2026-01-03', '2026-01-03'), (6, '2026-01-04', '2026-01-04'),
+			(7, '2026-01-05', '2026-01-05')`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readKeysetPage reads a page of items the way listings do.
+func readKeysetPage(t *testing.T, p KeysetPage) ([]int64, string, string) {
+	t.Helper()
+	var c Conditions
+	orderBy := p.Apply(&c, "i")
+	limit, limitArgs := p.LimitClause()
+	rows, err := DB.Query("SELECT i.id FROM items i "+c.Where()+" "+orderBy+limit, c.Args(limitArgs...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	ids, prev, next, err := KeysetRows(p, ids, func(id int64) int64 { return id })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids, prev, next
+}
+
+func TestKeysetCursorRoundTrip(t *testing.T) {
+	withKeysetItems(t)
+	k := Keyset{Table: "items", Columns: []string{"created_at", "updated_at"}}
+
+	for _, tc := range []struct {
+		order string
+		pages [][]int64
+	}{
+		{"desc", [][]int64{{7, 6, 5}, {4, 3, 2}, {1}}},
+		{"asc", [][]int64{{1, 2, 3}, {4, 5, 6}, {7}}},
+	} {
+		p, err := k.Page("", "", tc.order, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prevs []string
+		for i, want := range tc.pages {
+			ids, prev, next := readKeysetPage(t, p)
+			if !reflect.DeepEqual(ids, want) {
+				t.Fatalf("%s page %d = %v, want %v", tc.order, i+1, ids, want)
+			}
+			if (prev == "") != (i == 0) || (next == "") != (i == len(tc.pages)-1) {
+				t.Errorf("%s page %d: prev %q, next %q", tc.order, i+1, prev, next)
+			}
+			prevs = append(prevs, prev)
+			if next == "" {
+				break
+			}
+			// The cursor is all a client sends back; sort_by and sort_order no longer matter.
+			if p, err = k.Page(next, "updated_at", "", 3); err != nil {
+				t.Fatalf("next cursor of %s page %d: %v", tc.order, i+1, err)
+			}
+			if p.Column != "created_at" {
+				t.Errorf("the cursor lost its column: %q", p.Column)
+			}
+		}
+
+		// Going back from the last page gives the page before it again, in listing order.
+		last := len(tc.pages) - 1
+		p, err = k.Page(prevs[last], "", "", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids, prev, next := readKeysetPage(t, p)
+		if !reflect.DeepEqual(ids, tc.pages[last-1]) || prev == "" || next == "" {
+			t.Errorf("%s page before the last = %v (prev %q, next %q), want %v", tc.order, ids, prev, next, tc.pages[last-1])
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"toolkit/models"
+)
+
+const hostRateProfileColumns = `host, target_id, endpoint, method, baseline_status, safe_rps, manual, throttle_rps, throttle_status,
+	retry_after, max_tested_rps, requests_sent, wafs, steps, profiled_at, updated_at`
+
+func scanHostRateProfile(scanner interface{ Scan(...interface{}) error }) (models.HostRateProfile, error) {
+	var p models.HostRateProfile
+	var targetID, throttleStatus sql.NullInt64
+	var safeRPS, throttleRPS sql.NullFloat64
+	var wafs, steps string
+	var profiledAt sql.NullTime
+	err := scanner.Scan(&p.Host, &targetID, &p.Endpoint, &p.Method, &p.BaselineStatus, &safeRPS, &p.Manual, &throttleRPS, &throttleStatus,
+		&p.RetryAfter, &p.MaxTestedRPS, &p.RequestsSent, &wafs, &steps, &profiledAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	if targetID.Valid {
+		p.TargetID = &targetID.Int64
+	}
+	if safeRPS.Valid {
+		p.SafeRPS = &safeRPS.Float64
+	}
+	if throttleRPS.Valid {
+		p.ThrottleRPS = &throttleRPS.Float64
+	}
+	if throttleStatus.Valid {
+		status := int(throttleStatus.Int64)
+		p.ThrottleStatus = &status
+	}
+	if profiledAt.Valid {
+		p.ProfiledAt = &profiledAt.Time
+	}
+	if err := json.Unmarshal([]byte(wafs), &p.WAFs); err != nil || p.WAFs == nil {
+		p.WAFs = []models.WAFDetection{}
+	}
+	if err := json.Unmarshal([]byte(steps), &p.Steps); err != nil || p.Steps == nil {
+		p.Steps = []models.RateBurstStep{}
+	}
+	return p, nil
+}
+
+// SaveHostRateProfile inserts or replaces the rate profile of a host.
+func SaveHostRateProfile(p models.HostRateProfile) error {
+	wafs, err := json.Marshal(p.WAFs)
+	if err != nil {
+		return fmt.Errorf("encoding WAF detections: %w", err)
+	}
+	steps, err := json.Marshal(p.Steps)
+	if err != nil {
+		return fmt.Errorf("encoding burst steps: %w", err)
+	}
+	var profiledAt interface{}
+	if p.ProfiledAt != nil {
+		profiledAt = p.ProfiledAt.UTC()
+	}
+	_, err = DB.Exec(`INSERT INTO host_rate_profiles (`+hostRateProfileColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host) DO UPDATE SET target_id = excluded.target_id, endpoint = excluded.endpoint, method = excluded.method,
+			baseline_status = excluded.baseline_status, safe_rps = excluded.safe_rps, manual = excluded.manual,
+			throttle_rps = excluded.throttle_rps, throttle_status = excluded.throttle_status, retry_after = excluded.retry_after,
+			max_tested_rps = excluded.max_tested_rps, requests_sent = excluded.requests_sent, wafs = excluded.wafs,
+			steps = excluded.steps, profiled_at = excluded.profiled_at, updated_at = excluded.updated_at`,
+		p.Host, p.TargetID, p.Endpoint, p.Method, p.BaselineStatus, p.SafeRPS, p.Manual, p.ThrottleRPS, p.ThrottleStatus,
+		p.RetryAfter, p.MaxTestedRPS, p.RequestsSent, string(wafs), string(steps), profiledAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("saving rate profile of %s: %w", p.Host, err)
+	}
+	return nil
+}
+
+// GetHostRateProfile returns the rate profile of a host.
+func GetHostRateProfile(host string) (models.HostRateProfile, error) {
+	p, err := scanHostRateProfile(DB.QueryRow(`SELECT `+hostRateProfileColumns+` FROM host_rate_profiles WHERE host = ?`, host))
+	if err == sql.ErrNoRows {
+		return p, fmt.Errorf("rate profile of host %s not found", host)
+	}
+	if err != nil {
+		return p, fmt.Errorf("loading rate profile of %s: %w", host, err)
+	}
+	return p, nil
+}
+
+// GetHostRateProfiles lists the rate profiles of a target's hosts, or of all hosts when targetID
+// is 0, sorted by host.
+func GetHostRateProfiles(targetID int64) ([]models.HostRateProfile, error) {
+	query := `SELECT ` + hostRateProfileColumns + ` FROM host_rate_profiles`
+	var args []interface{}
+	if targetID != 0 {
+		query += ` WHERE target_id = ?`
+		args = append(args, targetID)
+	}
+	rows, err := DB.Query(query+` ORDER BY host`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying rate profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []models.HostRateProfile
+	for rows.Next() {
+		p, err := scanHostRateProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning rate profile: %w", err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// GetHostSafeRates returns the safe rate of every host that has one.
+func GetHostSafeRates() (map[string]float64, error) {
+	rows, err := DB.Query(`SELECT host, safe_rps FROM host_rate_profiles WHERE safe_rps IS NOT NULL AND safe_rps > 0`)
+	if err != nil {
+		return nil, fmt.Errorf("querying safe rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := make(map[string]float64)
+	for rows.Next() {
+		var host string
+		var rps float64
+		if err := rows.Scan(&host, &rps); err != nil {
+			return nil, fmt.Errorf("scanning safe rate: %w", err)
+		}
+		rates[host] = rps
+	}
+	return rates, rows.Err()
+}
+
+// SetHostSafeRate sets the safe rate of a host by hand, creating its profile if needed. A rate
+// of 0 clears it.
+func SetHostSafeRate(host string, rps float64) error {
+	var safe interface{}
+	if rps > 0 {
+		safe = rps
+	}
+	_, err := DB.Exec(`INSERT INTO host_rate_profiles (host, safe_rps, manual, updated_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT(host) DO UPDATE SET safe_rps = excluded.safe_rps, manual = 1, updated_at = excluded.updated_at`,
+		host, safe, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("setting safe rate of %s: %w", host, err)
+	}
+	return nil
+}
+
+// DeleteHostRateProfile removes the rate profile of a host.
+func DeleteHostRateProfile(host string) error {
+	result, err := DB.Exec(`DELETE FROM host_rate_profiles WHERE host = ?`, host)
+	if err != nil {
+		return fmt.Errorf("deleting rate profile of %s: %w", host, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("rate profile of host %s not found", host)
+	}
+	return nil
+}
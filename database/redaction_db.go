@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"toolkit/models"
+)
+
+// GetTargetRedactionOverride returns the redaction override of a target, or nil when it has none.
+func GetTargetRedactionOverride(targetID int64) (*models.TargetRedactionOverride, error) {
+	o := models.TargetRedactionOverride{TargetID: targetID}
+	var enabled sql.NullBool
+	var headers, jsonPaths, parameters string
+	err := DB.QueryRow(`SELECT enabled, apply_at, replace_global, headers, json_paths, parameters, updated_at
+		FROM target_redaction_rules WHERE target_id = ?`, targetID).
+		Scan(&enabled, &o.ApplyAt, &o.Replace, &headers, &jsonPaths, &parameters, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading redaction override of target %d: %w", targetID, err)
+	}
+	if enabled.Valid {
+		o.Enabled = &enabled.Bool
+	}
+	json.Unmarshal([]byte(headers), &o.Headers)
+	json.Unmarshal([]byte(jsonPaths), &o.JSONPaths)
+	json.Unmarshal([]byte(parameters), &o.Parameters)
+	return &o, nil
+}
+
+// SaveTargetRedactionOverride stores the redaction override of a target.
+func SaveTargetRedactionOverride(o models.TargetRedactionOverride) error {
+	headers, _ := json.Marshal(nonNilStrings(o.Headers))
+	jsonPaths, _ := json.Marshal(nonNilStrings(o.JSONPaths))
+	parameters, _ := json.Marshal(nonNilStrings(o.Parameters))
+	var enabled sql.NullBool
+	if o.Enabled != nil {
+		enabled = sql.NullBool{Bool: *o.Enabled, Valid: true}
+	}
+	_, err := DB.Exec(`INSERT INTO target_redaction_rules (target_id, enabled, apply_at, replace_global, headers, json_paths, parameters, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(target_id) DO UPDATE SET enabled = excluded.enabled, apply_at = excluded.apply_at,
+			replace_global = excluded.replace_global, headers = excluded.headers, json_paths = excluded.json_paths,
+			parameters = excluded.parameters, updated_at = CURRENT_TIMESTAMP`,
+		o.TargetID, enabled, o.ApplyAt, o.Replace, string(headers), string(jsonPaths), string(parameters))
+	if err != nil {
+		return fmt.Errorf("saving redaction override of target %d: %w", o.TargetID, err)
+	}
+	return nil
+}
+
+// DeleteTargetRedactionOverride removes the redaction override of a target.
+func DeleteTargetRedactionOverride(targetID int64) error {
+	result, err := DB.Exec(`DELETE FROM target_redaction_rules WHERE target_id = ?`, targetID)
+	if err != nil {
+		return fmt.Errorf("deleting redaction override of target %d: %w", targetID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("redaction override of target %d not found", targetID)
+	}
+	return nil
+}
+
+// ListTrafficLogsForRedaction returns up to limit logged requests of a target with an ID above
+// afterID, in ID order, with the columns redaction rewrites.
+func ListTrafficLogsForRedaction(targetID, afterID int64, limit int) ([]models.HTTPTrafficLog, error) {
+	rows, err := DB.Query(`SELECT id, target_id, request_url, request_full_url_with_fragment, request_headers, `+logRequestBody+`,
+			response_headers, `+logResponseBody+`, response_body_size, response_body_truncated
+		FROM http_traffic_log WHERE target_id = ? AND id > ? ORDER BY id LIMIT ?`, targetID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing traffic of target %d for redaction: %w", targetID, err)
+	}
+	defer rows.Close()
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		var responseBodySize sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.TargetID, &e.RequestURL, &e.RequestFullURLWithFragment, &e.RequestHeaders, &e.RequestBody,
+			&e.ResponseHeaders, &e.ResponseBody, &responseBodySize, &e.ResponseBodyTruncated); err != nil {
+			return nil, fmt.Errorf("scanning traffic log for redaction: %w", err)
+		}
+		e.ResponseBodySize = responseBodySize.Int64
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// UpdateRedactedTrafficLog stores the redacted columns of a logged request. Redacted bodies are
+// stored as blobs of their own, so entries that shared the original body keep it.
+func UpdateRedactedTrafficLog(e models.HTTPTrafficLog) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("starting redaction transaction: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`UPDATE http_traffic_log SET request_url = ?, request_full_url_with_fragment = ?, request_headers = ?,
+			response_headers = ?, response_body_size = ?
+		WHERE id = ?`,
+		e.RequestURL, e.RequestFullURLWithFragment, e.RequestHeaders, e.ResponseHeaders, e.ResponseBodySize, e.ID)
+	if err != nil {
+		return fmt.Errorf("updating redacted traffic log %d: %w", e.ID, err)
+	}
+	if err := setTrafficLogBodies(tx, e.ID, e.RequestBody, e.ResponseBody); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,530 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// replaySessionHeaderNames lists the request headers copied from a logged request into a
+// replay session (Cookie is stored separately).
+var replaySessionHeaderNames = []string{"Authorization", "X-Csrf-Token", "X-Xsrf-Token", "X-Requested-With", "User-Agent"}
+
+func scanReplaySession(scanner interface{ Scan(...interface{}) error }) (models.ReplaySession, error) {
+	var s models.ReplaySession
+	var cookies, headersJSON sql.NullString
+	if err := scanner.Scan(&s.ID, &s.TargetID, &s.Name, &cookies, &headersJSON, &s.SourceLogID, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return s, err
+	}
+	s.Cookies = cookies.String
+	s.Headers = make(map[string]string)
+	if headersJSON.Valid && headersJSON.String != "" {
+		if err := json.Unmarshal([]byte(headersJSON.String), &s.Headers); err != nil {
+			logger.Warn("scanReplaySession: Could not parse headers for session %d: %v", s.ID, err)
+		}
+	}
+	return s, nil
+}
+
+// CreateReplaySession stores a new replay session and returns its ID.
+func CreateReplaySession(s models.ReplaySession) (int64, error) {
+	if strings.TrimSpace(s.Name) == "" {
+		return 0, fmt.Errorf("session name is required")
+	}
+	headersJSON, err := json.Marshal(s.Headers)
+	if err != nil {
+		return 0, fmt.Errorf("marshalling session headers: %w", err)
+	}
+	result, err := DB.Exec(`INSERT INTO replay_sessions (target_id, name, cookies, headers, source_log_id) VALUES (?, ?, ?, ?, ?)`,
+		s.TargetID, s.Name, models.NullString(s.Cookies), string(headersJSON), s.SourceLogID)
+	if err != nil {
+		return 0, fmt.Errorf("inserting replay session: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// CreateReplaySessionFromLog builds a replay session from the Cookie and auth-related
+// headers of a logged request.
+func CreateReplaySessionFromLog(logID int64, name string) (models.ReplaySession, error) {
+	var targetID sql.NullInt64
+	var headersJSON sql.NullString
+	err := DB.QueryRow(`SELECT target_id, request_headers FROM http_traffic_log WHERE id = ?`, logID).Scan(&targetID, &headersJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ReplaySession{}, fmt.Errorf("HTTP traffic log entry with ID %d not found", logID)
+		}
+		return models.ReplaySession{}, fmt.Errorf("fetching http_traffic_log %d: %w", logID, err)
+	}
+
+	logged := http.Header{}
+	if headersJSON.Valid && headersJSON.String != "" {
+		if err := json.Unmarshal([]byte(headersJSON.String), &logged); err != nil {
+			return models.ReplaySession{}, fmt.Errorf("parsing request headers of log %d: %w", logID, err)
+		}
+	}
+
+	session := models.ReplaySession{
+		TargetID:    targetID,
+		Name:        name,
+		Cookies:     strings.Join(logged.Values("Cookie"), "; "),
+		Headers:     make(map[string]string),
+		SourceLogID: sql.NullInt64{Int64: logID, Valid: true},
+	}
+	if session.Name == "" {
+		session.Name = fmt.Sprintf("Session from Log %d", logID)
+	}
+	for _, h := range replaySessionHeaderNames {
+		if v := logged.Get(h); v != "" {
+			session.Headers[h] = v
+		}
+	}
+
+	id, err := CreateReplaySession(session)
+	if err != nil {
+		return session, err
+	}
+	return GetReplaySessionByID(id)
+}
+
+// GetReplaySessionByID fetches a single replay session.
+func GetReplaySessionByID(id int64) (models.ReplaySession, error) {
+	row := DB.QueryRow(`SELECT id, target_id, name, cookies, headers, source_log_id, created_at, updated_at FROM replay_sessions WHERE id = ?`, id)
+	s, err := scanReplaySession(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, fmt.Errorf("replay session with ID %d not found", id)
+		}
+		return s, fmt.Errorf("scanning replay session %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// GetReplaySessions lists replay sessions, optionally limited to one target.
+func GetReplaySessions(targetID int64) ([]models.ReplaySession, error) {
+	query := `SELECT id, target_id, name, cookies, headers, source_log_id, created_at, updated_at FROM replay_sessions`
+	var args []interface{}
+	if targetID != 0 {
+		query += " WHERE target_id = ?"
+		args = append(args, targetID)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying replay sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.ReplaySession{}
+	for rows.Next() {
+		s, err := scanReplaySession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning replay session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// UpdateReplaySessionCookies replaces the Cookie header value of a replay session.
+func UpdateReplaySessionCookies(id int64, cookies string) error {
+	result, err := DB.Exec(`UPDATE replay_sessions SET cookies = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, models.NullString(cookies), id)
+	if err != nil {
+		return fmt.Errorf("updating cookies of replay session %d: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("replay session with ID %d not found", id)
+	}
+	return nil
+}
+
+// UpdateReplaySessionCredentials replaces the Cookie header value and the headers of a replay
+// session.
+func UpdateReplaySessionCredentials(id int64, cookies string, headers map[string]string) error {
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("marshalling session headers: %w", err)
+	}
+	result, err := DB.Exec(`UPDATE replay_sessions SET cookies = ?, headers = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		models.NullString(cookies), string(headersJSON), id)
+	if err != nil {
+		return fmt.Errorf("updating credentials of replay session %d: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("replay session with ID %d not found", id)
+	}
+	return nil
+}
+
+// DeleteReplaySession removes a replay session. Batches that used it keep their history.
+func DeleteReplaySession(id int64) error {
+	result, err := DB.Exec(`DELETE FROM replay_sessions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting replay session %d: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("replay session with ID %d not found", id)
+	}
+	return nil
+}
+
+// CreateReplayBatch resolves the requested log entries and discovered URLs into batch items
+// and stores the batch in the 'queued' state.
+func CreateReplayBatch(req models.CreateReplayBatchRequest) (models.ReplayBatchDetail, error) {
+	var detail models.ReplayBatchDetail
+	if len(req.LogIDs) == 0 && len(req.DiscoveredURLIDs) == 0 && len(req.Requests) == 0 {
+		return detail, fmt.Errorf("no log_ids or discovered_url_ids provided")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return detail, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var items []models.ReplayBatchItem
+	for _, logID := range req.LogIDs {
+		var method, reqURL, fullURL sql.NullString
+		err := tx.QueryRow(`SELECT request_method, request_url, request_full_url_with_fragment FROM http_traffic_log WHERE id = ?`, logID).Scan(&method, &reqURL, &fullURL)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return detail, fmt.Errorf("HTTP traffic log entry with ID %d not found", logID)
+			}
+			return detail, fmt.Errorf("fetching http_traffic_log %d: %w", logID, err)
+		}
+		u := reqURL.String
+		if fullURL.Valid && fullURL.String != "" {
+			u = fullURL.String
+		}
+		m := method.String
+		if m == "" {
+			m = http.MethodGet
+		}
+		items = append(items, models.ReplayBatchItem{
+			SourceLogID:   sql.NullInt64{Int64: logID, Valid: true},
+			RequestMethod: m,
+			RequestURL:    u,
+		})
+	}
+	for _, duID := range req.DiscoveredURLIDs {
+		var u string
+		err := tx.QueryRow(`SELECT url FROM discovered_urls WHERE id = ?`, duID).Scan(&u)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return detail, fmt.Errorf("discovered URL with ID %d not found", duID)
+			}
+			return detail, fmt.Errorf("fetching discovered_url %d: %w", duID, err)
+		}
+		items = append(items, models.ReplayBatchItem{
+			SourceDiscoveredURLID: sql.NullInt64{Int64: duID, Valid: true},
+			RequestMethod:         http.MethodGet,
+			RequestURL:            u,
+		})
+	}
+
+	for _, r := range req.Requests {
+		items = append(items, models.ReplayBatchItem{SourceLogID: r.SourceLogID, RequestMethod: r.RequestMethod, RequestURL: r.RequestURL})
+	}
+
+	var baseURL sql.NullString
+	if req.BaseURL != "" {
+		base, err := parseReplayBaseURL(req.BaseURL)
+		if err != nil {
+			return detail, err
+		}
+		for i := range items {
+			if items[i].RequestURL, err = rebaseReplayURL(items[i].RequestURL, base); err != nil {
+				return detail, err
+			}
+		}
+		baseURL = models.NullString(base.String())
+	}
+
+	var targetID, sessionID, pageID sql.NullInt64
+	if req.TargetID != 0 {
+		targetID = sql.NullInt64{Int64: req.TargetID, Valid: true}
+	}
+	if req.SessionID != 0 {
+		sessionID = sql.NullInt64{Int64: req.SessionID, Valid: true}
+	}
+	if req.PageID != 0 {
+		pageID = sql.NullInt64{Int64: req.PageID, Valid: true}
+	}
+	if req.DelayMs < 0 {
+		req.DelayMs = 0
+	}
+
+	result, err := tx.Exec(`INSERT INTO replay_batches (target_id, session_id, name, status, total_items, delay_ms, shuffle, page_id, preserve_timing, carry_cookies, base_url)
+		VALUES (?, ?, ?, 'queued', ?, ?, ?, ?, ?, ?, ?)`,
+		targetID, sessionID, models.NullString(req.Name), len(items), req.DelayMs, req.Shuffle, pageID, req.PreserveTiming, req.CarryCookies, baseURL)
+	if err != nil {
+		return detail, fmt.Errorf("inserting replay batch: %w", err)
+	}
+	batchID, err := result.LastInsertId()
+	if err != nil {
+		return detail, fmt.Errorf("getting replay batch id: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO replay_batch_items (batch_id, source_log_id, source_discovered_url_id, request_method, request_url) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return detail, fmt.Errorf("preparing replay batch item insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, item := range items {
+		if _, err := stmt.Exec(batchID, item.SourceLogID, item.SourceDiscoveredURLID, item.RequestMethod, item.RequestURL); err != nil {
+			return detail, fmt.Errorf("inserting replay batch item for %s: %w", item.RequestURL, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return detail, fmt.Errorf("committing replay batch: %w", err)
+	}
+	logger.Info("CreateReplayBatch: Created batch %d with %d items", batchID, len(items))
+	return GetReplayBatchDetail(batchID)
+}
+
+// parseReplayBaseURL parses the base URL of an environment comparison: scheme, host and an
+// optional path prefix.
+func parseReplayBaseURL(raw string) (*url.URL, error) {
+	base, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid base_url '%s': expected http(s)://host[:port][/prefix]", raw)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	base.RawPath, base.RawQuery, base.Fragment = "", "", ""
+	return base, nil
+}
+
+// rebaseReplayURL moves a captured URL to the scheme and host of base, under its path prefix,
+// keeping the path, query and fragment.
+func rebaseReplayURL(raw string, base *url.URL) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("parsing replay URL '%s': %w", raw, err)
+	}
+	u.Scheme, u.Host, u.User = base.Scheme, base.Host, nil
+	if base.Path != "" {
+		u.Path = base.Path + "/" + strings.TrimPrefix(u.Path, "/")
+		if u.RawPath != "" {
+			u.RawPath = base.EscapedPath() + "/" + strings.TrimPrefix(u.RawPath, "/")
+		}
+	}
+	return u.String(), nil
+}
+
+const replayBatchColumns = `id, target_id, session_id, name, status, total_items, completed_items, failed_items, delay_ms, shuffle,
+	page_id, preserve_timing, carry_cookies, base_url, last_error, created_at, started_at, finished_at`
+
+func scanReplayBatch(scanner interface{ Scan(...interface{}) error }) (models.ReplayBatch, error) {
+	var b models.ReplayBatch
+	err := scanner.Scan(&b.ID, &b.TargetID, &b.SessionID, &b.Name, &b.Status, &b.TotalItems, &b.CompletedItems, &b.FailedItems,
+		&b.DelayMs, &b.Shuffle, &b.PageID, &b.PreserveTiming, &b.CarryCookies, &b.BaseURL, &b.LastError, &b.CreatedAt, &b.StartedAt, &b.FinishedAt)
+	return b, err
+}
+
+// GetReplayBatches lists replay batches, newest first, optionally limited to one target and/or to
+// the flow replays of one page.
+func GetReplayBatches(targetID int64, pageID int64) ([]models.ReplayBatch, error) {
+	query := `SELECT ` + replayBatchColumns + ` FROM replay_batches`
+	var conditions []string
+	var args []interface{}
+	if targetID != 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, targetID)
+	}
+	if pageID != 0 {
+		conditions = append(conditions, "page_id = ?")
+		args = append(args, pageID)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying replay batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []models.ReplayBatch{}
+	for rows.Next() {
+		b, err := scanReplayBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning replay batch: %w", err)
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// GetReplayBatchDetail fetches a batch and its items.
+func GetReplayBatchDetail(batchID int64) (models.ReplayBatchDetail, error) {
+	var detail models.ReplayBatchDetail
+	b, err := scanReplayBatch(DB.QueryRow(`SELECT `+replayBatchColumns+` FROM replay_batches WHERE id = ?`, batchID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return detail, fmt.Errorf("replay batch with ID %d not found", batchID)
+		}
+		return detail, fmt.Errorf("scanning replay batch %d: %w", batchID, err)
+	}
+	detail.ReplayBatch = b
+
+	rows, err := DB.Query(`SELECT id, batch_id, source_log_id, source_discovered_url_id, request_method, request_url, status, response_status_code, error, executed_at
+		FROM replay_batch_items WHERE batch_id = ? ORDER BY id ASC`, batchID)
+	if err != nil {
+		return detail, fmt.Errorf("querying replay batch items for batch %d: %w", batchID, err)
+	}
+	defer rows.Close()
+
+	detail.Items = []models.ReplayBatchItem{}
+	for rows.Next() {
+		var it models.ReplayBatchItem
+		if err := rows.Scan(&it.ID, &it.BatchID, &it.SourceLogID, &it.SourceDiscoveredURLID, &it.RequestMethod, &it.RequestURL,
+			&it.Status, &it.ResponseStatusCode, &it.Error, &it.ExecutedAt); err != nil {
+			return detail, fmt.Errorf("scanning replay batch item: %w", err)
+		}
+		detail.Items = append(detail.Items, it)
+	}
+	return detail, rows.Err()
+}
+
+// GetReplayBatchSourceTimestamps returns, per item ID, when the log entry an item replays was
+// recorded. Items without a source log entry are left out.
+func GetReplayBatchSourceTimestamps(batchID int64) (map[int64]time.Time, error) {
+	rows, err := DB.Query(`SELECT rbi.id, htl.timestamp FROM replay_batch_items rbi
+		JOIN http_traffic_log htl ON htl.id = rbi.source_log_id
+		WHERE rbi.batch_id = ?`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("querying source timestamps for replay batch %d: %w", batchID, err)
+	}
+	defer rows.Close()
+
+	timestamps := make(map[int64]time.Time)
+	for rows.Next() {
+		var itemID int64
+		var ts time.Time
+		if err := rows.Scan(&itemID, &ts); err != nil {
+			return nil, fmt.Errorf("scanning source timestamp for replay batch %d: %w", batchID, err)
+		}
+		timestamps[itemID] = ts
+	}
+	return timestamps, rows.Err()
+}
+
+// UpdateReplayBatchStatus sets the batch status, stamping started_at/finished_at as appropriate.
+func UpdateReplayBatchStatus(batchID int64, status string, lastError string) error {
+	query := `UPDATE replay_batches SET status = ?, last_error = ?`
+	switch status {
+	case "running":
+		query += ", started_at = CURRENT_TIMESTAMP"
+	case "completed", "failed", "cancelled":
+		query += ", finished_at = CURRENT_TIMESTAMP"
+	}
+	query += " WHERE id = ?"
+	if _, err := DB.Exec(query, status, models.NullString(lastError), batchID); err != nil {
+		return fmt.Errorf("updating status of replay batch %d: %w", batchID, err)
+	}
+	return nil
+}
+
+// RecordReplayBatchItemResult stores the outcome of one replayed item and bumps the batch counters.
+func RecordReplayBatchItemResult(itemID int64, batchID int64, statusCode int, itemErr error) error {
+	status := "completed"
+	var errText sql.NullString
+	var code sql.NullInt64
+	if itemErr != nil {
+		status = "failed"
+		errText = models.NullString(itemErr.Error())
+	} else {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE replay_batch_items SET status = ?, response_status_code = ?, error = ?, executed_at = ? WHERE id = ?`,
+		status, code, errText, time.Now(), itemID); err != nil {
+		return fmt.Errorf("updating replay batch item %d: %w", itemID, err)
+	}
+	counter := "completed_items"
+	if itemErr != nil {
+		counter = "failed_items"
+	}
+	if _, err := tx.Exec(`UPDATE replay_batches SET `+counter+` = `+counter+` + 1 WHERE id = ?`, batchID); err != nil {
+		return fmt.Errorf("updating counters of replay batch %d: %w", batchID, err)
+	}
+	return tx.Commit()
+}
+
+// GetHTTPTrafficLogsByReplayBatch returns summaries of the log entries produced by a replay batch.
+func GetHTTPTrafficLogsByReplayBatch(batchID int64) ([]models.HTTPTrafficLog, error) {
+	rows, err := DB.Query(`SELECT id, target_id, timestamp, request_method, request_url, response_status_code, response_content_type,
+		response_body_size, duration_ms, log_source, replay_batch_id, replay_source_log_id, request_full_url_with_fragment
+		FROM http_traffic_log WHERE replay_batch_id = ? ORDER BY id ASC`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("querying logs for replay batch %d: %w", batchID, err)
+	}
+	defer rows.Close()
+
+	logs := []models.HTTPTrafficLog{}
+	for rows.Next() {
+		var l models.HTTPTrafficLog
+		var contentType sql.NullString
+		var statusCode sql.NullInt64
+		var bodySize, duration sql.NullInt64
+		if err := rows.Scan(&l.ID, &l.TargetID, &l.Timestamp, &l.RequestMethod, &l.RequestURL, &statusCode, &contentType,
+			&bodySize, &duration, &l.LogSource, &l.ReplayBatchID, &l.ReplaySourceLogID, &l.RequestFullURLWithFragment); err != nil {
+			return nil, fmt.Errorf("scanning replay batch log: %w", err)
+		}
+		l.ResponseStatusCode = int(statusCode.Int64)
+		l.ResponseContentType = contentType
+		l.ResponseBodySize = bodySize.Int64
+		l.DurationMs = duration.Int64
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// ListTargetCapturedRequests returns the method and URL of the user's captured requests of a
+// target, newest first, leaving out replays. host limits them to one host; limit 0 means no limit.
+func ListTargetCapturedRequests(targetID int64, host string, limit int) ([]models.HTTPTrafficLog, error) {
+	condition, _ := TrafficOriginCondition("log_source", models.TrafficOriginManual)
+	query := `SELECT id, request_method, request_url, response_status_code FROM http_traffic_log
+		WHERE target_id = ? AND replay_batch_id IS NULL AND ` + condition
+	args := []interface{}{targetID}
+	if host != "" {
+		query += ` AND (request_url LIKE ? OR request_url LIKE ? OR request_url LIKE ? OR request_url LIKE ?)`
+		args = append(args, "%://"+host, "%://"+host+"/%", "%://"+host+"?%", "%://"+host+":%")
+	}
+	query += ` ORDER BY id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying captured requests of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	logs := []models.HTTPTrafficLog{}
+	for rows.Next() {
+		var l models.HTTPTrafficLog
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&l.ID, &l.RequestMethod, &l.RequestURL, &statusCode); err != nil {
+			return nil, fmt.Errorf("scanning captured request: %w", err)
+		}
+		l.ResponseStatusCode = int(statusCode.Int64)
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
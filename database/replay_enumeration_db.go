@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"toolkit/models"
+)
+
+const replayEnumerationColumns = `id, target_id, name, method, url_template, source_log_id, parameters, requests, created_at`
+
+func scanReplayEnumeration(scanner interface{ Scan(...interface{}) error }) (models.ReplayEnumeration, error) {
+	var e models.ReplayEnumeration
+	var parameters, requests string
+	if err := scanner.Scan(&e.ID, &e.TargetID, &e.Name, &e.Method, &e.URLTemplate, &e.SourceLogID, &parameters, &requests, &e.CreatedAt); err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal([]byte(parameters), &e.Parameters); err != nil {
+		return e, fmt.Errorf("parsing parameters of replay enumeration %d: %w", e.ID, err)
+	}
+	if err := json.Unmarshal([]byte(requests), &e.Requests); err != nil {
+		return e, fmt.Errorf("parsing requests of replay enumeration %d: %w", e.ID, err)
+	}
+	return e, nil
+}
+
+// CreateReplayEnumeration stores the requests generated from a URL template together with the
+// replay batches sending them, and returns its ID.
+func CreateReplayEnumeration(e models.ReplayEnumeration) (int64, error) {
+	parameters, err := json.Marshal(e.Parameters)
+	if err != nil {
+		return 0, fmt.Errorf("encoding parameters: %w", err)
+	}
+	requests, err := json.Marshal(e.Requests)
+	if err != nil {
+		return 0, fmt.Errorf("encoding requests: %w", err)
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO replay_enumerations (target_id, name, method, url_template, source_log_id, parameters, requests)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, e.TargetID, e.Name, e.Method, e.URLTemplate, e.SourceLogID, string(parameters), string(requests))
+	if err != nil {
+		return 0, fmt.Errorf("inserting replay enumeration: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("getting replay enumeration id: %w", err)
+	}
+	for _, batchID := range e.BatchIDs {
+		if _, err := tx.Exec(`INSERT INTO replay_enumeration_batches (enumeration_id, batch_id) VALUES (?, ?)`, id, batchID); err != nil {
+			return 0, fmt.Errorf("linking replay batch %d to enumeration %d: %w", batchID, id, err)
+		}
+	}
+	return id, tx.Commit()
+}
+
+// GetReplayEnumeration fetches a replay enumeration with the IDs of its batches.
+func GetReplayEnumeration(id int64) (models.ReplayEnumeration, error) {
+	e, err := scanReplayEnumeration(DB.QueryRow(`SELECT `+replayEnumerationColumns+` FROM replay_enumerations WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return e, fmt.Errorf("replay enumeration %d not found", id)
+	}
+	if err != nil {
+		return e, fmt.Errorf("scanning replay enumeration %d: %w", id, err)
+	}
+	batchIDs, err := getReplayEnumerationBatchIDs([]int64{id})
+	if err != nil {
+		return e, err
+	}
+	e.BatchIDs = batchIDs[id]
+	return e, nil
+}
+
+// GetReplayEnumerations lists the replay enumerations of a target, newest first, without their
+// requests.
+func GetReplayEnumerations(targetID int64) ([]models.ReplayEnumeration, error) {
+	rows, err := DB.Query(`SELECT `+replayEnumerationColumns+` FROM replay_enumerations WHERE target_id = ? ORDER BY id DESC`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying replay enumerations of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	enumerations := []models.ReplayEnumeration{}
+	var ids []int64
+	for rows.Next() {
+		e, err := scanReplayEnumeration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning replay enumeration: %w", err)
+		}
+		e.Requests = nil
+		enumerations = append(enumerations, e)
+		ids = append(ids, e.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	batchIDs, err := getReplayEnumerationBatchIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range enumerations {
+		enumerations[i].BatchIDs = batchIDs[enumerations[i].ID]
+	}
+	return enumerations, nil
+}
+
+// getReplayEnumerationBatchIDs returns the batch IDs of replay enumerations, by enumeration.
+func getReplayEnumerationBatchIDs(ids []int64) (map[int64][]int64, error) {
+	batchIDs := map[int64][]int64{}
+	for _, id := range ids {
+		batchIDs[id] = []int64{}
+	}
+	if len(ids) == 0 {
+		return batchIDs, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := DB.Query(`SELECT enumeration_id, batch_id FROM replay_enumeration_batches
+		WHERE enumeration_id IN (`+placeholders(len(ids))+`) ORDER BY batch_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying replay enumeration batches: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var enumerationID, batchID int64
+		if err := rows.Scan(&enumerationID, &batchID); err != nil {
+			return nil, fmt.Errorf("scanning replay enumeration batch: %w", err)
+		}
+		batchIDs[enumerationID] = append(batchIDs[enumerationID], batchID)
+	}
+	return batchIDs, rows.Err()
+}
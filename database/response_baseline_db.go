@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+// SaveResponseBaseline stores the soft-404/wildcard signatures of a domain, replacing the previous ones.
+func SaveResponseBaseline(b models.ResponseBaseline) error {
+	signatures := b.Signatures
+	if signatures == nil {
+		signatures = []models.ResponseSignature{}
+	}
+	signaturesJSON, err := json.Marshal(signatures)
+	if err != nil {
+		return fmt.Errorf("encoding signatures of domain %d: %w", b.DomainID, err)
+	}
+	_, err = DB.Exec(`INSERT INTO response_baselines (domain_id, target_id, base_url, is_wildcard, signatures, responses_clustered, last_error, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(domain_id) DO UPDATE SET base_url = excluded.base_url, is_wildcard = excluded.is_wildcard,
+			signatures = excluded.signatures, responses_clustered = excluded.responses_clustered,
+			last_error = excluded.last_error, updated_at = CURRENT_TIMESTAMP`,
+		b.DomainID, b.TargetID, b.BaseURL, b.IsWildcard, string(signaturesJSON), b.ResponsesClustered, b.LastError)
+	if err != nil {
+		return fmt.Errorf("saving response baseline of domain %d: %w", b.DomainID, err)
+	}
+	return nil
+}
+
+// GetResponseBaseline returns the soft-404/wildcard signatures of a domain. found is false when
+// the domain has not been baselined.
+func GetResponseBaseline(domainID int64) (b models.ResponseBaseline, found bool, err error) {
+	var signatures string
+	err = DB.QueryRow(`SELECT domain_id, target_id, base_url, is_wildcard, signatures, responses_clustered, last_error, updated_at
+		FROM response_baselines WHERE domain_id = ?`, domainID).
+		Scan(&b.DomainID, &b.TargetID, &b.BaseURL, &b.IsWildcard, &signatures, &b.ResponsesClustered, &b.LastError, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+	if err != nil {
+		return b, false, fmt.Errorf("loading response baseline of domain %d: %w", domainID, err)
+	}
+	if err := json.Unmarshal([]byte(signatures), &b.Signatures); err != nil {
+		return b, false, fmt.Errorf("decoding signatures of domain %d: %w", domainID, err)
+	}
+	return b, true, nil
+}
+
+// GetResponseBaselineForHost returns the baseline of the target's domain named host.
+func GetResponseBaselineForHost(targetID int64, host string) (models.ResponseBaseline, bool, error) {
+	var domainID int64
+	err := DB.QueryRow(`SELECT id FROM domains WHERE target_id = ? AND domain_name = ?`, targetID, strings.ToLower(host)).Scan(&domainID)
+	if err == sql.ErrNoRows {
+		return models.ResponseBaseline{}, false, nil
+	}
+	if err != nil {
+		return models.ResponseBaseline{}, false, fmt.Errorf("looking up domain %s: %w", host, err)
+	}
+	return GetResponseBaseline(domainID)
+}
+
+// GetLoggedResponsesForHost returns up to limit logged GET responses under baseURL, newest first,
+// with the fields needed to fingerprint them.
+func GetLoggedResponsesForHost(targetID int64, baseURL string, limit int) ([]models.HTTPTrafficLog, error) {
+	like := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.TrimRight(baseURL, "/")) + "/%"
+	rows, err := DB.Query(`SELECT id, request_url, response_status_code, response_headers, `+logResponseBody+`, response_content_type
+		FROM http_traffic_log
+		WHERE target_id = ? AND request_method = 'GET' AND response_status_code > 0 AND request_url LIKE ? ESCAPE '\'
+		ORDER BY id DESC LIMIT ?`, targetID, like, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing logged responses of %s: %w", baseURL, err)
+	}
+	defer rows.Close()
+	var entries []models.HTTPTrafficLog
+	for rows.Next() {
+		var e models.HTTPTrafficLog
+		if err := rows.Scan(&e.ID, &e.RequestURL, &e.ResponseStatusCode, &e.ResponseHeaders, &e.ResponseBody, &e.ResponseContentType); err != nil {
+			return nil, fmt.Errorf("scanning logged response: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+// GetGlobalRuntimeSettings returns the runtime settings stored in app_settings, keyed by setting
+// key, with JSON encoded values.
+func GetGlobalRuntimeSettings() (map[string]string, error) {
+	rows, err := DB.Query("SELECT key, value FROM app_settings WHERE key LIKE ?", models.RuntimeSettingKeyPrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("querying runtime settings: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("scanning runtime setting: %w", err)
+		}
+		values[strings.TrimPrefix(key, models.RuntimeSettingKeyPrefix)] = value
+	}
+	return values, rows.Err()
+}
+
+// SetGlobalRuntimeSetting stores the JSON encoded value of a runtime setting.
+func SetGlobalRuntimeSetting(key, value string) error {
+	return SetSetting(models.RuntimeSettingKeyPrefix+key, value)
+}
+
+// DeleteGlobalRuntimeSetting removes the stored value of a runtime setting.
+func DeleteGlobalRuntimeSetting(key string) error {
+	if _, err := DB.Exec("DELETE FROM app_settings WHERE key = ?", models.RuntimeSettingKeyPrefix+key); err != nil {
+		return fmt.Errorf("deleting runtime setting '%s': %w", key, err)
+	}
+	return nil
+}
+
+// GetTargetRuntimeSettings returns the runtime setting overrides of a target, keyed by setting key,
+// with JSON encoded values.
+func GetTargetRuntimeSettings(targetID int64) (map[string]string, error) {
+	rows, err := DB.Query("SELECT key, value FROM target_runtime_settings WHERE target_id = ?", targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying runtime settings of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("scanning runtime setting of target %d: %w", targetID, err)
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+// SetTargetRuntimeSetting stores the JSON encoded value of a runtime setting override for a target.
+func SetTargetRuntimeSetting(targetID int64, key, value string) error {
+	_, err := DB.Exec(`INSERT INTO target_runtime_settings (target_id, key, value) VALUES (?, ?, ?)
+		ON CONFLICT(target_id, key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`, targetID, key, value)
+	if err != nil {
+		return fmt.Errorf("storing runtime setting '%s' of target %d: %w", key, targetID, err)
+	}
+	return nil
+}
+
+// DeleteTargetRuntimeSetting removes a runtime setting override of a target.
+func DeleteTargetRuntimeSetting(targetID int64, key string) error {
+	if _, err := DB.Exec("DELETE FROM target_runtime_settings WHERE target_id = ? AND key = ?", targetID, key); err != nil {
+		return fmt.Errorf("deleting runtime setting '%s' of target %d: %w", key, targetID, err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+// sanitizeStatements strip the copy of the database of what must not leave the machine: bodies,
+// secrets, credentials, mail and command output. Metadata (URLs, statuses, sizes, hashes, notes,
+// findings) stays, so the copy still shows how the toolkit behaves.
+var sanitizeStatements = []struct {
+	table, action, query string
+}{
+	{"http_traffic_log", "request and response bodies removed",
+		`UPDATE http_traffic_log SET request_body = NULL, response_body = NULL, request_body_blob_id = NULL, response_body_blob_id = NULL,
+		 response_beautified_id = NULL
+		 WHERE request_body IS NOT NULL OR response_body IS NOT NULL OR request_body_blob_id IS NOT NULL OR response_body_blob_id IS NOT NULL
+		 OR response_beautified_id IS NOT NULL`},
+	{"body_blobs", "deleted", `DELETE FROM body_blobs`},
+	{"beautified_bodies", "deleted", `DELETE FROM beautified_bodies`},
+	{"secrets", "matches masked", `UPDATE secrets SET match_excerpt = '[REDACTED]' WHERE match_excerpt != '[REDACTED]'`},
+	{"auth_tokens", "tokens masked", `UPDATE auth_tokens SET token = '[REDACTED]' WHERE token != '[REDACTED]'`},
+	{"target_cookies", "values masked", `UPDATE target_cookies SET value = '[REDACTED]' WHERE value != ''`},
+	{"target_variables", "values masked", `UPDATE target_variables SET value = '[REDACTED]' WHERE value != ''`},
+	{"replay_sessions", "cookies removed", `UPDATE replay_sessions SET cookies = NULL WHERE cookies IS NOT NULL`},
+	{"graphql_operations", "variables removed", `UPDATE graphql_operations SET variables = NULL WHERE variables IS NOT NULL`},
+	{"target_client_profiles", "headers and cookies removed", `UPDATE target_client_profiles SET headers = '{}', cookies = '' WHERE headers != '{}' OR cookies != ''`},
+	{"login_flows", "session headers removed", `UPDATE login_flows SET session_headers = '{}' WHERE session_headers != '{}'`},
+	{"login_flow_steps", "bodies removed", `UPDATE login_flow_steps SET body = NULL WHERE body IS NOT NULL`},
+	{"modifier_tasks", "bodies and raw requests removed",
+		`UPDATE modifier_tasks SET base_request_body = NULL, base_request_raw = NULL, original_request_body = NULL, original_response_body = NULL
+		 WHERE base_request_body IS NOT NULL OR base_request_raw IS NOT NULL OR original_request_body IS NOT NULL OR original_response_body IS NOT NULL`},
+	{"inbox_messages", "bodies, codes and links removed",
+		`UPDATE inbox_messages SET body_text = '', body_html = '', codes = '[]', links = '[]', magic_links = '[]'
+		 WHERE body_text != '' OR body_html != '' OR codes != '[]' OR links != '[]' OR magic_links != '[]'`},
+	{"checklist_command_runs", "output removed", `UPDATE checklist_command_runs SET output = NULL WHERE output IS NOT NULL`},
+	{"app_settings", "custom headers and mailbox state deleted", `DELETE FROM app_settings WHERE key IN ('` + models.CustomHTTPHeadersKey + `', 'inbox_imap_state')`},
+}
+
+// sanitizeHeaderColumns hold header sets kept with their credentials masked.
+var sanitizeHeaderColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"http_traffic_log", []string{"request_headers", "response_headers"}},
+	{"login_flow_steps", []string{"headers"}},
+	{"replay_sessions", []string{"headers"}},
+	{"modifier_tasks", []string{"base_request_headers", "original_request_headers", "original_response_headers"}},
+}
+
+// sanitizeURLColumns hold URLs kept with their credential parameters masked.
+var sanitizeURLColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"http_traffic_log", []string{"request_url", "request_full_url_with_fragment"}},
+	{"login_flow_steps", []string{"url"}},
+	{"modifier_tasks", []string{"base_request_url"}},
+	{"replay_batch_items", []string{"request_url"}},
+}
+
+// DatabaseFile returns the path of the open database file.
+func DatabaseFile() (string, error) {
+	var file string
+	if err := DB.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file); err != nil {
+		return "", fmt.Errorf("reading database file: %w", err)
+	}
+	return file, nil
+}
+
+// CopyDatabase writes a consistent copy of the database to path, which must not exist.
+func CopyDatabase(path string) error {
+	if _, err := DB.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("copying database to %s: %w", path, err)
+	}
+	return nil
+}
+
+// SanitizeDatabaseCopy strips a copy of the database (see CopyDatabase) of bodies and credentials.
+// Stored header sets are passed through headers and URLs through rawURL, which mask the credentials
+// they carry. The copy is vacuumed at the end, so nothing removed lingers in free pages.
+func SanitizeDatabaseCopy(path string, headers, rawURL func(string) string) ([]models.SanitizeStep, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=off")
+	if err != nil {
+		return nil, fmt.Errorf("opening copy %s: %w", path, err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var steps []models.SanitizeStep
+	for _, s := range sanitizeStatements {
+		res, err := tx.Exec(s.query)
+		if err != nil {
+			return nil, fmt.Errorf("sanitizing %s: %w", s.table, err)
+		}
+		rows, _ := res.RowsAffected()
+		steps = append(steps, models.SanitizeStep{Table: s.table, Action: s.action, Rows: rows})
+	}
+	for _, c := range sanitizeHeaderColumns {
+		rows, err := rewriteColumns(tx, c.table, c.columns, headers)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, models.SanitizeStep{Table: c.table, Action: "credential headers masked", Rows: rows})
+	}
+	for _, c := range sanitizeURLColumns {
+		rows, err := rewriteColumns(tx, c.table, c.columns, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, models.SanitizeStep{Table: c.table, Action: "credential URL parameters masked", Rows: rows})
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing sanitized copy: %w", err)
+	}
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return nil, fmt.Errorf("vacuuming sanitized copy: %w", err)
+	}
+	return steps, nil
+}
+
+// rewriteColumns passes the text columns of every row of a table through rewrite and returns the
+// number of rows changed.
+func rewriteColumns(tx *sql.Tx, table string, columns []string, rewrite func(string) string) (int64, error) {
+	query := "SELECT id"
+	for _, c := range columns {
+		query += ", " + c
+	}
+	rows, err := tx.Query(query + " FROM " + table)
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", table, err)
+	}
+	type change struct {
+		id     int64
+		values []interface{}
+	}
+	var changes []change
+	for rows.Next() {
+		var id int64
+		values := make([]sql.NullString, len(columns))
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("reading %s: %w", table, err)
+		}
+		changed := false
+		args := make([]interface{}, len(columns))
+		for i, v := range values {
+			args[i] = v
+			if v.Valid && v.String != "" {
+				if out := rewrite(v.String); out != v.String {
+					args[i], changed = out, true
+				}
+			}
+		}
+		if changed {
+			changes = append(changes, change{id, args})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading %s: %w", table, err)
+	}
+
+	update := "UPDATE " + table + " SET "
+	for i, c := range columns {
+		if i > 0 {
+			update += ", "
+		}
+		update += c + " = ?"
+	}
+	stmt, err := tx.Prepare(update + " WHERE id = ?")
+	if err != nil {
+		return 0, fmt.Errorf("preparing update of %s: %w", table, err)
+	}
+	defer stmt.Close()
+	for _, c := range changes {
+		if _, err := stmt.Exec(append(c.values, c.id)...); err != nil {
+			return 0, fmt.Errorf("updating %s %d: %w", table, c.id, err)
+		}
+	}
+	return int64(len(changes)), nil
+}
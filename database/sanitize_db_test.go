@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// withMigratedDB points DB at a database file in a temporary directory with every migration
+// applied, and returns the directory.
+func withMigratedDB(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "toolkit.db")+"?_foreign_keys=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = saved
+		db.Close()
+	})
+	files, err := filepath.Glob(filepath.Join("migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			t.Fatalf("applying %s: %v", f, err)
+		}
+	}
+	return dir
+}
+
+func TestSanitizeDatabaseCopyRemovesSessionsAndVariables(t *testing.T) {
+	dir := withMigratedDB(t)
+	if _, err := DB.Exec(`INSERT INTO replay_sessions (id, name, cookies, headers) VALUES
+			(1, 'admin', 'session=s3cr3t-cookie', '{"Authorization":"Bearer s3cr3t-token","Accept":"application/json"}');
+		INSERT INTO graphql_endpoints (id, url) VALUES (1, 'https://example.com/graphql');
+		INSERT INTO graphql_operations (graphql_endpoint_id, operation_name, query, query_hash, variables)
+			VALUES (1, 'Login', 'mutation Login($password: String!) { login(password: $password) }', 'h', '{"password":"s3cr3t-password"}')`); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sanitized.db")
+	if err := CopyDatabase(path); err != nil {
+		t.Fatal(err)
+	}
+	masked := func(headers string) string {
+		return strings.ReplaceAll(headers, "Bearer s3cr3t-token", "[REDACTED]")
+	}
+	steps, err := SanitizeDatabaseCopy(path, masked, func(rawURL string) string { return rawURL })
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := map[string]int64{}
+	for _, s := range steps {
+		changed[s.Table+": "+s.Action] += s.Rows
+	}
+	for _, step := range []string{"replay_sessions: cookies removed", "replay_sessions: credential headers masked", "graphql_operations: variables removed"} {
+		if changed[step] != 1 {
+			t.Errorf("step %q changed %d rows, want 1 (steps %+v)", step, changed[step], steps)
+		}
+	}
+
+	copyDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copyDB.Close()
+	var cookies, variables sql.NullString
+	var headers string
+	if err := copyDB.QueryRow(`SELECT cookies, headers FROM replay_sessions WHERE id = 1`).Scan(&cookies, &headers); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyDB.QueryRow(`SELECT variables FROM graphql_operations`).Scan(&variables); err != nil {
+		t.Fatal(err)
+	}
+	if cookies.Valid || variables.Valid || strings.Contains(headers, "s3cr3t") || !strings.Contains(headers, "application/json") {
+		t.Errorf("sanitized copy kept cookies %q, variables %q, headers %q", cookies.String, variables.String, headers)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Error("the sanitized file still holds a secret in its pages")
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const savedViewColumns = `id, target_id, view_type, name, filters, created_at, updated_at`
+
+func scanSavedView(scanner interface{ Scan(...interface{}) error }) (models.SavedView, error) {
+	var v models.SavedView
+	var filtersJSON string
+	if err := scanner.Scan(&v.ID, &v.TargetID, &v.ViewType, &v.Name, &filtersJSON, &v.CreatedAt, &v.UpdatedAt); err != nil {
+		return v, err
+	}
+	v.Filters = make(map[string]string)
+	if filtersJSON != "" {
+		if err := json.Unmarshal([]byte(filtersJSON), &v.Filters); err != nil {
+			logger.Warn("scanSavedView: Could not parse filters for view %d: %v", v.ID, err)
+		}
+	}
+	return v, nil
+}
+
+// CreateSavedView stores a new saved view and returns its ID.
+func CreateSavedView(v models.SavedView) (int64, error) {
+	filtersJSON, err := json.Marshal(v.Filters)
+	if err != nil {
+		return 0, fmt.Errorf("marshalling view filters: %w", err)
+	}
+	res, err := DB.Exec(`INSERT INTO saved_views (target_id, view_type, name, filters) VALUES (?, ?, ?, ?)`,
+		v.TargetID, v.ViewType, v.Name, string(filtersJSON))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("a %s view named '%s' already exists", v.ViewType, v.Name)
+		}
+		return 0, fmt.Errorf("inserting saved view: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// UpdateSavedView replaces the name and filters of a saved view.
+func UpdateSavedView(v models.SavedView) error {
+	filtersJSON, err := json.Marshal(v.Filters)
+	if err != nil {
+		return fmt.Errorf("marshalling view filters: %w", err)
+	}
+	res, err := DB.Exec(`UPDATE saved_views SET name = ?, filters = ? WHERE id = ?`, v.Name, string(filtersJSON), v.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("a %s view named '%s' already exists", v.ViewType, v.Name)
+		}
+		return fmt.Errorf("updating saved view %d: %w", v.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("saved view with ID %d not found", v.ID)
+	}
+	return nil
+}
+
+// GetSavedViewByID fetches a saved view.
+func GetSavedViewByID(id int64) (models.SavedView, error) {
+	v, err := scanSavedView(DB.QueryRow(`SELECT `+savedViewColumns+` FROM saved_views WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, fmt.Errorf("saved view with ID %d not found", id)
+		}
+		return v, fmt.Errorf("scanning saved view %d: %w", id, err)
+	}
+	return v, nil
+}
+
+// FindSavedView looks up a view by type and name. A view saved for the target takes precedence
+// over a global view with the same name; targetID 0 only matches global views.
+func FindSavedView(targetID int64, viewType, name string) (models.SavedView, error) {
+	v, err := scanSavedView(DB.QueryRow(`SELECT `+savedViewColumns+` FROM saved_views
+		WHERE view_type = ? AND name = ? AND (target_id = ? OR target_id IS NULL)
+		ORDER BY target_id IS NULL LIMIT 1`, viewType, name, targetID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, fmt.Errorf("saved %s view '%s' not found", viewType, name)
+		}
+		return v, fmt.Errorf("scanning saved view '%s': %w", name, err)
+	}
+	return v, nil
+}
+
+// GetSavedViews lists saved views for a target (including global views) and optional type.
+// targetID 0 lists the views of all targets.
+func GetSavedViews(targetID int64, viewType string) ([]models.SavedView, error) {
+	var conditions []string
+	var args []interface{}
+	if targetID != 0 {
+		conditions = append(conditions, "(target_id = ? OR target_id IS NULL)")
+		args = append(args, targetID)
+	}
+	if viewType != "" {
+		conditions = append(conditions, "view_type = ?")
+		args = append(args, viewType)
+	}
+	query := `SELECT ` + savedViewColumns + ` FROM saved_views`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY view_type, LOWER(name)"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying saved views: %w", err)
+	}
+	defer rows.Close()
+
+	views := []models.SavedView{}
+	for rows.Next() {
+		v, err := scanSavedView(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning saved view: %w", err)
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// DeleteSavedView removes a saved view.
+func DeleteSavedView(id int64) error {
+	res, err := DB.Exec(`DELETE FROM saved_views WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting saved view %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("saved view with ID %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+const secretSelectColumns = `s.id, s.target_id, s.http_traffic_log_id, s.rule_name, s.match_excerpt, s.secret_hash, s.entropy,
+	s.location, s.status, s.notes, htl.request_url, s.created_at, s.updated_at`
+
+func scanSecret(scanner interface{ Scan(...interface{}) error }) (models.Secret, error) {
+	var s models.Secret
+	err := scanner.Scan(&s.ID, &s.TargetID, &s.HTTPTrafficLogID, &s.RuleName, &s.MatchExcerpt, &s.SecretHash, &s.Entropy,
+		&s.Location, &s.Status, &s.Notes, &s.RequestURL, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// InsertSecrets stores detected secrets, ignoring ones already recorded for the same log entry.
+// It returns the number of rows actually inserted.
+func InsertSecrets(secrets []models.Secret) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO secrets (target_id, http_traffic_log_id, rule_name, match_excerpt, secret_hash, entropy, location, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing secret insert: %w", err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, s := range secrets {
+		status := s.Status
+		if status == "" {
+			status = models.SecretStatusNew
+		}
+		res, err := stmt.Exec(s.TargetID, s.HTTPTrafficLogID, s.RuleName, s.MatchExcerpt, s.SecretHash, s.Entropy, s.Location, status)
+		if err != nil {
+			return 0, fmt.Errorf("inserting secret (%s) for log %d: %w", s.RuleName, s.HTTPTrafficLogID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing secrets: %w", err)
+	}
+	return inserted, nil
+}
+
+// GetSecrets lists detected secrets with optional filters and pagination, newest first.
+func GetSecrets(filters models.SecretFilters) ([]models.Secret, int64, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "s.target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.Status != "" {
+		conditions = append(conditions, "s.status = ?")
+		args = append(args, filters.Status)
+	}
+	if filters.RuleName != "" {
+		conditions = append(conditions, "s.rule_name = ?")
+		args = append(args, filters.RuleName)
+	}
+	if filters.LogID != 0 {
+		conditions = append(conditions, "s.http_traffic_log_id = ?")
+		args = append(args, filters.LogID)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := DB.QueryRow("SELECT COUNT(*) FROM secrets s"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting secrets: %w", err)
+	}
+
+	if filters.Limit <= 0 {
+		filters.Limit = 50
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	query := `SELECT ` + secretSelectColumns + ` FROM secrets s LEFT JOIN http_traffic_log htl ON s.http_traffic_log_id = htl.id` +
+		where + ` ORDER BY s.id DESC LIMIT ? OFFSET ?`
+	queryArgs := append(args, filters.Limit, (filters.Page-1)*filters.Limit)
+
+	rows, err := DB.Query(query, queryArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying secrets: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := []models.Secret{}
+	for rows.Next() {
+		s, err := scanSecret(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning secret: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, total, rows.Err()
+}
+
+// GetSecretByID fetches a single secret.
+func GetSecretByID(id int64) (models.Secret, error) {
+	s, err := scanSecret(DB.QueryRow(`SELECT `+secretSelectColumns+` FROM secrets s LEFT JOIN http_traffic_log htl ON s.http_traffic_log_id = htl.id WHERE s.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, fmt.Errorf("secret with ID %d not found", id)
+		}
+		return s, fmt.Errorf("scanning secret %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// UpdateSecretStatus records the triage result for a secret. When markAllWithHash is set the
+// status is applied to every secret with the same value (e.g. the same key seen in many JS files).
+func UpdateSecretStatus(id int64, status string, notes *string, markAllWithHash bool) (int64, error) {
+	switch status {
+	case models.SecretStatusNew, models.SecretStatusConfirmed, models.SecretStatusFalsePositive:
+	default:
+		return 0, fmt.Errorf("invalid status '%s'", status)
+	}
+
+	secret, err := GetSecretByID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "UPDATE secrets SET status = ?"
+	args := []interface{}{status}
+	if notes != nil {
+		query += ", notes = ?"
+		args = append(args, models.NullString(*notes))
+	}
+	if markAllWithHash {
+		query += " WHERE secret_hash = ?"
+		args = append(args, secret.SecretHash)
+	} else {
+		query += " WHERE id = ?"
+		args = append(args, id)
+	}
+
+	res, err := DB.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("updating status of secret %d: %w", id, err)
+	}
+	rows, _ := res.RowsAffected()
+	logger.Info("UpdateSecretStatus: Set status '%s' on %d secret(s) (source ID %d)", status, rows, id)
+	return rows, nil
+}
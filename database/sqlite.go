@@ -498,61 +498,45 @@ func GetChecklistItemsByTargetID(targetID int64) ([]models.TargetChecklistItem,
 	return items, nil
 }
 
-// GetChecklistItemsByTargetIDPaginated retrieves a paginated, sorted, and filtered list of checklist items for a target.
-// It returns the items, total records matching filters, total completed records matching filters, and an error.
-func GetChecklistItemsByTargetIDPaginated(targetID int64, limit int, offset int, sortBy string, sortOrder string, filter string, showIncompleteOnly bool) ([]models.TargetChecklistItem, int64, int64, error) {
-	var items []models.TargetChecklistItem
-	var totalCompletedRecordsForFilter int64
-	var totalRecords int64
-
-	// Validate sortBy column to prevent SQL injection
-	allowedSortColumns := map[string]string{
+// ChecklistSort lists the columns checklist items can be sorted by; text columns sort
+// case-insensitively.
+var ChecklistSort = SortSpec{
+	Columns: map[string]string{
 		"id":                "id",
-		"item_text":         "LOWER(item_text)", // Sort case-insensitively
+		"item_text":         "LOWER(item_text)",
 		"item_command_text": "LOWER(item_command_text)",
 		"notes":             "LOWER(notes)",
 		"is_completed":      "is_completed",
 		"created_at":        "created_at",
 		"updated_at":        "updated_at",
-	}
-	dbSortBy, ok := allowedSortColumns[sortBy]
-	if !ok {
-		dbSortBy = "created_at" // Default sort column
-	}
-
-	if strings.ToUpper(sortOrder) != "ASC" && strings.ToUpper(sortOrder) != "DESC" {
-		sortOrder = "ASC"
-	}
-
-	var whereClauses []string
-	var args []interface{}
+	},
+	Default:      "created_at",
+	DefaultOrder: "ASC",
+	TieBreaker:   "id",
+}
 
-	// Assuming the table `target_checklist_items` is the primary table here and not aliased.
-	// If it were aliased (e.g., "tci"), you'd use "tci.target_id = ?"
-	// For now, assuming no alias is needed for target_id in this specific query context.
-	// Explicitly state the table name for clarity, even if not strictly ambiguous in this specific query.
-	// This helps if the query is ever expanded or combined.
-	whereClauses = append(whereClauses, "target_checklist_items.target_id = ?")
-	args = append(args, targetID)
+// GetChecklistItemsByTargetIDPaginated retrieves a paginated, sorted, and filtered list of checklist items for a target.
+// It returns the items, total records matching filters, total completed records matching filters, and an error.
+func GetChecklistItemsByTargetIDPaginated(targetID int64, limit int, offset int, sortBy string, sortOrder string, filter string, showIncompleteOnly bool) ([]models.TargetChecklistItem, int64, int64, error) {
+	var items []models.TargetChecklistItem
+	var totalCompletedRecordsForFilter int64
+	var totalRecords int64
 
+	// Completed items are counted without showIncompleteOnly, which would leave none.
+	var completedConditions Conditions
+	completedConditions.Add("target_id = ?", targetID)
 	if filter != "" {
 		filterPattern := "%" + strings.ToLower(filter) + "%" // Filter case-insensitively
-		whereClauses = append(whereClauses, "(LOWER(item_text) LIKE ? OR LOWER(item_command_text) LIKE ? OR LOWER(notes) LIKE ?)")
-		args = append(args, filterPattern, filterPattern, filterPattern)
+		completedConditions.Add("(LOWER(item_text) LIKE ? OR LOWER(item_command_text) LIKE ? OR LOWER(notes) LIKE ?)", filterPattern, filterPattern, filterPattern)
 	}
-
+	conditions := completedConditions.Clone()
 	if showIncompleteOnly {
-		whereClauses = append(whereClauses, "is_completed = 0") // Or is_completed = FALSE depending on SQLite version/bool handling
+		conditions.Add("is_completed = 0")
 	}
+	completedConditions.Add("is_completed = 1")
 
-	whereCondition := ""
-	if len(whereClauses) > 0 {
-		whereCondition = "WHERE " + strings.Join(whereClauses, " AND ")
-	}
-
-	// Use the table name in COUNT for clarity, though COUNT(*) would also work.
-	countQuery := fmt.Sprintf("SELECT COUNT(target_checklist_items.id) FROM target_checklist_items %s", whereCondition)
-	err := DB.QueryRow(countQuery, args...).Scan(&totalRecords)
+	countQuery := "SELECT COUNT(id) FROM target_checklist_items " + conditions.Where()
+	err := DB.QueryRow(countQuery, conditions.Args()...).Scan(&totalRecords)
 	if err != nil {
 		logger.Error("GetChecklistItemsByTargetIDPaginated: Error counting total records for target %d: %v", targetID, err)
 		return nil, 0, 0, fmt.Errorf("counting total checklist items for target %d: %w", targetID, err)
@@ -562,28 +546,12 @@ func GetChecklistItemsByTargetIDPaginated(targetID int64, limit int, offset int,
 		return items, 0, 0, nil
 	}
 
-	// Count completed items matching the filter (excluding showIncompleteOnly for this specific count)
-	completedWhereClauses := []string{"target_checklist_items.target_id = ?", "is_completed = 1"} // Start with target_id and is_completed
-	completedArgs := []interface{}{targetID}
-	if filter != "" { // Apply text filter if present
-		filterPattern := "%" + strings.ToLower(filter) + "%"
-		completedWhereClauses = append(completedWhereClauses, "(LOWER(item_text) LIKE ? OR LOWER(item_command_text) LIKE ? OR LOWER(notes) LIKE ?)")
-		completedArgs = append(completedArgs, filterPattern, filterPattern, filterPattern)
-	}
-	completedCountQuery := fmt.Sprintf("SELECT COUNT(target_checklist_items.id) FROM target_checklist_items WHERE %s", strings.Join(completedWhereClauses, " AND "))
-	DB.QueryRow(completedCountQuery, completedArgs...).Scan(&totalCompletedRecordsForFilter) // Error handling for this can be added if critical
-
-	queryArgs := make([]interface{}, len(args)) // Args for fetching the actual items
-	copy(queryArgs, args)
-	queryArgs = append(queryArgs, limit, offset)
+	completedCountQuery := "SELECT COUNT(id) FROM target_checklist_items " + completedConditions.Where()
+	DB.QueryRow(completedCountQuery, completedConditions.Args()...).Scan(&totalCompletedRecordsForFilter) // Error handling for this can be added if critical
 
-	query := fmt.Sprintf(`
-		SELECT id, target_id, item_text, item_command_text, notes, is_completed, created_at, updated_at
-		FROM target_checklist_items  -- No alias needed here as it's the only table
-		%s
-		ORDER BY %s %s, id %s
-		LIMIT ? OFFSET ?
-	`, whereCondition, dbSortBy, sortOrder, sortOrder)
+	query := "SELECT id, target_id, item_text, item_command_text, notes, is_completed, created_at, updated_at FROM target_checklist_items " +
+		conditions.Where() + " " + ChecklistSort.OrderBy(sortBy, sortOrder) + " LIMIT ? OFFSET ?"
+	queryArgs := conditions.Args(limit, offset)
 
 	rows, err := DB.Query(query, queryArgs...)
 	if err != nil {
@@ -716,15 +684,15 @@ func AddChecklistItemIfNotExists(targetID int64, itemText string, itemCommandTex
 
 func CreateNote(note models.Note) (int64, error) {
 	stmt, err := DB.Prepare(`
-		INSERT INTO notes (title, content, created_at, updated_at)
-		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO notes (target_id, title, content, created_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`)
 	if err != nil {
 		return 0, fmt.Errorf("preparing create note statement: %w", err)
 	}
 	defer stmt.Close()
 
-	result, err := stmt.Exec(note.Title, note.Content)
+	result, err := stmt.Exec(note.TargetID, note.Title, note.Content)
 	if err != nil {
 		return 0, fmt.Errorf("executing create note statement: %w", err)
 	}
@@ -739,10 +707,10 @@ func CreateNote(note models.Note) (int64, error) {
 func GetNoteByID(noteID int64) (models.Note, error) {
 	var note models.Note
 	err := DB.QueryRow(`
-		SELECT id, title, content, created_at, updated_at
+		SELECT id, target_id, title, content, created_at, updated_at
 		FROM notes
 		WHERE id = ?
-	`, noteID).Scan(&note.ID, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt)
+	`, noteID).Scan(&note.ID, &note.TargetID, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -753,12 +721,19 @@ func GetNoteByID(noteID int64) (models.Note, error) {
 	return note, nil
 }
 
-func GetAllNotesPaginated(limit int, offset int, sortByColumn string, sortOrder string) ([]models.Note, int64, error) {
+// GetAllNotesPaginated lists notes; a non-zero targetID limits the list to that target's notes.
+func GetAllNotesPaginated(targetID int64, limit int, offset int, sortByColumn string, sortOrder string) ([]models.Note, int64, error) {
 	var notes []models.Note
 	var totalRecords int64
 
-	countQuery := "SELECT COUNT(*) FROM notes"
-	err := DB.QueryRow(countQuery).Scan(&totalRecords)
+	whereClause := ""
+	var args []interface{}
+	if targetID != 0 {
+		whereClause = " WHERE target_id = ?"
+		args = append(args, targetID)
+	}
+	countQuery := "SELECT COUNT(*) FROM notes" + whereClause
+	err := DB.QueryRow(countQuery, args...).Scan(&totalRecords)
 	if err != nil {
 		return nil, 0, fmt.Errorf("counting notes: %w", err)
 	}
@@ -789,8 +764,8 @@ func GetAllNotesPaginated(limit int, offset int, sortByColumn string, sortOrder
 		orderByClause = "ORDER BY updated_at DESC, id DESC"
 	}
 
-	query := fmt.Sprintf("SELECT id, title, content, created_at, updated_at FROM notes %s LIMIT ? OFFSET ?", orderByClause)
-	rows, err := DB.Query(query, limit, offset)
+	query := fmt.Sprintf("SELECT id, target_id, title, content, created_at, updated_at FROM notes%s %s LIMIT ? OFFSET ?", whereClause, orderByClause)
+	rows, err := DB.Query(query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, totalRecords, fmt.Errorf("querying notes: %w", err)
 	}
@@ -798,7 +773,7 @@ func GetAllNotesPaginated(limit int, offset int, sortByColumn string, sortOrder
 
 	for rows.Next() {
 		var note models.Note
-		if err := rows.Scan(&note.ID, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt); err != nil {
+		if err := rows.Scan(&note.ID, &note.TargetID, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt); err != nil {
 			return nil, totalRecords, fmt.Errorf("scanning note row: %w", err)
 		}
 		notes = append(notes, note)
@@ -806,17 +781,56 @@ func GetAllNotesPaginated(limit int, offset int, sortByColumn string, sortOrder
 	return notes, totalRecords, rows.Err()
 }
 
+// NoteKeyset pages note listings by cursor, on when notes were last changed or created.
+var NoteKeyset = Keyset{Table: "notes", Columns: []string{"updated_at", "created_at"}}
+
+// GetNotesByCursor returns a cursor page of the notes of a target (of all targets for 0), the
+// number of notes and the cursors of the previous and next pages.
+func GetNotesByCursor(targetID int64, page KeysetPage) ([]models.Note, int64, string, string, error) {
+	var conditions Conditions
+	if targetID != 0 {
+		conditions.Add("target_id = ?", targetID)
+	}
+	var totalRecords int64
+	if err := DB.QueryRow("SELECT COUNT(*) FROM notes "+conditions.Where(), conditions.Args()...).Scan(&totalRecords); err != nil {
+		return nil, 0, "", "", fmt.Errorf("counting notes: %w", err)
+	}
+
+	orderBy := page.Apply(&conditions, "")
+	limitClause, limitArgs := page.LimitClause()
+	query := "SELECT id, target_id, title, content, created_at, updated_at FROM notes " + conditions.Where() + " " + orderBy + limitClause
+	rows, err := DB.Query(query, conditions.Args(limitArgs...)...)
+	if err != nil {
+		return nil, totalRecords, "", "", fmt.Errorf("querying notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		var note models.Note
+		if err := rows.Scan(&note.ID, &note.TargetID, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt); err != nil {
+			return nil, totalRecords, "", "", fmt.Errorf("scanning note row: %w", err)
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, totalRecords, "", "", err
+	}
+	notes, prevCursor, nextCursor, err := KeysetRows(page, notes, func(n models.Note) int64 { return n.ID })
+	return notes, totalRecords, prevCursor, nextCursor, err
+}
+
 func UpdateNote(note models.Note) error {
 	stmt, err := DB.Prepare(`
 		UPDATE notes
-		SET title = ?, content = ?, updated_at = CURRENT_TIMESTAMP
+		SET target_id = ?, title = ?, content = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`)
 	if err != nil {
 		return fmt.Errorf("preparing update note statement for note %d: %w", note.ID, err)
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(note.Title, note.Content, note.ID)
+	_, err = stmt.Exec(note.TargetID, note.Title, note.Content, note.ID)
 	return err
 }
 
@@ -847,8 +861,8 @@ func CopyAllTemplateItemsToTarget(templateID int64, targetID int64) (int64, erro
 
 	var itemsCopiedCount int64 = 0
 	stmt, errPrep := tx.Prepare(`
-		INSERT OR IGNORE INTO target_checklist_items (target_id, item_text, item_command_text, notes, is_completed, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT OR IGNORE INTO target_checklist_items (target_id, item_text, item_command_text, notes, is_completed, template_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`)
 	if errPrep != nil {
 		return itemsCopiedCount, fmt.Errorf("preparing add checklist item statement (tx): %w", errPrep)
@@ -864,7 +878,7 @@ func CopyAllTemplateItemsToTarget(templateID int64, targetID int64) (int64, erro
 			return itemsCopiedCount, fmt.Errorf("scanning template item: %w", errScan)
 		}
 
-		result, errExec := stmt.Exec(targetID, itemText, itemCommandText, notes, false) // Always insert as not completed
+		result, errExec := stmt.Exec(targetID, itemText, itemCommandText, notes, false, templateID) // Always insert as not completed
 		if errExec != nil {
 			// Don't close stmt here, it's deferred for the whole function
 			return itemsCopiedCount, fmt.Errorf("executing add checklist item statement for target %d (tx): %w", targetID, errExec)
@@ -107,6 +107,104 @@ func DeactivateMissingSynackTargets(seenSynackIDs []string, currentTimestamp tim
 	return nil
 }
 
+// GetSynackTargetDBID returns the database ID of the Synack target with the given Synack ID.
+func GetSynackTargetDBID(synackID string) (int64, error) {
+	var dbID int64
+	err := DB.QueryRow("SELECT id FROM synack_targets WHERE synack_target_id_str = ?", synackID).Scan(&dbID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("synack target '%s' not found", synackID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("querying synack target '%s': %w", synackID, err)
+	}
+	return dbID, nil
+}
+
+// GetSynackTargetIDStr returns the Synack ID of the Synack target with the given database ID.
+func GetSynackTargetIDStr(synackTargetDBID int64) (string, error) {
+	var synackID string
+	err := DB.QueryRow("SELECT synack_target_id_str FROM synack_targets WHERE id = ?", synackTargetDBID).Scan(&synackID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("synack target with db_id %d not found", synackTargetDBID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("querying synack target %d: %w", synackTargetDBID, err)
+	}
+	return synackID, nil
+}
+
+// ListStaleSynackTargets returns the active Synack targets whose analytics were never fetched or
+// were fetched before the cutoff, oldest first.
+func ListStaleSynackTargets(cutoff time.Time) ([]models.SynackTarget, error) {
+	rows, err := DB.Query(`SELECT id, synack_target_id_str, analytics_last_fetched_at FROM synack_targets
+		WHERE is_active = TRUE AND (analytics_last_fetched_at IS NULL OR analytics_last_fetched_at = '' OR analytics_last_fetched_at <= ?)
+		ORDER BY analytics_last_fetched_at IS NOT NULL, analytics_last_fetched_at, id`, cutoff.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("querying stale synack targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []models.SynackTarget
+	for rows.Next() {
+		var t models.SynackTarget
+		var fetchedAt sql.NullString
+		if err := rows.Scan(&t.DBID, &t.SynackTargetIDStr, &fetchedAt); err != nil {
+			return nil, fmt.Errorf("scanning stale synack target: %w", err)
+		}
+		if fetchedAt.Valid {
+			if parsed, errParse := time.Parse(time.RFC3339, fetchedAt.String); errParse == nil {
+				t.AnalyticsLastFetchedAt = &parsed
+			}
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// SynackTargetExists reports whether a Synack target with the given Synack ID has been seen before.
+func SynackTargetExists(synackID string) (bool, error) {
+	var exists bool
+	if err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM synack_targets WHERE synack_target_id_str = ?)", synackID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("checking synack target '%s': %w", synackID, err)
+	}
+	return exists, nil
+}
+
+// CountSynackTargets returns the number of Synack targets stored.
+func CountSynackTargets() (int64, error) {
+	var n int64
+	if err := DB.QueryRow("SELECT COUNT(*) FROM synack_targets").Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting synack targets: %w", err)
+	}
+	return n, nil
+}
+
+// UpsertObservedSynackMission stores a mission seen in the Synack mission list and reports whether
+// it is new. Known missions get their details refreshed; their status is left alone.
+func UpsertObservedSynackMission(mission models.SynackAPIMission, rawJSON string) (bool, error) {
+	var exists bool
+	if err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM synack_missions WHERE synack_task_id = ?)", mission.ID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("checking synack mission '%s': %w", mission.ID, err)
+	}
+	_, err := DB.Exec(`INSERT INTO synack_missions (
+			synack_task_id, title, description, campaign_name, organization_uid, listing_uid, listing_codename,
+			campaign_uid, payout_amount, payout_currency, status, raw_mission_details_json, notes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'PUBLISHED', ?, '')
+		ON CONFLICT(synack_task_id) DO UPDATE SET
+			title = excluded.title,
+			description = excluded.description,
+			campaign_name = excluded.campaign_name,
+			payout_amount = excluded.payout_amount,
+			payout_currency = excluded.payout_currency,
+			raw_mission_details_json = excluded.raw_mission_details_json`,
+		mission.ID, mission.Title, mission.Description, mission.CampaignName, mission.OrganizationUID, mission.ListingUID,
+		mission.ListingCodename, mission.CampaignUID, mission.Payout.Amount, mission.Payout.Currency, rawJSON)
+	if err != nil {
+		return false, fmt.Errorf("upserting synack mission '%s': %w", mission.ID, err)
+	}
+	return !exists, nil
+}
+
 func GetSynackTargetAnalyticsFetchTime(synackTargetDBID int64) (*time.Time, error) {
 	var fetchedAt sql.NullString
 	err := DB.QueryRow("SELECT analytics_last_fetched_at FROM synack_targets WHERE id = ?", synackTargetDBID).Scan(&fetchedAt)
@@ -0,0 +1,789 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// syncTimeFormat is how synced DATETIME values are sent.
+const syncTimeFormat = "2006-01-02 15:04:05.999999999"
+
+// syncEntity describes how rows of a synced table are read and written.
+type syncEntity struct {
+	Name       string
+	Table      string
+	Columns    []string        // Copied as they are
+	Blobs      map[string]bool // Columns sent base64-encoded; traffic bodies, written on insert only
+	Refs       []syncRef
+	Lookups    []syncLookup
+	NaturalKey []string // Columns (after resolving refs) identifying the same row created on two instances
+}
+
+// syncRef is a column referencing another synced row, sent as that row's sync_id.
+type syncRef struct {
+	Key      string
+	Column   string
+	Entity   string
+	Required bool // Records referencing a missing row are skipped; otherwise the column is set NULL
+}
+
+// syncLookup is a column referencing a row of a small table by name (platforms, vulnerability
+// types), sent as the name and created when missing.
+type syncLookup struct {
+	Key    string
+	Column string
+	Table  string
+}
+
+// syncEntities are the synced tables, in the order records are applied.
+var syncEntities = []syncEntity{
+	{
+		Name:       models.SyncEntityTarget,
+		Table:      "targets",
+		Columns:    []string{"slug", "codename", "link", "notes", "payout_ranges", "program_rules", "scope_fetched_at", "scope_reviewed_at"},
+		Lookups:    []syncLookup{{Key: "platform", Column: "platform_id", Table: "platforms"}},
+		NaturalKey: []string{"slug"},
+	},
+	{
+		Name:       models.SyncEntityScopeRule,
+		Table:      "scope_rules",
+		Columns:    []string{"item_type", "pattern", "is_in_scope", "is_wildcard", "description"},
+		Refs:       []syncRef{{Key: "target", Column: "target_id", Entity: models.SyncEntityTarget, Required: true}},
+		NaturalKey: []string{"target_id", "item_type", "pattern", "is_in_scope"},
+	},
+	{
+		Name:  models.SyncEntityDomain,
+		Table: "domains",
+		Columns: []string{"domain_name", "source", "is_in_scope", "is_wildcard_scope", "notes", "is_favorite", "http_status_code",
+			"http_content_length", "http_title", "http_server", "http_tech", "httpx_full_json", "favicon_hash", "favicon_url", "open_ports",
+			"enrichment_json", "enriched_at", "takeover_status", "takeover_service", "takeover_cname", "takeover_checked_at", "created_at"},
+		Refs:       []syncRef{{Key: "target", Column: "target_id", Entity: models.SyncEntityTarget, Required: true}},
+		NaturalKey: []string{"target_id", "domain_name"},
+	},
+	{
+		Name:  models.SyncEntityTraffic,
+		Table: "http_traffic_log",
+		Columns: []string{"timestamp", "request_method", "request_url", "request_http_version", "request_headers", "request_body",
+			"response_status_code", "response_reason_phrase", "response_http_version", "response_headers", "response_body",
+			"response_content_type", "response_body_size", "response_body_sha256", "response_body_truncated", "response_content_encoding",
+			"duration_ms", "is_https", "notes", "request_full_url_with_fragment", "is_favorite", "log_source"},
+		Blobs: map[string]bool{"request_body": true, "response_body": true},
+		Refs:  []syncRef{{Key: "target", Column: "target_id", Entity: models.SyncEntityTarget}},
+	},
+	{
+		Name:  models.SyncEntityFinding,
+		Table: "target_findings",
+		Columns: []string{"title", "summary", "description", "steps_to_reproduce", "impact", "recommendations", "payload", "severity",
+			"status", "cvss_score", "cwe_id", "finding_references", "discovered_at"},
+		Refs: []syncRef{
+			{Key: "target", Column: "target_id", Entity: models.SyncEntityTarget, Required: true},
+			{Key: "traffic", Column: "http_traffic_log_id", Entity: models.SyncEntityTraffic},
+		},
+		Lookups: []syncLookup{{Key: "vulnerability_type", Column: "vulnerability_type_id", Table: "vulnerability_types"}},
+	},
+}
+
+func syncEntityByName(name string) (syncEntity, int, bool) {
+	for i, e := range syncEntities {
+		if e.Name == name {
+			return e, i, true
+		}
+	}
+	return syncEntity{}, 0, false
+}
+
+// SyncSequence returns the local change counter: the sync_seq of the latest change to a synced row.
+func SyncSequence() (int64, error) {
+	var seq int64
+	if err := DB.QueryRow(`SELECT value FROM sync_sequence WHERE id = 1`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("reading sync sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// SelectSyncTraffic gives a sync_id to the traffic log entries not synced yet that are finding
+// evidence, favorites (when favorites is set) or tagged with one of tags, which makes them synced.
+func SelectSyncTraffic(tags []string, favorites bool) (int64, error) {
+	conds := []string{"id IN (SELECT http_traffic_log_id FROM target_findings WHERE http_traffic_log_id IS NOT NULL)"}
+	var args []interface{}
+	if favorites {
+		conds = append(conds, "is_favorite = 1")
+	}
+	if len(tags) > 0 {
+		conds = append(conds, `id IN (SELECT ta.item_id FROM tag_associations ta JOIN tags t ON t.id = ta.tag_id
+			WHERE ta.item_type = 'httplog' AND t.name IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")+`))`)
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+	}
+	result, err := DB.Exec(`UPDATE http_traffic_log SET sync_id = lower(hex(randomblob(16)))
+		WHERE sync_id IS NULL AND (`+strings.Join(conds, " OR ")+`)`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("selecting traffic for sync: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// CountSyncChanges returns the number of changes to synced rows after since.
+func CountSyncChanges(since int64) (int, error) {
+	parts := []string{"(SELECT COUNT(*) FROM sync_tombstones WHERE sync_seq > ?1)"}
+	for _, e := range syncEntities {
+		parts = append(parts, fmt.Sprintf("(SELECT COUNT(*) FROM %s WHERE sync_seq > ?1)", e.Table))
+	}
+	var n int
+	if err := DB.QueryRow(`SELECT `+strings.Join(parts, " + "), since).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting sync changes: %w", err)
+	}
+	return n, nil
+}
+
+// ReadSyncChanges returns the changes to synced rows after since, up to limit records and about
+// maxBytes, with the rows they reference that are not among them, so a peer that lacks those can
+// apply them. Records whose sync_id is in skip are left out, e.g. the ones just received from the
+// asking peer. It returns the change counter the records go up to and whether more are waiting.
+func ReadSyncChanges(since int64, limit, maxBytes int, skip map[string]bool) ([]models.SyncRecord, int64, bool, error) {
+	snapshot, err := SyncSequence()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	var changes []models.SyncRecord
+	for _, e := range syncEntities {
+		records, err := readSyncRecords(DB, e, "x.sync_seq > ? AND x.sync_seq <= ? ORDER BY x.sync_seq LIMIT ?", since, snapshot, limit)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		changes = append(changes, records...)
+	}
+	tombstones, err := readSyncTombstones(since, snapshot, limit)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	changes = append(changes, tombstones...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+
+	until, more := snapshot, false
+	var records []models.SyncRecord
+	size := 0
+	for i, r := range changes {
+		if i == limit || (i > 0 && size >= maxBytes) {
+			until, more = changes[i-1].Seq, true
+			break
+		}
+		size += syncRecordSize(r)
+		if !skip[r.SyncID] {
+			records = append(records, r)
+		}
+	}
+	records, err = addSyncParents(records)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return records, until, more, nil
+}
+
+// addSyncParents adds the rows referenced by records that are not among them.
+func addSyncParents(records []models.SyncRecord) ([]models.SyncRecord, error) {
+	have := map[string]bool{}
+	for _, r := range records {
+		have[r.Entity+":"+r.SyncID] = true
+	}
+	// Findings reference traffic, which references targets.
+	for pass := 0; pass < 2; pass++ {
+		missing := map[string][]interface{}{}
+		for _, r := range records {
+			e, _, _ := syncEntityByName(r.Entity)
+			for _, ref := range e.Refs {
+				id := r.Refs[ref.Key]
+				if id == "" || have[ref.Entity+":"+id] {
+					continue
+				}
+				have[ref.Entity+":"+id] = true
+				missing[ref.Entity] = append(missing[ref.Entity], id)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		for _, e := range syncEntities {
+			ids := missing[e.Name]
+			if len(ids) == 0 {
+				continue
+			}
+			parents, err := readSyncRecords(DB, e, "x.sync_id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")+")", ids...)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, parents...)
+		}
+	}
+	return records, nil
+}
+
+func syncRecordSize(r models.SyncRecord) int {
+	size := 64
+	for _, v := range r.Values {
+		if s, ok := v.(string); ok {
+			size += len(s)
+		} else {
+			size += 8
+		}
+	}
+	return size
+}
+
+type syncQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readSyncRecords reads the rows of an entity matching where (on alias x) as records.
+func readSyncRecords(q syncQuerier, e syncEntity, where string, args ...interface{}) ([]models.SyncRecord, error) {
+	exprs := []string{"x.sync_id", "x.sync_updated_at", "x.sync_seq"}
+	for _, c := range e.Columns {
+		if e.Blobs[c] {
+			exprs = append(exprs, TrafficBodyExpr("x", c))
+		} else {
+			exprs = append(exprs, "x."+c)
+		}
+	}
+	for _, ref := range e.Refs {
+		refEntity, _, _ := syncEntityByName(ref.Entity)
+		exprs = append(exprs, fmt.Sprintf("(SELECT sync_id FROM %s WHERE id = x.%s)", refEntity.Table, ref.Column))
+	}
+	for _, l := range e.Lookups {
+		exprs = append(exprs, fmt.Sprintf("(SELECT name FROM %s WHERE id = x.%s)", l.Table, l.Column))
+	}
+	rows, err := q.Query(fmt.Sprintf("SELECT %s FROM %s x WHERE x.sync_id IS NOT NULL AND %s", strings.Join(exprs, ", "), e.Table, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s changes: %w", e.Name, err)
+	}
+	defer rows.Close()
+
+	var records []models.SyncRecord
+	for rows.Next() {
+		var (
+			r         = models.SyncRecord{Entity: e.Name, Values: map[string]interface{}{}}
+			updatedAt sql.NullString
+			values    = make([]interface{}, len(e.Columns))
+			refs      = make([]sql.NullString, len(e.Refs))
+			lookups   = make([]sql.NullString, len(e.Lookups))
+		)
+		dest := []interface{}{&r.SyncID, &updatedAt, &r.Seq}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		for i := range refs {
+			dest = append(dest, &refs[i])
+		}
+		for i := range lookups {
+			dest = append(dest, &lookups[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scanning %s change: %w", e.Name, err)
+		}
+		r.UpdatedAt = updatedAt.String
+		for i, c := range e.Columns {
+			r.Values[c] = syncValue(values[i], e.Blobs[c])
+		}
+		for i, ref := range e.Refs {
+			if refs[i].Valid {
+				if r.Refs == nil {
+					r.Refs = map[string]string{}
+				}
+				r.Refs[ref.Key] = refs[i].String
+			}
+		}
+		for i, l := range e.Lookups {
+			if lookups[i].Valid {
+				r.Values[l.Key] = lookups[i].String
+			} else {
+				r.Values[l.Key] = nil
+			}
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func readSyncTombstones(since, until int64, limit int) ([]models.SyncRecord, error) {
+	rows, err := DB.Query(`SELECT entity, sync_id, deleted_at, sync_seq FROM sync_tombstones
+		WHERE sync_seq > ? AND sync_seq <= ? ORDER BY sync_seq LIMIT ?`, since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("reading sync tombstones: %w", err)
+	}
+	defer rows.Close()
+	var records []models.SyncRecord
+	for rows.Next() {
+		r := models.SyncRecord{Deleted: true}
+		if err := rows.Scan(&r.Entity, &r.SyncID, &r.UpdatedAt, &r.Seq); err != nil {
+			return nil, fmt.Errorf("scanning sync tombstone: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// syncValue converts a column value into what is sent: times in syncTimeFormat (UTC), blobs
+// base64-encoded and other bytes as text.
+func syncValue(v interface{}, blob bool) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(syncTimeFormat)
+	case []byte:
+		if blob {
+			return base64.StdEncoding.EncodeToString(t)
+		}
+		return string(t)
+	case string:
+		if blob {
+			return base64.StdEncoding.EncodeToString([]byte(t))
+		}
+	}
+	return v
+}
+
+// columnValue converts a received value into what is written to a column.
+func columnValue(v interface{}, blob bool) (interface{}, error) {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		return t.Float64()
+	case string:
+		if blob {
+			return base64.StdEncoding.DecodeString(t)
+		}
+	}
+	return v, nil
+}
+
+// syncRecordHash identifies the data of a record, to tell identical records apart and to break
+// ties between changes made at the same time.
+func syncRecordHash(r models.SyncRecord) string {
+	data, _ := json.Marshal(struct {
+		Refs   map[string]string      `json:"refs"`
+		Values map[string]interface{} `json:"values"`
+	}{r.Refs, r.Values})
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%x", sum)
+}
+
+// ApplySyncRecords applies records received from a peer, last writer wins: a row is replaced when
+// the record is newer, and deleted when a deletion is newer than the row. Rows created on both
+// instances (same target slug, domain name, ...) are merged under the smaller sync_id.
+//
+// sentUntil is the local change counter up to which changes were sent to the peer. When nothing
+// else was waiting to be sent, the returned counter is moved past the changes the records made,
+// so they aren't sent back; otherwise sentUntil is returned as it is.
+func ApplySyncRecords(records []models.SyncRecord, sentUntil int64) (models.SyncApplyResult, int64, error) {
+	var result models.SyncApplyResult
+	tx, err := DB.Begin()
+	if err != nil {
+		return result, sentUntil, fmt.Errorf("starting sync transaction: %w", err)
+	}
+	defer tx.Rollback()
+	// Taking the write lock first keeps other changes out until the transaction ends.
+	var before int64
+	if err := tx.QueryRow(`UPDATE sync_sequence SET value = value WHERE id = 1 RETURNING value`).Scan(&before); err != nil {
+		return result, sentUntil, fmt.Errorf("reading sync sequence: %w", err)
+	}
+
+	// Referenced rows first; deletions last, children before parents.
+	order := func(r models.SyncRecord) int {
+		_, i, _ := syncEntityByName(r.Entity)
+		if r.Deleted {
+			return 2*len(syncEntities) - i
+		}
+		return i
+	}
+	sorted := append([]models.SyncRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return order(sorted[i]) < order(sorted[j]) })
+
+	for _, r := range sorted {
+		e, _, ok := syncEntityByName(r.Entity)
+		if !ok || r.SyncID == "" || r.UpdatedAt == "" {
+			result.Skipped++
+			continue
+		}
+		if r.Deleted {
+			err = applySyncDeletion(tx, e, r, &result)
+		} else {
+			err = applySyncRecord(tx, e, r, &result)
+		}
+		if err != nil {
+			if !strings.Contains(err.Error(), "constraint failed") {
+				return result, sentUntil, err
+			}
+			logger.Warn("Sync: Skipped %s %s: %v", r.Entity, r.SyncID, err)
+			result.Skipped++
+		}
+	}
+
+	after := before
+	if err := tx.QueryRow(`SELECT value FROM sync_sequence WHERE id = 1`).Scan(&after); err != nil {
+		return result, sentUntil, fmt.Errorf("reading sync sequence: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return result, sentUntil, fmt.Errorf("committing sync changes: %w", err)
+	}
+	if sentUntil >= before {
+		sentUntil = after
+	}
+	return result, sentUntil, nil
+}
+
+// canonicalSyncID follows the alias of a sync_id merged into another one.
+func canonicalSyncID(tx *sql.Tx, entity, syncID string) (string, error) {
+	var canonical string
+	err := tx.QueryRow(`SELECT sync_id FROM sync_aliases WHERE entity = ? AND alias = ?`, entity, syncID).Scan(&canonical)
+	if err == sql.ErrNoRows {
+		return syncID, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("looking up sync alias: %w", err)
+	}
+	return canonical, nil
+}
+
+// localSyncRow returns the ID and change time of the row of an entity with a sync_id.
+func localSyncRow(tx *sql.Tx, e syncEntity, syncID string) (int64, string, bool, error) {
+	var (
+		id        int64
+		updatedAt sql.NullString
+	)
+	err := tx.QueryRow(fmt.Sprintf(`SELECT id, sync_updated_at FROM %s WHERE sync_id = ?`, e.Table), syncID).Scan(&id, &updatedAt)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("looking up %s %s: %w", e.Name, syncID, err)
+	}
+	return id, updatedAt.String, true, nil
+}
+
+func applySyncRecord(tx *sql.Tx, e syncEntity, r models.SyncRecord, result *models.SyncApplyResult) error {
+	var err error
+	if r.SyncID, err = canonicalSyncID(tx, e.Name, r.SyncID); err != nil {
+		return err
+	}
+	var deletedAt string
+	err = tx.QueryRow(`SELECT deleted_at FROM sync_tombstones WHERE entity = ? AND sync_id = ?`, e.Name, r.SyncID).Scan(&deletedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("looking up sync tombstone: %w", err)
+	}
+	if err == nil && deletedAt >= r.UpdatedAt {
+		result.Unchanged++
+		return nil
+	}
+
+	columns := []string{}
+	values := []interface{}{}
+	set := func(column string, value interface{}) {
+		columns = append(columns, column)
+		values = append(values, value)
+	}
+	for _, c := range e.Columns {
+		v, ok := r.Values[c]
+		if !ok {
+			continue
+		}
+		cv, err := columnValue(v, e.Blobs[c])
+		if err != nil {
+			result.Skipped++
+			return nil
+		}
+		set(c, cv)
+	}
+	for _, ref := range e.Refs {
+		var localID interface{}
+		if id := r.Refs[ref.Key]; id != "" {
+			refEntity, _, _ := syncEntityByName(ref.Entity)
+			canonical, err := canonicalSyncID(tx, ref.Entity, id)
+			if err != nil {
+				return err
+			}
+			r.Refs[ref.Key] = canonical
+			rowID, _, found, err := localSyncRow(tx, refEntity, canonical)
+			if err != nil {
+				return err
+			}
+			if found {
+				localID = rowID
+			}
+		}
+		if localID == nil && ref.Required {
+			result.Skipped++
+			return nil
+		}
+		set(ref.Column, localID)
+	}
+	for _, l := range e.Lookups {
+		name, _ := r.Values[l.Key].(string)
+		id, err := syncLookupID(tx, l, name)
+		if err != nil {
+			return err
+		}
+		set(l.Column, id)
+	}
+
+	id, localUpdatedAt, found, err := localSyncRow(tx, e, r.SyncID)
+	if err != nil {
+		return err
+	}
+	if !found && len(e.NaturalKey) > 0 {
+		if id, localUpdatedAt, found, err = mergeSyncRow(tx, e, &r, columns, values); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return insertSyncRow(tx, e, r, columns, values, result)
+	}
+
+	local, err := readSyncRecords(tx, e, "x.id = ?", id)
+	if err != nil || len(local) == 0 {
+		return err
+	}
+	localHash, remoteHash := syncRecordHash(local[0]), syncRecordHash(r)
+	if localHash == remoteHash || r.UpdatedAt < localUpdatedAt || (r.UpdatedAt == localUpdatedAt && remoteHash < localHash) {
+		result.Unchanged++
+		return nil
+	}
+	var assignments []string
+	var args []interface{}
+	for i, c := range columns {
+		if e.Blobs[c] {
+			continue // Bodies of a log entry don't change
+		}
+		assignments = append(assignments, c+" = ?")
+		args = append(args, values[i])
+	}
+	assignments = append(assignments, "sync_updated_at = ?")
+	args = append(args, r.UpdatedAt, id)
+	if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s WHERE id = ?`, e.Table, strings.Join(assignments, ", ")), args...); err != nil {
+		return fmt.Errorf("updating %s %s: %w", e.Name, r.SyncID, err)
+	}
+	result.Updated++
+	return nil
+}
+
+// mergeSyncRow looks for a row with the natural key of a record that has another sync_id, i.e. the
+// same row created on both instances. Both are kept under the smaller sync_id, the other one
+// becoming an alias of it.
+func mergeSyncRow(tx *sql.Tx, e syncEntity, r *models.SyncRecord, columns []string, values []interface{}) (int64, string, bool, error) {
+	var conds []string
+	var args []interface{}
+	for _, key := range e.NaturalKey {
+		found := false
+		for i, c := range columns {
+			if c == key {
+				conds = append(conds, c+" = ?")
+				args = append(args, values[i])
+				found = true
+			}
+		}
+		if !found {
+			return 0, "", false, nil
+		}
+	}
+	var (
+		id        int64
+		localID   string
+		updatedAt sql.NullString
+	)
+	err := tx.QueryRow(fmt.Sprintf(`SELECT id, sync_id, sync_updated_at FROM %s WHERE %s`, e.Table, strings.Join(conds, " AND ")), args...).
+		Scan(&id, &localID, &updatedAt)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("looking up %s by key: %w", e.Name, err)
+	}
+	alias, canonical := r.SyncID, localID
+	if r.SyncID < localID {
+		alias, canonical = localID, r.SyncID
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET sync_id = ? WHERE id = ?`, e.Table), canonical, id); err != nil {
+			return 0, "", false, fmt.Errorf("merging %s %s: %w", e.Name, localID, err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE sync_aliases SET sync_id = ? WHERE entity = ? AND sync_id = ?`, canonical, e.Name, alias); err != nil {
+		return 0, "", false, fmt.Errorf("merging %s aliases: %w", e.Name, err)
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO sync_aliases (entity, alias, sync_id) VALUES (?, ?, ?)`, e.Name, alias, canonical); err != nil {
+		return 0, "", false, fmt.Errorf("recording %s alias: %w", e.Name, err)
+	}
+	r.SyncID = canonical
+	return id, updatedAt.String, true, nil
+}
+
+func insertSyncRow(tx *sql.Tx, e syncEntity, r models.SyncRecord, columns []string, values []interface{}, result *models.SyncApplyResult) error {
+	if len(e.Blobs) > 0 {
+		var request, response []byte
+		for i, c := range columns {
+			switch c {
+			case "request_body":
+				request, _ = values[i].([]byte)
+			case "response_body":
+				response, _ = values[i].([]byte)
+			}
+		}
+		bodies, err := storeTrafficBodies(tx, request, response)
+		if err != nil {
+			return err
+		}
+		for i, c := range columns {
+			switch c {
+			case "request_body":
+				values[i] = bodies.request
+			case "response_body":
+				values[i] = bodies.response
+			}
+		}
+		columns = append(columns, "request_body_blob_id", "response_body_blob_id")
+		values = append(values, bodies.requestBlob, bodies.responseBlob)
+	}
+	columns = append(columns, "sync_id", "sync_updated_at")
+	values = append(values, r.SyncID, r.UpdatedAt)
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, e.Table, strings.Join(columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+	if _, err := tx.Exec(query, values...); err != nil {
+		return fmt.Errorf("inserting %s %s: %w", e.Name, r.SyncID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM sync_tombstones WHERE entity = ? AND sync_id = ?`, e.Name, r.SyncID); err != nil {
+		return fmt.Errorf("clearing sync tombstone: %w", err)
+	}
+	result.Inserted++
+	return nil
+}
+
+// syncLookupID returns the ID of the row of a lookup table with a name, adding it when missing.
+func syncLookupID(tx *sql.Tx, l syncLookup, name string) (interface{}, error) {
+	if name == "" {
+		return nil, nil
+	}
+	var id int64
+	err := tx.QueryRow(fmt.Sprintf(`SELECT id FROM %s WHERE name = ?`, l.Table), name).Scan(&id)
+	if err == sql.ErrNoRows {
+		res, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s (name) VALUES (?)`, l.Table), name)
+		if err != nil {
+			return nil, fmt.Errorf("adding %s '%s': %w", l.Key, name, err)
+		}
+		return res.LastInsertId()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s '%s': %w", l.Key, name, err)
+	}
+	return id, nil
+}
+
+func applySyncDeletion(tx *sql.Tx, e syncEntity, r models.SyncRecord, result *models.SyncApplyResult) error {
+	var err error
+	if r.SyncID, err = canonicalSyncID(tx, e.Name, r.SyncID); err != nil {
+		return err
+	}
+	id, localUpdatedAt, found, err := localSyncRow(tx, e, r.SyncID)
+	if err != nil {
+		return err
+	}
+	if found {
+		if localUpdatedAt > r.UpdatedAt {
+			result.Unchanged++ // Changed after the deletion; the change wins
+			return nil
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, e.Table), id); err != nil {
+			return fmt.Errorf("deleting %s %s: %w", e.Name, r.SyncID, err)
+		}
+		// The trigger recorded the deletion as of now; keep the time it happened.
+		if _, err := tx.Exec(`UPDATE sync_tombstones SET deleted_at = ? WHERE entity = ? AND sync_id = ?`, r.UpdatedAt, e.Name, r.SyncID); err != nil {
+			return fmt.Errorf("recording deletion of %s %s: %w", e.Name, r.SyncID, err)
+		}
+		result.Deleted++
+		return nil
+	}
+	// Unknown row: remember the deletion so an older copy arriving later is not added.
+	if _, err := tx.Exec(`UPDATE sync_sequence SET value = value + 1 WHERE id = 1`); err != nil {
+		return fmt.Errorf("updating sync sequence: %w", err)
+	}
+	_, err = tx.Exec(`INSERT INTO sync_tombstones (entity, sync_id, deleted_at, sync_seq)
+		VALUES (?, ?, ?, (SELECT value FROM sync_sequence WHERE id = 1))
+		ON CONFLICT(entity, sync_id) DO UPDATE SET deleted_at = excluded.deleted_at, sync_seq = excluded.sync_seq
+		WHERE excluded.deleted_at > sync_tombstones.deleted_at`, e.Name, r.SyncID, r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("recording deletion of %s %s: %w", e.Name, r.SyncID, err)
+	}
+	result.Unchanged++
+	return nil
+}
+
+// PruneSyncTombstones removes the records of deletions made before a time.
+func PruneSyncTombstones(before time.Time) (int64, error) {
+	result, err := DB.Exec(`DELETE FROM sync_tombstones WHERE deleted_at < ?`, before.UTC().Format("2006-01-02T15:04:05.000Z"))
+	if err != nil {
+		return 0, fmt.Errorf("pruning sync tombstones: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetSyncPeerStates returns the stored progress of syncing with each peer by name.
+func GetSyncPeerStates() (map[string]models.SyncPeerStatus, error) {
+	rows, err := DB.Query(`SELECT name, pulled_until, pushed_until, last_sync_at, last_error, last_result FROM sync_peers`)
+	if err != nil {
+		return nil, fmt.Errorf("querying sync peers: %w", err)
+	}
+	defer rows.Close()
+	states := map[string]models.SyncPeerStatus{}
+	for rows.Next() {
+		var (
+			s                     models.SyncPeerStatus
+			lastSyncAt            sql.NullTime
+			lastError, lastResult sql.NullString
+		)
+		if err := rows.Scan(&s.Name, &s.PulledUntil, &s.PushedUntil, &lastSyncAt, &lastError, &lastResult); err != nil {
+			return nil, fmt.Errorf("scanning sync peer: %w", err)
+		}
+		if lastSyncAt.Valid {
+			s.LastSyncAt = &lastSyncAt.Time
+		}
+		s.LastError = lastError.String
+		if lastResult.Valid && lastResult.String != "" {
+			var result models.SyncRunResult
+			if json.Unmarshal([]byte(lastResult.String), &result) == nil {
+				s.LastResult = &result
+			}
+		}
+		states[s.Name] = s
+	}
+	return states, rows.Err()
+}
+
+// SaveSyncPeerState stores the progress of syncing with a peer and the outcome of the last run.
+func SaveSyncPeerState(name string, pulledUntil, pushedUntil int64, result *models.SyncRunResult, runErr error) error {
+	var resultJSON, errMsg interface{}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resultJSON = string(data)
+	}
+	if runErr != nil {
+		errMsg = runErr.Error()
+	}
+	_, err := DB.Exec(`INSERT INTO sync_peers (name, pulled_until, pushed_until, last_sync_at, last_error, last_result)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET pulled_until = excluded.pulled_until, pushed_until = excluded.pushed_until,
+			last_sync_at = excluded.last_sync_at, last_error = excluded.last_error, last_result = excluded.last_result`,
+		name, pulledUntil, pushedUntil, errMsg, resultJSON)
+	if err != nil {
+		return fmt.Errorf("saving state of sync peer '%s': %w", name, err)
+	}
+	return nil
+}
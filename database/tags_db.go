@@ -7,8 +7,17 @@ import (
 	"strings"
 	"toolkit/logger"
 	"toolkit/models"
+	"unicode/utf8"
 )
 
+const tagColumns = "t.id, t.name, t.color, t.parent_id, t.description, t.created_at, t.updated_at"
+
+func scanTag(scanner interface{ Scan(...interface{}) error }) (models.Tag, error) {
+	var tag models.Tag
+	err := scanner.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.ParentID, &tag.Description, &tag.CreatedAt, &tag.UpdatedAt)
+	return tag, err
+}
+
 // CreateTag inserts a new tag into the database.
 // It ensures the tag name is unique (case-insensitive).
 func CreateTag(tag models.Tag) (models.Tag, error) {
@@ -21,10 +30,7 @@ func CreateTag(tag models.Tag) (models.Tag, error) {
 	}
 
 	// Check if tag with the same name (case-insensitive) already exists
-	var existingTag models.Tag
-	err := DB.QueryRow("SELECT id, name, color, created_at, updated_at FROM tags WHERE LOWER(name) = LOWER(?)", tag.Name).Scan(
-		&existingTag.ID, &existingTag.Name, &existingTag.Color, &existingTag.CreatedAt, &existingTag.UpdatedAt,
-	)
+	existingTag, err := scanTag(DB.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE LOWER(t.name) = LOWER(?)", tag.Name))
 	if err == nil {
 		// Tag already exists, return it
 		logger.Info("CreateTag: Tag with name '%s' already exists (ID: %d). Returning existing tag.", tag.Name, existingTag.ID)
@@ -35,15 +41,21 @@ func CreateTag(tag models.Tag) (models.Tag, error) {
 		return models.Tag{}, fmt.Errorf("checking for existing tag '%s': %w", tag.Name, err)
 	}
 
+	if tag.ParentID.Valid {
+		if _, err := GetTagByID(tag.ParentID.Int64); err != nil {
+			return models.Tag{}, fmt.Errorf("parent %w", err)
+		}
+	}
+
 	// Tag does not exist, create it
-	stmt, err := DB.Prepare("INSERT INTO tags (name, color) VALUES (?, ?)")
+	stmt, err := DB.Prepare("INSERT INTO tags (name, color, parent_id, description) VALUES (?, ?, ?, ?)")
 	if err != nil {
 		logger.Error("CreateTag: Error preparing statement for tag '%s': %v", tag.Name, err)
 		return models.Tag{}, fmt.Errorf("preparing insert tag statement: %w", err)
 	}
 	defer stmt.Close()
 
-	result, err := stmt.Exec(tag.Name, tag.Color)
+	result, err := stmt.Exec(tag.Name, tag.Color, tag.ParentID, tag.Description)
 	if err != nil {
 		logger.Error("CreateTag: Error executing insert for tag '%s': %v", tag.Name, err)
 		return models.Tag{}, fmt.Errorf("executing insert tag: %w", err)
@@ -67,13 +79,10 @@ func CreateTag(tag models.Tag) (models.Tag, error) {
 
 // GetTagByID retrieves a single tag by its ID.
 func GetTagByID(id int64) (models.Tag, error) {
-	var tag models.Tag
 	if DB == nil {
-		return tag, errors.New("database connection is not initialized")
+		return models.Tag{}, errors.New("database connection is not initialized")
 	}
-	err := DB.QueryRow("SELECT id, name, color, created_at, updated_at FROM tags WHERE id = ?", id).Scan(
-		&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt,
-	)
+	tag, err := scanTag(DB.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return tag, fmt.Errorf("tag with ID %d not found", id)
@@ -89,7 +98,7 @@ func GetAllTags() ([]models.Tag, error) {
 	if DB == nil {
 		return nil, errors.New("database connection is not initialized")
 	}
-	rows, err := DB.Query("SELECT id, name, color, created_at, updated_at FROM tags ORDER BY LOWER(name) ASC")
+	rows, err := DB.Query("SELECT " + tagColumns + " FROM tags t ORDER BY LOWER(t.name) ASC")
 	if err != nil {
 		logger.Error("GetAllTags: Error querying all tags: %v", err)
 		return nil, fmt.Errorf("querying all tags: %w", err)
@@ -98,8 +107,8 @@ func GetAllTags() ([]models.Tag, error) {
 
 	var tags []models.Tag
 	for rows.Next() {
-		var tag models.Tag
-		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		tag, err := scanTag(rows)
+		if err != nil {
 			logger.Error("GetAllTags: Error scanning tag row: %v", err)
 			return nil, fmt.Errorf("scanning tag row: %w", err)
 		}
@@ -229,7 +238,7 @@ func GetTagsForItem(itemID int64, itemType string) ([]models.Tag, error) {
 	itemType = strings.ToLower(strings.TrimSpace(itemType))
 
 	query := `
-		SELECT t.id, t.name, t.color, t.created_at, t.updated_at
+		SELECT ` + tagColumns + `
 		FROM tags t
 		JOIN tag_associations ta ON t.id = ta.tag_id
 		WHERE ta.item_id = ? AND ta.item_type = ?
@@ -244,8 +253,8 @@ func GetTagsForItem(itemID int64, itemType string) ([]models.Tag, error) {
 
 	var tags []models.Tag
 	for rows.Next() {
-		var tag models.Tag
-		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		tag, err := scanTag(rows)
+		if err != nil {
 			logger.Error("GetTagsForItem: Error scanning tag row: %v", err)
 			return nil, fmt.Errorf("scanning tag row for item: %w", err)
 		}
@@ -287,7 +296,7 @@ func GetItemsForTag(tagID int64) ([]models.TagAssociation, error) {
 	return associations, rows.Err()
 }
 
-// UpdateTag updates an existing tag's name and/or color.
+// UpdateTag updates an existing tag's name, color and/or description.
 // It only updates fields that are non-zero/non-empty in the provided models.Tag struct.
 func UpdateTag(tag models.Tag) (models.Tag, error) {
 	if DB == nil {
@@ -310,6 +319,11 @@ func UpdateTag(tag models.Tag) (models.Tag, error) {
 		setClauses = append(setClauses, "color = ?")
 		args = append(args, tag.Color)
 	}
+	// Description follows the same rule as color
+	if tag.Description.Valid {
+		setClauses = append(setClauses, "description = ?")
+		args = append(args, tag.Description)
+	}
 
 	if len(setClauses) == 0 {
 		// No fields to update, just fetch and return the existing tag
@@ -418,7 +432,7 @@ func GetTagsForMultipleItems(itemIDs []int64, itemType string) (map[int64][]mode
 
 	placeholders := strings.Repeat("?,", len(itemIDs)-1) + "?"
 	query := fmt.Sprintf(`
-		SELECT ta.item_id, t.id, t.name, t.color, t.created_at, t.updated_at
+		SELECT ta.item_id, `+tagColumns+`
 		FROM tags t
 		JOIN tag_associations ta ON t.id = ta.tag_id
 		WHERE ta.item_id IN (%s) AND ta.item_type = ?
@@ -442,7 +456,7 @@ func GetTagsForMultipleItems(itemIDs []int64, itemType string) (map[int64][]mode
 	for rows.Next() {
 		var itemID int64
 		var tag models.Tag
-		if err := rows.Scan(&itemID, &tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		if err := rows.Scan(&itemID, &tag.ID, &tag.Name, &tag.Color, &tag.ParentID, &tag.Description, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
 			logger.Error("GetTagsForMultipleItems: Error scanning tag row: %v", err)
 			continue
 		}
@@ -450,3 +464,284 @@ func GetTagsForMultipleItems(itemIDs []int64, itemType string) (map[int64][]mode
 	}
 	return tagsByItemID, rows.Err()
 }
+
+// tagItemTables maps the tag item types that belong to a target to their table and display label column.
+var tagItemTables = map[string]struct{ table, label string }{
+	models.TagItemHTTPLog:  {"http_traffic_log", "request_method || ' ' || request_url"},
+	models.TagItemDomain:   {"domains", "domain_name"},
+	models.TagItemFinding:  {"target_findings", "title"},
+	models.TagItemWordlist: {"wordlists", "name"},
+}
+
+// tagItemExpr builds a CASE expression over ta.item_type selecting column from the tagged item's row.
+func tagItemExpr(column func(label string) string) string {
+	var b strings.Builder
+	b.WriteString("CASE ta.item_type")
+	for _, itemType := range models.TagItemTypes {
+		t := tagItemTables[itemType]
+		fmt.Fprintf(&b, " WHEN '%s' THEN (SELECT %s FROM %s WHERE id = ta.item_id)", itemType, column(t.label), t.table)
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+var (
+	tagItemTargetExpr = tagItemExpr(func(string) string { return "target_id" })
+	tagItemLabelExpr  = "COALESCE(" + tagItemExpr(func(label string) string { return label }) + ", '')"
+)
+
+// SetTagParent moves a tag under another tag, or to the top level when parentID is not valid.
+// A tag cannot be its own ancestor.
+func SetTagParent(tagID int64, parentID sql.NullInt64) error {
+	if parentID.Valid {
+		if parentID.Int64 == tagID {
+			return errors.New("a tag cannot be its own parent")
+		}
+		var isDescendant bool
+		err := DB.QueryRow(`
+			WITH RECURSIVE ancestors(id) AS (
+				SELECT ?
+				UNION
+				SELECT t.parent_id FROM tags t JOIN ancestors a ON t.id = a.id WHERE t.parent_id IS NOT NULL
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`, parentID.Int64, tagID).Scan(&isDescendant)
+		if err != nil {
+			return fmt.Errorf("checking ancestors of tag %d: %w", parentID.Int64, err)
+		}
+		if isDescendant {
+			return fmt.Errorf("tag %d cannot be moved under its own descendant %d", tagID, parentID.Int64)
+		}
+		if _, err := GetTagByID(parentID.Int64); err != nil {
+			return fmt.Errorf("parent %w", err)
+		}
+	}
+	res, err := DB.Exec(`UPDATE tags SET parent_id = ? WHERE id = ?`, parentID, tagID)
+	if err != nil {
+		return fmt.Errorf("setting parent of tag %d: %w", tagID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("tag with ID %d not found", tagID)
+	}
+	return nil
+}
+
+// GetTagSummaries returns all tags with their usage and child counts. When targetID is non-zero
+// only items of that target are counted.
+func GetTagSummaries(targetID int64) ([]models.TagSummary, error) {
+	usageWhere := "ta.tag_id = t.id"
+	var args []interface{}
+	if targetID > 0 {
+		usageWhere += " AND " + tagItemTargetExpr + " = ?"
+		args = append(args, targetID)
+	}
+	rows, err := DB.Query(`
+		SELECT `+tagColumns+`,
+			(SELECT COUNT(*) FROM tag_associations ta WHERE `+usageWhere+`),
+			(SELECT COUNT(*) FROM tags c WHERE c.parent_id = t.id)
+		FROM tags t
+		ORDER BY LOWER(t.name) ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying tag summaries: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []models.TagSummary{}
+	for rows.Next() {
+		var s models.TagSummary
+		if err := rows.Scan(&s.ID, &s.Name, &s.Color, &s.ParentID, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.UsageCount, &s.ChildCount); err != nil {
+			return nil, fmt.Errorf("scanning tag summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// GetTagUsage returns how many items of each type carry a tag, per target.
+func GetTagUsage(tagID int64) ([]models.TagUsage, error) {
+	rows, err := DB.Query(`
+		SELECT u.target_id, IFNULL(tg.codename, ''), u.item_type, u.cnt
+		FROM (
+			SELECT `+tagItemTargetExpr+` AS target_id, ta.item_type, COUNT(*) AS cnt
+			FROM tag_associations ta
+			WHERE ta.tag_id = ?
+			GROUP BY 1, 2
+		) u
+		LEFT JOIN targets tg ON tg.id = u.target_id
+		ORDER BY u.cnt DESC, u.item_type ASC`, tagID)
+	if err != nil {
+		return nil, fmt.Errorf("querying usage of tag %d: %w", tagID, err)
+	}
+	defer rows.Close()
+
+	usage := []models.TagUsage{}
+	for rows.Next() {
+		var u models.TagUsage
+		if err := rows.Scan(&u.TargetID, &u.TargetCodename, &u.ItemType, &u.Count); err != nil {
+			return nil, fmt.Errorf("scanning tag usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// GetTaggedItems lists the items carrying a tag (and, with includeDescendants, any of its child tags),
+// newest first. targetID and itemType are optional filters.
+func GetTaggedItems(tagID int64, includeDescendants bool, targetID int64, itemType string) ([]models.TaggedItem, error) {
+	tagFilter := "ta.tag_id = ?"
+	if includeDescendants {
+		tagFilter = `ta.tag_id IN (
+			WITH RECURSIVE subtree(id) AS (
+				SELECT ?
+				UNION
+				SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id
+			)
+			SELECT id FROM subtree)`
+	}
+	where := []string{tagFilter}
+	args := []interface{}{tagID}
+	if targetID > 0 {
+		where = append(where, tagItemTargetExpr+" = ?")
+		args = append(args, targetID)
+	}
+	if itemType != "" {
+		where = append(where, "ta.item_type = ?")
+		args = append(args, strings.ToLower(itemType))
+	}
+
+	rows, err := DB.Query(`
+		SELECT ta.tag_id, ta.item_type, ta.item_id, `+tagItemTargetExpr+`, `+tagItemLabelExpr+`, ta.created_at
+		FROM tag_associations ta
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY ta.created_at DESC, ta.id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying items for tag %d: %w", tagID, err)
+	}
+	defer rows.Close()
+
+	items := []models.TaggedItem{}
+	for rows.Next() {
+		var item models.TaggedItem
+		if err := rows.Scan(&item.TagID, &item.ItemType, &item.ItemID, &item.TargetID, &item.Label, &item.TaggedAt); err != nil {
+			return nil, fmt.Errorf("scanning tagged item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// MergeTags moves the associations and child tags of the source tags to the destination tag and
+// deletes the sources. It returns the number of associations moved; duplicates are dropped.
+func MergeTags(destID int64, sourceIDs []int64) (int64, error) {
+	if len(sourceIDs) == 0 {
+		return 0, errors.New("at least one source tag is required")
+	}
+	if _, err := GetTagByID(destID); err != nil {
+		return 0, err
+	}
+	for _, sourceID := range sourceIDs {
+		if sourceID == destID {
+			return 0, errors.New("a tag cannot be merged into itself")
+		}
+		if _, err := GetTagByID(sourceID); err != nil {
+			return 0, err
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting tag merge transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var moved int64
+	for _, sourceID := range sourceIDs {
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO tag_associations (tag_id, item_id, item_type, created_at)
+			SELECT ?, item_id, item_type, created_at FROM tag_associations WHERE tag_id = ?`, destID, sourceID)
+		if err != nil {
+			return 0, fmt.Errorf("moving associations of tag %d: %w", sourceID, err)
+		}
+		n, _ := res.RowsAffected()
+		moved += n
+		// If the destination sits below the source it takes the source's place in the tree first,
+		// so moving the source's children under it cannot create a cycle.
+		if _, err := tx.Exec(`
+			WITH RECURSIVE subtree(id) AS (
+				SELECT id FROM tags WHERE parent_id = ?
+				UNION
+				SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id
+			)
+			UPDATE tags SET parent_id = (SELECT parent_id FROM tags WHERE id = ?)
+			WHERE id = ? AND id IN (SELECT id FROM subtree)`, sourceID, sourceID, destID); err != nil {
+			return 0, fmt.Errorf("re-parenting tag %d: %w", destID, err)
+		}
+		if _, err := tx.Exec(`UPDATE tags SET parent_id = ? WHERE parent_id = ?`, destID, sourceID); err != nil {
+			return 0, fmt.Errorf("moving child tags of tag %d: %w", sourceID, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, sourceID); err != nil {
+			return 0, fmt.Errorf("deleting merged tag %d: %w", sourceID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing tag merge: %w", err)
+	}
+	logger.Info("MergeTags: Merged tags %v into tag %d (%d associations moved).", sourceIDs, destID, moved)
+	return moved, nil
+}
+
+// RenameTag renames a tag. With cascade, descendant tags whose names start with "<old name>/"
+// get the new prefix. Associations reference tags by ID and follow automatically. It returns the
+// number of descendant tags renamed.
+func RenameTag(tagID int64, newName string, cascade bool) (int64, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return 0, errors.New("tag name cannot be empty")
+	}
+	tag, err := GetTagByID(tagID)
+	if err != nil {
+		return 0, err
+	}
+	var existingID int64
+	err = DB.QueryRow(`SELECT id FROM tags WHERE LOWER(name) = LOWER(?) AND id != ?`, newName, tagID).Scan(&existingID)
+	if err == nil {
+		return 0, fmt.Errorf("tag '%s' already exists (ID %d); merge the tags instead", newName, existingID)
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("checking for existing tag '%s': %w", newName, err)
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting tag rename transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ?`, newName, tagID); err != nil {
+		return 0, fmt.Errorf("renaming tag %d: %w", tagID, err)
+	}
+	var renamed int64
+	if cascade {
+		oldPrefix := tag.Name + "/"
+		res, err := tx.Exec(`
+			WITH RECURSIVE subtree(id) AS (
+				SELECT id FROM tags WHERE parent_id = ?
+				UNION
+				SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id
+			)
+			UPDATE tags SET name = ? || substr(name, ?)
+			WHERE id IN (SELECT id FROM subtree) AND substr(name, 1, ?) = ?`,
+			tagID, newName+"/", utf8.RuneCountInString(oldPrefix)+1, utf8.RuneCountInString(oldPrefix), oldPrefix)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return 0, fmt.Errorf("renaming child tags of '%s' would create a tag that already exists", tag.Name)
+			}
+			return 0, fmt.Errorf("renaming child tags of tag %d: %w", tagID, err)
+		}
+		renamed, _ = res.RowsAffected()
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing tag rename: %w", err)
+	}
+	logger.Info("RenameTag: Renamed tag %d from '%s' to '%s' (%d child tags renamed).", tagID, tag.Name, newName, renamed)
+	return renamed, nil
+}
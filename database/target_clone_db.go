@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"toolkit/logger"
+	"toolkit/models"
+)
+
+// targetCloneCopies copies one component from the source to the new target and returns how many
+// records were copied.
+var targetCloneCopies = map[string]func(tx *sql.Tx, sourceID, newID int64) (int, error){
+	models.TargetCloneScope: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return cloneScopeRules(tx, sourceID, newID, true)
+	},
+	models.TargetCloneExclusions: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return cloneScopeRules(tx, sourceID, newID, false)
+	},
+	models.TargetCloneChecklist: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return execCloneCount(tx, `INSERT INTO target_checklist_items (target_id, item_text, item_command_text, template_id)
+			SELECT ?, item_text, item_command_text, template_id FROM target_checklist_items WHERE target_id = ? ORDER BY id`, newID, sourceID)
+	},
+	models.TargetCloneNotes: cloneTargetNotes,
+	models.TargetCloneProgram: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		n, err := execCloneCount(tx, `UPDATE targets SET
+			payout_ranges = (SELECT payout_ranges FROM targets WHERE id = ?),
+			program_rules = (SELECT program_rules FROM targets WHERE id = ?)
+			WHERE id = ? AND EXISTS (SELECT 1 FROM targets WHERE id = ? AND (IFNULL(payout_ranges, '') != '' OR IFNULL(program_rules, '') != ''))`,
+			sourceID, sourceID, newID, sourceID)
+		if err != nil {
+			return n, err
+		}
+		constraints, err := execCloneCount(tx, `INSERT INTO target_program_constraints (target_id, max_rps, banned_paths, required_headers)
+			SELECT ?, max_rps, banned_paths, required_headers FROM target_program_constraints WHERE target_id = ?`, newID, sourceID)
+		return n + constraints, err
+	},
+	models.TargetCloneRedaction: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return execCloneCount(tx, `INSERT INTO target_redaction_rules (target_id, enabled, apply_at, replace_global, headers, json_paths, parameters)
+			SELECT ?, enabled, apply_at, replace_global, headers, json_paths, parameters FROM target_redaction_rules WHERE target_id = ?`, newID, sourceID)
+	},
+	models.TargetCloneClientProfile: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return execCloneCount(tx, `INSERT INTO target_client_profiles (target_id, user_agent, headers, cookies)
+			SELECT ?, user_agent, headers, cookies FROM target_client_profiles WHERE target_id = ?`, newID, sourceID)
+	},
+	models.TargetCloneVariables: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return execCloneCount(tx, `INSERT INTO target_variables (target_id, name, value)
+			SELECT ?, name, value FROM target_variables WHERE target_id = ? ORDER BY id`, newID, sourceID)
+	},
+	models.TargetCloneSavedViews: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return execCloneCount(tx, `INSERT INTO saved_views (target_id, view_type, name, filters)
+			SELECT ?, view_type, name, filters FROM saved_views WHERE target_id = ? ORDER BY id`, newID, sourceID)
+	},
+	models.TargetCloneSettings: func(tx *sql.Tx, sourceID, newID int64) (int, error) {
+		return execCloneCount(tx, `INSERT INTO target_runtime_settings (target_id, key, value)
+			SELECT ?, key, value FROM target_runtime_settings WHERE target_id = ?`, newID, sourceID)
+	},
+}
+
+func execCloneCount(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func cloneScopeRules(tx *sql.Tx, sourceID, newID int64, inScope bool) (int, error) {
+	return execCloneCount(tx, `INSERT INTO scope_rules (target_id, item_type, pattern, is_in_scope, is_wildcard, description)
+		SELECT ?, item_type, pattern, is_in_scope, is_wildcard, description FROM scope_rules WHERE target_id = ? AND is_in_scope = ? ORDER BY id`,
+		newID, sourceID, inScope)
+}
+
+// cloneTargetNotes copies the notes of a target one by one so each copy keeps its [[links]].
+func cloneTargetNotes(tx *sql.Tx, sourceID, newID int64) (int, error) {
+	rows, err := tx.Query(`SELECT id, title, content FROM notes WHERE target_id = ? ORDER BY id`, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	var notes []models.Note
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		notes = append(notes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, n := range notes {
+		res, err := tx.Exec(`INSERT INTO notes (target_id, title, content, created_at, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+			newID, n.Title, n.Content)
+		if err != nil {
+			return 0, fmt.Errorf("copying note %d: %w", n.ID, err)
+		}
+		copyID, _ := res.LastInsertId()
+		if _, err := tx.Exec(`INSERT INTO note_links (note_id, link_type, linked_id, link_text)
+			SELECT ?, link_type, linked_id, link_text FROM note_links WHERE note_id = ? ORDER BY id`, copyID, n.ID); err != nil {
+			return 0, fmt.Errorf("copying links of note %d: %w", n.ID, err)
+		}
+	}
+	return len(notes), nil
+}
+
+// CloneTarget creates a new target from an existing one, copying the requested components (all of
+// them when none are given) in a single transaction. Findings, traffic and other recon results are
+// never copied: the clone is a baseline for a new engagement against the same program.
+func CloneTarget(sourceID int64, req models.TargetCloneRequest) (models.TargetCloneResult, error) {
+	result := models.TargetCloneResult{SourceTargetID: sourceID, Copied: map[string]int{}}
+	source, err := GetTargetByID(sourceID)
+	if err != nil {
+		return result, err
+	}
+
+	components := req.Components
+	if len(components) == 0 {
+		components = models.TargetCloneComponents
+	}
+	selected := make(map[string]bool, len(components))
+	for _, c := range components {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if _, ok := targetCloneCopies[c]; !ok {
+			return result, fmt.Errorf("invalid component '%s', expected one of: %s", c, strings.Join(models.TargetCloneComponents, ", "))
+		}
+		selected[c] = true
+	}
+
+	codename := strings.TrimSpace(req.Codename)
+	if codename == "" {
+		return result, fmt.Errorf("codename is required")
+	}
+	platformID := req.PlatformID
+	if platformID == 0 {
+		platformID = source.PlatformID
+	}
+	link := strings.TrimSpace(req.Link)
+	if link == "" {
+		link = source.Link
+	}
+	notes := source.Notes
+	if req.Notes != nil {
+		notes = *req.Notes
+	}
+
+	var platformExists bool
+	if err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM platforms WHERE id = ?)", platformID).Scan(&platformExists); err != nil {
+		return result, fmt.Errorf("error checking platform existence for PlatformID %d: %w", platformID, err)
+	}
+	if !platformExists {
+		return result, fmt.Errorf("platform with ID %d does not exist", platformID)
+	}
+	var existingTargetID int64
+	err = DB.QueryRow("SELECT id FROM targets WHERE platform_id = ? AND LOWER(codename) = LOWER(?)", platformID, codename).Scan(&existingTargetID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return result, fmt.Errorf("error checking for existing target codename '%s': %w", codename, err)
+	}
+	if err == nil {
+		return result, fmt.Errorf("target with codename '%s' already exists for this platform", codename)
+	}
+	slug, err := newTargetSlug(codename)
+	if err != nil {
+		return result, err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return result, fmt.Errorf("beginning database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO targets (platform_id, slug, codename, link, notes) VALUES (?, ?, ?, ?, ?)`, platformID, slug, codename, link, notes)
+	if err != nil {
+		return result, fmt.Errorf("inserting cloned target: %w", err)
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return result, fmt.Errorf("getting last insert ID for cloned target: %w", err)
+	}
+	for _, c := range models.TargetCloneComponents {
+		if !selected[c] {
+			continue
+		}
+		n, err := targetCloneCopies[c](tx, sourceID, newID)
+		if err != nil {
+			return result, fmt.Errorf("copying %s of target %d: %w", c, sourceID, err)
+		}
+		result.Copied[c] = n
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("committing cloned target: %w", err)
+	}
+
+	result.Target, err = GetTargetByID(newID)
+	if err != nil {
+		return result, err
+	}
+	logger.Info("CloneTarget: Cloned target %d into %d ('%s'): %v", sourceID, newID, codename, result.Copied)
+	return result, nil
+}
@@ -48,6 +48,20 @@ func determineItemType(pattern string) string {
 	return "domain" // Default or consider error/unknown
 }
 
+// newTargetSlug derives a slug from a codename, adding a random suffix if it is already taken.
+func newTargetSlug(codename string) (string, error) {
+	slug := slugify(codename)
+	var existingSlugID int64
+	err := DB.QueryRow("SELECT id FROM targets WHERE slug = ?", slug).Scan(&existingSlugID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("error checking for existing slug '%s': %w", slug, err)
+	}
+	if err == nil { // Slug conflict
+		slug = fmt.Sprintf("%s-%s", slug, uuid.New().String()[:4])
+	}
+	return slug, nil
+}
+
 // CreateTargetWithScopeRules creates a new target and its associated scope rules within a transaction.
 func CreateTargetWithScopeRules(targetData models.TargetCreateRequest) (models.Target, error) {
 	var createdTarget models.Target
@@ -72,14 +86,9 @@ func CreateTargetWithScopeRules(targetData models.TargetCreateRequest) (models.T
 		return createdTarget, fmt.Errorf("target with codename '%s' already exists for this platform", targetData.Codename)
 	}
 
-	generatedSlug := slugify(targetData.Codename)
-	var existingSlugID int64
-	err = DB.QueryRow("SELECT id FROM targets WHERE slug = ?", generatedSlug).Scan(&existingSlugID)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return createdTarget, fmt.Errorf("error checking for existing slug '%s': %w", generatedSlug, err)
-	}
-	if err == nil { // Slug conflict
-		generatedSlug = fmt.Sprintf("%s-%s", generatedSlug, uuid.New().String()[:4])
+	generatedSlug, err := newTargetSlug(targetData.Codename)
+	if err != nil {
+		return createdTarget, err
 	}
 
 	tx, err := DB.Begin()
@@ -202,6 +211,9 @@ func GetTargetByID(targetID int64) (models.Target, error) {
 		// Log the error but still return the target details found so far
 		logger.Error("GetTargetByID: Error fetching scope rules for target %d: %v", targetID, err)
 	}
+	if err := loadTargetProgram(&t); err != nil {
+		logger.Error("GetTargetByID: Error fetching program metadata for target %d: %v", targetID, err)
+	}
 	return t, nil
 }
 
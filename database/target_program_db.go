@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"toolkit/models"
+)
+
+// scopeHistoryTimeFormat matches the millisecond timestamps written by the scope_rule_history triggers,
+// so bound times compare correctly against changed_at.
+const scopeHistoryTimeFormat = "2006-01-02 15:04:05.000"
+
+// loadTargetProgram fills in the bounty program metadata of a target.
+func loadTargetProgram(t *models.Target) error {
+	var payoutRanges, programRules sql.NullString
+	var fetchedAt, reviewedAt sql.NullTime
+	err := DB.QueryRow(`SELECT payout_ranges, program_rules, scope_fetched_at, scope_reviewed_at FROM targets WHERE id = ?`, t.ID).Scan(
+		&payoutRanges, &programRules, &fetchedAt, &reviewedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("querying program metadata for target %d: %w", t.ID, err)
+	}
+	if payoutRanges.String != "" {
+		if err := json.Unmarshal([]byte(payoutRanges.String), &t.PayoutRanges); err != nil {
+			return fmt.Errorf("decoding payout ranges for target %d: %w", t.ID, err)
+		}
+	}
+	t.ProgramRules = programRules.String
+	if fetchedAt.Valid {
+		t.ScopeFetchedAt = &fetchedAt.Time
+	}
+	if reviewedAt.Valid {
+		t.ScopeReviewedAt = &reviewedAt.Time
+	}
+	return nil
+}
+
+// UpdateTargetProgram stores the program metadata fields present in the request.
+func UpdateTargetProgram(targetID int64, req models.TargetProgramRequest) error {
+	if _, err := GetTargetByID(targetID); err != nil {
+		return err
+	}
+	if req.PayoutRanges != nil {
+		var payoutRanges interface{}
+		if len(*req.PayoutRanges) > 0 {
+			b, err := json.Marshal(*req.PayoutRanges)
+			if err != nil {
+				return fmt.Errorf("encoding payout ranges: %w", err)
+			}
+			payoutRanges = string(b)
+		}
+		if _, err := DB.Exec(`UPDATE targets SET payout_ranges = ? WHERE id = ?`, payoutRanges, targetID); err != nil {
+			return fmt.Errorf("updating payout ranges for target %d: %w", targetID, err)
+		}
+	}
+	if req.ProgramRules != nil {
+		if _, err := DB.Exec(`UPDATE targets SET program_rules = ? WHERE id = ?`, models.NullString(*req.ProgramRules), targetID); err != nil {
+			return fmt.Errorf("updating program rules for target %d: %w", targetID, err)
+		}
+	}
+	if req.ScopeFetchedAt != nil {
+		if _, err := DB.Exec(`UPDATE targets SET scope_fetched_at = ? WHERE id = ?`, req.ScopeFetchedAt.UTC().Format(scopeHistoryTimeFormat), targetID); err != nil {
+			return fmt.Errorf("updating scope fetch time for target %d: %w", targetID, err)
+		}
+	}
+	return nil
+}
+
+// MarkTargetScopeReviewed records that the scope changes of a target have been seen. The next
+// scope diff without an explicit start time begins here.
+func MarkTargetScopeReviewed(targetID int64) error {
+	res, err := DB.Exec(`UPDATE targets SET scope_reviewed_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = ?`, targetID)
+	if err != nil {
+		return fmt.Errorf("marking scope of target %d reviewed: %w", targetID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("target with ID %d not found", targetID)
+	}
+	return nil
+}
+
+func scanScopeRuleChange(scanner interface{ Scan(...interface{}) error }) (models.ScopeRuleChange, error) {
+	var c models.ScopeRuleChange
+	var ruleID sql.NullInt64
+	var description sql.NullString
+	err := scanner.Scan(&c.ID, &c.TargetID, &ruleID, &c.ChangeType, &c.ItemType, &c.Pattern, &c.IsInScope, &c.IsWildcard, &description, &c.ChangedAt)
+	if ruleID.Valid {
+		c.ScopeRuleID = &ruleID.Int64
+	}
+	c.Description = description.String
+	return c, err
+}
+
+const scopeRuleChangeColumns = `id, target_id, scope_rule_id, change_type, item_type, pattern, is_in_scope, is_wildcard, description, changed_at`
+
+// GetScopeRuleHistory returns the newest scope rule changes of a target.
+func GetScopeRuleHistory(targetID int64, limit int) ([]models.ScopeRuleChange, error) {
+	rows, err := DB.Query(`SELECT `+scopeRuleChangeColumns+` FROM scope_rule_history
+		WHERE target_id = ? ORDER BY changed_at DESC, id DESC LIMIT ?`, targetID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying scope history for target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	history := []models.ScopeRuleChange{}
+	for rows.Next() {
+		c, err := scanScopeRuleChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning scope history row: %w", err)
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+// GetScopeDiff returns the net scope changes of a target after since. A nil since means the last
+// time the scope was reviewed (or the beginning of the history if it never was).
+func GetScopeDiff(targetID int64, since *time.Time) (models.ScopeDiff, error) {
+	target, err := GetTargetByID(targetID)
+	if err != nil {
+		return models.ScopeDiff{}, err
+	}
+	if since == nil {
+		since = target.ScopeReviewedAt
+	}
+	diff := models.ScopeDiff{TargetID: targetID, Since: since, Until: time.Now().UTC(),
+		Added: []models.ScopeRuleChange{}, Removed: []models.ScopeRuleChange{}, Changes: []models.ScopeRuleChange{}}
+
+	query := `SELECT ` + scopeRuleChangeColumns + ` FROM scope_rule_history WHERE target_id = ?`
+	args := []interface{}{targetID}
+	if since != nil {
+		query += ` AND changed_at > ?`
+		args = append(args, since.UTC().Format(scopeHistoryTimeFormat))
+	}
+	rows, err := DB.Query(query+` ORDER BY changed_at ASC, id ASC`, args...)
+	if err != nil {
+		return diff, fmt.Errorf("querying scope changes for target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	// A rule is identified by what it matches; its first and last change in the window give the net effect.
+	type ruleChanges struct{ first, last models.ScopeRuleChange }
+	byRule := make(map[string]*ruleChanges)
+	var order []string
+	for rows.Next() {
+		c, err := scanScopeRuleChange(rows)
+		if err != nil {
+			return diff, fmt.Errorf("scanning scope change row: %w", err)
+		}
+		diff.Changes = append(diff.Changes, c)
+		key := fmt.Sprintf("%s\x00%s\x00%t", c.ItemType, c.Pattern, c.IsInScope)
+		if rc, ok := byRule[key]; ok {
+			rc.last = c
+		} else {
+			byRule[key] = &ruleChanges{first: c, last: c}
+			order = append(order, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return diff, err
+	}
+
+	for _, key := range order {
+		rc := byRule[key]
+		switch {
+		case rc.first.ChangeType == models.ScopeChangeAdded && rc.last.ChangeType == models.ScopeChangeAdded:
+			diff.Added = append(diff.Added, rc.last)
+		case rc.first.ChangeType == models.ScopeChangeRemoved && rc.last.ChangeType == models.ScopeChangeRemoved:
+			diff.Removed = append(diff.Removed, rc.last)
+		}
+	}
+	diff.Changed = len(diff.Added) > 0 || len(diff.Removed) > 0
+	return diff, nil
+}
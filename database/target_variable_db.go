@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+const targetVariableColumns = `id, target_id, name, value, created_at, updated_at`
+
+func scanTargetVariable(scanner interface{ Scan(...interface{}) error }) (models.TargetVariable, error) {
+	var v models.TargetVariable
+	err := scanner.Scan(&v.ID, &v.TargetID, &v.Name, &v.Value, &v.CreatedAt, &v.UpdatedAt)
+	return v, err
+}
+
+// CreateTargetVariable stores a new variable for a target and returns its ID.
+func CreateTargetVariable(v models.TargetVariable) (int64, error) {
+	res, err := DB.Exec(`INSERT INTO target_variables (target_id, name, value) VALUES (?, ?, ?)`, v.TargetID, v.Name, v.Value)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("a variable named '%s' already exists for target %d", v.Name, v.TargetID)
+		}
+		return 0, fmt.Errorf("inserting target variable: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// UpdateTargetVariable replaces the name and value of a target variable.
+func UpdateTargetVariable(v models.TargetVariable) error {
+	res, err := DB.Exec(`UPDATE target_variables SET name = ?, value = ? WHERE id = ? AND target_id = ?`, v.Name, v.Value, v.ID, v.TargetID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("a variable named '%s' already exists for target %d", v.Name, v.TargetID)
+		}
+		return fmt.Errorf("updating target variable %d: %w", v.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("variable with ID %d not found for target %d", v.ID, v.TargetID)
+	}
+	return nil
+}
+
+// SetTargetVariableValue sets the value of a target variable by name, creating it if needed.
+func SetTargetVariableValue(targetID int64, name, value string) error {
+	_, err := DB.Exec(`INSERT INTO target_variables (target_id, name, value) VALUES (?, ?, ?)
+		ON CONFLICT(target_id, name) DO UPDATE SET value = excluded.value`, targetID, name, value)
+	if err != nil {
+		return fmt.Errorf("setting variable '%s' of target %d: %w", name, targetID, err)
+	}
+	return nil
+}
+
+// GetTargetVariableByID fetches a variable of a target.
+func GetTargetVariableByID(targetID, id int64) (models.TargetVariable, error) {
+	v, err := scanTargetVariable(DB.QueryRow(`SELECT `+targetVariableColumns+` FROM target_variables WHERE id = ? AND target_id = ?`, id, targetID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, fmt.Errorf("variable with ID %d not found for target %d", id, targetID)
+		}
+		return v, fmt.Errorf("scanning target variable %d: %w", id, err)
+	}
+	return v, nil
+}
+
+// GetTargetVariables lists the variables of a target, sorted by name.
+func GetTargetVariables(targetID int64) ([]models.TargetVariable, error) {
+	rows, err := DB.Query(`SELECT `+targetVariableColumns+` FROM target_variables WHERE target_id = ? ORDER BY name`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("querying variables of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+
+	variables := []models.TargetVariable{}
+	for rows.Next() {
+		v, err := scanTargetVariable(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning target variable: %w", err)
+		}
+		variables = append(variables, v)
+	}
+	return variables, rows.Err()
+}
+
+// GetTargetVariableMap returns the variables of a target as a name to value map.
+func GetTargetVariableMap(targetID int64) (map[string]string, error) {
+	variables, err := GetTargetVariables(targetID)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(variables))
+	for _, v := range variables {
+		values[v.Name] = v.Value
+	}
+	return values, nil
+}
+
+// DeleteTargetVariable removes a variable of a target.
+func DeleteTargetVariable(targetID, id int64) error {
+	res, err := DB.Exec(`DELETE FROM target_variables WHERE id = ? AND target_id = ?`, id, targetID)
+	if err != nil {
+		return fmt.Errorf("deleting target variable %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("variable with ID %d not found for target %d", id, targetID)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/models"
+)
+
+const tlsConnectionColumns = `c.id, c.host, c.server_name, c.tls_version, c.cipher_suite, c.alpn, c.certificate_chain,
+	c.untrusted_reason, c.hostname_mismatch, c.first_seen_at`
+
+// GetOrCreateTLSConnection returns the ID of the TLS connection stored under fingerprint, storing c
+// first when there is none.
+func GetOrCreateTLSConnection(fingerprint string, c models.TLSConnection) (int64, error) {
+	chain, err := json.Marshal(c.CertificateChain)
+	if err != nil {
+		return 0, fmt.Errorf("encoding certificate chain of %s: %w", c.Host, err)
+	}
+	_, err = DB.Exec(`INSERT INTO tls_connections (fingerprint, host, server_name, tls_version, cipher_suite, alpn,
+			certificate_chain, untrusted_reason, hostname_mismatch)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(fingerprint) DO NOTHING`,
+		fingerprint, c.Host, c.ServerName, c.Version, c.CipherSuite, c.ALPN, string(chain), c.UntrustedReason, c.HostnameMismatch)
+	if err != nil {
+		return 0, fmt.Errorf("storing TLS connection to %s: %w", c.Host, err)
+	}
+	var id int64
+	if err := DB.QueryRow(`SELECT id FROM tls_connections WHERE fingerprint = ?`, fingerprint).Scan(&id); err != nil {
+		return 0, fmt.Errorf("looking up TLS connection to %s: %w", c.Host, err)
+	}
+	return id, nil
+}
+
+// GetTLSConnection returns a stored TLS connection.
+func GetTLSConnection(id int64) (models.TLSConnection, error) {
+	c, err := scanTLSConnection(DB.QueryRow(`SELECT `+tlsConnectionColumns+` FROM tls_connections c WHERE c.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return c, fmt.Errorf("TLS connection with ID %d not found", id)
+	}
+	if err != nil {
+		return c, fmt.Errorf("loading TLS connection %d: %w", id, err)
+	}
+	return c, nil
+}
+
+// ListTargetTLSConnections returns the TLS connections a target's logged requests were sent over,
+// with the number of requests and the latest one, by host and then most recent first. host limits
+// the list to one host.
+func ListTargetTLSConnections(targetID int64, host string) ([]models.TLSConnection, error) {
+	query := `SELECT ` + tlsConnectionColumns + `, COUNT(l.id), MAX(strftime('%Y-%m-%dT%H:%M:%SZ', l.timestamp))
+		FROM tls_connections c JOIN http_traffic_log l ON l.tls_connection_id = c.id
+		WHERE l.target_id = ?`
+	args := []interface{}{targetID}
+	if host != "" {
+		query += ` AND c.host = ?`
+		args = append(args, strings.ToLower(host))
+	}
+	rows, err := DB.Query(query+` GROUP BY c.id ORDER BY c.host, MAX(l.id) DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing TLS connections of target %d: %w", targetID, err)
+	}
+	defer rows.Close()
+	connections := []models.TLSConnection{}
+	for rows.Next() {
+		var requests int
+		var lastSeen sql.NullString
+		c, err := scanTLSConnection(rows, &requests, &lastSeen)
+		if err != nil {
+			return nil, fmt.Errorf("scanning TLS connection: %w", err)
+		}
+		c.Requests = requests
+		if t, err := time.Parse(time.RFC3339, lastSeen.String); err == nil {
+			c.LastSeenAt = &t
+		}
+		connections = append(connections, c)
+	}
+	return connections, rows.Err()
+}
+
+// scanTLSConnection scans the tlsConnectionColumns of a row, followed by extra.
+func scanTLSConnection(row interface{ Scan(...any) error }, extra ...any) (models.TLSConnection, error) {
+	var c models.TLSConnection
+	var chain string
+	dest := append([]any{&c.ID, &c.Host, &c.ServerName, &c.Version, &c.CipherSuite, &c.ALPN, &chain,
+		&c.UntrustedReason, &c.HostnameMismatch, &c.FirstSeenAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return c, err
+	}
+	c.CertificateChain = []models.TLSCertificateSummary{}
+	if err := json.Unmarshal([]byte(chain), &c.CertificateChain); err != nil {
+		return c, fmt.Errorf("decoding certificate chain of TLS connection %d: %w", c.ID, err)
+	}
+	return c, nil
+}
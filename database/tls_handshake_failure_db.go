@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"toolkit/models"
+)
+
+// RecordTLSHandshakeFailure counts a failed client handshake for a host.
+func RecordTLSHandshakeFailure(host, errMsg string, certificateRejected bool) error {
+	rejected := 0
+	if certificateRejected {
+		rejected = 1
+	}
+	_, err := DB.Exec(`INSERT INTO tls_handshake_failures (host, failures, certificate_rejections, last_error)
+		VALUES (?, 1, ?, ?)
+		ON CONFLICT(host) DO UPDATE SET failures = failures + 1,
+			certificate_rejections = certificate_rejections + excluded.certificate_rejections,
+			last_error = excluded.last_error, last_failure_at = CURRENT_TIMESTAMP`,
+		host, rejected, errMsg)
+	if err != nil {
+		return fmt.Errorf("recording TLS handshake failure for %s: %w", host, err)
+	}
+	return nil
+}
+
+// RecordTLSHandshakeSuccess counts an intercepted connection that carried a request, for a host
+// with recorded failures.
+func RecordTLSHandshakeSuccess(host string) error {
+	_, err := DB.Exec(`UPDATE tls_handshake_failures SET successes = successes + 1, last_success_at = CURRENT_TIMESTAMP WHERE host = ?`, host)
+	if err != nil {
+		return fmt.Errorf("recording TLS handshake success for %s: %w", host, err)
+	}
+	return nil
+}
+
+// ListTLSHandshakeFailures returns the hosts with failed client handshakes, most failures first.
+func ListTLSHandshakeFailures() ([]models.TLSHandshakeFailure, error) {
+	rows, err := DB.Query(`SELECT host, failures, successes, certificate_rejections, last_error,
+			first_failure_at, last_failure_at, last_success_at
+		FROM tls_handshake_failures ORDER BY failures DESC, host`)
+	if err != nil {
+		return nil, fmt.Errorf("listing TLS handshake failures: %w", err)
+	}
+	defer rows.Close()
+	failures := []models.TLSHandshakeFailure{}
+	for rows.Next() {
+		var f models.TLSHandshakeFailure
+		var lastSuccess sql.NullTime
+		if err := rows.Scan(&f.Host, &f.Failures, &f.Successes, &f.CertificateRejections, &f.LastError,
+			&f.FirstFailureAt, &f.LastFailureAt, &lastSuccess); err != nil {
+			return nil, fmt.Errorf("scanning TLS handshake failure: %w", err)
+		}
+		if lastSuccess.Valid {
+			f.LastSuccessAt = &lastSuccess.Time
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// DeleteTLSHandshakeFailure forgets the handshake failures of a host.
+func DeleteTLSHandshakeFailure(host string) error {
+	result, err := DB.Exec(`DELETE FROM tls_handshake_failures WHERE host = ?`, host)
+	if err != nil {
+		return fmt.Errorf("deleting TLS handshake failures of %s: %w", host, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("TLS handshake failures of host %s not found", host)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"strings"
+	"toolkit/models"
+)
+
+// manualLogSourcesSQL is models.ManualLogSources as an SQL list of string literals.
+var manualLogSourcesSQL = "('" + strings.Join(models.ManualLogSources, "', '") + "')"
+
+// trafficOriginExpr classifies the log_source column column as 'manual' or 'automated' traffic.
+func trafficOriginExpr(column string) string {
+	return "CASE WHEN COALESCE(NULLIF(" + column + ", ''), '" + models.LogSourceProxy + "') IN " + manualLogSourcesSQL +
+		" THEN '" + models.TrafficOriginManual + "' ELSE '" + models.TrafficOriginAutomated + "' END"
+}
+
+// TrafficOriginCondition returns a WHERE condition matching the manual or automated traffic by
+// the log_source column column. ok is false for any other origin.
+func TrafficOriginCondition(column, origin string) (condition string, ok bool) {
+	source := "COALESCE(NULLIF(" + column + ", ''), '" + models.LogSourceProxy + "')"
+	switch origin {
+	case models.TrafficOriginManual:
+		return source + " IN " + manualLogSourcesSQL, true
+	case models.TrafficOriginAutomated:
+		return source + " NOT IN " + manualLogSourcesSQL, true
+	}
+	return "", false
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"toolkit/models"
+)
+
+// RecordTrafficSend stores that a traffic log entry was sent to another module and returns the
+// record.
+func RecordTrafficSend(send models.TrafficSend) (models.TrafficSend, error) {
+	var ids sql.NullString
+	if len(send.ArtifactIDs) > 0 {
+		data, err := json.Marshal(send.ArtifactIDs)
+		if err != nil {
+			return send, fmt.Errorf("encoding artifact IDs: %w", err)
+		}
+		ids = sql.NullString{String: string(data), Valid: true}
+	}
+	err := DB.QueryRow(`INSERT INTO traffic_sends (log_id, destination, artifact_type, artifact_ids, user)
+		VALUES (?, ?, ?, ?, ?) RETURNING id, created_at`,
+		send.LogID, send.Destination, send.ArtifactType, ids, send.User).Scan(&send.ID, &send.CreatedAt)
+	if err != nil {
+		return send, fmt.Errorf("recording send of log %d to %s: %w", send.LogID, send.Destination, err)
+	}
+	return send, nil
+}
+
+// GetTrafficSends lists where a traffic log entry was sent, newest first.
+func GetTrafficSends(logID int64) ([]models.TrafficSend, error) {
+	rows, err := DB.Query(`SELECT id, log_id, destination, artifact_type, artifact_ids, user, created_at
+		FROM traffic_sends WHERE log_id = ? ORDER BY id DESC`, logID)
+	if err != nil {
+		return nil, fmt.Errorf("querying sends of log %d: %w", logID, err)
+	}
+	defer rows.Close()
+	sends := []models.TrafficSend{}
+	for rows.Next() {
+		var s models.TrafficSend
+		var ids sql.NullString
+		if err := rows.Scan(&s.ID, &s.LogID, &s.Destination, &s.ArtifactType, &ids, &s.User, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning send of log %d: %w", logID, err)
+		}
+		if ids.Valid {
+			if err := json.Unmarshal([]byte(ids.String), &s.ArtifactIDs); err != nil {
+				return nil, fmt.Errorf("decoding artifact IDs of send %d: %w", s.ID, err)
+			}
+		}
+		sends = append(sends, s)
+	}
+	return sends, rows.Err()
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"toolkit/models"
+)
+
+// trafficLogsCTE derives the host, query-less URL and origin of each logged request of a target.
+// With a window, only requests between the second and third query arguments are included; with an
+// origin, only manual or automated requests.
+func trafficLogsCTE(window bool, origin string) string {
+	where := "target_id = ?"
+	if window {
+		where += " AND julianday(timestamp) >= julianday(?) AND julianday(timestamp) < julianday(?)"
+	}
+	if condition, ok := TrafficOriginCondition("log_source", origin); ok {
+		where += " AND " + condition
+	}
+	return `WITH raw AS (
+			SELECT timestamp, COALESCE(request_method, '') AS method, COALESCE(request_url, '') AS url,
+				substr(COALESCE(request_url, ''), instr(COALESCE(request_url, ''), '://') + 3) AS rest,
+				COALESCE(response_status_code, 0) AS status, COALESCE(response_body_size, 0) AS size, duration_ms,
+				COALESCE(NULLIF(log_source, ''), 'Proxy') AS source, ` + trafficOriginExpr("log_source") + ` AS origin
+			FROM http_traffic_log WHERE ` + where + `
+		), logs AS (
+			SELECT *, lower(substr(rest, 1, instr(rest || '/', '/') - 1)) AS host,
+				CASE WHEN instr(url, '?') > 0 THEN substr(url, 1, instr(url, '?') - 1) ELSE url END AS base_url
+			FROM raw
+		) `
+}
+
+// GetTrafficStats aggregates a target's logged traffic for the dashboard.
+func GetTrafficStats(filters models.TrafficStatsFilters) (models.TrafficStats, error) {
+	stats := models.TrafficStats{
+		TargetID:      filters.TargetID,
+		From:          filters.From,
+		To:            filters.To,
+		Bucket:        filters.Bucket,
+		Origin:        filters.Origin,
+		Timeline:      []models.TrafficTimeBucket{},
+		StatusCodes:   []models.StatusCodeCount{},
+		StatusClasses: map[string]int64{},
+		Sources:       []models.NamedCount{},
+		Origins:       []models.NamedCount{},
+		TopHosts:      []models.NamedCount{},
+		NewHosts:      []models.NewHostsBucket{},
+	}
+	cte := trafficLogsCTE(true, filters.Origin)
+	args := []interface{}{filters.TargetID, filters.From.UTC().Format(time.RFC3339), filters.To.UTC().Format(time.RFC3339)}
+
+	var first, last sql.NullString
+	err := DB.QueryRow(cte+`SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(AVG(duration_ms), 0), COALESCE(MAX(duration_ms), 0),
+			COUNT(DISTINCT host), COUNT(DISTINCT base_url), COALESCE(SUM(status >= 500), 0),
+			MIN(strftime('%Y-%m-%dT%H:%M:%SZ', timestamp)), MAX(strftime('%Y-%m-%dT%H:%M:%SZ', timestamp))
+		FROM logs`, args...).
+		Scan(&stats.Summary.Requests, &stats.Summary.BytesReceived, &stats.Summary.AvgDurationMs, &stats.Summary.MaxDurationMs,
+			&stats.Summary.DistinctHosts, &stats.Summary.DistinctURLs, &stats.Summary.ErrorResponses, &first, &last)
+	if err != nil {
+		return stats, fmt.Errorf("summarizing traffic of target %d: %w", filters.TargetID, err)
+	}
+	if t, err := time.Parse(time.RFC3339, first.String); err == nil {
+		stats.Summary.FirstRequestAt = &t
+	}
+	if t, err := time.Parse(time.RFC3339, last.String); err == nil {
+		stats.Summary.LastRequestAt = &t
+	}
+
+	if stats.Timeline, err = trafficTimeline(cte, args, filters); err != nil {
+		return stats, err
+	}
+
+	rows, err := DB.Query(cte+`SELECT status, COUNT(*) FROM logs GROUP BY status ORDER BY COUNT(*) DESC, status`, args...)
+	if err != nil {
+		return stats, fmt.Errorf("counting status codes of target %d: %w", filters.TargetID, err)
+	}
+	for rows.Next() {
+		var c models.StatusCodeCount
+		if err := rows.Scan(&c.StatusCode, &c.Count); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("scanning status code count: %w", err)
+		}
+		stats.StatusCodes = append(stats.StatusCodes, c)
+		class := "none"
+		if c.StatusCode > 0 {
+			class = strconv.Itoa(c.StatusCode/100) + "xx"
+		}
+		stats.StatusClasses[class] += c.Count
+	}
+	rows.Close()
+
+	if stats.Sources, err = namedCounts(cte+`SELECT source, COUNT(*) FROM logs GROUP BY source ORDER BY COUNT(*) DESC`, args...); err != nil {
+		return stats, fmt.Errorf("counting log sources of target %d: %w", filters.TargetID, err)
+	}
+	if stats.Origins, err = namedCounts(cte+`SELECT origin, COUNT(*) FROM logs GROUP BY origin ORDER BY COUNT(*) DESC`, args...); err != nil {
+		return stats, fmt.Errorf("counting traffic origins of target %d: %w", filters.TargetID, err)
+	}
+	if stats.TopHosts, err = namedCounts(cte+`SELECT host, COUNT(*) FROM logs WHERE host != '' GROUP BY host ORDER BY COUNT(*) DESC, host LIMIT ?`, append(args, filters.Top)...); err != nil {
+		return stats, fmt.Errorf("counting hosts of target %d: %w", filters.TargetID, err)
+	}
+	if stats.TopEndpoints, err = endpointStats(cte+`SELECT method, base_url, COUNT(*), COALESCE(AVG(duration_ms), 0), COALESCE(SUM(size), 0)
+		FROM logs GROUP BY method, base_url ORDER BY COUNT(*) DESC, base_url LIMIT ?`, append(args, filters.Top)...); err != nil {
+		return stats, fmt.Errorf("listing top endpoints of target %d: %w", filters.TargetID, err)
+	}
+	if stats.SlowEndpoints, err = endpointStats(cte+`SELECT method, base_url, COUNT(*), AVG(duration_ms), COALESCE(SUM(size), 0)
+		FROM logs WHERE duration_ms IS NOT NULL GROUP BY method, base_url HAVING COUNT(*) >= 3
+		ORDER BY AVG(duration_ms) DESC, base_url LIMIT ?`, append(args, filters.Top)...); err != nil {
+		return stats, fmt.Errorf("listing slow endpoints of target %d: %w", filters.TargetID, err)
+	}
+	if stats.NewHosts, err = newHostsPerDay(filters); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// trafficTimeline returns one bucket per hour or day of the window, including empty ones.
+func trafficTimeline(cte string, args []interface{}, filters models.TrafficStatsFilters) ([]models.TrafficTimeBucket, error) {
+	format, step := "%Y-%m-%dT%H:00:00Z", time.Hour
+	if filters.Bucket == "day" {
+		format, step = "%Y-%m-%dT00:00:00Z", 24*time.Hour
+	}
+	rows, err := DB.Query(cte+`SELECT strftime('`+format+`', timestamp) AS bucket, COUNT(*), COALESCE(SUM(size), 0),
+			COALESCE(AVG(duration_ms), 0), COALESCE(SUM(status >= 500), 0)
+		FROM logs GROUP BY bucket`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("building traffic timeline of target %d: %w", filters.TargetID, err)
+	}
+	defer rows.Close()
+	byStart := make(map[time.Time]models.TrafficTimeBucket)
+	for rows.Next() {
+		var start sql.NullString
+		var b models.TrafficTimeBucket
+		if err := rows.Scan(&start, &b.Requests, &b.BytesReceived, &b.AvgDurationMs, &b.Errors); err != nil {
+			return nil, fmt.Errorf("scanning traffic timeline bucket: %w", err)
+		}
+		t, err := time.Parse(time.RFC3339, start.String)
+		if err != nil {
+			continue
+		}
+		b.Start = t
+		byStart[t] = b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	timeline := []models.TrafficTimeBucket{}
+	for t := filters.From.UTC().Truncate(step); t.Before(filters.To); t = t.Add(step) {
+		b, ok := byStart[t]
+		if !ok {
+			b = models.TrafficTimeBucket{Start: t}
+		}
+		timeline = append(timeline, b)
+	}
+	return timeline, nil
+}
+
+// newHostsPerDay returns the hosts whose first logged request for the target falls in the window.
+func newHostsPerDay(filters models.TrafficStatsFilters) ([]models.NewHostsBucket, error) {
+	rows, err := DB.Query(trafficLogsCTE(false, filters.Origin)+`SELECT host, MIN(julianday(timestamp)) AS first_seen FROM logs
+		WHERE host != '' GROUP BY host
+		HAVING first_seen >= julianday(?) AND first_seen < julianday(?)`,
+		filters.TargetID, filters.From.UTC().Format(time.RFC3339), filters.To.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("finding new hosts of target %d: %w", filters.TargetID, err)
+	}
+	defer rows.Close()
+	byDay := make(map[time.Time]*models.NewHostsBucket)
+	for rows.Next() {
+		var host string
+		var firstSeen float64
+		if err := rows.Scan(&host, &firstSeen); err != nil {
+			return nil, fmt.Errorf("scanning new host: %w", err)
+		}
+		// Julian day 2440587.5 is the Unix epoch.
+		day := time.Unix(int64((firstSeen-2440587.5)*86400), 0).UTC().Truncate(24 * time.Hour)
+		b, ok := byDay[day]
+		if !ok {
+			b = &models.NewHostsBucket{Day: day}
+			byDay[day] = b
+		}
+		b.Hosts = append(b.Hosts, host)
+		b.Count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	buckets := []models.NewHostsBucket{}
+	for _, b := range byDay {
+		sort.Strings(b.Hosts)
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Day.Before(buckets[j].Day) })
+	return buckets, nil
+}
+
+func namedCounts(query string, args ...interface{}) ([]models.NamedCount, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []models.NamedCount{}
+	for rows.Next() {
+		var c models.NamedCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func endpointStats(query string, args ...interface{}) ([]models.EndpointStats, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	endpoints := []models.EndpointStats{}
+	for rows.Next() {
+		var e models.EndpointStats
+		if err := rows.Scan(&e.Method, &e.URL, &e.Requests, &e.AvgDurationMs, &e.BytesReceived); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
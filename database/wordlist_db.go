@@ -0,0 +1,237 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"toolkit/models"
+)
+
+var wordlistColumns = `w.id, w.name, w.description, w.target_id, w.source, w.word_count, w.size, w.sha256,
+	COALESCE((SELECT GROUP_CONCAT(t.name, char(10)) FROM tag_associations ta JOIN tags t ON t.id = ta.tag_id
+		WHERE ta.item_type = '` + models.TagItemWordlist + `' AND ta.item_id = w.id), ''), w.created_at, w.updated_at`
+
+func scanWordlist(scanner interface{ Scan(...interface{}) error }) (models.Wordlist, error) {
+	var w models.Wordlist
+	var tags string
+	err := scanner.Scan(&w.ID, &w.Name, &w.Description, &w.TargetID, &w.Source, &w.WordCount, &w.Size, &w.SHA256,
+		&tags, &w.CreatedAt, &w.UpdatedAt)
+	w.Tags = []string{}
+	if tags != "" {
+		w.Tags = strings.Split(tags, "\n")
+	}
+	return w, err
+}
+
+// wordlistNameTaken reports whether another wordlist than id is named name.
+func wordlistNameTaken(name string, id int64) (bool, error) {
+	var n int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM wordlists WHERE name = ? AND id != ?`, name, id).Scan(&n); err != nil {
+		return false, fmt.Errorf("looking up wordlist name: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CreateWordlist records a wordlist and returns its ID. The words are stored by the caller.
+func CreateWordlist(w models.Wordlist) (int64, error) {
+	taken, err := wordlistNameTaken(w.Name, 0)
+	if err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, fmt.Errorf("a wordlist named '%s' already exists", w.Name)
+	}
+	res, err := DB.Exec(`INSERT INTO wordlists (name, description, target_id, source, word_count, size, sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, w.Name, w.Description, w.TargetID, w.Source, w.WordCount, w.Size, w.SHA256)
+	if err != nil {
+		return 0, fmt.Errorf("inserting wordlist: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetWordlistByID fetches a wordlist.
+func GetWordlistByID(id int64) (models.Wordlist, error) {
+	w, err := scanWordlist(DB.QueryRow(`SELECT `+wordlistColumns+` FROM wordlists w WHERE w.id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return w, fmt.Errorf("wordlist with ID %d not found", id)
+		}
+		return w, fmt.Errorf("scanning wordlist %d: %w", id, err)
+	}
+	return w, nil
+}
+
+// GetWordlistByName fetches a wordlist by its name.
+func GetWordlistByName(name string) (models.Wordlist, error) {
+	w, err := scanWordlist(DB.QueryRow(`SELECT `+wordlistColumns+` FROM wordlists w WHERE w.name = ?`, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return w, fmt.Errorf("wordlist named '%s' not found", name)
+		}
+		return w, fmt.Errorf("scanning wordlist '%s': %w", name, err)
+	}
+	return w, nil
+}
+
+// GetLatestGeneratedWordlist returns the wordlist most recently generated for a target, or nil
+// when none was.
+func GetLatestGeneratedWordlist(targetID int64) (*models.Wordlist, error) {
+	w, err := scanWordlist(DB.QueryRow(`SELECT `+wordlistColumns+` FROM wordlists w WHERE w.target_id = ? AND w.source = ?
+		ORDER BY w.updated_at DESC, w.id DESC LIMIT 1`, targetID, models.WordlistSourceGenerated))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("scanning generated wordlist of target %d: %w", targetID, err)
+	}
+	return &w, nil
+}
+
+// GetWordlists lists wordlists by name.
+func GetWordlists(filters models.WordlistFilters) ([]models.Wordlist, error) {
+	var conditions []string
+	var args []interface{}
+	if filters.TargetID != 0 {
+		conditions = append(conditions, "w.target_id = ?")
+		args = append(args, filters.TargetID)
+	}
+	if filters.Source != "" {
+		conditions = append(conditions, "w.source = ?")
+		args = append(args, filters.Source)
+	}
+	if filters.Tag != "" {
+		conditions = append(conditions, `w.id IN (SELECT ta.item_id FROM tag_associations ta JOIN tags t ON t.id = ta.tag_id
+			WHERE ta.item_type = ? AND t.name = ? COLLATE NOCASE)`)
+		args = append(args, models.TagItemWordlist, filters.Tag)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := DB.Query(`SELECT `+wordlistColumns+` FROM wordlists w`+where+` ORDER BY w.name COLLATE NOCASE`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying wordlists: %w", err)
+	}
+	defer rows.Close()
+
+	wordlists := []models.Wordlist{}
+	for rows.Next() {
+		w, err := scanWordlist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning wordlist: %w", err)
+		}
+		wordlists = append(wordlists, w)
+	}
+	return wordlists, rows.Err()
+}
+
+// UpdateWordlist renames a wordlist and replaces its description.
+func UpdateWordlist(id int64, name, description string) error {
+	taken, err := wordlistNameTaken(name, id)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("a wordlist named '%s' already exists", name)
+	}
+	res, err := DB.Exec(`UPDATE wordlists SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, name, description, id)
+	if err != nil {
+		return fmt.Errorf("updating wordlist %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("wordlist with ID %d not found", id)
+	}
+	return nil
+}
+
+// SetWordlistContent records the word count, size and SHA-256 of a wordlist's rewritten file.
+func SetWordlistContent(id int64, wordCount, size int64, sha256 string) error {
+	_, err := DB.Exec(`UPDATE wordlists SET word_count = ?, size = ?, sha256 = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		wordCount, size, sha256, id)
+	if err != nil {
+		return fmt.Errorf("updating content of wordlist %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteWordlist removes a wordlist. Its tag associations are removed by a trigger.
+func DeleteWordlist(id int64) error {
+	res, err := DB.Exec(`DELETE FROM wordlists WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting wordlist %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("wordlist with ID %d not found", id)
+	}
+	return nil
+}
+
+// GetWordlistIDs returns the IDs of every wordlist, for pruning files left behind.
+func GetWordlistIDs() (map[int64]bool, error) {
+	rows, err := DB.Query(`SELECT id FROM wordlists`)
+	if err != nil {
+		return nil, fmt.Errorf("querying wordlist IDs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning wordlist ID: %w", err)
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// Kinds of values ForEachTargetWordSource passes on.
+const (
+	WordSourceURL       = "url"        // Captured, discovered or canonical URL
+	WordSourcePath      = "path"       // API endpoint path pattern
+	WordSourceParamKeys = "param_keys" // Comma-separated parameter names of a parameterized URL
+	WordSourceJSURL     = "js_url"     // Absolute URL found in JavaScript
+	WordSourceJSPath    = "js_path"    // Relative path or route found in JavaScript
+)
+
+// ForEachTargetWordSource calls fn with the values target-specific wordlists are generated from:
+// the URLs of the target's latest maxLogs captured requests, its discovered and canonical URLs, API
+// endpoint patterns, parameterized URL keys and the paths and URLs extracted from its JavaScript.
+func ForEachTargetWordSource(targetID int64, maxLogs int, fn func(kind, value string)) error {
+	queries := []struct {
+		kind, query string
+		args        []interface{}
+	}{
+		{WordSourceURL, `SELECT request_url FROM (SELECT request_url FROM http_traffic_log WHERE target_id = ? ORDER BY id DESC LIMIT ?)`,
+			[]interface{}{targetID, maxLogs}},
+		{WordSourceURL, `SELECT DISTINCT url FROM discovered_urls WHERE target_id = ?`, []interface{}{targetID}},
+		{WordSourceURL, `SELECT example_url FROM canonical_urls WHERE target_id = ?`, []interface{}{targetID}},
+		{WordSourcePath, `SELECT path_pattern FROM api_endpoints WHERE target_id = ?`, []interface{}{targetID}},
+		{WordSourceParamKeys, `SELECT param_keys FROM parameterized_urls WHERE target_id = ?`, []interface{}{targetID}},
+		{WordSourceJSURL, `SELECT DISTINCT value FROM js_endpoints WHERE target_id = ? AND kind = ?`,
+			[]interface{}{targetID, models.JSEndpointKindURL}},
+		{WordSourceJSPath, `SELECT DISTINCT value FROM js_endpoints WHERE target_id = ? AND kind = ?`,
+			[]interface{}{targetID, models.JSEndpointKindPath}},
+	}
+	for _, q := range queries {
+		rows, err := DB.Query(q.query, q.args...)
+		if err != nil {
+			return fmt.Errorf("querying %s word sources of target %d: %w", q.kind, targetID, err)
+		}
+		for rows.Next() {
+			var value sql.NullString
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				return fmt.Errorf("scanning %s word source: %w", q.kind, err)
+			}
+			if value.String != "" {
+				fn(q.kind, value.String)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Annotation entity types. They match the item_type values used for tag associations where those overlap.
+const (
+	AnnotationEntityHTTPLog       = "httplog"
+	AnnotationEntityDomain        = "domain"
+	AnnotationEntityFinding       = "finding"
+	AnnotationEntityChecklistItem = "checklist_item"
+	AnnotationEntityModifierTask  = "modifier_task"
+)
+
+// AnnotationEntityTypes lists the entity types annotations can be attached to.
+var AnnotationEntityTypes = []string{
+	AnnotationEntityHTTPLog, AnnotationEntityDomain, AnnotationEntityFinding, AnnotationEntityChecklistItem, AnnotationEntityModifierTask,
+}
+
+// Annotation is a timestamped comment attached to an entity. An entity can have any number of them.
+type Annotation struct {
+	ID          int64         `json:"id"`
+	TargetID    sql.NullInt64 `json:"target_id,omitempty"` // Copied from the entity when the annotation is created
+	EntityType  string        `json:"entity_type"`
+	EntityID    int64         `json:"entity_id"`
+	EntityLabel string        `json:"entity_label,omitempty"` // URL, domain name, title, etc. of the entity, for display
+	Content     string        `json:"content"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// AnnotationRequest is the payload for creating or updating an annotation.
+type AnnotationRequest struct {
+	EntityType string `json:"entity_type"`
+	EntityID   int64  `json:"entity_id"`
+	Content    string `json:"content"`
+}
+
+// AnnotationFilters are the query options for listing annotations.
+type AnnotationFilters struct {
+	TargetID   int64
+	EntityType string
+	EntityID   int64
+	Since      time.Time
+	Until      time.Time
+	Search     string
+	SortOrder  string // ASC (chronological, default) or DESC
+	Page       int
+	Limit      int
+}
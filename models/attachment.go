@@ -0,0 +1,43 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Attachment entity types.
+const (
+	AttachmentEntityTarget        = "target"
+	AttachmentEntityFinding       = "finding"
+	AttachmentEntityNote          = "note"
+	AttachmentEntityChecklistItem = "checklist_item"
+)
+
+// AttachmentEntityTypes lists the entity types files can be attached to.
+var AttachmentEntityTypes = []string{
+	AttachmentEntityTarget, AttachmentEntityFinding, AttachmentEntityNote, AttachmentEntityChecklistItem,
+}
+
+// Attachment is a file (screenshot, PoC, exported report, tool output, ...) attached to an
+// entity. Files are stored once per SHA-256, however many entities they are attached to.
+type Attachment struct {
+	ID          int64         `json:"id"`
+	TargetID    sql.NullInt64 `json:"target_id,omitempty"` // Copied from the entity when the file is attached; unset for notes
+	EntityType  string        `json:"entity_type"`
+	EntityID    int64         `json:"entity_id"`
+	EntityLabel string        `json:"entity_label,omitempty"` // Codename, title, etc. of the entity, for display
+	FileName    string        `json:"file_name" example:"poc.png"`
+	ContentType string        `json:"content_type" example:"image/png"`
+	Size        int64         `json:"size"`
+	SHA256      string        `json:"sha256"`
+	Description string        `json:"description,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Existing    bool          `json:"existing,omitempty"` // The same file was already attached to the entity
+}
+
+// AttachmentFilters filters the attachment list. Zero values match everything.
+type AttachmentFilters struct {
+	TargetID   int64
+	EntityType string
+	EntityID   int64
+}
@@ -0,0 +1,42 @@
+package models
+
+// Authentication schemes detected in captured requests.
+const (
+	AuthSchemeCookie = "cookie"  // Session cookies (cookie_jar.session_cookies)
+	AuthSchemeBearer = "bearer"  // Authorization: Bearer
+	AuthSchemeBasic  = "basic"   // Authorization: Basic
+	AuthSchemeAPIKey = "api_key" // An API key header or query parameter, or another Authorization scheme
+	AuthSchemeNone   = "none"    // No credentials
+)
+
+// AuthSchemeReport classifies the endpoints of a target's captured traffic by the credentials
+// their requests carried, and lists the endpoints that answered a request without credentials
+// with a 2xx while sibling endpoints require them.
+type AuthSchemeReport struct {
+	TargetID        int64          `json:"target_id"`
+	LogsScanned     int            `json:"logs_scanned"`
+	Schemes         map[string]int `json:"schemes"` // Endpoints per scheme
+	Endpoints       []EndpointAuth `json:"endpoints"`
+	Unauthenticated []EndpointAuth `json:"unauthenticated"` // Possibly unauthenticated endpoints, the flagged ones of Endpoints
+}
+
+// EndpointAuth is how the requests to one endpoint (method, host and path, with numeric and UUID
+// segments as {id} and {uuid}) authenticated.
+type EndpointAuth struct {
+	Method                  string   `json:"method" example:"GET"`
+	Host                    string   `json:"host" example:"api.example.com"`
+	Endpoint                string   `json:"endpoint" example:"/api/users/{id}"`
+	Scheme                  string   `json:"scheme" enums:"cookie,bearer,basic,api_key,none"` // Most frequent scheme of its authenticated requests, none without any
+	Schemes                 []string `json:"schemes"`                                         // All schemes seen
+	APIKeyNames             []string `json:"api_key_names,omitempty"`                         // Headers and query parameters carrying API keys
+	Requests                int      `json:"requests"`
+	AuthenticatedRequests   int      `json:"authenticated_requests"`
+	UnauthenticatedRequests int      `json:"unauthenticated_requests"`
+	UnauthenticatedSuccess  int      `json:"unauthenticated_success"` // Requests without credentials answered with a 2xx
+	AuthRequired            int      `json:"auth_required"`           // Requests without credentials answered with a 401 or 403
+	RequiresAuth            bool     `json:"requires_auth"`           // Refused requests without credentials, or only ever got requests with an Authorization header or API key
+	Flagged                 bool     `json:"flagged"`
+	Reason                  string   `json:"reason,omitempty"`
+	ExampleLogID            int64    `json:"example_log_id"`
+	UnauthenticatedLogID    int64    `json:"unauthenticated_log_id,omitempty"` // Latest request without credentials answered with a 2xx
+}
@@ -0,0 +1,142 @@
+package models
+
+import "time"
+
+// Kinds of authentication tokens tracked in captured traffic.
+const (
+	AuthTokenKindJWT  = "jwt"
+	AuthTokenKindSAML = "saml"
+)
+
+// Encodings of a SAML message as it travels: base64 (HTTP-POST binding), base64 of raw DEFLATE
+// (HTTP-Redirect binding) or plain XML.
+const (
+	SAMLEncodingBase64  = "base64"
+	SAMLEncodingDeflate = "deflate"
+	SAMLEncodingXML     = "xml"
+)
+
+// What a forged SAML message does with the signatures of the original.
+const (
+	SAMLSignatureKeep   = "keep"   // Leave them, now covering edited content
+	SAMLSignatureStrip  = "strip"  // Remove every signature
+	SAMLSignatureResign = "resign" // Sign again with the given key
+)
+
+// AuthToken is a JWT or SAML message seen in a target's captured traffic.
+type AuthToken struct {
+	ID              int64      `json:"id"`
+	TargetID        int64      `json:"target_id"`
+	Kind            string     `json:"kind" enums:"jwt,saml"`
+	Token           string     `json:"token"` // Compact JWT, or the SAML message as sent (URL-decoded)
+	Algorithm       string     `json:"algorithm,omitempty"`
+	Issuer          string     `json:"issuer,omitempty"`
+	Subject         string     `json:"subject,omitempty"` // JWT sub, or SAML NameID
+	ExpiresAt       *time.Time `json:"expires_at,omitempty" swaggertype:"string" format:"date-time"`
+	Signed          bool       `json:"signed"`
+	OccurrenceCount int        `json:"occurrence_count"`
+	FirstSeenAt     time.Time  `json:"first_seen_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+}
+
+// AuthTokenOccurrence is a log entry a token appeared in, and where.
+type AuthTokenOccurrence struct {
+	HTTPTrafficLogID int64     `json:"http_traffic_log_id"`
+	Location         string    `json:"location"` // url, request_header:NAME, request_body, response_header:NAME or response_body
+	Method           string    `json:"method"`
+	URL              string    `json:"url"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// AuthTokenDetail is a tracked token, decoded, with the log entries it appeared in.
+type AuthTokenDetail struct {
+	AuthToken
+	JWT         *DecodedJWT           `json:"jwt,omitempty"`
+	SAML        *DecodedSAML          `json:"saml,omitempty"`
+	Occurrences []AuthTokenOccurrence `json:"occurrences"`
+}
+
+// DecodedJWT is the header and claims of a JWT.
+type DecodedJWT struct {
+	Header    map[string]interface{} `json:"header"`
+	Claims    map[string]interface{} `json:"claims"`
+	Signature string                 `json:"signature,omitempty"` // base64url, as in the token
+	Algorithm string                 `json:"algorithm"`
+	IssuedAt  *time.Time             `json:"issued_at,omitempty" swaggertype:"string" format:"date-time"`
+	NotBefore *time.Time             `json:"not_before,omitempty" swaggertype:"string" format:"date-time"`
+	ExpiresAt *time.Time             `json:"expires_at,omitempty" swaggertype:"string" format:"date-time"`
+	Expired   bool                   `json:"expired"`
+}
+
+// DecodedSAML is a SAML message with the fields worth editing pulled out of its XML.
+type DecodedSAML struct {
+	MessageType        string              `json:"message_type"` // Root element: Response, AuthnRequest, ...
+	Encoding           string              `json:"encoding" enums:"base64,deflate,xml"`
+	XML                string              `json:"xml,omitempty"`
+	Issuer             string              `json:"issuer,omitempty"`
+	NameID             string              `json:"name_id,omitempty"`
+	Audience           string              `json:"audience,omitempty"`
+	Destination        string              `json:"destination,omitempty"`
+	InResponseTo       string              `json:"in_response_to,omitempty"`
+	NotBefore          *time.Time          `json:"not_before,omitempty" swaggertype:"string" format:"date-time"`
+	NotOnOrAfter       *time.Time          `json:"not_on_or_after,omitempty" swaggertype:"string" format:"date-time"`
+	Attributes         map[string][]string `json:"attributes,omitempty"`
+	SignedElements     []string            `json:"signed_elements"` // Elements carrying a signature: Response, Assertion, ...
+	SignatureAlgorithm string              `json:"signature_algorithm,omitempty"`
+	EncryptedAssertion bool                `json:"encrypted_assertion"`
+}
+
+// DecodedAuthToken is a JWT or SAML message decoded on request.
+type DecodedAuthToken struct {
+	Kind string       `json:"kind" enums:"jwt,saml"`
+	JWT  *DecodedJWT  `json:"jwt,omitempty"`
+	SAML *DecodedSAML `json:"saml,omitempty"`
+}
+
+// JWTForgeRequest edits a JWT and signs it again. The token is given as is or by tracked token ID.
+// Header and claim values replace the original ones; a null value removes the key.
+type JWTForgeRequest struct {
+	Token         string                 `json:"token,omitempty"`
+	TokenID       int64                  `json:"token_id,omitempty"`
+	Header        map[string]interface{} `json:"header,omitempty"`
+	Claims        map[string]interface{} `json:"claims,omitempty"`
+	ReplaceClaims bool                   `json:"replace_claims"`      // Claims replace the original set instead of being merged into it
+	Algorithm     string                 `json:"algorithm,omitempty"` // none, HS256/384/512, RS256/384/512, PS256/384/512 or ES256/384/512; default: the original's
+	Key           string                 `json:"key,omitempty"`       // HMAC secret, or PEM private key
+	KeepSignature bool                   `json:"keep_signature"`      // Reuse the original signature, for servers that do not check it
+	ModifierLogID int64                  `json:"modifier_log_id,omitempty"`
+}
+
+// SAMLForgeRequest edits a SAML message and keeps, strips or redoes its signatures. The message is
+// given as sent or by tracked token ID; xml replaces it with an edited document.
+type SAMLForgeRequest struct {
+	Token         string              `json:"token,omitempty"`
+	TokenID       int64               `json:"token_id,omitempty"`
+	XML           string              `json:"xml,omitempty"`
+	NameID        string              `json:"name_id,omitempty"`
+	Attributes    map[string][]string `json:"attributes,omitempty"` // Replace the values of these attributes, adding missing ones
+	NotOnOrAfter  *time.Time          `json:"not_on_or_after,omitempty" swaggertype:"string" format:"date-time"`
+	Signature     string              `json:"signature,omitempty" enums:"keep,strip,resign"` // Default: keep
+	SignElements  []string            `json:"sign_elements,omitempty"`                       // Response and/or Assertion; default: those signed in the original, else the assertion
+	Key           string              `json:"key,omitempty"`                                 // PEM RSA or ECDSA private key for resign
+	Certificate   string              `json:"certificate,omitempty"`                         // PEM certificate for KeyInfo; default: self-signed for the key
+	ModifierLogID int64               `json:"modifier_log_id,omitempty"`
+}
+
+// ForgedAuthToken is an edited token. With a modifier_log_id, the Modifier task sending it in
+// place of the original is created too.
+type ForgedAuthToken struct {
+	Kind           string       `json:"kind" enums:"jwt,saml"`
+	Token          string       `json:"token"` // In the original's encoding
+	JWT            *DecodedJWT  `json:"jwt,omitempty"`
+	SAML           *DecodedSAML `json:"saml,omitempty"`
+	ModifierTaskID int64        `json:"modifier_task_id,omitempty"`
+}
+
+// AuthTokenScanResult summarizes a rescan of a target's traffic for tokens.
+type AuthTokenScanResult struct {
+	TargetID    int64 `json:"target_id"`
+	LogsScanned int   `json:"logs_scanned"`
+	Tokens      int   `json:"tokens"`
+	Occurrences int   `json:"occurrences"`
+}
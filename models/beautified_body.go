@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// BeautifiedBody is a readable copy of a captured response body (JavaScript, JSON, HTML or XML),
+// shared by every traffic log entry with the same body. Diffs, the body search and the analyzers
+// read it instead of the original.
+type BeautifiedBody struct {
+	ID           int64     `json:"id"`
+	SHA256       string    `json:"sha256"`   // Hash of the original body, decoded from its Content-Encoding
+	Language     string    `json:"language"` // json, javascript, html or xml
+	Data         []byte    `json:"-"`        // Nil when the body could not be formatted
+	OriginalSize int       `json:"original_size"`
+	Size         int       `json:"size"`
+	Error        string    `json:"error,omitempty"` // Why the body could not be formatted
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BeautifyRequest starts storing beautified copies of the responses captured before they were
+// beautified on capture.
+type BeautifyRequest struct {
+	TargetID int64 `json:"target_id,omitempty"` // Only this target's responses; all by default
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// CanonicalSourceTraffic is the canonical URL source of endpoints seen in proxied traffic.
+const CanonicalSourceTraffic = "traffic"
+
+// CanonicalURL is a unique URL of a target: every discovered URL or traffic endpoint that
+// canonicalizes to the same form (lower-case host, sorted query, no session or tracking
+// parameters, no fragment) counts once.
+type CanonicalURL struct {
+	ID           int64     `json:"id"`
+	TargetID     int64     `json:"target_id"`
+	CanonicalURL string    `json:"canonical_url" example:"https://app.example.com/search?page=2&q=x"`
+	ExampleURL   string    `json:"example_url"` // First URL seen in this form
+	Sources      []string  `json:"sources" example:"jsluice,traffic"`
+	IsInScope    bool      `json:"is_in_scope"`
+	FirstSeenAt  time.Time `json:"first_seen_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+}
+
+// CanonicalURLEntry is a URL to store together with its canonical form.
+type CanonicalURLEntry struct {
+	URL          string
+	CanonicalURL string
+	IsInScope    bool
+}
+
+// CanonicalURLFilters filters the canonical URLs of a target.
+type CanonicalURLFilters struct {
+	TargetID  int64  `json:"target_id"`
+	Search    string `json:"search,omitempty"` // Canonical URL contains
+	Source    string `json:"source,omitempty"` // Seen from this source
+	IsInScope *bool  `json:"is_in_scope,omitempty"`
+	Page      int    `json:"page"`
+	Limit     int    `json:"limit"`
+}
+
+// CanonicalURLRebuildResult summarizes recomputing a target's canonical URLs.
+type CanonicalURLRebuildResult struct {
+	TargetID       int64 `json:"target_id"`
+	DiscoveredURLs int   `json:"discovered_urls"` // Discovered URL rows canonicalized
+	TrafficURLs    int   `json:"traffic_urls"`    // Parameterized URL examples canonicalized
+	UniqueURLs     int   `json:"unique_urls"`     // Canonical URLs stored
+}
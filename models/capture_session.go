@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// CaptureSession is a named time window of testing on a target, such as "testing checkout flow".
+// The traffic logged for the target from its start to its end (or now, while it runs) belongs to
+// it, the toolkit's own requests included. Unlike a Page Sitemap recording it changes nothing in
+// the logged traffic, so sessions may span any number of pages and overlap recordings.
+type CaptureSession struct {
+	ID              int64      `json:"id"`
+	TargetID        int64      `json:"target_id"`
+	Name            string     `json:"name" example:"testing checkout flow"`
+	Notes           string     `json:"notes,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	Running         bool       `json:"running"`
+	DurationSeconds int64      `json:"duration_seconds"` // From the start to the end, or to now while running
+	Requests        int64      `json:"requests"`         // Requests logged for the target within the window
+	ManualRequests  int64      `json:"manual_requests"`  // The user's requests (proxy, Page Sitemap, Modifier)
+}
+
+// CaptureSessionRequest starts a capture session or, on update, changes one. On update, omitted
+// fields are left unchanged.
+type CaptureSessionRequest struct {
+	Name  *string `json:"name,omitempty" example:"testing checkout flow"`
+	Notes *string `json:"notes,omitempty"`
+}
@@ -60,3 +60,68 @@ type PaginatedTargetChecklistItemsResponse struct {
 	Filter                         string                `json:"filter,omitempty"`
 	ShowIncompleteOnly             bool                  `json:"show_incomplete_only,omitempty"`
 }
+
+// Checklist evidence types.
+const (
+	ChecklistEvidenceHTTPLog    = "httplog"
+	ChecklistEvidenceFinding    = "finding"
+	ChecklistEvidenceScreenshot = "screenshot"
+)
+
+// ChecklistItemEvidence links a target checklist item to a request, finding or screenshot that backs it.
+type ChecklistItemEvidence struct {
+	ID               int64          `json:"id"`
+	ChecklistItemID  int64          `json:"checklist_item_id"`
+	EvidenceType     string         `json:"evidence_type"`
+	HTTPTrafficLogID sql.NullInt64  `json:"http_traffic_log_id,omitempty"`
+	FindingID        sql.NullInt64  `json:"finding_id,omitempty"`
+	ScreenshotPath   sql.NullString `json:"screenshot_path,omitempty"`
+	Caption          sql.NullString `json:"caption,omitempty"`
+	Label            string         `json:"label"` // "GET https://... -> 200", the finding title or the screenshot path
+	CreatedAt        time.Time      `json:"created_at"`
+}
+
+// ChecklistEvidenceRequest is the payload for linking evidence to a checklist item.
+type ChecklistEvidenceRequest struct {
+	EvidenceType     string `json:"evidence_type"`
+	HTTPTrafficLogID int64  `json:"http_traffic_log_id,omitempty"`
+	FindingID        int64  `json:"finding_id,omitempty"`
+	ScreenshotPath   string `json:"screenshot_path,omitempty"`
+	Caption          string `json:"caption,omitempty"`
+}
+
+// ChecklistReportItem is a target checklist item with its linked evidence, as used in checklist reports.
+type ChecklistReportItem struct {
+	TargetChecklistItem
+	Evidence []ChecklistItemEvidence `json:"evidence"`
+}
+
+// Checklist command run statuses.
+const (
+	CommandRunStatusRunning   = "running"
+	CommandRunStatusSucceeded = "succeeded"
+	CommandRunStatusFailed    = "failed"
+	CommandRunStatusTimedOut  = "timed_out"
+	CommandRunStatusCancelled = "cancelled"
+)
+
+// ChecklistCommandRun is one execution of a checklist item's command.
+type ChecklistCommandRun struct {
+	ID              int64          `json:"id"`
+	ChecklistItemID int64          `json:"checklist_item_id"`
+	Command         string         `json:"command"` // After placeholder substitution
+	Status          string         `json:"status"`
+	ExitCode        sql.NullInt64  `json:"exit_code,omitempty"`
+	Output          string         `json:"output"`
+	OutputTruncated bool           `json:"output_truncated"`
+	ErrorMessage    sql.NullString `json:"error_message,omitempty"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      sql.NullTime   `json:"finished_at,omitempty"`
+}
+
+// ChecklistCommandRunRequest is the payload for running a checklist item's command.
+type ChecklistCommandRunRequest struct {
+	Command      string            `json:"command,omitempty"`       // Overrides the item's item_command_text
+	Variables    map[string]string `json:"variables,omitempty"`     // Placeholder values, e.g. {"target_ip": "10.0.0.5"}
+	MarkComplete *bool             `json:"mark_complete,omitempty"` // Mark the item complete when the command exits 0 (default true)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ChecklistUncategorized names the category of checklist items without one.
+const ChecklistUncategorized = "Uncategorized"
+
+// ChecklistProgressItem is a target checklist item with what checklist analytics group it by.
+type ChecklistProgressItem struct {
+	ID           int64
+	ItemText     string
+	Notes        sql.NullString
+	IsCompleted  bool
+	TemplateID   sql.NullInt64
+	TemplateName sql.NullString
+	CreatedAt    time.Time
+	CompletedAt  sql.NullTime
+}
+
+// ChecklistAnalytics is the checklist completion of a target: overall, per template the items
+// were copied from, per category, and over time.
+type ChecklistAnalytics struct {
+	TargetID   int64                    `json:"target_id"`
+	Total      int                      `json:"total"`
+	Completed  int                      `json:"completed"`
+	Percent    float64                  `json:"percent"`
+	Templates  []ChecklistCompletion    `json:"templates"`  // Items added by hand are under "Custom", without a template ID
+	Categories []ChecklistCompletion    `json:"categories"` // From "Category:" in the notes or a code prefix such as "API4:2023 - "
+	Interval   string                   `json:"interval" enums:"day,week"`
+	Timeline   []ChecklistTimelinePoint `json:"timeline"`
+}
+
+// ChecklistCompletion is the completion of the checklist items of one template or category.
+type ChecklistCompletion struct {
+	TemplateID int64   `json:"template_id,omitempty"`
+	Name       string  `json:"name"`
+	Total      int     `json:"total"`
+	Completed  int     `json:"completed"`
+	Percent    float64 `json:"percent"`
+}
+
+// ChecklistTimelinePoint is the checklist of a target at the end of one day or week. Items
+// completed and later reopened are not counted.
+type ChecklistTimelinePoint struct {
+	Date              string  `json:"date" example:"2024-05-13"` // First day of the period
+	Total             int     `json:"total"`                     // Items added by the end of the period
+	Completed         int     `json:"completed"`                 // Items completed by the end of the period
+	CompletedInPeriod int     `json:"completed_in_period"`
+	Percent           float64 `json:"percent"`
+}
+
+// ChecklistCoverageReport maps the checklist items of a target to the endpoints of its captured
+// traffic they were tested against.
+type ChecklistCoverageReport struct {
+	TargetID            int64                   `json:"target_id"`
+	DiscoveredEndpoints int                     `json:"discovered_endpoints"`
+	Items               []ChecklistItemCoverage `json:"items"`
+}
+
+// ChecklistItemCoverage is how many of the discovered endpoints (method, host and path, with
+// numeric and UUID segments as {id} and {uuid}) a checklist item was tested against: those of its
+// request evidence, of the requests of its finding evidence, and of the requests tagged with its
+// category.
+type ChecklistItemCoverage struct {
+	ItemID              int64    `json:"item_id"`
+	ItemText            string   `json:"item_text"`
+	Category            string   `json:"category"`
+	Template            string   `json:"template"`
+	IsCompleted         bool     `json:"is_completed"`
+	TestedEndpoints     int      `json:"tested_endpoints"`
+	DiscoveredEndpoints int      `json:"discovered_endpoints"`
+	Percent             float64  `json:"percent"`
+	Summary             string   `json:"summary" example:"API4:2023 - Unrestricted Resource Consumption: tested against 12/45 discovered endpoints"`
+	Tested              []string `json:"tested"`             // "GET api.example.com/users/{id}"
+	Untested            []string `json:"untested,omitempty"` // Of completed items, the first 50 endpoints not tested
+}
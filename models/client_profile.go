@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TargetClientProfile holds what the toolkit adds to the requests it sends for a target (JS path
+// sends, the Modifier, replays, GraphQL introspection, harvesting, baselining, favicon fetches and
+// httpx): program-required headers, a user agent and cookies. A header the request already has
+// keeps its value, so single requests can override the profile.
+type TargetClientProfile struct {
+	TargetID  int64             `json:"target_id" readOnly:"true"`
+	UserAgent string            `json:"user_agent,omitempty" example:"Mozilla/5.0 (compatible; researcher-handle)"`
+	Headers   map[string]string `json:"headers"`
+	Cookies   string            `json:"cookies,omitempty" example:"program_session=abc"`
+	UpdatedAt time.Time         `json:"updated_at" readOnly:"true"`
+}
+
+// IsEmpty reports whether the profile adds nothing to a request.
+func (p TargetClientProfile) IsEmpty() bool {
+	return p.UserAgent == "" && len(p.Headers) == 0 && p.Cookies == ""
+}
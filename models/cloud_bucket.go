@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// CloudStorageLogSource is the log_source of the cloud storage checker's requests in http_traffic_log.
+const CloudStorageLogSource = "Cloud Storage Check"
+
+// Cloud storage providers.
+const (
+	CloudProviderS3    = "s3"
+	CloudProviderGCS   = "gcs"
+	CloudProviderAzure = "azure"
+)
+
+// CloudProviders are the providers the cloud storage checker knows.
+var CloudProviders = []string{CloudProviderS3, CloudProviderGCS, CloudProviderAzure}
+
+// Sources of cloud buckets.
+const (
+	CloudBucketSourceCandidate = "candidate" // Derived from the target's names and domains
+	CloudBucketSourceTraffic   = "traffic"   // Requested in, or referenced by, captured traffic
+	CloudBucketSourceJS        = "js"        // Referenced by a captured JavaScript file
+	CloudBucketSourceManual    = "manual"
+)
+
+// Statuses of cloud buckets.
+const (
+	CloudBucketStatusUnchecked  = "unchecked"
+	CloudBucketStatusNotFound   = "not_found"
+	CloudBucketStatusPrivate    = "private"     // Exists, anonymous listing denied
+	CloudBucketStatusPublicRead = "public_read" // Anyone can list the bucket
+	CloudBucketStatusError      = "error"
+)
+
+// CloudBucket is a cloud storage bucket (an Azure Blob container) of a target and what anonymous
+// requests may do with it.
+type CloudBucket struct {
+	ID            int64      `json:"id"`
+	TargetID      int64      `json:"target_id"`
+	Provider      string     `json:"provider" enums:"s3,gcs,azure"`
+	Name          string     `json:"name" example:"example-backups"` // account/container for Azure
+	URL           string     `json:"url" example:"https://s3.amazonaws.com/example-backups"`
+	Source        string     `json:"source" enums:"candidate,traffic,js,manual"`
+	SourceLogID   *int64     `json:"source_log_id,omitempty"` // Traffic log entry referencing the bucket
+	Status        string     `json:"status" enums:"unchecked,not_found,private,public_read,error"`
+	PublicWrite   *bool      `json:"public_write,omitempty"` // Not set when writes were not tested
+	Details       string     `json:"details,omitempty"`
+	EvidenceLogID *int64     `json:"evidence_log_id,omitempty"` // Traffic log entry of the response that decided the status
+	FirstSeenAt   time.Time  `json:"first_seen_at"`
+	CheckedAt     *time.Time `json:"checked_at,omitempty"`
+}
+
+// CloudBucketFilters narrows a cloud bucket listing.
+type CloudBucketFilters struct {
+	TargetID     int64
+	Provider     string
+	Status       string
+	Source       string
+	ExistingOnly bool // Leave out not_found and unchecked buckets
+}
+
+// CloudStorageCheckRequest starts a cloud storage check. By default candidate names derived from
+// the target's names and domains, and the buckets referenced in its traffic and JavaScript, are
+// checked on every provider.
+type CloudStorageCheckRequest struct {
+	Names          []string `json:"names,omitempty"`     // Extra bucket names (account/container for Azure)
+	Providers      []string `json:"providers,omitempty"` // Default: s3, gcs, azure
+	SkipCandidates bool     `json:"skip_candidates,omitempty"`
+	SkipDiscovered bool     `json:"skip_discovered,omitempty"`
+	// TestWrite uploads a small marker object to each existing bucket and deletes it right away.
+	// GCS write permissions are always read from its testPermissions API, which writes nothing.
+	TestWrite bool `json:"test_write,omitempty"`
+}
+
+// CloudStorageReference is a piece of captured data that may mention a bucket URL, with the
+// traffic log entry it comes from.
+type CloudStorageReference struct {
+	Source string // CloudBucketSourceTraffic or CloudBucketSourceJS
+	LogID  int64
+	Text   string
+}
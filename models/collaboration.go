@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Entity types that can be soft-locked while being edited.
+const (
+	LockEntityFinding = "finding"
+	LockEntityNote    = "note"
+)
+
+// Event types of GET /events.
+const (
+	EventTypeActivity = "activity" // A mutation made through the API
+	EventTypePresence = "presence" // The viewers changed; the event carries all of them
+	EventTypeLocks    = "locks"    // The locks changed; the event carries all of them
+)
+
+// Activity is a mutation made through the API.
+type Activity struct {
+	ID         int64     `json:"id"`
+	User       string    `json:"user" example:"alice"`
+	Action     string    `json:"action" example:"update"` // create, update, delete or the action of the route (enable, run, ...)
+	EntityType string    `json:"entity_type,omitempty" example:"finding"`
+	EntityID   string    `json:"entity_id,omitempty" example:"12"`
+	TargetID   *int64    `json:"target_id,omitempty"`
+	Method     string    `json:"method" example:"PUT"`
+	Path       string    `json:"path" example:"/findings/12"`
+	Route      string    `json:"route,omitempty" example:"/findings/{finding_id}"`
+	Status     int       `json:"status" example:"200"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ActivityFilters narrows the activity feed.
+type ActivityFilters struct {
+	User       string
+	EntityType string
+	EntityID   string
+	TargetID   int64
+	AfterID    int64 // Only activity after this one, oldest first
+	Limit      int
+}
+
+// Presence is a user viewing an entity.
+type Presence struct {
+	User       string    `json:"user"`
+	EntityType string    `json:"entity_type" example:"finding"`
+	EntityID   string    `json:"entity_id" example:"12"`
+	SeenAt     time.Time `json:"seen_at"`
+}
+
+// PresenceRequest announces that the requesting user views an entity. Repeat it within
+// collaboration.presence_ttl_seconds to stay present.
+type PresenceRequest struct {
+	EntityType string `json:"entity_type" example:"finding"`
+	EntityID   string `json:"entity_id" example:"12"`
+}
+
+// EntityLock is a soft lock on an entity being edited. Locks are advisory: they are shown to
+// others and reported on updates, not enforced.
+type EntityLock struct {
+	EntityType string    `json:"entity_type" example:"finding"`
+	EntityID   string    `json:"entity_id" example:"12"`
+	User       string    `json:"user"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LockRequest takes or refreshes a soft lock.
+type LockRequest struct {
+	EntityType string `json:"entity_type" enums:"finding,note"`
+	EntityID   string `json:"entity_id" example:"12"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" example:"300"` // collaboration.lock_ttl_seconds when omitted; at most an hour
+	Force      bool   `json:"force,omitempty"`                     // Take the lock over from another user
+}
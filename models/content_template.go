@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Kinds of content templates.
+const (
+	ContentTemplateFinding = "finding"
+	ContentTemplateNote    = "note"
+)
+
+// ContentTemplate is a reusable finding or note, such as an XSS writeup skeleton, whose fields may
+// hold {{name}} placeholders filled in when it is instantiated. Note templates use only the title
+// and content.
+type ContentTemplate struct {
+	ID                int64     `json:"id" readOnly:"true"`
+	Kind              string    `json:"kind" enums:"finding,note"`
+	Name              string    `json:"name" example:"Reflected XSS"`
+	Title             string    `json:"title" example:"Reflected XSS in the {{parameter}} parameter of {{endpoint}}"`
+	Content           string    `json:"content"` // Note content, or finding description (markdown)
+	Summary           string    `json:"summary,omitempty"`
+	StepsToReproduce  string    `json:"steps_to_reproduce,omitempty"`
+	Impact            string    `json:"impact,omitempty"`
+	Recommendations   string    `json:"recommendations,omitempty"`
+	Payload           string    `json:"payload,omitempty"` // Also the {{payload}} variable, unless one is given
+	Severity          string    `json:"severity,omitempty" enums:"Informational,Low,Medium,High,Critical"`
+	CWEID             *int64    `json:"cwe_id,omitempty" example:"79"`
+	VulnerabilityType string    `json:"vulnerability_type,omitempty" example:"Cross-Site Scripting (XSS) - Reflected"` // Name (case-insensitive)
+	Variables         []string  `json:"variables" readOnly:"true"`                                                     // Placeholders used in the fields
+	CreatedAt         time.Time `json:"created_at" readOnly:"true"`
+	UpdatedAt         time.Time `json:"updated_at" readOnly:"true"`
+}
+
+// ContentTemplateInstantiateRequest fills in a content template. The target comes from target_id or
+// the log entry; finding templates need one. Placeholders are filled from, later ones winning: the
+// built-in target variables (target_name, target_url, target_domain, ...), the target's variables,
+// the log entry (url, method, host, path, endpoint, status_code, curl, evidence), parameter and
+// parameter_value, vulnerability_type, date, and variables.
+type ContentTemplateInstantiateRequest struct {
+	TargetID         int64             `json:"target_id,omitempty"`
+	HTTPTrafficLogID int64             `json:"http_traffic_log_id,omitempty"`
+	Parameter        string            `json:"parameter,omitempty" example:"q"` // Its value is read from the log entry's query or form body
+	Variables        map[string]string `json:"variables,omitempty"`
+	Preview          bool              `json:"preview,omitempty"` // Return the filled-in finding or note without creating it
+}
+
+// ContentTemplateInstance is the finding or note created (or previewed) from a content template.
+type ContentTemplateInstance struct {
+	TemplateID int64          `json:"template_id"`
+	Kind       string         `json:"kind"`
+	Finding    *TargetFinding `json:"finding,omitempty"`
+	Note       *Note          `json:"note,omitempty"`
+	Unresolved []string       `json:"unresolved"` // Placeholders without a value, left as they are
+	Preview    bool           `json:"preview,omitempty"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session cookie states reported by the sessions API.
+const (
+	CookieStatusActive  = "active"  // Set and neither cleared nor followed by an auth failure
+	CookieStatusExpired = "expired" // Cleared by the server or past its expiry date
+	CookieStatusSuspect = "suspect" // An authenticated request failed (401 or login redirect) after it was last set
+)
+
+// Session event types.
+const (
+	SessionEventRotated        = "rotated"
+	SessionEventExpired        = "expired"
+	SessionEventUnauthorized   = "unauthorized"
+	SessionEventLoginRedirect  = "login_redirect"
+	SessionEventVaultUpdated   = "vault_updated"
+	SessionEventLoginRefreshed = "login_refreshed" // The login flow minted a new session
+	SessionEventLoginFailed    = "login_failed"
+)
+
+// TargetCookie is a cookie a target set through Set-Cookie in captured traffic, with the latest
+// value seen.
+type TargetCookie struct {
+	ID          int64         `json:"id"`
+	TargetID    int64         `json:"target_id"`
+	Domain      string        `json:"domain"`
+	Path        string        `json:"path"`
+	Name        string        `json:"name"`
+	Value       string        `json:"value"`
+	IsSession   bool          `json:"is_session"`
+	Secure      bool          `json:"secure"`
+	HTTPOnly    bool          `json:"http_only"`
+	SameSite    string        `json:"same_site,omitempty"`
+	ExpiresAt   *time.Time    `json:"expires_at,omitempty" swaggertype:"string" format:"date-time"`
+	Expired     bool          `json:"expired"`
+	SetCount    int           `json:"set_count"`
+	ChangeCount int           `json:"change_count"`
+	SourceLogID sql.NullInt64 `json:"source_log_id,omitempty"`
+	FirstSeenAt time.Time     `json:"first_seen_at"`
+	LastSetAt   time.Time     `json:"last_set_at"`
+	Status      string        `json:"status" enums:"active,expired,suspect"`
+	AgeSeconds  int64         `json:"age_seconds"` // Time since the current value was set
+}
+
+// SessionEvent records a session change seen in captured traffic.
+type SessionEvent struct {
+	ID               int64         `json:"id"`
+	TargetID         int64         `json:"target_id"`
+	CookieName       string        `json:"cookie_name,omitempty"`
+	EventType        string        `json:"event_type" enums:"rotated,expired,unauthorized,login_redirect,vault_updated,login_refreshed,login_failed"`
+	HTTPTrafficLogID sql.NullInt64 `json:"http_traffic_log_id,omitempty"`
+	Details          string        `json:"details,omitempty"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// TargetSessions is the cookie jar of a target: its known cookies (session cookies only unless
+// all were requested) and its most recent session events.
+type TargetSessions struct {
+	TargetID          int64          `json:"target_id"`
+	Cookies           []TargetCookie `json:"cookies"`
+	Events            []SessionEvent `json:"events"`
+	LastAuthFailureAt *time.Time     `json:"last_auth_failure_at,omitempty" swaggertype:"string" format:"date-time"`
+}
+
+// CookieJarRebuildResult summarizes a rescan of a target's traffic into its cookie jar.
+type CookieJarRebuildResult struct {
+	TargetID       int64 `json:"target_id"`
+	LogsScanned    int   `json:"logs_scanned"`
+	Cookies        int   `json:"cookies"`
+	SessionCookies int   `json:"session_cookies"`
+	Events         int   `json:"events"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// CORSCheckLogSource is the log_source of the CORS checker's requests in http_traffic_log.
+const CORSCheckLogSource = "CORS Check"
+
+// CORSCheckRequest starts a CORS misconfiguration check. Without log IDs, the target's distinct
+// captured GET endpoints with a 2xx response are checked, newest first.
+type CORSCheckRequest struct {
+	LogIDs       []int64 `json:"log_ids,omitempty"`       // Captured requests to replay
+	Host         string  `json:"host,omitempty"`          // Only check endpoints of this host
+	MaxEndpoints int     `json:"max_endpoints,omitempty"` // Default: cors_check.max_endpoints
+}
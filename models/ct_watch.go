@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// CTWatchState is the certificate transparency watch position of one wildcard scope pattern.
+type CTWatchState struct {
+	TargetID         int64      `json:"target_id"`
+	BaseDomain       string     `json:"base_domain" example:"example.com"`
+	LastCertID       int64      `json:"last_cert_id"` // Highest crt.sh certificate ID seen
+	CertificatesSeen int64      `json:"certificates_seen"`
+	DomainsAdded     int64      `json:"domains_added"`
+	LastCheckedAt    *time.Time `json:"last_checked_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// CTPollResult summarizes one certificate transparency poll.
+type CTPollResult struct {
+	Patterns         int      `json:"patterns"` // Wildcard scope patterns checked
+	CertificatesNew  int      `json:"certificates_new"`
+	DomainsAdded     []string `json:"domains_added"`
+	InterestingNames []string `json:"interesting_names"` // Added names matching ct_watch.interesting_keywords
+	Errors           []string `json:"errors,omitempty"`
+}
+
+// CTWatcherStatus reports the state of the certificate transparency watcher.
+type CTWatcherStatus struct {
+	Enabled          bool           `json:"enabled"`
+	Running          bool           `json:"running"`
+	LastPollAt       *time.Time     `json:"last_poll_at,omitempty"`
+	Polls            int64          `json:"polls"`
+	CertificatesNew  int64          `json:"certificates_new"`
+	DomainsAdded     int64          `json:"domains_added"`
+	InterestingNames int64          `json:"interesting_names"`
+	LastError        string         `json:"last_error,omitempty"`
+	LastErrorAt      *time.Time     `json:"last_error_at,omitempty"`
+	Patterns         []CTWatchState `json:"patterns"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Protocols of configured DNS resolvers.
+const (
+	DNSProtocolUDP   = "udp"   // Plain DNS, over TCP for truncated answers
+	DNSProtocolTCP   = "tcp"   // Plain DNS over TCP only
+	DNSProtocolTLS   = "tls"   // DNS over TLS
+	DNSProtocolHTTPS = "https" // DNS over HTTPS
+)
+
+// DNSResolverStatus reports the health and usage of a configured resolver.
+type DNSResolverStatus struct {
+	Resolver            string     `json:"resolver" example:"https://cloudflare-dns.com/dns-query"`
+	Protocol            string     `json:"protocol" enums:"udp,tcp,tls,https"`
+	Healthy             bool       `json:"healthy"`
+	DownUntil           *time.Time `json:"down_until,omitempty"` // Skipped until then after max_failures consecutive failures
+	Queries             int64      `json:"queries"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastCheckAt         *time.Time `json:"last_check_at,omitempty"`
+	LastCheckMs         int64      `json:"last_check_ms,omitempty"` // Duration of the last health check lookup
+}
+
+// DNSResolversStatus reports the resolvers of the recon DNS lookups.
+type DNSResolversStatus struct {
+	SystemResolver   bool                `json:"system_resolver"` // No resolvers configured; lookups use the system resolver
+	QueriesPerSecond float64             `json:"queries_per_second"`
+	Resolvers        []DNSResolverStatus `json:"resolvers"`
+	Errors           []string            `json:"errors,omitempty"` // Invalid resolver entries, skipped
+}
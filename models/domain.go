@@ -25,17 +25,33 @@ type Domain struct {
 	HTTPServer        sql.NullString `json:"http_server,omitempty"`
 	HTTPTech          sql.NullString `json:"http_tech,omitempty"`       // Comma-separated list of technologies
 	HttpxFullJson     sql.NullString `json:"httpx_full_json,omitempty"` // Store the full JSON output from httpx
+
+	// Fields for favicon hashing and Shodan/Censys enrichment
+	FaviconHash    sql.NullInt64  `json:"favicon_hash,omitempty"`    // Shodan-style murmur3 hash, searchable as http.favicon.hash
+	FaviconURL     sql.NullString `json:"favicon_url,omitempty"`     // Where the hashed favicon was fetched from
+	OpenPorts      sql.NullString `json:"open_ports,omitempty"`      // Comma-separated open ports reported by Shodan/Censys
+	EnrichmentJson sql.NullString `json:"enrichment_json,omitempty"` // DomainEnrichment as JSON: ports, certificates, related hosts
+	EnrichedAt     sql.NullTime   `json:"enriched_at,omitempty"`
+
+	// Fields for subdomain takeover verification
+	TakeoverStatus        sql.NullString `json:"takeover_status,omitempty"`          // vulnerable, dangling or not_vulnerable
+	TakeoverService       sql.NullString `json:"takeover_service,omitempty"`         // Service whose CNAME or fingerprint matched
+	TakeoverCNAME         sql.NullString `json:"takeover_cname,omitempty"`           // CNAME chain, "a -> b -> c"
+	TakeoverEvidenceLogID sql.NullInt64  `json:"takeover_evidence_log_id,omitempty"` // Traffic log entry of the fingerprinted response
+	TakeoverCheckedAt     sql.NullTime   `json:"takeover_checked_at,omitempty"`
 }
 
 // PaginatedDomainsResponse is the structure for paginated domain results.
 type PaginatedDomainsResponse struct {
-	Page         int    `json:"page"`
+	Page         int    `json:"page,omitempty"` // Not set for cursor pages
 	Limit        int    `json:"limit"`
 	TotalRecords int64  `json:"total_records"`
 	TotalPages   int64  `json:"total_pages"`
 	SortBy       string `json:"sort_by,omitempty"`
 	SortOrder    string `json:"sort_order,omitempty"`
 	Filter       string `json:"filter,omitempty"` // General filter text, if applicable
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
 	// Distinct values for dropdown filters
 	DistinctHttpStatusCodes []sql.NullInt64  `json:"distinct_http_status_codes,omitempty"`
 	DistinctHttpServers     []sql.NullString `json:"distinct_http_servers,omitempty"`
@@ -58,4 +74,30 @@ type DomainFilters struct {
 	FilterHTTPStatusCode string `json:"filter_http_status_code,omitempty"` // New: For exact status code match
 	FilterHTTPServer     string `json:"filter_http_server,omitempty"`      // New: For exact server match
 	FilterHTTPTech       string `json:"filter_http_tech,omitempty"`        // New: For exact tech string match
+	FilterFaviconHash    string `json:"filter_favicon_hash,omitempty"`     // Exact favicon hash
+	FilterOpenPort       string `json:"filter_open_port,omitempty"`        // Domains with this port among open_ports
+	FilterTakeoverStatus string `json:"filter_takeover_status,omitempty"`  // Exact takeover status (NULL for unchecked)
+	FilterRootDomain     string `json:"filter_root_domain,omitempty"`      // Domains under this root domain (eTLD+1)
+	FilterBusinessUnit   string `json:"filter_business_unit,omitempty"`    // Domains whose root domain has this business unit (NULL for none)
+}
+
+// DomainExportRecord is a domain as written by the JSON export, with plain values instead of the
+// nullable column types.
+type DomainExportRecord struct {
+	ID                int64     `json:"id"`
+	DomainName        string    `json:"domain_name"`
+	Source            string    `json:"source,omitempty"`
+	IsInScope         bool      `json:"is_in_scope"`
+	IsWildcardScope   bool      `json:"is_wildcard_scope"`
+	IsFavorite        bool      `json:"is_favorite"`
+	HTTPStatusCode    *int64    `json:"http_status_code,omitempty"`
+	HTTPContentLength *int64    `json:"http_content_length,omitempty"`
+	HTTPTitle         string    `json:"http_title,omitempty"`
+	HTTPServer        string    `json:"http_server,omitempty"`
+	HTTPTech          string    `json:"http_tech,omitempty"`
+	FaviconHash       *int64    `json:"favicon_hash,omitempty"`
+	OpenPorts         string    `json:"open_ports,omitempty"`
+	TakeoverStatus    string    `json:"takeover_status,omitempty"`
+	Notes             string    `json:"notes,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
 }
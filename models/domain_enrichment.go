@@ -0,0 +1,44 @@
+package models
+
+// DomainEnrichment is what favicon hashing and Shodan/Censys host lookups found for a domain. It
+// is stored as the domain's enrichment_json.
+type DomainEnrichment struct {
+	IPs          []string          `json:"ips"`
+	Ports        []int             `json:"ports"`
+	Hostnames    []string          `json:"hostnames"` // Other names the hosts are known by (reverse DNS, certificate names)
+	Certificates []CertificateInfo `json:"certificates"`
+	RelatedHosts []RelatedHost     `json:"related_hosts"` // Hosts serving the same favicon
+	Sources      []string          `json:"sources"`       // "shodan", "censys"
+	Errors       []string          `json:"errors,omitempty"`
+}
+
+// CertificateInfo summarizes a TLS certificate seen on one of a domain's hosts.
+type CertificateInfo struct {
+	Source   string   `json:"source"`
+	IP       string   `json:"ip"`
+	Port     int      `json:"port"`
+	Subject  string   `json:"subject"`
+	Issuer   string   `json:"issuer"`
+	Names    []string `json:"names,omitempty"`
+	NotAfter string   `json:"not_after,omitempty"`
+	SHA256   string   `json:"sha256,omitempty"`
+}
+
+// RelatedHost is another host found through the domain, e.g. serving the same favicon.
+type RelatedHost struct {
+	Source    string   `json:"source"`
+	Reason    string   `json:"reason" example:"favicon"`
+	IP        string   `json:"ip"`
+	Port      int      `json:"port,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// DomainEnrichmentRequest selects the domains of a target to enrich and the lookups to run. With
+// no domain_ids every domain with an httpx response is enriched; with none of favicon, shodan and
+// censys set, the favicon and every configured service are used.
+type DomainEnrichmentRequest struct {
+	DomainIDs []int64 `json:"domain_ids,omitempty"`
+	Favicon   bool    `json:"favicon,omitempty"`
+	Shodan    bool    `json:"shodan,omitempty"`
+	Censys    bool    `json:"censys,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// DomainGroupUnassigned names the business unit rollup of root domains without one.
+const DomainGroupUnassigned = "Unassigned"
+
+// DomainGroupLabel is the business unit and notes given to a root domain of a target.
+type DomainGroupLabel struct {
+	RootDomain   string    `json:"root_domain" example:"example.co.uk"`
+	BusinessUnit string    `json:"business_unit" example:"Payments"`
+	Notes        string    `json:"notes,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at" readOnly:"true"`
+}
+
+// DomainGroupUpdate assigns a root domain to a business unit. Empty values remove the label.
+type DomainGroupUpdate struct {
+	BusinessUnit string `json:"business_unit" example:"Payments"`
+	Notes        string `json:"notes,omitempty"`
+}
+
+// DomainGroup is a target's domains under one registrable root domain (eTLD+1), with counts.
+// IP addresses form a group of their own.
+type DomainGroup struct {
+	RootDomain         string         `json:"root_domain" example:"example.co.uk"`
+	BusinessUnit       string         `json:"business_unit,omitempty"`
+	Notes              string         `json:"notes,omitempty"`
+	Domains            int            `json:"domains"`
+	InScope            int            `json:"in_scope"`
+	Wildcards          int            `json:"wildcards"` // Entries from wildcard scope rules
+	Favorites          int            `json:"favorites"`
+	Live               int            `json:"live"`                    // With an httpx status code
+	StatusClasses      map[string]int `json:"status_classes"`          // Live domains by status class: "2xx", "3xx", ...
+	TakeoverVulnerable int            `json:"takeover_vulnerable"`     // Confirmed subdomain takeovers
+	TakeoverDangling   int            `json:"takeover_dangling"`       // Dangling CNAMEs to review
+	LastAddedAt        *time.Time     `json:"last_added_at,omitempty"` // When its newest domain was added
+}
+
+// BusinessUnitRollup sums the domain groups of one business unit.
+type BusinessUnitRollup struct {
+	Name               string   `json:"name"` // "Unassigned" for root domains without one
+	RootDomains        []string `json:"root_domains"`
+	Domains            int      `json:"domains"`
+	InScope            int      `json:"in_scope"`
+	Live               int      `json:"live"`
+	TakeoverVulnerable int      `json:"takeover_vulnerable"`
+}
+
+// DomainGroupsReport groups the domains of a target by root domain and rolls the groups up by
+// business unit.
+type DomainGroupsReport struct {
+	TargetID      int64                `json:"target_id"`
+	TotalDomains  int                  `json:"total_domains"`
+	Groups        []DomainGroup        `json:"groups"` // Most domains first
+	BusinessUnits []BusinessUnitRollup `json:"business_units"`
+}
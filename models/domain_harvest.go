@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Discovered URL source types of the harvester.
+const (
+	DiscoveredSourceRobotsDisallow = "robots_disallow"
+	DiscoveredSourceRobotsSitemap  = "robots_sitemap"
+	DiscoveredSourceSitemap        = "sitemap"
+	HarvesterLogSource             = "Harvester" // log_source of the harvester's requests in http_traffic_log
+)
+
+// DomainHarvest is what was harvested from a domain's robots.txt, sitemaps and security.txt.
+type DomainHarvest struct {
+	DomainID         int64             `json:"domain_id"`
+	TargetID         int64             `json:"target_id"`
+	BaseURL          string            `json:"base_url" example:"https://app.example.com"`
+	RobotsStatus     *int              `json:"robots_status,omitempty"`
+	RobotsTxt        string            `json:"robots_txt,omitempty"`
+	DisallowedPaths  []string          `json:"disallowed_paths"`
+	Sitemaps         []string          `json:"sitemaps"`
+	SitemapURLCount  int               `json:"sitemap_url_count"`
+	SecurityTxtURL   string            `json:"security_txt_url,omitempty"`
+	SecurityTxt      string            `json:"security_txt,omitempty"`
+	SecurityContacts []string          `json:"security_contacts"`
+	SecurityFields   map[string]string `json:"security_fields"` // Other security.txt fields (Policy, Expires, Encryption, ...), repeated values joined by ", "
+	Errors           []string          `json:"errors"`
+	HarvestedAt      time.Time         `json:"harvested_at"`
+}
+
+// HarvestedURL is a URL the harvester added to the discovered URL inventory.
+type HarvestedURL struct {
+	URL          string    `json:"url"`
+	SourceType   string    `json:"source_type" example:"sitemap"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+// DomainHarvestView is the per-domain harvest API response.
+type DomainHarvestView struct {
+	Harvest DomainHarvest  `json:"harvest"`
+	URLs    []HarvestedURL `json:"urls"`
+}
+
+// HarvestRequest selects the domains of a target to harvest; with no domain_ids every domain
+// with an httpx response is harvested.
+type HarvestRequest struct {
+	DomainIDs []int64 `json:"domain_ids,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// DomainImportDefaultSource is the source recorded for imported domains when none is given.
+const DomainImportDefaultSource = "import"
+
+// Reasons an imported row was not added.
+const (
+	DomainImportSkipInvalid    = "invalid"
+	DomainImportSkipDuplicate  = "duplicate" // Repeated within the imported file
+	DomainImportSkipExisting   = "existing"  // Already tracked for the target
+	DomainImportSkipOutOfScope = "out_of_scope"
+)
+
+// DomainImportOptions controls a bulk domain import.
+type DomainImportOptions struct {
+	Source        string `json:"source" example:"amass"` // Recorded as the source of the added domains
+	ValidateScope bool   `json:"validate_scope"`         // Skip domains the target's scope rules don't cover; added ones are marked in scope
+	DryRun        bool   `json:"dry_run"`                // Parse and check only; nothing is stored
+}
+
+// DomainImportSkip is a row of an import that was not added.
+type DomainImportSkip struct {
+	Line   int    `json:"line"`
+	Value  string `json:"value"`
+	Reason string `json:"reason" example:"existing" enums:"invalid,duplicate,existing,out_of_scope"`
+}
+
+// DomainImportResult summarizes a bulk domain import.
+type DomainImportResult struct {
+	TargetID   int64              `json:"target_id"`
+	Source     string             `json:"source"`
+	DryRun     bool               `json:"dry_run"`
+	Rows       int                `json:"rows"` // Non-empty rows read, header excluded
+	Added      int                `json:"added"`
+	Invalid    int                `json:"invalid"`
+	Duplicates int                `json:"duplicates"`
+	Existing   int                `json:"existing"`
+	OutOfScope int                `json:"out_of_scope"`
+	Errors     []string           `json:"errors,omitempty"`
+	Skipped    []DomainImportSkip `json:"skipped"`           // Capped at DomainImportMaxSkipped entries
+	Truncated  bool               `json:"skipped_truncated"` // More rows were skipped than listed
+}
+
+// DomainImportMaxSkipped caps the skipped rows listed in an import result.
+const DomainImportMaxSkipped = 500
@@ -0,0 +1,24 @@
+package models
+
+// DomainSourcePermutation is the source recorded for domains found by permutation.
+const DomainSourcePermutation = "permutation"
+
+// PermutationRequest controls a permutation job. Candidates are generated under the base domains
+// from the target's known subdomains of them and a wordlist, then resolved; live ones are added.
+type PermutationRequest struct {
+	Bases         []string `json:"bases,omitempty"`          // Base domains; default: the target's in-scope wildcard rules (*.example.com)
+	Words         []string `json:"words,omitempty"`          // Words added to the configured (or built-in) wordlist
+	WordlistID    int64    `json:"wordlist_id,omitempty"`    // Stored wordlist whose words are added as well
+	WordsOnly     bool     `json:"words_only,omitempty"`     // Use only Words and WordlistID, not the configured wordlist
+	MaxCandidates int      `json:"max_candidates,omitempty"` // Lower than permutation.max_candidates to resolve fewer
+	DryRun        bool     `json:"dry_run,omitempty"`        // Generate and return the candidates without resolving them
+}
+
+// PermutationPreview is the answer to a dry-run permutation request.
+type PermutationPreview struct {
+	Bases      []string `json:"bases"`
+	Seeds      int      `json:"seeds"` // Known domains the candidates were derived from
+	Words      int      `json:"words"`
+	Candidates []string `json:"candidates"`
+	Truncated  bool     `json:"truncated"` // More candidates were possible than max_candidates
+}
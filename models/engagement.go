@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Sources of engagement sessions.
+const (
+	EngagementSourceManual = "manual" // Started and stopped with the timer
+	EngagementSourceAuto   = "auto"   // Started by the user's traffic through the proxy, ended when it went idle
+)
+
+// EngagementSession is a stretch of time spent on a target, with the requests logged for the
+// target meanwhile. Counts and active time are stored when the session ends and computed from the
+// traffic log while it runs.
+type EngagementSession struct {
+	ID               int64      `json:"id"`
+	TargetID         int64      `json:"target_id"`
+	Label            string     `json:"label,omitempty" example:"PV mission 1234"`
+	Source           string     `json:"source" enums:"manual,auto"`
+	StartedAt        time.Time  `json:"started_at"`
+	EndedAt          *time.Time `json:"ended_at,omitempty"`
+	LastActivityAt   *time.Time `json:"last_activity_at,omitempty"` // Latest request of the user to the target
+	Running          bool       `json:"running"`
+	DurationSeconds  int64      `json:"duration_seconds"`            // From the start to the end, or to now while running
+	ActiveSeconds    int64      `json:"active_seconds"`              // Time with the user's requests no further apart than engagement.idle_timeout_minutes
+	Requests         int64      `json:"requests"`                    // Requests logged for the target, the toolkit's included
+	ManualRequests   int64      `json:"manual_requests"`             // The user's requests (proxy, Page Sitemap, Modifier)
+	PlannedMinutes   int        `json:"planned_minutes,omitempty"`   // Time budget
+	DueAt            *time.Time `json:"due_at,omitempty"`            // Deadline
+	RemainingSeconds *int64     `json:"remaining_seconds,omitempty"` // Budget left; negative when over it
+	OverBudget       bool       `json:"over_budget"`
+	Overdue          bool       `json:"overdue"` // Ran or is running past its deadline
+	Notes            string     `json:"notes,omitempty"`
+	ScheduleAlerted  bool       `json:"-"` // The over-budget or deadline notification was sent
+}
+
+// EngagementSessionRequest starts an engagement session or, on update, changes one. On update,
+// omitted fields are left unchanged.
+type EngagementSessionRequest struct {
+	Label          *string    `json:"label,omitempty" example:"PV mission 1234"`
+	PlannedMinutes *int       `json:"planned_minutes,omitempty" example:"60"`
+	DueAt          *time.Time `json:"due_at,omitempty"`
+	Notes          *string    `json:"notes,omitempty"`
+}
+
+// EngagementSummary totals the engagement sessions of a target started within a period, per day
+// they started on, for billing and patch verification reports.
+type EngagementSummary struct {
+	TargetID        int64              `json:"target_id"`
+	Since           *time.Time         `json:"since,omitempty"`
+	Until           *time.Time         `json:"until,omitempty"`
+	Sessions        int                `json:"sessions"`
+	DurationSeconds int64              `json:"duration_seconds"`
+	ActiveSeconds   int64              `json:"active_seconds"`
+	Hours           float64            `json:"hours"` // DurationSeconds in hours, rounded to the hundredth
+	Requests        int64              `json:"requests"`
+	ManualRequests  int64              `json:"manual_requests"`
+	Running         *EngagementSession `json:"running,omitempty"`
+	Days            []EngagementDay    `json:"days"`
+}
+
+// EngagementDay totals the sessions started on one day (local time).
+type EngagementDay struct {
+	Date            string  `json:"date" example:"2024-05-01"`
+	Sessions        int     `json:"sessions"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	ActiveSeconds   int64   `json:"active_seconds"`
+	Hours           float64 `json:"hours"`
+	Requests        int64   `json:"requests"`
+	ManualRequests  int64   `json:"manual_requests"`
+}
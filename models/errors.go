@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes of API problem details and CLI errors. They stay stable so the frontend and scripts
+// can act on them instead of on messages.
+const (
+	ErrCodeInvalidRequest   = "invalid_request"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeConflict         = "conflict"
+	ErrCodePayloadTooLarge  = "payload_too_large"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeInternal         = "internal_error"
+	ErrCodeNotImplemented   = "not_implemented"
+	ErrCodeUpstream         = "upstream_error"
+	ErrCodeUnavailable      = "unavailable"
+)
+
+// errorCodeStatus maps error codes to HTTP statuses.
+var errorCodeStatus = map[string]int{
+	ErrCodeInvalidRequest:   http.StatusBadRequest,
+	ErrCodeUnauthorized:     http.StatusUnauthorized,
+	ErrCodeForbidden:        http.StatusForbidden,
+	ErrCodeNotFound:         http.StatusNotFound,
+	ErrCodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	ErrCodeConflict:         http.StatusConflict,
+	ErrCodePayloadTooLarge:  http.StatusRequestEntityTooLarge,
+	ErrCodeRateLimited:      http.StatusTooManyRequests,
+	ErrCodeInternal:         http.StatusInternalServerError,
+	ErrCodeNotImplemented:   http.StatusNotImplemented,
+	ErrCodeUpstream:         http.StatusBadGateway,
+	ErrCodeUnavailable:      http.StatusServiceUnavailable,
+}
+
+// errorCodeExitStatus maps error codes to CLI exit statuses; other codes exit with 1.
+var errorCodeExitStatus = map[string]int{
+	ErrCodeInvalidRequest: 2,
+	ErrCodeNotFound:       3,
+	ErrCodeConflict:       4,
+	ErrCodeForbidden:      5,
+	ErrCodeUnauthorized:   5,
+	ErrCodeUpstream:       6,
+	ErrCodeUnavailable:    6,
+}
+
+// ProblemDetails is the body of API error responses (RFC 7807, application/problem+json).
+type ProblemDetails struct {
+	Type     string `json:"type" example:"urn:toolkit:error:not_found"` // urn:toolkit:error:<code>
+	Title    string `json:"title" example:"Not Found"`                  // Status text
+	Status   int    `json:"status" example:"404"`
+	Detail   string `json:"detail" example:"target with ID 7 not found"`
+	Instance string `json:"instance,omitempty" example:"/api/targets/7"`  // Request path
+	Code     string `json:"code" example:"not_found"`                     // One of the ErrCode values
+	Message  string `json:"message" example:"target with ID 7 not found"` // Same as detail, for clients reading ErrorResponse
+}
+
+// AppError is an error with a code deciding its API status and CLI exit status. Message is shown
+// to the caller; Err, when set, is the underlying cause and is only logged for internal errors.
+type AppError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil && e.Code == ErrCodeInternal {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error { return e.Err }
+
+// NewAppError returns an error with the given code.
+func NewAppError(code, format string, args ...interface{}) *AppError {
+	return &AppError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// NotFoundError returns a not_found error.
+func NotFoundError(format string, args ...interface{}) *AppError {
+	return NewAppError(ErrCodeNotFound, format, args...)
+}
+
+// InvalidRequestError returns an invalid_request error.
+func InvalidRequestError(format string, args ...interface{}) *AppError {
+	return NewAppError(ErrCodeInvalidRequest, format, args...)
+}
+
+// ConflictError returns a conflict error.
+func ConflictError(format string, args ...interface{}) *AppError {
+	return NewAppError(ErrCodeConflict, format, args...)
+}
+
+// InternalError wraps err as an internal error shown to the caller as message.
+func InternalError(err error, message string) *AppError {
+	return &AppError{Code: ErrCodeInternal, Message: message, Err: err}
+}
+
+// ErrorCode returns the code of err. Errors that are not an AppError are classified the way
+// handlers have always mapped them: "not found" anywhere or sql.ErrNoRows is not_found, a message
+// starting with "invalid" is invalid_request, "already exists" or "conflict" is conflict and
+// anything else is internal_error.
+func ErrorCode(err error) string {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCodeNotFound
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "not found"):
+		return ErrCodeNotFound
+	case strings.HasPrefix(msg, "invalid"):
+		return ErrCodeInvalidRequest
+	case strings.Contains(msg, "already exists"), strings.Contains(msg, "conflict"):
+		return ErrCodeConflict
+	}
+	return ErrCodeInternal
+}
+
+// ErrorCodeStatus returns the HTTP status of an error code.
+func ErrorCodeStatus(code string) int {
+	if status, ok := errorCodeStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// StatusErrorCode returns the error code of an HTTP error status.
+func StatusErrorCode(status int) string {
+	for code, s := range errorCodeStatus {
+		if s == status {
+			return code
+		}
+	}
+	switch {
+	case status == http.StatusGatewayTimeout:
+		return ErrCodeUpstream
+	case status >= 500:
+		return ErrCodeInternal
+	case status == http.StatusRequestTimeout || status == http.StatusUnprocessableEntity || status == http.StatusUnsupportedMediaType:
+		return ErrCodeInvalidRequest
+	case status == http.StatusGone:
+		return ErrCodeNotFound
+	case status == http.StatusPreconditionFailed || status == http.StatusLocked:
+		return ErrCodeConflict
+	}
+	return ErrCodeInvalidRequest
+}
+
+// ErrorExitStatus returns the CLI exit status of an error: 2 invalid_request, 3 not_found,
+// 4 conflict, 5 forbidden or unauthorized, 6 upstream_error or unavailable and 1 otherwise.
+func ErrorExitStatus(err error) int {
+	if status, ok := errorCodeExitStatus[ErrorCode(err)]; ok {
+		return status
+	}
+	return 1
+}
+
+// NewProblemDetails returns the problem details of an error response.
+func NewProblemDetails(status int, code, detail, instance string) ProblemDetails {
+	if code == "" {
+		code = StatusErrorCode(status)
+	}
+	title := http.StatusText(status)
+	if detail == "" {
+		detail = title
+	}
+	return ProblemDetails{Type: "urn:toolkit:error:" + code, Title: title, Status: status, Detail: detail,
+		Instance: instance, Code: code, Message: detail}
+}
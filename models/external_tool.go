@@ -0,0 +1,28 @@
+package models
+
+// Statuses of an external tool.
+const (
+	ExternalToolOK             = "ok"              // Found, at or above the minimum version
+	ExternalToolMissing        = "missing"         // Neither in tools.bin_dir nor in PATH
+	ExternalToolIncompatible   = "incompatible"    // Older than the minimum version
+	ExternalToolUnknownVersion = "unknown_version" // Its version could not be read, e.g. another program of the same name
+)
+
+// ExternalToolStatus describes an external binary the toolkit runs and what is installed of it.
+type ExternalToolStatus struct {
+	Name          string `json:"name" example:"subfinder"`
+	Required      bool   `json:"required"` // Used by toolkit features; optional tools are for checklist commands
+	Purpose       string `json:"purpose"`
+	Status        string `json:"status" example:"ok"`
+	Path          string `json:"path,omitempty"`    // Binary that is run, empty when missing
+	Managed       bool   `json:"managed"`           // Path is in tools.bin_dir
+	Version       string `json:"version,omitempty"` // Installed version, without the leading v
+	MinVersion    string `json:"min_version" example:"2.5.0"`
+	PinnedVersion string `json:"pinned_version" example:"2.6.6"` // Release 'toolkit tools install' downloads
+	Message       string `json:"message,omitempty"`
+}
+
+// ExternalToolInstallRequest selects the release to install. An empty version installs the pinned one.
+type ExternalToolInstallRequest struct {
+	Version string `json:"version,omitempty" example:"2.6.6"`
+}
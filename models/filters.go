@@ -1,19 +1,60 @@
 package models
 
+import (
+	"strconv"
+	"strings"
+)
+
 // ProxyLogFilters defines parameters for filtering proxy log queries.
 type ProxyLogFilters struct {
-	TargetID            int64  `json:"target_id"`
-	Page                int    `json:"page"`
-	Limit               int    `json:"limit"`
-	SortBy              string `json:"sort_by"`
-	SortOrder           string `json:"sort_order"`
-	FilterFavoritesOnly bool   `json:"favorites_only"`
-	FilterMethod        string `json:"method,omitempty"`
-	FilterStatus        string `json:"status,omitempty"`
-	FilterContentType   string `json:"type,omitempty"`
-	FilterSearchText    string `json:"search,omitempty"`
-	AnalysisType        string `json:"analysis_type,omitempty"` // For specific analyses like "params"
-	FilterDomain        string `json:"domain,omitempty"`
+	TargetID            int64   `json:"target_id"`
+	Page                int     `json:"page"`
+	Limit               int     `json:"limit"`
+	SortBy              string  `json:"sort_by"`
+	SortOrder           string  `json:"sort_order"`
+	FilterFavoritesOnly bool    `json:"favorites_only"`
+	FilterMethod        string  `json:"method,omitempty"`
+	FilterStatus        string  `json:"status,omitempty"`
+	FilterContentType   string  `json:"type,omitempty"`
+	FilterSearchText    string  `json:"search,omitempty"`
+	AnalysisType        string  `json:"analysis_type,omitempty"` // For specific analyses like "params"
+	FilterDomain        string  `json:"domain,omitempty"`
+	FilterOrigin        string  `json:"origin,omitempty"` // TrafficOriginManual or TrafficOriginAutomated
+	FilterSource        string  `json:"source,omitempty"` // Exact log_source
+	FilterTagIDs        []int64 `json:"filter_tag_ids,omitempty"`
+	FilterURLContains   string  `json:"url_contains,omitempty"`       // Substring of the request URL
+	FilterBodyContains  string  `json:"body,omitempty"`               // Substring of the response body or its beautified copy (case-insensitive for ASCII)
+	CaptureSessionID    int64   `json:"capture_session_id,omitempty"` // Logged within the window of this capture session
+}
+
+// ProxyLogFiltersFromValues reads traffic log filters under the names the traffic log API and saved
+// traffic views use (method, status, type, search, body, domain, origin, source, favorites_only,
+// filter_tag_ids, capture_session_id, sort_by, sort_order, page, limit). get returns the value of a
+// name, "" when unset.
+func ProxyLogFiltersFromValues(targetID int64, get func(string) string) ProxyLogFilters {
+	f := ProxyLogFilters{
+		TargetID:           targetID,
+		SortBy:             get("sort_by"),
+		SortOrder:          get("sort_order"),
+		FilterMethod:       strings.ToUpper(get("method")),
+		FilterStatus:       get("status"),
+		FilterContentType:  get("type"),
+		FilterSearchText:   get("search"),
+		FilterBodyContains: get("body"),
+		FilterDomain:       get("domain"),
+		FilterOrigin:       get("origin"),
+		FilterSource:       get("source"),
+	}
+	f.Page, _ = strconv.Atoi(get("page"))
+	f.Limit, _ = strconv.Atoi(get("limit"))
+	f.FilterFavoritesOnly, _ = strconv.ParseBool(get("favorites_only"))
+	f.CaptureSessionID, _ = strconv.ParseInt(get("capture_session_id"), 10, 64)
+	for _, part := range strings.Split(get("filter_tag_ids"), ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64); err == nil && id > 0 {
+			f.FilterTagIDs = append(f.FilterTagIDs, id)
+		}
+	}
+	return f
 }
 
 // ParameterizedURLFilters defines parameters for filtering parameterized URL queries.
@@ -26,6 +67,7 @@ type ParameterizedURLFilters struct {
 	RequestMethod   string `json:"request_method,omitempty"`
 	PathSearch      string `json:"path_search,omitempty"`
 	ParamKeysSearch string `json:"param_keys_search,omitempty"`
+	Source          string `json:"source,omitempty"` // traffic or param_mining
 }
 
 // Add other filter structs here as needed for different parts of your application.
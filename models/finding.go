@@ -18,7 +18,7 @@ type TargetFinding struct {
 	Recommendations     sql.NullString  `json:"recommendations,omitempty"`    // New field
 	Payload             sql.NullString  `json:"payload,omitempty"`
 	Severity            sql.NullString  `json:"severity,omitempty"` // Informational, Low, Medium, High, Critical
-	Status              string          `json:"status"`             // Open, Closed, Remediated, Accepted Risk
+	Status              string          `json:"status"`             // Draft, Open, Closed, Remediated, Accepted Risk
 	CVSSScore           sql.NullFloat64 `json:"cvss_score,omitempty"`
 	CWEID               sql.NullInt64   `json:"cwe_id,omitempty"`
 	FindingReferences   sql.NullString  `json:"finding_references,omitempty"`    // JSON string of URLs or IDs
@@ -32,3 +32,18 @@ type FindingLink struct {
 	ID    int64  `json:"id"`
 	Title string `json:"title"`
 }
+
+// FindingStatusDraft is the status of a finding promoted from traffic and not yet written up.
+const FindingStatusDraft = "Draft"
+
+// FindingSeverities are the accepted finding severities, lowest first.
+var FindingSeverities = []string{"Informational", "Low", "Medium", "High", "Critical"}
+
+// FindingPromotionRequest turns a traffic log entry into a draft finding. Everything is optional:
+// the title defaults to the vulnerability type and the request line.
+type FindingPromotionRequest struct {
+	VulnerabilityTypeID int64  `json:"vulnerability_type_id,omitempty"`
+	VulnerabilityType   string `json:"vulnerability_type,omitempty" example:"IDOR"` // Name (case-insensitive) instead of the ID
+	Title               string `json:"title,omitempty"`
+	Severity            string `json:"severity,omitempty" enums:"Informational,Low,Medium,High,Critical"`
+}
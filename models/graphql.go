@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// GraphQL operation types.
+const (
+	GraphQLOperationQuery        = "query"
+	GraphQLOperationMutation     = "mutation"
+	GraphQLOperationSubscription = "subscription"
+)
+
+// GraphQLEndpoint is a URL that received GraphQL operations.
+type GraphQLEndpoint struct {
+	ID                 int64          `json:"id"`
+	TargetID           sql.NullInt64  `json:"target_id,omitempty"`
+	URL                string         `json:"url"`
+	OperationCount     int            `json:"operation_count"`
+	HasSchema          bool           `json:"has_schema"`
+	SchemaSource       sql.NullString `json:"schema_source,omitempty"` // captured, introspection
+	SchemaFetchedAt    sql.NullTime   `json:"schema_fetched_at,omitempty"`
+	IntrospectionError sql.NullString `json:"introspection_error,omitempty"`
+	FirstSeenAt        time.Time      `json:"first_seen_at"`
+	LastSeenAt         time.Time      `json:"last_seen_at"`
+}
+
+// GraphQLOperation is a distinct operation sent to a GraphQL endpoint.
+type GraphQLOperation struct {
+	ID                   int64          `json:"id"`
+	GraphQLEndpointID    int64          `json:"graphql_endpoint_id"`
+	EndpointURL          string         `json:"endpoint_url,omitempty"` // Joined from graphql_endpoints for display
+	TargetID             sql.NullInt64  `json:"target_id,omitempty"`
+	OperationType        string         `json:"operation_type"`
+	OperationName        string         `json:"operation_name"`
+	Query                string         `json:"query"`
+	QueryHash            string         `json:"query_hash"`
+	PersistedQueryHash   sql.NullString `json:"persisted_query_hash,omitempty"` // Automatic persisted query hash, if the client sent one
+	Variables            sql.NullString `json:"variables,omitempty"`            // JSON of the latest variables seen
+	LastHTTPTrafficLogID sql.NullInt64  `json:"last_http_traffic_log_id,omitempty"`
+	SeenCount            int            `json:"seen_count"`
+	FirstSeenAt          time.Time      `json:"first_seen_at"`
+	LastSeenAt           time.Time      `json:"last_seen_at"`
+}
+
+// GraphQLVariableDefinition is a variable declared by an operation, e.g. ($id: ID! = 1).
+type GraphQLVariableDefinition struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	DefaultValue string `json:"default_value,omitempty"`
+}
+
+// GraphQLOperationDetail is an operation with the variable definitions parsed from its query.
+type GraphQLOperationDetail struct {
+	GraphQLOperation
+	VariableDefinitions []GraphQLVariableDefinition `json:"variable_definitions"`
+	RootFields          []string                    `json:"root_fields"`
+}
+
+// GraphQLOperationFilters are the query options for listing GraphQL operations.
+type GraphQLOperationFilters struct {
+	TargetID      int64
+	EndpointID    int64
+	OperationType string
+	Search        string // Matches operation name or query text
+	Page          int
+	Limit         int
+}
+
+// GraphQLIntrospectRequest is the payload for running introspection against an endpoint.
+type GraphQLIntrospectRequest struct {
+	SessionID int64             `json:"session_id,omitempty"` // Replay session whose cookies/headers are sent
+	Headers   map[string]string `json:"headers,omitempty"`    // Extra headers (override the session)
+}
+
+// GraphQLBuildTaskRequest is the payload for generating a modifier task from an operation.
+type GraphQLBuildTaskRequest struct {
+	Name          string            `json:"name,omitempty"`
+	OperationName string            `json:"operation_name,omitempty"` // Overrides the stored operation name
+	Query         string            `json:"query,omitempty"`          // Overrides the stored query
+	Variables     json.RawMessage   `json:"variables,omitempty"`      // Defaults to the latest variables seen
+	SessionID     int64             `json:"session_id,omitempty"`     // Replay session whose cookies/headers are applied
+	Headers       map[string]string `json:"headers,omitempty"`        // Extra headers (override the session)
+}
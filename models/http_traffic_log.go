@@ -21,6 +21,9 @@ type HTTPTrafficLog struct {
 	ResponseBody               []byte         `json:"response_body,omitempty"`
 	ResponseContentType        sql.NullString `json:"response_content_type,omitempty" example:"application/json"`
 	ResponseBodySize           int64          `json:"response_body_size,omitempty" example:"1024"`
+	ResponseBodyTruncated      bool           `json:"response_body_truncated"`             // The stored body is not the whole response: it exceeded proxy.max_capture_bytes or the stream ended early
+	ResponseContentEncoding    sql.NullString `json:"response_content_encoding,omitempty"` // Encoding the body arrived in; it is stored decoded
+	ResponseBodySHA256         sql.NullString `json:"response_body_sha256,omitempty"`      // Hash of the full body as it was streamed to the client
 	DurationMs                 int64          `json:"duration_ms,omitempty" example:"150"`
 	ClientIP                   sql.NullString `json:"client_ip,omitempty" example:"192.168.1.100"`
 	ServerIP                   sql.NullString `json:"server_ip,omitempty" example:"203.0.113.45"`
@@ -31,8 +34,14 @@ type HTTPTrafficLog struct {
 	IsFavorite                 bool           `json:"is_favorite"`
 	SourceModifierTaskID       sql.NullInt64  `json:"source_modifier_task_id,omitempty"` // Changed to sql.NullInt64 to match DB
 	LogSource                  sql.NullString `json:"log_source,omitempty"`
+	Origin                     string         `json:"origin,omitempty"` // "manual" or "automated", from LogSource; set by list endpoints
 	PageSitemapID              sql.NullInt64  `json:"page_sitemap_id,omitempty"`
 	PageSitemapName            sql.NullString `json:"page_sitemap_name,omitempty"`
+	ReplayBatchID              sql.NullInt64  `json:"replay_batch_id,omitempty"`
+	ReplaySourceLogID          sql.NullInt64  `json:"replay_source_log_id,omitempty"`
+	RequestFingerprint         sql.NullString `json:"-"`                             // Key under which the response cache may reuse the response
+	CachedFromLogID            sql.NullInt64  `json:"cached_from_log_id,omitempty"`  // Entry whose response the response cache reused
+	TLSConnectionID            sql.NullInt64  `json:"tls_connection_id,omitempty"`   // TLS parameters negotiated with the server (GET /api/tls-connections/{id})
 	AssociatedFindings         []FindingLink  `json:"associated_findings,omitempty"` // Already added in a previous step
 	Tags                       []Tag          `json:"tags,omitempty"`                // For associating tags with log entries
 }
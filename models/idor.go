@@ -0,0 +1,73 @@
+package models
+
+// Kinds of identifiers tracked for the IDOR worklist.
+const (
+	IDKindNumeric = "numeric"
+	IDKindUUID    = "uuid"
+)
+
+// Where an identifier appears in a request.
+const (
+	IDLocationPath  = "path"
+	IDLocationQuery = "query"
+	IDLocationBody  = "body"
+)
+
+// Suggested IDOR test types.
+const (
+	IDORTestIncrement   = "increment"    // The identifier plus one
+	IDORTestDecrement   = "decrement"    // The identifier minus one
+	IDORTestSwapID      = "swap_id"      // An identifier another session used in the same place
+	IDORTestSwapSession = "swap_session" // The same request replayed with another replay session
+)
+
+// IDORWorklist lists the places identifiers appear in a target's captured requests, most
+// promising first, with suggested tests.
+type IDORWorklist struct {
+	TargetID    int64           `json:"target_id"`
+	LogsScanned int             `json:"logs_scanned"`
+	Sessions    []IDORSession   `json:"sessions"`
+	Candidates  []IDORCandidate `json:"candidates"`
+}
+
+// IDORSession is a distinct set of session credentials (session cookies and Authorization) seen
+// in the captured requests.
+type IDORSession struct {
+	Key             string `json:"key"`   // Short hash of the credentials, "anonymous" without any
+	Label           string `json:"label"` // Name of the matching replay session, or "session <key>"
+	ReplaySessionID int64  `json:"replay_session_id,omitempty"`
+	Requests        int    `json:"requests"`
+	IDsSeen         int    `json:"ids_seen"` // Distinct identifiers in the session's requests and JSON responses
+}
+
+// IDORCandidate is one place an identifier appears on an endpoint: a path segment, a query
+// parameter or a body field.
+type IDORCandidate struct {
+	Method           string     `json:"method" example:"GET"`
+	Host             string     `json:"host" example:"app.example.com"`
+	Endpoint         string     `json:"endpoint" example:"/api/users/{id}/orders"` // Path with identifiers replaced
+	Location         string     `json:"location" enums:"path,query,body"`
+	Name             string     `json:"name" example:"users"` // Preceding path segment, parameter or body field
+	Kind             string     `json:"kind" enums:"numeric,uuid"`
+	Priority         int        `json:"priority"`
+	Requests         int        `json:"requests"`
+	SuccessResponses int        `json:"success_responses"` // Requests answered with a 2xx
+	DistinctValues   int        `json:"distinct_values"`
+	Sessions         []string   `json:"sessions"` // Labels of the sessions that sent it
+	ExampleLogID     int64      `json:"example_log_id"`
+	ExampleValue     string     `json:"example_value"`
+	Reasons          []string   `json:"reasons"`
+	Tests            []IDORTest `json:"tests"`
+}
+
+// IDORTest is a suggested request to try: the example request with another identifier, or
+// replayed with another session's credentials (POST /api/replay/batches with the log and
+// session IDs).
+type IDORTest struct {
+	Type            string `json:"type" enums:"increment,decrement,swap_id,swap_session"`
+	Description     string `json:"description"`
+	LogID           int64  `json:"log_id"`
+	Value           string `json:"value,omitempty"` // Identifier to send instead
+	URL             string `json:"url,omitempty"`   // Request URL with the identifier swapped (path and query identifiers)
+	ReplaySessionID int64  `json:"replay_session_id,omitempty"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Sources of inbox messages.
+const (
+	InboxSourceIMAP    = "imap"
+	InboxSourceWebhook = "webhook"
+)
+
+// How an inbox message was correlated to a target.
+const (
+	InboxMatchAddressTag    = "address_tag"    // A recipient is tagged with the target (hunter+t12@...)
+	InboxMatchScope         = "scope"          // A link or the sender's domain is in the target's scope
+	InboxMatchCurrentTarget = "current_target" // Nothing matched; the current target was under test
+)
+
+// Target variables set from the newest correlated message with a code or link.
+const (
+	InboxCodeVariable = "inbox_code"
+	InboxLinkVariable = "inbox_link"
+)
+
+// InboxMessage is an email read from the test inbox, with the verification codes and links found
+// in it.
+type InboxMessage struct {
+	ID          int64         `json:"id"`
+	TargetID    sql.NullInt64 `json:"target_id,omitempty"`
+	MatchReason string        `json:"match_reason,omitempty" enums:"address_tag,scope,current_target"`
+	Source      string        `json:"source" enums:"imap,webhook"`
+	MessageID   string        `json:"message_id,omitempty"` // Message-ID header
+	From        string        `json:"from"`
+	To          []string      `json:"to"`
+	Subject     string        `json:"subject"`
+	BodyText    string        `json:"body_text,omitempty"`
+	BodyHTML    string        `json:"body_html,omitempty"`
+	Codes       []string      `json:"codes"`             // Verification codes, most likely first
+	Links       []string      `json:"links"`             // http(s) links of the message
+	MagicLinks  []string      `json:"magic_links"`       // Links that look like verification, magic login or reset links
+	SentAt      *time.Time    `json:"sent_at,omitempty"` // Date header
+	ReceivedAt  time.Time     `json:"received_at"`       // When the toolkit read it
+}
+
+// InboxPollResult summarizes one poll of the IMAP mailbox.
+type InboxPollResult struct {
+	Fetched int     `json:"fetched"`
+	Stored  int     `json:"stored"` // New messages; already stored ones are skipped
+	IDs     []int64 `json:"ids"`
+}
+
+// InboxStatus reports the inbox configuration and the IMAP poller.
+type InboxStatus struct {
+	Enabled        bool       `json:"enabled"`
+	IMAPConfigured bool       `json:"imap_configured"`
+	WebhookToken   bool       `json:"webhook_token"` // The webhook requires a token
+	Running        bool       `json:"running"`
+	LastPollAt     *time.Time `json:"last_poll_at,omitempty"`
+	Polls          int64      `json:"polls"`
+	MessagesStored int64      `json:"messages_stored"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+}
+
+// InboxWebhookMessage is an email posted to the inbox webhook as JSON: parsed fields, or the raw
+// RFC 822 message in raw.
+type InboxWebhookMessage struct {
+	From      string      `json:"from"`
+	To        interface{} `json:"to" swaggertype:"array,string"` // An address, or a list of them
+	Subject   string      `json:"subject"`
+	Text      string      `json:"text"`
+	HTML      string      `json:"html"`
+	MessageID string      `json:"message_id"`
+	Raw       string      `json:"raw"`
+}
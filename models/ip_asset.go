@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// IP asset sources.
+const (
+	IPAssetSourceDNS       = "dns"
+	IPAssetSourceScopeRule = "scope_rule"
+	IPAssetSourceManual    = "manual"
+)
+
+// IPAsset is an IP address or CIDR block belonging to a target's asset inventory.
+type IPAsset struct {
+	ID          int64      `json:"id"`
+	TargetID    int64      `json:"target_id"`
+	Address     string     `json:"address" example:"192.0.2.10"` // Canonical IP or CIDR
+	IsCIDR      bool       `json:"is_cidr"`
+	IPVersion   int        `json:"ip_version" example:"4"`
+	Source      string     `json:"source" example:"dns"`
+	ReverseDNS  string     `json:"reverse_dns,omitempty"` // PTR names, comma-separated
+	ASN         *int64     `json:"asn,omitempty" example:"13335"`
+	ASOrg       string     `json:"as_org,omitempty" example:"CLOUDFLARENET - Cloudflare, Inc., US"`
+	ASCountry   string     `json:"as_country,omitempty" example:"US"`
+	ASPrefix    string     `json:"as_prefix,omitempty" example:"104.16.0.0/12"`
+	EnrichedAt  *time.Time `json:"enriched_at,omitempty"`
+	IsInScope   bool       `json:"is_in_scope"`
+	ScopeRuleID *int64     `json:"scope_rule_id,omitempty"` // Rule that decided is_in_scope
+	Notes       string     `json:"notes,omitempty"`
+	Domains     []string   `json:"domains"` // Domains of the target resolving to this address
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+}
+
+// IPAssetFilters narrows an IP asset listing.
+type IPAssetFilters struct {
+	TargetID  int64
+	IsInScope *bool
+	Source    string
+	ASN       int64
+	Search    string // Substring of the address, reverse DNS or AS organization
+	Limit     int
+	Offset    int
+}
+
+// IPAssetRequest is the payload for adding an IP or CIDR by hand.
+type IPAssetRequest struct {
+	Address string `json:"address" example:"192.0.2.0/24"`
+	Notes   string `json:"notes,omitempty"`
+	Enrich  bool   `json:"enrich,omitempty"` // Run reverse DNS and ASN lookups right away
+}
+
+// IPAssetRescopeResult summarizes a scope recomputation of a target's IP assets.
+type IPAssetRescopeResult struct {
+	Assets     int `json:"assets"`
+	InScope    int `json:"in_scope"`
+	OutOfScope int `json:"out_of_scope"`
+	Changed    int `json:"changed"`
+}
+
+// IPAssetListResponse is a page of a target's IP assets.
+type IPAssetListResponse struct {
+	Total   int64     `json:"total"`
+	Limit   int       `json:"limit"`
+	Offset  int       `json:"offset"`
+	Records []IPAsset `json:"records"`
+}
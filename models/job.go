@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Job types.
+const (
+	JobTypeHttpx            = "httpx"
+	JobTypeIPResolve        = "ip_resolve"        // Resolve domains into IP assets
+	JobTypeDomainEnrich     = "domain_enrich"     // Favicon hashes and Shodan/Censys lookups of domains
+	JobTypeHarvest          = "harvest"           // robots.txt, sitemap.xml and security.txt of live domains
+	JobTypeResponseBaseline = "response_baseline" // Soft-404/wildcard signatures of domains
+	JobTypeRedaction        = "redaction"         // Redaction rules applied to stored traffic
+	JobTypeTrafficAnalysis  = "traffic_analysis"  // Analyzers run over stored responses
+	JobTypePermutation      = "permutation"       // Subdomain candidates generated from known domains and resolved
+	JobTypeCORSCheck        = "cors_check"        // Captured endpoints replayed with crafted Origin headers
+	JobTypeTakeoverCheck    = "takeover_check"    // CNAME chains of domains verified against takeover fingerprints
+	JobTypeCloudStorage     = "cloud_storage"     // S3/GCS/Azure buckets checked for anonymous read and write access
+	JobTypeVhostDiscovery   = "vhost_discovery"   // Candidate Host headers sent to in-scope IPs to find hidden vhosts
+	JobTypeRedirectParams   = "redirect_params"   // Redirect and SSRF parameter candidates filed as findings
+	JobTypeParamMining      = "param_mining"      // Captured endpoints probed with candidate parameter names
+	JobTypeRateProfile      = "rate_profile"      // Bursts at rising rates to find where a host starts throttling
+	JobTypeBodyDedup        = "body_dedup"        // Inline traffic log bodies moved to deduplicated blobs
+	JobTypePluginRecon      = "plugin_recon"      // Domains found by a recon plugin imported into a target
+	JobTypeSync             = "sync"              // Changes exchanged with the peers of sync.peers
+	JobTypeBeautify         = "beautify"          // Readable copies of stored JavaScript, JSON, HTML and XML responses
+)
+
+// Job statuses.
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job is a background operation with its progress, error counts and duration.
+type Job struct {
+	ID             int64      `json:"id"`
+	Type           string     `json:"type" example:"httpx"`
+	TargetID       *int64     `json:"target_id,omitempty"`
+	Status         string     `json:"status" example:"running"`
+	Message        string     `json:"message"`
+	ItemsTotal     int64      `json:"items_total"`
+	ItemsProcessed int64      `json:"items_processed"`
+	ItemsSucceeded int64      `json:"items_succeeded"` // Items that produced a stored result
+	ErrorCount     int64      `json:"error_count"`
+	RetryCount     int64      `json:"retry_count"`
+	LastError      string     `json:"last_error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	DurationMs     *int64     `json:"duration_ms,omitempty"` // Set when the job finishes
+}
+
+// JobFilters narrows a job listing.
+type JobFilters struct {
+	Type     string
+	TargetID int64 // 0 = any target
+	Status   string
+	Limit    int
+}
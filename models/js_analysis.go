@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// JS endpoint kinds stored in js_endpoints.
+const (
+	JSEndpointKindURL     = "url"      // Absolute http(s) URL
+	JSEndpointKindPath    = "path"     // Relative path or route
+	JSEndpointKindDOMSink = "dom_sink" // Potential DOM XSS sink
+)
+
+// JSFile is a logical JavaScript bundle tracked across captures.
+type JSFile struct {
+	ID              int64         `json:"id"`
+	TargetID        sql.NullInt64 `json:"target_id,omitempty"`
+	URLKey          string        `json:"url_key"`
+	LatestURL       string        `json:"latest_url"`
+	LatestVersionID sql.NullInt64 `json:"latest_version_id,omitempty"`
+	VersionCount    int           `json:"version_count"`
+	FirstSeenAt     time.Time     `json:"first_seen_at"`
+	LastSeenAt      time.Time     `json:"last_seen_at"`
+}
+
+// JSFileVersion is one distinct content of a JSFile.
+type JSFileVersion struct {
+	ID                    int64          `json:"id"`
+	JSFileID              int64          `json:"js_file_id"`
+	HTTPTrafficLogID      sql.NullInt64  `json:"http_traffic_log_id,omitempty"`
+	URL                   string         `json:"url"`
+	ContentHash           string         `json:"content_hash"`
+	Size                  int64          `json:"size"`
+	SourceMapURL          sql.NullString `json:"sourcemap_url,omitempty"`
+	SourceMapStatus       string         `json:"sourcemap_status"` // none, inline, fetched, failed
+	SourceMapSourcesCount int            `json:"sourcemap_sources_count"`
+	EndpointCount         int            `json:"endpoint_count"`
+	AddedCount            int            `json:"added_count"`   // Endpoints not present in the previous version
+	RemovedCount          int            `json:"removed_count"` // Endpoints of the previous version that disappeared
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// JSEndpoint is an endpoint, path or DOM sink extracted from a JS file version.
+type JSEndpoint struct {
+	ID              int64          `json:"id"`
+	TargetID        sql.NullInt64  `json:"target_id,omitempty"`
+	JSFileID        int64          `json:"js_file_id"`
+	JSFileVersionID int64          `json:"js_file_version_id"`
+	Kind            string         `json:"kind"`
+	Value           string         `json:"value"`
+	Method          sql.NullString `json:"method,omitempty"`
+	SourceFile      sql.NullString `json:"source_file,omitempty"` // Original source file when extracted via a source map
+	Context         sql.NullString `json:"context,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// JSEndpointFilters are the query options for listing JS endpoints.
+type JSEndpointFilters struct {
+	TargetID    int64
+	JSFileID    int64
+	VersionID   int64
+	Kind        string
+	Search      string
+	AllVersions bool // Include endpoints of superseded versions (default: latest version of each file only)
+	Page        int
+	Limit       int
+}
+
+// JSBundleDiff lists the endpoints added and removed between two versions of a JS file.
+type JSBundleDiff struct {
+	JSFileID      int64        `json:"js_file_id"`
+	FromVersionID int64        `json:"from_version_id"`
+	ToVersionID   int64        `json:"to_version_id"`
+	Added         []JSEndpoint `json:"added"`
+	Removed       []JSEndpoint `json:"removed"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoginFlowLogSource is the log_source of the requests sent when replaying a login flow.
+const LoginFlowLogSource = "Login Flow"
+
+// Where a login flow extractor reads its value from in a step's response.
+const (
+	ExtractorSourceBodyRegex = "body_regex" // First capture group (or whole match) of a regular expression
+	ExtractorSourceJSONPath  = "json_path"  // JSON path into the response body ($.data.token)
+	ExtractorSourceHeader    = "header"     // Response header value
+	ExtractorSourceCookie    = "cookie"     // Value of a cookie set through Set-Cookie
+)
+
+// Login flow run outcomes and triggers.
+const (
+	LoginFlowStatusSucceeded = "succeeded"
+	LoginFlowStatusFailed    = "failed"
+
+	LoginFlowTriggerManual = "manual"
+	LoginFlowTriggerAuto   = "auto" // Session ended in captured traffic
+)
+
+// LoginFlowExtractor pulls a token from the response of a login flow step into a variable, which
+// later steps and the session header templates reference as {{name}}.
+type LoginFlowExtractor struct {
+	Name       string `json:"name"`
+	Step       int    `json:"step"` // 1-based position of the step whose response is read; 0 reads the last step
+	Source     string `json:"source" enums:"body_regex,json_path,header,cookie"`
+	Expression string `json:"expression"` // Regular expression, JSON path, header name or cookie name
+}
+
+// LoginFlowStep is a request of a login flow, copied from the traffic log.
+type LoginFlowStep struct {
+	ID          int64             `json:"id"`
+	Position    int               `json:"position"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body,omitempty"`
+	SourceLogID sql.NullInt64     `json:"source_log_id,omitempty"`
+}
+
+// LoginFlow is the sequence of requests that logs into a target, the tokens to extract from their
+// responses and the replay session the minted credentials are written to.
+type LoginFlow struct {
+	ID             int64                `json:"id"`
+	TargetID       int64                `json:"target_id"`
+	SessionID      sql.NullInt64        `json:"session_id,omitempty"`
+	Steps          []LoginFlowStep      `json:"steps"`
+	Extractors     []LoginFlowExtractor `json:"extractors"`
+	SessionHeaders map[string]string    `json:"session_headers"`
+	AutoRefresh    bool                 `json:"auto_refresh"`
+	LastRunAt      *time.Time           `json:"last_run_at,omitempty" swaggertype:"string" format:"date-time"`
+	LastStatus     string               `json:"last_status,omitempty" enums:"succeeded,failed"`
+	LastError      string               `json:"last_error,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
+
+// LoginFlowRequest is the payload for setting the login flow of a target. The steps are the given
+// log entries, in order. Without a session_id, the first run creates a replay session for the flow.
+type LoginFlowRequest struct {
+	LogIDs         []int64              `json:"log_ids"`
+	Extractors     []LoginFlowExtractor `json:"extractors"`
+	SessionHeaders map[string]string    `json:"session_headers"` // e.g. {"Authorization": "Bearer {{access_token}}"}
+	SessionID      *int64               `json:"session_id,omitempty"`
+	AutoRefresh    bool                 `json:"auto_refresh"`
+}
+
+// LoginFlowStepResult is the outcome of one request of a login flow run.
+type LoginFlowStepResult struct {
+	Position   int    `json:"position"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LogID      int64  `json:"log_id,omitempty"`
+}
+
+// LoginFlowRun is the outcome of replaying a login flow.
+type LoginFlowRun struct {
+	TargetID  int64                 `json:"target_id"`
+	Trigger   string                `json:"trigger" enums:"manual,auto"`
+	Status    string                `json:"status" enums:"succeeded,failed"`
+	Error     string                `json:"error,omitempty"`
+	Steps     []LoginFlowStepResult `json:"steps"`
+	Extracted map[string]string     `json:"extracted"` // Values of the extractors, also stored as target variables
+	Cookies   []string              `json:"cookies"`   // Names of the cookies the flow set
+	SessionID int64                 `json:"session_id,omitempty"`
+}
@@ -55,6 +55,12 @@ type Target struct {
 	Link       string      `json:"link" example:"https://alpha.example.com" format:"url"`
 	Notes      string      `json:"notes,omitempty" example:"Initial notes about the target."`
 	ScopeRules []ScopeRule `json:"scope_rules,omitempty"` // Associated scope rules for the target (populated for GET by ID).
+
+	// Bounty program metadata (populated for GET by ID).
+	PayoutRanges    []PayoutRange `json:"payout_ranges,omitempty"`
+	ProgramRules    string        `json:"program_rules,omitempty"`
+	ScopeFetchedAt  *time.Time    `json:"scope_fetched_at,omitempty"`  // Last time the scope was fetched from the program
+	ScopeReviewedAt *time.Time    `json:"scope_reviewed_at,omitempty"` // Last time scope changes were acknowledged
 }
 
 // TargetUpdateRequest defines the fields that can be updated for a target.
@@ -76,6 +82,8 @@ type DiscoveredURL struct {
 	HTTPTrafficLogID int64     `json:"http_traffic_log_id"`
 	URL              string    `json:"url"`
 	SourceType       string    `json:"source_type" example:"jsluice"`
+	CanonicalURL     string    `json:"canonical_url,omitempty"` // See core.CanonicalizeURL
+	SourceURL        string    `json:"source_url,omitempty"`    // URL of the response the URL was found in
 	DiscoveredAt     time.Time `json:"discovered_at" readOnly:"true"`
 }
 
@@ -115,8 +123,39 @@ type AnalysisResult struct {
 // Note represents a user-created note.
 type Note struct {
 	ID        int64          `json:"id" readOnly:"true"`
-	Title     sql.NullString `json:"title,omitempty"` // Optional title
-	Content   string         `json:"content" binding:"required"`
+	TargetID  sql.NullInt64  `json:"target_id,omitempty"`        // Optional target the note belongs to (null = global)
+	Title     sql.NullString `json:"title,omitempty"`            // Optional title
+	Content   string         `json:"content" binding:"required"` // Markdown; may contain [[wiki-style]] links
 	CreatedAt time.Time      `json:"created_at" readOnly:"true"`
 	UpdatedAt time.Time      `json:"updated_at" readOnly:"true"`
 }
+
+// NoteRequest is the payload for creating or updating a note.
+type NoteRequest struct {
+	TargetID *int64 `json:"target_id,omitempty"` // On update, omitted keeps the current target and 0 makes the note global
+	Title    string `json:"title,omitempty"`
+	Content  string `json:"content"`
+}
+
+// Note link types: what a [[wiki-style]] link in a note points at.
+const (
+	NoteLinkNote    = "note"
+	NoteLinkTarget  = "target"
+	NoteLinkFinding = "finding"
+	NoteLinkHTTPLog = "httplog"
+	NoteLinkDomain  = "domain"
+)
+
+// NoteLink is a [[wiki-style]] link found in a note's content.
+type NoteLink struct {
+	NoteID   int64         `json:"note_id"`
+	LinkType string        `json:"link_type"`
+	LinkedID sql.NullInt64 `json:"linked_id,omitempty"` // Null for links to a note by title that did not resolve
+	LinkText string        `json:"link_text"`
+}
+
+// NoteSearchResult is a note matched by full-text search, with a highlighted excerpt.
+type NoteSearchResult struct {
+	Note
+	Snippet string `json:"snippet"`
+}
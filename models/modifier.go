@@ -14,6 +14,7 @@ type ModifierTask struct {
 	BaseRequestURL           string         `json:"base_request_url"`
 	BaseRequestHeaders       sql.NullString `json:"base_request_headers,omitempty"` // JSON string of headers
 	BaseRequestBody          sql.NullString `json:"base_request_body,omitempty"`    // Base64 encoded body string
+	BaseRequestRaw           sql.NullString `json:"base_request_raw,omitempty"`     // Base64 encoded request of the raw editing mode
 	OriginalRequestHeaders   sql.NullString `json:"original_request_headers,omitempty"`
 	OriginalRequestBody      sql.NullString `json:"original_request_body,omitempty"`
 	OriginalResponseHeaders  sql.NullString `json:"original_response_headers,omitempty"`
@@ -21,6 +22,7 @@ type ModifierTask struct {
 	LastExecutedLogID        sql.NullInt64  `json:"last_executed_log_id,omitempty"`
 	SourceLogID              sql.NullInt64  `json:"source_log_id,omitempty"`       // Original http_traffic_log.id
 	SourceParameterizedURLID sql.NullInt64  `json:"source_param_url_id,omitempty"` // Original parameterized_urls.id
+	CollectionID             sql.NullInt64  `json:"collection_id,omitempty"`       // Folder the task is filed under
 	DisplayOrder             int            `json:"display_order"`                 // For ordering in the UI
 	CreatedAt                time.Time      `json:"created_at"`
 	UpdatedAt                time.Time      `json:"updated_at"`
@@ -34,3 +36,38 @@ type AddModifierTaskRequest struct {
 	// TargetID might be implicitly derived from the source or explicitly set.
 	// Name could be auto-generated or provided.
 }
+
+// ModifierCollection is a folder of modifier tasks, exportable as a Postman collection.
+type ModifierCollection struct {
+	ID          int64         `json:"id"`
+	TargetID    sql.NullInt64 `json:"target_id,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	TaskCount   int           `json:"task_count"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// ModifierCollectionRequest is the payload for creating or updating a modifier collection.
+type ModifierCollectionRequest struct {
+	TargetID    int64  `json:"target_id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TargetVariable is a per-target value substituted for {{name}} in the URL, headers and body of
+// modifier requests when they are executed.
+type TargetVariable struct {
+	ID        int64     `json:"id"`
+	TargetID  int64     `json:"target_id"`
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TargetVariableRequest is the payload for creating or updating a target variable.
+type TargetVariableRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Notification kinds (events). Each can be switched off under notifications.events.
+const (
+	NotificationNewSubdomain  = "new_subdomain"
+	NotificationScanFinished  = "scan_finished"
+	NotificationScopeChanged  = "scope_changed"
+	NotificationSynackTarget  = "synack_target"
+	NotificationSynackMission = "synack_mission"
+	NotificationCTInteresting = "ct_interesting"
+	NotificationEngagement    = "engagement_time"
+	NotificationTest          = "test"
+)
+
+// Notification is a message sent to the configured notification sinks.
+type Notification struct {
+	Kind      string      `json:"kind" example:"synack_target"`
+	Title     string      `json:"title" example:"New Synack target: ALPHA"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data,omitempty"` // The object the notification is about
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// NotificationSettings describes the configured sinks and enabled events.
+type NotificationSettings struct {
+	Sinks  []string        `json:"sinks" example:"desktop,slack"`
+	Events map[string]bool `json:"events"`
+}
+
+// SynackWatcherStatus reports the state of the Synack target/mission watcher.
+type SynackWatcherStatus struct {
+	Enabled            bool       `json:"enabled"`
+	Running            bool       `json:"running"`
+	LastPollAt         *time.Time `json:"last_poll_at,omitempty"`
+	Polls              int64      `json:"polls"`
+	NewTargets         int64      `json:"new_targets"`
+	NewMissions        int64      `json:"new_missions"`
+	NotificationsSent  int64      `json:"notifications_sent"`
+	NotificationErrors int64      `json:"notification_errors"`
+	LastError          string     `json:"last_error,omitempty"`
+	LastErrorAt        *time.Time `json:"last_error_at,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// ParamMiningLogSource is the log_source of parameter mining's confirming requests in http_traffic_log.
+const ParamMiningLogSource = "Param Mining"
+
+// Sources of parameter inventory entries.
+const (
+	ParameterSourceTraffic = "traffic"      // Parameter analysis of captured requests
+	ParameterSourceMining  = "param_mining" // Hidden parameter found by parameter mining
+)
+
+// Where a mined parameter is sent: the query string, or the form or JSON body of the captured request.
+const (
+	ParamLocationQuery = "query"
+	ParamLocationForm  = "form"
+	ParamLocationJSON  = "json"
+)
+
+// ParamMiningRequest starts a parameter mining job. Without log IDs, the target's distinct captured
+// GET endpoints with a 2xx response are mined, newest first. Mined parameters get the confidence
+// levels of redirect candidates: high when the value is reflected, medium when the status code
+// changes and low when only the content does.
+type ParamMiningRequest struct {
+	LogIDs       []int64  `json:"log_ids,omitempty"`       // Captured requests to mine; POST form and JSON bodies are mined too
+	Host         string   `json:"host,omitempty"`          // Only mine endpoints of this host
+	MaxEndpoints int      `json:"max_endpoints,omitempty"` // Default: param_mining.max_endpoints
+	Words        []string `json:"words,omitempty"`         // Extra parameter names
+	WordlistID   int64    `json:"wordlist_id,omitempty"`   // Stored wordlist whose words are added
+	WordsOnly    bool     `json:"words_only,omitempty"`    // Only Words and WordlistID, not the configured or built-in list
+	ChunkSize    int      `json:"chunk_size,omitempty"`    // Default: param_mining.chunk_size
+}
@@ -19,4 +19,6 @@ type ParameterizedURL struct {
 	DiscoveredAt     time.Time      `json:"discovered_at"`
 	LastSeenAt       time.Time      `json:"last_seen_at"`
 	HitCount         int            `json:"hit_count,omitempty"`
+	Source           string         `json:"source"`               // traffic or param_mining
+	Confidence       sql.NullString `json:"confidence,omitempty"` // low, medium or high for mined parameters
 }
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Payload placeholders expanded when a request is sent, next to the target's {{name}} variables.
+// A target variable of the same name takes precedence.
+const (
+	PlaceholderOOB          = "oob"           // <unique id>.<payloads.oob_domain>, one id per request
+	PlaceholderRandom       = "random"        // Random lowercase letters and digits (payloads.random_length)
+	PlaceholderTimestamp    = "timestamp"     // Unix time in seconds
+	PlaceholderTargetDomain = "target.domain" // Host of the target's link
+)
+
+// Sources of payload expansions.
+const (
+	PayloadSourceModifier    = "Modifier"
+	PayloadSourcePayloadList = "Payload list" // Payloads expanded for an external fuzzer
+)
+
+// PayloadExpansion is a value generated for a payload placeholder, with the request or payload it
+// was sent in, so out-of-band interactions and reflections can be correlated with it.
+type PayloadExpansion struct {
+	ID          int64     `json:"id"`
+	TargetID    *int64    `json:"target_id,omitempty"`
+	LogID       *int64    `json:"log_id,omitempty"` // Log entry of the request; unset for payload lists
+	Source      string    `json:"source" enums:"Modifier,Payload list"`
+	Placeholder string    `json:"placeholder" example:"oob"`
+	Value       string    `json:"value" example:"3f9a1c0b7d2e4a65.oob.example.com"`
+	OOBID       string    `json:"oob_id,omitempty" example:"3f9a1c0b7d2e4a65"`
+	Context     string    `json:"context,omitempty" example:"POST https://app.example.com/api/webhooks"` // Request line, or the payload as written
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PayloadExpansionFilters selects recorded payload expansions. Query matches an OOB identifier
+// anywhere in it (e.g. the full name of a DNS interaction) or a value exactly.
+type PayloadExpansionFilters struct {
+	TargetID int64
+	LogID    int64
+	Query    string
+	Limit    int
+}
+
+// PayloadExpandRequest expands the placeholders of payloads, one request's worth of values per
+// payload, for fuzzers that send them themselves.
+type PayloadExpandRequest struct {
+	Payloads   []string `json:"payloads,omitempty" example:"http://{{oob}}/x"`
+	WordlistID int64    `json:"wordlist_id,omitempty"` // Stored wordlist whose lines are expanded after Payloads
+}
+
+// PayloadExpandResult lists the expanded payloads, in order.
+type PayloadExpandResult struct {
+	Payloads   []string `json:"payloads"`
+	Recorded   int      `json:"recorded"`             // Generated values stored for correlation
+	Unresolved []string `json:"unresolved,omitempty"` // Placeholders without a value, left as written
+}
@@ -0,0 +1,45 @@
+package models
+
+// Platforms whose program scope can be imported.
+const (
+	ScopePlatformHackerOne = "hackerone"
+	ScopePlatformBugcrowd  = "bugcrowd"
+)
+
+// PlatformScopeEntry is one structured scope entry fetched from a bug bounty platform and the scope
+// rule it was converted to. SkipReason is set for entries that have no scope rule equivalent
+// (mobile apps, source code, hardware, ...).
+type PlatformScopeEntry struct {
+	AssetType      string `json:"asset_type" example:"WILDCARD"`
+	Identifier     string `json:"identifier" example:"*.example.com"`
+	InScope        bool   `json:"in_scope"`
+	BountyEligible bool   `json:"bounty_eligible"`
+	MaxSeverity    string `json:"max_severity,omitempty" example:"critical"`
+	Instruction    string `json:"instruction,omitempty"`
+	ItemType       string `json:"item_type,omitempty" example:"subdomain"`
+	Pattern        string `json:"pattern,omitempty" example:"*.example.com"`
+	SkipReason     string `json:"skip_reason,omitempty"`
+}
+
+// PlatformScopeImportRequest selects the platform program whose scope is imported into a target.
+type PlatformScopeImportRequest struct {
+	Platform      string `json:"platform" example:"hackerone" enums:"hackerone,bugcrowd"`
+	Program       string `json:"program" example:"security"` // HackerOne handle or Bugcrowd program code
+	ImportDomains bool   `json:"import_domains"`             // Also add in-scope hosts to the target's domains
+	DryRun        bool   `json:"dry_run"`                    // Fetch and convert only; nothing is stored
+}
+
+// PlatformScopeImportResult summarizes a scope import.
+type PlatformScopeImportResult struct {
+	TargetID        int64                `json:"target_id"`
+	Platform        string               `json:"platform"`
+	Program         string               `json:"program"`
+	DryRun          bool                 `json:"dry_run"`
+	RulesAdded      int                  `json:"rules_added"`
+	RulesExisting   int                  `json:"rules_existing"`
+	DomainsAdded    int                  `json:"domains_added"`
+	DomainsExisting int                  `json:"domains_existing"`
+	Skipped         int                  `json:"skipped"`
+	Errors          []string             `json:"errors,omitempty"`
+	Entries         []PlatformScopeEntry `json:"entries"`
+}
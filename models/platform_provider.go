@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PlatformProviderStatus reports what a bug bounty platform provider has seen and done since the
+// proxy started.
+type PlatformProviderStatus struct {
+	Name               string     `json:"name" example:"synack"`
+	Enabled            bool       `json:"enabled"`
+	TrafficURL         string     `json:"traffic_url,omitempty"` // Proxied URL whose responses the provider processes
+	HasAuthToken       bool       `json:"has_auth_token"`        // An auth token has been captured from proxied traffic
+	AuthTokenSeenAt    *time.Time `json:"auth_token_seen_at,omitempty"`
+	ResponsesProcessed int64      `json:"responses_processed"`
+	LastProcessedAt    *time.Time `json:"last_processed_at,omitempty"`
+	AnalyticsFetched   int64      `json:"analytics_fetched"`
+	AnalyticsFailed    int64      `json:"analytics_failed"`
+	Retries            int64      `json:"retries"` // Platform API requests retried after an error, 429 or 5xx
+	LastError          string     `json:"last_error,omitempty"`
+	LastErrorAt        *time.Time `json:"last_error_at,omitempty"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// Kinds of plugins. A plugin can be several.
+const (
+	PluginKindAnalyzer = "analyzer" // Extracts findings from captured response bodies, like the built-in analyzers
+	PluginKindRecon    = "recon"    // Finds domains of a target, which are imported like subfinder output
+	PluginKindNotifier = "notifier" // Delivers notifications, like the webhook sinks
+)
+
+// PluginProtocolVersion is the version of the JSON messages exchanged with plugins.
+const PluginProtocolVersion = 1
+
+// PluginManifest is a plugin's plugin.json.
+type PluginManifest struct {
+	Name           string   `json:"name" example:"wappalyzer"` // Lower-case letters, digits, - and _
+	Version        string   `json:"version,omitempty" example:"1.0.0"`
+	Description    string   `json:"description,omitempty"`
+	Protocol       int      `json:"protocol,omitempty" example:"1"`              // PluginProtocolVersion the plugin speaks; 1 when omitted
+	Kinds          []string `json:"kinds" example:"analyzer"`                    // analyzer, recon, notifier
+	Command        []string `json:"command" example:"python3,main.py"`           // Program and arguments; paths with a / are relative to the plugin directory
+	ContentTypes   []string `json:"content_types,omitempty" example:"text/html"` // Analyzers: response Content-Types analyzed (prefixes); text types when omitted
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" example:"30"`      // Per call; plugins.timeout_seconds when omitted
+	Env            []string `json:"env,omitempty" example:"SHODAN_API_KEY"`      // Environment variables passed on; the rest of the environment is not
+}
+
+// Plugin is a plugin found in plugins.dir.
+type Plugin struct {
+	PluginManifest
+	Dir       string     `json:"dir"`
+	Enabled   bool       `json:"enabled"`
+	Changed   bool       `json:"changed,omitempty"` // plugin.json changed since the plugin was enabled; it doesn't run until enabled again
+	Error     string     `json:"error,omitempty"`   // Why the plugin can't be used, e.g. an invalid manifest
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// PluginState is the stored on/off state of a plugin.
+type PluginState struct {
+	Name           string
+	Enabled        bool
+	ManifestSHA256 string
+	UpdatedAt      time.Time
+}
+
+// PluginRequest is what a plugin reads from stdin, once per call.
+type PluginRequest struct {
+	Protocol     int                 `json:"protocol"`
+	Kind         string              `json:"kind" enums:"analyzer,recon,notifier"`
+	Plugin       string              `json:"plugin"`
+	Analyze      *PluginAnalyzeInput `json:"analyze,omitempty"`      // Analyzer calls
+	Recon        *PluginReconInput   `json:"recon,omitempty"`        // Recon calls
+	Notification *Notification       `json:"notification,omitempty"` // Notifier calls
+}
+
+// PluginAnalyzeInput is a response body to analyze. Bodies that are not valid UTF-8 are passed
+// base64-encoded with body_base64 set.
+type PluginAnalyzeInput struct {
+	Body       string `json:"body"`
+	BodyBase64 bool   `json:"body_base64,omitempty"`
+}
+
+// PluginReconInput describes the target a recon plugin looks for domains of.
+type PluginReconInput struct {
+	TargetID int64    `json:"target_id"`
+	Codename string   `json:"codename"`
+	Scope    []string `json:"scope"`   // Patterns of the in-scope domain rules, e.g. *.example.com
+	Domains  []string `json:"domains"` // Domains the target already has
+}
+
+// PluginResponse is what a plugin writes to stdout. A plugin failing a call sets error or exits
+// non-zero.
+type PluginResponse struct {
+	Error    string          `json:"error,omitempty"`
+	Findings []PluginFinding `json:"findings,omitempty"` // Analyzer calls
+	Domains  []string        `json:"domains,omitempty"`  // Recon calls
+}
+
+// PluginFinding is an item an analyzer plugin extracted from a response body.
+type PluginFinding struct {
+	Category string `json:"category"`
+	Value    string `json:"value"`
+	Context  string `json:"context,omitempty"`
+}
+
+// PluginReconRequest runs a recon plugin for a target.
+type PluginReconRequest struct {
+	TargetID      int64 `json:"target_id"`
+	ValidateScope bool  `json:"validate_scope"` // Skip domains the target's scope rules don't cover
+	DryRun        bool  `json:"dry_run"`        // Run the plugin but store nothing
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ProxyExclusionRuleStats is a global proxy exclusion rule with how often it skipped a request.
+type ProxyExclusionRuleStats struct {
+	ProxyExclusionRule
+	Hits      int64      `json:"hits"`
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`
+}
+
+// ProxyExclusionHits counts the requests a rule skipped since its counter was last persisted.
+type ProxyExclusionHits struct {
+	Hits      int64
+	LastHitAt time.Time
+}
+
+// ProxyExclusionTestRequest is the body of a dry run of the exclusion rules against a URL.
+type ProxyExclusionTestRequest struct {
+	URL   string               `json:"url" example:"https://www.google-analytics.com/collect?v=1"`
+	Rules []ProxyExclusionRule `json:"rules,omitempty"` // Rules to test instead of the saved ones, e.g. unsaved edits
+}
+
+// ProxyExclusionRuleMatch is the outcome of one rule in a dry run.
+type ProxyExclusionRuleMatch struct {
+	ProxyExclusionRule
+	Matches bool   `json:"matches"`         // The pattern matches the URL, whether or not the rule is enabled
+	Error   string `json:"error,omitempty"` // Why the rule can never match, e.g. an invalid regex
+}
+
+// ProxyExclusionTestResult reports which exclusion rules match a URL.
+type ProxyExclusionTestResult struct {
+	URL           string                    `json:"url"`
+	Excluded      bool                      `json:"excluded"`                  // The proxy would skip the request
+	MatchedRuleID string                    `json:"matched_rule_id,omitempty"` // First enabled matching rule, the one credited with the hit
+	Rules         []ProxyExclusionRuleMatch `json:"rules"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ProxyListener is an additional proxy port bound to a target: requests through it are checked
+// against that target's scope and logged for it, whichever target is active. Useful to test
+// several programs at once from different browser profiles.
+type ProxyListener struct {
+	Port           string    `json:"port" example:"8779"`
+	TargetID       int64     `json:"target_id"`
+	TargetCodename string    `json:"target_codename"`
+	Running        bool      `json:"running"`         // Listening in this process
+	Error          string    `json:"error,omitempty"` // Why it is not listening, when the proxy runs
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ProxyListenerRequest adds a proxy listener, or binds an existing one to another target.
+type ProxyListenerRequest struct {
+	Port     string `json:"port,omitempty" example:"8779"` // Ignored when rebinding
+	TargetID int64  `json:"target_id"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ProxySampleCounts counts the requests of one sampled class since the proxy started.
+type ProxySampleCounts struct {
+	Seen    int64 `json:"seen"`
+	Logged  int64 `json:"logged"`
+	Skipped int64 `json:"skipped"`
+}
+
+// ProxyPerformanceStatus is the state of the proxy's performance mode, in which only 1 in
+// SampleRate out-of-scope or static-asset requests is logged.
+type ProxyPerformanceStatus struct {
+	Enabled     bool              `json:"enabled"`
+	SampleRate  int               `json:"sample_rate"`
+	OutOfScope  ProxySampleCounts `json:"out_of_scope"` // Requests not associated with a target
+	StaticAsset ProxySampleCounts `json:"static_asset"` // Images, fonts, stylesheets and media
+	Since       time.Time         `json:"since"`        // When the counters started
+}
+
+// ProxyPerformanceUpdate changes the performance mode. Omitted fields are left as they are.
+type ProxyPerformanceUpdate struct {
+	Enabled    *bool `json:"enabled,omitempty"`
+	SampleRate *int  `json:"sample_rate,omitempty" example:"20"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Events a proxy script runs on.
+const (
+	ProxyScriptEventRequest  = "request"  // Before the request is sent to the host
+	ProxyScriptEventResponse = "response" // Before the response is returned to the client and logged
+)
+
+// Actions a proxy script returns.
+const (
+	ProxyScriptActionContinue = "continue" // Pass the (possibly changed) message on; the default
+	ProxyScriptActionDrop     = "drop"     // Request: answer 403 without contacting the host. Response: don't log it
+)
+
+// ProxyScript is a user script the proxy runs on request and response events. The script gets the
+// message as JSON on stdin (ProxyScriptInput) and answers with JSON on stdout (ProxyScriptOutput),
+// which can change the message, tag its log entry or drop it.
+type ProxyScript struct {
+	ID          int64     `json:"id" readOnly:"true"`
+	Name        string    `json:"name" example:"sign-requests"`
+	Path        string    `json:"path" example:"hmac.lua"`                             // Script file in proxy_scripts.dir
+	Interpreter string    `json:"interpreter,omitempty" example:"lua" readOnly:"true"` // Command running the file, chosen by its extension (.lua, .js, .star, .py, .rb, .sh); empty when the file runs itself
+	Events      []string  `json:"events" example:"request"`                            // request, response
+	TargetID    *int64    `json:"target_id,omitempty"`                                 // Only traffic of this target; all traffic when omitted
+	HostPattern string    `json:"host_pattern,omitempty" example:"*.example.com"`      // Only hosts matching this pattern; * matches any characters
+	Enabled     bool      `json:"enabled"`
+	TimeoutMs   int       `json:"timeout_ms,omitempty" example:"2000"` // 0 for the default of 2000
+	Position    int       `json:"position"`                            // Scripts run in ascending position, then ID; each gets what the previous one returned
+	CreatedAt   time.Time `json:"created_at" readOnly:"true"`
+	UpdatedAt   time.Time `json:"updated_at" readOnly:"true"`
+
+	// Counters of this process, reset when the toolkit restarts.
+	Runs      int64      `json:"runs" readOnly:"true"`
+	Failures  int64      `json:"failures" readOnly:"true"`
+	LastError string     `json:"last_error,omitempty" readOnly:"true"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty" readOnly:"true"`
+}
+
+// ProxyScriptMessage is a request or response as a proxy script sees it. Bodies that are not valid
+// UTF-8 are passed base64-encoded with body_base64 set; a script returning a body sets body_base64
+// the same way. Response bodies are decoded (gzip, br, deflate) and omitted when larger than
+// proxy.script_max_body_bytes.
+type ProxyScriptMessage struct {
+	Method      string              `json:"method,omitempty"`      // Request only
+	URL         string              `json:"url,omitempty"`         // Request only
+	Status      int                 `json:"status,omitempty"`      // Response only
+	Headers     map[string][]string `json:"headers,omitempty"`     // Replaces all headers when returned
+	Body        *string             `json:"body,omitempty"`        // Left unchanged when not returned
+	BodyBase64  bool                `json:"body_base64,omitempty"` // Body is base64-encoded
+	BodyOmitted bool                `json:"body_omitted,omitempty"`
+}
+
+// ProxyScriptInput is what a proxy script reads from stdin.
+type ProxyScriptInput struct {
+	Event    string              `json:"event" enums:"request,response"`
+	Script   string              `json:"script"` // Name of the script
+	TargetID *int64              `json:"target_id,omitempty"`
+	Request  ProxyScriptMessage  `json:"request"`
+	Response *ProxyScriptMessage `json:"response,omitempty"` // Response events only
+}
+
+// ProxyScriptOutput is what a proxy script writes to stdout. An empty output changes nothing.
+type ProxyScriptOutput struct {
+	Action   string              `json:"action,omitempty" enums:"continue,drop"`
+	Request  *ProxyScriptMessage `json:"request,omitempty"`  // Request events: fields returned replace the request's
+	Response *ProxyScriptMessage `json:"response,omitempty"` // Response events: fields returned replace the response's
+	Tags     []string            `json:"tags,omitempty"`     // Tags added to the log entry, created when missing
+}
+
+// ProxyScriptTestRequest runs a proxy script against a logged request and response without sending
+// anything.
+type ProxyScriptTestRequest struct {
+	LogID int64  `json:"log_id"`
+	Event string `json:"event" enums:"request,response"` // Defaults to the script's first event
+}
+
+// ProxyScriptTestResult is what a proxy script returned for a logged request and response.
+type ProxyScriptTestResult struct {
+	Input      ProxyScriptInput  `json:"input"`
+	Output     ProxyScriptOutput `json:"output"`
+	Stderr     string            `json:"stderr,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+	Error      string            `json:"error,omitempty"`
+}
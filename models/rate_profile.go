@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// RateProfileLogSource is the log_source of the rate profiler's requests in http_traffic_log.
+const RateProfileLogSource = "Rate Profiler"
+
+// HostRateProfile is the measured rate-limit and WAF behavior of a host. A SafeRPS caps the rate
+// of every toolkit-initiated request to the host (replays, checks, repeater, raw requests).
+type HostRateProfile struct {
+	Host           string          `json:"host" example:"api.example.com"`
+	TargetID       *int64          `json:"target_id,omitempty"`
+	Endpoint       string          `json:"endpoint,omitempty"`
+	Method         string          `json:"method,omitempty"`
+	BaselineStatus int             `json:"baseline_status,omitempty"`
+	SafeRPS        *float64        `json:"safe_rps,omitempty"` // Unset when no throttling was seen
+	Manual         bool            `json:"manual"`             // SafeRPS was set by hand
+	ThrottleRPS    *float64        `json:"throttle_rps,omitempty"`
+	ThrottleStatus *int            `json:"throttle_status,omitempty"`
+	RetryAfter     string          `json:"retry_after,omitempty"`
+	MaxTestedRPS   float64         `json:"max_tested_rps"`
+	RequestsSent   int             `json:"requests_sent"`
+	WAFs           []WAFDetection  `json:"wafs"`
+	Steps          []RateBurstStep `json:"steps"`
+	ProfiledAt     *time.Time      `json:"profiled_at,omitempty"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// WAFDetection is a WAF or CDN security layer recognized from response signatures.
+type WAFDetection struct {
+	Name     string   `json:"name" example:"Cloudflare"`
+	Evidence []string `json:"evidence"` // Headers, cookies or body markers that matched
+}
+
+// RateBurstStep is one burst of the profiler at a fixed rate.
+type RateBurstStep struct {
+	RPS       float64     `json:"rps"`
+	Requests  int         `json:"requests"`
+	Statuses  map[int]int `json:"statuses"` // Status code -> responses (0 for transport errors)
+	Throttled int         `json:"throttled"`
+}
+
+// RateProfileRequest starts profiling the host of an endpoint: bursts of Requests requests at
+// StartRPS, doubling up to MaxRPS, until responses turn into 429, 503 or (unlike the baseline)
+// 403. Either URL or LogID (a captured request to resend) is required.
+type RateProfileRequest struct {
+	URL      string  `json:"url,omitempty" example:"https://api.example.com/health"`
+	LogID    int64   `json:"log_id,omitempty"`
+	StartRPS float64 `json:"start_rps,omitempty"` // Default: rate_profiler.start_rps
+	MaxRPS   float64 `json:"max_rps,omitempty"`   // Default: rate_profiler.max_rps
+	Requests int     `json:"requests,omitempty"`  // Requests per step. Default: rate_profiler.requests_per_step
+}
+
+// SetSafeRateRequest sets a host's safe rate by hand.
+type SetSafeRateRequest struct {
+	SafeRPS float64 `json:"safe_rps" example:"2.5"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Where redaction rules are applied.
+const (
+	RedactionApplyStorage = "storage" // Before traffic is saved; the stored database never holds the values
+	RedactionApplyExport  = "export"  // When requests are exported as curl/raw; stored traffic is untouched
+	RedactionApplyBoth    = "both"
+)
+
+// RedactionRules masks credentials in captured traffic.
+type RedactionRules struct {
+	Enabled     bool     `json:"enabled"`
+	ApplyAt     string   `json:"apply_at" example:"export"`
+	Replacement string   `json:"replacement" example:"[REDACTED]"`
+	Headers     []string `json:"headers" example:"Authorization,Cookie"`
+	JSONPaths   []string `json:"json_paths" example:"$.password,$..token"`
+	Parameters  []string `json:"parameters" example:"password"`
+}
+
+// TargetRedactionOverride adjusts the global redaction rules for one target. The lists are added
+// to the global ones unless Replace is set.
+type TargetRedactionOverride struct {
+	TargetID   int64     `json:"target_id" readOnly:"true"`
+	Enabled    *bool     `json:"enabled,omitempty"`  // Unset inherits the global switch
+	ApplyAt    string    `json:"apply_at,omitempty"` // Empty inherits the global setting
+	Replace    bool      `json:"replace"`
+	Headers    []string  `json:"headers"`
+	JSONPaths  []string  `json:"json_paths"`
+	Parameters []string  `json:"parameters"`
+	UpdatedAt  time.Time `json:"updated_at" readOnly:"true"`
+}
+
+// TargetRedactionView shows the redaction rules that apply to a target.
+type TargetRedactionView struct {
+	Global    RedactionRules           `json:"global"`
+	Override  *TargetRedactionOverride `json:"override,omitempty"`
+	Effective RedactionRules           `json:"effective"`
+}
@@ -0,0 +1,48 @@
+package models
+
+// RedirectCheckLogSource is the log_source of the redirect tests' requests in http_traffic_log.
+const RedirectCheckLogSource = "Redirect Check"
+
+// Confidence levels of a redirect or SSRF parameter candidate.
+const (
+	ConfidenceLow    = "low"    // Only the parameter name or only the captured value points at a URL
+	ConfidenceMedium = "medium" // The name and the captured value both point at a URL
+	ConfidenceHigh   = "high"   // A redirect to the marker domain was observed
+)
+
+// Confidences are the candidate confidence levels, lowest first.
+var Confidences = []string{ConfidenceLow, ConfidenceMedium, ConfidenceHigh}
+
+// Kinds of redirect and SSRF parameter candidates.
+const (
+	ParamKindOpenRedirect = "open_redirect"
+	ParamKindSSRF         = "ssrf"
+)
+
+// RedirectParamCandidate is a parameter of the target's parameter inventory that may take a URL
+// the server redirects to or fetches.
+type RedirectParamCandidate struct {
+	ParameterizedURLID int64    `json:"parameterized_url_id"`
+	LogID              int64    `json:"log_id,omitempty"`
+	Method             string   `json:"method" example:"GET"`
+	URL                string   `json:"url" example:"https://app.example.com/login?next=%2Fhome"`
+	Host               string   `json:"host"`
+	Path               string   `json:"path"`
+	Param              string   `json:"param" example:"next"`
+	Value              string   `json:"value,omitempty"` // Captured value
+	Kinds              []string `json:"kinds" enums:"open_redirect,ssrf"`
+	Reasons            []string `json:"reasons"`
+	Confidence         string   `json:"confidence" enums:"low,medium,high"`
+	Tested             bool     `json:"tested,omitempty"`
+	Confirmed          bool     `json:"confirmed,omitempty"`   // Redirected to the marker domain
+	TestLogID          int64    `json:"test_log_id,omitempty"` // Traffic log entry of the confirming test
+}
+
+// RedirectParamScanRequest starts a job filing the target's redirect and SSRF parameter
+// candidates as Draft findings.
+type RedirectParamScanRequest struct {
+	Host          string `json:"host,omitempty"`           // Only scan parameters of this host
+	Test          bool   `json:"test,omitempty"`           // Replay GET redirect candidates with the marker domain
+	MinConfidence string `json:"min_confidence,omitempty"` // Lowest confidence filed as a finding. Default: medium
+	MaxTests      int    `json:"max_tests,omitempty"`      // Default: redirect_check.max_tests
+}
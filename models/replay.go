@@ -0,0 +1,153 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ReplaySession is a stored set of cookies and headers (typically copied from a logged
+// browser request) that is applied to requests replayed through the proxy.
+type ReplaySession struct {
+	ID          int64             `json:"id"`
+	TargetID    sql.NullInt64     `json:"target_id,omitempty"`
+	Name        string            `json:"name"`
+	Cookies     string            `json:"cookies,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	SourceLogID sql.NullInt64     `json:"source_log_id,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// CreateReplaySessionFromLogRequest is the payload for building a session from a logged request.
+type CreateReplaySessionFromLogRequest struct {
+	HTTPTrafficLogID int64  `json:"http_traffic_log_id"`
+	Name             string `json:"name,omitempty"`
+}
+
+// ReplayBatch groups requests replayed together; every resulting log entry carries its ID.
+type ReplayBatch struct {
+	ID             int64          `json:"id"`
+	TargetID       sql.NullInt64  `json:"target_id,omitempty"`
+	SessionID      sql.NullInt64  `json:"session_id,omitempty"`
+	Name           sql.NullString `json:"name,omitempty"`
+	Status         string         `json:"status"`
+	TotalItems     int            `json:"total_items"`
+	CompletedItems int            `json:"completed_items"`
+	FailedItems    int            `json:"failed_items"`
+	DelayMs        int            `json:"delay_ms"`
+	Shuffle        bool           `json:"shuffle"`
+	PageID         sql.NullInt64  `json:"page_id,omitempty"`  // Page Sitemap page the batch replays as a flow
+	PreserveTiming bool           `json:"preserve_timing"`    // Wait as long between requests as when they were recorded
+	CarryCookies   bool           `json:"carry_cookies"`      // Cookies set by responses are sent with later requests
+	BaseURL        sql.NullString `json:"base_url,omitempty"` // Environment the requests were sent to instead of their captured host
+	LastError      sql.NullString `json:"last_error,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	StartedAt      sql.NullTime   `json:"started_at,omitempty"`
+	FinishedAt     sql.NullTime   `json:"finished_at,omitempty"`
+}
+
+// ReplayBatchItem is a single queued request in a replay batch.
+type ReplayBatchItem struct {
+	ID                    int64          `json:"id"`
+	BatchID               int64          `json:"batch_id"`
+	SourceLogID           sql.NullInt64  `json:"source_log_id,omitempty"`
+	SourceDiscoveredURLID sql.NullInt64  `json:"source_discovered_url_id,omitempty"`
+	RequestMethod         string         `json:"request_method"`
+	RequestURL            string         `json:"request_url"`
+	Status                string         `json:"status"`
+	ResponseStatusCode    sql.NullInt64  `json:"response_status_code,omitempty"`
+	Error                 sql.NullString `json:"error,omitempty"`
+	ExecutedAt            sql.NullTime   `json:"executed_at,omitempty"`
+}
+
+// CreateReplayBatchRequest is the payload for enqueuing a replay batch.
+// LogIDs, DiscoveredURLIDs or Requests (or several) must be provided.
+type CreateReplayBatchRequest struct {
+	TargetID         int64   `json:"target_id,omitempty"`
+	SessionID        int64   `json:"session_id,omitempty"`
+	Name             string  `json:"name,omitempty"`
+	LogIDs           []int64 `json:"log_ids,omitempty"`
+	DiscoveredURLIDs []int64 `json:"discovered_url_ids,omitempty"`
+	DelayMs          int     `json:"delay_ms,omitempty"`
+	Shuffle          bool    `json:"shuffle,omitempty"`
+	PageID           int64   `json:"page_id,omitempty"`
+	PreserveTiming   bool    `json:"preserve_timing,omitempty"`
+	CarryCookies     bool    `json:"carry_cookies,omitempty"`
+	BaseURL          string  `json:"base_url,omitempty" example:"https://staging.example.com"` // Send the requests to this scheme://host[:port][/prefix] instead, to compare environments
+
+	// Requests are built by the caller: a method and URL each, optionally with the log entry whose
+	// headers and body they reuse.
+	Requests []ReplayBatchItem `json:"-"`
+}
+
+// ReplayPageRequest is the payload for replaying a recorded Page Sitemap page as a flow: its logs
+// are replayed in the order they were recorded.
+type ReplayPageRequest struct {
+	SessionID      int64  `json:"session_id,omitempty"` // Replay session whose cookies and headers replace the recorded ones
+	Name           string `json:"name,omitempty"`
+	DelayMs        int    `json:"delay_ms,omitempty"`        // Fixed delay between requests, used unless PreserveTiming is set
+	PreserveTiming bool   `json:"preserve_timing,omitempty"` // Reproduce the recorded gaps between requests
+	CarryCookies   *bool  `json:"carry_cookies,omitempty"`   // Default true, like a browser following the flow
+}
+
+// ReplayBatchDetail is a batch together with its items.
+type ReplayBatchDetail struct {
+	ReplayBatch
+	Items []ReplayBatchItem `json:"items"`
+}
+
+// ReplayCompareRequest replays captured requests of a target against another environment (its
+// base URL) for a comparison with the captured responses. Without log IDs, the latest captured
+// request of each endpoint (method and path, IDs as {id}) of the target's own traffic is replayed.
+type ReplayCompareRequest struct {
+	BaseURL      string  `json:"base_url" example:"https://staging.example.com"`
+	LogIDs       []int64 `json:"log_ids,omitempty"`
+	Host         string  `json:"host,omitempty"`          // Only endpoints of this host when picking them
+	PathContains string  `json:"path_contains,omitempty"` // Only endpoints whose path contains this when picking them
+	MaxEndpoints int     `json:"max_endpoints,omitempty"` // When picking endpoints; default 100
+	SessionID    int64   `json:"session_id,omitempty"`    // Replay session with the other environment's cookies and headers
+	DelayMs      int     `json:"delay_ms,omitempty"`
+	Name         string  `json:"name,omitempty"`
+}
+
+// ReplayComparison compares the responses a replay batch got from another environment with the
+// captured responses it replays, per endpoint, endpoints with differences first.
+type ReplayComparison struct {
+	BatchID       int64                `json:"batch_id"`
+	BaseURL       string               `json:"base_url"`
+	Status        string               `json:"status"` // Of the batch; the report grows while it runs
+	Compared      int                  `json:"compared"`
+	Identical     int                  `json:"identical"`
+	StatusDiffers int                  `json:"status_differs"`
+	BodyDiffers   int                  `json:"body_differs"`
+	HeadersDiffer int                  `json:"headers_differ"`
+	Failed        int                  `json:"failed"` // Not answered, or not yet replayed
+	Endpoints     []EndpointComparison `json:"endpoints"`
+}
+
+// EndpointComparison holds the compared requests of one endpoint.
+type EndpointComparison struct {
+	Method        string              `json:"method" example:"GET"`
+	Path          string              `json:"path" example:"/api/users/{id}"`
+	StatusDiffers int                 `json:"status_differs"`
+	BodyDiffers   int                 `json:"body_differs"`
+	HeadersDiffer int                 `json:"headers_differ"`
+	Requests      []RequestComparison `json:"requests"`
+}
+
+// RequestComparison compares one captured request's response with the other environment's.
+type RequestComparison struct {
+	ItemID        int64    `json:"item_id"`
+	SourceLogID   int64    `json:"source_log_id"`
+	ReplayLogID   int64    `json:"replay_log_id,omitempty"`
+	URL           string   `json:"url"` // As sent to the other environment
+	SourceStatus  int      `json:"source_status"`
+	ReplayStatus  int      `json:"replay_status,omitempty"`
+	SourceSize    int      `json:"source_size"`
+	ReplaySize    int      `json:"replay_size"`
+	StatusChanged bool     `json:"status_changed"`
+	BodyMode      string   `json:"body_mode,omitempty" enums:"identical,json,text,binary"`
+	BodyChanges   int      `json:"body_changes"`             // Changed JSON values, or changed lines
+	HeaderChanges []string `json:"header_changes,omitempty"` // "added: X-Debug", "removed: Strict-Transport-Security", "changed: Server"
+	Error         string   `json:"error,omitempty"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Ways of combining the values of several URL template parameters.
+const (
+	EnumerationModeCartesian = "cartesian" // Every combination of the parameters' values
+	EnumerationModeZip       = "zip"       // The first values together, then the second ones, ...
+)
+
+// Verdicts of an enumerated request, from the answers of the sessions it was sent as.
+const (
+	EnumerationVerdictShared  = "shared"  // Several sessions got the same successful (2xx) body
+	EnumerationVerdictDiffers = "differs" // Several sessions got a successful answer, with different bodies
+	EnumerationVerdictSingle  = "single"  // One session got a successful answer
+	EnumerationVerdictDenied  = "denied"  // No session got a successful answer
+	EnumerationVerdictFailed  = "failed"  // No session got an answer
+	EnumerationVerdictPending = "pending" // Not sent by every session yet
+)
+
+// ReplayEnumerationRange is an inclusive range of integer values of a URL template parameter.
+type ReplayEnumerationRange struct {
+	From int64 `json:"from" example:"1"`
+	To   int64 `json:"to" example:"50"`
+	Step int64 `json:"step,omitempty"` // Default 1
+}
+
+// ReplayEnumerationRequest builds requests from a URL template such as
+// /api/users/{id}/orders/{orderId}, with each {name} parameter taking the values given for it,
+// and sends them through the proxy once per replay session. {{name}} target variables are filled
+// in first; a relative template is resolved against the base log entry's URL, or the target's URL.
+type ReplayEnumerationRequest struct {
+	URLTemplate string                            `json:"url_template" example:"/api/users/{id}/orders/{orderId}"`
+	Method      string                            `json:"method,omitempty" example:"GET"` // Default the base log entry's method, or GET
+	SourceLogID int64                             `json:"source_log_id,omitempty"`        // Base log entry whose headers and body the requests reuse
+	Values      map[string][]string               `json:"values,omitempty"`               // Values per parameter
+	Ranges      map[string]ReplayEnumerationRange `json:"ranges,omitempty"`               // Integer values per parameter
+	WordlistIDs map[string]int64                  `json:"wordlist_ids,omitempty"`         // Wordlist whose words a parameter takes
+	Mode        string                            `json:"mode,omitempty" enums:"cartesian,zip"`
+	SessionIDs  []int64                           `json:"session_ids,omitempty"` // A batch per session; default the target's sessions, or one batch without a session
+	DelayMs     int                               `json:"delay_ms,omitempty"`
+	Name        string                            `json:"name,omitempty"`
+	DryRun      bool                              `json:"dry_run,omitempty"` // Return the generated requests without sending them
+}
+
+// EnumeratedRequest is a request generated from a URL template, with the parameter values it was
+// built from.
+type EnumeratedRequest struct {
+	URL    string            `json:"url"`
+	Values map[string]string `json:"values"`
+}
+
+// ReplayEnumeration is a set of requests generated from a URL template and the replay batches
+// (one per session) that sent them.
+type ReplayEnumeration struct {
+	ID          int64               `json:"id"`
+	TargetID    int64               `json:"target_id"`
+	Name        string              `json:"name"`
+	Method      string              `json:"method"`
+	URLTemplate string              `json:"url_template"`
+	SourceLogID sql.NullInt64       `json:"source_log_id,omitempty"`
+	Parameters  []string            `json:"parameters"`
+	Requests    []EnumeratedRequest `json:"requests,omitempty"` // Left out of reports, which list them as results
+	BatchIDs    []int64             `json:"batch_ids"`
+	DryRun      bool                `json:"dry_run,omitempty"` // Generated only; nothing was stored or sent
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// ReplayEnumerationReport groups the answers to an enumeration's requests by request, across the
+// sessions they were sent as, requests more than one session could read first.
+type ReplayEnumerationReport struct {
+	ReplayEnumeration
+	Status   string                      `json:"status"` // running until every batch has finished
+	Sessions []EnumerationSessionSummary `json:"sessions"`
+	Verdicts map[string]int              `json:"verdicts"` // Requests per verdict
+	Results  []EnumeratedRequestResult   `json:"results"`
+}
+
+// EnumerationSessionSummary counts the answers one session (batch) got, by status class.
+type EnumerationSessionSummary struct {
+	BatchID       int64          `json:"batch_id"`
+	SessionID     int64          `json:"session_id,omitempty"`
+	SessionName   string         `json:"session_name"`
+	Status        string         `json:"status"`
+	StatusClasses map[string]int `json:"status_classes"` // "2xx", "4xx", ...
+	Failed        int            `json:"failed"`
+}
+
+// EnumeratedRequestResult holds the answers each session got to one enumerated request.
+type EnumeratedRequestResult struct {
+	EnumeratedRequest
+	Verdict   string                `json:"verdict" enums:"shared,differs,single,denied,failed,pending"`
+	Responses []EnumerationResponse `json:"responses"`
+}
+
+// EnumerationResponse is the answer one session got to an enumerated request.
+type EnumerationResponse struct {
+	BatchID     int64  `json:"batch_id"`
+	SessionName string `json:"session_name"`
+	LogID       int64  `json:"log_id,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Size        int    `json:"size"`
+	BodyHash    string `json:"body_hash,omitempty"` // Short SHA-256 of the response body
+	Error       string `json:"error,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+// Formats of requests generated from the parameter inventory.
+const (
+	RequestGenerationModifier = "modifier" // Modifier tasks, filed under a collection
+	RequestGenerationFfuf     = "ffuf"     // ffuf command lines fuzzing the parameters
+)
+
+// RequestGenerationRequest generates one request per endpoint (method, host and path) of a target's
+// parameter inventory. Templates take {method}, {host}, {path} and {params} for names and {name} and
+// {value} for parameter values; {{...}} is left for target variables, so "{{{name}}}" turns each
+// value into a {{<parameter>}} placeholder substituted when the task is executed.
+type RequestGenerationRequest struct {
+	Format          string   `json:"format,omitempty" example:"modifier"`               // modifier (default) or ffuf
+	Host            string   `json:"host,omitempty"`                                    // Only endpoints of this host
+	Methods         []string `json:"methods,omitempty" example:"GET,POST"`              // Only endpoints with these methods
+	PathContains    string   `json:"path_contains,omitempty" example:"/api/"`           // Only endpoints whose path contains this
+	Source          string   `json:"source,omitempty"`                                  // traffic or param_mining; both by default
+	NameTemplate    string   `json:"name_template,omitempty" example:"{method} {path}"` // Task names; default "{method} {host}{path}"
+	ValueTemplate   string   `json:"value_template,omitempty" example:"{{{name}}}"`     // Parameter values; captured values by default, FUZZ for ffuf
+	CollectionID    int64    `json:"collection_id,omitempty"`                           // File tasks under this collection instead of a new one
+	CollectionName  string   `json:"collection_name,omitempty"`                         // Name of the new collection
+	WordlistID      int64    `json:"wordlist_id,omitempty"`                             // ffuf wordlist; {{target_wordlist}} by default
+	IncludeExisting bool     `json:"include_existing,omitempty"`                        // Also endpoints that already have a task generated from them
+	MaxEndpoints    int      `json:"max_endpoints,omitempty"`                           // Default: 500
+	DryRun          bool     `json:"dry_run,omitempty"`                                 // Return the requests without creating tasks
+}
+
+// GeneratedRequest is the request generated for one endpoint.
+type GeneratedRequest struct {
+	Method              string   `json:"method"`
+	URL                 string   `json:"url"`
+	Parameters          []string `json:"parameters"`
+	ParameterizedURLIDs []int64  `json:"parameterized_url_ids"`   // Inventory entries of the endpoint
+	SourceLogID         int64    `json:"source_log_id,omitempty"` // Captured request the headers and body come from
+	TaskID              int64    `json:"task_id,omitempty"`       // Modifier task created
+	Name                string   `json:"name,omitempty"`
+	Args                []string `json:"args,omitempty"`    // ffuf arguments
+	Command             string   `json:"command,omitempty"` // ffuf command line, also usable as a checklist command
+}
+
+// RequestGenerationResult summarizes generating requests from a target's parameter inventory.
+type RequestGenerationResult struct {
+	TargetID     int64              `json:"target_id"`
+	Format       string             `json:"format"`
+	Endpoints    int                `json:"endpoints"` // Endpoints matching the filters
+	Generated    int                `json:"generated"`
+	Skipped      int                `json:"skipped"` // Endpoints that already have a generated task
+	CollectionID int64              `json:"collection_id,omitempty"`
+	Requests     []GeneratedRequest `json:"requests"`
+	Errors       []string           `json:"errors,omitempty"`
+}
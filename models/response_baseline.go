@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Sources of a response signature.
+const (
+	SignatureSourceProbe   = "probe"   // Response to a request for a random path that should not exist
+	SignatureSourceCluster = "cluster" // Near-identical responses logged for many different paths
+)
+
+// ResponseSignature identifies a family of near-identical responses, such as a host's soft-404 page.
+type ResponseSignature struct {
+	Source        string `json:"source" example:"probe"`
+	StatusCode    int    `json:"status_code" example:"200"`
+	SimHash       uint64 `json:"simhash,string"`                                              // 64-bit simhash of the normalized body
+	ContentLength int    `json:"content_length"`                                              // Body size of the sample response
+	WordCount     int    `json:"word_count,omitempty"`                                        // Words of the sample response
+	Title         string `json:"title,omitempty"`                                             // <title> of the sample response
+	Location      string `json:"location,omitempty" example:"https://app.example.com/{path}"` // Redirect target, with the requested path replaced by {path}
+	SampleURL     string `json:"sample_url"`
+	PathCount     int    `json:"path_count,omitempty"` // Distinct paths that returned it (cluster signatures)
+}
+
+// ResponseBaseline is what unknown paths of a domain look like. Tools requesting guessed paths
+// drop responses matching one of its signatures.
+type ResponseBaseline struct {
+	DomainID           int64               `json:"domain_id"`
+	TargetID           int64               `json:"target_id"`
+	BaseURL            string              `json:"base_url" example:"https://app.example.com"`
+	IsWildcard         bool                `json:"is_wildcard"` // Unknown paths do not return a plain 404
+	Signatures         []ResponseSignature `json:"signatures"`
+	ResponsesClustered int                 `json:"responses_clustered"` // Logged responses of the host in the last clustering pass
+	LastError          string              `json:"last_error,omitempty"`
+	UpdatedAt          time.Time           `json:"updated_at"`
+}
+
+// ResponseCluster is a group of near-identical responses logged for one host.
+type ResponseCluster struct {
+	StatusCode     int      `json:"status_code"`
+	SimHash        uint64   `json:"simhash,string"`
+	Title          string   `json:"title,omitempty"`
+	Responses      int      `json:"responses"`
+	PathCount      int      `json:"path_count"`
+	AvgLength      int      `json:"avg_length"`
+	SampleURLs     []string `json:"sample_urls"`
+	IsSoftNotFound bool     `json:"is_soft_not_found"` // Matches a probe signature, or too many paths share the page
+}
+
+// DomainResponseClusters is the result of clustering the logged responses of a domain.
+type DomainResponseClusters struct {
+	DomainID  int64             `json:"domain_id"`
+	BaseURL   string            `json:"base_url"`
+	Responses int               `json:"responses"`
+	Clusters  []ResponseCluster `json:"clusters"`
+}
+
+// ResponseBaselineRequest selects the domains of a target to baseline; with no domain_ids every
+// domain with an httpx response is baselined.
+type ResponseBaselineRequest struct {
+	DomainIDs []int64 `json:"domain_ids,omitempty"`
+}
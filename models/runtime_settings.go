@@ -0,0 +1,35 @@
+package models
+
+import "encoding/json"
+
+// RuntimeSettingKeyPrefix prefixes the app_settings keys of runtime settings changed through the
+// settings API ("runtime:rate_limit.burst"). Stored values take precedence over config.yaml.
+const RuntimeSettingKeyPrefix = "runtime:"
+
+// RuntimeSettingView is a runtime setting with its current value, as returned by the settings API.
+type RuntimeSettingView struct {
+	Key             string      `json:"key"`
+	Type            string      `json:"type"`
+	Description     string      `json:"description"`
+	Min             float64     `json:"min"`
+	PerTarget       bool        `json:"per_target"`
+	RequiresRestart bool        `json:"requires_restart"`
+	Value           interface{} `json:"value"`                     // Effective value
+	FileValue       interface{} `json:"file_value"`                // Value from config.yaml (or the built-in default)
+	GlobalValue     interface{} `json:"global_value,omitempty"`    // Global value a target override replaces
+	Overridden      bool        `json:"overridden"`                // A stored value replaces the file (or, for a target, the global) value
+	PendingValue    interface{} `json:"pending_value,omitempty"`   // Stored value of a requires_restart setting not applied yet
+	PendingRestart  bool        `json:"pending_restart,omitempty"` // The stored value takes effect on the next start
+}
+
+// RuntimeSettingsUpdate maps setting keys to new values. A null value removes the stored value so
+// the setting falls back to config.yaml (or, for a target, to the global value).
+type RuntimeSettingsUpdate map[string]json.RawMessage
+
+// RuntimeSettingsUpdateResult lists what an update changed.
+type RuntimeSettingsUpdateResult struct {
+	Updated         []string             `json:"updated"`
+	Reset           []string             `json:"reset"`
+	RestartRequired []string             `json:"restart_required"`
+	Settings        []RuntimeSettingView `json:"settings"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// SanitizeStep is what sanitizing a copy of the database did to one table.
+type SanitizeStep struct {
+	Table  string `json:"table"`
+	Action string `json:"action"`
+	Rows   int64  `json:"rows"` // Rows changed or deleted
+}
+
+// SanitizeReport describes a sanitized copy of the database.
+type SanitizeReport struct {
+	Path  string         `json:"path"`
+	Size  int64          `json:"size"` // Bytes
+	Steps []SanitizeStep `json:"steps"`
+}
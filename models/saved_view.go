@@ -0,0 +1,38 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Saved view types: the list a view applies to.
+const (
+	SavedViewTypeTrafficLog = "traffic_log"
+	SavedViewTypeDomains    = "domains"
+)
+
+// SavedViewFilterKeys are the query parameters of each list endpoint that a saved view may store.
+var SavedViewFilterKeys = map[string][]string{
+	SavedViewTypeTrafficLog: {"method", "status", "type", "search", "body", "filter_tag_ids", "domain", "origin", "source", "favorites_only", "capture_session_id", "sort_by", "sort_order", "limit"},
+	SavedViewTypeDomains: {"domain_name_search", "source_search", "is_in_scope", "is_favorite", "httpx_scan_status",
+		"filter_http_status_code", "filter_http_server", "filter_http_tech", "sort_by", "sort_order", "limit"},
+}
+
+// SavedView is a named combination of list filters and sorting.
+type SavedView struct {
+	ID        int64             `json:"id"`
+	TargetID  sql.NullInt64     `json:"target_id,omitempty"` // Null: available for every target
+	ViewType  string            `json:"view_type"`
+	Name      string            `json:"name"`
+	Filters   map[string]string `json:"filters"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// SavedViewRequest is the payload for creating or updating a saved view.
+type SavedViewRequest struct {
+	TargetID int64             `json:"target_id,omitempty"`
+	ViewType string            `json:"view_type"`
+	Name     string            `json:"name"`
+	Filters  map[string]string `json:"filters"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Secret statuses used for triage.
+const (
+	SecretStatusNew           = "new"
+	SecretStatusConfirmed     = "confirmed"
+	SecretStatusFalsePositive = "false_positive"
+)
+
+// Secret is a potential API key, token or credential detected in a logged body.
+type Secret struct {
+	ID               int64           `json:"id"`
+	TargetID         sql.NullInt64   `json:"target_id,omitempty"`
+	HTTPTrafficLogID int64           `json:"http_traffic_log_id"`
+	RuleName         string          `json:"rule_name"`
+	MatchExcerpt     string          `json:"match_excerpt"` // Redacted match with some surrounding context
+	SecretHash       string          `json:"secret_hash"`   // SHA-256 of the secret value, for de-duplication across logs
+	Entropy          sql.NullFloat64 `json:"entropy,omitempty"`
+	Location         string          `json:"location"` // response_body or request_body
+	Status           string          `json:"status"`
+	Notes            sql.NullString  `json:"notes,omitempty"`
+	RequestURL       sql.NullString  `json:"request_url,omitempty"` // Joined from http_traffic_log for display
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// SecretFilters are the query options for listing secrets.
+type SecretFilters struct {
+	TargetID int64
+	Status   string
+	RuleName string
+	LogID    int64
+	Page     int
+	Limit    int
+}
+
+// UpdateSecretStatusRequest is the triage payload for a secret.
+type UpdateSecretStatusRequest struct {
+	Status string  `json:"status"`
+	Notes  *string `json:"notes,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// Security header check states.
+const (
+	HeaderStatusOK            = "ok"           // Present and sound on every response it applies to
+	HeaderStatusWeak          = "weak"         // Present everywhere but with a weak value on some responses
+	HeaderStatusInconsistent  = "inconsistent" // Missing on some responses
+	HeaderStatusMissing       = "missing"      // Missing on every response it applies to
+	HeaderStatusDisclosed     = "disclosed"    // A version-disclosing header is sent
+	HeaderStatusNotApplicable = "n/a"          // No captured response it applies to
+)
+
+// SecurityHeaderReport grades the security headers observed in a target's captured responses,
+// per host, worst host first.
+type SecurityHeaderReport struct {
+	TargetID         int64                 `json:"target_id"`
+	ResponsesScanned int                   `json:"responses_scanned"`
+	Hosts            []HostSecurityHeaders `json:"hosts"`
+}
+
+// HostSecurityHeaders is the security header posture of one host.
+type HostSecurityHeaders struct {
+	Host           string                `json:"host" example:"app.example.com"`
+	Responses      int                   `json:"responses"`
+	HTMLResponses  int                   `json:"html_responses"`
+	HTTPSResponses int                   `json:"https_responses"`
+	Score          int                   `json:"score"` // 0-100, weighted over the checks that apply
+	Grade          string                `json:"grade" enums:"A,B,C,D,F"`
+	Checks         []SecurityHeaderCheck `json:"checks"`
+}
+
+// SecurityHeaderCheck is the result of one header check on a host. Issues name what is missing or
+// weak, and ExampleLogIDs are traffic log entries showing it.
+type SecurityHeaderCheck struct {
+	Header        string   `json:"header" example:"Strict-Transport-Security"`
+	Status        string   `json:"status" enums:"ok,weak,inconsistent,missing,disclosed,n/a"`
+	Applicable    int      `json:"applicable"` // Responses the check applies to
+	Present       int      `json:"present"`
+	Weak          int      `json:"weak"`
+	Values        []string `json:"values,omitempty"` // Distinct values seen, most common first
+	Issues        []string `json:"issues,omitempty"`
+	ExampleLogIDs []int64  `json:"example_log_ids,omitempty"`
+}
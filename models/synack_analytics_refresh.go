@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SynackAnalyticsRefreshStatus reports the progress of the bulk refresh of stale Synack analytics.
+type SynackAnalyticsRefreshStatus struct {
+	Running     bool       `json:"running"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	MaxAgeHours int        `json:"max_age_hours"` // Targets with analytics older than this were selected
+	Total       int        `json:"total"`
+	Refreshed   int        `json:"refreshed"`
+	Failed      int        `json:"failed"`
+	LastError   string     `json:"last_error,omitempty"`
+}
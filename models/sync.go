@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// Entities exchanged by sync, in the order they are applied: referenced rows come first.
+const (
+	SyncEntityTarget    = "target"
+	SyncEntityScopeRule = "scope_rule"
+	SyncEntityDomain    = "domain"
+	SyncEntityTraffic   = "traffic"
+	SyncEntityFinding   = "finding"
+)
+
+// SyncProtocolVersion is the version of the messages exchanged through POST /sync.
+const SyncProtocolVersion = 1
+
+// SyncRecord is the state of a synced row, or its deletion.
+type SyncRecord struct {
+	Entity    string                 `json:"entity"`
+	SyncID    string                 `json:"sync_id"`
+	UpdatedAt string                 `json:"updated_at"`        // Time of the last change; the later one wins
+	Deleted   bool                   `json:"deleted,omitempty"` // A tombstone; updated_at is the time of deletion
+	Refs      map[string]string      `json:"refs,omitempty"`    // sync_ids of referenced rows, e.g. {"target": "..."}
+	Values    map[string]interface{} `json:"values,omitempty"`  // Column values; blobs base64-encoded
+	Seq       int64                  `json:"-"`                 // Local change counter the record was read at
+}
+
+// SyncRequest is what an instance sends to a peer: its changes since the last exchange and the
+// point up to which it has the peer's changes.
+type SyncRequest struct {
+	Protocol   int          `json:"protocol"`
+	RequestID  string       `json:"request_id"`
+	InstanceID string       `json:"instance_id"`
+	SentAt     time.Time    `json:"sent_at"`
+	Since      int64        `json:"since"` // Peer's change counter up to which changes were received
+	Records    []SyncRecord `json:"records"`
+}
+
+// SyncResponse answers a SyncRequest with the peer's changes since the requested point.
+type SyncResponse struct {
+	Protocol   int             `json:"protocol"`
+	RequestID  string          `json:"request_id"` // That of the request
+	InstanceID string          `json:"instance_id"`
+	Records    []SyncRecord    `json:"records"`
+	Until      int64           `json:"until"` // Change counter the records go up to; the next request's since
+	More       bool            `json:"more"`  // More changes are waiting
+	Applied    SyncApplyResult `json:"applied"`
+}
+
+// SyncApplyResult counts what became of received records.
+type SyncApplyResult struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"` // Same data, or the local row is newer
+	Skipped   int `json:"skipped"`   // Referenced rows are missing, or the row conflicts with another one
+}
+
+// SyncRunResult is the outcome of syncing with a peer.
+type SyncRunResult struct {
+	Peer     string          `json:"peer"`
+	Pushed   int             `json:"pushed"`   // Records sent
+	Pulled   int             `json:"pulled"`   // Records received
+	Remote   SyncApplyResult `json:"remote"`   // What the peer did with the records sent
+	Local    SyncApplyResult `json:"local"`    // What was done with the records received
+	Requests int             `json:"requests"` // Exchanges it took
+}
+
+// SyncPeerStatus is the progress of syncing with a peer of sync.peers.
+type SyncPeerStatus struct {
+	Name        string         `json:"name"`
+	URL         string         `json:"url"`
+	PulledUntil int64          `json:"pulled_until"` // Peer's change counter up to which its changes were received
+	PushedUntil int64          `json:"pushed_until"` // Local change counter up to which changes were sent
+	Pending     int            `json:"pending"`      // Local changes not sent yet
+	LastSyncAt  *time.Time     `json:"last_sync_at,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	LastResult  *SyncRunResult `json:"last_result,omitempty"`
+}
+
+// SyncStatus describes the sync setup of this instance.
+type SyncStatus struct {
+	Enabled       bool             `json:"enabled"`
+	KeyConfigured bool             `json:"key_configured"`
+	InstanceID    string           `json:"instance_id"`
+	Sequence      int64            `json:"sequence"` // Local change counter
+	Peers         []SyncPeerStatus `json:"peers"`
+}
+
+// SyncRunRequest starts syncing with a peer, or with all peers when peer is empty.
+type SyncRunRequest struct {
+	Peer string `json:"peer"`
+}
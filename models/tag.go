@@ -5,13 +5,64 @@ import (
 	"time"
 )
 
+// Tag association item types that can be resolved to a target and a display label.
+const (
+	TagItemHTTPLog  = "httplog"
+	TagItemDomain   = "domain"
+	TagItemFinding  = "finding"
+	TagItemWordlist = "wordlist"
+)
+
+// TagItemTypes lists the item types tags are resolved for when listing tagged items and usage.
+var TagItemTypes = []string{TagItemHTTPLog, TagItemDomain, TagItemFinding, TagItemWordlist}
+
 // Tag represents a single tag that can be applied to various items.
 type Tag struct {
-	ID        int64          `json:"id" readOnly:"true"`
-	Name      string         `json:"name" binding:"required"`
-	Color     sql.NullString `json:"color,omitempty"` // Optional: e.g., hex code like #FF0000
-	CreatedAt time.Time      `json:"created_at" readOnly:"true"`
-	UpdatedAt time.Time      `json:"updated_at" readOnly:"true"`
+	ID          int64          `json:"id" readOnly:"true"`
+	Name        string         `json:"name" binding:"required"`
+	Color       sql.NullString `json:"color,omitempty"`       // Optional: e.g., hex code like #FF0000
+	ParentID    sql.NullInt64  `json:"parent_id,omitempty"`   // Optional parent tag (null = top level)
+	Description sql.NullString `json:"description,omitempty"` // Optional description of what the tag is used for
+	CreatedAt   time.Time      `json:"created_at" readOnly:"true"`
+	UpdatedAt   time.Time      `json:"updated_at" readOnly:"true"`
+}
+
+// TagSummary is a tag with the number of items carrying it (optionally limited to one target)
+// and the number of direct child tags.
+type TagSummary struct {
+	Tag
+	UsageCount int64 `json:"usage_count"`
+	ChildCount int64 `json:"child_count"`
+}
+
+// TagUsage is the number of items of one type carrying a tag within a target.
+type TagUsage struct {
+	TargetID       sql.NullInt64 `json:"target_id"`
+	TargetCodename string        `json:"target_codename,omitempty"`
+	ItemType       string        `json:"item_type"`
+	Count          int64         `json:"count"`
+}
+
+// TaggedItem is an item carrying a tag, resolved to its target and a display label.
+type TaggedItem struct {
+	TagID    int64         `json:"tag_id"`
+	ItemType string        `json:"item_type"`
+	ItemID   int64         `json:"item_id"`
+	TargetID sql.NullInt64 `json:"target_id"`
+	Label    string        `json:"label,omitempty"` // URL, domain name, finding title or wordlist name
+	TaggedAt time.Time     `json:"tagged_at"`
+}
+
+// TagMergeRequest is the payload for merging tags into another tag.
+type TagMergeRequest struct {
+	SourceTagIDs []int64 `json:"source_tag_ids"`
+}
+
+// TagRenameRequest is the payload for renaming a tag. With Cascade, child tags named
+// "<old name>/..." are renamed to "<new name>/..." as well.
+type TagRenameRequest struct {
+	Name    string `json:"name"`
+	Cascade bool   `json:"cascade"`
 }
 
 // TagAssociation represents the link between a Tag and an item.
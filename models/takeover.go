@@ -0,0 +1,17 @@
+package models
+
+// TakeoverCheckLogSource is the log_source of the takeover checker's requests in http_traffic_log.
+const TakeoverCheckLogSource = "Takeover Check"
+
+// Takeover statuses stored on a domain by the takeover check.
+const (
+	TakeoverStatusVulnerable    = "vulnerable"     // Fingerprint of an unclaimed resource matched, or the CNAME of a claimable service does not resolve
+	TakeoverStatusDangling      = "dangling"       // CNAME target does not resolve, but no service fingerprint confirms it
+	TakeoverStatusNotVulnerable = "not_vulnerable" // No CNAME, or the service answered normally
+)
+
+// TakeoverCheckRequest starts a subdomain takeover check. Without domain IDs, every domain of the
+// target within its scope rules is checked.
+type TakeoverCheckRequest struct {
+	DomainIDs []int64 `json:"domain_ids,omitempty"`
+}
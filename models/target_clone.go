@@ -0,0 +1,38 @@
+package models
+
+// Components a target clone can copy from its source.
+const (
+	TargetCloneScope         = "scope"          // In-scope rules
+	TargetCloneExclusions    = "exclusions"     // Out-of-scope rules
+	TargetCloneChecklist     = "checklist"      // Checklist items, reset to not completed and without notes
+	TargetCloneNotes         = "notes"          // Notes of the target, with their links
+	TargetCloneProgram       = "program"        // Payout ranges, program rules and program constraints
+	TargetCloneRedaction     = "redaction"      // Per-target redaction rules
+	TargetCloneClientProfile = "client_profile" // Headers, user agent and cookies added to toolkit requests
+	TargetCloneVariables     = "variables"      // Modifier variables
+	TargetCloneSavedViews    = "saved_views"    // Saved traffic log and domain filters
+	TargetCloneSettings      = "settings"       // Runtime setting overrides (capture and body size caps)
+)
+
+// TargetCloneComponents lists every component, in the order they are copied.
+var TargetCloneComponents = []string{
+	TargetCloneScope, TargetCloneExclusions, TargetCloneChecklist, TargetCloneNotes,
+	TargetCloneProgram, TargetCloneRedaction, TargetCloneClientProfile, TargetCloneVariables, TargetCloneSavedViews,
+	TargetCloneSettings,
+}
+
+// TargetCloneRequest is the payload of POST /targets/{target_id}/clone.
+type TargetCloneRequest struct {
+	Codename   string   `json:"codename" binding:"required"`
+	PlatformID int64    `json:"platform_id,omitempty"` // Defaults to the source target's platform
+	Link       string   `json:"link,omitempty"`        // Defaults to the source target's link
+	Notes      *string  `json:"notes,omitempty"`       // Defaults to the source target's notes
+	Components []string `json:"components,omitempty"`  // Defaults to every component
+}
+
+// TargetCloneResult is the new target and how many records of each component were copied.
+type TargetCloneResult struct {
+	Target         Target         `json:"target"`
+	SourceTargetID int64          `json:"source_target_id"`
+	Copied         map[string]int `json:"copied"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Scope rule history change types.
+const (
+	ScopeChangeAdded   = "added"
+	ScopeChangeRemoved = "removed"
+)
+
+// PayoutRange is the bounty paid for one severity level of a program.
+type PayoutRange struct {
+	Severity string  `json:"severity" example:"critical"`
+	Min      float64 `json:"min" example:"5000"`
+	Max      float64 `json:"max" example:"10000"`
+	Currency string  `json:"currency,omitempty" example:"USD"`
+}
+
+// TargetProgramRequest updates a target's program metadata. Omitted fields are left unchanged.
+type TargetProgramRequest struct {
+	PayoutRanges   *[]PayoutRange `json:"payout_ranges,omitempty"`
+	ProgramRules   *string        `json:"program_rules,omitempty"`
+	ScopeFetchedAt *time.Time     `json:"scope_fetched_at,omitempty"`
+}
+
+// ScopeRuleChange is one entry of a target's scope rule history.
+type ScopeRuleChange struct {
+	ID          int64     `json:"id"`
+	TargetID    int64     `json:"target_id"`
+	ScopeRuleID *int64    `json:"scope_rule_id,omitempty"`
+	ChangeType  string    `json:"change_type"` // added, removed
+	ItemType    string    `json:"item_type"`
+	Pattern     string    `json:"pattern"`
+	IsInScope   bool      `json:"is_in_scope"`
+	IsWildcard  bool      `json:"is_wildcard"`
+	Description string    `json:"description,omitempty"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// ScopeDiff is the net change of a target's scope between two points in time. A rule added and
+// removed again within the window does not appear in Added or Removed.
+type ScopeDiff struct {
+	TargetID int64             `json:"target_id"`
+	Since    *time.Time        `json:"since,omitempty"` // Nil when the scope was never reviewed: everything counts as added
+	Until    time.Time         `json:"until"`
+	Changed  bool              `json:"changed"`
+	Added    []ScopeRuleChange `json:"added"`
+	Removed  []ScopeRuleChange `json:"removed"`
+	Changes  []ScopeRuleChange `json:"changes"` // Every history entry in the window, oldest first
+}
+
+// Actions the proxy takes to enforce program constraints.
+const (
+	ConstraintActionBlocked   = "blocked"    // The path is banned; the request was answered with 403 without reaching the host
+	ConstraintActionHeaderSet = "header_set" // A required header was missing or had another value
+	ConstraintActionDelayed   = "delayed"    // The request would have exceeded max_rps
+)
+
+// TargetProgramConstraints are limits a program imposes on testing a target. The proxy enforces
+// them on the toolkit's own requests: banned paths are blocked, required headers are set and
+// requests are spaced to max_rps.
+type TargetProgramConstraints struct {
+	TargetID        int64             `json:"target_id" readOnly:"true"`
+	MaxRPS          float64           `json:"max_rps" example:"5"`                                   // Requests per second across the target's hosts; 0 for no limit
+	BannedPaths     []string          `json:"banned_paths" example:"/logout,/api/*/delete,/admin/*"` // Path patterns; * matches any characters and a pattern without one covers the paths under it
+	RequiredHeaders map[string]string `json:"required_headers"`                                      // Set on every request, replacing another value
+	UpdatedAt       time.Time         `json:"updated_at" readOnly:"true"`
+}
+
+// IsEmpty reports whether the constraints restrict nothing.
+func (c TargetProgramConstraints) IsEmpty() bool {
+	return c.MaxRPS <= 0 && len(c.BannedPaths) == 0 && len(c.RequiredHeaders) == 0
+}
+
+// ProgramConstraintEvent records one enforcement of a target's program constraints.
+type ProgramConstraintEvent struct {
+	ID        int64     `json:"id"`
+	TargetID  int64     `json:"target_id"`
+	Action    string    `json:"action" enums:"blocked,header_set,delayed"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Source    string    `json:"source,omitempty" example:"Replay"` // Log source of the toolkit request
+	Detail    string    `json:"detail,omitempty" example:"matches banned path /admin/*"`
+	DelayMs   int64     `json:"delay_ms,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Severities of weak TLS configurations.
+const (
+	TLSIssueHigh   = "high"
+	TLSIssueMedium = "medium"
+	TLSIssueLow    = "low"
+)
+
+// TLSConnection holds the TLS parameters the proxy negotiated with a server. Connections to a host
+// that negotiated the same parameters with the same certificate chain share one.
+type TLSConnection struct {
+	ID               int64                   `json:"id"`
+	Host             string                  `json:"host" example:"api.example.com"`
+	ServerName       string                  `json:"server_name,omitempty" example:"api.example.com"` // SNI sent to the server
+	Version          string                  `json:"tls_version" example:"TLS 1.3"`
+	CipherSuite      string                  `json:"cipher_suite" example:"TLS_AES_128_GCM_SHA256"`
+	ALPN             string                  `json:"alpn,omitempty" example:"h2"`
+	CertificateChain []TLSCertificateSummary `json:"certificate_chain"`          // Leaf first, as sent by the server
+	UntrustedReason  string                  `json:"untrusted_reason,omitempty"` // Why the chain does not verify against the system roots
+	HostnameMismatch bool                    `json:"hostname_mismatch"`          // The leaf certificate does not cover the host
+	FirstSeenAt      time.Time               `json:"first_seen_at"`
+	Requests         int                     `json:"requests,omitempty"` // Logged requests of the target sent over it; set by the report
+	LastSeenAt       *time.Time              `json:"last_seen_at,omitempty"`
+	Issues           []TLSIssue              `json:"issues,omitempty"` // Weaknesses as of now; set when loaded through core
+}
+
+// TLSCertificateSummary describes one certificate of a server's chain.
+type TLSCertificateSummary struct {
+	Subject            string    `json:"subject" example:"CN=api.example.com"`
+	Issuer             string    `json:"issuer" example:"CN=R11,O=Let's Encrypt,C=US"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	KeyType            string    `json:"key_type" example:"ECDSA"`
+	KeyBits            int       `json:"key_bits" example:"256"`
+	SignatureAlgorithm string    `json:"signature_algorithm" example:"SHA256-RSA"`
+	SelfSigned         bool      `json:"self_signed"`
+	SHA256             string    `json:"sha256"` // Fingerprint of the DER certificate
+}
+
+// TLSReport flags weak TLS configurations of the hosts a target's traffic was sent to over TLS,
+// from the connections the proxy made, weakest host first.
+type TLSReport struct {
+	TargetID int64          `json:"target_id"`
+	Hosts    []HostTLSState `json:"hosts"`
+}
+
+// HostTLSState is what the proxy negotiated with one host and what is weak about it.
+type HostTLSState struct {
+	Host         string                 `json:"host" example:"api.example.com"`
+	Requests     int                    `json:"requests"`
+	Versions     []string               `json:"versions"` // Negotiated versions, most used first
+	CipherSuites []string               `json:"cipher_suites"`
+	ServerNames  []string               `json:"server_names,omitempty"`
+	ALPN         []string               `json:"alpn,omitempty"`
+	Certificate  *TLSCertificateSummary `json:"certificate,omitempty"` // Leaf of the latest connection
+	Weak         bool                   `json:"weak"`
+	Issues       []TLSIssue             `json:"issues,omitempty"`
+	Connections  []TLSConnection        `json:"connections"`
+	LastSeenAt   *time.Time             `json:"last_seen_at,omitempty"`
+}
+
+// TLSIssue is a weakness found in a host's TLS configuration, with the connections showing it.
+type TLSIssue struct {
+	Severity      string  `json:"severity" enums:"high,medium,low"`
+	Issue         string  `json:"issue" example:"TLS 1.0 negotiated"`
+	ConnectionIDs []int64 `json:"connection_ids,omitempty"` // Set in host summaries
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// TLSHandshakeFailure tracks the clients of a host that failed the TLS handshake with the proxy's
+// MITM certificate. Hosts that fail consistently usually belong to apps pinning their certificates.
+type TLSHandshakeFailure struct {
+	Host                  string     `json:"host" example:"api.example.com"`
+	Failures              int64      `json:"failures"`
+	Successes             int64      `json:"successes"`              // Intercepted connections that carried a request since the first failure
+	CertificateRejections int64      `json:"certificate_rejections"` // Failures where the client answered with a certificate alert
+	LastError             string     `json:"last_error" example:"remote error: tls: bad certificate"`
+	FirstFailureAt        time.Time  `json:"first_failure_at"`
+	LastFailureAt         time.Time  `json:"last_failure_at"`
+	LastSuccessAt         *time.Time `json:"last_success_at,omitempty"`
+	LikelyPinned          bool       `json:"likely_pinned"`
+	Hint                  string     `json:"hint,omitempty"`
+}
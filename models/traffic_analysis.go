@@ -0,0 +1,7 @@
+package models
+
+// TrafficAnalysisRequest selects the analyzers and stored responses of a batch traffic analysis.
+type TrafficAnalysisRequest struct {
+	Analyzers   []string `json:"analyzers,omitempty"`                         // Defaults to analysis.analyzers from the config
+	ContentType string   `json:"content_type,omitempty" example:"javascript"` // Only responses whose Content-Type contains this
+}
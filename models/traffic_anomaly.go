@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Kinds of traffic anomalies.
+const (
+	AnomalyKindSize    = "size"    // Response much larger than the endpoint's median
+	AnomalyKindLatency = "latency" // Response much slower than the endpoint's median
+)
+
+// EndpointBaseline is what an endpoint's responses usually look like. Endpoints are told apart
+// by method, host and path, with numeric and UUID path segments replaced by {id} and {uuid}.
+type EndpointBaseline struct {
+	Method           string `json:"method" example:"GET"`
+	Endpoint         string `json:"endpoint" example:"https://api.example.com/users/{id}"`
+	Samples          int    `json:"samples"`
+	MedianSize       int64  `json:"median_size"`
+	MedianDurationMs int64  `json:"median_duration_ms"`
+}
+
+// TrafficAnomaly is a captured response that deviates massively from its endpoint's baseline.
+type TrafficAnomaly struct {
+	LogID      int64            `json:"log_id"`
+	Timestamp  time.Time        `json:"timestamp"`
+	Kind       string           `json:"kind" example:"size"`
+	URL        string           `json:"url"`
+	StatusCode int              `json:"status_code"`
+	LogSource  string           `json:"log_source,omitempty"`
+	Value      int64            `json:"value"`  // Response size in bytes or duration in milliseconds
+	Factor     float64          `json:"factor"` // Value divided by the endpoint's median
+	Baseline   EndpointBaseline `json:"baseline"`
+}
+
+// TrafficAnomalyFilters narrows an anomaly report. Zero thresholds use the anomalies config.
+type TrafficAnomalyFilters struct {
+	TargetID      int64
+	Host          string
+	Kind          string
+	SizeFactor    float64
+	LatencyFactor float64
+	MinSamples    int
+	Limit         int
+}
+
+// TrafficAnomalyReport lists a target's anomalous responses, largest deviation first.
+type TrafficAnomalyReport struct {
+	TargetID         int64            `json:"target_id"`
+	ResponsesScanned int              `json:"responses_scanned"`
+	Endpoints        int              `json:"endpoints"` // Endpoints with enough responses for a baseline
+	SizeFactor       float64          `json:"size_factor"`
+	LatencyFactor    float64          `json:"latency_factor"`
+	MinSamples       int              `json:"min_samples"`
+	Total            int              `json:"total"` // Anomalies found, before the limit
+	Anomalies        []TrafficAnomaly `json:"anomalies"`
+}
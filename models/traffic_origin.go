@@ -0,0 +1,36 @@
+package models
+
+// log_source values of http_traffic_log entries captured by the proxy or recorded by the UI. The
+// checks that send their own requests define theirs with their models (HarvesterLogSource, ...).
+const (
+	LogSourceProxy       = "mitmproxy"    // Browser traffic through the proxy
+	LogSourcePageSitemap = "Page Sitemap" // Browser traffic while recording a page
+	LogSourceModifier    = "Modifier"     // Requests sent from the modifier by the user
+	LogSourceJSAnalysis  = "JS Analysis"
+	LogSourceReplay      = "Replay"
+	LogSourceGraphQL     = "GraphQL Introspection"
+	LogSourceToolkit     = "Toolkit" // Other toolkit-initiated requests through the proxy
+)
+
+// Traffic origins: whether a logged request was made by the user or generated by the toolkit.
+const (
+	TrafficOriginManual    = "manual"
+	TrafficOriginAutomated = "automated"
+)
+
+// ManualLogSources are the log_source values of requests made by the user. Entries without a
+// log_source predate it and were captured by the proxy; every other source is automated.
+var ManualLogSources = []string{LogSourceProxy, LogSourcePageSitemap, LogSourceModifier}
+
+// TrafficOrigin classifies a log_source as manual or automated traffic.
+func TrafficOrigin(logSource string) string {
+	if logSource == "" {
+		return TrafficOriginManual
+	}
+	for _, s := range ManualLogSources {
+		if s == logSource {
+			return TrafficOriginManual
+		}
+	}
+	return TrafficOriginAutomated
+}
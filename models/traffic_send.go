@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Destinations a traffic log entry can be sent to.
+const (
+	SendToModifier   = "modifier"   // A modifier task built from the request
+	SendToReplay     = "replay"     // A replay batch sending the request again, optionally with a session
+	SendToAuthMatrix = "authmatrix" // A replay batch per replay session, to compare what each account gets
+	SendToFuzzer     = "fuzzer"     // An ffuf command fuzzing the request's parameters
+	SendToScanner    = "scanner"    // The response analyzers run over the entry
+	SendToCurl       = "curl"       // The request as a curl command
+	SendToRaw        = "raw"        // The request as a raw HTTP message
+)
+
+// Kinds of what sending a traffic log entry created.
+const (
+	SendArtifactModifierTask   = "modifier_task"
+	SendArtifactReplayBatch    = "replay_batch"
+	SendArtifactCommand        = "command"
+	SendArtifactAnalysisResult = "analysis_result"
+)
+
+// TrafficSendRequest sends a traffic log entry to another module. Fields other than destination
+// apply to some destinations only.
+type TrafficSendRequest struct {
+	Destination  string   `json:"destination" enums:"modifier,replay,authmatrix,fuzzer,scanner,curl,raw"`
+	Name         string   `json:"name,omitempty"`          // modifier, replay: name of the task or batch
+	CollectionID int64    `json:"collection_id,omitempty"` // modifier: file the task under this collection
+	SessionID    int64    `json:"session_id,omitempty"`    // replay: replay session whose cookies and headers are used
+	SessionIDs   []int64  `json:"session_ids,omitempty"`   // authmatrix: sessions to compare; all of the target's by default
+	Params       []string `json:"params,omitempty"`        // fuzzer: parameters to fuzz; all query and form parameters by default
+	WordlistID   int64    `json:"wordlist_id,omitempty"`   // fuzzer: wordlist; {{target_wordlist}} by default
+	Analyzers    []string `json:"analyzers,omitempty"`     // scanner: analyzers to run; analysis.analyzers by default
+}
+
+// TrafficSend records that a traffic log entry was sent to another module and what that created.
+// Created modifier tasks and replay batch items also point back to the entry (source_log_id).
+type TrafficSend struct {
+	ID           int64     `json:"id"`
+	LogID        int64     `json:"log_id"`
+	Destination  string    `json:"destination"`
+	ArtifactType string    `json:"artifact_type"`
+	ArtifactIDs  []int64   `json:"artifact_ids,omitempty"`
+	User         string    `json:"user"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TrafficSendResult is the outcome of sending a traffic log entry: the record of the send and,
+// depending on the destination, what was created.
+type TrafficSendResult struct {
+	TrafficSend
+	Task     *ModifierTask       `json:"task,omitempty"`
+	Batches  []ReplayBatchDetail `json:"batches,omitempty"`
+	Command  string              `json:"command,omitempty"`  // curl, raw or ffuf
+	Findings map[string]int      `json:"findings,omitempty"` // scanner: findings stored per analyzer (analysis_results)
+}
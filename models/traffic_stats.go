@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// TrafficStatsFilters selects the traffic a statistics report covers.
+type TrafficStatsFilters struct {
+	TargetID int64
+	From     time.Time
+	To       time.Time
+	Bucket   string // "hour" or "day"
+	Top      int    // Entries in the top endpoint/host lists
+	Origin   string // TrafficOriginManual or TrafficOriginAutomated; empty for all traffic
+}
+
+// TrafficStats is a per-target traffic report backing the dashboard, computed in one request.
+type TrafficStats struct {
+	TargetID      int64               `json:"target_id"`
+	From          time.Time           `json:"from"`
+	To            time.Time           `json:"to"`
+	Bucket        string              `json:"bucket" example:"hour"`
+	Origin        string              `json:"origin,omitempty"` // Only manual or automated traffic is counted
+	Summary       TrafficSummary      `json:"summary"`
+	Timeline      []TrafficTimeBucket `json:"timeline"` // One entry per bucket, empty buckets included
+	StatusCodes   []StatusCodeCount   `json:"status_codes"`
+	StatusClasses map[string]int64    `json:"status_classes"` // "2xx", "3xx", ...; "none" for requests without a response
+	Sources       []NamedCount        `json:"sources"`        // log_source; "Proxy" for browser traffic
+	Origins       []NamedCount        `json:"origins"`        // "manual" (the user's requests) and "automated" (the toolkit's)
+	TopEndpoints  []EndpointStats     `json:"top_endpoints"`
+	TopHosts      []NamedCount        `json:"top_hosts"`
+	NewHosts      []NewHostsBucket    `json:"new_hosts"`      // Hosts first seen for the target, per day
+	SlowEndpoints []EndpointStats     `json:"slow_endpoints"` // Highest average response time (at least 3 requests)
+}
+
+// TrafficSummary holds totals over the report window.
+type TrafficSummary struct {
+	Requests       int64      `json:"requests"`
+	BytesReceived  int64      `json:"bytes_received"` // Sum of response body sizes
+	AvgDurationMs  float64    `json:"avg_duration_ms"`
+	MaxDurationMs  int64      `json:"max_duration_ms"`
+	DistinctHosts  int64      `json:"distinct_hosts"`
+	DistinctURLs   int64      `json:"distinct_urls"`   // Without query strings
+	ErrorResponses int64      `json:"error_responses"` // 5xx
+	FirstRequestAt *time.Time `json:"first_request_at,omitempty"`
+	LastRequestAt  *time.Time `json:"last_request_at,omitempty"`
+}
+
+// TrafficTimeBucket holds the traffic of one hour or day.
+type TrafficTimeBucket struct {
+	Start         time.Time `json:"start"`
+	Requests      int64     `json:"requests"`
+	BytesReceived int64     `json:"bytes_received"`
+	AvgDurationMs float64   `json:"avg_duration_ms"`
+	Errors        int64     `json:"errors"` // 5xx
+}
+
+// StatusCodeCount is the number of responses with one status code.
+type StatusCodeCount struct {
+	StatusCode int   `json:"status_code"`
+	Count      int64 `json:"count"`
+}
+
+// NamedCount is a count keyed by name.
+type NamedCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// EndpointStats aggregates the requests of one method and URL (without query string).
+type EndpointStats struct {
+	Method        string  `json:"method" example:"GET"`
+	URL           string  `json:"url" example:"https://app.example.com/api/users"`
+	Requests      int64   `json:"requests"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	BytesReceived int64   `json:"bytes_received"`
+}
+
+// NewHostsBucket lists the hosts first seen on one day.
+type NewHostsBucket struct {
+	Day   time.Time `json:"day"`
+	Count int       `json:"count"`
+	Hosts []string  `json:"hosts"`
+}
+
+// BodyBlobStats summarizes the storage of traffic log bodies: bodies of at least 512 bytes are
+// stored once per SHA-256 in body_blobs and shared by the log entries that have them.
+type BodyBlobStats struct {
+	Blobs          int64 `json:"blobs"`
+	BlobBytes      int64 `json:"blob_bytes"`
+	References     int64 `json:"references"`     // Log entry bodies stored as blobs
+	SavedBytes     int64 `json:"saved_bytes"`    // Bytes the blobs' repeated references would take inline
+	InlineEntries  int64 `json:"inline_entries"` // Log entries with a body stored inline
+	InlineBytes    int64 `json:"inline_bytes"`
+	MovableEntries int64 `json:"movable_entries"` // Inline entries captured before deduplication that a body dedup job would move to blobs
+}
+
+// BodyDedupRequest starts moving inline traffic log bodies to deduplicated blobs.
+type BodyDedupRequest struct {
+	Vacuum bool `json:"vacuum"` // Rebuild the database file afterwards so it shrinks
+}
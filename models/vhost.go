@@ -0,0 +1,19 @@
+package models
+
+// DomainSourceVhost is the source recorded for domains found by vhost discovery.
+const DomainSourceVhost = "vhost"
+
+// VhostDiscoveryLogSource is the log_source of the vhost hits in http_traffic_log.
+const VhostDiscoveryLogSource = "Vhost Discovery"
+
+// VhostDiscoveryRequest controls a vhost discovery job. Candidate Host headers are the target's
+// domains and word.base names of its bases; each is sent to the in-scope IP assets and kept when
+// the answer differs from what the IP serves for unknown hosts.
+type VhostDiscoveryRequest struct {
+	IPAssetIDs    []int64  `json:"ip_asset_ids,omitempty"`   // Default: every in-scope, non-CIDR IP asset of the target
+	Ports         []int    `json:"ports,omitempty"`          // Default: vhost.ports; 443 and 8443 use HTTPS
+	Words         []string `json:"words,omitempty"`          // Words added to the permutation wordlist
+	WordlistID    int64    `json:"wordlist_id,omitempty"`    // Stored wordlist whose words are added as well
+	WordsOnly     bool     `json:"words_only,omitempty"`     // Use only Words and WordlistID, not the configured wordlist
+	MaxCandidates int      `json:"max_candidates,omitempty"` // Lower than vhost.max_candidates to try fewer hosts per IP
+}
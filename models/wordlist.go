@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Wordlist sources.
+const (
+	WordlistSourceUpload    = "upload"
+	WordlistSourceMerge     = "merge"
+	WordlistSourceGenerated = "generated"
+)
+
+// Kinds of words a target-specific wordlist is generated from.
+const (
+	WordlistKindPaths    = "paths"    // Full paths of captured, discovered and API endpoint URLs
+	WordlistKindSegments = "segments" // Single path segments: directory and file names
+	WordlistKindParams   = "params"   // Query parameter names
+	WordlistKindJS       = "js"       // Paths and routes extracted from JavaScript
+)
+
+// WordlistKinds lists the kinds of words generation can collect, all of them by default.
+var WordlistKinds = []string{WordlistKindPaths, WordlistKindSegments, WordlistKindParams, WordlistKindJS}
+
+// Wordlist is a stored wordlist, one word per line, for brute-force and fuzzing tools.
+type Wordlist struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name" example:"api-routes"`
+	Description string        `json:"description,omitempty"`
+	TargetID    sql.NullInt64 `json:"target_id,omitempty"` // Set for wordlists generated for, or uploaded to, a target
+	Source      string        `json:"source" example:"upload"`
+	WordCount   int64         `json:"word_count"`
+	Size        int64         `json:"size"`
+	SHA256      string        `json:"sha256"`
+	Tags        []string      `json:"tags"`
+	Path        string        `json:"path"` // File holding the words, for tools run on this machine
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// WordlistFilters filters the wordlist list. Zero values match everything.
+type WordlistFilters struct {
+	TargetID int64
+	Source   string
+	Tag      string
+}
+
+// WordlistDedupeResult is the outcome of removing duplicate words from a wordlist.
+type WordlistDedupeResult struct {
+	Wordlist Wordlist `json:"wordlist"`
+	Removed  int64    `json:"removed"`
+}
+
+// WordlistMergeRequest is the payload for merging wordlists into a new one.
+type WordlistMergeRequest struct {
+	IDs             []int64 `json:"ids"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description,omitempty"`
+	TargetID        int64   `json:"target_id,omitempty"`
+	Dedupe          bool    `json:"dedupe"`
+	CaseInsensitive bool    `json:"case_insensitive,omitempty"` // With Dedupe, "Admin" and "admin" are the same word
+}
+
+// WordlistGenerateRequest controls the generation of a target-specific wordlist from captured
+// traffic, discovered URLs, API endpoints, parameters and JavaScript.
+type WordlistGenerateRequest struct {
+	Name     string   `json:"name,omitempty"`      // Default: <codename>-generated; a generated wordlist of that name is replaced
+	Kinds    []string `json:"kinds,omitempty"`     // Default: all of WordlistKinds
+	MinCount int      `json:"min_count,omitempty"` // Only words seen at least this often (default 1)
+	MaxWords int      `json:"max_words,omitempty"` // Keep only the most frequent words (0 for all)
+}
@@ -29,15 +29,20 @@ async function handleResponse(response) {
         }
         return {}; // Return empty object for non-JSON or empty successful responses
     }
-    // Try to parse error message from JSON response
+    // API errors are problem details (application/problem+json) with a stable error code
     let errorMessage = `HTTP error ${response.status} ${response.statusText}`;
+    let errorCode = '';
     try {
         const errorData = await response.json();
-        errorMessage = errorData.message || errorMessage;
+        errorMessage = errorData.detail || errorData.message || errorMessage;
+        errorCode = errorData.code || '';
     } catch (e) {
         // If parsing JSON fails, use the original error message
     }
-    throw new Error(errorMessage);
+    const error = new Error(errorMessage);
+    error.status = response.status;
+    error.code = errorCode;
+    throw error;
 }
 
 /**
@@ -464,6 +469,21 @@ export async function saveProxyLogNotes(logId, notes) {
     return handleResponse(response);
 }
 
+/**
+ * Promotes a proxy log entry to a draft finding pre-filled with its request/response evidence.
+ * @param {number|string} logId - The ID of the log entry.
+ * @param {Object} data - Optional { vulnerability_type_id, title, severity }.
+ * @returns {Promise<Object>} - The created finding.
+ */
+export async function promoteLogToFinding(logId, data = {}) {
+    const response = await fetch(`${API_BASE}/traffic-log/entry/${logId}/promote`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(data)
+    });
+    return handleResponse(response);
+}
+
 /**
  * Deletes all proxy log entries for a specific target.
  * @param {number|string} targetId - The ID of the target whose logs are to be deleted.
@@ -598,6 +618,26 @@ export async function setProxyExclusionRules(rules) {
     return handleResponse(response);
 }
 
+export async function getProxyExclusionStats() {
+    const response = await fetch(`${API_BASE}/proxy/exclusions`);
+    return handleResponse(response);
+}
+
+/**
+ * Dry-runs the proxy exclusion rules against a URL.
+ * @param {string} url - The URL to test.
+ * @param {Array<Object>} [rules] - Rules to test instead of the saved ones (e.g. unsaved edits).
+ * @returns {Promise<Object>} - Which rules match and whether the proxy would skip the request.
+ */
+export async function testProxyExclusionRules(url, rules) {
+    const response = await fetch(`${API_BASE}/proxy/exclusions/test`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ url, rules }),
+    });
+    return handleResponse(response);
+}
+
 // --- Findings API ---
 
 /**
@@ -1080,6 +1120,26 @@ export async function importInScopeDomains(targetId) {
     return handleResponse(response);
 }
 
+/**
+ * Bulk imports domains from a newline-delimited or CSV file (or pasted text).
+ * @param {number|string} targetId - The ID of the target.
+ * @param {File|Blob} file - The domain list.
+ * @param {Object} options - { source, validate_scope, dry_run }.
+ * @returns {Promise<Object>} - Import summary (added, existing, duplicates, out_of_scope, invalid, skipped).
+ */
+export async function importDomainsFromFile(targetId, file, options = {}) {
+    const formData = new FormData();
+    formData.append('file', file);
+    if (options.source) formData.append('source', options.source);
+    formData.append('validate_scope', options.validate_scope ? 'true' : 'false');
+    formData.append('dry_run', options.dry_run ? 'true' : 'false');
+    const response = await fetch(`${API_BASE}/targets/${targetId}/domains/import`, {
+        method: 'POST',
+        body: formData,
+    });
+    return handleResponse(response);
+}
+
 /**
  * Deletes all domains for a specific target.
  * @param {number|string} targetId - The ID of the target.
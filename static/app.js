@@ -841,6 +841,7 @@ document.addEventListener('DOMContentLoaded', async function() {
                     <label for="findingStatus">Status:</label>
                     <select id="findingStatus" name="status">
                         <option value="Open" ${initialStatus === 'Open' ? 'selected' : ''}>Open</option>
+                        <option value="Draft" ${initialStatus === 'Draft' ? 'selected' : ''}>Draft</option>
                         <option value="Closed" ${initialStatus === 'Closed' ? 'selected' : ''}>Closed</option>
                         <option value="Remediated" ${initialStatus === 'Remediated' ? 'selected' : ''}>Remediated</option>
                         <option value="Accepted Risk" ${initialStatus === 'Accepted Risk' ? 'selected' : ''}>Accepted Risk</option>
@@ -1023,6 +1024,7 @@ document.addEventListener('DOMContentLoaded', async function() {
                         <label for="findingStatusEdit">Status:</label>
                         <select id="findingStatusEdit" name="status">
                             <option value="Open" ${finding.status === 'Open' ? 'selected' : ''}>Open</option>
+                            <option value="Draft" ${finding.status === 'Draft' ? 'selected' : ''}>Draft</option>
                             <option value="Closed" ${finding.status === 'Closed' ? 'selected' : ''}>Closed</option>
                             <option value="Remediated" ${finding.status === 'Remediated' ? 'selected' : ''}>Remediated</option>
                             <option value="Accepted Risk" ${finding.status === 'Accepted Risk' ? 'selected' : ''}>Accepted Risk</option>
@@ -1142,6 +1144,7 @@ document.addEventListener('DOMContentLoaded', async function() {
                         <label for="findingStatusView">Status:</label>
                         <select id="findingStatusView" name="status" disabled>
                             <option value="Open" ${finding.status === 'Open' ? 'selected' : ''}>Open</option>
+                            <option value="Draft" ${finding.status === 'Draft' ? 'selected' : ''}>Draft</option>
                             <option value="Closed" ${finding.status === 'Closed' ? 'selected' : ''}>Closed</option>
                             <option value="Remediated" ${finding.status === 'Remediated' ? 'selected' : ''}>Remediated</option>
                             <option value="Accepted Risk" ${finding.status === 'Accepted Risk' ? 'selected' : ''}>Accepted Risk</option>
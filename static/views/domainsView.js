@@ -344,6 +344,7 @@ export async function loadDomainsView(mainViewContainer) {
                     <button id="moreDomainActionsDropdownBtn" class="secondary small-button dropdown-toggle">More Actions</button>
                     <div class="dropdown-menu" id="moreDomainActionsDropdownMenu">
                         <a href="#" id="importInScopeDomainsBtnLink">Import In-Scope Domains</a>
+                        <a href="#" id="importDomainsFileBtnLink">Import Domains from File</a>
                         <a href="#" id="deleteAllDomainsBtnLink">Delete All Domains</a>
                         <a href="#" id="sendSelectedToSubfinderBtnLink">Send Selected to Subfinder</a>
                         <a href="#" id="sendSelectedToHttpxBtnLink">Send Selected to httpx</a>
@@ -435,6 +436,7 @@ export async function loadDomainsView(mainViewContainer) {
     });
 
     document.getElementById('importInScopeDomainsBtnLink')?.addEventListener('click', (e) => { e.preventDefault(); handleImportInScopeDomains(currentTargetId, currentTargetName); document.getElementById('moreDomainActionsDropdownMenu').classList.remove('show'); });
+    document.getElementById('importDomainsFileBtnLink')?.addEventListener('click', (e) => { e.preventDefault(); displayImportDomainsModal(currentTargetId, currentTargetName); document.getElementById('moreDomainActionsDropdownMenu').classList.remove('show'); });
     document.getElementById('deleteAllDomainsBtnLink')?.addEventListener('click', (e) => { e.preventDefault(); handleDeleteAllDomains(currentTargetId, currentTargetName); document.getElementById('moreDomainActionsDropdownMenu').classList.remove('show'); });
     document.getElementById('sendSelectedToSubfinderBtnLink')?.addEventListener('click', (e) => { e.preventDefault(); handleSendSelectedToSubfinder(currentTargetId); document.getElementById('moreDomainActionsDropdownMenu').classList.remove('show'); });
     document.getElementById('sendSelectedToHttpxBtnLink')?.addEventListener('click', (e) => { e.preventDefault(); handleSendSelectedToHttpx(currentTargetId); document.getElementById('moreDomainActionsDropdownMenu').classList.remove('show'); });
@@ -965,6 +967,33 @@ async function handleImportInScopeDomains(targetId, targetName) {
     } catch (error) { if (messageArea) setMessageWithCloseButton(messageArea, `Error importing: ${escapeHtml(error.message)}`, 'error-message'); stopSubfinderStatusUpdates(); console.error("Error importing domains:", error); }
 }
 
+function displayImportDomainsModal(targetId, targetName) {
+    if (!targetId) { uiService.showModalMessage("Error", "Target ID is missing."); return; }
+    const modalContentHTML = `
+        <form id="importDomainsForm">
+            <p>Import domains into target: <strong>${escapeHtml(targetName)}</strong> (ID: ${targetId}). One domain per line or CSV with a domain/host column.</p>
+            <div class="form-group"><label for="importDomainsFile">File:</label><input type="file" id="importDomainsFile" name="file" accept=".txt,.csv,.lst,text/plain,text/csv"></div>
+            <div class="form-group"><label for="importDomainsText">Or paste domains:</label><textarea id="importDomainsText" name="text" rows="8" placeholder="app.example.com&#10;api.example.com"></textarea></div>
+            <div class="form-group"><label for="importDomainsSource">Source:</label><input type="text" id="importDomainsSource" name="source" placeholder="import (e.g., amass)"></div>
+            <div class="form-group"><label for="importDomainsValidateScope">Skip out-of-scope domains:</label><input type="checkbox" id="importDomainsValidateScope" name="validate_scope" checked></div>
+            <div id="importDomainsModalMessage" class="message-area" style="margin-top: 10px;"></div>
+        </form>`;
+    uiService.showModalConfirm("Import Domains", modalContentHTML, async () => {
+        const form = document.getElementById('importDomainsForm');
+        const modalMessageArea = document.getElementById('importDomainsModalMessage');
+        if (!form || !modalMessageArea) return false;
+        const text = form.elements.text.value.trim();
+        const file = form.elements.file.files[0] || (text ? new Blob([text], { type: 'text/plain' }) : null);
+        if (!file) { modalMessageArea.textContent = "Choose a file or paste domains."; modalMessageArea.className = 'message-area error-message'; return false; }
+        try {
+            const result = await apiService.importDomainsFromFile(targetId, file, { source: form.elements.source.value.trim(), validate_scope: form.elements.validate_scope.checked });
+            const messageArea = document.getElementById('discoverSubdomainsMessage');
+            if (messageArea) setMessageWithCloseButton(messageArea, `Imported ${result.added} of ${result.rows} rows. Skipped: ${result.existing} already tracked, ${result.duplicates} duplicate, ${result.out_of_scope} out of scope, ${result.invalid} invalid.`, 'success-message');
+            fetchAndRenderDomainsTable(targetId); return true;
+        } catch (error) { modalMessageArea.textContent = `Error: ${escapeHtml(error.message)}`; modalMessageArea.className = 'message-area error-message'; console.error("Error importing domains:", error); return false; }
+    }, () => {}, "Import", "Cancel", true);
+}
+
 async function handleDeleteAllDomains(targetId, targetName) {
     if (!targetId) { uiService.showModalMessage("Error", "Target ID missing."); return; }
     uiService.showModalConfirm("Confirm Delete All Domains", `Delete ALL domains for target "${escapeHtml(targetName)}"?`, async () => {
@@ -611,6 +611,10 @@ async function loadModifierTaskIntoWorkspace(taskId) {
                             <input type="checkbox" id="modSendThroughProxyToggle" style="margin-right: 8px;" ${localStorage.getItem('modifierSendThroughProxy') === 'true' ? 'checked' : ''}>
                             <label for="modSendThroughProxyToggle" style="font-weight:normal;">Send through Proxy (log traffic)</label>
                         </div>
+                        <div class="form-group" style="display: flex; align-items: center; margin-bottom: 10px; margin-top: 10px;">
+                            <input type="checkbox" id="modApplyClientProfileToggle" style="margin-right: 8px;" ${localStorage.getItem('modifierApplyClientProfile') !== 'false' ? 'checked' : ''}>
+                            <label for="modApplyClientProfileToggle" style="font-weight:normal;">Apply target client profile (headers set above take precedence)</label>
+                        </div>
                         <button id="sendModifiedRequestBtn" class="primary" style="margin-top: 5px;">Send Request</button>
                     </div>
                 </div>
@@ -711,6 +715,10 @@ async function loadModifierTaskIntoWorkspace(taskId) {
             });
         }
 
+        document.getElementById('modApplyClientProfileToggle')?.addEventListener('change', (event) => {
+            localStorage.setItem('modifierApplyClientProfile', event.target.checked);
+        });
+
 
         setupEncoderDecoderListeners();
 
@@ -831,6 +839,7 @@ async function handleSendModifiedRequest(taskId) {
     let headersStringForSending = document.getElementById('modHeaders').value; 
     const body = document.getElementById('modBody').value; 
     const sendThroughProxy = localStorage.getItem('modifierSendThroughProxy') === 'true';
+    const applyClientProfile = localStorage.getItem('modifierApplyClientProfile') !== 'false';
     const requestStatusMessageEl = document.getElementById('modifierRequestStatusMessage');
     const sendButton = document.getElementById('sendModifiedRequestBtn');
 
@@ -888,6 +897,7 @@ async function handleSendModifiedRequest(taskId) {
             url: url,
             headers: headersStringForSending, 
             body: body,
+            skip_client_profile: !applyClientProfile,
             customHeaders: { // Pass custom headers to apiService.executeModifiedRequest
                 'X-Modifier-Send-Through-Proxy': sendThroughProxy ? 'true' : 'false'
             }
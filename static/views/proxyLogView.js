@@ -74,6 +74,61 @@ function formatBody(body, contentType = '') {
     }
 }
 
+async function handlePromoteLogToFinding(logId) {
+    let vulnTypes = [];
+    try {
+        vulnTypes = await apiService.getAllVulnerabilityTypes() || [];
+    } catch (error) {
+        console.error("Error fetching vulnerability types:", error);
+    }
+    const typeOptions = vulnTypes.map(vt => `<option value="${vt.id}">${escapeHtml(vt.name)}</option>`).join('');
+    const modalContentHTML = `
+        <p>Creates a Draft finding pre-filled with the URL, method, and request/response evidence of log ${escapeHtml(String(logId))}.</p>
+        <div class="form-group">
+            <label for="promoteVulnType">Vulnerability Type:</label>
+            <select id="promoteVulnType"><option value="">-- None --</option>${typeOptions}</select>
+        </div>
+        <div class="form-group">
+            <label for="promoteSeverity">Severity:</label>
+            <select id="promoteSeverity">
+                <option value="">-- Not set --</option>
+                <option value="Informational">Informational</option>
+                <option value="Low">Low</option>
+                <option value="Medium">Medium</option>
+                <option value="High">High</option>
+                <option value="Critical">Critical</option>
+            </select>
+        </div>
+        <div class="form-group">
+            <label for="promoteTitle">Title (optional):</label>
+            <input type="text" id="promoteTitle" placeholder="Defaults to the type and request line">
+        </div>
+    `;
+
+    uiService.showModalConfirm(
+        "Promote to Draft Finding",
+        modalContentHTML,
+        async () => {
+            const data = {
+                severity: document.getElementById('promoteSeverity').value,
+                title: document.getElementById('promoteTitle').value.trim()
+            };
+            const vulnTypeId = document.getElementById('promoteVulnType').value;
+            if (vulnTypeId) data.vulnerability_type_id = parseInt(vulnTypeId, 10);
+            try {
+                const finding = await apiService.promoteLogToFinding(logId, data);
+                uiService.showModalMessage("Draft Finding Created",
+                    `Created draft finding <a href="#current-target?id=${finding.target_id}&tab=findingsTab">#${finding.id}</a>: ${escapeHtml(finding.title)}`);
+            } catch (error) {
+                uiService.showModalMessage("Error", `Failed to promote log ${escapeHtml(String(logId))}: ${escapeHtml(error.message)}`);
+            }
+            return true;
+        },
+        () => {},
+        "Create Draft", "Cancel", true
+    );
+}
+
 async function handleLogAddAsFinding(logId) {
     const appState = stateService.getState();
     const currentTargetId = appState.currentTargetId;
@@ -694,7 +749,7 @@ function openMoreActionsDropdown(event) {
             <li><a href="#proxy-log-detail?id=${logId}" data-action="view-detail">View Details</a></li>
             <li><a href="#proxy-log-detail?id=${logId}&tab=jsAnalysisTab" data-action="analyze-js">Analyze JS</a></li>
             <li><a href="#" data-action="find-comments-dropdown" data-log-id="${logId}">Find Comments</a></li>
-            <li><a href="#" data-action="send-to-findings" data-log-id="${logId}">Send to Findings (TBD)</a></li>
+            <li><a href="#" data-action="promote-to-finding" data-log-id="${logId}">Promote to Draft Finding</a></li>
             <li><a href="#" data-action="send-to-modifier-dropdown" data-log-id="${logId}">Send to Modifier</a></li>
             <li><a href="#" data-action="add-as-finding" data-log-id="${logId}">Add as Finding</a></li>
         </ul>
@@ -723,11 +778,10 @@ function openMoreActionsDropdown(event) {
         closeMoreActionsDropdown();
     });
 
-    // Placeholder for "Send to Findings"
-    dropdown.querySelector('a[data-action="send-to-findings"]').addEventListener('click', (e) => {
+    dropdown.querySelector('a[data-action="promote-to-finding"]')?.addEventListener('click', async (e) => {
         e.preventDefault();
-        uiService.showModalMessage("Not Implemented", `Sending log ID ${logId} to Findings is not yet implemented.`);
         closeMoreActionsDropdown();
+        await handlePromoteLogToFinding(logId);
     });
 
     // Add event listener for "Send to Modifier"
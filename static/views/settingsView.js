@@ -9,6 +9,7 @@ let stateService;
 
 // Module-level state for proxy exclusion rules
 let currentProxyExclusionRules = [];
+let proxyExclusionHits = {}; // rule ID -> { hits, last_hit_at }
 let currentFullAppSettings = null; // To store the full settings loaded
 
 // DOM element references (will be queried within functions or passed)
@@ -338,6 +339,13 @@ async function loadAndDisplayProxyExclusionSettings() {
 
     try {
         currentProxyExclusionRules = await apiService.getProxyExclusionRules();
+        proxyExclusionHits = {};
+        try {
+            const stats = await apiService.getProxyExclusionStats();
+            stats.forEach(s => { proxyExclusionHits[s.id] = { hits: s.hits, last_hit_at: s.last_hit_at }; });
+        } catch (statsError) {
+            console.warn("Could not load proxy exclusion hit counters:", statsError);
+        }
         renderProxyExclusionUI(container);
     } catch (error) {
         console.error("Error loading proxy exclusion settings:", error);
@@ -363,18 +371,22 @@ function renderProxyExclusionUI(container) {
                         <th>Type</th>
                         <th>Pattern</th>
                         <th>Description</th>
+                        <th>Hits</th>
                         <th>Actions</th>
                     </tr>
                 </thead>
                 <tbody>
         `;
         currentProxyExclusionRules.forEach(rule => { 
+            const hitInfo = proxyExclusionHits[rule.id];
+            const hitsTitle = hitInfo && hitInfo.last_hit_at ? `Last hit: ${new Date(hitInfo.last_hit_at).toLocaleString()}` : 'Never hit';
             tableHTML += `
                 <tr data-rule-id="${escapeHtmlAttribute(rule.id)}">
                     <td><input type="checkbox" class="proxy-exclusion-enable" ${rule.is_enabled ? 'checked' : ''}></td>
                     <td>${escapeHtml(rule.rule_type)}</td>
                     <td>${escapeHtml(rule.pattern)}</td>
                     <td>${escapeHtml(rule.description)}</td>
+                    <td title="${escapeHtmlAttribute(hitsTitle)}">${hitInfo ? hitInfo.hits : 0}</td>
                     <td><button class="danger small-button delete-proxy-exclusion-rule">Delete</button></td>
                 </tr>
             `;
@@ -409,6 +421,14 @@ function renderProxyExclusionUI(container) {
             </div>
             <button type="submit" class="primary">Add Rule</button>
         </form>
+        <form id="testProxyExclusionForm" class="inline-form" style="margin-bottom: 20px;">
+            <div class="form-group">
+                <label for="proxyExclusionTestUrl">Test a URL against the rules below (unsaved changes included):</label>
+                <input type="text" id="proxyExclusionTestUrl" required placeholder="https://www.google-analytics.com/collect?v=1">
+            </div>
+            <button type="submit" class="secondary">Test URL</button>
+            <div id="proxyExclusionTestResult" class="message-area" style="margin-top: 10px;"></div>
+        </form>
         <div id="proxyExclusionRulesList">
             ${headingHTML}
             ${saveButtonHTML}
@@ -418,6 +438,7 @@ function renderProxyExclusionUI(container) {
 
     document.getElementById('addProxyExclusionForm')?.addEventListener('submit', handleAddProxyExclusionRule);
     document.getElementById('saveProxyExclusionsBtn')?.addEventListener('click', handleSaveAllProxyExclusions);
+    document.getElementById('testProxyExclusionForm')?.addEventListener('submit', handleTestProxyExclusionUrl);
     container.querySelectorAll('.delete-proxy-exclusion-rule').forEach(btn => btn.addEventListener('click', handleDeleteProxyExclusionRule));
     container.querySelectorAll('.proxy-exclusion-enable').forEach(checkbox => checkbox.addEventListener('change', handleToggleProxyExclusionEnable));
 }
@@ -452,6 +473,34 @@ function handleAddProxyExclusionRule(event) {
     renderProxyExclusionUI(); 
 }
 
+async function handleTestProxyExclusionUrl(event) {
+    event.preventDefault();
+    const url = document.getElementById('proxyExclusionTestUrl').value.trim();
+    const resultArea = document.getElementById('proxyExclusionTestResult');
+    if (!url) return;
+
+    try {
+        const result = await apiService.testProxyExclusionRules(url, currentProxyExclusionRules);
+        const matched = result.rules.find(r => r.id === result.matched_rule_id);
+        const notes = result.rules
+            .filter(r => r.error || (r.matches && !r.is_enabled))
+            .map(r => r.error
+                ? `Rule "${escapeHtml(r.pattern)}" can never match: ${escapeHtml(r.error)}`
+                : `Disabled rule "${escapeHtml(r.pattern)}" would match if enabled.`);
+        let html = result.excluded
+            ? `Excluded by ${escapeHtml(matched.rule_type)} rule "${escapeHtml(matched.pattern)}". The proxy would not log this request.`
+            : 'Not excluded. The proxy would log this request (if it is in scope of the current target).';
+        if (notes.length > 0) {
+            html += '<br>' + notes.join('<br>');
+        }
+        resultArea.innerHTML = html;
+        resultArea.className = `message-area ${result.excluded ? 'success-message' : 'info-message'}`;
+    } catch (error) {
+        resultArea.textContent = `Error testing URL: ${error.message}`;
+        resultArea.className = 'message-area error-message';
+    }
+}
+
 function handleDeleteProxyExclusionRule(event) {
     const ruleId = event.target.closest('tr').getAttribute('data-rule-id');
     currentProxyExclusionRules = currentProxyExclusionRules.filter(rule => rule.id !== ruleId);
@@ -0,0 +1,450 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"toolkit/logger"
+	"toolkit/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Options configure the terminal UI.
+type Options struct {
+	TargetID     int64         // Target to show; 0 uses the proxy's current target
+	Limit        int           // Newest log entries kept in the traffic pane
+	PollInterval time.Duration // How often new traffic is loaded
+}
+
+type focusPane int
+
+const (
+	focusList focusPane = iota
+	focusDetail
+)
+
+type inputKind int
+
+const (
+	inputNone inputKind = iota
+	inputFilter
+	inputTag
+)
+
+// statusTimeout is how long a status message replaces the key help.
+const statusTimeout = 5 * time.Second
+
+// app is the bubbletea model of the terminal UI. Bubbletea hands it key presses, resizes and poll
+// ticks one at a time and draws what View returns.
+type app struct {
+	opts          Options
+	width, height int
+
+	target models.Target // ID 0 until a target is picked
+	scope  []models.ScopeRule
+	logs   []models.HTTPTrafficLog // Newest first
+	total  int64
+
+	visible    []int // Indexes into logs after the filter
+	cursor     int   // Index into visible
+	listTop    int   // First row of visible shown
+	selectedID int64
+
+	focus     focusPane
+	detailID  int64
+	detail    []string
+	detailTop int
+	showScope bool
+
+	filter        string
+	favoritesOnly bool
+
+	input     inputKind
+	inputText string
+
+	pickingTarget bool
+	targets       []models.Target
+	targetCursor  int
+
+	loadingTraffic bool  // A traffic load is running; ticks skip loading until it is done
+	proxyTargetID  int64 // Target the proxy logs traffic for, as of the last traffic load
+
+	status    string
+	statusErr bool
+	statusAt  time.Time
+}
+
+// tickMsg asks the UI to load new traffic.
+type tickMsg time.Time
+
+// Run starts the terminal UI on the terminal and blocks until the user quits.
+func Run(opts Options) error {
+	if opts.Limit <= 0 {
+		opts.Limit = 500
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	// Errors are logged to stderr as well as to the app log; keep them off the screen meanwhile
+	if logger.ErrorLogger != nil {
+		logger.ErrorLogger.SetOutput(io.Discard)
+		defer logger.ErrorLogger.SetOutput(os.Stderr)
+	}
+	if _, err := tea.NewProgram(&app{opts: opts}, tea.WithAltScreen()).Run(); err != nil {
+		return fmt.Errorf("toolkit tui needs an interactive terminal: %w", err)
+	}
+	return nil
+}
+
+// Init loads the target to follow and starts polling for new traffic.
+func (a *app) Init() tea.Cmd {
+	return tea.Batch(startCmd(a.opts.TargetID), a.tick())
+}
+
+func (a *app) tick() tea.Cmd {
+	return tea.Tick(a.opts.PollInterval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+// Update applies a message to the UI. It never touches the database: loads and changes run in the
+// commands it returns, and their results come back as messages.
+func (a *app) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		a.width, a.height = msg.Width, msg.Height
+	case tickMsg:
+		if a.loadingTraffic {
+			return a, a.tick()
+		}
+		return a, tea.Batch(a.refresh(false), a.tick())
+	case tea.KeyMsg:
+		// Typed fast or pasted, several characters arrive in one message
+		if a.input != inputNone && msg.Type == tea.KeyRunes {
+			a.inputText += sanitize(string(msg.Runes))
+			return a, nil
+		}
+		return a, a.handleKey(msg.String())
+	case errMsg:
+		a.setError(msg.action, msg.err)
+	case statusMsg:
+		a.setStatus(string(msg), false)
+	case startMsg:
+		if msg.targetID == 0 {
+			a.setStatus("No current target: pick the target to follow.", false)
+			return a, loadTargetsCmd()
+		}
+		return a, loadTargetCmd(msg.targetID, "")
+	case targetLoadedMsg:
+		return a, a.applyTarget(msg)
+	case trafficLoadedMsg:
+		return a, a.applyTraffic(msg)
+	case detailLoadedMsg:
+		if msg.logID == a.detailID {
+			a.detail, a.detailTop = msg.lines, 0
+		}
+	case targetsLoadedMsg:
+		a.openTargetPicker(msg.targets)
+	case favoriteMsg:
+		return a, a.applyFavorite(msg)
+	case taggedMsg:
+		a.setStatus(fmt.Sprintf("Tagged #%d with '%s'.", msg.logID, msg.tag.Name), false)
+		if msg.logID == a.detailID {
+			return a, loadDetailCmd(msg.logID)
+		}
+	}
+	return a, nil
+}
+
+func (a *app) setStatus(message string, isErr bool) {
+	a.status, a.statusErr, a.statusAt = message, isErr, time.Now()
+}
+
+func (a *app) setError(action string, err error) {
+	logger.Error("TUI: %s: %v", action, err)
+	a.setStatus(action+": "+err.Error(), true)
+}
+
+// applyTarget switches the panes to a loaded target and loads its traffic.
+func (a *app) applyTarget(msg targetLoadedMsg) tea.Cmd {
+	if msg.scopeErr != nil {
+		a.setError("Loading scope", msg.scopeErr)
+	}
+	if msg.status != "" {
+		a.setStatus(msg.status, false)
+	}
+	a.target, a.scope = msg.target, msg.scope
+	a.logs, a.visible, a.total = nil, nil, 0
+	a.cursor, a.listTop, a.selectedID = 0, 0, 0
+	a.detailID, a.detail, a.detailTop = 0, nil, 0
+	return a.refresh(false)
+}
+
+// refresh loads the newest traffic of the target in a command.
+func (a *app) refresh(manual bool) tea.Cmd {
+	if a.target.ID == 0 {
+		return nil
+	}
+	a.loadingTraffic = true
+	return loadTrafficCmd(a.target.ID, a.opts.Limit, manual)
+}
+
+// applyTraffic shows loaded traffic. The selection stays on the same entry, except at the top of
+// the list where it follows new traffic.
+func (a *app) applyTraffic(msg trafficLoadedMsg) tea.Cmd {
+	a.loadingTraffic = false
+	if msg.targetID != a.target.ID {
+		return nil
+	}
+	if msg.err != nil {
+		a.setError("Loading traffic", msg.err)
+		return nil
+	}
+	if msg.manual {
+		a.setStatus("Refreshed.", false)
+	}
+	follow := a.cursor == 0
+	a.logs, a.total, a.proxyTargetID = msg.logs, msg.total, msg.proxyTargetID
+	return a.applyFilter(follow)
+}
+
+// applyFilter recomputes the visible entries and keeps the selection on the selected entry.
+func (a *app) applyFilter(follow bool) tea.Cmd {
+	needle := strings.ToLower(a.filter)
+	a.visible = a.visible[:0]
+	for i, l := range a.logs {
+		if a.favoritesOnly && !l.IsFavorite {
+			continue
+		}
+		if needle != "" && !strings.Contains(strings.ToLower(logSummary(l)), needle) {
+			continue
+		}
+		a.visible = append(a.visible, i)
+	}
+
+	a.cursor = 0
+	if !follow {
+		for i, idx := range a.visible {
+			if a.logs[idx].ID == a.selectedID {
+				a.cursor = i
+				break
+			}
+		}
+	}
+	return a.selectCursor()
+}
+
+// logSummary is the text the filter matches against.
+func logSummary(l models.HTTPTrafficLog) string {
+	return l.RequestMethod.String + " " + l.RequestURL.String + " " + strconv.Itoa(l.ResponseStatusCode)
+}
+
+func (a *app) selectedLog() (models.HTTPTrafficLog, bool) {
+	if a.cursor < 0 || a.cursor >= len(a.visible) {
+		return models.HTTPTrafficLog{}, false
+	}
+	return a.logs[a.visible[a.cursor]], true
+}
+
+// selectCursor clamps the cursor and loads the detail of the selected entry when it changed.
+func (a *app) selectCursor() tea.Cmd {
+	if a.cursor >= len(a.visible) {
+		a.cursor = len(a.visible) - 1
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+	l, ok := a.selectedLog()
+	if !ok {
+		a.selectedID, a.detailID, a.detail = 0, 0, nil
+		return nil
+	}
+	a.selectedID = l.ID
+	if a.detailID == l.ID {
+		return nil
+	}
+	// Results for entries passed over while scrolling are dropped when they arrive
+	a.detailID, a.detail, a.detailTop = l.ID, []string{fmt.Sprintf("Loading #%d...", l.ID)}, 0
+	return loadDetailCmd(l.ID)
+}
+
+// handleKey applies a key press and returns the command it starts, tea.Quit to exit.
+func (a *app) handleKey(key string) tea.Cmd {
+	if a.input != inputNone && key != keyCtrlC {
+		return a.handleInputKey(key)
+	}
+	if key == "q" || key == keyCtrlC {
+		return tea.Quit
+	}
+	if a.pickingTarget {
+		return a.handleTargetPickerKey(key)
+	}
+
+	switch key {
+	case keyTab:
+		if a.focus == focusList {
+			a.focus = focusDetail
+		} else {
+			a.focus = focusList
+		}
+	case keyEnter:
+		a.focus = focusDetail
+	case keyEsc:
+		switch {
+		case a.focus == focusDetail:
+			a.focus = focusList
+		case a.showScope:
+			a.showScope = false
+		case a.filter != "":
+			a.filter = ""
+			return a.applyFilter(false)
+		}
+	case keyUp, "k":
+		return a.move(-1)
+	case keyDown, "j":
+		return a.move(1)
+	case keyPgUp:
+		return a.move(-a.pageSize())
+	case keyPgDown:
+		return a.move(a.pageSize())
+	case keyHome, "g":
+		return a.move(-1 << 30)
+	case keyEnd, "G":
+		return a.move(1 << 30)
+	case "/":
+		a.input, a.inputText = inputFilter, a.filter
+	case "F":
+		a.favoritesOnly = !a.favoritesOnly
+		return a.applyFilter(false)
+	case "f":
+		if l, ok := a.selectedLog(); ok {
+			return setFavoriteCmd(l.ID, !l.IsFavorite)
+		}
+	case "a":
+		if _, ok := a.selectedLog(); ok {
+			a.input, a.inputText = inputTag, ""
+		}
+	case "m":
+		if l, ok := a.selectedLog(); ok {
+			return sendToModifierCmd(l.ID)
+		}
+	case "t":
+		return loadTargetsCmd()
+	case "s":
+		a.showScope = !a.showScope
+		a.detailTop = 0
+	case "r":
+		return a.refresh(true)
+	}
+	return nil
+}
+
+// pageSize is the number of rows PgUp/PgDn move in the focused pane.
+func (a *app) pageSize() int {
+	listRows, detailRows := a.paneHeights()
+	if a.focus == focusDetail {
+		return max(detailRows-1, 1)
+	}
+	return max(listRows-2, 1)
+}
+
+func (a *app) move(delta int) tea.Cmd {
+	if a.focus == focusDetail {
+		a.detailTop += delta
+		if maxTop := len(a.detailContent()) - 1; a.detailTop > maxTop {
+			a.detailTop = maxTop
+		}
+		if a.detailTop < 0 {
+			a.detailTop = 0
+		}
+		return nil
+	}
+	a.cursor += delta
+	return a.selectCursor()
+}
+
+func (a *app) handleInputKey(key string) tea.Cmd {
+	switch key {
+	case keyEsc:
+		a.input = inputNone
+	case keyEnter:
+		text := strings.TrimSpace(a.inputText)
+		kind := a.input
+		a.input = inputNone
+		if kind == inputFilter {
+			a.filter = text
+			return a.applyFilter(false)
+		}
+		if l, ok := a.selectedLog(); ok && text != "" {
+			return tagLogCmd(l.ID, text)
+		}
+	case keyBackspace:
+		if r := []rune(a.inputText); len(r) > 0 {
+			a.inputText = string(r[:len(r)-1])
+		}
+	case keyCtrlU:
+		a.inputText = ""
+	default:
+		if len([]rune(key)) == 1 {
+			a.inputText += key
+		}
+	}
+	return nil
+}
+
+func (a *app) openTargetPicker(targets []models.Target) {
+	if len(targets) == 0 {
+		a.setStatus("No targets yet: create one with 'toolkit target add' or the web UI.", true)
+		return
+	}
+	a.targets, a.targetCursor, a.pickingTarget = targets, 0, true
+	for i, t := range targets {
+		if t.ID == a.target.ID {
+			a.targetCursor = i
+		}
+	}
+}
+
+func (a *app) handleTargetPickerKey(key string) tea.Cmd {
+	switch key {
+	case keyEsc:
+		a.pickingTarget = false
+	case keyUp, "k":
+		a.targetCursor = max(a.targetCursor-1, 0)
+	case keyDown, "j":
+		a.targetCursor = min(a.targetCursor+1, len(a.targets)-1)
+	case keyEnter:
+		a.pickingTarget = false
+		return switchTargetCmd(a.targets[a.targetCursor])
+	}
+	return nil
+}
+
+// applyFavorite marks a log entry a favorite or not once the database has it.
+func (a *app) applyFavorite(msg favoriteMsg) tea.Cmd {
+	for i := range a.logs {
+		if a.logs[i].ID == msg.logID {
+			a.logs[i].IsFavorite = msg.favorite
+		}
+	}
+	if msg.favorite {
+		a.setStatus(fmt.Sprintf("Added #%d to favorites.", msg.logID), false)
+	} else {
+		a.setStatus(fmt.Sprintf("Removed #%d from favorites.", msg.logID), false)
+	}
+	if a.favoritesOnly {
+		return a.applyFilter(false)
+	}
+	return nil
+}
+
+// detailContent is what the detail pane shows: the selected entry or the scope of the target.
+func (a *app) detailContent() []string {
+	if a.showScope {
+		return scopeLines(a.target, a.scope)
+	}
+	return a.detail
+}
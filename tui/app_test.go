@@ -0,0 +1,185 @@
+package tui
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"toolkit/database"
+	"toolkit/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// newTestApp returns a UI sized 80x20 following a target with three log entries, newest first.
+// The database is empty, so loading details fails the way it does for deleted entries.
+func newTestApp(t *testing.T) *app {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = saved
+		db.Close()
+	})
+
+	a := &app{opts: Options{Limit: 500}}
+	a.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	a.target = models.Target{ID: 1, Codename: "acme"}
+	for i, u := range []string{"https://acme.test/api/users", "https://acme.test/login", "https://acme.test/api/orders"} {
+		a.logs = append(a.logs, models.HTTPTrafficLog{
+			ID:                 int64(3 - i),
+			RequestMethod:      sql.NullString{String: "GET", Valid: true},
+			RequestURL:         sql.NullString{String: u, Valid: true},
+			ResponseStatusCode: 200,
+		})
+	}
+	a.total = 3
+	settle(a, a.applyFilter(true))
+	return a
+}
+
+// settle runs a command and the commands its results start, feeding their messages to the UI the
+// way bubbletea does. It must not be given poll ticks.
+func settle(a *app, cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		for _, c := range msg {
+			settle(a, c)
+		}
+	default:
+		_, next := a.Update(msg)
+		settle(a, next)
+	}
+}
+
+// press sends keys to the UI as bubbletea reports them and returns the last command.
+func press(a *app, keys ...tea.KeyMsg) tea.Cmd {
+	var cmd tea.Cmd
+	for _, k := range keys {
+		_, cmd = a.Update(k)
+	}
+	return cmd
+}
+
+func runes(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+func TestViewFillsTheTerminal(t *testing.T) {
+	a := newTestApp(t)
+	lines := strings.Split(a.View(), "\n")
+	if len(lines) != 20 {
+		t.Fatalf("View has %d lines, want 20", len(lines))
+	}
+	for i, l := range lines {
+		if w := lipgloss.Width(l); w != 80 {
+			t.Errorf("line %d is %d columns wide, want 80: %q", i, w, l)
+		}
+	}
+	if view := a.View(); !strings.Contains(view, "acme (#1)") || !strings.Contains(view, "/api/orders") {
+		t.Errorf("View lacks the target or its traffic:\n%s", view)
+	}
+	if (&app{}).View() != "" {
+		t.Error("View before the terminal size is known is not empty")
+	}
+}
+
+func TestNavigationAndFilter(t *testing.T) {
+	a := newTestApp(t)
+	press(a, tea.KeyMsg{Type: tea.KeyDown}, runes("j"))
+	if l, _ := a.selectedLog(); l.ID != 1 {
+		t.Fatalf("after two moves down the selection is #%d, want #1", l.ID)
+	}
+	press(a, tea.KeyMsg{Type: tea.KeyDown})
+	if a.cursor != 2 {
+		t.Errorf("the cursor moved past the last entry: %d", a.cursor)
+	}
+
+	// "q" is typed into the filter instead of quitting; a message may carry several characters.
+	press(a, runes("/"), runes("q"), tea.KeyMsg{Type: tea.KeyBackspace}, runes("/api"), tea.KeyMsg{Type: tea.KeyEnter})
+	if a.filter != "/api" || len(a.visible) != 2 {
+		t.Fatalf("filter %q shows %d entries, want 2", a.filter, len(a.visible))
+	}
+	if l, _ := a.selectedLog(); l.ID != 1 {
+		t.Errorf("the selection moved to #%d when filtering, want #1", l.ID)
+	}
+	press(a, tea.KeyMsg{Type: tea.KeyEsc})
+	if a.filter != "" || len(a.visible) != 3 {
+		t.Errorf("Esc left filter %q with %d entries", a.filter, len(a.visible))
+	}
+}
+
+func TestFocusAndQuit(t *testing.T) {
+	a := newTestApp(t)
+	press(a, tea.KeyMsg{Type: tea.KeyTab})
+	if a.focus != focusDetail {
+		t.Error("Tab did not focus the detail pane")
+	}
+	if a.detailID != 3 || len(a.detail) == 0 || !strings.HasPrefix(a.detail[0], "Could not load log entry") {
+		t.Errorf("the detail pane shows #%d: %v", a.detailID, a.detail)
+	}
+	press(a, tea.KeyMsg{Type: tea.KeyEsc})
+	if a.focus != focusList {
+		t.Error("Esc did not go back to the list")
+	}
+	if cmd := press(a, runes("q")); cmd == nil {
+		t.Fatal("q did not quit")
+	} else if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("q returned a command other than tea.Quit")
+	}
+	if cmd := press(a, tea.KeyMsg{Type: tea.KeyCtrlC}); cmd == nil {
+		t.Error("Ctrl+C did not quit")
+	}
+}
+
+func TestLoadsRunInCommands(t *testing.T) {
+	a := newTestApp(t)
+	cmd := press(a, tea.KeyMsg{Type: tea.KeyDown})
+	if cmd == nil || a.detailID != 2 || !strings.HasPrefix(a.detail[0], "Loading") {
+		t.Fatalf("moving down loaded the detail in Update: #%d %v", a.detailID, a.detail)
+	}
+	// A result for an entry the selection has moved on from is dropped
+	a.Update(detailLoadedMsg{logID: 3, lines: []string{"stale"}})
+	if a.detail[0] == "stale" {
+		t.Error("a stale detail replaced the selected entry's")
+	}
+	settle(a, cmd)
+	if !strings.HasPrefix(a.detail[0], "Could not load log entry") {
+		t.Errorf("the loaded detail was not shown: %v", a.detail)
+	}
+
+	_, cmd = a.Update(tickMsg{})
+	if cmd == nil || !a.loadingTraffic {
+		t.Fatal("a tick did not start loading traffic")
+	}
+	if _, next := a.Update(tickMsg{}); next == nil {
+		t.Error("a tick during a load stopped polling")
+	}
+	newest := models.HTTPTrafficLog{ID: 4, RequestURL: sql.NullString{String: "https://acme.test/new", Valid: true}}
+	a.Update(trafficLoadedMsg{targetID: 1, logs: append([]models.HTTPTrafficLog{newest}, a.logs...), total: 4, proxyTargetID: 1})
+	if a.loadingTraffic || a.total != 4 || a.selectedID != 2 {
+		t.Errorf("loaded traffic left loading %t, total %d, selection #%d", a.loadingTraffic, a.total, a.selectedID)
+	}
+	if strings.Contains(a.headerText(), "not the proxy's current target") {
+		t.Error("the header calls the proxy's current target another one")
+	}
+	a.Update(trafficLoadedMsg{targetID: 7, total: 99})
+	if a.total != 4 {
+		t.Error("traffic of another target was shown")
+	}
+}
+
+func TestSanitizeKeepsTrafficFromDrivingTheTerminal(t *testing.T) {
+	got := sanitize("GET /\x1b[2J\x1b]0;title\x07 HTTP/1.1\tx")
+	if strings.ContainsAny(got, "\x1b\x07") || !strings.Contains(got, "\t") {
+		t.Errorf("sanitize = %q", got)
+	}
+	if got := fit("\x1b[1mabc\x1b[0m\tdéf", 8); lipgloss.Width(got) != 8 || !strings.HasPrefix(got, "\x1b[1mabc") {
+		t.Errorf("fit = %q (%d columns)", got, lipgloss.Width(got))
+	}
+}
@@ -0,0 +1,320 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"toolkit/core"
+	"toolkit/database"
+	"toolkit/logger"
+	"toolkit/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxDetailBodyLines caps the body lines shown in the detail pane so huge responses stay responsive.
+const maxDetailBodyLines = 2000
+
+// currentTargetID returns the target the proxy currently logs traffic for (0 when none is set).
+func currentTargetID() int64 {
+	value, err := database.GetSetting(models.CurrentTargetIDKey)
+	if err != nil || value == "" {
+		return 0
+	}
+	id, _ := strconv.ParseInt(value, 10, 64)
+	return id
+}
+
+// setCurrentTarget switches the proxy to a target, the same way the web UI's target selector does.
+func setCurrentTarget(targetID int64) error {
+	return database.SetSetting(models.CurrentTargetIDKey, strconv.FormatInt(targetID, 10))
+}
+
+// loadTraffic returns the newest log entries of a target, newest first, and how many it has.
+func loadTraffic(targetID int64, limit int) ([]models.HTTPTrafficLog, int64, error) {
+	return database.GetHTTPTrafficLogEntries(models.ProxyLogFilters{
+		TargetID:  targetID,
+		Page:      1,
+		Limit:     limit,
+		SortBy:    "id",
+		SortOrder: "DESC",
+	})
+}
+
+// scopeSummary counts the in-scope and out-of-scope rules of a target.
+func scopeSummary(rules []models.ScopeRule) (int, int) {
+	var in, out int
+	for _, r := range rules {
+		if r.IsInScope {
+			in++
+		} else {
+			out++
+		}
+	}
+	return in, out
+}
+
+// scopeLines lists the scope rules of a target for the detail pane.
+func scopeLines(target models.Target, rules []models.ScopeRule) []string {
+	in, out := scopeSummary(rules)
+	lines := []string{fmt.Sprintf("Scope of %s (#%d): %d in scope, %d out of scope", target.Codename, target.ID, in, out), ""}
+	for _, inScope := range []bool{true, false} {
+		if inScope {
+			lines = append(lines, "In scope:")
+		} else {
+			lines = append(lines, "", "Out of scope:")
+		}
+		if (inScope && in == 0) || (!inScope && out == 0) {
+			lines = append(lines, "  (none)")
+		}
+		for _, r := range rules {
+			if r.IsInScope != inScope {
+				continue
+			}
+			line := fmt.Sprintf("  %-10s %s", r.ItemType, r.Pattern)
+			if r.Description != "" {
+				line += "  (" + r.Description + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// detailLines renders a log entry as a raw request and response for the detail pane.
+func detailLines(entry models.HTTPTrafficLog, tags []models.Tag) []string {
+	var lines []string
+	version := entry.RequestHTTPVersion.String
+	if version == "" {
+		version = "HTTP/1.1"
+	}
+	lines = append(lines, fmt.Sprintf("%s %s %s", entry.RequestMethod.String, entry.RequestURL.String, version))
+	reqHeaders := core.HeadersFromJSON(entry.RequestHeaders.String)
+	lines = append(lines, headerLines(reqHeaders)...)
+	if len(entry.RequestBody) > 0 {
+		body, _ := core.DecodeContentEncoding(entry.RequestBody, reqHeaders.Get("Content-Encoding"))
+		lines = append(lines, "")
+		lines = append(lines, bodyLines(body, reqHeaders.Get("Content-Type"))...)
+	}
+
+	meta := fmt.Sprintf("#%d  %s  %d ms  %s", entry.ID, entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.DurationMs, formatSize(entry.ResponseBodySize))
+	if entry.ResponseBodyTruncated {
+		meta += " (body truncated)"
+	}
+	if entry.LogSource.String != "" {
+		meta += "  source: " + entry.LogSource.String
+	}
+	if len(tags) > 0 {
+		names := make([]string, len(tags))
+		for i, t := range tags {
+			names[i] = t.Name
+		}
+		meta += "  tags: " + strings.Join(names, ", ")
+	}
+	lines = append(lines, "", strings.Repeat("─", 20)+" "+meta, "")
+
+	if entry.ResponseStatusCode == 0 {
+		return append(lines, "(no response)")
+	}
+	respVersion := entry.ResponseHTTPVersion.String
+	if respVersion == "" {
+		respVersion = "HTTP/1.1"
+	}
+	lines = append(lines, strings.TrimSpace(fmt.Sprintf("%s %d %s", respVersion, entry.ResponseStatusCode, entry.ResponseReasonPhrase.String)))
+	respHeaders := core.HeadersFromJSON(entry.ResponseHeaders.String)
+	lines = append(lines, headerLines(respHeaders)...)
+	if len(entry.ResponseBody) > 0 {
+		body, _ := core.DecodeContentEncoding(entry.ResponseBody, respHeaders.Get("Content-Encoding"))
+		lines = append(lines, "")
+		lines = append(lines, bodyLines(body, entry.ResponseContentType.String)...)
+	}
+	return lines
+}
+
+func headerLines(headers map[string][]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		for _, v := range headers[name] {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	return lines
+}
+
+// bodyLines pretty-prints a body the same way the web UI's pretty view does.
+func bodyLines(body []byte, contentType string) []string {
+	pretty := core.PrettyPrintBody(body, contentType)
+	if pretty.Body == "" && pretty.Error != "" {
+		return []string{"(" + pretty.Error + ")"}
+	}
+	lines := strings.Split(strings.ReplaceAll(pretty.Body, "\r\n", "\n"), "\n")
+	if len(lines) > maxDetailBodyLines {
+		lines = append(lines[:maxDetailBodyLines], fmt.Sprintf("... %d more lines", len(lines)-maxDetailBodyLines))
+	}
+	return lines
+}
+
+// tagLog adds a tag to a log entry, creating the tag when no tag has that name yet.
+func tagLog(logID int64, name string) (models.Tag, error) {
+	tag, err := database.CreateTag(models.Tag{Name: name})
+	if err != nil {
+		return tag, err
+	}
+	if _, err := database.AssociateTagWithItem(tag.ID, logID, models.TagItemHTTPLog); err != nil {
+		return tag, err
+	}
+	return tag, nil
+}
+
+// sendToModifier creates a Modifier task from a log entry.
+func sendToModifier(logID int64) (*models.ModifierTask, error) {
+	return database.CreateModifierTaskFromSource(models.AddModifierTaskRequest{HTTPTrafficLogID: logID})
+}
+
+// The commands below run in bubbletea's goroutines, off the event loop, and report back with
+// these messages.
+type (
+	errMsg struct {
+		action string
+		err    error
+	}
+	statusMsg string
+	startMsg  struct{ targetID int64 }
+
+	targetLoadedMsg struct {
+		target   models.Target
+		scope    []models.ScopeRule
+		scopeErr error
+		status   string // Shown once the target is loaded
+	}
+	trafficLoadedMsg struct {
+		targetID      int64
+		logs          []models.HTTPTrafficLog
+		total         int64
+		proxyTargetID int64
+		manual        bool // Asked for with "r"
+		err           error
+	}
+	detailLoadedMsg struct {
+		logID int64
+		lines []string
+	}
+	targetsLoadedMsg struct{ targets []models.Target }
+	favoriteMsg      struct {
+		logID    int64
+		favorite bool
+	}
+	taggedMsg struct {
+		logID int64
+		tag   models.Tag
+	}
+)
+
+// startCmd resolves the target to follow: the one asked for, else the proxy's current target.
+func startCmd(targetID int64) tea.Cmd {
+	return func() tea.Msg {
+		if targetID == 0 {
+			targetID = currentTargetID()
+		}
+		return startMsg{targetID: targetID}
+	}
+}
+
+func loadTargetCmd(targetID int64, status string) tea.Cmd {
+	return func() tea.Msg { return loadTarget(targetID, status) }
+}
+
+func loadTarget(targetID int64, status string) tea.Msg {
+	target, err := database.GetTargetByID(targetID)
+	if err != nil {
+		return errMsg{"Loading target", err}
+	}
+	rules, err := database.GetScopeRulesByTargetID(targetID)
+	return targetLoadedMsg{target: target, scope: rules, scopeErr: err, status: status}
+}
+
+// switchTargetCmd makes a target the proxy's current target and loads it.
+func switchTargetCmd(t models.Target) tea.Cmd {
+	return func() tea.Msg {
+		if err := setCurrentTarget(t.ID); err != nil {
+			return errMsg{"Switching target", err}
+		}
+		return loadTarget(t.ID, fmt.Sprintf("The proxy now logs traffic for %s.", t.Codename))
+	}
+}
+
+func loadTargetsCmd() tea.Cmd {
+	return func() tea.Msg {
+		targets, err := database.GetTargets(nil)
+		if err != nil {
+			return errMsg{"Loading targets", err}
+		}
+		return targetsLoadedMsg{targets}
+	}
+}
+
+func loadTrafficCmd(targetID int64, limit int, manual bool) tea.Cmd {
+	return func() tea.Msg {
+		logs, total, err := loadTraffic(targetID, limit)
+		return trafficLoadedMsg{targetID: targetID, logs: logs, total: total, proxyTargetID: currentTargetID(), manual: manual, err: err}
+	}
+}
+
+func loadDetailCmd(logID int64) tea.Cmd {
+	return func() tea.Msg {
+		entry, err := database.GetHTTPTrafficLogEntryByID(logID)
+		if err != nil {
+			return detailLoadedMsg{logID, []string{"Could not load log entry: " + err.Error()}}
+		}
+		tags, err := database.GetTagsForItem(logID, models.TagItemHTTPLog)
+		if err != nil {
+			logger.Error("TUI: Loading tags of log %d: %v", logID, err)
+		}
+		return detailLoadedMsg{logID, detailLines(entry, tags)}
+	}
+}
+
+func setFavoriteCmd(logID int64, favorite bool) tea.Cmd {
+	return func() tea.Msg {
+		if err := database.SetHTTPTrafficLogFavorite(logID, favorite); err != nil {
+			return errMsg{"Updating favorite", err}
+		}
+		return favoriteMsg{logID, favorite}
+	}
+}
+
+func tagLogCmd(logID int64, name string) tea.Cmd {
+	return func() tea.Msg {
+		tag, err := tagLog(logID, name)
+		if err != nil {
+			return errMsg{"Tagging", err}
+		}
+		return taggedMsg{logID, tag}
+	}
+}
+
+func sendToModifierCmd(logID int64) tea.Cmd {
+	return func() tea.Msg {
+		task, err := sendToModifier(logID)
+		if err != nil {
+			return errMsg{"Sending to Modifier", err}
+		}
+		return statusMsg(fmt.Sprintf("Sent #%d to the Modifier as task #%d (%s).", logID, task.ID, task.Name))
+	}
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,18 @@
+package tui
+
+// Names of the non-printable keys the UI reacts to, as bubbletea names them (tea.KeyMsg.String).
+// Printable keys are the character.
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyPgUp      = "pgup"
+	keyPgDown    = "pgdown"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyEnter     = "enter"
+	keyTab       = "tab"
+	keyEsc       = "esc"
+	keyBackspace = "backspace"
+	keyCtrlC     = "ctrl+c"
+	keyCtrlU     = "ctrl+u"
+)
@@ -0,0 +1,288 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"toolkit/config"
+	"toolkit/models"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Styles used by the views. Lipgloss drops the colors on terminals without them.
+var (
+	stylePlain    = lipgloss.NewStyle()
+	styleBold     = lipgloss.NewStyle().Bold(true)
+	styleDim      = lipgloss.NewStyle().Faint(true)
+	styleSelected = lipgloss.NewStyle().Reverse(true)
+	styleHeader   = lipgloss.NewStyle().Reverse(true).Bold(true)
+	styleFocused  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("6"))
+	styleError    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	styleSuccess  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	styleWarning  = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	styleRedirect = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+)
+
+const keyHelp = "↑↓ move  tab detail  / filter  F favorites  f fav  a tag  m modifier  t target  s scope  r refresh  q quit"
+
+// paneHeights splits the rows between the header and status lines into the traffic list (with its
+// column header) and the detail pane (below its title bar).
+func (a *app) paneHeights() (int, int) {
+	rows := a.height - 3
+	if rows < 2 {
+		return 1, 1
+	}
+	list := max(rows*2/5, 3)
+	if list > rows-1 {
+		list = rows - 1
+	}
+	return list, rows - list
+}
+
+// View draws the whole screen: the header, the traffic list (or target picker), the detail pane
+// and the status line, one line per terminal row.
+func (a *app) View() string {
+	if a.width == 0 || a.height == 0 {
+		return "" // Until bubbletea reports the terminal size
+	}
+	listRows, detailRows := a.paneHeights()
+	var b strings.Builder
+	line := func(style lipgloss.Style, text string) {
+		b.WriteString(style.Render(fit(text, a.width)) + "\n")
+	}
+
+	line(styleHeader, a.headerText())
+
+	if a.pickingTarget {
+		a.renderTargetPicker(line, listRows)
+	} else {
+		a.renderList(line, listRows)
+	}
+
+	content := a.detailContent()
+	title := "─ Request "
+	if a.showScope {
+		title = "─ Scope "
+	} else if a.detailID == 0 {
+		title = "─ Detail "
+	} else {
+		title += fmt.Sprintf("#%d ", a.detailID)
+	}
+	if len(content) > detailRows {
+		title += fmt.Sprintf("[%d-%d/%d] ", a.detailTop+1, min(a.detailTop+detailRows, len(content)), len(content))
+	}
+	titleStyle := styleDim
+	if a.focus == focusDetail {
+		titleStyle = styleFocused
+	}
+	line(titleStyle, title+strings.Repeat("─", max(a.width-utf8.RuneCountInString(title), 0)))
+	for i := 0; i < detailRows; i++ {
+		if n := a.detailTop + i; n < len(content) {
+			line(stylePlain, sanitize(content[n]))
+		} else {
+			line(stylePlain, "")
+		}
+	}
+
+	// The status line is the last row; no newline so the screen does not scroll
+	status, style := keyHelp, styleDim
+	switch {
+	case a.input == inputFilter:
+		status, style = "Filter: "+sanitize(a.inputText)+"█", stylePlain
+	case a.input == inputTag:
+		status, style = "Tag name: "+sanitize(a.inputText)+"█", stylePlain
+	case a.status != "" && time.Since(a.statusAt) < statusTimeout:
+		status, style = sanitize(a.status), styleSuccess
+		if a.statusErr {
+			style = styleError
+		}
+	}
+	b.WriteString(style.Render(fit(status, a.width)))
+	return b.String()
+}
+
+func (a *app) headerText() string {
+	if a.target.ID == 0 {
+		return " toolkit │ no target selected"
+	}
+	in, out := scopeSummary(a.scope)
+	text := fmt.Sprintf(" toolkit │ %s (#%d) │ scope: %d in, %d out │ %d requests", sanitize(a.target.Codename), a.target.ID, in, out, a.total)
+	if int64(len(a.logs)) < a.total {
+		text += fmt.Sprintf(" (newest %d loaded)", len(a.logs))
+	}
+	if a.filter != "" {
+		text += fmt.Sprintf(" │ filter: %q (%d)", sanitize(a.filter), len(a.visible))
+	}
+	if a.favoritesOnly {
+		text += " │ favorites only"
+	}
+	if a.proxyTargetID != a.target.ID {
+		text += " │ not the proxy's current target"
+	}
+	return text
+}
+
+func (a *app) renderList(line func(style lipgloss.Style, text string), rows int) {
+	line(styleBold, "   ★      ID  METHOD  STATUS      SIZE  URL")
+	rows--
+
+	if a.target.ID == 0 {
+		line(stylePlain, "  No target selected. Press t to pick the target to follow.")
+		rows--
+	} else if len(a.visible) == 0 {
+		if len(a.logs) == 0 {
+			line(stylePlain, fmt.Sprintf("  No traffic yet. Browse through the proxy on 127.0.0.1:%s.", config.AppConfig.Proxy.Port))
+		} else {
+			line(stylePlain, "  No entries match the filter. Press Esc to clear it.")
+		}
+		rows--
+	}
+
+	// Keep the cursor in view
+	if a.cursor < a.listTop {
+		a.listTop = a.cursor
+	}
+	if a.cursor >= a.listTop+rows {
+		a.listTop = a.cursor - rows + 1
+	}
+	for i := 0; i < rows; i++ {
+		n := a.listTop + i
+		if n >= len(a.visible) {
+			line(stylePlain, "")
+			continue
+		}
+		l := a.logs[a.visible[n]]
+		if n == a.cursor {
+			style := styleSelected
+			if a.focus == focusDetail {
+				style = styleBold
+			}
+			line(style, "▶"+a.listRow(l, false))
+		} else {
+			line(stylePlain, " "+a.listRow(l, true))
+		}
+	}
+}
+
+// listRow formats a log entry for the traffic list, optionally coloring the status code.
+func (a *app) listRow(l models.HTTPTrafficLog, color bool) string {
+	star := " "
+	if l.IsFavorite {
+		star = "★"
+	}
+	status := "---"
+	if l.ResponseStatusCode != 0 {
+		status = fmt.Sprintf("%d", l.ResponseStatusCode)
+	}
+	status = fmt.Sprintf("%-6s", status)
+	if color {
+		status = statusStyle(l.ResponseStatusCode).Render(status)
+	}
+	method := fmt.Sprintf("%-7s", sanitize(l.RequestMethod.String))
+	return fmt.Sprintf(" %s %7d  %s %s %9s  %s", star, l.ID, method, status, formatSize(l.ResponseBodySize), sanitize(l.RequestURL.String))
+}
+
+func statusStyle(code int) lipgloss.Style {
+	switch {
+	case code >= 500:
+		return styleError
+	case code >= 400:
+		return styleWarning
+	case code >= 300:
+		return styleRedirect
+	case code >= 200:
+		return styleSuccess
+	}
+	return styleDim
+}
+
+func (a *app) renderTargetPicker(line func(style lipgloss.Style, text string), rows int) {
+	line(styleBold, "  Pick a target (enter to switch the proxy to it, esc to cancel)")
+	rows--
+	current := currentTargetID()
+	top := max(a.targetCursor-rows+1, 0)
+	for i := 0; i < rows; i++ {
+		n := top + i
+		if n >= len(a.targets) {
+			line(stylePlain, "")
+			continue
+		}
+		t := a.targets[n]
+		text := fmt.Sprintf("  #%-5d %s", t.ID, sanitize(t.Codename))
+		if t.Slug != "" {
+			text += "  (" + sanitize(t.Slug) + ")"
+		}
+		if t.ID == current {
+			text += "  ● current"
+		}
+		if n == a.targetCursor {
+			line(styleSelected, text)
+		} else {
+			line(stylePlain, text)
+		}
+	}
+}
+
+// fit pads or clips a line to width columns and expands tabs. SGR escapes (colors) are kept and
+// not counted; text coming from the database must go through sanitize first.
+func fit(text string, width int) string {
+	var b strings.Builder
+	cols := 0
+	for i := 0; i < len(text); {
+		if n := sgrLength(text[i:]); n > 0 {
+			b.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r == '\t' {
+			for n := 0; n < 4 && cols < width; n++ {
+				b.WriteByte(' ')
+				cols++
+			}
+			continue
+		}
+		if cols >= width {
+			continue
+		}
+		b.WriteRune(r)
+		cols++
+	}
+	if cols < width {
+		b.WriteString(strings.Repeat(" ", width-cols))
+	}
+	return b.String()
+}
+
+// sgrLength returns the length of the SGR escape ("\x1b[1;31m") s starts with, or 0.
+func sgrLength(s string) int {
+	if !strings.HasPrefix(s, "\x1b[") {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == 'm':
+			return i + 1
+		case c != ';' && (c < '0' || c > '9'):
+			return 0
+		}
+	}
+	return 0
+}
+
+// sanitize replaces control characters other than tabs, which logged traffic may contain, so they
+// cannot move the cursor or change the terminal state.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) || r == utf8.RuneError {
+			return '·'
+		}
+		return r
+	}, s)
+}